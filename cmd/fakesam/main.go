@@ -0,0 +1,128 @@
+// Command fakesam runs an in-memory SAM v3.3 bridge for integration tests and
+// local development without an I2P router.
+//
+// Sessions created on the server can stream to each other; nothing reaches
+// the I2P network. Destinations are derived from -seed, so repeated runs hand
+// out the same addresses. Hostnames for NAMING LOOKUP are loaded from a hosts
+// file in the I2P hosts.txt format (one "name=base64 destination" per line).
+//
+// The server runs until SIGTERM or SIGINT.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/fakesam"
+)
+
+// options holds command-line flag values.
+type options struct {
+	listen        string
+	seed          string
+	hostsPath     string
+	acceptTimeout time.Duration
+	quiet         bool
+}
+
+func main() {
+	os.Exit(run(parseFlags(os.Args[1:])))
+}
+
+// parseFlags parses command-line arguments into options.
+func parseFlags(args []string) options {
+	fs := flag.NewFlagSet("fakesam", flag.ExitOnError)
+
+	var opts options
+	fs.StringVar(&opts.listen, "listen", "127.0.0.1:7656", "Address to serve SAM on")
+	fs.StringVar(&opts.seed, "seed", fakesam.DefaultSeed, "Seed for generated destinations")
+	fs.StringVar(&opts.hostsPath, "hosts", "", "Hosts file (name=base64 destination per line) for NAMING LOOKUP")
+	fs.DurationVar(&opts.acceptTimeout, "accept-timeout", fakesam.DefaultAcceptTimeout, "How long STREAM CONNECT waits for the destination to accept")
+	fs.BoolVar(&opts.quiet, "quiet", false, "Do not log SAM commands")
+	fs.Parse(args)
+
+	return opts
+}
+
+// run serves SAM until a termination signal and returns the exit code.
+func run(opts options) int {
+	hosts, err := loadHosts(opts.hostsPath)
+	if err != nil {
+		log.Printf("Error loading hosts: %v", err)
+		return 1
+	}
+
+	serverOptions := fakesam.ServerOptions{
+		Seed:          opts.seed,
+		AcceptTimeout: opts.acceptTimeout,
+	}
+	if !opts.quiet {
+		serverOptions.Logger = log.Default()
+	}
+
+	server := fakesam.NewServer(serverOptions)
+	for name, destination := range hosts {
+		if err := server.AddHost(name, destination); err != nil {
+			log.Printf("Error loading hosts: %v", err)
+			return 1
+		}
+	}
+	if err := server.Listen(opts.listen); err != nil {
+		log.Printf("Error starting fake SAM server: %v", err)
+		return 1
+	}
+	log.Printf("Fake SAM bridge listening on %s (seed %q, %d hosts)", opts.listen, opts.seed, len(hosts))
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-signals
+	log.Printf("Received %s, shutting down", sig)
+
+	if err := server.Close(); err != nil {
+		log.Printf("Warning: Failed to close fake SAM server: %v", err)
+	}
+	return 0
+}
+
+// loadHosts reads a hosts file of name=destination lines. Blank lines and
+// lines starting with # are ignored. An empty path yields no hosts.
+func loadHosts(path string) (map[string]string, error) {
+	hosts := make(map[string]string)
+	if path == "" {
+		return hosts, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// Destinations with certificates exceed bufio's default line length
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, destination, found := strings.Cut(line, "=")
+		if !found || name == "" || destination == "" {
+			return nil, fmt.Errorf("%s:%d: expected name=destination", path, lineNumber)
+		}
+		// hosts.txt lines may carry #!key=value metadata after the destination
+		destination, _, _ = strings.Cut(destination, "#!")
+		hosts[strings.TrimSpace(name)] = strings.TrimSpace(destination)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return hosts, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFlags(t *testing.T) {
+	opts := parseFlags([]string{"-listen", "127.0.0.1:17656", "-seed", "ci", "-accept-timeout", "1s", "-quiet"})
+	if opts.listen != "127.0.0.1:17656" || opts.seed != "ci" || opts.acceptTimeout != time.Second || !opts.quiet {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+
+	opts = parseFlags(nil)
+	if opts.listen != "127.0.0.1:7656" || opts.seed != "fakesam" {
+		t.Errorf("Unexpected default options: %+v", opts)
+	}
+}
+
+func TestLoadHosts(t *testing.T) {
+	destination := strings.Repeat("A", 516)
+	path := filepath.Join(t.TempDir(), "hosts.txt")
+	data := "# test hosts\n\nforum.i2p=" + destination + "\nwiki.i2p = " + destination + "#!sig=xyz\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	hosts, err := loadHosts(path)
+	if err != nil {
+		t.Fatalf("loadHosts() unexpected error: %v", err)
+	}
+	if len(hosts) != 2 || hosts["forum.i2p"] != destination || hosts["wiki.i2p"] != destination {
+		t.Errorf("Unexpected hosts %v", hosts)
+	}
+
+	if hosts, err := loadHosts(""); err != nil || len(hosts) != 0 {
+		t.Errorf("Expected no hosts without a file, got %v, %v", hosts, err)
+	}
+
+	if err := os.WriteFile(path, []byte("forum.i2p\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+	if _, err := loadHosts(path); err == nil || !strings.Contains(err.Error(), ":1:") {
+		t.Errorf("Expected a line error, got %v", err)
+	}
+}
@@ -0,0 +1,530 @@
+// Command i2p-network-plugin runs the I2P Docker network plugin daemon.
+//
+// The daemon serves Docker's network driver API on a Unix socket and handles
+// process signals for its lifecycle:
+//   - SIGTERM, SIGINT: ordered shutdown (stop accepting, drain, release networks,
+//     iptables rules and SAM sessions). A second signal forces exit.
+//   - SIGUSR1: dump plugin state as JSON to the log.
+//   - SIGHUP: reload configuration that can change without a restart (SAM
+//     routers, tunnel options, maintenance windows, the key pool, debug
+//     logging, request tracing, exposure concurrency and naming backends).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"syscall"
+
+	"github.com/go-i2p/go-docker-network-i2p/internal/config"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/plugin"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+// Build information, set via -ldflags at build time.
+var (
+	version   = "dev"
+	buildTime = "unknown"
+	gitCommit = "unknown"
+)
+
+// options holds command-line flag values.
+type options struct {
+	sockPath    string
+	configPath  string
+	debug       bool
+	showVersion bool
+
+	// set records which flags were given explicitly
+	set map[string]bool
+}
+
+func main() {
+	opts := parseFlags(os.Args[1:])
+
+	if opts.showVersion {
+		fmt.Printf("i2p-network-plugin %s (commit %s, built %s)\n", version, gitCommit, buildTime)
+		return
+	}
+
+	os.Exit(run(opts))
+}
+
+// parseFlags parses command-line arguments into options.
+func parseFlags(args []string) options {
+	fs := flag.NewFlagSet("i2p-network-plugin", flag.ExitOnError)
+
+	var opts options
+	fs.StringVar(&opts.sockPath, "sock", "/run/docker/plugins/i2p-network.sock", "Unix socket path for plugin communication")
+	fs.StringVar(&opts.configPath, "config", "", "Path to JSON configuration file")
+	fs.BoolVar(&opts.debug, "debug", false, "Enable debug logging")
+	fs.BoolVar(&opts.showVersion, "version", false, "Show version and exit")
+	fs.Parse(args)
+
+	opts.set = make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { opts.set[f.Name] = true })
+
+	return opts
+}
+
+// loadConfig builds the configuration from defaults, file, environment and flags.
+//
+// Later sources take precedence, matching the order documented in CONFIG.md.
+func loadConfig(opts options) (*config.Config, error) {
+	cfg := config.DefaultConfig()
+
+	if opts.configPath != "" {
+		if err := cfg.LoadFromFile(opts.configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.LoadFromEnvironment(); err != nil {
+		return nil, fmt.Errorf("failed to load environment configuration: %w", err)
+	}
+
+	if opts.set["sock"] {
+		cfg.Plugin.SocketPath = opts.sockPath
+	}
+	if opts.set["debug"] {
+		cfg.Plugin.Debug = opts.debug
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// socketPermissions converts the socket settings in cfg for the plugin.
+func socketPermissions(cfg *config.Config) (plugin.SocketPermissions, plugin.SocketPermissions, error) {
+	var sockPerms, adminSockPerms plugin.SocketPermissions
+
+	mode, err := config.ParseSocketMode(cfg.Plugin.SocketMode)
+	if err != nil {
+		return sockPerms, adminSockPerms, fmt.Errorf("invalid plugin socket mode: %w", err)
+	}
+	sockPerms = plugin.SocketPermissions{Mode: mode, Owner: cfg.Plugin.SocketOwner, Group: cfg.Plugin.SocketGroup}
+
+	mode, err = config.ParseSocketMode(cfg.Plugin.AdminSocketMode)
+	if err != nil {
+		return sockPerms, adminSockPerms, fmt.Errorf("invalid admin socket mode: %w", err)
+	}
+	adminSockPerms = plugin.SocketPermissions{Mode: mode, Owner: cfg.Plugin.AdminSocketOwner, Group: cfg.Plugin.AdminSocketGroup}
+
+	return sockPerms, adminSockPerms, nil
+}
+
+// tlsListener converts the TLS listener settings in cfg for the plugin.
+func tlsListener(cfg *config.Config) plugin.TLSListenerConfig {
+	return plugin.TLSListenerConfig{
+		Address:      cfg.Plugin.TLSAddress,
+		CertFile:     cfg.Plugin.TLSCertFile,
+		KeyFile:      cfg.Plugin.TLSKeyFile,
+		ClientCAFile: cfg.Plugin.TLSClientCAFile,
+	}
+}
+
+// rateLimits converts the HTTP request limits in cfg for the plugin.
+func rateLimits(cfg *config.Config) plugin.RateLimitConfig {
+	return plugin.RateLimitConfig{
+		RequestsPerSecond: cfg.Plugin.RateLimit,
+		Burst:             cfg.Plugin.RateBurst,
+		MaxRequestBytes:   cfg.Plugin.MaxRequestBytes,
+	}
+}
+
+// dnsQueryLimits converts the DNS query limits in cfg for the plugin.
+func dnsQueryLimits(cfg *config.Config) proxy.DNSQueryLimits {
+	return proxy.DNSQueryLimits{
+		SourceQPS:   cfg.Plugin.DNSSourceQPS,
+		SourceBurst: cfg.Plugin.DNSSourceBurst,
+		GlobalQPS:   cfg.Plugin.DNSGlobalQPS,
+		GlobalBurst: cfg.Plugin.DNSGlobalBurst,
+	}
+}
+
+// addressPublisher creates the exposure address publisher configured in cfg.
+func addressPublisher(cfg *config.Config) (service.Publisher, error) {
+	return service.NewPublisher(service.PublisherConfig{
+		Backend:  cfg.Plugin.PublishBackend,
+		Target:   cfg.Plugin.PublishTarget,
+		Zone:     cfg.Plugin.PublishZone,
+		Encoding: cfg.Plugin.PublishEncoding,
+	})
+}
+
+// namingConfig returns the naming backend settings from the configuration.
+func namingConfig(cfg *config.Config) proxy.NamingConfig {
+	return proxy.NamingConfig{
+		Backends:     cfg.GetNamingBackends(),
+		HostsFile:    cfg.Plugin.NamingHostsFile,
+		RegistrarURL: cfg.Plugin.NamingRegistrarURL,
+	}
+}
+
+// applyLogging configures log output for the debug setting.
+func applyLogging(debugEnabled bool) {
+	if debugEnabled {
+		log.SetFlags(log.LstdFlags | log.Lshortfile)
+	} else {
+		log.SetFlags(log.LstdFlags)
+	}
+}
+
+// run starts the plugin and blocks until it exits, returning the exit code.
+//
+// Panics in the main loop or the plugin server are recovered so the ordered
+// shutdown still runs and iptables rules and SAM sessions are not left behind.
+func run(opts options) (exitCode int) {
+	cfg, err := loadConfig(opts)
+	if err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	applyLogging(cfg.Plugin.Debug)
+
+	log.Printf("Starting i2p-network-plugin %s (commit %s)", version, gitCommit)
+
+	p, err := plugin.NewWithSAMConfig(cfg.Plugin.SocketPath, cfg.GetSAMConfig())
+	if err != nil {
+		log.Printf("Error creating plugin: %v", err)
+		return 1
+	}
+	p.SetBuildInfo(plugin.BuildInfo{Version: version, Commit: gitCommit, BuildTime: buildTime})
+	p.SetAdminSocketPath(cfg.Plugin.AdminSocketPath)
+	p.SetGRPCSocketPath(cfg.Plugin.GRPCSocketPath)
+
+	sockPerms, adminSockPerms, err := socketPermissions(cfg)
+	if err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	p.SetSocketPermissions(sockPerms)
+	p.SetAdminSocketPermissions(adminSockPerms)
+	if err := p.SetSocketPeers(plugin.PeerAllowlist{
+		Users:  cfg.GetSocketAllowedUsers(),
+		Groups: cfg.GetSocketAllowedGroups(),
+	}); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	if err := p.SetTLSListener(tlsListener(cfg)); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+
+	if err := p.SetRateLimits(rateLimits(cfg)); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	p.SetRequestTracing(cfg.Plugin.TraceRequests, cfg.Plugin.TraceBufferSize)
+	p.SetExposureConcurrency(cfg.Plugin.ExposureConcurrency)
+	p.SetSmokeTest(cfg.Plugin.SmokeTest)
+	if err := p.SetDNSAddressMode(cfg.Plugin.DNSAddressMode); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	if err := p.SetDNSQueryLimits(dnsQueryLimits(cfg)); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	if err := p.SetStaleChainMode(cfg.Plugin.StaleChains); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	if err := p.SetFirewallBackend(cfg.Plugin.FirewallBackend); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	if err := p.SetLinkBackend(cfg.Plugin.LinkBackend); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	if err := p.SetDefaultSubnet(cfg.Plugin.IPAMSubnet); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	if err := p.SetScope(cfg.Plugin.Scope); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	if err := p.SetMeshPeers(cfg.GetMeshPeers()); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	if err := p.SetPeerReputation(cfg.GetPeerReputation()); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	p.SetSessionOptions(*cfg.GetTunnelDefaults())
+	if err := p.SetMaintenance(cfg.GetMaintenance()); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	if err := p.SetKeyGeneration(cfg.GetKeyGeneration()); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+
+	publisher, err := addressPublisher(cfg)
+	if err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	p.SetAddressPublisher(publisher)
+	p.SetStatsPersistence(cfg.Plugin.StatsPath, cfg.GetStatsInterval())
+	p.SetIdentityPath(cfg.Plugin.IdentityPath)
+	p.SetStatePath(cfg.Plugin.StatePath)
+	p.SetDockerSocket(cfg.Plugin.DockerSocket)
+	if err := p.SetAddressLabels(cfg.Plugin.LabelPath); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+
+	if err := p.SetNaming(namingConfig(cfg)); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	if err := p.SetSOCKSAliases(cfg.GetSOCKSAliases()); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+	if err := p.SetAnomalyWebhook(cfg.Plugin.AnomalyWebhook); err != nil {
+		log.Printf("Error loading configuration: %v", err)
+		return 1
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Error: panic in main loop: %v\n%s", r, debug.Stack())
+			shutdown(p)
+			exitCode = 2
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic in plugin server: %v\n%s", r, debug.Stack())
+			}
+		}()
+		done <- p.Start(ctx)
+	}()
+
+	signals := make(chan os.Signal, 4)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGUSR1, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case sig := <-signals:
+			switch sig {
+			case syscall.SIGUSR1:
+				log.Printf("Received SIGUSR1, dumping plugin state")
+				if err := p.DumpState(log.Writer()); err != nil {
+					log.Printf("Warning: Failed to dump plugin state: %v", err)
+				}
+			case syscall.SIGHUP:
+				log.Printf("Received SIGHUP, reloading configuration")
+				cfg = reload(p, cfg, opts)
+			default:
+				log.Printf("Received %s, shutting down", sig)
+				cancel()
+				return waitForShutdown(done, signals)
+			}
+
+		case err := <-done:
+			// Start only returns on its own after a server failure or panic;
+			// make sure resources are released either way.
+			shutdown(p)
+			if err != nil {
+				log.Printf("Error: plugin stopped: %v", err)
+				return 1
+			}
+			return 0
+		}
+	}
+}
+
+// waitForShutdown waits for the plugin to finish shutting down.
+//
+// A second termination signal aborts the wait so a stuck shutdown can
+// always be interrupted.
+func waitForShutdown(done <-chan error, signals <-chan os.Signal) int {
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				log.Printf("Error during shutdown: %v", err)
+				return 1
+			}
+			log.Printf("Shutdown complete")
+			return 0
+		case sig := <-signals:
+			if sig == syscall.SIGTERM || sig == syscall.SIGINT {
+				log.Printf("Received %s during shutdown, forcing exit", sig)
+				return 1
+			}
+		}
+	}
+}
+
+// shutdown runs the plugin's ordered shutdown, logging any error.
+func shutdown(p *plugin.Plugin) {
+	if err := p.Shutdown(context.Background()); err != nil {
+		log.Printf("Warning: Error during shutdown: %v", err)
+	}
+}
+
+// reload re-reads configuration and applies settings that can change at runtime.
+//
+// On failure the current configuration is kept. Settings that require a
+// restart are reported but not applied.
+func reload(p *plugin.Plugin, current *config.Config, opts options) *config.Config {
+	cfg, err := loadConfig(opts)
+	if err != nil {
+		log.Printf("Warning: Configuration reload failed, keeping current configuration: %v", err)
+		return current
+	}
+
+	if err := p.Reload(cfg.GetSAMConfig()); err != nil {
+		log.Printf("Warning: Configuration reload failed, keeping current configuration: %v", err)
+		return current
+	}
+
+	applyLogging(cfg.Plugin.Debug)
+
+	if cfg.Plugin.TraceRequests != current.Plugin.TraceRequests || cfg.Plugin.TraceBufferSize != current.Plugin.TraceBufferSize {
+		p.SetRequestTracing(cfg.Plugin.TraceRequests, cfg.Plugin.TraceBufferSize)
+	}
+
+	if cfg.Plugin.ExposureConcurrency != current.Plugin.ExposureConcurrency {
+		p.SetExposureConcurrency(cfg.Plugin.ExposureConcurrency)
+	}
+
+	if cfg.GetPeerReputation() != current.GetPeerReputation() {
+		if err := p.SetPeerReputation(cfg.GetPeerReputation()); err != nil {
+			log.Printf("Warning: Peer blocking not reloaded: %v", err)
+		}
+	}
+
+	if dnsQueryLimits(cfg) != dnsQueryLimits(current) {
+		if err := p.SetDNSQueryLimits(dnsQueryLimits(cfg)); err != nil {
+			log.Printf("Warning: DNS query limits not reloaded: %v", err)
+		}
+	}
+
+	if !cfg.GetTunnelDefaults().Equal(*current.GetTunnelDefaults()) {
+		p.SetSessionOptions(*cfg.GetTunnelDefaults())
+		log.Printf("Tunnel options changed, existing sessions are rebuilt during maintenance")
+	}
+
+	if cfg.Plugin.MaintenanceWindows != current.Plugin.MaintenanceWindows || cfg.Plugin.MaintenanceBatch != current.Plugin.MaintenanceBatch ||
+		cfg.Plugin.MaintenanceKeyRotation != current.Plugin.MaintenanceKeyRotation {
+		if err := p.SetMaintenance(cfg.GetMaintenance()); err != nil {
+			log.Printf("Warning: Maintenance settings not reloaded: %v", err)
+		}
+	}
+
+	if cfg.GetKeyGeneration() != current.GetKeyGeneration() {
+		if err := p.SetKeyGeneration(cfg.GetKeyGeneration()); err != nil {
+			log.Printf("Warning: Key generation settings not reloaded: %v", err)
+		}
+	}
+
+	if cfg.Plugin.NamingBackends != current.Plugin.NamingBackends || cfg.Plugin.NamingHostsFile != current.Plugin.NamingHostsFile ||
+		cfg.Plugin.NamingRegistrarURL != current.Plugin.NamingRegistrarURL {
+		if err := p.SetNaming(namingConfig(cfg)); err != nil {
+			log.Printf("Warning: Naming backends not reloaded: %v", err)
+		}
+	}
+
+	if cfg.Plugin.SOCKSAliases != current.Plugin.SOCKSAliases {
+		if err := p.SetSOCKSAliases(cfg.GetSOCKSAliases()); err != nil {
+			log.Printf("Warning: SOCKS aliases not reloaded: %v", err)
+		}
+	}
+
+	if cfg.Plugin.MeshPeers != current.Plugin.MeshPeers {
+		if err := p.SetMeshPeers(cfg.GetMeshPeers()); err != nil {
+			log.Printf("Warning: Mesh peers not reloaded: %v", err)
+		}
+	}
+
+	if cfg.Plugin.AnomalyWebhook != current.Plugin.AnomalyWebhook {
+		if err := p.SetAnomalyWebhook(cfg.Plugin.AnomalyWebhook); err != nil {
+			log.Printf("Warning: Anomaly webhook not reloaded: %v", err)
+		}
+	}
+
+	if cfg.Plugin.SocketPath != current.Plugin.SocketPath {
+		log.Printf("Warning: Socket path change to %s requires a restart", cfg.Plugin.SocketPath)
+	}
+	if cfg.Plugin.AdminSocketPath != current.Plugin.AdminSocketPath {
+		log.Printf("Warning: Admin socket path change to %s requires a restart", cfg.Plugin.AdminSocketPath)
+	}
+	if cfg.Plugin.GRPCSocketPath != current.Plugin.GRPCSocketPath {
+		log.Printf("Warning: gRPC socket path change to %s requires a restart", cfg.Plugin.GRPCSocketPath)
+	}
+	if cfg.Plugin.SocketMode != current.Plugin.SocketMode || cfg.Plugin.SocketOwner != current.Plugin.SocketOwner ||
+		cfg.Plugin.SocketGroup != current.Plugin.SocketGroup || cfg.Plugin.AdminSocketMode != current.Plugin.AdminSocketMode ||
+		cfg.Plugin.AdminSocketOwner != current.Plugin.AdminSocketOwner || cfg.Plugin.AdminSocketGroup != current.Plugin.AdminSocketGroup {
+		log.Printf("Warning: Socket permission changes require a restart")
+	}
+	if cfg.Plugin.SocketAllowedUsers != current.Plugin.SocketAllowedUsers ||
+		cfg.Plugin.SocketAllowedGroups != current.Plugin.SocketAllowedGroups {
+		log.Printf("Warning: Socket peer allowlist changes require a restart")
+	}
+	if tlsListener(cfg) != tlsListener(current) {
+		log.Printf("Warning: TLS listener changes require a restart")
+	}
+	if cfg.Plugin.DNSAddressMode != current.Plugin.DNSAddressMode {
+		log.Printf("Warning: DNS address mode change to %s requires a restart", cfg.Plugin.DNSAddressMode)
+	}
+	if cfg.Plugin.StaleChains != current.Plugin.StaleChains {
+		log.Printf("Warning: Stale chain mode change to %s requires a restart", cfg.Plugin.StaleChains)
+	}
+	if cfg.Plugin.FirewallBackend != current.Plugin.FirewallBackend {
+		log.Printf("Warning: Firewall backend change to %s requires a restart", cfg.Plugin.FirewallBackend)
+	}
+	if cfg.Plugin.LinkBackend != current.Plugin.LinkBackend {
+		log.Printf("Warning: Link backend change to %s requires a restart", cfg.Plugin.LinkBackend)
+	}
+	if cfg.Plugin.IPAMSubnet != current.Plugin.IPAMSubnet {
+		log.Printf("Warning: IPAM subnet change to %s requires a restart", cfg.Plugin.IPAMSubnet)
+	}
+	if cfg.Plugin.Scope != current.Plugin.Scope {
+		log.Printf("Warning: Scope change to %s requires a restart", cfg.Plugin.Scope)
+	}
+	if rateLimits(cfg) != rateLimits(current) {
+		log.Printf("Warning: Request limit changes require a restart")
+	}
+	if cfg.Plugin.PublishBackend != current.Plugin.PublishBackend || cfg.Plugin.PublishTarget != current.Plugin.PublishTarget ||
+		cfg.Plugin.PublishZone != current.Plugin.PublishZone || cfg.Plugin.PublishEncoding != current.Plugin.PublishEncoding ||
+		cfg.Plugin.LabelPath != current.Plugin.LabelPath {
+		log.Printf("Warning: Address publication changes require a restart")
+	}
+	if cfg.Plugin.StatsPath != current.Plugin.StatsPath || cfg.Plugin.StatsInterval != current.Plugin.StatsInterval {
+		log.Printf("Warning: Traffic statistics persistence changes require a restart")
+	}
+	if cfg.Plugin.IdentityPath != current.Plugin.IdentityPath {
+		log.Printf("Warning: Container identity path changes require a restart")
+	}
+	if cfg.Plugin.StatePath != current.Plugin.StatePath || cfg.Plugin.DockerSocket != current.Plugin.DockerSocket {
+		log.Printf("Warning: Network state path and Docker socket changes require a restart")
+	}
+
+	log.Printf("Configuration reloaded")
+	return cfg
+}
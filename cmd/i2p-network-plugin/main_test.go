@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFlags(t *testing.T) {
+	opts := parseFlags([]string{"-sock", "/tmp/custom.sock", "-debug"})
+
+	if opts.sockPath != "/tmp/custom.sock" || !opts.debug {
+		t.Errorf("Unexpected options: %+v", opts)
+	}
+	if !opts.set["sock"] || !opts.set["debug"] || opts.set["config"] {
+		t.Errorf("Unexpected explicit flags: %v", opts.set)
+	}
+
+	opts = parseFlags(nil)
+	if opts.sockPath != "/run/docker/plugins/i2p-network.sock" {
+		t.Errorf("Expected default socket path, got %s", opts.sockPath)
+	}
+	if len(opts.set) != 0 {
+		t.Errorf("Expected no explicit flags, got %v", opts.set)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	data := `{"plugin": {"socket_path": "/tmp/file.sock", "network_name": "from-file"}, "sam": {"host": "file-host"}}`
+	if err := os.WriteFile(configPath, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	t.Setenv("I2P_SAM_HOST", "env-host")
+
+	// File overrides defaults, environment overrides file
+	cfg, err := loadConfig(parseFlags([]string{"-config", configPath}))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Plugin.NetworkName != "from-file" {
+		t.Errorf("Expected network name from file, got %s", cfg.Plugin.NetworkName)
+	}
+	if cfg.SAM.Host != "env-host" {
+		t.Errorf("Expected SAM host from environment, got %s", cfg.SAM.Host)
+	}
+	if cfg.Plugin.SocketPath != "/tmp/file.sock" {
+		t.Errorf("Expected socket path from file, got %s", cfg.Plugin.SocketPath)
+	}
+
+	// Explicit flags override everything
+	cfg, err = loadConfig(parseFlags([]string{"-config", configPath, "-sock", "/tmp/flag.sock"}))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Plugin.SocketPath != "/tmp/flag.sock" {
+		t.Errorf("Expected socket path from flag, got %s", cfg.Plugin.SocketPath)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	if _, err := loadConfig(parseFlags([]string{"-config", "/nonexistent/config.json"})); err == nil {
+		t.Error("Expected error for missing configuration file")
+	}
+
+	t.Setenv("I2P_SAM_BACKUPS", "no-port")
+	if _, err := loadConfig(parseFlags(nil)); err == nil {
+		t.Error("Expected error for invalid configuration")
+	}
+}
@@ -0,0 +1,481 @@
+// Command i2pnet is a command-line client for the I2P network plugin's admin API.
+//
+// It talks to the plugin over the admin Unix socket
+// (/run/i2p-network-plugin/admin.sock by default, or ADMIN_SOCKET_PATH).
+//
+// Commands:
+//   - exposures: list services exposed from containers
+//   - filter explain: show which filter rule allows or blocks a container's connection
+//   - info: show the plugin build, enabled features and SAM handshake
+//   - options: list supported network options, endpoint options and container labels
+//   - update: apply docker network create options to a running network's traffic filter
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/internal/config"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/plugin"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+// requestTimeout bounds every admin API request.
+const requestTimeout = 10 * time.Second
+
+// command is an i2pnet subcommand.
+type command struct {
+	summary string
+	run     func(client *adminClient, args []string, stdout io.Writer) error
+}
+
+// commands holds the subcommands by name.
+var commands = map[string]command{
+	"exposures": {summary: "List services exposed from containers", run: runExposures},
+	"filter":    {summary: "Explain which filter rule allows or blocks a connection", run: runFilter},
+	"info":      {summary: "Show the plugin build, enabled features and SAM handshake", run: runInfo},
+	"options":   {summary: "List supported network options and container labels", run: runOptions},
+	"update":    {summary: "Apply network options to a running network's traffic filter", run: runUpdate},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("i2pnet", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	socketPath := fs.String("admin-socket", defaultAdminSocket(), "Path to the plugin's admin API socket")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: i2pnet [-admin-socket path] <command> [flags]\n\nCommands:\n")
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(stderr, "  %-10s %s\n", name, commands[name].summary)
+		}
+		fmt.Fprintf(stderr, "\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "i2pnet: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return 2
+	}
+
+	if err := cmd.run(newAdminClient(*socketPath), fs.Args()[1:], stdout); err != nil {
+		fmt.Fprintf(stderr, "i2pnet: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// defaultAdminSocket returns the admin socket path from the environment or the default.
+func defaultAdminSocket() string {
+	if path := os.Getenv("ADMIN_SOCKET_PATH"); path != "" {
+		return path
+	}
+	return config.DefaultConfig().Plugin.AdminSocketPath
+}
+
+// adminClient issues requests to the admin API over its Unix socket.
+type adminClient struct {
+	socketPath string
+	http       *http.Client
+}
+
+// newAdminClient creates a client for the admin socket at socketPath.
+func newAdminClient(socketPath string) *adminClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &adminClient{
+		socketPath: socketPath,
+		http:       &http.Client{Transport: transport, Timeout: requestTimeout},
+	}
+}
+
+// get fetches an admin API path and decodes the JSON response into v.
+func (c *adminClient) get(path string, query url.Values, v interface{}) error {
+	target := "http://admin" + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	resp, err := c.http.Get(target)
+	if err != nil {
+		return fmt.Errorf("failed to reach admin API at %s: %w", c.socketPath, err)
+	}
+	return decodeResponse(resp, v)
+}
+
+// post sends body as JSON to an admin API path and decodes the JSON response into v.
+func (c *adminClient) post(path string, body, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	resp, err := c.http.Post("http://admin"+path, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to reach admin API at %s: %w", c.socketPath, err)
+	}
+	return decodeResponse(resp, v)
+}
+
+// decodeResponse decodes a successful admin API response into v, or returns
+// the API's error.
+func decodeResponse(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr plugin.AdminError
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err == nil && apiErr.Error != "" {
+			return fmt.Errorf("admin API: %s", apiErr.Error)
+		}
+		return fmt.Errorf("admin API returned %s", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode admin API response: %w", err)
+	}
+	return nil
+}
+
+// runInfo prints the plugin build, its enabled features and the latest SAM
+// handshake.
+func runInfo(client *adminClient, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("info", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var info plugin.AdminInfo
+	if err := client.get("/"+plugin.AdminAPIVersion+"/info", nil, &info); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+	return writeInfo(stdout, info)
+}
+
+// writeInfo prints the plugin report as aligned key/value lines.
+func writeInfo(w io.Writer, info plugin.AdminInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Version:\t%s\n", info.Version)
+	fmt.Fprintf(tw, "Commit:\t%s\n", info.Commit)
+	fmt.Fprintf(tw, "Built:\t%s\n", info.BuildTime)
+	fmt.Fprintf(tw, "Go:\t%s\n", info.GoVersion)
+	fmt.Fprintf(tw, "Admin API:\t%s\n", info.AdminAPIRevision)
+
+	features := make([]string, 0, len(info.Features))
+	for feature := range info.Features {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	for _, feature := range features {
+		state := "disabled"
+		if info.Features[feature] {
+			state = "enabled"
+		}
+		fmt.Fprintf(tw, "Feature %s:\t%s\n", feature, state)
+	}
+
+	switch {
+	case info.SAM == nil:
+		fmt.Fprintf(tw, "SAM:\tnot checked yet\n")
+	case info.SAM.Error != "":
+		fmt.Fprintf(tw, "SAM:\t%s (handshake failed: %s)\n", info.SAM.Bridge, info.SAM.Error)
+	default:
+		fmt.Fprintf(tw, "SAM:\t%s, SAM %s (%s)\n", info.SAM.Bridge, info.SAM.Version, info.SAM.Reply)
+	}
+	return tw.Flush()
+}
+
+// runOptions lists the plugin's supported options and labels.
+func runOptions(client *adminClient, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("options", flag.ContinueOnError)
+	scope := fs.String("scope", "", "Only list options of this scope: network, endpoint or container")
+	asJSON := fs.Bool("json", false, "Print the catalog as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if *scope != "" {
+		query.Set("scope", *scope)
+	}
+
+	var options []plugin.CatalogOption
+	if err := client.get("/"+plugin.AdminAPIVersion+"/options", query, &options); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(options)
+	}
+	return writeOptionsTable(stdout, options)
+}
+
+// writeOptionsTable prints options as an aligned table.
+func writeOptionsTable(w io.Writer, options []plugin.CatalogOption) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSCOPE\tTYPE\tDEFAULT\tDESCRIPTION")
+	for _, option := range options {
+		description := option.Description
+		if len(option.Values) > 0 {
+			description += " (values: " + strings.Join(option.Values, ", ") + ")"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", option.Name, option.Scope, option.Type, option.Default, description)
+	}
+	return tw.Flush()
+}
+
+// runExposures lists the services exposed from containers.
+func runExposures(client *adminClient, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("exposures", flag.ContinueOnError)
+	container := fs.String("container", "", "Only list exposures of this container")
+	tenant := fs.String("tenant", "", "Only list exposures on networks of this tenant")
+	asJSON := fs.Bool("json", false, "Print the exposures as JSON")
+	encoding := fs.String("encoding", i2p.EncodingB32, "Address encoding of I2P destinations: b32, b33 or base64")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := i2p.ValidateEncoding(*encoding); err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if *tenant != "" {
+		query.Set("tenant", *tenant)
+	}
+
+	var exposures []plugin.AdminExposure
+	if err := client.get("/"+plugin.AdminAPIVersion+"/exposures", query, &exposures); err != nil {
+		return err
+	}
+	if *container != "" {
+		filtered := []plugin.AdminExposure{}
+		for _, exposure := range exposures {
+			if strings.HasPrefix(exposure.ContainerID, *container) {
+				filtered = append(filtered, exposure)
+			}
+		}
+		exposures = filtered
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(exposures)
+	}
+	return writeExposuresTable(stdout, exposures, *encoding)
+}
+
+// writeExposuresTable prints exposures as an aligned table, with I2P
+// destinations in the named address encoding.
+func writeExposuresTable(w io.Writer, exposures []plugin.AdminExposure, encoding string) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CONTAINER\tSERVICE\tTYPE\tSTATE\tDESTINATION\tEXPIRES")
+	for _, exposure := range exposures {
+		containerID := exposure.ContainerID
+		if len(containerID) > 12 {
+			containerID = containerID[:12]
+		}
+		expires := "-"
+		if exposure.ExpiresAt != nil {
+			expires = exposure.ExpiresAt.Format(time.RFC3339)
+		}
+		destination := exposure.Destination
+		if exposure.Addresses != nil {
+			destination = exposure.Addresses.Encoded(encoding)
+		}
+		fmt.Fprintf(tw, "%s\t%s/%s\t%s\t%s\t%s\t%s\n", containerID, exposure.Service, exposure.Protocol,
+			exposure.Type, exposure.State, destination, expires)
+	}
+	return tw.Flush()
+}
+
+// runFilter runs a filter subcommand; explain is the only one.
+func runFilter(client *adminClient, args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] != "explain" {
+		return fmt.Errorf("usage: filter explain [-network network] [-json] <container> <destination>")
+	}
+
+	fs := flag.NewFlagSet("filter explain", flag.ContinueOnError)
+	network := fs.String("network", "", "Network of the container, if it is joined to several")
+	asJSON := fs.Bool("json", false, "Print the explanation as JSON")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("filter explain takes a container and a destination")
+	}
+
+	query := url.Values{}
+	query.Set("container", fs.Arg(0))
+	query.Set("destination", fs.Arg(1))
+	if *network != "" {
+		query.Set("network", *network)
+	}
+
+	var explanation proxy.FilterExplanation
+	if err := client.get("/"+plugin.AdminAPIVersion+"/filters/explain", query, &explanation); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(explanation)
+	}
+	return writeExplanation(stdout, fs.Arg(0), explanation)
+}
+
+// writeExplanation prints the decision of a filter explanation followed by
+// the policy layers the connection passed.
+func writeExplanation(w io.Writer, container string, explanation proxy.FilterExplanation) error {
+	verdict := "blocked"
+	if explanation.Allowed {
+		verdict = "allowed"
+	}
+	fmt.Fprintf(w, "%s -> %s: %s\n", container, explanation.Destination, verdict)
+
+	rule := explanation.Rule
+	if explanation.Pattern != "" {
+		rule += " " + explanation.Pattern
+	}
+	fmt.Fprintf(w, "Decided by %s rule %s", explanation.Scope, rule)
+	if step := explanation.Decision(); step != nil && step.Origin != "" {
+		fmt.Fprintf(w, " (%s)", step.Origin)
+	}
+	fmt.Fprintf(w, "\n\n")
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCOPE\tRULE\tDECISION\tDETAIL")
+	for _, step := range explanation.Steps {
+		decision := step.Decision
+		if decision == "" {
+			decision = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", step.Scope, step.Rule, decision, step.Detail)
+	}
+	return tw.Flush()
+}
+
+// optionFlags collects repeated -o key=value flags.
+type optionFlags map[string]string
+
+// String returns the options in key order.
+func (o optionFlags) String() string {
+	keys := make([]string, 0, len(o))
+	for key := range o {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		keys[i] = key + "=" + o[key]
+	}
+	return strings.Join(keys, ",")
+}
+
+// Set adds a key=value option.
+func (o optionFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	o[strings.TrimSpace(key)] = val
+	return nil
+}
+
+// runUpdate applies network options to a running network.
+func runUpdate(client *adminClient, args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("update", flag.ContinueOnError)
+	options := optionFlags{}
+	fs.Var(options, "o", "Network option as key=value, as passed to docker network create (repeatable)")
+	dryRun := fs.Bool("dry-run", false, "Report the changes without applying them")
+	asJSON := fs.Bool("json", false, "Print the changes as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("update takes exactly one network ID or name")
+	}
+
+	var update plugin.NetworkUpdate
+	path := "/" + plugin.AdminAPIVersion + "/networks/" + url.PathEscape(fs.Arg(0)) + "/update"
+	if err := client.post(path, plugin.NetworkUpdateRequest{Options: options, DryRun: *dryRun}, &update); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(update)
+	}
+	return writeUpdate(stdout, update)
+}
+
+// writeUpdate prints the changes of a network update, one per line.
+func writeUpdate(w io.Writer, update plugin.NetworkUpdate) error {
+	verb := "Updated"
+	if update.DryRun {
+		verb = "Would update"
+	}
+	fmt.Fprintf(w, "%s network %s\n", verb, update.Network)
+	if update.FilterModeChanged {
+		fmt.Fprintf(w, "  filter mode: %s\n", update.FilterMode)
+	}
+	for _, list := range []struct {
+		name   string
+		change plugin.FilterListChange
+	}{{"allowlist", update.Allowlist}, {"blocklist", update.Blocklist}} {
+		for _, entry := range list.change.Added {
+			fmt.Fprintf(w, "  + %s %s\n", list.name, entry)
+		}
+		for _, entry := range list.change.Removed {
+			fmt.Fprintf(w, "  - %s %s\n", list.name, entry)
+		}
+		for _, entry := range list.change.Kept {
+			fmt.Fprintf(w, "  = %s %s (listed by another network)\n", list.name, entry)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/plugin"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+// serveAdmin serves handler on a Unix socket and returns the socket path.
+func serveAdmin(t *testing.T, handler http.Handler) string {
+	t.Helper()
+
+	socketPath := filepath.Join(t.TempDir(), "admin.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to listen on %s: %v", socketPath, err)
+	}
+
+	server := &http.Server{Handler: handler}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	return socketPath
+}
+
+func TestOptionsCommand(t *testing.T) {
+	var gotScope string
+	socketPath := serveAdmin(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/options" {
+			http.NotFound(w, r)
+			return
+		}
+		gotScope = r.URL.Query().Get("scope")
+		json.NewEncoder(w).Encode([]plugin.CatalogOption{
+			{Name: "i2p.filter.mode", Scope: "network", Type: "string", Default: "blocklist",
+				Values: []string{"allowlist", "blocklist"}, Description: "Filter mode"},
+		})
+	}))
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-admin-socket", socketPath, "options", "-scope", "network"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if gotScope != "network" {
+		t.Errorf("Expected scope query network, got %q", gotScope)
+	}
+	for _, expected := range []string{"NAME", "i2p.filter.mode", "blocklist", "(values: allowlist, blocklist)"} {
+		if !strings.Contains(stdout.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-admin-socket", socketPath, "options", "-json"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	var options []plugin.CatalogOption
+	if err := json.Unmarshal(stdout.Bytes(), &options); err != nil || len(options) != 1 {
+		t.Errorf("Expected JSON catalog, got %q (%v)", stdout.String(), err)
+	}
+}
+
+func TestInfoCommand(t *testing.T) {
+	socketPath := serveAdmin(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/info" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(plugin.AdminInfo{
+			BuildInfo:        plugin.BuildInfo{Version: "v1.2.3", Commit: "abc1234", BuildTime: "2026-01-01"},
+			AdminAPIRevision: "1.21.0",
+			Features:         map[string]bool{plugin.FeatureSAMFailover: true, plugin.FeatureSmokeTest: false},
+			SAM:              &plugin.AdminSAMHandshake{Bridge: "127.0.0.1:7656", Version: "3.3", Reply: "HELLO REPLY RESULT=OK VERSION=3.3"},
+		})
+	}))
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-admin-socket", socketPath, "info"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	for _, expected := range []string{"v1.2.3", "abc1234", "1.21.0", "sam_failover:", "enabled", "smoke_test:", "disabled", "127.0.0.1:7656, SAM 3.3"} {
+		if !strings.Contains(stdout.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-admin-socket", socketPath, "info", "-json"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	var info plugin.AdminInfo
+	if err := json.Unmarshal(stdout.Bytes(), &info); err != nil || info.Version != "v1.2.3" || !info.Features[plugin.FeatureSAMFailover] {
+		t.Errorf("Expected JSON report, got %q (%v)", stdout.String(), err)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	if code := run(nil, &stdout, &stderr); code != 2 {
+		t.Errorf("Expected exit code 2 without a command, got %d", code)
+	}
+	if code := run([]string{"bogus"}, &stdout, &stderr); code != 2 {
+		t.Errorf("Expected exit code 2 for an unknown command, got %d", code)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.sock")
+	stderr.Reset()
+	if code := run([]string{"-admin-socket", missing, "options"}, &stdout, &stderr); code != 1 {
+		t.Errorf("Expected exit code 1 for an unreachable socket, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "failed to reach admin API") {
+		t.Errorf("Unexpected error output: %s", stderr.String())
+	}
+}
+
+func TestExposuresCommand(t *testing.T) {
+	var tenant string
+	socketPath := serveAdmin(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/exposures" {
+			http.NotFound(w, r)
+			return
+		}
+		tenant = r.URL.Query().Get("tenant")
+		json.NewEncoder(w).Encode([]plugin.AdminExposure{
+			{ContainerID: "0123456789abcdef", Service: "web-80", Protocol: "tcp", Type: "i2p", State: "active", Destination: "abc.b32.i2p",
+				Addresses: &i2p.Addresses{Base64: "abc~base64", B32: "abc.b32.i2p"}},
+			{ContainerID: "fedcba9876543210", Service: "8080", Protocol: "tcp", Type: "ip", State: "active", Destination: "192.168.1.10:8080"},
+		})
+	}))
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-admin-socket", socketPath, "exposures", "-container", "0123"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	for _, expected := range []string{"CONTAINER", "0123456789ab ", "web-80/tcp", "abc.b32.i2p"} {
+		if !strings.Contains(stdout.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, stdout.String())
+		}
+	}
+	if strings.Contains(stdout.String(), "192.168.1.10") {
+		t.Errorf("Expected other containers to be filtered out, got:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-admin-socket", socketPath, "exposures", "-encoding", "base64"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "abc~base64") || !strings.Contains(stdout.String(), "192.168.1.10:8080") {
+		t.Errorf("Expected base64 destinations, got:\n%s", stdout.String())
+	}
+	if code := run([]string{"-admin-socket", socketPath, "exposures", "-encoding", "b64"}, &stdout, &stderr); code == 0 {
+		t.Error("Expected an unknown encoding to fail")
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-admin-socket", socketPath, "exposures", "-json"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	var exposures []plugin.AdminExposure
+	if err := json.Unmarshal(stdout.Bytes(), &exposures); err != nil || len(exposures) != 2 {
+		t.Errorf("Expected JSON exposures, got %q (%v)", stdout.String(), err)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-admin-socket", socketPath, "exposures", "-tenant", "acme"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if tenant != "acme" {
+		t.Errorf("Expected the tenant to be passed to the admin API, got %q", tenant)
+	}
+}
+
+func TestUpdateCommand(t *testing.T) {
+	var received plugin.NetworkUpdateRequest
+	socketPath := serveAdmin(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/networks/my-net/update" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&received)
+		json.NewEncoder(w).Encode(plugin.NetworkUpdate{
+			Network:           "net1",
+			DryRun:            received.DryRun,
+			FilterMode:        "allowlist",
+			FilterModeChanged: true,
+			Allowlist:         plugin.FilterListChange{Added: []string{"good.i2p"}},
+		})
+	}))
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-admin-socket", socketPath, "update", "-o", "i2p.filter.mode=allowlist", "-o", "i2p.filter.allowlist=good.i2p", "-dry-run", "my-net"}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if received.Options["i2p.filter.mode"] != "allowlist" || received.Options["i2p.filter.allowlist"] != "good.i2p" || !received.DryRun {
+		t.Errorf("Expected the options and dry run to be sent, got %+v", received)
+	}
+	for _, expected := range []string{"Would update network net1", "filter mode: allowlist", "+ allowlist good.i2p"} {
+		if !strings.Contains(stdout.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, stdout.String())
+		}
+	}
+
+	if code := run([]string{"-admin-socket", socketPath, "update", "-o", "novalue"}, &stdout, &stderr); code != 1 {
+		t.Errorf("Expected exit code 1 for a malformed option, got %d", code)
+	}
+}
+
+func TestFilterExplainCommand(t *testing.T) {
+	var query map[string]string
+	socketPath := serveAdmin(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/filters/explain" {
+			http.NotFound(w, r)
+			return
+		}
+		query = map[string]string{}
+		for key := range r.URL.Query() {
+			query[key] = r.URL.Query().Get(key)
+		}
+		json.NewEncoder(w).Encode(proxy.FilterExplanation{
+			Destination: query["destination"], Target: query["destination"], Scope: "network", Rule: "blocklist", Pattern: "evil.i2p",
+			Steps: []proxy.FilterStep{
+				{Scope: "container", Rule: "outbound", Detail: "container may open outbound I2P connections"},
+				{Scope: "network", Rule: "blocklist", Pattern: "evil.i2p", Origin: "options of network i2p-test",
+					Detail: "I2P destination blocked by blocklist: evil.i2p", Decision: "block"},
+			},
+		})
+	}))
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-admin-socket", socketPath, "filter", "explain", "-network", "i2p-test", "web", "evil.i2p:80"}
+	if code := run(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	if query["container"] != "web" || query["destination"] != "evil.i2p:80" || query["network"] != "i2p-test" {
+		t.Errorf("Expected the container, destination and network to be sent, got %v", query)
+	}
+	for _, expected := range []string{"web -> evil.i2p:80: blocked", "Decided by network rule blocklist evil.i2p (options of network i2p-test)", "SCOPE", "outbound"} {
+		if !strings.Contains(stdout.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	if code := run([]string{"-admin-socket", socketPath, "filter", "explain", "-json", "web", "evil.i2p"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	var explanation proxy.FilterExplanation
+	if err := json.Unmarshal(stdout.Bytes(), &explanation); err != nil || explanation.Rule != "blocklist" || len(explanation.Steps) != 2 {
+		t.Errorf("Expected a JSON explanation, got %q (%v)", stdout.String(), err)
+	}
+
+	for _, args := range [][]string{{"filter"}, {"filter", "show"}, {"filter", "explain", "web"}} {
+		if code := run(append([]string{"-admin-socket", socketPath}, args...), &stdout, &stderr); code != 1 {
+			t.Errorf("Expected exit code 1 for %v, got %d", args, code)
+		}
+	}
+}
@@ -2,27 +2,35 @@ module github.com/go-i2p/go-docker-network-i2p
 
 go 1.24.4
 
+toolchain go1.25.0
+
 require (
+	github.com/go-i2p/go-forward v0.0.0-20250202052226-ee8a43dcb664
 	github.com/go-i2p/go-sam-go v0.33.0
+	github.com/go-i2p/i2pkeys v0.33.92
 	github.com/miekg/dns v1.1.68
+	github.com/vishvananda/netlink v1.3.1
+	google.golang.org/grpc v1.80.0
+	google.golang.org/protobuf v1.36.11
 )
 
 require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/go-i2p/common v0.0.1 // indirect
 	github.com/go-i2p/crypto v0.0.1 // indirect
-	github.com/go-i2p/go-forward v0.0.0-20250202052226-ee8a43dcb664 // indirect
-	github.com/go-i2p/i2pkeys v0.33.92 // indirect
 	github.com/go-i2p/logger v0.0.1 // indirect
 	github.com/oklog/ulid/v2 v2.1.1 // indirect
 	github.com/samber/lo v1.52.0 // indirect
 	github.com/samber/oops v1.19.3 // indirect
 	github.com/sirupsen/logrus v1.9.3 // indirect
-	go.opentelemetry.io/otel v1.38.0 // indirect
-	go.opentelemetry.io/otel/trace v1.38.0 // indirect
-	golang.org/x/mod v0.28.0 // indirect
-	golang.org/x/net v0.45.0 // indirect
-	golang.org/x/sync v0.17.0 // indirect
-	golang.org/x/sys v0.37.0 // indirect
-	golang.org/x/text v0.30.0 // indirect
-	golang.org/x/tools v0.37.0 // indirect
+	github.com/vishvananda/netns v0.0.5 // indirect
+	go.opentelemetry.io/otel v1.39.0 // indirect
+	go.opentelemetry.io/otel/trace v1.39.0 // indirect
+	golang.org/x/mod v0.31.0 // indirect
+	golang.org/x/net v0.49.0 // indirect
+	golang.org/x/sync v0.19.0 // indirect
+	golang.org/x/sys v0.40.0 // indirect
+	golang.org/x/text v0.33.0 // indirect
+	golang.org/x/tools v0.40.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260120221211-b8f7ae30c516 // indirect
 )
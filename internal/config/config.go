@@ -9,13 +9,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"os"
+	"path"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
 )
 
+// maxExposureConcurrency caps the number of exposures created in parallel,
+// since every I2P exposure holds a SAM sub-session open while it is built.
+const maxExposureConcurrency = 32
+
 // Config represents the complete configuration for the I2P network plugin.
 type Config struct {
 	// Plugin configuration
@@ -39,11 +46,170 @@ type PluginConfig struct {
 	// NetworkName is the default name for I2P networks
 	NetworkName string `json:"network_name"`
 
-	// IPAMSubnet is the default subnet for container IP allocation
+	// IPAMSubnet is the default subnet networks created without IPAM data get a /24 of
 	IPAMSubnet string `json:"ipam_subnet"`
 
 	// Gateway is the default gateway IP for I2P networks
 	Gateway string `json:"gateway"`
+
+	// AdminSocketPath is the Unix socket path for the admin API (empty disables it)
+	AdminSocketPath string `json:"admin_socket_path"`
+
+	// GRPCSocketPath is the Unix socket path for the gRPC admin API (empty disables it)
+	GRPCSocketPath string `json:"grpc_socket_path"`
+
+	// SocketMode is the octal permission mode for the plugin socket
+	SocketMode string `json:"socket_mode"`
+
+	// SocketOwner is the user name or UID owning the plugin socket (empty keeps the process user)
+	SocketOwner string `json:"socket_owner"`
+
+	// SocketGroup is the group name or GID owning the plugin socket (empty keeps the process group)
+	SocketGroup string `json:"socket_group"`
+
+	// AdminSocketMode is the octal permission mode for the admin socket
+	AdminSocketMode string `json:"admin_socket_mode"`
+
+	// AdminSocketOwner is the user name or UID owning the admin socket
+	AdminSocketOwner string `json:"admin_socket_owner"`
+
+	// AdminSocketGroup is the group name or GID owning the admin socket
+	AdminSocketGroup string `json:"admin_socket_group"`
+
+	// SocketAllowedUsers is the comma-separated list of user names or UIDs whose processes may connect to the plugin socket
+	SocketAllowedUsers string `json:"socket_allowed_users"`
+
+	// SocketAllowedGroups is the comma-separated list of group names or GIDs whose members may connect to the plugin socket
+	SocketAllowedGroups string `json:"socket_allowed_groups"`
+
+	// TLSAddress is the host:port the plugin API is also served on with TLS for remote Docker engines (empty disables it)
+	TLSAddress string `json:"tls_address"`
+
+	// TLSCertFile is the PEM certificate chain of the TLS listener
+	TLSCertFile string `json:"tls_cert_file"`
+
+	// TLSKeyFile is the PEM private key of the TLS listener's certificate
+	TLSKeyFile string `json:"tls_key_file"`
+
+	// TLSClientCAFile holds the PEM CA certificates client certificates of Docker engines must be signed by
+	TLSClientCAFile string `json:"tls_client_ca_file"`
+
+	// RateLimit is the sustained requests per second allowed per HTTP endpoint, and per peer UID on the plugin socket (0 disables it)
+	RateLimit float64 `json:"rate_limit"`
+
+	// RateBurst is the number of requests allowed above the sustained rate
+	RateBurst int `json:"rate_burst"`
+
+	// MaxRequestBytes is the maximum HTTP request body size (0 disables the limit)
+	MaxRequestBytes int64 `json:"max_request_bytes"`
+
+	// TraceRequests records plugin API request and response bodies for the admin API
+	TraceRequests bool `json:"trace_requests"`
+
+	// TraceBufferSize is the number of traced plugin API calls kept in memory
+	TraceBufferSize int `json:"trace_buffer_size"`
+
+	// PublishBackend selects where exposure addresses are published: zonefile, consul or http (empty disables it)
+	PublishBackend string `json:"publish_backend"`
+
+	// PublishTarget is the zone file path, Consul KV URL prefix or HTTP endpoint for published addresses
+	PublishTarget string `json:"publish_target"`
+
+	// PublishZone is the DNS zone used for zone file records
+	PublishZone string `json:"publish_zone"`
+
+	// PublishEncoding is the address encoding published: b32, b33 or base64 (empty publishes b32)
+	PublishEncoding string `json:"publish_encoding"`
+
+	// LabelPath is the file in containers the plugin writes i2p.address.<port> labels of their exposures to through the Docker socket (empty disables it)
+	LabelPath string `json:"label_path"`
+
+	// StatsPath is the file traffic statistics are persisted to (empty disables persistence)
+	StatsPath string `json:"stats_path"`
+
+	// StatsInterval is how often traffic statistics are saved, as a Go duration (e.g. "5m")
+	StatsInterval string `json:"stats_interval"`
+
+	// IdentityPath is the file container identities and their keys are persisted to (empty keeps them in memory)
+	IdentityPath string `json:"identity_path"`
+
+	// StatePath is the file networks and endpoints are persisted to for live-restore (empty keeps them in memory)
+	StatePath string `json:"state_path"`
+
+	// DockerSocket is the Docker Engine API socket the plugin state is reconciled against on start (empty disables reconciliation)
+	DockerSocket string `json:"docker_socket"`
+
+	// NamingBackends is the comma-separated order of I2P naming backends: sam, hosts, registrar (empty disables lookups)
+	NamingBackends string `json:"naming_backends"`
+
+	// NamingHostsFile is the hosts.txt file used by the hosts naming backend
+	NamingHostsFile string `json:"naming_hosts_file"`
+
+	// NamingRegistrarURL is the lookup endpoint used by the registrar naming backend
+	NamingRegistrarURL string `json:"naming_registrar_url"`
+
+	// SOCKSAliases are comma-separated alias=destination pairs mapping SOCKS targets such as service.internal to I2P destinations
+	SOCKSAliases string `json:"socks_aliases"`
+
+	// ExposureConcurrency is the number of a container's exposures created in parallel
+	ExposureConcurrency int `json:"exposure_concurrency"`
+
+	// DNSAddressMode is the family of synthetic addresses I2P names resolve to: ipv4 or ipv6
+	DNSAddressMode string `json:"dns_address_mode"`
+
+	// DNSSourceQPS is the sustained DNS queries per second answered per container address (0 disables it)
+	DNSSourceQPS float64 `json:"dns_source_qps"`
+
+	// DNSSourceBurst is the number of DNS queries a container may send above DNSSourceQPS
+	DNSSourceBurst int `json:"dns_source_burst"`
+
+	// DNSGlobalQPS is the sustained DNS queries per second answered by all resolvers together (0 disables it)
+	DNSGlobalQPS float64 `json:"dns_global_qps"`
+
+	// DNSGlobalBurst is the number of DNS queries answered above DNSGlobalQPS
+	DNSGlobalBurst int `json:"dns_global_burst"`
+
+	// PeerBlockDuration is how long an abusive inbound I2P peer is first blocked, as a Go duration ("0" disables blocking)
+	PeerBlockDuration string `json:"peer_block_duration"`
+
+	// PeerFloodStreams is the decaying stream score at which an inbound I2P peer is blocked
+	PeerFloodStreams int `json:"peer_flood_streams"`
+
+	// PeerEmptyStreams is the decaying score of streams closed without data at which an inbound I2P peer is blocked
+	PeerEmptyStreams int `json:"peer_empty_streams"`
+
+	// MaintenanceWindows are the daily HH:MM-HH:MM periods of local time container sessions are rebuilt in, comma-separated (empty allows any time)
+	MaintenanceWindows string `json:"maintenance_windows"`
+
+	// MaintenanceBatch is the maximum number of container sessions rebuilt per maintenance run
+	MaintenanceBatch int `json:"maintenance_batch"`
+
+	// MaintenanceKeyRotation is the age at which transient identities of client-only containers are replaced, as a Go duration ("0" disables rotation)
+	MaintenanceKeyRotation string `json:"maintenance_key_rotation"`
+
+	// KeyPoolSize is the number of I2P destination keys generated ahead of time (0 disables the pool)
+	KeyPoolSize int `json:"key_pool_size"`
+
+	// KeySlowThreshold is the key generation time above which a warning is logged, as a Go duration ("0" disables the warning)
+	KeySlowThreshold string `json:"key_slow_threshold"`
+
+	// StaleChains selects how firewall chains left behind by a previous instance are handled: reconcile or flush
+	StaleChains string `json:"stale_chains"`
+	// FirewallBackend is the backend the plugin's firewall rules are applied with: iptables, nftables or noop
+	FirewallBackend string `json:"firewall_backend"`
+	// LinkBackend is how endpoint interfaces are created: netlink (a bridge per network and a veth pair per endpoint) or noop
+	LinkBackend string `json:"link_backend"`
+
+	// Scope is the driver scope: local, or global to share services of networks of the same name between hosts
+	Scope string `json:"scope"`
+	// MeshPeers is the comma-separated list of control addresses of the plugin instances on other hosts (global scope)
+	MeshPeers string `json:"mesh_peers"`
+
+	// AnomalyWebhook is the URL traffic filter anomalies are posted to (empty disables notifications)
+	AnomalyWebhook string `json:"anomaly_webhook"`
+
+	// SmokeTest runs an end-to-end exposure round trip over I2P at startup
+	SmokeTest bool `json:"smoke_test"`
 }
 
 // DefaultConfig returns a default configuration.
@@ -59,11 +225,43 @@ type PluginConfig struct {
 func DefaultConfig() *Config {
 	return &Config{
 		Plugin: PluginConfig{
-			SocketPath:  "/run/docker/plugins/i2p-network.sock",
-			Debug:       false,
-			NetworkName: "i2p",
-			IPAMSubnet:  "172.20.0.0/16",
-			Gateway:     "172.20.0.1",
+			SocketPath:          "/run/docker/plugins/i2p-network.sock",
+			Debug:               false,
+			NetworkName:         "i2p",
+			IPAMSubnet:          "172.20.0.0/16",
+			Gateway:             "172.20.0.1",
+			AdminSocketPath:     "/run/i2p-network-plugin/admin.sock",
+			SocketMode:          "0600",
+			AdminSocketMode:     "0600",
+			SocketAllowedUsers:  "0",
+			SocketAllowedGroups: "docker",
+			RateLimit:           50,
+			RateBurst:           100,
+			MaxRequestBytes:     1 << 20,
+			TraceBufferSize:     500,
+			StatsPath:           "/var/lib/i2p-network-plugin/traffic-stats.json",
+			StatsInterval:       "5m",
+			IdentityPath:        "/var/lib/i2p-network-plugin/identities.json",
+			StatePath:           "/var/lib/i2p-network-plugin/networks.json",
+			DockerSocket:        "/var/run/docker.sock",
+
+			ExposureConcurrency: 4,
+			DNSAddressMode:      "ipv4",
+			DNSSourceQPS:        50,
+			DNSSourceBurst:      100,
+			DNSGlobalQPS:        1000,
+			DNSGlobalBurst:      2000,
+			StaleChains:         "reconcile",
+			FirewallBackend:     "iptables",
+			LinkBackend:         "netlink",
+			Scope:               "local",
+			PeerBlockDuration:   "5m",
+			PeerFloodStreams:    60,
+			PeerEmptyStreams:    20,
+
+			MaintenanceBatch:       i2p.DefaultMaintenanceBatch,
+			MaintenanceKeyRotation: "0",
+			KeySlowThreshold:       i2p.DefaultSlowKeyGeneration.String(),
 		},
 		SAM:            *i2p.DefaultSAMConfig(),
 		TunnelDefaults: i2p.DefaultTunnelOptions(),
@@ -119,6 +317,234 @@ func (c *Config) LoadFromEnvironment() error {
 		c.Plugin.Gateway = gateway
 	}
 
+	if adminSockPath, ok := os.LookupEnv("ADMIN_SOCKET_PATH"); ok {
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Applying ADMIN_SOCKET_PATH from environment: %s", adminSockPath)
+		}
+		c.Plugin.AdminSocketPath = adminSockPath
+	}
+
+	if grpcSockPath, ok := os.LookupEnv("GRPC_SOCKET_PATH"); ok {
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Applying GRPC_SOCKET_PATH from environment: %s", grpcSockPath)
+		}
+		c.Plugin.GRPCSocketPath = grpcSockPath
+	}
+
+	// Plugin socket peer allowlist; setting both empty admits every process
+	if users, ok := os.LookupEnv("PLUGIN_SOCKET_ALLOWED_USERS"); ok {
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Applying PLUGIN_SOCKET_ALLOWED_USERS from environment: %s", users)
+		}
+		c.Plugin.SocketAllowedUsers = users
+	}
+	if groups, ok := os.LookupEnv("PLUGIN_SOCKET_ALLOWED_GROUPS"); ok {
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Applying PLUGIN_SOCKET_ALLOWED_GROUPS from environment: %s", groups)
+		}
+		c.Plugin.SocketAllowedGroups = groups
+	}
+
+	// Socket ownership and permissions
+	socketSettings := []struct {
+		env    string
+		target *string
+	}{
+		{"PLUGIN_SOCKET_MODE", &c.Plugin.SocketMode},
+		{"PLUGIN_SOCKET_OWNER", &c.Plugin.SocketOwner},
+		{"PLUGIN_SOCKET_GROUP", &c.Plugin.SocketGroup},
+		{"ADMIN_SOCKET_MODE", &c.Plugin.AdminSocketMode},
+		{"ADMIN_SOCKET_OWNER", &c.Plugin.AdminSocketOwner},
+		{"ADMIN_SOCKET_GROUP", &c.Plugin.AdminSocketGroup},
+		{"TLS_ADDRESS", &c.Plugin.TLSAddress},
+		{"TLS_CERT_FILE", &c.Plugin.TLSCertFile},
+		{"TLS_KEY_FILE", &c.Plugin.TLSKeyFile},
+		{"TLS_CLIENT_CA_FILE", &c.Plugin.TLSClientCAFile},
+	}
+	for _, setting := range socketSettings {
+		if value := os.Getenv(setting.env); value != "" {
+			if c.Plugin.Debug {
+				log.Printf("DEBUG: Applying %s from environment: %s", setting.env, value)
+			}
+			*setting.target = value
+		}
+	}
+
+	// HTTP request limits
+	if rateStr := os.Getenv("PLUGIN_RATE_LIMIT"); rateStr != "" {
+		if rate, err := strconv.ParseFloat(rateStr, 64); err == nil && rate >= 0 {
+			if c.Plugin.Debug {
+				log.Printf("DEBUG: Applying PLUGIN_RATE_LIMIT from environment: %v", rate)
+			}
+			c.Plugin.RateLimit = rate
+		}
+	}
+
+	if burstStr := os.Getenv("PLUGIN_RATE_BURST"); burstStr != "" {
+		if burst, err := strconv.Atoi(burstStr); err == nil && burst > 0 {
+			if c.Plugin.Debug {
+				log.Printf("DEBUG: Applying PLUGIN_RATE_BURST from environment: %d", burst)
+			}
+			c.Plugin.RateBurst = burst
+		}
+	}
+
+	if sizeStr := os.Getenv("PLUGIN_MAX_REQUEST_BYTES"); sizeStr != "" {
+		if size, err := strconv.ParseInt(sizeStr, 10, 64); err == nil && size >= 0 {
+			if c.Plugin.Debug {
+				log.Printf("DEBUG: Applying PLUGIN_MAX_REQUEST_BYTES from environment: %d", size)
+			}
+			c.Plugin.MaxRequestBytes = size
+		}
+	}
+
+	// DNS query limits
+	for _, setting := range []struct {
+		env    string
+		target *float64
+	}{
+		{"DNS_SOURCE_QPS", &c.Plugin.DNSSourceQPS},
+		{"DNS_GLOBAL_QPS", &c.Plugin.DNSGlobalQPS},
+	} {
+		if rateStr := os.Getenv(setting.env); rateStr != "" {
+			if rate, err := strconv.ParseFloat(rateStr, 64); err == nil && rate >= 0 {
+				if c.Plugin.Debug {
+					log.Printf("DEBUG: Applying %s from environment: %v", setting.env, rate)
+				}
+				*setting.target = rate
+			}
+		}
+	}
+
+	// Request tracing
+	if trace := os.Getenv("PLUGIN_TRACE_REQUESTS"); trace != "" {
+		c.Plugin.TraceRequests = parseBool(trace, c.Plugin.TraceRequests)
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Applying PLUGIN_TRACE_REQUESTS from environment: %v", c.Plugin.TraceRequests)
+		}
+	}
+
+	if sizeStr := os.Getenv("PLUGIN_TRACE_BUFFER_SIZE"); sizeStr != "" {
+		if size, err := strconv.Atoi(sizeStr); err == nil && size > 0 {
+			if c.Plugin.Debug {
+				log.Printf("DEBUG: Applying PLUGIN_TRACE_BUFFER_SIZE from environment: %d", size)
+			}
+			c.Plugin.TraceBufferSize = size
+		}
+	}
+
+	// Service exposure
+	if workersStr := os.Getenv("EXPOSURE_CONCURRENCY"); workersStr != "" {
+		if workers, err := strconv.Atoi(workersStr); err == nil && workers > 0 {
+			if c.Plugin.Debug {
+				log.Printf("DEBUG: Applying EXPOSURE_CONCURRENCY from environment: %d", workers)
+			}
+			c.Plugin.ExposureConcurrency = workers
+		}
+	}
+
+	// Inbound peer blocking and session maintenance
+	for _, setting := range []struct {
+		env    string
+		target *int
+	}{
+		{"PEER_FLOOD_STREAMS", &c.Plugin.PeerFloodStreams},
+		{"PEER_EMPTY_STREAMS", &c.Plugin.PeerEmptyStreams},
+		{"MAINTENANCE_BATCH", &c.Plugin.MaintenanceBatch},
+		{"KEY_POOL_SIZE", &c.Plugin.KeyPoolSize},
+		{"DNS_SOURCE_BURST", &c.Plugin.DNSSourceBurst},
+		{"DNS_GLOBAL_BURST", &c.Plugin.DNSGlobalBurst},
+	} {
+		if valueStr := os.Getenv(setting.env); valueStr != "" {
+			if value, err := strconv.Atoi(valueStr); err == nil && value > 0 {
+				if c.Plugin.Debug {
+					log.Printf("DEBUG: Applying %s from environment: %d", setting.env, value)
+				}
+				*setting.target = value
+			}
+		}
+	}
+
+	// Startup smoke test
+	if smokeTest := os.Getenv("PLUGIN_SMOKE_TEST"); smokeTest != "" {
+		c.Plugin.SmokeTest = parseBool(smokeTest, c.Plugin.SmokeTest)
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Applying PLUGIN_SMOKE_TEST from environment: %v", c.Plugin.SmokeTest)
+		}
+	}
+
+	// Address publication and naming
+	publishSettings := []struct {
+		env    string
+		target *string
+	}{
+		{"PUBLISH_BACKEND", &c.Plugin.PublishBackend},
+		{"PUBLISH_TARGET", &c.Plugin.PublishTarget},
+		{"PUBLISH_ZONE", &c.Plugin.PublishZone},
+		{"PUBLISH_ENCODING", &c.Plugin.PublishEncoding},
+		{"LABEL_PATH", &c.Plugin.LabelPath},
+		{"NAMING_BACKENDS", &c.Plugin.NamingBackends},
+		{"NAMING_HOSTS_FILE", &c.Plugin.NamingHostsFile},
+		{"NAMING_REGISTRAR_URL", &c.Plugin.NamingRegistrarURL},
+		{"SOCKS_ALIASES", &c.Plugin.SOCKSAliases},
+		{"DNS_ADDRESS_MODE", &c.Plugin.DNSAddressMode},
+		{"STALE_CHAINS", &c.Plugin.StaleChains},
+		{"FIREWALL_BACKEND", &c.Plugin.FirewallBackend},
+		{"LINK_BACKEND", &c.Plugin.LinkBackend},
+		{"SCOPE", &c.Plugin.Scope},
+		{"MESH_PEERS", &c.Plugin.MeshPeers},
+		{"PEER_BLOCK_DURATION", &c.Plugin.PeerBlockDuration},
+		{"MAINTENANCE_WINDOWS", &c.Plugin.MaintenanceWindows},
+		{"MAINTENANCE_KEY_ROTATION", &c.Plugin.MaintenanceKeyRotation},
+		{"KEY_SLOW_THRESHOLD", &c.Plugin.KeySlowThreshold},
+		{"ANOMALY_WEBHOOK", &c.Plugin.AnomalyWebhook},
+	}
+	for _, setting := range publishSettings {
+		if value := os.Getenv(setting.env); value != "" {
+			if c.Plugin.Debug {
+				log.Printf("DEBUG: Applying %s from environment: %s", setting.env, value)
+			}
+			*setting.target = value
+		}
+	}
+
+	// Container identity persistence
+	if identityPath, ok := os.LookupEnv("IDENTITY_PATH"); ok {
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Applying IDENTITY_PATH from environment: %s", identityPath)
+		}
+		c.Plugin.IdentityPath = identityPath
+	}
+
+	// Network state persistence
+	if statePath, ok := os.LookupEnv("STATE_PATH"); ok {
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Applying STATE_PATH from environment: %s", statePath)
+		}
+		c.Plugin.StatePath = statePath
+	}
+	if dockerSocket, ok := os.LookupEnv("DOCKER_SOCKET"); ok {
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Applying DOCKER_SOCKET from environment: %s", dockerSocket)
+		}
+		c.Plugin.DockerSocket = dockerSocket
+	}
+
+	// Traffic statistics persistence
+	if statsPath, ok := os.LookupEnv("STATS_PATH"); ok {
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Applying STATS_PATH from environment: %s", statsPath)
+		}
+		c.Plugin.StatsPath = statsPath
+	}
+
+	if interval := os.Getenv("STATS_INTERVAL"); interval != "" {
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Applying STATS_INTERVAL from environment: %s", interval)
+		}
+		c.Plugin.StatsInterval = interval
+	}
+
 	// I2P SAM configuration
 	if host := os.Getenv("I2P_SAM_HOST"); host != "" {
 		if c.Plugin.Debug {
@@ -150,6 +576,36 @@ func (c *Config) LoadFromEnvironment() error {
 		c.SAM.Password = password
 	}
 
+	if backups := os.Getenv("I2P_SAM_BACKUPS"); backups != "" {
+		c.SAM.Backups = nil
+		for _, backup := range strings.Split(backups, ",") {
+			if backup = strings.TrimSpace(backup); backup != "" {
+				c.SAM.Backups = append(c.SAM.Backups, backup)
+			}
+		}
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Applying I2P_SAM_BACKUPS from environment: %v", c.SAM.Backups)
+		}
+	}
+
+	if rateStr := os.Getenv("I2P_SAM_OPERATION_RATE"); rateStr != "" {
+		if rate, err := strconv.ParseFloat(rateStr, 64); err == nil && rate >= 0 {
+			if c.Plugin.Debug {
+				log.Printf("DEBUG: Applying I2P_SAM_OPERATION_RATE from environment: %v", rate)
+			}
+			c.SAM.OperationRate = rate
+		}
+	}
+
+	if burstStr := os.Getenv("I2P_SAM_OPERATION_BURST"); burstStr != "" {
+		if burst, err := strconv.Atoi(burstStr); err == nil && burst > 0 {
+			if c.Plugin.Debug {
+				log.Printf("DEBUG: Applying I2P_SAM_OPERATION_BURST from environment: %d", burst)
+			}
+			c.SAM.OperationBurst = burst
+		}
+	}
+
 	// Tunnel defaults
 	if inTunnels := os.Getenv("I2P_INBOUND_TUNNELS"); inTunnels != "" {
 		if val, err := strconv.Atoi(inTunnels); err == nil && val > 0 {
@@ -189,6 +645,22 @@ func (c *Config) LoadFromEnvironment() error {
 		}
 	}
 
+	if reduceIdle := os.Getenv("I2P_REDUCE_IDLE"); reduceIdle != "" {
+		c.TunnelDefaults.ReduceIdle = parseBool(reduceIdle, c.TunnelDefaults.ReduceIdle)
+	}
+
+	if reduceTime := os.Getenv("I2P_REDUCE_IDLE_TIME"); reduceTime != "" {
+		if val, err := strconv.Atoi(reduceTime); err == nil && val > 0 {
+			c.TunnelDefaults.ReduceIdleTime = val
+		}
+	}
+
+	if reduceQuantity := os.Getenv("I2P_REDUCE_QUANTITY"); reduceQuantity != "" {
+		if val, err := strconv.Atoi(reduceQuantity); err == nil && val > 0 {
+			c.TunnelDefaults.ReduceQuantity = val
+		}
+	}
+
 	return nil
 }
 
@@ -272,6 +744,179 @@ func (c *Config) LoadFromFile(filePath string) error {
 		}
 	}
 
+	if fileConfig.Plugin.AdminSocketPath != "" {
+		c.Plugin.AdminSocketPath = fileConfig.Plugin.AdminSocketPath
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded ADMIN_SOCKET_PATH from file: %s", fileConfig.Plugin.AdminSocketPath)
+		}
+	}
+
+	if fileConfig.Plugin.GRPCSocketPath != "" {
+		c.Plugin.GRPCSocketPath = fileConfig.Plugin.GRPCSocketPath
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded GRPC_SOCKET_PATH from file: %s", fileConfig.Plugin.GRPCSocketPath)
+		}
+	}
+
+	fileSocketSettings := []struct {
+		name   string
+		value  string
+		target *string
+	}{
+		{"PLUGIN_SOCKET_MODE", fileConfig.Plugin.SocketMode, &c.Plugin.SocketMode},
+		{"PLUGIN_SOCKET_OWNER", fileConfig.Plugin.SocketOwner, &c.Plugin.SocketOwner},
+		{"PLUGIN_SOCKET_GROUP", fileConfig.Plugin.SocketGroup, &c.Plugin.SocketGroup},
+		{"ADMIN_SOCKET_MODE", fileConfig.Plugin.AdminSocketMode, &c.Plugin.AdminSocketMode},
+		{"ADMIN_SOCKET_OWNER", fileConfig.Plugin.AdminSocketOwner, &c.Plugin.AdminSocketOwner},
+		{"ADMIN_SOCKET_GROUP", fileConfig.Plugin.AdminSocketGroup, &c.Plugin.AdminSocketGroup},
+		{"PLUGIN_SOCKET_ALLOWED_USERS", fileConfig.Plugin.SocketAllowedUsers, &c.Plugin.SocketAllowedUsers},
+		{"PLUGIN_SOCKET_ALLOWED_GROUPS", fileConfig.Plugin.SocketAllowedGroups, &c.Plugin.SocketAllowedGroups},
+		{"TLS_ADDRESS", fileConfig.Plugin.TLSAddress, &c.Plugin.TLSAddress},
+		{"TLS_CERT_FILE", fileConfig.Plugin.TLSCertFile, &c.Plugin.TLSCertFile},
+		{"TLS_KEY_FILE", fileConfig.Plugin.TLSKeyFile, &c.Plugin.TLSKeyFile},
+		{"TLS_CLIENT_CA_FILE", fileConfig.Plugin.TLSClientCAFile, &c.Plugin.TLSClientCAFile},
+	}
+	for _, setting := range fileSocketSettings {
+		if setting.value != "" {
+			*setting.target = setting.value
+			if c.Plugin.Debug {
+				log.Printf("DEBUG: Loaded %s from file: %s", setting.name, setting.value)
+			}
+		}
+	}
+
+	// HTTP request limits
+	if fileConfig.Plugin.RateLimit > 0 {
+		c.Plugin.RateLimit = fileConfig.Plugin.RateLimit
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded PLUGIN_RATE_LIMIT from file: %v", fileConfig.Plugin.RateLimit)
+		}
+	}
+
+	if fileConfig.Plugin.RateBurst > 0 {
+		c.Plugin.RateBurst = fileConfig.Plugin.RateBurst
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded PLUGIN_RATE_BURST from file: %d", fileConfig.Plugin.RateBurst)
+		}
+	}
+
+	if fileConfig.Plugin.MaxRequestBytes > 0 {
+		c.Plugin.MaxRequestBytes = fileConfig.Plugin.MaxRequestBytes
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded PLUGIN_MAX_REQUEST_BYTES from file: %d", fileConfig.Plugin.MaxRequestBytes)
+		}
+	}
+
+	// DNS query limits
+	for _, setting := range []struct {
+		name   string
+		value  float64
+		target *float64
+	}{
+		{"DNS_SOURCE_QPS", fileConfig.Plugin.DNSSourceQPS, &c.Plugin.DNSSourceQPS},
+		{"DNS_GLOBAL_QPS", fileConfig.Plugin.DNSGlobalQPS, &c.Plugin.DNSGlobalQPS},
+	} {
+		if setting.value > 0 {
+			*setting.target = setting.value
+			if c.Plugin.Debug {
+				log.Printf("DEBUG: Loaded %s from file: %v", setting.name, setting.value)
+			}
+		}
+	}
+
+	// Request tracing
+	if fileConfig.Plugin.TraceRequests {
+		c.Plugin.TraceRequests = true
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded PLUGIN_TRACE_REQUESTS from file: %v", fileConfig.Plugin.TraceRequests)
+		}
+	}
+
+	if fileConfig.Plugin.TraceBufferSize > 0 {
+		c.Plugin.TraceBufferSize = fileConfig.Plugin.TraceBufferSize
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded PLUGIN_TRACE_BUFFER_SIZE from file: %d", fileConfig.Plugin.TraceBufferSize)
+		}
+	}
+
+	// Service exposure
+	if fileConfig.Plugin.ExposureConcurrency > 0 {
+		c.Plugin.ExposureConcurrency = fileConfig.Plugin.ExposureConcurrency
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded EXPOSURE_CONCURRENCY from file: %d", fileConfig.Plugin.ExposureConcurrency)
+		}
+	}
+
+	// Inbound peer blocking and session maintenance
+	for _, setting := range []struct {
+		name   string
+		value  int
+		target *int
+	}{
+		{"PEER_FLOOD_STREAMS", fileConfig.Plugin.PeerFloodStreams, &c.Plugin.PeerFloodStreams},
+		{"PEER_EMPTY_STREAMS", fileConfig.Plugin.PeerEmptyStreams, &c.Plugin.PeerEmptyStreams},
+		{"MAINTENANCE_BATCH", fileConfig.Plugin.MaintenanceBatch, &c.Plugin.MaintenanceBatch},
+		{"KEY_POOL_SIZE", fileConfig.Plugin.KeyPoolSize, &c.Plugin.KeyPoolSize},
+		{"DNS_SOURCE_BURST", fileConfig.Plugin.DNSSourceBurst, &c.Plugin.DNSSourceBurst},
+		{"DNS_GLOBAL_BURST", fileConfig.Plugin.DNSGlobalBurst, &c.Plugin.DNSGlobalBurst},
+	} {
+		if setting.value > 0 {
+			*setting.target = setting.value
+			if c.Plugin.Debug {
+				log.Printf("DEBUG: Loaded %s from file: %d", setting.name, setting.value)
+			}
+		}
+	}
+
+	// Startup smoke test
+	if fileConfig.Plugin.SmokeTest {
+		c.Plugin.SmokeTest = true
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded PLUGIN_SMOKE_TEST from file: %v", fileConfig.Plugin.SmokeTest)
+		}
+	}
+
+	// Address publication, traffic statistics and naming
+	filePublishSettings := []struct {
+		name   string
+		value  string
+		target *string
+	}{
+		{"PUBLISH_BACKEND", fileConfig.Plugin.PublishBackend, &c.Plugin.PublishBackend},
+		{"PUBLISH_TARGET", fileConfig.Plugin.PublishTarget, &c.Plugin.PublishTarget},
+		{"PUBLISH_ZONE", fileConfig.Plugin.PublishZone, &c.Plugin.PublishZone},
+		{"PUBLISH_ENCODING", fileConfig.Plugin.PublishEncoding, &c.Plugin.PublishEncoding},
+		{"LABEL_PATH", fileConfig.Plugin.LabelPath, &c.Plugin.LabelPath},
+		{"STATS_PATH", fileConfig.Plugin.StatsPath, &c.Plugin.StatsPath},
+		{"STATS_INTERVAL", fileConfig.Plugin.StatsInterval, &c.Plugin.StatsInterval},
+		{"IDENTITY_PATH", fileConfig.Plugin.IdentityPath, &c.Plugin.IdentityPath},
+		{"STATE_PATH", fileConfig.Plugin.StatePath, &c.Plugin.StatePath},
+		{"DOCKER_SOCKET", fileConfig.Plugin.DockerSocket, &c.Plugin.DockerSocket},
+		{"NAMING_BACKENDS", fileConfig.Plugin.NamingBackends, &c.Plugin.NamingBackends},
+		{"NAMING_HOSTS_FILE", fileConfig.Plugin.NamingHostsFile, &c.Plugin.NamingHostsFile},
+		{"NAMING_REGISTRAR_URL", fileConfig.Plugin.NamingRegistrarURL, &c.Plugin.NamingRegistrarURL},
+		{"SOCKS_ALIASES", fileConfig.Plugin.SOCKSAliases, &c.Plugin.SOCKSAliases},
+		{"DNS_ADDRESS_MODE", fileConfig.Plugin.DNSAddressMode, &c.Plugin.DNSAddressMode},
+		{"STALE_CHAINS", fileConfig.Plugin.StaleChains, &c.Plugin.StaleChains},
+		{"FIREWALL_BACKEND", fileConfig.Plugin.FirewallBackend, &c.Plugin.FirewallBackend},
+		{"LINK_BACKEND", fileConfig.Plugin.LinkBackend, &c.Plugin.LinkBackend},
+		{"SCOPE", fileConfig.Plugin.Scope, &c.Plugin.Scope},
+		{"MESH_PEERS", fileConfig.Plugin.MeshPeers, &c.Plugin.MeshPeers},
+		{"PEER_BLOCK_DURATION", fileConfig.Plugin.PeerBlockDuration, &c.Plugin.PeerBlockDuration},
+		{"MAINTENANCE_WINDOWS", fileConfig.Plugin.MaintenanceWindows, &c.Plugin.MaintenanceWindows},
+		{"MAINTENANCE_KEY_ROTATION", fileConfig.Plugin.MaintenanceKeyRotation, &c.Plugin.MaintenanceKeyRotation},
+		{"KEY_SLOW_THRESHOLD", fileConfig.Plugin.KeySlowThreshold, &c.Plugin.KeySlowThreshold},
+		{"ANOMALY_WEBHOOK", fileConfig.Plugin.AnomalyWebhook, &c.Plugin.AnomalyWebhook},
+	}
+	for _, setting := range filePublishSettings {
+		if setting.value != "" {
+			*setting.target = setting.value
+			if c.Plugin.Debug {
+				log.Printf("DEBUG: Loaded %s from file: %s", setting.name, setting.value)
+			}
+		}
+	}
+
 	// SAM configuration
 	if fileConfig.SAM.Host != "" {
 		c.SAM.Host = fileConfig.SAM.Host
@@ -294,6 +939,27 @@ func (c *Config) LoadFromFile(filePath string) error {
 		}
 	}
 
+	if len(fileConfig.SAM.Backups) > 0 {
+		c.SAM.Backups = fileConfig.SAM.Backups
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded I2P_SAM_BACKUPS from file: %v", fileConfig.SAM.Backups)
+		}
+	}
+
+	if fileConfig.SAM.OperationRate > 0 {
+		c.SAM.OperationRate = fileConfig.SAM.OperationRate
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded I2P_SAM_OPERATION_RATE from file: %v", fileConfig.SAM.OperationRate)
+		}
+	}
+
+	if fileConfig.SAM.OperationBurst > 0 {
+		c.SAM.OperationBurst = fileConfig.SAM.OperationBurst
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded I2P_SAM_OPERATION_BURST from file: %d", fileConfig.SAM.OperationBurst)
+		}
+	}
+
 	// Tunnel defaults
 	if fileConfig.TunnelDefaults.InboundTunnels > 0 {
 		c.TunnelDefaults.InboundTunnels = fileConfig.TunnelDefaults.InboundTunnels
@@ -359,6 +1025,34 @@ func (c *Config) LoadFromFile(filePath string) error {
 		}
 	}
 
+	if fileConfig.TunnelDefaults.ReduceIdle {
+		c.TunnelDefaults.ReduceIdle = fileConfig.TunnelDefaults.ReduceIdle
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded ReduceIdle from file: %v", fileConfig.TunnelDefaults.ReduceIdle)
+		}
+	}
+
+	if fileConfig.TunnelDefaults.ReduceIdleTime > 0 {
+		c.TunnelDefaults.ReduceIdleTime = fileConfig.TunnelDefaults.ReduceIdleTime
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded ReduceIdleTime from file: %d", fileConfig.TunnelDefaults.ReduceIdleTime)
+		}
+	}
+
+	if fileConfig.TunnelDefaults.ReduceQuantity > 0 {
+		c.TunnelDefaults.ReduceQuantity = fileConfig.TunnelDefaults.ReduceQuantity
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded ReduceQuantity from file: %d", fileConfig.TunnelDefaults.ReduceQuantity)
+		}
+	}
+
+	if len(fileConfig.TunnelDefaults.Extra) > 0 {
+		c.TunnelDefaults.Extra = fileConfig.TunnelDefaults.Extra
+		if c.Plugin.Debug {
+			log.Printf("DEBUG: Loaded %d extra tunnel options from file", len(fileConfig.TunnelDefaults.Extra))
+		}
+	}
+
 	if c.Plugin.Debug {
 		log.Printf("DEBUG: Successfully loaded configuration from file: %s", filePath)
 	}
@@ -390,11 +1084,169 @@ func (c *Config) Validate() error {
 	if c.Plugin.IPAMSubnet == "" {
 		return fmt.Errorf("IPAM subnet cannot be empty")
 	}
+	if _, subnet, err := net.ParseCIDR(c.Plugin.IPAMSubnet); err != nil {
+		return fmt.Errorf("invalid IPAM subnet: %w", err)
+	} else if ones, bits := subnet.Mask.Size(); bits != 32 || ones > 23 {
+		return fmt.Errorf("IPAM subnet must be an IPv4 subnet of /23 or larger, got %q", c.Plugin.IPAMSubnet)
+	}
 
 	if c.Plugin.Gateway == "" {
 		return fmt.Errorf("gateway cannot be empty")
 	}
 
+	if _, err := ParseSocketMode(c.Plugin.SocketMode); err != nil {
+		return fmt.Errorf("invalid plugin socket mode: %w", err)
+	}
+
+	if _, err := ParseSocketMode(c.Plugin.AdminSocketMode); err != nil {
+		return fmt.Errorf("invalid admin socket mode: %w", err)
+	}
+
+	for _, entry := range append(c.GetSocketAllowedUsers(), c.GetSocketAllowedGroups()...) {
+		if strings.ContainsAny(entry, " \t:") {
+			return fmt.Errorf("socket allowlist entry %q is not a name or numeric ID", entry)
+		}
+	}
+
+	if c.Plugin.TLSAddress != "" {
+		if _, port, err := net.SplitHostPort(c.Plugin.TLSAddress); err != nil || port == "" {
+			return fmt.Errorf("TLS address must be host:port, got %q", c.Plugin.TLSAddress)
+		}
+		if c.Plugin.TLSCertFile == "" || c.Plugin.TLSKeyFile == "" || c.Plugin.TLSClientCAFile == "" {
+			return fmt.Errorf("TLS address requires a certificate, key and client CA file")
+		}
+	}
+
+	if c.Plugin.RateLimit < 0 {
+		return fmt.Errorf("rate limit cannot be negative, got %v", c.Plugin.RateLimit)
+	}
+
+	if c.Plugin.RateLimit > 0 && c.Plugin.RateBurst < 1 {
+		return fmt.Errorf("rate burst must be at least 1 when rate limiting is enabled, got %d", c.Plugin.RateBurst)
+	}
+
+	if c.Plugin.MaxRequestBytes < 0 {
+		return fmt.Errorf("max request bytes cannot be negative, got %d", c.Plugin.MaxRequestBytes)
+	}
+
+	if c.Plugin.TraceBufferSize <= 0 {
+		return fmt.Errorf("trace buffer size must be positive, got %d", c.Plugin.TraceBufferSize)
+	}
+
+	if c.Plugin.ExposureConcurrency < 1 || c.Plugin.ExposureConcurrency > maxExposureConcurrency {
+		return fmt.Errorf("exposure concurrency must be between 1 and %d, got %d", maxExposureConcurrency, c.Plugin.ExposureConcurrency)
+	}
+
+	switch c.Plugin.PublishBackend {
+	case "":
+	case "zonefile", "consul", "http":
+		if c.Plugin.PublishTarget == "" {
+			return fmt.Errorf("publish target cannot be empty when publish backend %s is set", c.Plugin.PublishBackend)
+		}
+	default:
+		return fmt.Errorf("publish backend must be zonefile, consul or http, got %q", c.Plugin.PublishBackend)
+	}
+	if c.Plugin.PublishEncoding != "" {
+		if err := i2p.ValidateEncoding(c.Plugin.PublishEncoding); err != nil {
+			return fmt.Errorf("publish encoding: %w", err)
+		}
+	}
+
+	if c.Plugin.LabelPath != "" {
+		if !path.IsAbs(c.Plugin.LabelPath) || path.Clean(c.Plugin.LabelPath) != c.Plugin.LabelPath || c.Plugin.LabelPath == "/" {
+			return fmt.Errorf("label path must be a clean absolute file path, got %q", c.Plugin.LabelPath)
+		}
+		if c.Plugin.DockerSocket == "" {
+			return fmt.Errorf("label path requires a Docker socket")
+		}
+	}
+
+	if interval, err := time.ParseDuration(c.Plugin.StatsInterval); err != nil || interval <= 0 {
+		return fmt.Errorf("stats interval must be a positive duration, got %q", c.Plugin.StatsInterval)
+	}
+
+	for _, backend := range c.GetNamingBackends() {
+		switch backend {
+		case "sam":
+		case "hosts":
+			if c.Plugin.NamingHostsFile == "" {
+				return fmt.Errorf("naming hosts file cannot be empty when the hosts naming backend is enabled")
+			}
+		case "registrar":
+			if c.Plugin.NamingRegistrarURL == "" {
+				return fmt.Errorf("naming registrar URL cannot be empty when the registrar naming backend is enabled")
+			}
+		default:
+			return fmt.Errorf("naming backend must be sam, hosts or registrar, got %q", backend)
+		}
+	}
+
+	for _, alias := range c.GetSOCKSAliases() {
+		if name, destination, ok := strings.Cut(alias, "="); !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(destination) == "" {
+			return fmt.Errorf("SOCKS aliases must be alias=destination pairs, got %q", alias)
+		}
+	}
+
+	switch c.Plugin.DNSAddressMode {
+	case "ipv4", "ipv6":
+	default:
+		return fmt.Errorf("DNS address mode must be ipv4 or ipv6, got %q", c.Plugin.DNSAddressMode)
+	}
+
+	if duration, err := time.ParseDuration(c.Plugin.PeerBlockDuration); err != nil || duration < 0 {
+		return fmt.Errorf("peer block duration must be a non-negative duration, got %q", c.Plugin.PeerBlockDuration)
+	}
+	if c.Plugin.DNSSourceQPS < 0 || c.Plugin.DNSGlobalQPS < 0 {
+		return fmt.Errorf("DNS query rates cannot be negative, got %v and %v", c.Plugin.DNSSourceQPS, c.Plugin.DNSGlobalQPS)
+	}
+	if (c.Plugin.DNSSourceQPS > 0 && c.Plugin.DNSSourceBurst < 1) || (c.Plugin.DNSGlobalQPS > 0 && c.Plugin.DNSGlobalBurst < 1) {
+		return fmt.Errorf("DNS query bursts must be at least 1 when their limit is enabled, got %d and %d", c.Plugin.DNSSourceBurst, c.Plugin.DNSGlobalBurst)
+	}
+
+	if c.Plugin.PeerFloodStreams < 1 || c.Plugin.PeerEmptyStreams < 1 {
+		return fmt.Errorf("peer flood and empty stream thresholds must be positive, got %d and %d", c.Plugin.PeerFloodStreams, c.Plugin.PeerEmptyStreams)
+	}
+
+	if _, err := i2p.ParseMaintenanceWindows(c.Plugin.MaintenanceWindows); err != nil {
+		return err
+	}
+	if c.Plugin.MaintenanceBatch < 1 {
+		return fmt.Errorf("maintenance batch must be positive, got %d", c.Plugin.MaintenanceBatch)
+	}
+	if rotation, err := time.ParseDuration(c.Plugin.MaintenanceKeyRotation); err != nil || rotation < 0 {
+		return fmt.Errorf("maintenance key rotation must be a non-negative duration, got %q", c.Plugin.MaintenanceKeyRotation)
+	}
+
+	if c.Plugin.KeyPoolSize < 0 || c.Plugin.KeyPoolSize > i2p.MaxKeyPoolSize {
+		return fmt.Errorf("key pool size must be between 0 and %d, got %d", i2p.MaxKeyPoolSize, c.Plugin.KeyPoolSize)
+	}
+	if threshold, err := time.ParseDuration(c.Plugin.KeySlowThreshold); err != nil || threshold < 0 {
+		return fmt.Errorf("key slow threshold must be a non-negative duration, got %q", c.Plugin.KeySlowThreshold)
+	}
+
+	switch c.Plugin.StaleChains {
+	case "reconcile", "flush":
+	default:
+		return fmt.Errorf("stale chain mode must be reconcile or flush, got %q", c.Plugin.StaleChains)
+	}
+	switch c.Plugin.FirewallBackend {
+	case "iptables", "nftables", "noop":
+	case "ebpf":
+		return fmt.Errorf("firewall backend ebpf is not supported, use iptables, nftables or noop")
+	default:
+		return fmt.Errorf("firewall backend must be iptables, nftables or noop, got %q", c.Plugin.FirewallBackend)
+	}
+	switch c.Plugin.LinkBackend {
+	case "netlink", "noop":
+	default:
+		return fmt.Errorf("link backend must be netlink or noop, got %q", c.Plugin.LinkBackend)
+	}
+	switch c.Plugin.Scope {
+	case "local", "global":
+	default:
+		return fmt.Errorf("scope must be local or global, got %q", c.Plugin.Scope)
+	}
+
 	// Validate SAM configuration
 	if c.SAM.Host == "" {
 		return fmt.Errorf("SAM host cannot be empty")
@@ -408,6 +1260,20 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("SAM timeout must be positive, got %v", c.SAM.Timeout)
 	}
 
+	for _, backup := range c.SAM.Backups {
+		if _, _, err := net.SplitHostPort(backup); err != nil {
+			return fmt.Errorf("invalid SAM backup address %q: %w", backup, err)
+		}
+	}
+
+	if c.SAM.OperationRate < 0 {
+		return fmt.Errorf("SAM operation rate cannot be negative, got %v", c.SAM.OperationRate)
+	}
+
+	if c.SAM.OperationRate > 0 && c.SAM.OperationBurst < 1 {
+		return fmt.Errorf("SAM operation burst must be at least 1 when pacing is enabled, got %d", c.SAM.OperationBurst)
+	}
+
 	// Validate tunnel defaults
 	if c.TunnelDefaults.InboundTunnels <= 0 {
 		return fmt.Errorf("inbound tunnels must be positive, got %d", c.TunnelDefaults.InboundTunnels)
@@ -429,6 +1295,18 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("close idle time must be positive, got %d", c.TunnelDefaults.CloseIdleTime)
 	}
 
+	if c.TunnelDefaults.ReduceIdleTime <= 0 {
+		return fmt.Errorf("reduce idle time must be positive, got %d", c.TunnelDefaults.ReduceIdleTime)
+	}
+
+	if c.TunnelDefaults.ReduceQuantity <= 0 {
+		return fmt.Errorf("reduce quantity must be positive, got %d", c.TunnelDefaults.ReduceQuantity)
+	}
+
+	if err := c.TunnelDefaults.ValidateExtra(); err != nil {
+		return err
+	}
+
 	if c.Plugin.Debug {
 		log.Printf("DEBUG: Configuration validation successful")
 	}
@@ -446,6 +1324,119 @@ func (c *Config) GetTunnelDefaults() *i2p.TunnelOptions {
 	return &c.TunnelDefaults
 }
 
+// GetStatsInterval returns how often traffic statistics are saved.
+//
+// Validate rejects invalid intervals, so an unparseable value only occurs on
+// unvalidated configurations and yields zero.
+func (c *Config) GetStatsInterval() time.Duration {
+	interval, _ := time.ParseDuration(c.Plugin.StatsInterval)
+	return interval
+}
+
+// GetPeerReputation returns the settings for blocking abusive inbound I2P
+// peers.
+//
+// Validate rejects invalid block durations, so an unparseable value only
+// occurs on unvalidated configurations and disables blocking.
+func (c *Config) GetPeerReputation() i2p.PeerReputationConfig {
+	config := i2p.DefaultPeerReputationConfig()
+	config.BlockDuration, _ = time.ParseDuration(c.Plugin.PeerBlockDuration)
+	config.FloodStreams = float64(c.Plugin.PeerFloodStreams)
+	config.EmptyStreams = float64(c.Plugin.PeerEmptyStreams)
+	return config
+}
+
+// GetMaintenance returns when and how container sessions are rebuilt.
+//
+// Validate rejects invalid windows and key rotation ages, so an unparseable
+// value only occurs on unvalidated configurations and is left out.
+func (c *Config) GetMaintenance() i2p.MaintenanceConfig {
+	config := i2p.MaintenanceConfig{Batch: c.Plugin.MaintenanceBatch}
+	config.Windows, _ = i2p.ParseMaintenanceWindows(c.Plugin.MaintenanceWindows)
+	config.KeyRotation, _ = time.ParseDuration(c.Plugin.MaintenanceKeyRotation)
+	return config
+}
+
+// GetKeyGeneration returns the key pool size and slow key generation
+// threshold.
+//
+// Validate rejects invalid thresholds, so an unparseable value only occurs
+// on unvalidated configurations and disables the warning.
+func (c *Config) GetKeyGeneration() i2p.KeyGenerationConfig {
+	config := i2p.KeyGenerationConfig{PoolSize: c.Plugin.KeyPoolSize}
+	config.SlowThreshold, _ = time.ParseDuration(c.Plugin.KeySlowThreshold)
+	return config
+}
+
+// GetNamingBackends returns the configured naming backends in lookup order.
+func (c *Config) GetNamingBackends() []string {
+	var backends []string
+	for _, backend := range strings.Split(c.Plugin.NamingBackends, ",") {
+		if backend = strings.ToLower(strings.TrimSpace(backend)); backend != "" {
+			backends = append(backends, backend)
+		}
+	}
+	return backends
+}
+
+// GetSOCKSAliases returns the configured SOCKS alias=destination pairs.
+func (c *Config) GetSOCKSAliases() []string {
+	var aliases []string
+	for _, alias := range strings.Split(c.Plugin.SOCKSAliases, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// GetMeshPeers returns the control addresses of the mesh peers.
+func (c *Config) GetMeshPeers() []string {
+	var peers []string
+	for _, peer := range strings.Split(c.Plugin.MeshPeers, ",") {
+		if peer = strings.TrimSpace(peer); peer != "" {
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
+
+// GetSocketAllowedUsers returns the users whose processes may connect to the
+// plugin socket.
+func (c *Config) GetSocketAllowedUsers() []string {
+	var users []string
+	for _, name := range strings.Split(c.Plugin.SocketAllowedUsers, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			users = append(users, name)
+		}
+	}
+	return users
+}
+
+// GetSocketAllowedGroups returns the groups whose members may connect to the
+// plugin socket.
+func (c *Config) GetSocketAllowedGroups() []string {
+	var groups []string
+	for _, name := range strings.Split(c.Plugin.SocketAllowedGroups, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			groups = append(groups, name)
+		}
+	}
+	return groups
+}
+
+// ParseSocketMode parses an octal socket permission mode such as "0660".
+func ParseSocketMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(strings.TrimSpace(s), 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("mode %q must be an octal number: %w", s, err)
+	}
+	if mode > 0777 {
+		return 0, fmt.Errorf("mode %q must not exceed 0777", s)
+	}
+	return os.FileMode(mode), nil
+}
+
 // parseBool parses a string as a boolean with a default fallback.
 func parseBool(s string, defaultValue bool) bool {
 	switch s {
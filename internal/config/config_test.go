@@ -57,10 +57,23 @@ func TestLoadFromEnvironment(t *testing.T) {
 	// Save original environment
 	originalEnv := map[string]string{}
 	envVars := []string{
-		"PLUGIN_SOCKET_PATH", "DEBUG", "NETWORK_NAME", "IPAM_SUBNET", "GATEWAY",
+		"PLUGIN_SOCKET_PATH", "DEBUG", "NETWORK_NAME", "IPAM_SUBNET", "GATEWAY", "ADMIN_SOCKET_PATH", "GRPC_SOCKET_PATH",
+		"PLUGIN_SOCKET_MODE", "PLUGIN_SOCKET_OWNER", "PLUGIN_SOCKET_GROUP",
+		"ADMIN_SOCKET_MODE", "ADMIN_SOCKET_OWNER", "ADMIN_SOCKET_GROUP",
+		"PLUGIN_SOCKET_ALLOWED_USERS", "PLUGIN_SOCKET_ALLOWED_GROUPS",
+		"TLS_ADDRESS", "TLS_CERT_FILE", "TLS_KEY_FILE", "TLS_CLIENT_CA_FILE",
+		"PLUGIN_RATE_LIMIT", "PLUGIN_RATE_BURST", "PLUGIN_MAX_REQUEST_BYTES",
+		"PLUGIN_TRACE_REQUESTS", "PLUGIN_TRACE_BUFFER_SIZE", "EXPOSURE_CONCURRENCY",
+		"PUBLISH_BACKEND", "PUBLISH_TARGET", "PUBLISH_ZONE", "PUBLISH_ENCODING", "LABEL_PATH", "STATS_PATH", "STATS_INTERVAL",
+		"IDENTITY_PATH", "STATE_PATH", "DOCKER_SOCKET", "SOCKS_ALIASES",
+		"DNS_ADDRESS_MODE", "STALE_CHAINS", "ANOMALY_WEBHOOK", "PLUGIN_SMOKE_TEST",
+		"PEER_BLOCK_DURATION", "PEER_FLOOD_STREAMS", "PEER_EMPTY_STREAMS",
+		"MAINTENANCE_WINDOWS", "MAINTENANCE_BATCH", "MAINTENANCE_KEY_ROTATION",
 		"I2P_SAM_HOST", "I2P_SAM_PORT", "I2P_SAM_TIMEOUT", "I2P_SAM_USERNAME", "I2P_SAM_PASSWORD",
+		"I2P_SAM_BACKUPS", "I2P_SAM_OPERATION_RATE", "I2P_SAM_OPERATION_BURST",
 		"I2P_INBOUND_TUNNELS", "I2P_OUTBOUND_TUNNELS", "I2P_INBOUND_LENGTH", "I2P_OUTBOUND_LENGTH",
 		"I2P_ENCRYPT_LEASESET", "I2P_CLOSE_IDLE", "I2P_CLOSE_IDLE_TIME",
+		"I2P_REDUCE_IDLE", "I2P_REDUCE_IDLE_TIME", "I2P_REDUCE_QUANTITY",
 	}
 
 	for _, key := range envVars {
@@ -111,6 +124,102 @@ func TestLoadFromEnvironment(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "address publication",
+			envVars: map[string]string{
+				"PUBLISH_BACKEND":  "consul",
+				"PUBLISH_TARGET":   "http://127.0.0.1:8500/v1/kv/i2p",
+				"PUBLISH_ZONE":     "i2p.example",
+				"PUBLISH_ENCODING": "b33",
+				"LABEL_PATH":       "/run/i2p/labels",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.PublishBackend != "consul" {
+					t.Errorf("Expected publish backend 'consul', got '%s'", c.Plugin.PublishBackend)
+				}
+				if c.Plugin.PublishTarget != "http://127.0.0.1:8500/v1/kv/i2p" {
+					t.Errorf("Expected publish target 'http://127.0.0.1:8500/v1/kv/i2p', got '%s'", c.Plugin.PublishTarget)
+				}
+				if c.Plugin.PublishZone != "i2p.example" {
+					t.Errorf("Expected publish zone 'i2p.example', got '%s'", c.Plugin.PublishZone)
+				}
+				if c.Plugin.PublishEncoding != "b33" {
+					t.Errorf("Expected publish encoding 'b33', got '%s'", c.Plugin.PublishEncoding)
+				}
+				if c.Plugin.LabelPath != "/run/i2p/labels" {
+					t.Errorf("Expected label path '/run/i2p/labels', got '%s'", c.Plugin.LabelPath)
+				}
+			},
+		},
+		{
+			name: "traffic statistics persistence",
+			envVars: map[string]string{
+				"STATS_PATH":     "/data/stats.json",
+				"STATS_INTERVAL": "30s",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.StatsPath != "/data/stats.json" {
+					t.Errorf("Expected stats path '/data/stats.json', got '%s'", c.Plugin.StatsPath)
+				}
+				if c.GetStatsInterval() != 30*time.Second {
+					t.Errorf("Expected stats interval 30s, got %v", c.GetStatsInterval())
+				}
+			},
+		},
+		{
+			name: "network state persistence",
+			envVars: map[string]string{
+				"STATE_PATH":    "/tmp/networks.json",
+				"DOCKER_SOCKET": "",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.StatePath != "/tmp/networks.json" {
+					t.Errorf("Expected state path '/tmp/networks.json', got '%s'", c.Plugin.StatePath)
+				}
+				if c.Plugin.DockerSocket != "" {
+					t.Errorf("Expected reconciliation with Docker to be disabled, got '%s'", c.Plugin.DockerSocket)
+				}
+			},
+		},
+		{
+			name: "identity persistence",
+			envVars: map[string]string{
+				"IDENTITY_PATH": "",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.IdentityPath != "" {
+					t.Errorf("Expected identity persistence to be disabled, got '%s'", c.Plugin.IdentityPath)
+				}
+			},
+		},
+		{
+			name: "naming backends",
+			envVars: map[string]string{
+				"NAMING_BACKENDS":   "Hosts, sam",
+				"NAMING_HOSTS_FILE": "/etc/i2p/hosts.txt",
+			},
+			validate: func(t *testing.T, c *Config) {
+				backends := c.GetNamingBackends()
+				if len(backends) != 2 || backends[0] != "hosts" || backends[1] != "sam" {
+					t.Errorf("Expected naming backends [hosts sam], got %v", backends)
+				}
+				if c.Plugin.NamingHostsFile != "/etc/i2p/hosts.txt" {
+					t.Errorf("Expected naming hosts file '/etc/i2p/hosts.txt', got '%s'", c.Plugin.NamingHostsFile)
+				}
+			},
+		},
+		{
+			name: "SOCKS aliases",
+			envVars: map[string]string{
+				"SOCKS_ALIASES": "service.internal=forum.i2p, *.corp=*.i2p",
+			},
+			validate: func(t *testing.T, c *Config) {
+				aliases := c.GetSOCKSAliases()
+				if len(aliases) != 2 || aliases[0] != "service.internal=forum.i2p" || aliases[1] != "*.corp=*.i2p" {
+					t.Errorf("Expected two SOCKS aliases, got %v", aliases)
+				}
+			},
+		},
 		{
 			name: "SAM configuration",
 			envVars: map[string]string{
@@ -138,6 +247,260 @@ func TestLoadFromEnvironment(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "admin API disabled",
+			envVars: map[string]string{
+				"ADMIN_SOCKET_PATH": "",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.AdminSocketPath != "" {
+					t.Errorf("Expected admin socket path to be empty, got '%s'", c.Plugin.AdminSocketPath)
+				}
+			},
+		},
+		{
+			name: "gRPC API enabled",
+			envVars: map[string]string{
+				"GRPC_SOCKET_PATH": "/run/i2p-network-plugin/grpc.sock",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.GRPCSocketPath != "/run/i2p-network-plugin/grpc.sock" {
+					t.Errorf("Expected gRPC socket path to be set, got '%s'", c.Plugin.GRPCSocketPath)
+				}
+			},
+		},
+		{
+			name: "socket permissions",
+			envVars: map[string]string{
+				"PLUGIN_SOCKET_MODE":  "0660",
+				"PLUGIN_SOCKET_GROUP": "docker",
+				"ADMIN_SOCKET_OWNER":  "1000",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.SocketMode != "0660" {
+					t.Errorf("Expected socket mode '0660', got '%s'", c.Plugin.SocketMode)
+				}
+				if c.Plugin.SocketGroup != "docker" {
+					t.Errorf("Expected socket group 'docker', got '%s'", c.Plugin.SocketGroup)
+				}
+				if c.Plugin.AdminSocketOwner != "1000" {
+					t.Errorf("Expected admin socket owner '1000', got '%s'", c.Plugin.AdminSocketOwner)
+				}
+				if c.Plugin.AdminSocketMode != "0600" {
+					t.Errorf("Expected default admin socket mode '0600', got '%s'", c.Plugin.AdminSocketMode)
+				}
+			},
+		},
+		{
+			name: "socket peer allowlist",
+			envVars: map[string]string{
+				"PLUGIN_SOCKET_ALLOWED_USERS":  "",
+				"PLUGIN_SOCKET_ALLOWED_GROUPS": "docker, 998",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if users := c.GetSocketAllowedUsers(); len(users) != 0 {
+					t.Errorf("Expected an empty PLUGIN_SOCKET_ALLOWED_USERS to clear the default, got %v", users)
+				}
+				if groups := c.GetSocketAllowedGroups(); len(groups) != 2 || groups[1] != "998" {
+					t.Errorf("Expected groups docker and 998, got %v", groups)
+				}
+			},
+		},
+		{
+			name: "TLS listener",
+			envVars: map[string]string{
+				"TLS_ADDRESS":        "0.0.0.0:9443",
+				"TLS_CERT_FILE":      "/etc/i2p/cert.pem",
+				"TLS_KEY_FILE":       "/etc/i2p/key.pem",
+				"TLS_CLIENT_CA_FILE": "/etc/i2p/ca.pem",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.TLSAddress != "0.0.0.0:9443" || c.Plugin.TLSClientCAFile != "/etc/i2p/ca.pem" {
+					t.Errorf("Expected the TLS listener settings, got %q and %q", c.Plugin.TLSAddress, c.Plugin.TLSClientCAFile)
+				}
+			},
+		},
+		{
+			name: "request limits",
+			envVars: map[string]string{
+				"PLUGIN_RATE_LIMIT":        "0",
+				"PLUGIN_RATE_BURST":        "invalid",
+				"PLUGIN_MAX_REQUEST_BYTES": "4096",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.RateLimit != 0 {
+					t.Errorf("Expected rate limiting disabled, got %v", c.Plugin.RateLimit)
+				}
+				if c.Plugin.RateBurst != 100 {
+					t.Errorf("Expected default rate burst 100, got %d", c.Plugin.RateBurst)
+				}
+				if c.Plugin.MaxRequestBytes != 4096 {
+					t.Errorf("Expected max request bytes 4096, got %d", c.Plugin.MaxRequestBytes)
+				}
+			},
+		},
+		{
+			name: "request tracing",
+			envVars: map[string]string{
+				"PLUGIN_TRACE_REQUESTS":    "true",
+				"PLUGIN_TRACE_BUFFER_SIZE": "50",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if !c.Plugin.TraceRequests {
+					t.Error("Expected request tracing to be enabled")
+				}
+				if c.Plugin.TraceBufferSize != 50 {
+					t.Errorf("Expected trace buffer size 50, got %d", c.Plugin.TraceBufferSize)
+				}
+			},
+		},
+		{
+			name: "startup smoke test",
+			envVars: map[string]string{
+				"PLUGIN_SMOKE_TEST": "true",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if !c.Plugin.SmokeTest {
+					t.Error("Expected the startup smoke test to be enabled")
+				}
+			},
+		},
+		{
+			name: "exposure concurrency",
+			envVars: map[string]string{
+				"EXPOSURE_CONCURRENCY": "8",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.ExposureConcurrency != 8 {
+					t.Errorf("Expected exposure concurrency 8, got %d", c.Plugin.ExposureConcurrency)
+				}
+			},
+		},
+		{
+			name: "DNS address mode",
+			envVars: map[string]string{
+				"DNS_ADDRESS_MODE": "ipv6",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.DNSAddressMode != "ipv6" {
+					t.Errorf("Expected DNS address mode ipv6, got %s", c.Plugin.DNSAddressMode)
+				}
+			},
+		},
+		{
+			name: "stale chain mode",
+			envVars: map[string]string{
+				"STALE_CHAINS": "flush",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.StaleChains != "flush" {
+					t.Errorf("Expected stale chain mode flush, got %s", c.Plugin.StaleChains)
+				}
+			},
+		},
+		{
+			name: "firewall backend",
+			envVars: map[string]string{
+				"FIREWALL_BACKEND": "nftables",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.FirewallBackend != "nftables" {
+					t.Errorf("Expected firewall backend nftables, got %s", c.Plugin.FirewallBackend)
+				}
+			},
+		},
+		{
+			name: "link backend",
+			envVars: map[string]string{
+				"LINK_BACKEND": "noop",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.LinkBackend != "noop" {
+					t.Errorf("Expected link backend noop, got %s", c.Plugin.LinkBackend)
+				}
+			},
+		},
+		{
+			name: "global scope",
+			envVars: map[string]string{
+				"SCOPE":      "global",
+				"MESH_PEERS": "a.b32.i2p, b.b32.i2p",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.Scope != "global" {
+					t.Errorf("Expected global scope, got %s", c.Plugin.Scope)
+				}
+				if peers := c.GetMeshPeers(); len(peers) != 2 || peers[1] != "b.b32.i2p" {
+					t.Errorf("Expected 2 mesh peers, got %v", peers)
+				}
+			},
+		},
+		{
+			name: "peer blocking",
+			envVars: map[string]string{
+				"PEER_BLOCK_DURATION": "10m",
+				"PEER_FLOOD_STREAMS":  "100",
+				"PEER_EMPTY_STREAMS":  "5",
+			},
+			validate: func(t *testing.T, c *Config) {
+				peers := c.GetPeerReputation()
+				if peers.BlockDuration != 10*time.Minute || peers.FloodStreams != 100 || peers.EmptyStreams != 5 {
+					t.Errorf("Unexpected peer blocking settings %+v", peers)
+				}
+			},
+		},
+		{
+			name: "session maintenance",
+			envVars: map[string]string{
+				"MAINTENANCE_WINDOWS":      "02:00-04:00,23:30-00:30",
+				"MAINTENANCE_BATCH":        "2",
+				"MAINTENANCE_KEY_ROTATION": "24h",
+			},
+			validate: func(t *testing.T, c *Config) {
+				maintenance := c.GetMaintenance()
+				if len(maintenance.Windows) != 2 || maintenance.Windows[1].String() != "23:30-00:30" ||
+					maintenance.Batch != 2 || maintenance.KeyRotation != 24*time.Hour {
+					t.Errorf("Unexpected maintenance settings %+v", maintenance)
+				}
+			},
+		},
+		{
+			name: "anomaly webhook",
+			envVars: map[string]string{
+				"ANOMALY_WEBHOOK": "https://alerts.example/i2p",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.Plugin.AnomalyWebhook != "https://alerts.example/i2p" {
+					t.Errorf("Expected anomaly webhook https://alerts.example/i2p, got %s", c.Plugin.AnomalyWebhook)
+				}
+			},
+		},
+		{
+			name: "SAM backup routers",
+			envVars: map[string]string{
+				"I2P_SAM_BACKUPS": "10.0.0.2:7656, 10.0.0.3:7656,",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if len(c.SAM.Backups) != 2 {
+					t.Fatalf("Expected 2 SAM backups, got %d", len(c.SAM.Backups))
+				}
+				if c.SAM.Backups[0] != "10.0.0.2:7656" || c.SAM.Backups[1] != "10.0.0.3:7656" {
+					t.Errorf("Unexpected SAM backups: %v", c.SAM.Backups)
+				}
+			},
+		},
+		{
+			name: "SAM operation pacing",
+			envVars: map[string]string{
+				"I2P_SAM_OPERATION_RATE":  "2.5",
+				"I2P_SAM_OPERATION_BURST": "4",
+			},
+			validate: func(t *testing.T, c *Config) {
+				if c.SAM.OperationRate != 2.5 || c.SAM.OperationBurst != 4 {
+					t.Errorf("Expected SAM operation rate 2.5 and burst 4, got %v and %d", c.SAM.OperationRate, c.SAM.OperationBurst)
+				}
+			},
+		},
 		{
 			name: "tunnel configuration",
 			envVars: map[string]string{
@@ -148,6 +511,9 @@ func TestLoadFromEnvironment(t *testing.T) {
 				"I2P_ENCRYPT_LEASESET": "true",
 				"I2P_CLOSE_IDLE":       "false",
 				"I2P_CLOSE_IDLE_TIME":  "600",
+				"I2P_REDUCE_IDLE":      "true",
+				"I2P_REDUCE_IDLE_TIME": "15",
+				"I2P_REDUCE_QUANTITY":  "2",
 			},
 			validate: func(t *testing.T, c *Config) {
 				if c.TunnelDefaults.InboundTunnels != 5 {
@@ -171,6 +537,9 @@ func TestLoadFromEnvironment(t *testing.T) {
 				if c.TunnelDefaults.CloseIdleTime != 600 {
 					t.Errorf("Expected close idle time 600, got %d", c.TunnelDefaults.CloseIdleTime)
 				}
+				if !c.TunnelDefaults.ReduceIdle || c.TunnelDefaults.ReduceIdleTime != 15 || c.TunnelDefaults.ReduceQuantity != 2 {
+					t.Errorf("Expected reduce idle after 15 minutes to 2 tunnels, got %+v", c.TunnelDefaults)
+				}
 			},
 		},
 		{
@@ -256,12 +625,249 @@ func TestValidate(t *testing.T) {
 			expectError: true,
 			errorMsg:    "IPAM subnet cannot be empty",
 		},
+		{
+			name:        "IPv6 IPAM subnet",
+			modify:      func(c *Config) { c.Plugin.IPAMSubnet = "fd00::/48" },
+			expectError: true,
+			errorMsg:    `IPAM subnet must be an IPv4 subnet of /23 or larger, got "fd00::/48"`,
+		},
+		{
+			name:        "IPAM subnet smaller than two /24s",
+			modify:      func(c *Config) { c.Plugin.IPAMSubnet = "172.20.1.0/24" },
+			expectError: true,
+			errorMsg:    `IPAM subnet must be an IPv4 subnet of /23 or larger, got "172.20.1.0/24"`,
+		},
 		{
 			name:        "empty gateway",
 			modify:      func(c *Config) { c.Plugin.Gateway = "" },
 			expectError: true,
 			errorMsg:    "gateway cannot be empty",
 		},
+		{
+			name:        "invalid socket mode",
+			modify:      func(c *Config) { c.Plugin.SocketMode = "rw-rw----" },
+			expectError: true,
+			errorMsg:    `invalid plugin socket mode: mode "rw-rw----" must be an octal number: strconv.ParseUint: parsing "rw-rw----": invalid syntax`,
+		},
+		{
+			name:        "admin socket mode out of range",
+			modify:      func(c *Config) { c.Plugin.AdminSocketMode = "1777" },
+			expectError: true,
+			errorMsg:    `invalid admin socket mode: mode "1777" must not exceed 0777`,
+		},
+		{
+			name:        "negative rate limit",
+			modify:      func(c *Config) { c.Plugin.RateLimit = -1 },
+			expectError: true,
+			errorMsg:    "rate limit cannot be negative, got -1",
+		},
+		{
+			name:        "zero rate burst",
+			modify:      func(c *Config) { c.Plugin.RateBurst = 0 },
+			expectError: true,
+			errorMsg:    "rate burst must be at least 1 when rate limiting is enabled, got 0",
+		},
+		{
+			name:        "rate limiting disabled",
+			modify:      func(c *Config) { c.Plugin.RateLimit = 0; c.Plugin.RateBurst = 0 },
+			expectError: false,
+		},
+		{
+			name:        "negative SAM operation rate",
+			modify:      func(c *Config) { c.SAM.OperationRate = -1 },
+			expectError: true,
+			errorMsg:    "SAM operation rate cannot be negative, got -1",
+		},
+		{
+			name:        "zero SAM operation burst",
+			modify:      func(c *Config) { c.SAM.OperationBurst = 0 },
+			expectError: true,
+			errorMsg:    "SAM operation burst must be at least 1 when pacing is enabled, got 0",
+		},
+		{
+			name:        "negative max request bytes",
+			modify:      func(c *Config) { c.Plugin.MaxRequestBytes = -1 },
+			expectError: true,
+			errorMsg:    "max request bytes cannot be negative, got -1",
+		},
+		{
+			name:        "zero trace buffer size",
+			modify:      func(c *Config) { c.Plugin.TraceBufferSize = 0 },
+			expectError: true,
+			errorMsg:    "trace buffer size must be positive, got 0",
+		},
+		{
+			name:        "zero exposure concurrency",
+			modify:      func(c *Config) { c.Plugin.ExposureConcurrency = 0 },
+			expectError: true,
+			errorMsg:    "exposure concurrency must be between 1 and 32, got 0",
+		},
+		{
+			name:        "excessive exposure concurrency",
+			modify:      func(c *Config) { c.Plugin.ExposureConcurrency = 64 },
+			expectError: true,
+			errorMsg:    "exposure concurrency must be between 1 and 32, got 64",
+		},
+		{
+			name:        "unknown DNS address mode",
+			modify:      func(c *Config) { c.Plugin.DNSAddressMode = "dual" },
+			expectError: true,
+			errorMsg:    `DNS address mode must be ipv4 or ipv6, got "dual"`,
+		},
+		{
+			name:        "unknown stale chain mode",
+			modify:      func(c *Config) { c.Plugin.StaleChains = "keep" },
+			expectError: true,
+			errorMsg:    `stale chain mode must be reconcile or flush, got "keep"`,
+		},
+		{
+			name:        "unknown firewall backend",
+			modify:      func(c *Config) { c.Plugin.FirewallBackend = "pf" },
+			expectError: true,
+			errorMsg:    `firewall backend must be iptables, nftables or noop, got "pf"`,
+		},
+		{
+			name:        "ebpf firewall backend",
+			modify:      func(c *Config) { c.Plugin.FirewallBackend = "ebpf" },
+			expectError: true,
+			errorMsg:    "firewall backend ebpf is not supported, use iptables, nftables or noop",
+		},
+		{
+			name:        "unknown link backend",
+			modify:      func(c *Config) { c.Plugin.LinkBackend = "ip" },
+			expectError: true,
+			errorMsg:    `link backend must be netlink or noop, got "ip"`,
+		},
+		{
+			name:        "negative peer block duration",
+			modify:      func(c *Config) { c.Plugin.PeerBlockDuration = "-1m" },
+			expectError: true,
+			errorMsg:    `peer block duration must be a non-negative duration, got "-1m"`,
+		},
+		{
+			name:        "zero peer flood threshold",
+			modify:      func(c *Config) { c.Plugin.PeerFloodStreams = 0 },
+			expectError: true,
+			errorMsg:    "peer flood and empty stream thresholds must be positive, got 0 and 20",
+		},
+		{
+			name:        "invalid maintenance window",
+			modify:      func(c *Config) { c.Plugin.MaintenanceWindows = "02:00-25:00" },
+			expectError: true,
+			errorMsg:    `invalid maintenance window "02:00-25:00": "25:00" is not a HH:MM time of day`,
+		},
+		{
+			name:        "zero maintenance batch",
+			modify:      func(c *Config) { c.Plugin.MaintenanceBatch = 0 },
+			expectError: true,
+			errorMsg:    "maintenance batch must be positive, got 0",
+		},
+		{
+			name:        "invalid maintenance key rotation",
+			modify:      func(c *Config) { c.Plugin.MaintenanceKeyRotation = "daily" },
+			expectError: true,
+			errorMsg:    `maintenance key rotation must be a non-negative duration, got "daily"`,
+		},
+		{
+			name:        "unknown publish backend",
+			modify:      func(c *Config) { c.Plugin.PublishBackend = "etcd"; c.Plugin.PublishTarget = "http://etcd" },
+			expectError: true,
+			errorMsg:    `publish backend must be zonefile, consul or http, got "etcd"`,
+		},
+		{
+			name:        "invalid TLS address",
+			modify:      func(c *Config) { c.Plugin.TLSAddress = "gateway" },
+			expectError: true,
+			errorMsg:    `TLS address must be host:port, got "gateway"`,
+		},
+		{
+			name:        "socket allowlist entry with a space",
+			modify:      func(c *Config) { c.Plugin.SocketAllowedGroups = "docker,i2p admins" },
+			expectError: true,
+			errorMsg:    `socket allowlist entry "i2p admins" is not a name or numeric ID`,
+		},
+		{
+			name: "TLS address without client CA",
+			modify: func(c *Config) {
+				c.Plugin.TLSAddress = ":9443"
+				c.Plugin.TLSCertFile = "cert.pem"
+				c.Plugin.TLSKeyFile = "key.pem"
+			},
+			expectError: true,
+			errorMsg:    "TLS address requires a certificate, key and client CA file",
+		},
+		{
+			name:        "relative label path",
+			modify:      func(c *Config) { c.Plugin.LabelPath = "run/i2p/labels" },
+			expectError: true,
+			errorMsg:    `label path must be a clean absolute file path, got "run/i2p/labels"`,
+		},
+		{
+			name:        "label path without Docker socket",
+			modify:      func(c *Config) { c.Plugin.LabelPath = "/run/i2p/labels"; c.Plugin.DockerSocket = "" },
+			expectError: true,
+			errorMsg:    "label path requires a Docker socket",
+		},
+		{
+			name:        "publish backend without target",
+			modify:      func(c *Config) { c.Plugin.PublishBackend = "zonefile" },
+			expectError: true,
+			errorMsg:    "publish target cannot be empty when publish backend zonefile is set",
+		},
+		{
+			name:        "unknown publish encoding",
+			modify:      func(c *Config) { c.Plugin.PublishEncoding = "b64" },
+			expectError: true,
+			errorMsg:    `publish encoding: unknown address encoding "b64" (must be b32, b33 or base64)`,
+		},
+		{
+			name: "zone file publication",
+			modify: func(c *Config) {
+				c.Plugin.PublishBackend = "zonefile"
+				c.Plugin.PublishTarget = "/etc/unbound/i2p.conf"
+			},
+			expectError: false,
+		},
+		{
+			name:        "invalid stats interval",
+			modify:      func(c *Config) { c.Plugin.StatsInterval = "often" },
+			expectError: true,
+			errorMsg:    `stats interval must be a positive duration, got "often"`,
+		},
+		{
+			name:        "stats persistence disabled",
+			modify:      func(c *Config) { c.Plugin.StatsPath = "" },
+			expectError: false,
+		},
+		{
+			name:        "unknown naming backend",
+			modify:      func(c *Config) { c.Plugin.NamingBackends = "sam,dht" },
+			expectError: true,
+			errorMsg:    `naming backend must be sam, hosts or registrar, got "dht"`,
+		},
+		{
+			name:        "hosts naming backend without file",
+			modify:      func(c *Config) { c.Plugin.NamingBackends = "hosts" },
+			expectError: true,
+			errorMsg:    "naming hosts file cannot be empty when the hosts naming backend is enabled",
+		},
+		{
+			name:        "registrar naming backend without URL",
+			modify:      func(c *Config) { c.Plugin.NamingBackends = "registrar" },
+			expectError: true,
+			errorMsg:    "naming registrar URL cannot be empty when the registrar naming backend is enabled",
+		},
+		{
+			name:        "SAM naming backend",
+			modify:      func(c *Config) { c.Plugin.NamingBackends = "sam" },
+			expectError: false,
+		},
+		{
+			name:        "SOCKS alias without destination",
+			modify:      func(c *Config) { c.Plugin.SOCKSAliases = "service.internal=forum.i2p,wiki.internal" },
+			expectError: true,
+			errorMsg:    `SOCKS aliases must be alias=destination pairs, got "wiki.internal"`,
+		},
 		{
 			name:        "empty SAM host",
 			modify:      func(c *Config) { c.SAM.Host = "" },
@@ -286,6 +892,12 @@ func TestValidate(t *testing.T) {
 			expectError: true,
 			errorMsg:    "SAM timeout must be positive, got 0s",
 		},
+		{
+			name:        "invalid SAM backup address",
+			modify:      func(c *Config) { c.SAM.Backups = []string{"backup-router"} },
+			expectError: true,
+			errorMsg:    "invalid SAM backup address \"backup-router\": address backup-router: missing port in address",
+		},
 		{
 			name:        "invalid inbound tunnels",
 			modify:      func(c *Config) { c.TunnelDefaults.InboundTunnels = 0 },
@@ -316,6 +928,18 @@ func TestValidate(t *testing.T) {
 			expectError: true,
 			errorMsg:    "close idle time must be positive, got 0",
 		},
+		{
+			name:        "invalid reduce quantity",
+			modify:      func(c *Config) { c.TunnelDefaults.ReduceQuantity = 0 },
+			expectError: true,
+			errorMsg:    "reduce quantity must be positive, got 0",
+		},
+		{
+			name:        "extra option set by typed option",
+			modify:      func(c *Config) { c.TunnelDefaults.Extra = map[string]string{"inbound.length": "1"} },
+			expectError: true,
+			errorMsg:    "tunnel option inbound.length is set by its typed option, not passed through",
+		},
 	}
 
 	for _, tt := range tests {
@@ -484,3 +1108,34 @@ func TestValidateComplexScenarios(t *testing.T) {
 		}
 	})
 }
+
+func TestParseSocketMode(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    os.FileMode
+		expectError bool
+	}{
+		{"0600", 0600, false},
+		{"660", 0660, false},
+		{" 0666 ", 0666, false},
+		{"0777", 0777, false},
+		{"1777", 0, true},
+		{"0800", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		mode, err := ParseSocketMode(tt.input)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("ParseSocketMode(%q): expected error, got %o", tt.input, mode)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSocketMode(%q): unexpected error: %v", tt.input, err)
+		} else if mode != tt.expected {
+			t.Errorf("ParseSocketMode(%q) = %o, want %o", tt.input, mode, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,1574 @@
+// Typed admin API of the I2P Docker network plugin.
+//
+// The service mirrors the read-only views of the JSON admin API (/v1/networks,
+// /v1/tunnels, /v1/exposures and /v1/stats) for integrators that prefer
+// generated clients. Field names and meanings match the JSON API.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: admin.proto
+
+package adminpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListNetworksRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only list networks of this tenant (see the i2p.tenant option)
+	Tenant        string `protobuf:"bytes,1,opt,name=tenant,proto3" json:"tenant,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListNetworksRequest) Reset() {
+	*x = ListNetworksRequest{}
+	mi := &file_admin_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListNetworksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListNetworksRequest) ProtoMessage() {}
+
+func (x *ListNetworksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListNetworksRequest.ProtoReflect.Descriptor instead.
+func (*ListNetworksRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ListNetworksRequest) GetTenant() string {
+	if x != nil {
+		return x.Tenant
+	}
+	return ""
+}
+
+type ListNetworksResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Networks      []*Network             `protobuf:"bytes,1,rep,name=networks,proto3" json:"networks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListNetworksResponse) Reset() {
+	*x = ListNetworksResponse{}
+	mi := &file_admin_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListNetworksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListNetworksResponse) ProtoMessage() {}
+
+func (x *ListNetworksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListNetworksResponse.ProtoReflect.Descriptor instead.
+func (*ListNetworksResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListNetworksResponse) GetNetworks() []*Network {
+	if x != nil {
+		return x.Networks
+	}
+	return nil
+}
+
+type GetNetworkRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Network ID or name
+	Id            string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetNetworkRequest) Reset() {
+	*x = GetNetworkRequest{}
+	mi := &file_admin_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetNetworkRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetNetworkRequest) ProtoMessage() {}
+
+func (x *GetNetworkRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetNetworkRequest.ProtoReflect.Descriptor instead.
+func (*GetNetworkRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetNetworkRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+// Network is an I2P network and its endpoints.
+type Network struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// Subnet in CIDR notation
+	Subnet  string `protobuf:"bytes,3,opt,name=subnet,proto3" json:"subnet,omitempty"`
+	Gateway string `protobuf:"bytes,4,opt,name=gateway,proto3" json:"gateway,omitempty"`
+	// Address the network's SOCKS proxy and DNS resolver listen on
+	ProxyAddress string `protobuf:"bytes,5,opt,name=proxy_address,json=proxyAddress,proto3" json:"proxy_address,omitempty"`
+	AntiSpoof    bool   `protobuf:"varint,6,opt,name=anti_spoof,json=antiSpoof,proto3" json:"anti_spoof,omitempty"`
+	Isolated     bool   `protobuf:"varint,7,opt,name=isolated,proto3" json:"isolated,omitempty"`
+	// Endpoint limit (0 is unlimited)
+	MaxEndpoints int32 `protobuf:"varint,8,opt,name=max_endpoints,json=maxEndpoints,proto3" json:"max_endpoints,omitempty"`
+	// Policy template the network was created with (unset if none)
+	Policy *NetworkPolicy `protobuf:"bytes,9,opt,name=policy,proto3" json:"policy,omitempty"`
+	// "active", "degraded" or "stopped"
+	Status string `protobuf:"bytes,10,opt,name=status,proto3" json:"status,omitempty"`
+	// Stopped subsystems the network relies on
+	DegradedBy []string    `protobuf:"bytes,11,rep,name=degraded_by,json=degradedBy,proto3" json:"degraded_by,omitempty"`
+	Endpoints  []*Endpoint `protobuf:"bytes,12,rep,name=endpoints,proto3" json:"endpoints,omitempty"`
+	// Tenant the network's telemetry is tagged with (i2p.tenant)
+	Tenant        string `protobuf:"bytes,13,opt,name=tenant,proto3" json:"tenant,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Network) Reset() {
+	*x = Network{}
+	mi := &file_admin_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Network) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Network) ProtoMessage() {}
+
+func (x *Network) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Network.ProtoReflect.Descriptor instead.
+func (*Network) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Network) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Network) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Network) GetSubnet() string {
+	if x != nil {
+		return x.Subnet
+	}
+	return ""
+}
+
+func (x *Network) GetGateway() string {
+	if x != nil {
+		return x.Gateway
+	}
+	return ""
+}
+
+func (x *Network) GetProxyAddress() string {
+	if x != nil {
+		return x.ProxyAddress
+	}
+	return ""
+}
+
+func (x *Network) GetAntiSpoof() bool {
+	if x != nil {
+		return x.AntiSpoof
+	}
+	return false
+}
+
+func (x *Network) GetIsolated() bool {
+	if x != nil {
+		return x.Isolated
+	}
+	return false
+}
+
+func (x *Network) GetMaxEndpoints() int32 {
+	if x != nil {
+		return x.MaxEndpoints
+	}
+	return 0
+}
+
+func (x *Network) GetPolicy() *NetworkPolicy {
+	if x != nil {
+		return x.Policy
+	}
+	return nil
+}
+
+func (x *Network) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Network) GetDegradedBy() []string {
+	if x != nil {
+		return x.DegradedBy
+	}
+	return nil
+}
+
+func (x *Network) GetEndpoints() []*Endpoint {
+	if x != nil {
+		return x.Endpoints
+	}
+	return nil
+}
+
+func (x *Network) GetTenant() string {
+	if x != nil {
+		return x.Tenant
+	}
+	return ""
+}
+
+// NetworkPolicy is the i2p.policy template of a network.
+type NetworkPolicy struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// Values of the template's options on the network, including overrides
+	Settings map[string]string `protobuf:"bytes,2,rep,name=settings,proto3" json:"settings,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Template options that were set explicitly
+	Overrides     []string `protobuf:"bytes,3,rep,name=overrides,proto3" json:"overrides,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NetworkPolicy) Reset() {
+	*x = NetworkPolicy{}
+	mi := &file_admin_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NetworkPolicy) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NetworkPolicy) ProtoMessage() {}
+
+func (x *NetworkPolicy) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NetworkPolicy.ProtoReflect.Descriptor instead.
+func (*NetworkPolicy) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *NetworkPolicy) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *NetworkPolicy) GetSettings() map[string]string {
+	if x != nil {
+		return x.Settings
+	}
+	return nil
+}
+
+func (x *NetworkPolicy) GetOverrides() []string {
+	if x != nil {
+		return x.Overrides
+	}
+	return nil
+}
+
+// Endpoint is a container endpoint on an I2P network.
+type Endpoint struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// "created", "joined" or "parked"
+	State       string `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	ContainerId string `protobuf:"bytes,3,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
+	// Empty while parked
+	IpAddress  string `protobuf:"bytes,4,opt,name=ip_address,json=ipAddress,proto3" json:"ip_address,omitempty"`
+	MacAddress string `protobuf:"bytes,5,opt,name=mac_address,json=macAddress,proto3" json:"mac_address,omitempty"`
+	// Set for inbound-only containers (i2p.outbound=false)
+	OutboundDisabled bool       `protobuf:"varint,6,opt,name=outbound_disabled,json=outboundDisabled,proto3" json:"outbound_disabled,omitempty"`
+	IsolationGroups  []string   `protobuf:"bytes,7,rep,name=isolation_groups,json=isolationGroups,proto3" json:"isolation_groups,omitempty"`
+	PortMaps         []*PortMap `protobuf:"bytes,8,rep,name=port_maps,json=portMaps,proto3" json:"port_maps,omitempty"`
+	// Proxy traffic and inbound streams of the endpoint (unset if none)
+	Stats *EndpointStats `protobuf:"bytes,9,opt,name=stats,proto3" json:"stats,omitempty"`
+	// Services exposed from the container
+	Exposures []*Exposure `protobuf:"bytes,10,rep,name=exposures,proto3" json:"exposures,omitempty"`
+	// I2P ports pending until the router recovers from overload
+	QueuedExposures int32 `protobuf:"varint,11,opt,name=queued_exposures,json=queuedExposures,proto3" json:"queued_exposures,omitempty"`
+	// Identity the container keeps across recreation (i2p.identity or Compose labels)
+	Identity      string `protobuf:"bytes,12,opt,name=identity,proto3" json:"identity,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Endpoint) Reset() {
+	*x = Endpoint{}
+	mi := &file_admin_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Endpoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Endpoint) ProtoMessage() {}
+
+func (x *Endpoint) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Endpoint.ProtoReflect.Descriptor instead.
+func (*Endpoint) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Endpoint) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Endpoint) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Endpoint) GetContainerId() string {
+	if x != nil {
+		return x.ContainerId
+	}
+	return ""
+}
+
+func (x *Endpoint) GetIpAddress() string {
+	if x != nil {
+		return x.IpAddress
+	}
+	return ""
+}
+
+func (x *Endpoint) GetMacAddress() string {
+	if x != nil {
+		return x.MacAddress
+	}
+	return ""
+}
+
+func (x *Endpoint) GetOutboundDisabled() bool {
+	if x != nil {
+		return x.OutboundDisabled
+	}
+	return false
+}
+
+func (x *Endpoint) GetIsolationGroups() []string {
+	if x != nil {
+		return x.IsolationGroups
+	}
+	return nil
+}
+
+func (x *Endpoint) GetPortMaps() []*PortMap {
+	if x != nil {
+		return x.PortMaps
+	}
+	return nil
+}
+
+func (x *Endpoint) GetStats() *EndpointStats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+func (x *Endpoint) GetExposures() []*Exposure {
+	if x != nil {
+		return x.Exposures
+	}
+	return nil
+}
+
+func (x *Endpoint) GetQueuedExposures() int32 {
+	if x != nil {
+		return x.QueuedExposures
+	}
+	return 0
+}
+
+func (x *Endpoint) GetIdentity() string {
+	if x != nil {
+		return x.Identity
+	}
+	return ""
+}
+
+// PortMap is a client port map of an endpoint.
+type PortMap struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Gateway IP:port the container connects to
+	Listen string `protobuf:"bytes,1,opt,name=listen,proto3" json:"listen,omitempty"`
+	// The destination and its fallbacks in the order they are tried
+	Destinations  []*PortMapDestination `protobuf:"bytes,2,rep,name=destinations,proto3" json:"destinations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PortMap) Reset() {
+	*x = PortMap{}
+	mi := &file_admin_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PortMap) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PortMap) ProtoMessage() {}
+
+func (x *PortMap) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PortMap.ProtoReflect.Descriptor instead.
+func (*PortMap) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *PortMap) GetListen() string {
+	if x != nil {
+		return x.Listen
+	}
+	return ""
+}
+
+func (x *PortMap) GetDestinations() []*PortMapDestination {
+	if x != nil {
+		return x.Destinations
+	}
+	return nil
+}
+
+// PortMapDestination is the health of a port map destination.
+type PortMapDestination struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Remote I2P host:port
+	Destination string `protobuf:"bytes,1,opt,name=destination,proto3" json:"destination,omitempty"`
+	Healthy     bool   `protobuf:"varint,2,opt,name=healthy,proto3" json:"healthy,omitempty"`
+	// Consecutive failed connections
+	Failures int32 `protobuf:"varint,3,opt,name=failures,proto3" json:"failures,omitempty"`
+	// When an unhealthy destination is tried again (unset if healthy)
+	RetryAt       *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=retry_at,json=retryAt,proto3" json:"retry_at,omitempty"`
+	LastError     string                 `protobuf:"bytes,5,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PortMapDestination) Reset() {
+	*x = PortMapDestination{}
+	mi := &file_admin_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PortMapDestination) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PortMapDestination) ProtoMessage() {}
+
+func (x *PortMapDestination) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PortMapDestination.ProtoReflect.Descriptor instead.
+func (*PortMapDestination) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *PortMapDestination) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *PortMapDestination) GetHealthy() bool {
+	if x != nil {
+		return x.Healthy
+	}
+	return false
+}
+
+func (x *PortMapDestination) GetFailures() int32 {
+	if x != nil {
+		return x.Failures
+	}
+	return 0
+}
+
+func (x *PortMapDestination) GetRetryAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.RetryAt
+	}
+	return nil
+}
+
+func (x *PortMapDestination) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+// EndpointStats counts an endpoint's traffic.
+type EndpointStats struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	ProxyConnections    int64                  `protobuf:"varint,1,opt,name=proxy_connections,json=proxyConnections,proto3" json:"proxy_connections,omitempty"`
+	ProxyBytes          int64                  `protobuf:"varint,2,opt,name=proxy_bytes,json=proxyBytes,proto3" json:"proxy_bytes,omitempty"`
+	ServerTunnelStreams int64                  `protobuf:"varint,3,opt,name=server_tunnel_streams,json=serverTunnelStreams,proto3" json:"server_tunnel_streams,omitempty"`
+	ServerTunnelBytes   int64                  `protobuf:"varint,4,opt,name=server_tunnel_bytes,json=serverTunnelBytes,proto3" json:"server_tunnel_bytes,omitempty"`
+	ActiveStreams       int64                  `protobuf:"varint,5,opt,name=active_streams,json=activeStreams,proto3" json:"active_streams,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *EndpointStats) Reset() {
+	*x = EndpointStats{}
+	mi := &file_admin_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EndpointStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EndpointStats) ProtoMessage() {}
+
+func (x *EndpointStats) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EndpointStats.ProtoReflect.Descriptor instead.
+func (*EndpointStats) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *EndpointStats) GetProxyConnections() int64 {
+	if x != nil {
+		return x.ProxyConnections
+	}
+	return 0
+}
+
+func (x *EndpointStats) GetProxyBytes() int64 {
+	if x != nil {
+		return x.ProxyBytes
+	}
+	return 0
+}
+
+func (x *EndpointStats) GetServerTunnelStreams() int64 {
+	if x != nil {
+		return x.ServerTunnelStreams
+	}
+	return 0
+}
+
+func (x *EndpointStats) GetServerTunnelBytes() int64 {
+	if x != nil {
+		return x.ServerTunnelBytes
+	}
+	return 0
+}
+
+func (x *EndpointStats) GetActiveStreams() int64 {
+	if x != nil {
+		return x.ActiveStreams
+	}
+	return 0
+}
+
+type ListTunnelsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTunnelsRequest) Reset() {
+	*x = ListTunnelsRequest{}
+	mi := &file_admin_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTunnelsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTunnelsRequest) ProtoMessage() {}
+
+func (x *ListTunnelsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTunnelsRequest.ProtoReflect.Descriptor instead.
+func (*ListTunnelsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{9}
+}
+
+type ListTunnelsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tunnels       []*Tunnel              `protobuf:"bytes,1,rep,name=tunnels,proto3" json:"tunnels,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTunnelsResponse) Reset() {
+	*x = ListTunnelsResponse{}
+	mi := &file_admin_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTunnelsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTunnelsResponse) ProtoMessage() {}
+
+func (x *ListTunnelsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTunnelsResponse.ProtoReflect.Descriptor instead.
+func (*ListTunnelsResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ListTunnelsResponse) GetTunnels() []*Tunnel {
+	if x != nil {
+		return x.Tunnels
+	}
+	return nil
+}
+
+// Tunnel is an I2P tunnel.
+type Tunnel struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Name        string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	ContainerId string                 `protobuf:"bytes,2,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
+	// What the tunnel name was generated from, e.g. "web-80"
+	Purpose string `protobuf:"bytes,3,opt,name=purpose,proto3" json:"purpose,omitempty"`
+	// "client" or "server"
+	Type string `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	// Remote (client) or local (server) I2P destination
+	Destination string `protobuf:"bytes,5,opt,name=destination,proto3" json:"destination,omitempty"`
+	// Local host:port of the tunnel
+	LocalEndpoint string `protobuf:"bytes,6,opt,name=local_endpoint,json=localEndpoint,proto3" json:"local_endpoint,omitempty"`
+	Active        bool   `protobuf:"varint,7,opt,name=active,proto3" json:"active,omitempty"`
+	// When the tunnel was created (unset if unknown)
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Tunnel) Reset() {
+	*x = Tunnel{}
+	mi := &file_admin_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Tunnel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Tunnel) ProtoMessage() {}
+
+func (x *Tunnel) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Tunnel.ProtoReflect.Descriptor instead.
+func (*Tunnel) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Tunnel) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Tunnel) GetContainerId() string {
+	if x != nil {
+		return x.ContainerId
+	}
+	return ""
+}
+
+func (x *Tunnel) GetPurpose() string {
+	if x != nil {
+		return x.Purpose
+	}
+	return ""
+}
+
+func (x *Tunnel) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Tunnel) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *Tunnel) GetLocalEndpoint() string {
+	if x != nil {
+		return x.LocalEndpoint
+	}
+	return ""
+}
+
+func (x *Tunnel) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+func (x *Tunnel) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+type ListExposuresRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only list exposures on networks of this tenant
+	Tenant        string `protobuf:"bytes,1,opt,name=tenant,proto3" json:"tenant,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListExposuresRequest) Reset() {
+	*x = ListExposuresRequest{}
+	mi := &file_admin_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListExposuresRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListExposuresRequest) ProtoMessage() {}
+
+func (x *ListExposuresRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListExposuresRequest.ProtoReflect.Descriptor instead.
+func (*ListExposuresRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ListExposuresRequest) GetTenant() string {
+	if x != nil {
+		return x.Tenant
+	}
+	return ""
+}
+
+type ListExposuresResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Exposures     []*Exposure            `protobuf:"bytes,1,rep,name=exposures,proto3" json:"exposures,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListExposuresResponse) Reset() {
+	*x = ListExposuresResponse{}
+	mi := &file_admin_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListExposuresResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListExposuresResponse) ProtoMessage() {}
+
+func (x *ListExposuresResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListExposuresResponse.ProtoReflect.Descriptor instead.
+func (*ListExposuresResponse) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ListExposuresResponse) GetExposures() []*Exposure {
+	if x != nil {
+		return x.Exposures
+	}
+	return nil
+}
+
+// Exposure is a service exposed from a container.
+type Exposure struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ContainerId   string                 `protobuf:"bytes,1,opt,name=container_id,json=containerId,proto3" json:"container_id,omitempty"`
+	NetworkId     string                 `protobuf:"bytes,2,opt,name=network_id,json=networkId,proto3" json:"network_id,omitempty"`
+	ContainerPort int32                  `protobuf:"varint,3,opt,name=container_port,json=containerPort,proto3" json:"container_port,omitempty"`
+	// "tcp" or "udp"
+	Protocol string `protobuf:"bytes,4,opt,name=protocol,proto3" json:"protocol,omitempty"`
+	// "i2p" or "ip"
+	Type string `protobuf:"bytes,5,opt,name=type,proto3" json:"type,omitempty"`
+	// .b32.i2p address or host IP:port
+	Destination string `protobuf:"bytes,6,opt,name=destination,proto3" json:"destination,omitempty"`
+	// When the exposure's TTL elapses (unset if it has none)
+	ExpiresAt    *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	SharedTunnel bool                   `protobuf:"varint,8,opt,name=shared_tunnel,json=sharedTunnel,proto3" json:"shared_tunnel,omitempty"`
+	// Exposure group whose destination serves the port
+	Group string `protobuf:"bytes,9,opt,name=group,proto3" json:"group,omitempty"`
+	// Exposure purpose, e.g. "web-80"
+	Service string `protobuf:"bytes,10,opt,name=service,proto3" json:"service,omitempty"`
+	// Server tunnel serving the port, or the name an IP exposure is tracked under
+	TunnelName string `protobuf:"bytes,11,opt,name=tunnel_name,json=tunnelName,proto3" json:"tunnel_name,omitempty"`
+	// Container IP:port traffic is forwarded to
+	Target string `protobuf:"bytes,12,opt,name=target,proto3" json:"target,omitempty"`
+	// "active" or "inactive"
+	State string `protobuf:"bytes,13,opt,name=state,proto3" json:"state,omitempty"`
+	// When the exposure was created (unset if unknown)
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	// Encodings of the I2P destination (unset for IP exposures)
+	Addresses     *Addresses `protobuf:"bytes,15,opt,name=addresses,proto3" json:"addresses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Exposure) Reset() {
+	*x = Exposure{}
+	mi := &file_admin_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Exposure) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Exposure) ProtoMessage() {}
+
+func (x *Exposure) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Exposure.ProtoReflect.Descriptor instead.
+func (*Exposure) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Exposure) GetContainerId() string {
+	if x != nil {
+		return x.ContainerId
+	}
+	return ""
+}
+
+func (x *Exposure) GetNetworkId() string {
+	if x != nil {
+		return x.NetworkId
+	}
+	return ""
+}
+
+func (x *Exposure) GetContainerPort() int32 {
+	if x != nil {
+		return x.ContainerPort
+	}
+	return 0
+}
+
+func (x *Exposure) GetProtocol() string {
+	if x != nil {
+		return x.Protocol
+	}
+	return ""
+}
+
+func (x *Exposure) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Exposure) GetDestination() string {
+	if x != nil {
+		return x.Destination
+	}
+	return ""
+}
+
+func (x *Exposure) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *Exposure) GetSharedTunnel() bool {
+	if x != nil {
+		return x.SharedTunnel
+	}
+	return false
+}
+
+func (x *Exposure) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *Exposure) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *Exposure) GetTunnelName() string {
+	if x != nil {
+		return x.TunnelName
+	}
+	return ""
+}
+
+func (x *Exposure) GetTarget() string {
+	if x != nil {
+		return x.Target
+	}
+	return ""
+}
+
+func (x *Exposure) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Exposure) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Exposure) GetAddresses() *Addresses {
+	if x != nil {
+		return x.Addresses
+	}
+	return nil
+}
+
+// Addresses are the encodings of an I2P destination.
+type Addresses struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Full base64 destination
+	Base64 string `protobuf:"bytes,1,opt,name=base64,proto3" json:"base64,omitempty"`
+	// .b32.i2p address
+	B32 string `protobuf:"bytes,2,opt,name=b32,proto3" json:"b32,omitempty"`
+	// .b32.i2p address of the blinded leaseset (empty if it is not blinded)
+	B33           string `protobuf:"bytes,3,opt,name=b33,proto3" json:"b33,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Addresses) Reset() {
+	*x = Addresses{}
+	mi := &file_admin_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Addresses) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Addresses) ProtoMessage() {}
+
+func (x *Addresses) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Addresses.ProtoReflect.Descriptor instead.
+func (*Addresses) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Addresses) GetBase64() string {
+	if x != nil {
+		return x.Base64
+	}
+	return ""
+}
+
+func (x *Addresses) GetB32() string {
+	if x != nil {
+		return x.B32
+	}
+	return ""
+}
+
+func (x *Addresses) GetB33() string {
+	if x != nil {
+		return x.B33
+	}
+	return ""
+}
+
+type GetStatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStatsRequest) Reset() {
+	*x = GetStatsRequest{}
+	mi := &file_admin_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStatsRequest) ProtoMessage() {}
+
+func (x *GetStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetStatsRequest) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{16}
+}
+
+// Stats are the plugin's traffic statistics.
+type Stats struct {
+	state                    protoimpl.MessageState `protogen:"open.v1"`
+	I2PConnectionsAllowed    int64                  `protobuf:"varint,1,opt,name=i2p_connections_allowed,json=i2pConnectionsAllowed,proto3" json:"i2p_connections_allowed,omitempty"`
+	I2PConnectionsBlocked    int64                  `protobuf:"varint,2,opt,name=i2p_connections_blocked,json=i2pConnectionsBlocked,proto3" json:"i2p_connections_blocked,omitempty"`
+	NonI2PConnectionsBlocked int64                  `protobuf:"varint,3,opt,name=non_i2p_connections_blocked,json=nonI2pConnectionsBlocked,proto3" json:"non_i2p_connections_blocked,omitempty"`
+	// Proxy connections from clients that are not endpoints of managed networks
+	UnknownSourcesRejected int64                  `protobuf:"varint,4,opt,name=unknown_sources_rejected,json=unknownSourcesRejected,proto3" json:"unknown_sources_rejected,omitempty"`
+	TotalBytesTransferred  int64                  `protobuf:"varint,5,opt,name=total_bytes_transferred,json=totalBytesTransferred,proto3" json:"total_bytes_transferred,omitempty"`
+	LastActivity           *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=last_activity,json=lastActivity,proto3" json:"last_activity,omitempty"`
+	unknownFields          protoimpl.UnknownFields
+	sizeCache              protoimpl.SizeCache
+}
+
+func (x *Stats) Reset() {
+	*x = Stats{}
+	mi := &file_admin_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stats) ProtoMessage() {}
+
+func (x *Stats) ProtoReflect() protoreflect.Message {
+	mi := &file_admin_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stats.ProtoReflect.Descriptor instead.
+func (*Stats) Descriptor() ([]byte, []int) {
+	return file_admin_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Stats) GetI2PConnectionsAllowed() int64 {
+	if x != nil {
+		return x.I2PConnectionsAllowed
+	}
+	return 0
+}
+
+func (x *Stats) GetI2PConnectionsBlocked() int64 {
+	if x != nil {
+		return x.I2PConnectionsBlocked
+	}
+	return 0
+}
+
+func (x *Stats) GetNonI2PConnectionsBlocked() int64 {
+	if x != nil {
+		return x.NonI2PConnectionsBlocked
+	}
+	return 0
+}
+
+func (x *Stats) GetUnknownSourcesRejected() int64 {
+	if x != nil {
+		return x.UnknownSourcesRejected
+	}
+	return 0
+}
+
+func (x *Stats) GetTotalBytesTransferred() int64 {
+	if x != nil {
+		return x.TotalBytesTransferred
+	}
+	return 0
+}
+
+func (x *Stats) GetLastActivity() *timestamppb.Timestamp {
+	if x != nil {
+		return x.LastActivity
+	}
+	return nil
+}
+
+var File_admin_proto protoreflect.FileDescriptor
+
+const file_admin_proto_rawDesc = "" +
+	"\n" +
+	"\vadmin.proto\x12\x0fi2pnet.admin.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"-\n" +
+	"\x13ListNetworksRequest\x12\x16\n" +
+	"\x06tenant\x18\x01 \x01(\tR\x06tenant\"L\n" +
+	"\x14ListNetworksResponse\x124\n" +
+	"\bnetworks\x18\x01 \x03(\v2\x18.i2pnet.admin.v1.NetworkR\bnetworks\"#\n" +
+	"\x11GetNetworkRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xa6\x03\n" +
+	"\aNetwork\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x16\n" +
+	"\x06subnet\x18\x03 \x01(\tR\x06subnet\x12\x18\n" +
+	"\agateway\x18\x04 \x01(\tR\agateway\x12#\n" +
+	"\rproxy_address\x18\x05 \x01(\tR\fproxyAddress\x12\x1d\n" +
+	"\n" +
+	"anti_spoof\x18\x06 \x01(\bR\tantiSpoof\x12\x1a\n" +
+	"\bisolated\x18\a \x01(\bR\bisolated\x12#\n" +
+	"\rmax_endpoints\x18\b \x01(\x05R\fmaxEndpoints\x126\n" +
+	"\x06policy\x18\t \x01(\v2\x1e.i2pnet.admin.v1.NetworkPolicyR\x06policy\x12\x16\n" +
+	"\x06status\x18\n" +
+	" \x01(\tR\x06status\x12\x1f\n" +
+	"\vdegraded_by\x18\v \x03(\tR\n" +
+	"degradedBy\x127\n" +
+	"\tendpoints\x18\f \x03(\v2\x19.i2pnet.admin.v1.EndpointR\tendpoints\x12\x16\n" +
+	"\x06tenant\x18\r \x01(\tR\x06tenant\"\xc8\x01\n" +
+	"\rNetworkPolicy\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12H\n" +
+	"\bsettings\x18\x02 \x03(\v2,.i2pnet.admin.v1.NetworkPolicy.SettingsEntryR\bsettings\x12\x1c\n" +
+	"\toverrides\x18\x03 \x03(\tR\toverrides\x1a;\n" +
+	"\rSettingsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xd8\x03\n" +
+	"\bEndpoint\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12!\n" +
+	"\fcontainer_id\x18\x03 \x01(\tR\vcontainerId\x12\x1d\n" +
+	"\n" +
+	"ip_address\x18\x04 \x01(\tR\tipAddress\x12\x1f\n" +
+	"\vmac_address\x18\x05 \x01(\tR\n" +
+	"macAddress\x12+\n" +
+	"\x11outbound_disabled\x18\x06 \x01(\bR\x10outboundDisabled\x12)\n" +
+	"\x10isolation_groups\x18\a \x03(\tR\x0fisolationGroups\x125\n" +
+	"\tport_maps\x18\b \x03(\v2\x18.i2pnet.admin.v1.PortMapR\bportMaps\x124\n" +
+	"\x05stats\x18\t \x01(\v2\x1e.i2pnet.admin.v1.EndpointStatsR\x05stats\x127\n" +
+	"\texposures\x18\n" +
+	" \x03(\v2\x19.i2pnet.admin.v1.ExposureR\texposures\x12)\n" +
+	"\x10queued_exposures\x18\v \x01(\x05R\x0fqueuedExposures\x12\x1a\n" +
+	"\bidentity\x18\f \x01(\tR\bidentity\"j\n" +
+	"\aPortMap\x12\x16\n" +
+	"\x06listen\x18\x01 \x01(\tR\x06listen\x12G\n" +
+	"\fdestinations\x18\x02 \x03(\v2#.i2pnet.admin.v1.PortMapDestinationR\fdestinations\"\xc2\x01\n" +
+	"\x12PortMapDestination\x12 \n" +
+	"\vdestination\x18\x01 \x01(\tR\vdestination\x12\x18\n" +
+	"\ahealthy\x18\x02 \x01(\bR\ahealthy\x12\x1a\n" +
+	"\bfailures\x18\x03 \x01(\x05R\bfailures\x125\n" +
+	"\bretry_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\aretryAt\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\x05 \x01(\tR\tlastError\"\xe8\x01\n" +
+	"\rEndpointStats\x12+\n" +
+	"\x11proxy_connections\x18\x01 \x01(\x03R\x10proxyConnections\x12\x1f\n" +
+	"\vproxy_bytes\x18\x02 \x01(\x03R\n" +
+	"proxyBytes\x122\n" +
+	"\x15server_tunnel_streams\x18\x03 \x01(\x03R\x13serverTunnelStreams\x12.\n" +
+	"\x13server_tunnel_bytes\x18\x04 \x01(\x03R\x11serverTunnelBytes\x12%\n" +
+	"\x0eactive_streams\x18\x05 \x01(\x03R\ractiveStreams\"\x14\n" +
+	"\x12ListTunnelsRequest\"H\n" +
+	"\x13ListTunnelsResponse\x121\n" +
+	"\atunnels\x18\x01 \x03(\v2\x17.i2pnet.admin.v1.TunnelR\atunnels\"\x89\x02\n" +
+	"\x06Tunnel\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12!\n" +
+	"\fcontainer_id\x18\x02 \x01(\tR\vcontainerId\x12\x18\n" +
+	"\apurpose\x18\x03 \x01(\tR\apurpose\x12\x12\n" +
+	"\x04type\x18\x04 \x01(\tR\x04type\x12 \n" +
+	"\vdestination\x18\x05 \x01(\tR\vdestination\x12%\n" +
+	"\x0elocal_endpoint\x18\x06 \x01(\tR\rlocalEndpoint\x12\x16\n" +
+	"\x06active\x18\a \x01(\bR\x06active\x129\n" +
+	"\n" +
+	"created_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\".\n" +
+	"\x14ListExposuresRequest\x12\x16\n" +
+	"\x06tenant\x18\x01 \x01(\tR\x06tenant\"P\n" +
+	"\x15ListExposuresResponse\x127\n" +
+	"\texposures\x18\x01 \x03(\v2\x19.i2pnet.admin.v1.ExposureR\texposures\"\x99\x04\n" +
+	"\bExposure\x12!\n" +
+	"\fcontainer_id\x18\x01 \x01(\tR\vcontainerId\x12\x1d\n" +
+	"\n" +
+	"network_id\x18\x02 \x01(\tR\tnetworkId\x12%\n" +
+	"\x0econtainer_port\x18\x03 \x01(\x05R\rcontainerPort\x12\x1a\n" +
+	"\bprotocol\x18\x04 \x01(\tR\bprotocol\x12\x12\n" +
+	"\x04type\x18\x05 \x01(\tR\x04type\x12 \n" +
+	"\vdestination\x18\x06 \x01(\tR\vdestination\x129\n" +
+	"\n" +
+	"expires_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12#\n" +
+	"\rshared_tunnel\x18\b \x01(\bR\fsharedTunnel\x12\x14\n" +
+	"\x05group\x18\t \x01(\tR\x05group\x12\x18\n" +
+	"\aservice\x18\n" +
+	" \x01(\tR\aservice\x12\x1f\n" +
+	"\vtunnel_name\x18\v \x01(\tR\n" +
+	"tunnelName\x12\x16\n" +
+	"\x06target\x18\f \x01(\tR\x06target\x12\x14\n" +
+	"\x05state\x18\r \x01(\tR\x05state\x129\n" +
+	"\n" +
+	"created_at\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x128\n" +
+	"\taddresses\x18\x0f \x01(\v2\x1a.i2pnet.admin.v1.AddressesR\taddresses\"G\n" +
+	"\tAddresses\x12\x16\n" +
+	"\x06base64\x18\x01 \x01(\tR\x06base64\x12\x10\n" +
+	"\x03b32\x18\x02 \x01(\tR\x03b32\x12\x10\n" +
+	"\x03b33\x18\x03 \x01(\tR\x03b33\"\x11\n" +
+	"\x0fGetStatsRequest\"\xe9\x02\n" +
+	"\x05Stats\x126\n" +
+	"\x17i2p_connections_allowed\x18\x01 \x01(\x03R\x15i2pConnectionsAllowed\x126\n" +
+	"\x17i2p_connections_blocked\x18\x02 \x01(\x03R\x15i2pConnectionsBlocked\x12=\n" +
+	"\x1bnon_i2p_connections_blocked\x18\x03 \x01(\x03R\x18nonI2pConnectionsBlocked\x128\n" +
+	"\x18unknown_sources_rejected\x18\x04 \x01(\x03R\x16unknownSourcesRejected\x126\n" +
+	"\x17total_bytes_transferred\x18\x05 \x01(\x03R\x15totalBytesTransferred\x12?\n" +
+	"\rlast_activity\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\flastActivity2\xb7\x03\n" +
+	"\fAdminService\x12[\n" +
+	"\fListNetworks\x12$.i2pnet.admin.v1.ListNetworksRequest\x1a%.i2pnet.admin.v1.ListNetworksResponse\x12J\n" +
+	"\n" +
+	"GetNetwork\x12\".i2pnet.admin.v1.GetNetworkRequest\x1a\x18.i2pnet.admin.v1.Network\x12X\n" +
+	"\vListTunnels\x12#.i2pnet.admin.v1.ListTunnelsRequest\x1a$.i2pnet.admin.v1.ListTunnelsResponse\x12^\n" +
+	"\rListExposures\x12%.i2pnet.admin.v1.ListExposuresRequest\x1a&.i2pnet.admin.v1.ListExposuresResponse\x12D\n" +
+	"\bGetStats\x12 .i2pnet.admin.v1.GetStatsRequest\x1a\x16.i2pnet.admin.v1.StatsB5Z3github.com/go-i2p/go-docker-network-i2p/pkg/adminpbb\x06proto3"
+
+var (
+	file_admin_proto_rawDescOnce sync.Once
+	file_admin_proto_rawDescData []byte
+)
+
+func file_admin_proto_rawDescGZIP() []byte {
+	file_admin_proto_rawDescOnce.Do(func() {
+		file_admin_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_admin_proto_rawDesc), len(file_admin_proto_rawDesc)))
+	})
+	return file_admin_proto_rawDescData
+}
+
+var file_admin_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_admin_proto_goTypes = []any{
+	(*ListNetworksRequest)(nil),   // 0: i2pnet.admin.v1.ListNetworksRequest
+	(*ListNetworksResponse)(nil),  // 1: i2pnet.admin.v1.ListNetworksResponse
+	(*GetNetworkRequest)(nil),     // 2: i2pnet.admin.v1.GetNetworkRequest
+	(*Network)(nil),               // 3: i2pnet.admin.v1.Network
+	(*NetworkPolicy)(nil),         // 4: i2pnet.admin.v1.NetworkPolicy
+	(*Endpoint)(nil),              // 5: i2pnet.admin.v1.Endpoint
+	(*PortMap)(nil),               // 6: i2pnet.admin.v1.PortMap
+	(*PortMapDestination)(nil),    // 7: i2pnet.admin.v1.PortMapDestination
+	(*EndpointStats)(nil),         // 8: i2pnet.admin.v1.EndpointStats
+	(*ListTunnelsRequest)(nil),    // 9: i2pnet.admin.v1.ListTunnelsRequest
+	(*ListTunnelsResponse)(nil),   // 10: i2pnet.admin.v1.ListTunnelsResponse
+	(*Tunnel)(nil),                // 11: i2pnet.admin.v1.Tunnel
+	(*ListExposuresRequest)(nil),  // 12: i2pnet.admin.v1.ListExposuresRequest
+	(*ListExposuresResponse)(nil), // 13: i2pnet.admin.v1.ListExposuresResponse
+	(*Exposure)(nil),              // 14: i2pnet.admin.v1.Exposure
+	(*Addresses)(nil),             // 15: i2pnet.admin.v1.Addresses
+	(*GetStatsRequest)(nil),       // 16: i2pnet.admin.v1.GetStatsRequest
+	(*Stats)(nil),                 // 17: i2pnet.admin.v1.Stats
+	nil,                           // 18: i2pnet.admin.v1.NetworkPolicy.SettingsEntry
+	(*timestamppb.Timestamp)(nil), // 19: google.protobuf.Timestamp
+}
+var file_admin_proto_depIdxs = []int32{
+	3,  // 0: i2pnet.admin.v1.ListNetworksResponse.networks:type_name -> i2pnet.admin.v1.Network
+	4,  // 1: i2pnet.admin.v1.Network.policy:type_name -> i2pnet.admin.v1.NetworkPolicy
+	5,  // 2: i2pnet.admin.v1.Network.endpoints:type_name -> i2pnet.admin.v1.Endpoint
+	18, // 3: i2pnet.admin.v1.NetworkPolicy.settings:type_name -> i2pnet.admin.v1.NetworkPolicy.SettingsEntry
+	6,  // 4: i2pnet.admin.v1.Endpoint.port_maps:type_name -> i2pnet.admin.v1.PortMap
+	8,  // 5: i2pnet.admin.v1.Endpoint.stats:type_name -> i2pnet.admin.v1.EndpointStats
+	14, // 6: i2pnet.admin.v1.Endpoint.exposures:type_name -> i2pnet.admin.v1.Exposure
+	7,  // 7: i2pnet.admin.v1.PortMap.destinations:type_name -> i2pnet.admin.v1.PortMapDestination
+	19, // 8: i2pnet.admin.v1.PortMapDestination.retry_at:type_name -> google.protobuf.Timestamp
+	11, // 9: i2pnet.admin.v1.ListTunnelsResponse.tunnels:type_name -> i2pnet.admin.v1.Tunnel
+	19, // 10: i2pnet.admin.v1.Tunnel.created_at:type_name -> google.protobuf.Timestamp
+	14, // 11: i2pnet.admin.v1.ListExposuresResponse.exposures:type_name -> i2pnet.admin.v1.Exposure
+	19, // 12: i2pnet.admin.v1.Exposure.expires_at:type_name -> google.protobuf.Timestamp
+	19, // 13: i2pnet.admin.v1.Exposure.created_at:type_name -> google.protobuf.Timestamp
+	15, // 14: i2pnet.admin.v1.Exposure.addresses:type_name -> i2pnet.admin.v1.Addresses
+	19, // 15: i2pnet.admin.v1.Stats.last_activity:type_name -> google.protobuf.Timestamp
+	0,  // 16: i2pnet.admin.v1.AdminService.ListNetworks:input_type -> i2pnet.admin.v1.ListNetworksRequest
+	2,  // 17: i2pnet.admin.v1.AdminService.GetNetwork:input_type -> i2pnet.admin.v1.GetNetworkRequest
+	9,  // 18: i2pnet.admin.v1.AdminService.ListTunnels:input_type -> i2pnet.admin.v1.ListTunnelsRequest
+	12, // 19: i2pnet.admin.v1.AdminService.ListExposures:input_type -> i2pnet.admin.v1.ListExposuresRequest
+	16, // 20: i2pnet.admin.v1.AdminService.GetStats:input_type -> i2pnet.admin.v1.GetStatsRequest
+	1,  // 21: i2pnet.admin.v1.AdminService.ListNetworks:output_type -> i2pnet.admin.v1.ListNetworksResponse
+	3,  // 22: i2pnet.admin.v1.AdminService.GetNetwork:output_type -> i2pnet.admin.v1.Network
+	10, // 23: i2pnet.admin.v1.AdminService.ListTunnels:output_type -> i2pnet.admin.v1.ListTunnelsResponse
+	13, // 24: i2pnet.admin.v1.AdminService.ListExposures:output_type -> i2pnet.admin.v1.ListExposuresResponse
+	17, // 25: i2pnet.admin.v1.AdminService.GetStats:output_type -> i2pnet.admin.v1.Stats
+	21, // [21:26] is the sub-list for method output_type
+	16, // [16:21] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
+}
+
+func init() { file_admin_proto_init() }
+func file_admin_proto_init() {
+	if File_admin_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_admin_proto_rawDesc), len(file_admin_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   19,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_admin_proto_goTypes,
+		DependencyIndexes: file_admin_proto_depIdxs,
+		MessageInfos:      file_admin_proto_msgTypes,
+	}.Build()
+	File_admin_proto = out.File
+	file_admin_proto_goTypes = nil
+	file_admin_proto_depIdxs = nil
+}
@@ -0,0 +1,8 @@
+// Package adminpb provides the generated protobuf messages and gRPC client
+// and server code of the plugin's typed admin API.
+//
+// The service is defined in admin.proto; run go generate after changing it.
+// The plugin serves it when GRPC_SOCKET_PATH is set.
+package adminpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative admin.proto
@@ -0,0 +1,78 @@
+// Package fakesam provides deterministic destination keys for the fake SAM
+// server.
+//
+// Keys are laid out like the Ed25519 destinations a router hands out: a
+// 256-byte encryption key, a 128-byte signing key field and a key
+// certificate, followed in the private blob by the private encryption and
+// signing keys. The key material is derived from the server's seed and a
+// counter, so a server started with the same seed hands out the same
+// destinations in the same order.
+package fakesam
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+const (
+	// destinationKeysLength is the length of the encryption and signing
+	// key fields of a destination
+	destinationKeysLength = 384
+	// privateKeysLength is the length of the private encryption and Ed25519
+	// signing keys following the destination in a private blob
+	privateKeysLength = 256 + 32
+)
+
+// keyCertificate is a key certificate for Ed25519 signing keys (type 7) and
+// ElGamal encryption (type 0).
+var keyCertificate = []byte{5, 0, 4, 0, 7, 0, 0}
+
+// i2pEncoding is the base64 alphabet I2P uses for destinations.
+var i2pEncoding = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-~")
+
+// deriveKeys returns the base64 public destination and private blob of the
+// n-th destination of seed.
+func deriveKeys(seed string, n uint64) (public, private string) {
+	material := make([]byte, 0, destinationKeysLength+privateKeysLength+sha256.Size)
+	for block := uint64(0); len(material) < destinationKeysLength+privateKeysLength; block++ {
+		input := make([]byte, len(seed)+16)
+		copy(input, seed)
+		binary.BigEndian.PutUint64(input[len(seed):], n)
+		binary.BigEndian.PutUint64(input[len(seed)+8:], block)
+		sum := sha256.Sum256(input)
+		material = append(material, sum[:]...)
+	}
+
+	destination := append(append([]byte{}, material[:destinationKeysLength]...), keyCertificate...)
+	blob := append(append([]byte{}, destination...), material[destinationKeysLength:destinationKeysLength+privateKeysLength]...)
+	return i2pEncoding.EncodeToString(destination), i2pEncoding.EncodeToString(blob)
+}
+
+// publicFromPrivate returns the base64 public destination a private blob
+// starts with.
+func publicFromPrivate(private string) (string, error) {
+	blob, err := i2pEncoding.DecodeString(private)
+	if err != nil {
+		return "", fmt.Errorf("private keys are not base64: %w", err)
+	}
+	if len(blob) < destinationKeysLength+3 {
+		return "", fmt.Errorf("private keys are too short")
+	}
+
+	// The certificate length follows its type byte
+	certificateLength := int(binary.BigEndian.Uint16(blob[destinationKeysLength+1:]))
+	destinationLength := destinationKeysLength + 3 + certificateLength
+	if len(blob) <= destinationLength {
+		return "", fmt.Errorf("private keys hold no private part")
+	}
+	return i2pEncoding.EncodeToString(blob[:destinationLength]), nil
+}
+
+// base32Address returns the .b32.i2p address of a base64 destination.
+func base32Address(destination string) string {
+	return i2pkeys.I2PAddr(destination).Base32()
+}
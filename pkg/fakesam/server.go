@@ -0,0 +1,778 @@
+// Package fakesam provides an in-memory SAM v3.3 bridge for tests and local
+// development without an I2P router.
+//
+// The server speaks enough of the SAM protocol for go-sam-go clients and the
+// plugin's tunnel manager: HELLO, DEST GENERATE, SESSION CREATE (STREAM and
+// PRIMARY), SESSION ADD and REMOVE of stream sub-sessions, STREAM CONNECT,
+// STREAM ACCEPT and NAMING LOOKUP. Nothing leaves the process. A STREAM
+// CONNECT to a destination one of the server's sessions holds is paired with
+// a pending STREAM ACCEPT of that destination, preferring a sub-session
+// listening on the stream's port, and the two sockets are relayed to each
+// other. Connects to any other destination fail with CANT_REACH_PEER.
+//
+// Destinations are derived from a seed (see keys.go), so runs with the same
+// seed and the same sequence of requests see the same addresses.
+package fakesam
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// samVersion is the SAM version the server implements
+	samVersion = "3.3"
+	// DefaultSeed seeds key generation when ServerOptions.Seed is empty
+	DefaultSeed = "fakesam"
+	// DefaultAcceptTimeout is how long STREAM CONNECT waits for a STREAM
+	// ACCEPT by default
+	DefaultAcceptTimeout = 5 * time.Second
+	// settleDelay separates the status and destination lines from relayed
+	// data. go-sam-go reads each line with a single Read, so data arriving
+	// with a line would be lost.
+	settleDelay = 50 * time.Millisecond
+)
+
+// ServerOptions configures a fake SAM server.
+type ServerOptions struct {
+	// Seed determines the generated destinations (empty uses DefaultSeed)
+	Seed string
+	// Hosts maps hostnames to base64 destinations for NAMING LOOKUP
+	Hosts map[string]string
+	// AcceptTimeout is how long STREAM CONNECT waits for the destination to
+	// accept a stream (0 uses DefaultAcceptTimeout)
+	AcceptTimeout time.Duration
+	// Logger receives a line per command (nil disables logging)
+	Logger *log.Logger
+}
+
+// Server is an in-memory SAM bridge.
+type Server struct {
+	options  ServerOptions
+	listener net.Listener
+
+	mutex       sync.Mutex
+	generated   uint64
+	hosts       map[string]string
+	known       map[string]string
+	sessions    map[string]*session
+	accepts     []*pendingAccept
+	acceptAdded chan struct{}
+	conns       map[*connection]struct{}
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+// session is a SAM session or a sub-session of a primary session.
+type session struct {
+	id          string
+	style       string
+	destination string
+	private     string
+	fromPort    int
+	toPort      int
+	listenPort  int
+	// owner is the control connection of the session or its primary session
+	owner *connection
+	// primary is set for sub-sessions
+	primary *session
+}
+
+// pendingAccept is a STREAM ACCEPT waiting for a stream.
+type pendingAccept struct {
+	session *session
+	conn    *connection
+	peer    chan *connection
+	// taken is set once a STREAM CONNECT took the accept
+	taken bool
+}
+
+// connection is a client connection to the server.
+type connection struct {
+	net.Conn
+	reader *bufio.Reader
+	// session is the session created on this connection, if any
+	session *session
+}
+
+// command is a parsed SAM command line.
+type command struct {
+	verb   string
+	action string
+	args   map[string]string
+}
+
+// NewServer creates a fake SAM server. Call Listen or Serve to accept
+// clients.
+func NewServer(options ServerOptions) *Server {
+	if options.Seed == "" {
+		options.Seed = DefaultSeed
+	}
+	if options.AcceptTimeout <= 0 {
+		options.AcceptTimeout = DefaultAcceptTimeout
+	}
+
+	s := &Server{
+		options:     options,
+		hosts:       make(map[string]string),
+		known:       make(map[string]string),
+		sessions:    make(map[string]*session),
+		acceptAdded: make(chan struct{}),
+		conns:       make(map[*connection]struct{}),
+	}
+	for name, destination := range options.Hosts {
+		s.addHostLocked(name, destination)
+	}
+	return s
+}
+
+// Listen starts serving SAM clients on address (host:port, port 0 picks a
+// free port) in the background.
+func (s *Server) Listen(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+	go s.Serve(listener)
+	return nil
+}
+
+// Serve accepts SAM clients on listener until the server is closed.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		listener.Close()
+		return net.ErrClosed
+	}
+	s.listener = listener
+	s.mutex.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mutex.Lock()
+			closed := s.closed
+			s.mutex.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		c := &connection{Conn: conn, reader: bufio.NewReader(conn)}
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			conn.Close()
+			return nil
+		}
+		s.conns[c] = struct{}{}
+		s.wg.Add(1)
+		s.mutex.Unlock()
+
+		go s.handleConnection(c)
+	}
+}
+
+// Addr returns the address the server listens on, or "" before Listen.
+func (s *Server) Addr() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Close stops the server and closes all client connections.
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	// Wake connects waiting for an accept
+	close(s.acceptAdded)
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// NewKeys generates the next destination of the server's seed, as
+// DEST GENERATE would. It returns the base64 public destination and private
+// key blob.
+func (s *Server) NewKeys() (public, private string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.newKeysLocked()
+}
+
+// newKeysLocked generates the next destination. The caller must hold
+// s.mutex.
+func (s *Server) newKeysLocked() (public, private string) {
+	s.generated++
+	public, private = deriveKeys(s.options.Seed, s.generated)
+	s.known[base32Address(public)] = public
+	return public, private
+}
+
+// AddHost makes NAMING LOOKUP resolve name to a base64 destination.
+func (s *Server) AddHost(name, destination string) error {
+	if !isDestination(destination) {
+		return fmt.Errorf("invalid destination for %s", name)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.addHostLocked(name, destination)
+	return nil
+}
+
+// addHostLocked records a hostname. The caller must hold s.mutex.
+func (s *Server) addHostLocked(name, destination string) {
+	s.hosts[strings.ToLower(name)] = destination
+	s.known[base32Address(destination)] = destination
+}
+
+// handleConnection serves a client connection until it is closed or turned
+// into a stream.
+func (s *Server) handleConnection(c *connection) {
+	defer s.wg.Done()
+	defer s.closeConnection(c)
+
+	hello := false
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := parseCommand(line)
+		s.logf("%s: %s %s %s", c.RemoteAddr(), cmd.verb, cmd.action, describeArgs(cmd.args))
+
+		if !hello && cmd.verb != "HELLO" {
+			c.reply("%s STATUS RESULT=I2P_ERROR MESSAGE=\"HELLO expected\"", cmd.verb)
+			return
+		}
+
+		switch cmd.verb {
+		case "PING":
+			c.reply("PONG%s", strings.TrimRight(strings.TrimPrefix(line, "PING"), "\r\n"))
+			continue
+		case "QUIT", "EXIT":
+			return
+		}
+
+		switch cmd.verb + " " + cmd.action {
+		case "HELLO VERSION":
+			if hello = s.hello(c, cmd); !hello {
+				return
+			}
+		case "DEST GENERATE":
+			public, private := s.NewKeys()
+			c.reply("DEST REPLY PUB=%s PRIV=%s", public, private)
+		case "SESSION CREATE":
+			s.createSession(c, cmd)
+		case "SESSION ADD":
+			s.addSubSession(c, cmd)
+		case "SESSION REMOVE":
+			s.removeSubSession(c, cmd)
+		case "NAMING LOOKUP":
+			s.lookup(c, cmd)
+		case "STREAM CONNECT":
+			// The connection is the stream from here on
+			s.streamConnect(c, cmd)
+			return
+		case "STREAM ACCEPT":
+			s.streamAccept(c, cmd)
+			return
+		default:
+			c.reply("%s STATUS RESULT=I2P_ERROR MESSAGE=\"unsupported command %s %s\"", cmd.verb, cmd.verb, cmd.action)
+		}
+	}
+}
+
+// hello negotiates the protocol version and reports whether it succeeded.
+func (s *Server) hello(c *connection, cmd command) bool {
+	if maximum, ok := cmd.args["MAX"]; ok {
+		if version, err := strconv.ParseFloat(maximum, 64); err == nil && version < 3.0 {
+			c.reply("HELLO REPLY RESULT=NOVERSION")
+			return false
+		}
+	}
+	if minimum, ok := cmd.args["MIN"]; ok {
+		if version, err := strconv.ParseFloat(minimum, 64); err == nil && version > 3.3 {
+			c.reply("HELLO REPLY RESULT=NOVERSION")
+			return false
+		}
+	}
+	c.reply("HELLO REPLY RESULT=OK VERSION=%s", samVersion)
+	return true
+}
+
+// createSession handles SESSION CREATE.
+func (s *Server) createSession(c *connection, cmd command) {
+	style := cmd.args["STYLE"]
+	if style == "MASTER" {
+		style = "PRIMARY"
+	}
+	if style != "STREAM" && style != "PRIMARY" {
+		c.reply("SESSION STATUS RESULT=I2P_ERROR MESSAGE=\"unsupported style %s\"", cmd.args["STYLE"])
+		return
+	}
+	id := cmd.args["ID"]
+	if id == "" {
+		c.reply("SESSION STATUS RESULT=I2P_ERROR MESSAGE=\"missing ID\"")
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if c.session != nil {
+		c.reply("SESSION STATUS RESULT=I2P_ERROR MESSAGE=\"a session already exists on this connection\"")
+		return
+	}
+	if _, exists := s.sessions[id]; exists {
+		c.reply("SESSION STATUS RESULT=DUPLICATED_ID")
+		return
+	}
+
+	var public, private string
+	if destination := cmd.args["DESTINATION"]; destination == "" || destination == "TRANSIENT" {
+		public, private = s.newKeysLocked()
+	} else {
+		var err error
+		if public, err = publicFromPrivate(destination); err != nil {
+			c.reply("SESSION STATUS RESULT=INVALID_KEY")
+			return
+		}
+		private = destination
+	}
+	for _, other := range s.sessions {
+		if other.primary == nil && other.destination == public {
+			c.reply("SESSION STATUS RESULT=DUPLICATED_DEST")
+			return
+		}
+	}
+
+	sess := &session{id: id, style: style, destination: public, private: private, owner: c}
+	sess.fromPort, sess.toPort, sess.listenPort = sessionPorts(cmd.args)
+	s.sessions[id] = sess
+	s.known[base32Address(public)] = public
+	c.session = sess
+
+	c.reply("SESSION STATUS RESULT=OK DESTINATION=%s", private)
+}
+
+// addSubSession handles SESSION ADD on a primary session's connection.
+func (s *Server) addSubSession(c *connection, cmd command) {
+	id := cmd.args["ID"]
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if c.session == nil || c.session.style != "PRIMARY" {
+		c.reply("SESSION STATUS RESULT=I2P_ERROR MESSAGE=\"no primary session on this connection\"")
+		return
+	}
+	if cmd.args["STYLE"] != "STREAM" {
+		c.reply("SESSION STATUS RESULT=I2P_ERROR MESSAGE=\"unsupported style %s\"", cmd.args["STYLE"])
+		return
+	}
+	if id == "" {
+		c.reply("SESSION STATUS RESULT=I2P_ERROR MESSAGE=\"missing ID\"")
+		return
+	}
+	if _, exists := s.sessions[id]; exists {
+		c.reply("SESSION STATUS RESULT=DUPLICATED_ID")
+		return
+	}
+
+	sub := &session{id: id, style: "STREAM", destination: c.session.destination, owner: c, primary: c.session}
+	sub.fromPort, sub.toPort, sub.listenPort = sessionPorts(cmd.args)
+	s.sessions[id] = sub
+
+	c.reply("SESSION STATUS RESULT=OK ID=%s MESSAGE=\"ADD %s\"", id, id)
+}
+
+// removeSubSession handles SESSION REMOVE on a primary session's connection.
+func (s *Server) removeSubSession(c *connection, cmd command) {
+	id := cmd.args["ID"]
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sub, exists := s.sessions[id]
+	if !exists || c.session == nil || sub.primary != c.session {
+		c.reply("SESSION STATUS RESULT=I2P_ERROR MESSAGE=\"no sub-session %s\"", id)
+		return
+	}
+	s.removeSessionLocked(sub)
+
+	c.reply("SESSION STATUS RESULT=OK ID=%s MESSAGE=\"REMOVE %s\"", id, id)
+}
+
+// lookup handles NAMING LOOKUP.
+func (s *Server) lookup(c *connection, cmd command) {
+	name := cmd.args["NAME"]
+
+	s.mutex.Lock()
+	destination, found := s.resolveLocked(c, name)
+	s.mutex.Unlock()
+
+	if !found {
+		c.reply("NAMING REPLY RESULT=KEY_NOT_FOUND NAME=%s", name)
+		return
+	}
+	c.reply("NAMING REPLY RESULT=OK NAME=%s VALUE=%s", name, destination)
+}
+
+// resolveLocked resolves ME, a hostname, a .b32.i2p address or a base64
+// destination. The caller must hold s.mutex.
+func (s *Server) resolveLocked(c *connection, name string) (string, bool) {
+	if name == "ME" {
+		if c.session == nil {
+			return "", false
+		}
+		return c.session.destination, true
+	}
+
+	lower := strings.ToLower(name)
+	if destination, exists := s.hosts[lower]; exists {
+		return destination, true
+	}
+	if strings.HasSuffix(lower, ".b32.i2p") {
+		destination, exists := s.known[lower]
+		return destination, exists
+	}
+	if isDestination(name) {
+		return name, true
+	}
+	return "", false
+}
+
+// streamConnect handles STREAM CONNECT and relays the stream once a STREAM
+// ACCEPT of the destination takes it.
+func (s *Server) streamConnect(c *connection, cmd command) {
+	s.mutex.Lock()
+	sess, exists := s.sessions[cmd.args["ID"]]
+	destination, resolved := s.resolveLocked(c, cmd.args["DESTINATION"])
+	s.mutex.Unlock()
+
+	if !exists {
+		c.reply("STREAM STATUS RESULT=INVALID_ID MESSAGE=\"no session %s\"", cmd.args["ID"])
+		return
+	}
+	if !resolved {
+		c.reply("STREAM STATUS RESULT=INVALID_KEY MESSAGE=\"unknown destination\"")
+		return
+	}
+	fromPort := parsePort(cmd.args["FROM_PORT"], sess.fromPort)
+	toPort := parsePort(cmd.args["TO_PORT"], sess.toPort)
+
+	accept, result := s.waitForAccept(destination, toPort)
+	if accept == nil {
+		c.reply("STREAM STATUS RESULT=%s", result)
+		return
+	}
+
+	if err := accept.conn.reply("%s FROM_PORT=%d TO_PORT=%d", sess.destination, fromPort, toPort); err != nil {
+		accept.conn.Close()
+		c.reply("STREAM STATUS RESULT=CANT_REACH_PEER")
+		return
+	}
+	c.reply("STREAM STATUS RESULT=OK")
+	accept.peer <- c
+
+	time.Sleep(settleDelay)
+	relay(accept.conn, c)
+}
+
+// waitForAccept takes a pending STREAM ACCEPT of destination, waiting up to
+// the accept timeout for one. It returns the SAM result code on failure.
+func (s *Server) waitForAccept(destination string, port int) (*pendingAccept, string) {
+	deadline := time.Now().Add(s.options.AcceptTimeout)
+	for {
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			return nil, "I2P_ERROR"
+		}
+		if !s.hostsDestinationLocked(destination) {
+			s.mutex.Unlock()
+			return nil, "CANT_REACH_PEER"
+		}
+		if accept := s.takeAcceptLocked(destination, port); accept != nil {
+			s.mutex.Unlock()
+			return accept, ""
+		}
+		added := s.acceptAdded
+		s.mutex.Unlock()
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, "TIMEOUT"
+		}
+		select {
+		case <-added:
+		case <-time.After(remaining):
+		}
+	}
+}
+
+// hostsDestinationLocked reports whether a session of the server holds
+// destination. The caller must hold s.mutex.
+func (s *Server) hostsDestinationLocked(destination string) bool {
+	for _, sess := range s.sessions {
+		if sess.destination == destination {
+			return true
+		}
+	}
+	return false
+}
+
+// takeAcceptLocked removes and returns the oldest pending accept of
+// destination, preferring one whose session listens on port. The caller
+// must hold s.mutex.
+func (s *Server) takeAcceptLocked(destination string, port int) *pendingAccept {
+	index := -1
+	for i, accept := range s.accepts {
+		if accept.session.destination != destination {
+			continue
+		}
+		if accept.session.listenPort == port {
+			index = i
+			break
+		}
+		if index < 0 {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil
+	}
+
+	accept := s.accepts[index]
+	accept.taken = true
+	s.accepts = append(s.accepts[:index], s.accepts[index+1:]...)
+	return accept
+}
+
+// streamAccept handles STREAM ACCEPT: it waits for a stream and relays the
+// peer's data to the client.
+func (s *Server) streamAccept(c *connection, cmd command) {
+	s.mutex.Lock()
+	sess, exists := s.sessions[cmd.args["ID"]]
+	if !exists {
+		s.mutex.Unlock()
+		c.reply("STREAM STATUS RESULT=INVALID_ID MESSAGE=\"no session %s\"", cmd.args["ID"])
+		return
+	}
+	accept := &pendingAccept{session: sess, conn: c, peer: make(chan *connection, 1)}
+	if err := c.reply("STREAM STATUS RESULT=OK"); err != nil {
+		s.mutex.Unlock()
+		return
+	}
+	s.accepts = append(s.accepts, accept)
+	if !s.closed {
+		close(s.acceptAdded)
+		s.acceptAdded = make(chan struct{})
+	}
+	s.mutex.Unlock()
+
+	// Clients send nothing until a stream arrives, so the first readable
+	// byte or error means the stream is relaying or the client went away
+	_, err := c.reader.Peek(1)
+	if s.dropAccept(accept) {
+		return
+	}
+	peer := <-accept.peer
+	if err != nil {
+		peer.Close()
+		return
+	}
+
+	time.Sleep(settleDelay)
+	relay(peer, c)
+}
+
+// dropAccept removes an accept no STREAM CONNECT took and reports whether
+// it was not taken.
+func (s *Server) dropAccept(accept *pendingAccept) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if accept.taken {
+		return false
+	}
+	for i, pending := range s.accepts {
+		if pending == accept {
+			s.accepts = append(s.accepts[:i], s.accepts[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// closeConnection closes a client connection and the sessions it controls.
+func (s *Server) closeConnection(c *connection) {
+	c.Close()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.conns, c)
+	for _, sess := range s.sessions {
+		if sess.owner == c {
+			s.removeSessionLocked(sess)
+		}
+	}
+}
+
+// removeSessionLocked removes a session and fails its pending accepts. The
+// caller must hold s.mutex.
+func (s *Server) removeSessionLocked(sess *session) {
+	delete(s.sessions, sess.id)
+
+	kept := s.accepts[:0]
+	for _, accept := range s.accepts {
+		if accept.session == sess {
+			accept.conn.Close()
+			continue
+		}
+		kept = append(kept, accept)
+	}
+	s.accepts = kept
+}
+
+// logf logs a line if the server has a logger.
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.options.Logger != nil {
+		s.options.Logger.Printf(format, args...)
+	}
+}
+
+// reply writes a reply line in a single write, as go-sam-go reads each reply
+// with a single read.
+func (c *connection) reply(format string, args ...interface{}) error {
+	_, err := io.WriteString(c.Conn, fmt.Sprintf(format, args...)+"\n")
+	return err
+}
+
+// relay copies data from src, including anything it buffered, to dst and
+// closes both when either side ends.
+func relay(dst, src *connection) {
+	io.Copy(dst.Conn, src.reader)
+	dst.Close()
+	src.Close()
+}
+
+// parseCommand splits a SAM command line into its verb, action and
+// KEY=VALUE arguments. Values may be double-quoted.
+func parseCommand(line string) command {
+	fields := splitFields(strings.TrimSpace(line))
+	cmd := command{args: make(map[string]string)}
+	if len(fields) > 0 {
+		cmd.verb = strings.ToUpper(fields[0])
+		fields = fields[1:]
+	}
+	if len(fields) > 0 && !strings.Contains(fields[0], "=") {
+		cmd.action = strings.ToUpper(fields[0])
+		fields = fields[1:]
+	}
+	for _, field := range fields {
+		key, value, _ := strings.Cut(field, "=")
+		cmd.args[key] = strings.Trim(value, "\"")
+	}
+	return cmd
+}
+
+// splitFields splits a line at spaces outside double quotes.
+func splitFields(line string) []string {
+	var fields []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case r == ' ' && !quoted:
+			if current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+// describeArgs summarizes command arguments for logging, leaving out keys.
+func describeArgs(args map[string]string) string {
+	var parts []string
+	for _, key := range []string{"ID", "STYLE", "NAME", "FROM_PORT", "TO_PORT"} {
+		if value, exists := args[key]; exists {
+			parts = append(parts, key+"="+value)
+		}
+	}
+	if destination, exists := args["DESTINATION"]; exists {
+		if isDestination(destination) {
+			destination = base32Address(destination)
+		}
+		parts = append(parts, "DESTINATION="+destination)
+	}
+	return strings.Join(parts, " ")
+}
+
+// sessionPorts returns a session's FROM_PORT, TO_PORT and the port it
+// accepts streams on (LISTEN_PORT, defaulting to FROM_PORT).
+func sessionPorts(args map[string]string) (fromPort, toPort, listenPort int) {
+	fromPort = parsePort(args["FROM_PORT"], 0)
+	toPort = parsePort(args["TO_PORT"], 0)
+	listenPort = parsePort(args["LISTEN_PORT"], fromPort)
+	return fromPort, toPort, listenPort
+}
+
+// parsePort parses a port argument, returning fallback if it is missing or
+// invalid.
+func parsePort(value string, fallback int) int {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 0 || port > 65535 {
+		return fallback
+	}
+	return port
+}
+
+// isDestination reports whether s is a base64 destination.
+func isDestination(s string) bool {
+	if len(s) < 516 {
+		return false
+	}
+	_, err := i2pEncoding.DecodeString(s)
+	return err == nil
+}
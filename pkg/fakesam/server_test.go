@@ -0,0 +1,288 @@
+package fakesam
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	sam3 "github.com/go-i2p/go-sam-go"
+	"github.com/go-i2p/i2pkeys"
+)
+
+// newTestServer starts a fake SAM server on a free port.
+func newTestServer(t *testing.T, options ServerOptions) *Server {
+	t.Helper()
+
+	server := NewServer(options)
+	if err := server.Listen("127.0.0.1:0"); err != nil {
+		t.Fatalf("Failed to start fake SAM server: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	for server.Addr() == "" {
+		time.Sleep(time.Millisecond)
+	}
+	return server
+}
+
+// rawExchange sends SAM command lines on a new connection and returns the
+// reply to each.
+func rawExchange(t *testing.T, server *Server, lines ...string) []string {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	var replies []string
+	for _, line := range lines {
+		if _, err := io.WriteString(conn, line+"\n"); err != nil {
+			t.Fatalf("Failed to send %q: %v", line, err)
+		}
+		reply, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read reply to %q: %v", line, err)
+		}
+		replies = append(replies, strings.TrimSpace(reply))
+	}
+	return replies
+}
+
+func TestDeterministicKeys(t *testing.T) {
+	first := NewServer(ServerOptions{Seed: "test"})
+	second := NewServer(ServerOptions{Seed: "test"})
+	other := NewServer(ServerOptions{Seed: "other"})
+
+	public, private := first.NewKeys()
+	if samePublic, samePrivate := second.NewKeys(); samePublic != public || samePrivate != private {
+		t.Error("Expected servers with the same seed to generate the same keys")
+	}
+	if otherPublic, _ := other.NewKeys(); otherPublic == public {
+		t.Error("Expected servers with different seeds to generate different keys")
+	}
+	if next, _ := first.NewKeys(); next == public {
+		t.Error("Expected successive keys to differ")
+	}
+
+	if _, err := i2pkeys.NewI2PAddrFromString(public); err != nil {
+		t.Errorf("Generated destination is not a valid I2P address: %v", err)
+	}
+	if derived, err := publicFromPrivate(private); err != nil || derived != public {
+		t.Errorf("publicFromPrivate() = %q, %v; want the generated destination", derived, err)
+	}
+	if _, err := publicFromPrivate(public); err == nil {
+		t.Error("Expected a destination without private keys to be rejected")
+	}
+}
+
+func TestHelloAndNaming(t *testing.T) {
+	hosted, _ := deriveKeys("hosts", 1)
+	server := newTestServer(t, ServerOptions{Hosts: map[string]string{"Example.i2p": hosted}})
+
+	replies := rawExchange(t, server,
+		"HELLO VERSION MIN=3.1 MAX=3.3",
+		"NAMING LOOKUP NAME=example.i2p",
+		"NAMING LOOKUP NAME="+base32Address(hosted),
+		"NAMING LOOKUP NAME=missing.i2p",
+		"NAMING LOOKUP NAME=ME",
+		"PING 123",
+	)
+
+	expected := []string{
+		"HELLO REPLY RESULT=OK VERSION=3.3",
+		"NAMING REPLY RESULT=OK NAME=example.i2p VALUE=" + hosted,
+		"NAMING REPLY RESULT=OK NAME=" + base32Address(hosted) + " VALUE=" + hosted,
+		"NAMING REPLY RESULT=KEY_NOT_FOUND NAME=missing.i2p",
+		"NAMING REPLY RESULT=KEY_NOT_FOUND NAME=ME",
+		"PONG 123",
+	}
+	for i, reply := range replies {
+		if reply != expected[i] {
+			t.Errorf("Reply %d = %q, want %q", i, reply, expected[i])
+		}
+	}
+
+	if replies := rawExchange(t, server, "NAMING LOOKUP NAME=example.i2p"); !strings.Contains(replies[0], "HELLO expected") {
+		t.Errorf("Expected commands before HELLO to be refused, got %q", replies[0])
+	}
+	if replies := rawExchange(t, server, "HELLO VERSION MIN=3.4 MAX=3.4"); replies[0] != "HELLO REPLY RESULT=NOVERSION" {
+		t.Errorf("Expected unsupported versions to be refused, got %q", replies[0])
+	}
+}
+
+func TestSessionCreate(t *testing.T) {
+	server := newTestServer(t, ServerOptions{})
+	_, private := server.NewKeys()
+
+	conn, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	send := func(line string) string {
+		io.WriteString(conn, line+"\n")
+		reply, _ := reader.ReadString('\n')
+		return strings.TrimSpace(reply)
+	}
+
+	send("HELLO VERSION MIN=3.1 MAX=3.3")
+	if reply := send("SESSION CREATE STYLE=PRIMARY ID=primary DESTINATION=" + private); reply != "SESSION STATUS RESULT=OK DESTINATION="+private {
+		t.Fatalf("Unexpected SESSION CREATE reply %q", reply)
+	}
+	if reply := send("SESSION ADD STYLE=STREAM ID=sub FROM_PORT=80 TO_PORT=80"); !strings.HasPrefix(reply, "SESSION STATUS RESULT=OK") {
+		t.Errorf("Unexpected SESSION ADD reply %q", reply)
+	}
+	if reply := send("SESSION ADD STYLE=STREAM ID=sub"); reply != "SESSION STATUS RESULT=DUPLICATED_ID" {
+		t.Errorf("Expected a duplicate sub-session ID to be refused, got %q", reply)
+	}
+	if reply := send("SESSION REMOVE ID=sub"); !strings.HasPrefix(reply, "SESSION STATUS RESULT=OK") {
+		t.Errorf("Unexpected SESSION REMOVE reply %q", reply)
+	}
+
+	// The destination and ID are held until the session's connection closes
+	replies := rawExchange(t, server,
+		"HELLO VERSION MIN=3.1 MAX=3.3",
+		"SESSION CREATE STYLE=STREAM ID=other DESTINATION="+private,
+	)
+	if replies[1] != "SESSION STATUS RESULT=DUPLICATED_DEST" {
+		t.Errorf("Expected a duplicate destination to be refused, got %q", replies[1])
+	}
+	replies = rawExchange(t, server,
+		"HELLO VERSION MIN=3.1 MAX=3.3",
+		"SESSION CREATE STYLE=STREAM ID=bad DESTINATION=AAAA",
+	)
+	if replies[1] != "SESSION STATUS RESULT=INVALID_KEY" {
+		t.Errorf("Expected invalid keys to be refused, got %q", replies[1])
+	}
+
+	conn.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		replies = rawExchange(t, server,
+			"HELLO VERSION MIN=3.1 MAX=3.3",
+			"SESSION CREATE STYLE=STREAM ID=primary DESTINATION=TRANSIENT",
+		)
+		if strings.HasPrefix(replies[1], "SESSION STATUS RESULT=OK") || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strings.HasPrefix(replies[1], "SESSION STATUS RESULT=OK") {
+		t.Errorf("Expected the session ID to be free after its connection closed, got %q", replies[1])
+	}
+}
+
+// openSession creates a STREAM session on a control connection that stays
+// open until the test ends and returns the session's destination.
+func openSession(t *testing.T, server *Server, id string) string {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", server.Addr())
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	reader := bufio.NewReader(conn)
+
+	io.WriteString(conn, "HELLO VERSION MIN=3.1 MAX=3.3\n")
+	reader.ReadString('\n')
+	io.WriteString(conn, "SESSION CREATE STYLE=STREAM ID="+id+" DESTINATION=TRANSIENT\n")
+	reply, _ := reader.ReadString('\n')
+
+	destination, err := publicFromPrivate(strings.TrimSpace(strings.TrimPrefix(reply, "SESSION STATUS RESULT=OK DESTINATION=")))
+	if err != nil {
+		t.Fatalf("Failed to create session %s: %q", id, reply)
+	}
+	return destination
+}
+
+func TestStreamLoopback(t *testing.T) {
+	server := newTestServer(t, ServerOptions{})
+	serviceDestination := openSession(t, server, "service")
+
+	// Accept one stream on the service's destination and echo a line
+	accepted := make(chan string, 1)
+	go func() {
+		conn, err := net.Dial("tcp", server.Addr())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		io.WriteString(conn, "HELLO VERSION MIN=3.1 MAX=3.3\n")
+		reader.ReadString('\n')
+		io.WriteString(conn, "STREAM ACCEPT ID=service SILENT=false\n")
+		reader.ReadString('\n')
+		peer, _ := reader.ReadString('\n')
+		accepted <- peer
+		line, _ := reader.ReadString('\n')
+		io.WriteString(conn, "echo: "+line)
+	}()
+
+	// Dial it with go-sam-go through a stream sub-session
+	sam, err := sam3.NewSAM(server.Addr())
+	if err != nil {
+		t.Fatalf("Failed to connect to fake SAM server: %v", err)
+	}
+	defer sam.Close()
+	keys, err := sam.NewKeys()
+	if err != nil {
+		t.Fatalf("Failed to generate keys: %v", err)
+	}
+	primary, err := sam.NewPrimarySession("client", keys, nil)
+	if err != nil {
+		t.Fatalf("Failed to create primary session: %v", err)
+	}
+	defer primary.Close()
+	client, err := primary.NewStreamSubSessionWithPort("client-http", nil, 1080, 80)
+	if err != nil {
+		t.Fatalf("Failed to create stream sub-session: %v", err)
+	}
+
+	conn, err := client.Dial(base32Address(serviceDestination))
+	if err != nil {
+		t.Fatalf("Failed to dial the service: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := io.WriteString(conn, "hello\n"); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || reply != "echo: hello\n" {
+		t.Errorf("Unexpected reply %q, %v", reply, err)
+	}
+
+	if peer := <-accepted; peer != keys.Addr().Base64()+" FROM_PORT=1080 TO_PORT=80\n" {
+		t.Errorf("Unexpected peer line %q", peer)
+	}
+}
+
+func TestStreamConnectFailures(t *testing.T) {
+	server := newTestServer(t, ServerOptions{AcceptTimeout: 100 * time.Millisecond})
+	openSession(t, server, "dialer")
+	idle := openSession(t, server, "idle")
+	unknown, _ := deriveKeys("unknown", 1)
+
+	for _, tt := range []struct {
+		command string
+		want    string
+	}{
+		{command: "STREAM CONNECT ID=dialer DESTINATION=" + unknown, want: "STREAM STATUS RESULT=CANT_REACH_PEER"},
+		{command: "STREAM CONNECT ID=dialer DESTINATION=" + idle, want: "STREAM STATUS RESULT=TIMEOUT"},
+		{command: "STREAM CONNECT ID=dialer DESTINATION=missing.i2p", want: "STREAM STATUS RESULT=INVALID_KEY"},
+		{command: "STREAM CONNECT ID=nobody DESTINATION=" + idle, want: "STREAM STATUS RESULT=INVALID_ID"},
+	} {
+		replies := rawExchange(t, server, "HELLO VERSION MIN=3.1 MAX=3.3", tt.command)
+		if !strings.HasPrefix(replies[1], tt.want) {
+			t.Errorf("%s = %q, want %q", tt.command[:26], replies[1], tt.want)
+		}
+	}
+}
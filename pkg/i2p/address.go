@@ -0,0 +1,283 @@
+// Package i2p provides conversions between the encodings of I2P addresses.
+//
+// A destination is commonly written in one of three forms. The full base64
+// destination is what SAM hands out and what clients dial without a lookup.
+// Its .b32.i2p address is the base32 SHA-256 hash of the destination, short
+// enough for hostnames but only usable if the leaseset is published in the
+// clear. Destinations with a blinded leaseset (i2cp.leaseSetType=5) are
+// reached through their b33 address instead: a longer .b32.i2p name that
+// carries the destination's signing key, from which clients derive the
+// blinded key the leaseset is stored under, along with flags telling them
+// whether a secret or per-client authorization is needed.
+//
+// Addresses bundles the forms of a destination, and Addresses.Encoded picks
+// one by name, so consumers such as publishers can be configured with the
+// encoding their clients need.
+package i2p
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"strings"
+)
+
+// Address encodings accepted by Addresses.Encoded.
+const (
+	// EncodingB32 is the .b32.i2p hash of the destination
+	EncodingB32 = "b32"
+	// EncodingB33 is the .b32.i2p address of a blinded destination
+	EncodingB33 = "b33"
+	// EncodingBase64 is the full base64 destination
+	EncodingBase64 = "base64"
+)
+
+// Signature types of destinations that can be blinded.
+const (
+	// sigTypeEd25519 is EdDSA_SHA512_Ed25519, the default of SAM sessions
+	sigTypeEd25519 = 7
+	// sigTypeRedDSA is RedDSA_SHA512_Ed25519, the type of blinded keys
+	sigTypeRedDSA = 11
+)
+
+// Destination layout: a 256-byte encryption key field and a 128-byte signing
+// key field, followed by a certificate of one type byte, a two-byte length
+// and its payload.
+const (
+	destinationKeysLength = 384
+	certificateTypeKey    = 5
+)
+
+var (
+	// i2pBase64 is I2P's base64 alphabet, using - and ~ instead of + and /
+	i2pBase64 = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-~")
+	// i2pBase32 is the lower-case, unpadded base32 of .b32.i2p addresses
+	i2pBase32 = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+)
+
+// b32Suffix is the suffix of b32 and b33 addresses.
+const b32Suffix = ".b32.i2p"
+
+// b32Length is the length of a b32 address without its suffix.
+const b32Length = 52
+
+// Blinding describes how a destination's leaseset is blinded.
+type Blinding struct {
+	// Secret reports whether clients need the leaseset secret
+	Secret bool
+	// PerClientAuth reports whether clients need an authorization key
+	PerClientAuth bool
+}
+
+// BlindingFor returns the blinding a session with options publishes its
+// leaseset with, and false if the leaseset is not blinded.
+//
+// Blinding is enabled by passing i2cp.leaseSetType=5 through to the router;
+// i2cp.leaseSetSecret and i2cp.leaseSetAuthType select the secret and
+// per-client authorization.
+func BlindingFor(options TunnelOptions) (Blinding, bool) {
+	if strings.TrimSpace(options.Extra["i2cp.leaseSetType"]) != "5" {
+		return Blinding{}, false
+	}
+	authType := strings.TrimSpace(options.Extra["i2cp.leaseSetAuthType"])
+	return Blinding{
+		Secret:        options.Extra["i2cp.leaseSetSecret"] != "",
+		PerClientAuth: authType != "" && authType != "0",
+	}, true
+}
+
+// Addresses holds the encodings of a destination.
+type Addresses struct {
+	// Base64 is the full base64 destination
+	Base64 string `json:"base64"`
+	// B32 is the .b32.i2p address
+	B32 string `json:"b32"`
+	// B33 is the .b32.i2p address of the blinded leaseset, if it is blinded
+	B33 string `json:"b33,omitempty"`
+}
+
+// AddressesOf returns the encodings of a base64 destination. The b33 address
+// is included if blinding is not nil.
+func AddressesOf(destination string, blinding *Blinding) (Addresses, error) {
+	b32, err := B32Address(destination)
+	if err != nil {
+		return Addresses{}, err
+	}
+	addresses := Addresses{Base64: destination, B32: b32}
+	if blinding != nil {
+		if addresses.B33, err = B33Address(destination, *blinding); err != nil {
+			return Addresses{}, err
+		}
+	}
+	return addresses, nil
+}
+
+// Encoded returns the address in the named encoding. A destination without a
+// b33 address is returned as b32, as it is reachable under that.
+func (a Addresses) Encoded(encoding string) string {
+	switch encoding {
+	case EncodingBase64:
+		return a.Base64
+	case EncodingB33:
+		if a.B33 != "" {
+			return a.B33
+		}
+	}
+	return a.B32
+}
+
+// ValidateEncoding checks that encoding names an address encoding.
+func ValidateEncoding(encoding string) error {
+	switch encoding {
+	case EncodingB32, EncodingB33, EncodingBase64:
+		return nil
+	}
+	return fmt.Errorf("unknown address encoding %q (must be %s, %s or %s)", encoding, EncodingB32, EncodingB33, EncodingBase64)
+}
+
+// DecodeDestination decodes a base64 destination into its binary form.
+func DecodeDestination(destination string) ([]byte, error) {
+	raw, err := i2pBase64.DecodeString(strings.TrimSuffix(destination, ".i2p"))
+	if err != nil {
+		return nil, fmt.Errorf("destination is not I2P base64: %w", err)
+	}
+	if len(raw) < destinationKeysLength+3 {
+		return nil, fmt.Errorf("destination is %d bytes, too short for a destination", len(raw))
+	}
+	return raw, nil
+}
+
+// EncodeDestination returns the base64 form of a binary destination.
+func EncodeDestination(raw []byte) string {
+	return i2pBase64.EncodeToString(raw)
+}
+
+// B32Address returns the .b32.i2p address of a base64 destination.
+func B32Address(destination string) (string, error) {
+	raw, err := DecodeDestination(destination)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(raw)
+	return i2pBase32.EncodeToString(hash[:]) + b32Suffix, nil
+}
+
+// B33Address returns the b33 address of a base64 destination whose leaseset
+// is blinded. Only Ed25519 destinations, the SAM default, can be blinded.
+func B33Address(destination string, blinding Blinding) (string, error) {
+	raw, err := DecodeDestination(destination)
+	if err != nil {
+		return "", err
+	}
+	sigType := signatureType(raw)
+	if sigType != sigTypeEd25519 && sigType != sigTypeRedDSA {
+		return "", fmt.Errorf("destinations with signature type %d cannot be blinded", sigType)
+	}
+
+	// Ed25519 keys are right-aligned in the signing key field
+	key := raw[destinationKeysLength-32 : destinationKeysLength]
+	data := make([]byte, 3+len(key))
+	data[0] = blindingFlags(blinding)
+	data[1] = byte(sigType)
+	data[2] = sigTypeRedDSA
+	copy(data[3:], key)
+
+	// The checksum of the key guards against typos
+	checksum := crc32.ChecksumIEEE(data[3:])
+	data[0] ^= byte(checksum)
+	data[1] ^= byte(checksum >> 8)
+	data[2] ^= byte(checksum >> 16)
+
+	return i2pBase32.EncodeToString(data) + b32Suffix, nil
+}
+
+// BlindedAddress is the decoded form of a b33 address.
+type BlindedAddress struct {
+	// SigningKey is the destination's signing public key
+	SigningKey []byte
+	// SigType is the signature type of the destination
+	SigType int
+	// BlindedSigType is the signature type of the blinded key
+	BlindedSigType int
+	// Blinding is what clients need to reach the destination
+	Blinding Blinding
+}
+
+// ParseB33Address decodes a b33 address. Addresses whose checksum does not
+// match decode to invalid flags or signature types and are refused.
+func ParseB33Address(address string) (BlindedAddress, error) {
+	encoded, ok := strings.CutSuffix(strings.ToLower(address), b32Suffix)
+	if !ok || len(encoded) <= b32Length {
+		return BlindedAddress{}, fmt.Errorf("%q is not a b33 address", address)
+	}
+	data, err := i2pBase32.DecodeString(encoded)
+	if err != nil || len(data) < 4 {
+		return BlindedAddress{}, fmt.Errorf("%q is not a b33 address", address)
+	}
+
+	checksum := crc32.ChecksumIEEE(data[3:])
+	flags := data[0] ^ byte(checksum)
+	sigType := data[1] ^ byte(checksum>>8)
+	blindedSigType := data[2] ^ byte(checksum>>16)
+	// Two-byte signature types are not used by any blindable key
+	if flags&0xf9 != 0 || sigType != sigTypeEd25519 && sigType != sigTypeRedDSA || blindedSigType != sigTypeRedDSA || len(data[3:]) != 32 {
+		return BlindedAddress{}, fmt.Errorf("%q is not a valid b33 address", address)
+	}
+
+	return BlindedAddress{
+		SigningKey:     data[3:],
+		SigType:        int(sigType),
+		BlindedSigType: int(blindedSigType),
+		Blinding: Blinding{
+			Secret:        flags&0x02 != 0,
+			PerClientAuth: flags&0x04 != 0,
+		},
+	}, nil
+}
+
+// MatchesB33 reports whether a b33 address belongs to a base64 destination.
+func MatchesB33(address, destination string) bool {
+	blinded, err := ParseB33Address(address)
+	if err != nil {
+		return false
+	}
+	expected, err := B33Address(destination, blinded.Blinding)
+	return err == nil && expected == strings.ToLower(address)
+}
+
+// SessionBlinding returns the blinding of the leaseset owner's tunnels are
+// served from, and false if it is not blinded. Sessions that do not exist
+// yet are reported as they will be created.
+func (tm *TunnelManager) SessionBlinding(owner string) (Blinding, bool) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	if router, exists := tm.containerRouters[owner]; exists {
+		return BlindingFor(router.options)
+	}
+	return BlindingFor(tm.sessionOptionsLocked(owner))
+}
+
+// blindingFlags returns the flag byte of a b33 address.
+func blindingFlags(blinding Blinding) byte {
+	var flags byte
+	if blinding.Secret {
+		flags |= 0x02
+	}
+	if blinding.PerClientAuth {
+		flags |= 0x04
+	}
+	return flags
+}
+
+// signatureType returns the signature type of a binary destination. A
+// destination without a key certificate is DSA_SHA1, type 0.
+func signatureType(raw []byte) int {
+	if raw[destinationKeysLength] != certificateTypeKey || len(raw) < destinationKeysLength+5 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(raw[destinationKeysLength+3:]))
+}
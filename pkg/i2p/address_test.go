@@ -0,0 +1,158 @@
+package i2p
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+// testDestination returns a base64 Ed25519 destination with the given
+// signing key and a key certificate.
+func testDestination(signingKey []byte) string {
+	raw := make([]byte, destinationKeysLength, destinationKeysLength+7)
+	for i := range raw {
+		raw[i] = byte(i)
+	}
+	copy(raw[destinationKeysLength-32:], signingKey)
+	raw = append(raw, certificateTypeKey, 0, 4, 0, sigTypeEd25519, 0, 4)
+	return EncodeDestination(raw)
+}
+
+func TestB32Address(t *testing.T) {
+	destination := testDestination(bytes.Repeat([]byte{0xab}, 32))
+
+	address, err := B32Address(destination)
+	if err != nil {
+		t.Fatalf("B32Address() unexpected error: %v", err)
+	}
+	if expected := i2pkeys.I2PAddr(destination).Base32(); address != expected {
+		t.Errorf("Expected %s, got %s", expected, address)
+	}
+	if len(address) != b32Length+len(b32Suffix) {
+		t.Errorf("Expected a %d character address, got %s", b32Length+len(b32Suffix), address)
+	}
+
+	for _, invalid := range []string{"", "not-a-destination", EncodeDestination(make([]byte, 100))} {
+		if _, err := B32Address(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestB33Address(t *testing.T) {
+	key := bytes.Repeat([]byte{0x5c}, 32)
+	destination := testDestination(key)
+
+	tests := []struct {
+		name     string
+		blinding Blinding
+	}{
+		{name: "plain"},
+		{name: "secret", blinding: Blinding{Secret: true}},
+		{name: "per-client auth", blinding: Blinding{Secret: true, PerClientAuth: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			address, err := B33Address(destination, tt.blinding)
+			if err != nil {
+				t.Fatalf("B33Address() unexpected error: %v", err)
+			}
+			if !strings.HasSuffix(address, b32Suffix) || len(address) != 56+len(b32Suffix) {
+				t.Errorf("Unexpected b33 address %s", address)
+			}
+
+			blinded, err := ParseB33Address(strings.ToUpper(address))
+			if err != nil {
+				t.Fatalf("ParseB33Address() unexpected error: %v", err)
+			}
+			if !bytes.Equal(blinded.SigningKey, key) || blinded.SigType != sigTypeEd25519 ||
+				blinded.BlindedSigType != sigTypeRedDSA || blinded.Blinding != tt.blinding {
+				t.Errorf("Unexpected decoded address %+v", blinded)
+			}
+			if !MatchesB33(address, destination) {
+				t.Error("Expected the address to match its destination")
+			}
+			if MatchesB33(address, testDestination(bytes.Repeat([]byte{0x01}, 32))) {
+				t.Error("Expected the address not to match another destination")
+			}
+		})
+	}
+}
+
+func TestB33AddressErrors(t *testing.T) {
+	// A destination without a key certificate is DSA_SHA1
+	raw := make([]byte, destinationKeysLength+3)
+	if _, err := B33Address(EncodeDestination(raw), Blinding{}); err == nil {
+		t.Error("Expected an error for a DSA destination")
+	}
+
+	b32, _ := B32Address(testDestination(make([]byte, 32)))
+	address, _ := B33Address(testDestination(make([]byte, 32)), Blinding{})
+	corrupted := "b" + address[1:]
+	if address[0] == 'b' {
+		corrupted = "c" + address[1:]
+	}
+	for _, invalid := range []string{b32, "forum.i2p", corrupted} {
+		if _, err := ParseB33Address(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestAddresses(t *testing.T) {
+	destination := testDestination(bytes.Repeat([]byte{0x42}, 32))
+
+	plain, err := AddressesOf(destination, nil)
+	if err != nil {
+		t.Fatalf("AddressesOf() unexpected error: %v", err)
+	}
+	if plain.Base64 != destination || plain.B33 != "" {
+		t.Errorf("Unexpected addresses %+v", plain)
+	}
+	if plain.Encoded(EncodingB33) != plain.B32 {
+		t.Error("Expected b33 to fall back to b32 without blinding")
+	}
+
+	blinded, err := AddressesOf(destination, &Blinding{})
+	if err != nil {
+		t.Fatalf("AddressesOf() unexpected error: %v", err)
+	}
+	if blinded.B32 != plain.B32 || blinded.B33 == "" {
+		t.Errorf("Unexpected blinded addresses %+v", blinded)
+	}
+	for encoding, expected := range map[string]string{
+		EncodingB32:    blinded.B32,
+		EncodingB33:    blinded.B33,
+		EncodingBase64: destination,
+	} {
+		if err := ValidateEncoding(encoding); err != nil {
+			t.Errorf("ValidateEncoding(%s) unexpected error: %v", encoding, err)
+		}
+		if got := blinded.Encoded(encoding); got != expected {
+			t.Errorf("Encoded(%s) = %s, expected %s", encoding, got, expected)
+		}
+	}
+	if err := ValidateEncoding("b64"); err == nil {
+		t.Error("Expected an error for an unknown encoding")
+	}
+}
+
+func TestBlindingFor(t *testing.T) {
+	options := DefaultTunnelOptions()
+	if _, blinded := BlindingFor(options); blinded {
+		t.Error("Expected default options not to blind")
+	}
+
+	options, _ = options.WithOverrides(map[string]string{
+		"i2cp.leaseSetType":     "5",
+		"i2cp.leaseSetSecret":   "s3cret",
+		"i2cp.leaseSetAuthType": "1",
+	})
+	blinding, blinded := BlindingFor(options)
+	if !blinded || !blinding.Secret || !blinding.PerClientAuth {
+		t.Errorf("Expected a blinded leaseset with secret and auth, got %+v, %v", blinding, blinded)
+	}
+}
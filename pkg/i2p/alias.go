@@ -0,0 +1,75 @@
+// Package i2p provides human-friendly aliases of containers.
+//
+// Container IDs are 64 hex characters, which makes tunnel names, log lines
+// and router consoles hard to read when many containers share a router. A
+// container can be given an alias, e.g. the Compose project, service and
+// container number "webapp_web_1", that replaces the short container ID in
+// its tunnel names and log messages. Aliases are sanitized like every tunnel
+// name part and truncated to MaxAliasLength. Tunnel names stay unique when
+// two containers carry the same alias, because their hash suffix covers the
+// full container ID.
+//
+// Aliases are kept per process; the plugin registers a container's alias
+// when it joins, before any of its tunnels is named, and removes it once the
+// container has left every network.
+package i2p
+
+import (
+	"strings"
+	"sync"
+)
+
+// MaxAliasLength is the maximum length of a container alias in tunnel names.
+const MaxAliasLength = 24
+
+// containerAliases maps container IDs to their aliases.
+var containerAliases = struct {
+	sync.RWMutex
+	names map[string]string
+}{names: make(map[string]string)}
+
+// SetContainerAlias sets the alias of a container; an empty alias removes it.
+//
+// The alias is sanitized and truncated. Tunnels the container already has
+// keep their names, so the alias must be set before the container's tunnels
+// are created.
+func SetContainerAlias(containerID, alias string) {
+	alias = SanitizeAlias(alias)
+
+	containerAliases.Lock()
+	defer containerAliases.Unlock()
+
+	if alias == "" {
+		delete(containerAliases.names, containerID)
+		return
+	}
+	containerAliases.names[containerID] = alias
+}
+
+// ContainerAlias returns the alias of a container, or false if it has none.
+func ContainerAlias(containerID string) (string, bool) {
+	containerAliases.RLock()
+	defer containerAliases.RUnlock()
+
+	alias, exists := containerAliases.names[containerID]
+	return alias, exists
+}
+
+// DisplayName returns the alias of a container, or its short ID if it has
+// none.
+func DisplayName(containerID string) string {
+	if alias, exists := ContainerAlias(containerID); exists {
+		return alias
+	}
+	return ShortID(containerID)
+}
+
+// SanitizeAlias returns an alias in the form used in tunnel names: limited
+// to SAM-safe characters and at most MaxAliasLength long.
+func SanitizeAlias(alias string) string {
+	alias = sanitizeNamePart(alias)
+	if len(alias) > MaxAliasLength {
+		alias = alias[:MaxAliasLength]
+	}
+	return strings.Trim(alias, "-._")
+}
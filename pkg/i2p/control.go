@@ -0,0 +1,118 @@
+// Package i2p provides datagram control channels between plugin instances.
+//
+// Plugin instances on different hosts exchange small control messages over
+// repliable I2P datagrams. A control channel is a datagram sub-session of an
+// owner's primary session, so it is reachable at the owner's destination.
+// Repliable datagrams are signed by their sender, which makes the source
+// address of a received message trustworthy without any further handshake.
+//
+// The sub-session lives on the router the owner's session was created on and
+// is not handed off on router failover; a channel whose Receive fails is
+// closed and opened again by its user.
+package i2p
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/go-i2p/go-sam-go/primary"
+	"github.com/go-i2p/i2pkeys"
+)
+
+// ControlChannel sends and receives datagrams at an owner's destination.
+type ControlChannel struct {
+	tm      *TunnelManager
+	session *primary.DatagramSubSession
+	address string
+
+	// destinations caches the base64 destinations of b32 addresses sent to
+	destinations map[string]i2pkeys.I2PAddr
+	mutex        sync.Mutex
+}
+
+// OpenControlChannel opens a datagram channel on port of owner's session,
+// creating the session if needed.
+func (tm *TunnelManager) OpenControlChannel(owner string, port int) (*ControlChannel, error) {
+	if owner == "" {
+		return nil, fmt.Errorf("owner cannot be empty")
+	}
+	if port < 1 || port > 65535 {
+		return nil, fmt.Errorf("control port must be between 1 and 65535, got %d", port)
+	}
+
+	session, err := tm.GetOrCreateContainerSession(owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session for %s: %w", owner, err)
+	}
+	options := []string{fmt.Sprintf("PORT=%d", port), fmt.Sprintf("FROM_PORT=%d", port)}
+	datagrams, err := session.NewDatagramSubSession(fmt.Sprintf("%s-control-%d", owner, port), options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create control channel for %s: %w", owner, err)
+	}
+
+	channel := &ControlChannel{
+		tm:           tm,
+		session:      datagrams,
+		address:      session.Addr().Base32(),
+		destinations: make(map[string]i2pkeys.I2PAddr),
+	}
+	log.Printf("Opened control channel of %s at %s port %d", owner, channel.address, port)
+	return channel, nil
+}
+
+// Address returns the b32 address datagrams to the channel are sent to.
+func (c *ControlChannel) Address() string {
+	return c.address
+}
+
+// Send sends a datagram to a b32 address or base64 destination. b32
+// addresses are looked up once and remembered.
+func (c *ControlChannel) Send(destination string, data []byte) error {
+	addr, err := c.resolve(destination)
+	if err != nil {
+		return err
+	}
+	return c.session.SendDatagram(data, addr)
+}
+
+// resolve returns the destination of a b32 address or base64 destination.
+func (c *ControlChannel) resolve(destination string) (i2pkeys.I2PAddr, error) {
+	if !strings.HasSuffix(destination, b32Suffix) {
+		return i2pkeys.NewI2PAddrFromString(destination)
+	}
+
+	c.mutex.Lock()
+	addr, known := c.destinations[destination]
+	c.mutex.Unlock()
+	if known {
+		return addr, nil
+	}
+
+	base64, err := c.tm.LookupName(destination)
+	if err != nil {
+		return "", err
+	}
+	addr = i2pkeys.I2PAddr(base64)
+
+	c.mutex.Lock()
+	c.destinations[destination] = addr
+	c.mutex.Unlock()
+	return addr, nil
+}
+
+// Receive blocks until a datagram arrives and returns it with the b32 address
+// of its sender.
+func (c *ControlChannel) Receive() (source string, data []byte, err error) {
+	datagram, err := c.session.ReceiveDatagram()
+	if err != nil {
+		return "", nil, err
+	}
+	return datagram.Source.Base32(), datagram.Data, nil
+}
+
+// Close closes the channel, leaving the owner's session open.
+func (c *ControlChannel) Close() error {
+	return c.session.Close()
+}
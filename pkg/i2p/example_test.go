@@ -0,0 +1,66 @@
+package i2p_test
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+func ExampleNewTunnelManager() {
+	tm := i2p.NewTunnelManager(i2p.TunnelManagerOptions{
+		SAM: &i2p.SAMConfig{
+			Host:    "127.0.0.1",
+			Port:    7656,
+			Timeout: 30 * time.Second,
+			Backups: []string{"127.0.0.1:7657"},
+		},
+	})
+	defer tm.DestroyAllTunnels()
+
+	tunnel, err := tm.CreateClientTunnelTo("my-app", "forum.i2p", 8080)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	conn, err := tunnel.Connect()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	io.WriteString(conn, "GET / HTTP/1.0\r\nHost: forum.i2p\r\n\r\n")
+	io.Copy(os.Stdout, conn)
+}
+
+func ExampleTunnelManager_CreateServerTunnelWithKeys() {
+	tm := i2p.NewTunnelManager(i2p.TunnelManagerOptions{})
+	defer tm.DestroyAllTunnels()
+
+	// mirror.keys holds the base64 destination and private keys on two lines
+	data, err := os.ReadFile("mirror.keys")
+	if err != nil {
+		log.Fatal(err)
+	}
+	public, private, _ := strings.Cut(string(data), "\n")
+	keys, err := i2p.ParseKeys(public, private)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tunnel, err := tm.CreateServerTunnelWithKeys("mirror", keys, 80)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Serving %s", tunnel.GetDestination())
+
+	listener, err := tunnel.Listen()
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.Serve(listener, http.FileServer(http.Dir("/srv/mirror")))
+}
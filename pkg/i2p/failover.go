@@ -0,0 +1,418 @@
+// Package i2p provides SAM router failover for container sessions.
+//
+// This file implements connection draining and session handoff when the plugin
+// fails over from one SAM router to a backup. New connections are directed to
+// the new router immediately, while streams opened through the old router stay
+// alive until they close naturally. Only then is the old session torn down.
+// During the handoff window a container may therefore hold sessions on several
+// routers at once; these are tracked per container as router sessions.
+package i2p
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RouterSessionInfo describes a container's session on a single SAM router.
+type RouterSessionInfo struct {
+	// Address is the host:port of the SAM router
+	Address string `json:"address"`
+	// ActiveStreams is the number of open streams using this session
+	ActiveStreams int `json:"active_streams"`
+	// Draining is true once the session no longer accepts new connections
+	Draining bool `json:"draining"`
+}
+
+// routerSession tracks the I2P sessions a container holds on one SAM router.
+//
+// The session is reference counted by open streams. Once draining, it closes
+// itself as soon as the last stream is released.
+type routerSession struct {
+	address     string
+	samClient   *SAMClient
+	primary     interface{ Close() error }
+	subSessions []interface{ Close() error }
+	options     TunnelOptions // Tunnel options the primary session was created with
+	created     time.Time
+	streams     int
+	draining    bool
+	closed      bool
+	mutex       sync.Mutex
+}
+
+// acquire registers a new stream on the session.
+func (r *routerSession) acquire() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.streams++
+}
+
+// release unregisters a stream and closes a draining session once idle.
+func (r *routerSession) release() {
+	r.mutex.Lock()
+	r.streams--
+	idle := r.draining && r.streams <= 0
+	r.mutex.Unlock()
+
+	if idle {
+		r.close()
+	}
+}
+
+// drain stops the session from being used for new connections.
+//
+// The session is closed immediately if no streams are open.
+func (r *routerSession) drain() {
+	r.mutex.Lock()
+	r.draining = true
+	idle := r.streams <= 0
+	r.mutex.Unlock()
+
+	if idle {
+		r.close()
+	}
+}
+
+// close tears down all sub-sessions, the primary session and the SAM client.
+func (r *routerSession) close() {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return
+	}
+	r.closed = true
+	subSessions := r.subSessions
+	r.subSessions = nil
+	r.mutex.Unlock()
+
+	log.Printf("Closing drained session on SAM router %s", r.address)
+
+	for _, sub := range subSessions {
+		if err := sub.Close(); err != nil {
+			log.Printf("Warning: Error closing drained sub-session on %s: %v", r.address, err)
+		}
+	}
+	if r.primary != nil {
+		if err := r.primary.Close(); err != nil {
+			log.Printf("Warning: Error closing drained primary session on %s: %v", r.address, err)
+		}
+	}
+	if r.samClient != nil {
+		if err := r.samClient.Disconnect(); err != nil {
+			log.Printf("Warning: Error disconnecting drained SAM client on %s: %v", r.address, err)
+		}
+	}
+}
+
+// isClosed reports whether the session has been torn down.
+func (r *routerSession) isClosed() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.closed
+}
+
+// info returns a snapshot of the session state.
+func (r *routerSession) info() RouterSessionInfo {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return RouterSessionInfo{
+		Address:       r.address,
+		ActiveStreams: r.streams,
+		Draining:      r.draining,
+	}
+}
+
+// track wraps a stream so that closing it releases the session.
+func (r *routerSession) track(conn net.Conn) net.Conn {
+	r.acquire()
+	return &trackedConn{Conn: conn, router: r}
+}
+
+// trackedConn is a stream that holds a reference on its router session.
+type trackedConn struct {
+	net.Conn
+	router *routerSession
+	once   sync.Once
+}
+
+// Close closes the stream and releases its router session.
+func (c *trackedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.router.release)
+	return err
+}
+
+// routerAddresses returns the primary SAM router followed by configured backups.
+// The caller must hold tm.mutex.
+func (tm *TunnelManager) routerAddresses() []string {
+	config := tm.samConfig
+	if config == nil {
+		config = DefaultSAMConfig()
+	}
+
+	addresses := []string{net.JoinHostPort(config.Host, strconv.Itoa(config.Port))}
+	return append(addresses, config.Backups...)
+}
+
+// activeSAMConfig returns the SAM configuration for the currently active router.
+// The caller must hold tm.mutex.
+func (tm *TunnelManager) activeSAMConfig() (*SAMConfig, error) {
+	base := DefaultSAMConfig()
+	if tm.samConfig != nil {
+		copied := *tm.samConfig
+		base = &copied
+	}
+
+	addresses := tm.routerAddresses()
+	address := addresses[tm.activeRouter%len(addresses)]
+
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAM router address %s: %w", address, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SAM router port in %s: %w", address, err)
+	}
+
+	base.Host = host
+	base.Port = port
+	return base, nil
+}
+
+// UpdateSAMConfig replaces the SAM configuration used for new container sessions.
+//
+// Existing sessions keep running on their current router; only sessions
+// created afterwards use the new configuration. The active router is reset
+// to the new primary, and sessions on other routers are moved to it by
+// scheduled maintenance.
+func (tm *TunnelManager) UpdateSAMConfig(config *SAMConfig) error {
+	if config == nil {
+		return fmt.Errorf("SAM config cannot be nil")
+	}
+	if err := validateSAMConfig(config); err != nil {
+		return fmt.Errorf("invalid SAM config: %w", err)
+	}
+
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	copied := *config
+	copied.Backups = append([]string(nil), config.Backups...)
+	tm.samConfig = &copied
+	tm.activeRouter = 0
+	tm.pacer.setLimits(samOperationLimits(&copied))
+
+	log.Printf("Updated SAM configuration, new sessions will use %s", tm.activeRouterLocked())
+	return nil
+}
+
+// SAMConfig returns a copy of the SAM configuration used for new sessions.
+func (tm *TunnelManager) SAMConfig() SAMConfig {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	if tm.samConfig == nil {
+		return *DefaultSAMConfig()
+	}
+	copied := *tm.samConfig
+	copied.Backups = append([]string(nil), tm.samConfig.Backups...)
+	return copied
+}
+
+// ActiveRouter returns the host:port of the SAM router used for new sessions.
+func (tm *TunnelManager) ActiveRouter() string {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	return tm.activeRouterLocked()
+}
+
+// activeRouterLocked returns the host:port of the active SAM router.
+// The caller must hold tm.mutex.
+func (tm *TunnelManager) activeRouterLocked() string {
+	addresses := tm.routerAddresses()
+	return addresses[tm.activeRouter%len(addresses)]
+}
+
+// Failover switches new connections to the next reachable backup SAM router.
+//
+// Every container with an active session is handed off: a new primary session
+// is created on the new router and each tunnel is rebuilt on it, so subsequent
+// dials use the new router. The old sessions are drained rather than closed,
+// keeping existing streams alive until they finish.
+func (tm *TunnelManager) Failover() error {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	return tm.failoverLocked()
+}
+
+// failoverLocked switches to the next reachable backup SAM router.
+// The caller must hold tm.mutex.
+func (tm *TunnelManager) failoverLocked() error {
+	addresses := tm.routerAddresses()
+	if len(addresses) < 2 {
+		return fmt.Errorf("no backup SAM routers configured")
+	}
+
+	previous := tm.activeRouter
+	switched := false
+	for i := 1; i < len(addresses); i++ {
+		tm.activeRouter = (previous + i) % len(addresses)
+		config, err := tm.activeSAMConfig()
+		if err != nil {
+			log.Printf("Warning: Skipping SAM router %s: %v", addresses[tm.activeRouter], err)
+			continue
+		}
+		if err := validateSAMConfig(config); err != nil {
+			log.Printf("Warning: SAM router %s unavailable: %v", addresses[tm.activeRouter], err)
+			continue
+		}
+		switched = true
+		break
+	}
+
+	if !switched {
+		tm.activeRouter = previous
+		return fmt.Errorf("no reachable backup SAM router")
+	}
+
+	log.Printf("Failing over from SAM router %s to %s", addresses[previous], addresses[tm.activeRouter])
+
+	var errors []error
+	for containerID := range tm.containerSessions {
+		if err := tm.handoffContainer(containerID); err != nil {
+			errors = append(errors, fmt.Errorf("container %s: %w", containerID, err))
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("errors handing off container sessions: %v", errors)
+	}
+
+	return nil
+}
+
+// handoffContainer moves a container's tunnels to a session on the active router.
+//
+// The container's previous router session is drained and kept until its open
+// streams close. The caller must hold tm.mutex.
+func (tm *TunnelManager) handoffContainer(containerID string) error {
+	old := tm.containerRouters[containerID]
+
+	samClient, session, err := tm.connectContainerSession(containerID)
+	if err != nil {
+		return fmt.Errorf("failed to create session on new router: %w", err)
+	}
+
+	router := tm.newRouterSession(containerID, samClient, session)
+
+	// The shared server sub-session is moved once for all tunnels using it
+	sharedErr := tm.handoffSharedServer(containerID, session, old)
+
+	for name, tunnel := range tm.tunnels {
+		if tunnel.config.ContainerID != containerID {
+			continue
+		}
+
+		previousSession := tunnel.session
+		tunnel.session = session
+
+		var createErr error
+		switch {
+		case tunnel.config.Shared:
+			createErr = sharedErr
+			if sharedErr == nil {
+				tunnel.session = tm.sharedServers[containerID].session
+				tunnel.router = router
+				continue
+			}
+		case tunnel.config.Type == TunnelTypeClient:
+			createErr = tm.createClientTunnel(tunnel)
+		case tunnel.config.Type == TunnelTypeServer:
+			createErr = tm.createServerTunnel(tunnel)
+		}
+		if createErr != nil {
+			// Keep serving from the old router rather than losing the tunnel. The
+			// tunnel holds a reference so the old session outlives the drain.
+			log.Printf("Warning: Failed to hand off tunnel %s: %v", name, createErr)
+			tunnel.session = previousSession
+			if old != nil && !tunnel.holdsRouter {
+				old.acquire()
+				tunnel.holdsRouter = true
+			}
+			continue
+		}
+
+		if sub, ok := previousSession.(interface{ Close() error }); ok && old != nil {
+			old.mutex.Lock()
+			old.subSessions = append(old.subSessions, sub)
+			old.mutex.Unlock()
+		}
+		tunnel.router = router
+	}
+
+	tm.containerSessions[containerID] = session
+	tm.containerSAMClients[containerID] = samClient
+	tm.containerRouters[containerID] = router
+
+	if old != nil {
+		tm.drainingRouters[containerID] = append(tm.pruneDrainingRouters(containerID), old)
+		old.drain()
+	}
+
+	log.Printf("Handed off container %s to SAM router %s", containerID, router.address)
+	return nil
+}
+
+// pruneDrainingRouters drops fully closed router sessions for a container.
+// The caller must hold tm.mutex.
+func (tm *TunnelManager) pruneDrainingRouters(containerID string) []*routerSession {
+	var remaining []*routerSession
+	for _, router := range tm.drainingRouters[containerID] {
+		if !router.isClosed() {
+			remaining = append(remaining, router)
+		}
+	}
+
+	if len(remaining) == 0 {
+		delete(tm.drainingRouters, containerID)
+	} else {
+		tm.drainingRouters[containerID] = remaining
+	}
+	return remaining
+}
+
+// ContainerRouters returns the router sessions held by a container.
+//
+// The active session is listed first, followed by any sessions still draining
+// after a failover.
+func (tm *TunnelManager) ContainerRouters(containerID string) []RouterSessionInfo {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	var infos []RouterSessionInfo
+
+	if router, exists := tm.containerRouters[containerID]; exists {
+		infos = append(infos, router.info())
+	}
+	for _, router := range tm.pruneDrainingRouters(containerID) {
+		infos = append(infos, router.info())
+	}
+
+	return infos
+}
+
+// closeDrainingRouters force-closes all draining sessions for a container.
+// The caller must hold tm.mutex.
+func (tm *TunnelManager) closeDrainingRouters(containerID string) {
+	for _, router := range tm.drainingRouters[containerID] {
+		router.close()
+	}
+	delete(tm.drainingRouters, containerID)
+}
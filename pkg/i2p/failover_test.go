@@ -0,0 +1,202 @@
+package i2p
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// closeCounter records Close calls for sessions in draining tests.
+type closeCounter struct {
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestRouterSessionDraining(t *testing.T) {
+	primary := &closeCounter{}
+	sub := &closeCounter{}
+	router := &routerSession{
+		address:     "127.0.0.1:7656",
+		primary:     primary,
+		subSessions: []interface{ Close() error }{sub},
+	}
+
+	client1, server1 := net.Pipe()
+	defer server1.Close()
+	client2, server2 := net.Pipe()
+	defer server2.Close()
+
+	conn1 := router.track(client1)
+	conn2 := router.track(client2)
+
+	if info := router.info(); info.ActiveStreams != 2 || info.Draining {
+		t.Fatalf("Unexpected router state before drain: %+v", info)
+	}
+
+	router.drain()
+	if router.isClosed() {
+		t.Fatal("Router closed while streams were still open")
+	}
+
+	conn1.Close()
+	conn1.Close() // Double close must not release twice
+	if router.isClosed() {
+		t.Fatal("Router closed before the last stream finished")
+	}
+	if info := router.info(); info.ActiveStreams != 1 || !info.Draining {
+		t.Errorf("Unexpected router state while draining: %+v", info)
+	}
+
+	conn2.Close()
+	if !router.isClosed() {
+		t.Fatal("Router not closed after the last stream finished")
+	}
+	if primary.closed != 1 || sub.closed != 1 {
+		t.Errorf("Expected sessions closed once, got primary=%d sub=%d", primary.closed, sub.closed)
+	}
+}
+
+func TestRouterSessionDrainIdle(t *testing.T) {
+	primary := &closeCounter{}
+	router := &routerSession{address: "127.0.0.1:7656", primary: primary}
+
+	router.drain()
+	if !router.isClosed() || primary.closed != 1 {
+		t.Error("Idle router should close immediately when drained")
+	}
+}
+
+func TestTunnelManagerRouterAddresses(t *testing.T) {
+	tm := NewTunnelManager(TunnelManagerOptions{SAM: &SAMConfig{
+		Host:    "localhost",
+		Port:    7656,
+		Timeout: time.Second,
+		Backups: []string{"10.0.0.2:7656", "10.0.0.3:7657"},
+	}})
+
+	addresses := tm.routerAddresses()
+	expected := []string{"localhost:7656", "10.0.0.2:7656", "10.0.0.3:7657"}
+	if len(addresses) != len(expected) {
+		t.Fatalf("Expected %d addresses, got %d", len(expected), len(addresses))
+	}
+	for i := range expected {
+		if addresses[i] != expected[i] {
+			t.Errorf("Address %d: expected %s, got %s", i, expected[i], addresses[i])
+		}
+	}
+
+	tm.activeRouter = 2
+	config, err := tm.activeSAMConfig()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.Host != "10.0.0.3" || config.Port != 7657 {
+		t.Errorf("Expected active router 10.0.0.3:7657, got %s:%d", config.Host, config.Port)
+	}
+	if config.Timeout != time.Second {
+		t.Errorf("Expected timeout to be inherited, got %v", config.Timeout)
+	}
+	if tm.samConfig.Host != "localhost" {
+		t.Error("activeSAMConfig must not modify the template configuration")
+	}
+}
+
+func TestTunnelManagerUpdateSAMConfig(t *testing.T) {
+	tm := NewTunnelManager(TunnelManagerOptions{SAM: DefaultSAMConfig()})
+	tm.activeRouter = 1
+
+	config := &SAMConfig{
+		Host:    "10.0.0.5",
+		Port:    7656,
+		Timeout: time.Second,
+		Backups: []string{"10.0.0.6:7656"},
+	}
+	if err := tm.UpdateSAMConfig(config); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tm.ActiveRouter() != "10.0.0.5:7656" {
+		t.Errorf("Expected active router reset to new primary, got %s", tm.ActiveRouter())
+	}
+
+	// The manager keeps its own copy
+	config.Backups[0] = "10.0.0.9:7656"
+	if tm.routerAddresses()[1] != "10.0.0.6:7656" {
+		t.Error("UpdateSAMConfig must copy the backup list")
+	}
+
+	if err := tm.UpdateSAMConfig(&SAMConfig{Port: 7656, Timeout: time.Second}); err == nil {
+		t.Error("Expected error for config without host")
+	}
+	if err := tm.UpdateSAMConfig(nil); err == nil {
+		t.Error("Expected error for nil config")
+	}
+	if tm.ActiveRouter() != "10.0.0.5:7656" {
+		t.Errorf("Failed update must not change the router, got %s", tm.ActiveRouter())
+	}
+}
+
+func TestTunnelManagerFailoverWithoutBackups(t *testing.T) {
+	tm := NewTunnelManager(TunnelManagerOptions{SAM: DefaultSAMConfig()})
+
+	if err := tm.Failover(); err == nil {
+		t.Error("Expected error when no backup routers are configured")
+	}
+}
+
+func TestTunnelManagerFailoverUnreachable(t *testing.T) {
+	// Reserve a port and close it so connections are refused
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	unreachable := listener.Addr().String()
+	listener.Close()
+
+	tm := NewTunnelManager(TunnelManagerOptions{SAM: &SAMConfig{
+		Host:    "localhost",
+		Port:    7656,
+		Timeout: time.Second,
+		Backups: []string{unreachable},
+	}})
+
+	if err := tm.Failover(); err == nil {
+		t.Error("Expected error when backup router is unreachable")
+	}
+	if tm.ActiveRouter() != "localhost:7656" {
+		t.Errorf("Active router should be unchanged, got %s", tm.ActiveRouter())
+	}
+}
+
+func TestContainerRoutersTracking(t *testing.T) {
+	tm := NewTunnelManager(TunnelManagerOptions{SAM: DefaultSAMConfig()})
+
+	active := &routerSession{address: "10.0.0.2:7656"}
+	draining := &routerSession{address: "localhost:7656", draining: true, streams: 1}
+	closed := &routerSession{address: "10.0.0.9:7656", closed: true}
+
+	tm.containerRouters["c1"] = active
+	tm.drainingRouters["c1"] = []*routerSession{draining, closed}
+
+	infos := tm.ContainerRouters("c1")
+	if len(infos) != 2 {
+		t.Fatalf("Expected 2 router sessions, got %d", len(infos))
+	}
+	if infos[0].Address != "10.0.0.2:7656" || infos[0].Draining {
+		t.Errorf("Expected active router first, got %+v", infos[0])
+	}
+	if infos[1].Address != "localhost:7656" || !infos[1].Draining || infos[1].ActiveStreams != 1 {
+		t.Errorf("Unexpected draining router info: %+v", infos[1])
+	}
+
+	tm.closeDrainingRouters("c1")
+	if !draining.isClosed() {
+		t.Error("Expected draining router to be force-closed")
+	}
+	if len(tm.ContainerRouters("c1")) != 1 {
+		t.Error("Expected only the active router after closing draining routers")
+	}
+}
@@ -0,0 +1,110 @@
+// Package i2p provides the SAM handshake probe.
+//
+// Sessions negotiate the SAM protocol with the bridge on their own, but the
+// outcome is not kept anywhere. ProbeSAM repeats the HELLO exchange on a
+// throwaway connection to the active router so the plugin can report which
+// bridge it talks to and which protocol version that bridge speaks. SAM has no
+// command that returns the router's own version; bridges that volunteer extra
+// fields in their HELLO reply have them preserved verbatim in the reply.
+package i2p
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// samProbeMinVersion is the lowest SAM version offered in the probe
+	samProbeMinVersion = "3.0"
+	// samProbeMaxVersion is the highest SAM version offered in the probe
+	samProbeMaxVersion = "3.3"
+)
+
+// SAMHandshake is the outcome of a HELLO exchange with a SAM bridge.
+type SAMHandshake struct {
+	// Bridge is the host:port of the SAM bridge
+	Bridge string `json:"bridge"`
+	// Version is the SAM protocol version the bridge selected
+	Version string `json:"version"`
+	// Reply is the bridge's complete HELLO reply
+	Reply string `json:"reply"`
+	// Latency is the time from dialing the bridge to receiving its reply
+	Latency time.Duration `json:"latency"`
+}
+
+// ProbeSAM performs a SAM handshake with the active router.
+func (tm *TunnelManager) ProbeSAM() (SAMHandshake, error) {
+	tm.mutex.Lock()
+	config, err := tm.activeSAMConfig()
+	tm.mutex.Unlock()
+	if err != nil {
+		return SAMHandshake{}, err
+	}
+	return probeSAM(config)
+}
+
+// probeSAM sends a HELLO to the bridge of config and parses its reply.
+func probeSAM(config *SAMConfig) (SAMHandshake, error) {
+	handshake := SAMHandshake{Bridge: net.JoinHostPort(config.Host, strconv.Itoa(config.Port))}
+
+	started := time.Now()
+	conn, err := net.DialTimeout("tcp", handshake.Bridge, config.Timeout)
+	if err != nil {
+		return handshake, fmt.Errorf("cannot reach SAM bridge at %s: %w", handshake.Bridge, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(started.Add(config.Timeout))
+
+	hello := fmt.Sprintf("HELLO VERSION MIN=%s MAX=%s", samProbeMinVersion, samProbeMaxVersion)
+	if config.Username != "" {
+		hello += fmt.Sprintf(" USER=%s PASSWORD=%s", config.Username, config.Password)
+	}
+	if _, err := fmt.Fprintf(conn, "%s\n", hello); err != nil {
+		return handshake, fmt.Errorf("failed to send HELLO to %s: %w", handshake.Bridge, err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return handshake, fmt.Errorf("failed to read HELLO reply from %s: %w", handshake.Bridge, err)
+	}
+	handshake.Latency = time.Since(started)
+	handshake.Reply = strings.TrimSpace(reply)
+
+	fields := samReplyFields(handshake.Reply)
+	if !strings.HasPrefix(handshake.Reply, "HELLO REPLY") || fields["RESULT"] != "OK" {
+		return handshake, fmt.Errorf("SAM bridge at %s refused the handshake: %s", handshake.Bridge, handshake.Reply)
+	}
+	handshake.Version = fields["VERSION"]
+	return handshake, nil
+}
+
+// samReplyFields returns the KEY=value fields of a SAM reply line. Quoted
+// values are unquoted.
+func samReplyFields(reply string) map[string]string {
+	fields := make(map[string]string)
+	for len(reply) > 0 {
+		reply = strings.TrimLeft(reply, " ")
+		end := strings.IndexByte(reply, ' ')
+		if end < 0 {
+			end = len(reply)
+		}
+		key, value, found := strings.Cut(reply[:end], "=")
+		if found && strings.HasPrefix(value, `"`) {
+			// Quoted values may contain spaces
+			closing := strings.IndexByte(reply[len(key)+2:], '"')
+			if closing >= 0 {
+				end = len(key) + 2 + closing + 1
+				value = reply[len(key)+2 : end-1]
+			}
+		}
+		if found {
+			fields[key] = value
+		}
+		reply = reply[end:]
+	}
+	return fields
+}
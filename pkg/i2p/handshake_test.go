@@ -0,0 +1,13 @@
+package i2p
+
+import "testing"
+
+func TestSAMReplyFields(t *testing.T) {
+	fields := samReplyFields(`HELLO REPLY RESULT=I2P_ERROR MESSAGE="bad credentials given" VERSION=3.3`)
+	if fields["RESULT"] != "I2P_ERROR" || fields["MESSAGE"] != "bad credentials given" || fields["VERSION"] != "3.3" {
+		t.Errorf("Unexpected fields %v", fields)
+	}
+	if _, ok := fields["HELLO"]; ok {
+		t.Errorf("Expected words without a value to be skipped, got %v", fields)
+	}
+}
@@ -0,0 +1,223 @@
+// Package i2p provides the mapping of tunnel options to SAM session options.
+//
+// Hosting many destinations on one router calls for the same levers
+// i2ptunnel users set per tunnel: tunnel pool sizes and lengths, shrinking the
+// pools of idle sessions, closing idle sessions and restricting tunnel peers.
+// TunnelOptions has typed fields for the common ones and passes further
+// I2CP, tunnel pool and streaming options through unchanged. Options can be
+// set as defaults for every session or overridden per network by name (the
+// JSON field names of TunnelOptions, or a passed-through option key). The
+// shorthands length, quantity and backups set both directions at once.
+//
+// Transport preferences such as NTCP2 or SSU2 are router-wide settings that
+// I2CP clients cannot change, so they cannot be passed through.
+package i2p
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// passthroughPrefixes are the prefixes of options that may be passed through
+// to the router in TunnelOptions.Extra.
+var passthroughPrefixes = []string{"i2cp.", "inbound.", "outbound.", "streaming."}
+
+// tunnelOptionFields maps the names of typed tunnel options to the setters
+// applying an override.
+var tunnelOptionFields = map[string]func(o *TunnelOptions, value string) error{
+	"inbound_tunnels":  intOption(func(o *TunnelOptions) *int { return &o.InboundTunnels }, 1),
+	"outbound_tunnels": intOption(func(o *TunnelOptions) *int { return &o.OutboundTunnels }, 1),
+	"inbound_length":   intOption(func(o *TunnelOptions) *int { return &o.InboundLength }, 0),
+	"outbound_length":  intOption(func(o *TunnelOptions) *int { return &o.OutboundLength }, 0),
+	"inbound_backups":  intOption(func(o *TunnelOptions) *int { return &o.InboundBackups }, 0),
+	"outbound_backups": intOption(func(o *TunnelOptions) *int { return &o.OutboundBackups }, 0),
+	"encrypt_leaseset": boolOption(func(o *TunnelOptions) *bool { return &o.EncryptLeaseset }),
+	"close_idle":       boolOption(func(o *TunnelOptions) *bool { return &o.CloseIdle }),
+	"close_idle_time":  intOption(func(o *TunnelOptions) *int { return &o.CloseIdleTime }, 1),
+	"reduce_idle":      boolOption(func(o *TunnelOptions) *bool { return &o.ReduceIdle }),
+	"reduce_idle_time": intOption(func(o *TunnelOptions) *int { return &o.ReduceIdleTime }, 1),
+	"reduce_quantity":  intOption(func(o *TunnelOptions) *int { return &o.ReduceQuantity }, 1),
+}
+
+// tunnelOptionShorthands maps shorthand option names to the typed options
+// they set in both directions.
+var tunnelOptionShorthands = map[string][2]string{
+	"length":   {"inbound_length", "outbound_length"},
+	"quantity": {"inbound_tunnels", "outbound_tunnels"},
+	"backups":  {"inbound_backups", "outbound_backups"},
+}
+
+// intOption returns a setter parsing an integer option of at least min.
+func intOption(field func(o *TunnelOptions) *int, min int) func(o *TunnelOptions, value string) error {
+	return func(o *TunnelOptions, value string) error {
+		parsed, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || parsed < min {
+			return fmt.Errorf("expected a whole number of at least %d", min)
+		}
+		*field(o) = parsed
+		return nil
+	}
+}
+
+// boolOption returns a setter parsing a boolean option.
+func boolOption(field func(o *TunnelOptions) *bool) func(o *TunnelOptions, value string) error {
+	return func(o *TunnelOptions, value string) error {
+		parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("expected true or false")
+		}
+		*field(o) = parsed
+		return nil
+	}
+}
+
+// TunnelOptionNames returns the names of the typed tunnel options and their
+// shorthands, sorted.
+func TunnelOptionNames() []string {
+	names := make([]string, 0, len(tunnelOptionFields)+len(tunnelOptionShorthands))
+	for name := range tunnelOptionFields {
+		names = append(names, name)
+	}
+	for name := range tunnelOptionShorthands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SAMOptions returns the options as SAM session options.
+//
+// Options only used while a feature is enabled are left out otherwise, and
+// passed-through options follow the typed ones, sorted by key.
+func (o TunnelOptions) SAMOptions() []string {
+	options := []string{
+		"inbound.quantity=" + strconv.Itoa(o.InboundTunnels),
+		"outbound.quantity=" + strconv.Itoa(o.OutboundTunnels),
+		"inbound.length=" + strconv.Itoa(o.InboundLength),
+		"outbound.length=" + strconv.Itoa(o.OutboundLength),
+		"inbound.backupQuantity=" + strconv.Itoa(o.InboundBackups),
+		"outbound.backupQuantity=" + strconv.Itoa(o.OutboundBackups),
+		"i2cp.encryptLeaseSet=" + strconv.FormatBool(o.EncryptLeaseset),
+		"i2cp.closeOnIdle=" + strconv.FormatBool(o.CloseIdle),
+		"i2cp.reduceOnIdle=" + strconv.FormatBool(o.ReduceIdle),
+	}
+	if o.CloseIdle {
+		options = append(options, "i2cp.closeIdleTime="+strconv.Itoa(o.CloseIdleTime*60*1000))
+	}
+	if o.ReduceIdle {
+		options = append(options,
+			"i2cp.reduceIdleTime="+strconv.Itoa(o.ReduceIdleTime*60*1000),
+			"i2cp.reduceQuantity="+strconv.Itoa(o.ReduceQuantity))
+	}
+
+	keys := make([]string, 0, len(o.Extra))
+	for key := range o.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		options = append(options, key+"="+o.Extra[key])
+	}
+	return options
+}
+
+// Equal reports whether two sets of options configure sessions alike.
+func (o TunnelOptions) Equal(other TunnelOptions) bool {
+	return o.InboundTunnels == other.InboundTunnels &&
+		o.OutboundTunnels == other.OutboundTunnels &&
+		o.InboundLength == other.InboundLength &&
+		o.OutboundLength == other.OutboundLength &&
+		o.InboundBackups == other.InboundBackups &&
+		o.OutboundBackups == other.OutboundBackups &&
+		o.EncryptLeaseset == other.EncryptLeaseset &&
+		o.CloseIdle == other.CloseIdle &&
+		o.CloseIdleTime == other.CloseIdleTime &&
+		o.ReduceIdle == other.ReduceIdle &&
+		o.ReduceIdleTime == other.ReduceIdleTime &&
+		o.ReduceQuantity == other.ReduceQuantity &&
+		maps.Equal(o.Extra, other.Extra)
+}
+
+// WithOverrides returns the options with overrides applied.
+//
+// Overrides are keyed by the JSON name of a typed option, e.g.
+// "reduce_idle", or by the key of an option passed through to the router,
+// e.g. "inbound.IPRestriction". A shorthand such as "length" sets both
+// directions, unless the override for a direction is given as well, which
+// then wins. The options are not modified.
+func (o TunnelOptions) WithOverrides(overrides map[string]string) (TunnelOptions, error) {
+	o.Extra = maps.Clone(o.Extra)
+
+	for name, value := range overrides {
+		targets, shorthand := tunnelOptionShorthands[name]
+		if !shorthand {
+			continue
+		}
+		for _, target := range targets {
+			if _, explicit := overrides[target]; explicit {
+				continue
+			}
+			if err := tunnelOptionFields[target](&o, value); err != nil {
+				return o, fmt.Errorf("invalid tunnel option %s=%q: %w", name, value, err)
+			}
+		}
+	}
+
+	for name, value := range overrides {
+		if _, shorthand := tunnelOptionShorthands[name]; shorthand {
+			continue
+		}
+		if set, typed := tunnelOptionFields[name]; typed {
+			if err := set(&o, value); err != nil {
+				return o, fmt.Errorf("invalid tunnel option %s=%q: %w", name, value, err)
+			}
+			continue
+		}
+		if err := validatePassthroughOption(name, value); err != nil {
+			return o, err
+		}
+		if o.Extra == nil {
+			o.Extra = make(map[string]string)
+		}
+		o.Extra[name] = value
+	}
+	return o, nil
+}
+
+// ValidateExtra checks the passed-through options.
+func (o TunnelOptions) ValidateExtra() error {
+	for key, value := range o.Extra {
+		if err := validatePassthroughOption(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validatePassthroughOption checks an option passed through to the router.
+//
+// Options set by typed fields are refused, so there is a single place each
+// option is configured in.
+func validatePassthroughOption(key, value string) error {
+	passthrough := false
+	for _, prefix := range passthroughPrefixes {
+		passthrough = passthrough || (strings.HasPrefix(key, prefix) && len(key) > len(prefix))
+	}
+	if !passthrough {
+		return fmt.Errorf("unknown tunnel option %s: expected one of %s or an option starting with %s",
+			key, strings.Join(TunnelOptionNames(), ", "), strings.Join(passthroughPrefixes, ", "))
+	}
+	if strings.ContainsAny(key+value, " \t\r\n\"=") {
+		return fmt.Errorf("invalid tunnel option %s=%q: keys and values must not contain spaces, quotes or '='", key, value)
+	}
+
+	for _, option := range (TunnelOptions{CloseIdle: true, ReduceIdle: true}).SAMOptions() {
+		if typed, _, _ := strings.Cut(option, "="); typed == key {
+			return fmt.Errorf("tunnel option %s is set by its typed option, not passed through", key)
+		}
+	}
+	return nil
+}
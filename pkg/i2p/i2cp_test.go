@@ -0,0 +1,116 @@
+package i2p
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTunnelOptionsSAMOptions(t *testing.T) {
+	options := DefaultTunnelOptions()
+	options.Extra = map[string]string{"outbound.IPRestriction": "1", "inbound.IPRestriction": "2"}
+
+	got := options.SAMOptions()
+	if slices.Contains(got, "i2cp.reduceQuantity=1") {
+		t.Errorf("Expected no reduce options while ReduceIdle is off, got %v", got)
+	}
+	if !slices.Contains(got, "i2cp.closeIdleTime=600000") {
+		t.Errorf("Expected close idle time in %v", got)
+	}
+	if tail := got[len(got)-2:]; tail[0] != "inbound.IPRestriction=2" || tail[1] != "outbound.IPRestriction=1" {
+		t.Errorf("Expected passed-through options last and sorted, got %v", tail)
+	}
+
+	options.ReduceIdle = true
+	got = options.SAMOptions()
+	for _, want := range []string{"i2cp.reduceOnIdle=true", "i2cp.reduceIdleTime=1200000", "i2cp.reduceQuantity=1"} {
+		if !slices.Contains(got, want) {
+			t.Errorf("Expected %s in %v", want, got)
+		}
+	}
+}
+
+func TestTunnelOptionsWithOverrides(t *testing.T) {
+	base := DefaultTunnelOptions()
+
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		check     func(o TunnelOptions) bool
+		wantErr   string
+	}{
+		{
+			name:      "typed options",
+			overrides: map[string]string{"inbound_tunnels": "5", "reduce_idle": "true"},
+			check:     func(o TunnelOptions) bool { return o.InboundTunnels == 5 && o.ReduceIdle },
+		},
+		{
+			name:      "shorthands",
+			overrides: map[string]string{"length": "2", "quantity": "3"},
+			check: func(o TunnelOptions) bool {
+				return o.InboundLength == 2 && o.OutboundLength == 2 && o.InboundTunnels == 3 && o.OutboundTunnels == 3
+			},
+		},
+		{
+			name:      "direction wins over shorthand",
+			overrides: map[string]string{"backups": "2", "outbound_backups": "0"},
+			check:     func(o TunnelOptions) bool { return o.InboundBackups == 2 && o.OutboundBackups == 0 },
+		},
+		{
+			name:      "invalid shorthand",
+			overrides: map[string]string{"quantity": "0"},
+			wantErr:   "invalid tunnel option quantity",
+		},
+		{
+			name:      "passthrough option",
+			overrides: map[string]string{"streaming.maxConnsPerMinute": "30"},
+			check:     func(o TunnelOptions) bool { return o.Extra["streaming.maxConnsPerMinute"] == "30" },
+		},
+		{
+			name:      "invalid number",
+			overrides: map[string]string{"outbound_length": "-1"},
+			wantErr:   "at least 0",
+		},
+		{
+			name:      "invalid bool",
+			overrides: map[string]string{"close_idle": "sometimes"},
+			wantErr:   "true or false",
+		},
+		{
+			name:      "unknown option",
+			overrides: map[string]string{"ntcp.enable": "false"},
+			wantErr:   "unknown tunnel option",
+		},
+		{
+			name:      "typed option passed through",
+			overrides: map[string]string{"inbound.quantity": "3"},
+			wantErr:   "set by its typed option",
+		},
+		{
+			name:      "value with spaces",
+			overrides: map[string]string{"i2cp.leaseSetType": "3 4"},
+			wantErr:   "must not contain",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := base.WithOverrides(tt.overrides)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("WithOverrides() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("WithOverrides() unexpected error: %v", err)
+			}
+			if !tt.check(got) {
+				t.Errorf("WithOverrides() = %+v", got)
+			}
+			if !base.Equal(DefaultTunnelOptions()) {
+				t.Error("WithOverrides() modified the base options")
+			}
+		})
+	}
+}
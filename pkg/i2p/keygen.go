@@ -0,0 +1,356 @@
+// Package i2p provides observability of destination key generation and a
+// pool of keys generated ahead of time.
+//
+// Every container session that is not created with saved keys asks the
+// router for a new destination (DEST GENERATE). Generating one draws on the
+// router host's entropy pool, and on minimal VMs short of entropy it has been
+// seen to take minutes, stalling the container's Join all the while. The
+// tunnel manager therefore times every generation, overall and per container,
+// counts failures, and logs a warning when a generation takes longer than the
+// configured threshold. Operators on such hosts can keep a pool of keys
+// generated in the background; sessions take their keys from the pool and
+// only ask the router when it is empty.
+package i2p
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+const (
+	// DefaultSlowKeyGeneration is the duration after which a key generation
+	// is logged as slow
+	DefaultSlowKeyGeneration = 10 * time.Second
+	// MaxKeyPoolSize is the largest number of keys kept generated ahead
+	MaxKeyPoolSize = 64
+)
+
+// maxKeyGenerationContainers bounds the containers whose last key
+// generation is remembered.
+const maxKeyGenerationContainers = 4096
+
+// KeyGenerationConfig configures how destination keys are generated.
+type KeyGenerationConfig struct {
+	// PoolSize is the number of keys kept generated ahead of time (0
+	// disables the pool)
+	PoolSize int
+	// SlowThreshold is the duration after which a generation is logged as
+	// slow (0 disables the warning)
+	SlowThreshold time.Duration
+}
+
+// DefaultKeyGenerationConfig returns the default key generation settings:
+// no pool, and warnings for generations slower than DefaultSlowKeyGeneration.
+func DefaultKeyGenerationConfig() KeyGenerationConfig {
+	return KeyGenerationConfig{SlowThreshold: DefaultSlowKeyGeneration}
+}
+
+// Validate checks the key generation settings.
+func (c KeyGenerationConfig) Validate() error {
+	if c.PoolSize < 0 || c.PoolSize > MaxKeyPoolSize {
+		return fmt.Errorf("key pool size must be between 0 and %d, got %d", MaxKeyPoolSize, c.PoolSize)
+	}
+	if c.SlowThreshold < 0 {
+		return fmt.Errorf("slow key generation threshold cannot be negative, got %s", c.SlowThreshold)
+	}
+	return nil
+}
+
+// KeyGenerationStats counts destination key generations.
+type KeyGenerationStats struct {
+	// Generated counts the keys generated by the router
+	Generated int64 `json:"generated"`
+	// Failed counts the generations that failed
+	Failed int64 `json:"failed"`
+	// Slow counts the generations, failed or not, that took longer than the threshold
+	Slow int64 `json:"slow"`
+	// Total is the time spent generating keys
+	Total time.Duration `json:"total"`
+	// Slowest is the longest generation
+	Slowest time.Duration `json:"slowest"`
+	// PoolSize is the number of keys the pool is kept at
+	PoolSize int `json:"pool_size"`
+	// Pooled is the number of keys in the pool
+	Pooled int `json:"pooled"`
+	// PoolHits counts sessions that took their keys from the pool
+	PoolHits int64 `json:"pool_hits"`
+	// PoolMisses counts sessions that found the pool empty
+	PoolMisses int64 `json:"pool_misses"`
+}
+
+// ContainerKeyGeneration describes the key generations of a container.
+type ContainerKeyGeneration struct {
+	// Duration is how long the last successful generation took; zero for
+	// keys taken from the pool
+	Duration time.Duration `json:"duration"`
+	// Pooled reports whether the last keys were taken from the pool
+	Pooled bool `json:"pooled"`
+	// Failures counts the container's failed generations
+	Failures int `json:"failures"`
+	// LastError is the error of the last failed generation
+	LastError string `json:"last_error,omitempty"`
+}
+
+// keyGeneration times key generations and holds the key pool. It has a
+// lock of its own, so the pool can be filled without holding the manager's.
+type keyGeneration struct {
+	mutex      sync.Mutex
+	config     KeyGenerationConfig
+	pool       []i2pkeys.I2PKeys
+	filling    bool
+	stats      KeyGenerationStats
+	containers map[string]ContainerKeyGeneration
+}
+
+// newKeyGeneration creates key generation state with the default settings.
+func newKeyGeneration() *keyGeneration {
+	return &keyGeneration{
+		config:     DefaultKeyGenerationConfig(),
+		containers: make(map[string]ContainerKeyGeneration),
+	}
+}
+
+// setConfig replaces the settings, dropping pooled keys beyond the new size.
+func (g *keyGeneration) setConfig(config KeyGenerationConfig) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.config = config
+	if len(g.pool) > config.PoolSize {
+		g.pool = g.pool[:config.PoolSize]
+	}
+}
+
+// take removes keys from the pool. Without a pool it always fails and
+// counts nothing.
+func (g *keyGeneration) take(containerID string) (i2pkeys.I2PKeys, bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.config.PoolSize == 0 {
+		return i2pkeys.I2PKeys{}, false
+	}
+	if len(g.pool) == 0 {
+		g.stats.PoolMisses++
+		return i2pkeys.I2PKeys{}, false
+	}
+
+	keys := g.pool[len(g.pool)-1]
+	g.pool = g.pool[:len(g.pool)-1]
+	g.stats.PoolHits++
+	g.rememberLocked(containerID, func(c *ContainerKeyGeneration) {
+		c.Duration = 0
+		c.Pooled = true
+	})
+	return keys, true
+}
+
+// record accounts for a generation that took duration, on behalf of
+// containerID or of the pool if containerID is empty.
+func (g *keyGeneration) record(containerID string, duration time.Duration, err error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	g.stats.Total += duration
+	if duration > g.stats.Slowest {
+		g.stats.Slowest = duration
+	}
+	if threshold := g.config.SlowThreshold; threshold > 0 && duration >= threshold {
+		g.stats.Slow++
+		owner := "container " + containerID
+		if containerID == "" {
+			owner = "the key pool"
+		}
+		log.Printf("Warning: Generating I2P keys for %s took %s (threshold %s); the router host may be short of entropy, consider KEY_POOL_SIZE",
+			owner, duration.Round(time.Millisecond), threshold)
+	}
+
+	if err != nil {
+		g.stats.Failed++
+		g.rememberLocked(containerID, func(c *ContainerKeyGeneration) {
+			c.Failures++
+			c.LastError = err.Error()
+		})
+		return
+	}
+
+	g.stats.Generated++
+	g.rememberLocked(containerID, func(c *ContainerKeyGeneration) {
+		c.Duration = duration
+		c.Pooled = false
+	})
+}
+
+// rememberLocked updates the record of a container. Generations for the
+// pool are not recorded, and neither are new containers beyond
+// maxKeyGenerationContainers. The caller must hold g.mutex.
+func (g *keyGeneration) rememberLocked(containerID string, update func(*ContainerKeyGeneration)) {
+	if containerID == "" {
+		return
+	}
+	record, exists := g.containers[containerID]
+	if !exists && len(g.containers) >= maxKeyGenerationContainers {
+		return
+	}
+	update(&record)
+	g.containers[containerID] = record
+}
+
+// forget drops the record of a container.
+func (g *keyGeneration) forget(containerID string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	delete(g.containers, containerID)
+}
+
+// startFill reports how many keys the pool is missing and marks it as being
+// filled; it returns 0 if the pool is full or already being filled.
+func (g *keyGeneration) startFill() int {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	missing := g.config.PoolSize - len(g.pool)
+	if missing <= 0 || g.filling {
+		return 0
+	}
+	g.filling = true
+	return missing
+}
+
+// add puts generated keys into the pool, reporting false if the pool shrank
+// meanwhile and has no room for them.
+func (g *keyGeneration) add(keys i2pkeys.I2PKeys) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if len(g.pool) >= g.config.PoolSize {
+		return false
+	}
+	g.pool = append(g.pool, keys)
+	return true
+}
+
+// endFill marks the pool as no longer being filled.
+func (g *keyGeneration) endFill() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.filling = false
+}
+
+// snapshot returns the counters and pool occupancy.
+func (g *keyGeneration) snapshot() KeyGenerationStats {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	stats := g.stats
+	stats.PoolSize = g.config.PoolSize
+	stats.Pooled = len(g.pool)
+	return stats
+}
+
+// SetKeyGeneration configures the key pool and the slow generation warning.
+//
+// May be called while the manager is in use. Shrinking the pool discards the
+// surplus keys; growing it takes effect with the next FillKeyPool.
+func (tm *TunnelManager) SetKeyGeneration(config KeyGenerationConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	tm.keygen.setConfig(config)
+	return nil
+}
+
+// KeyGeneration returns the key generation settings.
+func (tm *TunnelManager) KeyGeneration() KeyGenerationConfig {
+	tm.keygen.mutex.Lock()
+	defer tm.keygen.mutex.Unlock()
+	return tm.keygen.config
+}
+
+// KeyGenerationStats returns how many keys were generated, how long that
+// took and how the pool is used.
+func (tm *TunnelManager) KeyGenerationStats() KeyGenerationStats {
+	return tm.keygen.snapshot()
+}
+
+// ContainerKeyGenerations returns the key generations of the containers
+// that generated keys since their session was last destroyed, by container ID.
+func (tm *TunnelManager) ContainerKeyGenerations() map[string]ContainerKeyGeneration {
+	tm.keygen.mutex.Lock()
+	defer tm.keygen.mutex.Unlock()
+
+	containers := make(map[string]ContainerKeyGeneration, len(tm.keygen.containers))
+	for containerID, record := range tm.keygen.containers {
+		containers[containerID] = record
+	}
+	return containers
+}
+
+// FillKeyPool generates keys on the active router until the pool holds its
+// configured size, returning the number of keys added.
+//
+// Keys are generated over a SAM connection of their own and without holding
+// the manager's lock, so sessions are not held up meanwhile. Only one fill
+// runs at a time; concurrent calls return right away.
+func (tm *TunnelManager) FillKeyPool() (int, error) {
+	missing := tm.keygen.startFill()
+	if missing == 0 {
+		return 0, nil
+	}
+	defer tm.keygen.endFill()
+
+	tm.mutex.Lock()
+	samConfig, err := tm.activeSAMConfig()
+	tm.mutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	samClient, err := NewSAMClient(samConfig)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create SAM client for the key pool: %w", err)
+	}
+	if err := samClient.Connect(context.Background()); err != nil {
+		return 0, fmt.Errorf("failed to connect SAM client for the key pool: %w", err)
+	}
+	defer samClient.Disconnect()
+
+	for added := 0; added < missing; added++ {
+		tm.pacer.wait()
+		start := time.Now()
+		keys, err := samClient.sam.NewKeys()
+		tm.keygen.record("", time.Since(start), err)
+		if err != nil {
+			return added, fmt.Errorf("failed to generate I2P keys for the key pool: %w", err)
+		}
+		if !tm.keygen.add(keys) {
+			return added, nil
+		}
+	}
+	return missing, nil
+}
+
+// generateSessionKeys returns keys for a new session of containerID, taken
+// from the pool or generated on samClient's router. The caller must hold
+// tm.mutex.
+func (tm *TunnelManager) generateSessionKeys(containerID string, samClient *SAMClient) (i2pkeys.I2PKeys, error) {
+	if keys, ok := tm.keygen.take(containerID); ok {
+		log.Printf("Using pre-generated I2P keys for container %s", containerID)
+		return keys, nil
+	}
+
+	tm.pacer.wait()
+	start := time.Now()
+	keys, err := samClient.sam.NewKeys()
+	duration := time.Since(start)
+	tm.keygen.record(containerID, duration, err)
+	if err == nil {
+		log.Printf("DEBUG: Generated new I2P keys for container %s in %s", containerID, duration.Round(time.Millisecond))
+	}
+	return keys, err
+}
@@ -0,0 +1,107 @@
+package i2p
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeyGenerationConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  KeyGenerationConfig
+		wantErr bool
+	}{
+		{name: "defaults", config: DefaultKeyGenerationConfig()},
+		{name: "pool", config: KeyGenerationConfig{PoolSize: MaxKeyPoolSize}},
+		{name: "negative pool", config: KeyGenerationConfig{PoolSize: -1}, wantErr: true},
+		{name: "oversized pool", config: KeyGenerationConfig{PoolSize: MaxKeyPoolSize + 1}, wantErr: true},
+		{name: "negative threshold", config: KeyGenerationConfig{SlowThreshold: -time.Second}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestKeyGeneration_Record(t *testing.T) {
+	g := newKeyGeneration()
+	g.setConfig(KeyGenerationConfig{SlowThreshold: time.Second})
+
+	g.record("fast", 10*time.Millisecond, nil)
+	g.record("slow", 2*time.Second, nil)
+	g.record("slow", 3*time.Second, errTestKeyGeneration)
+	g.record("", 5*time.Second, nil)
+
+	stats := g.snapshot()
+	if stats.Generated != 3 || stats.Failed != 1 || stats.Slow != 3 || stats.Slowest != 5*time.Second {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if stats.Total != 10*time.Millisecond+10*time.Second {
+		t.Errorf("Expected the total to include every generation, got %s", stats.Total)
+	}
+
+	if len(g.containers) != 2 {
+		t.Fatalf("Expected records for both containers and none for the pool, got %+v", g.containers)
+	}
+	if record := g.containers["slow"]; record.Duration != 2*time.Second || record.Failures != 1 || record.LastError == "" {
+		t.Errorf("Unexpected record %+v", record)
+	}
+
+	g.forget("slow")
+	if _, exists := g.containers["slow"]; exists {
+		t.Errorf("Expected the record to be forgotten")
+	}
+}
+
+func TestKeyPoolOverFakeSAM(t *testing.T) {
+	t.Parallel()
+
+	tm := NewTunnelManager(TunnelManagerOptions{SAM: startFakeSAM(t)})
+	defer tm.DestroyAllTunnels()
+
+	if err := tm.SetKeyGeneration(KeyGenerationConfig{PoolSize: 2}); err != nil {
+		t.Fatalf("SetKeyGeneration() unexpected error: %v", err)
+	}
+	added, err := tm.FillKeyPool()
+	if err != nil || added != 2 {
+		t.Fatalf("FillKeyPool() = %d, %v; expected 2 keys", added, err)
+	}
+	if added, _ := tm.FillKeyPool(); added != 0 {
+		t.Errorf("Expected a full pool not to be filled again, added %d", added)
+	}
+
+	if _, err := tm.GetOrCreateContainerSession("app"); err != nil {
+		t.Fatalf("GetOrCreateContainerSession() unexpected error: %v", err)
+	}
+	stats := tm.KeyGenerationStats()
+	if stats.PoolHits != 1 || stats.Pooled != 1 || stats.Generated != 2 {
+		t.Errorf("Expected the session to take pooled keys, got %+v", stats)
+	}
+	if record := tm.ContainerKeyGenerations()["app"]; !record.Pooled {
+		t.Errorf("Expected the container's keys to be recorded as pooled, got %+v", record)
+	}
+
+	// Shrinking the pool drops the surplus and sessions generate their own keys
+	if err := tm.SetKeyGeneration(KeyGenerationConfig{}); err != nil {
+		t.Fatalf("SetKeyGeneration() unexpected error: %v", err)
+	}
+	if _, err := tm.GetOrCreateContainerSession("other"); err != nil {
+		t.Fatalf("GetOrCreateContainerSession() unexpected error: %v", err)
+	}
+	stats = tm.KeyGenerationStats()
+	if stats.Pooled != 0 || stats.Generated != 3 || stats.PoolMisses != 0 {
+		t.Errorf("Expected keys generated without a pool, got %+v", stats)
+	}
+	if record := tm.ContainerKeyGenerations()["other"]; record.Pooled || record.Duration <= 0 {
+		t.Errorf("Expected the generation to be timed, got %+v", record)
+	}
+
+	if err := tm.SetKeyGeneration(KeyGenerationConfig{PoolSize: -1}); err == nil {
+		t.Errorf("Expected an invalid pool size to be rejected")
+	}
+}
+
+var errTestKeyGeneration = errors.New("entropy exhausted")
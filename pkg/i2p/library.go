@@ -0,0 +1,172 @@
+// Package i2p provides tunnels created directly by library users.
+//
+// The plugin creates tunnels while it follows containers' exposures and
+// endpoints, but the tunnel manager does not depend on Docker. Other Go
+// programs can create a TunnelManager and open tunnels with the methods in
+// this file: client tunnels bound to a remote destination, and server
+// tunnels serving a destination whose keys the caller already holds, so the
+// service keeps its address across restarts and SAM router failover.
+//
+// Tunnels belong to an owner, any ID the caller chooses (the plugin uses
+// container IDs). All tunnels of an owner share one primary session and
+// therefore one destination; the owner's session is closed with
+// DestroyContainerSession once its tunnels are gone.
+package i2p
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/go-i2p/i2pkeys"
+)
+
+// ParseKeys builds destination keys from a base64 public destination and the
+// base64 private key blob I2P routers and SAM bridges hand out, which starts
+// with the public destination.
+func ParseKeys(public, private string) (i2pkeys.I2PKeys, error) {
+	addr, err := i2pkeys.NewI2PAddrFromString(strings.TrimSpace(public))
+	if err != nil {
+		return i2pkeys.I2PKeys{}, fmt.Errorf("invalid public destination: %w", err)
+	}
+	keys := i2pkeys.NewKeys(addr, strings.TrimSpace(private))
+	if err := validateKeys(keys); err != nil {
+		return i2pkeys.I2PKeys{}, err
+	}
+	return keys, nil
+}
+
+// validateKeys checks that the private key blob belongs to the destination.
+//
+// The blob starts with the destination's bytes. Destinations are rarely a
+// multiple of three bytes long, so the base64 strings are compared decoded.
+func validateKeys(keys i2pkeys.I2PKeys) error {
+	if keys.Addr().Base64() == "" {
+		return fmt.Errorf("keys have no public destination")
+	}
+	public, err := keys.Addr().ToBytes()
+	if err != nil {
+		return fmt.Errorf("invalid public destination: %w", err)
+	}
+	private, err := i2pkeys.I2PAddr(keys.String()).ToBytes()
+	if err != nil || len(private) <= len(public) || !bytes.HasPrefix(private, public) {
+		return fmt.Errorf("private keys do not belong to destination %s", keys.Addr().Base32())
+	}
+	return nil
+}
+
+// CreateClientTunnelTo creates a client tunnel of owner bound to a remote
+// destination, which Connect dials.
+//
+// The destination may be a .i2p hostname, a .b32.i2p address or a full base64
+// destination. port is the local port the tunnel's sub-session is bound to
+// and must be unique among the owner's client tunnels.
+func (tm *TunnelManager) CreateClientTunnelTo(owner, destination string, port int) (*Tunnel, error) {
+	destination = strings.TrimSpace(destination)
+	if destination == "" {
+		return nil, fmt.Errorf("destination cannot be empty")
+	}
+
+	purpose := fmt.Sprintf("client-%d", port)
+	return tm.CreateTunnel(&TunnelConfig{
+		Name:        TunnelName(owner, purpose),
+		ContainerID: owner,
+		Purpose:     purpose,
+		Type:        TunnelTypeClient,
+		LocalPort:   port,
+		Destination: destination,
+	})
+}
+
+// CreateServerTunnelWithKeys creates a server tunnel of owner serving the
+// destination of keys on I2P port port. Inbound streams are accepted with
+// the tunnel's Listen method.
+//
+// The owner's session is created with the keys. If the owner already has a
+// session, its destination must match them.
+func (tm *TunnelManager) CreateServerTunnelWithKeys(owner string, keys i2pkeys.I2PKeys, port int) (*Tunnel, error) {
+	if err := validateKeys(keys); err != nil {
+		return nil, fmt.Errorf("invalid keys: %w", err)
+	}
+	if err := tm.setSessionKeys(owner, keys); err != nil {
+		return nil, err
+	}
+
+	purpose := fmt.Sprintf("server-%d", port)
+	tunnel, err := tm.CreateTunnel(&TunnelConfig{
+		Name:        TunnelName(owner, purpose),
+		ContainerID: owner,
+		Purpose:     purpose,
+		Type:        TunnelTypeServer,
+		LocalPort:   port,
+	})
+	if err != nil {
+		// Keys of a session that was never created must not pin the owner
+		tm.mutex.Lock()
+		if _, exists := tm.containerSessions[owner]; !exists {
+			delete(tm.sessionKeys, owner)
+		}
+		tm.mutex.Unlock()
+		return nil, err
+	}
+	return tunnel, nil
+}
+
+// setSessionKeys records the keys owner's session is created with.
+func (tm *TunnelManager) setSessionKeys(owner string, keys i2pkeys.I2PKeys) error {
+	if owner == "" {
+		return fmt.Errorf("container ID cannot be empty")
+	}
+
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	if session, exists := tm.containerSessions[owner]; exists {
+		if session.Addr().Base64() != keys.Addr().Base64() {
+			return fmt.Errorf("%s already has a session with destination %s", owner, session.Addr().Base32())
+		}
+		return nil
+	}
+	if current, exists := tm.sessionKeys[owner]; exists && current.Addr().Base64() != keys.Addr().Base64() {
+		return fmt.Errorf("%s already has a session with destination %s", owner, current.Addr().Base32())
+	}
+
+	if tm.sessionKeys == nil {
+		tm.sessionKeys = make(map[string]i2pkeys.I2PKeys)
+	}
+	tm.sessionKeys[owner] = keys
+	return nil
+}
+
+// AdoptSessionKeys makes owner's session use keys when it is created, so its
+// tunnels serve the destination of keys. It fails if owner already has a
+// session or supplied keys with another destination.
+func (tm *TunnelManager) AdoptSessionKeys(owner string, keys i2pkeys.I2PKeys) error {
+	if err := validateKeys(keys); err != nil {
+		return fmt.Errorf("invalid keys: %w", err)
+	}
+	return tm.setSessionKeys(owner, keys)
+}
+
+// SessionKeys returns the keys of owner's session, or the keys supplied for
+// it if the session has not been created yet.
+func (tm *TunnelManager) SessionKeys(owner string) (i2pkeys.I2PKeys, bool) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	if session, exists := tm.containerSessions[owner]; exists {
+		return session.Keys(), true
+	}
+	keys, supplied := tm.sessionKeys[owner]
+	return keys, supplied
+}
+
+// Connect opens an outbound I2P stream to the destination the tunnel was
+// created for.
+func (t *Tunnel) Connect() (net.Conn, error) {
+	if t.config.Destination == "" {
+		return nil, fmt.Errorf("tunnel %s has no destination", t.config.Name)
+	}
+	return t.Dial(t.config.Destination)
+}
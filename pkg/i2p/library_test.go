@@ -0,0 +1,178 @@
+package i2p
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/fakesam"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/testutil"
+	"github.com/go-i2p/i2pkeys"
+)
+
+// testKeys returns well-formed keys for a fake destination.
+func testKeys(fill string) i2pkeys.I2PKeys {
+	public := strings.Repeat(fill, 516)
+	return i2pkeys.NewKeys(i2pkeys.I2PAddr(public), public+"AAAA")
+}
+
+func TestParseKeys(t *testing.T) {
+	public := strings.Repeat("A", 516)
+
+	keys, err := ParseKeys(" "+public+"\n", public+"BBBB\n")
+	if err != nil {
+		t.Fatalf("ParseKeys() unexpected error: %v", err)
+	}
+	if keys.Addr().Base64() != public || keys.String() != public+"BBBB" {
+		t.Errorf("Unexpected keys %s / %s", keys.Addr().Base64(), keys.String())
+	}
+
+	if _, err := ParseKeys("short", public+"BBBB"); err == nil {
+		t.Error("Expected error for an invalid public destination")
+	}
+	if _, err := ParseKeys(public, strings.Repeat("B", 520)); err == nil {
+		t.Error("Expected error for private keys of another destination")
+	}
+	if _, err := ParseKeys(public, public); err == nil {
+		t.Error("Expected error for private keys without the private part")
+	}
+}
+
+func TestSessionKeys(t *testing.T) {
+	tm := NewTunnelManager(TunnelManagerOptions{})
+
+	if err := tm.setSessionKeys("", testKeys("A")); err == nil {
+		t.Error("Expected error for an empty owner")
+	}
+	if err := tm.setSessionKeys("app", testKeys("A")); err != nil {
+		t.Fatalf("setSessionKeys() unexpected error: %v", err)
+	}
+	if err := tm.setSessionKeys("app", testKeys("A")); err != nil {
+		t.Errorf("Expected the same keys to be accepted again, got %v", err)
+	}
+	if err := tm.setSessionKeys("app", testKeys("B")); err == nil {
+		t.Error("Expected keys of another destination to be refused")
+	}
+
+	// Supplied keys are forgotten with the owner's session
+	if err := tm.DestroyContainerSession("app"); err != nil {
+		t.Fatalf("DestroyContainerSession() unexpected error: %v", err)
+	}
+	if err := tm.setSessionKeys("app", testKeys("B")); err != nil {
+		t.Errorf("Expected new keys after the session was destroyed, got %v", err)
+	}
+}
+
+func TestAdoptSessionKeys(t *testing.T) {
+	tm := NewTunnelManager(TunnelManagerOptions{})
+
+	if err := tm.AdoptSessionKeys("app", i2pkeys.I2PKeys{}); err == nil {
+		t.Error("Expected empty keys to be refused")
+	}
+	if _, exists := tm.SessionKeys("app"); exists {
+		t.Error("Expected no keys before any were adopted")
+	}
+	if err := tm.AdoptSessionKeys("app", testKeys("A")); err != nil {
+		t.Fatalf("AdoptSessionKeys() unexpected error: %v", err)
+	}
+	if keys, exists := tm.SessionKeys("app"); !exists || keys.String() != testKeys("A").String() {
+		t.Errorf("Expected the adopted keys, got %q (exists %v)", keys.String(), exists)
+	}
+}
+
+func TestLibraryTunnelValidation(t *testing.T) {
+	tm := NewTunnelManager(TunnelManagerOptions{})
+
+	if _, err := tm.CreateClientTunnelTo("app", "  ", 8080); err == nil {
+		t.Error("Expected error for an empty destination")
+	}
+	if _, err := tm.CreateClientTunnelTo("", "forum.i2p", 8080); err == nil {
+		t.Error("Expected error for an empty owner")
+	}
+	if _, err := tm.CreateServerTunnelWithKeys("app", i2pkeys.I2PKeys{}, 80); err == nil {
+		t.Error("Expected error for empty keys")
+	}
+
+	// Keys of a session that could not be created do not pin the owner
+	if _, err := tm.CreateServerTunnelWithKeys("app", testKeys("A"), 0); err == nil {
+		t.Fatal("Expected error for an invalid port")
+	}
+	if err := tm.setSessionKeys("app", testKeys("B")); err != nil {
+		t.Errorf("Expected the failed tunnel's keys to be forgotten, got %v", err)
+	}
+}
+
+func TestTunnelConnectWithoutDestination(t *testing.T) {
+	tunnel := &Tunnel{config: &TunnelConfig{Name: "test", Type: TunnelTypeClient}, active: true}
+	if _, err := tunnel.Connect(); err == nil || !strings.Contains(err.Error(), "no destination") {
+		t.Errorf("Expected missing destination error, got %v", err)
+	}
+}
+
+func TestLibraryTunnelsOverFakeSAM(t *testing.T) {
+	server := testutil.StartFakeSAM(t, fakesam.ServerOptions{})
+	tm := NewTunnelManager(TunnelManagerOptions{SAM: fakeSAMConfig(server)})
+	defer tm.DestroyAllTunnels()
+
+	// Keys shaped like a router's are accepted and keep their destination
+	public, private := server.NewKeys()
+	keys, err := ParseKeys(public, private)
+	if err != nil {
+		t.Fatalf("ParseKeys() unexpected error: %v", err)
+	}
+	service, err := tm.CreateServerTunnelWithKeys("service", keys, 80)
+	if err != nil {
+		t.Fatalf("Failed to create server tunnel: %v", err)
+	}
+	if service.GetDestination() != public {
+		t.Errorf("Expected the server tunnel to serve the supplied destination")
+	}
+
+	// Echo a line on the server tunnel's sub-session
+	go func() {
+		conn, err := net.Dial("tcp", server.Addr())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		io.WriteString(conn, "HELLO VERSION MIN=3.1 MAX=3.3\n")
+		reader.ReadString('\n')
+		io.WriteString(conn, "STREAM ACCEPT ID="+service.GetConfig().Name+"-server-port80 SILENT=false\n")
+		reader.ReadString('\n')
+		reader.ReadString('\n')
+		line, _ := reader.ReadString('\n')
+		io.WriteString(conn, "echo: "+line)
+	}()
+
+	client, err := tm.CreateClientTunnelTo("client", keys.Addr().Base32(), 80)
+	if err != nil {
+		t.Fatalf("Failed to create client tunnel: %v", err)
+	}
+	conn, err := client.Connect()
+	if err != nil {
+		t.Fatalf("Connect() unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	io.WriteString(conn, "hello\n")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if reply, err := bufio.NewReader(conn).ReadString('\n'); err != nil || reply != "echo: hello\n" {
+		t.Errorf("Unexpected reply %q, %v", reply, err)
+	}
+
+	// Hostnames resolve through the server's address book
+	if err := server.AddHost("service.i2p", public); err != nil {
+		t.Fatalf("AddHost() unexpected error: %v", err)
+	}
+	if resolved, err := tm.LookupName("service.i2p"); err != nil || resolved != public {
+		t.Errorf("LookupName() = %.16s..., %v; want the service destination", resolved, err)
+	}
+	if _, err := tm.LookupName("missing.i2p"); !errors.Is(err, ErrNameNotFound) {
+		t.Errorf("Expected ErrNameNotFound, got %v", err)
+	}
+}
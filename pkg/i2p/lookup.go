@@ -0,0 +1,55 @@
+// Package i2p provides I2P hostname lookups through the SAM bridge.
+//
+// Lookups use SAM's NAMING LOOKUP command on the active router, which
+// consults the router's address book and, for b32 addresses, the network
+// database.
+package i2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	sam3 "github.com/go-i2p/go-sam-go"
+)
+
+// ErrNameNotFound is returned by LookupName when the router does not know a name.
+var ErrNameNotFound = errors.New("I2P name not found")
+
+// LookupName resolves an I2P hostname to its base64 destination.
+//
+// Each lookup uses a short-lived connection to the active SAM router so
+// lookups keep working across failovers.
+func (tm *TunnelManager) LookupName(name string) (string, error) {
+	tm.mutex.Lock()
+	samConfig, err := tm.activeSAMConfig()
+	tm.mutex.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	samClient, err := NewSAMClient(samConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to create SAM client for lookup: %w", err)
+	}
+	if err := samClient.Connect(context.Background()); err != nil {
+		return "", fmt.Errorf("failed to connect SAM client for lookup: %w", err)
+	}
+	defer samClient.Disconnect()
+
+	resolver, err := sam3.NewSAMResolver(samClient.sam)
+	if err != nil {
+		return "", fmt.Errorf("failed to create SAM resolver: %w", err)
+	}
+
+	addr, err := resolver.Resolve(name)
+	if err != nil {
+		// The router reports unknown names as KEY_NOT_FOUND
+		if strings.Contains(err.Error(), "Unable to resolve") {
+			return "", fmt.Errorf("%w: %s", ErrNameNotFound, name)
+		}
+		return "", fmt.Errorf("failed to look up %s: %w", name, err)
+	}
+	return addr.Base64(), nil
+}
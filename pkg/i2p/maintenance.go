@@ -0,0 +1,388 @@
+// Package i2p provides scheduled maintenance of container sessions.
+//
+// Rebuilding a container's primary session replaces all of its tunnels, and
+// the router needs a while to build new ones, so new streams of the container
+// stall meanwhile. Work that is not urgent is therefore left to maintenance
+// runs instead of being done the moment it becomes due:
+//   - sessions created with tunnel options that have since been reloaded
+//   - sessions left on a SAM router other than the active one, e.g. after a
+//     reload made the primary router active again (consolidation)
+//   - transient identities of client-only containers older than the key
+//     rotation age
+//
+// A run moves a bounded batch of containers to a fresh session on the active
+// router, so SAM churn is spread out rather than rebuilding every container
+// at once. Like a failover handoff, a session moved to another router or given
+// new keys drains, so open streams are not cut; a session rebuilt on the same
+// router with the same destination has to be closed first. Runs happen only
+// inside the configured maintenance windows, or at any time if there are
+// none. Router failover is urgent and never deferred.
+package i2p
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reasons a container session is due for maintenance.
+const (
+	// MaintenanceTunnelOptions rebuilds sessions created with outdated tunnel options
+	MaintenanceTunnelOptions = "tunnel_options"
+	// MaintenanceConsolidation moves sessions to the active SAM router
+	MaintenanceConsolidation = "consolidation"
+	// MaintenanceKeyRotation replaces aged transient identities
+	MaintenanceKeyRotation = "key_rotation"
+)
+
+// DefaultMaintenanceBatch is the number of containers rebuilt per maintenance
+// run unless configured otherwise.
+const DefaultMaintenanceBatch = 4
+
+// MaintenanceWindow is a daily period of local time during which maintenance
+// may run. A window whose end is before its start spans midnight.
+type MaintenanceWindow struct {
+	// Start is the offset of the window's start from midnight
+	Start time.Duration
+	// End is the offset of the window's end from midnight
+	End time.Duration
+}
+
+// ParseMaintenanceWindows parses a comma-separated list of HH:MM-HH:MM
+// windows, e.g. "02:00-04:30,23:00-01:00". An empty list allows maintenance
+// at any time.
+func ParseMaintenanceWindows(spec string) ([]MaintenanceWindow, error) {
+	var windows []MaintenanceWindow
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		start, end, found := strings.Cut(part, "-")
+		if !found {
+			return nil, fmt.Errorf("invalid maintenance window %q: expected HH:MM-HH:MM", part)
+		}
+		var window MaintenanceWindow
+		var err error
+		if window.Start, err = parseClock(start); err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %q: %w", part, err)
+		}
+		if window.End, err = parseClock(end); err != nil {
+			return nil, fmt.Errorf("invalid maintenance window %q: %w", part, err)
+		}
+		if window.Start == window.End {
+			return nil, fmt.Errorf("invalid maintenance window %q: start and end are equal", part)
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// parseClock parses an HH:MM time of day into its offset from midnight.
+func parseClock(clock string) (time.Duration, error) {
+	hours, minutes, found := strings.Cut(strings.TrimSpace(clock), ":")
+	h, hErr := strconv.Atoi(hours)
+	m, mErr := strconv.Atoi(minutes)
+	if !found || hErr != nil || mErr != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("%q is not a HH:MM time of day", clock)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// Contains reports whether t falls inside the window.
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	if w.Start < w.End {
+		return offset >= w.Start && offset < w.End
+	}
+	return offset >= w.Start || offset < w.End
+}
+
+// String formats the window as HH:MM-HH:MM.
+func (w MaintenanceWindow) String() string {
+	clock := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	}
+	return clock(w.Start) + "-" + clock(w.End)
+}
+
+// MaintenanceConfig configures when and how container sessions are rebuilt.
+type MaintenanceConfig struct {
+	// Windows are the daily periods maintenance may run in (empty allows any time)
+	Windows []MaintenanceWindow
+	// Batch is the maximum number of containers rebuilt per run (0 uses DefaultMaintenanceBatch)
+	Batch int
+	// KeyRotation is the age after which transient identities of client-only
+	// containers are replaced (0 disables key rotation)
+	KeyRotation time.Duration
+}
+
+// MaintenanceTask is a container session that is due for maintenance.
+type MaintenanceTask struct {
+	// ContainerID is the container whose session is rebuilt
+	ContainerID string
+	// Reasons lists why the session is due, e.g. MaintenanceTunnelOptions
+	Reasons []string
+	// Router is the SAM router the session is on
+	Router string
+	// Created is when the session was created
+	Created time.Time
+}
+
+// MaintenanceResult is the outcome of rebuilding a container session.
+type MaintenanceResult struct {
+	MaintenanceTask
+	// Err is the error of a failed rebuild; the session is retried next run
+	Err error
+}
+
+// InWindow reports whether maintenance may run at t.
+func (c MaintenanceConfig) InWindow(t time.Time) bool {
+	if len(c.Windows) == 0 {
+		return true
+	}
+	for _, window := range c.Windows {
+		if window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetSessionOptions sets the tunnel options new primary sessions are created
+// with. Existing sessions keep their options until maintenance rebuilds them.
+func (tm *TunnelManager) SetSessionOptions(options TunnelOptions) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	tm.sessionOptions = &options
+}
+
+// SetContainerSessionOverrides sets tunnel option overrides for a container's
+// sessions, keyed as for TunnelOptions.WithOverrides. Empty overrides remove
+// them. The session is created with them if it does not exist yet; otherwise
+// maintenance rebuilds it with them.
+func (tm *TunnelManager) SetContainerSessionOverrides(containerID string, overrides map[string]string) error {
+	if _, err := DefaultTunnelOptions().WithOverrides(overrides); err != nil {
+		return err
+	}
+
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	if len(overrides) == 0 {
+		delete(tm.containerOverrides, containerID)
+		return nil
+	}
+	if tm.containerOverrides == nil {
+		tm.containerOverrides = make(map[string]map[string]string)
+	}
+	tm.containerOverrides[containerID] = maps.Clone(overrides)
+	return nil
+}
+
+// sessionOptionsLocked returns the tunnel options of a container's new
+// primary sessions, zero for minimal sessions. The caller must hold tm.mutex.
+func (tm *TunnelManager) sessionOptionsLocked(containerID string) TunnelOptions {
+	overrides := tm.containerOverrides[containerID]
+	if tm.sessionOptions == nil && len(overrides) == 0 {
+		return TunnelOptions{}
+	}
+
+	base := DefaultTunnelOptions()
+	if tm.sessionOptions != nil {
+		base = *tm.sessionOptions
+	}
+	options, err := base.WithOverrides(overrides)
+	if err != nil {
+		// Overrides are validated when set
+		log.Printf("Warning: ignoring tunnel option overrides of container %s: %v", containerID, err)
+		return base
+	}
+	return options
+}
+
+// sessionSAMOptions returns the SAM options of a container's new primary
+// sessions. The caller must hold tm.mutex.
+func (tm *TunnelManager) sessionSAMOptions(containerID string) []string {
+	options := tm.sessionOptionsLocked(containerID)
+	if options.Equal(TunnelOptions{}) {
+		// Minimal sessions build quickly and suffice until options are set
+		return []string{"inbound.quantity=1", "outbound.quantity=1"}
+	}
+	return options.SAMOptions()
+}
+
+// newRouterSession records a container's primary session just created on
+// the active router. The caller must hold tm.mutex.
+func (tm *TunnelManager) newRouterSession(containerID string, samClient *SAMClient, session interface{ Close() error }) *routerSession {
+	return &routerSession{
+		address:   samClient.Address(),
+		samClient: samClient,
+		primary:   session,
+		options:   tm.sessionOptionsLocked(containerID),
+		created:   time.Now(),
+	}
+}
+
+// SetMaintenance configures when and how container sessions are rebuilt.
+func (tm *TunnelManager) SetMaintenance(config MaintenanceConfig) error {
+	if config.Batch < 0 {
+		return fmt.Errorf("maintenance batch must not be negative, got %d", config.Batch)
+	}
+	if config.KeyRotation < 0 {
+		return fmt.Errorf("key rotation age must not be negative, got %s", config.KeyRotation)
+	}
+
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	config.Windows = append([]MaintenanceWindow(nil), config.Windows...)
+	tm.maintenance = config
+	return nil
+}
+
+// Maintenance returns the maintenance settings.
+func (tm *TunnelManager) Maintenance() MaintenanceConfig {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	config := tm.maintenance
+	config.Windows = append([]MaintenanceWindow(nil), config.Windows...)
+	return config
+}
+
+// PendingMaintenance lists the container sessions due for maintenance,
+// sorted by container ID.
+func (tm *TunnelManager) PendingMaintenance() []MaintenanceTask {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	return tm.pendingMaintenanceLocked(time.Now())
+}
+
+// pendingMaintenanceLocked lists the sessions due for maintenance at now.
+// The caller must hold tm.mutex.
+func (tm *TunnelManager) pendingMaintenanceLocked(now time.Time) []MaintenanceTask {
+	active := tm.activeRouterLocked()
+
+	var tasks []MaintenanceTask
+	for containerID, router := range tm.containerRouters {
+		var reasons []string
+		if !router.options.Equal(tm.sessionOptionsLocked(containerID)) {
+			reasons = append(reasons, MaintenanceTunnelOptions)
+		}
+		if router.address != active {
+			reasons = append(reasons, MaintenanceConsolidation)
+		}
+		if tm.rotatesKeysLocked(containerID) && now.Sub(router.created) >= tm.maintenance.KeyRotation {
+			reasons = append(reasons, MaintenanceKeyRotation)
+		}
+		if len(reasons) == 0 {
+			continue
+		}
+		tasks = append(tasks, MaintenanceTask{
+			ContainerID: containerID,
+			Reasons:     reasons,
+			Router:      router.address,
+			Created:     router.created,
+		})
+	}
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ContainerID < tasks[j].ContainerID })
+	return tasks
+}
+
+// rotatesKeysLocked reports whether a container's identity is rotated.
+//
+// Only transient identities of containers without server tunnels are: a
+// server destination is an address others connect to, and supplied keys are
+// the caller's to manage. The caller must hold tm.mutex.
+func (tm *TunnelManager) rotatesKeysLocked(containerID string) bool {
+	if tm.maintenance.KeyRotation <= 0 {
+		return false
+	}
+	if _, supplied := tm.sessionKeys[containerID]; supplied {
+		return false
+	}
+	for _, tunnel := range tm.tunnels {
+		if tunnel.config.ContainerID == containerID && tunnel.config.Type == TunnelTypeServer {
+			return false
+		}
+	}
+	return true
+}
+
+// RunMaintenance rebuilds a batch of the sessions due for maintenance at now.
+//
+// Nothing is done outside the maintenance windows unless force is set. Each
+// container is moved to a fresh session on the active router, keeping its
+// destination unless its keys are rotated.
+func (tm *TunnelManager) RunMaintenance(now time.Time, force bool) []MaintenanceResult {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	if !force && !tm.maintenance.InWindow(now) {
+		return nil
+	}
+
+	tasks := tm.pendingMaintenanceLocked(now)
+	batch := tm.maintenance.Batch
+	if batch == 0 {
+		batch = DefaultMaintenanceBatch
+	}
+	if len(tasks) > batch {
+		tasks = tasks[:batch]
+	}
+
+	var results []MaintenanceResult
+	for _, task := range tasks {
+		err := tm.rebuildContainerLocked(task)
+		if err != nil {
+			log.Printf("Warning: Maintenance of container %s (%s) failed: %v", DisplayName(task.ContainerID), strings.Join(task.Reasons, ", "), err)
+		} else {
+			log.Printf("Rebuilt session of container %s for maintenance (%s)", DisplayName(task.ContainerID), strings.Join(task.Reasons, ", "))
+		}
+		results = append(results, MaintenanceResult{MaintenanceTask: task, Err: err})
+	}
+	return results
+}
+
+// rebuildContainerLocked moves a container to a fresh session on the active
+// router. The caller must hold tm.mutex.
+func (tm *TunnelManager) rebuildContainerLocked(task MaintenanceTask) error {
+	containerID := task.ContainerID
+	session, exists := tm.containerSessions[containerID]
+	if !exists {
+		return fmt.Errorf("container has no session")
+	}
+
+	rotate := false
+	for _, reason := range task.Reasons {
+		rotate = rotate || reason == MaintenanceKeyRotation
+	}
+
+	// Sessions with transient keys are recreated with the same keys unless
+	// they are rotated, so their destination does not change
+	if _, supplied := tm.sessionKeys[containerID]; !supplied && !rotate {
+		tm.sessionKeys[containerID] = session.Keys()
+		defer delete(tm.sessionKeys, containerID)
+	}
+
+	// A router refuses a second session with a destination it already has,
+	// so a session kept on the same router cannot drain and its open streams
+	// are cut. If the new session fails, the next run tries again.
+	if !rotate && task.Router == tm.activeRouterLocked() {
+		if old := tm.containerRouters[containerID]; old != nil {
+			old.close()
+		}
+	}
+
+	return tm.handoffContainer(containerID)
+}
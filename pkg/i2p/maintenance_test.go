@@ -0,0 +1,255 @@
+package i2p
+
+import (
+	"net"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/fakesam"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/testutil"
+)
+
+// startFakeSAM starts a fake SAM bridge and returns the configuration to
+// reach it.
+func startFakeSAM(t *testing.T) *SAMConfig {
+	return fakeSAMConfig(testutil.StartFakeSAM(t, fakesam.ServerOptions{}))
+}
+
+// fakeSAMConfig returns the configuration to reach a fake SAM bridge.
+func fakeSAMConfig(server *fakesam.Server) *SAMConfig {
+	host, port := testutil.SAMAddress(server)
+	return &SAMConfig{Host: host, Port: port, Timeout: testutil.StartTimeout}
+}
+
+func TestParseMaintenanceWindows(t *testing.T) {
+	windows, err := ParseMaintenanceWindows(" 02:00-04:30, 23:00-01:00 ,")
+	if err != nil {
+		t.Fatalf("ParseMaintenanceWindows() unexpected error: %v", err)
+	}
+	if len(windows) != 2 || windows[0].String() != "02:00-04:30" || windows[1].String() != "23:00-01:00" {
+		t.Fatalf("Unexpected windows %v", windows)
+	}
+
+	day := func(hour, minute int) time.Time { return time.Date(2026, 3, 1, hour, minute, 0, 0, time.Local) }
+	for _, tc := range []struct {
+		at     time.Time
+		window int
+		want   bool
+	}{
+		{day(2, 0), 0, true},
+		{day(4, 29), 0, true},
+		{day(4, 30), 0, false},
+		{day(1, 59), 0, false},
+		{day(23, 30), 1, true},
+		{day(0, 30), 1, true},
+		{day(1, 0), 1, false},
+		{day(12, 0), 1, false},
+	} {
+		if got := windows[tc.window].Contains(tc.at); got != tc.want {
+			t.Errorf("%s contains %s: expected %v, got %v", windows[tc.window], tc.at.Format("15:04"), tc.want, got)
+		}
+	}
+
+	config := MaintenanceConfig{Windows: windows}
+	if !config.InWindow(day(3, 0)) || config.InWindow(day(12, 0)) {
+		t.Error("Expected InWindow to check every window")
+	}
+	if !(MaintenanceConfig{}).InWindow(day(12, 0)) {
+		t.Error("Expected maintenance to run at any time without windows")
+	}
+
+	if windows, err := ParseMaintenanceWindows(""); err != nil || len(windows) != 0 {
+		t.Errorf("Expected no windows for an empty list, got %v, %v", windows, err)
+	}
+	for _, spec := range []string{"02:00", "2-4", "24:00-01:00", "02:60-03:00", "03:00-03:00"} {
+		if _, err := ParseMaintenanceWindows(spec); err == nil {
+			t.Errorf("Expected error for window %q", spec)
+		}
+	}
+}
+
+func TestSetMaintenanceValidation(t *testing.T) {
+	tm := NewTunnelManager(TunnelManagerOptions{})
+
+	if err := tm.SetMaintenance(MaintenanceConfig{Batch: -1}); err == nil {
+		t.Error("Expected error for a negative batch")
+	}
+	if err := tm.SetMaintenance(MaintenanceConfig{KeyRotation: -time.Hour}); err == nil {
+		t.Error("Expected error for a negative key rotation age")
+	}
+	if err := tm.SetMaintenance(MaintenanceConfig{Batch: 2, KeyRotation: time.Hour}); err != nil {
+		t.Fatalf("SetMaintenance() unexpected error: %v", err)
+	}
+	if config := tm.Maintenance(); config.Batch != 2 || config.KeyRotation != time.Hour {
+		t.Errorf("Unexpected maintenance settings %+v", config)
+	}
+}
+
+func TestSessionSAMOptions(t *testing.T) {
+	tm := NewTunnelManager(TunnelManagerOptions{})
+	if options := tm.sessionSAMOptions("app"); len(options) != 2 || options[0] != "inbound.quantity=1" {
+		t.Errorf("Expected minimal options before options are set, got %v", options)
+	}
+
+	tm.SetSessionOptions(DefaultTunnelOptions())
+	options := tm.sessionSAMOptions("app")
+	want := map[string]bool{"inbound.quantity=2": true, "outbound.length=3": true, "i2cp.closeIdleTime=600000": true}
+	for _, option := range options {
+		delete(want, option)
+	}
+	if len(want) != 0 {
+		t.Errorf("Missing options %v in %v", want, options)
+	}
+
+	overrides := map[string]string{"inbound_tunnels": "4", "inbound.IPRestriction": "1"}
+	if err := tm.SetContainerSessionOverrides("app", overrides); err != nil {
+		t.Fatalf("SetContainerSessionOverrides() unexpected error: %v", err)
+	}
+	options = tm.sessionSAMOptions("app")
+	want = map[string]bool{"inbound.quantity=4": true, "inbound.IPRestriction=1": true, "outbound.length=3": true}
+	for _, option := range options {
+		delete(want, option)
+	}
+	if len(want) != 0 {
+		t.Errorf("Missing overridden options %v in %v", want, options)
+	}
+	if other := tm.sessionSAMOptions("other"); slices.Contains(other, "inbound.quantity=4") {
+		t.Errorf("Overrides of app applied to another container: %v", other)
+	}
+
+	if err := tm.SetContainerSessionOverrides("app", map[string]string{"inbound_tunnels": "0"}); err == nil {
+		t.Error("Expected error for an invalid override")
+	}
+	if err := tm.SetContainerSessionOverrides("app", nil); err != nil {
+		t.Fatalf("SetContainerSessionOverrides() unexpected error: %v", err)
+	}
+	if options := tm.sessionSAMOptions("app"); slices.Contains(options, "inbound.quantity=4") {
+		t.Errorf("Expected overrides removed, got %v", options)
+	}
+}
+
+func TestMaintenanceOverFakeSAM(t *testing.T) {
+	t.Parallel()
+
+	tm := NewTunnelManager(TunnelManagerOptions{SAM: startFakeSAM(t)})
+	defer tm.DestroyAllTunnels()
+
+	session, err := tm.GetOrCreateContainerSession("app")
+	if err != nil {
+		t.Fatalf("GetOrCreateContainerSession() unexpected error: %v", err)
+	}
+	destination := session.Addr().Base64()
+	if pending := tm.PendingMaintenance(); len(pending) != 0 {
+		t.Fatalf("Expected no pending maintenance, got %+v", pending)
+	}
+
+	// Reloaded tunnel options leave the session for the maintenance window
+	tm.SetSessionOptions(DefaultTunnelOptions())
+	pending := tm.PendingMaintenance()
+	if len(pending) != 1 || pending[0].ContainerID != "app" || len(pending[0].Reasons) != 1 || pending[0].Reasons[0] != MaintenanceTunnelOptions {
+		t.Fatalf("Expected the session to be due for new tunnel options, got %+v", pending)
+	}
+
+	now := time.Now()
+	closed := MaintenanceWindow{Start: 0, End: time.Minute}
+	if closed.Contains(now) {
+		closed = MaintenanceWindow{Start: time.Hour, End: time.Hour + time.Minute}
+	}
+	if err := tm.SetMaintenance(MaintenanceConfig{Windows: []MaintenanceWindow{closed}}); err != nil {
+		t.Fatalf("SetMaintenance() unexpected error: %v", err)
+	}
+	if results := tm.RunMaintenance(now, false); results != nil {
+		t.Fatalf("Expected no maintenance outside the windows, got %+v", results)
+	}
+
+	results := tm.RunMaintenance(now, true)
+	if len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Expected a forced run to rebuild the session, got %+v", results)
+	}
+	rebuilt, _ := tm.GetOrCreateContainerSession("app")
+	if rebuilt == session || rebuilt.Addr().Base64() != destination {
+		t.Error("Expected a new session keeping the destination")
+	}
+	if pending := tm.PendingMaintenance(); len(pending) != 0 {
+		t.Errorf("Expected no pending maintenance after the rebuild, got %+v", pending)
+	}
+	if _, supplied := tm.sessionKeys["app"]; supplied {
+		t.Error("Expected the kept keys not to be recorded as supplied")
+	}
+
+	// Transient identities of client-only containers are rotated once aged
+	if err := tm.SetMaintenance(MaintenanceConfig{KeyRotation: time.Hour}); err != nil {
+		t.Fatalf("SetMaintenance() unexpected error: %v", err)
+	}
+	if results := tm.RunMaintenance(now, false); len(results) != 0 {
+		t.Fatalf("Expected a fresh identity not to be rotated, got %+v", results)
+	}
+	results = tm.RunMaintenance(now.Add(2*time.Hour), false)
+	if len(results) != 1 || results[0].Err != nil || results[0].Reasons[0] != MaintenanceKeyRotation {
+		t.Fatalf("Expected the identity to be rotated, got %+v", results)
+	}
+	rotated, _ := tm.GetOrCreateContainerSession("app")
+	if rotated.Addr().Base64() == destination {
+		t.Error("Expected a rotated identity to have a new destination")
+	}
+}
+
+func TestMaintenanceConsolidation(t *testing.T) {
+	t.Parallel()
+
+	first, second := startFakeSAM(t), startFakeSAM(t)
+	tm := NewTunnelManager(TunnelManagerOptions{SAM: first})
+	defer tm.DestroyAllTunnels()
+
+	session, err := tm.GetOrCreateContainerSession("app")
+	if err != nil {
+		t.Fatalf("GetOrCreateContainerSession() unexpected error: %v", err)
+	}
+	if err := tm.UpdateSAMConfig(second); err != nil {
+		t.Fatalf("UpdateSAMConfig() unexpected error: %v", err)
+	}
+
+	pending := tm.PendingMaintenance()
+	if len(pending) != 1 || pending[0].Reasons[0] != MaintenanceConsolidation || pending[0].Router != net.JoinHostPort(first.Host, strconv.Itoa(first.Port)) {
+		t.Fatalf("Expected the session to be due for consolidation, got %+v", pending)
+	}
+
+	if results := tm.RunMaintenance(time.Now(), false); len(results) != 1 || results[0].Err != nil {
+		t.Fatalf("Expected the session to be moved, got %+v", results)
+	}
+	routers := tm.ContainerRouters("app")
+	if len(routers) == 0 || routers[0].Address != tm.ActiveRouter() {
+		t.Errorf("Expected the session on the active router, got %+v", routers)
+	}
+	moved, _ := tm.GetOrCreateContainerSession("app")
+	if moved.Addr().Base64() != session.Addr().Base64() {
+		t.Error("Expected consolidation to keep the destination")
+	}
+}
+
+func TestMaintenanceBatch(t *testing.T) {
+	t.Parallel()
+
+	tm := NewTunnelManager(TunnelManagerOptions{SAM: startFakeSAM(t)})
+	defer tm.DestroyAllTunnels()
+
+	for _, containerID := range []string{"a", "b", "c"} {
+		if _, err := tm.GetOrCreateContainerSession(containerID); err != nil {
+			t.Fatalf("GetOrCreateContainerSession() unexpected error: %v", err)
+		}
+	}
+	tm.SetSessionOptions(DefaultTunnelOptions())
+	if err := tm.SetMaintenance(MaintenanceConfig{Batch: 2}); err != nil {
+		t.Fatalf("SetMaintenance() unexpected error: %v", err)
+	}
+
+	results := tm.RunMaintenance(time.Now(), false)
+	if len(results) != 2 || results[0].ContainerID != "a" || results[1].ContainerID != "b" {
+		t.Fatalf("Expected the first batch of two, got %+v", results)
+	}
+	if pending := tm.PendingMaintenance(); len(pending) != 1 || pending[0].ContainerID != "c" {
+		t.Errorf("Expected one session left, got %+v", pending)
+	}
+}
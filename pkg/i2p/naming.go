@@ -0,0 +1,106 @@
+// Package i2p provides tunnel naming for I2P services.
+//
+// This file implements the tunnel naming scheme. Names are embedded in SAM
+// session IDs, so they must be short and limited to a safe character set.
+// Raw container IDs and service names satisfy neither, so names are built
+// from a short container ID, a sanitized purpose and a hash of the original
+// inputs that keeps names unique after truncation and sanitization.
+package i2p
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// MaxTunnelNameLength is the maximum length of a tunnel name.
+//
+// Sub-session IDs append a type and port suffix of up to 17 characters
+// (e.g. "-server-port65535"), which keeps them well within SAM limits.
+const MaxTunnelNameLength = 48
+
+const (
+	// shortIDLength matches the short container IDs shown by the Docker CLI
+	shortIDLength = 12
+	// nameHashLength is the number of hex digits in the name hash suffix
+	nameHashLength = 8
+)
+
+// TunnelOrigin records what a tunnel name was generated from.
+type TunnelOrigin struct {
+	// ContainerID is the full container ID the tunnel belongs to
+	ContainerID string `json:"container_id"`
+	// Purpose is the unsanitized description the name was built from
+	Purpose string `json:"purpose"`
+}
+
+// TunnelName returns the deterministic tunnel name for a container and purpose.
+//
+// Names have the form <short container ID>-<purpose>-<hash>, where the purpose
+// is sanitized to SAM-safe characters and truncated so the name never exceeds
+// MaxTunnelNameLength. A container with an alias (see SetContainerAlias) is
+// named by its alias instead of its short ID. The hash covers the full, unmodified inputs, so distinct
+// origins get distinct names even when their readable parts are identical.
+func TunnelName(containerID, purpose string) string {
+	sum := sha256.Sum256([]byte(containerID + "\x00" + purpose))
+	suffix := hex.EncodeToString(sum[:])[:nameHashLength]
+
+	name := DisplayName(containerID)
+	if readable := sanitizeNamePart(purpose); readable != "" {
+		budget := MaxTunnelNameLength - len(name) - len(suffix) - 2
+		if len(readable) > budget {
+			readable = strings.TrimRight(readable[:budget], "-._")
+		}
+		if name != "" {
+			name += "-"
+		}
+		name += readable
+	}
+
+	if name == "" {
+		return suffix
+	}
+	return name + "-" + suffix
+}
+
+// ShortID returns the sanitized short form of a container ID.
+func ShortID(containerID string) string {
+	short := sanitizeNamePart(containerID)
+	if len(short) > shortIDLength {
+		short = short[:shortIDLength]
+	}
+	return short
+}
+
+// ValidateTunnelName checks that a name is usable in SAM session IDs.
+func ValidateTunnelName(name string) error {
+	if name == "" {
+		return fmt.Errorf("tunnel name cannot be empty")
+	}
+	if len(name) > MaxTunnelNameLength {
+		return fmt.Errorf("tunnel name %q exceeds %d characters", name, MaxTunnelNameLength)
+	}
+	for _, r := range name {
+		if !isNameChar(r) {
+			return fmt.Errorf("tunnel name %q contains %q, only letters, digits, '.', '_' and '-' are allowed", name, r)
+		}
+	}
+	return nil
+}
+
+// sanitizeNamePart replaces characters that are not SAM-safe with underscores.
+func sanitizeNamePart(part string) string {
+	return strings.Map(func(r rune) rune {
+		if isNameChar(r) {
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(part))
+}
+
+// isNameChar reports whether r may appear in a tunnel name.
+func isNameChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') ||
+		r == '-' || r == '_' || r == '.'
+}
@@ -0,0 +1,127 @@
+package i2p
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTunnelName(t *testing.T) {
+	containerID := strings.Repeat("3f4e5a6b7c8d", 5) + "abcd"
+
+	name := TunnelName(containerID, "web-80")
+	if name != TunnelName(containerID, "web-80") {
+		t.Error("Expected tunnel names to be deterministic")
+	}
+	if !strings.HasPrefix(name, "3f4e5a6b7c8d-web-80-") || len(name) != len("3f4e5a6b7c8d-web-80-")+nameHashLength {
+		t.Errorf("Unexpected tunnel name %s", name)
+	}
+	if err := ValidateTunnelName(name); err != nil {
+		t.Errorf("Generated name is invalid: %v", err)
+	}
+
+	// Long purposes are truncated but stay unique
+	long1 := TunnelName(containerID, "client-"+strings.Repeat("a", 52)+".b32.i2p-80")
+	long2 := TunnelName(containerID, "client-"+strings.Repeat("a", 52)+".b32.i2p-443")
+	if len(long1) > MaxTunnelNameLength || len(long2) > MaxTunnelNameLength {
+		t.Errorf("Expected names within %d characters, got %d and %d", MaxTunnelNameLength, len(long1), len(long2))
+	}
+	if long1 == long2 {
+		t.Errorf("Expected truncated names to differ, both are %s", long1)
+	}
+
+	// Unsafe characters are replaced, and the hash keeps names apart
+	spaced := TunnelName("container1", "my web")
+	underscored := TunnelName("container1", "my_web")
+	if err := ValidateTunnelName(spaced); err != nil {
+		t.Errorf("Expected sanitized name to be valid: %v", err)
+	}
+	if spaced == underscored {
+		t.Errorf("Expected sanitization collisions to be avoided, both are %s", spaced)
+	}
+
+	// Containers sharing a short ID prefix get different names
+	if TunnelName(strings.Repeat("a", 64), "web-80") == TunnelName(strings.Repeat("a", 63)+"b", "web-80") {
+		t.Error("Expected containers with the same short ID to get different names")
+	}
+
+	if name := TunnelName("", ""); len(name) != nameHashLength {
+		t.Errorf("Expected hash-only name for empty inputs, got %s", name)
+	}
+}
+
+func TestTunnelNameAlias(t *testing.T) {
+	containerID := strings.Repeat("3f4e5a6b7c8d", 5) + "abcd"
+	other := strings.Repeat("a", 64)
+	SetContainerAlias(containerID, "webapp_web_1")
+	SetContainerAlias(other, "webapp web 1")
+	defer SetContainerAlias(containerID, "")
+	defer SetContainerAlias(other, "")
+
+	name := TunnelName(containerID, "http-80")
+	if !strings.HasPrefix(name, "webapp_web_1-http-80-") {
+		t.Errorf("Expected the alias in the tunnel name, got %s", name)
+	}
+	if TunnelName(other, "http-80") == name {
+		t.Error("Expected containers with the same alias to get different names")
+	}
+	if DisplayName(containerID) != "webapp_web_1" {
+		t.Errorf("Expected the alias as display name, got %s", DisplayName(containerID))
+	}
+
+	SetContainerAlias(containerID, "---")
+	if _, exists := ContainerAlias(containerID); exists || DisplayName(containerID) != ShortID(containerID) {
+		t.Errorf("Expected an alias without readable characters to be removed, got %s", DisplayName(containerID))
+	}
+
+	if alias := SanitizeAlias(strings.Repeat("service", 10)); len(alias) != MaxAliasLength {
+		t.Errorf("Expected aliases to be truncated to %d characters, got %s", MaxAliasLength, alias)
+	}
+	if err := ValidateTunnelName(TunnelName(other, strings.Repeat("p", 60))); err != nil {
+		t.Errorf("Expected names with aliases to be valid: %v", err)
+	}
+}
+
+func TestValidateTunnelName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"container1-web-80-1a2b3c4d", false},
+		{"", true},
+		{"has space", true},
+		{"equals=sign", true},
+		{strings.Repeat("a", MaxTunnelNameLength+1), true},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateTunnelName(tt.name); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateTunnelName(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTunnelOriginTracking(t *testing.T) {
+	tm := NewTunnelManager(TunnelManagerOptions{})
+
+	name := TunnelName("container1", "web-80")
+	tm.tunnels[name] = &Tunnel{config: &TunnelConfig{Name: name, ContainerID: "container1", Purpose: "web-80"}}
+	tm.origins[name] = TunnelOrigin{ContainerID: "container1", Purpose: "web-80"}
+
+	origin, ok := tm.TunnelOrigin(name)
+	if !ok || origin.ContainerID != "container1" || origin.Purpose != "web-80" {
+		t.Errorf("Unexpected origin %+v (%v)", origin, ok)
+	}
+
+	// A different origin claiming the same name is reported as a collision
+	_, err := tm.CreateTunnel(&TunnelConfig{Name: name, ContainerID: "container2", Purpose: "api-80", Type: TunnelTypeServer, LocalPort: 80})
+	if err == nil || !strings.Contains(err.Error(), "collides") {
+		t.Errorf("Expected collision error, got %v", err)
+	}
+
+	if err := tm.DestroyTunnel(name); err != nil {
+		t.Fatalf("Failed to destroy tunnel: %v", err)
+	}
+	if _, ok := tm.TunnelOrigin(name); ok {
+		t.Error("Expected origin to be removed with the tunnel")
+	}
+}
@@ -0,0 +1,120 @@
+// Package i2p provides pacing of SAM session operations.
+//
+// Starting many containers at once, e.g. on docker compose up, makes the
+// plugin create a primary session per container and a sub-session per
+// tunnel within moments. SAM bridges refuse or time out bursts like that,
+// which leaves containers with half-created sessions. The tunnel manager
+// therefore sends the control messages that create sessions (DEST GENERATE,
+// SESSION CREATE and SESSION ADD) through a token bucket shared by all
+// containers: a burst of operations goes out at once, and further ones wait
+// their turn at the configured rate. Tearing sessions down is not paced.
+package i2p
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSAMOperationRate is the sustained number of SAM session
+	// operations sent per second
+	DefaultSAMOperationRate = 10
+	// DefaultSAMOperationBurst is the number of SAM session operations sent
+	// without waiting
+	DefaultSAMOperationBurst = 20
+)
+
+// SAMOperationStats counts the paced SAM session operations.
+type SAMOperationStats struct {
+	// Operations counts the session operations sent
+	Operations int64 `json:"operations"`
+	// Delayed counts the operations that waited for their turn
+	Delayed int64 `json:"delayed"`
+	// Waited is the total time operations waited
+	Waited time.Duration `json:"waited"`
+}
+
+// samPacer spaces SAM session operations to a sustained rate.
+//
+// Operations reserve a token when they arrive, so concurrent callers are
+// released in arrival order rather than racing for each refill.
+type samPacer struct {
+	mutex sync.Mutex
+	// rate is the sustained operations per second (0 disables pacing)
+	rate float64
+	// burst is the number of tokens the bucket holds
+	burst int
+	// tokens are the operations that may be sent now; negative while
+	// callers wait for reserved tokens
+	tokens float64
+	// last is when tokens were last refilled
+	last time.Time
+	// stats counts the paced operations
+	stats SAMOperationStats
+}
+
+// setLimits changes the pacing rate and burst. A full burst is available
+// right after the change.
+func (p *samPacer) setLimits(rate float64, burst int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if burst < 1 {
+		burst = 1
+	}
+	p.rate = rate
+	p.burst = burst
+	p.tokens = float64(burst)
+	p.last = time.Now()
+}
+
+// wait blocks until the next SAM session operation may be sent.
+func (p *samPacer) wait() {
+	p.mutex.Lock()
+	p.stats.Operations++
+	if p.rate <= 0 {
+		p.mutex.Unlock()
+		return
+	}
+
+	now := time.Now()
+	p.tokens += now.Sub(p.last).Seconds() * p.rate
+	if p.tokens > float64(p.burst) {
+		p.tokens = float64(p.burst)
+	}
+	p.last = now
+	p.tokens--
+
+	var delay time.Duration
+	if p.tokens < 0 {
+		delay = time.Duration(-p.tokens / p.rate * float64(time.Second))
+		p.stats.Delayed++
+		p.stats.Waited += delay
+	}
+	p.mutex.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// snapshot returns the operation counters.
+func (p *samPacer) snapshot() SAMOperationStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.stats
+}
+
+// SAMOperationStats returns how many SAM session operations were sent and
+// how many had to wait for their turn.
+func (tm *TunnelManager) SAMOperationStats() SAMOperationStats {
+	return tm.pacer.snapshot()
+}
+
+// samOperationLimits returns the pacing rate and burst of a SAM configuration.
+func samOperationLimits(config *SAMConfig) (float64, int) {
+	if config == nil {
+		config = DefaultSAMConfig()
+	}
+	return config.OperationRate, config.OperationBurst
+}
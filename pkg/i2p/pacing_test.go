@@ -0,0 +1,101 @@
+package i2p
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSAMPacerBurst(t *testing.T) {
+	var pacer samPacer
+	pacer.setLimits(20, 3)
+
+	started := time.Now()
+	for i := 0; i < 3; i++ {
+		pacer.wait()
+	}
+	if elapsed := time.Since(started); elapsed > 20*time.Millisecond {
+		t.Errorf("Expected the burst to pass without waiting, took %v", elapsed)
+	}
+
+	// The next two operations wait for refills at 20 per second
+	pacer.wait()
+	pacer.wait()
+	if elapsed := time.Since(started); elapsed < 80*time.Millisecond {
+		t.Errorf("Expected operations beyond the burst to be paced, took %v", elapsed)
+	}
+
+	stats := pacer.snapshot()
+	if stats.Operations != 5 || stats.Delayed != 2 || stats.Waited <= 0 {
+		t.Errorf("Unexpected pacing stats %+v", stats)
+	}
+}
+
+func TestSAMPacerConcurrent(t *testing.T) {
+	var pacer samPacer
+	pacer.setLimits(50, 1)
+
+	started := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pacer.wait()
+		}()
+	}
+	wg.Wait()
+
+	// One operation goes out at once, the other five are spaced 20ms apart
+	if elapsed := time.Since(started); elapsed < 90*time.Millisecond {
+		t.Errorf("Expected concurrent operations to be spaced, took %v", elapsed)
+	}
+	if stats := pacer.snapshot(); stats.Operations != 6 || stats.Delayed != 5 {
+		t.Errorf("Unexpected pacing stats %+v", stats)
+	}
+}
+
+func TestSAMPacerDisabled(t *testing.T) {
+	var pacer samPacer
+	pacer.setLimits(0, 0)
+
+	started := time.Now()
+	for i := 0; i < 100; i++ {
+		pacer.wait()
+	}
+	if elapsed := time.Since(started); elapsed > 20*time.Millisecond {
+		t.Errorf("Expected disabled pacing not to wait, took %v", elapsed)
+	}
+	if stats := pacer.snapshot(); stats.Operations != 100 || stats.Delayed != 0 {
+		t.Errorf("Unexpected pacing stats %+v", stats)
+	}
+}
+
+func TestUpdateSAMConfigPacing(t *testing.T) {
+	tm := NewTunnelManager(TunnelManagerOptions{})
+	if tm.pacer.rate != DefaultSAMOperationRate || tm.pacer.burst != DefaultSAMOperationBurst {
+		t.Errorf("Expected default pacing %v/%d, got %v/%d", DefaultSAMOperationRate, DefaultSAMOperationBurst, tm.pacer.rate, tm.pacer.burst)
+	}
+
+	// The update dials the SAM bridge
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	config := &SAMConfig{
+		Host:           "127.0.0.1",
+		Port:           listener.Addr().(*net.TCPAddr).Port,
+		Timeout:        time.Second,
+		OperationRate:  2,
+		OperationBurst: 5,
+	}
+	if err := tm.UpdateSAMConfig(config); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tm.pacer.rate != 2 || tm.pacer.burst != 5 {
+		t.Errorf("Expected pacing 2/5 after the update, got %v/%d", tm.pacer.rate, tm.pacer.burst)
+	}
+}
@@ -0,0 +1,407 @@
+// Package i2p provides reputation tracking of inbound I2P peers.
+//
+// Destinations are free to create, so a single I2P peer can open streams to
+// an exposed service faster than the container can serve them, or open
+// streams only to drop them again. Server tunnels therefore score each client
+// destination: every stream it opens adds to its stream score, and every
+// stream that ends before the peer sent anything adds to its empty stream
+// score. Scores decay exponentially, so occasional bursts are forgotten. A
+// destination whose score reaches its threshold is blocked for a while: its
+// streams are closed as soon as they are accepted, before they reach the
+// container. Repeat offenders are blocked for longer.
+package i2p
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reasons a destination is blocked for.
+const (
+	// PeerBlockFlood blocks destinations opening streams too quickly
+	PeerBlockFlood = "flood"
+	// PeerBlockEmptyStreams blocks destinations closing streams without
+	// sending data
+	PeerBlockEmptyStreams = "empty_streams"
+)
+
+// maxBlockDoublings bounds how often a repeat offender's block duration is
+// doubled.
+const maxBlockDoublings = 3
+
+// maxTrackedPeers bounds the number of destinations scored at once. Streams
+// of further destinations are accepted without being scored until idle
+// records are forgotten.
+const maxTrackedPeers = 10000
+
+// peerForgetAfter is how long the offences of a destination are remembered
+// after its last block, so repeat offenders are recognized.
+const peerForgetAfter = time.Hour
+
+// PeerReputationConfig tunes the blocking of abusive inbound peers.
+type PeerReputationConfig struct {
+	// FloodStreams is the stream score at which a destination is blocked
+	FloodStreams float64
+	// EmptyStreams is the empty stream score at which a destination is blocked
+	EmptyStreams float64
+	// HalfLife is the time after which scores have decayed to half
+	HalfLife time.Duration
+	// BlockDuration is how long a first offence blocks a destination; it is
+	// doubled for repeat offences up to eight times as long (0 disables
+	// blocking)
+	BlockDuration time.Duration
+}
+
+// DefaultPeerReputationConfig returns the settings used by server tunnels.
+//
+// With a one-minute half-life, a destination is blocked after opening about
+// 60 streams in quick succession or keeping up about 40 streams per minute.
+func DefaultPeerReputationConfig() PeerReputationConfig {
+	return PeerReputationConfig{
+		FloodStreams:  60,
+		EmptyStreams:  20,
+		HalfLife:      time.Minute,
+		BlockDuration: 5 * time.Minute,
+	}
+}
+
+// BlockedPeer is a destination whose inbound streams are refused.
+type BlockedPeer struct {
+	// Destination is the peer's base32 address
+	Destination string
+	// Reason is PeerBlockFlood or PeerBlockEmptyStreams
+	Reason string
+	// Tunnel is the server tunnel the offence was detected on
+	Tunnel string
+	// Offences counts the destination's blocks, including this one
+	Offences int
+	// BlockedAt is when the block started
+	BlockedAt time.Time
+	// Until is when the block expires
+	Until time.Time
+	// Rejected counts the streams refused during the block
+	Rejected int64
+}
+
+// PeerReputationStats summarizes inbound peer blocking.
+type PeerReputationStats struct {
+	// Tracked is the number of destinations currently scored
+	Tracked int
+	// Blocked is the number of destinations currently blocked
+	Blocked int
+	// Blocks counts blocks since startup
+	Blocks int64
+	// Rejected counts streams refused since startup
+	Rejected int64
+}
+
+// peerRecord holds the scores of one destination.
+type peerRecord struct {
+	streams   float64
+	empty     float64
+	updated   time.Time
+	offences  int
+	blocked   *BlockedPeer
+	lastBlock time.Time
+}
+
+// decay ages the record's scores to now.
+func (r *peerRecord) decay(now time.Time, halfLife time.Duration) {
+	if elapsed := now.Sub(r.updated); elapsed > 0 && halfLife > 0 {
+		factor := math.Pow(0.5, float64(elapsed)/float64(halfLife))
+		r.streams *= factor
+		r.empty *= factor
+	}
+	r.updated = now
+}
+
+// PeerReputation scores inbound I2P peers and blocks abusive ones.
+//
+// It is safe for concurrent use and shared by all server tunnels of a
+// TunnelManager.
+type PeerReputation struct {
+	config   PeerReputationConfig
+	peers    map[string]*peerRecord
+	blocks   int64
+	rejected int64
+	now      func() time.Time
+	mutex    sync.Mutex
+}
+
+// NewPeerReputation creates a reputation tracker with the given settings.
+func NewPeerReputation(config PeerReputationConfig) *PeerReputation {
+	return &PeerReputation{
+		config: config,
+		peers:  make(map[string]*peerRecord),
+		now:    time.Now,
+	}
+}
+
+// SetConfig replaces the settings. Existing blocks keep their expiry;
+// disabling blocking lifts them.
+func (r *PeerReputation) SetConfig(config PeerReputationConfig) error {
+	if config.BlockDuration < 0 {
+		return fmt.Errorf("peer block duration must not be negative, got %s", config.BlockDuration)
+	}
+	if config.BlockDuration > 0 && (config.FloodStreams <= 0 || config.EmptyStreams <= 0 || config.HalfLife <= 0) {
+		return fmt.Errorf("peer stream thresholds and half-life must be positive")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.config = config
+	if config.BlockDuration == 0 {
+		r.peers = make(map[string]*peerRecord)
+	}
+	return nil
+}
+
+// Config returns the current settings.
+func (r *PeerReputation) Config() PeerReputationConfig {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.config
+}
+
+// admit scores a stream a destination opened on a tunnel and reports
+// whether it may be served.
+func (r *PeerReputation) admit(destination, tunnel string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.config.BlockDuration == 0 || destination == "" {
+		return true
+	}
+
+	now := r.now()
+	record := r.recordLocked(destination, now)
+	if record == nil {
+		return true
+	}
+	if r.blockedLocked(record, now) {
+		record.blocked.Rejected++
+		r.rejected++
+		return false
+	}
+
+	record.decay(now, r.config.HalfLife)
+	record.streams++
+	if record.streams >= r.config.FloodStreams {
+		r.blockLocked(destination, record, PeerBlockFlood, tunnel, now)
+		record.blocked.Rejected++
+		r.rejected++
+		return false
+	}
+	return true
+}
+
+// closed scores the end of a stream admitted for a destination.
+func (r *PeerReputation) closed(destination, tunnel string, empty bool) {
+	if !empty {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.config.BlockDuration == 0 {
+		return
+	}
+
+	now := r.now()
+	record, exists := r.peers[destination]
+	if !exists || r.blockedLocked(record, now) {
+		return
+	}
+
+	record.decay(now, r.config.HalfLife)
+	record.empty++
+	if record.empty >= r.config.EmptyStreams {
+		r.blockLocked(destination, record, PeerBlockEmptyStreams, tunnel, now)
+	}
+}
+
+// recordLocked returns the record of a destination, creating it if there
+// is room. The caller must hold r.mutex.
+func (r *PeerReputation) recordLocked(destination string, now time.Time) *peerRecord {
+	if record, exists := r.peers[destination]; exists {
+		return record
+	}
+	if len(r.peers) >= maxTrackedPeers {
+		r.forgetIdleLocked(now)
+		if len(r.peers) >= maxTrackedPeers {
+			return nil
+		}
+	}
+	record := &peerRecord{updated: now}
+	r.peers[destination] = record
+	return record
+}
+
+// blockedLocked reports whether a record is blocked at now, lifting an
+// expired block. The caller must hold r.mutex.
+func (r *PeerReputation) blockedLocked(record *peerRecord, now time.Time) bool {
+	if record.blocked == nil {
+		return false
+	}
+	if now.Before(record.blocked.Until) {
+		return true
+	}
+	record.blocked = nil
+	return false
+}
+
+// blockLocked blocks a destination. The caller must hold r.mutex.
+func (r *PeerReputation) blockLocked(destination string, record *peerRecord, reason, tunnel string, now time.Time) {
+	record.offences++
+	duration := r.config.BlockDuration << min(record.offences-1, maxBlockDoublings)
+
+	record.blocked = &BlockedPeer{
+		Destination: destination,
+		Reason:      reason,
+		Tunnel:      tunnel,
+		Offences:    record.offences,
+		BlockedAt:   now,
+		Until:       now.Add(duration),
+	}
+	record.lastBlock = now
+	record.streams = 0
+	record.empty = 0
+	r.blocks++
+
+	log.Printf("Warning: Blocked I2P destination %s for %s after %s on tunnel %s (offence %d)",
+		destination, duration, reason, tunnel, record.offences)
+}
+
+// forgetIdleLocked drops records of destinations that are neither blocked
+// nor active. The caller must hold r.mutex.
+func (r *PeerReputation) forgetIdleLocked(now time.Time) {
+	for destination, record := range r.peers {
+		if r.blockedLocked(record, now) {
+			continue
+		}
+		record.decay(now, r.config.HalfLife)
+		if record.streams < 1 && record.empty < 1 && now.Sub(record.lastBlock) > peerForgetAfter {
+			delete(r.peers, destination)
+		}
+	}
+}
+
+// Blocked lists the blocked destinations, the latest block first.
+func (r *PeerReputation) Blocked() []BlockedPeer {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.now()
+	var blocked []BlockedPeer
+	for _, record := range r.peers {
+		if r.blockedLocked(record, now) {
+			blocked = append(blocked, *record.blocked)
+		}
+	}
+	sort.Slice(blocked, func(i, j int) bool {
+		if !blocked[i].BlockedAt.Equal(blocked[j].BlockedAt) {
+			return blocked[i].BlockedAt.After(blocked[j].BlockedAt)
+		}
+		return blocked[i].Destination < blocked[j].Destination
+	})
+	return blocked
+}
+
+// Unblock lifts the block of a destination, returning the lifted block and
+// whether there was one. Its offences are remembered.
+func (r *PeerReputation) Unblock(destination string) (BlockedPeer, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	record, exists := r.peers[destination]
+	if !exists || !r.blockedLocked(record, r.now()) {
+		return BlockedPeer{}, false
+	}
+	lifted := *record.blocked
+	record.blocked = nil
+	log.Printf("Unblocked I2P destination %s", destination)
+	return lifted, true
+}
+
+// Stats returns a summary of inbound peer blocking.
+func (r *PeerReputation) Stats() PeerReputationStats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.now()
+	stats := PeerReputationStats{Tracked: len(r.peers), Blocks: r.blocks, Rejected: r.rejected}
+	for _, record := range r.peers {
+		if r.blockedLocked(record, now) {
+			stats.Blocked++
+		}
+	}
+	return stats
+}
+
+// reputationListener refuses streams of blocked destinations and scores
+// the others.
+type reputationListener struct {
+	net.Listener
+	peers  *PeerReputation
+	tunnel string
+}
+
+// Accept returns the next stream of a destination that is not blocked.
+func (l *reputationListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		destination := ""
+		if addr := conn.RemoteAddr(); addr != nil {
+			destination = addr.String()
+		}
+		if !l.peers.admit(destination, l.tunnel) {
+			conn.Close()
+			continue
+		}
+		return &reputationConn{Conn: conn, listener: l, destination: destination}, nil
+	}
+}
+
+// reputationConn reports the end of a stream to the reputation tracker.
+type reputationConn struct {
+	net.Conn
+	listener    *reputationListener
+	destination string
+	received    atomic.Bool
+	closeOnce   sync.Once
+}
+
+// Read marks the stream as having carried data from the peer.
+func (c *reputationConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.received.Store(true)
+	}
+	return n, err
+}
+
+// Close closes the stream and scores it once.
+func (c *reputationConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.listener.peers.closed(c.destination, c.listener.tunnel, !c.received.Load())
+	})
+	return c.Conn.Close()
+}
+
+// PeerReputation returns the reputation tracker shared by the server
+// tunnels.
+func (tm *TunnelManager) PeerReputation() *PeerReputation {
+	return tm.peers
+}
@@ -0,0 +1,215 @@
+package i2p
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// newTestReputation returns a tracker with the default settings and a clock
+// advanced by the returned function.
+func newTestReputation(t *testing.T) (*PeerReputation, func(time.Duration)) {
+	t.Helper()
+
+	r := NewPeerReputation(DefaultPeerReputationConfig())
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+	return r, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestPeerReputation_Flood(t *testing.T) {
+	r, advance := newTestReputation(t)
+
+	for i := 0; i < 59; i++ {
+		if !r.admit("peer.b32.i2p", "web") {
+			t.Fatalf("Expected stream %d to be admitted", i+1)
+		}
+	}
+	if r.admit("peer.b32.i2p", "web") {
+		t.Fatal("Expected the 60th stream in a burst to be refused")
+	}
+	if !r.admit("other.b32.i2p", "web") {
+		t.Error("Expected other destinations to be admitted")
+	}
+
+	blocked := r.Blocked()
+	if len(blocked) != 1 || blocked[0].Reason != PeerBlockFlood || blocked[0].Tunnel != "web" || blocked[0].Offences != 1 {
+		t.Fatalf("Unexpected blocks %+v", blocked)
+	}
+	if until := blocked[0].Until.Sub(blocked[0].BlockedAt); until != 5*time.Minute {
+		t.Errorf("Expected a 5m block, got %s", until)
+	}
+
+	r.admit("peer.b32.i2p", "web")
+	if stats := r.Stats(); stats.Blocked != 1 || stats.Blocks != 1 || stats.Rejected != 2 || stats.Tracked != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// The block expires, and a repeat offence blocks twice as long
+	advance(5 * time.Minute)
+	if !r.admit("peer.b32.i2p", "web") {
+		t.Fatal("Expected the block to expire")
+	}
+	for i := 0; i < 60; i++ {
+		r.admit("peer.b32.i2p", "web")
+	}
+	blocked = r.Blocked()
+	if len(blocked) != 1 || blocked[0].Offences != 2 || blocked[0].Until.Sub(blocked[0].BlockedAt) != 10*time.Minute {
+		t.Errorf("Expected a 10m block for the second offence, got %+v", blocked)
+	}
+}
+
+func TestPeerReputation_Decay(t *testing.T) {
+	r, advance := newTestReputation(t)
+
+	// 30 streams per minute stay below the sustained flood rate
+	for minute := 0; minute < 10; minute++ {
+		for i := 0; i < 30; i++ {
+			if !r.admit("peer.b32.i2p", "web") {
+				t.Fatalf("Expected stream %d of minute %d to be admitted", i+1, minute+1)
+			}
+			advance(2 * time.Second)
+		}
+	}
+}
+
+func TestPeerReputation_EmptyStreams(t *testing.T) {
+	r, _ := newTestReputation(t)
+
+	for i := 0; i < 19; i++ {
+		r.admit("peer.b32.i2p", "web")
+		r.closed("peer.b32.i2p", "web", true)
+		r.closed("peer.b32.i2p", "web", false)
+	}
+	if !r.admit("peer.b32.i2p", "web") {
+		t.Fatal("Expected the peer to be admitted before its 20th empty stream")
+	}
+	r.closed("peer.b32.i2p", "web", true)
+
+	blocked := r.Blocked()
+	if len(blocked) != 1 || blocked[0].Reason != PeerBlockEmptyStreams || blocked[0].Rejected != 0 {
+		t.Fatalf("Unexpected blocks %+v", blocked)
+	}
+
+	peer, ok := r.Unblock("peer.b32.i2p")
+	if !ok || peer.Destination != "peer.b32.i2p" {
+		t.Fatalf("Expected the block to be lifted, got %+v", peer)
+	}
+	if _, ok := r.Unblock("peer.b32.i2p"); ok {
+		t.Error("Expected unblocking twice to report no block")
+	}
+	if !r.admit("peer.b32.i2p", "web") {
+		t.Error("Expected an unblocked peer to be admitted")
+	}
+}
+
+func TestPeerReputation_SetConfig(t *testing.T) {
+	r, _ := newTestReputation(t)
+
+	for i := 0; i < 60; i++ {
+		r.admit("peer.b32.i2p", "web")
+	}
+	if err := r.SetConfig(PeerReputationConfig{}); err != nil {
+		t.Fatalf("Failed to disable blocking: %v", err)
+	}
+	if !r.admit("peer.b32.i2p", "web") || len(r.Blocked()) != 0 {
+		t.Error("Expected disabling blocking to lift blocks")
+	}
+
+	invalid := DefaultPeerReputationConfig()
+	invalid.FloodStreams = 0
+	if err := r.SetConfig(invalid); err == nil {
+		t.Error("Expected a zero flood threshold to be rejected")
+	}
+	invalid = DefaultPeerReputationConfig()
+	invalid.BlockDuration = -time.Minute
+	if err := r.SetConfig(invalid); err == nil {
+		t.Error("Expected a negative block duration to be rejected")
+	}
+}
+
+// testAddr is a peer address of a stream accepted by testListener.
+type testAddr string
+
+func (a testAddr) Network() string { return "i2p" }
+func (a testAddr) String() string  { return string(a) }
+
+// testStream is an accepted stream with a peer address.
+type testStream struct {
+	net.Conn
+	peer testAddr
+}
+
+func (s *testStream) RemoteAddr() net.Addr { return s.peer }
+
+// testListener hands out the streams sent to it.
+type testListener struct {
+	streams chan net.Conn
+}
+
+func (l *testListener) Accept() (net.Conn, error) {
+	conn, ok := <-l.streams
+	if !ok {
+		return nil, errors.New("listener closed")
+	}
+	return conn, nil
+}
+
+func (l *testListener) Close() error   { return nil }
+func (l *testListener) Addr() net.Addr { return testAddr("local") }
+
+func TestReputationListener(t *testing.T) {
+	r, _ := newTestReputation(t)
+	config := DefaultPeerReputationConfig()
+	config.EmptyStreams = 2
+	r.SetConfig(config)
+
+	inner := &testListener{streams: make(chan net.Conn, 4)}
+	listener := &reputationListener{Listener: inner, peers: r, tunnel: "web"}
+
+	var peerEnds []net.Conn
+	for i := 0; i < 3; i++ {
+		local, remote := net.Pipe()
+		peerEnds = append(peerEnds, remote)
+		inner.streams <- &testStream{Conn: local, peer: "peer.b32.i2p"}
+	}
+	local, remote := net.Pipe()
+	inner.streams <- &testStream{Conn: local, peer: "other.b32.i2p"}
+	defer remote.Close()
+
+	// A stream carrying data is not empty
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	go peerEnds[0].Write([]byte("GET / HTTP/1.0\r\n\r\n"))
+	buf := make([]byte, 64)
+	if _, err := conn.Read(buf); err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	conn.Close()
+	conn.Close()
+
+	// Two streams closed without data block the peer
+	for i := 1; i < 3; i++ {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Fatalf("Accept failed: %v", err)
+		}
+		conn.Close()
+	}
+	if blocked := r.Blocked(); len(blocked) != 1 || blocked[0].Reason != PeerBlockEmptyStreams {
+		t.Fatalf("Expected the peer to be blocked, got %+v", blocked)
+	}
+
+	close(inner.streams)
+	conn, err = listener.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	if conn.RemoteAddr().String() != "other.b32.i2p" {
+		t.Errorf("Expected a stream of another peer, got %s", conn.RemoteAddr())
+	}
+	conn.Close()
+}
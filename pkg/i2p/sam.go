@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"strconv"
 	"time"
 
 	sam3 "github.com/go-i2p/go-sam-go"
@@ -21,6 +22,10 @@ type SAMConfig struct {
 	Timeout  time.Duration `json:"timeout"`  // Connection timeout (default: 30s)
 	Username string        `json:"username"` // SAM username (optional)
 	Password string        `json:"password"` // SAM password (optional)
+	Backups  []string      `json:"backups"`  // Backup SAM bridges (host:port) used for failover
+
+	OperationRate  float64 `json:"operation_rate"`  // Sustained SAM session operations per second (0 disables pacing)
+	OperationBurst int     `json:"operation_burst"` // SAM session operations sent without pacing
 }
 
 // DefaultSAMConfig returns a default SAM configuration.
@@ -29,6 +34,9 @@ func DefaultSAMConfig() *SAMConfig {
 		Host:    "localhost",
 		Port:    7656,
 		Timeout: 30 * time.Second,
+
+		OperationRate:  DefaultSAMOperationRate,
+		OperationBurst: DefaultSAMOperationBurst,
 	}
 }
 
@@ -86,6 +94,11 @@ func (c *SAMClient) Connect(ctx context.Context) error {
 	return nil
 }
 
+// Address returns the host:port of the SAM bridge this client targets.
+func (c *SAMClient) Address() string {
+	return net.JoinHostPort(c.config.Host, strconv.Itoa(c.config.Port))
+}
+
 // IsConnected returns true if the client is connected to the SAM bridge.
 func (c *SAMClient) IsConnected() bool {
 	return c.sam != nil
@@ -146,6 +159,14 @@ func validateSAMConfig(config *SAMConfig) error {
 		return fmt.Errorf("timeout must be positive, got %v", config.Timeout)
 	}
 
+	if config.OperationRate < 0 {
+		return fmt.Errorf("operation rate cannot be negative, got %v", config.OperationRate)
+	}
+
+	if config.OperationRate > 0 && config.OperationBurst < 1 {
+		return fmt.Errorf("operation burst must be at least 1 when pacing is enabled, got %d", config.OperationBurst)
+	}
+
 	// Validate that the host is reachable (basic check)
 	conn, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", config.Host, config.Port), config.Timeout)
 	if err != nil {
@@ -198,6 +198,26 @@ func TestValidateSAMConfig(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "negative operation rate",
+			config: &SAMConfig{
+				Host:          "localhost",
+				Port:          7656,
+				Timeout:       10 * time.Second,
+				OperationRate: -1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "operation pacing without burst",
+			config: &SAMConfig{
+				Host:          "localhost",
+				Port:          7656,
+				Timeout:       10 * time.Second,
+				OperationRate: 5,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
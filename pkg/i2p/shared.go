@@ -0,0 +1,178 @@
+// Package i2p provides shared server sub-sessions.
+//
+// By default every server tunnel of a container gets its own stream
+// sub-session, and the router builds tunnels for each of them. Containers
+// exposing many ports can share a single server sub-session instead: it
+// listens on every I2P port of the container's destination, and incoming
+// streams are routed to the exposed service by the TO_PORT they were opened
+// to, so the router load no longer grows with the number of exposed ports.
+package i2p
+
+import (
+	"fmt"
+	"log"
+
+	sam3 "github.com/go-i2p/go-sam-go"
+)
+
+// sharedServer is a server sub-session shared by the tunnels of a container.
+type sharedServer struct {
+	// session is the shared stream sub-session (nil until built)
+	session interface{ Close() error }
+	// ports maps the I2P ports the sub-session serves to their tunnel names
+	ports map[int]string
+	// ready is closed once the sub-session is built or has failed
+	ready chan struct{}
+	// err is the error building the sub-session
+	err error
+}
+
+// sharedSubSessionID returns the ID of a container's shared server sub-session.
+func sharedSubSessionID(containerID string) string {
+	return TunnelName(containerID, "shared") + "-server"
+}
+
+// SharedServerPorts returns the I2P ports served by a container's shared
+// server sub-session, or nil if the container has none.
+func (tm *TunnelManager) SharedServerPorts(containerID string) []int {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	shared, exists := tm.sharedServers[containerID]
+	if !exists {
+		return nil
+	}
+
+	var ports []int
+	for port := range shared.ports {
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// createSharedServerTunnel routes a server tunnel through the container's
+// shared server sub-session, building the sub-session for the first tunnel.
+//
+// Tunnels built concurrently wait for the first one to finish building the
+// sub-session. It must be called without tm.mutex held.
+func (tm *TunnelManager) createSharedServerTunnel(tunnel *Tunnel) error {
+	config := tunnel.config
+
+	primarySession, ok := tunnel.session.(*sam3.PrimarySession)
+	if !ok {
+		return fmt.Errorf("invalid session type for server tunnel %s", config.Name)
+	}
+
+	tm.mutex.Lock()
+	shared, build, err := tm.reserveSharedPortLocked(config.ContainerID, config.LocalPort, config.Name)
+	tm.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if build {
+		log.Printf("Creating shared server sub-session for container %s", config.ContainerID)
+		tm.pacer.wait()
+		session, err := primarySession.NewStreamSubSession(sharedSubSessionID(config.ContainerID), []string{})
+
+		tm.mutex.Lock()
+		if err != nil {
+			shared.err = fmt.Errorf("failed to create shared server sub-session: %w", err)
+		} else {
+			shared.session = session
+		}
+		tm.mutex.Unlock()
+		close(shared.ready)
+	} else {
+		<-shared.ready
+	}
+
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	if shared.err != nil {
+		tm.releaseSharedPortLocked(config.ContainerID, config.LocalPort)
+		return shared.err
+	}
+
+	config.Destination = string(primarySession.Addr())
+	tunnel.session = shared.session
+
+	log.Printf("Successfully created server tunnel %s on shared sub-session (port %d) with I2P destination: %s",
+		config.Name, config.LocalPort, config.Destination)
+	return nil
+}
+
+// reserveSharedPortLocked claims an I2P port on a container's shared server
+// sub-session, reporting whether the caller must build the sub-session.
+// The caller must hold tm.mutex.
+func (tm *TunnelManager) reserveSharedPortLocked(containerID string, port int, name string) (*sharedServer, bool, error) {
+	if tm.sharedServers == nil {
+		tm.sharedServers = make(map[string]*sharedServer)
+	}
+
+	shared, exists := tm.sharedServers[containerID]
+	if exists {
+		if existing, taken := shared.ports[port]; taken {
+			return nil, false, fmt.Errorf("port %d of container %s is already served by tunnel %s", port, containerID, existing)
+		}
+		shared.ports[port] = name
+		return shared, false, nil
+	}
+
+	shared = &sharedServer{
+		ports: map[int]string{port: name},
+		ready: make(chan struct{}),
+	}
+	tm.sharedServers[containerID] = shared
+	return shared, true, nil
+}
+
+// releaseSharedPortLocked gives up an I2P port of a container's shared server
+// sub-session, closing the sub-session with its last port.
+// The caller must hold tm.mutex.
+func (tm *TunnelManager) releaseSharedPortLocked(containerID string, port int) {
+	shared, exists := tm.sharedServers[containerID]
+	if !exists {
+		return
+	}
+
+	delete(shared.ports, port)
+	if len(shared.ports) > 0 {
+		return
+	}
+
+	delete(tm.sharedServers, containerID)
+	if shared.session != nil {
+		log.Printf("Closing shared server sub-session for container %s", containerID)
+		if err := shared.session.Close(); err != nil {
+			log.Printf("Warning: Error closing shared server sub-session for container %s: %v", containerID, err)
+		}
+	}
+}
+
+// handoffSharedServer rebuilds a container's shared server sub-session on a
+// new primary session.
+//
+// The previous sub-session is handed to the old router so it drains with the
+// container's other sub-sessions. The caller must hold tm.mutex.
+func (tm *TunnelManager) handoffSharedServer(containerID string, primarySession *sam3.PrimarySession, old *routerSession) error {
+	shared, exists := tm.sharedServers[containerID]
+	if !exists || shared.session == nil {
+		return nil
+	}
+
+	tm.pacer.wait()
+	session, err := primarySession.NewStreamSubSession(sharedSubSessionID(containerID), []string{})
+	if err != nil {
+		return fmt.Errorf("failed to create shared server sub-session: %w", err)
+	}
+
+	if old != nil {
+		old.mutex.Lock()
+		old.subSessions = append(old.subSessions, shared.session)
+		old.mutex.Unlock()
+	}
+	shared.session = session
+	return nil
+}
@@ -0,0 +1,97 @@
+package i2p
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestSharedServerPorts(t *testing.T) {
+	tm := &TunnelManager{}
+
+	shared, build, err := tm.reserveSharedPortLocked("container-123", 80, "web")
+	if err != nil || !build {
+		t.Fatalf("Expected the first port to build the sub-session, got build=%v err=%v", build, err)
+	}
+	if _, build, err := tm.reserveSharedPortLocked("container-123", 443, "tls"); err != nil || build {
+		t.Fatalf("Expected later ports to reuse the sub-session, got build=%v err=%v", build, err)
+	}
+	if _, _, err := tm.reserveSharedPortLocked("container-123", 80, "other"); err == nil {
+		t.Error("Expected error when a port is already served")
+	}
+
+	ports := tm.SharedServerPorts("container-123")
+	sort.Ints(ports)
+	if len(ports) != 2 || ports[0] != 80 || ports[1] != 443 {
+		t.Errorf("Expected ports [80 443], got %v", ports)
+	}
+
+	// The sub-session is closed with its last port
+	session := &closeCounter{}
+	shared.session = session
+	tm.releaseSharedPortLocked("container-123", 80)
+	if session.closed != 0 {
+		t.Error("Expected the sub-session to stay open while ports remain")
+	}
+	tm.releaseSharedPortLocked("container-123", 443)
+	if session.closed != 1 {
+		t.Errorf("Expected the sub-session to be closed once, got %d", session.closed)
+	}
+	if ports := tm.SharedServerPorts("container-123"); ports != nil {
+		t.Errorf("Expected no shared ports, got %v", ports)
+	}
+}
+
+func TestSharedTunnelValidation(t *testing.T) {
+	tm := &TunnelManager{}
+
+	config := &TunnelConfig{
+		Name:        "client",
+		ContainerID: "container-123",
+		Type:        TunnelTypeClient,
+		LocalPort:   8080,
+		Shared:      true,
+	}
+	if err := tm.validateTunnelConfig(config); err == nil {
+		t.Error("Expected client tunnels to reject sharing a sub-session")
+	}
+
+	config.Type = TunnelTypeServer
+	if err := tm.validateTunnelConfig(config); err != nil {
+		t.Errorf("validateTunnelConfig() unexpected error: %v", err)
+	}
+}
+
+func TestDestroySharedTunnel(t *testing.T) {
+	tm := &TunnelManager{
+		tunnels: make(map[string]*Tunnel),
+		origins: make(map[string]TunnelOrigin),
+	}
+
+	session := &closeCounter{}
+	for port, purpose := range map[int]string{80: "web", 443: "tls"} {
+		name := TunnelName("container-123", purpose)
+		shared, _, err := tm.reserveSharedPortLocked("container-123", port, name)
+		if err != nil {
+			t.Fatalf("Failed to reserve port %d: %v", port, err)
+		}
+		shared.session = session
+		tm.tunnels[name] = &Tunnel{
+			config:  &TunnelConfig{Name: name, ContainerID: "container-123", Type: TunnelTypeServer, LocalPort: port, Shared: true},
+			session: session,
+			active:  true,
+		}
+	}
+
+	if err := tm.DestroyTunnel(TunnelName("container-123", "web")); err != nil {
+		t.Fatalf("Failed to destroy tunnel: %v", err)
+	}
+	if session.closed != 0 {
+		t.Error("Destroying one shared tunnel must not close the sub-session")
+	}
+	if err := tm.DestroyTunnel(TunnelName("container-123", "tls")); err != nil {
+		t.Fatalf("Failed to destroy tunnel: %v", err)
+	}
+	if session.closed != 1 {
+		t.Errorf("Expected the sub-session to be closed with the last tunnel, got %d closes", session.closed)
+	}
+}
@@ -22,9 +22,13 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
+	"sort"
+	"sync"
 	"time"
 
 	sam3 "github.com/go-i2p/go-sam-go"
+	"github.com/go-i2p/i2pkeys"
 )
 
 // TunnelType represents the type of I2P tunnel.
@@ -45,6 +49,10 @@ type TunnelConfig struct {
 	// ContainerID is the Docker container ID this tunnel belongs to
 	ContainerID string `json:"container_id"`
 
+	// Purpose describes what the tunnel is for (e.g. "web-80"), recorded as
+	// the tunnel's origin; Name is usually TunnelName(ContainerID, Purpose)
+	Purpose string `json:"purpose,omitempty"`
+
 	// Type specifies whether this is a client or server tunnel
 	Type TunnelType `json:"type"`
 
@@ -62,6 +70,17 @@ type TunnelConfig struct {
 
 	// Options contains I2P-specific tunnel options
 	Options TunnelOptions `json:"options"`
+
+	// HTTPHeaders are headers added to HTTP responses served by server tunnels
+	HTTPHeaders map[string]string `json:"http_headers,omitempty"`
+
+	// InboundOnly restricts a server tunnel to answering inbound streams; it
+	// keeps only the outbound capacity streaming replies need and never dials out
+	InboundOnly bool `json:"inbound_only,omitempty"`
+
+	// Shared routes a server tunnel through the container's shared server
+	// sub-session by its LocalPort instead of giving it a sub-session of its own
+	Shared bool `json:"shared,omitempty"`
 }
 
 // TunnelOptions contains I2P-specific configuration options for tunnels.
@@ -92,6 +111,20 @@ type TunnelOptions struct {
 
 	// CloseIdleTime specifies idle timeout in minutes (default: 10)
 	CloseIdleTime int `json:"close_idle_time,omitempty"`
+
+	// ReduceIdle shrinks the tunnel pools of idle sessions (default: false)
+	ReduceIdle bool `json:"reduce_idle,omitempty"`
+
+	// ReduceIdleTime specifies the idle time in minutes before tunnel pools
+	// are shrunk (default: 20)
+	ReduceIdleTime int `json:"reduce_idle_time,omitempty"`
+
+	// ReduceQuantity specifies the number of tunnels kept while shrunk (default: 1)
+	ReduceQuantity int `json:"reduce_quantity,omitempty"`
+
+	// Extra holds further I2CP, tunnel pool and streaming options passed to
+	// the router unchanged, e.g. "inbound.IPRestriction": "2"
+	Extra map[string]string `json:"extra,omitempty"`
 }
 
 // DefaultTunnelOptions returns default tunnel options optimized for Docker containers.
@@ -106,14 +139,30 @@ func DefaultTunnelOptions() TunnelOptions {
 		EncryptLeaseset: false,
 		CloseIdle:       true,
 		CloseIdleTime:   10,
+		ReduceIdleTime:  20,
+		ReduceQuantity:  1,
 	}
 }
 
+// InboundOnlyTunnelOptions reduces options for an inbound-only server tunnel.
+//
+// A single outbound tunnel without backups is kept because streaming replies
+// still travel through the destination's outbound tunnels.
+func InboundOnlyTunnelOptions(options TunnelOptions) TunnelOptions {
+	options.OutboundTunnels = 1
+	options.OutboundBackups = 0
+	return options
+}
+
 // Tunnel represents an active I2P tunnel.
 type Tunnel struct {
-	config  *TunnelConfig
-	session interface{} // Will hold either StreamSession or DatagramSession
-	active  bool
+	config      *TunnelConfig
+	session     interface{}     // Will hold either StreamSession or DatagramSession
+	router      *routerSession  // SAM router session the tunnel currently dials through
+	holdsRouter bool            // True if the tunnel pins a draining router session
+	peers       *PeerReputation // Scores the peers of inbound streams
+	active      bool
+	created     time.Time // When the tunnel was created
 }
 
 // TunnelManager manages I2P tunnels and sessions for containers.
@@ -149,21 +198,57 @@ type Tunnel struct {
 type TunnelManager struct {
 	samConfig           *SAMConfig                      // Template configuration for creating SAM clients
 	tunnels             map[string]*Tunnel              // Active tunnels by name
+	origins             map[string]TunnelOrigin         // Origin of each active tunnel by name
 	containerSessions   map[string]*sam3.PrimarySession // Primary sessions by container ID
 	containerSAMClients map[string]*SAMClient           // SAM clients by container ID
+	containerRouters    map[string]*routerSession       // Active router sessions by container ID
+	drainingRouters     map[string][]*routerSession     // Router sessions draining after failover
+	building            map[string]string               // Container IDs of tunnels being built, by name
+	sharedServers       map[string]*sharedServer        // Shared server sub-sessions by container ID
+	sessionKeys         map[string]i2pkeys.I2PKeys      // Supplied session keys by container ID
+	activeRouter        int                             // Index of the SAM router used for new sessions
+	pacer               samPacer                        // Paces SAM session operations of all containers
+	peers               *PeerReputation                 // Scores and blocks inbound peers of all server tunnels
+	sessionOptions      *TunnelOptions                  // Tunnel options of new primary sessions (nil for minimal sessions)
+	containerOverrides  map[string]map[string]string    // Tunnel option overrides by container ID
+	maintenance         MaintenanceConfig               // When and how sessions are rebuilt for maintenance
+	keygen              *keyGeneration                  // Times key generations and holds the key pool
+
+	// mutex protects the maps and active router. It is not held while a
+	// tunnel's sub-session is built, so tunnels can be created concurrently.
+	mutex sync.Mutex
 }
 
-// NewTunnelManager creates a new tunnel manager with the given SAM configuration.
+// TunnelManagerOptions configures a TunnelManager.
+//
+// The zero value is ready to use: sessions connect to the SAM bridge of
+// DefaultSAMConfig.
+type TunnelManagerOptions struct {
+	// SAM is the SAM bridge, and its backups, sessions connect to (nil uses DefaultSAMConfig)
+	SAM *SAMConfig
+}
+
+// NewTunnelManager creates a new tunnel manager with the given options.
 //
 // Instead of a single SAM client, this manager will create individual SAM clients
 // for each container to ensure proper isolation.
-func NewTunnelManager(samClient *SAMClient) *TunnelManager {
-	return &TunnelManager{
-		samConfig:           samClient.config,
+func NewTunnelManager(options TunnelManagerOptions) *TunnelManager {
+	tm := &TunnelManager{
+		samConfig:           options.SAM,
 		tunnels:             make(map[string]*Tunnel),
+		origins:             make(map[string]TunnelOrigin),
 		containerSessions:   make(map[string]*sam3.PrimarySession),
 		containerSAMClients: make(map[string]*SAMClient),
-	}
+		containerRouters:    make(map[string]*routerSession),
+		drainingRouters:     make(map[string][]*routerSession),
+		building:            make(map[string]string),
+		sharedServers:       make(map[string]*sharedServer),
+		sessionKeys:         make(map[string]i2pkeys.I2PKeys),
+		peers:               NewPeerReputation(DefaultPeerReputationConfig()),
+		keygen:              newKeyGeneration(),
+	}
+	tm.pacer.setLimits(samOperationLimits(options.SAM))
+	return tm
 }
 
 // CreateTunnel creates a new I2P tunnel with the given configuration.
@@ -182,6 +267,10 @@ func NewTunnelManager(samClient *SAMClient) *TunnelManager {
 //
 // Each tunnel gets its own sub-session but shares the container's primary session,
 // ensuring both isolation (separate tunnel handling) and efficiency (shared identity).
+//
+// CreateTunnel is safe for concurrent use. Sub-sessions are built without
+// holding the manager's lock, so several tunnels of a container can be built
+// in parallel.
 func (tm *TunnelManager) CreateTunnel(config *TunnelConfig) (*Tunnel, error) {
 	if config == nil {
 		return nil, fmt.Errorf("tunnel configuration cannot be nil")
@@ -191,65 +280,87 @@ func (tm *TunnelManager) CreateTunnel(config *TunnelConfig) (*Tunnel, error) {
 		return nil, fmt.Errorf("invalid tunnel configuration: %w", err)
 	}
 
-	// Check if tunnel with this name already exists
+	tm.mutex.Lock()
+
+	// Check if tunnel with this name already exists or is being built
 	if _, exists := tm.tunnels[config.Name]; exists {
+		origin := tm.origins[config.Name]
+		tm.mutex.Unlock()
+		if origin.ContainerID != config.ContainerID || origin.Purpose != config.Purpose {
+			return nil, fmt.Errorf("tunnel name %s collides with existing tunnel for container %s (%s)",
+				config.Name, origin.ContainerID, origin.Purpose)
+		}
 		return nil, fmt.Errorf("tunnel with name %s already exists", config.Name)
 	}
+	if _, building := tm.building[config.Name]; building {
+		tm.mutex.Unlock()
+		return nil, fmt.Errorf("tunnel with name %s is already being created", config.Name)
+	}
 
-	// Get or create container session (this will handle SAM client creation)
-	session, err := tm.GetOrCreateContainerSession(config.ContainerID)
+	// Get or create container session (this will handle SAM client creation).
+	// Holding the lock makes concurrent tunnels of a container share one session.
+	session, err := tm.getOrCreateContainerSessionLocked(config.ContainerID)
 	if err != nil {
+		tm.mutex.Unlock()
 		return nil, fmt.Errorf("failed to get container session: %w", err)
 	}
 
+	// Reserve the name while the sub-session is built without the lock
+	if tm.building == nil {
+		tm.building = make(map[string]string)
+	}
+	tm.building[config.Name] = config.ContainerID
+	tm.mutex.Unlock()
+
 	// Create the appropriate tunnel type
 	tunnel := &Tunnel{
-		config: config,
-		active: false,
+		config:  config,
+		peers:   tm.peers,
+		active:  false,
+		created: time.Now(),
 	}
 	tunnel.session = session
 
-	// Track if this is the first tunnel for this container (before creation attempt)
-	// This is used for cleanup if tunnel creation fails
-	isFirstTunnel := tm.countContainerTunnels(config.ContainerID) == 0
-
 	switch config.Type {
 	case TunnelTypeClient:
-		if err := tm.createClientTunnel(tunnel); err != nil {
-			// Clean up container session if this was the first tunnel attempt
-			// This prevents orphaned sessions consuming resources
-			if isFirstTunnel {
-				if cleanupErr := tm.DestroyContainerSession(config.ContainerID); cleanupErr != nil {
-					// Log cleanup error but return original error
-					log.Printf("Failed to cleanup container session after tunnel creation failure: %v", cleanupErr)
-				}
-			}
-			return nil, fmt.Errorf("failed to create client tunnel: %w", err)
+		err = tm.createClientTunnel(tunnel)
+		if err != nil {
+			err = fmt.Errorf("failed to create client tunnel: %w", err)
 		}
 	case TunnelTypeServer:
-		if err := tm.createServerTunnel(tunnel); err != nil {
-			// Clean up container session if this was the first tunnel attempt
-			// This prevents orphaned sessions consuming resources
-			if isFirstTunnel {
-				if cleanupErr := tm.DestroyContainerSession(config.ContainerID); cleanupErr != nil {
-					// Log cleanup error but return original error
-					log.Printf("Failed to cleanup container session after tunnel creation failure: %v", cleanupErr)
-				}
-			}
-			return nil, fmt.Errorf("failed to create server tunnel: %w", err)
+		if config.Shared {
+			err = tm.createSharedServerTunnel(tunnel)
+		} else {
+			err = tm.createServerTunnel(tunnel)
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to create server tunnel: %w", err)
 		}
 	default:
-		// Clean up container session if this was the first tunnel attempt
-		if isFirstTunnel {
-			if cleanupErr := tm.DestroyContainerSession(config.ContainerID); cleanupErr != nil {
-				log.Printf("Failed to cleanup container session after unknown tunnel type: %v", cleanupErr)
+		err = fmt.Errorf("unknown tunnel type: %s", config.Type)
+	}
+
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	delete(tm.building, config.Name)
+
+	if err != nil {
+		// Clean up the container session if no other tunnel uses it or is
+		// being built on it. This prevents orphaned sessions consuming resources
+		if tm.countContainerTunnelsLocked(config.ContainerID) == 0 {
+			if cleanupErr := tm.destroyContainerSessionLocked(config.ContainerID); cleanupErr != nil {
+				// Log cleanup error but return original error
+				log.Printf("Failed to cleanup container session after tunnel creation failure: %v", cleanupErr)
 			}
 		}
-		return nil, fmt.Errorf("unknown tunnel type: %s", config.Type)
+		return nil, err
 	}
 
 	// Register the tunnel
 	tm.tunnels[config.Name] = tunnel
+	tm.origins[config.Name] = TunnelOrigin{ContainerID: config.ContainerID, Purpose: config.Purpose}
+	tunnel.router = tm.containerRouters[config.ContainerID]
 	tunnel.active = true
 
 	return tunnel, nil
@@ -257,35 +368,75 @@ func (tm *TunnelManager) CreateTunnel(config *TunnelConfig) (*Tunnel, error) {
 
 // GetTunnel retrieves a tunnel by name.
 func (tm *TunnelManager) GetTunnel(name string) (*Tunnel, bool) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
 	tunnel, exists := tm.tunnels[name]
 	return tunnel, exists
 }
 
-// ListTunnels returns a list of all tunnel names.
+// TunnelOrigin returns what an active tunnel's name was generated from.
+func (tm *TunnelManager) TunnelOrigin(name string) (TunnelOrigin, bool) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	origin, exists := tm.origins[name]
+	return origin, exists
+}
+
+// ListTunnels returns the names of all tunnels, sorted.
 func (tm *TunnelManager) ListTunnels() []string {
-	var names []string
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	names := make([]string, 0, len(tm.tunnels))
 	for name := range tm.tunnels {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
 
-// countContainerTunnels returns the number of tunnels for a specific container.
+// countContainerTunnelsLocked returns the number of tunnels for a specific
+// container, counting tunnels that are still being built.
 //
-// This helper method is used to detect if a tunnel is the first one for a container,
+// This helper method is used to detect if a tunnel is the only one for a container,
 // which is important for cleanup logic when tunnel creation fails.
-func (tm *TunnelManager) countContainerTunnels(containerID string) int {
+// The caller must hold tm.mutex.
+func (tm *TunnelManager) countContainerTunnelsLocked(containerID string) int {
 	count := 0
 	for _, tunnel := range tm.tunnels {
 		if tunnel.config.ContainerID == containerID {
 			count++
 		}
 	}
+	for _, buildingContainer := range tm.building {
+		if buildingContainer == containerID {
+			count++
+		}
+	}
 	return count
 }
 
+// HasContainerTunnels reports whether any tunnel of the container is still active.
+func (tm *TunnelManager) HasContainerTunnels(containerID string) bool {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	return tm.countContainerTunnelsLocked(containerID) > 0
+}
+
 // DestroyTunnel removes and cleans up a tunnel.
 func (tm *TunnelManager) DestroyTunnel(name string) error {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	return tm.destroyTunnelLocked(name)
+}
+
+// destroyTunnelLocked removes and cleans up a tunnel.
+// The caller must hold tm.mutex.
+func (tm *TunnelManager) destroyTunnelLocked(name string) error {
 	tunnel, exists := tm.tunnels[name]
 	if !exists {
 		return fmt.Errorf("tunnel %s not found", name)
@@ -293,8 +444,11 @@ func (tm *TunnelManager) DestroyTunnel(name string) error {
 
 	log.Printf("Destroying tunnel %s", name)
 
-	// Clean up the tunnel session based on its type
-	if tunnel.session != nil {
+	// Clean up the tunnel session based on its type. Shared sub-sessions are
+	// closed with the last tunnel using them
+	if tunnel.config.Shared {
+		tm.releaseSharedPortLocked(tunnel.config.ContainerID, tunnel.config.LocalPort)
+	} else if tunnel.session != nil {
 		// For stream sub-sessions, close them properly
 		// Note: We don't close the primary session here since it may be used by other tunnels
 		// The primary session is cleaned up when the container is destroyed
@@ -309,8 +463,14 @@ func (tm *TunnelManager) DestroyTunnel(name string) error {
 		}
 	}
 
+	if tunnel.holdsRouter {
+		tunnel.holdsRouter = false
+		tunnel.router.release()
+	}
+
 	tunnel.active = false
 	delete(tm.tunnels, name)
+	delete(tm.origins, name)
 
 	log.Printf("Successfully destroyed tunnel %s", name)
 	return nil
@@ -318,17 +478,20 @@ func (tm *TunnelManager) DestroyTunnel(name string) error {
 
 // DestroyAllTunnels removes and cleans up all tunnels.
 func (tm *TunnelManager) DestroyAllTunnels() error {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
 	var errors []error
 
 	for name := range tm.tunnels {
-		if err := tm.DestroyTunnel(name); err != nil {
+		if err := tm.destroyTunnelLocked(name); err != nil {
 			errors = append(errors, fmt.Errorf("failed to destroy tunnel %s: %w", name, err))
 		}
 	}
 
 	// Clean up all container sessions
 	for containerID := range tm.containerSessions {
-		if err := tm.DestroyContainerSession(containerID); err != nil {
+		if err := tm.destroyContainerSessionLocked(containerID); err != nil {
 			errors = append(errors, fmt.Errorf("failed to destroy container session %s: %w", containerID, err))
 		}
 	}
@@ -342,8 +505,8 @@ func (tm *TunnelManager) DestroyAllTunnels() error {
 
 // validateTunnelConfig validates the tunnel configuration.
 func (tm *TunnelManager) validateTunnelConfig(config *TunnelConfig) error {
-	if config.Name == "" {
-		return fmt.Errorf("tunnel name cannot be empty")
+	if err := ValidateTunnelName(config.Name); err != nil {
+		return err
 	}
 
 	if config.ContainerID == "" {
@@ -362,10 +525,21 @@ func (tm *TunnelManager) validateTunnelConfig(config *TunnelConfig) error {
 		return fmt.Errorf("invalid local port: %d", config.LocalPort)
 	}
 
+	if config.InboundOnly && config.Type != TunnelTypeServer {
+		return fmt.Errorf("only server tunnels can be inbound-only")
+	}
+
+	if config.Shared && config.Type != TunnelTypeServer {
+		return fmt.Errorf("only server tunnels can share a sub-session")
+	}
+
 	// Apply default options if not specified
 	if config.Options.InboundTunnels == 0 {
 		config.Options = DefaultTunnelOptions()
 	}
+	if config.InboundOnly {
+		config.Options = InboundOnlyTunnelOptions(config.Options)
+	}
 
 	return nil
 }
@@ -393,6 +567,7 @@ func (tm *TunnelManager) createClientTunnel(tunnel *Tunnel) error {
 	// Create a stream sub-session for this client tunnel
 	// This will be used to establish outbound connections to I2P destinations
 	// Use port-specific sub-session to avoid conflicts with multiple tunnels
+	tm.pacer.wait()
 	streamSession, err := primarySession.NewStreamSubSessionWithPort(subSessionID, []string{}, config.LocalPort, config.LocalPort)
 	if err != nil {
 		return fmt.Errorf("failed to create stream sub-session for client tunnel %s: %w", config.Name, err)
@@ -428,6 +603,7 @@ func (tm *TunnelManager) createServerTunnel(tunnel *Tunnel) error {
 	// Create a stream sub-session for this server tunnel
 	// This will create an I2P destination that can accept inbound connections
 	// Use port-specific sub-session to support multiple server tunnels per container
+	tm.pacer.wait()
 	streamSession, err := primarySession.NewStreamSubSessionWithPort(subSessionID, []string{}, config.LocalPort, config.LocalPort)
 	if err != nil {
 		return fmt.Errorf("failed to create stream sub-session for server tunnel %s: %w", config.Name, err)
@@ -467,6 +643,68 @@ func (t *Tunnel) GetLocalEndpoint() string {
 	return fmt.Sprintf("%s:%d", t.config.LocalHost, t.config.LocalPort)
 }
 
+// Dial opens an outbound I2P stream to the given destination through this tunnel.
+//
+// The destination may be a .i2p hostname, a .b32.i2p address, or a full base64
+// destination. Only client tunnels backed by a stream sub-session can dial.
+func (t *Tunnel) Dial(destination string) (net.Conn, error) {
+	if !t.active {
+		return nil, fmt.Errorf("tunnel %s is not active", t.config.Name)
+	}
+	if t.config.InboundOnly {
+		return nil, fmt.Errorf("tunnel %s is inbound-only", t.config.Name)
+	}
+
+	dialer, ok := t.session.(interface {
+		Dial(destination string) (*sam3.SAMConn, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("tunnel %s does not support outbound connections", t.config.Name)
+	}
+
+	conn, err := dialer.Dial(destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s via tunnel %s: %w", destination, t.config.Name, err)
+	}
+
+	// Streams hold a reference on their router so failover can drain them
+	if t.router != nil {
+		return t.router.track(conn), nil
+	}
+
+	return conn, nil
+}
+
+// Listen accepts inbound I2P streams opened to this tunnel's destination.
+//
+// Only server tunnels backed by a stream sub-session can listen. Streams are
+// accepted until the returned listener is closed; streams of destinations
+// blocked by the manager's PeerReputation are refused.
+func (t *Tunnel) Listen() (net.Listener, error) {
+	if !t.active {
+		return nil, fmt.Errorf("tunnel %s is not active", t.config.Name)
+	}
+	if t.config.Type != TunnelTypeServer {
+		return nil, fmt.Errorf("tunnel %s is not a server tunnel", t.config.Name)
+	}
+
+	listener, ok := t.session.(interface {
+		Listen() (*sam3.StreamListener, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("tunnel %s does not support inbound connections", t.config.Name)
+	}
+
+	streams, err := listener.Listen()
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on tunnel %s: %w", t.config.Name, err)
+	}
+	if t.peers == nil {
+		return streams, nil
+	}
+	return &reputationListener{Listener: streams, peers: t.peers, tunnel: t.config.Name}, nil
+}
+
 // GetOrCreateContainerSession gets or creates a primary I2P session for a container.
 //
 // This method implements the "one SAM connection per container" architecture:
@@ -492,6 +730,15 @@ func (t *Tunnel) GetLocalEndpoint() string {
 //   - Primary session is reused for all tunnels within the same container
 //   - Cleanup via DestroyContainerSession() when container is removed
 func (tm *TunnelManager) GetOrCreateContainerSession(containerID string) (*sam3.PrimarySession, error) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	return tm.getOrCreateContainerSessionLocked(containerID)
+}
+
+// getOrCreateContainerSessionLocked returns the container's primary session,
+// creating it if needed. The caller must hold tm.mutex.
+func (tm *TunnelManager) getOrCreateContainerSessionLocked(containerID string) (*sam3.PrimarySession, error) {
 	// Check if we already have a session for this container
 	if session, exists := tm.containerSessions[containerID]; exists {
 		log.Printf("Reusing existing primary session for container %s", containerID)
@@ -501,60 +748,105 @@ func (tm *TunnelManager) GetOrCreateContainerSession(containerID string) (*sam3.
 	// Create a new SAM client for this container
 	log.Printf("Creating new SAM client and primary session for container %s", containerID)
 
-	samClient, err := NewSAMClient(tm.samConfig)
+	samClient, session, err := tm.connectContainerSession(containerID)
+	if err != nil && len(tm.routerAddresses()) > 1 {
+		// The active router may be down; fail over and retry on the backup
+		log.Printf("Warning: SAM router %s failed for container %s: %v", tm.activeRouterLocked(), containerID, err)
+		if failoverErr := tm.failoverLocked(); failoverErr != nil {
+			log.Printf("Warning: SAM router failover failed: %v", failoverErr)
+		} else {
+			samClient, session, err = tm.connectContainerSession(containerID)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Store both the session and SAM client
+	tm.containerSessions[containerID] = session
+	tm.containerSAMClients[containerID] = samClient
+	tm.containerRouters[containerID] = tm.newRouterSession(containerID, samClient, session)
+
+	return session, nil
+}
+
+// connectContainerSession connects a new SAM client to the active router and
+// creates a primary session for the container, with the keys supplied for it
+// or fresh ones. Supplied keys keep the destination across router failover.
+// The caller must hold tm.mutex.
+func (tm *TunnelManager) connectContainerSession(containerID string) (*SAMClient, *sam3.PrimarySession, error) {
+	samConfig, err := tm.activeSAMConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	samClient, err := NewSAMClient(samConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create SAM client for container %s: %w", containerID, err)
+		return nil, nil, fmt.Errorf("failed to create SAM client for container %s: %w", containerID, err)
 	}
 
 	// Connect the SAM client
 	ctx := context.Background()
 	if err := samClient.Connect(ctx); err != nil {
-		return nil, fmt.Errorf("failed to connect SAM client for container %s: %w", containerID, err)
+		return nil, nil, fmt.Errorf("failed to connect SAM client for container %s: %w", containerID, err)
 	}
 
 	// Verify SAM connection was established (defensive check)
 	if !samClient.IsConnected() {
-		return nil, fmt.Errorf("SAM client for container %s connected but sam field is nil", containerID)
+		return nil, nil, fmt.Errorf("SAM client for container %s connected but sam field is nil", containerID)
 	}
 
-	// Generate a unique session ID for this container
-	sessionID := fmt.Sprintf("cont_%s_%d", containerID, time.Now().UnixNano())
+	// Generate a unique session ID for this container. The full container ID
+	// would push the ID past SAM limits, so only its short form is used.
+	sessionID := fmt.Sprintf("cont_%s_%d", ShortID(containerID), time.Now().UnixNano())
 
-	// Generate I2P keys for this session
-	keys, err := samClient.sam.NewKeys()
-	if err != nil {
-		samClient.Disconnect()
-		return nil, fmt.Errorf("failed to generate I2P keys for container %s: %w", containerID, err)
-	}
-	log.Printf("DEBUG: Generated new I2P keys for container %s", containerID)
-
-	// Create minimal options for the session to avoid potential issues
-	options := []string{
-		"inbound.quantity=1",  // Reduce to 1 tunnel for testing
-		"outbound.quantity=1", // Reduce to 1 tunnel for testing
+	// Generate I2P keys for this session unless they were supplied
+	keys, supplied := tm.sessionKeys[containerID]
+	if !supplied {
+		keys, err = tm.generateSessionKeys(containerID, samClient)
+		if err != nil {
+			samClient.Disconnect()
+			return nil, nil, fmt.Errorf("failed to generate I2P keys for container %s: %w", containerID, err)
+		}
 	}
 
 	// Create the primary session using the SAM client
-	session, err := samClient.sam.NewPrimarySession(sessionID, keys, options)
+	tm.pacer.wait()
+	session, err := samClient.sam.NewPrimarySession(sessionID, keys, tm.sessionSAMOptions(containerID))
 	if err != nil {
 		samClient.Disconnect()
-		return nil, fmt.Errorf("failed to create primary session for container %s: %w", containerID, err)
+		return nil, nil, fmt.Errorf("failed to create primary session for container %s: %w", containerID, err)
 	}
 
-	// Store both the session and SAM client
-	tm.containerSessions[containerID] = session
-	tm.containerSAMClients[containerID] = samClient
-
 	log.Printf("Successfully created primary session for container %s with session ID %s", containerID, sessionID)
-	return session, nil
+	return samClient, session, nil
 }
 
 // DestroyContainerSession removes and cleans up a container's primary session.
 //
 // This should be called when a container is removed to clean up I2P resources.
 func (tm *TunnelManager) DestroyContainerSession(containerID string) error {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	return tm.destroyContainerSessionLocked(containerID)
+}
+
+// destroyContainerSessionLocked removes and cleans up a container's primary
+// session. The caller must hold tm.mutex.
+func (tm *TunnelManager) destroyContainerSessionLocked(containerID string) error {
 	session, exists := tm.containerSessions[containerID]
 
+	// Streams still draining on previous routers are closed with the container
+	tm.closeDrainingRouters(containerID)
+	delete(tm.containerRouters, containerID)
+
+	// The shared server sub-session is closed with the primary session
+	delete(tm.sharedServers, containerID)
+	delete(tm.sessionKeys, containerID)
+	delete(tm.containerOverrides, containerID)
+	tm.keygen.forget(containerID)
+
 	// Always attempt to clean up SAM client, even if session doesn't exist
 	// This handles cases where session creation partially failed
 	if samClient, samExists := tm.containerSAMClients[containerID]; samExists {
@@ -584,11 +876,32 @@ func (tm *TunnelManager) DestroyContainerSession(containerID string) error {
 	return nil
 }
 
-// ListContainerSessions returns a list of container IDs that have active sessions.
+// ListContainerSessions returns the IDs of the containers that have active
+// sessions, sorted.
 func (tm *TunnelManager) ListContainerSessions() []string {
-	var containerIDs []string
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	containerIDs := make([]string, 0, len(tm.containerSessions))
 	for containerID := range tm.containerSessions {
 		containerIDs = append(containerIDs, containerID)
 	}
+	sort.Strings(containerIDs)
 	return containerIDs
 }
+
+// KnownDestination returns the base64 destination owner's tunnels are served
+// from, if it is known before they are created: the destination of the
+// owner's session, or of the keys supplied for it.
+func (tm *TunnelManager) KnownDestination(owner string) (string, bool) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	if session, exists := tm.containerSessions[owner]; exists {
+		return string(session.Addr()), true
+	}
+	if keys, supplied := tm.sessionKeys[owner]; supplied {
+		return string(keys.Addr()), true
+	}
+	return "", false
+}
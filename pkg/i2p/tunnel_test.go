@@ -19,17 +19,17 @@ func TestDefaultTunnelOptions(t *testing.T) {
 		EncryptLeaseset: false,
 		CloseIdle:       true,
 		CloseIdleTime:   10,
+		ReduceIdleTime:  20,
+		ReduceQuantity:  1,
 	}
 
-	if opts != expected {
+	if !opts.Equal(expected) {
 		t.Errorf("DefaultTunnelOptions() = %+v, want %+v", opts, expected)
 	}
 }
 
 func TestNewTunnelManager(t *testing.T) {
-	client, _ := NewSAMClient(nil) // Error expected, but we just need the struct
-
-	tm := NewTunnelManager(client)
+	tm := NewTunnelManager(TunnelManagerOptions{})
 
 	if tm == nil {
 		t.Fatal("NewTunnelManager() returned nil")
@@ -50,6 +50,11 @@ func TestNewTunnelManager(t *testing.T) {
 	if len(tm.containerSessions) != 0 {
 		t.Errorf("Expected empty container sessions map, got %d entries", len(tm.containerSessions))
 	}
+
+	// The zero options connect to the default SAM bridge
+	if addresses := tm.routerAddresses(); len(addresses) != 1 || addresses[0] != "localhost:7656" {
+		t.Errorf("Expected the default SAM bridge, got %v", addresses)
+	}
 }
 
 // isI2PAvailable checks if I2P SAM is available for testing
@@ -72,8 +77,7 @@ func isI2PAvailable() bool {
 }
 
 func TestTunnelManagerCreateTunnel(t *testing.T) {
-	client, _ := NewSAMClient(nil) // Error expected for validation purposes
-	tm := NewTunnelManager(client)
+	tm := NewTunnelManager(TunnelManagerOptions{})
 
 	// Check if I2P is available for environment-aware testing
 	i2pAvailable := isI2PAvailable()
@@ -202,8 +206,7 @@ func TestTunnelManagerCreateTunnel(t *testing.T) {
 }
 
 func TestTunnelManagerOperations(t *testing.T) {
-	client, _ := NewSAMClient(nil)
-	tm := NewTunnelManager(client)
+	tm := NewTunnelManager(TunnelManagerOptions{})
 
 	// Test with empty tunnel manager
 	tunnels := tm.ListTunnels()
@@ -234,8 +237,7 @@ func TestTunnelManagerOperations(t *testing.T) {
 }
 
 func TestTunnelConfigValidation(t *testing.T) {
-	client, _ := NewSAMClient(nil)
-	tm := NewTunnelManager(client)
+	tm := NewTunnelManager(TunnelManagerOptions{})
 
 	tests := []struct {
 		name    string
@@ -301,6 +303,61 @@ func TestTunnelConfigValidation(t *testing.T) {
 	}
 }
 
+func TestInboundOnlyTunnels(t *testing.T) {
+	tm := &TunnelManager{}
+
+	config := &TunnelConfig{
+		Name:        "inbound",
+		ContainerID: "container-123",
+		Type:        TunnelTypeServer,
+		LocalPort:   80,
+		InboundOnly: true,
+	}
+	if err := tm.validateTunnelConfig(config); err != nil {
+		t.Fatalf("validateTunnelConfig() unexpected error: %v", err)
+	}
+	if config.Options.OutboundTunnels != 1 || config.Options.OutboundBackups != 0 {
+		t.Errorf("Expected reduced outbound options, got %d tunnels and %d backups",
+			config.Options.OutboundTunnels, config.Options.OutboundBackups)
+	}
+	if config.Options.InboundTunnels != DefaultTunnelOptions().InboundTunnels {
+		t.Error("Inbound options should be left unchanged")
+	}
+
+	clientConfig := &TunnelConfig{
+		Name:        "outbound",
+		ContainerID: "container-123",
+		Type:        TunnelTypeClient,
+		LocalPort:   8080,
+		InboundOnly: true,
+	}
+	if err := tm.validateTunnelConfig(clientConfig); err == nil {
+		t.Error("Expected client tunnels to reject inbound-only mode")
+	}
+
+	tunnel := &Tunnel{config: config, active: true}
+	if _, err := tunnel.Dial("example.b32.i2p"); err == nil {
+		t.Error("Expected inbound-only tunnel to refuse dialing")
+	}
+}
+
+func TestTunnelListen(t *testing.T) {
+	tunnel := &Tunnel{config: &TunnelConfig{Name: "web", Type: TunnelTypeServer}}
+	if _, err := tunnel.Listen(); err == nil {
+		t.Error("Expected inactive tunnel to refuse listening")
+	}
+
+	tunnel.active = true
+	if _, err := tunnel.Listen(); err == nil {
+		t.Error("Expected tunnel without stream sub-session to refuse listening")
+	}
+
+	client := &Tunnel{config: &TunnelConfig{Name: "outbound", Type: TunnelTypeClient}, active: true}
+	if _, err := client.Listen(); err == nil {
+		t.Error("Expected client tunnel to refuse listening")
+	}
+}
+
 func TestTunnelMethods(t *testing.T) {
 	config := &TunnelConfig{
 		Name:        "test-tunnel",
@@ -340,8 +397,7 @@ func TestTunnelMethods(t *testing.T) {
 }
 
 func TestTunnelManagerContainerSessions(t *testing.T) {
-	client, _ := NewSAMClient(nil)
-	tm := NewTunnelManager(client)
+	tm := NewTunnelManager(TunnelManagerOptions{})
 
 	// Check if I2P is available for environment-aware testing
 	i2pAvailable := isI2PAvailable()
@@ -398,7 +454,7 @@ func TestTunnelManagerSessionManagement(t *testing.T) {
 	}
 	defer client.Disconnect()
 
-	tm := NewTunnelManager(client)
+	tm := NewTunnelManager(TunnelManagerOptions{})
 
 	// Test creating a primary session for a container
 	containerID := "test-container-123"
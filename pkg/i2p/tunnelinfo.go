@@ -0,0 +1,55 @@
+// Package i2p provides the JSON form of tunnels.
+//
+// A Tunnel holds SAM sessions and router state that have no meaningful JSON
+// form. TunnelInfo is the documented schema every output surface uses for a
+// tunnel, and Tunnel marshals to it, so encoding a tunnel directly gives the
+// same document as the admin API.
+package i2p
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// TunnelInfo is the external JSON schema of a tunnel.
+type TunnelInfo struct {
+	// Name is the unique tunnel name
+	Name string `json:"name"`
+	// ContainerID is the container that owns the tunnel
+	ContainerID string `json:"container_id"`
+	// Purpose is what the tunnel name was generated from, e.g. "web-80"
+	Purpose string `json:"purpose,omitempty"`
+	// Type is either "client" or "server"
+	Type string `json:"type"`
+	// Destination is the remote (client) or local (server) I2P destination
+	Destination string `json:"destination,omitempty"`
+	// LocalEndpoint is the local host:port of the tunnel
+	LocalEndpoint string `json:"local_endpoint"`
+	// Active reports whether the tunnel is active
+	Active bool `json:"active"`
+	// CreatedAt is when the tunnel was created (omitted if unknown)
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// Info returns the external form of the tunnel.
+func (t *Tunnel) Info() TunnelInfo {
+	info := TunnelInfo{
+		Name:          t.config.Name,
+		ContainerID:   t.config.ContainerID,
+		Purpose:       t.config.Purpose,
+		Type:          string(t.config.Type),
+		Destination:   t.config.Destination,
+		LocalEndpoint: t.GetLocalEndpoint(),
+		Active:        t.IsActive(),
+	}
+	if !t.created.IsZero() {
+		created := t.created
+		info.CreatedAt = &created
+	}
+	return info
+}
+
+// MarshalJSON encodes the tunnel as its TunnelInfo.
+func (t *Tunnel) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Info())
+}
@@ -0,0 +1,44 @@
+package i2p
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTunnel_MarshalJSON(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tunnel := &Tunnel{
+		config: &TunnelConfig{
+			Name:        "container1-web-80",
+			ContainerID: "container1",
+			Purpose:     "web-80",
+			Type:        TunnelTypeServer,
+			LocalHost:   "172.20.0.2",
+			LocalPort:   80,
+			Destination: "abc.b32.i2p",
+		},
+		active:  true,
+		created: created,
+	}
+
+	data, err := json.Marshal(tunnel)
+	if err != nil {
+		t.Fatalf("Failed to marshal tunnel: %v", err)
+	}
+	var info TunnelInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		t.Fatalf("Failed to decode %s: %v", data, err)
+	}
+	if info.Name != "container1-web-80" || info.Purpose != "web-80" || info.Type != "server" ||
+		info.LocalEndpoint != "172.20.0.2:80" || !info.Active {
+		t.Errorf("Unexpected tunnel info %+v", info)
+	}
+	if info.CreatedAt == nil || !info.CreatedAt.Equal(created) {
+		t.Errorf("Expected creation time %v, got %v", created, info.CreatedAt)
+	}
+	if strings.Contains(string(data), "options") {
+		t.Errorf("Expected the tunnel options to be left out, got %s", data)
+	}
+}
@@ -0,0 +1,404 @@
+// Package metrics provides a minimal metrics registry for the I2P network plugin.
+//
+// Metrics are exported in the Prometheus text exposition format so they can be
+// scraped from the admin API without pulling a client library into the plugin.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// collector is implemented by every metric family in a registry.
+type collector interface {
+	name() string
+	write(w io.Writer) error
+}
+
+// Registry holds a set of named metric families.
+//
+// Registry is safe for concurrent use.
+type Registry struct {
+	mutex      sync.Mutex
+	collectors map[string]collector
+}
+
+// NewRegistry creates an empty metrics registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]collector),
+	}
+}
+
+// NewCounterVec registers a counter family partitioned by the given labels.
+//
+// If a counter with the same name is already registered it is returned
+// instead, so independent components can share a family.
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if existing, ok := r.collectors[name].(*CounterVec); ok {
+		return existing
+	}
+
+	counter := &CounterVec{
+		family: family{metricName: name, help: help, labelNames: labelNames},
+		values: make(map[string]*sample),
+	}
+	r.collectors[name] = counter
+	return counter
+}
+
+// DefaultDurationBuckets are histogram upper bounds, in seconds, for operations
+// that may wait on an I2P router, where tunnel builds can take tens of seconds.
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// NewHistogramVec registers a histogram family partitioned by the given labels.
+//
+// Buckets are the sorted upper bounds of the histogram buckets; a +Inf bucket
+// is always added. As with NewCounterVec, an existing histogram with the same
+// name is returned instead.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if existing, ok := r.collectors[name].(*HistogramVec); ok {
+		return existing
+	}
+
+	histogram := &HistogramVec{
+		family:  family{metricName: name, help: help, labelNames: labelNames},
+		buckets: append([]float64(nil), buckets...),
+		values:  make(map[string]*histogramSample),
+	}
+	sort.Float64s(histogram.buckets)
+	r.collectors[name] = histogram
+	return histogram
+}
+
+// Sample is a single labelled value reported by a collect function.
+type Sample struct {
+	// LabelValues are the values for the family's labels, in order
+	LabelValues []string
+	// Value is the sample value
+	Value float64
+}
+
+// NewGaugeFunc registers a gauge family whose samples are computed at scrape time.
+//
+// This suits values that already live elsewhere, such as pool utilization,
+// so they don't have to be mirrored into the registry on every change.
+func (r *Registry) NewGaugeFunc(name, help string, labelNames []string, collect func() []Sample) {
+	r.registerFunc(name, help, "gauge", labelNames, collect)
+}
+
+// NewCounterFunc registers a counter family whose samples are computed at scrape time.
+//
+// The collect function must return monotonically increasing values.
+func (r *Registry) NewCounterFunc(name, help string, labelNames []string, collect func() []Sample) {
+	r.registerFunc(name, help, "counter", labelNames, collect)
+}
+
+// registerFunc registers a function-backed family, replacing any existing one.
+func (r *Registry) registerFunc(name, help, metricType string, labelNames []string, collect func() []Sample) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.collectors[name] = &funcCollector{
+		family:     family{metricName: name, help: help, labelNames: labelNames},
+		metricType: metricType,
+		collect:    collect,
+	}
+}
+
+// WritePrometheus writes all registered metrics in the Prometheus text format.
+//
+// Families and series are sorted so the output is stable between scrapes.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mutex.Lock()
+	collectors := make([]collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.mutex.Unlock()
+
+	sort.Slice(collectors, func(i, j int) bool {
+		return collectors[i].name() < collectors[j].name()
+	})
+
+	for _, c := range collectors {
+		if err := c.write(w); err != nil {
+			return fmt.Errorf("failed to write metric %s: %w", c.name(), err)
+		}
+	}
+	return nil
+}
+
+// ServeHTTP serves the registry in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	if err := r.WritePrometheus(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// family holds the metadata shared by all series of a metric.
+type family struct {
+	metricName string
+	help       string
+	labelNames []string
+}
+
+// name returns the metric family name.
+func (f *family) name() string {
+	return f.metricName
+}
+
+// labelKey validates label values and returns the series key.
+func (f *family) labelKey(labelValues []string) string {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d",
+			f.metricName, len(f.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// formatLabels renders label pairs in exposition syntax.
+func (f *family) formatLabels(labelValues []string) string {
+	if len(f.labelNames) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(f.labelNames))
+	for i, name := range f.labelNames {
+		pairs[i] = name + `="` + escapeLabelValue(labelValues[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// writeHeader writes the HELP and TYPE lines for a family.
+func (f *family) writeHeader(w io.Writer, metricType string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n",
+		f.metricName, escapeHelp(f.help), f.metricName, metricType)
+	return err
+}
+
+// sample is a single labelled series value.
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+// CounterVec is a monotonically increasing counter partitioned by labels.
+type CounterVec struct {
+	family
+	mutex  sync.Mutex
+	values map[string]*sample
+}
+
+// Inc increments the counter for the given label values by one.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter for the given label values.
+//
+// Negative values are ignored because counters never decrease.
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	if value < 0 {
+		return
+	}
+
+	key := c.labelKey(labelValues)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	s, ok := c.values[key]
+	if !ok {
+		s = &sample{labelValues: append([]string(nil), labelValues...)}
+		c.values[key] = s
+	}
+	s.value += value
+}
+
+// Value returns the current counter value for the given label values.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	key := c.labelKey(labelValues)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if s, ok := c.values[key]; ok {
+		return s.value
+	}
+	return 0
+}
+
+// write writes the counter family in exposition format.
+func (c *CounterVec) write(w io.Writer) error {
+	c.mutex.Lock()
+	samples := make([]sample, 0, len(c.values))
+	for _, s := range c.values {
+		samples = append(samples, *s)
+	}
+	c.mutex.Unlock()
+
+	return writeSamples(w, &c.family, "counter", samples)
+}
+
+// HistogramVec counts observations in buckets, partitioned by labels.
+type HistogramVec struct {
+	family
+	buckets []float64
+	mutex   sync.Mutex
+	values  map[string]*histogramSample
+}
+
+// histogramSample is a single labelled histogram series.
+type histogramSample struct {
+	labelValues []string
+	counts      []uint64 // per bucket, not cumulative
+	count       uint64
+	sum         float64
+}
+
+// Observe records a value for the given label values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := h.labelKey(labelValues)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogramSample{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.values[key] = s
+	}
+
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += value
+}
+
+// Count returns the number of observations for the given label values.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	key := h.labelKey(labelValues)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if s, ok := h.values[key]; ok {
+		return s.count
+	}
+	return 0
+}
+
+// write writes the histogram family in exposition format.
+func (h *HistogramVec) write(w io.Writer) error {
+	h.mutex.Lock()
+	samples := make([]histogramSample, 0, len(h.values))
+	for _, s := range h.values {
+		copied := *s
+		copied.counts = append([]uint64(nil), s.counts...)
+		samples = append(samples, copied)
+	}
+	h.mutex.Unlock()
+
+	if err := h.writeHeader(w, "histogram"); err != nil {
+		return err
+	}
+
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].labelValues, "\xff") < strings.Join(samples[j].labelValues, "\xff")
+	})
+
+	bucketFamily := family{metricName: h.metricName + "_bucket", labelNames: append(append([]string(nil), h.labelNames...), "le")}
+	for _, s := range samples {
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			labels := append(append([]string(nil), s.labelValues...), formatValue(upper))
+			if _, err := fmt.Fprintf(w, "%s%s %d\n", bucketFamily.metricName, bucketFamily.formatLabels(labels), cumulative); err != nil {
+				return err
+			}
+		}
+		labels := append(append([]string(nil), s.labelValues...), "+Inf")
+		if _, err := fmt.Fprintf(w, "%s%s %d\n", bucketFamily.metricName, bucketFamily.formatLabels(labels), s.count); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.formatLabels(s.labelValues), formatValue(s.sum)); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.formatLabels(s.labelValues), s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// funcCollector is a metric family backed by a collect function.
+type funcCollector struct {
+	family
+	metricType string
+	collect    func() []Sample
+}
+
+// write collects and writes the family in exposition format.
+func (c *funcCollector) write(w io.Writer) error {
+	collected := c.collect()
+	samples := make([]sample, 0, len(collected))
+	for _, s := range collected {
+		c.labelKey(s.LabelValues)
+		samples = append(samples, sample{labelValues: s.LabelValues, value: s.Value})
+	}
+
+	return writeSamples(w, &c.family, c.metricType, samples)
+}
+
+// writeSamples writes a family header followed by its sorted samples.
+func writeSamples(w io.Writer, f *family, metricType string, samples []sample) error {
+	if err := f.writeHeader(w, metricType); err != nil {
+		return err
+	}
+
+	sort.Slice(samples, func(i, j int) bool {
+		return strings.Join(samples[i].labelValues, "\xff") < strings.Join(samples[j].labelValues, "\xff")
+	})
+
+	for _, s := range samples {
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", f.metricName, f.formatLabels(s.labelValues), formatValue(s.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatValue renders a sample value.
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// escapeLabelValue escapes a label value for the exposition format.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// escapeHelp escapes HELP text for the exposition format.
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounterVec(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounterVec("test_total", "Test counter", "reason")
+
+	counter.Inc("a")
+	counter.Inc("a")
+	counter.Add(3, "b")
+	counter.Add(-1, "b")
+
+	if got := counter.Value("a"); got != 2 {
+		t.Errorf("Expected counter a to be 2, got %v", got)
+	}
+	if got := counter.Value("b"); got != 3 {
+		t.Errorf("Expected counter b to be 3, got %v", got)
+	}
+	if got := counter.Value("missing"); got != 0 {
+		t.Errorf("Expected missing counter to be 0, got %v", got)
+	}
+
+	if again := registry.NewCounterVec("test_total", "Test counter", "reason"); again != counter {
+		t.Error("Expected re-registering a counter to return the existing one")
+	}
+}
+
+func TestCounterVecLabelMismatch(t *testing.T) {
+	counter := NewRegistry().NewCounterVec("test_total", "Test counter", "a", "b")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic for wrong number of label values")
+		}
+	}()
+	counter.Inc("only-one")
+}
+
+func TestWritePrometheus(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounterVec("zeta_total", "Last family").Inc()
+	counter := registry.NewCounterVec("alpha_total", "First family", "path")
+	counter.Inc("/b")
+	counter.Inc(`/a"quoted"`)
+
+	var buf bytes.Buffer
+	if err := registry.WritePrometheus(&buf); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+
+	expected := strings.Join([]string{
+		"# HELP alpha_total First family",
+		"# TYPE alpha_total counter",
+		`alpha_total{path="/a\"quoted\""} 1`,
+		`alpha_total{path="/b"} 1`,
+		"# HELP zeta_total Last family",
+		"# TYPE zeta_total counter",
+		"zeta_total 1",
+		"",
+	}, "\n")
+	if buf.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
+
+func TestRegistryServeHTTP(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounterVec("requests_total", "Requests").Inc()
+
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Expected content type %q, got %q", ContentType, ct)
+	}
+	if !strings.Contains(rec.Body.String(), "requests_total 1") {
+		t.Errorf("Expected counter in output, got %q", rec.Body.String())
+	}
+}
+
+func TestFuncCollectors(t *testing.T) {
+	registry := NewRegistry()
+	available := 10.0
+	registry.NewGaugeFunc("pool_available", "Free addresses", []string{"network"}, func() []Sample {
+		return []Sample{{LabelValues: []string{"net1"}, Value: available}}
+	})
+	registry.NewCounterFunc("pool_exhausted_total", "Exhaustions", nil, func() []Sample {
+		return []Sample{{Value: 2}}
+	})
+
+	available = 7
+
+	var buf bytes.Buffer
+	if err := registry.WritePrometheus(&buf); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+
+	for _, expected := range []string{
+		"# TYPE pool_available gauge",
+		`pool_available{network="net1"} 7`,
+		"# TYPE pool_exhausted_total counter",
+		"pool_exhausted_total 2",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	registry := NewRegistry()
+	histogram := registry.NewHistogramVec("latency_seconds", "Latency", []float64{1, 0.1}, "op")
+
+	histogram.Observe(0.05, "join")
+	histogram.Observe(0.1, "join")
+	histogram.Observe(0.5, "join")
+	histogram.Observe(2, "join")
+
+	if got := histogram.Count("join"); got != 4 {
+		t.Errorf("Expected 4 observations, got %d", got)
+	}
+	if again := registry.NewHistogramVec("latency_seconds", "Latency", nil, "op"); again != histogram {
+		t.Error("Expected re-registering a histogram to return the existing one")
+	}
+
+	var buf bytes.Buffer
+	if err := registry.WritePrometheus(&buf); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+
+	expected := strings.Join([]string{
+		"# HELP latency_seconds Latency",
+		"# TYPE latency_seconds histogram",
+		`latency_seconds_bucket{op="join",le="0.1"} 2`,
+		`latency_seconds_bucket{op="join",le="1"} 3`,
+		`latency_seconds_bucket{op="join",le="+Inf"} 4`,
+		`latency_seconds_sum{op="join"} 2.65`,
+		`latency_seconds_count{op="join"} 4`,
+		"",
+	}, "\n")
+	if buf.String() != expected {
+		t.Errorf("Unexpected output:\n%s\nexpected:\n%s", buf.String(), expected)
+	}
+}
@@ -0,0 +1,1044 @@
+// Package plugin provides the admin API for the I2P network plugin.
+//
+// This file implements a read-only JSON admin API served on a separate Unix
+// socket. It exposes networks, tunnels, service exposures, traffic filters and
+// statistics so operators can build tooling against the running plugin.
+// Endpoints are versioned under a path prefix (e.g. /v1/networks) and the API
+// describes itself with an OpenAPI document served at runtime.
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+const (
+	// AdminAPIVersion is the current admin API path version
+	AdminAPIVersion = "v1"
+
+	// AdminAPIRevision is the semantic version of the current admin API.
+	// Additive changes bump the minor version; breaking changes require a new
+	// path version so existing integrators keep working.
+	AdminAPIRevision = "1.42.0"
+
+	// adminVersionHeader carries the API revision on every admin response
+	adminVersionHeader = "X-I2P-Admin-API-Version"
+)
+
+// AdminError is the error body returned by admin API endpoints.
+type AdminError struct {
+	// Error describes what went wrong
+	Error string `json:"error"`
+}
+
+// AdminVersions lists the admin API versions served by the plugin.
+type AdminVersions struct {
+	// Current is the newest API path version
+	Current string `json:"current"`
+	// Revision is the semantic version of the current API
+	Revision string `json:"revision"`
+	// Supported lists all API path versions that are still served
+	Supported []string `json:"supported"`
+}
+
+// AdminEndpoint describes a container endpoint on an I2P network.
+type AdminEndpoint struct {
+	// ID is the Docker endpoint ID
+	ID string `json:"id"`
+	// State is the endpoint lifecycle state (created, joined or parked)
+	State EndpointState `json:"state"`
+	// ContainerID is the attached container, empty if not joined
+	ContainerID string `json:"container_id,omitempty"`
+	// IPAddress is the endpoint IP address, empty while parked
+	IPAddress string `json:"ip_address"`
+	// IPv6Address is the endpoint IPv6 address on dual-stack networks
+	IPv6Address string `json:"ipv6_address,omitempty"`
+	// MacAddress is the endpoint MAC address
+	MacAddress string `json:"mac_address"`
+	// Interface is the container end of the endpoint's veth pair, if the plugin manages links
+	Interface string `json:"interface,omitempty"`
+	// OutboundDisabled reports whether the container is inbound-only (i2p.outbound=false)
+	OutboundDisabled bool `json:"outbound_disabled,omitempty"`
+	// IsolationGroups are the groups whose members may reach the endpoint on isolated networks
+	IsolationGroups []string `json:"isolation_groups,omitempty"`
+	// PortMaps are the container's client port maps and the health of their destinations
+	PortMaps []AdminPortMap `json:"port_maps,omitempty"`
+	// Exposures are the services exposed from the container
+	Exposures []AdminExposure `json:"exposures,omitempty"`
+	// Identity is the identity the container keeps across recreation, if any
+	Identity string `json:"identity,omitempty"`
+	// Alias is the name the container is shown under in place of its ID, if any
+	Alias string `json:"alias,omitempty"`
+	// Aliases are the network aliases other containers on the network resolve the container by
+	Aliases []string `json:"aliases,omitempty"`
+	// QueuedExposures is the number of the container's I2P ports pending until the router recovers from overload
+	QueuedExposures int `json:"queued_exposures,omitempty"`
+	// Stats counts the endpoint's proxy traffic and inbound streams
+	Stats *EndpointStats `json:"stats,omitempty"`
+}
+
+// AdminPortMap describes a client port map of an endpoint.
+type AdminPortMap struct {
+	// Listen is the gateway IP:port the container connects to
+	Listen string `json:"listen"`
+	// Destinations are the destination and its fallbacks, in the order they are tried when healthy
+	Destinations []AdminPortMapDestination `json:"destinations"`
+}
+
+// AdminPortMapDestination describes the health of a port map destination.
+type AdminPortMapDestination struct {
+	// Destination is the remote I2P host:port
+	Destination string `json:"destination"`
+	// Healthy is false while the destination is skipped after failed connections
+	Healthy bool `json:"healthy"`
+	// Failures counts the consecutive failed connections
+	Failures int `json:"failures"`
+	// RetryAt is when an unhealthy destination is tried again
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+	// LastError is the error of the last failed connection
+	LastError string `json:"last_error,omitempty"`
+}
+
+// AdminNetwork describes an I2P network and its endpoints.
+type AdminNetwork struct {
+	// ID is the Docker network ID
+	ID string `json:"id"`
+	// Name is the human-readable network name
+	Name string `json:"name"`
+	// Subnet is the network subnet in CIDR notation
+	Subnet string `json:"subnet"`
+	// Gateway is the network gateway address
+	Gateway string `json:"gateway"`
+	// SubnetIPv6 is the IPv6 subnet of a dual-stack network in CIDR notation
+	SubnetIPv6 string `json:"subnet_ipv6,omitempty"`
+	// GatewayIPv6 is the IPv6 gateway address of a dual-stack network
+	GatewayIPv6 string `json:"gateway_ipv6,omitempty"`
+	// ProxyAddress is the address the network's SOCKS proxy and DNS resolver listen on
+	ProxyAddress string `json:"proxy_address,omitempty"`
+	// Bridge is the bridge the network's endpoints are attached to, if the plugin manages links
+	Bridge string `json:"bridge,omitempty"`
+	// AntiSpoof reports whether endpoints are bound to their addresses by firewall rules
+	AntiSpoof bool `json:"anti_spoof"`
+	// Isolated reports whether direct traffic between the network's containers is blocked
+	Isolated bool `json:"isolated"`
+	// Internal reports whether traffic leaving the network is dropped except to the SAM bridges
+	Internal bool `json:"internal,omitempty"`
+	// MTU is the MTU of the network's interfaces (0 keeps the kernel default)
+	MTU int `json:"mtu,omitempty"`
+	// DNSServer is the address of the network's DNS resolver, for docker run --dns
+	DNSServer string `json:"dns_server,omitempty"`
+	// MaxEndpoints is the endpoint limit of the network (0 is unlimited)
+	MaxEndpoints int `json:"max_endpoints,omitempty"`
+	// TunnelOptions are the tunnel option overrides of the network's container sessions
+	TunnelOptions map[string]string `json:"tunnel_options,omitempty"`
+	// Policy is the policy template the network was created with
+	Policy *NetworkPolicy `json:"policy,omitempty"`
+	// Tenant is the tenant the network's telemetry is tagged with
+	Tenant string `json:"tenant,omitempty"`
+	// ExternalServices are the external I2P services containers on the network reach by name
+	ExternalServices []AdminExternalService `json:"external_services,omitempty"`
+	// Status is "active", "degraded" or "stopped"
+	Status string `json:"status"`
+	// DegradedBy lists the stopped subsystems the network relies on
+	DegradedBy []string `json:"degraded_by,omitempty"`
+	// Endpoints lists the endpoints on this network
+	Endpoints []AdminEndpoint `json:"endpoints"`
+}
+
+// AdminTunnel describes an active I2P tunnel.
+//
+// It has the fields of i2p.TunnelInfo, the schema tunnels are encoded with
+// on every output surface.
+type AdminTunnel i2p.TunnelInfo
+
+// ClientTunnelRequest creates a client tunnel bound to a remote destination.
+type ClientTunnelRequest struct {
+	// Owner is the session the tunnel belongs to; tunnels of one owner share a destination
+	Owner string `json:"owner"`
+	// Destination is the .i2p hostname, .b32.i2p address or base64 destination to connect to
+	Destination string `json:"destination"`
+	// Port is the local port the tunnel is bound to
+	Port int `json:"port"`
+}
+
+// ServerTunnelRequest creates a server tunnel serving a destination whose
+// keys the caller supplies.
+type ServerTunnelRequest struct {
+	// Owner is the session the tunnel belongs to; it is created with the keys
+	Owner string `json:"owner"`
+	// PublicKey is the base64 destination to serve
+	PublicKey string `json:"public_key"`
+	// PrivateKey is the base64 private key blob of the destination
+	PrivateKey string `json:"private_key"`
+	// Port is the I2P port the tunnel serves
+	Port int `json:"port"`
+}
+
+// AdminExposure describes a service exposed from a container.
+//
+// It has the fields of service.ExposureInfo, the schema exposures are
+// encoded with on every output surface.
+type AdminExposure service.ExposureInfo
+
+// AdminExposureGroup describes a port of an exposure group.
+type AdminExposureGroup struct {
+	// Name is the group name from the i2p.expose.group label
+	Name string `json:"name"`
+	// Port is the exposed port
+	Port int `json:"port"`
+	// Balance is either "round-robin" or "least-connections"
+	Balance string `json:"balance"`
+	// Destination is the .b32.i2p address shared by the members
+	Destination string `json:"destination"`
+	// Members are the containers backing the port in the order they joined
+	Members []AdminGroupMember `json:"members"`
+	// OnDemand is the state of the container of an on-demand exposure
+	// (running, starting or stopped), empty for other groups
+	OnDemand string `json:"on_demand,omitempty"`
+}
+
+// AdminGroupMember describes a container backing an exposure group.
+type AdminGroupMember struct {
+	// ContainerID is the member container
+	ContainerID string `json:"container_id"`
+	// Address is the container IP:port inbound streams are forwarded to
+	Address string `json:"address"`
+	// ActiveStreams counts the streams currently forwarded to the member
+	ActiveStreams int `json:"active_streams"`
+}
+
+// AdminFilters describes the traffic filter configuration.
+type AdminFilters struct {
+	// EnableAllowlist enables allowlist-based filtering
+	EnableAllowlist bool `json:"enable_allowlist"`
+	// EnableBlocklist enables blocklist-based filtering
+	EnableBlocklist bool `json:"enable_blocklist"`
+	// LogTraffic enables detailed traffic logging
+	LogTraffic bool `json:"log_traffic"`
+	// Allowlist contains allowed destinations and patterns
+	Allowlist []string `json:"allowlist"`
+	// Blocklist contains blocked destinations and patterns
+	Blocklist []string `json:"blocklist"`
+}
+
+// AdminStats describes traffic statistics.
+type AdminStats struct {
+	// I2PConnectionsAllowed counts successful I2P connections
+	I2PConnectionsAllowed int64 `json:"i2p_connections_allowed"`
+	// I2PConnectionsBlocked counts blocked I2P connections
+	I2PConnectionsBlocked int64 `json:"i2p_connections_blocked"`
+	// NonI2PConnectionsBlocked counts blocked non-I2P connections
+	NonI2PConnectionsBlocked int64 `json:"non_i2p_connections_blocked"`
+	// UnknownSourcesRejected counts proxy connections from clients that are
+	// not endpoints of managed networks
+	UnknownSourcesRejected int64 `json:"unknown_sources_rejected"`
+	// TotalBytesTransferred tracks total data volume
+	TotalBytesTransferred int64 `json:"total_bytes_transferred"`
+	// LastActivity is the time of the last network activity
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// AdminUsage describes the proxy traffic of one container on one day.
+type AdminUsage struct {
+	// Day is the UTC day (YYYY-MM-DD)
+	Day string `json:"day"`
+	// ContainerID is the container the traffic originated from
+	ContainerID string `json:"container_id"`
+	// Connections counts completed proxy connections
+	Connections int64 `json:"connections"`
+	// BytesTransferred is the data volume in both directions
+	BytesTransferred int64 `json:"bytes_transferred"`
+}
+
+// adminRoute describes a single admin API endpoint.
+//
+// Routes are the single source of truth for both request routing and the
+// generated OpenAPI document, so the spec cannot drift from the handlers.
+type adminRoute struct {
+	method       string
+	path         string
+	summary      string
+	response     interface{}
+	handler      http.HandlerFunc
+	notFoundable bool
+	contentType  string            // Response media type (default application/json)
+	query        map[string]string // Query parameter names and descriptions
+	request      interface{}       // JSON request body type (nil if none)
+	statuses     map[int]string    // Other status codes returning the response type
+	failable     bool              // Whether the operation can fail with a 500 error
+	paginated    bool              // Whether the list takes limit and offset (see writeAdminList)
+}
+
+// SetAdminSocketPath configures the Unix socket for the admin API.
+//
+// The admin API is disabled when the path is empty. It must be called
+// before Start.
+func (p *Plugin) SetAdminSocketPath(path string) {
+	p.adminSockPath = path
+}
+
+// adminRoutes returns all admin API routes for the current version.
+func (p *Plugin) adminRoutes() []adminRoute {
+	prefix := "/" + AdminAPIVersion
+	return []adminRoute{
+		{
+			method:    http.MethodGet,
+			path:      prefix + "/networks",
+			summary:   "List I2P networks sorted by ID",
+			response:  []AdminNetwork{},
+			handler:   p.handleAdminNetworks,
+			paginated: true,
+			query: map[string]string{
+				"tenant": "Only list networks of this tenant (see the i2p.tenant option)",
+			},
+		},
+		{method: http.MethodGet, path: prefix + "/networks/{id}", summary: "Get an I2P network by ID or name", response: AdminNetwork{}, handler: p.handleAdminNetwork, notFoundable: true},
+		{method: http.MethodGet, path: prefix + "/tunnels", summary: "List I2P tunnels sorted by name", response: []AdminTunnel{}, handler: p.handleAdminTunnels, paginated: true},
+		{
+			method:    http.MethodGet,
+			path:      prefix + "/exposures",
+			summary:   "List exposed services sorted by network, container and tunnel",
+			response:  []AdminExposure{},
+			handler:   p.handleAdminExposures,
+			paginated: true,
+			query: map[string]string{
+				"tenant": "Only list exposures on networks of this tenant",
+			},
+		},
+		{method: http.MethodGet, path: prefix + "/exposures/groups", summary: "List exposure groups and their members", response: []AdminExposureGroup{}, handler: p.handleAdminExposureGroups, paginated: true},
+		{method: http.MethodGet, path: prefix + "/filters", summary: "Get traffic filter configuration", response: AdminFilters{}, handler: p.handleAdminFilters},
+		{
+			method:       http.MethodGet,
+			path:         prefix + "/filters/explain",
+			summary:      "Explain which filter rule, at which scope, allows or blocks a container's connection",
+			response:     proxy.FilterExplanation{},
+			handler:      p.handleAdminExplainFilter,
+			notFoundable: true,
+			query: map[string]string{
+				"container":   "Container ID (full or short) making the connection (required)",
+				"destination": "Destination host, with or without a port (required)",
+				"network":     "Network (ID or name) of the container, if it is joined to several",
+			},
+		},
+		{method: http.MethodGet, path: prefix + "/stats", summary: "Get traffic statistics", response: AdminStats{}, handler: p.handleAdminStats},
+		{
+			method:    http.MethodGet,
+			path:      prefix + "/usage",
+			summary:   "Get proxy traffic per container per day",
+			response:  []AdminUsage{},
+			handler:   p.handleAdminUsage,
+			paginated: true,
+			query: map[string]string{
+				"day":       "Only return usage of this UTC day (YYYY-MM-DD)",
+				"container": "Only return usage of this container ID (full or short)",
+			},
+		},
+		{
+			method:    http.MethodGet,
+			path:      prefix + "/anomalies",
+			summary:   "List containers whose filter refusals rose abruptly, newest first",
+			response:  []AdminAnomaly{},
+			handler:   p.handleAdminAnomalies,
+			paginated: true,
+			query: map[string]string{
+				"container": "Only return anomalies of this container ID (full or short)",
+				"tenant":    "Only return anomalies of containers on networks of this tenant",
+			},
+		},
+		{
+			method:   http.MethodGet,
+			path:     prefix + "/options",
+			summary:  "List supported network options, endpoint options and container labels",
+			response: []CatalogOption{},
+			handler:  p.handleAdminOptions,
+			query: map[string]string{
+				"scope": "Only list options of this scope: network, endpoint or container",
+			},
+		},
+		{
+			method:       http.MethodGet,
+			path:         prefix + "/traces",
+			summary:      "Get recorded Docker plugin API calls (request tracing must be enabled)",
+			response:     []RequestTrace{},
+			handler:      p.handleAdminTraces,
+			notFoundable: true,
+			paginated:    true,
+			query: map[string]string{
+				"endpoint": "Only return calls to this plugin API path, e.g. /NetworkDriver.Join",
+			},
+		},
+		{method: http.MethodGet, path: prefix + "/peers/blocked", summary: "List inbound I2P peers blocked for abusing server tunnels, latest first", response: []AdminBlockedPeer{}, handler: p.handleAdminBlockedPeers, paginated: true},
+		{
+			method:       http.MethodPost,
+			path:         prefix + "/peers/{destination}/unblock",
+			summary:      "Lift the block of an inbound I2P peer",
+			response:     AdminBlockedPeer{},
+			handler:      p.handleAdminUnblockPeer,
+			notFoundable: true,
+		},
+		{method: http.MethodGet, path: prefix + "/maintenance", summary: "Get the maintenance windows and the container sessions due to be rebuilt", response: AdminMaintenance{}, handler: p.handleAdminMaintenance},
+		{method: http.MethodPost, path: prefix + "/maintenance/run", summary: "Rebuild a batch of due container sessions now, regardless of the maintenance windows", response: []AdminMaintenanceTask{}, handler: p.handleAdminRunMaintenance},
+		{method: http.MethodGet, path: prefix + "/info", summary: "Get the plugin build, enabled features and SAM handshake", response: AdminInfo{}, handler: p.handleAdminInfo},
+		{
+			method:       http.MethodGet,
+			path:         prefix + "/smoke-test",
+			summary:      "Get the outcome of the startup smoke test (the smoke test must be enabled)",
+			response:     AdminSmokeTest{},
+			handler:      p.handleAdminSmokeTest,
+			notFoundable: true,
+		},
+		{
+			method:   http.MethodPost,
+			path:     prefix + "/exposures/batch",
+			summary:  "Expose and unexpose container ports in bulk",
+			request:  ExposureBatchRequest{},
+			response: BatchResult{},
+			handler:  p.handleAdminExposureBatch,
+			statuses: batchStatuses,
+		},
+		{
+			method:   http.MethodPost,
+			path:     prefix + "/filters/batch",
+			summary:  "Add and remove traffic filter rules in bulk",
+			request:  FilterBatchRequest{},
+			response: BatchResult{},
+			handler:  p.handleAdminFilterBatch,
+			statuses: batchStatuses,
+		},
+		{
+			method:   http.MethodPost,
+			path:     prefix + "/tunnels/client",
+			summary:  "Create a client tunnel to a destination",
+			request:  ClientTunnelRequest{},
+			response: AdminTunnel{},
+			handler:  p.handleAdminCreateClientTunnel,
+			failable: true,
+		},
+		{
+			method:   http.MethodPost,
+			path:     prefix + "/tunnels/server",
+			summary:  "Create a server tunnel for a destination with supplied keys",
+			request:  ServerTunnelRequest{},
+			response: AdminTunnel{},
+			handler:  p.handleAdminCreateServerTunnel,
+			failable: true,
+		},
+		{
+			method:   http.MethodPost,
+			path:     prefix + "/tunnels/batch",
+			summary:  "Destroy tunnels in bulk",
+			request:  TunnelBatchRequest{},
+			response: BatchResult{},
+			handler:  p.handleAdminTunnelBatch,
+			statuses: batchStatuses,
+		},
+		{
+			method:       http.MethodPost,
+			path:         prefix + "/dry-run",
+			summary:      "Plan a network creation and container join without performing them",
+			request:      DryRunRequest{},
+			response:     DryRunPlan{},
+			handler:      p.handleAdminDryRun,
+			notFoundable: true,
+		},
+		{
+			method:       http.MethodPost,
+			path:         prefix + "/exposures/preview",
+			summary:      "Preview the exposures and known addresses of a container join",
+			request:      ExposurePreviewRequest{},
+			response:     ExposurePreview{},
+			handler:      p.handleAdminPreviewExposures,
+			notFoundable: true,
+		},
+		{method: http.MethodGet, path: prefix + "/subsystems", summary: "List subsystems and the networks relying on them", response: []AdminSubsystem{}, handler: p.handleAdminSubsystems},
+		{
+			method:       http.MethodPost,
+			path:         prefix + "/subsystems/{name}/stop",
+			summary:      "Stop a subsystem: proxy, dns or exposures",
+			response:     AdminSubsystem{},
+			handler:      p.handleAdminSubsystemAction(p.networkMgr.StopSubsystem),
+			notFoundable: true,
+			failable:     true,
+		},
+		{
+			method:       http.MethodPost,
+			path:         prefix + "/subsystems/{name}/start",
+			summary:      "Start a stopped subsystem",
+			response:     AdminSubsystem{},
+			handler:      p.handleAdminSubsystemAction(p.networkMgr.StartSubsystem),
+			notFoundable: true,
+			failable:     true,
+		},
+		{
+			method:       http.MethodPost,
+			path:         prefix + "/subsystems/{name}/restart",
+			summary:      "Stop and start a subsystem",
+			response:     AdminSubsystem{},
+			handler:      p.handleAdminSubsystemAction(p.networkMgr.StopSubsystem, p.networkMgr.StartSubsystem),
+			notFoundable: true,
+			failable:     true,
+		},
+		{
+			method:       http.MethodPost,
+			path:         prefix + "/networks/{id}/update",
+			summary:      "Apply docker network create options to a running network, changing its traffic filter options live",
+			request:      NetworkUpdateRequest{},
+			response:     NetworkUpdate{},
+			handler:      p.handleAdminUpdateNetwork,
+			notFoundable: true,
+		},
+		{
+			method:       http.MethodPut,
+			path:         prefix + "/networks/{id}/external/{name}",
+			summary:      "Add or replace an external I2P service containers on a network reach by name",
+			request:      ExternalServiceRequest{},
+			response:     NetworkUpdate{},
+			handler:      p.handleAdminSetExternalService,
+			notFoundable: true,
+		},
+		{
+			method:       http.MethodDelete,
+			path:         prefix + "/networks/{id}/external/{name}",
+			summary:      "Remove an external I2P service of a network",
+			response:     NetworkUpdate{},
+			handler:      p.handleAdminRemoveExternalService,
+			notFoundable: true,
+		},
+		{
+			method:       http.MethodPost,
+			path:         prefix + "/networks/{id}/stop",
+			summary:      "Stop the proxy listeners of a network",
+			response:     AdminNetwork{},
+			handler:      p.handleAdminNetworkAction(p.networkMgr.StopNetwork),
+			notFoundable: true,
+			failable:     true,
+		},
+		{
+			method:       http.MethodPost,
+			path:         prefix + "/networks/{id}/start",
+			summary:      "Start the proxy listeners of a stopped network",
+			response:     AdminNetwork{},
+			handler:      p.handleAdminNetworkAction(p.networkMgr.StartNetwork),
+			notFoundable: true,
+			failable:     true,
+		},
+		{
+			method:       http.MethodPost,
+			path:         prefix + "/networks/{id}/restart",
+			summary:      "Stop and start the proxy listeners of a network",
+			response:     AdminNetwork{},
+			handler:      p.handleAdminNetworkAction(p.networkMgr.StopNetwork, p.networkMgr.StartNetwork),
+			notFoundable: true,
+			failable:     true,
+		},
+		{
+			method:      http.MethodGet,
+			path:        prefix + "/logs",
+			summary:     "Stream plugin log events",
+			response:    LogEvent{},
+			handler:     p.handleAdminLogs,
+			contentType: "text/event-stream",
+			query: map[string]string{
+				"container": "Only stream events mentioning this container ID (full or short)",
+				"network":   "Only stream events mentioning this network (ID or name)",
+				"tenant":    "Only stream events of networks of this tenant",
+				"format":    "Stream format: sse (default) or ndjson",
+			},
+		},
+	}
+}
+
+// setupAdminHandlers configures the HTTP handlers for the admin API.
+func (p *Plugin) setupAdminHandlers(mux *http.ServeMux) {
+	handle := func(method, path string, handler http.HandlerFunc) {
+		mux.HandleFunc(method+" "+path, p.withAdminHeaders(p.limitRequests(serverAdmin, path, handler)))
+	}
+
+	for _, route := range p.adminRoutes() {
+		handle(route.method, route.path, route.handler)
+	}
+
+	handle(http.MethodGet, "/versions", p.handleAdminVersions)
+	handle(http.MethodGet, "/openapi.json", p.handleAdminOpenAPI)
+	handle(http.MethodGet, "/"+AdminAPIVersion+"/openapi.json", p.handleAdminOpenAPI)
+	handle(http.MethodGet, "/metrics", p.handleAdminMetrics)
+}
+
+// startAdminServer starts the admin API on its Unix socket.
+//
+// Server errors are reported on errCh.
+func (p *Plugin) startAdminServer(errCh chan<- error) error {
+	if err := os.MkdirAll(filepath.Dir(p.adminSockPath), 0755); err != nil {
+		return fmt.Errorf("failed to create admin socket directory: %w", err)
+	}
+
+	if err := os.RemoveAll(p.adminSockPath); err != nil {
+		return fmt.Errorf("failed to remove existing admin socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", p.adminSockPath)
+	if err != nil {
+		return fmt.Errorf("failed to create admin socket listener: %w", err)
+	}
+
+	if err := p.adminSockPerms.apply(p.adminSockPath); err != nil {
+		listener.Close()
+		return fmt.Errorf("admin socket: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	p.setupAdminHandlers(mux)
+	p.adminServer = &http.Server{Handler: mux}
+
+	// Mirror plugin logs into the hub so they can be streamed
+	log.SetOutput(io.MultiWriter(os.Stderr, p.logHub))
+
+	log.Printf("Admin API listening on %s", p.adminSockPath)
+
+	go func() {
+		if err := p.adminServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			errCh <- fmt.Errorf("admin server error: %w", err)
+		}
+	}()
+
+	return nil
+}
+
+// stopAdminServer shuts down the admin API if it is running.
+//
+// Long-lived log streams never become idle, so connections still open when
+// ctx expires are closed forcibly.
+func (p *Plugin) stopAdminServer(ctx context.Context) error {
+	if p.adminServer == nil {
+		return nil
+	}
+	log.SetOutput(os.Stderr)
+
+	if err := p.adminServer.Shutdown(ctx); err != nil {
+		p.adminServer.Close()
+		return err
+	}
+	return nil
+}
+
+// withAdminHeaders adds the API version header to admin responses.
+func (p *Plugin) withAdminHeaders(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(adminVersionHeader, AdminAPIRevision)
+		handler(w, r)
+	}
+}
+
+// writeAdminError writes an admin API error with the given status code.
+func (p *Plugin) writeAdminError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(AdminError{Error: message}); err != nil {
+		log.Printf("Error encoding admin error response: %v", err)
+	}
+}
+
+// handleAdminTraces returns the recorded plugin API calls, oldest first.
+func (p *Plugin) handleAdminTraces(w http.ResponseWriter, r *http.Request) {
+	tracer := p.tracer.Load()
+	if tracer == nil {
+		p.writeAdminError(w, http.StatusNotFound, "request tracing is disabled")
+		return
+	}
+
+	endpoint := r.URL.Query().Get("endpoint")
+	traces := []RequestTrace{}
+	for _, trace := range tracer.Snapshot() {
+		if endpoint == "" || trace.Endpoint == endpoint {
+			traces = append(traces, trace)
+		}
+	}
+
+	writeAdminList(p, w, r, traces)
+}
+
+// handleAdminOptions returns the catalog of supported options and labels.
+func (p *Plugin) handleAdminOptions(w http.ResponseWriter, r *http.Request) {
+	p.writeJSONResponse(w, Catalog(r.URL.Query().Get("scope")))
+}
+
+// handleAdminMetrics exports plugin metrics in the Prometheus text format.
+func (p *Plugin) handleAdminMetrics(w http.ResponseWriter, r *http.Request) {
+	p.metrics.ServeHTTP(w, r)
+}
+
+// handleAdminVersions lists the supported admin API versions.
+func (p *Plugin) handleAdminVersions(w http.ResponseWriter, r *http.Request) {
+	p.writeJSONResponse(w, AdminVersions{
+		Current:   AdminAPIVersion,
+		Revision:  AdminAPIRevision,
+		Supported: []string{AdminAPIVersion},
+	})
+}
+
+// handleAdminOpenAPI serves the generated OpenAPI document.
+func (p *Plugin) handleAdminOpenAPI(w http.ResponseWriter, r *http.Request) {
+	p.writeJSONResponse(w, p.adminOpenAPISpec())
+}
+
+// handleAdminNetworks lists all I2P networks, or those of a tenant.
+func (p *Plugin) handleAdminNetworks(w http.ResponseWriter, r *http.Request) {
+	writeAdminList(p, w, r, p.networkMgr.adminNetworks(r.URL.Query().Get("tenant")))
+}
+
+// handleAdminNetwork returns a single I2P network by ID or name.
+func (p *Plugin) handleAdminNetwork(w http.ResponseWriter, r *http.Request) {
+	ref := r.PathValue("id")
+
+	network := p.networkMgr.LookupNetwork(ref)
+	if network == nil {
+		p.writeAdminError(w, http.StatusNotFound, fmt.Sprintf("network %s not found", ref))
+		return
+	}
+
+	p.writeJSONResponse(w, p.networkMgr.adminNetworkView(network))
+}
+
+// handleAdminTunnels lists all active I2P tunnels.
+func (p *Plugin) handleAdminTunnels(w http.ResponseWriter, r *http.Request) {
+	writeAdminList(p, w, r, p.adminTunnels())
+}
+
+// adminTunnels returns admin views of all tunnels sorted by name.
+func (p *Plugin) adminTunnels() []AdminTunnel {
+	tunnelMgr := p.networkMgr.tunnelMgr
+
+	tunnels := []AdminTunnel{}
+	for _, name := range tunnelMgr.ListTunnels() {
+		tunnel, exists := tunnelMgr.GetTunnel(name)
+		if !exists {
+			continue
+		}
+		tunnels = append(tunnels, p.adminTunnel(tunnel))
+	}
+
+	sort.Slice(tunnels, func(i, j int) bool { return tunnels[i].Name < tunnels[j].Name })
+	return tunnels
+}
+
+// adminTunnel returns the admin view of a tunnel.
+func (p *Plugin) adminTunnel(tunnel *i2p.Tunnel) AdminTunnel {
+	return AdminTunnel(tunnel.Info())
+}
+
+// handleAdminCreateClientTunnel creates a client tunnel outside of any
+// container's endpoint.
+func (p *Plugin) handleAdminCreateClientTunnel(w http.ResponseWriter, r *http.Request) {
+	var req ClientTunnelRequest
+	if err := p.readJSONRequest(r, &req); err != nil {
+		p.writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateTunnelRequest(req.Owner, req.Port); err != nil {
+		p.writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Destination) == "" {
+		p.writeAdminError(w, http.StatusBadRequest, "destination is required")
+		return
+	}
+
+	tunnel, err := p.networkMgr.tunnelMgr.CreateClientTunnelTo(req.Owner, req.Destination, req.Port)
+	if err != nil {
+		p.writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	p.writeJSONResponse(w, p.adminTunnel(tunnel))
+}
+
+// handleAdminCreateServerTunnel creates a server tunnel for a destination
+// with supplied keys outside of any container's exposures.
+func (p *Plugin) handleAdminCreateServerTunnel(w http.ResponseWriter, r *http.Request) {
+	var req ServerTunnelRequest
+	if err := p.readJSONRequest(r, &req); err != nil {
+		p.writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateTunnelRequest(req.Owner, req.Port); err != nil {
+		p.writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	keys, err := i2p.ParseKeys(req.PublicKey, req.PrivateKey)
+	if err != nil {
+		p.writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tunnel, err := p.networkMgr.tunnelMgr.CreateServerTunnelWithKeys(req.Owner, keys, req.Port)
+	if err != nil {
+		p.writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	p.writeJSONResponse(w, p.adminTunnel(tunnel))
+}
+
+// validateTunnelRequest checks the fields shared by tunnel creation requests.
+func validateTunnelRequest(owner string, port int) error {
+	if strings.TrimSpace(owner) == "" {
+		return fmt.Errorf("owner is required")
+	}
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("port %d is out of range", port)
+	}
+	return nil
+}
+
+// handleAdminExposures lists all exposed services across networks, or those
+// on the networks of a tenant.
+func (p *Plugin) handleAdminExposures(w http.ResponseWriter, r *http.Request) {
+	writeAdminList(p, w, r, p.adminExposures(r.URL.Query().Get("tenant")))
+}
+
+// adminExposures returns admin views of the services exposed on all networks,
+// or only on the networks of tenant if it is not empty.
+func (p *Plugin) adminExposures(tenant string) []AdminExposure {
+	exposures := []AdminExposure{}
+
+	for _, networkID := range p.networkMgr.ListNetworks() {
+		network := p.networkMgr.GetNetwork(networkID)
+		if network == nil || (tenant != "" && network.Tenant != tenant) {
+			continue
+		}
+
+		network.mutex.RLock()
+		for _, endpoint := range network.Endpoints {
+			exposures = append(exposures, endpoint.adminExposures(networkID)...)
+		}
+		network.mutex.RUnlock()
+	}
+
+	sort.Slice(exposures, func(i, j int) bool {
+		if exposures[i].NetworkID != exposures[j].NetworkID {
+			return exposures[i].NetworkID < exposures[j].NetworkID
+		}
+		if exposures[i].ContainerID != exposures[j].ContainerID {
+			return exposures[i].ContainerID < exposures[j].ContainerID
+		}
+		return exposures[i].TunnelName < exposures[j].TunnelName
+	})
+	return exposures
+}
+
+// adminView returns the admin API representation of the endpoint. The caller
+// must hold the network's mutex.
+func (e *I2PEndpoint) adminView() AdminEndpoint {
+	view := AdminEndpoint{
+		ID:               e.ID,
+		State:            e.State,
+		ContainerID:      e.ContainerID,
+		MacAddress:       e.MacAddress,
+		Interface:        e.Interface,
+		OutboundDisabled: e.OutboundDisabled,
+		IsolationGroups:  e.IsolationGroups,
+		Exposures:        e.adminExposures(e.NetworkID),
+		Identity:         e.Identity,
+		Alias:            e.Alias,
+		Aliases:          e.Aliases,
+	}
+	if e.IPAddress != nil {
+		view.IPAddress = e.IPAddress.String()
+	}
+	if e.IPv6Address != nil {
+		view.IPv6Address = e.IPv6Address.String()
+	}
+	for _, mapping := range e.PortMappings {
+		view.PortMaps = append(view.PortMaps, adminPortMap(mapping))
+	}
+	return view
+}
+
+// adminExposures returns the admin views of the endpoint's exposures on
+// networkID. The caller must hold the network's mutex.
+func (e *I2PEndpoint) adminExposures(networkID string) []AdminExposure {
+	var exposures []AdminExposure
+	for _, exposure := range e.ServiceExposures {
+		info := exposure.Info()
+		info.NetworkID = networkID
+		exposures = append(exposures, AdminExposure(info))
+	}
+	return exposures
+}
+
+// MarshalJSON encodes the endpoint in the admin API schema, without the
+// statistics only the admin API collects. The caller must hold the network's
+// mutex.
+func (e *I2PEndpoint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.adminView())
+}
+
+// adminPortMap returns the admin view of a port map.
+func adminPortMap(mapping *service.PortMapping) AdminPortMap {
+	portMap := AdminPortMap{Listen: mapping.ListenAddr}
+	for _, health := range mapping.Health() {
+		portMap.Destinations = append(portMap.Destinations, AdminPortMapDestination{
+			Destination: net.JoinHostPort(health.Destination, strconv.Itoa(health.Port)),
+			Healthy:     health.Healthy,
+			Failures:    health.Failures,
+			RetryAt:     health.RetryAt,
+			LastError:   health.LastError,
+		})
+	}
+	return portMap
+}
+
+// handleAdminExposureGroups lists the ports of all exposure groups.
+func (p *Plugin) handleAdminExposureGroups(w http.ResponseWriter, r *http.Request) {
+	groups := []AdminExposureGroup{}
+
+	for _, group := range p.networkMgr.serviceMgr.ExposureGroups() {
+		adminGroup := AdminExposureGroup{
+			Name:        group.Name,
+			Port:        group.Port,
+			Balance:     group.Balance,
+			Destination: group.Destination,
+			Members:     make([]AdminGroupMember, 0, len(group.Members)),
+			OnDemand:    group.OnDemand,
+		}
+		for _, member := range group.Members {
+			adminGroup.Members = append(adminGroup.Members, AdminGroupMember(member))
+		}
+		groups = append(groups, adminGroup)
+	}
+
+	writeAdminList(p, w, r, groups)
+}
+
+// handleAdminFilters returns the traffic filter configuration.
+func (p *Plugin) handleAdminFilters(w http.ResponseWriter, r *http.Request) {
+	proxyMgr := p.networkMgr.proxyMgr
+	config := proxyMgr.GetFilterConfig()
+
+	allowlist := proxyMgr.GetAllowlist()
+	blocklist := proxyMgr.GetBlocklist()
+	sort.Strings(allowlist)
+	sort.Strings(blocklist)
+
+	p.writeJSONResponse(w, AdminFilters{
+		EnableAllowlist: config.EnableAllowlist,
+		EnableBlocklist: config.EnableBlocklist,
+		LogTraffic:      config.LogTraffic,
+		Allowlist:       allowlist,
+		Blocklist:       blocklist,
+	})
+}
+
+// handleAdminStats returns traffic statistics.
+func (p *Plugin) handleAdminStats(w http.ResponseWriter, r *http.Request) {
+	p.writeJSONResponse(w, p.adminStats())
+}
+
+// adminStats returns the admin view of traffic statistics.
+func (p *Plugin) adminStats() AdminStats {
+	stats := p.networkMgr.proxyMgr.GetTrafficStats()
+
+	return AdminStats{
+		I2PConnectionsAllowed:    stats.I2PConnectionsAllowed,
+		I2PConnectionsBlocked:    stats.I2PConnectionsBlocked,
+		NonI2PConnectionsBlocked: stats.NonI2PConnectionsBlocked,
+		UnknownSourcesRejected:   stats.UnknownSourcesRejected,
+		TotalBytesTransferred:    stats.TotalBytesTransferred,
+		LastActivity:             stats.LastActivity,
+	}
+}
+
+// handleAdminUsage returns per-container daily proxy traffic.
+func (p *Plugin) handleAdminUsage(w http.ResponseWriter, r *http.Request) {
+	container := r.URL.Query().Get("container")
+
+	usage := []AdminUsage{}
+	for _, record := range p.networkMgr.proxyMgr.GetUsage(r.URL.Query().Get("day")) {
+		if container != "" && !strings.HasPrefix(record.ContainerID, container) {
+			continue
+		}
+		usage = append(usage, AdminUsage{
+			Day:              record.Day,
+			ContainerID:      record.ContainerID,
+			Connections:      record.Connections,
+			BytesTransferred: record.BytesTransferred,
+		})
+	}
+
+	writeAdminList(p, w, r, usage)
+}
+
+// adminNetworks returns admin views of all networks sorted by ID, or only of
+// the networks of tenant if it is not empty.
+func (nm *NetworkManager) adminNetworks(tenant string) []AdminNetwork {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	networks := make([]AdminNetwork, 0, len(nm.networks))
+	for _, network := range nm.networks {
+		if tenant != "" && network.Tenant != tenant {
+			continue
+		}
+		networks = append(networks, nm.adminNetworkView(network))
+	}
+
+	sort.Slice(networks, func(i, j int) bool { return networks[i].ID < networks[j].ID })
+	return networks
+}
+
+// adminView returns the admin API representation of the network.
+func (n *I2PNetwork) adminView() AdminNetwork {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+
+	view := AdminNetwork{
+		ID:            n.ID,
+		Name:          n.Name,
+		Bridge:        n.Bridge,
+		AntiSpoof:     n.AntiSpoof,
+		Isolated:      n.Isolated,
+		Internal:      n.Internal,
+		MTU:           n.MTU,
+		MaxEndpoints:  n.MaxEndpoints,
+		TunnelOptions: n.TunnelOverrides,
+		Policy:        n.Policy,
+		Tenant:        n.Tenant,
+		Endpoints:     make([]AdminEndpoint, 0, len(n.Endpoints)),
+	}
+	if n.Subnet != nil {
+		view.Subnet = n.Subnet.String()
+	}
+	if n.Gateway != nil {
+		view.Gateway = n.Gateway.String()
+	}
+	if n.SubnetIPv6 != nil {
+		view.SubnetIPv6 = n.SubnetIPv6.String()
+		view.GatewayIPv6 = n.GatewayIPv6.String()
+	}
+	if n.ProxyBindIP != nil {
+		view.ProxyAddress = n.ProxyBindIP.String()
+	}
+	if server := n.dnsServer(); server != nil {
+		view.DNSServer = server.String()
+	}
+
+	for _, endpoint := range n.Endpoints {
+		view.Endpoints = append(view.Endpoints, endpoint.adminView())
+	}
+
+	sort.Slice(view.Endpoints, func(i, j int) bool { return view.Endpoints[i].ID < view.Endpoints[j].ID })
+	return view
+}
@@ -0,0 +1,313 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/metrics"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+// newAdminTestPlugin creates a plugin with one network for admin API tests.
+//
+// The plugin is built without a SAM bridge, so only read-only state is available.
+func newAdminTestPlugin(t *testing.T) (*Plugin, *http.ServeMux) {
+	t.Helper()
+
+	networkMgr, err := NewNetworkManager(i2p.NewTunnelManager(i2p.TunnelManagerOptions{}))
+	if err != nil {
+		t.Fatalf("Failed to create network manager: %v", err)
+	}
+
+	_, subnet, _ := net.ParseCIDR("172.20.1.0/24")
+	gateway := net.ParseIP("172.20.1.1")
+	networkMgr.addNetworkLocked(&I2PNetwork{
+		ID:      "net1",
+		Name:    "i2p-test",
+		Subnet:  subnet,
+		Gateway: gateway,
+		Endpoints: map[string]*I2PEndpoint{
+			"ep1": {
+				ID:          "ep1",
+				NetworkID:   "net1",
+				State:       EndpointJoined,
+				ContainerID: "container1",
+				IPAddress:   net.ParseIP("172.20.1.2"),
+				MacAddress:  "02:42:ac:14:01:02",
+			},
+		},
+		IPAllocator:   NewIPAllocator(subnet, gateway),
+		TunnelManager: networkMgr.tunnelMgr,
+	})
+
+	p := &Plugin{sockPath: "/tmp/test.sock", networkMgr: networkMgr, logHub: NewLogHub(), metrics: metrics.NewRegistry()}
+	p.registerMetrics()
+	mux := http.NewServeMux()
+	p.setupAdminHandlers(mux)
+	return p, mux
+}
+
+func adminGet(t *testing.T, mux *http.ServeMux, path string, v interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("Failed to decode %s response: %v", path, err)
+		}
+	}
+	return w
+}
+
+func TestAdminNetworks(t *testing.T) {
+	_, mux := newAdminTestPlugin(t)
+
+	var networks []AdminNetwork
+	w := adminGet(t, mux, "/v1/networks", &networks)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get(adminVersionHeader) != AdminAPIRevision {
+		t.Errorf("Expected version header %s, got %q", AdminAPIRevision, w.Header().Get(adminVersionHeader))
+	}
+	if len(networks) != 1 {
+		t.Fatalf("Expected 1 network, got %d", len(networks))
+	}
+
+	network := networks[0]
+	if network.ID != "net1" || network.Subnet != "172.20.1.0/24" || network.Gateway != "172.20.1.1" {
+		t.Errorf("Unexpected network: %+v", network)
+	}
+	if len(network.Endpoints) != 1 || network.Endpoints[0].IPAddress != "172.20.1.2" {
+		t.Errorf("Unexpected endpoints: %+v", network.Endpoints)
+	}
+}
+
+func TestAdminNetworkByID(t *testing.T) {
+	_, mux := newAdminTestPlugin(t)
+
+	var network AdminNetwork
+	if w := adminGet(t, mux, "/v1/networks/net1", &network); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if network.Name != "i2p-test" {
+		t.Errorf("Expected network name i2p-test, got %s", network.Name)
+	}
+
+	if w := adminGet(t, mux, "/v1/networks/i2p-test", &network); w.Code != http.StatusOK || network.ID != "net1" {
+		t.Errorf("Expected lookup by name to return net1, got status %d and ID %s", w.Code, network.ID)
+	}
+
+	var adminErr AdminError
+	w := adminGet(t, mux, "/v1/networks/missing", &adminErr)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+	if adminErr.Error == "" {
+		t.Error("Expected error message for missing network")
+	}
+}
+
+func TestAdminListEndpoints(t *testing.T) {
+	_, mux := newAdminTestPlugin(t)
+
+	var tunnels []AdminTunnel
+	if w := adminGet(t, mux, "/v1/tunnels", &tunnels); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for tunnels, got %d", w.Code)
+	}
+	if tunnels == nil || len(tunnels) != 0 {
+		t.Errorf("Expected empty tunnel list, got %v", tunnels)
+	}
+
+	var exposures []AdminExposure
+	if w := adminGet(t, mux, "/v1/exposures", &exposures); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for exposures, got %d", w.Code)
+	}
+
+	var groups []AdminExposureGroup
+	if w := adminGet(t, mux, "/v1/exposures/groups", &groups); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for exposure groups, got %d", w.Code)
+	}
+	if groups == nil || len(groups) != 0 {
+		t.Errorf("Expected empty exposure group list, got %v", groups)
+	}
+
+	var filters AdminFilters
+	if w := adminGet(t, mux, "/v1/filters", &filters); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for filters, got %d", w.Code)
+	}
+	if !filters.EnableBlocklist {
+		t.Error("Expected blocklist to be enabled by default")
+	}
+
+	var stats AdminStats
+	if w := adminGet(t, mux, "/v1/stats", &stats); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for stats, got %d", w.Code)
+	}
+}
+
+func TestAdminExposureSchema(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+	endpoint := p.networkMgr.GetNetwork("net1").Endpoints["ep1"]
+	endpoint.ServiceExposures = []*service.ServiceExposure{{
+		ContainerID: "container1",
+		Port:        service.ExposedPort{ContainerPort: 8080, ExposureType: service.ExposureTypeIP},
+		Destination: "192.168.1.10:8080",
+		TunnelName:  "ip-container1-8080",
+	}}
+
+	var exposures []AdminExposure
+	adminGet(t, mux, "/v1/exposures", &exposures)
+	if len(exposures) != 1 || exposures[0].NetworkID != "net1" || exposures[0].Service != "8080" ||
+		exposures[0].State != service.ExposureStateActive {
+		t.Fatalf("Unexpected exposures %+v", exposures)
+	}
+
+	// Endpoints list their exposures in the same schema
+	var network AdminNetwork
+	adminGet(t, mux, "/v1/networks/net1", &network)
+	if len(network.Endpoints) != 1 || len(network.Endpoints[0].Exposures) != 1 ||
+		network.Endpoints[0].Exposures[0] != exposures[0] {
+		t.Errorf("Expected the endpoint to list %+v, got %+v", exposures[0], network.Endpoints)
+	}
+
+	// Marshaling an exposure or endpoint directly gives the same documents;
+	// the test exposure was not created on a network, so it has no network ID
+	direct, _ := json.Marshal(endpoint.ServiceExposures[0])
+	expected := exposures[0]
+	expected.NetworkID = ""
+	listed, _ := json.Marshal(expected)
+	if string(direct) != string(listed) {
+		t.Errorf("Expected %s, got %s", listed, direct)
+	}
+	data, err := json.Marshal(endpoint)
+	if err != nil {
+		t.Fatalf("Failed to marshal endpoint: %v", err)
+	}
+	var view AdminEndpoint
+	if err := json.Unmarshal(data, &view); err != nil || view.ID != "ep1" || view.IPAddress != "172.20.1.2" ||
+		len(view.Exposures) != 1 {
+		t.Errorf("Expected the endpoint's admin view, got %s (%v)", data, err)
+	}
+}
+
+func TestAdminVersions(t *testing.T) {
+	_, mux := newAdminTestPlugin(t)
+
+	var versions AdminVersions
+	adminGet(t, mux, "/versions", &versions)
+
+	if versions.Current != AdminAPIVersion || versions.Revision != AdminAPIRevision {
+		t.Errorf("Unexpected versions: %+v", versions)
+	}
+	if len(versions.Supported) == 0 {
+		t.Error("Expected at least one supported version")
+	}
+}
+
+func TestAdminOpenAPISpec(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+
+	var spec map[string]interface{}
+	if w := adminGet(t, mux, "/openapi.json", &spec); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	if spec["openapi"] != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %v", spec["openapi"])
+	}
+
+	info := spec["info"].(map[string]interface{})
+	if info["version"] != AdminAPIRevision {
+		t.Errorf("Expected spec version %s, got %v", AdminAPIRevision, info["version"])
+	}
+
+	// Every served route must be documented
+	paths := spec["paths"].(map[string]interface{})
+	for _, route := range p.adminRoutes() {
+		item, ok := paths[route.path].(map[string]interface{})
+		if !ok {
+			t.Errorf("Route %s missing from OpenAPI paths", route.path)
+			continue
+		}
+		if _, ok := item[strings.ToLower(route.method)]; !ok {
+			t.Errorf("Route %s missing %s operation", route.path, route.method)
+		}
+	}
+
+	schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	for _, name := range []string{"AdminNetwork", "AdminEndpoint", "AdminTunnel", "AdminExposure", "AdminFilters", "AdminStats", "AdminError"} {
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("Schema %s missing from OpenAPI components", name)
+		}
+	}
+
+	network := schemas["AdminNetwork"].(map[string]interface{})
+	properties := network["properties"].(map[string]interface{})
+	if _, ok := properties["subnet"]; !ok {
+		t.Error("Expected AdminNetwork schema to use JSON field names")
+	}
+
+	// The versioned spec path serves the same document
+	var versioned map[string]interface{}
+	adminGet(t, mux, "/v1/openapi.json", &versioned)
+	if versioned["openapi"] != spec["openapi"] {
+		t.Error("Expected /v1/openapi.json to serve the OpenAPI document")
+	}
+}
+
+func TestOpenAPIOperationID(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{http.MethodGet, "/v1/networks", "getV1Networks"},
+		{http.MethodGet, "/v1/networks/{id}", "getV1NetworksById"},
+	}
+
+	for _, tt := range tests {
+		if got := openAPIOperationID(tt.method, tt.path); got != tt.expected {
+			t.Errorf("openAPIOperationID(%s, %s) = %s, want %s", tt.method, tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestAdminCreateTunnelValidation(t *testing.T) {
+	_, mux := newAdminTestPlugin(t)
+
+	destination := strings.Repeat("A", 516)
+	tests := []struct {
+		name string
+		path string
+		body interface{}
+		want string
+	}{
+		{name: "client without owner", path: "/v1/tunnels/client", body: ClientTunnelRequest{Destination: "forum.i2p", Port: 8080}, want: "owner"},
+		{name: "client without destination", path: "/v1/tunnels/client", body: ClientTunnelRequest{Owner: "app", Port: 8080}, want: "destination"},
+		{name: "client port out of range", path: "/v1/tunnels/client", body: ClientTunnelRequest{Owner: "app", Destination: "forum.i2p", Port: 70000}, want: "out of range"},
+		{name: "server with invalid public key", path: "/v1/tunnels/server", body: ServerTunnelRequest{Owner: "app", PublicKey: "short", PrivateKey: "short", Port: 80}, want: "public destination"},
+		{name: "server with foreign private key", path: "/v1/tunnels/server", body: ServerTunnelRequest{Owner: "app", PublicKey: destination, PrivateKey: "BBBB", Port: 80}, want: "do not belong"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := json.Marshal(tt.body)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(string(data))))
+
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("Expected 400 mentioning %q, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
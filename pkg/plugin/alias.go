@@ -0,0 +1,77 @@
+// Package plugin provides human-friendly aliases of joined containers.
+//
+// Operators recognise containers by their Compose service or their name, not
+// by 64 hex character IDs. A joined container gets an alias from its i2p.alias
+// label or, for Compose services, from the project, service and container
+// number labels Compose sets ("webapp_web_1"). The alias names the
+// container's tunnels ("webapp_web_1-http-80-<hash>") and appears in log
+// messages, the admin API, the endpoint's operational data and metrics
+// labels, and admin lookups accept it in place of a container ID.
+//
+// The alias is derived from the labels in the Join options, which are saved
+// with the endpoint, so a container keeps its alias and tunnel names when the
+// plugin restarts.
+package plugin
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+// AliasLabel is the container label naming the alias shown for a container
+// in place of its ID.
+const AliasLabel = "i2p.alias"
+
+// containerAlias returns the alias of a container from its labels, or an
+// empty string if it has none.
+//
+// An i2p.alias label takes precedence; Compose containers are named
+// <project>_<service>_<container number>.
+func containerAlias(options map[string]interface{}) string {
+	labels, ok := options["Labels"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if alias, ok := labels[AliasLabel].(string); ok && alias != "" {
+		return i2p.SanitizeAlias(alias)
+	}
+
+	project, _ := labels[composeProjectLabel].(string)
+	serviceName, _ := labels[composeServiceLabel].(string)
+	if project == "" || serviceName == "" {
+		return ""
+	}
+	number, _ := labels[composeNumberLabel].(string)
+	if number == "" {
+		number = "1"
+	}
+	return i2p.SanitizeAlias(fmt.Sprintf("%s_%s_%s", project, serviceName, number))
+}
+
+// assignAliasLocked records a joining container in nm.joined and registers
+// its alias before any of its tunnels is named. Another joined container with
+// the same alias is reported, as admin lookups by the alias become
+// ambiguous; tunnel names stay unique. The caller must hold the network's
+// mutex.
+func (nm *NetworkManager) assignAliasLocked(endpoint *I2PEndpoint, options map[string]interface{}) {
+	endpoint.Alias = containerAlias(options)
+	for _, other := range nm.joined.add(endpoint.ContainerID, endpoint.Alias) {
+		log.Printf("Warning: Containers %s and %s share the alias %s",
+			i2p.ShortID(other), i2p.ShortID(endpoint.ContainerID), endpoint.Alias)
+	}
+	if endpoint.Alias != "" {
+		i2p.SetContainerAlias(endpoint.ContainerID, endpoint.Alias)
+	}
+}
+
+// releaseAliasLocked forgets a joined endpoint of a container in nm.joined
+// and removes the alias of a container that has left every network. The
+// caller must hold the network's mutex.
+func (nm *NetworkManager) releaseAliasLocked(containerID string) {
+	if containerID == "" || !nm.joined.remove(containerID) {
+		return
+	}
+	i2p.SetContainerAlias(containerID, "")
+}
@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+func TestContainerAlias(t *testing.T) {
+	labels := func(values map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"Labels": values}
+	}
+
+	tests := []struct {
+		name     string
+		options  map[string]interface{}
+		expected string
+	}{
+		{name: "no labels", options: map[string]interface{}{}},
+		{name: "label", options: labels(map[string]interface{}{AliasLabel: "blog"}), expected: "blog"},
+		{name: "sanitized label", options: labels(map[string]interface{}{AliasLabel: "my blog!"}), expected: "my_blog"},
+		{name: "compose", options: labels(map[string]interface{}{composeProjectLabel: "webapp", composeServiceLabel: "web"}), expected: "webapp_web_1"},
+		{name: "compose replica", options: labels(map[string]interface{}{composeProjectLabel: "webapp", composeServiceLabel: "web", composeNumberLabel: "3"}), expected: "webapp_web_3"},
+		{name: "label overrides compose", options: labels(map[string]interface{}{composeProjectLabel: "webapp", composeServiceLabel: "web", AliasLabel: "blog"}), expected: "blog"},
+		{name: "truncated", options: labels(map[string]interface{}{AliasLabel: strings.Repeat("x", 40)}), expected: strings.Repeat("x", i2p.MaxAliasLength)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if alias := containerAlias(tt.options); alias != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, alias)
+			}
+		})
+	}
+}
+
+func TestAliasJoinAndLeave(t *testing.T) {
+	nm := createMockNetworkManager(t)
+
+	_, subnet, _ := net.ParseCIDR("10.32.0.0/24")
+	gateway := net.ParseIP("10.32.0.1")
+	nm.addNetworkLocked(&I2PNetwork{
+		ID:            "net1",
+		Subnet:        subnet,
+		Gateway:       gateway,
+		Endpoints:     map[string]*I2PEndpoint{},
+		IPAllocator:   NewIPAllocator(subnet, gateway),
+		TunnelManager: nm.tunnelMgr,
+	})
+	if _, err := nm.CreateEndpoint("net1", "ep1", nil); err != nil {
+		t.Fatalf("Failed to create endpoint: %v", err)
+	}
+
+	containerID := strings.Repeat("c", 64)
+	options := map[string]interface{}{"Labels": map[string]interface{}{composeProjectLabel: "webapp", composeServiceLabel: "web"}}
+	endpoint, err := nm.JoinEndpoint("net1", "ep1", containerID, "", options)
+	if err != nil {
+		t.Fatalf("Failed to join endpoint: %v", err)
+	}
+	if endpoint.Alias != "webapp_web_1" || endpoint.adminView().Alias != "webapp_web_1" {
+		t.Errorf("Expected the Compose alias, got %q", endpoint.Alias)
+	}
+	name := service.ExposureTunnelName(containerID, service.ExposedPort{ServiceName: "http", ContainerPort: 80, ExposureType: service.ExposureTypeI2P})
+	if !strings.HasPrefix(name, "webapp_web_1-http-80-") {
+		t.Errorf("Expected tunnels to be named by the alias, got %s", name)
+	}
+	if info, _ := nm.EndpointOperInfo("net1", "ep1"); info[aliasKey] != "webapp_web_1" {
+		t.Errorf("Expected the alias in the operational data, got %v", info[aliasKey])
+	}
+
+	nm.mutex.Lock()
+	resolved, err := nm.resolveJoinedContainerLocked("webapp_web_1")
+	nm.mutex.Unlock()
+	if err != nil || resolved != containerID {
+		t.Errorf("Expected the alias to resolve to the container, got %q (%v)", resolved, err)
+	}
+
+	if err := nm.LeaveEndpoint("net1", "ep1"); err != nil {
+		t.Fatalf("Failed to leave endpoint: %v", err)
+	}
+	if endpoint.Alias != "" {
+		t.Errorf("Expected the parked endpoint to drop its alias, got %q", endpoint.Alias)
+	}
+	if _, exists := i2p.ContainerAlias(containerID); exists {
+		t.Error("Expected the alias to be released once the container left")
+	}
+}
@@ -0,0 +1,169 @@
+// Package plugin provides reporting of traffic filter anomalies.
+//
+// The proxy flags containers whose blocked non-I2P attempts or filter denials
+// rise abruptly. Every flagged anomaly is logged as a warning naming the
+// container, so it shows up in the admin API's log stream, is listed at
+// GET /v1/anomalies and, when an anomaly webhook is configured, posted to it
+// as JSON.
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+// anomalyWebhookTimeout bounds each anomaly webhook request.
+const anomalyWebhookTimeout = 5 * time.Second
+
+// AdminAnomaly describes an abrupt rise in a container's filter refusals.
+type AdminAnomaly struct {
+	// ContainerID is the container whose connections were refused
+	ContainerID string `json:"container_id"`
+	// NetworkID is the network the container is on, if it is still attached
+	NetworkID string `json:"network_id,omitempty"`
+	// Network is the name of the container's network
+	Network string `json:"network,omitempty"`
+	// Tenant is the tenant of the container's network (see the i2p.tenant option)
+	Tenant string `json:"tenant,omitempty"`
+	// Kind is "non_i2p_blocked" or "denied"
+	Kind string `json:"kind"`
+	// Count is the number of refusals in the window that was flagged
+	Count int64 `json:"count"`
+	// Baseline is the number of refusals per window expected from history
+	Baseline float64 `json:"baseline"`
+	// Window is the interval Count and Baseline refer to, e.g. "1m0s"
+	Window string `json:"window"`
+	// DetectedAt is when the anomaly was flagged
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// AnomalyNotification is the body posted to the anomaly webhook.
+type AnomalyNotification struct {
+	// Event is always "filter_anomaly"
+	Event string `json:"event"`
+	// Anomaly is the flagged anomaly
+	Anomaly AdminAnomaly `json:"anomaly"`
+}
+
+// anomalyWebhook posts anomaly notifications to a URL.
+type anomalyWebhook struct {
+	url    string
+	client *http.Client
+}
+
+// SetAnomalyWebhook sets the URL flagged filter anomalies are posted to. An
+// empty URL disables notifications.
+//
+// May be called while the plugin runs.
+func (p *Plugin) SetAnomalyWebhook(target string) error {
+	if target == "" {
+		p.anomalyWebhook.Store(nil)
+		return nil
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("invalid anomaly webhook URL %q: %w", target, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid anomaly webhook URL %q: must be an http or https URL", target)
+	}
+
+	p.anomalyWebhook.Store(&anomalyWebhook{url: target, client: &http.Client{Timeout: anomalyWebhookTimeout}})
+	return nil
+}
+
+// reportAnomaly logs a flagged anomaly and notifies the webhook, if any.
+//
+// The webhook is called in the background so the connection that triggered
+// the anomaly is not held up.
+func (p *Plugin) reportAnomaly(anomaly proxy.Anomaly) {
+	// Name the network so the warning is tagged in the log stream
+	view := p.adminAnomaly(anomaly)
+	location := ""
+	if view.NetworkID != "" {
+		location = " on network " + view.NetworkID
+	}
+	log.Printf("Warning: Filter anomaly for container %s%s: %d %s refusals in %s, baseline %.1f",
+		anomaly.ContainerID, location, anomaly.Count, anomaly.Kind, anomaly.Window, anomaly.Baseline)
+
+	webhook := p.anomalyWebhook.Load()
+	if webhook == nil {
+		return
+	}
+	go func() {
+		if err := webhook.notify(view); err != nil {
+			log.Printf("Warning: Failed to notify anomaly webhook of container %s: %v", anomaly.ContainerID, err)
+		}
+	}()
+}
+
+// notify posts an anomaly to the webhook and fails on non-2xx responses.
+func (w *anomalyWebhook) notify(anomaly AdminAnomaly) error {
+	body, err := json.Marshal(AnomalyNotification{Event: "filter_anomaly", Anomaly: anomaly})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("POST %s failed: %w", req.URL.Redacted(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("POST %s returned %s", req.URL.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+// adminAnomaly returns the admin API representation of an anomaly, tagged
+// with the container's network if it is still attached to one.
+func (p *Plugin) adminAnomaly(anomaly proxy.Anomaly) AdminAnomaly {
+	tag, _ := p.networkMgr.containerTag(anomaly.ContainerID)
+	return AdminAnomaly{
+		ContainerID: anomaly.ContainerID,
+		NetworkID:   tag.ID,
+		Network:     tag.Name,
+		Tenant:      tag.Tenant,
+		Kind:        anomaly.Kind,
+		Count:       anomaly.Count,
+		Baseline:    anomaly.Baseline,
+		Window:      anomaly.Window.String(),
+		DetectedAt:  anomaly.DetectedAt,
+	}
+}
+
+// handleAdminAnomalies lists recently flagged filter anomalies, newest first.
+func (p *Plugin) handleAdminAnomalies(w http.ResponseWriter, r *http.Request) {
+	container := r.URL.Query().Get("container")
+	tenant := r.URL.Query().Get("tenant")
+
+	anomalies := []AdminAnomaly{}
+	for _, anomaly := range p.networkMgr.proxyMgr.GetAnomalies() {
+		if container != "" && !strings.HasPrefix(anomaly.ContainerID, container) {
+			continue
+		}
+		view := p.adminAnomaly(anomaly)
+		if tenant != "" && view.Tenant != tenant {
+			continue
+		}
+		anomalies = append(anomalies, view)
+	}
+
+	writeAdminList(p, w, r, anomalies)
+}
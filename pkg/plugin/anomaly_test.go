@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+func TestAdminAnomalies(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+
+	filter := p.networkMgr.proxyMgr.GetTrafficFilter()
+	for i := int64(0); i < proxy.DefaultAnomalyConfig().MinEvents; i++ {
+		filter.ShouldAllowConnectionFrom("container1", "example.com:443", "tcp")
+		filter.ShouldAllowConnectionFrom("container2", "example.org:443", "tcp")
+	}
+
+	var anomalies []AdminAnomaly
+	adminGet(t, mux, "/v1/anomalies", &anomalies)
+	if len(anomalies) != 2 {
+		t.Fatalf("Expected 2 anomalies, got %+v", anomalies)
+	}
+
+	adminGet(t, mux, "/v1/anomalies?container=container1", &anomalies)
+	if len(anomalies) != 1 || anomalies[0].ContainerID != "container1" || anomalies[0].Kind != proxy.AnomalyNonI2PBlocked || anomalies[0].Window != "1m0s" {
+		t.Errorf("Unexpected anomalies for container1: %+v", anomalies)
+	}
+}
+
+func TestAnomalyWebhook(t *testing.T) {
+	received := make(chan AnomalyNotification, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notification AnomalyNotification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+			t.Errorf("Failed to decode notification: %v", err)
+		}
+		received <- notification
+	}))
+	defer server.Close()
+
+	p, _ := newAdminTestPlugin(t)
+	if err := p.SetAnomalyWebhook("ftp://example.com/hook"); err == nil {
+		t.Error("Expected non-HTTP webhook URL to be rejected")
+	}
+	if err := p.SetAnomalyWebhook(server.URL); err != nil {
+		t.Fatalf("Failed to set anomaly webhook: %v", err)
+	}
+
+	p.reportAnomaly(proxy.Anomaly{ContainerID: "container1", Kind: proxy.AnomalyDenied, Count: 12, Window: time.Minute, DetectedAt: time.Now()})
+
+	select {
+	case notification := <-received:
+		if notification.Event != "filter_anomaly" || notification.Anomaly.ContainerID != "container1" || notification.Anomaly.Count != 12 {
+			t.Errorf("Unexpected notification %+v", notification)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the webhook to be notified")
+	}
+
+	// Disabling the webhook stops notifications
+	if err := p.SetAnomalyWebhook(""); err != nil {
+		t.Fatalf("Failed to disable anomaly webhook: %v", err)
+	}
+	p.reportAnomaly(proxy.Anomaly{ContainerID: "container1", Kind: proxy.AnomalyDenied})
+	select {
+	case notification := <-received:
+		t.Errorf("Expected no notification after disabling the webhook, got %+v", notification)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,586 @@
+// Package plugin provides bulk operations for the admin API.
+//
+// This file implements batch endpoints that expose and unexpose ports, edit
+// traffic filter lists and destroy tunnels in a single request. Every
+// operation in a batch is validated before anything is changed, and atomic
+// batches are rolled back when an operation fails, so orchestration scripts
+// never leave the plugin in a half-applied state.
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+// maxBatchOperations caps the number of operations in a single batch request.
+const maxBatchOperations = 256
+
+// Batch operation result statuses.
+const (
+	batchApplied    = "applied"
+	batchFailed     = "failed"
+	batchInvalid    = "invalid"
+	batchSkipped    = "skipped"
+	batchRolledBack = "rolled_back"
+)
+
+// ExposureBatchRequest exposes or unexposes several container ports.
+type ExposureBatchRequest struct {
+	// Atomic rolls back applied operations when one fails
+	Atomic bool `json:"atomic"`
+	// Operations are applied in order
+	Operations []ExposureOperation `json:"operations"`
+}
+
+// ExposureOperation exposes or unexposes a single container port.
+type ExposureOperation struct {
+	// Action is either "expose" or "unexpose"
+	Action string `json:"action"`
+	// ContainerID is the container providing the service
+	ContainerID string `json:"container_id"`
+	// Network selects the network (ID or name) if the container is on several
+	Network string `json:"network,omitempty"`
+	// ContainerPort is the port inside the container
+	ContainerPort int `json:"container_port"`
+	// Protocol is tcp (default) or udp
+	Protocol string `json:"protocol,omitempty"`
+	// Type is "i2p" or "ip", defaulting to the network's exposure default (expose only)
+	Type string `json:"type,omitempty"`
+	// TargetIP is the host address for IP exposure (expose only)
+	TargetIP string `json:"target_ip,omitempty"`
+	// HostPort is the host port for IP exposure, defaulting to ContainerPort (expose only)
+	HostPort int `json:"host_port,omitempty"`
+	// ServiceName is an optional name used in tunnel names (expose only)
+	ServiceName string `json:"service_name,omitempty"`
+}
+
+// FilterBatchRequest adds or removes several traffic filter rules.
+type FilterBatchRequest struct {
+	// Atomic rolls back applied operations when one fails
+	Atomic bool `json:"atomic"`
+	// Operations are applied in order
+	Operations []FilterOperation `json:"operations"`
+}
+
+// FilterOperation adds or removes a single traffic filter rule.
+type FilterOperation struct {
+	// Action is either "add" or "remove"
+	Action string `json:"action"`
+	// List is either "allowlist" or "blocklist"
+	List string `json:"list"`
+	// Destination is an I2P destination or wildcard pattern
+	Destination string `json:"destination"`
+}
+
+// TunnelBatchRequest destroys several tunnels.
+//
+// Destroyed tunnels cannot be restored, so an atomic tunnel batch stops at the
+// first failure instead of rolling back.
+type TunnelBatchRequest struct {
+	// Atomic stops at the first failure and skips the remaining operations
+	Atomic bool `json:"atomic"`
+	// Names are the tunnels to destroy
+	Names []string `json:"names"`
+}
+
+// BatchResult reports the outcome of a batch request.
+type BatchResult struct {
+	// Applied is the number of operations that took effect and were kept
+	Applied int `json:"applied"`
+	// Failed is the number of invalid or failed operations
+	Failed int `json:"failed"`
+	// RolledBack reports whether an atomic batch was rolled back
+	RolledBack bool `json:"rolled_back"`
+	// Results holds one entry per operation, in request order
+	Results []BatchItemResult `json:"results"`
+}
+
+// BatchItemResult reports the outcome of a single batch operation.
+type BatchItemResult struct {
+	// Index is the operation's position in the request
+	Index int `json:"index"`
+	// Status is applied, failed, invalid, skipped or rolled_back
+	Status string `json:"status"`
+	// Error describes why the operation failed
+	Error string `json:"error,omitempty"`
+	// Destination is the resulting .b32.i2p address or host IP:port of an exposure
+	Destination string `json:"destination,omitempty"`
+}
+
+// batchStatuses documents the non-200 outcomes of batch routes.
+var batchStatuses = map[int]string{
+	http.StatusMultiStatus:         "Some operations failed; the others were applied",
+	http.StatusConflict:            "An operation of an atomic batch failed and the batch was rolled back",
+	http.StatusUnprocessableEntity: "The batch contains invalid operations; nothing was applied",
+}
+
+// batchStep is a single operation of a batch.
+type batchStep struct {
+	// validate checks the operation without changing anything
+	validate func() error
+	// apply performs the operation and returns an optional destination
+	apply func() (string, error)
+	// undo reverts a successful apply (nil if the operation is irreversible)
+	undo func() error
+}
+
+// runBatch validates and applies batch steps in order.
+//
+// If any step is invalid nothing is applied and 422 is returned. Otherwise
+// every step is applied; a failure in an atomic batch skips the remaining
+// steps, undoes the applied ones in reverse order and returns 409. Partial
+// failures in non-atomic batches return 207.
+func runBatch(steps []batchStep, atomic bool) (BatchResult, int) {
+	result := BatchResult{Results: make([]BatchItemResult, len(steps))}
+	for i := range result.Results {
+		result.Results[i].Index = i
+	}
+
+	for i, step := range steps {
+		if err := step.validate(); err != nil {
+			result.Results[i].Status = batchInvalid
+			result.Results[i].Error = err.Error()
+			result.Failed++
+		}
+	}
+	if result.Failed > 0 {
+		for i := range result.Results {
+			if result.Results[i].Status == "" {
+				result.Results[i].Status = batchSkipped
+			}
+		}
+		return result, http.StatusUnprocessableEntity
+	}
+
+	for i, step := range steps {
+		destination, err := step.apply()
+		if err != nil {
+			result.Results[i].Status = batchFailed
+			result.Results[i].Error = err.Error()
+			result.Failed++
+
+			if atomic {
+				for j := i + 1; j < len(steps); j++ {
+					result.Results[j].Status = batchSkipped
+				}
+				rollbackBatch(steps[:i], &result)
+				return result, http.StatusConflict
+			}
+			continue
+		}
+
+		result.Results[i].Status = batchApplied
+		result.Results[i].Destination = destination
+		result.Applied++
+	}
+
+	if result.Failed > 0 {
+		return result, http.StatusMultiStatus
+	}
+	return result, http.StatusOK
+}
+
+// rollbackBatch undoes applied steps in reverse order.
+//
+// Irreversible steps and steps whose undo fails stay applied and are still
+// counted in result.Applied.
+func rollbackBatch(steps []batchStep, result *BatchResult) {
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i].undo == nil {
+			continue
+		}
+		if err := steps[i].undo(); err != nil {
+			log.Printf("Warning: Failed to roll back batch operation %d: %v", i, err)
+			result.Results[i].Error = fmt.Sprintf("rollback failed: %v", err)
+			continue
+		}
+		result.Results[i].Status = batchRolledBack
+		result.Results[i].Destination = ""
+		result.Applied--
+		result.RolledBack = true
+	}
+}
+
+// readBatchRequest decodes a batch request and checks its size.
+//
+// It writes an error response and returns false if the request is unusable.
+func (p *Plugin) readBatchRequest(w http.ResponseWriter, r *http.Request, v interface{}, count func() int) bool {
+	if err := p.readJSONRequest(r, v); err != nil {
+		p.writeAdminError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+
+	switch n := count(); {
+	case n == 0:
+		p.writeAdminError(w, http.StatusBadRequest, "batch contains no operations")
+		return false
+	case n > maxBatchOperations:
+		p.writeAdminError(w, http.StatusBadRequest,
+			fmt.Sprintf("batch contains %d operations, the maximum is %d", n, maxBatchOperations))
+		return false
+	}
+	return true
+}
+
+// writeBatchResult writes a batch result with the given status code.
+func (p *Plugin) writeBatchResult(w http.ResponseWriter, result BatchResult, status int) {
+	log.Printf("Admin batch %s: %d applied, %d failed, rolled back: %t",
+		http.StatusText(status), result.Applied, result.Failed, result.RolledBack)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Error encoding batch result: %v", err)
+	}
+}
+
+// handleAdminExposureBatch exposes and unexposes container ports in bulk.
+func (p *Plugin) handleAdminExposureBatch(w http.ResponseWriter, r *http.Request) {
+	var req ExposureBatchRequest
+	if !p.readBatchRequest(w, r, &req, func() int { return len(req.Operations) }) {
+		return
+	}
+
+	nm := p.networkMgr
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	steps := make([]batchStep, len(req.Operations))
+	for i, op := range req.Operations {
+		steps[i] = nm.exposureStep(op)
+	}
+
+	result, status := runBatch(steps, req.Atomic)
+	nm.saveStateLocked()
+	p.writeBatchResult(w, result, status)
+}
+
+// handleAdminFilterBatch adds and removes traffic filter rules in bulk.
+func (p *Plugin) handleAdminFilterBatch(w http.ResponseWriter, r *http.Request) {
+	var req FilterBatchRequest
+	if !p.readBatchRequest(w, r, &req, func() int { return len(req.Operations) }) {
+		return
+	}
+
+	// Filter lists live in the proxy manager, so hold the network manager lock
+	// to keep concurrent batches from interleaving
+	nm := p.networkMgr
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	steps := make([]batchStep, len(req.Operations))
+	for i, op := range req.Operations {
+		steps[i] = nm.filterStep(op)
+	}
+
+	result, status := runBatch(steps, req.Atomic)
+	p.writeBatchResult(w, result, status)
+}
+
+// handleAdminTunnelBatch destroys tunnels in bulk.
+func (p *Plugin) handleAdminTunnelBatch(w http.ResponseWriter, r *http.Request) {
+	var req TunnelBatchRequest
+	if !p.readBatchRequest(w, r, &req, func() int { return len(req.Names) }) {
+		return
+	}
+
+	nm := p.networkMgr
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	seen := make(map[string]bool)
+	steps := make([]batchStep, len(req.Names))
+	for i, name := range req.Names {
+		duplicate := seen[name]
+		seen[name] = true
+		steps[i] = nm.destroyTunnelStep(name, duplicate)
+	}
+
+	result, status := runBatch(steps, req.Atomic)
+	p.writeBatchResult(w, result, status)
+}
+
+// exposureStep builds the batch step for an exposure operation.
+//
+// The caller must hold nm.mutex for the lifetime of the step.
+func (nm *NetworkManager) exposureStep(op ExposureOperation) batchStep {
+	var network *I2PNetwork
+	var endpoint *I2PEndpoint
+
+	validate := func() error {
+		if op.Action != "expose" && op.Action != "unexpose" {
+			return fmt.Errorf("unknown action %q (expected expose or unexpose)", op.Action)
+		}
+		if op.ContainerPort < 1 || op.ContainerPort > 65535 {
+			return fmt.Errorf("container port %d is out of range", op.ContainerPort)
+		}
+		if protocol := strings.ToLower(op.Protocol); protocol != "" && protocol != "tcp" && protocol != "udp" {
+			return fmt.Errorf("unsupported protocol %q (expected tcp or udp)", op.Protocol)
+		}
+		if op.Type != "" && op.Type != string(service.ExposureTypeI2P) && op.Type != string(service.ExposureTypeIP) {
+			return fmt.Errorf("unknown exposure type %q (expected i2p or ip)", op.Type)
+		}
+
+		var err error
+		network, endpoint, err = nm.containerEndpointLocked(op.ContainerID, op.Network)
+		if err != nil {
+			return err
+		}
+
+		if op.Action == "expose" {
+			exposureType := service.ExposureType(op.Type)
+			if exposureType == "" {
+				exposureType = network.ExposureConfig.DefaultExposureType
+			}
+			if exposureType == service.ExposureTypeI2P && endpoint.ClientOnly {
+				return fmt.Errorf("container %s is client-only and cannot expose I2P services", op.ContainerID)
+			}
+			if exposureType == service.ExposureTypeIP {
+				if !network.ExposureConfig.AllowIPExposure {
+					return fmt.Errorf("IP exposure is not allowed on network %s", network.ID)
+				}
+				targetIP := op.TargetIP
+				if targetIP == "" {
+					targetIP = "127.0.0.1"
+				}
+				if err := network.ExposureConfig.ValidateTargetIP(targetIP); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	expose := func(port service.ExposedPort) (string, error) {
+		exposure, err := nm.serviceMgr.ExposeService(op.ContainerID, network.ID, endpoint.IPAddress, port)
+		if err != nil {
+			return "", err
+		}
+		network.mutex.Lock()
+		endpoint.ServiceExposures = append(endpoint.ServiceExposures, exposure)
+		network.mutex.Unlock()
+		return exposure.Destination, nil
+	}
+
+	unexpose := func() (*service.ServiceExposure, error) {
+		exposure, err := nm.serviceMgr.UnexposeService(op.ContainerID, op.ContainerPort, op.Protocol)
+		if exposure != nil {
+			network.mutex.Lock()
+			for i, existing := range endpoint.ServiceExposures {
+				if existing == exposure {
+					endpoint.ServiceExposures = append(endpoint.ServiceExposures[:i:i], endpoint.ServiceExposures[i+1:]...)
+					break
+				}
+			}
+			network.mutex.Unlock()
+		}
+		return exposure, err
+	}
+
+	if op.Action == "unexpose" {
+		var removed *service.ServiceExposure
+		return batchStep{
+			validate: validate,
+			apply: func() (string, error) {
+				exposure, err := unexpose()
+				if exposure != nil {
+					removed = exposure
+				}
+				return "", err
+			},
+			undo: func() error {
+				_, err := expose(removed.Port)
+				return err
+			},
+		}
+	}
+
+	return batchStep{
+		validate: validate,
+		apply: func() (string, error) {
+			exposureType := service.ExposureType(op.Type)
+			if exposureType == "" {
+				exposureType = network.ExposureConfig.DefaultExposureType
+			}
+			return expose(service.ExposedPort{
+				ContainerPort: op.ContainerPort,
+				Protocol:      strings.ToLower(op.Protocol),
+				ServiceName:   op.ServiceName,
+				ExposureType:  exposureType,
+				TargetIP:      op.TargetIP,
+				HostPort:      op.HostPort,
+			})
+		},
+		undo: func() error {
+			_, err := unexpose()
+			return err
+		},
+	}
+}
+
+// containerEndpointLocked finds the joined endpoint of a container.
+//
+// networkRef (ID or name) is required only when the container is joined to
+// more than one I2P network. The caller must hold nm.mutex but no network's
+// mutex.
+func (nm *NetworkManager) containerEndpointLocked(containerID, networkRef string) (*I2PNetwork, *I2PEndpoint, error) {
+	if containerID == "" {
+		return nil, nil, fmt.Errorf("container ID cannot be empty")
+	}
+
+	var networks []*I2PNetwork
+	if networkRef != "" {
+		network := nm.lookupNetworkLocked(networkRef)
+		if network == nil {
+			return nil, nil, fmt.Errorf("network %s not found", networkRef)
+		}
+		networks = []*I2PNetwork{network}
+	} else {
+		for _, network := range nm.networks {
+			networks = append(networks, network)
+		}
+	}
+
+	var foundNetwork *I2PNetwork
+	var foundEndpoint *I2PEndpoint
+	several := false
+	for _, network := range networks {
+		network.mutex.RLock()
+		for _, endpoint := range network.Endpoints {
+			if endpoint.State != EndpointJoined || endpoint.ContainerID != containerID {
+				continue
+			}
+			several = foundEndpoint != nil
+			foundNetwork, foundEndpoint = network, endpoint
+		}
+		network.mutex.RUnlock()
+		if several {
+			return nil, nil, fmt.Errorf("container %s is joined to several I2P networks, set network", containerID)
+		}
+	}
+
+	if foundEndpoint == nil {
+		return nil, nil, fmt.Errorf("container %s is not joined to an I2P network", containerID)
+	}
+	return foundNetwork, foundEndpoint, nil
+}
+
+// filterStep builds the batch step for a traffic filter operation.
+func (nm *NetworkManager) filterStep(op FilterOperation) batchStep {
+	proxyMgr := nm.proxyMgr
+
+	add, remove, list := proxyMgr.AddToAllowlist, proxyMgr.RemoveFromAllowlist, proxyMgr.GetAllowlist
+	if op.List == "blocklist" {
+		add, remove, list = proxyMgr.AddToBlocklist, proxyMgr.RemoveFromBlocklist, proxyMgr.GetBlocklist
+	}
+
+	contains := func() bool {
+		for _, entry := range list() {
+			if entry == strings.ToLower(op.Destination) {
+				return true
+			}
+		}
+		return false
+	}
+
+	step := batchStep{
+		validate: func() error {
+			if op.List != "allowlist" && op.List != "blocklist" {
+				return fmt.Errorf("unknown list %q (expected allowlist or blocklist)", op.List)
+			}
+			if op.Action != "add" && op.Action != "remove" {
+				return fmt.Errorf("unknown action %q (expected add or remove)", op.Action)
+			}
+			if op.Destination == "" {
+				return fmt.Errorf("destination cannot be empty")
+			}
+			return nil
+		},
+	}
+
+	if op.Action == "remove" {
+		step.apply = func() (string, error) {
+			if !contains() {
+				return "", fmt.Errorf("%s is not on the %s", op.Destination, op.List)
+			}
+			remove(op.Destination)
+			return "", nil
+		}
+		step.undo = func() error {
+			return add(op.Destination)
+		}
+		return step
+	}
+
+	var added bool
+	step.apply = func() (string, error) {
+		added = !contains()
+		return "", add(op.Destination)
+	}
+	step.undo = func() error {
+		// Rules that were already present before the batch are left alone
+		if added {
+			remove(op.Destination)
+		}
+		return nil
+	}
+	return step
+}
+
+// destroyTunnelStep builds the batch step that destroys a tunnel.
+//
+// Tunnels backing service exposures are refused so exposures never point at
+// a missing tunnel; they are removed with an unexpose operation instead. The
+// caller must hold nm.mutex for the lifetime of the step.
+func (nm *NetworkManager) destroyTunnelStep(name string, duplicate bool) batchStep {
+	return batchStep{
+		validate: func() error {
+			if duplicate {
+				return fmt.Errorf("tunnel %s is listed more than once", name)
+			}
+			if _, exists := nm.tunnelMgr.GetTunnel(name); !exists {
+				return fmt.Errorf("tunnel %s not found", name)
+			}
+			for _, network := range nm.networks {
+				for _, endpoint := range network.Endpoints {
+					for _, exposure := range endpoint.ServiceExposures {
+						if exposure.Tunnel != nil && exposure.TunnelName == name {
+							return fmt.Errorf("tunnel %s backs an exposure of container %s, unexpose it instead",
+								name, exposure.ContainerID)
+						}
+					}
+				}
+			}
+			return nil
+		},
+		apply: func() (string, error) {
+			if err := nm.tunnelMgr.DestroyTunnel(name); err != nil {
+				return "", err
+			}
+
+			// Forget the tunnel on the endpoint that created it
+			for _, network := range nm.networks {
+				network.mutex.Lock()
+				for _, endpoint := range network.Endpoints {
+					for key, tunnel := range endpoint.ClientTunnels {
+						if tunnel.GetConfig().Name == name {
+							delete(endpoint.ClientTunnels, key)
+						}
+					}
+					for key, tunnel := range endpoint.ServerTunnels {
+						if tunnel.GetConfig().Name == name {
+							delete(endpoint.ServerTunnels, key)
+						}
+					}
+				}
+				network.mutex.Unlock()
+			}
+			return "", nil
+		},
+	}
+}
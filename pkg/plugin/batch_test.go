@@ -0,0 +1,240 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+// adminPost sends a JSON request to the admin API and decodes a batch result.
+func adminPost(t *testing.T, mux *http.ServeMux, path string, body interface{}) (*httptest.ResponseRecorder, BatchResult) {
+	t.Helper()
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to encode request: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(data))))
+
+	var result BatchResult
+	if w.Code != http.StatusBadRequest {
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("Failed to decode batch result: %v (body %q)", err, w.Body.String())
+		}
+	}
+	return w, result
+}
+
+// freeTCPPort returns a TCP port that is currently free on 127.0.0.1.
+func freeTCPPort(t *testing.T) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestRunBatch(t *testing.T) {
+	var log []string
+	step := func(name string, applyErr error, reversible bool) batchStep {
+		s := batchStep{
+			validate: func() error { return nil },
+			apply: func() (string, error) {
+				if applyErr != nil {
+					return "", applyErr
+				}
+				log = append(log, "apply "+name)
+				return "", nil
+			},
+		}
+		if reversible {
+			s.undo = func() error {
+				log = append(log, "undo "+name)
+				return nil
+			}
+		}
+		return s
+	}
+
+	// Invalid operations prevent anything from being applied
+	invalid := step("b", nil, true)
+	invalid.validate = func() error { return errors.New("bad") }
+	result, status := runBatch([]batchStep{step("a", nil, true), invalid}, true)
+	if status != http.StatusUnprocessableEntity || len(log) != 0 {
+		t.Errorf("Expected 422 without side effects, got %d and %v", status, log)
+	}
+	if result.Results[0].Status != batchSkipped || result.Results[1].Status != batchInvalid {
+		t.Errorf("Unexpected results: %+v", result.Results)
+	}
+
+	// Atomic batches undo applied operations in reverse order
+	log = nil
+	result, status = runBatch([]batchStep{
+		step("a", nil, true),
+		step("b", nil, false),
+		step("c", nil, true),
+		step("d", errors.New("boom"), true),
+		step("e", nil, true),
+	}, true)
+	if status != http.StatusConflict || !result.RolledBack {
+		t.Errorf("Expected rolled back 409, got %d (%+v)", status, result)
+	}
+	expectedLog := "apply a,apply b,apply c,undo c,undo a"
+	if got := strings.Join(log, ","); got != expectedLog {
+		t.Errorf("Expected %s, got %s", expectedLog, got)
+	}
+	var statuses []string
+	for _, r := range result.Results {
+		statuses = append(statuses, r.Status)
+	}
+	expectedStatuses := "rolled_back,applied,rolled_back,failed,skipped"
+	if got := strings.Join(statuses, ","); got != expectedStatuses {
+		t.Errorf("Expected statuses %s, got %s", expectedStatuses, got)
+	}
+	if result.Applied != 1 || result.Failed != 1 {
+		t.Errorf("Expected 1 irreversible operation to stay applied, got %+v", result)
+	}
+
+	// Non-atomic batches report partial failures
+	log = nil
+	result, status = runBatch([]batchStep{step("a", nil, true), step("b", errors.New("boom"), true), step("c", nil, true)}, false)
+	if status != http.StatusMultiStatus || result.Applied != 2 || result.Failed != 1 || result.RolledBack {
+		t.Errorf("Expected 207 with 2 applied, got %d (%+v)", status, result)
+	}
+}
+
+func TestAdminExposureBatch(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+	network := p.networkMgr.GetNetwork("net1")
+	network.ExposureConfig = service.NetworkExposureConfig{DefaultExposureType: service.ExposureTypeIP, AllowIPExposure: true}
+	endpoint := network.Endpoints["ep1"]
+	t.Cleanup(func() { p.networkMgr.serviceMgr.CleanupServices("container1") })
+
+	webPort, apiPort := freeTCPPort(t), freeTCPPort(t)
+	w, result := adminPost(t, mux, "/v1/exposures/batch", ExposureBatchRequest{
+		Operations: []ExposureOperation{
+			{Action: "expose", ContainerID: "container1", ContainerPort: 80, HostPort: webPort},
+			{Action: "expose", ContainerID: "container1", ContainerPort: 8080, HostPort: apiPort},
+		},
+	})
+	if w.Code != http.StatusOK || result.Applied != 2 {
+		t.Fatalf("Expected both exposures to be applied, got %d: %s", w.Code, w.Body.String())
+	}
+	if expected := fmt.Sprintf("127.0.0.1:%d", webPort); result.Results[0].Destination != expected {
+		t.Errorf("Expected destination %s, got %s", expected, result.Results[0].Destination)
+	}
+	if len(endpoint.ServiceExposures) != 2 {
+		t.Errorf("Expected 2 endpoint exposures, got %d", len(endpoint.ServiceExposures))
+	}
+
+	// The duplicate expose fails, so the unexpose before it is rolled back
+	w, result = adminPost(t, mux, "/v1/exposures/batch", ExposureBatchRequest{
+		Atomic: true,
+		Operations: []ExposureOperation{
+			{Action: "unexpose", ContainerID: "container1", ContainerPort: 80},
+			{Action: "expose", ContainerID: "container1", ContainerPort: 8080, HostPort: apiPort},
+		},
+	})
+	if w.Code != http.StatusConflict || !result.RolledBack || result.Results[0].Status != batchRolledBack {
+		t.Fatalf("Expected rolled back batch, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(endpoint.ServiceExposures) != 2 {
+		t.Errorf("Expected rollback to restore 2 exposures, got %d", len(endpoint.ServiceExposures))
+	}
+	if listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", webPort)); err == nil {
+		listener.Close()
+		t.Errorf("Expected port %d to be forwarded again after rollback", webPort)
+	}
+
+	// Validation failures change nothing
+	w, result = adminPost(t, mux, "/v1/exposures/batch", ExposureBatchRequest{
+		Operations: []ExposureOperation{
+			{Action: "unexpose", ContainerID: "container1", ContainerPort: 80},
+			{Action: "expose", ContainerID: "unknown", ContainerPort: 80},
+		},
+	})
+	if w.Code != http.StatusUnprocessableEntity || result.Results[1].Status != batchInvalid {
+		t.Fatalf("Expected 422 for unknown container, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(endpoint.ServiceExposures) != 2 {
+		t.Errorf("Expected invalid batch to leave 2 exposures, got %d", len(endpoint.ServiceExposures))
+	}
+
+	// IP exposure is refused when the network does not allow it
+	network.ExposureConfig.AllowIPExposure = false
+	w, _ = adminPost(t, mux, "/v1/exposures/batch", ExposureBatchRequest{
+		Operations: []ExposureOperation{{Action: "expose", ContainerID: "container1", ContainerPort: 9090, Type: "ip"}},
+	})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for disallowed IP exposure, got %d", w.Code)
+	}
+}
+
+func TestAdminFilterBatch(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+	proxyMgr := p.networkMgr.proxyMgr
+
+	w, result := adminPost(t, mux, "/v1/filters/batch", FilterBatchRequest{
+		Atomic: true,
+		Operations: []FilterOperation{
+			{Action: "add", List: "allowlist", Destination: "good.i2p"},
+			{Action: "add", List: "allowlist", Destination: "example.com"},
+		},
+	})
+	if w.Code != http.StatusConflict || !result.RolledBack {
+		t.Fatalf("Expected rolled back batch, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(proxyMgr.GetAllowlist()) != 0 {
+		t.Errorf("Expected empty allowlist after rollback, got %v", proxyMgr.GetAllowlist())
+	}
+
+	w, result = adminPost(t, mux, "/v1/filters/batch", FilterBatchRequest{
+		Operations: []FilterOperation{
+			{Action: "add", List: "allowlist", Destination: "good.i2p"},
+			{Action: "add", List: "blocklist", Destination: "*.bad.i2p"},
+			{Action: "remove", List: "blocklist", Destination: "missing.i2p"},
+		},
+	})
+	if w.Code != http.StatusMultiStatus || result.Applied != 2 || result.Results[2].Status != batchFailed {
+		t.Fatalf("Expected partial success, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(proxyMgr.GetAllowlist()) != 1 || len(proxyMgr.GetBlocklist()) != 1 {
+		t.Errorf("Expected one rule per list, got %v and %v", proxyMgr.GetAllowlist(), proxyMgr.GetBlocklist())
+	}
+
+	w, result = adminPost(t, mux, "/v1/filters/batch", FilterBatchRequest{
+		Operations: []FilterOperation{{Action: "add", List: "denylist", Destination: "good.i2p"}},
+	})
+	if w.Code != http.StatusUnprocessableEntity || result.Results[0].Status != batchInvalid {
+		t.Errorf("Expected 422 for unknown list, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAdminTunnelBatch(t *testing.T) {
+	_, mux := newAdminTestPlugin(t)
+
+	w, result := adminPost(t, mux, "/v1/tunnels/batch", TunnelBatchRequest{Names: []string{"missing", "missing"}})
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected 422 for unknown tunnels, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(result.Results[0].Error, "not found") || !strings.Contains(result.Results[1].Error, "more than once") {
+		t.Errorf("Unexpected results: %+v", result.Results)
+	}
+
+	w, _ = adminPost(t, mux, "/v1/tunnels/batch", TunnelBatchRequest{})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for empty batch, got %d", w.Code)
+	}
+}
@@ -0,0 +1,208 @@
+// Package plugin provides the plugin's build and environment report.
+//
+// Bug reports and scripts both need to know exactly what they are talking
+// to: which build of the plugin, which optional features are switched on and
+// which SAM bridge and protocol version sessions are negotiated with. The
+// plugin logs this when it starts and after every reload, serves it at
+// GET /v1/info and exports it as the i2p_plugin_info metric.
+package plugin
+
+import (
+	"log"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/metrics"
+)
+
+// Feature flags reported in AdminInfo.
+const (
+	FeatureAdminAPI           = "admin_api"
+	FeatureGRPCAPI            = "grpc_api"
+	FeatureRateLimits         = "rate_limits"
+	FeatureRequestTracing     = "request_tracing"
+	FeatureAnomalyWebhook     = "anomaly_webhook"
+	FeatureStatsPersistence   = "stats_persistence"
+	FeatureSmokeTest          = "smoke_test"
+	FeatureSAMFailover        = "sam_failover"
+	FeatureSAMOperationPacing = "sam_operation_pacing"
+)
+
+// BuildInfo identifies the plugin build.
+type BuildInfo struct {
+	// Version is the release version, e.g. "v0.4.0" or "dev"
+	Version string `json:"version"`
+	// Commit is the git commit the plugin was built from
+	Commit string `json:"commit"`
+	// BuildTime is when the plugin was built
+	BuildTime string `json:"build_time"`
+}
+
+// AdminSAMHandshake describes the latest handshake with the SAM bridge.
+type AdminSAMHandshake struct {
+	// Bridge is the host:port of the SAM bridge
+	Bridge string `json:"bridge"`
+	// Version is the SAM protocol version the bridge selected (unset if the
+	// handshake failed)
+	Version string `json:"version,omitempty"`
+	// Reply is the bridge's complete HELLO reply; SAM does not report the
+	// router's own version, but bridges may add details here
+	Reply string `json:"reply,omitempty"`
+	// Latency is the handshake round trip, e.g. "3ms"
+	Latency string `json:"latency,omitempty"`
+	// CheckedAt is when the handshake was performed
+	CheckedAt time.Time `json:"checked_at"`
+	// Error is why the handshake failed
+	Error string `json:"error,omitempty"`
+}
+
+// AdminInfo describes the plugin build and its environment.
+type AdminInfo struct {
+	BuildInfo
+	// GoVersion is the Go toolchain the plugin was built with
+	GoVersion string `json:"go_version"`
+	// AdminAPIRevision is the semantic version of the admin API
+	AdminAPIRevision string `json:"admin_api_revision"`
+	// Features reports which optional features are enabled
+	Features map[string]bool `json:"features"`
+	// SAM is the latest handshake with the SAM bridge (unset before the
+	// first handshake)
+	SAM *AdminSAMHandshake `json:"sam,omitempty"`
+}
+
+// buildInfoState holds the build information and the latest SAM handshake.
+type buildInfoState struct {
+	mutex sync.Mutex
+	build BuildInfo
+	sam   *AdminSAMHandshake
+}
+
+// defaultBuildInfo is reported when the binary does not set build information.
+var defaultBuildInfo = BuildInfo{Version: "dev", Commit: "unknown", BuildTime: "unknown"}
+
+// buildLocked returns the build information, or defaultBuildInfo if none was
+// set. The caller must hold the mutex.
+func (s *buildInfoState) buildLocked() BuildInfo {
+	if s.build == (BuildInfo{}) {
+		return defaultBuildInfo
+	}
+	return s.build
+}
+
+// SetBuildInfo sets the build information the plugin reports. It should be
+// called before Start so the activation log includes it.
+func (p *Plugin) SetBuildInfo(build BuildInfo) {
+	p.buildInfo.mutex.Lock()
+	defer p.buildInfo.mutex.Unlock()
+	p.buildInfo.build = build
+}
+
+// Info returns the plugin build, its enabled features and the latest SAM
+// handshake.
+func (p *Plugin) Info() AdminInfo {
+	p.buildInfo.mutex.Lock()
+	info := AdminInfo{
+		BuildInfo:        p.buildInfo.buildLocked(),
+		GoVersion:        runtime.Version(),
+		AdminAPIRevision: AdminAPIRevision,
+	}
+	if p.buildInfo.sam != nil {
+		sam := *p.buildInfo.sam
+		info.SAM = &sam
+	}
+	p.buildInfo.mutex.Unlock()
+
+	info.Features = p.features()
+	return info
+}
+
+// features reports which optional features are enabled.
+func (p *Plugin) features() map[string]bool {
+	sam := p.networkMgr.tunnelMgr.SAMConfig()
+	return map[string]bool{
+		FeatureAdminAPI:           p.adminSockPath != "",
+		FeatureGRPCAPI:            p.grpcSockPath != "",
+		FeatureRateLimits:         p.rateLimits.RequestsPerSecond > 0,
+		FeatureRequestTracing:     p.tracer.Load() != nil,
+		FeatureAnomalyWebhook:     p.anomalyWebhook.Load() != nil,
+		FeatureStatsPersistence:   p.stats.path != "",
+		FeatureSmokeTest:          p.smokeTestEnabled(),
+		FeatureSAMFailover:        len(sam.Backups) > 0,
+		FeatureSAMOperationPacing: sam.OperationRate > 0,
+	}
+}
+
+// probeSAM performs a SAM handshake with the active router and records the
+// outcome.
+func (p *Plugin) probeSAM() AdminSAMHandshake {
+	handshake, err := p.networkMgr.tunnelMgr.ProbeSAM()
+
+	result := AdminSAMHandshake{
+		Bridge:    handshake.Bridge,
+		Version:   handshake.Version,
+		Reply:     handshake.Reply,
+		CheckedAt: time.Now(),
+	}
+	if handshake.Latency > 0 {
+		result.Latency = handshake.Latency.Round(time.Millisecond).String()
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	p.buildInfo.mutex.Lock()
+	p.buildInfo.sam = &result
+	p.buildInfo.mutex.Unlock()
+	return result
+}
+
+// logActivation performs a SAM handshake and logs the plugin build, its
+// enabled features and the handshake outcome.
+func (p *Plugin) logActivation() {
+	p.probeSAM()
+	info := p.Info()
+
+	var enabled []string
+	for feature, on := range info.Features {
+		if on {
+			enabled = append(enabled, feature)
+		}
+	}
+	sort.Strings(enabled)
+	if len(enabled) == 0 {
+		enabled = []string{"none"}
+	}
+
+	log.Printf("Plugin build: version %s, commit %s, built %s, %s, admin API %s",
+		info.Version, info.Commit, info.BuildTime, info.GoVersion, info.AdminAPIRevision)
+	log.Printf("Enabled features: %s", strings.Join(enabled, ", "))
+	if info.SAM.Error != "" {
+		log.Printf("Warning: SAM handshake with %s failed: %s", info.SAM.Bridge, info.SAM.Error)
+		return
+	}
+	log.Printf("SAM handshake with %s: SAM %s in %s (reply %q)", info.SAM.Bridge, info.SAM.Version, info.SAM.Latency, info.SAM.Reply)
+}
+
+// buildInfoSamples returns the plugin_info sample labelled with the build and
+// the negotiated SAM version.
+func (p *Plugin) buildInfoSamples() []metrics.Sample {
+	p.buildInfo.mutex.Lock()
+	defer p.buildInfo.mutex.Unlock()
+
+	samVersion := ""
+	if p.buildInfo.sam != nil {
+		samVersion = p.buildInfo.sam.Version
+	}
+	build := p.buildInfo.buildLocked()
+	return []metrics.Sample{{LabelValues: []string{build.Version, build.Commit, samVersion}, Value: 1}}
+}
+
+// handleAdminInfo returns the plugin build, its features and the latest SAM
+// handshake.
+func (p *Plugin) handleAdminInfo(w http.ResponseWriter, r *http.Request) {
+	p.writeJSONResponse(w, p.Info())
+}
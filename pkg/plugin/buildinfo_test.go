@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/fakesam"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/testutil"
+)
+
+func TestAdminInfo(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+	p.SetBuildInfo(BuildInfo{Version: "v1.2.3", Commit: "abc1234", BuildTime: "2026-01-01"})
+	p.SetSmokeTest(true)
+
+	var info AdminInfo
+	if w := adminGet(t, mux, "/v1/info", &info); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if info.Version != "v1.2.3" || info.Commit != "abc1234" || info.AdminAPIRevision != AdminAPIRevision || info.GoVersion == "" {
+		t.Errorf("Unexpected build info %+v", info)
+	}
+	if !info.Features[FeatureSmokeTest] || info.Features[FeatureSAMFailover] {
+		t.Errorf("Unexpected features %v", info.Features)
+	}
+	if _, ok := info.Features[FeatureSAMOperationPacing]; !ok {
+		t.Errorf("Expected every feature to be reported, got %v", info.Features)
+	}
+	if info.SAM != nil {
+		t.Errorf("Expected no SAM handshake before the first probe, got %+v", info.SAM)
+	}
+}
+
+func TestProbeSAMOverFakeSAM(t *testing.T) {
+	server := testutil.StartFakeSAM(t, fakesam.ServerOptions{})
+	p, mux := newAdminTestPlugin(t)
+	p.networkMgr.tunnelMgr = i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: fakeSAMConfig(server)})
+	p.logActivation()
+
+	var info AdminInfo
+	adminGet(t, mux, "/v1/info", &info)
+	if info.SAM == nil || info.SAM.Error != "" || info.SAM.Bridge != server.Addr() || info.SAM.Version != "3.3" {
+		t.Fatalf("Unexpected SAM handshake %+v", info.SAM)
+	}
+	if !strings.HasPrefix(info.SAM.Reply, "HELLO REPLY RESULT=OK") || info.SAM.CheckedAt.IsZero() {
+		t.Errorf("Unexpected SAM handshake %+v", info.SAM)
+	}
+
+	var out bytes.Buffer
+	p.metrics.WritePrometheus(&out)
+	if want := `i2p_plugin_info{version="dev",commit="unknown",sam_version="3.3"} 1`; !strings.Contains(out.String(), want) {
+		t.Errorf("Expected metrics to contain %q, got:\n%s", want, out.String())
+	}
+
+	// A bridge that went away is reported, not fatal
+	server.Close()
+	if handshake := p.probeSAM(); handshake.Error == "" || handshake.Version != "" {
+		t.Errorf("Expected a failed handshake, got %+v", handshake)
+	}
+}
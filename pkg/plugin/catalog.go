@@ -0,0 +1,325 @@
+// Package plugin provides the catalog of supported options and labels.
+//
+// This file is the central registry of every `-o i2p.*` network option,
+// endpoint option and `i2p.*` container label the plugin understands, with its
+// type, default and description. The catalog is served from the admin API at
+// GET /v1/options and printed by `i2pnet options`, so features added in any
+// package stay discoverable. New options must be registered here.
+package plugin
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+// Option scopes, i.e. where an option or label is set.
+const (
+	// ScopeNetwork options are set with `docker network create -o`
+	ScopeNetwork = "network"
+	// ScopeEndpoint options are set per container connection (driver options)
+	ScopeEndpoint = "endpoint"
+	// ScopeContainer labels are set with `docker run --label`
+	ScopeContainer = "container"
+)
+
+// CatalogOption describes a supported option or label.
+type CatalogOption struct {
+	// Name is the option or label key; <placeholders> stand for variable parts
+	Name string `json:"name"`
+	// Scope is where the option is set: network, endpoint or container
+	Scope string `json:"scope"`
+	// Type is the value type: string, bool, int, list or duration
+	Type string `json:"type"`
+	// Default is the value used when the option is absent (empty if none)
+	Default string `json:"default,omitempty"`
+	// Values lists the accepted values of enumerated options
+	Values []string `json:"values,omitempty"`
+	// Description explains what the option does
+	Description string `json:"description"`
+}
+
+// optionCatalog holds every supported option and label.
+var optionCatalog = []CatalogOption{
+	// Network options
+	{
+		Name:        "name",
+		Scope:       ScopeNetwork,
+		Type:        "string",
+		Description: "Plugin-visible network name; must be unique and can replace the network ID in admin API lookups",
+	},
+	{
+		Name:        "i2p.exposure.default",
+		Scope:       ScopeNetwork,
+		Type:        "string",
+		Default:     string(service.ExposureTypeI2P),
+		Values:      []string{string(service.ExposureTypeI2P), string(service.ExposureTypeIP)},
+		Description: "Exposure type of ports without an explicit i2p.expose label",
+	},
+	{
+		Name:        "i2p.exposure.allow_ip",
+		Scope:       ScopeNetwork,
+		Type:        "bool",
+		Default:     "true",
+		Description: "Allow ports to be exposed on host IP addresses",
+	},
+	{
+		Name:        "i2p.exposure.allowed_targets",
+		Scope:       ScopeNetwork,
+		Type:        "list",
+		Description: "Comma-separated IPs or interface names IP exposures may bind to (default: any)",
+	},
+	{
+		Name:        FilterModeOption,
+		Scope:       ScopeNetwork,
+		Type:        "string",
+		Default:     "blocklist",
+		Values:      []string{"allowlist", "blocklist", "disabled"},
+		Description: "Traffic filter mode for outbound I2P connections",
+	},
+	{
+		Name:        FilterAllowlistOption,
+		Scope:       ScopeNetwork,
+		Type:        "list",
+		Description: "Comma-separated destinations allowed in allowlist mode (wildcards like *.i2p supported)",
+	},
+	{
+		Name:        FilterBlocklistOption,
+		Scope:       ScopeNetwork,
+		Type:        "list",
+		Description: "Comma-separated destinations blocked in blocklist mode (wildcards like *.i2p supported)",
+	},
+	{
+		Name:        ExternalServicesOption,
+		Scope:       ScopeNetwork,
+		Type:        "list",
+		Description: "Comma-separated name=b32 pairs of external I2P services containers on the network resolve by name; the addresses are allowlisted",
+	},
+	{
+		Name:        "i2p.ipam.strategy",
+		Scope:       ScopeNetwork,
+		Type:        "string",
+		Default:     StrategySequential,
+		Values:      []string{StrategySequential, StrategyRandom, StrategySticky},
+		Description: "Container IP allocation strategy",
+	},
+	{
+		Name:        DryRunOption,
+		Scope:       ScopeNetwork,
+		Type:        "bool",
+		Default:     "false",
+		Description: "Validate the options and report the planned network, listeners and iptables rules instead of creating the network",
+	},
+	{
+		Name:        "i2p.proxy.bind",
+		Scope:       ScopeNetwork,
+		Type:        "string",
+		Default:     proxy.BindGateway,
+		Values:      []string{proxy.BindGateway, proxy.BindAny, "<ip>"},
+		Description: "Address the SOCKS proxy and DNS resolver listen on; any is restricted to container subnets by iptables",
+	},
+	{
+		Name:        AntiSpoofOption,
+		Scope:       ScopeNetwork,
+		Type:        "bool",
+		Default:     "true",
+		Description: "Bind each joined endpoint's IP and MAC addresses with ebtables and iptables rules so containers cannot spoof each other",
+	},
+	{
+		Name:        IsolatedOption,
+		Scope:       ScopeNetwork,
+		Type:        "bool",
+		Default:     "false",
+		Description: "Block direct traffic between the network's containers; only members of a shared isolation group may reach each other",
+	},
+	{
+		Name:        MaxEndpointsOption,
+		Scope:       ScopeNetwork,
+		Type:        "int",
+		Default:     "0",
+		Description: "Maximum number of endpoints on the network; further endpoint creations fail (0 is unlimited)",
+	},
+	{
+		Name:        MTUOption,
+		Scope:       ScopeNetwork,
+		Type:        "int",
+		Default:     "0",
+		Description: "MTU of the network's bridge and container interfaces with the ip link backend, also accepted as com.docker.network.driver.mtu (0 keeps the kernel default)",
+	},
+	{
+		Name:        TenantOption,
+		Scope:       ScopeNetwork,
+		Type:        "string",
+		Description: "Tenant the network belongs to; its metrics, log events and anomalies are tagged with it and admin listings can be filtered by it",
+	},
+	{
+		Name:        TunnelOptionPrefix + "<option>",
+		Scope:       ScopeNetwork,
+		Type:        "string",
+		Description: "Override a tunnel default for the sessions of the network's containers; <option> is one of " + strings.Join(i2p.TunnelOptionNames(), ", "),
+	},
+	{
+		Name:        TunnelOptionPrefix + "<i2cp option>",
+		Scope:       ScopeNetwork,
+		Type:        "string",
+		Description: "Pass an I2CP, tunnel pool or streaming option starting with i2cp., inbound., outbound. or streaming. to the router, e.g. i2p.tunnel.inbound.IPRestriction=1",
+	},
+	{
+		Name:        PolicyOption,
+		Scope:       ScopeNetwork,
+		Type:        "string",
+		Values:      Policies(),
+		Description: "Policy template setting filter mode, exposure, isolation and anti-spoofing options at once; explicit options override it",
+	},
+	{
+		Name:        "i2p.sidecar.socks_path",
+		Scope:       ScopeNetwork,
+		Type:        "string",
+		Default:     defaultSidecarSOCKSPath,
+		Description: "SOCKS socket path template for sidecars ({name}, {network}, {endpoint}, {container}); empty disables",
+	},
+	{
+		Name:        "i2p.sidecar.dns_path",
+		Scope:       ScopeNetwork,
+		Type:        "string",
+		Default:     defaultSidecarDNSPath,
+		Description: "DNS API socket path template for sidecars ({name}, {network}, {endpoint}, {container}); empty disables",
+	},
+
+	// Endpoint options
+	{
+		Name:        "i2p.ipam.sticky_key",
+		Scope:       ScopeEndpoint,
+		Type:        "string",
+		Description: "Key under which the sticky strategy remembers the endpoint's address",
+	},
+	{
+		Name:        RequestedIPv4Option,
+		Scope:       ScopeEndpoint,
+		Type:        "string",
+		Description: "IPv4 address to request for the endpoint where Docker assigns none (like --ip)",
+	},
+	{
+		Name:        RequestedIPv6Option,
+		Scope:       ScopeEndpoint,
+		Type:        "string",
+		Description: "IPv6 address to request for the endpoint where Docker assigns none (like --ip6)",
+	},
+
+	// Container labels
+	{
+		Name:        "i2p.expose.<port>",
+		Scope:       ScopeContainer,
+		Type:        "string",
+		Description: "Expose a port: i2p, ip or ip:<address>, optionally followed by ;ttl=<duration> and ;inbound_only=true",
+	},
+	{
+		Name:        "i2p.expose.<port>.after",
+		Scope:       ScopeContainer,
+		Type:        "list",
+		Description: "Comma-separated container ports that must be listening before the port is exposed",
+	},
+	{
+		Name:        service.PortMapLabelPrefix + "<port>",
+		Scope:       ScopeContainer,
+		Type:        "string",
+		Description: "Forward <gateway>:<port> to an I2P destination: <destination>[:<port>], with comma-separated fallbacks tried when it is unreachable",
+	},
+	{
+		Name:        service.PresetLabel,
+		Scope:       ScopeContainer,
+		Type:        "list",
+		Values:      service.PresetNames(),
+		Description: "Comma-separated exposure presets expanding into ports, tunnel options and HTTP headers",
+	},
+	{
+		Name:        service.ModeLabel,
+		Scope:       ScopeContainer,
+		Type:        "string",
+		Values:      []string{service.ModeClient},
+		Description: "Set to client to reach I2P without ever creating server tunnels for the container",
+	},
+	{
+		Name:        service.TunnelStrategyLabel,
+		Scope:       ScopeContainer,
+		Type:        "string",
+		Default:     service.TunnelStrategyPerPort,
+		Values:      service.TunnelStrategies(),
+		Description: "Serve I2P exposures from one server sub-session per port or from a single shared one routed by port",
+	},
+	{
+		Name:        service.ExposureGroupLabel,
+		Scope:       ScopeContainer,
+		Type:        "string",
+		Description: "Serve the container's I2P ports from the destination of this exposure group, shared with its other members",
+	},
+	{
+		Name:        service.ExposureBalanceLabel,
+		Scope:       ScopeContainer,
+		Type:        "string",
+		Default:     service.BalanceRoundRobin,
+		Values:      service.BalanceModes(),
+		Description: "Spread the exposure group's inbound streams across members in turn or to the member with the fewest streams",
+	},
+	{
+		Name:        service.OnDemandLabel,
+		Scope:       ScopeContainer,
+		Type:        "duration",
+		Default:     "false",
+		Description: "Keep the container's I2P ports reachable while it is stopped, start it on the first inbound stream and stop it after this idle timeout (true for " + service.DefaultOnDemandIdle.String() + ")",
+	},
+	{
+		Name:        IsolationGroupLabel,
+		Scope:       ScopeContainer,
+		Type:        "list",
+		Description: "Comma-separated isolation groups; on isolated networks, containers sharing a group may reach each other directly",
+	},
+	{
+		Name:        OutboundLabel,
+		Scope:       ScopeContainer,
+		Type:        "bool",
+		Default:     "true",
+		Description: "Set to false for inbound-only containers: drop their connections to the proxy and skip port maps and sidecar sockets",
+	},
+	{
+		Name:        IdentityLabel,
+		Scope:       ScopeContainer,
+		Type:        "string",
+		Description: "Identity the container keeps across recreation; a new container with the same identity reuses its destination (Compose services are identified automatically)",
+	},
+	{
+		Name:        AliasLabel,
+		Scope:       ScopeContainer,
+		Type:        "string",
+		Description: "Name the container is shown under in tunnel names, logs, metrics and the admin API in place of its ID (Compose services are named <project>_<service>_<number> automatically)",
+	},
+	{
+		Name:        SidecarLabel,
+		Scope:       ScopeContainer,
+		Type:        "string",
+		Description: "Serve the SOCKS proxy and DNS API on Unix sockets for the container under this name",
+	},
+}
+
+// Catalog returns the supported options and labels, optionally limited to a scope.
+//
+// Options are sorted by scope and name.
+func Catalog(scope string) []CatalogOption {
+	options := make([]CatalogOption, 0, len(optionCatalog))
+	for _, option := range optionCatalog {
+		if scope == "" || strings.EqualFold(option.Scope, scope) {
+			options = append(options, option)
+		}
+	}
+
+	sort.SliceStable(options, func(i, j int) bool {
+		if options[i].Scope != options[j].Scope {
+			return options[i].Scope > options[j].Scope // network, endpoint, container
+		}
+		return options[i].Name < options[j].Name
+	})
+	return options
+}
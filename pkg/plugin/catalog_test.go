@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCatalog(t *testing.T) {
+	options := Catalog("")
+	if len(options) != len(optionCatalog) {
+		t.Fatalf("Expected %d options, got %d", len(optionCatalog), len(options))
+	}
+
+	seen := make(map[string]bool)
+	for _, option := range options {
+		if seen[option.Name] {
+			t.Errorf("Duplicate catalog entry %s", option.Name)
+		}
+		seen[option.Name] = true
+
+		if option.Name != "name" && !strings.HasPrefix(option.Name, "i2p.") {
+			t.Errorf("Option %s should use the i2p. prefix", option.Name)
+		}
+		if option.Scope != ScopeNetwork && option.Scope != ScopeEndpoint && option.Scope != ScopeContainer {
+			t.Errorf("Option %s has unknown scope %q", option.Name, option.Scope)
+		}
+		if option.Type == "" || option.Description == "" {
+			t.Errorf("Option %s needs a type and description", option.Name)
+		}
+	}
+
+	for _, name := range []string{"i2p.filter.mode", "i2p.ipam.sticky_key", "i2p.expose.<port>", "i2p.preset", "i2p.mode"} {
+		if !seen[name] {
+			t.Errorf("Expected catalog to contain %s", name)
+		}
+	}
+
+	if options[0].Scope != ScopeNetwork || options[len(options)-1].Scope != ScopeContainer {
+		t.Error("Expected network options first and container labels last")
+	}
+}
+
+func TestCatalogScope(t *testing.T) {
+	for _, option := range Catalog("Container") {
+		if option.Scope != ScopeContainer {
+			t.Errorf("Expected only container labels, got %s (%s)", option.Name, option.Scope)
+		}
+	}
+	if options := Catalog("bogus"); len(options) != 0 {
+		t.Errorf("Expected no options for unknown scope, got %d", len(options))
+	}
+}
+
+func TestHandleAdminOptions(t *testing.T) {
+	p := &Plugin{}
+
+	rec := httptest.NewRecorder()
+	p.handleAdminOptions(rec, httptest.NewRequest(http.MethodGet, "/v1/options?scope=endpoint", nil))
+
+	var options []CatalogOption
+	if err := json.Unmarshal(rec.Body.Bytes(), &options); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	names := make([]string, 0, len(options))
+	for _, option := range options {
+		if option.Scope != ScopeEndpoint {
+			t.Errorf("Expected only endpoint options, got %+v", option)
+		}
+		names = append(names, option.Name)
+	}
+	expected := []string{RequestedIPv4Option, RequestedIPv6Option, "i2p.ipam.sticky_key"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected endpoint options %v, got %v", expected, names)
+	}
+}
@@ -0,0 +1,117 @@
+// Package plugin provides name-based discovery of containers on a network.
+//
+// Docker's embedded DNS server resolves container names and network aliases
+// for containers that use it, but clients querying the network's DNS resolver
+// directly only see what the plugin knows. The plugin therefore records the
+// network aliases Docker passes for an endpoint (docker run --network-alias,
+// and the service and container names Compose sets) in the Aliases option of
+// CreateEndpoint and Join, and registers them with the DNS resolver, together
+// with the container's i2p.alias, while the endpoint is joined. Other
+// containers on the same network then resolve the names to the container's
+// addresses, as they would through Docker's resolver.
+package plugin
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+// EndpointAliasesOption is the CreateEndpoint and Join option listing the
+// network aliases of an endpoint
+const EndpointAliasesOption = "Aliases"
+
+// endpointAliases returns the network aliases in options, lowercased and
+// without trailing dots. Aliases that are not DNS names, or that are I2P
+// names and would shadow them, are skipped with a warning.
+func endpointAliases(options map[string]interface{}) []string {
+	var values []string
+	switch value := options[EndpointAliasesOption].(type) {
+	case []interface{}:
+		for _, entry := range value {
+			if alias, ok := entry.(string); ok {
+				values = append(values, alias)
+			}
+		}
+	case []string:
+		values = value
+	case string:
+		values = strings.Split(value, ",")
+	}
+
+	var aliases []string
+	for _, alias := range values {
+		alias = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(alias)), ".")
+		if alias == "" {
+			continue
+		}
+		if !validDNSName(alias) || alias == "i2p" || strings.HasSuffix(alias, ".i2p") {
+			log.Printf("Warning: Ignoring network alias %q: not a DNS name outside .i2p", alias)
+			continue
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases
+}
+
+// mergeAliases returns the aliases of both lists, sorted and without
+// duplicates.
+func mergeAliases(aliases, more []string) []string {
+	seen := make(map[string]bool, len(aliases)+len(more))
+	var merged []string
+	for _, alias := range append(append([]string(nil), aliases...), more...) {
+		if !seen[alias] {
+			seen[alias] = true
+			merged = append(merged, alias)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// dnsNames returns the names an endpoint's container is found under on its
+// network: its network aliases and its alias, if that is a DNS name.
+func (e *I2PEndpoint) dnsNames() []string {
+	var alias []string
+	if name := strings.ToLower(e.Alias); name != "" && validDNSName(name) && !strings.HasSuffix(name, ".i2p") {
+		alias = []string{name}
+	}
+	return mergeAliases(e.Aliases, alias)
+}
+
+// registerContainerNamesLocked makes a joined endpoint's names resolve to its
+// addresses on its network. The caller must hold the network's mutex.
+func (nm *NetworkManager) registerContainerNamesLocked(network *I2PNetwork, endpoint *I2PEndpoint) {
+	names := endpoint.dnsNames()
+	nm.proxyMgr.SetContainerNames(network.ID, endpoint.ID, names, endpoint.IPAddress, endpoint.IPv6Address)
+	if len(names) > 0 {
+		log.Printf("Container %s resolves as %s on network %s", i2p.DisplayName(endpoint.ContainerID), strings.Join(names, ", "), network.ID)
+	}
+}
+
+// forgetContainerNamesLocked stops an endpoint's names from resolving. The
+// caller must hold the network's mutex.
+func (nm *NetworkManager) forgetContainerNamesLocked(network *I2PNetwork, endpoint *I2PEndpoint) {
+	nm.proxyMgr.SetContainerNames(network.ID, endpoint.ID, nil, nil, nil)
+}
+
+// validDNSName reports whether name is a hostname of letters, digits and
+// hyphens in labels of at most 63 characters.
+func validDNSName(name string) bool {
+	if len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
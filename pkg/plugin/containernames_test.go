@@ -0,0 +1,64 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEndpointAliases(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  map[string]interface{}
+		expected string
+	}{
+		{name: "unset", options: map[string]interface{}{}},
+		{name: "from JSON", options: map[string]interface{}{EndpointAliasesOption: []interface{}{"Web", "db.", 7}}, expected: "web db"},
+		{name: "strings", options: map[string]interface{}{EndpointAliasesOption: []string{"api"}}, expected: "api"},
+		{name: "comma-separated", options: map[string]interface{}{EndpointAliasesOption: " web , ,cache"}, expected: "web cache"},
+		{name: "invalid skipped", options: map[string]interface{}{EndpointAliasesOption: []string{"bad_name", "forum.i2p", "i2p", "ok"}}, expected: "ok"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if aliases := strings.Join(endpointAliases(tt.options), " "); aliases != tt.expected {
+				t.Errorf("endpointAliases() = %q, want %q", aliases, tt.expected)
+			}
+		})
+	}
+
+	if merged := strings.Join(mergeAliases([]string{"web", "db"}, []string{"db", "api"}), " "); merged != "api db web" {
+		t.Errorf("mergeAliases() = %q, want sorted aliases without duplicates", merged)
+	}
+}
+
+func TestJoinRecordsAliases(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	nm := p.networkMgr
+	if _, err := nm.CreateEndpoint("net1", "ep2", map[string]interface{}{EndpointAliasesOption: []interface{}{"web"}}); err != nil {
+		t.Fatalf("CreateEndpoint() unexpected error: %v", err)
+	}
+	if _, err := nm.JoinEndpoint("net1", "ep2", "container2", "/var/run/docker/netns/container2",
+		map[string]interface{}{EndpointAliasesOption: []interface{}{"frontend", "web"}}); err != nil {
+		t.Fatalf("JoinEndpoint() unexpected error: %v", err)
+	}
+
+	endpoint := nm.GetNetwork("net1").Endpoints["ep2"]
+	if aliases := strings.Join(endpoint.Aliases, " "); aliases != "frontend web" {
+		t.Errorf("Expected the aliases of CreateEndpoint and Join, got %q", aliases)
+	}
+	endpoint.Alias = "Shop"
+	if names := strings.Join(endpoint.dnsNames(), " "); names != "frontend shop web" {
+		t.Errorf("Expected the aliases and i2p.alias as DNS names, got %q", names)
+	}
+	endpoint.Alias = "shop.i2p"
+	if names := strings.Join(endpoint.dnsNames(), " "); names != "frontend web" {
+		t.Errorf("Expected an .i2p alias to be left out of DNS names, got %q", names)
+	}
+
+	if view := endpoint.adminView(); strings.Join(view.Aliases, " ") != "frontend web" {
+		t.Errorf("Expected the aliases in the admin view, got %v", view.Aliases)
+	}
+	if err := nm.LeaveEndpoint("net1", "ep2"); err != nil {
+		t.Fatalf("LeaveEndpoint() unexpected error: %v", err)
+	}
+}
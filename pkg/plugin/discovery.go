@@ -0,0 +1,161 @@
+// Package plugin provides the table of Docker nodes discovered in global
+// scope.
+//
+// Docker tells global scope drivers about the nodes of its cluster with
+// DiscoverNew and DiscoverDelete notifications, including one about the node
+// the plugin runs on. The plugin instance of a discovered node is trusted as
+// a mesh peer once it announces itself from that node: the first control
+// address announcing a discovered node is bound to it, so instances in a
+// cluster only need a single configured peer between them to exchange
+// services. When Docker reports a node as gone, its instance and the services
+// it announced are forgotten right away instead of after meshPeerTimeout.
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// NodeDiscovery is the DiscoveryType of notifications about cluster nodes.
+const NodeDiscovery = 1
+
+// parseNodeDiscovery returns the node address of a node discovery
+// notification and whether it is about the plugin's own node.
+func parseNodeDiscovery(req DiscoveryNotification) (address string, self bool, err error) {
+	if req.DiscoveryType != NodeDiscovery {
+		return "", false, nil
+	}
+	address, _ = req.DiscoveryData["Address"].(string)
+	if address == "" {
+		return "", false, fmt.Errorf("node discovery without an address")
+	}
+	self, _ = req.DiscoveryData["Self"].(bool)
+	return address, self, nil
+}
+
+// discoverNode records a discovered node; a node of the plugin itself is
+// named in its announcements.
+func (d *meshDirectory) discoverNode(address string, self bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if self {
+		d.node = address
+		return
+	}
+	if d.nodes == nil {
+		d.nodes = make(map[string]string)
+	}
+	if _, exists := d.nodes[address]; !exists {
+		d.nodes[address] = ""
+	}
+}
+
+// forgetNode forgets a node that left the cluster with the instance bound to
+// it. A configured peer is kept but loses its services.
+func (d *meshDirectory) forgetNode(address string, self bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if self {
+		d.node = ""
+		return
+	}
+	source, exists := d.nodes[address]
+	delete(d.nodes, address)
+	if !exists || source == "" {
+		return
+	}
+
+	peer, exists := d.peers[source]
+	if !exists {
+		return
+	}
+	if peer.static {
+		peer.node = ""
+		peer.networks = nil
+		return
+	}
+	delete(d.peers, source)
+	log.Printf("Docker node %s left, forgetting mesh peer %s", address, source)
+}
+
+// selfNode returns the address of the plugin's own node, if discovered.
+func (d *meshDirectory) selfNode() string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.node
+}
+
+// bindNodeLocked binds a discovered node no instance announced yet to source,
+// making source a peer if it is not one, and reports whether it did. The
+// caller must hold d.mutex.
+func (d *meshDirectory) bindNodeLocked(source, node string) bool {
+	if node == "" {
+		return false
+	}
+	if bound, discovered := d.nodes[node]; !discovered || bound != "" {
+		return false
+	}
+
+	d.nodes[node] = source
+	if d.peers == nil {
+		d.peers = make(map[string]*meshPeer)
+	}
+	peer, exists := d.peers[source]
+	if !exists {
+		peer = &meshPeer{}
+		d.peers[source] = peer
+	}
+	peer.node = node
+	log.Printf("Bound Docker node %s to mesh peer %s", node, source)
+	return true
+}
+
+// removePeerLocked forgets a peer, unbinding its node so the node's next
+// instance can announce itself. The caller must hold d.mutex.
+func (d *meshDirectory) removePeerLocked(address string) {
+	if peer, exists := d.peers[address]; exists && peer.node != "" {
+		if d.nodes[peer.node] == address {
+			d.nodes[peer.node] = ""
+		}
+	}
+	delete(d.peers, address)
+}
+
+// handleDiscoverNew records a node Docker discovered in its cluster.
+func (p *Plugin) handleDiscoverNew(w http.ResponseWriter, r *http.Request) {
+	p.handleDiscovery(w, r, "DiscoverNew", p.networkMgr.mesh.discoverNode)
+}
+
+// handleDiscoverDelete forgets a node that left Docker's cluster.
+func (p *Plugin) handleDiscoverDelete(w http.ResponseWriter, r *http.Request) {
+	p.handleDiscovery(w, r, "DiscoverDelete", p.networkMgr.mesh.forgetNode)
+}
+
+// handleDiscovery applies a discovery notification to the node table.
+// Notifications other than node discovery are acknowledged and ignored.
+func (p *Plugin) handleDiscovery(w http.ResponseWriter, r *http.Request, name string, apply func(address string, self bool)) {
+	log.Printf("Received NetworkDriver.%s request", name)
+
+	var req DiscoveryNotification
+	if err := p.readJSONRequest(r, &req); err != nil {
+		log.Printf("Error parsing %s request: %v", name, err)
+		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
+		return
+	}
+
+	address, self, err := parseNodeDiscovery(req)
+	if err != nil {
+		log.Printf("Error handling %s request: %v", name, err)
+		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
+		return
+	}
+	if address != "" {
+		apply(address, self)
+		log.Printf("%s: node %s (self: %t)", name, address, self)
+	}
+	p.writeJSONResponse(w, ErrorResponse{Err: ""})
+}
@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sendDiscovery calls a discovery handler with body and returns the error of
+// its response.
+func sendDiscovery(t *testing.T, handler http.HandlerFunc, body string) string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	var response ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return response.Err
+}
+
+func TestNodeDiscovery(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	directory := &p.networkMgr.mesh
+	peerA, peerB := meshTestAddress("a"), meshTestAddress("b")
+
+	for _, body := range []string{
+		`{"DiscoveryType":1,"DiscoveryData":{"Address":"10.0.0.1","BindAddress":"10.0.0.1","Self":true}}`,
+		`{"DiscoveryType":1,"DiscoveryData":{"Address":"10.0.0.2","Self":false}}`,
+		`{"DiscoveryType":2,"DiscoveryData":{"Primary":"key"}}`,
+	} {
+		if err := sendDiscovery(t, p.handleDiscoverNew, body); err != "" {
+			t.Fatalf("DiscoverNew %s failed: %s", body, err)
+		}
+	}
+	if err := sendDiscovery(t, p.handleDiscoverNew, `{"DiscoveryType":1,"DiscoveryData":{}}`); err == "" {
+		t.Error("Expected a node discovery without an address to fail")
+	}
+
+	if message := p.networkMgr.meshAnnouncement(); message.Node != "10.0.0.1" {
+		t.Errorf("Expected announcements to name the plugin's node, got %q", message.Node)
+	}
+
+	// The first instance announcing a discovered node becomes a peer
+	now := time.Now()
+	services := map[string][]meshEntry{"app": {{Name: "web", Address: meshTestAddress("w")}}}
+	if err := directory.update(peerA, meshMessage{Version: meshMessageVersion, Node: "10.0.0.3"}, now); err == nil {
+		t.Error("Expected announcements from undiscovered nodes to be rejected")
+	}
+	if err := directory.update(peerA, meshMessage{Version: meshMessageVersion, Node: "10.0.0.2", Networks: services}, now); err != nil {
+		t.Fatalf("update() unexpected error: %v", err)
+	}
+	if err := directory.update(peerB, meshMessage{Version: meshMessageVersion, Node: "10.0.0.2"}, now); err == nil {
+		t.Error("Expected a second instance claiming the node to be rejected")
+	}
+	if address, ok := directory.lookup("app", "web"); !ok || address != meshTestAddress("w") {
+		t.Errorf("Expected the services of the discovered peer, got %q", address)
+	}
+
+	// A node leaving the cluster takes its instance and services with it
+	if err := sendDiscovery(t, p.handleDiscoverDelete, `{"DiscoveryType":1,"DiscoveryData":{"Address":"10.0.0.2"}}`); err != "" {
+		t.Fatalf("DiscoverDelete failed: %s", err)
+	}
+	if _, ok := directory.lookup("app", "web"); ok {
+		t.Error("Expected the services of the departed node to be forgotten")
+	}
+	if peers := directory.addresses(); len(peers) != 0 {
+		t.Errorf("Expected no peers left, got %v", peers)
+	}
+	if err := directory.update(peerA, meshMessage{Version: meshMessageVersion, Node: "10.0.0.2"}, now); err == nil {
+		t.Error("Expected announcements for the departed node to be rejected")
+	}
+}
+
+func TestNodeDiscoveryStaticPeer(t *testing.T) {
+	var directory meshDirectory
+	peer := meshTestAddress("a")
+	directory.setStatic([]string{peer})
+	directory.discoverNode("10.0.0.2", false)
+
+	now := time.Now()
+	if err := directory.update(peer, meshMessage{Version: meshMessageVersion, Node: "10.0.0.2"}, now); err != nil {
+		t.Fatalf("update() unexpected error: %v", err)
+	}
+
+	// A configured peer outlives its node, and a quiet learned peer frees it
+	directory.forgetNode("10.0.0.2", false)
+	if peers := directory.addresses(); len(peers) != 1 {
+		t.Errorf("Expected the configured peer to be kept, got %v", peers)
+	}
+
+	directory.discoverNode("10.0.0.3", false)
+	learned := meshTestAddress("b")
+	if err := directory.update(learned, meshMessage{Version: meshMessageVersion, Node: "10.0.0.3"}, now); err != nil {
+		t.Fatalf("update() unexpected error: %v", err)
+	}
+	directory.expire(now.Add(meshPeerTimeout + time.Second))
+	if err := directory.update(meshTestAddress("c"), meshMessage{Version: meshMessageVersion, Node: "10.0.0.3"}, now); err != nil {
+		t.Errorf("Expected the node of an expired peer to be bound again, got %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+// Package plugin provides the DNS resolver address of I2P networks.
+//
+// Docker's remote driver protocol has no way for a network driver to set the
+// name servers of a container: libnetwork ignores resolver settings in Join
+// responses, and containers on user-defined networks keep Docker's embedded
+// resolver, which forwards names it does not know to the servers given with
+// docker run --dns. Every network's DNS resolver listens where its SOCKS
+// proxy does, so that address is reported as the network's DNS server for
+// containers to be started with --dns <address> (and --dns-search i2p for
+// short names).
+package plugin
+
+import "net"
+
+// dnsServer returns the address containers reach the network's DNS resolver
+// on, to be passed to docker run --dns: its proxy bind address, or the gateway
+// if the resolver listens on all interfaces.
+func (n *I2PNetwork) dnsServer() net.IP {
+	if n.ProxyBindIP == nil || n.ProxyBindIP.IsUnspecified() {
+		return n.Gateway
+	}
+	return n.ProxyBindIP
+}
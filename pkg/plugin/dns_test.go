@@ -0,0 +1,80 @@
+package plugin
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+	"github.com/miekg/dns"
+)
+
+// freePort returns a port that is free on the loopback address.
+func freePort(t *testing.T, network string) int {
+	t.Helper()
+
+	var addr net.Addr
+	if network == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to find a free UDP port: %v", err)
+		}
+		addr = conn.LocalAddr()
+		conn.Close()
+	} else {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to find a free TCP port: %v", err)
+		}
+		addr = listener.Addr()
+		listener.Close()
+	}
+	_, port, _ := net.SplitHostPort(addr.String())
+	number, _ := strconv.Atoi(port)
+	return number
+}
+
+func TestNetworkDNSServer(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+	config := nm.proxyMgr.GetConfig()
+	config.SOCKSPort = freePort(t, "tcp")
+	config.DNSPort = freePort(t, "udp")
+	t.Cleanup(func() { nm.Shutdown() })
+
+	err := nm.CreateNetwork("dns-net", map[string]interface{}{"i2p.proxy.bind": "any"},
+		[]IPAMData{{Pool: "172.31.9.0/24", Gateway: "172.31.9.1"}})
+	if err != nil {
+		t.Fatalf("CreateNetwork() unexpected error: %v", err)
+	}
+
+	// A resolver on all interfaces is reached through the gateway
+	network := nm.GetNetwork("dns-net")
+	if server := network.adminView().DNSServer; server != "172.31.9.1" {
+		t.Fatalf("Expected the gateway as DNS server, got %q", server)
+	}
+
+	// The resolver answers .i2p names on every address, the gateway included
+	query := new(dns.Msg)
+	query.SetQuestion("forum.i2p.", dns.TypeA)
+	client := &dns.Client{Timeout: 2 * time.Second}
+	reply, _, err := client.Exchange(query, net.JoinHostPort("127.0.0.1", strconv.Itoa(config.DNSPort)))
+	if err != nil {
+		t.Fatalf("Failed to query the network's DNS resolver: %v", err)
+	}
+	_, synthetic, _ := net.ParseCIDR(proxy.SyntheticIPRange)
+	if len(reply.Answer) != 1 {
+		t.Fatalf("Expected one answer for forum.i2p, got %v", reply.Answer)
+	}
+	if a, ok := reply.Answer[0].(*dns.A); !ok || !synthetic.Contains(a.A) {
+		t.Errorf("Expected a synthetic address for forum.i2p, got %v", reply.Answer[0])
+	}
+
+	// A resolver on a literal address is reached there
+	network.ProxyBindIP = net.ParseIP("10.9.0.1")
+	if server := network.adminView().DNSServer; server != "10.9.0.1" {
+		t.Errorf("Expected the proxy bind address as DNS server, got %q", server)
+	}
+}
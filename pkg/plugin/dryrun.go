@@ -0,0 +1,444 @@
+// Package plugin provides dry-run planning for networks and joins.
+//
+// This file implements the i2p.dryrun network option and the admin dry-run
+// endpoint. Both walk the same validation as CreateNetwork and Join, then
+// report the network, proxy listeners, iptables rules, exposures and tunnels
+// that would be created, without touching SAM, iptables or the proxy. Operators
+// can check an option set before committing to it.
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+const (
+	// DryRunOption is the network option that plans a network instead of creating it
+	DryRunOption = "i2p.dryrun"
+
+	// dryRunID stands in for the network, endpoint and container IDs a dry
+	// run does not have
+	dryRunID = "dry-run"
+)
+
+// errDryRunNetworkNotFound is returned when a dry run targets an unknown network.
+var errDryRunNetworkNotFound = errors.New("network not found")
+
+// DryRunRequest describes a network creation and container join to plan.
+//
+// Without Network, a new network is planned from Options, Subnet and Gateway.
+// With Network, the join is planned on that existing network. A join is
+// planned whenever ContainerID or Labels are given.
+type DryRunRequest struct {
+	// NetworkID is the ID of the planned network (default "dry-run")
+	NetworkID string `json:"network_id,omitempty"`
+	// Name is the name of the planned network
+	Name string `json:"name,omitempty"`
+	// Network plans the join on this existing network (ID or name) instead
+	Network string `json:"network,omitempty"`
+	// Options are the network options, as passed with docker network create -o
+	Options map[string]string `json:"options,omitempty"`
+	// Subnet is the network subnet in CIDR notation (default: allocated from the pool)
+	Subnet string `json:"subnet,omitempty"`
+	// Gateway is the network gateway address (default: first address of the subnet)
+	Gateway string `json:"gateway,omitempty"`
+	// ContainerID is the ID of the joining container (default "dry-run")
+	ContainerID string `json:"container_id,omitempty"`
+	// Labels are the labels of the joining container
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// DryRunPlan is what a network creation and container join would do.
+type DryRunPlan struct {
+	// Network is the planned network (omitted when joining an existing network)
+	Network *NetworkPlan `json:"network,omitempty"`
+	// Join is the planned container join (omitted when no container was given)
+	Join *JoinPlan `json:"join,omitempty"`
+}
+
+// NetworkPlan describes a network that would be created.
+type NetworkPlan struct {
+	// ID is the Docker network ID
+	ID string `json:"id"`
+	// Name is the human-readable network name
+	Name string `json:"name,omitempty"`
+	// Subnet is the network subnet in CIDR notation
+	Subnet string `json:"subnet"`
+	// Gateway is the network gateway address
+	Gateway string `json:"gateway"`
+	// AllocationStrategy is the IP allocation strategy
+	AllocationStrategy string `json:"allocation_strategy"`
+	// SOCKSListener is the address the network's SOCKS proxy would listen on
+	SOCKSListener string `json:"socks_listener"`
+	// DNSListener is the address the network's DNS resolver would listen on
+	DNSListener string `json:"dns_listener"`
+	// StartsProxy reports whether the network would start the proxy manager
+	StartsProxy bool `json:"starts_proxy"`
+	// Firewall is the backend the interception rules would be installed with
+	Firewall string `json:"firewall"`
+	// IptablesRules are the firewall commands that would run, in order. The
+	// interception rules are rendered by the firewall backend, so they are nft
+	// script lines with the nftables backend
+	IptablesRules []string `json:"iptables_rules"`
+	// AntiSpoof reports whether joined endpoints would get anti-spoofing rules
+	AntiSpoof bool `json:"anti_spoof"`
+	// Isolated reports whether direct traffic between containers would be blocked
+	Isolated bool `json:"isolated"`
+	// Internal reports whether traffic leaving the network would be dropped
+	// except to the SAM bridges
+	Internal bool `json:"internal"`
+	// MTU is the MTU the network's interfaces would get (0 keeps the kernel default)
+	MTU int `json:"mtu,omitempty"`
+	// DNSServer is the address containers would pass to docker run --dns
+	DNSServer string `json:"dns_server,omitempty"`
+	// MaxEndpoints is the endpoint limit the network would enforce (0 is unlimited)
+	MaxEndpoints int `json:"max_endpoints"`
+	// TunnelOptions are the tunnel option overrides the network's container sessions would get
+	TunnelOptions map[string]string `json:"tunnel_options,omitempty"`
+	// Policy is the policy template the network would be created with
+	Policy *NetworkPolicy `json:"policy,omitempty"`
+	// Tenant is the tenant the network's telemetry would be tagged with
+	Tenant string `json:"tenant,omitempty"`
+	// DefaultExposureType is the exposure type of ports without explicit configuration
+	DefaultExposureType string `json:"default_exposure_type"`
+	// AllowIPExposure reports whether IP exposures are permitted
+	AllowIPExposure bool `json:"allow_ip_exposure"`
+	// AllowedTargets restricts IP exposure targets (empty allows any)
+	AllowedTargets []string `json:"allowed_targets,omitempty"`
+	// FilterMode is allowlist, blocklist or disabled
+	FilterMode string `json:"filter_mode"`
+	// Allowlist are the destinations that would be added to the allowlist
+	Allowlist []string `json:"allowlist,omitempty"`
+	// Blocklist are the destinations that would be added to the blocklist
+	Blocklist []string `json:"blocklist,omitempty"`
+}
+
+// JoinPlan describes what a container join would set up.
+type JoinPlan struct {
+	// ContainerID is the joining container
+	ContainerID string `json:"container_id"`
+	// ClientOnly reports whether the container only makes outbound connections
+	ClientOnly bool `json:"client_only"`
+	// OutboundDisabled reports whether the container would be inbound-only,
+	// in which case no port maps or sidecar sockets are planned
+	OutboundDisabled bool `json:"outbound_disabled,omitempty"`
+	// Exposures are the service exposures that would be created
+	Exposures []PlannedExposure `json:"exposures"`
+	// PortMaps are the client port maps that would be created
+	PortMaps []PlannedPortMap `json:"port_maps"`
+	// Sidecar are the sidecar sockets that would be served (omitted if none)
+	Sidecar *PlannedSidecar `json:"sidecar,omitempty"`
+	// IsolationGroups are the groups whose members could reach the container
+	// directly (omitted unless the network is isolated)
+	IsolationGroups []string `json:"isolation_groups,omitempty"`
+}
+
+// PlannedExposure describes a service exposure that would be created.
+type PlannedExposure struct {
+	// ContainerPort is the port inside the container
+	ContainerPort int `json:"container_port"`
+	// Protocol is tcp or udp
+	Protocol string `json:"protocol"`
+	// Type is either "i2p" or "ip"
+	Type string `json:"type"`
+	// TunnelName is the server tunnel name (I2P exposure) or forwarder name (IP exposure)
+	TunnelName string `json:"tunnel_name"`
+	// Address is the .b32.i2p address of an I2P exposure, if its destination
+	// already exists (omitted when the join would generate a new one)
+	Address string `json:"address,omitempty"`
+	// Listen is the host IP:port of an IP exposure
+	Listen string `json:"listen,omitempty"`
+	// Preset is the i2p.preset the port came from, if any
+	Preset string `json:"preset,omitempty"`
+	// TTL is how long the exposure would live (omitted if it has none)
+	TTL string `json:"ttl,omitempty"`
+	// InboundOnly reports whether the server tunnel would be inbound-only
+	InboundOnly bool `json:"inbound_only,omitempty"`
+	// After lists the container ports the exposure would wait for
+	After []int `json:"after,omitempty"`
+	// SharedTunnel reports whether the port would be served by the shared server sub-session
+	SharedTunnel bool `json:"shared_tunnel,omitempty"`
+	// Group is the exposure group whose destination would serve the port
+	Group string `json:"group,omitempty"`
+}
+
+// PlannedPortMap describes a client port map that would be created.
+type PlannedPortMap struct {
+	// Listen is the gateway IP:port the listener would bind to
+	Listen string `json:"listen"`
+	// Destination is the remote I2P host:port
+	Destination string `json:"destination"`
+	// Fallbacks are the host:port destinations tried when the destination is unreachable
+	Fallbacks []string `json:"fallbacks,omitempty"`
+	// TunnelName is the client tunnel name
+	TunnelName string `json:"tunnel_name"`
+}
+
+// PlannedSidecar describes the sidecar sockets that would be served.
+type PlannedSidecar struct {
+	// Name is the sidecar name from the i2p.sidecar label
+	Name string `json:"name"`
+	// SOCKSPath is the SOCKS socket path (empty if disabled)
+	SOCKSPath string `json:"socks_path,omitempty"`
+	// DNSPath is the DNS API socket path (empty if disabled)
+	DNSPath string `json:"dns_path,omitempty"`
+}
+
+// parseDryRun reports whether network options request a dry run.
+//
+// Configuration options:
+//   - i2p.dryrun: true plans the network without creating it (default: false)
+func parseDryRun(options map[string]interface{}) (bool, error) {
+	return parseBoolOption(options, DryRunOption, false)
+}
+
+// dryRunNetworkLocked reports the plan of a network created with i2p.dryrun.
+//
+// Docker has no way to return data from CreateNetwork, so the plan is
+// returned as the error, which also keeps Docker from recording the network.
+// The caller must hold nm.mutex.
+func (nm *NetworkManager) dryRunNetworkLocked(setup *networkSetup) error {
+	plan, err := json.Marshal(nm.planNetworkLocked(setup))
+	if err != nil {
+		return fmt.Errorf("dry run of network %s failed: %w", setup.network.ID, err)
+	}
+
+	log.Printf("Dry run of network %s: %s", setup.network.ID, plan)
+	return fmt.Errorf("dry run, network %s not created; plan: %s", setup.network.ID, plan)
+}
+
+// planNetworkLocked describes what creating a prepared network would do.
+//
+// The caller must hold nm.mutex.
+func (nm *NetworkManager) planNetworkLocked(setup *networkSetup) *NetworkPlan {
+	network := setup.network
+	proxyConfig := nm.proxyMgr.GetConfig()
+	startsProxy := len(nm.networks) == 0 && !nm.proxyMgr.IsRunning()
+
+	plan := &NetworkPlan{
+		ID:                  network.ID,
+		Name:                network.Name,
+		Subnet:              network.Subnet.String(),
+		Gateway:             network.Gateway.String(),
+		AllocationStrategy:  setup.strategy.Name(),
+		SOCKSListener:       net.JoinHostPort(network.ProxyBindIP.String(), strconv.Itoa(proxyConfig.SOCKSPort)),
+		DNSListener:         net.JoinHostPort(network.ProxyBindIP.String(), strconv.Itoa(proxyConfig.DNSPort)),
+		StartsProxy:         startsProxy,
+		Firewall:            nm.proxyMgr.FirewallBackend(),
+		IptablesRules:       nm.proxyMgr.PlanNetworkRules(network.Subnet, network.ProxyBindIP, startsProxy),
+		AntiSpoof:           network.AntiSpoof,
+		Isolated:            network.Isolated,
+		Internal:            network.Internal,
+		MTU:                 network.MTU,
+		DNSServer:           network.dnsServer().String(),
+		MaxEndpoints:        network.MaxEndpoints,
+		TunnelOptions:       network.TunnelOverrides,
+		Policy:              network.Policy,
+		Tenant:              network.Tenant,
+		DefaultExposureType: string(network.ExposureConfig.DefaultExposureType),
+		AllowIPExposure:     network.ExposureConfig.AllowIPExposure,
+		AllowedTargets:      network.ExposureConfig.AllowedTargets,
+		FilterMode:          filterMode(setup.filterConfig),
+		Allowlist:           setup.allowlist,
+		Blocklist:           setup.blocklist,
+	}
+	if network.Internal {
+		plan.IptablesRules = append(plan.IptablesRules, nm.proxyMgr.PlanNetworkEgressRules(network.egressSubnets(), nm.samBridgeAddrsLocked())...)
+	}
+	if plan.IptablesRules == nil {
+		plan.IptablesRules = []string{}
+	}
+	return plan
+}
+
+// planJoinLocked describes what joining a container to network would do.
+//
+// The caller must hold nm.mutex.
+func (nm *NetworkManager) planJoinLocked(network *I2PNetwork, containerID string, options map[string]interface{}) (*JoinPlan, error) {
+	clientOnly := service.IsClientOnly(options)
+	outboundDisabled, err := isOutboundDisabled(options)
+	if err != nil {
+		return nil, err
+	}
+	plan := &JoinPlan{
+		ContainerID:      containerID,
+		ClientOnly:       clientOnly,
+		OutboundDisabled: outboundDisabled,
+		Exposures:        []PlannedExposure{},
+		PortMaps:         []PlannedPortMap{},
+	}
+
+	exposedPorts, err := nm.serviceMgr.DetectExposedPorts(containerID, options)
+	if err != nil {
+		return nil, err
+	}
+	for _, port := range applyExposurePolicy(network, containerID, clientOnly, exposedPorts) {
+		planned := PlannedExposure{
+			ContainerPort: port.ContainerPort,
+			Protocol:      port.Protocol,
+			Type:          string(port.ExposureType),
+			TunnelName:    service.ExposureTunnelName(containerID, port),
+			Preset:        port.Preset,
+			InboundOnly:   port.InboundOnly,
+			After:         port.After,
+			SharedTunnel:  port.SharedTunnel,
+			Group:         port.Group,
+		}
+		if planned.Protocol == "" {
+			planned.Protocol = "tcp"
+		}
+		if port.TTL > 0 {
+			planned.TTL = port.TTL.String()
+		}
+		if port.ExposureType == service.ExposureTypeIP {
+			planned.Listen = plannedIPListen(port)
+		} else if address, known := nm.serviceMgr.PreviewAddress(containerID, port); known {
+			planned.Address = address
+		}
+		plan.Exposures = append(plan.Exposures, planned)
+	}
+
+	// Labels are unordered, so sort for a stable plan
+	sort.Slice(plan.Exposures, func(i, j int) bool {
+		if plan.Exposures[i].ContainerPort != plan.Exposures[j].ContainerPort {
+			return plan.Exposures[i].ContainerPort < plan.Exposures[j].ContainerPort
+		}
+		return plan.Exposures[i].Type < plan.Exposures[j].Type
+	})
+
+	var portMaps []service.PortMap
+	if !outboundDisabled {
+		portMaps = nm.serviceMgr.DetectPortMaps(options)
+	}
+	sort.Slice(portMaps, func(i, j int) bool { return portMaps[i].ListenPort < portMaps[j].ListenPort })
+	for _, portMap := range portMaps {
+		planned := PlannedPortMap{
+			Listen:      net.JoinHostPort(network.Gateway.String(), strconv.Itoa(portMap.ListenPort)),
+			Destination: net.JoinHostPort(portMap.Destination, strconv.Itoa(portMap.DestinationPort)),
+			TunnelName:  service.PortMapTunnelName(containerID, portMap),
+		}
+		for _, fallback := range portMap.Fallbacks {
+			planned.Fallbacks = append(planned.Fallbacks, net.JoinHostPort(fallback.Destination, strconv.Itoa(fallback.Port)))
+		}
+		plan.PortMaps = append(plan.PortMaps, planned)
+	}
+
+	if network.Isolated {
+		plan.IsolationGroups = getIsolationGroups(options)
+	}
+
+	if name, ok := getSidecarName(options); ok && !outboundDisabled {
+		socksPath, dnsPath, err := renderSidecarPaths(network, dryRunID, containerID, name)
+		if err != nil {
+			return nil, err
+		}
+		if socksPath != "" || dnsPath != "" {
+			plan.Sidecar = &PlannedSidecar{Name: name, SOCKSPath: socksPath, DNSPath: dnsPath}
+		}
+	}
+
+	return plan, nil
+}
+
+// plannedIPListen returns the host address an IP exposure would listen on.
+func plannedIPListen(port service.ExposedPort) string {
+	targetIP := port.TargetIP
+	if targetIP == "" {
+		targetIP = "127.0.0.1"
+	}
+	hostPort := port.HostPort
+	if hostPort == 0 {
+		hostPort = port.ContainerPort
+	}
+	return net.JoinHostPort(targetIP, strconv.Itoa(hostPort))
+}
+
+// DryRun plans a network creation and container join without performing them.
+func (nm *NetworkManager) DryRun(req DryRunRequest) (*DryRunPlan, error) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	plan := &DryRunPlan{}
+
+	var network *I2PNetwork
+	if req.Network != "" {
+		network = nm.lookupNetworkLocked(req.Network)
+		if network == nil {
+			return nil, fmt.Errorf("%w: %s", errDryRunNetworkNotFound, req.Network)
+		}
+	} else {
+		networkID := req.NetworkID
+		if networkID == "" {
+			networkID = dryRunID
+		}
+
+		options := make(map[string]interface{}, len(req.Options)+1)
+		for key, value := range req.Options {
+			options[key] = value
+		}
+		if req.Name != "" {
+			options["com.docker.network.generic"] = map[string]interface{}{"name": req.Name}
+		}
+
+		var ipamData []IPAMData
+		if req.Subnet != "" {
+			ipamData = []IPAMData{{Pool: req.Subnet, Gateway: req.Gateway}}
+		}
+
+		setup, err := nm.prepareNetworkLocked(networkID, options, ipamData)
+		if err != nil {
+			return nil, err
+		}
+		plan.Network = nm.planNetworkLocked(setup)
+		network = setup.network
+	}
+
+	if req.ContainerID != "" || len(req.Labels) > 0 {
+		containerID := req.ContainerID
+		if containerID == "" {
+			containerID = dryRunID
+		}
+
+		labels := make(map[string]interface{}, len(req.Labels))
+		for key, value := range req.Labels {
+			labels[key] = value
+		}
+
+		join, err := nm.planJoinLocked(network, containerID, map[string]interface{}{"Labels": labels})
+		if err != nil {
+			return nil, err
+		}
+		plan.Join = join
+	}
+
+	return plan, nil
+}
+
+// handleAdminDryRun plans a network creation and container join.
+func (p *Plugin) handleAdminDryRun(w http.ResponseWriter, r *http.Request) {
+	var req DryRunRequest
+	if err := p.readJSONRequest(r, &req); err != nil {
+		p.writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	plan, err := p.networkMgr.DryRun(req)
+	if errors.Is(err, errDryRunNetworkNotFound) {
+		p.writeAdminError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		p.writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	p.writeJSONResponse(w, plan)
+}
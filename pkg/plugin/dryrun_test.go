@@ -0,0 +1,195 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// adminDryRun posts a dry-run request and decodes the plan of a 200 response.
+func adminDryRun(t *testing.T, mux *http.ServeMux, req DryRunRequest) (*httptest.ResponseRecorder, DryRunPlan) {
+	t.Helper()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to encode request: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/"+AdminAPIVersion+"/dry-run", strings.NewReader(string(data))))
+
+	var plan DryRunPlan
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &plan); err != nil {
+			t.Fatalf("Failed to decode plan: %v (body %q)", err, w.Body.String())
+		}
+	}
+	return w, plan
+}
+
+func TestParseDryRun(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     map[string]interface{}
+		expected    bool
+		expectError bool
+	}{
+		{name: "absent", options: map[string]interface{}{}, expected: false},
+		{name: "true", options: map[string]interface{}{DryRunOption: "true"}, expected: true},
+		{name: "false", options: map[string]interface{}{DryRunOption: "false"}, expected: false},
+		{name: "bool", options: map[string]interface{}{DryRunOption: true}, expected: true},
+		{name: "invalid", options: map[string]interface{}{DryRunOption: "maybe"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dryRun, err := parseDryRun(tt.options)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if dryRun != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, dryRun)
+			}
+		})
+	}
+}
+
+func TestCreateNetworkDryRun(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	nm := p.networkMgr
+
+	err := nm.CreateNetwork("net2", map[string]interface{}{
+		DryRunOption:     "true",
+		"i2p.proxy.bind": "any",
+	}, []IPAMData{{Pool: "172.30.0.0/24"}})
+	if err == nil {
+		t.Fatal("Expected dry run to report its plan as an error")
+	}
+	if !strings.Contains(err.Error(), "dry run") || !strings.Contains(err.Error(), "-s 172.30.0.0/24 -j ACCEPT") {
+		t.Errorf("Expected plan with the proxy input rule, got %v", err)
+	}
+	if nm.GetNetwork("net2") != nil {
+		t.Error("Expected dry run not to create the network")
+	}
+
+	// Anti-spoofing can be switched off, but only with a boolean
+	err = nm.CreateNetwork("net2", map[string]interface{}{
+		DryRunOption:    "true",
+		AntiSpoofOption: "false",
+	}, []IPAMData{{Pool: "172.30.0.0/24"}})
+	if err == nil || !strings.Contains(err.Error(), `"anti_spoof":false`) {
+		t.Errorf("Expected plan without anti-spoofing, got %v", err)
+	}
+	err = nm.CreateNetwork("net2", map[string]interface{}{
+		DryRunOption:    "true",
+		AntiSpoofOption: "sometimes",
+	}, []IPAMData{{Pool: "172.30.0.0/24"}})
+	if err == nil || strings.Contains(err.Error(), "plan:") {
+		t.Errorf("Expected invalid %s to be rejected, got %v", AntiSpoofOption, err)
+	}
+
+	// Options are still validated
+	err = nm.CreateNetwork("net2", map[string]interface{}{
+		DryRunOption:     "true",
+		"i2p.proxy.bind": "nowhere",
+	}, []IPAMData{{Pool: "172.30.0.0/24"}})
+	if err == nil || strings.Contains(err.Error(), "plan:") {
+		t.Errorf("Expected invalid option to be rejected, got %v", err)
+	}
+}
+
+func TestAdminDryRun(t *testing.T) {
+	_, mux := newAdminTestPlugin(t)
+
+	w, plan := adminDryRun(t, mux, DryRunRequest{
+		NetworkID: "net2",
+		Name:      "planned",
+		Options:   map[string]string{"i2p.filter.mode": "allowlist", "i2p.filter.allowlist": "example.i2p"},
+		Subnet:    "172.30.0.0/24",
+		Labels: map[string]string{
+			"i2p.expose.80":        "i2p",
+			"i2p.expose.443":       "i2p",
+			"i2p.expose.443.after": "80",
+			"i2p.portmap.6667":     "irc.postman.i2p:6667,irc.echelon.i2p",
+			SidecarLabel:           "web",
+		},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if plan.Network == nil {
+		t.Fatal("Expected a network plan")
+	}
+	if plan.Network.Subnet != "172.30.0.0/24" || plan.Network.Gateway != "172.30.0.1" || plan.Network.Name != "planned" {
+		t.Errorf("Unexpected network plan: %+v", plan.Network)
+	}
+	if plan.Network.SOCKSListener != "172.30.0.1:1080" {
+		t.Errorf("Expected SOCKS listener on the gateway, got %s", plan.Network.SOCKSListener)
+	}
+	if plan.Network.FilterMode != "allowlist" || len(plan.Network.Allowlist) != 1 {
+		t.Errorf("Expected allowlist filter with one entry, got %s %v", plan.Network.FilterMode, plan.Network.Allowlist)
+	}
+	if !plan.Network.AntiSpoof {
+		t.Error("Expected anti-spoofing to be enabled by default")
+	}
+
+	if plan.Join == nil {
+		t.Fatal("Expected a join plan")
+	}
+	if len(plan.Join.Exposures) != 2 {
+		t.Fatalf("Expected 2 exposures, got %+v", plan.Join.Exposures)
+	}
+	if exposure := plan.Join.Exposures[1]; exposure.ContainerPort != 443 || exposure.TunnelName == "" || len(exposure.After) != 1 {
+		t.Errorf("Unexpected planned exposure: %+v", exposure)
+	}
+	if len(plan.Join.PortMaps) != 1 || plan.Join.PortMaps[0].Listen != "172.30.0.1:6667" ||
+		len(plan.Join.PortMaps[0].Fallbacks) != 1 || plan.Join.PortMaps[0].Fallbacks[0] != "irc.echelon.i2p:6667" {
+		t.Errorf("Unexpected planned port maps: %+v", plan.Join.PortMaps)
+	}
+	if plan.Join.Sidecar == nil || plan.Join.Sidecar.SOCKSPath != "/run/i2p-sidecar/web/socks.sock" {
+		t.Errorf("Unexpected planned sidecar: %+v", plan.Join.Sidecar)
+	}
+
+	if network := adminGet(t, mux, "/"+AdminAPIVersion+"/networks/net2", nil); network.Code != http.StatusNotFound {
+		t.Errorf("Expected dry run not to create the network, got %d", network.Code)
+	}
+}
+
+func TestAdminDryRunExistingNetwork(t *testing.T) {
+	_, mux := newAdminTestPlugin(t)
+
+	w, plan := adminDryRun(t, mux, DryRunRequest{
+		Network:     "i2p-test",
+		ContainerID: "container2",
+		Labels:      map[string]string{"i2p.expose.8080": "ip:127.0.0.1"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if plan.Network != nil {
+		t.Errorf("Expected no network plan for an existing network, got %+v", plan.Network)
+	}
+	if plan.Join == nil || len(plan.Join.Exposures) != 1 {
+		t.Fatalf("Expected one planned exposure, got %+v", plan.Join)
+	}
+	// The test network does not allow IP exposure, so it is downgraded
+	if exposure := plan.Join.Exposures[0]; exposure.Type != "i2p" || exposure.Listen != "" {
+		t.Errorf("Expected IP exposure to be downgraded to I2P, got %+v", exposure)
+	}
+
+	if w, _ := adminDryRun(t, mux, DryRunRequest{Network: "missing", ContainerID: "container2"}); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown network, got %d", w.Code)
+	}
+	if w, _ := adminDryRun(t, mux, DryRunRequest{Subnet: "172.20.1.0/24"}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an overlapping subnet, got %d", w.Code)
+	}
+}
@@ -0,0 +1,115 @@
+// Package plugin provides per-network endpoint count limits.
+//
+// A runaway CI job creating containers in a loop can fill a network's /24
+// pool and open a tunnel session per container on the router long before the
+// pool runs dry. Networks created with i2p.endpoints.max refuse endpoints
+// beyond the limit with ErrEndpointLimit, and the current and maximum counts
+// are exported as gauges so the limit can be alerted on before it is hit.
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/metrics"
+)
+
+// MaxEndpointsOption is the network option limiting the number of endpoints
+// on the network.
+const MaxEndpointsOption = "i2p.endpoints.max"
+
+// ErrEndpointLimit is returned by CreateEndpoint when the network already has
+// its maximum number of endpoints.
+var ErrEndpointLimit = errors.New("network endpoint limit reached")
+
+// parseMaxEndpoints returns the endpoint limit selected by network options;
+// zero means unlimited.
+func parseMaxEndpoints(options map[string]interface{}) (int, error) {
+	var limit int
+	switch value := options[MaxEndpointsOption].(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		limit = int(value)
+		if float64(limit) != value {
+			return 0, fmt.Errorf("invalid %s value %v: expected a whole number", MaxEndpointsOption, value)
+		}
+	case int:
+		limit = value
+	case string:
+		parsed, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s value %q: expected a whole number", MaxEndpointsOption, value)
+		}
+		limit = parsed
+	default:
+		return 0, fmt.Errorf("invalid %s value %v: expected a whole number", MaxEndpointsOption, value)
+	}
+	if limit < 0 {
+		return 0, fmt.Errorf("invalid %s value %d: must not be negative", MaxEndpointsOption, limit)
+	}
+	return limit, nil
+}
+
+// checkEndpointLimitLocked returns ErrEndpointLimit if the network cannot take
+// another endpoint, counting the rejection. The caller must hold the
+// network's mutex.
+func (nm *NetworkManager) checkEndpointLimitLocked(network *I2PNetwork) error {
+	if network.MaxEndpoints == 0 || len(network.Endpoints) < network.MaxEndpoints {
+		return nil
+	}
+	network.EndpointLimitRejections++
+	return fmt.Errorf("%w: network %s has %d of %d endpoints (see the %s option)",
+		ErrEndpointLimit, network.ID, len(network.Endpoints), network.MaxEndpoints, MaxEndpointsOption)
+}
+
+// endpointCounts describes the endpoint count of a network for metrics.
+type endpointCounts struct {
+	current    int
+	max        int
+	rejections uint64
+}
+
+// endpointStats returns the endpoint counts of every network.
+func (nm *NetworkManager) endpointStats() map[string]endpointCounts {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	stats := make(map[string]endpointCounts, len(nm.networks))
+	for networkID, network := range nm.networks {
+		network.mutex.RLock()
+		stats[networkID] = endpointCounts{
+			current:    len(network.Endpoints),
+			max:        network.MaxEndpoints,
+			rejections: network.EndpointLimitRejections,
+		}
+		network.mutex.RUnlock()
+	}
+	return stats
+}
+
+// endpointSamples returns a collect function reporting one value per network.
+// Networks for which value reports false are left out.
+func (p *Plugin) endpointSamples(value func(endpointCounts) (float64, bool)) func() []metrics.Sample {
+	return func() []metrics.Sample {
+		stats := p.networkMgr.endpointStats()
+
+		networkIDs := make([]string, 0, len(stats))
+		for networkID := range stats {
+			networkIDs = append(networkIDs, networkID)
+		}
+		sort.Strings(networkIDs)
+
+		samples := make([]metrics.Sample, 0, len(stats))
+		for _, networkID := range networkIDs {
+			if v, ok := value(stats[networkID]); ok {
+				tag := p.networkMgr.networkTag(networkID)
+				samples = append(samples, metrics.Sample{LabelValues: []string{networkID, tag.Name, tag.Tenant}, Value: v})
+			}
+		}
+		return samples
+	}
+}
@@ -0,0 +1,113 @@
+package plugin
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseMaxEndpoints(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		want    int
+		wantErr bool
+	}{
+		{value: nil, want: 0},
+		{value: "50", want: 50},
+		{value: " 3 ", want: 3},
+		{value: float64(10), want: 10},
+		{value: "0", want: 0},
+		{value: "-1", wantErr: true},
+		{value: "many", wantErr: true},
+		{value: 2.5, wantErr: true},
+		{value: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		options := map[string]interface{}{}
+		if tt.value != nil {
+			options[MaxEndpointsOption] = tt.value
+		}
+		got, err := parseMaxEndpoints(options)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseMaxEndpoints(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseMaxEndpoints(%v) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCreateEndpointLimit(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	_, subnet, _ := net.ParseCIDR("10.31.0.0/24")
+	gateway := net.ParseIP("10.31.0.1")
+	allocator := NewIPAllocator(subnet, gateway)
+	nm.addNetworkLocked(&I2PNetwork{
+		ID:            "net1",
+		Subnet:        subnet,
+		Gateway:       gateway,
+		Endpoints:     map[string]*I2PEndpoint{},
+		IPAllocator:   allocator,
+		TunnelManager: nm.tunnelMgr,
+		MaxEndpoints:  2,
+	})
+
+	for _, endpointID := range []string{"ep1", "ep2"} {
+		if _, err := nm.CreateEndpoint("net1", endpointID, nil); err != nil {
+			t.Fatalf("Failed to create endpoint %s: %v", endpointID, err)
+		}
+	}
+
+	_, err := nm.CreateEndpoint("net1", "ep3", nil)
+	if !errors.Is(err, ErrEndpointLimit) {
+		t.Fatalf("Expected ErrEndpointLimit, got %v", err)
+	}
+	if stats := allocator.Stats(); stats.Allocated != 3 || stats.Exhaustions != 0 {
+		t.Errorf("Expected refused endpoint to leave the pool alone, got %+v", stats)
+	}
+	if counts := nm.endpointStats()["net1"]; counts.current != 2 || counts.max != 2 || counts.rejections != 1 {
+		t.Errorf("Unexpected endpoint counts %+v", counts)
+	}
+
+	if err := nm.DeleteEndpoint("net1", "ep1"); err != nil {
+		t.Fatalf("Failed to delete endpoint: %v", err)
+	}
+	if _, err := nm.CreateEndpoint("net1", "ep3", nil); err != nil {
+		t.Errorf("Expected endpoint creation to succeed below the limit: %v", err)
+	}
+}
+
+func TestEndpointMetrics(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `i2p_network_endpoints{network="net1",name="i2p-test",tenant=""} 1`) {
+		t.Errorf("Expected endpoint count in metrics, got:\n%s", rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), `i2p_network_endpoints_max{`) {
+		t.Errorf("Expected no limit sample for an unlimited network, got:\n%s", rec.Body.String())
+	}
+
+	network := p.networkMgr.GetNetwork("net1")
+	network.MaxEndpoints = 1
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, expected := range []string{
+		`i2p_network_endpoints_max{network="net1",name="i2p-test",tenant=""} 1`,
+		`i2p_network_endpoint_limit_rejected_total{network="net1",name="i2p-test",tenant=""} 0`,
+	} {
+		if !strings.Contains(rec.Body.String(), expected) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", expected, rec.Body.String())
+		}
+	}
+}
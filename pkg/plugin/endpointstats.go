@@ -0,0 +1,79 @@
+// Package plugin provides per-endpoint traffic statistics.
+//
+// An endpoint's traffic is the proxy connections of its container, counted by
+// the SOCKS proxy under the endpoint's ID, and the inbound I2P streams the
+// plugin forwarded to the container's exposures. The totals are returned in
+// EndpointOperInfo, so they show up next to the endpoint's service addresses,
+// and with every endpoint in the admin API's network views.
+package plugin
+
+// endpointStatsKey is the EndpointOperInfo key the endpoint statistics are
+// returned under.
+const endpointStatsKey = "com.i2p.endpoint.stats"
+
+// EndpointStats counts the traffic of an endpoint since it was created.
+type EndpointStats struct {
+	// ProxyConnections counts completed connections through the SOCKS proxy
+	ProxyConnections int64 `json:"proxy_connections"`
+	// ProxyBytes is the data volume of completed proxy connections
+	ProxyBytes int64 `json:"proxy_bytes"`
+	// ServerTunnelStreams counts completed inbound streams forwarded to the
+	// container's exposures
+	ServerTunnelStreams int64 `json:"server_tunnel_streams"`
+	// ServerTunnelBytes is the data volume of inbound streams, including
+	// streams still open
+	ServerTunnelBytes int64 `json:"server_tunnel_bytes"`
+	// ActiveStreams counts the proxy connections and inbound streams
+	// currently open
+	ActiveStreams int64 `json:"active_streams"`
+}
+
+// EndpointStats returns the traffic statistics of an endpoint, or false if
+// the endpoint does not exist.
+func (nm *NetworkManager) EndpointStats(networkID, endpointID string) (EndpointStats, bool) {
+	network := nm.GetNetwork(networkID)
+	if network == nil {
+		return EndpointStats{}, false
+	}
+
+	network.mutex.RLock()
+	defer network.mutex.RUnlock()
+
+	endpoint, exists := network.Endpoints[endpointID]
+	if !exists {
+		return EndpointStats{}, false
+	}
+	return nm.endpointStatsLocked(endpoint), true
+}
+
+// addEndpointStats fills in the statistics of the endpoints of a network view.
+func (nm *NetworkManager) addEndpointStats(network *I2PNetwork, endpoints []AdminEndpoint) {
+	network.mutex.RLock()
+	defer network.mutex.RUnlock()
+
+	for i := range endpoints {
+		if endpoint, exists := network.Endpoints[endpoints[i].ID]; exists {
+			stats := nm.endpointStatsLocked(endpoint)
+			endpoints[i].Stats = &stats
+		}
+	}
+}
+
+// endpointStatsLocked aggregates the counters of an endpoint. The caller
+// must hold the network's mutex.
+func (nm *NetworkManager) endpointStatsLocked(endpoint *I2PEndpoint) EndpointStats {
+	traffic := nm.proxyMgr.GetEndpointTraffic(endpoint.ID)
+	stats := EndpointStats{
+		ProxyConnections: traffic.Connections,
+		ProxyBytes:       traffic.BytesTransferred,
+		ActiveStreams:    traffic.ActiveConnections,
+	}
+
+	for _, exposure := range endpoint.ServiceExposures {
+		inbound := exposure.StreamStats()
+		stats.ServerTunnelStreams += inbound.Streams
+		stats.ServerTunnelBytes += inbound.BytesTransferred
+		stats.ActiveStreams += inbound.ActiveStreams
+	}
+	return stats
+}
@@ -0,0 +1,40 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEndpointStats(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+
+	if _, exists := p.networkMgr.EndpointStats("net1", "missing"); exists {
+		t.Error("Expected no statistics for an unknown endpoint")
+	}
+	if stats, exists := p.networkMgr.EndpointStats("net1", "ep1"); !exists || stats != (EndpointStats{}) {
+		t.Errorf("Expected empty statistics for ep1, got %+v, %v", stats, exists)
+	}
+
+	var network AdminNetwork
+	adminGet(t, mux, "/v1/networks/net1", &network)
+	if len(network.Endpoints) != 1 || network.Endpoints[0].Stats == nil {
+		t.Fatalf("Expected the endpoint view to carry statistics, got %+v", network.Endpoints)
+	}
+
+	w := httptest.NewRecorder()
+	p.handleEndpointInfo(w, httptest.NewRequest("POST", "/", strings.NewReader(`{"NetworkID": "net1", "EndpointID": "ep1"}`)))
+
+	var response struct {
+		Value map[string]json.RawMessage
+		Err   string
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode EndpointOperInfo response: %v", err)
+	}
+	var stats EndpointStats
+	if err := json.Unmarshal(response.Value[endpointStatsKey], &stats); err != nil || response.Err != "" {
+		t.Errorf("Expected %s in EndpointOperInfo, got %s (%v)", endpointStatsKey, w.Body.String(), err)
+	}
+}
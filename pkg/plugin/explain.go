@@ -0,0 +1,186 @@
+// Package plugin provides explanations of traffic filter decisions.
+//
+// GET /v1/filters/explain answers why a container can or cannot reach a
+// destination. The proxy walks the destination through the layers a SOCKS
+// connection passes, and the plugin attributes the deciding rule to where it
+// is configured: the container (i2p.outbound=false), the options of a network
+// (i2p.filter.mode, i2p.filter.allowlist, i2p.filter.blocklist and external
+// services), or the global filter, whose entries are added through the admin
+// API. All networks share one traffic filter, so a list entry from another
+// network's options applies to every container; the explanation names that
+// network.
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+// ExplainFilter reports how the proxy would decide a connection from a
+// container, by full or short ID, to destination. networkRef (ID or name) is
+// required only when the container is joined to several I2P networks.
+func (nm *NetworkManager) ExplainFilter(container, networkRef, destination string) (*proxy.FilterExplanation, error) {
+	if strings.TrimSpace(destination) == "" {
+		return nil, fmt.Errorf("destination cannot be empty")
+	}
+
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	containerID, err := nm.resolveJoinedContainerLocked(container)
+	if err != nil {
+		return nil, err
+	}
+	network, endpoint, err := nm.containerEndpointLocked(containerID, networkRef)
+	if err != nil {
+		return nil, err
+	}
+
+	network.mutex.RLock()
+	source := proxy.ClientSource{
+		NetworkID:        network.ID,
+		EndpointID:       endpoint.ID,
+		ContainerID:      endpoint.ContainerID,
+		OutboundDisabled: endpoint.OutboundDisabled,
+	}
+	network.mutex.RUnlock()
+
+	explanation := nm.proxyMgr.ExplainConnection(source, destination)
+	nm.attributeDecisionLocked(&explanation, network)
+	return &explanation, nil
+}
+
+// resolveJoinedContainerLocked expands a short container ID or a container
+// alias to the full ID of a joined container. The caller must hold nm.mutex
+// but no network's mutex.
+func (nm *NetworkManager) resolveJoinedContainerLocked(container string) (string, error) {
+	if container == "" {
+		return "", fmt.Errorf("container ID cannot be empty")
+	}
+
+	matches := make(map[string]bool)
+	for _, network := range nm.networks {
+		network.mutex.RLock()
+		for _, endpoint := range network.Endpoints {
+			if endpoint.State != EndpointJoined {
+				continue
+			}
+			if endpoint.ContainerID == container {
+				network.mutex.RUnlock()
+				return container, nil
+			}
+			if strings.HasPrefix(endpoint.ContainerID, container) || endpoint.Alias == container {
+				matches[endpoint.ContainerID] = true
+			}
+		}
+		network.mutex.RUnlock()
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("container %s is not joined to an I2P network", container)
+	case 1:
+		return sortedKeys(matches)[0], nil
+	default:
+		return "", fmt.Errorf("container ID %s is ambiguous", container)
+	}
+}
+
+// attributeDecisionLocked attributes the deciding filter rule of an
+// explanation to the network options that configure it. Rules no network
+// configures stay global. The caller must hold nm.mutex.
+func (nm *NetworkManager) attributeDecisionLocked(explanation *proxy.FilterExplanation, network *I2PNetwork) {
+	step := explanation.Decision()
+	if step == nil || step.Scope != proxy.FilterScopeGlobal {
+		return
+	}
+
+	var configuring []string
+	switch step.Rule {
+	case proxy.FilterRuleAllowlist, proxy.FilterRuleBlocklist:
+		configuring = nm.networksListingLocked(network, step.Rule, step.Pattern)
+		if len(configuring) == 0 {
+			explanation.SetDecisionOrigin(proxy.FilterScopeGlobal, "added through the admin API")
+			return
+		}
+		explanation.SetDecisionOrigin(proxy.FilterScopeNetwork, "options of network "+strings.Join(configuring, ", "))
+	case proxy.FilterRuleNotAllowlisted, proxy.FilterRuleDefault:
+		config := nm.proxyMgr.GetFilterConfig()
+		mode := filterMode(&config)
+		configuring = nm.networksSettingModeLocked(network, mode)
+		if len(configuring) == 0 {
+			explanation.SetDecisionOrigin(proxy.FilterScopeGlobal, "default "+mode+" mode")
+			return
+		}
+		explanation.SetDecisionOrigin(proxy.FilterScopeNetwork, fmt.Sprintf("%s=%s of network %s", FilterModeOption, mode, strings.Join(configuring, ", ")))
+	}
+}
+
+// networksListingLocked returns the names of the networks whose options put
+// pattern on a filter list, the container's network first. The caller must
+// hold nm.mutex.
+func (nm *NetworkManager) networksListingLocked(own *I2PNetwork, list, pattern string) []string {
+	return nm.networksMatchingLocked(own, func(network *I2PNetwork) bool {
+		allowlist, blocklist := parseFilterDestinations(network.Options)
+		entries := allowlist
+		if list == proxy.FilterRuleBlocklist {
+			entries = blocklist
+		}
+		return lowerSet(entries)[strings.ToLower(pattern)]
+	})
+}
+
+// networksSettingModeLocked returns the names of the networks whose options
+// set the filter mode to mode, the container's network first. The caller
+// must hold nm.mutex.
+func (nm *NetworkManager) networksSettingModeLocked(own *I2PNetwork, mode string) []string {
+	return nm.networksMatchingLocked(own, func(network *I2PNetwork) bool {
+		return normalizedOption(network.Options[FilterModeOption]) == mode
+	})
+}
+
+// networksMatchingLocked returns the names of the networks matching a
+// predicate, own first and the others sorted. The caller must hold nm.mutex.
+func (nm *NetworkManager) networksMatchingLocked(own *I2PNetwork, match func(*I2PNetwork) bool) []string {
+	var names []string
+	for _, network := range nm.networks {
+		if network != own && match(network) {
+			names = append(names, explainNetworkName(network))
+		}
+	}
+	sort.Strings(names)
+	if match(own) {
+		names = append([]string{explainNetworkName(own)}, names...)
+	}
+	return names
+}
+
+// explainNetworkName returns the name of a network, or its ID if unnamed.
+func explainNetworkName(network *I2PNetwork) string {
+	if network.Name != "" {
+		return network.Name
+	}
+	return network.ID
+}
+
+// handleAdminExplainFilter explains the filter decision for a container's
+// connection.
+func (p *Plugin) handleAdminExplainFilter(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	container, destination := query.Get("container"), query.Get("destination")
+	if container == "" || destination == "" {
+		p.writeAdminError(w, http.StatusBadRequest, "container and destination are required")
+		return
+	}
+
+	explanation, err := p.networkMgr.ExplainFilter(container, query.Get("network"), destination)
+	if err != nil {
+		p.writeAdminError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	p.writeJSONResponse(w, explanation)
+}
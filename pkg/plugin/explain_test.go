@@ -0,0 +1,83 @@
+package plugin
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+func TestExplainFilter(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+	nm := p.networkMgr
+
+	nm.networks["net1"].Options = map[string]interface{}{FilterBlocklistOption: "evil.i2p"}
+	nm.addNetworkLocked(&I2PNetwork{ID: "net2", Name: "i2p-other", Options: map[string]interface{}{FilterBlocklistOption: "spam.i2p"}, Endpoints: map[string]*I2PEndpoint{}})
+	for _, destination := range []string{"evil.i2p", "spam.i2p", "*.tracker.i2p"} {
+		if err := nm.proxyMgr.AddToBlocklist(destination); err != nil {
+			t.Fatalf("AddToBlocklist(%s) unexpected error: %v", destination, err)
+		}
+	}
+
+	tests := []struct {
+		destination string
+		allowed     bool
+		scope       string
+		rule        string
+		origin      string
+	}{
+		{destination: "evil.i2p:80", scope: proxy.FilterScopeNetwork, rule: proxy.FilterRuleBlocklist, origin: "options of network i2p-test"},
+		{destination: "spam.i2p", scope: proxy.FilterScopeNetwork, rule: proxy.FilterRuleBlocklist, origin: "options of network i2p-other"},
+		{destination: "www.tracker.i2p", scope: proxy.FilterScopeGlobal, rule: proxy.FilterRuleBlocklist, origin: "added through the admin API"},
+		{destination: "forum.i2p", allowed: true, scope: proxy.FilterScopeGlobal, rule: proxy.FilterRuleDefault, origin: "default blocklist mode"},
+	}
+	for _, tt := range tests {
+		explanation, err := nm.ExplainFilter("contain", "", tt.destination)
+		if err != nil {
+			t.Fatalf("ExplainFilter(%s) unexpected error: %v", tt.destination, err)
+		}
+		if explanation.Allowed != tt.allowed || explanation.Scope != tt.scope || explanation.Rule != tt.rule || explanation.Decision().Origin != tt.origin {
+			t.Errorf("%s: expected allowed=%v by %s rule %s from %q, got %+v", tt.destination, tt.allowed, tt.scope, tt.rule, tt.origin, explanation)
+		}
+	}
+
+	// A filter mode set by network options is attributed to the network
+	nm.networks["net1"].Options[FilterModeOption] = "allowlist"
+	nm.proxyMgr.UpdateFilterConfig(parseFilterConfig(nm.networks["net1"].Options))
+	explanation, err := nm.ExplainFilter("container1", "i2p-test", "forum.i2p")
+	if err != nil {
+		t.Fatalf("ExplainFilter() unexpected error: %v", err)
+	}
+	if explanation.Allowed || explanation.Rule != proxy.FilterRuleNotAllowlisted || explanation.Scope != proxy.FilterScopeNetwork ||
+		!strings.Contains(explanation.Decision().Origin, "i2p.filter.mode=allowlist of network i2p-test") {
+		t.Errorf("Expected the network's allowlist mode to block, got %+v", explanation)
+	}
+
+	nm.networks["net1"].Endpoints["ep1"].OutboundDisabled = true
+	explanation, _ = nm.ExplainFilter("container1", "", "forum.i2p")
+	if explanation.Allowed || explanation.Scope != proxy.FilterScopeContainer || explanation.Rule != proxy.FilterRuleOutboundDisabled {
+		t.Errorf("Expected the container's outbound label to block, got %+v", explanation)
+	}
+
+	if _, err := nm.ExplainFilter("container2", "", "forum.i2p"); err == nil {
+		t.Error("Expected an error for a container that is not joined")
+	}
+	if _, err := nm.ExplainFilter("container1", "missing", "forum.i2p"); err == nil {
+		t.Error("Expected an error for an unknown network")
+	}
+
+	var admin proxy.FilterExplanation
+	if w := adminGet(t, mux, "/v1/filters/explain?container=container1&destination=forum.i2p", &admin); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if admin.Rule != proxy.FilterRuleOutboundDisabled || len(admin.Steps) != 1 {
+		t.Errorf("Unexpected explanation: %+v", admin)
+	}
+	if w := adminGet(t, mux, "/v1/filters/explain?container=container1", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without a destination, got %d", w.Code)
+	}
+	if w := adminGet(t, mux, "/v1/filters/explain?container=container2&destination=forum.i2p", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown container, got %d", w.Code)
+	}
+}
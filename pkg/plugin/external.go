@@ -0,0 +1,190 @@
+// Package plugin provides external I2P services of networks.
+//
+// The i2p.external.services option names existing I2P destinations a
+// network's containers depend on, as comma-separated name=b32 pairs. Every
+// container on the network resolves the names to stable synthetic addresses
+// and reaches them through the SOCKS proxy (see proxy.ExternalServices), and
+// the destinations are added to the traffic filter allowlist like entries of
+// i2p.filter.allowlist. The option can be changed on a running network with
+// POST /v1/networks/{id}/update, and single services can be added and removed
+// with PUT and DELETE /v1/networks/{id}/external/{name}.
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+// ExternalServicesOption names the external I2P services of a network.
+const ExternalServicesOption = "i2p.external.services"
+
+// errExternalServiceNotFound is returned when the external service to remove
+// does not exist.
+var errExternalServiceNotFound = errors.New("external service not found")
+
+// AdminExternalService describes an external I2P service of a network.
+type AdminExternalService struct {
+	// Name is the name containers on the network resolve
+	Name string `json:"name"`
+	// Address is the b32 address of the service's destination
+	Address string `json:"address"`
+	// IP is the synthetic address the name resolves to
+	IP string `json:"ip"`
+}
+
+// ExternalServiceRequest adds or replaces an external service of a network.
+type ExternalServiceRequest struct {
+	// Address is the b32 address of the service's destination
+	Address string `json:"address"`
+}
+
+// parseExternalServices returns the external services of network options,
+// by name.
+func parseExternalServices(options map[string]interface{}) (map[string]string, error) {
+	value, set := options[ExternalServicesOption]
+	if !set || value == nil {
+		return nil, nil
+	}
+	services, err := proxy.ParseExternalServices(fmt.Sprint(value))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s value: %w", ExternalServicesOption, err)
+	}
+	return services, nil
+}
+
+// externalServiceAddresses returns the b32 addresses of the external services
+// of network options, for the allowlist. Invalid options have none.
+func externalServiceAddresses(options map[string]interface{}) []string {
+	services, _ := parseExternalServices(options)
+	addresses := make([]string, 0, len(services))
+	for _, address := range services {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// formatExternalServices returns the option value of external services.
+func formatExternalServices(services map[string]string) string {
+	entries := make([]string, 0, len(services))
+	for name, address := range services {
+		entries = append(entries, name+"="+address)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
+
+// adminExternalServices returns the admin API representation of a network's
+// external services, in name order.
+func (nm *NetworkManager) adminExternalServices(network *I2PNetwork) []AdminExternalService {
+	network.mutex.RLock()
+	services := network.ExternalServices
+	network.mutex.RUnlock()
+	if len(services) == 0 {
+		return nil
+	}
+
+	views := make([]AdminExternalService, 0, len(services))
+	for name, address := range services {
+		ip := nm.proxyMgr.ExternalServiceAddress(address)
+		views = append(views, AdminExternalService{Name: name, Address: address, IP: ip.String()})
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].Name < views[j].Name })
+	return views
+}
+
+// SetExternalService adds or replaces an external service of a network, by
+// ID or name, updating its options and allowlist like UpdateNetwork.
+func (nm *NetworkManager) SetExternalService(ref, name, address string) (*NetworkUpdate, error) {
+	return nm.updateExternalServices(ref, func(services map[string]string) error {
+		parsed, err := proxy.ParseExternalServices(name + "=" + address)
+		if err != nil {
+			return err
+		}
+		for name, address := range parsed {
+			services[name] = address
+		}
+		return nil
+	})
+}
+
+// RemoveExternalService removes an external service of a network, by ID or
+// name, updating its options and allowlist like UpdateNetwork.
+func (nm *NetworkManager) RemoveExternalService(ref, name string) (*NetworkUpdate, error) {
+	return nm.updateExternalServices(ref, func(services map[string]string) error {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if _, exists := services[name]; !exists {
+			return fmt.Errorf("%w: %s", errExternalServiceNotFound, name)
+		}
+		delete(services, name)
+		return nil
+	})
+}
+
+// updateExternalServices applies change to the external services of a
+// network and updates the network with the resulting options.
+func (nm *NetworkManager) updateExternalServices(ref string, change func(map[string]string) error) (*NetworkUpdate, error) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	network := nm.lookupNetworkLocked(ref)
+	if network == nil {
+		return nil, fmt.Errorf("%w: %s", errUpdateNetworkNotFound, ref)
+	}
+
+	services := make(map[string]string, len(network.ExternalServices)+1)
+	for name, address := range network.ExternalServices {
+		services[name] = address
+	}
+	if err := change(services); err != nil {
+		return nil, err
+	}
+
+	// The other options are passed on as they are, so only the services change
+	options := make(map[string]string, len(network.Options)+1)
+	for key, value := range network.Options {
+		options[key] = fmt.Sprint(value)
+	}
+	delete(options, ExternalServicesOption)
+	if len(services) > 0 {
+		options[ExternalServicesOption] = formatExternalServices(services)
+	}
+	return nm.updateNetworkLocked(network, NetworkUpdateRequest{Options: options})
+}
+
+// handleAdminSetExternalService adds or replaces an external service of a
+// network.
+func (p *Plugin) handleAdminSetExternalService(w http.ResponseWriter, r *http.Request) {
+	var req ExternalServiceRequest
+	if err := p.readJSONRequest(r, &req); err != nil {
+		p.writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	update, err := p.networkMgr.SetExternalService(r.PathValue("id"), r.PathValue("name"), req.Address)
+	p.writeExternalServiceUpdate(w, update, err)
+}
+
+// handleAdminRemoveExternalService removes an external service of a network.
+func (p *Plugin) handleAdminRemoveExternalService(w http.ResponseWriter, r *http.Request) {
+	update, err := p.networkMgr.RemoveExternalService(r.PathValue("id"), r.PathValue("name"))
+	p.writeExternalServiceUpdate(w, update, err)
+}
+
+// writeExternalServiceUpdate writes the result of an external service change.
+func (p *Plugin) writeExternalServiceUpdate(w http.ResponseWriter, update *NetworkUpdate, err error) {
+	if errors.Is(err, errUpdateNetworkNotFound) || errors.Is(err, errExternalServiceNotFound) {
+		p.writeAdminError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		p.writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	p.writeJSONResponse(w, update)
+}
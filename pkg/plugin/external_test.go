@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAdminExternalServices(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+	address := strings.Repeat("a", 52) + ".b32.i2p"
+
+	req := httptest.NewRequest(http.MethodPut, "/v1/networks/i2p-test/external/DB", bytes.NewBufferString(`{"address":"`+address+`"}`))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !slices.Contains(p.networkMgr.proxyMgr.GetAllowlist(), address) {
+		t.Errorf("Expected the service's address on the allowlist, got %v", p.networkMgr.proxyMgr.GetAllowlist())
+	}
+
+	var view AdminNetwork
+	adminGet(t, mux, "/v1/networks/net1", &view)
+	if len(view.ExternalServices) != 1 || view.ExternalServices[0].Name != "db" || view.ExternalServices[0].Address != address {
+		t.Fatalf("Expected the db service in the network view, got %+v", view.ExternalServices)
+	}
+	_, synthetic, _ := net.ParseCIDR("198.18.0.0/15")
+	if ip := net.ParseIP(view.ExternalServices[0].IP); ip == nil || !synthetic.Contains(ip) {
+		t.Errorf("Expected a synthetic address, got %q", view.ExternalServices[0].IP)
+	}
+	if value := p.networkMgr.GetNetwork("net1").Options[ExternalServicesOption]; value != "db="+address {
+		t.Errorf("Expected the option to record the service, got %v", value)
+	}
+
+	for _, tt := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodPut, "/v1/networks/net1/external/api", `{"address":"api.i2p"}`, http.StatusBadRequest},
+		{http.MethodPut, "/v1/networks/net1/external/forum.i2p", `{"address":"` + address + `"}`, http.StatusBadRequest},
+		{http.MethodPut, "/v1/networks/missing/external/db", `{"address":"` + address + `"}`, http.StatusNotFound},
+		{http.MethodDelete, "/v1/networks/net1/external/api", "", http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s %s: expected status %d, got %d: %s", tt.method, tt.path, tt.want, w.Code, w.Body.String())
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/v1/networks/net1/external/db", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if slices.Contains(p.networkMgr.proxyMgr.GetAllowlist(), address) {
+		t.Errorf("Expected the address to leave the allowlist with the service")
+	}
+	if _, set := p.networkMgr.GetNetwork("net1").Options[ExternalServicesOption]; set {
+		t.Errorf("Expected the option to be removed with the last service")
+	}
+}
+
+func TestPrepareNetworkExternalServices(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	nm := p.networkMgr
+	address := strings.Repeat("b", 52) + ".b32.i2p"
+
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	setup, err := nm.prepareNetworkLocked("net2", map[string]interface{}{ExternalServicesOption: "cache=" + address}, []IPAMData{{Pool: "172.30.0.0/24"}})
+	if err != nil {
+		t.Fatalf("prepareNetworkLocked() unexpected error: %v", err)
+	}
+	if setup.network.ExternalServices["cache"] != address || !slices.Contains(setup.allowlist, address) {
+		t.Errorf("Expected the service to be parsed and allowlisted, got %v and %v", setup.network.ExternalServices, setup.allowlist)
+	}
+
+	if _, err := nm.prepareNetworkLocked("net2", map[string]interface{}{ExternalServicesOption: "cache"}, []IPAMData{{Pool: "172.30.0.0/24"}}); err == nil {
+		t.Error("Expected a service without an address to be rejected")
+	}
+}
@@ -0,0 +1,304 @@
+// Package plugin provides the gRPC admin API.
+//
+// Platform teams embedding the plugin in larger control planes prefer typed
+// clients over hand-rolled HTTP, so the read-only views of the JSON admin API
+// are also served as the i2pnet.admin.v1.AdminService gRPC service, defined in
+// pkg/adminpb/admin.proto. It listens on its own Unix socket, which gets the
+// admin socket's ownership and permissions, and is subject to the same rate
+// and size limits as the HTTP servers. Both APIs are built from the same admin
+// views, so they cannot drift apart.
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/adminpb"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// SetGRPCSocketPath configures the Unix socket for the gRPC admin API.
+//
+// The gRPC API is disabled when the path is empty. It must be called before
+// Start.
+func (p *Plugin) SetGRPCSocketPath(path string) {
+	p.grpcSockPath = path
+}
+
+// startGRPCServer starts the gRPC admin API on its Unix socket.
+//
+// Server errors are reported on errCh.
+func (p *Plugin) startGRPCServer(errCh chan<- error) error {
+	if err := os.MkdirAll(filepath.Dir(p.grpcSockPath), 0755); err != nil {
+		return fmt.Errorf("failed to create gRPC socket directory: %w", err)
+	}
+
+	if err := os.RemoveAll(p.grpcSockPath); err != nil {
+		return fmt.Errorf("failed to remove existing gRPC socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", p.grpcSockPath)
+	if err != nil {
+		return fmt.Errorf("failed to create gRPC socket listener: %w", err)
+	}
+
+	if err := p.adminSockPerms.apply(p.grpcSockPath); err != nil {
+		listener.Close()
+		return fmt.Errorf("gRPC socket: %w", err)
+	}
+
+	p.grpcServer = p.newGRPCServer()
+
+	log.Printf("gRPC admin API listening on %s", p.grpcSockPath)
+
+	go func() {
+		if err := p.grpcServer.Serve(listener); err != nil && err != grpc.ErrServerStopped {
+			errCh <- fmt.Errorf("gRPC server error: %w", err)
+		}
+	}()
+
+	return nil
+}
+
+// newGRPCServer creates a gRPC server with the admin service registered.
+func (p *Plugin) newGRPCServer() *grpc.Server {
+	var options []grpc.ServerOption
+	if p.rateLimits.MaxRequestBytes > 0 {
+		options = append(options, grpc.MaxRecvMsgSize(int(p.rateLimits.MaxRequestBytes)))
+	}
+	options = append(options, grpc.UnaryInterceptor(p.limitGRPCRequests(adminpb.AdminService_ServiceDesc)))
+
+	server := grpc.NewServer(options...)
+	adminpb.RegisterAdminServiceServer(server, &grpcAdminServer{p: p})
+	return server
+}
+
+// stopGRPCServer shuts down the gRPC admin API if it is running.
+//
+// Calls still in flight when ctx expires are cancelled.
+func (p *Plugin) stopGRPCServer(ctx context.Context) {
+	if p.grpcServer == nil {
+		return
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		p.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		p.grpcServer.Stop()
+	}
+}
+
+// limitGRPCRequests returns an interceptor applying the configured rate
+// limit to the methods of a service.
+//
+// Like the HTTP servers, every method gets its own bucket, created up front.
+func (p *Plugin) limitGRPCRequests(desc grpc.ServiceDesc) grpc.UnaryServerInterceptor {
+	limits := p.rateLimits
+
+	buckets := make(map[string]*tokenBucket)
+	if limits.RequestsPerSecond > 0 {
+		for _, method := range desc.Methods {
+			buckets["/"+desc.ServiceName+"/"+method.MethodName] = newTokenBucket(limits.RequestsPerSecond, limits.Burst)
+		}
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if bucket := buckets[info.FullMethod]; bucket != nil && !bucket.allow() {
+			p.rejectedRequests().Inc(serverGRPC, info.FullMethod, rejectRateLimited)
+			return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// grpcAdminServer implements the gRPC admin service on top of the admin views.
+type grpcAdminServer struct {
+	adminpb.UnimplementedAdminServiceServer
+	p *Plugin
+}
+
+// ListNetworks lists I2P networks sorted by ID, optionally only those of a tenant.
+func (s *grpcAdminServer) ListNetworks(ctx context.Context, req *adminpb.ListNetworksRequest) (*adminpb.ListNetworksResponse, error) {
+	resp := &adminpb.ListNetworksResponse{}
+	for _, network := range s.p.networkMgr.adminNetworks(req.GetTenant()) {
+		resp.Networks = append(resp.Networks, networkMessage(network))
+	}
+	return resp, nil
+}
+
+// GetNetwork returns an I2P network by ID or name.
+func (s *grpcAdminServer) GetNetwork(ctx context.Context, req *adminpb.GetNetworkRequest) (*adminpb.Network, error) {
+	network := s.p.networkMgr.LookupNetwork(req.GetId())
+	if network == nil {
+		return nil, status.Errorf(codes.NotFound, "network %s not found", req.GetId())
+	}
+	return networkMessage(s.p.networkMgr.adminNetworkView(network)), nil
+}
+
+// ListTunnels lists I2P tunnels sorted by name.
+func (s *grpcAdminServer) ListTunnels(ctx context.Context, req *adminpb.ListTunnelsRequest) (*adminpb.ListTunnelsResponse, error) {
+	resp := &adminpb.ListTunnelsResponse{}
+	for _, tunnel := range s.p.adminTunnels() {
+		resp.Tunnels = append(resp.Tunnels, &adminpb.Tunnel{
+			Name:          tunnel.Name,
+			ContainerId:   tunnel.ContainerID,
+			Purpose:       tunnel.Purpose,
+			Type:          tunnel.Type,
+			Destination:   tunnel.Destination,
+			LocalEndpoint: tunnel.LocalEndpoint,
+			Active:        tunnel.Active,
+			CreatedAt:     timestampMessage(tunnel.CreatedAt),
+		})
+	}
+	return resp, nil
+}
+
+// ListExposures lists services exposed from containers, optionally only those
+// on networks of a tenant.
+func (s *grpcAdminServer) ListExposures(ctx context.Context, req *adminpb.ListExposuresRequest) (*adminpb.ListExposuresResponse, error) {
+	resp := &adminpb.ListExposuresResponse{}
+	for _, exposure := range s.p.adminExposures(req.GetTenant()) {
+		resp.Exposures = append(resp.Exposures, exposureMessage(exposure))
+	}
+	return resp, nil
+}
+
+// exposureMessage converts the admin view of an exposure.
+func exposureMessage(exposure AdminExposure) *adminpb.Exposure {
+	return &adminpb.Exposure{
+		ContainerId:   exposure.ContainerID,
+		NetworkId:     exposure.NetworkID,
+		ContainerPort: int32(exposure.ContainerPort),
+		Protocol:      exposure.Protocol,
+		Type:          exposure.Type,
+		Destination:   exposure.Destination,
+		ExpiresAt:     timestampMessage(exposure.ExpiresAt),
+		SharedTunnel:  exposure.SharedTunnel,
+		Group:         exposure.Group,
+		Service:       exposure.Service,
+		TunnelName:    exposure.TunnelName,
+		Target:        exposure.Target,
+		State:         exposure.State,
+		CreatedAt:     timestampMessage(exposure.CreatedAt),
+		Addresses:     addressesMessage(exposure.Addresses),
+	}
+}
+
+// addressesMessage converts destination encodings, which are nil for IP
+// exposures.
+func addressesMessage(addresses *i2p.Addresses) *adminpb.Addresses {
+	if addresses == nil {
+		return nil
+	}
+	return &adminpb.Addresses{
+		Base64: addresses.Base64,
+		B32:    addresses.B32,
+		B33:    addresses.B33,
+	}
+}
+
+// GetStats returns traffic statistics.
+func (s *grpcAdminServer) GetStats(ctx context.Context, req *adminpb.GetStatsRequest) (*adminpb.Stats, error) {
+	stats := s.p.adminStats()
+	return &adminpb.Stats{
+		I2PConnectionsAllowed:    stats.I2PConnectionsAllowed,
+		I2PConnectionsBlocked:    stats.I2PConnectionsBlocked,
+		NonI2PConnectionsBlocked: stats.NonI2PConnectionsBlocked,
+		UnknownSourcesRejected:   stats.UnknownSourcesRejected,
+		TotalBytesTransferred:    stats.TotalBytesTransferred,
+		LastActivity:             timestampMessage(&stats.LastActivity),
+	}, nil
+}
+
+// networkMessage converts the admin view of a network to its gRPC message.
+func networkMessage(network AdminNetwork) *adminpb.Network {
+	msg := &adminpb.Network{
+		Id:           network.ID,
+		Name:         network.Name,
+		Subnet:       network.Subnet,
+		Gateway:      network.Gateway,
+		ProxyAddress: network.ProxyAddress,
+		AntiSpoof:    network.AntiSpoof,
+		Isolated:     network.Isolated,
+		MaxEndpoints: int32(network.MaxEndpoints),
+		Status:       network.Status,
+		DegradedBy:   network.DegradedBy,
+		Tenant:       network.Tenant,
+	}
+	if network.Policy != nil {
+		msg.Policy = &adminpb.NetworkPolicy{
+			Name:      network.Policy.Name,
+			Settings:  network.Policy.Settings,
+			Overrides: network.Policy.Overrides,
+		}
+	}
+	for _, endpoint := range network.Endpoints {
+		msg.Endpoints = append(msg.Endpoints, endpointMessage(endpoint))
+	}
+	return msg
+}
+
+// endpointMessage converts the admin view of an endpoint to its gRPC message.
+func endpointMessage(endpoint AdminEndpoint) *adminpb.Endpoint {
+	msg := &adminpb.Endpoint{
+		Id:               endpoint.ID,
+		State:            string(endpoint.State),
+		ContainerId:      endpoint.ContainerID,
+		IpAddress:        endpoint.IPAddress,
+		MacAddress:       endpoint.MacAddress,
+		OutboundDisabled: endpoint.OutboundDisabled,
+		IsolationGroups:  endpoint.IsolationGroups,
+		QueuedExposures:  int32(endpoint.QueuedExposures),
+		Identity:         endpoint.Identity,
+	}
+	for _, portMap := range endpoint.PortMaps {
+		portMapMsg := &adminpb.PortMap{Listen: portMap.Listen}
+		for _, destination := range portMap.Destinations {
+			portMapMsg.Destinations = append(portMapMsg.Destinations, &adminpb.PortMapDestination{
+				Destination: destination.Destination,
+				Healthy:     destination.Healthy,
+				Failures:    int32(destination.Failures),
+				RetryAt:     timestampMessage(destination.RetryAt),
+				LastError:   destination.LastError,
+			})
+		}
+		msg.PortMaps = append(msg.PortMaps, portMapMsg)
+	}
+	for _, exposure := range endpoint.Exposures {
+		msg.Exposures = append(msg.Exposures, exposureMessage(exposure))
+	}
+	if endpoint.Stats != nil {
+		msg.Stats = &adminpb.EndpointStats{
+			ProxyConnections:    endpoint.Stats.ProxyConnections,
+			ProxyBytes:          endpoint.Stats.ProxyBytes,
+			ServerTunnelStreams: endpoint.Stats.ServerTunnelStreams,
+			ServerTunnelBytes:   endpoint.Stats.ServerTunnelBytes,
+			ActiveStreams:       endpoint.Stats.ActiveStreams,
+		}
+	}
+	return msg
+}
+
+// timestampMessage converts an optional time to a timestamp, leaving it unset
+// for nil and zero times.
+func timestampMessage(t *time.Time) *timestamppb.Timestamp {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,144 @@
+package plugin
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/adminpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// newGRPCTestClient serves the gRPC admin API of p on a temporary socket and
+// returns a client connected to it.
+func newGRPCTestClient(t *testing.T, p *Plugin) adminpb.AdminServiceClient {
+	t.Helper()
+
+	p.SetGRPCSocketPath(filepath.Join(t.TempDir(), "grpc.sock"))
+	p.adminSockPerms = DefaultSocketPermissions()
+	errCh := make(chan error, 1)
+	if err := p.startGRPCServer(errCh); err != nil {
+		t.Fatalf("Failed to start gRPC server: %v", err)
+	}
+	t.Cleanup(func() { p.stopGRPCServer(context.Background()) })
+
+	conn, err := grpc.NewClient("unix://"+p.grpcSockPath, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to create gRPC client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return adminpb.NewAdminServiceClient(conn)
+}
+
+func TestGRPCAdminNetworks(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	client := newGRPCTestClient(t, p)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := client.ListNetworks(ctx, &adminpb.ListNetworksRequest{})
+	if err != nil {
+		t.Fatalf("ListNetworks failed: %v", err)
+	}
+	if len(resp.Networks) != 1 {
+		t.Fatalf("Expected 1 network, got %d", len(resp.Networks))
+	}
+	network := resp.Networks[0]
+	if network.Id != "net1" || network.Subnet != "172.20.1.0/24" || len(network.Endpoints) != 1 {
+		t.Errorf("Unexpected network: %v", network)
+	}
+	if endpoint := network.Endpoints[0]; endpoint.ContainerId != "container1" || endpoint.IpAddress != "172.20.1.2" || endpoint.State != "joined" {
+		t.Errorf("Unexpected endpoint: %v", endpoint)
+	}
+
+	// Networks are looked up by name like in the JSON API
+	byName, err := client.GetNetwork(ctx, &adminpb.GetNetworkRequest{Id: "i2p-test"})
+	if err != nil || byName.Id != "net1" {
+		t.Errorf("Expected network net1 by name, got %v (%v)", byName, err)
+	}
+
+	_, err = client.GetNetwork(ctx, &adminpb.GetNetworkRequest{Id: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+}
+
+func TestGRPCAdminTunnelsExposuresStats(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	client := newGRPCTestClient(t, p)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tunnels, err := client.ListTunnels(ctx, &adminpb.ListTunnelsRequest{})
+	if err != nil || len(tunnels.Tunnels) != 0 {
+		t.Errorf("Expected no tunnels, got %v (%v)", tunnels, err)
+	}
+	exposures, err := client.ListExposures(ctx, &adminpb.ListExposuresRequest{})
+	if err != nil || len(exposures.Exposures) != 0 {
+		t.Errorf("Expected no exposures, got %v (%v)", exposures, err)
+	}
+
+	stats, err := client.GetStats(ctx, &adminpb.GetStatsRequest{})
+	if err != nil {
+		t.Fatalf("GetStats failed: %v", err)
+	}
+	if want := p.adminStats(); stats.I2PConnectionsAllowed != want.I2PConnectionsAllowed || stats.TotalBytesTransferred != want.TotalBytesTransferred {
+		t.Errorf("Expected stats %+v, got %v", want, stats)
+	}
+}
+
+func TestGRPCAdminRateLimit(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	p.rateLimits = RateLimitConfig{RequestsPerSecond: 0.001, Burst: 1}
+	client := newGRPCTestClient(t, p)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := client.GetStats(ctx, &adminpb.GetStatsRequest{}); err != nil {
+		t.Fatalf("First call failed: %v", err)
+	}
+	if _, err := client.GetStats(ctx, &adminpb.GetStatsRequest{}); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Expected ResourceExhausted, got %v", err)
+	}
+
+	// Other methods have their own bucket
+	if _, err := client.ListTunnels(ctx, &adminpb.ListTunnelsRequest{}); err != nil {
+		t.Errorf("Expected ListTunnels to be allowed, got %v", err)
+	}
+}
+
+func TestNetworkMessage(t *testing.T) {
+	retryAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	msg := networkMessage(AdminNetwork{
+		ID:     "net1",
+		Policy: &NetworkPolicy{Name: PolicyStandard, Settings: map[string]string{IsolatedOption: "false"}},
+		Endpoints: []AdminEndpoint{{
+			ID:               "ep1",
+			OutboundDisabled: true,
+			PortMaps: []AdminPortMap{{
+				Listen:       "172.20.1.1:6667",
+				Destinations: []AdminPortMapDestination{{Destination: "irc.postman.i2p:6667", Failures: 2, RetryAt: &retryAt}},
+			}},
+			Stats: &EndpointStats{ProxyConnections: 3},
+		}},
+	})
+
+	if msg.Policy.GetName() != PolicyStandard || msg.Policy.Settings[IsolatedOption] != "false" {
+		t.Errorf("Unexpected policy: %v", msg.Policy)
+	}
+	endpoint := msg.Endpoints[0]
+	if !endpoint.OutboundDisabled || endpoint.Stats.GetProxyConnections() != 3 {
+		t.Errorf("Unexpected endpoint: %v", endpoint)
+	}
+	destination := endpoint.PortMaps[0].Destinations[0]
+	if destination.Failures != 2 || !destination.RetryAt.AsTime().Equal(retryAt) {
+		t.Errorf("Unexpected port map destination: %v", destination)
+	}
+	if timestampMessage(&time.Time{}) != nil || timestampMessage(nil) != nil {
+		t.Error("Expected zero and nil times to stay unset")
+	}
+}
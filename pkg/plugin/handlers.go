@@ -6,20 +6,19 @@ import (
 	"net"
 	"net/http"
 	"strings"
-
-	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
 )
 
 // handleGetCapabilities returns the capabilities of the network driver.
 //
-// This tells Docker what features this network plugin supports.
-// For I2P networks, we support local scope networking.
+// This tells Docker what features this network plugin supports. Networks
+// have local scope unless the plugin runs in global scope (see SetScope).
 func (p *Plugin) handleGetCapabilities(w http.ResponseWriter, r *http.Request) {
 	log.Println("Received NetworkDriver.GetCapabilities request")
 
+	scope := p.networkMgr.driverScope()
 	response := CapabilitiesResponse{
-		Scope:             "local",
-		ConnectivityScope: "local",
+		Scope:             scope,
+		ConnectivityScope: scope,
 		ErrorResponse:     ErrorResponse{Err: ""},
 	}
 
@@ -42,8 +41,10 @@ func (p *Plugin) handleCreateNetwork(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("Creating network %s", req.NetworkID)
 
-	// Use the network manager to create the network
-	if err := p.networkMgr.CreateNetwork(req.NetworkID, req.Options, req.IPv4Data); err != nil {
+	// Use the network manager to create the network; it tells the IPv6
+	// pools of --ipv6 networks apart from the IPv4 pools
+	ipamData := append(append([]IPAMData{}, req.IPv4Data...), req.IPv6Data...)
+	if err := p.networkMgr.CreateNetwork(req.NetworkID, req.Options, ipamData); err != nil {
 		log.Printf("Error creating network %s: %v", req.NetworkID, err)
 		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
 		return
@@ -96,8 +97,24 @@ func (p *Plugin) handleCreateEndpoint(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("Creating endpoint %s on network %s", req.EndpointID, req.NetworkID)
 
+	// Docker passes the addresses its IPAM driver assigned; a malformed one
+	// must fail rather than silently get the endpoint another address
+	var address, addressIPv6 net.IP
+	if req.Interface != nil && req.Interface.Address != "" {
+		if address = parseAddress(req.Interface.Address); address == nil {
+			p.writeInvalidEndpointAddress(w, req.Interface.Address)
+			return
+		}
+	}
+	if req.Interface != nil && req.Interface.AddressIPv6 != "" {
+		if addressIPv6 = parseAddress(req.Interface.AddressIPv6); addressIPv6 == nil {
+			p.writeInvalidEndpointAddress(w, req.Interface.AddressIPv6)
+			return
+		}
+	}
+
 	// Use the network manager to create the endpoint
-	endpoint, err := p.networkMgr.CreateEndpoint(req.NetworkID, req.EndpointID, req.Options)
+	endpoint, err := p.networkMgr.CreateEndpointWithAddresses(req.NetworkID, req.EndpointID, address, addressIPv6, req.Options)
 	if err != nil {
 		log.Printf("Error creating endpoint %s: %v", req.EndpointID, err)
 		p.writeJSONResponse(w, CreateEndpointResponse{
@@ -106,19 +123,46 @@ func (p *Plugin) handleCreateEndpoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Prepare the response with endpoint interface information
+	// Prepare the response with endpoint interface information, in CIDR
+	// notation with the prefix length of the network's subnets
 	response := CreateEndpointResponse{
 		Interface: &EndpointInterface{
 			MacAddress: endpoint.MacAddress,
-			Address:    endpoint.IPAddress.String() + "/" + "24", // Include CIDR notation
 		},
 		ErrorResponse: ErrorResponse{Err: ""},
 	}
+	// Docker refuses to have an address it assigned set again
+	if network := p.networkMgr.GetNetwork(req.NetworkID); network != nil {
+		if !endpoint.IPAddress.Equal(address) {
+			response.Interface.Address = endpointCIDR(endpoint.IPAddress, network.Subnet)
+		}
+		if endpoint.IPv6Address != nil && !endpoint.IPv6Address.Equal(addressIPv6) {
+			response.Interface.AddressIPv6 = endpointCIDR(endpoint.IPv6Address, network.SubnetIPv6)
+		}
+	}
 
 	log.Printf("Successfully created endpoint %s on network %s", req.EndpointID, req.NetworkID)
 	p.writeJSONResponse(w, response)
 }
 
+// writeInvalidEndpointAddress rejects a CreateEndpoint request with an
+// address that does not parse.
+func (p *Plugin) writeInvalidEndpointAddress(w http.ResponseWriter, address string) {
+	log.Printf("Error creating endpoint: invalid address %q", address)
+	p.writeJSONResponse(w, CreateEndpointResponse{
+		ErrorResponse: ErrorResponse{Err: fmt.Sprintf("invalid address %q", address)},
+	})
+}
+
+// endpointCIDR formats address with the prefix length of subnet, or returns
+// an empty string if either is missing.
+func endpointCIDR(address net.IP, subnet *net.IPNet) string {
+	if address == nil || subnet == nil {
+		return ""
+	}
+	return (&net.IPNet{IP: address, Mask: subnet.Mask}).String()
+}
+
 // handleDeleteEndpoint removes a container endpoint.
 //
 // This cleans up I2P resources for a specific container.
@@ -162,26 +206,9 @@ func (p *Plugin) handleEndpointInfo(w http.ResponseWriter, r *http.Request) {
 
 	log.Printf("Getting info for endpoint %s on network %s", req.EndpointID, req.NetworkID)
 
-	// Get endpoint to retrieve service exposures
-	network := p.networkMgr.GetNetwork(req.NetworkID)
-	value := make(map[string]interface{})
-
-	if network != nil {
-		network.mutex.RLock()
-		endpoint, exists := network.Endpoints[req.EndpointID]
-		network.mutex.RUnlock()
-
-		if exists && endpoint != nil && len(endpoint.ServiceExposures) > 0 {
-			// Add service addresses to endpoint info (accessible via docker inspect)
-			serviceAddresses := make(map[string]string)
-			for _, exposure := range endpoint.ServiceExposures {
-				portKey := exposure.TunnelName
-				serviceAddresses[portKey] = exposure.Destination
-			}
-			value["com.i2p.service.addresses"] = serviceAddresses
-			log.Printf("Providing %d I2P service addresses for endpoint %s via EndpointOperInfo",
-				len(serviceAddresses), req.EndpointID)
-		}
+	value, exists := p.networkMgr.EndpointOperInfo(req.NetworkID, req.EndpointID)
+	if !exists {
+		value = make(map[string]interface{})
 	}
 
 	response := EndpointInfoResponse{
@@ -235,16 +262,27 @@ func (p *Plugin) handleJoin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Docker moves the endpoint's interface into the container and renames it
+	srcName := endpoint.Interface
+	if srcName == "" {
+		srcName = "veth" // Interfaces are set up outside the plugin (LinkBackendNoop)
+	}
+
 	// Prepare the response with network configuration
 	response := JoinResponse{
 		InterfaceName: &InterfaceName{
-			SrcName:   "veth", // Standard Docker veth interface
-			DstPrefix: "eth",  // Standard container interface prefix
+			SrcName:   srcName,
+			DstPrefix: "eth", // Standard container interface prefix
 		},
-		Gateway: network.Gateway.String(),
-		// ResolvConf and DNS can be configured for I2P-specific resolution
+		Gateway:       network.Gateway.String(),
 		ErrorResponse: ErrorResponse{Err: ""},
 	}
+	if network.GatewayIPv6 != nil {
+		response.GatewayIPv6 = network.GatewayIPv6.String()
+	}
+
+	// Route synthetic I2P addresses to the gateway, where the proxy intercepts them
+	response.StaticRoutes = p.networkMgr.syntheticRoutes(network, endpoint.ContainerID)
 
 	// Add I2P service addresses to response options for user retrieval
 	if len(endpoint.ServiceExposures) > 0 {
@@ -252,16 +290,11 @@ func (p *Plugin) handleJoin(w http.ResponseWriter, r *http.Request) {
 			response.Options = make(map[string]interface{})
 		}
 
-		// Create a map of port -> .b32.i2p address
-		serviceAddresses := make(map[string]string)
-		for _, exposure := range endpoint.ServiceExposures {
-			// Use service name and port as key
-			portKey := exposure.TunnelName
-			serviceAddresses[portKey] = exposure.Destination
-		}
-		response.Options["com.i2p.service.addresses"] = serviceAddresses
+		// Map each exposure tunnel to its .b32.i2p address
+		addresses := serviceAddresses(endpoint)
+		response.Options[serviceAddressesKey] = addresses
 
-		log.Printf("Exposed %d I2P service addresses for container via Join response", len(serviceAddresses))
+		log.Printf("Exposed %d I2P service addresses for container via Join response", len(addresses))
 	}
 
 	log.Printf("Successfully joined endpoint %s to network %s with IP %s",
@@ -296,44 +329,11 @@ func (p *Plugin) handleLeave(w http.ResponseWriter, r *http.Request) {
 	p.writeJSONResponse(w, ErrorResponse{Err: ""})
 }
 
-// handleDiscoverNew handles discovery of new nodes.
-//
-// This is used for multi-host networking, which we don't support for I2P.
-func (p *Plugin) handleDiscoverNew(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received NetworkDriver.DiscoverNew request")
-
-	var req DiscoveryNotification
-	if err := p.readJSONRequest(r, &req); err != nil {
-		log.Printf("Error parsing DiscoverNew request: %v", err)
-		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
-		return
-	}
-
-	// I2P networks are local scope, so discovery is not needed
-	p.writeJSONResponse(w, ErrorResponse{Err: ""})
-}
-
-// handleDiscoverDelete handles removal of discovered nodes.
-//
-// This is used for multi-host networking, which we don't support for I2P.
-func (p *Plugin) handleDiscoverDelete(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received NetworkDriver.DiscoverDelete request")
-
-	var req DiscoveryNotification
-	if err := p.readJSONRequest(r, &req); err != nil {
-		log.Printf("Error parsing DiscoverDelete request: %v", err)
-		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
-		return
-	}
-
-	// I2P networks are local scope, so discovery is not needed
-	p.writeJSONResponse(w, ErrorResponse{Err: ""})
-}
-
-// handleProgramExternalConnectivity sets up external connectivity.
+// handleProgramExternalConnectivity publishes the ports of a container.
 //
-// Docker calls this when the user specifies port mappings via -p flag.
-// For I2P networks with IP exposure enabled, we create TCP/UDP port forwarding.
+// Docker calls this after Join when the user publishes ports with the -p flag.
+// Each port binding is forwarded from the host, or served over I2P on
+// networks that do not allow IP exposure (see PublishPorts).
 func (p *Plugin) handleProgramExternalConnectivity(w http.ResponseWriter, r *http.Request) {
 	log.Println("Received NetworkDriver.ProgramExternalConnectivity request")
 
@@ -346,153 +346,32 @@ func (p *Plugin) handleProgramExternalConnectivity(w http.ResponseWriter, r *htt
 
 	log.Printf("Programming external connectivity for endpoint %s on network %s", req.EndpointID, req.NetworkID)
 
-	// Extract port bindings from options
-	portBindingsRaw, hasPortBindings := req.Options["com.docker.network.portmap"]
-	if !hasPortBindings || portBindingsRaw == nil {
-		log.Printf("No port bindings found in external connectivity request")
-		p.writeJSONResponse(w, ErrorResponse{Err: ""})
-		return
-	}
-
-	// Parse port bindings
-	portBindingsList, ok := portBindingsRaw.([]interface{})
-	if !ok {
-		log.Printf("Invalid port bindings format: expected []interface{}, got %T", portBindingsRaw)
-		p.writeJSONResponse(w, ErrorResponse{Err: "invalid port bindings format"})
-		return
-	}
-
-	// Get network manager
-	network := p.networkMgr.GetNetwork(req.NetworkID)
-	if network == nil {
-		log.Printf("Failed to get network %s", req.NetworkID)
-		p.writeJSONResponse(w, ErrorResponse{Err: fmt.Sprintf("network %s not found", req.NetworkID)})
+	bindings, err := parsePortBindings(req.Options)
+	if err != nil {
+		log.Printf("Error parsing port bindings of endpoint %s: %v", req.EndpointID, err)
+		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
 		return
 	}
-
-	// Check if network allows IP exposure
-	if !network.ExposureConfig.AllowIPExposure {
-		log.Printf("Port mapping requested but network %s has allow_ip=false", req.NetworkID)
-		p.writeJSONResponse(w, ErrorResponse{
-			Err: "This I2P network does not allow IP exposure (allow_ip=false). Port mappings (-p) are not supported. Use --expose for I2P-only service exposure, or create a network with allow_ip=true to enable port forwarding.",
-		})
+	if len(bindings) == 0 {
+		log.Printf("No port bindings found in external connectivity request")
+		p.writeJSONResponse(w, ErrorResponse{Err: ""})
 		return
 	}
 
-	// Get endpoint
-	endpoint, ok := network.Endpoints[req.EndpointID]
-	if !ok {
-		log.Printf("Endpoint %s not found in network %s", req.EndpointID, req.NetworkID)
-		p.writeJSONResponse(w, ErrorResponse{Err: fmt.Sprintf("endpoint %s not found", req.EndpointID)})
+	if _, err := p.networkMgr.PublishPorts(req.NetworkID, req.EndpointID, bindings); err != nil {
+		log.Printf("Error publishing ports of endpoint %s: %v", req.EndpointID, err)
+		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
 		return
 	}
 
-	// Create IP exposures for each port binding
-	log.Printf("Processing %d port binding(s) for endpoint %s", len(portBindingsList), req.EndpointID)
-
-	for i, bindingRaw := range portBindingsList {
-		bindingMap, ok := bindingRaw.(map[string]interface{})
-		if !ok {
-			log.Printf("Skipping invalid port binding at index %d: not a map", i)
-			continue
-		}
-
-		// Extract port binding fields
-		var containerPort int
-		var hostIP string
-		var hostPort int
-
-		// Parse container port (Port field)
-		if portVal, ok := bindingMap["Port"].(float64); ok {
-			containerPort = int(portVal)
-		} else {
-			log.Printf("Skipping port binding at index %d: missing or invalid Port field", i)
-			continue
-		}
-
-		// Parse host IP - check HostIP field first, default to 127.0.0.1
-		hostIP = "127.0.0.1"
-		if hostIPBytes, ok := bindingMap["HostIP"].([]interface{}); ok && len(hostIPBytes) >= 4 {
-			// Convert byte array to IP string
-			ipBytes := make([]byte, len(hostIPBytes))
-			for j, b := range hostIPBytes {
-				if bFloat, ok := b.(float64); ok {
-					ipBytes[j] = byte(bFloat)
-				}
-			}
-			parsedIP := net.IP(ipBytes)
-			if parsedIP != nil && !parsedIP.IsUnspecified() {
-				hostIP = parsedIP.String()
-			}
-		}
-
-		// Parse host port (HostPort field)
-		if hostPortVal, ok := bindingMap["HostPort"].(float64); ok {
-			hostPort = int(hostPortVal)
-		} else {
-			log.Printf("Skipping port binding at index %d: missing or invalid HostPort field", i)
-			continue
-		}
-
-		// Parse protocol (Proto field)
-		var protocol string = "tcp"
-		if protoVal, ok := bindingMap["Proto"].(float64); ok {
-			switch uint8(protoVal) {
-			case 6:
-				protocol = "tcp"
-			case 17:
-				protocol = "udp"
-			default:
-				log.Printf("Skipping port binding at index %d: unsupported protocol %d", i, uint8(protoVal))
-				continue
-			}
-		}
-
-		log.Printf("Port binding %d: %s:%d -> container:%d (protocol: %s)",
-			i, hostIP, hostPort, containerPort, protocol)
-
-		// Create ExposedPort for the port mapping
-		// Note: -p mappings can use different host/container ports (e.g., -p 8080:80)
-		exposedPort := service.ExposedPort{
-			ContainerPort: containerPort,
-			HostPort:      hostPort, // Host port may differ from container port
-			Protocol:      protocol,
-			ServiceName:   fmt.Sprintf("portmap-%d", hostPort),
-			ExposureType:  service.ExposureTypeIP,
-			TargetIP:      hostIP,
-		}
-
-		// Use service manager to create the IP exposure
-		exposures, err := p.networkMgr.ExposeServicesForEndpoint(
-			endpoint.ContainerID,
-			req.NetworkID,
-			endpoint.IPAddress,
-			[]service.ExposedPort{exposedPort},
-		)
-		if err != nil {
-			log.Printf("Failed to create IP exposure for port %d: %v", containerPort, err)
-			p.writeJSONResponse(w, ErrorResponse{
-				Err: fmt.Sprintf("failed to create port forwarding from %s:%d to container port %d: %v", hostIP, hostPort, containerPort, err),
-			})
-			return
-		}
-
-		log.Printf("Successfully created port mapping: %s:%d -> %s:%d (%s)",
-			hostIP, hostPort, endpoint.IPAddress.String(), containerPort, protocol)
-
-		// Store the exposures in the endpoint
-		if len(exposures) > 0 {
-			endpoint.ServiceExposures = append(endpoint.ServiceExposures, exposures[0])
-		}
-	}
-
 	log.Printf("Successfully programmed external connectivity for endpoint %s", req.EndpointID)
 	p.writeJSONResponse(w, ErrorResponse{Err: ""})
 }
 
-// handleRevokeExternalConnectivity removes external connectivity.
+// handleRevokeExternalConnectivity removes the published ports of a container.
 //
-// This cleans up I2P server tunnels when ports are no longer exposed.
+// Docker calls this before Leave; exposures the container has for other
+// reasons are removed with the endpoint.
 func (p *Plugin) handleRevokeExternalConnectivity(w http.ResponseWriter, r *http.Request) {
 	log.Println("Received NetworkDriver.RevokeExternalConnectivity request")
 
@@ -505,10 +384,11 @@ func (p *Plugin) handleRevokeExternalConnectivity(w http.ResponseWriter, r *http
 
 	log.Printf("Revoking external connectivity for endpoint %s on network %s", req.EndpointID, req.NetworkID)
 
-	// I2P server tunnel cleanup is handled in LeaveEndpoint and DeleteEndpoint.
-	// This handler acknowledges Docker's cleanup request but actual tunnel teardown
-	// happens during endpoint/network lifecycle management.
-	log.Printf("Note: I2P tunnel cleanup handled via endpoint lifecycle management")
+	if err := p.networkMgr.UnpublishPorts(req.NetworkID, req.EndpointID); err != nil {
+		log.Printf("Error unpublishing ports of endpoint %s: %v", req.EndpointID, err)
+		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
+		return
+	}
 
 	p.writeJSONResponse(w, ErrorResponse{Err: ""})
 }
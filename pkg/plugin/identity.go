@@ -0,0 +1,242 @@
+// Package plugin provides stable I2P identities for recreated containers.
+//
+// `docker compose up --force-recreate` replaces a container with a new one
+// under a new container ID, which would otherwise get a fresh destination
+// and new .b32.i2p addresses. Containers are recognised across recreation by
+// their i2p.identity label or, for Compose services, by the project, service
+// and container number labels Compose sets. The session keys and exposure
+// records of a container with an identity are remembered under it. When a
+// container with the same identity joins again under another ID, the keys
+// are handed to its session before any tunnel is built and the records are
+// rebound to the new ID, so its published addresses stay the same.
+//
+// Identities are kept in memory and, with an identity path configured, in a
+// state file that survives plugin restarts. The file holds private keys;
+// like every state file it is created with mode 0600.
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/state"
+)
+
+// IdentityLabel is the container label naming the identity a container keeps
+// across recreation.
+const IdentityLabel = "i2p.identity"
+
+// Labels Docker Compose sets on service containers.
+const (
+	composeProjectLabel = "com.docker.compose.project"
+	composeServiceLabel = "com.docker.compose.service"
+	composeNumberLabel  = "com.docker.compose.container-number"
+)
+
+// identitySchema describes the layout versions of the identity file.
+var identitySchema = state.Schema{Name: "container identities"}
+
+// containerIdentity returns the identity of a container from its labels, or
+// an empty string if it has none.
+//
+// An i2p.identity label takes precedence; Compose containers are identified
+// as compose:<project>/<service>/<container number>.
+func containerIdentity(options map[string]interface{}) string {
+	labels, ok := options["Labels"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if identity, ok := labels[IdentityLabel].(string); ok && identity != "" {
+		return identity
+	}
+
+	project, _ := labels[composeProjectLabel].(string)
+	serviceName, _ := labels[composeServiceLabel].(string)
+	if project == "" || serviceName == "" {
+		return ""
+	}
+	number, _ := labels[composeNumberLabel].(string)
+	if number == "" {
+		number = "1"
+	}
+	return fmt.Sprintf("compose:%s/%s/%s", project, serviceName, number)
+}
+
+// identityRecord is what is remembered about the container holding an identity.
+type identityRecord struct {
+	// ContainerID is the container the identity was last bound to
+	ContainerID string `json:"container_id"`
+	// PublicKey is the base64 destination of the container's session
+	PublicKey string `json:"public_key"`
+	// PrivateKey is the base64 private key blob of the session
+	PrivateKey string `json:"private_key"`
+	// Exposures are the container's exposures when the record was saved
+	Exposures []service.ExposureInfo `json:"exposures,omitempty"`
+	// UpdatedAt is when the record was last saved
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// identityStore remembers identities by name, persisted to path if set.
+type identityStore struct {
+	path    string
+	records map[string]*identityRecord
+	mutex   sync.Mutex
+}
+
+// newIdentityStore returns an empty, unpersisted identity store.
+func newIdentityStore() *identityStore {
+	return &identityStore{records: make(map[string]*identityRecord)}
+}
+
+// load reads the identity file, keeping the current records if it is missing.
+func (s *identityStore) load(path string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.path = path
+	if path == "" {
+		return nil
+	}
+
+	records := make(map[string]*identityRecord)
+	err := state.Load(path, identitySchema, &records)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.records = records
+	log.Printf("Restored %d container identities from %s", len(records), path)
+	return nil
+}
+
+// get returns a copy of the record of identity.
+func (s *identityStore) get(identity string) (identityRecord, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record, exists := s.records[identity]
+	if !exists {
+		return identityRecord{}, false
+	}
+	return *record, true
+}
+
+// put saves the record of identity.
+func (s *identityStore) put(identity string, record identityRecord) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record.UpdatedAt = time.Now()
+	s.records[identity] = &record
+	s.saveLocked()
+}
+
+// saveLocked writes the records to the identity file, if one is configured.
+// The caller must hold s.mutex.
+func (s *identityStore) saveLocked() {
+	if s.path == "" {
+		return
+	}
+	if err := state.Save(s.path, identitySchema, s.records); err != nil {
+		log.Printf("Warning: Failed to save container identities: %v", err)
+	}
+}
+
+// SetIdentityPath sets the file container identities are persisted to. An
+// empty path keeps them in memory only.
+//
+// Must be called before Start so persisted identities are loaded.
+func (p *Plugin) SetIdentityPath(path string) {
+	p.identityPath = path
+}
+
+// loadIdentities restores persisted container identities.
+func (p *Plugin) loadIdentities() error {
+	return p.networkMgr.identities.load(p.identityPath)
+}
+
+// adoptIdentityLocked binds the identity of a joining container, handing the
+// session keys of the container that held it before to the new container.
+//
+// Nothing is rebound while the previous container is still joined, so two
+// running containers never share a destination. Handoffs are serialized by
+// nm.identityMutex, as containers may join different networks at once. The
+// caller must hold the network's mutex.
+func (nm *NetworkManager) adoptIdentityLocked(endpoint *I2PEndpoint, options map[string]interface{}) {
+	endpoint.Identity = ""
+	if endpoint.ClientOnly {
+		return
+	}
+	identity := containerIdentity(options)
+	if identity == "" {
+		return
+	}
+
+	nm.identityMutex.Lock()
+	defer nm.identityMutex.Unlock()
+
+	containerID := endpoint.ContainerID
+	record, exists := nm.identities.get(identity)
+	if !exists || record.ContainerID == containerID {
+		endpoint.Identity = identity
+		return
+	}
+	if nm.joined.contains(record.ContainerID) {
+		log.Printf("Warning: Identity %s is still held by container %s, container %s gets its own destination",
+			identity, record.ContainerID, containerID)
+		return
+	}
+
+	keys, err := i2p.ParseKeys(record.PublicKey, record.PrivateKey)
+	if err == nil {
+		err = nm.tunnelMgr.AdoptSessionKeys(containerID, keys)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to rebind identity %s to container %s: %v", identity, containerID, err)
+		return
+	}
+
+	previous := record.ContainerID
+	record.ContainerID = containerID
+	for i := range record.Exposures {
+		record.Exposures[i].ContainerID = containerID
+	}
+	nm.identities.put(identity, record)
+	endpoint.Identity = identity
+
+	log.Printf("Container %s rejoined as %s, rebound destination %s from container %s",
+		containerID, identity, keys.Addr().Base32(), previous)
+}
+
+// rememberIdentityLocked saves the session keys and exposures of a container
+// with an identity. Containers without a session yet are skipped. The caller
+// must hold the network's mutex.
+func (nm *NetworkManager) rememberIdentityLocked(endpoint *I2PEndpoint) {
+	if endpoint.Identity == "" {
+		return
+	}
+	keys, exists := nm.tunnelMgr.SessionKeys(endpoint.ContainerID)
+	if !exists {
+		return
+	}
+
+	record := identityRecord{
+		ContainerID: endpoint.ContainerID,
+		PublicKey:   keys.Addr().Base64(),
+		PrivateKey:  keys.String(),
+	}
+	for _, exposure := range endpoint.ServiceExposures {
+		info := exposure.Info()
+		info.NetworkID = endpoint.NetworkID
+		record.Exposures = append(record.Exposures, info)
+	}
+	nm.identities.put(endpoint.Identity, record)
+}
@@ -0,0 +1,130 @@
+package plugin
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContainerIdentity(t *testing.T) {
+	labels := func(values map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"Labels": values}
+	}
+	compose := map[string]interface{}{composeProjectLabel: "shop", composeServiceLabel: "web"}
+
+	tests := []struct {
+		name     string
+		options  map[string]interface{}
+		expected string
+	}{
+		{name: "no labels", options: map[string]interface{}{}},
+		{name: "unrelated labels", options: labels(map[string]interface{}{"other": "x"})},
+		{name: "label", options: labels(map[string]interface{}{IdentityLabel: "blog"}), expected: "blog"},
+		{name: "compose", options: labels(compose), expected: "compose:shop/web/1"},
+		{name: "compose replica", options: labels(map[string]interface{}{composeProjectLabel: "shop", composeServiceLabel: "web", composeNumberLabel: "2"}), expected: "compose:shop/web/2"},
+		{name: "label overrides compose", options: labels(map[string]interface{}{composeProjectLabel: "shop", composeServiceLabel: "web", IdentityLabel: "blog"}), expected: "blog"},
+		{name: "compose without service", options: labels(map[string]interface{}{composeProjectLabel: "shop"})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if identity := containerIdentity(tt.options); identity != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, identity)
+			}
+		})
+	}
+}
+
+func TestIdentityRebinding(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	_, subnet, _ := net.ParseCIDR("10.31.0.0/24")
+	gateway := net.ParseIP("10.31.0.1")
+	nm.addNetworkLocked(&I2PNetwork{
+		ID:            "net1",
+		Subnet:        subnet,
+		Gateway:       gateway,
+		Endpoints:     map[string]*I2PEndpoint{},
+		IPAllocator:   NewIPAllocator(subnet, gateway),
+		TunnelManager: nm.tunnelMgr,
+	})
+
+	// The container that held the identity has left
+	public := strings.Repeat("A", 516)
+	nm.identities.put("compose:shop/web/1", identityRecord{ContainerID: "old", PublicKey: public, PrivateKey: public + "AAAA"})
+
+	options := map[string]interface{}{"Labels": map[string]interface{}{composeProjectLabel: "shop", composeServiceLabel: "web"}}
+	for _, endpointID := range []string{"ep1", "ep2"} {
+		if _, err := nm.CreateEndpoint("net1", endpointID, nil); err != nil {
+			t.Fatalf("Failed to create endpoint: %v", err)
+		}
+	}
+
+	endpoint, err := nm.JoinEndpoint("net1", "ep1", "new", "", options)
+	if err != nil {
+		t.Fatalf("Failed to join endpoint: %v", err)
+	}
+	if endpoint.Identity != "compose:shop/web/1" {
+		t.Errorf("Expected the Compose identity, got %q", endpoint.Identity)
+	}
+	if destination, known := nm.tunnelMgr.KnownDestination("new"); !known || destination != public {
+		t.Errorf("Expected the recreated container to reuse the destination, got %q (known %v)", destination, known)
+	}
+	if record, _ := nm.identities.get("compose:shop/web/1"); record.ContainerID != "new" {
+		t.Errorf("Expected the identity to be rebound to the new container, got %q", record.ContainerID)
+	}
+
+	// A second container with the same identity does not share the destination
+	other, err := nm.JoinEndpoint("net1", "ep2", "other", "", options)
+	if err != nil {
+		t.Fatalf("Failed to join endpoint: %v", err)
+	}
+	if other.Identity != "" {
+		t.Errorf("Expected no identity while it is held, got %q", other.Identity)
+	}
+	if _, known := nm.tunnelMgr.KnownDestination("other"); known {
+		t.Error("Expected the second container to get its own destination")
+	}
+
+	// Leaving keeps the identity for the next container
+	if err := nm.LeaveEndpoint("net1", "ep1"); err != nil {
+		t.Fatalf("Failed to leave endpoint: %v", err)
+	}
+	if endpoint.Identity != "" {
+		t.Errorf("Expected the parked endpoint to drop its identity, got %q", endpoint.Identity)
+	}
+	if record, exists := nm.identities.get("compose:shop/web/1"); !exists || record.PublicKey != public {
+		t.Errorf("Expected the identity to be remembered after Leave, got %+v", record)
+	}
+}
+
+func TestIdentityStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identities.json")
+	public := strings.Repeat("B", 516)
+
+	store := newIdentityStore()
+	if err := store.load(path); err != nil {
+		t.Fatalf("Expected a missing file to be ignored, got %v", err)
+	}
+	store.put("blog", identityRecord{ContainerID: "c1", PublicKey: public, PrivateKey: public + "AAAA"})
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected the identity file to be written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the identity file to be private, got %v", info.Mode().Perm())
+	}
+
+	restored := newIdentityStore()
+	if err := restored.load(path); err != nil {
+		t.Fatalf("Failed to load identities: %v", err)
+	}
+	if record, exists := restored.get("blog"); !exists || record.ContainerID != "c1" || record.PrivateKey != public+"AAAA" {
+		t.Errorf("Expected the identity to be restored, got %+v", record)
+	}
+}
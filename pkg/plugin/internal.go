@@ -0,0 +1,59 @@
+// Package plugin provides internal networks.
+//
+// docker network create --internal asks for a network without external
+// connectivity, and Docker passes the flag to the driver with CreateNetwork.
+// The plugin turns it into an I2P-only guarantee: traffic leaving the network
+// is dropped by firewall rules except connections to the SAM bridges (see
+// proxy.ProxyManager.BlockNetworkEgress), and containers cannot publish
+// ports on host addresses, so ports requested as IP exposures or with
+// docker run -p are served through I2P instead. The SOCKS proxy and DNS
+// resolver only ever reach I2P destinations, on internal networks as
+// everywhere else. Without firewall rules there is no guarantee, so internal
+// networks cannot be created with the noop firewall backend.
+package plugin
+
+import (
+	"log"
+	"net"
+	"strconv"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+// InternalOption is the network option Docker sets to true for networks
+// created with --internal.
+const InternalOption = "com.docker.network.internal"
+
+// parseInternal reports whether the network options mark an internal network.
+func parseInternal(options map[string]interface{}) (bool, error) {
+	return parseBoolOption(options, InternalOption, false)
+}
+
+// restrictInternalExposure forbids IP exposure on an internal network.
+func restrictInternalExposure(network *I2PNetwork) {
+	if network.ExposureConfig.AllowIPExposure {
+		log.Printf("Network %s is internal, serving IP exposures through I2P", network.ID)
+	}
+	network.ExposureConfig.AllowIPExposure = false
+}
+
+// egressSubnets returns the subnets whose egress is blocked on an internal
+// network: its IPv4 subnet and, on networks created with --ipv6, its IPv6
+// subnet.
+func (n *I2PNetwork) egressSubnets() []*net.IPNet {
+	subnets := []*net.IPNet{n.Subnet}
+	if n.SubnetIPv6 != nil {
+		subnets = append(subnets, n.SubnetIPv6)
+	}
+	return subnets
+}
+
+// samBridgeAddrsLocked returns the routed addresses of the SAM bridges
+// internal networks may still reach.
+//
+// The caller must hold nm.mutex.
+func (nm *NetworkManager) samBridgeAddrsLocked() []*net.TCPAddr {
+	config := nm.tunnelMgr.SAMConfig()
+	bridges := append([]string{net.JoinHostPort(config.Host, strconv.Itoa(config.Port))}, config.Backups...)
+	return proxy.ResolveSAMBridges(bridges)
+}
@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseInternal(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  map[string]interface{}
+		expected bool
+		wantErr  bool
+	}{
+		{name: "unset", options: map[string]interface{}{}},
+		{name: "docker flag", options: map[string]interface{}{InternalOption: true}, expected: true},
+		{name: "string", options: map[string]interface{}{InternalOption: "true"}, expected: true},
+		{name: "invalid", options: map[string]interface{}{InternalOption: "sometimes"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			internal, err := parseInternal(tt.options)
+			if (err != nil) != tt.wantErr || internal != tt.expected {
+				t.Errorf("parseInternal() = %v, %v; want %v (error %v)", internal, err, tt.expected, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdminDryRunInternal(t *testing.T) {
+	_, mux := newAdminTestPlugin(t)
+
+	w, plan := adminDryRun(t, mux, DryRunRequest{
+		Options: map[string]string{InternalOption: "true", "i2p.exposure.allow_ip": "true"},
+		Subnet:  "172.30.0.0/24",
+		Labels:  map[string]string{"i2p.expose.8080": "ip"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if plan.Network == nil || !plan.Network.Internal || plan.Network.AllowIPExposure {
+		t.Fatalf("Expected an internal network without IP exposure, got %+v", plan.Network)
+	}
+
+	var dropped bool
+	for _, rule := range plan.Network.IptablesRules {
+		if strings.HasSuffix(rule, "-A I2P_EGRESS -s 172.30.0.0/24 -j DROP") {
+			dropped = true
+		}
+	}
+	if !dropped {
+		t.Errorf("Expected the plan to drop traffic leaving the network, got %v", plan.Network.IptablesRules)
+	}
+
+	if plan.Join == nil || len(plan.Join.Exposures) != 1 || plan.Join.Exposures[0].Type != "i2p" {
+		t.Errorf("Expected the IP exposure to be served through I2P, got %+v", plan.Join)
+	}
+}
+
+func TestCreateInternalNetworkNoopFirewall(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	// The noop backend cannot block egress, so the network is refused
+	err := nm.CreateNetwork("net0", map[string]interface{}{InternalOption: true},
+		[]IPAMData{{Pool: "172.31.10.0/24", Gateway: "172.31.10.1"}})
+	if err == nil || !strings.Contains(err.Error(), "noop") {
+		t.Fatalf("Expected the internal network to be refused, got %v", err)
+	}
+	if nm.GetNetwork("net0") != nil {
+		t.Error("Expected the refused network not to be stored")
+	}
+}
@@ -2,14 +2,160 @@
 //
 // This file implements IP address management (IPAM) for containers on I2P networks,
 // ensuring proper allocation and cleanup of IP addresses within network subnets.
+// The order in which addresses are handed out is decided by a pluggable
+// AllocationStrategy selected per network.
 package plugin
 
 import (
 	"fmt"
+	"math/big"
+	"math/rand/v2"
 	"net"
+	"strings"
 	"sync"
 )
 
+// Allocation strategy names accepted by the i2p.ipam.strategy network option.
+const (
+	StrategySequential = "sequential"
+	StrategyRandom     = "random"
+	StrategySticky     = "sticky"
+)
+
+// maxPoolSize caps the number of addresses considered in very large (IPv6) subnets.
+const maxPoolSize = 1 << 24
+
+// AllocationStrategy decides where the allocator starts looking for a free address.
+//
+// Addresses are identified by their offset from the subnet's network address.
+// The allocator probes upwards from the returned offset (wrapping around), so a
+// strategy only has to pick a starting point and never sees allocator state.
+// Implementations are called with the allocator's lock held.
+type AllocationStrategy interface {
+	// Name identifies the strategy in options, logs and metrics
+	Name() string
+	// Start returns the offset in [first, last] to probe first for key
+	Start(key string, first, last uint64) uint64
+	// Allocated records that key received the address at offset
+	Allocated(key string, offset uint64)
+}
+
+// NewAllocationStrategy returns the built-in strategy with the given name.
+func NewAllocationStrategy(name string) (AllocationStrategy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", StrategySequential:
+		return &sequentialStrategy{}, nil
+	case StrategyRandom:
+		return randomStrategy{}, nil
+	case StrategySticky:
+		return &stickyStrategy{reservations: make(map[string]uint64)}, nil
+	default:
+		return nil, fmt.Errorf("unknown IP allocation strategy %q (expected %s, %s or %s)",
+			name, StrategySequential, StrategyRandom, StrategySticky)
+	}
+}
+
+// sequentialStrategy hands out addresses in order, continuing after the last one.
+type sequentialStrategy struct {
+	next uint64
+}
+
+// Name returns the strategy name.
+func (s *sequentialStrategy) Name() string { return StrategySequential }
+
+// Start returns the offset after the previous allocation.
+func (s *sequentialStrategy) Start(key string, first, last uint64) uint64 {
+	if s.next < first || s.next > last {
+		return first
+	}
+	return s.next
+}
+
+// Allocated advances past the allocated offset.
+func (s *sequentialStrategy) Allocated(key string, offset uint64) {
+	s.next = offset + 1
+}
+
+// randomStrategy picks a uniformly random starting point, so container
+// addresses cannot be predicted from creation order.
+type randomStrategy struct{}
+
+// Name returns the strategy name.
+func (randomStrategy) Name() string { return StrategyRandom }
+
+// Start returns a random offset within the pool.
+func (randomStrategy) Start(key string, first, last uint64) uint64 {
+	return first + rand.Uint64N(last-first+1)
+}
+
+// Allocated is a no-op for the random strategy.
+func (randomStrategy) Allocated(key string, offset uint64) {}
+
+// stickyStrategy gives a key the same address it had last time when that
+// address is still free, and otherwise behaves like the sequential strategy.
+type stickyStrategy struct {
+	sequentialStrategy
+	reservations map[string]uint64
+}
+
+// Name returns the strategy name.
+func (s *stickyStrategy) Name() string { return StrategySticky }
+
+// Start returns the key's previous offset if it has one.
+func (s *stickyStrategy) Start(key string, first, last uint64) uint64 {
+	if offset, ok := s.reservations[key]; ok && key != "" && offset >= first && offset <= last {
+		return offset
+	}
+	return s.sequentialStrategy.Start(key, first, last)
+}
+
+// Allocated remembers the key's offset and advances the sequential cursor.
+func (s *stickyStrategy) Allocated(key string, offset uint64) {
+	if key != "" {
+		s.reservations[key] = offset
+	}
+	if offset >= s.next {
+		s.sequentialStrategy.Allocated(key, offset)
+	}
+}
+
+// PoolExhaustedError is returned when a subnet has no free addresses left.
+type PoolExhaustedError struct {
+	// Subnet is the exhausted subnet
+	Subnet *net.IPNet
+	// Allocated is the number of addresses in use, including the gateway
+	Allocated int
+}
+
+// Error implements the error interface.
+func (e *PoolExhaustedError) Error() string {
+	return fmt.Sprintf("no available IP addresses in subnet %s (%d allocated)", e.Subnet, e.Allocated)
+}
+
+// AddressInUseError is returned when a specific address is requested that
+// is already allocated.
+type AddressInUseError struct {
+	// IP is the requested address
+	IP net.IP
+}
+
+// Error implements the error interface.
+func (e *AddressInUseError) Error() string {
+	return fmt.Sprintf("IP %s is already allocated", e.IP)
+}
+
+// IPAllocatorStats describes the utilization of an IP pool.
+type IPAllocatorStats struct {
+	// Strategy is the allocation strategy name
+	Strategy string `json:"strategy"`
+	// Size is the number of allocatable addresses, including the gateway
+	Size int `json:"size"`
+	// Allocated is the number of addresses in use, including the gateway
+	Allocated int `json:"allocated"`
+	// Exhaustions counts allocations that failed because the pool was full
+	Exhaustions uint64 `json:"exhaustions"`
+}
+
 // IPAllocator manages IP address allocation within a network subnet.
 //
 // The allocator tracks allocated IP addresses and provides allocation/deallocation
@@ -24,34 +170,53 @@ type IPAllocator struct {
 	// allocated tracks which IP addresses are currently in use
 	allocated map[string]bool
 
-	// nextIP tracks the next IP to try for allocation (optimization)
-	nextIP net.IP
+	// strategy picks where each allocation starts probing
+	strategy AllocationStrategy
+
+	// first and last bound the allocatable offsets within the subnet
+	first, last uint64
+
+	// exhaustions counts allocations that failed on a full pool
+	exhaustions uint64
 
 	// mutex protects concurrent access to allocation state
 	mutex sync.Mutex
 }
 
-// NewIPAllocator creates a new IP allocator for the given subnet.
+// NewIPAllocator creates a new sequential IP allocator for the given subnet.
 //
 // The allocator will manage IP allocation within the subnet, reserving the
 // gateway address and tracking allocated addresses.
 func NewIPAllocator(subnet *net.IPNet, gateway net.IP) *IPAllocator {
+	return NewIPAllocatorWithStrategy(subnet, gateway, &sequentialStrategy{})
+}
+
+// NewIPAllocatorWithStrategy creates an IP allocator using the given strategy.
+//
+// For IPv4 the network and broadcast addresses are never allocated, and for
+// IPv6 the subnet-router anycast address (the subnet's first address) is not.
+func NewIPAllocatorWithStrategy(subnet *net.IPNet, gateway net.IP, strategy AllocationStrategy) *IPAllocator {
+	ones, bits := subnet.Mask.Size()
+	size := uint64(maxPoolSize)
+	if hostBits := bits - ones; hostBits < 24 {
+		size = 1 << hostBits
+	}
+
 	allocator := &IPAllocator{
 		subnet:    subnet,
 		gateway:   gateway,
 		allocated: make(map[string]bool),
-		nextIP:    make(net.IP, len(subnet.IP)),
+		strategy:  strategy,
+		first:     0,
+		last:      size - 1,
 	}
 
-	// Start allocation from the second usable IP (first is typically gateway)
-	copy(allocator.nextIP, subnet.IP)
-	allocator.nextIP = allocator.nextIP.Mask(subnet.Mask)
-
-	// Increment to first usable IP
-	allocator.incrementIP(allocator.nextIP)
-	// If that's the gateway, increment again
-	if allocator.nextIP.Equal(gateway) {
-		allocator.incrementIP(allocator.nextIP)
+	// Skip the network and broadcast addresses where they exist
+	if subnet.IP.To4() != nil && size >= 4 {
+		allocator.first = 1
+		allocator.last = size - 2
+	} else if subnet.IP.To4() == nil && size >= 2 {
+		allocator.first = 1
 	}
 
 	// Mark gateway as allocated (reserved)
@@ -60,55 +225,44 @@ func NewIPAllocator(subnet *net.IPNet, gateway net.IP) *IPAllocator {
 	return allocator
 }
 
+// Strategy returns the name of the allocator's strategy.
+func (a *IPAllocator) Strategy() string {
+	return a.strategy.Name()
+}
+
 // AllocateIP allocates an available IP address from the subnet.
 //
-// Returns an allocated IP address or an error if no addresses are available.
-// The allocated IP is marked as in-use until released.
+// Returns an allocated IP address or a *PoolExhaustedError if no addresses
+// are available. The allocated IP is marked as in-use until released.
 func (a *IPAllocator) AllocateIP() (net.IP, error) {
+	return a.AllocateIPFor("")
+}
+
+// AllocateIPFor allocates an address on behalf of key.
+//
+// The key lets strategies such as sticky hand the same address back to the
+// same container; it is ignored by strategies that don't use it.
+func (a *IPAllocator) AllocateIPFor(key string) (net.IP, error) {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
-	// Calculate maximum IPs in subnet to prevent infinite loop
-	ones, bits := a.subnet.Mask.Size()
-	maxIPs := 1 << (bits - ones)
-
-	// Track attempts to detect when we've checked all IPs
-	attempts := 0
-
-	// Try to find an available IP starting from nextIP
-	for attempts < maxIPs {
-		ipStr := a.nextIP.String()
+	count := a.last - a.first + 1
+	start := a.strategy.Start(key, a.first, a.last)
 
-		// Check if this IP is available
-		if !a.allocated[ipStr] && a.subnet.Contains(a.nextIP) {
-			// Found available IP
-			allocatedIP := make(net.IP, len(a.nextIP))
-			copy(allocatedIP, a.nextIP)
-
-			// Mark as allocated
-			a.allocated[ipStr] = true
-
-			// Advance nextIP for next allocation
-			a.incrementIP(a.nextIP)
-
-			return allocatedIP, nil
+	for i := uint64(0); i < count; i++ {
+		offset := a.first + (start-a.first+i)%count
+		ip := a.ipAt(offset)
+		if a.allocated[ip.String()] {
+			continue
 		}
 
-		// Move to next IP
-		a.incrementIP(a.nextIP)
-		attempts++
-
-		// Check if we've gone outside the subnet
-		if !a.subnet.Contains(a.nextIP) {
-			// Wrap to beginning of subnet
-			copy(a.nextIP, a.subnet.IP)
-			a.nextIP = a.nextIP.Mask(a.subnet.Mask)
-			a.incrementIP(a.nextIP)
-		}
+		a.allocated[ip.String()] = true
+		a.strategy.Allocated(key, offset)
+		return ip, nil
 	}
 
-	// Exhausted all IPs in subnet
-	return nil, fmt.Errorf("no available IP addresses in subnet %s", a.subnet)
+	a.exhaustions++
+	return nil, &PoolExhaustedError{Subnet: a.subnet, Allocated: len(a.allocated)}
 }
 
 // AllocateSpecificIP allocates a specific IP address if available.
@@ -116,6 +270,15 @@ func (a *IPAllocator) AllocateIP() (net.IP, error) {
 // Returns an error if the IP is already allocated or outside the subnet.
 // This is useful when Docker requests a specific IP address.
 func (a *IPAllocator) AllocateSpecificIP(ip net.IP) error {
+	return a.Reserve(ip)
+}
+
+// Reserve marks a specific address as in use.
+//
+// Unlike AllocateIPFor it bypasses the strategy, and it refuses addresses that
+// are outside the subnet or already in use so callers learn about conflicts
+// instead of silently sharing an address.
+func (a *IPAllocator) Reserve(ip net.IP) error {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
@@ -128,7 +291,7 @@ func (a *IPAllocator) AllocateSpecificIP(ip net.IP) error {
 
 	// Check if IP is already allocated
 	if a.allocated[ipStr] {
-		return fmt.Errorf("IP %s is already allocated", ip)
+		return &AddressInUseError{IP: ip}
 	}
 
 	// Allocate the IP
@@ -137,23 +300,67 @@ func (a *IPAllocator) AllocateSpecificIP(ip net.IP) error {
 	return nil
 }
 
-// ReleaseIP releases a previously allocated IP address.
+// SetGateway moves the reserved gateway address to ip.
 //
-// The IP address becomes available for future allocation. It's safe to call
-// this method with an IP that wasn't allocated or is already released.
-func (a *IPAllocator) ReleaseIP(ip net.IP) {
+// Docker picks the gateway of a pool after the pool exists, so the IPAM
+// driver starts with the default gateway and moves it here when another one
+// is requested. The new gateway must be inside the subnet and not in use.
+func (a *IPAllocator) SetGateway(ip net.IP) error {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
-	ipStr := ip.String()
+	if ip.Equal(a.gateway) {
+		return nil
+	}
+	if !a.subnet.Contains(ip) {
+		return fmt.Errorf("gateway %s is outside subnet %s", ip, a.subnet)
+	}
+	if a.allocated[ip.String()] {
+		return fmt.Errorf("gateway %s is already allocated", ip)
+	}
+
+	delete(a.allocated, a.gateway.String())
+	a.gateway = ip
+	a.allocated[ip.String()] = true
+	return nil
+}
+
+// Gateway returns the reserved gateway address.
+func (a *IPAllocator) Gateway() net.IP {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.gateway
+}
+
+// Free returns an allocated address to the pool.
+//
+// It is the strict counterpart of ReleaseIP: freeing the gateway or an
+// address that is not allocated is reported as an error, which surfaces
+// double frees in endpoint bookkeeping.
+func (a *IPAllocator) Free(ip net.IP) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
 
-	// Don't release the gateway IP
 	if ip.Equal(a.gateway) {
-		return
+		return fmt.Errorf("cannot free gateway IP %s", ip)
+	}
+
+	ipStr := ip.String()
+	if !a.allocated[ipStr] {
+		return fmt.Errorf("IP %s is not allocated", ip)
 	}
 
-	// Release the IP
 	delete(a.allocated, ipStr)
+	return nil
+}
+
+// ReleaseIP releases a previously allocated IP address.
+//
+// The IP address becomes available for future allocation. It's safe to call
+// this method with an IP that wasn't allocated or is already released.
+func (a *IPAllocator) ReleaseIP(ip net.IP) {
+	_ = a.Free(ip)
 }
 
 // IsAllocated checks if an IP address is currently allocated.
@@ -190,19 +397,7 @@ func (a *IPAllocator) GetAvailableCount() int {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
-	// Calculate total IPs in subnet
-	ones, bits := a.subnet.Mask.Size()
-	totalIPs := 1 << (bits - ones)
-
-	// Subtract network and broadcast addresses for IPv4
-	if len(a.subnet.IP) == 4 { // IPv4
-		totalIPs -= 2 // network and broadcast
-	}
-
-	// Subtract allocated IPs
-	allocated := len(a.allocated)
-
-	available := totalIPs - allocated
+	available := int(a.last-a.first+1) - len(a.allocated)
 	if available < 0 {
 		available = 0
 	}
@@ -210,15 +405,98 @@ func (a *IPAllocator) GetAvailableCount() int {
 	return available
 }
 
-// incrementIP increments an IP address by 1.
+// Stats returns a snapshot of pool utilization.
+func (a *IPAllocator) Stats() IPAllocatorStats {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return IPAllocatorStats{
+		Strategy:    a.strategy.Name(),
+		Size:        int(a.last - a.first + 1),
+		Allocated:   len(a.allocated),
+		Exhaustions: a.exhaustions,
+	}
+}
+
+// ipAt returns the address at offset from the subnet's network address.
+func (a *IPAllocator) ipAt(offset uint64) net.IP {
+	base := a.subnet.IP.Mask(a.subnet.Mask)
+
+	value := new(big.Int).SetBytes(base)
+	value.Add(value, new(big.Int).SetUint64(offset))
+
+	ip := make(net.IP, len(base))
+	value.FillBytes(ip)
+	return ip
+}
+
+// parseAllocationStrategy returns the strategy selected by network options.
+//
+// Configuration options:
+//   - i2p.ipam.strategy: sequential (default), random or sticky
+func parseAllocationStrategy(options map[string]interface{}) (AllocationStrategy, error) {
+	name, _ := options["i2p.ipam.strategy"].(string)
+	return NewAllocationStrategy(name)
+}
+
+// getStickyKey returns the key used by the sticky strategy for an endpoint.
+//
+// Docker does not pass the container ID to CreateEndpoint, so containers that
+// should keep their address set the i2p.ipam.sticky_key endpoint option (for
+// example to the container or Compose service name).
+func getStickyKey(options map[string]interface{}) string {
+	key, _ := options["i2p.ipam.sticky_key"].(string)
+	return strings.TrimSpace(key)
+}
+
+// Endpoint options requesting an endpoint's addresses.
+const (
+	// RequestedIPv4Option requests the IPv4 address of an endpoint
+	RequestedIPv4Option = "i2p.ipam.ipv4_address"
+	// RequestedIPv6Option requests the IPv6 address of an endpoint
+	RequestedIPv6Option = "i2p.ipam.ipv6_address"
+)
+
+// requestedAddresses returns the addresses endpoint or Join options request,
+// nil where they request none.
 //
-// This handles both IPv4 and IPv6 addresses, modifying the IP in-place.
-// The increment wraps at the maximum value for each byte.
-func (a *IPAllocator) incrementIP(ip net.IP) {
-	for i := len(ip) - 1; i >= 0; i-- {
-		ip[i]++
-		if ip[i] != 0 {
-			break
+// They let docker network connect --driver-opt, Compose driver_opts and
+// container labels ask for an address where Docker's IPAM configuration
+// (--ip, --ip6) does not reach the plugin.
+func requestedAddresses(options map[string]interface{}) (ipv4, ipv6 net.IP, err error) {
+	parse := func(option string, wantIPv4 bool) (net.IP, error) {
+		value, _ := options[option].(string)
+		if value = strings.TrimSpace(value); value == "" {
+			return nil, nil
+		}
+		ip := net.ParseIP(value)
+		if ip == nil || (ip.To4() != nil) != wantIPv4 {
+			return nil, fmt.Errorf("invalid %s value %q", option, value)
 		}
+		if wantIPv4 {
+			ip = ip.To4()
+		}
+		return ip, nil
+	}
+
+	if ipv4, err = parse(RequestedIPv4Option, true); err != nil {
+		return nil, nil, err
+	}
+	if ipv6, err = parse(RequestedIPv6Option, false); err != nil {
+		return nil, nil, err
+	}
+	return ipv4, ipv6, nil
+}
+
+// preferredAddress returns the address an endpoint asks for: the one Docker
+// assigned, or else the one its options request. Docker does not let drivers
+// replace an address it assigned, so options requesting another one fail.
+func preferredAddress(assigned, requested net.IP) (net.IP, error) {
+	if assigned == nil {
+		return requested, nil
+	}
+	if requested != nil && !requested.Equal(assigned) {
+		return nil, fmt.Errorf("requested address %s does not match the endpoint's address %s; request it with --ip or --ip6 instead", requested, assigned)
 	}
+	return assigned, nil
 }
@@ -0,0 +1,257 @@
+package plugin
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestAllocator(t *testing.T, cidr, strategyName string) *IPAllocator {
+	t.Helper()
+
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		t.Fatalf("Failed to parse subnet: %v", err)
+	}
+	strategy, err := NewAllocationStrategy(strategyName)
+	if err != nil {
+		t.Fatalf("Failed to create strategy: %v", err)
+	}
+	return NewIPAllocatorWithStrategy(subnet, calculateDefaultGateway(subnet), strategy)
+}
+
+func TestIPAllocatorSequential(t *testing.T) {
+	allocator := newTestAllocator(t, "10.0.0.0/29", StrategySequential)
+
+	// .0 is the network address, .1 the gateway and .7 the broadcast address
+	var got []string
+	for i := 0; i < 5; i++ {
+		ip, err := allocator.AllocateIP()
+		if err != nil {
+			t.Fatalf("Allocation %d failed: %v", i+1, err)
+		}
+		got = append(got, ip.String())
+	}
+
+	expected := []string{"10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Allocation %d: expected %s, got %s", i+1, expected[i], got[i])
+		}
+	}
+
+	// Released addresses are reused only after the cursor wraps around
+	allocator.ReleaseIP(net.ParseIP("10.0.0.3"))
+	ip, err := allocator.AllocateIP()
+	if err != nil || ip.String() != "10.0.0.3" {
+		t.Errorf("Expected wrap-around to 10.0.0.3, got %v (%v)", ip, err)
+	}
+}
+
+func TestIPAllocatorIPv6(t *testing.T) {
+	allocator := newTestAllocator(t, "fd00:1::/64", StrategySequential)
+
+	// fd00:1:: is the subnet-router anycast address and fd00:1::1 the gateway
+	for _, expected := range []string{"fd00:1::2", "fd00:1::3"} {
+		ip, err := allocator.AllocateIP()
+		if err != nil || ip.String() != expected {
+			t.Fatalf("Expected %s, got %v (%v)", expected, ip, err)
+		}
+	}
+	if allocator.IsAllocated(net.ParseIP("fd00:1::")) {
+		t.Error("Expected the subnet-router anycast address not to be allocated")
+	}
+
+	// Large subnets are capped rather than enumerated
+	if stats := allocator.Stats(); stats.Size != maxPoolSize-1 {
+		t.Errorf("Expected pool size %d, got %d", maxPoolSize-1, stats.Size)
+	}
+
+	if err := allocator.Reserve(net.ParseIP("fd00:1::ffff:1")); err != nil {
+		t.Errorf("Failed to reserve an IPv6 address: %v", err)
+	}
+	if err := allocator.Reserve(net.ParseIP("fd00:2::5")); err == nil {
+		t.Error("Expected reserving an address outside the subnet to fail")
+	}
+
+	if mac := generateMACAddress(net.ParseIP("fd00:1::a:b0c")); mac != "02:42:00:0a:0b:0c" {
+		t.Errorf("Expected a MAC address derived from the IPv6 address, got %s", mac)
+	}
+}
+
+func TestIPAllocatorExhaustion(t *testing.T) {
+	allocator := newTestAllocator(t, "10.0.0.0/30", StrategySequential)
+
+	// A /30 has two usable addresses, one of which is the gateway
+	if _, err := allocator.AllocateIP(); err != nil {
+		t.Fatalf("First allocation failed: %v", err)
+	}
+
+	_, err := allocator.AllocateIP()
+	var exhausted *PoolExhaustedError
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("Expected PoolExhaustedError, got %v", err)
+	}
+	if exhausted.Subnet.String() != "10.0.0.0/30" || exhausted.Allocated != 2 {
+		t.Errorf("Unexpected error details: %+v", exhausted)
+	}
+
+	stats := allocator.Stats()
+	if stats.Size != 2 || stats.Allocated != 2 || stats.Exhaustions != 1 || stats.Strategy != StrategySequential {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if allocator.GetAvailableCount() != 0 {
+		t.Errorf("Expected no available addresses, got %d", allocator.GetAvailableCount())
+	}
+}
+
+func TestIPAllocatorRandom(t *testing.T) {
+	allocator := newTestAllocator(t, "10.0.0.0/28", StrategyRandom)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 13; i++ {
+		ip, err := allocator.AllocateIP()
+		if err != nil {
+			t.Fatalf("Allocation %d failed: %v", i+1, err)
+		}
+		if seen[ip.String()] {
+			t.Fatalf("Address %s allocated twice", ip)
+		}
+		if ip.String() == "10.0.0.0" || ip.String() == "10.0.0.1" || ip.String() == "10.0.0.15" {
+			t.Fatalf("Allocated reserved address %s", ip)
+		}
+		seen[ip.String()] = true
+	}
+
+	if _, err := allocator.AllocateIP(); err == nil {
+		t.Error("Expected pool to be exhausted")
+	}
+}
+
+func TestIPAllocatorSticky(t *testing.T) {
+	allocator := newTestAllocator(t, "10.0.0.0/24", StrategySticky)
+
+	web, _ := allocator.AllocateIPFor("web")
+	db, _ := allocator.AllocateIPFor("db")
+	allocator.ReleaseIP(web)
+	allocator.ReleaseIP(db)
+
+	// Another container allocates in the meantime
+	other, _ := allocator.AllocateIPFor("cache")
+	if other.Equal(web) || other.Equal(db) {
+		t.Errorf("Expected new key to avoid recently used addresses, got %s", other)
+	}
+
+	again, err := allocator.AllocateIPFor("web")
+	if err != nil || !again.Equal(web) {
+		t.Errorf("Expected web to get %s again, got %v (%v)", web, again, err)
+	}
+
+	// A taken address falls back to the next free one
+	allocator.ReleaseIP(again)
+	if _, err := allocator.AllocateIPFor("intruder"); err != nil {
+		t.Fatalf("Allocation failed: %v", err)
+	}
+	if err := allocator.AllocateSpecificIP(web); err != nil {
+		t.Fatalf("Failed to take web's address: %v", err)
+	}
+	fallback, err := allocator.AllocateIPFor("web")
+	if err != nil || fallback.Equal(web) {
+		t.Errorf("Expected fallback address other than %s, got %v (%v)", web, fallback, err)
+	}
+}
+
+func TestIPAllocatorReserveFree(t *testing.T) {
+	allocator := newTestAllocator(t, "10.0.0.0/24", StrategySequential)
+	ip := net.ParseIP("10.0.0.42")
+
+	if err := allocator.Reserve(ip); err != nil {
+		t.Fatalf("Failed to reserve %s: %v", ip, err)
+	}
+	if err := allocator.Reserve(ip); err == nil {
+		t.Error("Expected reserving an allocated address to fail")
+	}
+	if err := allocator.Reserve(net.ParseIP("10.0.1.1")); err == nil {
+		t.Error("Expected reserving an address outside the subnet to fail")
+	}
+
+	if err := allocator.Free(ip); err != nil {
+		t.Fatalf("Failed to free %s: %v", ip, err)
+	}
+	if err := allocator.Free(ip); err == nil {
+		t.Error("Expected double free to fail")
+	}
+	if err := allocator.Free(net.ParseIP("10.0.0.1")); err == nil {
+		t.Error("Expected freeing the gateway to fail")
+	}
+	if !allocator.IsAllocated(net.ParseIP("10.0.0.1")) {
+		t.Error("Expected gateway to stay allocated")
+	}
+}
+
+func TestIPAllocatorSetGateway(t *testing.T) {
+	allocator := newTestAllocator(t, "10.0.0.0/24", StrategySequential)
+	taken := net.ParseIP("10.0.0.7")
+	if err := allocator.Reserve(taken); err != nil {
+		t.Fatalf("Failed to reserve %s: %v", taken, err)
+	}
+
+	if err := allocator.SetGateway(net.ParseIP("10.0.0.254")); err != nil {
+		t.Fatalf("Failed to move gateway: %v", err)
+	}
+	if !allocator.Gateway().Equal(net.ParseIP("10.0.0.254")) || allocator.IsAllocated(net.ParseIP("10.0.0.1")) {
+		t.Errorf("Expected the gateway to move to 10.0.0.254 and free 10.0.0.1, got %s", allocator.Gateway())
+	}
+	if stats := allocator.Stats(); stats.Allocated != 2 {
+		t.Errorf("Expected the gateway and one address allocated, got %d", stats.Allocated)
+	}
+
+	for _, invalid := range []net.IP{taken, net.ParseIP("10.0.1.1")} {
+		if err := allocator.SetGateway(invalid); err == nil {
+			t.Errorf("Expected moving the gateway to %s to fail", invalid)
+		}
+	}
+}
+
+func TestNewAllocationStrategy(t *testing.T) {
+	for _, name := range []string{"", "sequential", "Random", " sticky "} {
+		if _, err := NewAllocationStrategy(name); err != nil {
+			t.Errorf("Unexpected error for %q: %v", name, err)
+		}
+	}
+	if _, err := NewAllocationStrategy("round-robin"); err == nil {
+		t.Error("Expected error for unknown strategy")
+	}
+
+	if _, err := parseAllocationStrategy(map[string]interface{}{"i2p.ipam.strategy": "bogus"}); err == nil {
+		t.Error("Expected error for unknown strategy option")
+	}
+	strategy, err := parseAllocationStrategy(nil)
+	if err != nil || strategy.Name() != StrategySequential {
+		t.Errorf("Expected sequential default, got %v (%v)", strategy, err)
+	}
+
+	if key := getStickyKey(map[string]interface{}{"i2p.ipam.sticky_key": " web "}); key != "web" {
+		t.Errorf("Expected sticky key web, got %q", key)
+	}
+}
+
+func TestIPAMMetrics(t *testing.T) {
+	_, mux := newAdminTestPlugin(t)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	for _, expected := range []string{
+		`i2p_ipam_addresses_allocated{network="net1",name="i2p-test",tenant="",strategy="sequential"} 1`,
+		`i2p_ipam_addresses_available{network="net1",name="i2p-test",tenant="",strategy="sequential"} 253`,
+		`i2p_ipam_exhausted_total{network="net1",name="i2p-test",tenant="",strategy="sequential"} 0`,
+	} {
+		if !strings.Contains(rec.Body.String(), expected) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", expected, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,480 @@
+// Package plugin provides the plugin's Docker IPAM driver.
+//
+// Docker normally assigns subnets and container addresses with its built-in
+// IPAM driver and only tells the network driver the result. Networks created
+// with --ipam-driver i2p have their address pools managed by the plugin
+// instead: pools are taken from --subnet or allocated from the plugin's
+// default subnet, checked against the subnets of other I2P networks and pools
+// and the DNS synthetic range, and addresses are handed out by the same
+// IPAllocator and strategies the network driver uses (--ipam-opt
+// i2p.ipam.strategy=sticky).
+//
+// A network created on such a pool shares the pool's allocator, so the address
+// Docker requests for an endpoint is the address the endpoint gets. Those
+// addresses belong to Docker until it calls ReleaseAddress: endpoints keep
+// them while they are parked, and networks restored after a plugin restart
+// bring their pools back with them.
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+)
+
+const (
+	// IPAMAddressSpace is the address space of the IPAM driver's pools
+	IPAMAddressSpace = "i2p"
+	// requestAddressTypeOption is the RequestAddress option Docker uses to
+	// say what an address is for
+	requestAddressTypeOption = "RequestAddressType"
+	// gatewayAddressType marks Docker's request for a pool's gateway
+	gatewayAddressType = "com.docker.network.gateway"
+)
+
+// ipamPool is an address pool handed out by the IPAM driver.
+type ipamPool struct {
+	// id identifies the pool in Docker's requests
+	id string
+	// subnet is the pool's address range
+	subnet *net.IPNet
+	// allocator hands out the pool's addresses; it is shared with the
+	// network created on the pool
+	allocator *IPAllocator
+}
+
+// ipamPoolID returns the ID of the pool for subnet.
+func ipamPoolID(subnet *net.IPNet) string {
+	return IPAMAddressSpace + "/" + subnet.String()
+}
+
+// ipamOptions converts IPAM driver options to the form network options are
+// parsed from.
+func ipamOptions(options map[string]string) map[string]interface{} {
+	converted := make(map[string]interface{}, len(options))
+	for key, value := range options {
+		converted[key] = value
+	}
+	return converted
+}
+
+// parseAddress parses an address with or without a prefix length, as Docker
+// passes addresses in CIDR notation.
+func parseAddress(address string) net.IP {
+	if ip, _, err := net.ParseCIDR(address); err == nil {
+		return ip
+	}
+	return net.ParseIP(address)
+}
+
+// RequestPool creates an address pool and returns its ID and subnet.
+//
+// A requested pool must not overlap the subnets of other networks or pools;
+// without one a free /24 is allocated from the default subnet, like for
+// networks created without IPAM data. IPv6 pools must be requested
+// explicitly, and address ranges within a pool (--ip-range) are not
+// supported.
+func (nm *NetworkManager) RequestPool(req RequestPoolRequest) (string, *net.IPNet, error) {
+	if req.AddressSpace != "" && req.AddressSpace != IPAMAddressSpace {
+		return "", nil, fmt.Errorf("unknown address space %q", req.AddressSpace)
+	}
+	if req.SubPool != "" {
+		return "", nil, fmt.Errorf("address ranges within a pool are not supported")
+	}
+	strategy, err := parseAllocationStrategy(ipamOptions(req.Options))
+	if err != nil {
+		return "", nil, err
+	}
+
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	var subnet *net.IPNet
+	switch {
+	case req.Pool != "":
+		if _, subnet, err = net.ParseCIDR(req.Pool); err != nil {
+			return "", nil, fmt.Errorf("invalid pool %s: %w", req.Pool, err)
+		}
+		if (subnet.IP.To4() == nil) != req.V6 {
+			return "", nil, fmt.Errorf("pool %s does not match the requested address family", req.Pool)
+		}
+		if err := nm.checkSubnetConflicts(subnet); err != nil {
+			return "", nil, err
+		}
+	case req.V6:
+		return "", nil, fmt.Errorf("IPv6 pools must be requested with a subnet")
+	default:
+		if subnet, err = nm.nextDefaultSubnetLocked(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	pool := &ipamPool{
+		id:        ipamPoolID(subnet),
+		subnet:    subnet,
+		allocator: NewIPAllocatorWithStrategy(subnet, calculateDefaultGateway(subnet), strategy),
+	}
+	nm.ipamPools[pool.id] = pool
+
+	log.Printf("Allocated IPAM pool %s (%s IP allocation)", pool.id, strategy.Name())
+	return pool.id, subnet, nil
+}
+
+// ReleasePool removes an address pool. Docker releases a pool after deleting
+// the network created on it.
+func (nm *NetworkManager) ReleasePool(poolID string) error {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if _, exists := nm.ipamPools[poolID]; !exists {
+		return fmt.Errorf("IPAM pool %s not found", poolID)
+	}
+	delete(nm.ipamPools, poolID)
+
+	log.Printf("Released IPAM pool %s", poolID)
+	return nil
+}
+
+// RequestAddress allocates an address from a pool, or the given address if
+// address is not nil. A gateway request moves the pool's gateway to the
+// given address, or returns the default gateway without one.
+func (nm *NetworkManager) RequestAddress(poolID string, address net.IP, options map[string]string) (*net.IPNet, error) {
+	pool, err := nm.ipamPool(poolID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ip net.IP
+	switch {
+	case options[requestAddressTypeOption] == gatewayAddressType && address == nil:
+		ip = pool.allocator.Gateway()
+	case options[requestAddressTypeOption] == gatewayAddressType:
+		if err := pool.allocator.SetGateway(address); err != nil {
+			return nil, err
+		}
+		ip = address
+	case address != nil:
+		if err := pool.allocator.Reserve(address); err != nil {
+			return nil, nm.poolAddressError(poolID, pool.allocator, address, err)
+		}
+		ip = address
+	default:
+		if ip, err = pool.allocator.AllocateIPFor(getStickyKey(ipamOptions(options))); err != nil {
+			return nil, err
+		}
+	}
+
+	return &net.IPNet{IP: ip, Mask: pool.subnet.Mask}, nil
+}
+
+// ReleaseAddress returns an address to its pool. The gateway is released with
+// the pool itself.
+func (nm *NetworkManager) ReleaseAddress(poolID string, address net.IP) error {
+	pool, err := nm.ipamPool(poolID)
+	if err != nil {
+		return err
+	}
+	if address.Equal(pool.allocator.Gateway()) {
+		return nil
+	}
+	return pool.allocator.Free(address)
+}
+
+// ipamPool returns the pool with the given ID.
+func (nm *NetworkManager) ipamPool(poolID string) (*ipamPool, error) {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	pool, exists := nm.ipamPools[poolID]
+	if !exists {
+		return nil, fmt.Errorf("IPAM pool %s not found", poolID)
+	}
+	return pool, nil
+}
+
+// ipamPoolLocked returns the IPAM driver pool a network is created on, or nil
+// if its IPAM data comes from another IPAM driver. The caller must hold
+// nm.mutex.
+func (nm *NetworkManager) ipamPoolLocked(ipamData []IPAMData) *ipamPool {
+	for _, data := range ipamData {
+		if data.AddressSpace != IPAMAddressSpace || data.Pool == "" {
+			continue
+		}
+		if _, subnet, err := net.ParseCIDR(data.Pool); err == nil {
+			return nm.ipamPools[ipamPoolID(subnet)]
+		}
+	}
+	return nil
+}
+
+// restoreIPAMPoolLocked creates the IPAM driver pool of a saved network
+// again. The caller must hold nm.mutex.
+func (nm *NetworkManager) restoreIPAMPoolLocked(record networkRecord) error {
+	return nm.restorePoolLocked(record.IPAMPool, record.Subnet, record.Gateway, record.IPAMStrategy)
+}
+
+// restorePoolLocked creates a saved IPAM driver pool again from its saved
+// subnet, gateway and strategy. The caller must hold nm.mutex.
+func (nm *NetworkManager) restorePoolLocked(poolID, subnetText, gatewayText, strategyName string) error {
+	_, subnet, err := net.ParseCIDR(subnetText)
+	if err != nil {
+		return fmt.Errorf("invalid subnet %q: %w", subnetText, err)
+	}
+	gateway := net.ParseIP(gatewayText)
+	if gateway == nil {
+		return fmt.Errorf("invalid gateway %q", gatewayText)
+	}
+	if ipamPoolID(subnet) != poolID {
+		return fmt.Errorf("IPAM pool %s does not match subnet %s", poolID, subnet)
+	}
+	strategy, err := NewAllocationStrategy(strategyName)
+	if err != nil {
+		return err
+	}
+
+	nm.ipamPools[poolID] = &ipamPool{
+		id:        poolID,
+		subnet:    subnet,
+		allocator: NewIPAllocatorWithStrategy(subnet, gateway, strategy),
+	}
+	return nil
+}
+
+// endpointAddressLocked returns the address of a new endpoint: the address
+// Docker requested from the network's IPAM driver pool, or on other networks
+// the address Docker passed reserved as is, or else one allocated for key. The
+// caller must hold the network's mutex.
+func (nm *NetworkManager) endpointAddressLocked(network *I2PNetwork, key string, address net.IP) (net.IP, error) {
+	if network.IPAMPool == "" {
+		if address != nil {
+			return address, reserveRequestedAddress(network, network.IPAllocator, address)
+		}
+		ip, err := network.IPAllocator.AllocateIPFor(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to allocate IP address: %w", err)
+		}
+		return ip, nil
+	}
+
+	if address == nil {
+		return nil, fmt.Errorf("network %s has no address from IPAM pool %s for the endpoint", network.ID, network.IPAMPool)
+	}
+	if !network.IPAllocator.IsAllocated(address) {
+		return nil, fmt.Errorf("address %s was not requested from IPAM pool %s", address, network.IPAMPool)
+	}
+	return address, nil
+}
+
+// endpointIPv6AddressLocked returns the IPv6 address of a new endpoint the way
+// endpointAddressLocked returns its IPv4 address. It returns nil on networks
+// without an IPv6 subnet. The caller must hold the network's mutex.
+func (nm *NetworkManager) endpointIPv6AddressLocked(network *I2PNetwork, key string, address net.IP) (net.IP, error) {
+	if network.IPv6Allocator == nil {
+		if address != nil {
+			return nil, fmt.Errorf("network %s has no IPv6 subnet for address %s", network.ID, address)
+		}
+		return nil, nil
+	}
+
+	if network.IPv6Pool == "" {
+		if address != nil {
+			return address, reserveRequestedAddress(network, network.IPv6Allocator, address)
+		}
+		ip, err := network.IPv6Allocator.AllocateIPFor(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to allocate IPv6 address: %w", err)
+		}
+		return ip, nil
+	}
+
+	if address == nil {
+		return nil, fmt.Errorf("network %s has no address from IPAM pool %s for the endpoint", network.ID, network.IPv6Pool)
+	}
+	if !network.IPv6Allocator.IsAllocated(address) {
+		return nil, fmt.Errorf("address %s was not requested from IPAM pool %s", address, network.IPv6Pool)
+	}
+	return address, nil
+}
+
+// releaseIPv6AddressLocked gives an endpoint's IPv6 address back to the
+// network; addresses of IPAM driver pools stay with the endpoint until Docker
+// releases them. The caller must hold the network's mutex.
+func (nm *NetworkManager) releaseIPv6AddressLocked(network *I2PNetwork, endpoint *I2PEndpoint) {
+	if endpoint.IPv6Address == nil || network.IPv6Pool != "" {
+		return
+	}
+	if err := network.IPv6Allocator.Free(endpoint.IPv6Address); err != nil {
+		log.Printf("Warning: Failed to free IPv6 address of endpoint %s: %v", endpoint.ID, err)
+	}
+	endpoint.IPv6Address = nil
+}
+
+// reserveRequestedAddress reserves an address Docker requested for an
+// endpoint, e.g. with docker run --ip or --ip6, in one of the network's
+// allocators.
+func reserveRequestedAddress(network *I2PNetwork, allocator *IPAllocator, address net.IP) error {
+	if err := allocator.Reserve(address); err != nil {
+		return requestedAddressError(network, allocator, address, err)
+	}
+	return nil
+}
+
+// requestedAddressError explains why a requested address could not be
+// reserved in an allocator of network, which is nil for IPAM pools no
+// network uses yet.
+//
+// Conflicts name the endpoint and container holding the address, so the
+// error Docker shows tells the user which container to look at.
+func requestedAddressError(network *I2PNetwork, allocator *IPAllocator, address net.IP, err error) error {
+	var inUse *AddressInUseError
+	if !errors.As(err, &inUse) {
+		return fmt.Errorf("requested address %s cannot be reserved: %w", address, err)
+	}
+	if network == nil {
+		return fmt.Errorf("requested address %s: %w", address, err)
+	}
+
+	if address.Equal(allocator.Gateway()) {
+		return fmt.Errorf("requested address %s is the gateway of network %s: %w", address, network.ID, err)
+	}
+	for _, endpoint := range network.Endpoints {
+		if !endpoint.IPAddress.Equal(address) && !endpoint.IPv6Address.Equal(address) {
+			continue
+		}
+		if endpoint.ContainerID != "" {
+			return fmt.Errorf("requested address %s is in use by endpoint %s of container %s on network %s: %w",
+				address, endpoint.ID, endpoint.ContainerID, network.ID, err)
+		}
+		return fmt.Errorf("requested address %s is in use by endpoint %s on network %s: %w", address, endpoint.ID, network.ID, err)
+	}
+	return fmt.Errorf("requested address %s on network %s: %w", address, network.ID, err)
+}
+
+// poolAddressError explains why an address requested from an IPAM pool could
+// not be reserved, naming its holder on the network using the pool.
+func (nm *NetworkManager) poolAddressError(poolID string, allocator *IPAllocator, address net.IP, err error) error {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	for _, network := range nm.networks {
+		if network.IPAMPool == poolID || network.IPv6Pool == poolID {
+			network.mutex.RLock()
+			defer network.mutex.RUnlock()
+			return requestedAddressError(network, allocator, address, err)
+		}
+	}
+	return requestedAddressError(nil, allocator, address, err)
+}
+
+// handleIPAMCapabilities reports the IPAM driver's capabilities.
+func (p *Plugin) handleIPAMCapabilities(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received IpamDriver.GetCapabilities request")
+	p.writeJSONResponse(w, IPAMCapabilitiesResponse{})
+}
+
+// handleDefaultAddressSpaces returns the IPAM driver's address spaces.
+func (p *Plugin) handleDefaultAddressSpaces(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received IpamDriver.GetDefaultAddressSpaces request")
+	p.writeJSONResponse(w, AddressSpacesResponse{
+		LocalDefaultAddressSpace:  IPAMAddressSpace,
+		GlobalDefaultAddressSpace: IPAMAddressSpace,
+	})
+}
+
+// handleRequestPool allocates an address pool for a network.
+func (p *Plugin) handleRequestPool(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received IpamDriver.RequestPool request")
+
+	var req RequestPoolRequest
+	if err := p.readJSONRequest(r, &req); err != nil {
+		log.Printf("Error parsing RequestPool request: %v", err)
+		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
+		return
+	}
+
+	poolID, subnet, err := p.networkMgr.RequestPool(req)
+	if err != nil {
+		log.Printf("Error allocating IPAM pool %s: %v", req.Pool, err)
+		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
+		return
+	}
+
+	p.writeJSONResponse(w, RequestPoolResponse{PoolID: poolID, Pool: subnet.String()})
+}
+
+// handleReleasePool releases an address pool.
+func (p *Plugin) handleReleasePool(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received IpamDriver.ReleasePool request")
+
+	var req ReleasePoolRequest
+	if err := p.readJSONRequest(r, &req); err != nil {
+		log.Printf("Error parsing ReleasePool request: %v", err)
+		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
+		return
+	}
+
+	if err := p.networkMgr.ReleasePool(req.PoolID); err != nil {
+		log.Printf("Error releasing IPAM pool %s: %v", req.PoolID, err)
+		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
+		return
+	}
+
+	p.writeJSONResponse(w, ErrorResponse{Err: ""})
+}
+
+// handleRequestAddress allocates an address from a pool.
+func (p *Plugin) handleRequestAddress(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received IpamDriver.RequestAddress request")
+
+	var req RequestAddressRequest
+	if err := p.readJSONRequest(r, &req); err != nil {
+		log.Printf("Error parsing RequestAddress request: %v", err)
+		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
+		return
+	}
+
+	var address net.IP
+	if req.Address != "" {
+		if address = parseAddress(req.Address); address == nil {
+			p.writeJSONResponse(w, ErrorResponse{Err: fmt.Sprintf("invalid address %q", req.Address)})
+			return
+		}
+	}
+
+	allocated, err := p.networkMgr.RequestAddress(req.PoolID, address, req.Options)
+	if err != nil {
+		log.Printf("Error allocating an address from IPAM pool %s: %v", req.PoolID, err)
+		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
+		return
+	}
+
+	p.writeJSONResponse(w, RequestAddressResponse{Address: allocated.String()})
+}
+
+// handleReleaseAddress returns an address to its pool.
+func (p *Plugin) handleReleaseAddress(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received IpamDriver.ReleaseAddress request")
+
+	var req ReleaseAddressRequest
+	if err := p.readJSONRequest(r, &req); err != nil {
+		log.Printf("Error parsing ReleaseAddress request: %v", err)
+		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
+		return
+	}
+
+	address := parseAddress(req.Address)
+	if address == nil {
+		p.writeJSONResponse(w, ErrorResponse{Err: fmt.Sprintf("invalid address %q", req.Address)})
+		return
+	}
+
+	if err := p.networkMgr.ReleaseAddress(req.PoolID, address); err != nil {
+		log.Printf("Error releasing address %s of IPAM pool %s: %v", req.Address, req.PoolID, err)
+		p.writeJSONResponse(w, ErrorResponse{Err: err.Error()})
+		return
+	}
+
+	p.writeJSONResponse(w, ErrorResponse{Err: ""})
+}
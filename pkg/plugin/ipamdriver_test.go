@@ -0,0 +1,256 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIPAMDriverPools(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	poolID, subnet, err := nm.RequestPool(RequestPoolRequest{AddressSpace: IPAMAddressSpace})
+	if err != nil {
+		t.Fatalf("Failed to request default pool: %v", err)
+	}
+	if poolID != "i2p/172.20.1.0/24" || subnet.String() != "172.20.1.0/24" {
+		t.Errorf("Expected pool i2p/172.20.1.0/24, got %s (%s)", poolID, subnet)
+	}
+
+	// Default pools skip allocated ones, and other networks cannot overlap them
+	if _, subnet, err := nm.RequestPool(RequestPoolRequest{}); err != nil || subnet.String() != "172.20.2.0/24" {
+		t.Errorf("Expected second pool 172.20.2.0/24, got %v (%v)", subnet, err)
+	}
+	if _, _, err := nm.allocateNetworkSubnet([]IPAMData{{Pool: "172.20.1.0/25"}}); err == nil ||
+		err.Error() != "subnet 172.20.1.0/25 overlaps IPAM pool i2p/172.20.1.0/24" {
+		t.Errorf("Expected overlap with the pool, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		req      RequestPoolRequest
+		errorMsg string
+	}{
+		{"overlapping pool", RequestPoolRequest{Pool: "172.20.0.0/16"}, "overlaps IPAM pool i2p/172.20.1.0/24"},
+		{"invalid pool", RequestPoolRequest{Pool: "172.20.300.0/24"}, "invalid pool"},
+		{"address family", RequestPoolRequest{Pool: "10.40.0.0/24", V6: true}, "does not match the requested address family"},
+		{"IPv6 without pool", RequestPoolRequest{V6: true}, "IPv6 pools must be requested with a subnet"},
+		{"sub-pool", RequestPoolRequest{Pool: "10.40.0.0/24", SubPool: "10.40.0.0/25"}, "address ranges within a pool are not supported"},
+		{"address space", RequestPoolRequest{AddressSpace: "LocalDefault"}, `unknown address space "LocalDefault"`},
+		{"strategy", RequestPoolRequest{Options: map[string]string{"i2p.ipam.strategy": "bogus"}}, "unknown IP allocation strategy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := nm.RequestPool(tt.req); err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errorMsg, err)
+			}
+		})
+	}
+
+	if err := nm.ReleasePool(poolID); err != nil {
+		t.Fatalf("Failed to release pool: %v", err)
+	}
+	if err := nm.ReleasePool(poolID); err == nil {
+		t.Error("Expected releasing a released pool to fail")
+	}
+	if _, err := nm.RequestAddress(poolID, nil, nil); err == nil {
+		t.Error("Expected requesting an address from a released pool to fail")
+	}
+}
+
+func TestIPAMDriverNetwork(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	poolID, _, err := nm.RequestPool(RequestPoolRequest{Pool: "10.40.0.0/24", Options: map[string]string{"i2p.ipam.strategy": "sticky"}})
+	if err != nil {
+		t.Fatalf("Failed to request pool: %v", err)
+	}
+
+	// Docker requests the gateway first
+	gatewayType := map[string]string{requestAddressTypeOption: gatewayAddressType}
+	gateway, err := nm.RequestAddress(poolID, net.ParseIP("10.40.0.254"), gatewayType)
+	if err != nil || gateway.String() != "10.40.0.254/24" {
+		t.Fatalf("Expected gateway 10.40.0.254/24, got %v (%v)", gateway, err)
+	}
+	address, err := nm.RequestAddress(poolID, nil, nil)
+	if err != nil || address.String() != "10.40.0.1/24" {
+		t.Fatalf("Expected address 10.40.0.1/24, got %v (%v)", address, err)
+	}
+	if _, err := nm.RequestAddress(poolID, address.IP, nil); err == nil {
+		t.Error("Expected requesting an allocated address to fail")
+	}
+
+	// The network shares the pool's allocator
+	setup, err := nm.prepareNetworkLocked("net1", nil, []IPAMData{{AddressSpace: IPAMAddressSpace, Pool: "10.40.0.0/24", Gateway: gateway.String()}})
+	if err != nil {
+		t.Fatalf("Failed to prepare network: %v", err)
+	}
+	network := setup.network
+	if network.IPAMPool != poolID || network.IPAllocator != nm.ipamPools[poolID].allocator ||
+		!network.Gateway.Equal(gateway.IP) || setup.strategy.Name() != StrategySticky {
+		t.Fatalf("Expected network on pool %s via %s, got %+v", poolID, gateway.IP, network)
+	}
+	// Anti-spoofing rules need iptables
+	network.AntiSpoof = false
+	nm.addNetworkLocked(network)
+
+	// Endpoints take the address Docker requested
+	if _, err := nm.CreateEndpointWithAddress("net1", "ep0", nil, nil); err == nil {
+		t.Error("Expected an endpoint without an address to fail")
+	}
+	if _, err := nm.CreateEndpointWithAddress("net1", "ep0", net.ParseIP("10.40.0.9"), nil); err == nil {
+		t.Error("Expected an endpoint with an unrequested address to fail")
+	}
+	endpoint, err := nm.CreateEndpointWithAddress("net1", "ep1", address.IP, nil)
+	if err != nil {
+		t.Fatalf("Failed to create endpoint: %v", err)
+	}
+	if !endpoint.IPAddress.Equal(address.IP) {
+		t.Errorf("Expected endpoint address %s, got %s", address.IP, endpoint.IPAddress)
+	}
+
+	// Requests for a taken address name the endpoint holding it
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "", nil); err != nil {
+		t.Fatalf("Failed to join endpoint: %v", err)
+	}
+	_, err = nm.RequestAddress(poolID, address.IP, nil)
+	if err == nil || !strings.Contains(err.Error(), "in use by endpoint ep1 of container container1 on network net1") {
+		t.Errorf("Expected the conflict to name container1, got %v", err)
+	}
+	if err := nm.LeaveEndpoint("net1", "ep1"); err != nil {
+		t.Fatalf("Failed to leave endpoint: %v", err)
+	}
+
+	// Parked endpoints keep their address until Docker releases it
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "", nil); err != nil {
+		t.Fatalf("Failed to join endpoint: %v", err)
+	}
+	if err := nm.LeaveEndpoint("net1", "ep1"); err != nil {
+		t.Fatalf("Failed to leave endpoint: %v", err)
+	}
+	if endpoint.State != EndpointParked || !endpoint.IPAddress.Equal(address.IP) || !network.IPAllocator.IsAllocated(address.IP) {
+		t.Errorf("Expected parked endpoint to keep %s, got %s with %s", address.IP, endpoint.State, endpoint.IPAddress)
+	}
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "", nil); err != nil {
+		t.Fatalf("Failed to rejoin endpoint: %v", err)
+	}
+	if !endpoint.IPAddress.Equal(address.IP) {
+		t.Errorf("Expected rejoined endpoint to keep %s, got %s", address.IP, endpoint.IPAddress)
+	}
+
+	if err := nm.DeleteEndpoint("net1", "ep1"); err != nil {
+		t.Fatalf("Failed to delete endpoint: %v", err)
+	}
+	if !network.IPAllocator.IsAllocated(address.IP) {
+		t.Error("Expected the address to stay allocated until Docker releases it")
+	}
+	if err := nm.ReleaseAddress(poolID, address.IP); err != nil {
+		t.Fatalf("Failed to release address: %v", err)
+	}
+	if err := nm.ReleaseAddress(poolID, address.IP); err == nil {
+		t.Error("Expected releasing a released address to fail")
+	}
+	if err := nm.ReleaseAddress(poolID, gateway.IP); err != nil {
+		t.Errorf("Expected releasing the gateway to succeed, got %v", err)
+	}
+	if stats := network.IPAllocator.Stats(); stats.Allocated != 1 {
+		t.Errorf("Expected only the gateway to remain allocated, got %d", stats.Allocated)
+	}
+}
+
+func TestIPAMDriverRestore(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	record := networkRecord{ID: "net1", Subnet: "10.40.0.0/24", Gateway: "10.40.0.1", IPAMPool: "i2p/10.40.0.0/24", IPAMStrategy: StrategySticky}
+	if err := nm.restoreIPAMPoolLocked(record); err != nil {
+		t.Fatalf("Failed to restore pool: %v", err)
+	}
+	pool := nm.ipamPools[record.IPAMPool]
+	if pool == nil || pool.allocator.Strategy() != StrategySticky || !pool.allocator.Gateway().Equal(net.ParseIP("10.40.0.1")) {
+		t.Fatalf("Expected a sticky pool via 10.40.0.1, got %+v", pool)
+	}
+
+	// A parked endpoint on the pool gets its address back
+	network := &I2PNetwork{ID: "net1", IPAMPool: record.IPAMPool, IPAllocator: pool.allocator, Endpoints: map[string]*I2PEndpoint{}}
+	endpoint, err := nm.restoreEndpointLocked(network, endpointRecord{ID: "ep1", State: EndpointParked, IPAddress: "10.40.0.2"})
+	if err != nil {
+		t.Fatalf("Failed to restore endpoint: %v", err)
+	}
+	if endpoint.State != EndpointParked || !pool.allocator.IsAllocated(endpoint.IPAddress) {
+		t.Errorf("Expected parked endpoint to hold 10.40.0.2, got %s with %s", endpoint.State, endpoint.IPAddress)
+	}
+
+	record.IPAMPool = "i2p/10.50.0.0/24"
+	if err := nm.restoreIPAMPoolLocked(record); err == nil {
+		t.Error("Expected a pool that does not match its subnet to fail")
+	}
+}
+
+func TestIPAMDriverHandlers(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+
+	call := func(handler http.HandlerFunc, body string, response interface{}) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if err := json.Unmarshal(rec.Body.Bytes(), response); err != nil {
+			t.Fatalf("Failed to decode response %q: %v", rec.Body.String(), err)
+		}
+	}
+
+	var activate ActivateResponse
+	call(p.handleActivate, "", &activate)
+	if strings.Join(activate.Implements, ",") != "NetworkDriver,IpamDriver" {
+		t.Errorf("Expected both driver interfaces, got %v", activate.Implements)
+	}
+
+	var spaces AddressSpacesResponse
+	call(p.handleDefaultAddressSpaces, "", &spaces)
+	if spaces.LocalDefaultAddressSpace != IPAMAddressSpace || spaces.GlobalDefaultAddressSpace != IPAMAddressSpace {
+		t.Errorf("Unexpected address spaces %+v", spaces)
+	}
+
+	// The default pool skips net1's 172.20.1.0/24
+	var pool RequestPoolResponse
+	call(p.handleRequestPool, `{"AddressSpace": "i2p"}`, &pool)
+	if pool.Err != "" || pool.PoolID != "i2p/172.20.2.0/24" || pool.Pool != "172.20.2.0/24" {
+		t.Fatalf("Unexpected pool response %+v", pool)
+	}
+
+	var address RequestAddressResponse
+	call(p.handleRequestAddress, `{"PoolID": "i2p/172.20.2.0/24", "Options": {"RequestAddressType": "com.docker.network.gateway"}}`, &address)
+	if address.Err != "" || address.Address != "172.20.2.1/24" {
+		t.Errorf("Expected gateway 172.20.2.1/24, got %+v", address)
+	}
+	call(p.handleRequestAddress, `{"PoolID": "i2p/172.20.2.0/24", "Address": "172.20.2.10"}`, &address)
+	if address.Err != "" || address.Address != "172.20.2.10/24" {
+		t.Errorf("Expected address 172.20.2.10/24, got %+v", address)
+	}
+	call(p.handleRequestAddress, `{"PoolID": "i2p/172.20.2.0/24", "Address": "bogus"}`, &address)
+	if address.Err != `invalid address "bogus"` {
+		t.Errorf("Expected an invalid address error, got %+v", address)
+	}
+
+	var result ErrorResponse
+	call(p.handleReleaseAddress, `{"PoolID": "i2p/172.20.2.0/24", "Address": "172.20.2.10/24"}`, &result)
+	if result.Err != "" {
+		t.Errorf("Failed to release address: %s", result.Err)
+	}
+	call(p.handleReleasePool, `{"PoolID": "i2p/172.20.2.0/24"}`, &result)
+	if result.Err != "" {
+		t.Errorf("Failed to release pool: %s", result.Err)
+	}
+	call(p.handleReleasePool, `{"PoolID": "i2p/172.20.2.0/24"}`, &result)
+	if result.Err != "IPAM pool i2p/172.20.2.0/24 not found" {
+		t.Errorf("Expected a not found error, got %q", result.Err)
+	}
+}
@@ -0,0 +1,90 @@
+// Package plugin provides inter-container isolation for I2P networks.
+//
+// Networks created with i2p.isolated=true drop direct traffic between their
+// containers, so co-tenants can only reach each other through I2P
+// destinations. Containers that should talk directly join the same isolation
+// group with the i2p.isolation.group label; every pair of endpoints sharing a
+// group is allowed through.
+package plugin
+
+import (
+	"log"
+	"strings"
+)
+
+const (
+	// IsolatedOption is the network option that blocks east-west traffic
+	IsolatedOption = "i2p.isolated"
+
+	// IsolationGroupLabel is the container label naming the isolation groups
+	// (comma-separated) whose members may reach the container directly
+	IsolationGroupLabel = "i2p.isolation.group"
+)
+
+// getIsolationGroups returns the isolation groups from container labels.
+func getIsolationGroups(options map[string]interface{}) []string {
+	labels, ok := options["Labels"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	value, ok := labels[IsolationGroupLabel].(string)
+	if !ok {
+		return nil
+	}
+
+	var groups []string
+	for _, group := range strings.Split(value, ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// sharesIsolationGroup reports whether two endpoints have a group in common.
+func sharesIsolationGroup(a, b *I2PEndpoint) bool {
+	for _, group := range a.IsolationGroups {
+		for _, other := range b.IsolationGroups {
+			if group == other {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowIsolationPeersLocked lets a joined endpoint reach the joined endpoints
+// of its network that share one of its isolation groups.
+//
+// Failures only cost connectivity, so they are logged. The caller must hold
+// the network's mutex.
+func (nm *NetworkManager) allowIsolationPeersLocked(network *I2PNetwork, endpoint *I2PEndpoint) {
+	if !network.Isolated || len(endpoint.IsolationGroups) == 0 {
+		return
+	}
+
+	for _, peer := range network.Endpoints {
+		if peer.ID == endpoint.ID || peer.State != EndpointJoined || peer.IPAddress == nil {
+			continue
+		}
+		if !sharesIsolationGroup(endpoint, peer) {
+			continue
+		}
+		if err := nm.proxyMgr.AllowPeers(network.ID, endpoint.IPAddress, peer.IPAddress); err != nil {
+			log.Printf("Warning: Failed to allow endpoints %s and %s to reach each other: %v", endpoint.ID, peer.ID, err)
+		}
+	}
+}
+
+// revokeIsolationPeersLocked removes the allowed pairs of an endpoint that is
+// giving up its address. The caller must hold the network's mutex.
+func (nm *NetworkManager) revokeIsolationPeersLocked(network *I2PNetwork, endpoint *I2PEndpoint) {
+	if !network.Isolated || endpoint.IPAddress == nil {
+		return
+	}
+	if err := nm.proxyMgr.RevokePeers(network.ID, endpoint.IPAddress); err != nil {
+		log.Printf("Warning: Failed to revoke isolation peers of endpoint %s: %v", endpoint.ID, err)
+	}
+	endpoint.IsolationGroups = nil
+}
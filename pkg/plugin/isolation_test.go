@@ -0,0 +1,61 @@
+package plugin
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestGetIsolationGroups(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  map[string]interface{}
+		expected []string
+	}{
+		{name: "no labels", options: map[string]interface{}{}, expected: nil},
+		{name: "no group", options: map[string]interface{}{"Labels": map[string]interface{}{"other": "x"}}, expected: nil},
+		{name: "single", options: map[string]interface{}{"Labels": map[string]interface{}{IsolationGroupLabel: "web"}}, expected: []string{"web"}},
+		{name: "list", options: map[string]interface{}{"Labels": map[string]interface{}{IsolationGroupLabel: " web, ,db "}}, expected: []string{"web", "db"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if groups := getIsolationGroups(tt.options); !reflect.DeepEqual(groups, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, groups)
+			}
+		})
+	}
+}
+
+func TestSharesIsolationGroup(t *testing.T) {
+	web := &I2PEndpoint{IsolationGroups: []string{"web"}}
+	webDB := &I2PEndpoint{IsolationGroups: []string{"db", "web"}}
+	db := &I2PEndpoint{IsolationGroups: []string{"db"}}
+	none := &I2PEndpoint{}
+
+	if !sharesIsolationGroup(web, webDB) || !sharesIsolationGroup(webDB, db) {
+		t.Error("Expected endpoints with a common group to share it")
+	}
+	if sharesIsolationGroup(web, db) || sharesIsolationGroup(web, none) || sharesIsolationGroup(none, none) {
+		t.Error("Expected endpoints without a common group not to share one")
+	}
+}
+
+func TestAdminDryRunIsolated(t *testing.T) {
+	_, mux := newAdminTestPlugin(t)
+
+	w, plan := adminDryRun(t, mux, DryRunRequest{
+		Options: map[string]string{IsolatedOption: "true"},
+		Subnet:  "172.30.0.0/24",
+		Labels:  map[string]string{IsolationGroupLabel: "web,db"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if plan.Network == nil || !plan.Network.Isolated {
+		t.Errorf("Expected an isolated network plan, got %+v", plan.Network)
+	}
+	if plan.Join == nil || !reflect.DeepEqual(plan.Join.IsolationGroups, []string{"web", "db"}) {
+		t.Errorf("Expected isolation groups in the join plan, got %+v", plan.Join)
+	}
+}
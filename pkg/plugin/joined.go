@@ -0,0 +1,97 @@
+// Package plugin provides the index of joined containers.
+//
+// Endpoint operations hold the mutex of their own network only, so they
+// cannot look through the endpoints of other networks to learn whether a
+// container is still joined elsewhere or which containers share an alias.
+// The index answers both under a mutex of its own and is updated whenever an
+// endpoint is joined or stops being joined.
+package plugin
+
+import "sync"
+
+// joinedContainers counts the joined endpoints of every container across all
+// networks. The zero value is an empty index.
+type joinedContainers struct {
+	// containers maps container IDs to their joined endpoints
+	containers map[string]*joinedContainer
+
+	// mutex protects containers; it is taken after network mutexes and no
+	// other lock is taken while holding it
+	mutex sync.Mutex
+}
+
+// joinedContainer describes a container with at least one joined endpoint.
+type joinedContainer struct {
+	// endpoints is the number of joined endpoints of the container
+	endpoints int
+
+	// alias is the alias the container joined with, if any
+	alias string
+}
+
+// add records a joined endpoint of a container and returns the other joined
+// containers with the same alias, if alias is not empty.
+func (j *joinedContainers) add(containerID, alias string) []string {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.containers == nil {
+		j.containers = make(map[string]*joinedContainer)
+	}
+	container, exists := j.containers[containerID]
+	if !exists {
+		container = &joinedContainer{}
+		j.containers[containerID] = container
+	}
+	container.endpoints++
+	container.alias = alias
+
+	var sharing []string
+	if alias == "" {
+		return sharing
+	}
+	for otherID, other := range j.containers {
+		if otherID != containerID && other.alias == alias {
+			sharing = append(sharing, otherID)
+		}
+	}
+	return sharing
+}
+
+// remove forgets a joined endpoint of a container and reports whether the
+// container has no joined endpoint left.
+func (j *joinedContainers) remove(containerID string) bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	container, exists := j.containers[containerID]
+	if !exists {
+		return true
+	}
+	container.endpoints--
+	if container.endpoints > 0 {
+		return false
+	}
+	delete(j.containers, containerID)
+	return true
+}
+
+// contains reports whether a container is joined to any network.
+func (j *joinedContainers) contains(containerID string) bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	_, exists := j.containers[containerID]
+	return exists
+}
+
+// endpoints returns the number of joined endpoints of a container.
+func (j *joinedContainers) endpoints(containerID string) int {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if container, exists := j.containers[containerID]; exists {
+		return container.endpoints
+	}
+	return 0
+}
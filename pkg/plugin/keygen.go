@@ -0,0 +1,90 @@
+// Package plugin provides the plugin side of destination key generation.
+//
+// The tunnel manager times every key generation and can keep a pool of keys
+// generated ahead of time (see i2p.KeyGenerationConfig). The plugin tops the
+// pool up in the background and exports the timings, failures and pool usage
+// as metrics, so hosts whose router is short of entropy show up before Joins
+// start timing out.
+package plugin
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/metrics"
+)
+
+// keyPoolInterval is how often the key pool is topped up.
+const keyPoolInterval = 5 * time.Second
+
+// SetKeyGeneration configures the key pool and the slow key generation
+// warning.
+//
+// May be called while the plugin runs.
+func (p *Plugin) SetKeyGeneration(config i2p.KeyGenerationConfig) error {
+	return p.networkMgr.tunnelMgr.SetKeyGeneration(config)
+}
+
+// fillKeyPool tops up the key pool every keyPoolInterval until ctx is done.
+func (p *Plugin) fillKeyPool(ctx context.Context) {
+	ticker := time.NewTicker(keyPoolInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			added, err := p.networkMgr.tunnelMgr.FillKeyPool()
+			if err != nil {
+				log.Printf("Warning: Failed to fill the I2P key pool: %v", err)
+			}
+			if added > 0 {
+				log.Printf("Added %d pre-generated I2P keys to the key pool", added)
+			}
+		}
+	}
+}
+
+// keyGenerationSamples returns a collect function reporting one value of the
+// key generation summary.
+func (p *Plugin) keyGenerationSamples(value func(i2p.KeyGenerationStats) float64) func() []metrics.Sample {
+	return func() []metrics.Sample {
+		return []metrics.Sample{{Value: value(p.networkMgr.tunnelMgr.KeyGenerationStats())}}
+	}
+}
+
+// keyPoolRequestSamples reports the sessions that asked the key pool for
+// keys, by result.
+func (p *Plugin) keyPoolRequestSamples() []metrics.Sample {
+	stats := p.networkMgr.tunnelMgr.KeyGenerationStats()
+	return []metrics.Sample{
+		{LabelValues: []string{"hit"}, Value: float64(stats.PoolHits)},
+		{LabelValues: []string{"miss"}, Value: float64(stats.PoolMisses)},
+	}
+}
+
+// containerKeyGenerationSamples returns a collect function reporting one
+// value per container that generated keys, in container order.
+func (p *Plugin) containerKeyGenerationSamples(value func(i2p.ContainerKeyGeneration) (float64, bool)) func() []metrics.Sample {
+	return func() []metrics.Sample {
+		containers := p.networkMgr.tunnelMgr.ContainerKeyGenerations()
+		ids := make([]string, 0, len(containers))
+		for containerID := range containers {
+			ids = append(ids, containerID)
+		}
+		sort.Strings(ids)
+
+		samples := make([]metrics.Sample, 0, len(ids))
+		for _, containerID := range ids {
+			if v, ok := value(containers[containerID]); ok {
+				alias, _ := i2p.ContainerAlias(containerID)
+				samples = append(samples, metrics.Sample{LabelValues: []string{containerID, alias}, Value: v})
+			}
+		}
+		return samples
+	}
+}
@@ -0,0 +1,349 @@
+// Package plugin provides address labels written back to containers.
+//
+// Services learn their own .b32.i2p address only from the plugin. Docker
+// cannot change the labels of an existing container, and plugins cannot add
+// to its event stream, so when a label path is set the plugin writes the
+// address of every I2P exposure of a container into a file at that path in
+// the container instead, one label per line in the form
+// i2p.address.<port>=<address>. The file is uploaded through the Engine
+// API's archive endpoint and rewritten whenever an exposure of the container
+// is added or removed. Every write is logged as an event naming the
+// container and its labels, which the admin log stream delivers to
+// subscribers following the container.
+//
+// Exposures are created during Join, while Docker is still starting the
+// container and before it lists the container on the network, so files are
+// written in the background and retried until Docker lists the container or
+// labelWriteTimeout has passed.
+package plugin
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+// AddressLabelPrefix prefixes the container port in the label of an
+// exposure's address.
+const AddressLabelPrefix = "i2p.address."
+
+const (
+	// labelWriteTimeout is how long the labels of a container are retried
+	// while Docker does not list the container
+	labelWriteTimeout = 2 * time.Minute
+	// labelWriteRetry is the delay between attempts to write labels
+	labelWriteRetry = 2 * time.Second
+)
+
+// labelWriter writes the exposure addresses of containers into them. It
+// implements service.Publisher and only queues writes, so it is safe to
+// call with the exposure manager's locks held.
+type labelWriter struct {
+	nm     *NetworkManager
+	client *dockerClient
+	path   string
+
+	// labels maps container IDs to their addresses by container port
+	labels map[string]map[int]string
+	// pending are the containers whose file is to be written
+	pending map[string]*labelWrite
+	// wake signals the run loop that writes are pending
+	wake  chan struct{}
+	mutex sync.Mutex
+}
+
+// labelWrite is a pending write of a container's labels.
+type labelWrite struct {
+	// since is when the labels first changed without being written
+	since time.Time
+	// changed reports whether the labels changed during the last attempt
+	changed bool
+}
+
+// newLabelWriter creates a label writer uploading files to filePath through
+// client.
+func newLabelWriter(nm *NetworkManager, client *dockerClient, filePath string) *labelWriter {
+	return &labelWriter{
+		nm:      nm,
+		client:  client,
+		path:    filePath,
+		labels:  make(map[string]map[int]string),
+		pending: make(map[string]*labelWrite),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// SetAddressLabels sets the path in containers the addresses of their I2P
+// exposures are written to as i2p.address.<port> labels. An empty path
+// disables the labels.
+//
+// Must be called after SetDockerSocket and before Start.
+func (p *Plugin) SetAddressLabels(filePath string) error {
+	if filePath == "" {
+		p.labels = nil
+		p.applyPublishers()
+		return nil
+	}
+	if !path.IsAbs(filePath) || path.Clean(filePath) != filePath || filePath == "/" {
+		return fmt.Errorf("label path must be a clean absolute file path, got %q", filePath)
+	}
+	if p.dockerSocket == "" {
+		return fmt.Errorf("label path %s requires a Docker socket", filePath)
+	}
+
+	p.labels = newLabelWriter(p.networkMgr, newDockerClient(p.dockerSocket), filePath)
+	p.applyPublishers()
+	return nil
+}
+
+// applyPublishers notifies the configured address publisher and the label
+// writer of exposure addresses.
+func (p *Plugin) applyPublishers() {
+	if p.labels == nil {
+		p.networkMgr.serviceMgr.SetPublisher(p.publisher)
+		return
+	}
+	p.networkMgr.serviceMgr.SetPublisher(service.Publishers(p.publisher, p.labels))
+}
+
+// Publish queues the address of an exposure for its container's file.
+func (w *labelWriter) Publish(record service.AddressRecord) error {
+	if record.Address == "" {
+		return nil
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.labels[record.ContainerID] == nil {
+		w.labels[record.ContainerID] = make(map[int]string)
+	}
+	w.labels[record.ContainerID][record.Port] = record.Address
+	w.scheduleLocked(record.ContainerID)
+	return nil
+}
+
+// Unpublish queues the removal of an exposure's address from its
+// container's file.
+func (w *labelWriter) Unpublish(record service.AddressRecord) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	addresses, exists := w.labels[record.ContainerID]
+	if !exists || addresses[record.Port] != record.Address {
+		return nil
+	}
+	delete(addresses, record.Port)
+	if len(addresses) == 0 {
+		delete(w.labels, record.ContainerID)
+	}
+	w.scheduleLocked(record.ContainerID)
+	return nil
+}
+
+// scheduleLocked queues a write of a container's labels. The caller must
+// hold w.mutex.
+func (w *labelWriter) scheduleLocked(containerID string) {
+	if write, exists := w.pending[containerID]; exists {
+		write.changed = true
+	} else {
+		w.pending[containerID] = &labelWrite{since: time.Now()}
+	}
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run writes pending labels until ctx is done.
+func (w *labelWriter) run(ctx context.Context) {
+	ticker := time.NewTicker(labelWriteRetry)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.wake:
+		case <-ticker.C:
+		}
+		w.flush(ctx, time.Now())
+	}
+}
+
+// flush attempts every pending write once. Writes that cannot be done yet
+// stay pending until labelWriteTimeout has passed.
+func (w *labelWriter) flush(ctx context.Context, now time.Time) {
+	w.mutex.Lock()
+	containers := make([]string, 0, len(w.pending))
+	for containerID, write := range w.pending {
+		write.changed = false
+		containers = append(containers, containerID)
+	}
+	w.mutex.Unlock()
+	sort.Strings(containers)
+
+	for _, containerID := range containers {
+		err := w.write(ctx, containerID)
+
+		w.mutex.Lock()
+		write := w.pending[containerID]
+		switch {
+		case err == nil && !write.changed:
+			delete(w.pending, containerID)
+		case err != nil && now.Sub(write.since) > labelWriteTimeout:
+			log.Printf("Warning: Failed to write I2P address labels of container %s: %v", i2p.DisplayName(containerID), err)
+			delete(w.pending, containerID)
+		}
+		w.mutex.Unlock()
+	}
+}
+
+// write uploads the labels of a container to the file in it. Containers that
+// have left every network or no longer exist need no file.
+func (w *labelWriter) write(ctx context.Context, containerID string) error {
+	networkID, endpointID, joined := w.nm.joinedEndpoint(containerID)
+	if !joined {
+		return nil
+	}
+	dockerID, err := w.dockerContainerID(ctx, networkID, endpointID)
+	if err != nil {
+		return err
+	}
+
+	labels := w.containerLabels(containerID)
+	err = w.client.putFile(ctx, dockerID, w.path, []byte(strings.Join(labels, "\n")+"\n"))
+	if errors.Is(err, service.ErrContainerGone) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Wrote I2P address labels of container %s (%s) to %s: %s",
+		i2p.DisplayName(containerID), dockerID, w.path, strings.Join(labels, " "))
+	return nil
+}
+
+// containerLabels returns the labels of a container sorted by port.
+func (w *labelWriter) containerLabels(containerID string) []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	addresses := w.labels[containerID]
+	ports := make([]int, 0, len(addresses))
+	for port := range addresses {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+
+	labels := make([]string, 0, len(ports))
+	for _, port := range ports {
+		labels = append(labels, fmt.Sprintf("%s%d=%s", AddressLabelPrefix, port, addresses[port]))
+	}
+	return labels
+}
+
+// dockerContainerID returns the ID Docker knows the container attached to
+// an endpoint by.
+func (w *labelWriter) dockerContainerID(ctx context.Context, networkID, endpointID string) (string, error) {
+	network, found, err := w.client.network(ctx, networkID)
+	if err != nil {
+		return "", err
+	}
+	if found {
+		for dockerID, attached := range network.Containers {
+			if attached.EndpointID == endpointID {
+				return dockerID, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("Docker does not list endpoint %s on network %s", endpointID, networkID)
+}
+
+// joinedEndpoint returns the first joined endpoint of a container, in network
+// ID order.
+func (nm *NetworkManager) joinedEndpoint(containerID string) (networkID, endpointID string, joined bool) {
+	nm.mutex.RLock()
+	networks := make([]*I2PNetwork, 0, len(nm.networks))
+	for _, network := range nm.networks {
+		networks = append(networks, network)
+	}
+	nm.mutex.RUnlock()
+	sort.Slice(networks, func(i, j int) bool { return networks[i].ID < networks[j].ID })
+
+	for _, network := range networks {
+		network.mutex.RLock()
+		for _, endpoint := range network.Endpoints {
+			if endpoint.State == EndpointJoined && endpoint.ContainerID == containerID {
+				network.mutex.RUnlock()
+				return network.ID, endpoint.ID, true
+			}
+		}
+		network.mutex.RUnlock()
+	}
+	return "", "", false
+}
+
+// putFile writes a file into a container, creating its parent directories.
+// Containers Docker does not know are reported as service.ErrContainerGone.
+func (c *dockerClient) putFile(ctx context.Context, containerID, filePath string, data []byte) error {
+	var archive bytes.Buffer
+	writer := tar.NewWriter(&archive)
+	name := strings.TrimPrefix(filePath, "/")
+	var dirs []string
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		dirs = append([]string{dir}, dirs...)
+	}
+	for _, dir := range dirs {
+		if err := writer.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: dir + "/", Mode: 0o755}); err != nil {
+			return err
+		}
+	}
+	if err := writer.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o644, Size: int64(len(data))}); err != nil {
+		return err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	target := "http://docker/containers/" + url.PathEscape(containerID) + "/archive?path=%2F"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, &archive)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-tar")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("write %s to container %s: %w", filePath, containerID, service.ErrContainerGone)
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("write %s to container %s: %s: %s", filePath, containerID, resp.Status, body)
+	}
+}
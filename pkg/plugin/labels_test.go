@@ -0,0 +1,126 @@
+package plugin
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+func TestLabelWriter(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+
+	var mutex sync.Mutex
+	listed := false
+	files := make(map[string]string)
+	client := startDockerTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/networks/net1":
+			network := dockerNetwork{ID: "net1", Containers: map[string]dockerNetworkContainer{}}
+			if listed {
+				network.Containers["docker1"] = dockerNetworkContainer{EndpointID: "ep1"}
+			}
+			json.NewEncoder(w).Encode(network)
+		case r.Method == http.MethodPut && r.URL.Path == "/containers/docker1/archive" && r.URL.Query().Get("path") == "/":
+			archive := tar.NewReader(r.Body)
+			for {
+				header, err := archive.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Errorf("Failed to read archive: %v", err)
+					return
+				}
+				data, _ := io.ReadAll(archive)
+				files[header.Name] = string(data)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	file := func() string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return files["run/i2p/labels"]
+	}
+
+	writer := newLabelWriter(p.networkMgr, client, "/run/i2p/labels")
+	web, api := meshTestAddress("w"), meshTestAddress("a")
+	writer.Publish(service.AddressRecord{ContainerID: "container1", Port: 443, Address: api})
+	writer.Publish(service.AddressRecord{ContainerID: "container1", Port: 80, Address: web})
+
+	// Docker lists the container only once it has started
+	ctx := context.Background()
+	now := time.Now()
+	writer.flush(ctx, now)
+	if len(writer.pending) != 1 || file() != "" {
+		t.Fatalf("Expected the write to wait for Docker to list the container, got %q", file())
+	}
+	mutex.Lock()
+	listed = true
+	mutex.Unlock()
+	writer.flush(ctx, now)
+	expected := "i2p.address.80=" + web + "\ni2p.address.443=" + api + "\n"
+	if file() != expected || len(writer.pending) != 0 {
+		t.Fatalf("Expected labels %q, got %q", expected, file())
+	}
+	mutex.Lock()
+	if _, exists := files["run/i2p/"]; !exists {
+		t.Error("Expected the archive to create the file's directory")
+	}
+	mutex.Unlock()
+
+	// Removed exposures drop out of the file
+	writer.Unpublish(service.AddressRecord{ContainerID: "container1", Port: 80, Address: web})
+	writer.flush(ctx, now)
+	if expected := "i2p.address.443=" + api + "\n"; file() != expected {
+		t.Errorf("Expected labels %q, got %q", expected, file())
+	}
+
+	// Containers that left need no file, and writes Docker never allows are
+	// given up
+	writer.Publish(service.AddressRecord{ContainerID: "container9", Port: 80, Address: web})
+	writer.flush(ctx, now)
+	if len(writer.pending) != 0 {
+		t.Errorf("Expected the labels of a container that left to be dropped, got %v", writer.pending)
+	}
+	mutex.Lock()
+	listed = false
+	mutex.Unlock()
+	writer.Publish(service.AddressRecord{ContainerID: "container1", Port: 80, Address: web})
+	writer.flush(ctx, now)
+	writer.flush(ctx, now.Add(labelWriteTimeout+time.Second))
+	if len(writer.pending) != 0 {
+		t.Errorf("Expected the write to be given up, got %v", writer.pending)
+	}
+}
+
+func TestSetAddressLabels(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	if err := p.SetAddressLabels("/run/i2p/labels"); err == nil {
+		t.Error("Expected labels without a Docker socket to be rejected")
+	}
+
+	p.dockerSocket = "/var/run/docker.sock"
+	for _, path := range []string{"labels", "/run/../labels", "/"} {
+		if err := p.SetAddressLabels(path); err == nil {
+			t.Errorf("Expected label path %q to be rejected", path)
+		}
+	}
+	if err := p.SetAddressLabels("/run/i2p/labels"); err != nil || p.labels == nil {
+		t.Fatalf("SetAddressLabels() unexpected error: %v", err)
+	}
+	if err := p.SetAddressLabels(""); err != nil || p.labels != nil {
+		t.Errorf("Expected an empty path to disable the labels, got %v", err)
+	}
+}
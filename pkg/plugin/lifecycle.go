@@ -0,0 +1,140 @@
+// Package plugin provides lifecycle operations for the plugin process.
+//
+// This file implements ordered shutdown, state dumps and configuration
+// reload, which the plugin binary wires to SIGTERM/SIGINT, SIGUSR1 and
+// SIGHUP respectively.
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+// shutdownTimeout bounds how long in-flight requests may take to drain.
+const shutdownTimeout = 30 * time.Second
+
+// PluginState is a point-in-time snapshot of the plugin for diagnostics.
+type PluginState struct {
+	// Time is when the snapshot was taken
+	Time time.Time `json:"time"`
+	// ActiveRouter is the SAM router used for new sessions
+	ActiveRouter string `json:"active_router"`
+	// ContainerSessions lists containers with an active SAM session
+	ContainerSessions []string `json:"container_sessions"`
+	// Networks are all I2P networks with their endpoints
+	Networks []AdminNetwork `json:"networks"`
+	// Tunnels are all active I2P tunnels
+	Tunnels []AdminTunnel `json:"tunnels"`
+	// Stats are the traffic filter statistics
+	Stats AdminStats `json:"stats"`
+}
+
+// Shutdown stops the plugin in dependency order.
+//
+// The Docker API socket stops accepting connections first and in-flight
+// requests are drained, so no network operation is interrupted halfway.
+// The admin APIs are stopped next, then all networks are torn down, which
+// removes iptables rules, stops forwarders and closes SAM sessions. Traffic
+// statistics are saved once the proxy has stopped. Finally the socket file
+// is removed. Calling Shutdown more than once is safe.
+func (p *Plugin) Shutdown(ctx context.Context) error {
+	p.shutdownOnce.Do(func() {
+		p.shutdownErr = p.shutdown(ctx)
+	})
+	return p.shutdownErr
+}
+
+// shutdown performs the ordered teardown for Shutdown.
+func (p *Plugin) shutdown(ctx context.Context) error {
+	var errors []string
+
+	// Stop accepting Docker requests and drain in-flight ones
+	if p.server != nil {
+		log.Printf("Draining plugin API requests...")
+		if err := p.server.Shutdown(ctx); err != nil {
+			errors = append(errors, fmt.Sprintf("plugin API: %v", err))
+			p.server.Close()
+		}
+	}
+
+	if err := p.stopAdminServer(ctx); err != nil {
+		errors = append(errors, fmt.Sprintf("admin API: %v", err))
+	}
+	p.stopGRPCServer(ctx)
+
+	// Release networks, tunnels, SAM sessions and iptables rules
+	if p.networkMgr != nil {
+		if err := p.networkMgr.Shutdown(); err != nil {
+			errors = append(errors, fmt.Sprintf("network manager: %v", err))
+		}
+	}
+
+	if err := p.saveTrafficStats(); err != nil {
+		errors = append(errors, fmt.Sprintf("traffic statistics: %v", err))
+	}
+
+	if err := os.Remove(p.sockPath); err != nil && !os.IsNotExist(err) {
+		errors = append(errors, fmt.Sprintf("socket cleanup: %v", err))
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("shutdown errors: %s", strings.Join(errors, "; "))
+	}
+
+	log.Printf("Plugin shutdown complete")
+	return nil
+}
+
+// shutdownWithTimeout runs Shutdown bounded by shutdownTimeout.
+func (p *Plugin) shutdownWithTimeout() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return p.Shutdown(ctx)
+}
+
+// State returns a snapshot of networks, tunnels and router state.
+func (p *Plugin) State() PluginState {
+	p.networkMgr.mutex.RLock()
+	activeRouter := p.networkMgr.tunnelMgr.ActiveRouter()
+	sessions := p.networkMgr.tunnelMgr.ListContainerSessions()
+	p.networkMgr.mutex.RUnlock()
+
+	return PluginState{
+		Time:              time.Now(),
+		ActiveRouter:      activeRouter,
+		ContainerSessions: sessions,
+		Networks:          p.networkMgr.adminNetworks(""),
+		Tunnels:           p.adminTunnels(),
+		Stats:             p.adminStats(),
+	}
+}
+
+// DumpState writes the current plugin state to w as indented JSON.
+func (p *Plugin) DumpState(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(p.State()); err != nil {
+		return fmt.Errorf("failed to encode plugin state: %w", err)
+	}
+	return nil
+}
+
+// Reload applies configuration that can change without a restart.
+//
+// Currently this is the SAM router configuration (primary and backups).
+// Existing sessions are kept; new sessions use the updated routers.
+func (p *Plugin) Reload(samConfig *i2p.SAMConfig) error {
+	if err := p.networkMgr.UpdateSAMConfig(samConfig); err != nil {
+		return fmt.Errorf("failed to reload SAM configuration: %w", err)
+	}
+	go p.logActivation()
+	return nil
+}
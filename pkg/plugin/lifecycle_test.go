@@ -0,0 +1,82 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+func TestPluginDumpState(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+
+	var buf bytes.Buffer
+	if err := p.DumpState(&buf); err != nil {
+		t.Fatalf("DumpState failed: %v", err)
+	}
+
+	var state PluginState
+	if err := json.Unmarshal(buf.Bytes(), &state); err != nil {
+		t.Fatalf("Failed to decode state dump: %v", err)
+	}
+
+	if len(state.Networks) != 1 || state.Networks[0].ID != "net1" {
+		t.Errorf("Expected network net1 in state, got %+v", state.Networks)
+	}
+	if state.ActiveRouter != "localhost:7656" {
+		t.Errorf("Expected default active router, got %s", state.ActiveRouter)
+	}
+}
+
+func TestPluginShutdown(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	p.sockPath = filepath.Join(t.TempDir(), "plugin.sock")
+
+	done := make(chan error, 1)
+	go func() { done <- p.Shutdown(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Shutdown returned error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Shutdown deadlocked")
+	}
+
+	if networks := p.networkMgr.ListNetworks(); len(networks) != 0 {
+		t.Errorf("Expected all networks to be removed, got %v", networks)
+	}
+
+	// Shutdown is idempotent
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Errorf("Second Shutdown returned error: %v", err)
+	}
+}
+
+func TestPluginReload(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+
+	config := i2p.DefaultSAMConfig()
+	config.Host = "10.0.0.5"
+	config.Backups = []string{"10.0.0.6:7656"}
+	if err := p.Reload(config); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if router := p.State().ActiveRouter; router != "10.0.0.5:7656" {
+		t.Errorf("Expected reloaded active router, got %s", router)
+	}
+
+	invalid := i2p.DefaultSAMConfig()
+	invalid.Host = ""
+	if err := p.Reload(invalid); err == nil {
+		t.Error("Expected error reloading invalid SAM config")
+	}
+	if router := p.State().ActiveRouter; router != "10.0.0.5:7656" {
+		t.Errorf("Expected failed reload to keep router, got %s", router)
+	}
+}
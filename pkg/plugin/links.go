@@ -0,0 +1,303 @@
+// Package plugin provides the network interfaces of I2P networks.
+//
+// Docker's remote driver protocol leaves creating an endpoint's interface to
+// the driver: Join names an interface on the host, which Docker moves into
+// the container's network namespace, renames to eth<N> and assigns the
+// endpoint's addresses to. With the netlink backend, every network gets a
+// bridge holding its gateway addresses, and every joined endpoint gets a veth
+// pair whose host end is attached to the bridge and whose other end, carrying
+// the endpoint's MAC address, is handed to Docker. Links are managed over
+// rtnetlink in process, so the plugin needs no iproute2 and gets the kernel's
+// errors rather than a command's output. The noop backend creates nothing,
+// for hosts that set up interfaces by other means.
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// LinkBackendNetlink creates bridges and veth pairs over rtnetlink
+	LinkBackendNetlink = "netlink"
+	// LinkBackendNoop creates no interfaces
+	LinkBackendNoop = "noop"
+)
+
+const (
+	// bridgePrefix starts the names of network bridges
+	bridgePrefix = "i2pbr-"
+	// hostVethPrefix starts the names of the host ends of veth pairs
+	hostVethPrefix = "i2ph"
+	// containerVethPrefix starts the names of the container ends of veth pairs
+	containerVethPrefix = "i2pc"
+	// maxInterfaceName is the longest interface name Linux accepts (IFNAMSIZ - 1)
+	maxInterfaceName = 15
+)
+
+// LinkBackends returns the names of the supported link backends.
+func LinkBackends() []string {
+	return []string{LinkBackendNetlink, LinkBackendNoop}
+}
+
+// interfaceName joins a prefix and as much of an ID as fits into an
+// interface name.
+func interfaceName(prefix, id string) string {
+	if room := maxInterfaceName - len(prefix); len(id) > room {
+		id = id[:room]
+	}
+	return prefix + id
+}
+
+// bridgeName returns the name of a network's bridge.
+func bridgeName(networkID string) string {
+	return interfaceName(bridgePrefix, networkID)
+}
+
+// vethNames returns the names of the host and container ends of an
+// endpoint's veth pair.
+func vethNames(endpointID string) (host, container string) {
+	return interfaceName(hostVethPrefix, endpointID), interfaceName(containerVethPrefix, endpointID)
+}
+
+// linkOps changes the links of the host's network namespace. Links are named
+// by their interface names.
+type linkOps interface {
+	// exists reports whether a link exists
+	exists(name string) bool
+	// addBridge creates a bridge
+	addBridge(name string) error
+	// addVeth creates a veth pair
+	addVeth(name, peer string) error
+	// replaceAddr assigns an address to a link, keeping it if assigned
+	replaceAddr(name string, addr *net.IPNet) error
+	// setMTU sets a link's MTU
+	setMTU(name string, mtu int) error
+	// setHardwareAddr sets a link's MAC address
+	setHardwareAddr(name string, addr net.HardwareAddr) error
+	// setMaster attaches a link to a bridge
+	setMaster(name, master string) error
+	// setUp brings a link up
+	setUp(name string) error
+	// remove deletes a link, and with a veth end its peer
+	remove(name string) error
+}
+
+// netlinkOps changes links over rtnetlink.
+type netlinkOps struct{}
+
+func (netlinkOps) exists(name string) bool {
+	_, err := netlink.LinkByName(name)
+	return err == nil
+}
+
+func (netlinkOps) addBridge(name string) error {
+	return netlink.LinkAdd(&netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: name}})
+}
+
+func (netlinkOps) addVeth(name, peer string) error {
+	return netlink.LinkAdd(&netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: name}, PeerName: peer})
+}
+
+func (netlinkOps) replaceAddr(name string, addr *net.IPNet) error {
+	return withLink(name, func(link netlink.Link) error {
+		return netlink.AddrReplace(link, &netlink.Addr{IPNet: addr})
+	})
+}
+
+func (netlinkOps) setMTU(name string, mtu int) error {
+	return withLink(name, func(link netlink.Link) error { return netlink.LinkSetMTU(link, mtu) })
+}
+
+func (netlinkOps) setHardwareAddr(name string, addr net.HardwareAddr) error {
+	return withLink(name, func(link netlink.Link) error { return netlink.LinkSetHardwareAddr(link, addr) })
+}
+
+func (netlinkOps) setMaster(name, master string) error {
+	bridge, err := netlink.LinkByName(master)
+	if err != nil {
+		return fmt.Errorf("bridge %s: %w", master, err)
+	}
+	return withLink(name, func(link netlink.Link) error { return netlink.LinkSetMaster(link, bridge) })
+}
+
+func (netlinkOps) setUp(name string) error {
+	return withLink(name, netlink.LinkSetUp)
+}
+
+func (netlinkOps) remove(name string) error {
+	return withLink(name, netlink.LinkDel)
+}
+
+// withLink looks up a link by name and applies change to it.
+func withLink(name string, change func(netlink.Link) error) error {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return fmt.Errorf("link %s: %w", name, err)
+	}
+	if err := change(link); err != nil {
+		return fmt.Errorf("link %s: %w", name, err)
+	}
+	return nil
+}
+
+// SetLinkBackend selects how endpoint interfaces are created:
+// LinkBackendNetlink or LinkBackendNoop (default). It must be called before
+// any network is created.
+func (nm *NetworkManager) SetLinkBackend(name string) error {
+	switch name {
+	case LinkBackendNetlink, LinkBackendNoop:
+	default:
+		return fmt.Errorf("unknown link backend %q (supported: %s)", name, strings.Join(LinkBackends(), ", "))
+	}
+
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	nm.linkBackend = name
+	return nil
+}
+
+// LinkBackend returns the name of the link backend in use.
+func (nm *NetworkManager) LinkBackend() string {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	return nm.linkBackend
+}
+
+// createBridgeLocked creates a network's bridge, assigns it the gateway
+// addresses and brings it up, and records it as network.Bridge.
+//
+// A bridge that already exists, e.g. one a restored network's containers are
+// still attached to, is taken over. The caller must hold nm.mutex.
+func (nm *NetworkManager) createBridgeLocked(network *I2PNetwork) error {
+	if nm.linkBackend != LinkBackendNetlink {
+		return nil
+	}
+
+	name := bridgeName(network.ID)
+	created := false
+	if !nm.links.exists(name) {
+		if err := nm.links.addBridge(name); err != nil {
+			return fmt.Errorf("failed to create bridge %s for network %s: %w", name, network.ID, err)
+		}
+		created = true
+	}
+
+	steps := []func() error{func() error { return nm.links.replaceAddr(name, gatewayAddr(network.Gateway, network.Subnet)) }}
+	if network.GatewayIPv6 != nil && network.SubnetIPv6 != nil {
+		steps = append(steps, func() error { return nm.links.replaceAddr(name, gatewayAddr(network.GatewayIPv6, network.SubnetIPv6)) })
+	}
+	if network.MTU != 0 {
+		steps = append(steps, func() error { return nm.links.setMTU(name, network.MTU) })
+	}
+	steps = append(steps, func() error { return nm.links.setUp(name) })
+	for _, step := range steps {
+		if err := step(); err != nil {
+			if created {
+				if err := nm.links.remove(name); err != nil {
+					log.Printf("Warning: Failed to remove bridge %s: %v", name, err)
+				}
+			}
+			return fmt.Errorf("failed to set up bridge %s for network %s: %w", name, network.ID, err)
+		}
+	}
+
+	network.Bridge = name
+	log.Printf("Network %s uses bridge %s with gateway %s", network.ID, name, network.Gateway)
+	return nil
+}
+
+// deleteBridgeLocked removes a network's bridge, if it has one. The caller
+// must hold nm.mutex.
+func (nm *NetworkManager) deleteBridgeLocked(network *I2PNetwork) {
+	if network.Bridge == "" {
+		return
+	}
+	if err := nm.links.remove(network.Bridge); err != nil && nm.links.exists(network.Bridge) {
+		log.Printf("Warning: Failed to remove bridge %s of network %s: %v", network.Bridge, network.ID, err)
+		return
+	}
+	log.Printf("Removed bridge %s of network %s", network.Bridge, network.ID)
+	network.Bridge = ""
+}
+
+// createVethLocked creates an endpoint's veth pair, attaches its host end to
+// the network's bridge and returns the name of the end Docker moves into the
+// container, or "" if the network has no bridge.
+//
+// A pair that already exists, e.g. one a container that kept running through a
+// plugin restart still uses, is taken over. The caller must hold the
+// network's mutex.
+func (nm *NetworkManager) createVethLocked(network *I2PNetwork, endpoint *I2PEndpoint) (string, error) {
+	if network.Bridge == "" {
+		return "", nil
+	}
+
+	host, container := vethNames(endpoint.ID)
+	created := false
+	if !nm.links.exists(host) {
+		if err := nm.links.addVeth(host, container); err != nil {
+			return "", fmt.Errorf("failed to create veth pair %s for endpoint %s: %w", host, endpoint.ID, err)
+		}
+		created = true
+	}
+
+	var steps []func() error
+	if created && endpoint.MacAddress != "" {
+		steps = append(steps, func() error {
+			mac, err := net.ParseMAC(endpoint.MacAddress)
+			if err != nil {
+				return err
+			}
+			return nm.links.setHardwareAddr(container, mac)
+		})
+	}
+	if network.MTU != 0 {
+		steps = append(steps,
+			func() error { return nm.links.setMTU(container, network.MTU) },
+			func() error { return nm.links.setMTU(host, network.MTU) },
+		)
+	}
+	steps = append(steps,
+		func() error { return nm.links.setMaster(host, network.Bridge) },
+		func() error { return nm.links.setUp(host) },
+	)
+	for _, step := range steps {
+		if err := step(); err != nil {
+			if created {
+				nm.deleteVethLocked(network, endpoint)
+			}
+			return "", fmt.Errorf("failed to attach veth pair %s of endpoint %s to bridge %s: %w", host, endpoint.ID, network.Bridge, err)
+		}
+	}
+
+	log.Printf("Endpoint %s uses veth pair %s/%s on bridge %s", endpoint.ID, host, container, network.Bridge)
+	return container, nil
+}
+
+// deleteVethLocked removes an endpoint's veth pair, if the network has a
+// bridge and the pair still exists; deleting the host end removes both ends.
+// The caller must hold the network's mutex.
+func (nm *NetworkManager) deleteVethLocked(network *I2PNetwork, endpoint *I2PEndpoint) {
+	if network.Bridge == "" {
+		return
+	}
+	host, _ := vethNames(endpoint.ID)
+	if !nm.links.exists(host) {
+		return
+	}
+	if err := nm.links.remove(host); err != nil {
+		log.Printf("Warning: Failed to remove veth pair %s of endpoint %s: %v", host, endpoint.ID, err)
+	}
+}
+
+// gatewayAddr returns a gateway address with the prefix length of its subnet.
+func gatewayAddr(gateway net.IP, subnet *net.IPNet) *net.IPNet {
+	return &net.IPNet{IP: gateway, Mask: subnet.Mask}
+}
@@ -0,0 +1,225 @@
+package plugin
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeLinks records link changes as the ip commands that would make them
+// and keeps the links they would create.
+type fakeLinks struct {
+	mutex    sync.Mutex
+	links    map[string]string
+	commands []string
+	// fail fails the changes whose command contains it
+	fail string
+	// hook, if set, is called with every command before it is applied
+	hook func(command string)
+}
+
+// newFakeLinks returns fakeLinks without links.
+func newFakeLinks() *fakeLinks {
+	return &fakeLinks{links: map[string]string{}}
+}
+
+// run records a command and applies change to the links unless it fails.
+func (f *fakeLinks) run(command string, change func()) error {
+	if f.hook != nil {
+		f.hook(command)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.commands = append(f.commands, command)
+	if f.fail != "" && strings.Contains(command, f.fail) {
+		return errors.New("operation not permitted")
+	}
+	if change != nil {
+		change()
+	}
+	return nil
+}
+
+func (f *fakeLinks) exists(name string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	_, exists := f.links[name]
+	return exists
+}
+
+func (f *fakeLinks) addBridge(name string) error {
+	return f.run("link add name "+name+" type bridge", func() { f.links[name] = "" })
+}
+
+func (f *fakeLinks) addVeth(name, peer string) error {
+	return f.run("link add name "+name+" type veth peer name "+peer, func() {
+		f.links[name] = peer
+		f.links[peer] = name
+	})
+}
+
+func (f *fakeLinks) replaceAddr(name string, addr *net.IPNet) error {
+	return f.run("addr replace "+addr.String()+" dev "+name, nil)
+}
+
+func (f *fakeLinks) setMTU(name string, mtu int) error {
+	return f.run("link set dev "+name+" mtu "+strconv.Itoa(mtu), nil)
+}
+
+func (f *fakeLinks) setHardwareAddr(name string, addr net.HardwareAddr) error {
+	return f.run("link set dev "+name+" address "+addr.String(), nil)
+}
+
+func (f *fakeLinks) setMaster(name, master string) error {
+	return f.run("link set dev "+name+" master "+master, nil)
+}
+
+func (f *fakeLinks) setUp(name string) error {
+	return f.run("link set dev "+name+" up", nil)
+}
+
+func (f *fakeLinks) remove(name string) error {
+	return f.run("link delete dev "+name, func() {
+		delete(f.links, f.links[name])
+		delete(f.links, name)
+	})
+}
+
+func newLinkTestManager(t *testing.T) (*NetworkManager, *fakeLinks) {
+	t.Helper()
+
+	nm := createMockNetworkManager(t)
+	if err := nm.SetLinkBackend(LinkBackendNetlink); err != nil {
+		t.Fatalf("SetLinkBackend() unexpected error: %v", err)
+	}
+	links := newFakeLinks()
+	nm.links = links
+	return nm, links
+}
+
+func TestSetLinkBackend(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+	if nm.LinkBackend() != LinkBackendNoop {
+		t.Errorf("Expected the noop link backend by default, got %s", nm.LinkBackend())
+	}
+	if err := nm.SetLinkBackend("ip"); err == nil {
+		t.Error("Expected an unknown link backend to be rejected")
+	}
+}
+
+func TestInterfaceNames(t *testing.T) {
+	if name := bridgeName("3f2a9c1d4b5e6f708192a3b4"); name != "i2pbr-3f2a9c1d4" {
+		t.Errorf("Unexpected bridge name %s", name)
+	}
+	host, container := vethNames("9a8b7c6d5e4f3a2b1c0d")
+	if host != "i2ph9a8b7c6d5e4" || container != "i2pc9a8b7c6d5e4" {
+		t.Errorf("Unexpected veth names %s and %s", host, container)
+	}
+	if len(host) > maxInterfaceName {
+		t.Errorf("Interface name %s is longer than %d characters", host, maxInterfaceName)
+	}
+}
+
+func TestEndpointVethLifecycle(t *testing.T) {
+	t.Parallel()
+
+	nm, links := newLinkTestManager(t)
+
+	_, subnet, _ := net.ParseCIDR("10.40.0.0/24")
+	gateway := net.ParseIP("10.40.0.1")
+	network := &I2PNetwork{
+		ID:            "net1",
+		Subnet:        subnet,
+		Gateway:       gateway,
+		Endpoints:     map[string]*I2PEndpoint{},
+		IPAllocator:   NewIPAllocator(subnet, gateway),
+		TunnelManager: nm.tunnelMgr,
+	}
+	if err := nm.createBridgeLocked(network); err != nil {
+		t.Fatalf("createBridgeLocked() unexpected error: %v", err)
+	}
+	if network.Bridge != "i2pbr-net1" {
+		t.Fatalf("Expected bridge i2pbr-net1, got %q", network.Bridge)
+	}
+	if !strings.Contains(strings.Join(links.commands, "\n"), "addr replace 10.40.0.1/24 dev i2pbr-net1") {
+		t.Errorf("Expected the gateway to be assigned to the bridge, got %v", links.commands)
+	}
+	nm.addNetworkLocked(network)
+
+	endpoint, err := nm.CreateEndpoint("net1", "ep1", nil)
+	if err != nil {
+		t.Fatalf("CreateEndpoint() unexpected error: %v", err)
+	}
+	links.commands = nil
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "/var/run/docker/netns/abc", nil); err != nil {
+		t.Fatalf("JoinEndpoint() unexpected error: %v", err)
+	}
+	if endpoint.Interface != "i2pcep1" || links.links["i2phep1"] != "i2pcep1" {
+		t.Fatalf("Expected veth pair i2phep1/i2pcep1, got %q and %v", endpoint.Interface, links.links)
+	}
+	commands := strings.Join(links.commands, "\n")
+	for _, want := range []string{
+		"link set dev i2pcep1 address " + endpoint.MacAddress,
+		"link set dev i2phep1 master i2pbr-net1",
+		"link set dev i2phep1 up",
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("Expected %q, got %v", want, links.commands)
+		}
+	}
+
+	if err := nm.LeaveEndpoint("net1", "ep1"); err != nil {
+		t.Fatalf("LeaveEndpoint() unexpected error: %v", err)
+	}
+	if _, exists := links.links["i2phep1"]; exists || endpoint.Interface != "" {
+		t.Errorf("Expected the veth pair to be removed on Leave, got %v", links.links)
+	}
+
+	// A failing veth pair fails the Join and gives the address back
+	links.fail = "master"
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "/var/run/docker/netns/abc", nil); err == nil {
+		t.Fatal("Expected the Join to fail")
+	}
+	if _, exists := links.links["i2phep1"]; exists || endpoint.State != EndpointParked || endpoint.IPAddress != nil {
+		t.Errorf("Expected the failed Join to be rolled back, got %+v and %v", endpoint, links.links)
+	}
+	links.fail = ""
+
+	if err := nm.DeleteNetwork("net1"); err != nil {
+		t.Fatalf("DeleteNetwork() unexpected error: %v", err)
+	}
+	if len(links.links) != 0 {
+		t.Errorf("Expected the bridge to be removed with the network, got %v", links.links)
+	}
+}
+
+func TestCreateBridgeTakesOverExistingLinks(t *testing.T) {
+	t.Parallel()
+
+	nm, links := newLinkTestManager(t)
+	links.links["i2pbr-net1"] = ""
+	links.links["i2phep1"] = "i2pcep1"
+
+	_, subnet, _ := net.ParseCIDR("10.40.0.0/24")
+	network := &I2PNetwork{ID: "net1", Subnet: subnet, Gateway: net.ParseIP("10.40.0.1")}
+	if err := nm.createBridgeLocked(network); err != nil {
+		t.Fatalf("createBridgeLocked() unexpected error: %v", err)
+	}
+	iface, err := nm.createVethLocked(network, &I2PEndpoint{ID: "ep1", MacAddress: "02:42:0a:28:00:02"})
+	if err != nil || iface != "i2pcep1" {
+		t.Fatalf("createVethLocked() = %q, %v", iface, err)
+	}
+	for _, command := range links.commands {
+		if strings.HasPrefix(command, "link add") || strings.Contains(command, "address") {
+			t.Errorf("Expected existing links to be taken over, got %q", command)
+		}
+	}
+}
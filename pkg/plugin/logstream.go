@@ -0,0 +1,237 @@
+// Package plugin provides log streaming for the admin API.
+//
+// This file implements a log hub that captures the plugin's log output and
+// fans it out as structured events to admin API subscribers. Subscribers can
+// filter by container or network ID so developers debugging a single service
+// can follow its events without access to the host journal. Events naming a
+// network are tagged with its ID, name and tenant, and can be filtered by
+// tenant.
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// logSubscriberBuffer is the number of events buffered per subscriber
+	logSubscriberBuffer = 256
+
+	// logStreamHeartbeat is the interval between keep-alive messages
+	logStreamHeartbeat = 15 * time.Second
+
+	// logTimestampLayout matches the standard log package timestamp prefix
+	logTimestampLayout = "2006/01/02 15:04:05"
+)
+
+// LogEvent is a single structured plugin log event.
+type LogEvent struct {
+	// Time is when the event was logged
+	Time time.Time `json:"time"`
+	// Level is one of debug, info, warning or error
+	Level string `json:"level"`
+	// Message is the log message without the timestamp prefix
+	Message string `json:"message"`
+	// NetworkID is the network the message names, if any
+	NetworkID string `json:"network_id,omitempty"`
+	// Network is the name of the network the message names
+	Network string `json:"network,omitempty"`
+	// Tenant is the tenant of the network the message names
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// logSubscriber receives log events matching its filters.
+type logSubscriber struct {
+	containerID string
+	networkID   string
+	tenant      string
+	events      chan LogEvent
+}
+
+// matches reports whether the event passes the subscriber's filters.
+//
+// IDs match as substrings, so short container IDs work as well as full ones.
+func (s *logSubscriber) matches(event LogEvent) bool {
+	if s.containerID != "" && !strings.Contains(event.Message, s.containerID) {
+		return false
+	}
+	if s.networkID != "" && !strings.Contains(event.Message, s.networkID) {
+		return false
+	}
+	if s.tenant != "" && event.Tenant != s.tenant {
+		return false
+	}
+	return true
+}
+
+// LogHub captures plugin log output and broadcasts it to subscribers.
+//
+// LogHub implements io.Writer so it can be installed as an additional log
+// output. Slow subscribers drop events rather than blocking logging.
+type LogHub struct {
+	subscribers map[*logSubscriber]struct{}
+	// tag returns the network a message names; it must not block on
+	// anything held while logging
+	tag   func(message string) (networkTag, bool)
+	mutex sync.RWMutex
+}
+
+// NewLogHub creates a new log hub with no subscribers.
+func NewLogHub() *LogHub {
+	return &LogHub{
+		subscribers: make(map[*logSubscriber]struct{}),
+	}
+}
+
+// Write publishes a single log line to all matching subscribers.
+func (h *LogHub) Write(p []byte) (int, error) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if len(h.subscribers) == 0 {
+		return len(p), nil
+	}
+
+	event := parseLogLine(string(p))
+	if h.tag != nil {
+		if tag, ok := h.tag(event.Message); ok {
+			event.NetworkID, event.Network, event.Tenant = tag.ID, tag.Name, tag.Tenant
+		}
+	}
+	for sub := range h.subscribers {
+		if !sub.matches(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			// Subscriber is too slow; drop rather than block logging
+		}
+	}
+
+	return len(p), nil
+}
+
+// subscribe registers a subscriber for events matching the given filters.
+func (h *LogHub) subscribe(containerID, networkID, tenant string) *logSubscriber {
+	sub := &logSubscriber{
+		containerID: containerID,
+		networkID:   networkID,
+		tenant:      tenant,
+		events:      make(chan LogEvent, logSubscriberBuffer),
+	}
+
+	h.mutex.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mutex.Unlock()
+
+	return sub
+}
+
+// unsubscribe removes a subscriber.
+func (h *LogHub) unsubscribe(sub *logSubscriber) {
+	h.mutex.Lock()
+	delete(h.subscribers, sub)
+	h.mutex.Unlock()
+}
+
+// parseLogLine converts a formatted log line into a structured event.
+func parseLogLine(line string) LogEvent {
+	line = strings.TrimRight(line, "\n")
+	event := LogEvent{Time: time.Now(), Message: line}
+
+	// Strip the standard "2006/01/02 15:04:05 " prefix if present
+	if len(line) > len(logTimestampLayout) {
+		if ts, err := time.ParseInLocation(logTimestampLayout, line[:len(logTimestampLayout)], time.Local); err == nil {
+			event.Time = ts
+			event.Message = strings.TrimPrefix(line[len(logTimestampLayout):], " ")
+		}
+	}
+
+	event.Level = logLevel(event.Message)
+	return event
+}
+
+// logLevel derives a level from the message prefix conventions used in the plugin.
+func logLevel(message string) string {
+	switch {
+	case strings.HasPrefix(message, "DEBUG:"):
+		return "debug"
+	case strings.HasPrefix(message, "Warning:"):
+		return "warning"
+	case strings.HasPrefix(message, "Error"), strings.HasPrefix(message, "ERROR"):
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// handleAdminLogs streams plugin log events filtered by container, network or
+// tenant.
+//
+// Events are sent as server-sent events by default. With format=ndjson the
+// stream is chunked newline-delimited JSON instead.
+func (p *Plugin) handleAdminLogs(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		p.writeAdminError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format != "" && format != "sse" && format != "ndjson" {
+		p.writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("unsupported format %q", format))
+		return
+	}
+
+	// Log messages carry network IDs, so resolve names first
+	networkID := query.Get("network")
+	if network := p.networkMgr.GetNetworkByName(networkID); network != nil {
+		networkID = network.ID
+	}
+
+	sub := p.logHub.subscribe(query.Get("container"), networkID, query.Get("tenant"))
+	defer p.logHub.unsubscribe(sub)
+
+	if format == "ndjson" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(logStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if format != "ndjson" {
+				fmt.Fprint(w, ": keep-alive\n\n")
+				flusher.Flush()
+			}
+		case event := <-sub.events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Error encoding log event: %v", err)
+				continue
+			}
+			if format == "ndjson" {
+				fmt.Fprintf(w, "%s\n", data)
+			} else {
+				fmt.Fprintf(w, "event: log\ndata: %s\n\n", data)
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLogLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		message string
+		level   string
+	}{
+		{
+			name:    "standard prefix",
+			line:    "2025/01/02 15:04:05 Creating I2P network abc\n",
+			message: "Creating I2P network abc",
+			level:   "info",
+		},
+		{
+			name:    "warning",
+			line:    "2025/01/02 15:04:05 Warning: Failed to expose services\n",
+			message: "Warning: Failed to expose services",
+			level:   "warning",
+		},
+		{
+			name:    "error",
+			line:    "2025/01/02 15:04:05 Error creating network abc: boom\n",
+			message: "Error creating network abc: boom",
+			level:   "error",
+		},
+		{
+			name:    "debug without prefix",
+			line:    "DEBUG: Generated new I2P keys\n",
+			message: "DEBUG: Generated new I2P keys",
+			level:   "debug",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := parseLogLine(tt.line)
+			if event.Message != tt.message {
+				t.Errorf("Expected message %q, got %q", tt.message, event.Message)
+			}
+			if event.Level != tt.level {
+				t.Errorf("Expected level %q, got %q", tt.level, event.Level)
+			}
+		})
+	}
+}
+
+func TestLogHubFiltering(t *testing.T) {
+	hub := NewLogHub()
+
+	all := hub.subscribe("", "", "")
+	container := hub.subscribe("abc123", "", "")
+	network := hub.subscribe("", "net1", "")
+	defer hub.unsubscribe(all)
+	defer hub.unsubscribe(container)
+	defer hub.unsubscribe(network)
+
+	hub.Write([]byte("Joining endpoint ep1 to network net1 for container abc123def456\n"))
+	hub.Write([]byte("Creating I2P network net2\n"))
+
+	if len(all.events) != 2 {
+		t.Errorf("Expected unfiltered subscriber to get 2 events, got %d", len(all.events))
+	}
+	if len(container.events) != 1 {
+		t.Errorf("Expected container subscriber to get 1 event, got %d", len(container.events))
+	}
+	if len(network.events) != 1 {
+		t.Errorf("Expected network subscriber to get 1 event, got %d", len(network.events))
+	}
+
+	hub.unsubscribe(all)
+	hub.Write([]byte("Creating I2P network net3\n"))
+	if len(all.events) != 2 {
+		t.Error("Unsubscribed subscriber should not receive events")
+	}
+}
+
+func TestLogHubSlowSubscriber(t *testing.T) {
+	hub := NewLogHub()
+	sub := hub.subscribe("", "", "")
+	defer hub.unsubscribe(sub)
+
+	// Writes beyond the buffer must not block
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < logSubscriberBuffer*2; i++ {
+			hub.Write([]byte("event\n"))
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Log hub blocked on a slow subscriber")
+	}
+
+	if len(sub.events) != logSubscriberBuffer {
+		t.Errorf("Expected %d buffered events, got %d", logSubscriberBuffer, len(sub.events))
+	}
+}
+
+func TestAdminLogsStream(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v1/logs?container=abc123&format=ndjson")
+	if err != nil {
+		t.Fatalf("Failed to open log stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Expected ndjson content type, got %s", ct)
+	}
+
+	// Wait for the subscription to be registered before logging
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p.logHub.mutex.RLock()
+		subscribed := len(p.logHub.subscribers) > 0
+		p.logHub.mutex.RUnlock()
+		if subscribed || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	p.logHub.Write([]byte("Creating I2P network other\n"))
+	p.logHub.Write([]byte("Warning: Failed to create port maps for container abc123\n"))
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read log event: %v", err)
+	}
+
+	var event LogEvent
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		t.Fatalf("Failed to decode log event: %v", err)
+	}
+	if !strings.Contains(event.Message, "abc123") || event.Level != "warning" {
+		t.Errorf("Unexpected log event: %+v", event)
+	}
+}
+
+func TestAdminLogsInvalidFormat(t *testing.T) {
+	_, mux := newAdminTestPlugin(t)
+
+	var adminErr AdminError
+	w := adminGet(t, mux, "/v1/logs?format=xml", &adminErr)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
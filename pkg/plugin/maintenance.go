@@ -0,0 +1,181 @@
+// Package plugin provides scheduled maintenance of container sessions.
+//
+// Reloaded tunnel options, consolidation onto the active SAM router and key
+// rotation rebuild container sessions, which stalls their tunnels for a while.
+// Instead of rebuilding as soon as a change is loaded, a scheduler checks once
+// a minute for sessions that are due and rebuilds a batch of them while a
+// maintenance window is open (see i2p.MaintenanceConfig). Operators can see
+// what is pending at GET /v1/maintenance and run a batch outside the windows
+// with POST /v1/maintenance/run.
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/metrics"
+)
+
+// maintenanceInterval is how often the scheduler looks for sessions due for
+// maintenance.
+const maintenanceInterval = time.Minute
+
+// maintenanceState counts the session rebuilds done for maintenance.
+type maintenanceState struct {
+	rebuilt atomic.Int64
+	failed  atomic.Int64
+	lastRun atomic.Pointer[time.Time]
+}
+
+// AdminMaintenance describes scheduled session maintenance.
+type AdminMaintenance struct {
+	// Windows are the daily HH:MM-HH:MM periods of local time maintenance runs in (empty is any time)
+	Windows []string `json:"windows"`
+	// InWindow reports whether maintenance may run now
+	InWindow bool `json:"in_window"`
+	// Batch is the maximum number of sessions rebuilt per run
+	Batch int `json:"batch"`
+	// KeyRotation is the age at which transient identities of client-only containers are replaced, empty if disabled
+	KeyRotation string `json:"key_rotation,omitempty"`
+	// Pending lists the container sessions due for maintenance
+	Pending []AdminMaintenanceTask `json:"pending"`
+	// Rebuilt counts sessions rebuilt since startup
+	Rebuilt int64 `json:"rebuilt"`
+	// Failed counts failed rebuilds since startup
+	Failed int64 `json:"failed"`
+	// LastRun is when sessions were last rebuilt
+	LastRun *time.Time `json:"last_run,omitempty"`
+}
+
+// AdminMaintenanceTask describes a container session due for maintenance.
+type AdminMaintenanceTask struct {
+	// ContainerID is the container whose session is rebuilt
+	ContainerID string `json:"container_id"`
+	// Reasons lists why: "tunnel_options", "consolidation" or "key_rotation"
+	Reasons []string `json:"reasons"`
+	// Router is the SAM router the session is on
+	Router string `json:"router"`
+	// Created is when the session was created
+	Created time.Time `json:"created"`
+	// Error is the error of a failed rebuild (only set in run results)
+	Error string `json:"error,omitempty"`
+}
+
+// SetSessionOptions sets the tunnel options of container sessions.
+//
+// Sessions created afterwards use them; existing sessions are rebuilt with
+// them by scheduled maintenance.
+func (p *Plugin) SetSessionOptions(options i2p.TunnelOptions) {
+	p.networkMgr.tunnelMgr.SetSessionOptions(options)
+}
+
+// SetMaintenance configures when and how container sessions are rebuilt.
+//
+// May be called while the plugin runs.
+func (p *Plugin) SetMaintenance(config i2p.MaintenanceConfig) error {
+	return p.networkMgr.tunnelMgr.SetMaintenance(config)
+}
+
+// scheduleMaintenance rebuilds sessions due for maintenance every
+// maintenanceInterval until ctx is done.
+func (p *Plugin) scheduleMaintenance(ctx context.Context) {
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.runMaintenance(now, false)
+		}
+	}
+}
+
+// runMaintenance rebuilds a batch of sessions and records the outcome.
+func (p *Plugin) runMaintenance(now time.Time, force bool) []i2p.MaintenanceResult {
+	results := p.networkMgr.tunnelMgr.RunMaintenance(now, force)
+	if len(results) == 0 {
+		return nil
+	}
+
+	for _, result := range results {
+		if result.Err != nil {
+			p.maintenance.failed.Add(1)
+		} else {
+			p.maintenance.rebuilt.Add(1)
+		}
+	}
+	p.maintenance.lastRun.Store(&now)
+	return results
+}
+
+// adminMaintenanceTask returns the admin API representation of a task.
+func adminMaintenanceTask(task i2p.MaintenanceTask) AdminMaintenanceTask {
+	return AdminMaintenanceTask{
+		ContainerID: task.ContainerID,
+		Reasons:     task.Reasons,
+		Router:      task.Router,
+		Created:     task.Created,
+	}
+}
+
+// handleAdminMaintenance returns the maintenance settings and pending sessions.
+func (p *Plugin) handleAdminMaintenance(w http.ResponseWriter, r *http.Request) {
+	tunnelMgr := p.networkMgr.tunnelMgr
+	config := tunnelMgr.Maintenance()
+
+	view := AdminMaintenance{
+		Windows:  []string{},
+		InWindow: config.InWindow(time.Now()),
+		Batch:    config.Batch,
+		Pending:  []AdminMaintenanceTask{},
+		Rebuilt:  p.maintenance.rebuilt.Load(),
+		Failed:   p.maintenance.failed.Load(),
+		LastRun:  p.maintenance.lastRun.Load(),
+	}
+	if view.Batch == 0 {
+		view.Batch = i2p.DefaultMaintenanceBatch
+	}
+	if config.KeyRotation > 0 {
+		view.KeyRotation = config.KeyRotation.String()
+	}
+	for _, window := range config.Windows {
+		view.Windows = append(view.Windows, window.String())
+	}
+	for _, task := range tunnelMgr.PendingMaintenance() {
+		view.Pending = append(view.Pending, adminMaintenanceTask(task))
+	}
+	p.writeJSONResponse(w, view)
+}
+
+// handleAdminRunMaintenance rebuilds a batch of pending sessions right away,
+// regardless of the maintenance windows.
+func (p *Plugin) handleAdminRunMaintenance(w http.ResponseWriter, r *http.Request) {
+	results := []AdminMaintenanceTask{}
+	for _, result := range p.runMaintenance(time.Now(), true) {
+		task := adminMaintenanceTask(result.MaintenanceTask)
+		if result.Err != nil {
+			task.Error = result.Err.Error()
+		}
+		results = append(results, task)
+	}
+	p.writeJSONResponse(w, results)
+}
+
+// maintenancePendingSamples reports the number of sessions due for
+// maintenance.
+func (p *Plugin) maintenancePendingSamples() []metrics.Sample {
+	return []metrics.Sample{{Value: float64(len(p.networkMgr.tunnelMgr.PendingMaintenance()))}}
+}
+
+// maintenanceRebuildSamples reports the session rebuilds by outcome.
+func (p *Plugin) maintenanceRebuildSamples() []metrics.Sample {
+	return []metrics.Sample{
+		{LabelValues: []string{"rebuilt"}, Value: float64(p.maintenance.rebuilt.Load())},
+		{LabelValues: []string{"failed"}, Value: float64(p.maintenance.failed.Load())},
+	}
+}
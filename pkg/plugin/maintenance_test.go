@@ -0,0 +1,37 @@
+package plugin
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+func TestAdminMaintenance(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+
+	var view AdminMaintenance
+	adminGet(t, mux, "/v1/maintenance", &view)
+	if !view.InWindow || view.Batch != i2p.DefaultMaintenanceBatch || len(view.Windows) != 0 || len(view.Pending) != 0 || view.KeyRotation != "" {
+		t.Errorf("Unexpected default maintenance view %+v", view)
+	}
+
+	windows, _ := i2p.ParseMaintenanceWindows("02:00-04:00")
+	if err := p.SetMaintenance(i2p.MaintenanceConfig{Windows: windows, Batch: 2, KeyRotation: 24 * time.Hour}); err != nil {
+		t.Fatalf("SetMaintenance() unexpected error: %v", err)
+	}
+	adminGet(t, mux, "/v1/maintenance", &view)
+	if len(view.Windows) != 1 || view.Windows[0] != "02:00-04:00" || view.Batch != 2 || view.KeyRotation != "24h0m0s" {
+		t.Errorf("Unexpected maintenance view %+v", view)
+	}
+
+	var results []AdminMaintenanceTask
+	if w := adminAction(t, mux, "/v1/maintenance/run", &results); w.Code != http.StatusOK || results == nil || len(results) != 0 {
+		t.Errorf("Expected an empty run without sessions, got %d %v", w.Code, results)
+	}
+
+	if err := p.SetMaintenance(i2p.MaintenanceConfig{Batch: -1}); err == nil {
+		t.Error("Expected a negative batch to be rejected")
+	}
+}
@@ -0,0 +1,126 @@
+// Package plugin provides the details of running as a Docker managed plugin.
+//
+// Installed with docker plugin install, the plugin runs in its own rootfs
+// and Docker talks to it like to a plugin socket on the host, with a few
+// differences the handler mux accounts for:
+//
+//   - Docker sends Accept: application/vnd.docker.plugins.v1.2+json with
+//     every call, starting with Plugin.Activate, and responses carry the
+//     plugin media type Docker asked for instead of plain application/json.
+//   - Driver calls may be addressed under an API version prefix, such as
+//     /v1.2/NetworkDriver.Join, and are served like the unversioned path.
+//   - Docker names networks of a managed plugin's driver after the plugin
+//     reference (e.g. go-i2p/network-i2p:latest) rather than the socket file,
+//     so reconciliation looks the name up in the Engine API's plugin list.
+package plugin
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pluginMediaTypePrefix prefixes the media types of Docker plugin API
+// versions, as in application/vnd.docker.plugins.v1.2+json
+const pluginMediaTypePrefix = "application/vnd.docker.plugins.v1"
+
+// versionedPath matches plugin API paths under a version prefix and captures
+// the unversioned path
+var versionedPath = regexp.MustCompile(`^/v[0-9]+(?:\.[0-9]+)?(/[A-Za-z]+\.[A-Za-z]+)$`)
+
+// negotiateMediaType answers in the plugin media type the request accepts.
+func negotiateMediaType(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if mediaType := acceptedPluginMediaType(r.Header.Values("Accept")); mediaType != "" {
+			w.Header().Set("Content-Type", mediaType)
+		}
+		handler(w, r)
+	}
+}
+
+// acceptedPluginMediaType returns the first Docker plugin media type listed
+// in Accept headers, or an empty string if none is.
+func acceptedPluginMediaType(accept []string) string {
+	for _, header := range accept {
+		for _, entry := range strings.Split(header, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(entry))
+			if err == nil && strings.HasPrefix(mediaType, pluginMediaTypePrefix) && strings.HasSuffix(mediaType, "+json") {
+				return mediaType
+			}
+		}
+	}
+	return ""
+}
+
+// handleVersionedPath serves plugin API calls under a version prefix with
+// the handler of their unversioned path. Other paths are not found.
+func handleVersionedPath(mux *http.ServeMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		match := versionedPath.FindStringSubmatch(r.URL.Path)
+		if match == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		unversioned := r.Clone(r.Context())
+		unversioned.URL.Path, unversioned.URL.RawPath = match[1], ""
+		if _, pattern := mux.Handler(unversioned); pattern == "" || pattern == "/" {
+			http.NotFound(w, r)
+			return
+		}
+		mux.ServeHTTP(w, unversioned)
+	}
+}
+
+// dockerPlugin is the part of an Engine API plugin list entry the plugin
+// reads.
+type dockerPlugin struct {
+	Name    string `json:"Name"`
+	Enabled bool   `json:"Enabled"`
+	Config  struct {
+		Interface struct {
+			Types []struct {
+				Prefix     string `json:"Prefix"`
+				Capability string `json:"Capability"`
+			} `json:"Types"`
+			Socket string `json:"Socket"`
+		} `json:"Interface"`
+	} `json:"Config"`
+}
+
+// managedPluginName returns the name of the enabled managed network driver
+// plugin serving socket, the plugin socket's file name. found is false if no
+// managed plugin serves it.
+func (c *dockerClient) managedPluginName(ctx context.Context, socket string) (name string, found bool, err error) {
+	var plugins []dockerPlugin
+	if _, err := c.getJSON(ctx, "/plugins", "plugins", &plugins); err != nil {
+		return "", false, err
+	}
+	for _, plugin := range plugins {
+		if !plugin.Enabled || plugin.Config.Interface.Socket != socket {
+			continue
+		}
+		for _, t := range plugin.Config.Interface.Types {
+			if t.Prefix == "docker" && t.Capability == "networkdriver" {
+				return plugin.Name, true, nil
+			}
+		}
+	}
+	return "", false, nil
+}
+
+// driverName returns the driver name Docker knows the plugin by: the name of
+// the managed plugin serving the plugin socket, or else the socket's name.
+func (p *Plugin) driverName(ctx context.Context, client *dockerClient) (string, error) {
+	name, found, err := client.managedPluginName(ctx, filepath.Base(p.sockPath))
+	if err != nil {
+		return "", err
+	}
+	if found {
+		return name, nil
+	}
+	return dockerDriverName(p.sockPath), nil
+}
@@ -0,0 +1,101 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateMediaType(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	mux := http.NewServeMux()
+	p.setupHandlers(mux)
+
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"managed plugin", "application/vnd.docker.plugins.v1.2+json", "application/vnd.docker.plugins.v1.2+json"},
+		{"listed with other types", "text/plain, application/vnd.docker.plugins.v1.1+json; q=0.9", "application/vnd.docker.plugins.v1.1+json"},
+		{"no plugin media type", "*/*", "application/json"},
+		{"no accept header", "", "application/json"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/Plugin.Activate", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Content-Type"); got != tt.want {
+			t.Errorf("%s: expected Content-Type %s, got %s", tt.name, tt.want, got)
+		}
+		var response ActivateResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil || len(response.Implements) != 2 {
+			t.Errorf("%s: expected an activation response, got %+v, %v", tt.name, response, err)
+		}
+	}
+}
+
+func TestVersionedPath(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	mux := http.NewServeMux()
+	p.setupHandlers(mux)
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		return w
+	}
+
+	unversioned := serve("/NetworkDriver.GetCapabilities")
+	for _, path := range []string{"/v1/NetworkDriver.GetCapabilities", "/v1.2/NetworkDriver.GetCapabilities"} {
+		w := serve(path)
+		if w.Code != http.StatusOK || w.Body.String() != unversioned.Body.String() {
+			t.Errorf("Expected %s to be served like the unversioned path, got %d: %s", path, w.Code, w.Body)
+		}
+	}
+	for _, path := range []string{"/v1.2/NetworkDriver.AllocateNetwork", "/v1.2/v1.2/Plugin.Activate", "/latest/Plugin.Activate", "/"} {
+		if w := serve(path); w.Code != http.StatusNotFound {
+			t.Errorf("Expected %s not to be found, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestDriverName(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	p.sockPath = "/run/docker/plugins/i2p-network.sock"
+
+	plugins := `[
+		{"Name":"old/network-i2p:1.0","Enabled":false,"Config":{"Interface":{"Socket":"i2p-network.sock","Types":[{"Prefix":"docker","Capability":"networkdriver","Version":"1.0"}]}}},
+		{"Name":"vieux/sshfs:latest","Enabled":true,"Config":{"Interface":{"Socket":"i2p-network.sock","Types":[{"Prefix":"docker","Capability":"volumedriver","Version":"1.0"}]}}},
+		{"Name":"go-i2p/network-i2p:latest","Enabled":true,"Config":{"Interface":{"Socket":"i2p-network.sock","Types":[{"Prefix":"docker","Capability":"networkdriver","Version":"1.0"}]}}}
+	]`
+	client := startDockerTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/plugins" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(plugins))
+	})
+
+	if name, err := p.driverName(context.Background(), client); err != nil || name != "go-i2p/network-i2p:latest" {
+		t.Errorf("Expected the managed plugin's name, got %q, %v", name, err)
+	}
+
+	plugins = `[]`
+	if name, err := p.driverName(context.Background(), client); err != nil || name != "i2p-network" {
+		t.Errorf("Expected the socket name without a managed plugin, got %q, %v", name, err)
+	}
+
+	client = startDockerTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+	})
+	if _, err := p.driverName(context.Background(), client); err == nil {
+		t.Error("Expected an error while Docker is unavailable")
+	}
+}
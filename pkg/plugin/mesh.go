@@ -0,0 +1,564 @@
+// Package plugin provides global scope networks spanning several hosts.
+//
+// By default the driver has local scope: a network exists on one host and
+// its containers reach each other directly. In global scope, plugin
+// instances on different hosts gossip which services they run, so containers
+// joined to networks of the same name on separate hosts reach each other by
+// service name, routed through I2P.
+//
+// Every instance opens a control channel, a datagram sub-session of its own
+// session, and periodically announces to its peers the joined containers of
+// every named network: their alias and Compose service name, and the b32
+// address of their destination. Announcements also list the announcer's
+// peers, so configuring one peer on each host is enough for the hosts to find
+// each other. Repliable datagrams are signed by their sender; announcements
+// from instances that are neither peers nor on a Docker node discovered
+// through DiscoverNew are ignored.
+//
+// Containers resolve <service>.<network>.i2p through the DNS resolver to
+// the announced destination, or to a local container announcing the same
+// name. Services of peers that stop announcing are forgotten after
+// meshPeerTimeout. The control destination is kept in the identity file, so
+// an instance keeps its address across restarts.
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+// Driver scopes selected with SetScope.
+const (
+	// ScopeLocal keeps every network on its own host
+	ScopeLocal = "local"
+	// ScopeGlobal shares the services of networks of the same name between
+	// hosts over I2P
+	ScopeGlobal = "global"
+)
+
+const (
+	// meshOwner owns the session of the control channel
+	meshOwner = "mesh-control"
+	// meshIdentity is the identity the control channel's keys are kept under
+	meshIdentity = "mesh:control"
+	// meshControlPort is the I2P port of the control channel
+	meshControlPort = 7661
+	// meshAnnounceInterval is how often services are announced to peers
+	meshAnnounceInterval = 30 * time.Second
+	// meshPeerTimeout is how long the services of a quiet peer are kept
+	meshPeerTimeout = 3 * meshAnnounceInterval
+	// meshRetry is the delay before the control channel is opened again
+	meshRetry = 30 * time.Second
+	// meshMessageVersion is the version of the announcement format
+	meshMessageVersion = 1
+	// maxMeshMessage is the largest announcement that fits a datagram
+	maxMeshMessage = 31 * 1024
+)
+
+// meshTransport carries announcements between plugin instances; an
+// i2p.ControlChannel outside of tests.
+type meshTransport interface {
+	// Address returns the b32 address announcements to this instance are sent to
+	Address() string
+	// Send sends a message to a b32 address
+	Send(destination string, data []byte) error
+	// Receive blocks until a message arrives and returns it with the b32
+	// address of its sender
+	Receive() (source string, data []byte, err error)
+	// Close closes the transport, failing pending calls to Receive
+	Close() error
+}
+
+// meshEntry is a service announced on a network.
+type meshEntry struct {
+	// Name is the alias or Compose service name of the container
+	Name string `json:"name"`
+	// Address is the b32 address of the container's destination
+	Address string `json:"address"`
+}
+
+// meshMessage is an announcement of an instance's services and peers.
+type meshMessage struct {
+	Version int `json:"version"`
+	// Networks maps network names to the services joined to them
+	Networks map[string][]meshEntry `json:"networks,omitempty"`
+	// Peers are the control addresses of the other instances the sender knows
+	Peers []string `json:"peers,omitempty"`
+	// Node is the address of the sender's Docker node, if Docker reported it
+	Node string `json:"node,omitempty"`
+}
+
+// meshPeer is another plugin instance.
+type meshPeer struct {
+	// static is set for configured peers, which are kept while quiet
+	static bool
+	// networks maps network names to service names and their addresses
+	networks map[string]map[string]string
+	// seen is when the peer last announced, or was learned of
+	seen time.Time
+	// node is the address of the peer's Docker node, if it was discovered
+	node string
+}
+
+// meshDirectory is the peer table and the services peers announced. The
+// zero value is an empty directory.
+type meshDirectory struct {
+	// self is the control address of this instance
+	self string
+	// peers maps control addresses to peers
+	peers map[string]*meshPeer
+	// node is the address of this instance's Docker node (see discovery.go)
+	node string
+	// nodes maps the addresses of discovered Docker nodes to the control
+	// addresses of their instances, empty until they announce
+	nodes map[string]string
+	mutex sync.Mutex
+}
+
+// setStatic replaces the configured peers. Configured peers dropped from the
+// list are forgotten with their services.
+func (d *meshDirectory) setStatic(addresses []string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.peers == nil {
+		d.peers = make(map[string]*meshPeer)
+	}
+	configured := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		configured[address] = true
+		if peer, exists := d.peers[address]; exists {
+			peer.static = true
+			continue
+		}
+		d.peers[address] = &meshPeer{static: true, seen: time.Now()}
+	}
+	for address, peer := range d.peers {
+		if peer.static && !configured[address] {
+			d.removePeerLocked(address)
+		}
+	}
+}
+
+// setSelf records the control address of this instance, which is never
+// treated as a peer.
+func (d *meshDirectory) setSelf(address string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.self = address
+	delete(d.peers, address)
+}
+
+// addresses returns the sorted control addresses of all peers.
+func (d *meshDirectory) addresses() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	addresses := make([]string, 0, len(d.peers))
+	for address := range d.peers {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// update records an announcement from source, learning the peers it lists.
+// Announcements from instances that are neither peers nor on a discovered
+// node are rejected.
+func (d *meshDirectory) update(source string, message meshMessage, now time.Time) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if message.Version != meshMessageVersion {
+		return fmt.Errorf("announcement from %s has unsupported version %d", source, message.Version)
+	}
+	peer, known := d.peers[source]
+	if !known && !d.bindNodeLocked(source, message.Node) {
+		return fmt.Errorf("announcement from unknown instance %s", source)
+	}
+	if known && peer.node == "" {
+		d.bindNodeLocked(source, message.Node)
+	}
+	peer = d.peers[source]
+
+	peer.seen = now
+	peer.networks = make(map[string]map[string]string, len(message.Networks))
+	for network, entries := range message.Networks {
+		services := make(map[string]string, len(entries))
+		for _, entry := range entries {
+			name := strings.ToLower(entry.Name)
+			if name == "" || !validB32Address(entry.Address) {
+				continue
+			}
+			services[name] = entry.Address
+		}
+		peer.networks[strings.ToLower(network)] = services
+	}
+
+	for _, address := range message.Peers {
+		if address == d.self || !validB32Address(address) {
+			continue
+		}
+		if _, exists := d.peers[address]; !exists {
+			d.peers[address] = &meshPeer{seen: now}
+			log.Printf("Learned of mesh peer %s from %s", address, source)
+		}
+	}
+	return nil
+}
+
+// expire forgets the services of peers that have been quiet for longer than
+// meshPeerTimeout, and learned peers with them.
+func (d *meshDirectory) expire(now time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for address, peer := range d.peers {
+		if now.Sub(peer.seen) <= meshPeerTimeout {
+			continue
+		}
+		if !peer.static {
+			d.removePeerLocked(address)
+			log.Printf("Mesh peer %s stopped announcing, forgetting it", address)
+			continue
+		}
+		peer.networks = nil
+	}
+}
+
+// lookup returns the address a peer announced a service under. When several
+// peers announce it, the peer with the lowest address wins, so all instances
+// agree.
+func (d *meshDirectory) lookup(network, name string) (string, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var owner, address string
+	for peerAddress, peer := range d.peers {
+		if service, exists := peer.networks[network][name]; exists && (owner == "" || peerAddress < owner) {
+			owner, address = peerAddress, service
+		}
+	}
+	return address, owner != ""
+}
+
+// validB32Address reports whether address is a b32 address.
+func validB32Address(address string) bool {
+	label, ok := strings.CutSuffix(address, ".b32.i2p")
+	if !ok || len(label) != 52 {
+		return false
+	}
+	for _, c := range label {
+		if (c < 'a' || c > 'z') && (c < '2' || c > '7') {
+			return false
+		}
+	}
+	return true
+}
+
+// SetScope selects the driver scope: ScopeLocal (default) or ScopeGlobal.
+//
+// Must be called before Start.
+func (p *Plugin) SetScope(scope string) error {
+	switch scope {
+	case ScopeLocal, ScopeGlobal:
+	default:
+		return fmt.Errorf("scope must be %s or %s, got %q", ScopeLocal, ScopeGlobal, scope)
+	}
+	p.networkMgr.scope = scope
+	p.applyNaming()
+	return nil
+}
+
+// driverScope returns the driver scope.
+func (nm *NetworkManager) driverScope() string {
+	if nm.scope == "" {
+		return ScopeLocal
+	}
+	return nm.scope
+}
+
+// SetMeshPeers sets the control addresses of the plugin instances on other
+// hosts announcements are sent to in global scope.
+//
+// May be called while the plugin runs.
+func (p *Plugin) SetMeshPeers(peers []string) error {
+	addresses := make([]string, 0, len(peers))
+	for _, peer := range peers {
+		address := strings.ToLower(strings.TrimSpace(peer))
+		if !validB32Address(address) {
+			return fmt.Errorf("mesh peer %q is not a b32 address", peer)
+		}
+		addresses = append(addresses, address)
+	}
+	p.networkMgr.mesh.setStatic(addresses)
+	return nil
+}
+
+// meshAnnouncement returns the services of this instance and its peers.
+func (nm *NetworkManager) meshAnnouncement() meshMessage {
+	return meshMessage{
+		Version:  meshMessageVersion,
+		Networks: nm.localMeshServices(),
+		Peers:    nm.mesh.addresses(),
+		Node:     nm.mesh.selfNode(),
+	}
+}
+
+// localMeshServices returns the services joined to the named networks of
+// this instance. Client-only containers serve nothing and are left out, as
+// are containers whose destination is not known yet.
+func (nm *NetworkManager) localMeshServices() map[string][]meshEntry {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	services := make(map[string][]meshEntry)
+	for _, network := range nm.networks {
+		if network.Name == "" {
+			continue
+		}
+		network.mutex.RLock()
+		for _, endpoint := range network.Endpoints {
+			if endpoint.State != EndpointJoined || endpoint.ClientOnly {
+				continue
+			}
+			destination, known := nm.tunnelMgr.KnownDestination(endpoint.ContainerID)
+			if !known {
+				continue
+			}
+			address, err := i2p.B32Address(destination)
+			if err != nil {
+				continue
+			}
+			for _, name := range meshNames(endpoint) {
+				services[network.Name] = append(services[network.Name], meshEntry{Name: name, Address: address})
+			}
+		}
+		network.mutex.RUnlock()
+	}
+	for _, entries := range services {
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	}
+	return services
+}
+
+// meshNames returns the names a joined endpoint is announced under: its
+// alias and the Compose service of its container.
+func meshNames(endpoint *I2PEndpoint) []string {
+	var names []string
+	if endpoint.Alias != "" {
+		names = append(names, strings.ToLower(endpoint.Alias))
+	}
+	labels, _ := endpoint.JoinOptions["Labels"].(map[string]interface{})
+	if serviceName, _ := labels[composeServiceLabel].(string); serviceName != "" {
+		serviceName = strings.ToLower(i2p.SanitizeAlias(serviceName))
+		if len(names) == 0 || names[0] != serviceName {
+			names = append(names, serviceName)
+		}
+	}
+	return names
+}
+
+// lookupMeshName returns the b32 address of <service>.<network>.i2p, served
+// by a local container or announced by a peer. ok is false for names that do
+// not name a service of a named network.
+func (nm *NetworkManager) lookupMeshName(name string) (address string, ok bool) {
+	host, found := strings.CutSuffix(strings.ToLower(name), ".i2p")
+	if !found {
+		return "", false
+	}
+	serviceName, network, found := strings.Cut(host, ".")
+	if !found {
+		return "", false
+	}
+
+	for _, entry := range nm.localMeshServices()[nm.meshNetworkName(network)] {
+		if entry.Name == serviceName {
+			return entry.Address, true
+		}
+	}
+	return nm.mesh.lookup(network, serviceName)
+}
+
+// meshNetworkName returns the name of the local network whose lower-case
+// name is network, or network itself if there is none.
+func (nm *NetworkManager) meshNetworkName(network string) string {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	for name := range nm.networkNames {
+		if strings.ToLower(name) == network {
+			return name
+		}
+	}
+	return network
+}
+
+// meshResolver resolves the service names of global scope networks before
+// handing other names to the configured naming backends.
+type meshResolver struct {
+	nm   *NetworkManager
+	next proxy.NamingResolver
+}
+
+// Name returns the backend names in lookup order.
+func (r *meshResolver) Name() string {
+	if r.next == nil {
+		return "mesh"
+	}
+	return "mesh," + r.next.Name()
+}
+
+// Lookup returns the address of a mesh service, or asks the configured
+// backends. Without backends, other names are handed to the router
+// unresolved, as they would be without the mesh.
+func (r *meshResolver) Lookup(name string) (string, error) {
+	if address, ok := r.nm.lookupMeshName(name); ok {
+		return address, nil
+	}
+	if r.next == nil {
+		return name, nil
+	}
+	return r.next.Lookup(name)
+}
+
+// applyNaming installs the configured naming backends, behind the mesh
+// resolver in global scope.
+func (p *Plugin) applyNaming() {
+	resolver := p.naming
+	if p.networkMgr.driverScope() == ScopeGlobal {
+		resolver = &meshResolver{nm: p.networkMgr, next: p.naming}
+	}
+	p.networkMgr.proxyMgr.SetNamingResolver(resolver)
+}
+
+// runMesh announces the services of this instance to its peers and records
+// theirs until ctx is done, opening the control channel again after
+// failures. It returns right away in local scope.
+func (p *Plugin) runMesh(ctx context.Context) {
+	nm := p.networkMgr
+	if nm.driverScope() != ScopeGlobal {
+		return
+	}
+
+	for {
+		channel, err := nm.openMeshChannel()
+		if err != nil {
+			log.Printf("Warning: Failed to open the mesh control channel: %v", err)
+		} else {
+			nm.serveMesh(ctx, channel)
+			channel.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(meshRetry):
+		}
+	}
+}
+
+// openMeshChannel opens the control channel under the destination kept in
+// the identity file, remembering a new destination there.
+func (nm *NetworkManager) openMeshChannel() (*i2p.ControlChannel, error) {
+	record, exists := nm.identities.get(meshIdentity)
+	if exists {
+		keys, err := i2p.ParseKeys(record.PublicKey, record.PrivateKey)
+		if err == nil {
+			err = nm.tunnelMgr.AdoptSessionKeys(meshOwner, keys)
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to reuse the mesh control destination: %v", err)
+		}
+	}
+
+	channel, err := nm.tunnelMgr.OpenControlChannel(meshOwner, meshControlPort)
+	if err != nil {
+		return nil, err
+	}
+	if keys, known := nm.tunnelMgr.SessionKeys(meshOwner); known && keys.Addr().Base64() != record.PublicKey {
+		nm.identities.put(meshIdentity, identityRecord{
+			ContainerID: meshOwner,
+			PublicKey:   keys.Addr().Base64(),
+			PrivateKey:  keys.String(),
+		})
+	}
+	return channel, nil
+}
+
+// serveMesh announces services over transport every meshAnnounceInterval and
+// records received announcements until ctx is done or receiving fails.
+func (nm *NetworkManager) serveMesh(ctx context.Context, transport meshTransport) {
+	nm.mesh.setSelf(transport.Address())
+	log.Printf("Mesh control channel listening at %s", transport.Address())
+
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			source, data, err := transport.Receive()
+			if err != nil {
+				failed <- err
+				return
+			}
+			nm.receiveMesh(source, data)
+		}
+	}()
+
+	ticker := time.NewTicker(meshAnnounceInterval)
+	defer ticker.Stop()
+	for {
+		nm.mesh.expire(time.Now())
+		nm.announceMesh(transport)
+
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-failed:
+			log.Printf("Warning: Mesh control channel failed: %v", err)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// announceMesh sends the announcement of this instance to every peer.
+func (nm *NetworkManager) announceMesh(transport meshTransport) {
+	message := nm.meshAnnouncement()
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Warning: Failed to encode mesh announcement: %v", err)
+		return
+	}
+	if len(data) > maxMeshMessage {
+		log.Printf("Warning: Mesh announcement of %d bytes exceeds the datagram limit of %d bytes, not sending it", len(data), maxMeshMessage)
+		return
+	}
+
+	for _, peer := range message.Peers {
+		if err := transport.Send(peer, data); err != nil {
+			log.Printf("Warning: Failed to announce services to mesh peer %s: %v", peer, err)
+		}
+	}
+}
+
+// receiveMesh records an announcement received from source.
+func (nm *NetworkManager) receiveMesh(source string, data []byte) {
+	var message meshMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		log.Printf("Warning: Ignoring malformed mesh announcement from %s: %v", source, err)
+		return
+	}
+	if err := nm.mesh.update(source, message, time.Now()); err != nil {
+		log.Printf("Warning: Ignoring mesh %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/i2pkeys"
+)
+
+// meshTestAddress returns a b32 address made of fill.
+func meshTestAddress(fill string) string {
+	return strings.Repeat(fill, 52) + ".b32.i2p"
+}
+
+// meshTestDatagram is a message in flight between test transports.
+type meshTestDatagram struct {
+	source string
+	data   []byte
+}
+
+// meshTestHub delivers messages between test transports by address.
+type meshTestHub struct {
+	inboxes map[string]chan meshTestDatagram
+	mutex   sync.Mutex
+}
+
+// meshTestTransport is a transport attached to a meshTestHub.
+type meshTestTransport struct {
+	hub     *meshTestHub
+	address string
+	inbox   chan meshTestDatagram
+	closed  chan struct{}
+	once    sync.Once
+}
+
+func (h *meshTestHub) attach(address string) *meshTestTransport {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.inboxes == nil {
+		h.inboxes = make(map[string]chan meshTestDatagram)
+	}
+	inbox := make(chan meshTestDatagram, 16)
+	h.inboxes[address] = inbox
+	return &meshTestTransport{hub: h, address: address, inbox: inbox, closed: make(chan struct{})}
+}
+
+func (t *meshTestTransport) Address() string {
+	return t.address
+}
+
+func (t *meshTestTransport) Send(destination string, data []byte) error {
+	t.hub.mutex.Lock()
+	inbox, exists := t.hub.inboxes[destination]
+	t.hub.mutex.Unlock()
+	if !exists {
+		return errors.New("unreachable")
+	}
+	inbox <- meshTestDatagram{source: t.address, data: data}
+	return nil
+}
+
+func (t *meshTestTransport) Receive() (string, []byte, error) {
+	select {
+	case datagram := <-t.inbox:
+		return datagram.source, datagram.data, nil
+	case <-t.closed:
+		return "", nil, errors.New("closed")
+	}
+}
+
+func (t *meshTestTransport) Close() error {
+	t.once.Do(func() { close(t.closed) })
+	return nil
+}
+
+func TestMeshDirectory(t *testing.T) {
+	peerA, peerB, peerC := meshTestAddress("a"), meshTestAddress("b"), meshTestAddress("c")
+	var directory meshDirectory
+	directory.setStatic([]string{peerA, peerB})
+	directory.setSelf(meshTestAddress("z"))
+
+	now := time.Now()
+	message := meshMessage{
+		Version:  meshMessageVersion,
+		Networks: map[string][]meshEntry{"App": {{Name: "Web", Address: meshTestAddress("w")}, {Name: "bad", Address: "web.i2p"}}},
+		Peers:    []string{peerC, meshTestAddress("z")},
+	}
+	if err := directory.update(meshTestAddress("d"), message, now); err == nil {
+		t.Error("Expected announcements from unknown instances to be rejected")
+	}
+	if err := directory.update(peerB, meshMessage{Version: 2}, now); err == nil {
+		t.Error("Expected announcements of another version to be rejected")
+	}
+	if err := directory.update(peerB, message, now); err != nil {
+		t.Fatalf("update() unexpected error: %v", err)
+	}
+
+	if address, ok := directory.lookup("app", "web"); !ok || address != meshTestAddress("w") {
+		t.Errorf("Expected the announced service, got %q", address)
+	}
+	if _, ok := directory.lookup("app", "bad"); ok {
+		t.Error("Expected services without a b32 address to be dropped")
+	}
+	if peers := directory.addresses(); len(peers) != 3 || peers[2] != peerC {
+		t.Errorf("Expected the listed peer to be learned and this instance left out, got %v", peers)
+	}
+
+	// The peer with the lowest address wins on every instance
+	other := meshMessage{Version: meshMessageVersion, Networks: map[string][]meshEntry{"app": {{Name: "web", Address: meshTestAddress("v")}}}}
+	if err := directory.update(peerA, other, now); err != nil {
+		t.Fatalf("update() unexpected error: %v", err)
+	}
+	if address, _ := directory.lookup("app", "web"); address != meshTestAddress("v") {
+		t.Errorf("Expected the service of the lowest peer, got %q", address)
+	}
+
+	// Quiet peers lose their services; learned ones are forgotten
+	directory.expire(now.Add(meshPeerTimeout + time.Second))
+	if _, ok := directory.lookup("app", "web"); ok {
+		t.Error("Expected the services of quiet peers to expire")
+	}
+	if peers := directory.addresses(); len(peers) != 2 {
+		t.Errorf("Expected the configured peers only, got %v", peers)
+	}
+
+	directory.setStatic([]string{peerA})
+	if peers := directory.addresses(); len(peers) != 1 || peers[0] != peerA {
+		t.Errorf("Expected peers dropped from the configuration to be forgotten, got %v", peers)
+	}
+}
+
+func TestMeshServeAndResolve(t *testing.T) {
+	serving, _ := newAdminTestPlugin(t)
+	resolving, _ := newAdminTestPlugin(t)
+	for _, p := range []*Plugin{serving, resolving} {
+		if err := p.SetScope(ScopeGlobal); err != nil {
+			t.Fatalf("SetScope() unexpected error: %v", err)
+		}
+	}
+
+	public := strings.Repeat("A", 516)
+	keys := i2pkeys.NewKeys(i2pkeys.I2PAddr(public), public+"AAAA")
+	if err := serving.networkMgr.tunnelMgr.AdoptSessionKeys("container1", keys); err != nil {
+		t.Fatalf("AdoptSessionKeys() unexpected error: %v", err)
+	}
+	serving.networkMgr.GetNetwork("net1").Endpoints["ep1"].Alias = "web"
+	address, _ := i2p.B32Address(public)
+
+	var hub meshTestHub
+	servingTransport, resolvingTransport := hub.attach(meshTestAddress("a")), hub.attach(meshTestAddress("b"))
+	if err := serving.SetMeshPeers([]string{resolvingTransport.Address()}); err != nil {
+		t.Fatalf("SetMeshPeers() unexpected error: %v", err)
+	}
+	if err := resolving.SetMeshPeers([]string{strings.ToUpper(servingTransport.Address())}); err != nil {
+		t.Fatalf("SetMeshPeers() unexpected error: %v", err)
+	}
+	if err := resolving.SetMeshPeers([]string{"web.i2p"}); err == nil {
+		t.Error("Expected peers that are not b32 addresses to be rejected")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, run := range []struct {
+		p         *Plugin
+		transport *meshTestTransport
+	}{{resolving, resolvingTransport}, {serving, servingTransport}} {
+		go func(p *Plugin, transport *meshTestTransport) {
+			p.networkMgr.serveMesh(ctx, transport)
+			transport.Close()
+		}(run.p, run.transport)
+	}
+
+	resolver := &meshResolver{nm: resolving.networkMgr}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resolved, err := resolver.Lookup("web.i2p-test.i2p")
+		if err == nil && resolved == address {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the announced service to resolve to %s, got %q", address, resolved)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The serving instance resolves its own service locally
+	local := &meshResolver{nm: serving.networkMgr}
+	if resolved, _ := local.Lookup("WEB.I2P-TEST.I2P"); resolved != address {
+		t.Errorf("Expected the local service, got %q", resolved)
+	}
+	// Other names are handed on unresolved without naming backends
+	if resolved, _ := resolver.Lookup("forum.i2p"); resolved != "forum.i2p" {
+		t.Errorf("Expected other names to be left to the router, got %q", resolved)
+	}
+}
+
+func TestGetCapabilitiesScope(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	if err := p.SetScope("swarm"); err == nil {
+		t.Error("Expected unknown scopes to be rejected")
+	}
+
+	for _, scope := range []string{ScopeLocal, ScopeGlobal} {
+		if err := p.SetScope(scope); err != nil {
+			t.Fatalf("SetScope() unexpected error: %v", err)
+		}
+		w := httptest.NewRecorder()
+		p.handleGetCapabilities(w, httptest.NewRequest("POST", "/", nil))
+		if body := w.Body.String(); !strings.Contains(body, `"Scope":"`+scope+`"`) || !strings.Contains(body, `"ConnectivityScope":"`+scope+`"`) {
+			t.Errorf("Expected %s scope, got %s", scope, body)
+		}
+	}
+}
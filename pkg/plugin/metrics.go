@@ -0,0 +1,300 @@
+// Package plugin provides metrics registration for the plugin.
+//
+// This file registers the plugin's metric families with its registry. Values
+// that already live in plugin state, such as IP pool utilization, are computed
+// at scrape time; the families are served from the admin API at GET /metrics.
+// Per-network families are labelled with the network's ID, name and tenant.
+// Docker plugin API handlers are instrumented with per-operation latency
+// histograms and error counters, so slow Joins caused by a sluggish router
+// show up before containers start timing out.
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/metrics"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+// registerMetrics registers the plugin's scrape-time metric families.
+func (p *Plugin) registerMetrics() {
+	p.metrics.NewGaugeFunc("i2p_ipam_addresses_allocated",
+		"IP addresses in use per network, including the gateway.",
+		[]string{"network", "name", "tenant", "strategy"}, p.ipamSamples(func(s IPAllocatorStats) float64 { return float64(s.Allocated) }))
+
+	p.metrics.NewGaugeFunc("i2p_ipam_addresses_available",
+		"Free IP addresses per network.",
+		[]string{"network", "name", "tenant", "strategy"}, p.ipamSamples(func(s IPAllocatorStats) float64 { return float64(s.Size - s.Allocated) }))
+
+	p.metrics.NewCounterFunc("i2p_ipam_exhausted_total",
+		"Endpoint creations that failed because the network's IP pool was full.",
+		[]string{"network", "name", "tenant", "strategy"}, p.ipamSamples(func(s IPAllocatorStats) float64 { return float64(s.Exhaustions) }))
+
+	p.metrics.NewGaugeFunc("i2p_network_endpoints",
+		"Endpoints per network.",
+		[]string{"network", "name", "tenant"}, p.endpointSamples(func(c endpointCounts) (float64, bool) { return float64(c.current), true }))
+
+	p.metrics.NewGaugeFunc("i2p_network_endpoints_max",
+		"Endpoint limit per network with i2p.endpoints.max set.",
+		[]string{"network", "name", "tenant"}, p.endpointSamples(func(c endpointCounts) (float64, bool) { return float64(c.max), c.max > 0 }))
+
+	p.metrics.NewCounterFunc("i2p_network_endpoint_limit_rejected_total",
+		"Endpoint creations refused because the network reached i2p.endpoints.max.",
+		[]string{"network", "name", "tenant"}, p.endpointSamples(func(c endpointCounts) (float64, bool) { return float64(c.rejections), true }))
+
+	p.metrics.NewGaugeFunc("i2p_smoke_test_success",
+		"Whether the startup smoke test's round trip over I2P succeeded (1) or failed (0).",
+		nil, p.smokeTestSamples(func(r AdminSmokeTest, _ time.Duration) (float64, bool) {
+			if r.Status == SmokeTestPassed {
+				return 1, true
+			}
+			return 0, true
+		}))
+
+	p.metrics.NewGaugeFunc("i2p_smoke_test_round_trip_seconds",
+		"Time from dialing the startup smoke test's echo service to receiving its reply.",
+		nil, p.smokeTestSamples(func(r AdminSmokeTest, roundTrip time.Duration) (float64, bool) {
+			return roundTrip.Seconds(), r.Status == SmokeTestPassed
+		}))
+
+	p.metrics.NewGaugeFunc("i2p_traffic_log_entries",
+		"Traffic log entries kept in memory.",
+		nil, p.logBufferSamples(func(s proxy.LogBufferStats) float64 { return float64(s.Entries) }))
+
+	p.metrics.NewGaugeFunc("i2p_traffic_log_capacity",
+		"Maximum number of traffic log entries kept in memory.",
+		nil, p.logBufferSamples(func(s proxy.LogBufferStats) float64 { return float64(s.Capacity) }))
+
+	p.metrics.NewCounterFunc("i2p_traffic_log_written_total",
+		"Traffic log entries recorded.",
+		nil, p.logBufferSamples(func(s proxy.LogBufferStats) float64 { return float64(s.Written) }))
+
+	p.metrics.NewCounterFunc("i2p_traffic_log_evicted_total",
+		"Traffic log entries overwritten by newer ones.",
+		nil, p.logBufferSamples(func(s proxy.LogBufferStats) float64 { return float64(s.Evicted) }))
+
+	p.metrics.NewCounterFunc("i2p_dns_queries_total",
+		"DNS queries received by the resolvers.",
+		nil, func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(p.networkMgr.proxyMgr.GetDNSLoadStats().Queries)}}
+		})
+
+	p.metrics.NewCounterFunc("i2p_dns_queries_shed_total",
+		"DNS queries answered with SERVFAIL over the query limits, by limit (source or global).",
+		[]string{"limit"}, p.dnsShedSamples)
+
+	p.metrics.NewGaugeFunc("i2p_peers_blocked",
+		"Inbound I2P peers whose streams are currently refused.",
+		nil, p.peerSamples(func(s i2p.PeerReputationStats) float64 { return float64(s.Blocked) }))
+
+	p.metrics.NewCounterFunc("i2p_peer_blocks_total",
+		"Blocks of inbound I2P peers that flooded server tunnels or kept dropping streams.",
+		nil, p.peerSamples(func(s i2p.PeerReputationStats) float64 { return float64(s.Blocks) }))
+
+	p.metrics.NewCounterFunc("i2p_peer_streams_rejected_total",
+		"Inbound streams refused because their peer was blocked.",
+		nil, p.peerSamples(func(s i2p.PeerReputationStats) float64 { return float64(s.Rejected) }))
+
+	p.metrics.NewGaugeFunc("i2p_maintenance_pending",
+		"Container sessions due to be rebuilt in the next maintenance window.",
+		nil, p.maintenancePendingSamples)
+
+	p.metrics.NewCounterFunc("i2p_maintenance_rebuilds_total",
+		"Container sessions rebuilt for maintenance, by result (rebuilt or failed).",
+		[]string{"result"}, p.maintenanceRebuildSamples)
+
+	p.metrics.NewCounterFunc("i2p_key_generations_total",
+		"I2P destination keys generated by the router.",
+		nil, p.keyGenerationSamples(func(s i2p.KeyGenerationStats) float64 { return float64(s.Generated) }))
+
+	p.metrics.NewCounterFunc("i2p_key_generation_failures_total",
+		"I2P destination key generations that failed.",
+		nil, p.keyGenerationSamples(func(s i2p.KeyGenerationStats) float64 { return float64(s.Failed) }))
+
+	p.metrics.NewCounterFunc("i2p_key_generation_slow_total",
+		"I2P destination key generations slower than the configured threshold.",
+		nil, p.keyGenerationSamples(func(s i2p.KeyGenerationStats) float64 { return float64(s.Slow) }))
+
+	p.metrics.NewCounterFunc("i2p_key_generation_seconds_total",
+		"Time spent generating I2P destination keys.",
+		nil, p.keyGenerationSamples(func(s i2p.KeyGenerationStats) float64 { return s.Total.Seconds() }))
+
+	p.metrics.NewGaugeFunc("i2p_key_generation_slowest_seconds",
+		"Longest I2P destination key generation since startup.",
+		nil, p.keyGenerationSamples(func(s i2p.KeyGenerationStats) float64 { return s.Slowest.Seconds() }))
+
+	p.metrics.NewGaugeFunc("i2p_key_generation_duration_seconds",
+		"Duration of each container's last I2P destination key generation; 0 for keys from the pool.",
+		[]string{"container", "alias"}, p.containerKeyGenerationSamples(func(c i2p.ContainerKeyGeneration) (float64, bool) {
+			return c.Duration.Seconds(), c.Duration > 0 || c.Pooled
+		}))
+
+	p.metrics.NewCounterFunc("i2p_key_generation_container_failures_total",
+		"Failed I2P destination key generations per container.",
+		[]string{"container", "alias"}, p.containerKeyGenerationSamples(func(c i2p.ContainerKeyGeneration) (float64, bool) {
+			return float64(c.Failures), c.Failures > 0
+		}))
+
+	p.metrics.NewGaugeFunc("i2p_key_pool_keys",
+		"Pre-generated I2P destination keys in the key pool.",
+		nil, p.keyGenerationSamples(func(s i2p.KeyGenerationStats) float64 { return float64(s.Pooled) }))
+
+	p.metrics.NewCounterFunc("i2p_key_pool_requests_total",
+		"Sessions that asked the key pool for keys, by result (hit or miss).",
+		[]string{"result"}, p.keyPoolRequestSamples)
+
+	p.metrics.NewGaugeFunc("i2p_exposure_queue_depth",
+		"I2P ports queued until the router recovers from overload.",
+		nil, func() []metrics.Sample {
+			return []metrics.Sample{{Value: float64(p.networkMgr.serviceMgr.BacklogDepth())}}
+		})
+
+	p.metrics.NewGaugeFunc("i2p_router_overloaded",
+		"Whether new I2P exposures are queued because the router is overloaded (1) or created (0).",
+		nil, func() []metrics.Sample {
+			if p.networkMgr.serviceMgr.Overloaded() {
+				return []metrics.Sample{{Value: 1}}
+			}
+			return []metrics.Sample{{Value: 0}}
+		})
+
+	p.metrics.NewGaugeFunc("i2p_plugin_info",
+		"Plugin build and negotiated SAM protocol version; always 1.",
+		[]string{"version", "commit", "sam_version"}, p.buildInfoSamples)
+}
+
+// logBufferSamples returns a collect function reporting one value of the
+// traffic log buffer.
+func (p *Plugin) logBufferSamples(value func(proxy.LogBufferStats) float64) func() []metrics.Sample {
+	return func() []metrics.Sample {
+		return []metrics.Sample{{Value: value(p.networkMgr.proxyMgr.GetLogBufferStats())}}
+	}
+}
+
+// dnsShedSamples reports the DNS queries shed per limit.
+func (p *Plugin) dnsShedSamples() []metrics.Sample {
+	shed := p.networkMgr.proxyMgr.GetDNSLoadStats().Shed
+	return []metrics.Sample{
+		{LabelValues: []string{proxy.DNSShedSource}, Value: float64(shed[proxy.DNSShedSource])},
+		{LabelValues: []string{proxy.DNSShedGlobal}, Value: float64(shed[proxy.DNSShedGlobal])},
+	}
+}
+
+// ipamSamples returns a collect function reporting one value per network pool.
+func (p *Plugin) ipamSamples(value func(IPAllocatorStats) float64) func() []metrics.Sample {
+	return func() []metrics.Sample {
+		stats := p.networkMgr.ipamStats()
+
+		networkIDs := make([]string, 0, len(stats))
+		for networkID := range stats {
+			networkIDs = append(networkIDs, networkID)
+		}
+		sort.Strings(networkIDs)
+
+		samples := make([]metrics.Sample, 0, len(stats))
+		for _, networkID := range networkIDs {
+			tag := p.networkMgr.networkTag(networkID)
+			samples = append(samples, metrics.Sample{
+				LabelValues: []string{networkID, tag.Name, tag.Tenant, stats[networkID].Strategy},
+				Value:       value(stats[networkID]),
+			})
+		}
+		return samples
+	}
+}
+
+// ipamStats returns IP pool utilization for every network.
+func (nm *NetworkManager) ipamStats() map[string]IPAllocatorStats {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	stats := make(map[string]IPAllocatorStats, len(nm.networks))
+	for networkID, network := range nm.networks {
+		if network.IPAllocator != nil {
+			stats[networkID] = network.IPAllocator.Stats()
+		}
+	}
+	return stats
+}
+
+// handlerDurations returns the histogram of plugin API handler durations.
+func (p *Plugin) handlerDurations() *metrics.HistogramVec {
+	return p.metrics.NewHistogramVec("i2p_plugin_handler_duration_seconds",
+		"Time spent handling Docker plugin API calls.", metrics.DefaultDurationBuckets, "operation")
+}
+
+// handlerErrors returns the counter of plugin API calls that returned an error.
+func (p *Plugin) handlerErrors() *metrics.CounterVec {
+	return p.metrics.NewCounterVec("i2p_plugin_handler_errors_total",
+		"Docker plugin API calls that returned an error.", "operation")
+}
+
+// handlerOperation names the operation of a plugin API path, e.g. NetworkDriver.Join.
+func handlerOperation(path string) string {
+	return strings.TrimPrefix(path, "/")
+}
+
+// instrumentHandler records the duration and outcome of every call to next.
+//
+// Docker plugin handlers report failures in the Err field of a 200 response,
+// so a call counts as an error when it has an error status or a non-empty Err.
+func (p *Plugin) instrumentHandler(path string, next http.HandlerFunc) http.HandlerFunc {
+	operation := handlerOperation(path)
+	durations := p.handlerDurations()
+	failures := p.handlerErrors()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		recorder := &metricsRecorder{ResponseWriter: w}
+		start := time.Now()
+
+		next(recorder, r)
+
+		durations.Observe(time.Since(start).Seconds(), operation)
+		if recorder.failed() {
+			failures.Inc(operation)
+		}
+	}
+}
+
+// metricsRecorder captures the status and body a handler writes.
+type metricsRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader records the status code before passing it on.
+func (r *metricsRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body before passing it on.
+func (r *metricsRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+// failed reports whether the recorded response is an error.
+func (r *metricsRecorder) failed() bool {
+	if r.status >= http.StatusBadRequest {
+		return true
+	}
+
+	var response ErrorResponse
+	if err := json.Unmarshal(r.body.Bytes(), &response); err != nil {
+		return false
+	}
+	return response.Err != ""
+}
@@ -0,0 +1,145 @@
+package plugin
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/metrics"
+)
+
+func TestInstrumentHandler(t *testing.T) {
+	p := &Plugin{metrics: metrics.NewRegistry()}
+
+	tests := []struct {
+		name      string
+		path      string
+		handler   http.HandlerFunc
+		wantError bool
+	}{
+		{
+			name: "success",
+			path: "/NetworkDriver.Join",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				p.writeJSONResponse(w, JoinResponse{})
+			},
+		},
+		{
+			name: "error in body",
+			path: "/NetworkDriver.Leave",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				p.writeJSONResponse(w, ErrorResponse{Err: "endpoint not found"})
+			},
+			wantError: true,
+		},
+		{
+			name: "error status",
+			path: "/NetworkDriver.CreateNetwork",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "bad request", http.StatusBadRequest)
+			},
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := p.instrumentHandler(tt.path, tt.handler)
+			handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, tt.path, nil))
+
+			operation := handlerOperation(tt.path)
+			if got := p.handlerDurations().Count(operation); got != 1 {
+				t.Errorf("Expected 1 duration observation for %s, got %d", operation, got)
+			}
+
+			wantErrors := 0.0
+			if tt.wantError {
+				wantErrors = 1
+			}
+			if got := p.handlerErrors().Value(operation); got != wantErrors {
+				t.Errorf("Expected %v errors for %s, got %v", wantErrors, operation, got)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := p.metrics.WritePrometheus(&buf); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+	for _, expected := range []string{
+		`i2p_plugin_handler_duration_seconds_count{operation="NetworkDriver.Join"} 1`,
+		`i2p_plugin_handler_errors_total{operation="NetworkDriver.Leave"} 1`,
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestTrafficLogMetrics(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+
+	var buf bytes.Buffer
+	if err := p.metrics.WritePrometheus(&buf); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+	for _, expected := range []string{
+		"i2p_traffic_log_capacity 1000\n",
+		"i2p_traffic_log_entries ",
+		"i2p_traffic_log_written_total ",
+		"i2p_traffic_log_evicted_total 0\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestDNSLoadMetrics(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+
+	var buf bytes.Buffer
+	if err := p.metrics.WritePrometheus(&buf); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+	for _, expected := range []string{
+		"i2p_dns_queries_total 0\n",
+		`i2p_dns_queries_shed_total{limit="source"} 0` + "\n",
+		`i2p_dns_queries_shed_total{limit="global"} 0` + "\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestKeyGenerationMetrics(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	if err := p.SetKeyGeneration(i2p.KeyGenerationConfig{PoolSize: 4}); err != nil {
+		t.Fatalf("SetKeyGeneration() unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := p.metrics.WritePrometheus(&buf); err != nil {
+		t.Fatalf("Failed to write metrics: %v", err)
+	}
+	for _, expected := range []string{
+		"i2p_key_generations_total 0\n",
+		"i2p_key_generation_failures_total 0\n",
+		"i2p_key_generation_slow_total 0\n",
+		"i2p_key_pool_keys 0\n",
+		`i2p_key_pool_requests_total{result="hit"} 0` + "\n",
+		`i2p_key_pool_requests_total{result="miss"} 0` + "\n",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, buf.String())
+		}
+	}
+
+	if err := p.SetKeyGeneration(i2p.KeyGenerationConfig{PoolSize: i2p.MaxKeyPoolSize + 1}); err == nil {
+		t.Errorf("Expected an oversized key pool to be rejected")
+	}
+}
@@ -0,0 +1,100 @@
+// Package plugin provides per-network MTUs.
+//
+// Streams through I2P tunnels cross several hops, and containers on long
+// paths or behind encapsulating links do better with a smaller MTU than the
+// 1500 bytes interfaces get by default. `-o i2p.mtu=<bytes>` sets the MTU of a
+// network's bridge and of the interface every joined container gets. Docker's
+// standard com.docker.network.driver.mtu option is accepted as well; if both
+// are given they must agree. Interfaces are only created with the ip link
+// backend, so the MTU has no effect with the noop backend.
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MTUOption is the network option setting the MTU of the network's interfaces
+	MTUOption = "i2p.mtu"
+	// dockerMTUOption is Docker's standard driver option for the MTU
+	dockerMTUOption = "com.docker.network.driver.mtu"
+)
+
+const (
+	// minMTU is the smallest MTU accepted, the minimum IPv4 datagram size
+	// every host must accept
+	minMTU = 576
+	// minMTUIPv6 is the smallest MTU IPv6 works with
+	minMTUIPv6 = 1280
+	// maxMTU is the largest MTU accepted, the jumbo frame size most NICs
+	// support
+	maxMTU = 9216
+)
+
+// parseMTU returns the MTU selected by network options; zero keeps the
+// kernel default. dualStack raises the minimum to what IPv6 requires.
+func parseMTU(options map[string]interface{}, dualStack bool) (int, error) {
+	mtu, err := parseMTUValue(options, MTUOption)
+	if err != nil {
+		return 0, err
+	}
+	dockerMTU, err := parseMTUValue(options, dockerMTUOption)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case mtu == 0:
+		mtu = dockerMTU
+	case dockerMTU != 0 && dockerMTU != mtu:
+		return 0, fmt.Errorf("%s %d conflicts with %s %d", MTUOption, mtu, dockerMTUOption, dockerMTU)
+	}
+	if mtu == 0 {
+		return 0, nil
+	}
+
+	minimum := minMTU
+	if dualStack {
+		minimum = minMTUIPv6
+	}
+	if mtu < minimum || mtu > maxMTU {
+		return 0, fmt.Errorf("invalid MTU %d: must be between %d and %d", mtu, minimum, maxMTU)
+	}
+	return mtu, nil
+}
+
+// parseMTUValue returns the whole number an MTU option is set to, or zero.
+func parseMTUValue(options map[string]interface{}, name string) (int, error) {
+	switch value := options[name].(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		if float64(int(value)) != value {
+			return 0, fmt.Errorf("invalid %s value %v: expected a whole number", name, value)
+		}
+		return int(value), nil
+	case int:
+		return value, nil
+	case string:
+		if strings.TrimSpace(value) == "" {
+			return 0, nil
+		}
+		parsed, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s value %q: expected a whole number", name, value)
+		}
+		return parsed, nil
+	default:
+		return 0, fmt.Errorf("invalid %s value %v: expected a whole number", name, value)
+	}
+}
+
+// warnUnappliedMTULocked logs that a network's MTU cannot be applied because
+// the plugin creates no interfaces. The caller must hold nm.mutex.
+func (nm *NetworkManager) warnUnappliedMTULocked(network *I2PNetwork) {
+	if network.MTU != 0 && nm.linkBackend != LinkBackendNetlink {
+		log.Printf("Warning: Network %s has MTU %d, but the %s link backend creates no interfaces to apply it to", network.ID, network.MTU, nm.linkBackend)
+	}
+}
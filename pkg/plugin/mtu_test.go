@@ -0,0 +1,79 @@
+package plugin
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParseMTU(t *testing.T) {
+	tests := []struct {
+		name      string
+		options   map[string]interface{}
+		dualStack bool
+		expected  int
+		wantErr   bool
+	}{
+		{name: "unset", options: map[string]interface{}{}},
+		{name: "option", options: map[string]interface{}{MTUOption: "1400"}, expected: 1400},
+		{name: "docker option", options: map[string]interface{}{dockerMTUOption: "1380"}, expected: 1380},
+		{name: "both agree", options: map[string]interface{}{MTUOption: "1400", dockerMTUOption: "1400"}, expected: 1400},
+		{name: "both conflict", options: map[string]interface{}{MTUOption: "1400", dockerMTUOption: "1500"}, wantErr: true},
+		{name: "json number", options: map[string]interface{}{MTUOption: float64(1420)}, expected: 1420},
+		{name: "too small", options: map[string]interface{}{MTUOption: "500"}, wantErr: true},
+		{name: "too small for IPv6", options: map[string]interface{}{MTUOption: "1200"}, dualStack: true, wantErr: true},
+		{name: "too large", options: map[string]interface{}{MTUOption: "65000"}, wantErr: true},
+		{name: "not a number", options: map[string]interface{}{MTUOption: "small"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mtu, err := parseMTU(tt.options, tt.dualStack)
+			if (err != nil) != tt.wantErr || mtu != tt.expected {
+				t.Errorf("parseMTU() = %d, %v; want %d (error %v)", mtu, err, tt.expected, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNetworkMTUAppliedToLinks(t *testing.T) {
+	t.Parallel()
+
+	nm, links := newLinkTestManager(t)
+
+	_, subnet, _ := net.ParseCIDR("10.41.0.0/24")
+	gateway := net.ParseIP("10.41.0.1")
+	network := &I2PNetwork{
+		ID:            "net1",
+		Subnet:        subnet,
+		Gateway:       gateway,
+		Endpoints:     map[string]*I2PEndpoint{},
+		IPAllocator:   NewIPAllocator(subnet, gateway),
+		TunnelManager: nm.tunnelMgr,
+		MTU:           1400,
+	}
+	if err := nm.createBridgeLocked(network); err != nil {
+		t.Fatalf("createBridgeLocked() unexpected error: %v", err)
+	}
+	nm.addNetworkLocked(network)
+	if _, err := nm.CreateEndpoint("net1", "ep1", nil); err != nil {
+		t.Fatalf("CreateEndpoint() unexpected error: %v", err)
+	}
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "/var/run/docker/netns/abc", nil); err != nil {
+		t.Fatalf("JoinEndpoint() unexpected error: %v", err)
+	}
+
+	commands := strings.Join(links.commands, "\n")
+	for _, want := range []string{
+		"link set dev i2pbr-net1 mtu 1400",
+		"link set dev i2pcep1 mtu 1400",
+		"link set dev i2phep1 mtu 1400",
+	} {
+		if !strings.Contains(commands, want) {
+			t.Errorf("Expected %q, got %v", want, links.commands)
+		}
+	}
+	if view := network.adminView(); view.MTU != 1400 {
+		t.Errorf("Expected the admin view to report the MTU, got %d", view.MTU)
+	}
+}
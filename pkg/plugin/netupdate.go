@@ -0,0 +1,380 @@
+// Package plugin provides live updates of a network's traffic filter options.
+//
+// Filter policy is usually kept with the rest of a network's definition, as
+// the -o options of docker network create in a Compose file or Terraform
+// module. Docker cannot change the options of an existing network, so the
+// admin API accepts the same options for a running network, diffs them
+// against the ones it was created with and applies the differences live: the
+// filter mode is switched and allowlist and blocklist entries are added and
+// removed through the same atomic batch steps as POST /v1/filters/batch, so a
+// failed update leaves the filter as it was.
+//
+// Only the filter options and the external services can change. Other
+// options may be omitted, in which case the network keeps them, but must not
+// differ from their current values. Filter options follow docker network
+// create: an omitted filter option is unset, so its entries are removed and
+// the mode falls back to its default.
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+// Traffic filter network options.
+const (
+	FilterModeOption      = "i2p.filter.mode"
+	FilterAllowlistOption = "i2p.filter.allowlist"
+	FilterBlocklistOption = "i2p.filter.blocklist"
+)
+
+// errUpdateNetworkNotFound is returned when the network to update does not exist.
+var errUpdateNetworkNotFound = errors.New("network not found")
+
+// NetworkUpdateRequest re-applies options to a running network.
+type NetworkUpdateRequest struct {
+	// Options are the network options, as passed with docker network create -o
+	Options map[string]string `json:"options"`
+	// DryRun reports the changes without applying them
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// NetworkUpdate describes the changes of a network update.
+type NetworkUpdate struct {
+	// Network is the ID of the updated network
+	Network string `json:"network"`
+	// DryRun reports whether the changes were only planned
+	DryRun bool `json:"dry_run,omitempty"`
+	// FilterMode is allowlist, blocklist or disabled after the update
+	FilterMode string `json:"filter_mode"`
+	// FilterModeChanged reports whether the update switches the filter mode
+	FilterModeChanged bool `json:"filter_mode_changed"`
+	// Allowlist lists the changes to the allowlist
+	Allowlist FilterListChange `json:"allowlist"`
+	// Blocklist lists the changes to the blocklist
+	Blocklist FilterListChange `json:"blocklist"`
+	// ExternalServices lists the changed external services as name=address entries
+	ExternalServices FilterListChange `json:"external_services"`
+}
+
+// FilterListChange lists the changes to one filter list.
+type FilterListChange struct {
+	// Added are the entries added to the list
+	Added []string `json:"added,omitempty"`
+	// Removed are the entries removed from the list
+	Removed []string `json:"removed,omitempty"`
+	// Kept are entries dropped from the network's options that stay on the
+	// list because another network's options still list them
+	Kept []string `json:"kept,omitempty"`
+}
+
+// filterOptions are the options a network update may change.
+var filterOptions = map[string]bool{
+	FilterModeOption:       true,
+	FilterAllowlistOption:  true,
+	FilterBlocklistOption:  true,
+	ExternalServicesOption: true,
+}
+
+// filterMode returns the mode name of a filter configuration.
+func filterMode(config *proxy.FilterConfig) string {
+	switch {
+	case config.EnableAllowlist:
+		return "allowlist"
+	case config.EnableBlocklist:
+		return "blocklist"
+	default:
+		return "disabled"
+	}
+}
+
+// UpdateNetwork diffs options against those of a running network, by ID or
+// name, and applies the changes to its traffic filter options and external
+// services.
+func (nm *NetworkManager) UpdateNetwork(ref string, req NetworkUpdateRequest) (*NetworkUpdate, error) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	network := nm.lookupNetworkLocked(ref)
+	if network == nil {
+		return nil, fmt.Errorf("%w: %s", errUpdateNetworkNotFound, ref)
+	}
+	return nm.updateNetworkLocked(network, req)
+}
+
+// updateNetworkLocked applies an update to network. The caller must hold
+// nm.mutex.
+func (nm *NetworkManager) updateNetworkLocked(network *I2PNetwork, req NetworkUpdateRequest) (*NetworkUpdate, error) {
+	options, err := nm.updatedOptionsLocked(network, req.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	update := &NetworkUpdate{Network: network.ID, DryRun: req.DryRun}
+	var steps []batchStep
+
+	oldAllow, oldBlock := parseFilterDestinations(network.Options)
+	newAllow, newBlock := parseFilterDestinations(options)
+	steps = append(steps, nm.filterListSteps(network, "allowlist", oldAllow, newAllow, &update.Allowlist)...)
+	steps = append(steps, nm.filterListSteps(network, "blocklist", oldBlock, newBlock, &update.Blocklist)...)
+
+	// Filter modes are global, so only a change of this network's option switches it
+	current := nm.proxyMgr.GetFilterConfig()
+	update.FilterMode = filterMode(&current)
+	if normalizedOption(options[FilterModeOption]) != normalizedOption(network.Options[FilterModeOption]) {
+		config := parseFilterConfig(options)
+		update.FilterMode = filterMode(config)
+		update.FilterModeChanged = update.FilterMode != filterMode(&current)
+		steps = append(steps, batchStep{
+			validate: func() error { return nil },
+			apply: func() (string, error) {
+				nm.proxyMgr.UpdateFilterConfig(config)
+				return "", nil
+			},
+			undo: func() error {
+				nm.proxyMgr.UpdateFilterConfig(&current)
+				return nil
+			},
+		})
+	}
+
+	// External services only change the network's entries in the service table
+	oldServices := network.ExternalServices
+	newServices, _ := parseExternalServices(options)
+	update.ExternalServices = externalServiceChange(oldServices, newServices)
+	if len(update.ExternalServices.Added) > 0 || len(update.ExternalServices.Removed) > 0 {
+		steps = append(steps, batchStep{
+			validate: func() error { return nil },
+			apply: func() (string, error) {
+				nm.proxyMgr.SetExternalServices(network.ID, newServices)
+				return "", nil
+			},
+			undo: func() error {
+				nm.proxyMgr.SetExternalServices(network.ID, oldServices)
+				return nil
+			},
+		})
+	}
+
+	if req.DryRun {
+		return update, nil
+	}
+
+	result, status := runBatch(steps, true)
+	if status != http.StatusOK {
+		for _, item := range result.Results {
+			if item.Error != "" {
+				return nil, fmt.Errorf("failed to update network %s, nothing was changed: %s", network.ID, item.Error)
+			}
+		}
+		return nil, fmt.Errorf("failed to update network %s, nothing was changed", network.ID)
+	}
+
+	updated := make(map[string]interface{}, len(network.Options))
+	for key, value := range network.Options {
+		if !filterOptions[key] {
+			updated[key] = value
+		}
+	}
+	for key := range filterOptions {
+		if value, set := options[key]; set {
+			updated[key] = value
+		}
+	}
+	network.Options = updated
+	network.mutex.Lock()
+	network.ExternalServices = newServices
+	network.mutex.Unlock()
+	nm.saveStateLocked()
+
+	log.Printf("Updated network %s: filter mode %s, allowlist +%d -%d, blocklist +%d -%d, external services +%d -%d", network.ID, update.FilterMode,
+		len(update.Allowlist.Added), len(update.Allowlist.Removed), len(update.Blocklist.Added), len(update.Blocklist.Removed),
+		len(update.ExternalServices.Added), len(update.ExternalServices.Removed))
+	return update, nil
+}
+
+// externalServiceChange lists the external services added, replaced or
+// removed between previous and desired as name=address entries; a replaced
+// service is both removed and added.
+func externalServiceChange(previous, desired map[string]string) FilterListChange {
+	var change FilterListChange
+	for _, name := range sortedKeys(serviceNames(desired)) {
+		if previous[name] != desired[name] {
+			change.Added = append(change.Added, name+"="+desired[name])
+		}
+	}
+	for _, name := range sortedKeys(serviceNames(previous)) {
+		if previous[name] != desired[name] {
+			change.Removed = append(change.Removed, name+"="+previous[name])
+		}
+	}
+	return change
+}
+
+// serviceNames returns the set of names of external services.
+func serviceNames(services map[string]string) map[string]bool {
+	names := make(map[string]bool, len(services))
+	for name := range services {
+		names[name] = true
+	}
+	return names
+}
+
+// updatedOptionsLocked returns the options of network after an update to
+// requested, with the policy template expanded. It fails if an option other
+// than the filter options would change. The caller must hold nm.mutex.
+func (nm *NetworkManager) updatedOptionsLocked(network *I2PNetwork, requested map[string]string) (map[string]interface{}, error) {
+	desired := make(map[string]interface{}, len(network.Options)+len(requested))
+	for key, value := range requested {
+		desired[key] = value
+	}
+	// Omitted options keep their values, except the filter options, which
+	// are unset like they would be by docker network create
+	for key, value := range network.Options {
+		if _, set := desired[key]; !set && !filterOptions[key] {
+			desired[key] = value
+		}
+	}
+
+	options, _, err := applyPolicyTemplate(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	var immutable []string
+	for key, value := range options {
+		if filterOptions[key] {
+			continue
+		}
+		if current, set := network.Options[key]; !set || fmt.Sprint(current) != fmt.Sprint(value) {
+			immutable = append(immutable, key)
+		}
+	}
+	if len(immutable) > 0 {
+		sort.Strings(immutable)
+		return nil, fmt.Errorf("options %s cannot be changed on a running network, recreate it instead (only %s, %s, %s and %s can be updated)",
+			strings.Join(immutable, ", "), FilterModeOption, FilterAllowlistOption, FilterBlocklistOption, ExternalServicesOption)
+	}
+	if _, err := parseExternalServices(options); err != nil {
+		return nil, err
+	}
+
+	switch mode := normalizedOption(options[FilterModeOption]); mode {
+	case "", "allowlist", "blocklist", "disabled":
+	default:
+		return nil, fmt.Errorf("invalid %s value %q: expected allowlist, blocklist or disabled", FilterModeOption, mode)
+	}
+	return options, nil
+}
+
+// filterListSteps builds the batch steps that turn a network's entries of a
+// filter list from previous into desired, recording them in change.
+//
+// Entries dropped from the options stay on the list if another network's
+// options list them, or if they were already removed. The caller must hold
+// nm.mutex.
+func (nm *NetworkManager) filterListSteps(network *I2PNetwork, list string, previous, desired []string, change *FilterListChange) []batchStep {
+	current := nm.proxyMgr.GetAllowlist()
+	if list == "blocklist" {
+		current = nm.proxyMgr.GetBlocklist()
+	}
+	onList := lowerSet(current)
+	before, after := lowerSet(previous), lowerSet(desired)
+
+	var steps []batchStep
+	for _, destination := range sortedKeys(after) {
+		if before[destination] {
+			continue
+		}
+		change.Added = append(change.Added, destination)
+		steps = append(steps, nm.filterStep(FilterOperation{Action: "add", List: list, Destination: destination}))
+	}
+	for _, destination := range sortedKeys(before) {
+		if after[destination] || !onList[destination] {
+			continue
+		}
+		if nm.filterEntrySharedLocked(network, list, destination) {
+			change.Kept = append(change.Kept, destination)
+			continue
+		}
+		change.Removed = append(change.Removed, destination)
+		steps = append(steps, nm.filterStep(FilterOperation{Action: "remove", List: list, Destination: destination}))
+	}
+	return steps
+}
+
+// filterEntrySharedLocked reports whether a network other than network lists
+// destination on a filter list in its options. The caller must hold nm.mutex.
+func (nm *NetworkManager) filterEntrySharedLocked(network *I2PNetwork, list, destination string) bool {
+	for _, other := range nm.networks {
+		if other == network {
+			continue
+		}
+		allowlist, blocklist := parseFilterDestinations(other.Options)
+		entries := allowlist
+		if list == "blocklist" {
+			entries = blocklist
+		}
+		if lowerSet(entries)[destination] {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizedOption returns an option value in lower case without surrounding
+// spaces, or an empty string if it is unset.
+func normalizedOption(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(fmt.Sprint(value)))
+}
+
+// lowerSet returns the set of the lower-cased, non-empty values.
+func lowerSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			set[value] = true
+		}
+	}
+	return set
+}
+
+// sortedKeys returns the keys of a set in order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// handleAdminUpdateNetwork applies options to a running network.
+func (p *Plugin) handleAdminUpdateNetwork(w http.ResponseWriter, r *http.Request) {
+	var req NetworkUpdateRequest
+	if err := p.readJSONRequest(r, &req); err != nil {
+		p.writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	update, err := p.networkMgr.UpdateNetwork(r.PathValue("id"), req)
+	if errors.Is(err, errUpdateNetworkNotFound) {
+		p.writeAdminError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		p.writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	p.writeJSONResponse(w, update)
+}
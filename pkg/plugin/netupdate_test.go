@@ -0,0 +1,133 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUpdateNetworkFilters(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	nm := p.networkMgr
+	proxyMgr := nm.proxyMgr
+
+	update, err := nm.UpdateNetwork("i2p-test", NetworkUpdateRequest{Options: map[string]string{
+		FilterModeOption:      "allowlist",
+		FilterAllowlistOption: "a.i2p, B.i2p",
+	}})
+	if err != nil {
+		t.Fatalf("UpdateNetwork() unexpected error: %v", err)
+	}
+	if !update.FilterModeChanged || update.FilterMode != "allowlist" {
+		t.Errorf("Expected the filter mode to switch to allowlist, got %+v", update)
+	}
+	if !slices.Equal(update.Allowlist.Added, []string{"a.i2p", "b.i2p"}) {
+		t.Errorf("Expected a.i2p and b.i2p to be added, got %v", update.Allowlist.Added)
+	}
+	if config := proxyMgr.GetFilterConfig(); !config.EnableAllowlist {
+		t.Error("Expected the allowlist to be enabled")
+	}
+
+	// Dropping an entry removes it; a dry run changes nothing
+	request := NetworkUpdateRequest{Options: map[string]string{FilterModeOption: "allowlist", FilterAllowlistOption: "b.i2p"}, DryRun: true}
+	update, err = nm.UpdateNetwork("net1", request)
+	if err != nil {
+		t.Fatalf("UpdateNetwork() unexpected error: %v", err)
+	}
+	if !slices.Equal(update.Allowlist.Removed, []string{"a.i2p"}) || update.FilterModeChanged {
+		t.Errorf("Expected a.i2p to be removed without a mode change, got %+v", update)
+	}
+	if !slices.Contains(proxyMgr.GetAllowlist(), "a.i2p") {
+		t.Error("Expected a dry run to leave the allowlist unchanged")
+	}
+
+	request.DryRun = false
+	if _, err := nm.UpdateNetwork("net1", request); err != nil {
+		t.Fatalf("UpdateNetwork() unexpected error: %v", err)
+	}
+	if slices.Contains(proxyMgr.GetAllowlist(), "a.i2p") || !slices.Contains(proxyMgr.GetAllowlist(), "b.i2p") {
+		t.Errorf("Expected only b.i2p on the allowlist, got %v", proxyMgr.GetAllowlist())
+	}
+
+	// Entries another network lists stay on the list
+	addTenantNetwork(p)
+	nm.GetNetwork("net2").Options = map[string]interface{}{FilterAllowlistOption: "b.i2p"}
+	update, err = nm.UpdateNetwork("net1", NetworkUpdateRequest{Options: map[string]string{FilterModeOption: "allowlist"}})
+	if err != nil {
+		t.Fatalf("UpdateNetwork() unexpected error: %v", err)
+	}
+	if !slices.Equal(update.Allowlist.Kept, []string{"b.i2p"}) || len(update.Allowlist.Removed) != 0 {
+		t.Errorf("Expected b.i2p to be kept for net2, got %+v", update.Allowlist)
+	}
+	if !slices.Contains(proxyMgr.GetAllowlist(), "b.i2p") {
+		t.Error("Expected the shared entry to stay on the allowlist")
+	}
+}
+
+func TestUpdateNetworkRejectsChanges(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	nm := p.networkMgr
+	nm.GetNetwork("net1").Options = map[string]interface{}{IsolatedOption: "false", FilterBlocklistOption: "bad.i2p"}
+	if err := nm.proxyMgr.AddToBlocklist("bad.i2p"); err != nil {
+		t.Fatalf("Failed to seed blocklist: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		options map[string]string
+		errText string
+	}{
+		{name: "other option", options: map[string]string{IsolatedOption: "true"}, errText: "cannot be changed"},
+		{name: "new option", options: map[string]string{MaxEndpointsOption: "5"}, errText: "cannot be changed"},
+		{name: "unknown mode", options: map[string]string{FilterModeOption: "strict"}, errText: "expected allowlist, blocklist or disabled"},
+		{name: "invalid destination", options: map[string]string{FilterBlocklistOption: "good.i2p,example.com"}, errText: "nothing was changed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := nm.UpdateNetwork("net1", NetworkUpdateRequest{Options: tt.options})
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Fatalf("Expected an error containing %q, got %v", tt.errText, err)
+			}
+		})
+	}
+
+	// Failed updates are rolled back
+	blocklist := nm.proxyMgr.GetBlocklist()
+	if !slices.Equal(blocklist, []string{"bad.i2p"}) {
+		t.Errorf("Expected the blocklist to be unchanged, got %v", blocklist)
+	}
+	if value := nm.GetNetwork("net1").Options[FilterBlocklistOption]; value != "bad.i2p" {
+		t.Errorf("Expected the network options to be unchanged, got %v", value)
+	}
+}
+
+func TestAdminUpdateNetwork(t *testing.T) {
+	_, mux := newAdminTestPlugin(t)
+
+	post := func(path string, req NetworkUpdateRequest) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(string(data))))
+		return w
+	}
+
+	w := post("/v1/networks/i2p-test/update", NetworkUpdateRequest{Options: map[string]string{FilterBlocklistOption: "bad.i2p"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var update NetworkUpdate
+	if err := json.Unmarshal(w.Body.Bytes(), &update); err != nil || update.Network != "net1" {
+		t.Errorf("Expected the update of net1, got %q (%v)", w.Body.String(), err)
+	}
+
+	if w := post("/v1/networks/missing/update", NetworkUpdateRequest{}); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown network, got %d", w.Code)
+	}
+	if w := post("/v1/networks/net1/update", NetworkUpdateRequest{Options: map[string]string{IsolatedOption: "true"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an immutable option, got %d", w.Code)
+	}
+}
@@ -8,8 +8,11 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
 	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
@@ -34,6 +37,17 @@ type I2PNetwork struct {
 	// Gateway is the gateway IP address for this network
 	Gateway net.IP
 
+	// SubnetIPv6 is the network's IPv6 subnet, a unique local address range;
+	// nil unless the network was created with --ipv6
+	SubnetIPv6 *net.IPNet
+
+	// GatewayIPv6 is the IPv6 gateway address, nil without SubnetIPv6
+	GatewayIPv6 net.IP
+
+	// Bridge is the bridge the network's endpoints are attached to; empty
+	// unless the ip link backend is in use (see SetLinkBackend)
+	Bridge string
+
 	// TunnelManager handles I2P tunnel creation and management
 	TunnelManager *i2p.TunnelManager
 
@@ -43,13 +57,90 @@ type I2PNetwork struct {
 	// IPAllocator manages IP address allocation for containers
 	IPAllocator *IPAllocator
 
+	// IPAMPool is the IPAM driver pool the network was created on; empty if
+	// its addresses are not managed by the plugin's IPAM driver
+	IPAMPool string
+
+	// IPv6Allocator manages IPv6 address allocation for containers; nil
+	// without SubnetIPv6
+	IPv6Allocator *IPAllocator
+
+	// IPv6Pool is the IPAM driver pool of the IPv6 subnet, like IPAMPool
+	IPv6Pool string
+
 	// Options stores network-level configuration options
 	Options map[string]interface{}
 
 	// ExposureConfig defines service exposure defaults for this network
 	ExposureConfig service.NetworkExposureConfig
 
-	// mutex protects concurrent access to network state
+	// SidecarConfig defines Unix socket path templates for sidecar containers
+	SidecarConfig SidecarConfig
+
+	// ProxyBindIP is the address the network's SOCKS proxy and DNS resolver
+	// listen on (see the i2p.proxy.bind option)
+	ProxyBindIP net.IP
+
+	// AntiSpoof binds each joined endpoint's IP and MAC addresses to each
+	// other with firewall rules (see the i2p.antispoof option)
+	AntiSpoof bool
+
+	// Isolated blocks direct traffic between the network's containers
+	// (see the i2p.isolated option)
+	Isolated bool
+
+	// Internal drops traffic leaving the network except to the SAM bridges
+	// (docker network create --internal, see InternalOption)
+	Internal bool
+
+	// MTU is the MTU of the network's bridge and endpoint interfaces; zero
+	// keeps the kernel default (see the i2p.mtu option)
+	MTU int
+
+	// MaxEndpoints limits the number of endpoints on the network; zero means
+	// unlimited (see the i2p.endpoints.max option)
+	MaxEndpoints int
+
+	// TunnelOverrides override tunnel options for the sessions of the
+	// network's containers (see the i2p.tunnel.* options)
+	TunnelOverrides map[string]string
+	// ExternalServices maps the names of external I2P services containers
+	// on the network reach to their b32 addresses
+	ExternalServices map[string]string
+
+	// Policy is the policy template the network was created with, or nil
+	// (see the i2p.policy option)
+	Policy *NetworkPolicy
+
+	// Tenant is the tenant the network's telemetry is tagged with, if any
+	// (see the i2p.tenant option)
+	Tenant string
+
+	// EndpointLimitRejections counts endpoints refused by MaxEndpoints
+	EndpointLimitRejections uint64
+
+	// Stopped is set while the network's proxy listeners are stopped through
+	// the admin API (see StopNetwork)
+	Stopped bool
+
+	// Restored is set for a network recovered from the state file until
+	// Docker creates it again (see restoreState)
+	Restored bool
+
+	// deleted is set once DeleteNetwork removed the network. Operations
+	// that found the network before and then wait for its mutex fail as if
+	// it did not exist (see updateEndpoints)
+	deleted bool
+
+	// savedEndpoints are the endpoints last written to the state file,
+	// protected by nm.stateMutex (see saveStateLocked)
+	savedEndpoints []endpointRecord
+
+	// mutex protects the network's endpoints. Endpoint operations hold it
+	// for writing, but not nm.mutex once they found the network (see
+	// updateEndpoints), so operations on different networks run
+	// concurrently and a slow join does not hold up the others; it is never
+	// held while taking nm.mutex or the mutex of another network
 	mutex sync.RWMutex
 }
 
@@ -64,15 +155,39 @@ type I2PEndpoint struct {
 	// NetworkID identifies the network this endpoint belongs to
 	NetworkID string
 
+	// State is the endpoint's position in its lifecycle
+	State EndpointState
+
+	// AllocationKey is passed to the network's allocation strategy whenever
+	// the endpoint needs an address (see the i2p.ipam.sticky_key option)
+	AllocationKey string
+
 	// ContainerID identifies the container using this endpoint
 	ContainerID string
 
 	// IPAddress is the assigned IP address for this endpoint
 	IPAddress net.IP
 
+	// RequestedAddress is the address Docker asked for when the endpoint was
+	// created (e.g. docker run --ip), reserved again when a parked endpoint
+	// rejoins; nil if the address was allocated by the plugin
+	RequestedAddress net.IP
+
+	// IPv6Address is the assigned IPv6 address, nil on networks without an
+	// IPv6 subnet
+	IPv6Address net.IP
+
+	// RequestedIPv6Address is the IPv6 address Docker asked for, like
+	// RequestedAddress
+	RequestedIPv6Address net.IP
+
 	// MacAddress is the assigned MAC address for this endpoint
 	MacAddress string
 
+	// Interface is the container end of the endpoint's veth pair, which
+	// Docker moves into the container; empty unless the network has a bridge
+	Interface string
+
 	// ClientTunnels are I2P client tunnels for outbound connections
 	ClientTunnels map[string]*i2p.Tunnel
 
@@ -81,8 +196,71 @@ type I2PEndpoint struct {
 
 	// ServiceExposures contains I2P addresses for exposed services
 	ServiceExposures []*service.ServiceExposure
+
+	// PortMappings are client port maps forwarding fixed local ports to I2P destinations
+	PortMappings []*service.PortMapping
+
+	// SidecarSockets are Unix sockets serving the proxy to this endpoint (nil if disabled)
+	SidecarSockets *proxy.SidecarSockets
+
+	// ClientOnly is set for containers labelled i2p.mode=client, which reach
+	// I2P through the proxy but never get server tunnels of their own
+	ClientOnly bool
+
+	// OutboundDisabled is set for containers labelled i2p.outbound=false,
+	// which serve I2P but may not open connections through the proxy
+	OutboundDisabled bool
+
+	// IsolationGroups are the i2p.isolation.group labels of the container;
+	// on isolated networks, endpoints sharing a group may reach each other
+	IsolationGroups []string
+
+	// Identity is the identity the container keeps across recreation, empty
+	// if it has none (see IdentityLabel)
+	Identity string
+
+	// Alias is the name the container is shown under in place of its ID,
+	// empty if it has none (see AliasLabel)
+	Alias string
+
+	// Aliases are the network aliases Docker passed for the endpoint, which
+	// resolve to its addresses on the network (see EndpointAliasesOption)
+	Aliases []string
+
+	// SandboxKey is the network namespace of the joined container
+	SandboxKey string
+
+	// JoinOptions are the options of the Join that attached the container,
+	// kept so the join can be replayed after a plugin restart
+	JoinOptions map[string]interface{}
+
+	// Restored is set for an endpoint recovered from the state file until
+	// Docker refers to it again (see restoreState)
+	Restored bool
 }
 
+// EndpointState describes where an endpoint is in Docker's endpoint lifecycle.
+//
+// Docker drives endpoints through CreateEndpoint → Join → Leave → DeleteEndpoint,
+// but may Join a left endpoint again before deleting it. The states are:
+//
+//	created → joined    Join attaches a container to a new endpoint
+//	joined  → parked    Leave detaches the container and frees the IP address
+//	parked  → joined    Join reattaches the endpoint with a freshly allocated IP
+//	any     → deleted   DeleteEndpoint releases everything and removes the endpoint
+type EndpointState string
+
+const (
+	// EndpointCreated is a new endpoint holding an IP address but no container
+	EndpointCreated EndpointState = "created"
+	// EndpointJoined is an endpoint attached to a container
+	EndpointJoined EndpointState = "joined"
+	// EndpointParked is a left endpoint kept for reuse without an IP address
+	EndpointParked EndpointState = "parked"
+	// EndpointDeleted is an endpoint that has been removed from its network
+	EndpointDeleted EndpointState = "deleted"
+)
+
 // NetworkManager manages I2P networks and their lifecycle.
 //
 // The NetworkManager maintains network state and coordinates between Docker's
@@ -92,6 +270,9 @@ type NetworkManager struct {
 	// networks tracks all active I2P networks
 	networks map[string]*I2PNetwork
 
+	// networkNames maps user-visible network names to network IDs
+	networkNames map[string]string
+
 	// tunnelMgr provides I2P tunnel management capabilities
 	tunnelMgr *i2p.TunnelManager
 
@@ -101,10 +282,56 @@ type NetworkManager struct {
 	// serviceMgr handles I2P service exposure for containers
 	serviceMgr *service.ServiceExposureManager
 
-	// defaultSubnet defines the base subnet for I2P networks
+	// identities remembers the keys of containers with an identity across recreation
+	identities *identityStore
+
+	// statePath is the file networks and endpoints are persisted to (empty
+	// keeps them in memory)
+	statePath string
+
+	// sandboxRunning reports whether a container's sandbox still exists
+	// (replaceable in tests)
+	sandboxRunning func(sandboxKey string) bool
+
+	// linkBackend selects how endpoint interfaces are created (see
+	// SetLinkBackend)
+	linkBackend string
+
+	// links changes the host's links (replaceable in tests)
+	links linkOps
+
+	// tags is a snapshot of the networks' telemetry tags, readable without
+	// nm.mutex (see refreshNetworkTagsLocked)
+	tags atomic.Pointer[[]networkTag]
+
+	// defaultSubnet is the pool networks without IPAM data get a /24 of (see
+	// SetDefaultSubnet)
 	defaultSubnet *net.IPNet
 
-	// mutex protects concurrent access to network manager state
+	// ipamPools tracks the pools handed out by the IPAM driver by pool ID
+	ipamPools map[string]*ipamPool
+
+	// joined indexes the joined containers of all networks for endpoint
+	// operations, which cannot look into other networks
+	joined joinedContainers
+
+	// scope is the driver scope, ScopeLocal if empty (see SetScope)
+	scope string
+
+	// mesh holds the peers and services of global scope networks
+	mesh meshDirectory
+
+	// identityMutex serializes identity handoffs between containers joining
+	// different networks (see adoptIdentityLocked)
+	identityMutex sync.Mutex
+
+	// stateMutex serializes writes of the state file (see saveStateLocked)
+	stateMutex sync.Mutex
+
+	// mutex protects the set of networks and their configuration. Creating,
+	// updating and deleting networks hold it for writing; endpoint
+	// operations hold it for reading only to look up their network, which
+	// they lock instead (see I2PNetwork.mutex)
 	mutex sync.RWMutex
 }
 
@@ -117,7 +344,7 @@ func NewNetworkManager(tunnelMgr *i2p.TunnelManager) (*NetworkManager, error) {
 	}
 
 	// Define the default subnet for I2P networks
-	_, defaultSubnet, err := net.ParseCIDR("172.20.0.0/16")
+	_, defaultSubnet, err := net.ParseCIDR(DefaultSubnet)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse default subnet: %w", err)
 	}
@@ -126,101 +353,242 @@ func NewNetworkManager(tunnelMgr *i2p.TunnelManager) (*NetworkManager, error) {
 	proxyConfig := proxy.DefaultProxyConfig(defaultSubnet)
 
 	// Create proxy manager for transparent I2P proxying
-	proxyMgr := proxy.NewProxyManager(proxyConfig, tunnelMgr)
+	proxyMgr := proxy.NewProxyManager(proxy.ProxyOptions{Config: proxyConfig, TunnelManager: tunnelMgr})
 
 	// Create service exposure manager for I2P service exposure
-	serviceMgr, err := service.NewServiceExposureManager(tunnelMgr)
+	serviceMgr, err := service.NewServiceExposureManager(service.ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create service exposure manager: %w", err)
 	}
 
-	return &NetworkManager{
-		networks:      make(map[string]*I2PNetwork),
-		tunnelMgr:     tunnelMgr,
-		proxyMgr:      proxyMgr,
-		serviceMgr:    serviceMgr,
-		defaultSubnet: defaultSubnet,
-	}, nil
+	nm := &NetworkManager{
+		networks:       make(map[string]*I2PNetwork),
+		networkNames:   make(map[string]string),
+		tunnelMgr:      tunnelMgr,
+		proxyMgr:       proxyMgr,
+		serviceMgr:     serviceMgr,
+		identities:     newIdentityStore(),
+		sandboxRunning: sandboxExists,
+		linkBackend:    LinkBackendNoop,
+		links:          netlinkOps{},
+		defaultSubnet:  defaultSubnet,
+		ipamPools:      make(map[string]*ipamPool),
+	}
+	serviceMgr.SetExpiryHandler(nm.removeExpiredExposure)
+	serviceMgr.SetReadyHandler(nm.addDeferredExposure)
+	proxyMgr.SetSourceResolver(nm.resolveProxySource)
+
+	return nm, nil
+}
+
+// removeExpiredExposure drops an exposure whose TTL elapsed from its endpoint.
+func (nm *NetworkManager) removeExpiredExposure(exposure *service.ServiceExposure) {
+	for _, networkID := range nm.ListNetworks() {
+		network := nm.GetNetwork(networkID)
+		if network == nil {
+			continue
+		}
+
+		network.mutex.Lock()
+		for _, endpoint := range network.Endpoints {
+			for i, existing := range endpoint.ServiceExposures {
+				if existing == exposure {
+					endpoint.ServiceExposures = append(endpoint.ServiceExposures[:i:i], endpoint.ServiceExposures[i+1:]...)
+					network.mutex.Unlock()
+					nm.saveState()
+					return
+				}
+			}
+		}
+		network.mutex.Unlock()
+	}
+}
+
+// addDeferredExposure records an exposure created once its dependency ports
+// were listening on the endpoint of its container.
+func (nm *NetworkManager) addDeferredExposure(exposure *service.ServiceExposure) {
+	for _, networkID := range nm.ListNetworks() {
+		network := nm.GetNetwork(networkID)
+		if network == nil {
+			continue
+		}
+
+		network.mutex.Lock()
+		for _, endpoint := range network.Endpoints {
+			if endpoint.ContainerID == exposure.ContainerID && endpoint.State == EndpointJoined {
+				endpoint.ServiceExposures = append(endpoint.ServiceExposures, exposure)
+				network.mutex.Unlock()
+				nm.saveState()
+				return
+			}
+		}
+		network.mutex.Unlock()
+	}
+}
+
+// addQueuedExposures fills in the number of ports each joined endpoint of a
+// network view has queued while the router is overloaded.
+func (nm *NetworkManager) addQueuedExposures(endpoints []AdminEndpoint) {
+	for i := range endpoints {
+		if endpoints[i].ContainerID != "" {
+			endpoints[i].QueuedExposures = nm.serviceMgr.QueuedExposures(endpoints[i].ContainerID)
+		}
+	}
+}
+
+// resolveProxySource maps a proxy client address to the endpoint holding it.
+//
+// Only addresses allocated to endpoints of managed networks resolve; gateways,
+// the host and other Docker networks do not.
+func (nm *NetworkManager) resolveProxySource(ip net.IP) (proxy.ClientSource, bool) {
+	nm.mutex.RLock()
+	networks := make([]*I2PNetwork, 0, len(nm.networks))
+	for _, network := range nm.networks {
+		if network.Subnet != nil && network.Subnet.Contains(ip) {
+			networks = append(networks, network)
+		}
+	}
+	nm.mutex.RUnlock()
+
+	for _, network := range networks {
+		network.mutex.RLock()
+		for _, endpoint := range network.Endpoints {
+			if endpoint.IPAddress != nil && endpoint.IPAddress.Equal(ip) {
+				source := proxy.ClientSource{
+					NetworkID:        network.ID,
+					EndpointID:       endpoint.ID,
+					ContainerID:      endpoint.ContainerID,
+					OutboundDisabled: endpoint.OutboundDisabled,
+				}
+				network.mutex.RUnlock()
+				return source, true
+			}
+		}
+		network.mutex.RUnlock()
+	}
+	return proxy.ClientSource{}, false
 }
 
 // CreateNetwork creates a new I2P network.
 //
 // This method implements Docker's CreateNetwork operation, setting up the
 // network infrastructure including IP allocation and I2P tunnel management.
+// Networks created with the i2p.dryrun option are validated and planned but
+// not created; the plan is returned as the error so Docker shows it.
+//
+// ipamData may hold IPv4 and IPv6 pools. The first IPv4 pool becomes the
+// network's subnet; the first IPv6 pool, which must be a unique local
+// address range, makes it a dual-stack network.
 func (nm *NetworkManager) CreateNetwork(networkID string, options map[string]interface{}, ipamData []IPAMData) error {
 	nm.mutex.Lock()
 	defer nm.mutex.Unlock()
 
-	// Validate network ID
-	if networkID == "" {
-		return fmt.Errorf("network ID cannot be empty")
-	}
-
-	// Check if network already exists
-	if _, exists := nm.networks[networkID]; exists {
-		return fmt.Errorf("network %s already exists", networkID)
+	// Docker may create a network again that was restored from the state file
+	if network, exists := nm.networks[networkID]; exists && network.Restored {
+		network.Restored = false
+		log.Printf("Network %s was restored after a plugin restart, keeping it", networkID)
+		return nil
 	}
 
-	log.Printf("Creating I2P network %s", networkID)
-
-	// Check iptables availability on every network creation (required for traffic filtering).
-	// This enforces the security requirement that iptables must be available at all times,
-	// even if the proxy manager is already running from a previous network creation.
-	// This prevents scenarios where iptables becomes unavailable between network creations.
-	if err := nm.proxyMgr.CheckIptablesAvailability(); err != nil {
-		return fmt.Errorf("iptables not available (required for traffic filtering): %w", err)
+	setup, err := nm.prepareNetworkLocked(networkID, options, ipamData)
+	if err != nil {
+		return err
 	}
 
-	// Determine subnet for this network
-	subnet, gateway, err := nm.allocateNetworkSubnet(ipamData)
+	dryRun, err := parseDryRun(options)
 	if err != nil {
-		return fmt.Errorf("failed to allocate network subnet: %w", err)
+		return err
+	}
+	if dryRun {
+		return nm.dryRunNetworkLocked(setup)
 	}
 
-	// Create tunnel manager for this network
-	tunnelManager := nm.tunnelMgr
-
-	// Create IP allocator for this network
-	ipAllocator := NewIPAllocator(subnet, gateway)
+	if err := nm.createNetworkLocked(setup); err != nil {
+		return err
+	}
+	nm.saveStateLocked()
+	return nil
+}
 
-	// Parse network-level exposure configuration
-	exposureConfig := parseNetworkExposureConfig(options)
+// createNetworkLocked stores a prepared network and sets up its firewall
+// rules, proxy listeners and filter entries. The caller must hold nm.mutex.
+func (nm *NetworkManager) createNetworkLocked(setup *networkSetup) error {
+	network := setup.network
+	networkID := network.ID
 
-	// Parse traffic filter configuration
-	filterConfig := parseFilterConfig(options)
-	allowlist, blocklist := parseFilterDestinations(options)
+	log.Printf("Creating I2P network %s", networkID)
 
-	// Create the network
-	network := &I2PNetwork{
-		ID:             networkID,
-		Name:           getNetworkName(options),
-		Subnet:         subnet,
-		Gateway:        gateway,
-		TunnelManager:  tunnelManager,
-		Endpoints:      make(map[string]*I2PEndpoint),
-		IPAllocator:    ipAllocator,
-		Options:        options,
-		ExposureConfig: exposureConfig,
+	// Check firewall availability on every network creation (required for traffic filtering).
+	// This enforces the security requirement that the firewall must be available at all times,
+	// even if the proxy manager is already running from a previous network creation.
+	// This prevents scenarios where the firewall becomes unavailable between network creations.
+	if err := nm.proxyMgr.CheckIptablesAvailability(); err != nil {
+		return fmt.Errorf("%s not available (required for traffic filtering): %w", nm.proxyMgr.FirewallBackend(), err)
+	}
+	// The noop backend would leave an internal network's egress open
+	if network.Internal && nm.proxyMgr.FirewallBackend() == proxy.FirewallNoop {
+		return fmt.Errorf("internal networks need a firewall backend to block their egress, the %s backend installs no rules", proxy.FirewallNoop)
+	}
+	// Anti-spoofing and isolation filter bridged frames, with ebtables on
+	// the iptables backend
+	if network.AntiSpoof || network.Isolated {
+		if err := nm.proxyMgr.CheckEbtablesAvailability(); err != nil {
+			return fmt.Errorf("bridge filtering not available with the %s firewall backend (required for anti-spoofing and %s, disable anti-spoofing with %s=false): %w",
+				nm.proxyMgr.FirewallBackend(), IsolatedOption, AntiSpoofOption, err)
+		}
+	}
+	// The bridge carries the gateway address the proxy listeners bind to
+	nm.warnUnappliedMTULocked(network)
+	if err := nm.createBridgeLocked(network); err != nil {
+		return err
+	}
+	if network.Isolated {
+		if err := nm.proxyMgr.IsolateNetwork(networkID, network.Subnet); err != nil {
+			nm.deleteBridgeLocked(network)
+			return err
+		}
+	}
+	if network.Internal {
+		if err := nm.proxyMgr.BlockNetworkEgress(networkID, network.egressSubnets(), nm.samBridgeAddrsLocked()); err != nil {
+			if err := nm.proxyMgr.RemoveNetworkIsolation(networkID); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			nm.deleteBridgeLocked(network)
+			return err
+		}
 	}
 
 	// Store the network
-	nm.networks[networkID] = network
+	nm.addNetworkLocked(network)
 
 	// Start proxy manager if this is the first network
 	if len(nm.networks) == 1 && !nm.proxyMgr.IsRunning() {
 		if err := nm.proxyMgr.Start(); err != nil {
 			// Clean up the network if proxy start fails
-			delete(nm.networks, networkID)
+			nm.removeNetworkLocked(network)
+			if err := nm.proxyMgr.RemoveNetworkIsolation(networkID); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			if err := nm.proxyMgr.RemoveNetworkEgressBlock(networkID); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			nm.deleteBridgeLocked(network)
 			return fmt.Errorf("failed to start proxy manager: %w", err)
 		}
 		log.Printf("Started proxy manager for transparent I2P proxying")
 	}
 
+	// Listeners are best effort: the gateway address only exists once the
+	// network's bridge is up, and intercepted traffic is still redirected
+	if err := nm.proxyMgr.AddNetworkListeners(networkID, network.Subnet, network.ProxyBindIP); err != nil {
+		log.Printf("Warning: Failed to start proxy listeners for network %s on %s: %v", networkID, network.ProxyBindIP, err)
+	}
+
 	// Apply filter configuration (whether proxy manager is new or already running)
-	nm.proxyMgr.UpdateFilterConfig(filterConfig)
+	nm.proxyMgr.UpdateFilterConfig(setup.filterConfig)
 
 	// Add allowlist/blocklist entries
-	for _, dest := range allowlist {
+	for _, dest := range setup.allowlist {
 		if err := nm.proxyMgr.AddToAllowlist(dest); err != nil {
 			log.Printf("Warning: Failed to add '%s' to allowlist: %v", dest, err)
 		} else {
@@ -228,7 +596,7 @@ func (nm *NetworkManager) CreateNetwork(networkID string, options map[string]int
 		}
 	}
 
-	for _, dest := range blocklist {
+	for _, dest := range setup.blocklist {
 		if err := nm.proxyMgr.AddToBlocklist(dest); err != nil {
 			log.Printf("Warning: Failed to add '%s' to blocklist: %v", dest, err)
 		} else {
@@ -236,10 +604,170 @@ func (nm *NetworkManager) CreateNetwork(networkID string, options map[string]int
 		}
 	}
 
-	log.Printf("Successfully created I2P network %s with subnet %s", networkID, subnet)
+	if len(network.ExternalServices) > 0 {
+		nm.proxyMgr.SetExternalServices(networkID, network.ExternalServices)
+		log.Printf("Network %s reaches external services %s", networkID, formatExternalServices(network.ExternalServices))
+	}
+
+	log.Printf("Successfully created I2P network %s with subnet %s (%s IP allocation)", networkID, network.Subnet, setup.strategy.Name())
 	return nil
 }
 
+// networkSetup is a validated network that has not been stored or started yet.
+type networkSetup struct {
+	network      *I2PNetwork
+	strategy     AllocationStrategy
+	filterConfig *proxy.FilterConfig
+	allowlist    []string
+	blocklist    []string
+}
+
+// prepareNetworkLocked validates network options and builds the network
+// without touching SAM, iptables or the proxy.
+//
+// The caller must hold nm.mutex.
+func (nm *NetworkManager) prepareNetworkLocked(networkID string, options map[string]interface{}, ipamData []IPAMData) (*networkSetup, error) {
+	// Validate network ID
+	if networkID == "" {
+		return nil, fmt.Errorf("network ID cannot be empty")
+	}
+
+	// Check if network already exists
+	if _, exists := nm.networks[networkID]; exists {
+		return nil, fmt.Errorf("network %s already exists", networkID)
+	}
+
+	// Expand the policy template before any option is read
+	options, policy, err := applyPolicyTemplate(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Names must be unique so they can be used in place of IDs
+	name := getNetworkName(options)
+	if existingID, exists := nm.networkNames[name]; exists && name != "" {
+		return nil, fmt.Errorf("network name %s already in use by network %s", name, existingID)
+	}
+
+	// Determine subnets for this network, rejecting overlaps before any
+	// resources are touched
+	ipv4Data, ipv6Data := splitIPAMData(ipamData)
+	subnet, gateway, err := nm.allocateNetworkSubnet(ipv4Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate network subnet: %w", err)
+	}
+	subnetIPv6, gatewayIPv6, err := nm.allocateNetworkSubnetIPv6(ipv6Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate network IPv6 subnet: %w", err)
+	}
+
+	// Select the IP allocation strategy; networks on an IPAM driver pool
+	// share the pool's allocator and strategy
+	strategy, err := parseAllocationStrategy(options)
+	if err != nil {
+		return nil, err
+	}
+	allocator := NewIPAllocatorWithStrategy(subnet, gateway, strategy)
+	var poolID string
+	if pool := nm.ipamPoolLocked(ipv4Data); pool != nil {
+		allocator, strategy, poolID = pool.allocator, pool.allocator.strategy, pool.id
+	}
+
+	// IPv6 addresses are allocated with the same strategy, but strategies
+	// keep state, so the IPv6 allocator gets its own
+	var ipv6Allocator *IPAllocator
+	var ipv6PoolID string
+	if subnetIPv6 != nil {
+		if pool := nm.ipamPoolLocked(ipv6Data); pool != nil {
+			ipv6Allocator, ipv6PoolID = pool.allocator, pool.id
+		} else {
+			ipv6Strategy, _ := NewAllocationStrategy(strategy.Name())
+			ipv6Allocator = NewIPAllocatorWithStrategy(subnetIPv6, gatewayIPv6, ipv6Strategy)
+		}
+	}
+
+	// Select the address containers reach the proxy on
+	proxyBindIP, err := parseProxyBind(options, gateway)
+	if err != nil {
+		return nil, err
+	}
+
+	antiSpoof, err := parseBoolOption(options, AntiSpoofOption, true)
+	if err != nil {
+		return nil, err
+	}
+	isolated, err := parseBoolOption(options, IsolatedOption, false)
+	if err != nil {
+		return nil, err
+	}
+	internal, err := parseInternal(options)
+	if err != nil {
+		return nil, err
+	}
+	mtu, err := parseMTU(options, subnetIPv6 != nil)
+	if err != nil {
+		return nil, err
+	}
+	maxEndpoints, err := parseMaxEndpoints(options)
+	if err != nil {
+		return nil, err
+	}
+	tunnelOverrides, err := parseTunnelOverrides(options)
+	if err != nil {
+		return nil, err
+	}
+	tenant, err := parseTenant(options)
+	if err != nil {
+		return nil, err
+	}
+	externalServices, err := parseExternalServices(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse traffic filter configuration
+	allowlist, blocklist := parseFilterDestinations(options)
+
+	network := &I2PNetwork{
+		ID:               networkID,
+		Name:             name,
+		Subnet:           subnet,
+		Gateway:          gateway,
+		SubnetIPv6:       subnetIPv6,
+		GatewayIPv6:      gatewayIPv6,
+		TunnelManager:    nm.tunnelMgr,
+		Endpoints:        make(map[string]*I2PEndpoint),
+		IPAllocator:      allocator,
+		IPAMPool:         poolID,
+		IPv6Allocator:    ipv6Allocator,
+		IPv6Pool:         ipv6PoolID,
+		Options:          options,
+		ExposureConfig:   parseNetworkExposureConfig(options),
+		SidecarConfig:    parseSidecarConfig(options),
+		ProxyBindIP:      proxyBindIP,
+		AntiSpoof:        antiSpoof,
+		Isolated:         isolated,
+		MaxEndpoints:     maxEndpoints,
+		Policy:           policy,
+		TunnelOverrides:  tunnelOverrides,
+		Tenant:           tenant,
+		ExternalServices: externalServices,
+		Internal:         internal,
+		MTU:              mtu,
+	}
+	if internal {
+		restrictInternalExposure(network)
+	}
+
+	return &networkSetup{
+		network:      network,
+		strategy:     strategy,
+		filterConfig: parseFilterConfig(options),
+		allowlist:    allowlist,
+		blocklist:    blocklist,
+	}, nil
+}
+
 // DeleteNetwork removes an I2P network and cleans up all resources.
 //
 // This method implements Docker's DeleteNetwork operation, ensuring proper
@@ -248,6 +776,11 @@ func (nm *NetworkManager) DeleteNetwork(networkID string) error {
 	nm.mutex.Lock()
 	defer nm.mutex.Unlock()
 
+	return nm.deleteNetworkLocked(networkID)
+}
+
+// deleteNetworkLocked removes an I2P network. The caller must hold nm.mutex.
+func (nm *NetworkManager) deleteNetworkLocked(networkID string) error {
 	// Validate network ID
 	if networkID == "" {
 		return fmt.Errorf("network ID cannot be empty")
@@ -267,6 +800,7 @@ func (nm *NetworkManager) DeleteNetwork(networkID string) error {
 			log.Printf("Warning: Failed to clean up endpoint %s: %v", endpointID, err)
 		}
 	}
+	network.deleted = true
 	network.mutex.Unlock()
 
 	// Clean up all I2P tunnels
@@ -274,8 +808,22 @@ func (nm *NetworkManager) DeleteNetwork(networkID string) error {
 		log.Printf("Warning: Failed to destroy all tunnels: %v", err)
 	}
 
+	// Release the network's proxy listeners and isolation rules
+	if err := nm.proxyMgr.RemoveNetworkListeners(networkID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := nm.proxyMgr.RemoveNetworkIsolation(networkID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := nm.proxyMgr.RemoveNetworkEgressBlock(networkID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	nm.proxyMgr.SetExternalServices(networkID, nil)
+	nm.proxyMgr.RemoveContainerNames(networkID)
+	nm.deleteBridgeLocked(network)
+
 	// Remove network from manager
-	delete(nm.networks, networkID)
+	nm.removeNetworkLocked(network)
 
 	// Stop proxy manager if this was the last network
 	if len(nm.networks) == 0 && nm.proxyMgr.IsRunning() {
@@ -286,6 +834,8 @@ func (nm *NetworkManager) DeleteNetwork(networkID string) error {
 		}
 	}
 
+	nm.saveStateLocked()
+
 	log.Printf("Successfully deleted I2P network %s", networkID)
 	return nil
 }
@@ -300,17 +850,71 @@ func (nm *NetworkManager) GetNetwork(networkID string) *I2PNetwork {
 	return nm.networks[networkID]
 }
 
-// ListNetworks returns a list of all network IDs.
+// GetNetworkByName retrieves a network by its user-visible name.
+//
+// Returns the network if it exists, or nil if not found.
+func (nm *NetworkManager) GetNetworkByName(name string) *I2PNetwork {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	if name == "" {
+		return nil
+	}
+	return nm.networks[nm.networkNames[name]]
+}
+
+// LookupNetwork retrieves a network by ID or name.
+//
+// IDs take precedence, so a network whose name equals another network's ID
+// cannot shadow it. Returns nil if neither matches.
+func (nm *NetworkManager) LookupNetwork(ref string) *I2PNetwork {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	return nm.lookupNetworkLocked(ref)
+}
+
+// lookupNetworkLocked resolves a network ID or name. The caller must hold nm.mutex.
+func (nm *NetworkManager) lookupNetworkLocked(ref string) *I2PNetwork {
+	if network, exists := nm.networks[ref]; exists {
+		return network
+	}
+	if networkID, exists := nm.networkNames[ref]; exists && ref != "" {
+		return nm.networks[networkID]
+	}
+	return nil
+}
+
+// addNetworkLocked stores a network and indexes its name. The caller must hold nm.mutex.
+func (nm *NetworkManager) addNetworkLocked(network *I2PNetwork) {
+	nm.networks[network.ID] = network
+	if network.Name != "" {
+		nm.networkNames[network.Name] = network.ID
+	}
+	nm.refreshNetworkTagsLocked()
+}
+
+// removeNetworkLocked removes a network and its name index entry. The caller must hold nm.mutex.
+func (nm *NetworkManager) removeNetworkLocked(network *I2PNetwork) {
+	delete(nm.networks, network.ID)
+	if nm.networkNames[network.Name] == network.ID {
+		delete(nm.networkNames, network.Name)
+	}
+	nm.refreshNetworkTagsLocked()
+}
+
+// ListNetworks returns the IDs of all networks, sorted.
 //
 // This provides visibility into active I2P networks for debugging and monitoring.
 func (nm *NetworkManager) ListNetworks() []string {
 	nm.mutex.RLock()
 	defer nm.mutex.RUnlock()
 
-	var networks []string
+	networks := make([]string, 0, len(nm.networks))
 	for networkID := range nm.networks {
 		networks = append(networks, networkID)
 	}
+	sort.Strings(networks)
 	return networks
 }
 
@@ -319,9 +923,27 @@ func (nm *NetworkManager) ListNetworks() []string {
 // This method implements Docker's CreateEndpoint operation, setting up
 // the endpoint configuration but not yet connecting it to the network.
 func (nm *NetworkManager) CreateEndpoint(networkID, endpointID string, options map[string]interface{}) (*I2PEndpoint, error) {
-	nm.mutex.Lock()
-	defer nm.mutex.Unlock()
+	return nm.CreateEndpointWithAddress(networkID, endpointID, nil, options)
+}
+
+// CreateEndpointWithAddress creates an endpoint with the address Docker
+// assigned to it.
+//
+// On networks created on an IPAM driver pool the address was requested from
+// the pool and is used as is. Other networks reserve the exact address in
+// their allocator, failing if another endpoint or the gateway holds it, and
+// allocate one themselves when Docker passes none.
+func (nm *NetworkManager) CreateEndpointWithAddress(networkID, endpointID string, address net.IP, options map[string]interface{}) (*I2PEndpoint, error) {
+	return nm.CreateEndpointWithAddresses(networkID, endpointID, address, nil, options)
+}
 
+// CreateEndpointWithAddresses creates an endpoint with the IPv4 and IPv6
+// addresses Docker assigned to it, either of which may be nil.
+//
+// The IPv6 address is handled like the IPv4 address by
+// CreateEndpointWithAddress. On networks with an IPv6 subnet the endpoint
+// always gets an IPv6 address; on others passing one is an error.
+func (nm *NetworkManager) CreateEndpointWithAddresses(networkID, endpointID string, address, addressIPv6 net.IP, options map[string]interface{}) (*I2PEndpoint, error) {
 	// Validate inputs
 	if networkID == "" {
 		return nil, fmt.Errorf("network ID cannot be empty")
@@ -330,23 +952,65 @@ func (nm *NetworkManager) CreateEndpoint(networkID, endpointID string, options m
 		return nil, fmt.Errorf("endpoint ID cannot be empty")
 	}
 
-	// Get the network
-	network, exists := nm.networks[networkID]
-	if !exists {
-		return nil, fmt.Errorf("network %s not found", networkID)
+	var endpoint *I2PEndpoint
+	err := nm.updateEndpoints(networkID, func(network *I2PNetwork) error {
+		var err error
+		endpoint, err = nm.createEndpointLocked(network, endpointID, address, addressIPv6, options)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	return endpoint, nil
+}
 
-	// Check if endpoint already exists
-	if _, exists := network.Endpoints[endpointID]; exists {
+// createEndpointLocked creates an endpoint the way CreateEndpointWithAddresses
+// describes. The caller must hold the network's mutex.
+func (nm *NetworkManager) createEndpointLocked(network *I2PNetwork, endpointID string, address, addressIPv6 net.IP, options map[string]interface{}) (*I2PEndpoint, error) {
+	networkID := network.ID
+
+	// Check if endpoint already exists; Docker may create an endpoint again
+	// that was restored from the state file
+	if endpoint, exists := network.Endpoints[endpointID]; exists {
+		if endpoint.Restored {
+			endpoint.Restored = false
+			log.Printf("Endpoint %s was restored after a plugin restart, keeping it", endpointID)
+			return endpoint, nil
+		}
 		return nil, fmt.Errorf("endpoint %s already exists on network %s", endpointID, networkID)
 	}
 
+	// Refuse endpoints beyond the network's limit before touching the pool
+	if err := nm.checkEndpointLimitLocked(network); err != nil {
+		return nil, err
+	}
+
 	log.Printf("Creating I2P endpoint %s on network %s", endpointID, networkID)
 
+	// Options may request the addresses Docker did not assign
+	requested, requestedIPv6, err := requestedAddresses(options)
+	if err != nil {
+		return nil, err
+	}
+	if address, err = preferredAddress(address, requested); err != nil {
+		return nil, err
+	}
+	if addressIPv6, err = preferredAddress(addressIPv6, requestedIPv6); err != nil {
+		return nil, err
+	}
+
 	// Allocate IP address for the endpoint
-	ipAddr, err := network.IPAllocator.AllocateIP()
+	allocationKey := getStickyKey(options)
+	ipAddr, err := nm.endpointAddressLocked(network, allocationKey, address)
+	if err != nil {
+		return nil, err
+	}
+	ipv6Addr, err := nm.endpointIPv6AddressLocked(network, allocationKey, addressIPv6)
 	if err != nil {
-		return nil, fmt.Errorf("failed to allocate IP address: %w", err)
+		if network.IPAMPool == "" {
+			network.IPAllocator.ReleaseIP(ipAddr)
+		}
+		return nil, err
 	}
 
 	// Generate MAC address for the endpoint
@@ -356,10 +1020,20 @@ func (nm *NetworkManager) CreateEndpoint(networkID, endpointID string, options m
 	endpoint := &I2PEndpoint{
 		ID:            endpointID,
 		NetworkID:     networkID,
+		State:         EndpointCreated,
+		AllocationKey: allocationKey,
 		IPAddress:     ipAddr,
+		IPv6Address:   ipv6Addr,
 		MacAddress:    macAddr,
 		ClientTunnels: make(map[string]*i2p.Tunnel),
 		ServerTunnels: make(map[string]*i2p.Tunnel),
+		Aliases:       mergeAliases(nil, endpointAliases(options)),
+	}
+	if network.IPAMPool == "" && address != nil {
+		endpoint.RequestedAddress = address
+	}
+	if network.IPv6Pool == "" && addressIPv6 != nil {
+		endpoint.RequestedIPv6Address = addressIPv6
 	}
 
 	// Store the endpoint
@@ -374,9 +1048,6 @@ func (nm *NetworkManager) CreateEndpoint(networkID, endpointID string, options m
 // This method implements Docker's DeleteEndpoint operation, cleaning up
 // all I2P resources associated with the endpoint.
 func (nm *NetworkManager) DeleteEndpoint(networkID, endpointID string) error {
-	nm.mutex.Lock()
-	defer nm.mutex.Unlock()
-
 	// Validate inputs
 	if networkID == "" {
 		return fmt.Errorf("network ID cannot be empty")
@@ -385,22 +1056,22 @@ func (nm *NetworkManager) DeleteEndpoint(networkID, endpointID string) error {
 		return fmt.Errorf("endpoint ID cannot be empty")
 	}
 
-	// Get the network
-	network, exists := nm.networks[networkID]
-	if !exists {
-		return fmt.Errorf("network %s not found", networkID)
-	}
-
-	// Check if endpoint exists
-	if _, exists := network.Endpoints[endpointID]; !exists {
-		return fmt.Errorf("endpoint %s not found on network %s", endpointID, networkID)
-	}
+	err := nm.updateEndpoints(networkID, func(network *I2PNetwork) error {
+		// Check if endpoint exists
+		if _, exists := network.Endpoints[endpointID]; !exists {
+			return fmt.Errorf("endpoint %s not found on network %s", endpointID, networkID)
+		}
 
-	log.Printf("Deleting I2P endpoint %s from network %s", endpointID, networkID)
+		log.Printf("Deleting I2P endpoint %s from network %s", endpointID, networkID)
 
-	// Use the existing internal cleanup method
-	if err := nm.deleteEndpointInternal(network, endpointID); err != nil {
-		return fmt.Errorf("failed to delete endpoint %s: %w", endpointID, err)
+		// Use the existing internal cleanup method
+		if err := nm.deleteEndpointInternal(network, endpointID); err != nil {
+			return fmt.Errorf("failed to delete endpoint %s: %w", endpointID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	log.Printf("Successfully deleted I2P endpoint %s from network %s", endpointID, networkID)
@@ -412,9 +1083,6 @@ func (nm *NetworkManager) DeleteEndpoint(networkID, endpointID string) error {
 // This method implements Docker's Join operation, allocating IP addresses
 // and setting up I2P tunnels for the container.
 func (nm *NetworkManager) JoinEndpoint(networkID, endpointID, containerID, sandboxKey string, options map[string]interface{}) (*I2PEndpoint, error) {
-	nm.mutex.Lock()
-	defer nm.mutex.Unlock()
-
 	// Validate inputs
 	if networkID == "" {
 		return nil, fmt.Errorf("network ID cannot be empty")
@@ -426,27 +1094,153 @@ func (nm *NetworkManager) JoinEndpoint(networkID, endpointID, containerID, sandb
 		return nil, fmt.Errorf("container ID cannot be empty")
 	}
 
-	// Get the network
-	network, exists := nm.networks[networkID]
-	if !exists {
-		return nil, fmt.Errorf("network %s not found", networkID)
-	}
+	var endpoint *I2PEndpoint
+	err := nm.updateEndpoints(networkID, func(network *I2PNetwork) error {
+		// Get the endpoint
+		var exists bool
+		endpoint, exists = network.Endpoints[endpointID]
+		if !exists {
+			return fmt.Errorf("endpoint %s not found on network %s", endpointID, networkID)
+		}
 
-	// Get the endpoint
-	endpoint, exists := network.Endpoints[endpointID]
-	if !exists {
-		return nil, fmt.Errorf("endpoint %s not found on network %s", endpointID, networkID)
+		// Check if endpoint is already joined; a container that kept running
+		// through a plugin restart may be joined again by Docker
+		if endpoint.State == EndpointJoined {
+			if endpoint.Restored && endpoint.ContainerID == containerID {
+				endpoint.Restored = false
+				log.Printf("Container %s was rejoined to endpoint %s after a plugin restart, keeping it", containerID, endpointID)
+				return nil
+			}
+			return fmt.Errorf("endpoint %s is already joined to container %s", endpointID, endpoint.ContainerID)
+		}
+
+		return nm.joinEndpointLocked(network, endpoint, containerID, sandboxKey, options)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return endpoint, nil
+}
 
-	// Check if endpoint is already joined
-	if endpoint.ContainerID != "" {
-		return nil, fmt.Errorf("endpoint %s is already joined to container %s", endpointID, endpoint.ContainerID)
+// joinEndpointLocked attaches a container to an endpoint that is not joined,
+// creating its session, exposures, port maps and sidecar sockets. The caller
+// must hold the network's mutex.
+func (nm *NetworkManager) joinEndpointLocked(network *I2PNetwork, endpoint *I2PEndpoint, containerID, sandboxKey string, options map[string]interface{}) error {
+	networkID, endpointID := network.ID, endpoint.ID
+
+	outboundDisabled, err := isOutboundDisabled(options)
+	if err != nil {
+		return err
 	}
 
 	log.Printf("Joining container %s to I2P network %s via endpoint %s", containerID, networkID, endpointID)
 
+	requested, requestedIPv6, err := requestedAddresses(options)
+	if err != nil {
+		return err
+	}
+
+	// A parked endpoint gave up its address on Leave, so allocate a fresh one
+	// the same way CreateEndpoint would, preferring an address the Join
+	// options request; endpoints on IPAM driver pools keep theirs
+	allocated := endpoint.State == EndpointParked && endpoint.IPAddress == nil
+	if allocated {
+		if requested == nil {
+			requested = endpoint.RequestedAddress
+		}
+		if requestedIPv6 == nil {
+			requestedIPv6 = endpoint.RequestedIPv6Address
+		}
+		ipAddr, err := nm.endpointAddressLocked(network, endpoint.AllocationKey, requested)
+		if err != nil {
+			return fmt.Errorf("failed to allocate IP address for rejoined endpoint %s: %w", endpointID, err)
+		}
+		ipv6Addr, err := nm.endpointIPv6AddressLocked(network, endpoint.AllocationKey, requestedIPv6)
+		if err != nil {
+			network.IPAllocator.Free(ipAddr)
+			return fmt.Errorf("failed to allocate IPv6 address for rejoined endpoint %s: %w", endpointID, err)
+		}
+		endpoint.IPAddress = ipAddr
+		endpoint.IPv6Address = ipv6Addr
+		endpoint.MacAddress = generateMACAddress(ipAddr)
+		if network.IPAMPool == "" && requested != nil {
+			endpoint.RequestedAddress = requested
+		}
+		if network.IPv6Pool == "" && requestedIPv6 != nil {
+			endpoint.RequestedIPv6Address = requestedIPv6
+		}
+		log.Printf("Allocated IP %s to rejoined endpoint %s", ipAddr, endpointID)
+	} else {
+		// Docker already told the container the endpoint's addresses
+		if _, err := preferredAddress(endpoint.IPAddress, requested); err != nil {
+			return fmt.Errorf("endpoint %s: %w", endpointID, err)
+		}
+		if _, err := preferredAddress(endpoint.IPv6Address, requestedIPv6); err != nil {
+			return fmt.Errorf("endpoint %s: %w", endpointID, err)
+		}
+	}
+
+	// releaseParked gives back the addresses allocated above if the join fails
+	releaseParked := func() {
+		if allocated {
+			network.IPAllocator.Free(endpoint.IPAddress)
+			if endpoint.IPv6Address != nil {
+				network.IPv6Allocator.Free(endpoint.IPv6Address)
+			}
+			endpoint.IPAddress = nil
+			endpoint.IPv6Address = nil
+			endpoint.MacAddress = ""
+		}
+	}
+
+	// Bind the endpoint's addresses before the container can use them, so it
+	// cannot take over another endpoint's proxy or filter identity
+	if network.AntiSpoof {
+		if err := nm.proxyMgr.BindEndpointAddress(endpointID, endpoint.IPAddress, endpoint.MacAddress); err != nil {
+			releaseParked()
+			return fmt.Errorf("failed to install anti-spoofing rules for endpoint %s: %w", endpointID, err)
+		}
+	}
+
+	// Cut inbound-only containers off from the proxy before they start
+	if outboundDisabled {
+		if err := nm.proxyMgr.BlockEndpointOutbound(endpointID, endpoint.IPAddress); err != nil {
+			if unbindErr := nm.proxyMgr.UnbindEndpointAddress(endpointID); unbindErr != nil {
+				log.Printf("Warning: Failed to remove anti-spoofing rules of endpoint %s: %v", endpointID, unbindErr)
+			}
+			releaseParked()
+			return fmt.Errorf("failed to block outbound traffic of endpoint %s: %w", endpointID, err)
+		}
+	}
+
+	// Create the interface Docker moves into the container
+	iface, err := nm.createVethLocked(network, endpoint)
+	if err != nil {
+		if err := nm.proxyMgr.UnblockEndpointOutbound(endpointID); err != nil {
+			log.Printf("Warning: Failed to remove outbound block of endpoint %s: %v", endpointID, err)
+		}
+		if err := nm.proxyMgr.UnbindEndpointAddress(endpointID); err != nil {
+			log.Printf("Warning: Failed to remove anti-spoofing rules of endpoint %s: %v", endpointID, err)
+		}
+		releaseParked()
+		return err
+	}
+
 	// Update endpoint with container information
+	endpoint.Interface = iface
 	endpoint.ContainerID = containerID
+	endpoint.SandboxKey = sandboxKey
+	endpoint.JoinOptions = options
+	endpoint.State = EndpointJoined
+	endpoint.ClientOnly = service.IsClientOnly(options)
+	endpoint.OutboundDisabled = outboundDisabled
+	endpoint.IsolationGroups = getIsolationGroups(options)
+	nm.assignAliasLocked(endpoint, options)
+	endpoint.Aliases = mergeAliases(endpoint.Aliases, endpointAliases(options))
+	nm.registerContainerNamesLocked(network, endpoint)
+	nm.allowIsolationPeersLocked(network, endpoint)
+	nm.applyTunnelOverridesLocked(network, containerID)
+	nm.adoptIdentityLocked(endpoint, options)
 
 	// Detect and expose services for this container
 	if options != nil {
@@ -454,28 +1248,19 @@ func (nm *NetworkManager) JoinEndpoint(networkID, endpointID, containerID, sandb
 		if err != nil {
 			log.Printf("Warning: Failed to detect exposed ports for container %s: %v", containerID, err)
 		} else if len(exposedPorts) > 0 {
-			// Apply network-level exposure defaults to ports without explicit configuration
-			for i := range exposedPorts {
-				if exposedPorts[i].ExposureType == "" {
-					exposedPorts[i].ExposureType = network.ExposureConfig.DefaultExposureType
-					log.Printf("Applied network default exposure type %s to port %d",
-						network.ExposureConfig.DefaultExposureType, exposedPorts[i].ContainerPort)
-				}
-
-				// Check if IP exposure is allowed when port requests it
-				if exposedPorts[i].ExposureType == service.ExposureTypeIP && !network.ExposureConfig.AllowIPExposure {
-					log.Printf("Warning: IP exposure requested for port %d but not allowed by network policy, defaulting to I2P",
-						exposedPorts[i].ContainerPort)
-					exposedPorts[i].ExposureType = service.ExposureTypeI2P
-				}
-			}
+			exposedPorts = applyExposurePolicy(network, containerID, endpoint.ClientOnly, exposedPorts)
 
 			log.Printf("Container %s has %d exposed ports, creating service exposures", containerID, len(exposedPorts))
 
+			// Failed ports are reported but do not undo the exposures that succeeded
 			exposures, err := nm.serviceMgr.ExposeServices(containerID, networkID, endpoint.IPAddress, exposedPorts)
 			if err != nil {
 				log.Printf("Warning: Failed to expose services for container %s: %v", containerID, err)
-			} else {
+			}
+			if queued := nm.serviceMgr.QueuedExposures(containerID); queued > 0 {
+				log.Printf("Join of container %s succeeds with %d services pending until the router recovers", containerID, queued)
+			}
+			if len(exposures) > 0 {
 				log.Printf("Successfully exposed %d services for container %s", len(exposures), containerID)
 
 				// Store exposures in endpoint for retrieval via Join response
@@ -487,22 +1272,46 @@ func (nm *NetworkManager) JoinEndpoint(networkID, endpointID, containerID, sandb
 				}
 			}
 		}
+
+		// Start client port maps for legacy applications. Listeners bind to the
+		// network gateway, which is the address containers already route through.
+		portMaps := nm.serviceMgr.DetectPortMaps(options)
+		if outboundDisabled && len(portMaps) > 0 {
+			log.Printf("Warning: Skipping %d port maps of container %s: %s=false", len(portMaps), containerID, OutboundLabel)
+			portMaps = nil
+		}
+		if len(portMaps) > 0 {
+			mappings, err := nm.serviceMgr.CreatePortMaps(containerID, network.Gateway, portMaps)
+			if err != nil {
+				log.Printf("Warning: Failed to create port maps for container %s: %v", containerID, err)
+			} else {
+				endpoint.PortMappings = mappings
+				log.Printf("Created %d port maps for container %s", len(mappings), containerID)
+			}
+		}
+
+		// Serve the proxy on Unix sockets for designated sidecar containers
+		if _, ok := getSidecarName(options); ok && outboundDisabled {
+			log.Printf("Warning: Skipping sidecar sockets of container %s: %s=false", containerID, OutboundLabel)
+		} else if err := nm.startSidecarSockets(network, endpoint, options); err != nil {
+			log.Printf("Warning: Failed to start sidecar sockets for container %s: %v", containerID, err)
+		}
 	}
 
+	nm.rememberIdentityLocked(endpoint)
+
 	log.Printf("Container %s joined I2P network %s with IP %s via endpoint %s",
-		containerID, networkID, endpoint.IPAddress.String(), endpointID)
+		i2p.DisplayName(containerID), networkID, endpoint.IPAddress.String(), endpointID)
 
-	return endpoint, nil
+	return nil
 }
 
 // LeaveEndpoint disconnects a container from an I2P network.
 //
 // This method implements Docker's Leave operation, cleaning up
-// IP allocations but preserving the endpoint for potential reuse.
+// IP allocations but parking the endpoint for potential reuse. A later
+// Join allocates a fresh address; DeleteEndpoint removes it for good.
 func (nm *NetworkManager) LeaveEndpoint(networkID, endpointID string) error {
-	nm.mutex.Lock()
-	defer nm.mutex.Unlock()
-
 	// Validate inputs
 	if networkID == "" {
 		return fmt.Errorf("network ID cannot be empty")
@@ -511,11 +1320,15 @@ func (nm *NetworkManager) LeaveEndpoint(networkID, endpointID string) error {
 		return fmt.Errorf("endpoint ID cannot be empty")
 	}
 
-	// Get the network
-	network, exists := nm.networks[networkID]
-	if !exists {
-		return fmt.Errorf("network %s not found", networkID)
-	}
+	return nm.updateEndpoints(networkID, func(network *I2PNetwork) error {
+		return nm.leaveEndpointLocked(network, endpointID)
+	})
+}
+
+// leaveEndpointLocked detaches the container of a joined endpoint the way
+// LeaveEndpoint describes. The caller must hold the network's mutex.
+func (nm *NetworkManager) leaveEndpointLocked(network *I2PNetwork, endpointID string) error {
+	networkID := network.ID
 
 	// Get the endpoint
 	endpoint, exists := network.Endpoints[endpointID]
@@ -524,12 +1337,12 @@ func (nm *NetworkManager) LeaveEndpoint(networkID, endpointID string) error {
 	}
 
 	// Check if endpoint is actually joined
-	if endpoint.ContainerID == "" {
+	if endpoint.State != EndpointJoined {
 		return nil // Already left
 	}
 
 	log.Printf("Container %s leaving I2P network %s via endpoint %s",
-		endpoint.ContainerID, networkID, endpointID)
+		i2p.DisplayName(endpoint.ContainerID), networkID, endpointID)
 
 	// Clean up I2P tunnels for this endpoint
 	for tunnelName, tunnel := range endpoint.ClientTunnels {
@@ -556,8 +1369,10 @@ func (nm *NetworkManager) LeaveEndpoint(networkID, endpointID string) error {
 		}
 	}
 
-	// Clean up I2P service exposures if this was the last endpoint
+	// Clean up I2P service exposures if this was the last endpoint, keeping
+	// the keys of a container with an identity for its successor
 	if !hasOtherEndpoints {
+		nm.rememberIdentityLocked(endpoint)
 		if err := nm.serviceMgr.CleanupServices(containerID); err != nil {
 			log.Printf("Warning: Failed to cleanup I2P services for container %s: %v", containerID, err)
 		}
@@ -570,18 +1385,48 @@ func (nm *NetworkManager) LeaveEndpoint(networkID, endpointID string) error {
 		}
 	}
 
-	// Release IP address
-	if endpoint.IPAddress != nil {
-		network.IPAllocator.ReleaseIP(endpoint.IPAddress)
+	// Drop the address binding and peers before the address can be handed out again
+	if err := nm.proxyMgr.UnbindEndpointAddress(endpointID); err != nil {
+		log.Printf("Warning: Failed to remove anti-spoofing rules of endpoint %s: %v", endpointID, err)
+	}
+	if err := nm.proxyMgr.UnblockEndpointOutbound(endpointID); err != nil {
+		log.Printf("Warning: Failed to remove outbound block of endpoint %s: %v", endpointID, err)
+	}
+	nm.revokeIsolationPeersLocked(network, endpoint)
+	nm.forgetContainerNamesLocked(network, endpoint)
+
+	// Docker has moved the container end back to the host by now
+	nm.deleteVethLocked(network, endpoint)
+	endpoint.Interface = ""
+
+	// Release IP address; addresses of IPAM driver pools stay with the
+	// endpoint until Docker releases them
+	if endpoint.IPAddress != nil && network.IPAMPool == "" {
+		if err := network.IPAllocator.Free(endpoint.IPAddress); err != nil {
+			log.Printf("Warning: Failed to free IP address of endpoint %s: %v", endpointID, err)
+		}
 		endpoint.IPAddress = nil
+		endpoint.MacAddress = ""
 	}
+	nm.releaseIPv6AddressLocked(network, endpoint)
+
+	// Stop sidecar sockets for this endpoint
+	nm.stopSidecarSockets(endpoint)
 
 	// Clear container information but keep endpoint for reuse
+	endpoint.PortMappings = nil
+	endpoint.ServiceExposures = nil
+	endpoint.Identity = ""
+	endpoint.Alias = ""
 	endpoint.ContainerID = ""
-	endpoint.MacAddress = ""
+	endpoint.SandboxKey = ""
+	endpoint.JoinOptions = nil
+	endpoint.Restored = false
+	endpoint.State = EndpointParked
 
 	log.Printf("Container %s left I2P network %s via endpoint %s",
-		containerID, networkID, endpointID)
+		i2p.DisplayName(containerID), networkID, endpointID)
+	nm.releaseAliasLocked(containerID)
 
 	return nil
 }
@@ -590,12 +1435,15 @@ func (nm *NetworkManager) LeaveEndpoint(networkID, endpointID string) error {
 //
 // This method provides access to endpoint information for debugging and monitoring.
 func (nm *NetworkManager) GetEndpoint(networkID, endpointID string) (*I2PEndpoint, error) {
-	nm.mutex.RLock()
-	defer nm.mutex.RUnlock()
+	// Get the network; a join in progress on it must not hold up others
+	network := nm.GetNetwork(networkID)
+	if network == nil {
+		return nil, fmt.Errorf("network %s not found", networkID)
+	}
 
-	// Get the network
-	network, exists := nm.networks[networkID]
-	if !exists {
+	network.mutex.RLock()
+	defer network.mutex.RUnlock()
+	if network.deleted {
 		return nil, fmt.Errorf("network %s not found", networkID)
 	}
 
@@ -608,20 +1456,55 @@ func (nm *NetworkManager) GetEndpoint(networkID, endpointID string) (*I2PEndpoin
 	return endpoint, nil
 }
 
+// updateEndpoints runs an endpoint operation holding the mutex of the network
+// it applies to, then saves the state file. nm.mutex is only held to look the
+// network up, so a slow operation, such as building the tunnels of a joining
+// container, does not hold up the operations of other networks or changes to
+// the set of networks. The caller must hold neither nm.mutex nor any network's
+// mutex.
+func (nm *NetworkManager) updateEndpoints(networkID string, update func(network *I2PNetwork) error) error {
+	nm.mutex.RLock()
+	network, exists := nm.networks[networkID]
+	nm.mutex.RUnlock()
+	if !exists {
+		return fmt.Errorf("network %s not found", networkID)
+	}
+
+	network.mutex.Lock()
+	var err error
+	if network.deleted {
+		err = fmt.Errorf("network %s not found", networkID)
+	} else {
+		err = update(network)
+	}
+	network.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	nm.saveState()
+	return nil
+}
+
 // generateMACAddress generates a MAC address based on IP address.
 //
-// This ensures consistent MAC addresses for the same IP allocation.
+// This ensures consistent MAC addresses for the same IP allocation. IPv6
+// addresses contribute their last four bytes, which differ between the
+// addresses of a subnet up to a /96.
 func generateMACAddress(ip net.IP) string {
 	// Use a fixed prefix for I2P networks and derive from IP
 	// Format: 02:42:XX:XX:XX:XX where XX comes from IP
-	ipv4 := ip.To4()
-	if ipv4 == nil {
-		// Fallback for IPv6 or invalid IP
-		return "02:42:00:00:00:01"
+	suffix := ip.To4()
+	if suffix == nil {
+		if len(ip) != net.IPv6len {
+			// Fallback for invalid IP
+			return "02:42:00:00:00:01"
+		}
+		suffix = ip[net.IPv6len-4:]
 	}
 
 	return fmt.Sprintf("02:42:%02x:%02x:%02x:%02x",
-		ipv4[0], ipv4[1], ipv4[2], ipv4[3])
+		suffix[0], suffix[1], suffix[2], suffix[3])
 }
 
 // allocateNetworkSubnet determines the subnet and gateway for a new network.
@@ -639,10 +1522,18 @@ func (nm *NetworkManager) allocateNetworkSubnet(ipamData []IPAMData) (*net.IPNet
 					return nil, nil, fmt.Errorf("invalid subnet in IPAM data: %w", err)
 				}
 
-				// Use provided gateway or calculate default
+				// IPAM driver pools were checked when they were requested
+				if nm.ipamPoolLocked([]IPAMData{data}) == nil {
+					if err := nm.checkSubnetConflicts(subnet); err != nil {
+						return nil, nil, err
+					}
+				}
+
+				// Use provided gateway or calculate default; Docker passes
+				// it in CIDR notation
 				var gateway net.IP
 				if data.Gateway != "" {
-					gateway = net.ParseIP(data.Gateway)
+					gateway = parseAddress(data.Gateway)
 					if gateway == nil {
 						return nil, nil, fmt.Errorf("invalid gateway IP: %s", data.Gateway)
 					}
@@ -656,16 +1547,136 @@ func (nm *NetworkManager) allocateNetworkSubnet(ipamData []IPAMData) (*net.IPNet
 		}
 	}
 
-	// No IPAM data provided, allocate from default subnet
-	// For simplicity, we'll use /24 subnets within our /16 default
-	// In production, this would need more sophisticated allocation
-	subnet := &net.IPNet{
-		IP:   net.IPv4(172, 20, 1, 0),
-		Mask: net.IPv4Mask(255, 255, 255, 0),
+	// No IPAM data provided, allocate the first free /24 within the default
+	// subnet
+	subnet, err := nm.nextDefaultSubnetLocked()
+	if err != nil {
+		return nil, nil, err
+	}
+	return subnet, calculateDefaultGateway(subnet), nil
+}
+
+// ulaRange is the unique local IPv6 address range (fc00::/7) IPv6 subnets
+// must be taken from. Its locally assigned half, fd00::/8, is what Docker's
+// default address pools and --subnet fd00:... use.
+var ulaRange = &net.IPNet{IP: net.ParseIP("fc00::"), Mask: net.CIDRMask(7, 128)}
+
+// splitIPAMData separates IPv4 and IPv6 pools.
+//
+// Docker passes the pools of each family in a list of its own, but the
+// network manager receives them together. Pools that do not parse are kept
+// with the IPv4 pools, where they are reported.
+func splitIPAMData(ipamData []IPAMData) (ipv4Data, ipv6Data []IPAMData) {
+	for _, data := range ipamData {
+		if ip, _, err := net.ParseCIDR(data.Pool); err == nil && ip.To4() == nil {
+			ipv6Data = append(ipv6Data, data)
+			continue
+		}
+		ipv4Data = append(ipv4Data, data)
+	}
+	return ipv4Data, ipv6Data
+}
+
+// allocateNetworkSubnetIPv6 determines the IPv6 subnet and gateway of a new
+// network from the first IPv6 pool, returning nil if there is none.
+//
+// Only unique local addresses are accepted: containers reach I2P through the
+// proxy, and a globally routed prefix would let them bypass it. The caller
+// must hold nm.mutex.
+func (nm *NetworkManager) allocateNetworkSubnetIPv6(ipv6Data []IPAMData) (*net.IPNet, net.IP, error) {
+	for _, data := range ipv6Data {
+		if data.Pool == "" {
+			continue
+		}
+		_, subnet, err := net.ParseCIDR(data.Pool)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid subnet in IPAM data: %w", err)
+		}
+		if ones, _ := subnet.Mask.Size(); ones < 7 || !ulaRange.Contains(subnet.IP) {
+			return nil, nil, fmt.Errorf("IPv6 subnet %s is not a unique local address range (fd00::/8)", subnet)
+		}
+
+		// IPAM driver pools were checked when they were requested
+		if nm.ipamPoolLocked([]IPAMData{data}) == nil {
+			if err := nm.checkSubnetConflicts(subnet); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		gateway := calculateDefaultGateway(subnet)
+		if data.Gateway != "" {
+			gateway = parseAddress(data.Gateway)
+			if gateway == nil || gateway.To4() != nil || !subnet.Contains(gateway) {
+				return nil, nil, fmt.Errorf("invalid IPv6 gateway: %s", data.Gateway)
+			}
+		}
+		return subnet, gateway, nil
+	}
+	return nil, nil, nil
+}
+
+// checkSubnetConflicts rejects subnets that overlap existing networks, IPAM
+// driver pools or the synthetic DNS range.
+//
+// Overlapping subnets break traffic interception and forwarder routing, since
+// the plugin can no longer tell which network a container address belongs to.
+// The caller must hold nm.mutex.
+func (nm *NetworkManager) checkSubnetConflicts(subnet *net.IPNet) error {
+	_, syntheticRange, err := net.ParseCIDR(proxy.SyntheticIPRange)
+	if err != nil {
+		return fmt.Errorf("failed to parse synthetic DNS range: %w", err)
+	}
+	if subnetsOverlap(subnet, syntheticRange) {
+		return fmt.Errorf("subnet %s overlaps the I2P DNS synthetic address range %s", subnet, syntheticRange)
+	}
+	_, syntheticIPv6Range, err := net.ParseCIDR(proxy.SyntheticIPv6Range)
+	if err != nil {
+		return fmt.Errorf("failed to parse synthetic IPv6 DNS range: %w", err)
+	}
+	if subnetsOverlap(subnet, syntheticIPv6Range) {
+		return fmt.Errorf("subnet %s overlaps the I2P DNS synthetic address range %s", subnet, syntheticIPv6Range)
+	}
+
+	networkIDs := make([]string, 0, len(nm.networks))
+	for networkID := range nm.networks {
+		networkIDs = append(networkIDs, networkID)
+	}
+	sort.Strings(networkIDs)
+
+	for _, networkID := range networkIDs {
+		network := nm.networks[networkID]
+		for _, existing := range []*net.IPNet{network.Subnet, network.SubnetIPv6} {
+			if existing == nil || !subnetsOverlap(subnet, existing) {
+				continue
+			}
+			if network.Name != "" {
+				return fmt.Errorf("subnet %s overlaps subnet %s of network %s (%s)", subnet, existing, network.Name, networkID)
+			}
+			return fmt.Errorf("subnet %s overlaps subnet %s of network %s", subnet, existing, networkID)
+		}
+	}
+
+	poolIDs := make([]string, 0, len(nm.ipamPools))
+	for poolID := range nm.ipamPools {
+		poolIDs = append(poolIDs, poolID)
+	}
+	sort.Strings(poolIDs)
+
+	for _, poolID := range poolIDs {
+		if subnetsOverlap(subnet, nm.ipamPools[poolID].subnet) {
+			return fmt.Errorf("subnet %s overlaps IPAM pool %s", subnet, poolID)
+		}
 	}
-	gateway := net.IPv4(172, 20, 1, 1)
 
-	return subnet, gateway, nil
+	return nil
+}
+
+// subnetsOverlap reports whether two subnets share any address.
+//
+// CIDR blocks are either nested or disjoint, so it is enough to check whether
+// either contains the other's network address.
+func subnetsOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
 }
 
 // calculateDefaultGateway calculates the default gateway IP for a subnet.
@@ -704,7 +1715,7 @@ func getNetworkName(options map[string]interface{}) string {
 
 // deleteEndpointInternal removes an endpoint from a network (internal helper).
 //
-// This is called during network cleanup and assumes locks are already held.
+// The caller must hold the network's mutex.
 func (nm *NetworkManager) deleteEndpointInternal(network *I2PNetwork, endpointID string) error {
 	endpoint, exists := network.Endpoints[endpointID]
 	if !exists {
@@ -726,6 +1737,9 @@ func (nm *NetworkManager) deleteEndpointInternal(network *I2PNetwork, endpointID
 		}
 	}
 
+	// Stop sidecar sockets for this endpoint
+	nm.stopSidecarSockets(endpoint)
+
 	// Clean up container session if this was the last endpoint for the container
 	if endpoint.ContainerID != "" {
 		hasOtherEndpoints := false
@@ -743,12 +1757,33 @@ func (nm *NetworkManager) deleteEndpointInternal(network *I2PNetwork, endpointID
 		}
 	}
 
-	// Release IP address
-	if endpoint.IPAddress != nil {
-		network.IPAllocator.ReleaseIP(endpoint.IPAddress)
+	// Remove the address binding and peers of a joined endpoint
+	if err := nm.proxyMgr.UnbindEndpointAddress(endpointID); err != nil {
+		log.Printf("Warning: Failed to remove anti-spoofing rules of endpoint %s: %v", endpointID, err)
+	}
+	if err := nm.proxyMgr.UnblockEndpointOutbound(endpointID); err != nil {
+		log.Printf("Warning: Failed to remove outbound block of endpoint %s: %v", endpointID, err)
+	}
+	nm.revokeIsolationPeersLocked(network, endpoint)
+	nm.forgetContainerNamesLocked(network, endpoint)
+	nm.deleteVethLocked(network, endpoint)
+
+	// Release IP address; Docker releases addresses of IPAM driver pools
+	if endpoint.IPAddress != nil && network.IPAMPool == "" {
+		if err := network.IPAllocator.Free(endpoint.IPAddress); err != nil {
+			log.Printf("Warning: Failed to free IP address of endpoint %s: %v", endpointID, err)
+		}
+		endpoint.IPAddress = nil
+	}
+	nm.releaseIPv6AddressLocked(network, endpoint)
+
+	nm.proxyMgr.ForgetEndpointTraffic(endpointID)
+	if endpoint.State == EndpointJoined {
+		nm.releaseAliasLocked(endpoint.ContainerID)
 	}
 
 	// Remove endpoint
+	endpoint.State = EndpointDeleted
 	delete(network.Endpoints, endpointID)
 
 	return nil
@@ -759,10 +1794,12 @@ func (nm *NetworkManager) deleteEndpointInternal(network *I2PNetwork, endpointID
 // This function parses Docker network creation options to determine:
 // - Default exposure type for containers on this network
 // - Whether IP-based exposure is allowed
+// - Which target IPs or interfaces IP-based exposure may bind to
 //
 // Configuration options:
 //   - i2p.exposure.default: "i2p" or "ip" (default: "i2p")
 //   - i2p.exposure.allow_ip: "true" or "false" (default: "true")
+//   - i2p.exposure.allowed_targets: comma-separated IPs or interface names (default: any)
 func parseNetworkExposureConfig(options map[string]interface{}) service.NetworkExposureConfig {
 	config := service.NetworkExposureConfig{
 		DefaultExposureType: service.ExposureTypeI2P, // Default to I2P exposure
@@ -793,9 +1830,122 @@ func parseNetworkExposureConfig(options map[string]interface{}) service.NetworkE
 		}
 	}
 
+	// Check for strict mode target allowlist
+	if targets, ok := options["i2p.exposure.allowed_targets"].(string); ok {
+		for _, target := range strings.Split(targets, ",") {
+			if target = strings.TrimSpace(target); target != "" {
+				config.AllowedTargets = append(config.AllowedTargets, target)
+			}
+		}
+		if len(config.AllowedTargets) > 0 {
+			log.Printf("Network IP exposure restricted to targets: %v", config.AllowedTargets)
+		}
+	}
+
 	return config
 }
 
+// applyExposurePolicy applies a network's exposure policy to the ports a
+// joining container requested.
+//
+// Ports without an exposure type get the network default, IP exposures are
+// downgraded to I2P where the network forbids them, and exposures refused by
+// the target allowlist or the container's client-only mode are dropped.
+func applyExposurePolicy(network *I2PNetwork, containerID string, clientOnly bool, ports []service.ExposedPort) []service.ExposedPort {
+	for i := range ports {
+		// Apply network-level exposure defaults to ports without explicit configuration
+		if ports[i].ExposureType == "" {
+			ports[i].ExposureType = network.ExposureConfig.DefaultExposureType
+			log.Printf("Applied network default exposure type %s to port %d",
+				network.ExposureConfig.DefaultExposureType, ports[i].ContainerPort)
+		}
+
+		// Check if IP exposure is allowed when port requests it
+		if ports[i].ExposureType == service.ExposureTypeIP && !network.ExposureConfig.AllowIPExposure {
+			log.Printf("Warning: IP exposure requested for port %d but not allowed by network policy, defaulting to I2P",
+				ports[i].ContainerPort)
+			ports[i].ExposureType = service.ExposureTypeI2P
+		}
+	}
+
+	// Refuse IP exposures targeting addresses outside the strict mode allowlist
+	ports = filterAllowedExposureTargets(network.ExposureConfig, ports)
+	if clientOnly {
+		ports = filterClientOnlyExposures(containerID, ports)
+	}
+	return ports
+}
+
+// filterAllowedExposureTargets removes IP exposures whose target is not allowed.
+//
+// Rejected ports are dropped rather than downgraded to I2P exposure, since the
+// label explicitly asked for an IP binding the network does not permit.
+func filterAllowedExposureTargets(config service.NetworkExposureConfig, ports []service.ExposedPort) []service.ExposedPort {
+	allowed := ports[:0]
+	for _, port := range ports {
+		if port.ExposureType == service.ExposureTypeIP {
+			targetIP := port.TargetIP
+			if targetIP == "" {
+				targetIP = "127.0.0.1"
+			}
+			if err := config.ValidateTargetIP(targetIP); err != nil {
+				log.Printf("Warning: Refusing IP exposure for port %d: %v", port.ContainerPort, err)
+				continue
+			}
+		}
+		allowed = append(allowed, port)
+	}
+	return allowed
+}
+
+// filterClientOnlyExposures drops I2P exposures of a client-only container.
+//
+// Only IP exposures are kept, so no server destination is ever created for
+// the container.
+func filterClientOnlyExposures(containerID string, ports []service.ExposedPort) []service.ExposedPort {
+	allowed := ports[:0]
+	for _, port := range ports {
+		if port.ExposureType == service.ExposureTypeI2P {
+			log.Printf("Warning: Skipping I2P exposure of port %d for client-only container %s",
+				port.ContainerPort, containerID)
+			continue
+		}
+		allowed = append(allowed, port)
+	}
+	return allowed
+}
+
+// AntiSpoofOption is the network option that binds endpoint addresses with
+// firewall rules.
+const AntiSpoofOption = "i2p.antispoof"
+
+// parseBoolOption returns a boolean network option, or def if it is absent.
+func parseBoolOption(options map[string]interface{}, name string, def bool) (bool, error) {
+	switch value := options[name].(type) {
+	case nil:
+		return def, nil
+	case bool:
+		return value, nil
+	case string:
+		parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return false, fmt.Errorf("invalid %s value %q: expected true or false", name, value)
+		}
+		return parsed, nil
+	default:
+		return false, fmt.Errorf("invalid %s value %v: expected true or false", name, value)
+	}
+}
+
+// parseProxyBind returns the proxy bind address selected by network options.
+//
+// Configuration options:
+//   - i2p.proxy.bind: gateway (default), any, or an IP address
+func parseProxyBind(options map[string]interface{}, gateway net.IP) (net.IP, error) {
+	mode, _ := options["i2p.proxy.bind"].(string)
+	return proxy.ResolveBindIP(mode, gateway)
+}
+
 // parseFilterConfig extracts traffic filter configuration from network options.
 //
 // This function parses Docker network creation options to configure traffic filtering:
@@ -813,7 +1963,7 @@ func parseFilterConfig(options map[string]interface{}) *proxy.FilterConfig {
 	}
 
 	// Parse filter mode
-	if mode, ok := options["i2p.filter.mode"]; ok {
+	if mode, ok := options[FilterModeOption]; ok {
 		if modeStr, ok := mode.(string); ok {
 			switch strings.ToLower(modeStr) {
 			case "allowlist":
@@ -840,7 +1990,8 @@ func parseFilterConfig(options map[string]interface{}) *proxy.FilterConfig {
 // parseFilterDestinations extracts destination lists from network options.
 //
 // Returns two slices: allowlist destinations and blocklist destinations.
-// Destinations can be comma-separated or provided as string slices.
+// Destinations can be comma-separated or provided as string slices. The
+// addresses of the network's external services are allowlisted as well.
 func parseFilterDestinations(options map[string]interface{}) ([]string, []string) {
 	var allowlist, blocklist []string
 
@@ -849,7 +2000,7 @@ func parseFilterDestinations(options map[string]interface{}) ([]string, []string
 	}
 
 	// Parse allowlist
-	if allowlistOpt, ok := options["i2p.filter.allowlist"]; ok {
+	if allowlistOpt, ok := options[FilterAllowlistOption]; ok {
 		switch v := allowlistOpt.(type) {
 		case string:
 			// Split comma-separated list
@@ -871,7 +2022,7 @@ func parseFilterDestinations(options map[string]interface{}) ([]string, []string
 	}
 
 	// Parse blocklist
-	if blocklistOpt, ok := options["i2p.filter.blocklist"]; ok {
+	if blocklistOpt, ok := options[FilterBlocklistOption]; ok {
 		switch v := blocklistOpt.(type) {
 		case string:
 			// Split comma-separated list
@@ -892,6 +2043,7 @@ func parseFilterDestinations(options map[string]interface{}) ([]string, []string
 		}
 	}
 
+	allowlist = append(allowlist, externalServiceAddresses(options)...)
 	return allowlist, blocklist
 }
 
@@ -919,7 +2071,7 @@ func (nm *NetworkManager) Shutdown() error {
 
 	// Clean up all networks
 	for networkID := range nm.networks {
-		if err := nm.DeleteNetwork(networkID); err != nil {
+		if err := nm.deleteNetworkLocked(networkID); err != nil {
 			log.Printf("Warning: Failed to delete network %s during shutdown: %v", networkID, err)
 		}
 	}
@@ -928,10 +2080,17 @@ func (nm *NetworkManager) Shutdown() error {
 	return nil
 }
 
-// ExposeServicesForEndpoint exposes services for a specific endpoint.
-//
-// This is a helper method to allow external callers (like ProgramExternalConnectivity)
-// to expose services without direct access to the service manager.
-func (nm *NetworkManager) ExposeServicesForEndpoint(containerID, networkID string, containerIP net.IP, ports []service.ExposedPort) ([]*service.ServiceExposure, error) {
-	return nm.serviceMgr.ExposeServices(containerID, networkID, containerIP, ports)
+// UpdateSAMConfig applies a new SAM configuration for sessions created from now on.
+func (nm *NetworkManager) UpdateSAMConfig(config *i2p.SAMConfig) error {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	return nm.tunnelMgr.UpdateSAMConfig(config)
+}
+
+// SetFirewallBackend selects the firewall backend the manager's networks
+// intercept container traffic with (see Plugin.SetFirewallBackend). It must
+// be called before any network is created.
+func (nm *NetworkManager) SetFirewallBackend(name string) error {
+	return nm.proxyMgr.SetFirewallBackend(name)
 }
@@ -5,23 +5,27 @@
 package plugin
 
 import (
-	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/go-i2p/go-docker-network-i2p/pkg/fakesam"
 	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/testutil"
 )
 
 // TestNetworkManager_CreateNetwork tests network creation functionality.
 func TestNetworkManager_CreateNetwork(t *testing.T) {
-	// Create a mock tunnel manager for testing
-	tunnelMgr := createMockTunnelManager(t)
+	t.Parallel()
 
-	// Create network manager
-	nm, err := NewNetworkManager(tunnelMgr)
-	if err != nil {
-		t.Fatalf("Failed to create network manager: %v", err)
-	}
+	nm := createMockNetworkManager(t)
 
 	tests := []struct {
 		name        string
@@ -121,23 +125,19 @@ func TestNetworkManager_CreateNetwork(t *testing.T) {
 
 // TestNetworkManager_DeleteNetwork tests network deletion functionality.
 func TestNetworkManager_DeleteNetwork(t *testing.T) {
-	// Create a mock tunnel manager for testing
-	tunnelMgr := createMockTunnelManager(t)
+	t.Parallel()
 
-	// Create network manager
-	nm, err := NewNetworkManager(tunnelMgr)
-	if err != nil {
-		t.Fatalf("Failed to create network manager: %v", err)
-	}
+	nm := createMockNetworkManager(t)
 
 	// Create test networks
 	testNetworks := []string{"delete-test-1", "delete-test-2"}
-	for _, networkID := range testNetworks {
+	for i, networkID := range testNetworks {
 		options := map[string]interface{}{}
+		// Networks may not share a subnet
 		ipamData := []IPAMData{
 			{
-				Pool:    "172.30.0.0/16",
-				Gateway: "172.30.0.1",
+				Pool:    fmt.Sprintf("172.30.%d.0/24", i),
+				Gateway: fmt.Sprintf("172.30.%d.1", i),
 			},
 		}
 		if err := nm.CreateNetwork(networkID, options, ipamData); err != nil {
@@ -201,14 +201,9 @@ func TestNetworkManager_DeleteNetwork(t *testing.T) {
 
 // TestNetworkManager_GetNetwork tests network retrieval functionality.
 func TestNetworkManager_GetNetwork(t *testing.T) {
-	// Create a mock tunnel manager for testing
-	tunnelMgr := createMockTunnelManager(t)
+	t.Parallel()
 
-	// Create network manager
-	nm, err := NewNetworkManager(tunnelMgr)
-	if err != nil {
-		t.Fatalf("Failed to create network manager: %v", err)
-	}
+	nm := createMockNetworkManager(t)
 
 	// Create a test network
 	networkID := "get-test-network"
@@ -265,16 +260,490 @@ func TestNetworkManager_GetNetwork(t *testing.T) {
 	}
 }
 
-// TestNetworkManager_ListNetworks tests network listing functionality.
-func TestNetworkManager_ListNetworks(t *testing.T) {
-	// Create a mock tunnel manager for testing
-	tunnelMgr := createMockTunnelManager(t)
+// TestNetworkManager_LookupNetworkByName tests name indexing and lookups.
+func TestNetworkManager_LookupNetworkByName(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	web := &I2PNetwork{ID: "abc123", Name: "web", Endpoints: map[string]*I2PEndpoint{}}
+	shadow := &I2PNetwork{ID: "def456", Name: "abc123", Endpoints: map[string]*I2PEndpoint{}}
+	unnamed := &I2PNetwork{ID: "ghi789", Endpoints: map[string]*I2PEndpoint{}}
+	nm.addNetworkLocked(web)
+	nm.addNetworkLocked(shadow)
+	nm.addNetworkLocked(unnamed)
+
+	if got := nm.GetNetworkByName("web"); got != web {
+		t.Errorf("Expected lookup by name to find web, got %v", got)
+	}
+	if got := nm.GetNetworkByName(""); got != nil {
+		t.Errorf("Expected empty name not to match unnamed network, got %s", got.ID)
+	}
+	if got := nm.LookupNetwork("web"); got != web {
+		t.Errorf("Expected LookupNetwork to resolve name, got %v", got)
+	}
+	if got := nm.LookupNetwork("abc123"); got != web {
+		t.Errorf("Expected IDs to take precedence over names, got %v", got)
+	}
+	if got := nm.LookupNetwork("missing"); got != nil {
+		t.Errorf("Expected no match, got %s", got.ID)
+	}
+
+	// Duplicate names are rejected before any resources are allocated
+	options := map[string]interface{}{
+		"com.docker.network.generic": map[string]interface{}{"name": "web"},
+	}
+	err := nm.CreateNetwork("jkl012", options, nil)
+	if err == nil || !strings.Contains(err.Error(), "already in use") {
+		t.Errorf("Expected duplicate name error, got %v", err)
+	}
+
+	nm.removeNetworkLocked(web)
+	if got := nm.GetNetworkByName("web"); got != nil {
+		t.Errorf("Expected name to be released on removal, got %s", got.ID)
+	}
+	if got := nm.GetNetworkByName("abc123"); got != shadow {
+		t.Errorf("Expected other names to be kept, got %v", got)
+	}
+}
+
+// TestNetworkManager_SubnetConflicts tests overlap detection for new subnets.
+func TestNetworkManager_SubnetConflicts(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	_, existing, _ := net.ParseCIDR("10.50.0.0/16")
+	nm.addNetworkLocked(&I2PNetwork{ID: "net1", Name: "web", Subnet: existing, Endpoints: map[string]*I2PEndpoint{}})
 
-	// Create network manager
-	nm, err := NewNetworkManager(tunnelMgr)
+	tests := []struct {
+		name     string
+		pool     string
+		errorMsg string
+	}{
+		{"disjoint", "10.60.0.0/16", ""},
+		{"same subnet", "10.50.0.0/16", "subnet 10.50.0.0/16 overlaps subnet 10.50.0.0/16 of network web (net1)"},
+		{"nested subnet", "10.50.3.0/24", "subnet 10.50.3.0/24 overlaps subnet 10.50.0.0/16 of network web (net1)"},
+		{"enclosing subnet", "10.0.0.0/8", "subnet 10.0.0.0/8 overlaps subnet 10.50.0.0/16 of network web (net1)"},
+		{"synthetic DNS range", "198.19.0.0/24", "subnet 198.19.0.0/24 overlaps the I2P DNS synthetic address range 198.18.0.0/15"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := nm.allocateNetworkSubnet([]IPAMData{{Pool: tt.pool}})
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.errorMsg {
+				t.Errorf("Expected error %q, got %v", tt.errorMsg, err)
+			}
+		})
+	}
+
+	// CreateNetwork rejects the overlap before checking iptables
+	err := nm.CreateNetwork("net2", nil, []IPAMData{{Pool: "10.50.1.0/24"}})
+	if err == nil || !strings.Contains(err.Error(), "overlaps subnet 10.50.0.0/16") {
+		t.Errorf("Expected overlap error from CreateNetwork, got %v", err)
+	}
+}
+
+// TestNetworkManager_DefaultSubnetAllocation tests that default subnets skip used ranges.
+func TestNetworkManager_DefaultSubnetAllocation(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	subnet, gateway, err := nm.allocateNetworkSubnet(nil)
 	if err != nil {
-		t.Fatalf("Failed to create network manager: %v", err)
+		t.Fatalf("Failed to allocate default subnet: %v", err)
+	}
+	if subnet.String() != "172.20.1.0/24" || !gateway.Equal(net.ParseIP("172.20.1.1")) {
+		t.Errorf("Expected 172.20.1.0/24 via 172.20.1.1, got %s via %s", subnet, gateway)
+	}
+
+	nm.addNetworkLocked(&I2PNetwork{ID: "net1", Subnet: subnet, Endpoints: map[string]*I2PEndpoint{}, TunnelManager: nm.tunnelMgr})
+
+	subnet, _, err = nm.allocateNetworkSubnet(nil)
+	if err != nil {
+		t.Fatalf("Failed to allocate second default subnet: %v", err)
+	}
+	if subnet.String() != "172.20.2.0/24" {
+		t.Errorf("Expected second network to get 172.20.2.0/24, got %s", subnet)
+	}
+	nm.addNetworkLocked(&I2PNetwork{ID: "net2", Subnet: subnet, Endpoints: map[string]*I2PEndpoint{}, TunnelManager: nm.tunnelMgr})
+
+	// Deleting a network releases its subnet for the next one
+	if err := nm.DeleteNetwork("net1"); err != nil {
+		t.Fatalf("Failed to delete network: %v", err)
+	}
+	subnet, _, err = nm.allocateNetworkSubnet(nil)
+	if err != nil {
+		t.Fatalf("Failed to allocate default subnet after deletion: %v", err)
+	}
+	if subnet.String() != "172.20.1.0/24" {
+		t.Errorf("Expected the released 172.20.1.0/24 to be reused, got %s", subnet)
+	}
+}
+
+// TestNetworkManager_EndpointLifecycle tests Docker's Leave→Join→Leave sequences.
+func TestNetworkManager_EndpointLifecycle(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	_, subnet, _ := net.ParseCIDR("10.30.0.0/24")
+	gateway := net.ParseIP("10.30.0.1")
+	network := &I2PNetwork{
+		ID:            "net1",
+		Subnet:        subnet,
+		Gateway:       gateway,
+		Endpoints:     map[string]*I2PEndpoint{},
+		IPAllocator:   NewIPAllocator(subnet, gateway),
+		TunnelManager: nm.tunnelMgr,
+	}
+	nm.addNetworkLocked(network)
+
+	endpoint, err := nm.CreateEndpoint("net1", "ep1", nil)
+	if err != nil {
+		t.Fatalf("Failed to create endpoint: %v", err)
+	}
+	if endpoint.State != EndpointCreated || endpoint.IPAddress.String() != "10.30.0.2" {
+		t.Fatalf("Expected created endpoint with 10.30.0.2, got %s with %s", endpoint.State, endpoint.IPAddress)
+	}
+
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "", nil); err != nil {
+		t.Fatalf("Failed to join endpoint: %v", err)
+	}
+	if endpoint.State != EndpointJoined || endpoint.IPAddress.String() != "10.30.0.2" {
+		t.Errorf("Expected joined endpoint to keep 10.30.0.2, got %s with %s", endpoint.State, endpoint.IPAddress)
+	}
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container2", "", nil); err == nil {
+		t.Error("Expected joining a joined endpoint to fail")
+	}
+
+	// Leave parks the endpoint and frees its address
+	if err := nm.LeaveEndpoint("net1", "ep1"); err != nil {
+		t.Fatalf("Failed to leave endpoint: %v", err)
+	}
+	if endpoint.State != EndpointParked || endpoint.IPAddress != nil || endpoint.ContainerID != "" {
+		t.Errorf("Expected parked endpoint without IP or container, got %+v", endpoint)
+	}
+	if network.IPAllocator.IsAllocated(net.ParseIP("10.30.0.2")) {
+		t.Error("Expected Leave to free 10.30.0.2")
+	}
+
+	// A repeated Leave is a no-op
+	if err := nm.LeaveEndpoint("net1", "ep1"); err != nil {
+		t.Errorf("Expected repeated Leave to succeed, got %v", err)
+	}
+
+	// Rejoining allocates a fresh address through the network's strategy
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "", nil); err != nil {
+		t.Fatalf("Failed to rejoin endpoint: %v", err)
+	}
+	if endpoint.State != EndpointJoined || endpoint.IPAddress.String() != "10.30.0.3" {
+		t.Errorf("Expected rejoined endpoint to get 10.30.0.3, got %s with %s", endpoint.State, endpoint.IPAddress)
+	}
+	if endpoint.MacAddress != generateMACAddress(endpoint.IPAddress) {
+		t.Errorf("Expected MAC address to follow the new IP, got %s", endpoint.MacAddress)
+	}
+
+	if err := nm.LeaveEndpoint("net1", "ep1"); err != nil {
+		t.Fatalf("Failed to leave rejoined endpoint: %v", err)
+	}
+	if stats := network.IPAllocator.Stats(); stats.Allocated != 1 {
+		t.Errorf("Expected only the gateway to remain allocated, got %d", stats.Allocated)
+	}
+
+	// Deleting a parked endpoint must not free anything twice
+	if err := nm.DeleteEndpoint("net1", "ep1"); err != nil {
+		t.Fatalf("Failed to delete endpoint: %v", err)
+	}
+	if endpoint.State != EndpointDeleted {
+		t.Errorf("Expected deleted state, got %s", endpoint.State)
+	}
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "", nil); err == nil {
+		t.Error("Expected joining a deleted endpoint to fail")
+	}
+}
+
+func TestNetworkManager_RequestedAddress(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	_, subnet, _ := net.ParseCIDR("10.30.0.0/24")
+	gateway := net.ParseIP("10.30.0.1")
+	network := &I2PNetwork{
+		ID:            "net1",
+		Subnet:        subnet,
+		Gateway:       gateway,
+		Endpoints:     map[string]*I2PEndpoint{},
+		IPAllocator:   NewIPAllocator(subnet, gateway),
+		TunnelManager: nm.tunnelMgr,
+	}
+	nm.addNetworkLocked(network)
+
+	requested := net.ParseIP("10.30.0.50")
+	endpoint, err := nm.CreateEndpointWithAddress("net1", "ep1", requested, nil)
+	if err != nil {
+		t.Fatalf("Failed to create endpoint: %v", err)
+	}
+	if !endpoint.IPAddress.Equal(requested) || !network.IPAllocator.IsAllocated(requested) {
+		t.Fatalf("Expected endpoint to get the requested 10.30.0.50, got %s", endpoint.IPAddress)
+	}
+
+	tests := []struct {
+		name     string
+		address  string
+		errorMsg string
+	}{
+		{"in use", "10.30.0.50", "requested address 10.30.0.50 is in use by endpoint ep1 on network net1"},
+		{"gateway", "10.30.0.1", "requested address 10.30.0.1 is the gateway of network net1"},
+		{"outside subnet", "10.31.0.5", "is outside subnet 10.30.0.0/24"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := nm.CreateEndpointWithAddress("net1", "ep2", net.ParseIP(tt.address), nil)
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errorMsg, err)
+			}
+			if _, exists := network.Endpoints["ep2"]; exists {
+				t.Error("Expected no endpoint to be stored after a conflict")
+			}
+		})
+	}
+	var inUse *AddressInUseError
+	if _, err := nm.CreateEndpointWithAddress("net1", "ep2", requested, nil); !errors.As(err, &inUse) {
+		t.Errorf("Expected an AddressInUseError, got %v", err)
+	}
+
+	// Endpoints without a requested address are allocated as before
+	other, err := nm.CreateEndpoint("net1", "ep3", nil)
+	if err != nil || other.IPAddress.String() != "10.30.0.2" {
+		t.Fatalf("Expected allocated 10.30.0.2, got %v (%v)", other, err)
+	}
+
+	// A parked endpoint rejoins with the address it requested
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "", nil); err != nil {
+		t.Fatalf("Failed to join endpoint: %v", err)
+	}
+	if err := nm.LeaveEndpoint("net1", "ep1"); err != nil {
+		t.Fatalf("Failed to leave endpoint: %v", err)
+	}
+	if network.IPAllocator.IsAllocated(requested) {
+		t.Error("Expected Leave to free 10.30.0.50")
+	}
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "", nil); err != nil {
+		t.Fatalf("Failed to rejoin endpoint: %v", err)
+	}
+	if !endpoint.IPAddress.Equal(requested) {
+		t.Errorf("Expected rejoined endpoint to get 10.30.0.50 again, got %s", endpoint.IPAddress)
+	}
+}
+
+func TestNetworkManager_RequestedAddressOptions(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	_, subnet, _ := net.ParseCIDR("10.32.0.0/24")
+	gateway := net.ParseIP("10.32.0.1")
+	network := &I2PNetwork{
+		ID:            "net1",
+		Subnet:        subnet,
+		Gateway:       gateway,
+		Endpoints:     map[string]*I2PEndpoint{},
+		IPAllocator:   NewIPAllocator(subnet, gateway),
+		TunnelManager: nm.tunnelMgr,
+	}
+	nm.addNetworkLocked(network)
+
+	// Endpoint options request the address Docker did not assign
+	endpoint, err := nm.CreateEndpoint("net1", "ep1", map[string]interface{}{RequestedIPv4Option: "10.32.0.40"})
+	if err != nil {
+		t.Fatalf("Failed to create endpoint: %v", err)
+	}
+	if endpoint.IPAddress.String() != "10.32.0.40" {
+		t.Fatalf("Expected the requested 10.32.0.40, got %s", endpoint.IPAddress)
+	}
+
+	tests := []struct {
+		name     string
+		address  net.IP
+		options  map[string]interface{}
+		errorMsg string
+	}{
+		{"taken", nil, map[string]interface{}{RequestedIPv4Option: "10.32.0.40"}, "in use by endpoint ep1 on network net1"},
+		{"invalid", nil, map[string]interface{}{RequestedIPv4Option: "fd00::40"}, "invalid i2p.ipam.ipv4_address value"},
+		{"no IPv6 subnet", nil, map[string]interface{}{RequestedIPv6Option: "fd00::40"}, "has no IPv6 subnet"},
+		{"differs from Docker", net.ParseIP("10.32.0.41"), map[string]interface{}{RequestedIPv4Option: "10.32.0.42"}, "does not match the endpoint's address 10.32.0.41"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := nm.CreateEndpointWithAddress("net1", "ep2", tt.address, tt.options)
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errorMsg, err)
+			}
+			if _, exists := network.Endpoints["ep2"]; exists {
+				t.Error("Expected no endpoint to be stored after a failure")
+			}
+		})
+	}
+
+	// Joined endpoints name their container in conflicts
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "", nil); err != nil {
+		t.Fatalf("Failed to join endpoint: %v", err)
+	}
+	if _, err := nm.CreateEndpointWithAddress("net1", "ep2", net.ParseIP("10.32.0.40"), nil); err == nil ||
+		!strings.Contains(err.Error(), "in use by endpoint ep1 of container container1 on network net1") {
+		t.Errorf("Expected the conflict to name container1, got %v", err)
+	}
+
+	// A rejoining endpoint takes the address its Join options request
+	if err := nm.LeaveEndpoint("net1", "ep1"); err != nil {
+		t.Fatalf("Failed to leave endpoint: %v", err)
+	}
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "", map[string]interface{}{RequestedIPv4Option: "10.32.0.60"}); err != nil {
+		t.Fatalf("Failed to rejoin endpoint: %v", err)
+	}
+	if endpoint.IPAddress.String() != "10.32.0.60" || network.IPAllocator.IsAllocated(net.ParseIP("10.32.0.40")) {
+		t.Errorf("Expected the rejoined endpoint to move to 10.32.0.60, got %s", endpoint.IPAddress)
+	}
+
+	// An endpoint joining with its address cannot be given another
+	other, err := nm.CreateEndpoint("net1", "ep3", nil)
+	if err != nil {
+		t.Fatalf("Failed to create endpoint: %v", err)
+	}
+	if _, err := nm.JoinEndpoint("net1", "ep3", "container3", "", map[string]interface{}{RequestedIPv4Option: "10.32.0.70"}); err == nil ||
+		!strings.Contains(err.Error(), "does not match the endpoint's address "+other.IPAddress.String()) {
+		t.Errorf("Expected the join to be refused, got %v", err)
+	}
+}
+
+func TestNetworkManager_DualStack(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	ipamData := []IPAMData{
+		{Pool: "10.31.0.0/24", Gateway: "10.31.0.1/24"},
+		{Pool: "fd00:31::/64", Gateway: "fd00:31::1/64"},
+	}
+	nm.mutex.Lock()
+	setup, err := nm.prepareNetworkLocked("net1", map[string]interface{}{AntiSpoofOption: "false"}, ipamData)
+	if err == nil {
+		nm.addNetworkLocked(setup.network)
+	}
+	nm.mutex.Unlock()
+	if err != nil {
+		t.Fatalf("Failed to prepare dual-stack network: %v", err)
+	}
+
+	network := setup.network
+	if network.Subnet.String() != "10.31.0.0/24" || network.SubnetIPv6.String() != "fd00:31::/64" {
+		t.Fatalf("Expected subnets 10.31.0.0/24 and fd00:31::/64, got %s and %s", network.Subnet, network.SubnetIPv6)
 	}
+	if network.GatewayIPv6.String() != "fd00:31::1" {
+		t.Errorf("Expected IPv6 gateway fd00:31::1, got %s", network.GatewayIPv6)
+	}
+
+	// Docker's IPAM driver passes both addresses
+	requested := net.ParseIP("fd00:31::42")
+	endpoint, err := nm.CreateEndpointWithAddresses("net1", "ep1", nil, requested, nil)
+	if err != nil {
+		t.Fatalf("Failed to create endpoint: %v", err)
+	}
+	if !endpoint.IPv6Address.Equal(requested) || !network.IPv6Allocator.IsAllocated(requested) {
+		t.Fatalf("Expected endpoint to get fd00:31::42, got %s", endpoint.IPv6Address)
+	}
+	if _, err := nm.CreateEndpointWithAddresses("net1", "ep2", nil, requested, nil); err == nil ||
+		!strings.Contains(err.Error(), "in use by endpoint ep1") {
+		t.Errorf("Expected a conflict naming ep1, got %v", err)
+	}
+	if network.IPAllocator.GetAvailableCount() != 252 {
+		t.Errorf("Expected the failed endpoint's IPv4 address to be released, %d available", network.IPAllocator.GetAvailableCount())
+	}
+
+	// Without a requested address one is allocated
+	other, err := nm.CreateEndpoint("net1", "ep3", nil)
+	if err != nil || other.IPv6Address.String() != "fd00:31::2" {
+		t.Fatalf("Expected allocated fd00:31::2, got %v (%v)", other, err)
+	}
+
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "", nil); err != nil {
+		t.Fatalf("Failed to join endpoint: %v", err)
+	}
+	if err := nm.LeaveEndpoint("net1", "ep1"); err != nil {
+		t.Fatalf("Failed to leave endpoint: %v", err)
+	}
+	if endpoint.IPv6Address != nil || network.IPv6Allocator.IsAllocated(requested) {
+		t.Error("Expected Leave to free fd00:31::42")
+	}
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "", nil); err != nil {
+		t.Fatalf("Failed to rejoin endpoint: %v", err)
+	}
+	if !endpoint.IPv6Address.Equal(requested) {
+		t.Errorf("Expected rejoined endpoint to get fd00:31::42 again, got %s", endpoint.IPv6Address)
+	}
+
+	if err := nm.DeleteEndpoint("net1", "ep3"); err != nil {
+		t.Fatalf("Failed to delete endpoint: %v", err)
+	}
+	if network.IPv6Allocator.IsAllocated(net.ParseIP("fd00:31::2")) {
+		t.Error("Expected DeleteEndpoint to free fd00:31::2")
+	}
+}
+
+func TestNetworkManager_IPv6SubnetValidation(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	tests := []struct {
+		name     string
+		pool     string
+		errorMsg string
+	}{
+		{"global unicast", "2001:db8::/64", "is not a unique local address range"},
+		{"synthetic range", "fd69:3270:b32::/64", "overlaps the I2P DNS synthetic address range"},
+		{"too wide", "fc00::/6", "is not a unique local address range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nm.mutex.Lock()
+			_, err := nm.prepareNetworkLocked("net1", map[string]interface{}{}, []IPAMData{{Pool: tt.pool}})
+			nm.mutex.Unlock()
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errorMsg, err)
+			}
+		})
+	}
+
+	// Without an IPv6 pool, IPv6 addresses are refused
+	nm.mutex.Lock()
+	setup, err := nm.prepareNetworkLocked("net2", map[string]interface{}{}, []IPAMData{{Pool: "10.32.0.0/24"}})
+	if err == nil {
+		nm.addNetworkLocked(setup.network)
+	}
+	nm.mutex.Unlock()
+	if err != nil {
+		t.Fatalf("Failed to prepare network: %v", err)
+	}
+	if _, err := nm.CreateEndpointWithAddresses("net2", "ep1", nil, net.ParseIP("fd00::5"), nil); err == nil {
+		t.Error("Expected an IPv6 address on an IPv4-only network to be refused")
+	}
+}
+
+// TestNetworkManager_ListNetworks tests network listing functionality.
+func TestNetworkManager_ListNetworks(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
 
 	// Test with empty manager
 	networks := nm.ListNetworks()
@@ -284,12 +753,13 @@ func TestNetworkManager_ListNetworks(t *testing.T) {
 
 	// Create test networks
 	testNetworkIDs := []string{"list-test-1", "list-test-2", "list-test-3"}
-	for _, networkID := range testNetworkIDs {
+	for i, networkID := range testNetworkIDs {
 		options := map[string]interface{}{}
+		// Networks may not share a subnet
 		ipamData := []IPAMData{
 			{
-				Pool:    "172.40.0.0/16",
-				Gateway: "172.40.0.1",
+				Pool:    fmt.Sprintf("172.40.%d.0/24", i),
+				Gateway: fmt.Sprintf("172.40.%d.1", i),
 			},
 		}
 		if err := nm.CreateNetwork(networkID, options, ipamData); err != nil {
@@ -318,12 +788,10 @@ func TestNetworkManager_ListNetworks(t *testing.T) {
 
 // TestI2PNetwork_BasicOperations tests basic network operations.
 func TestI2PNetwork_BasicOperations(t *testing.T) {
+	t.Parallel()
+
 	// Create mock tunnel manager and network manager
-	tunnelMgr := createMockTunnelManager(t)
-	nm, err := NewNetworkManager(tunnelMgr)
-	if err != nil {
-		t.Fatalf("Failed to create network manager: %v", err)
-	}
+	nm := createMockNetworkManager(t)
 
 	// Create test network
 	networkID := "basic-ops-test"
@@ -376,6 +844,8 @@ func TestI2PNetwork_BasicOperations(t *testing.T) {
 
 // TestNewNetworkManager tests network manager creation.
 func TestNewNetworkManager(t *testing.T) {
+	t.Parallel()
+
 	tests := []struct {
 		name        string
 		tunnelMgr   *i2p.TunnelManager
@@ -432,28 +902,42 @@ func TestNewNetworkManager(t *testing.T) {
 	}
 }
 
-// createMockTunnelManager creates a tunnel manager for testing with real SAM connection.
-//
-// This function creates a tunnel manager that connects to the actual I2P SAM bridge
-// on localhost:7656, suitable for integration testing.
-func createMockTunnelManager(t *testing.T) *i2p.TunnelManager {
-	// Create a SAM client that connects to the real SAM bridge
-	samClient, err := i2p.NewSAMClient(&i2p.SAMConfig{
-		Host:    "127.0.0.1",
-		Port:    7656,
-		Timeout: 30 * time.Second, // 30 seconds timeout
-	})
+// startFakeSAM starts an in-memory SAM bridge that is closed when the test
+// ends, and returns its configuration.
+func startFakeSAM(t testing.TB) *i2p.SAMConfig {
+	return fakeSAMConfig(testutil.StartFakeSAM(t, fakesam.ServerOptions{}))
+}
+
+// fakeSAMConfig returns the configuration to reach a fake SAM bridge.
+func fakeSAMConfig(server *fakesam.Server) *i2p.SAMConfig {
+	host, port := testutil.SAMAddress(server)
+	return &i2p.SAMConfig{Host: host, Port: port, Timeout: testutil.StartTimeout}
+}
+
+// createMockTunnelManager creates a tunnel manager for testing on a fake SAM
+// bridge of its own, so tests need no I2P router.
+func createMockTunnelManager(t testing.TB) *i2p.TunnelManager {
+	t.Helper()
+
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: startFakeSAM(t)})
+	t.Cleanup(func() { tunnelMgr.DestroyAllTunnels() })
+	return tunnelMgr
+}
+
+// createMockNetworkManager creates a network manager for testing on a fake
+// SAM bridge with the noop firewall backend, so tests need neither an I2P
+// router nor root.
+func createMockNetworkManager(t testing.TB) *NetworkManager {
+	t.Helper()
+
+	nm, err := NewNetworkManager(createMockTunnelManager(t))
 	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
+		t.Fatalf("Failed to create network manager: %v", err)
 	}
-
-	// Connect to the SAM bridge for real testing
-	ctx := context.Background()
-	if err := samClient.Connect(ctx); err != nil {
-		t.Fatalf("Failed to connect to I2P SAM bridge at localhost:7656: %v", err)
+	if err := nm.SetFirewallBackend(proxy.FirewallNoop); err != nil {
+		t.Fatalf("Failed to select the noop firewall: %v", err)
 	}
-
-	return i2p.NewTunnelManager(samClient)
+	return nm
 }
 
 // TestParseNetworkExposureConfig tests network exposure configuration parsing.
@@ -578,13 +1062,69 @@ func TestParseNetworkExposureConfig(t *testing.T) {
 	}
 }
 
+// TestParseAllowedExposureTargets tests parsing of the strict mode target allowlist.
+func TestParseAllowedExposureTargets(t *testing.T) {
+	config := parseNetworkExposureConfig(map[string]interface{}{})
+	if len(config.AllowedTargets) != 0 {
+		t.Errorf("Expected no allowed targets by default, got %v", config.AllowedTargets)
+	}
+
+	config = parseNetworkExposureConfig(map[string]interface{}{
+		"i2p.exposure.allowed_targets": "127.0.0.1, eth1,,10.0.5.2",
+	})
+	expected := []string{"127.0.0.1", "eth1", "10.0.5.2"}
+	if len(config.AllowedTargets) != len(expected) {
+		t.Fatalf("Expected targets %v, got %v", expected, config.AllowedTargets)
+	}
+	for i, target := range expected {
+		if config.AllowedTargets[i] != target {
+			t.Errorf("Expected target %d to be %s, got %s", i, target, config.AllowedTargets[i])
+		}
+	}
+}
+
+// TestFilterAllowedExposureTargets tests that disallowed IP exposures are dropped.
+func TestFilterAllowedExposureTargets(t *testing.T) {
+	config := service.NetworkExposureConfig{
+		AllowIPExposure: true,
+		AllowedTargets:  []string{"127.0.0.1"},
+	}
+
+	ports := []service.ExposedPort{
+		{ContainerPort: 80, ExposureType: service.ExposureTypeI2P},
+		{ContainerPort: 443, ExposureType: service.ExposureTypeIP, TargetIP: "127.0.0.1"},
+		{ContainerPort: 8080, ExposureType: service.ExposureTypeIP, TargetIP: "0.0.0.0"},
+		{ContainerPort: 9090, ExposureType: service.ExposureTypeIP},
+	}
+
+	allowed := filterAllowedExposureTargets(config, ports)
+	if len(allowed) != 3 {
+		t.Fatalf("Expected 3 allowed ports, got %d: %+v", len(allowed), allowed)
+	}
+	for _, port := range allowed {
+		if port.ContainerPort == 8080 {
+			t.Error("Expected wildcard exposure on port 8080 to be refused")
+		}
+	}
+}
+
+func TestFilterClientOnlyExposures(t *testing.T) {
+	ports := []service.ExposedPort{
+		{ContainerPort: 80, ExposureType: service.ExposureTypeI2P},
+		{ContainerPort: 443, ExposureType: service.ExposureTypeIP, TargetIP: "127.0.0.1"},
+	}
+
+	allowed := filterClientOnlyExposures("container-123", ports)
+	if len(allowed) != 1 || allowed[0].ContainerPort != 443 {
+		t.Fatalf("Expected only the IP exposure to remain, got %+v", allowed)
+	}
+}
+
 // TestNetworkCreationWithExposureConfig tests that networks are created with proper exposure configuration.
 func TestNetworkCreationWithExposureConfig(t *testing.T) {
-	tunnelMgr := createMockTunnelManager(t)
-	nm, err := NewNetworkManager(tunnelMgr)
-	if err != nil {
-		t.Fatalf("Failed to create network manager: %v", err)
-	}
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
 
 	tests := []struct {
 		name                    string
@@ -636,14 +1176,15 @@ func TestNetworkCreationWithExposureConfig(t *testing.T) {
 	}
 
 	// Create all networks first (proxy manager starts with first network)
-	ipamData := []IPAMData{
-		{
-			Pool:    "172.20.0.0/16",
-			Gateway: "172.20.0.1",
-		},
-	}
+	for i, tt := range tests {
+		// Networks may not share a subnet
+		ipamData := []IPAMData{
+			{
+				Pool:    fmt.Sprintf("172.21.%d.0/24", i),
+				Gateway: fmt.Sprintf("172.21.%d.1", i),
+			},
+		}
 
-	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			err := nm.CreateNetwork(tt.networkID, tt.options, ipamData)
 			if err != nil {
@@ -680,3 +1221,239 @@ func TestNetworkCreationWithExposureConfig(t *testing.T) {
 		}
 	}
 }
+
+// TestNetworkManager_ResolveProxySource tests mapping proxy clients to endpoints.
+func TestNetworkManager_ResolveProxySource(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	_, subnet, _ := net.ParseCIDR("172.30.0.0/24")
+	network := &I2PNetwork{
+		ID:      "net1",
+		Subnet:  subnet,
+		Gateway: net.ParseIP("172.30.0.1"),
+		Endpoints: map[string]*I2PEndpoint{
+			"ep1":    {ID: "ep1", ContainerID: "web", IPAddress: net.ParseIP("172.30.0.2")},
+			"parked": {ID: "parked", State: EndpointParked},
+		},
+	}
+	nm.addNetworkLocked(network)
+
+	source, ok := nm.resolveProxySource(net.ParseIP("172.30.0.2"))
+	if !ok {
+		t.Fatal("Expected endpoint address to resolve")
+	}
+	if source.NetworkID != "net1" || source.EndpointID != "ep1" || source.ContainerID != "web" {
+		t.Errorf("Unexpected source: %+v", source)
+	}
+
+	for _, addr := range []string{"172.30.0.1", "172.30.0.3", "127.0.0.1", "10.0.0.2"} {
+		if source, ok := nm.resolveProxySource(net.ParseIP(addr)); ok {
+			t.Errorf("Expected %s not to resolve, got %+v", addr, source)
+		}
+	}
+}
+
+// newConcurrencyTestManager returns a manager with count networks whose link
+// changes take delay, standing in for the time Join spends creating links.
+// Published ports get their tunnels from the fake SAM bridge.
+func newConcurrencyTestManager(tb testing.TB, count int, delay time.Duration) *NetworkManager {
+	tb.Helper()
+
+	nm := createMockNetworkManager(tb)
+	nm.linkBackend = LinkBackendNetlink
+	links := newFakeLinks()
+	links.hook = func(string) { time.Sleep(delay) }
+	nm.links = links
+
+	for i := 0; i < count; i++ {
+		_, subnet, _ := net.ParseCIDR(fmt.Sprintf("10.60.%d.0/24", i))
+		gateway := net.ParseIP(fmt.Sprintf("10.60.%d.1", i))
+		nm.addNetworkLocked(&I2PNetwork{
+			ID:            fmt.Sprintf("net%d", i),
+			Subnet:        subnet,
+			Gateway:       gateway,
+			Bridge:        fmt.Sprintf("i2pbr-net%d", i),
+			Endpoints:     map[string]*I2PEndpoint{},
+			IPAllocator:   NewIPAllocator(subnet, gateway),
+			TunnelManager: nm.tunnelMgr,
+		})
+	}
+	return nm
+}
+
+// runEndpointLifecycle creates and joins an endpoint, publishes a port of its
+// container over I2P and revokes it, then leaves and deletes the endpoint.
+func runEndpointLifecycle(nm *NetworkManager, networkID, endpointID, containerID string) error {
+	if _, err := nm.CreateEndpoint(networkID, endpointID, nil); err != nil {
+		return err
+	}
+	if _, err := nm.JoinEndpoint(networkID, endpointID, containerID, "", nil); err != nil {
+		return err
+	}
+	if _, err := nm.PublishPorts(networkID, endpointID, []PortBinding{{Proto: protoTCP, Port: 80}}); err != nil {
+		return err
+	}
+	if err := nm.UnpublishPorts(networkID, endpointID); err != nil {
+		return err
+	}
+	if err := nm.LeaveEndpoint(networkID, endpointID); err != nil {
+		return err
+	}
+	return nm.DeleteEndpoint(networkID, endpointID)
+}
+
+// TestNetworkManager_ConcurrentEndpoints runs endpoint operations on several
+// networks at once, which hold only their own network's lock.
+func TestNetworkManager_ConcurrentEndpoints(t *testing.T) {
+	nm := newConcurrencyTestManager(t, 4, 0)
+	nm.statePath = t.TempDir() + "/networks.json"
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*10)
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 10; j++ {
+			wg.Add(1)
+			go func(networkID, endpointID string) {
+				defer wg.Done()
+				// Every container joins one network, some share an alias
+				options := map[string]interface{}{"Labels": map[string]interface{}{AliasLabel: "shared"}}
+				if _, err := nm.CreateEndpoint(networkID, endpointID, nil); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := nm.JoinEndpoint(networkID, endpointID, "container-"+endpointID, "", options); err != nil {
+					errs <- err
+				}
+			}(fmt.Sprintf("net%d", i), fmt.Sprintf("ep%d-%d", i, j))
+		}
+	}
+
+	// Admin reads run alongside the endpoint operations
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nm.adminNetworks("")
+			nm.endpointStats()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Endpoint operation failed: %v", err)
+	}
+
+	for _, networkID := range nm.ListNetworks() {
+		if count := len(nm.GetNetwork(networkID).Endpoints); count != 10 {
+			t.Errorf("Expected 10 endpoints on %s, got %d", networkID, count)
+		}
+	}
+	if !nm.joined.contains("container-ep0-0") {
+		t.Error("Expected joined containers to be indexed")
+	}
+
+	if err := runEndpointLifecycle(nm, "net0", "last", "container-last"); err != nil {
+		t.Fatalf("Endpoint lifecycle failed: %v", err)
+	}
+	if nm.joined.contains("container-last") {
+		t.Error("Expected a container that left to be dropped from the index")
+	}
+}
+
+// TestNetworkManager_JoinReleasesManagerLock checks that a slow join holds
+// only its network's lock, so networks can be deleted meanwhile.
+func TestNetworkManager_JoinReleasesManagerLock(t *testing.T) {
+	nm := newConcurrencyTestManager(t, 2, 0)
+
+	// Joins on net0 block while creating their link until released
+	linking := make(chan struct{})
+	release := make(chan struct{})
+	links := newFakeLinks()
+	links.hook = func(command string) {
+		if strings.HasSuffix(command, "master i2pbr-net0") {
+			close(linking)
+			<-release
+		}
+	}
+	nm.links = links
+
+	if _, err := nm.CreateEndpoint("net0", "ep0", nil); err != nil {
+		t.Fatalf("CreateEndpoint() unexpected error: %v", err)
+	}
+	joined := make(chan error, 1)
+	go func() {
+		_, err := nm.JoinEndpoint("net0", "ep0", "container0", "", nil)
+		joined <- err
+	}()
+	<-linking
+
+	deleted := make(chan error, 1)
+	go func() { deleted <- nm.DeleteNetwork("net1") }()
+	select {
+	case err := <-deleted:
+		if err != nil {
+			t.Errorf("DeleteNetwork() unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a network to be deleted during a join on another network")
+	}
+
+	close(release)
+	if err := <-joined; err != nil {
+		t.Fatalf("JoinEndpoint() unexpected error: %v", err)
+	}
+}
+
+// TestNetworkManager_DeletedNetworkEndpoints checks that operations which
+// found a network before it was deleted fail as if it did not exist.
+func TestNetworkManager_DeletedNetworkEndpoints(t *testing.T) {
+	nm := newConcurrencyTestManager(t, 1, 0)
+	if _, err := nm.CreateEndpoint("net0", "ep0", nil); err != nil {
+		t.Fatalf("CreateEndpoint() unexpected error: %v", err)
+	}
+
+	// DeleteNetwork sets the flag before it removes the network
+	network := nm.GetNetwork("net0")
+	network.deleted = true
+
+	if _, err := nm.JoinEndpoint("net0", "ep0", "container0", "", nil); err == nil || !strings.Contains(err.Error(), "network net0 not found") {
+		t.Errorf("Expected the join to fail with network not found, got %v", err)
+	}
+	if _, err := nm.PublishPorts("net0", "ep0", []PortBinding{{Proto: protoTCP, Port: 80}}); err == nil || !strings.Contains(err.Error(), "network net0 not found") {
+		t.Errorf("Expected publishing to fail with network not found, got %v", err)
+	}
+	if _, err := nm.GetEndpoint("net0", "ep0"); err == nil {
+		t.Error("Expected the endpoint of a deleted network not to be found")
+	}
+	if network.Endpoints["ep0"].State != EndpointCreated {
+		t.Errorf("Expected the endpoint to be left alone, got state %v", network.Endpoints["ep0"].State)
+	}
+}
+
+// BenchmarkNetworkManager_ConcurrentJoin measures endpoint lifecycles,
+// port publishing included, run in parallel on one network and spread over
+// several. Operations on one network take turns on its lock; operations on
+// different networks do not wait for each other.
+func BenchmarkNetworkManager_ConcurrentJoin(b *testing.B) {
+	for _, networks := range []int{1, 8} {
+		b.Run(fmt.Sprintf("networks=%d", networks), func(b *testing.B) {
+			nm := newConcurrencyTestManager(b, networks, 20*time.Microsecond)
+			var next atomic.Int64
+
+			b.SetParallelism(networks)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					n := next.Add(1)
+					endpointID := fmt.Sprintf("ep%d", n)
+					if err := runEndpointLifecycle(nm, fmt.Sprintf("net%d", n%int64(networks)), endpointID, "container-"+endpointID); err != nil {
+						b.Errorf("Endpoint lifecycle failed: %v", err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Package plugin provides starting and stopping on-demand containers.
+//
+// Containers labeled i2p.expose.ondemand keep their I2P destination while
+// they are stopped (see service.OnDemandLabel). The plugin starts them
+// through the Docker Engine API when an inbound stream arrives and stops
+// them again once they have been idle, so anonymous services can scale to
+// zero without an orchestrator. Docker then calls Leave and Join as for any
+// other container, which parks and resumes the exposures.
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+// onDemandStopGrace is how many seconds Docker waits for an idle on-demand
+// container to exit before killing it.
+const onDemandStopGrace = "10"
+
+// newContainerRunner returns a client starting and stopping containers
+// through the Engine API socket at socketPath.
+func newContainerRunner(socketPath string) *dockerClient {
+	client := newDockerClient(socketPath)
+	// Starting a container waits for its Join, which may generate keys for
+	// minutes; the caller's context bounds each request instead
+	client.http.Timeout = 0
+	return client
+}
+
+// StartContainer starts a stopped container. A container that is already
+// running is left alone.
+func (c *dockerClient) StartContainer(ctx context.Context, containerID string) error {
+	return c.containerAction(ctx, containerID, "start", nil)
+}
+
+// StopContainer stops a running container. A container that is already
+// stopped is left alone.
+func (c *dockerClient) StopContainer(ctx context.Context, containerID string) error {
+	return c.containerAction(ctx, containerID, "stop", url.Values{"t": {onDemandStopGrace}})
+}
+
+// containerAction posts a start or stop request for a container. Containers
+// Docker does not know are reported as service.ErrContainerGone.
+func (c *dockerClient) containerAction(ctx context.Context, containerID, action string, query url.Values) error {
+	target := "http://docker/containers/" + url.PathEscape(containerID) + "/" + action
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusNotModified:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("%s container %s: %w", action, containerID, service.ErrContainerGone)
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s container %s: %s: %s", action, containerID, resp.Status, body)
+	}
+}
@@ -0,0 +1,48 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+func TestContainerRunner(t *testing.T) {
+	var requests []string
+	client := startDockerTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/containers/running/start":
+			w.WriteHeader(http.StatusNotModified)
+		case "/containers/web/start", "/containers/web/stop":
+			w.WriteHeader(http.StatusNoContent)
+		case "/containers/broken/start":
+			http.Error(w, "port is already allocated", http.StatusInternalServerError)
+		default:
+			http.Error(w, "No such container", http.StatusNotFound)
+		}
+	})
+	ctx := context.Background()
+
+	if err := client.StartContainer(ctx, "web"); err != nil {
+		t.Errorf("StartContainer() unexpected error: %v", err)
+	}
+	if err := client.StartContainer(ctx, "running"); err != nil {
+		t.Errorf("Expected an already running container to be accepted, got %v", err)
+	}
+	if err := client.StopContainer(ctx, "web"); err != nil {
+		t.Errorf("StopContainer() unexpected error: %v", err)
+	}
+	if err := client.StartContainer(ctx, "removed"); !errors.Is(err, service.ErrContainerGone) {
+		t.Errorf("Expected ErrContainerGone for an unknown container, got %v", err)
+	}
+	if err := client.StartContainer(ctx, "broken"); err == nil || errors.Is(err, service.ErrContainerGone) {
+		t.Errorf("Expected the Engine API error, got %v", err)
+	}
+
+	if len(requests) < 3 || requests[0] != "POST /containers/web/start" || requests[2] != "POST /containers/web/stop?t="+onDemandStopGrace {
+		t.Errorf("Unexpected requests %v", requests)
+	}
+}
@@ -0,0 +1,302 @@
+// Package plugin provides OpenAPI generation for the admin API.
+//
+// This file builds the OpenAPI 3 document for the admin API from the route
+// table and the Go response types, so the published spec always matches the
+// endpoints actually being served.
+package plugin
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// adminOpenAPISpec generates the OpenAPI document for the admin API.
+func (p *Plugin) adminOpenAPISpec() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]interface{}{}
+
+	errorSchema := openAPISchemaRef(reflect.TypeOf(AdminError{}), schemas)
+
+	for _, route := range p.adminRoutes() {
+		contentType := route.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+
+		responses := map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "Successful response",
+				"content": map[string]interface{}{
+					contentType: map[string]interface{}{
+						"schema": openAPISchemaRef(reflect.TypeOf(route.response), schemas),
+					},
+				},
+			},
+		}
+		for status, description := range route.statuses {
+			responses[strconv.Itoa(status)] = map[string]interface{}{
+				"description": description,
+				"content": map[string]interface{}{
+					contentType: map[string]interface{}{
+						"schema": openAPISchemaRef(reflect.TypeOf(route.response), schemas),
+					},
+				},
+			}
+		}
+		if route.paginated {
+			responses["200"].(map[string]interface{})["headers"] = map[string]interface{}{
+				TotalCountHeader: map[string]interface{}{
+					"description": "Number of items in the list before paging",
+					"schema":      map[string]interface{}{"type": "integer"},
+				},
+			}
+		}
+		if route.request != nil || route.paginated {
+			responses["400"] = map[string]interface{}{
+				"description": "Malformed request",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": errorSchema},
+				},
+			}
+		}
+		if route.notFoundable {
+			responses["404"] = map[string]interface{}{
+				"description": "Resource not found",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": errorSchema},
+				},
+			}
+		}
+		if route.failable {
+			responses["500"] = map[string]interface{}{
+				"description": "Operation failed",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": errorSchema},
+				},
+			}
+		}
+
+		operation := map[string]interface{}{
+			"summary":     route.summary,
+			"operationId": openAPIOperationID(route.method, route.path),
+			"responses":   responses,
+		}
+
+		if route.request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": openAPISchemaRef(reflect.TypeOf(route.request), schemas),
+					},
+				},
+			}
+		}
+
+		params := openAPIPathParameters(route.path)
+		params = append(params, openAPIQueryParameters(route.query)...)
+		if route.paginated {
+			params = append(params, openAPIPaginationParameters()...)
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+
+		pathItem, ok := paths[route.path].(map[string]interface{})
+		if !ok {
+			pathItem = map[string]interface{}{}
+			paths[route.path] = pathItem
+		}
+		pathItem[strings.ToLower(route.method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "I2P Docker Network Plugin Admin API",
+			"description": "Administrative API for inspecting I2P networks, tunnels, service exposures, traffic filters and statistics, with batch endpoints for changing exposures, filters and tunnels.",
+			"version":     AdminAPIRevision,
+		},
+		"servers": []interface{}{
+			map[string]interface{}{
+				"url":         "http://localhost",
+				"description": "Unix socket (use curl --unix-socket <admin socket>)",
+			},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+		},
+	}
+}
+
+// openAPIOperationID derives a stable operation ID from a route.
+func openAPIOperationID(method, path string) string {
+	var parts []string
+	parts = append(parts, strings.ToLower(method))
+
+	for _, segment := range strings.Split(strings.Trim(path, "/"), "/") {
+		if strings.HasPrefix(segment, "{") {
+			parts = append(parts, "By"+upperFirst(strings.Trim(segment, "{}")))
+			continue
+		}
+		parts = append(parts, upperFirst(segment))
+	}
+
+	return strings.Join(parts, "")
+}
+
+// upperFirst returns s with its first letter upper-cased.
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// openAPIPathParameters returns parameter objects for {name} path segments.
+func openAPIPathParameters(path string) []interface{} {
+	var params []interface{}
+	for _, segment := range strings.Split(path, "/") {
+		if !strings.HasPrefix(segment, "{") || !strings.HasSuffix(segment, "}") {
+			continue
+		}
+		params = append(params, map[string]interface{}{
+			"name":     strings.Trim(segment, "{}"),
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	return params
+}
+
+// openAPIQueryParameters returns optional string query parameter objects.
+func openAPIQueryParameters(query map[string]string) []interface{} {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var params []interface{}
+	for _, name := range names {
+		params = append(params, map[string]interface{}{
+			"name":        name,
+			"in":          "query",
+			"required":    false,
+			"description": query[name],
+			"schema":      map[string]interface{}{"type": "string"},
+		})
+	}
+	return params
+}
+
+// openAPIPaginationParameters returns the integer limit and offset query
+// parameter objects of paginated routes.
+func openAPIPaginationParameters() []interface{} {
+	var params []interface{}
+	for _, name := range []string{"limit", "offset"} {
+		params = append(params, map[string]interface{}{
+			"name":        name,
+			"in":          "query",
+			"required":    false,
+			"description": paginationQuery[name],
+			"schema":      map[string]interface{}{"type": "integer", "minimum": 0},
+		})
+	}
+	return params
+}
+
+// openAPISchemaRef returns a schema for t, registering named structs as
+// reusable components.
+func openAPISchemaRef(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		// Arbitrary embedded JSON document
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return openAPISchemaRef(t.Elem(), schemas)
+	case reflect.Struct:
+		name := t.Name()
+		if _, exists := schemas[name]; !exists {
+			// Register before recursing so self-referencing types terminate
+			schemas[name] = map[string]interface{}{}
+			schemas[name] = openAPIStructSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": openAPISchemaRef(t.Elem(), schemas),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": openAPISchemaRef(t.Elem(), schemas),
+		}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+// openAPIStructSchema builds an object schema from exported, JSON-tagged fields.
+func openAPIStructSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		omitEmpty := false
+		if tag := field.Tag.Get("json"); tag != "" {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" {
+					omitEmpty = true
+				}
+			}
+		}
+
+		properties[name] = openAPISchemaRef(field.Type, schemas)
+		if !omitEmpty {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
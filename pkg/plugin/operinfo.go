@@ -0,0 +1,126 @@
+// Package plugin provides the operational data of endpoints.
+//
+// Docker asks the driver for an endpoint's operational data with
+// EndpointOperInfo and shows the returned map with the endpoint, so docker
+// inspect and other tooling can display a container's I2P address without
+// going through the admin API. The map carries the endpoint's addresses, the
+// container's alias and I2P destination, its active tunnels, its service exposures with
+// their state, and the endpoint's traffic statistics. Keys are only present
+// when they have a value: a container without a session has no destination
+// yet, and one without exposures has no service addresses.
+package plugin
+
+import (
+	"sort"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+// EndpointOperInfo keys.
+const (
+	// macAddressKey carries the endpoint MAC address, under Docker's own key
+	macAddressKey = "com.docker.network.endpoint.macaddress"
+	// ipv4AddressKey carries the endpoint IPv4 address
+	ipv4AddressKey = "com.i2p.endpoint.ipv4_address"
+	// ipv6AddressKey carries the endpoint IPv6 address on dual-stack networks
+	ipv6AddressKey = "com.i2p.endpoint.ipv6_address"
+	// aliasKey carries the name the container is shown under, if it has one
+	aliasKey = "com.i2p.alias"
+	// destinationKey carries the .b32.i2p address of the container's session
+	destinationKey = "com.i2p.destination"
+	// tunnelsKey carries the names of the container's active tunnels
+	tunnelsKey = "com.i2p.tunnels"
+	// exposuresKey carries the container's service exposures and their state
+	exposuresKey = "com.i2p.exposures"
+	// serviceAddressesKey maps the container's exposure tunnels to their
+	// addresses
+	serviceAddressesKey = "com.i2p.service.addresses"
+)
+
+// EndpointOperInfo returns the operational data of an endpoint, or false if
+// the endpoint does not exist.
+func (nm *NetworkManager) EndpointOperInfo(networkID, endpointID string) (map[string]interface{}, bool) {
+	network := nm.GetNetwork(networkID)
+	if network == nil {
+		return nil, false
+	}
+
+	network.mutex.RLock()
+	defer network.mutex.RUnlock()
+
+	endpoint, exists := network.Endpoints[endpointID]
+	if !exists {
+		return nil, false
+	}
+
+	info := map[string]interface{}{
+		endpointStatsKey: nm.endpointStatsLocked(endpoint),
+	}
+	if endpoint.MacAddress != "" {
+		info[macAddressKey] = endpoint.MacAddress
+	}
+	if endpoint.IPAddress != nil {
+		info[ipv4AddressKey] = endpoint.IPAddress.String()
+	}
+	if endpoint.IPv6Address != nil {
+		info[ipv6AddressKey] = endpoint.IPv6Address.String()
+	}
+
+	if endpoint.Alias != "" {
+		info[aliasKey] = endpoint.Alias
+	}
+
+	if endpoint.ContainerID != "" && nm.tunnelMgr != nil {
+		if destination, known := nm.tunnelMgr.KnownDestination(endpoint.ContainerID); known {
+			if address, err := i2p.B32Address(destination); err == nil {
+				info[destinationKey] = address
+			}
+		}
+	}
+
+	if tunnels := activeTunnelNames(endpoint); len(tunnels) > 0 {
+		info[tunnelsKey] = tunnels
+	}
+
+	if len(endpoint.ServiceExposures) > 0 {
+		info[exposuresKey] = endpoint.adminExposures(network.ID)
+		info[serviceAddressesKey] = serviceAddresses(endpoint)
+	}
+	return info, true
+}
+
+// activeTunnelNames returns the sorted names of an endpoint's active client
+// and server tunnels, including the tunnels serving its exposures. The caller
+// must hold the network's mutex.
+func activeTunnelNames(endpoint *I2PEndpoint) []string {
+	names := make(map[string]bool)
+	for _, tunnels := range []map[string]*i2p.Tunnel{endpoint.ClientTunnels, endpoint.ServerTunnels} {
+		for name, tunnel := range tunnels {
+			if tunnel != nil && tunnel.IsActive() {
+				names[name] = true
+			}
+		}
+	}
+	for _, exposure := range endpoint.ServiceExposures {
+		if exposure.Tunnel != nil && exposure.Tunnel.IsActive() {
+			names[exposure.TunnelName] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// serviceAddresses maps the tunnel names of an endpoint's exposures to their
+// addresses.
+func serviceAddresses(endpoint *I2PEndpoint) map[string]string {
+	addresses := make(map[string]string, len(endpoint.ServiceExposures))
+	for _, exposure := range endpoint.ServiceExposures {
+		addresses[exposure.TunnelName] = exposure.Destination
+	}
+	return addresses
+}
@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+	"github.com/go-i2p/i2pkeys"
+)
+
+func TestEndpointOperInfo(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	nm := p.networkMgr
+
+	if _, exists := nm.EndpointOperInfo("net1", "missing"); exists {
+		t.Error("Expected no data for an unknown endpoint")
+	}
+
+	// A container without a session or exposures reports its addresses only
+	info, exists := nm.EndpointOperInfo("net1", "ep1")
+	if !exists {
+		t.Fatal("Expected data for ep1")
+	}
+	if info[ipv4AddressKey] != "172.20.1.2" || info[macAddressKey] != "02:42:ac:14:01:02" {
+		t.Errorf("Expected the endpoint addresses, got %v", info)
+	}
+	for _, key := range []string{destinationKey, tunnelsKey, exposuresKey, serviceAddressesKey, ipv6AddressKey} {
+		if _, set := info[key]; set {
+			t.Errorf("Expected no %s, got %v", key, info[key])
+		}
+	}
+
+	public := strings.Repeat("A", 516)
+	if err := nm.tunnelMgr.AdoptSessionKeys("container1", i2pkeys.NewKeys(i2pkeys.I2PAddr(public), public+"AAAA")); err != nil {
+		t.Fatalf("AdoptSessionKeys() unexpected error: %v", err)
+	}
+	endpoint := nm.GetNetwork("net1").Endpoints["ep1"]
+	endpoint.IPv6Address = net.ParseIP("fd00::2")
+	endpoint.ServiceExposures = append(endpoint.ServiceExposures, &service.ServiceExposure{
+		ContainerID: "container1",
+		TunnelName:  "container1-web-80",
+		Destination: "web.b32.i2p",
+		Port:        service.ExposedPort{ServiceName: "web", ContainerPort: 80, Protocol: "tcp", ExposureType: service.ExposureTypeI2P},
+	})
+
+	w := httptest.NewRecorder()
+	p.handleEndpointInfo(w, httptest.NewRequest("POST", "/", strings.NewReader(`{"NetworkID": "net1", "EndpointID": "ep1"}`)))
+
+	var response struct {
+		Value struct {
+			IPv6Address      string            `json:"com.i2p.endpoint.ipv6_address"`
+			Destination      string            `json:"com.i2p.destination"`
+			Exposures        []AdminExposure   `json:"com.i2p.exposures"`
+			ServiceAddresses map[string]string `json:"com.i2p.service.addresses"`
+		}
+		Err string
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Err != "" {
+		t.Fatalf("Unexpected EndpointOperInfo response %s (%v)", w.Body.String(), err)
+	}
+	expected, _ := i2p.B32Address(public)
+	if response.Value.Destination != expected {
+		t.Errorf("Expected destination %s, got %q", expected, response.Value.Destination)
+	}
+	if response.Value.IPv6Address != "fd00::2" {
+		t.Errorf("Expected the IPv6 address, got %q", response.Value.IPv6Address)
+	}
+	if len(response.Value.Exposures) != 1 || response.Value.Exposures[0].State != service.ExposureStateActive || response.Value.Exposures[0].NetworkID != "net1" {
+		t.Errorf("Expected the exposure with its state, got %+v", response.Value.Exposures)
+	}
+	if response.Value.ServiceAddresses["container1-web-80"] != "web.b32.i2p" {
+		t.Errorf("Expected the service address, got %v", response.Value.ServiceAddresses)
+	}
+}
@@ -0,0 +1,48 @@
+// Package plugin provides inbound-only containers.
+//
+// Eepsites and other services that only answer I2P streams never need to
+// initiate connections. Labelling such a container with i2p.outbound=false
+// hardens it accordingly: its address gets a firewall rule dropping new
+// connections to the proxy, the SOCKS proxy refuses it as a client, and no
+// client port maps or sidecar sockets are set up for it. Server tunnels work
+// as usual. Traffic that bypasses I2P is dropped for every container anyway,
+// so the container is left without any way out.
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+// OutboundLabel is the container label that disables outbound I2P
+// connections when set to false.
+const OutboundLabel = "i2p.outbound"
+
+// isOutboundDisabled reports whether container options disable outbound I2P.
+//
+// Values that are not booleans are an error rather than being ignored, so a
+// typo cannot silently leave the container able to dial out. Disabling
+// outbound connections of a client-only container would leave it with
+// nothing to do, so that combination is an error too.
+func isOutboundDisabled(options map[string]interface{}) (bool, error) {
+	labels, ok := options["Labels"].(map[string]interface{})
+	if !ok {
+		return false, nil
+	}
+	value, ok := labels[OutboundLabel].(string)
+	if !ok {
+		return false, nil
+	}
+
+	enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		return false, fmt.Errorf("invalid %s label %q: expected true or false", OutboundLabel, value)
+	}
+	if !enabled && service.IsClientOnly(options) {
+		return false, fmt.Errorf("%s=false cannot be combined with %s=%s", OutboundLabel, service.ModeLabel, service.ModeClient)
+	}
+	return !enabled, nil
+}
@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+func TestIsOutboundDisabled(t *testing.T) {
+	labels := func(values map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"Labels": values}
+	}
+
+	tests := []struct {
+		name     string
+		options  map[string]interface{}
+		expected bool
+		wantErr  bool
+	}{
+		{name: "no labels", options: map[string]interface{}{}},
+		{name: "no label", options: labels(map[string]interface{}{"other": "x"})},
+		{name: "enabled", options: labels(map[string]interface{}{OutboundLabel: "true"})},
+		{name: "disabled", options: labels(map[string]interface{}{OutboundLabel: " false "}), expected: true},
+		{name: "disabled numerically", options: labels(map[string]interface{}{OutboundLabel: "0"}), expected: true},
+		{name: "invalid", options: labels(map[string]interface{}{OutboundLabel: "no"}), wantErr: true},
+		{name: "client-only", options: labels(map[string]interface{}{OutboundLabel: "false", service.ModeLabel: service.ModeClient}), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			disabled, err := isOutboundDisabled(tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if disabled != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, disabled)
+			}
+		})
+	}
+}
+
+func TestResolveProxySourceOutboundDisabled(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+
+	_, subnet, _ := net.ParseCIDR("172.30.0.0/24")
+	nm.addNetworkLocked(&I2PNetwork{
+		ID:     "net1",
+		Subnet: subnet,
+		Endpoints: map[string]*I2PEndpoint{
+			"ep1": {ID: "ep1", ContainerID: "site", IPAddress: net.ParseIP("172.30.0.2"), OutboundDisabled: true},
+		},
+	})
+
+	source, ok := nm.resolveProxySource(net.ParseIP("172.30.0.2"))
+	if !ok || !source.OutboundDisabled {
+		t.Errorf("Expected an outbound-disabled source, got %+v (resolved %v)", source, ok)
+	}
+}
+
+func TestAdminDryRunOutboundDisabled(t *testing.T) {
+	_, mux := newAdminTestPlugin(t)
+
+	w, plan := adminDryRun(t, mux, DryRunRequest{
+		Subnet: "172.30.0.0/24",
+		Labels: map[string]string{
+			OutboundLabel:      "false",
+			"i2p.portmap.6667": "irc.postman.i2p",
+			SidecarLabel:       "site",
+		},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if plan.Join == nil || !plan.Join.OutboundDisabled {
+		t.Fatalf("Expected an outbound-disabled join plan, got %+v", plan.Join)
+	}
+	if len(plan.Join.PortMaps) != 0 || plan.Join.Sidecar != nil {
+		t.Errorf("Expected no port maps or sidecar sockets, got %+v", plan.Join)
+	}
+
+	w, _ = adminDryRun(t, mux, DryRunRequest{
+		Subnet: "172.30.0.0/24",
+		Labels: map[string]string{OutboundLabel: "maybe"},
+	})
+	if w.Code == http.StatusOK {
+		t.Error("Expected an invalid label to fail the dry run")
+	}
+}
@@ -0,0 +1,85 @@
+// Package plugin provides pagination of admin API lists.
+//
+// List endpoints return their items in a stable order, so the same state
+// always yields the same output and pages do not shift between requests
+// unless items are added or removed. The limit and offset query parameters
+// select a page, and the X-Total-Count response header reports how many items
+// the list holds in total, before paging. Without parameters the whole list
+// is returned.
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// TotalCountHeader reports the number of items of a paginated list
+	TotalCountHeader = "X-Total-Count"
+
+	// MaxAdminPageSize is the largest page a list request may ask for
+	MaxAdminPageSize = 1000
+)
+
+// adminPage selects a page of a list.
+type adminPage struct {
+	// offset is the number of items skipped
+	offset int
+	// limit is the maximum number of items returned; 0 returns all
+	limit int
+}
+
+// paginationQuery describes the query parameters of paginated routes.
+var paginationQuery = map[string]string{
+	"limit":  fmt.Sprintf("Return at most this many items (1-%d, default all)", MaxAdminPageSize),
+	"offset": "Skip this many items (default 0)",
+}
+
+// parseAdminPage reads the limit and offset query parameters of a request.
+func parseAdminPage(r *http.Request) (adminPage, error) {
+	var page adminPage
+	query := r.URL.Query()
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxAdminPageSize {
+			return page, fmt.Errorf("limit must be between 1 and %d, got %q", MaxAdminPageSize, value)
+		}
+		page.limit = limit
+	}
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return page, fmt.Errorf("offset must be a non-negative integer, got %q", value)
+		}
+		page.offset = offset
+	}
+	return page, nil
+}
+
+// paginate returns the items of a page. Pages beyond the end are empty.
+func paginate[T any](items []T, page adminPage) []T {
+	if page.offset >= len(items) {
+		return []T{}
+	}
+	items = items[page.offset:]
+	if page.limit > 0 && page.limit < len(items) {
+		items = items[:page.limit]
+	}
+	return items
+}
+
+// writeAdminList writes the page of a sorted list the request asks for,
+// reporting the total number of items in the TotalCountHeader header.
+// Malformed pagination parameters are answered with 400 Bad Request.
+func writeAdminList[T any](p *Plugin, w http.ResponseWriter, r *http.Request, items []T) {
+	page, err := parseAdminPage(r)
+	if err != nil {
+		p.writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set(TotalCountHeader, strconv.Itoa(len(items)))
+	p.writeJSONResponse(w, paginate(items, page))
+}
@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		name string
+		page adminPage
+		want []int
+	}{
+		{name: "all", page: adminPage{}, want: []int{1, 2, 3, 4, 5}},
+		{name: "first page", page: adminPage{limit: 2}, want: []int{1, 2}},
+		{name: "middle page", page: adminPage{offset: 2, limit: 2}, want: []int{3, 4}},
+		{name: "last page", page: adminPage{offset: 4, limit: 2}, want: []int{5}},
+		{name: "beyond the end", page: adminPage{offset: 5, limit: 2}, want: []int{}},
+	}
+
+	for _, tt := range tests {
+		got := paginate(items, tt.page)
+		if got == nil || len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+				break
+			}
+		}
+	}
+}
+
+func TestAdminListPagination(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+	for _, networkID := range []string{"net4", "net3", "net2"} {
+		_, subnet, _ := net.ParseCIDR("10.60." + networkID[3:] + ".0/24")
+		p.networkMgr.addNetworkLocked(&I2PNetwork{ID: networkID, Subnet: subnet, Endpoints: map[string]*I2PEndpoint{}})
+	}
+
+	if networks := p.networkMgr.ListNetworks(); strings.Join(networks, ",") != "net1,net2,net3,net4" {
+		t.Errorf("Expected sorted network IDs, got %v", networks)
+	}
+
+	var networks []AdminNetwork
+	w := adminGet(t, mux, "/v1/networks?limit=2&offset=1", &networks)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get(TotalCountHeader) != "4" {
+		t.Errorf("Expected a total of 4, got %q", w.Header().Get(TotalCountHeader))
+	}
+	if len(networks) != 2 || networks[0].ID != "net2" || networks[1].ID != "net3" {
+		t.Errorf("Expected net2 and net3, got %+v", networks)
+	}
+
+	w = adminGet(t, mux, "/v1/networks?offset=10", &networks)
+	if w.Code != http.StatusOK || len(networks) != 0 || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("Expected an empty page beyond the end, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for _, query := range []string{"limit=0", "limit=abc", "limit=1001", "offset=-1"} {
+		if w := adminGet(t, mux, "/v1/tunnels?"+query, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestAdminOpenAPIPagination(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	spec := p.adminOpenAPISpec()
+
+	operation := spec["paths"].(map[string]interface{})["/v1/networks"].(map[string]interface{})["get"].(map[string]interface{})
+	responses := operation["responses"].(map[string]interface{})
+	if _, ok := responses["200"].(map[string]interface{})["headers"].(map[string]interface{})[TotalCountHeader]; !ok {
+		t.Errorf("Expected the %s header to be documented", TotalCountHeader)
+	}
+	if _, ok := responses["400"]; !ok {
+		t.Error("Expected malformed pagination parameters to be documented")
+	}
+
+	names := map[string]bool{}
+	for _, param := range operation["parameters"].([]interface{}) {
+		names[param.(map[string]interface{})["name"].(string)] = true
+	}
+	if !names["limit"] || !names["offset"] || !names["tenant"] {
+		t.Errorf("Expected limit, offset and tenant parameters, got %v", names)
+	}
+}
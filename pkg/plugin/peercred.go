@@ -0,0 +1,182 @@
+// Package plugin provides peer credential checks on the plugin socket.
+//
+// Socket permissions decide who can open the plugin socket, but a socket
+// made group-accessible for Docker admits every member of the group, and
+// any process that connects can create networks and tunnels. When a peer
+// allowlist is set, the plugin asks the kernel for the credentials of every
+// process connecting to the plugin socket (SO_PEERCRED) and closes the
+// connection unless the process runs as an allowed user or is a member of
+// an allowed group. The plugin's own user is always admitted, since it can
+// act as the plugin anyway.
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+)
+
+// Reasons connections to the plugin socket are refused
+const (
+	refuseNoCredentials = "credentials"
+	refuseNotAllowed    = "allowlist"
+)
+
+// PeerAllowlist lists the processes admitted to the plugin socket.
+type PeerAllowlist struct {
+	// Users are user names or numeric UIDs admitted to the socket
+	Users []string
+	// Groups are group names or numeric GIDs whose members are admitted
+	Groups []string
+}
+
+// peerCredentials identifies the process at the other end of a connection.
+type peerCredentials struct {
+	PID int32
+	UID uint32
+	// GIDs are the primary group followed by any supplementary groups
+	GIDs []uint32
+}
+
+// peerPolicy is a peer allowlist resolved to numeric IDs.
+type peerPolicy struct {
+	uids map[uint32]bool
+	gids map[uint32]bool
+}
+
+// SetSocketPeers restricts connections to the plugin socket to processes of
+// the allowlisted users and groups. Names are resolved immediately; names
+// unknown on this host are skipped with a warning, so they admit nobody. An
+// empty allowlist admits every process able to open the socket.
+//
+// Must be called before Start.
+func (p *Plugin) SetSocketPeers(allowlist PeerAllowlist) error {
+	if len(allowlist.Users) == 0 && len(allowlist.Groups) == 0 {
+		p.peerPolicy = nil
+		return nil
+	}
+	if !peerCredentialsSupported {
+		return fmt.Errorf("socket peer allowlists are not supported on %s", runtime.GOOS)
+	}
+
+	policy, err := allowlist.resolve()
+	if err != nil {
+		return err
+	}
+	p.peerPolicy = policy
+	return nil
+}
+
+// resolve converts the allowlist's names to numeric IDs.
+func (allowlist PeerAllowlist) resolve() (*peerPolicy, error) {
+	policy := &peerPolicy{
+		uids: map[uint32]bool{uint32(os.Geteuid()): true},
+		gids: make(map[uint32]bool),
+	}
+
+	for _, name := range allowlist.Users {
+		id, err := strconv.ParseUint(name, 10, 32)
+		if err != nil {
+			u, lookupErr := user.Lookup(name)
+			var unknown user.UnknownUserError
+			if errors.As(lookupErr, &unknown) {
+				log.Printf("Warning: Socket peer allowlist names unknown user %q", name)
+				continue
+			}
+			if lookupErr != nil {
+				return nil, fmt.Errorf("failed to look up socket peer user %q: %w", name, lookupErr)
+			}
+			if id, err = strconv.ParseUint(u.Uid, 10, 32); err != nil {
+				return nil, fmt.Errorf("non-numeric UID %q for user %s", u.Uid, name)
+			}
+		}
+		policy.uids[uint32(id)] = true
+	}
+
+	for _, name := range allowlist.Groups {
+		id, err := strconv.ParseUint(name, 10, 32)
+		if err != nil {
+			g, lookupErr := user.LookupGroup(name)
+			var unknown user.UnknownGroupError
+			if errors.As(lookupErr, &unknown) {
+				log.Printf("Warning: Socket peer allowlist names unknown group %q", name)
+				continue
+			}
+			if lookupErr != nil {
+				return nil, fmt.Errorf("failed to look up socket peer group %q: %w", name, lookupErr)
+			}
+			if id, err = strconv.ParseUint(g.Gid, 10, 32); err != nil {
+				return nil, fmt.Errorf("non-numeric GID %q for group %s", g.Gid, name)
+			}
+		}
+		policy.gids[uint32(id)] = true
+	}
+
+	return policy, nil
+}
+
+// admits reports whether a process with the given credentials is allowed.
+func (policy *peerPolicy) admits(creds peerCredentials) bool {
+	if policy.uids[creds.UID] {
+		return true
+	}
+	for _, gid := range creds.GIDs {
+		if policy.gids[gid] {
+			return true
+		}
+	}
+	return false
+}
+
+// peerListener closes accepted connections of processes the policy does not
+// admit.
+type peerListener struct {
+	net.Listener
+	policy *peerPolicy
+	// refused counts a refused connection by reason
+	refused func(reason string)
+}
+
+// listenPeers wraps the plugin socket listener with the peer allowlist, if
+// one is set.
+func (p *Plugin) listenPeers(listener net.Listener) net.Listener {
+	if p.peerPolicy == nil {
+		return listener
+	}
+	refused := p.metrics.NewCounterVec("i2p_plugin_connections_refused_total",
+		"Plugin socket connections closed by the socket peer allowlist.", "reason")
+	return &peerListener{
+		Listener: listener,
+		policy:   p.peerPolicy,
+		refused:  func(reason string) { refused.Inc(reason) },
+	}
+}
+
+// Accept returns the next connection of an admitted process.
+func (l *peerListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		creds, err := peerCredentialsOf(conn)
+		switch {
+		case err != nil:
+			log.Printf("Warning: Refused plugin socket connection: %v", err)
+			l.refused(refuseNoCredentials)
+		case !l.policy.admits(creds):
+			log.Printf("Warning: Refused plugin socket connection of pid %d (uid %d, gids %v): not in the socket peer allowlist",
+				creds.PID, creds.UID, creds.GIDs)
+			l.refused(refuseNotAllowed)
+		default:
+			return conn, nil
+		}
+		conn.Close()
+	}
+}
@@ -0,0 +1,82 @@
+// Package plugin provides peer credentials of Unix socket connections on
+// Linux.
+//
+// The kernel records the PID, UID and primary GID of a connecting process
+// (SO_PEERCRED). Supplementary groups, which is how users usually belong to
+// the docker group, are read from /proc. Processes in another PID namespace,
+// such as the Docker daemon seen from a managed plugin, have no PID here and
+// are matched on their primary group only.
+package plugin
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// peerCredentialsSupported reports whether peerCredentialsOf works here
+const peerCredentialsSupported = true
+
+// peerCredentialsOf returns the credentials of the process at the other end
+// of a Unix socket connection.
+func peerCredentialsOf(conn net.Conn) (peerCredentials, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return peerCredentials{}, fmt.Errorf("connection from %s is not a Unix socket connection", conn.RemoteAddr())
+	}
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return peerCredentials{}, err
+	}
+
+	var ucred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		ucred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return peerCredentials{}, err
+	}
+	if credErr != nil {
+		return peerCredentials{}, fmt.Errorf("failed to read peer credentials: %w", credErr)
+	}
+
+	creds := peerCredentials{PID: ucred.Pid, UID: ucred.Uid, GIDs: []uint32{ucred.Gid}}
+	if ucred.Pid > 0 {
+		// The process may exit before it is looked up, leaving only the
+		// primary group to match
+		groups, _ := supplementaryGroups(ucred.Pid)
+		creds.GIDs = append(creds.GIDs, groups...)
+	}
+	return creds, nil
+}
+
+// supplementaryGroups returns the supplementary groups of a process.
+func supplementaryGroups(pid int32) ([]uint32, error) {
+	file, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields, found := strings.CutPrefix(scanner.Text(), "Groups:")
+		if !found {
+			continue
+		}
+		var groups []uint32
+		for _, field := range strings.Fields(fields) {
+			gid, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid group %q of process %d", field, pid)
+			}
+			groups = append(groups, uint32(gid))
+		}
+		return groups, nil
+	}
+	return nil, scanner.Err()
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+// Package plugin provides peer credentials of Unix socket connections on
+// platforms without SO_PEERCRED, where socket peer allowlists are refused.
+package plugin
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+// peerCredentialsSupported reports whether peerCredentialsOf works here
+const peerCredentialsSupported = false
+
+// peerCredentialsOf fails, since peer credentials are not available here.
+func peerCredentialsOf(conn net.Conn) (peerCredentials, error) {
+	return peerCredentials{}, fmt.Errorf("peer credentials are not supported on %s", runtime.GOOS)
+}
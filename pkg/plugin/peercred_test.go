@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPeerAllowlistResolve(t *testing.T) {
+	policy, err := PeerAllowlist{
+		Users:  []string{"1234", "no-such-user-i2p"},
+		Groups: []string{"2345", "no-such-group-i2p"},
+	}.resolve()
+	if err != nil {
+		t.Fatalf("resolve() unexpected error: %v", err)
+	}
+
+	euid := uint32(os.Geteuid())
+	tests := []struct {
+		name  string
+		creds peerCredentials
+		want  bool
+	}{
+		{"plugin user", peerCredentials{UID: euid, GIDs: []uint32{9999}}, true},
+		{"allowed user", peerCredentials{UID: 1234, GIDs: []uint32{9999}}, true},
+		{"allowed primary group", peerCredentials{UID: 9998, GIDs: []uint32{2345}}, true},
+		{"allowed supplementary group", peerCredentials{UID: 9998, GIDs: []uint32{9999, 2345}}, true},
+		{"other process", peerCredentials{UID: 9998, GIDs: []uint32{9999}}, euid == 9998},
+	}
+	for _, tt := range tests {
+		if got := policy.admits(tt.creds); got != tt.want {
+			t.Errorf("%s: admits() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetSocketPeers(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+
+	if err := p.SetSocketPeers(PeerAllowlist{}); err != nil || p.peerPolicy != nil {
+		t.Fatalf("Expected an empty allowlist to admit everyone, got %v", err)
+	}
+	err := p.SetSocketPeers(PeerAllowlist{Users: []string{"0"}})
+	if !peerCredentialsSupported {
+		if err == nil {
+			t.Fatal("Expected allowlists to be refused without peer credentials")
+		}
+		return
+	}
+	if err != nil || p.peerPolicy == nil || !p.peerPolicy.uids[0] {
+		t.Fatalf("Expected UID 0 to be allowed, got %+v, %v", p.peerPolicy, err)
+	}
+}
+
+func TestPeerListener(t *testing.T) {
+	if !peerCredentialsSupported {
+		t.Skip("peer credentials are not supported on this platform")
+	}
+
+	// listen serves a socket admitting the given UIDs and dials it
+	listen := func(uids ...uint32) (net.Conn, <-chan net.Conn, <-chan string) {
+		path := filepath.Join(t.TempDir(), "plugin.sock")
+		inner, err := net.Listen("unix", path)
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		refusals := make(chan string, 1)
+		listener := &peerListener{
+			Listener: inner,
+			policy:   &peerPolicy{uids: make(map[uint32]bool), gids: make(map[uint32]bool)},
+			refused:  func(reason string) { refusals <- reason },
+		}
+		for _, uid := range uids {
+			listener.policy.uids[uid] = true
+		}
+		t.Cleanup(func() { listener.Close() })
+
+		accepted := make(chan net.Conn, 1)
+		go func() {
+			if conn, err := listener.Accept(); err == nil {
+				accepted <- conn
+			}
+		}()
+
+		conn, err := net.Dial("unix", path)
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn, accepted, refusals
+	}
+
+	// The test process is not allowed, so its connection is closed
+	conn, _, refusals := listen()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("Expected the refused connection to be closed")
+	}
+	if reason := <-refusals; reason != refuseNotAllowed {
+		t.Errorf("Expected a refusal by the allowlist, got %s", reason)
+	}
+
+	// Once its user is allowed, connections are handed to the server
+	_, accepted, _ := listen(uint32(os.Getuid()))
+	select {
+	case server := <-accepted:
+		creds, err := peerCredentialsOf(server)
+		server.Close()
+		if err != nil {
+			t.Fatalf("peerCredentialsOf() unexpected error: %v", err)
+		}
+		if creds.PID != int32(os.Getpid()) || creds.UID != uint32(os.Getuid()) || creds.GIDs[0] != uint32(os.Getgid()) {
+			t.Errorf("Expected credentials of pid %d uid %d gid %d, got %+v",
+				os.Getpid(), os.Getuid(), os.Getgid(), creds)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the allowed connection to be accepted")
+	}
+}
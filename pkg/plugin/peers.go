@@ -0,0 +1,84 @@
+// Package plugin provides the admin view of blocked inbound I2P peers.
+//
+// Server tunnels block client destinations that flood an exposed service
+// with streams or keep dropping them right away (see i2p.PeerReputation).
+// Blocks expire on their own; operators can list them at GET /v1/peers/blocked
+// and lift a false positive early with POST /v1/peers/{destination}/unblock.
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/metrics"
+)
+
+// AdminBlockedPeer describes an inbound I2P peer whose streams are refused.
+type AdminBlockedPeer struct {
+	// Destination is the peer's base32 address
+	Destination string `json:"destination"`
+	// Reason is "flood" or "empty_streams"
+	Reason string `json:"reason"`
+	// Tunnel is the server tunnel the offence was detected on
+	Tunnel string `json:"tunnel"`
+	// Offences counts the peer's blocks, including this one
+	Offences int `json:"offences"`
+	// BlockedAt is when the block started
+	BlockedAt time.Time `json:"blocked_at"`
+	// Until is when the block expires
+	Until time.Time `json:"until"`
+	// Rejected counts the streams refused during the block
+	Rejected int64 `json:"rejected"`
+}
+
+// SetPeerReputation configures the blocking of abusive inbound I2P peers.
+//
+// May be called while the plugin runs; current blocks keep their expiry
+// unless blocking is disabled.
+func (p *Plugin) SetPeerReputation(config i2p.PeerReputationConfig) error {
+	return p.networkMgr.tunnelMgr.PeerReputation().SetConfig(config)
+}
+
+// adminBlockedPeer returns the admin API representation of a block.
+func adminBlockedPeer(peer i2p.BlockedPeer) AdminBlockedPeer {
+	return AdminBlockedPeer{
+		Destination: peer.Destination,
+		Reason:      peer.Reason,
+		Tunnel:      peer.Tunnel,
+		Offences:    peer.Offences,
+		BlockedAt:   peer.BlockedAt,
+		Until:       peer.Until,
+		Rejected:    peer.Rejected,
+	}
+}
+
+// handleAdminBlockedPeers lists blocked inbound peers, the latest block first.
+func (p *Plugin) handleAdminBlockedPeers(w http.ResponseWriter, r *http.Request) {
+	peers := []AdminBlockedPeer{}
+	for _, peer := range p.networkMgr.tunnelMgr.PeerReputation().Blocked() {
+		peers = append(peers, adminBlockedPeer(peer))
+	}
+	writeAdminList(p, w, r, peers)
+}
+
+// handleAdminUnblockPeer lifts the block of the peer in the path.
+func (p *Plugin) handleAdminUnblockPeer(w http.ResponseWriter, r *http.Request) {
+	destination := r.PathValue("destination")
+
+	peer, blocked := p.networkMgr.tunnelMgr.PeerReputation().Unblock(destination)
+	if !blocked {
+		p.writeAdminError(w, http.StatusNotFound, fmt.Sprintf("destination %s is not blocked", destination))
+		return
+	}
+	p.writeJSONResponse(w, adminBlockedPeer(peer))
+}
+
+// peerSamples returns a collect function reporting one value of the inbound
+// peer blocking summary.
+func (p *Plugin) peerSamples(value func(i2p.PeerReputationStats) float64) func() []metrics.Sample {
+	return func() []metrics.Sample {
+		return []metrics.Sample{{Value: value(p.networkMgr.tunnelMgr.PeerReputation().Stats())}}
+	}
+}
@@ -0,0 +1,30 @@
+package plugin
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+func TestAdminBlockedPeers(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+
+	var peers []AdminBlockedPeer
+	adminGet(t, mux, "/v1/peers/blocked", &peers)
+	if peers == nil || len(peers) != 0 {
+		t.Errorf("Expected an empty list, got %v", peers)
+	}
+
+	w := adminAction(t, mux, "/v1/peers/peer.b32.i2p/unblock", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a peer that is not blocked, got %d", w.Code)
+	}
+
+	if err := p.SetPeerReputation(i2p.PeerReputationConfig{BlockDuration: -1}); err == nil {
+		t.Error("Expected a negative block duration to be rejected")
+	}
+	if err := p.SetPeerReputation(i2p.PeerReputationConfig{}); err != nil {
+		t.Errorf("Failed to disable peer blocking: %v", err)
+	}
+}
@@ -6,6 +6,7 @@ package plugin
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,16 +14,49 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"sync"
+	"sync/atomic"
 
 	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/metrics"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+	"google.golang.org/grpc"
 )
 
 // Plugin represents the I2P Docker network plugin.
 type Plugin struct {
-	sockPath   string
-	listener   net.Listener
-	server     *http.Server
-	networkMgr *NetworkManager
+	sockPath       string
+	sockPerms      SocketPermissions
+	peerPolicy     *peerPolicy
+	tlsAddress     string
+	tlsConfig      *tls.Config
+	tlsListener    net.Listener
+	listener       net.Listener
+	server         *http.Server
+	networkMgr     *NetworkManager
+	adminSockPath  string
+	adminSockPerms SocketPermissions
+	adminServer    *http.Server
+	grpcSockPath   string
+	grpcServer     *grpc.Server
+	logHub         *LogHub
+	rateLimits     RateLimitConfig
+	metrics        *metrics.Registry
+	tracer         atomic.Pointer[RequestTracer]
+	anomalyWebhook atomic.Pointer[anomalyWebhook]
+	stats          statsPersistence
+	identityPath   string
+	statePath      string
+	dockerSocket   string
+	publisher      service.Publisher
+	labels         *labelWriter
+	naming         proxy.NamingResolver
+	smokeTest      smokeTestState
+	buildInfo      buildInfoState
+	maintenance    maintenanceState
+	shutdownOnce   sync.Once
+	shutdownErr    error
 }
 
 // New creates a new instance of the I2P network plugin.
@@ -31,18 +65,25 @@ type Plugin struct {
 // will listen for Docker daemon requests. This follows Docker's plugin
 // discovery mechanism.
 func New(sockPath string) (*Plugin, error) {
+	return NewWithSAMConfig(sockPath, i2p.DefaultSAMConfig())
+}
+
+// NewWithSAMConfig creates a new plugin instance using the given SAM configuration.
+//
+// This allows the binary to apply SAM settings loaded from flags, environment
+// variables and configuration files instead of the defaults.
+func NewWithSAMConfig(sockPath string, samConfig *i2p.SAMConfig) (*Plugin, error) {
 	if sockPath == "" {
 		return nil, fmt.Errorf("socket path cannot be empty")
 	}
 
-	// Create SAM client for I2P connectivity
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
+	// Validate the SAM configuration; sessions connect their own SAM clients
+	if _, err := i2p.NewSAMClient(samConfig); err != nil {
 		return nil, fmt.Errorf("failed to create SAM client: %w", err)
 	}
 
 	// Create tunnel manager for I2P network operations
-	tunnelMgr := i2p.NewTunnelManager(samClient)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: samConfig})
 
 	// Create network manager with I2P integration
 	networkMgr, err := NewNetworkManager(tunnelMgr)
@@ -50,17 +91,41 @@ func New(sockPath string) (*Plugin, error) {
 		return nil, fmt.Errorf("failed to create network manager: %w", err)
 	}
 
-	return &Plugin{
-		sockPath:   sockPath,
-		networkMgr: networkMgr,
-	}, nil
+	p := &Plugin{
+		sockPath:       sockPath,
+		sockPerms:      DefaultSocketPermissions(),
+		networkMgr:     networkMgr,
+		adminSockPerms: DefaultSocketPermissions(),
+		logHub:         NewLogHub(),
+		rateLimits:     DefaultRateLimitConfig(),
+		metrics:        metrics.NewRegistry(),
+	}
+	p.logHub.tag = networkMgr.messageTag
+	p.registerMetrics()
+	networkMgr.proxyMgr.SetAnomalyHandler(p.reportAnomaly)
+
+	return p, nil
 }
 
 // Start begins the plugin operation, listening for Docker daemon requests.
 //
 // This method sets up the Unix socket listener and HTTP server to handle
-// Docker's plugin API calls. It blocks until the context is cancelled.
+// Docker's plugin API calls. It blocks until the context is cancelled or a
+// server fails, then performs an ordered shutdown (see Shutdown).
 func (p *Plugin) Start(ctx context.Context) error {
+	// Restore traffic statistics from the previous run
+	if err := p.loadTrafficStats(); err != nil {
+		log.Printf("Warning: Failed to load traffic statistics: %v", err)
+	}
+	if err := p.loadIdentities(); err != nil {
+		log.Printf("Warning: Failed to load container identities: %v", err)
+	}
+	// Restore networks before Docker can call in, so containers that kept
+	// running through the restart find their endpoints
+	if err := p.restoreNetworks(); err != nil {
+		log.Printf("Warning: Failed to restore networks: %v", err)
+	}
+
 	// Clean up any existing socket file
 	if err := os.RemoveAll(p.sockPath); err != nil {
 		return fmt.Errorf("failed to remove existing socket: %w", err)
@@ -73,9 +138,10 @@ func (p *Plugin) Start(ctx context.Context) error {
 	}
 	p.listener = listener
 
-	// Set socket permissions to allow Docker daemon access
-	if err := os.Chmod(p.sockPath, 0600); err != nil {
-		return fmt.Errorf("failed to set socket permissions: %w", err)
+	// Set socket ownership and permissions to allow Docker daemon access
+	if err := p.sockPerms.apply(p.sockPath); err != nil {
+		listener.Close()
+		return err
 	}
 
 	// Create HTTP server with plugin handlers
@@ -84,24 +150,65 @@ func (p *Plugin) Start(ctx context.Context) error {
 
 	p.server = &http.Server{
 		Handler: mux,
+		// Socket requests are rate limited per peer UID
+		ConnContext: peerConnContext,
 	}
 
 	log.Printf("Plugin listening on %s", p.sockPath)
 
 	// Start server in a goroutine
-	errCh := make(chan error, 1)
+	errCh := make(chan error, 4)
 	go func() {
-		if err := p.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		if err := p.server.Serve(p.listenPeers(listener)); err != nil && err != http.ErrServerClosed {
 			errCh <- fmt.Errorf("server error: %w", err)
 		}
 	}()
 
+	// Serve remote Docker engines over TCP if configured
+	if err := p.startTLSListener(errCh); err != nil {
+		p.server.Shutdown(context.Background())
+		return err
+	}
+
+	// Start the admin API on its own socket if configured
+	if p.adminSockPath != "" {
+		if err := p.startAdminServer(errCh); err != nil {
+			p.server.Shutdown(context.Background())
+			return fmt.Errorf("failed to start admin API: %w", err)
+		}
+	}
+
+	// Start the gRPC admin API on its own socket if configured
+	if p.grpcSockPath != "" {
+		if err := p.startGRPCServer(errCh); err != nil {
+			p.server.Shutdown(context.Background())
+			p.stopAdminServer(context.Background())
+			return fmt.Errorf("failed to start gRPC admin API: %w", err)
+		}
+	}
+
+	go p.logActivation()
+	go p.snapshotTrafficStats(ctx)
+	go p.scheduleMaintenance(ctx)
+	go p.fillKeyPool(ctx)
+	if p.labels != nil {
+		go p.labels.run(ctx)
+	}
+	go p.reconcileNetworks(ctx)
+	go p.runMesh(ctx)
+	if p.smokeTestEnabled() {
+		go p.runSmokeTest(ctx)
+	}
+
 	// Wait for context cancellation or server error
 	select {
 	case <-ctx.Done():
 		log.Println("Shutting down plugin server...")
-		return p.server.Shutdown(context.Background())
+		return p.shutdownWithTimeout()
 	case err := <-errCh:
+		if shutdownErr := p.shutdownWithTimeout(); shutdownErr != nil {
+			log.Printf("Warning: Error during shutdown: %v", shutdownErr)
+		}
 		return err
 	}
 }
@@ -112,32 +219,54 @@ func (p *Plugin) Start(ctx context.Context) error {
 // The handlers provide the required endpoints for plugin activation and
 // network operations.
 func (p *Plugin) setupHandlers(mux *http.ServeMux) {
-	// Plugin activation endpoint
-	mux.HandleFunc("/Plugin.Activate", p.handleActivate)
-
-	// Network driver endpoints (stub implementations for now)
-	mux.HandleFunc("/NetworkDriver.GetCapabilities", p.handleGetCapabilities)
-	mux.HandleFunc("/NetworkDriver.CreateNetwork", p.handleCreateNetwork)
-	mux.HandleFunc("/NetworkDriver.DeleteNetwork", p.handleDeleteNetwork)
-	mux.HandleFunc("/NetworkDriver.CreateEndpoint", p.handleCreateEndpoint)
-	mux.HandleFunc("/NetworkDriver.DeleteEndpoint", p.handleDeleteEndpoint)
-	mux.HandleFunc("/NetworkDriver.EndpointOperInfo", p.handleEndpointInfo)
-	mux.HandleFunc("/NetworkDriver.Join", p.handleJoin)
-	mux.HandleFunc("/NetworkDriver.Leave", p.handleLeave)
-	mux.HandleFunc("/NetworkDriver.DiscoverNew", p.handleDiscoverNew)
-	mux.HandleFunc("/NetworkDriver.DiscoverDelete", p.handleDiscoverDelete)
-	mux.HandleFunc("/NetworkDriver.ProgramExternalConnectivity", p.handleProgramExternalConnectivity)
-	mux.HandleFunc("/NetworkDriver.RevokeExternalConnectivity", p.handleRevokeExternalConnectivity)
+	handlers := []struct {
+		path    string
+		handler http.HandlerFunc
+	}{
+		// Plugin activation endpoint
+		{"/Plugin.Activate", p.handleActivate},
+
+		// Network driver endpoints
+		{"/NetworkDriver.GetCapabilities", p.handleGetCapabilities},
+		{"/NetworkDriver.CreateNetwork", p.handleCreateNetwork},
+		{"/NetworkDriver.DeleteNetwork", p.handleDeleteNetwork},
+		{"/NetworkDriver.CreateEndpoint", p.handleCreateEndpoint},
+		{"/NetworkDriver.DeleteEndpoint", p.handleDeleteEndpoint},
+		{"/NetworkDriver.EndpointOperInfo", p.handleEndpointInfo},
+		{"/NetworkDriver.Join", p.handleJoin},
+		{"/NetworkDriver.Leave", p.handleLeave},
+		{"/NetworkDriver.DiscoverNew", p.handleDiscoverNew},
+		{"/NetworkDriver.DiscoverDelete", p.handleDiscoverDelete},
+		{"/NetworkDriver.ProgramExternalConnectivity", p.handleProgramExternalConnectivity},
+		{"/NetworkDriver.RevokeExternalConnectivity", p.handleRevokeExternalConnectivity},
+
+		// IPAM driver endpoints
+		{"/IpamDriver.GetCapabilities", p.handleIPAMCapabilities},
+		{"/IpamDriver.GetDefaultAddressSpaces", p.handleDefaultAddressSpaces},
+		{"/IpamDriver.RequestPool", p.handleRequestPool},
+		{"/IpamDriver.ReleasePool", p.handleReleasePool},
+		{"/IpamDriver.RequestAddress", p.handleRequestAddress},
+		{"/IpamDriver.ReleaseAddress", p.handleReleaseAddress},
+	}
+
+	for _, h := range handlers {
+		mux.HandleFunc(h.path, negotiateMediaType(p.limitRequests(serverPlugin, h.path, p.traceRequests(h.path, p.instrumentHandler(h.path, h.handler)))))
+	}
+
+	// Docker may address calls under an API version prefix
+	mux.HandleFunc("/", handleVersionedPath(mux))
 }
 
 // handleActivate responds to Docker's plugin activation request.
 //
-// This tells Docker that this plugin implements the NetworkDriver interface.
+// This tells Docker that this plugin implements the NetworkDriver and
+// IpamDriver interfaces.
 func (p *Plugin) handleActivate(w http.ResponseWriter, r *http.Request) {
-	log.Println("Received Plugin.Activate request")
+	build := p.Info().BuildInfo
+	log.Printf("Received Plugin.Activate request (plugin %s, commit %s, admin API %s)", build.Version, build.Commit, AdminAPIRevision)
 
 	response := ActivateResponse{
-		Implements: []string{"NetworkDriver"},
+		Implements: []string{"NetworkDriver", "IpamDriver"},
 	}
 
 	p.writeJSONResponse(w, response)
@@ -148,7 +277,9 @@ func (p *Plugin) handleActivate(w http.ResponseWriter, r *http.Request) {
 // This properly marshals the response data to JSON and handles errors.
 // All Docker plugin API responses must be valid JSON.
 func (p *Plugin) writeJSONResponse(w http.ResponseWriter, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
 
 	if err := json.NewEncoder(w).Encode(data); err != nil {
 		log.Printf("Error encoding JSON response: %v", err)
@@ -180,3 +311,102 @@ func (p *Plugin) readJSONRequest(r *http.Request, v interface{}) error {
 
 	return nil
 }
+
+// SetAddressPublisher sets where the .b32.i2p addresses of I2P exposures are
+// published. A nil publisher disables publication.
+//
+// Must be called before Start so no exposure is missed.
+func (p *Plugin) SetAddressPublisher(publisher service.Publisher) {
+	p.publisher = publisher
+	p.applyPublishers()
+}
+
+// SetExposureConcurrency sets how many exposures of a joining container are
+// created in parallel.
+//
+// May be called while the plugin runs; the limit applies to subsequent joins.
+func (p *Plugin) SetExposureConcurrency(workers int) {
+	p.networkMgr.serviceMgr.SetConcurrency(workers)
+}
+
+// SetDNSAddressMode selects whether I2P names resolve to synthetic IPv4 or
+// IPv6 addresses. It must be called before any network is created.
+func (p *Plugin) SetDNSAddressMode(mode string) error {
+	return p.networkMgr.proxyMgr.SetAddressMode(mode)
+}
+
+// SetDNSQueryLimits configures the per-source and global DNS query rates
+// above which queries are answered with SERVFAIL.
+//
+// May be called while the plugin runs.
+func (p *Plugin) SetDNSQueryLimits(limits proxy.DNSQueryLimits) error {
+	if err := p.networkMgr.proxyMgr.SetDNSQueryLimits(limits); err != nil {
+		return fmt.Errorf("invalid DNS query limits: %w", err)
+	}
+	return nil
+}
+
+// SetStaleChainMode selects how interception chains left behind by a
+// previous instance are handled: proxy.StaleChainsReconcile (default) or
+// proxy.StaleChainsFlush.
+func (p *Plugin) SetStaleChainMode(mode string) error {
+	return p.networkMgr.proxyMgr.SetStaleChainMode(mode)
+}
+
+// SetFirewallBackend selects the firewall backend the plugin's rules are
+// applied with: proxy.FirewallIptables (default), proxy.FirewallNftables or
+// proxy.FirewallNoop. It must be called before any network is created.
+func (p *Plugin) SetFirewallBackend(name string) error {
+	return p.networkMgr.SetFirewallBackend(name)
+}
+
+// SetDefaultSubnet sets the IPv4 subnet networks created without IPAM data
+// get a /24 of (default DefaultSubnet). It must be called before any network
+// is created.
+func (p *Plugin) SetDefaultSubnet(cidr string) error {
+	return p.networkMgr.SetDefaultSubnet(cidr)
+}
+
+// SetLinkBackend selects how endpoint interfaces are created:
+// LinkBackendNetlink, which gives every network a bridge and every joined
+// endpoint a veth pair, or LinkBackendNoop (default). It must be called before
+// any network is created.
+func (p *Plugin) SetLinkBackend(name string) error {
+	return p.networkMgr.SetLinkBackend(name)
+}
+
+// SetNaming selects the backends the DNS resolver and SOCKS proxy use to look
+// up I2P hostnames. Without backends, hostnames are passed to the router
+// unresolved.
+//
+// May be called while the plugin runs; the new backends apply to subsequent
+// lookups.
+func (p *Plugin) SetNaming(config proxy.NamingConfig) error {
+	resolver, err := proxy.NewNamingResolver(config, p.networkMgr.tunnelMgr)
+	if err != nil {
+		return err
+	}
+	p.naming = resolver
+	p.applyNaming()
+	return nil
+}
+
+// SetSOCKSAliases sets the alias=destination pairs the SOCKS proxy uses to
+// map requested hosts such as service.internal to I2P destinations before
+// filtering and dialing them. Without aliases, targets are left unchanged.
+//
+// May be called while the plugin runs; the new aliases apply to subsequent
+// connections.
+func (p *Plugin) SetSOCKSAliases(aliases []string) error {
+	if len(aliases) == 0 {
+		p.networkMgr.proxyMgr.SetTargetRewriter(nil)
+		return nil
+	}
+
+	rewriter, err := proxy.NewAliasRewriter(aliases)
+	if err != nil {
+		return err
+	}
+	p.networkMgr.proxyMgr.SetTargetRewriter(rewriter)
+	return nil
+}
@@ -3,6 +3,8 @@ package plugin
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -10,6 +12,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
 )
 
 func TestNew(t *testing.T) {
@@ -88,11 +92,24 @@ func TestPluginStart(t *testing.T) {
 	}
 }
 
-func TestJSONResponseHandling(t *testing.T) {
-	plugin, err := New("/tmp/test.sock")
+// newTestPlugin creates a plugin on a fake SAM bridge with the noop firewall
+// backend, so tests need neither an I2P router nor root.
+func newTestPlugin(t *testing.T) *Plugin {
+	t.Helper()
+
+	plugin, err := NewWithSAMConfig(filepath.Join(t.TempDir(), "test.sock"), startFakeSAM(t))
 	if err != nil {
 		t.Fatalf("Failed to create plugin: %v", err)
 	}
+	if err := plugin.SetFirewallBackend(proxy.FirewallNoop); err != nil {
+		t.Fatalf("Failed to select firewall backend: %v", err)
+	}
+	t.Cleanup(func() { plugin.networkMgr.tunnelMgr.DestroyAllTunnels() })
+	return plugin
+}
+
+func TestJSONResponseHandling(t *testing.T) {
+	plugin := newTestPlugin(t)
 
 	tests := []struct {
 		name           string
@@ -144,10 +161,7 @@ func TestJSONResponseHandling(t *testing.T) {
 }
 
 func TestRequestParsing(t *testing.T) {
-	plugin, err := New("/tmp/test.sock")
-	if err != nil {
-		t.Fatalf("Failed to create plugin: %v", err)
-	}
+	plugin := newTestPlugin(t)
 
 	tests := []struct {
 		name           string
@@ -218,10 +232,7 @@ func TestRequestParsing(t *testing.T) {
 }
 
 func TestErrorHandling(t *testing.T) {
-	plugin, err := New("/tmp/test.sock")
-	if err != nil {
-		t.Fatalf("Failed to create plugin: %v", err)
-	}
+	plugin := newTestPlugin(t)
 
 	// Test with empty body
 	req := httptest.NewRequest("POST", "/", nil)
@@ -242,10 +253,7 @@ func TestErrorHandling(t *testing.T) {
 
 // TestEndpointLifecycle tests the complete endpoint lifecycle from creation to deletion.
 func TestEndpointLifecycle(t *testing.T) {
-	plugin, err := New("/tmp/test.sock")
-	if err != nil {
-		t.Fatalf("Failed to create plugin: %v", err)
-	}
+	plugin := newTestPlugin(t)
 
 	// First create a network
 	networkID := "test-endpoint-lifecycle-network"
@@ -376,10 +384,7 @@ func TestEndpointLifecycle(t *testing.T) {
 
 // TestEndpointErrorCases tests various error conditions in endpoint management.
 func TestEndpointErrorCases(t *testing.T) {
-	plugin, err := New("/tmp/test.sock")
-	if err != nil {
-		t.Fatalf("Failed to create plugin: %v", err)
-	}
+	plugin := newTestPlugin(t)
 
 	tests := []struct {
 		name        string
@@ -486,10 +491,7 @@ func TestEndpointErrorCases(t *testing.T) {
 
 // TestEndpointDuplicateCreation tests handling of duplicate endpoint creation.
 func TestEndpointDuplicateCreation(t *testing.T) {
-	plugin, err := New("/tmp/test.sock")
-	if err != nil {
-		t.Fatalf("Failed to create plugin: %v", err)
-	}
+	plugin := newTestPlugin(t)
 
 	// Create a network first
 	networkID := "test-duplicate-network"
@@ -558,10 +560,7 @@ func TestEndpointDuplicateCreation(t *testing.T) {
 
 // TestMultipleEndpointsOnNetwork tests creating multiple endpoints on the same network.
 func TestMultipleEndpointsOnNetwork(t *testing.T) {
-	plugin, err := New("/tmp/test.sock")
-	if err != nil {
-		t.Fatalf("Failed to create plugin: %v", err)
-	}
+	plugin := newTestPlugin(t)
 
 	// Create a network
 	networkID := "test-multiple-endpoints-network"
@@ -645,3 +644,153 @@ func TestMultipleEndpointsOnNetwork(t *testing.T) {
 	w = httptest.NewRecorder()
 	plugin.handleDeleteNetwork(w, deleteNetworkReq)
 }
+
+// TestCreateEndpointPrefixLength tests that CreateEndpoint responses carry the
+// prefix length of the network's subnets rather than assuming a /24.
+func TestCreateEndpointPrefixLength(t *testing.T) {
+	t.Parallel()
+
+	networkMgr := createMockNetworkManager(t)
+	plugin := &Plugin{networkMgr: networkMgr}
+
+	tests := []struct {
+		subnet, gateway, ipv6 string
+		address, addressIPv6  string
+	}{
+		{subnet: "10.60.0.0/16", gateway: "10.60.0.1", address: "10.60.0.2/16"},
+		{subnet: "10.61.0.16/28", gateway: "10.61.0.17", address: "10.61.0.18/28"},
+		{subnet: "10.62.0.0/24", gateway: "10.62.0.1", ipv6: "fd00:62::/80", address: "10.62.0.2/24", addressIPv6: "fd00:62::2/80"},
+	}
+	for i, tt := range tests {
+		networkID := fmt.Sprintf("prefix-net%d", i)
+		_, subnet, _ := net.ParseCIDR(tt.subnet)
+		gateway := net.ParseIP(tt.gateway)
+		network := &I2PNetwork{
+			ID:            networkID,
+			Subnet:        subnet,
+			Gateway:       gateway,
+			Endpoints:     map[string]*I2PEndpoint{},
+			IPAllocator:   NewIPAllocator(subnet, gateway),
+			TunnelManager: networkMgr.tunnelMgr,
+		}
+		if tt.ipv6 != "" {
+			_, subnetIPv6, _ := net.ParseCIDR(tt.ipv6)
+			gatewayIPv6 := make(net.IP, net.IPv6len)
+			copy(gatewayIPv6, subnetIPv6.IP)
+			gatewayIPv6[net.IPv6len-1] = 1
+			network.SubnetIPv6, network.GatewayIPv6 = subnetIPv6, gatewayIPv6
+			network.IPv6Allocator = NewIPAllocator(subnetIPv6, gatewayIPv6)
+		}
+		networkMgr.addNetworkLocked(network)
+
+		w := httptest.NewRecorder()
+		plugin.handleCreateEndpoint(w, httptest.NewRequest("POST", "/", strings.NewReader(
+			`{"NetworkID":"`+networkID+`","EndpointID":"ep"}`)))
+		var response CreateEndpointResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse CreateEndpoint response: %v", err)
+		}
+		if response.Err != "" {
+			t.Fatalf("Failed to create endpoint on %s: %s", tt.subnet, response.Err)
+		}
+		if response.Interface.Address != tt.address || response.Interface.AddressIPv6 != tt.addressIPv6 {
+			t.Errorf("Expected %q and %q on %s, got %q and %q", tt.address, tt.addressIPv6, tt.subnet,
+				response.Interface.Address, response.Interface.AddressIPv6)
+		}
+	}
+
+	// Addresses assigned by Docker's IPAM driver are not echoed back
+	w := httptest.NewRecorder()
+	plugin.handleCreateEndpoint(w, httptest.NewRequest("POST", "/", strings.NewReader(
+		`{"NetworkID":"prefix-net0","EndpointID":"ipam","Interface":{"Address":"10.60.3.7/16"}}`)))
+	var response CreateEndpointResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse CreateEndpoint response: %v", err)
+	}
+	if response.Err != "" || response.Interface.Address != "" {
+		t.Errorf("Expected the IPAM address to be left out, got %q (error %q)", response.Interface.Address, response.Err)
+	}
+
+	// A malformed address is rejected instead of replaced by another one
+	for _, iface := range []string{`{"Address":"10.60.300.7/16"}`, `{"AddressIPv6":"bogus"}`} {
+		w = httptest.NewRecorder()
+		plugin.handleCreateEndpoint(w, httptest.NewRequest("POST", "/", strings.NewReader(
+			`{"NetworkID":"prefix-net0","EndpointID":"malformed","Interface":`+iface+`}`)))
+		response = CreateEndpointResponse{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse CreateEndpoint response: %v", err)
+		}
+		if !strings.HasPrefix(response.Err, "invalid address") {
+			t.Errorf("Expected an invalid address error for %s, got %q", iface, response.Err)
+		}
+	}
+	if networkMgr.GetNetwork("prefix-net0").Endpoints["malformed"] != nil {
+		t.Error("Expected no endpoint to be created for a malformed address")
+	}
+}
+
+// TestJoinLeaveCycle tests that an endpoint can be hot re-attached through the
+// Join and Leave handlers, getting a fresh address on every Join.
+func TestJoinLeaveCycle(t *testing.T) {
+	t.Parallel()
+
+	networkMgr := createMockNetworkManager(t)
+	plugin := &Plugin{networkMgr: networkMgr}
+
+	_, subnet, _ := net.ParseCIDR("10.63.0.0/28")
+	gateway := net.ParseIP("10.63.0.1")
+	network := &I2PNetwork{
+		ID:            "cycle-net",
+		Subnet:        subnet,
+		Gateway:       gateway,
+		Endpoints:     map[string]*I2PEndpoint{},
+		IPAllocator:   NewIPAllocator(subnet, gateway),
+		TunnelManager: networkMgr.tunnelMgr,
+	}
+	networkMgr.addNetworkLocked(network)
+
+	call := func(handler http.HandlerFunc, body string) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		var response ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return response.Err
+	}
+	endpointBody := `{"NetworkID":"cycle-net","EndpointID":"ep"}`
+	if err := call(plugin.handleCreateEndpoint, endpointBody); err != "" {
+		t.Fatalf("Failed to create endpoint: %s", err)
+	}
+	endpoint := network.Endpoints["ep"]
+
+	var addresses []string
+	for _, container := range []string{"container1", "container2", "container1"} {
+		joinBody := `{"NetworkID":"cycle-net","EndpointID":"ep","SandboxKey":"/var/run/docker/netns/` + container + `"}`
+		if err := call(plugin.handleJoin, joinBody); err != "" {
+			t.Fatalf("Failed to join %s: %s", container, err)
+		}
+		if endpoint.State != EndpointJoined || endpoint.IPAddress == nil || !network.IPAllocator.IsAllocated(endpoint.IPAddress) {
+			t.Fatalf("Expected %s to join with an allocated address, got %s with %s", container, endpoint.State, endpoint.IPAddress)
+		}
+		addresses = append(addresses, endpoint.IPAddress.String())
+
+		if err := call(plugin.handleLeave, endpointBody); err != "" {
+			t.Fatalf("Failed to leave %s: %s", container, err)
+		}
+		if endpoint.State != EndpointParked || endpoint.IPAddress != nil {
+			t.Fatalf("Expected Leave to park the endpoint without an address, got %s with %s", endpoint.State, endpoint.IPAddress)
+		}
+	}
+	if addresses[0] == addresses[1] || addresses[1] == addresses[2] {
+		t.Errorf("Expected each Join to allocate a fresh address, got %v", addresses)
+	}
+	if stats := network.IPAllocator.Stats(); stats.Allocated != 1 {
+		t.Errorf("Expected only the gateway to remain allocated, got %d", stats.Allocated)
+	}
+
+	if err := call(plugin.handleDeleteEndpoint, endpointBody); err != "" {
+		t.Fatalf("Failed to delete endpoint: %s", err)
+	}
+}
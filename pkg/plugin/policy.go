@@ -0,0 +1,119 @@
+// Package plugin provides network policy templates.
+//
+// Getting a network's security posture right takes half a dozen options,
+// and teams drift apart when every compose file spells them out by hand.
+// `-o i2p.policy=locked-down|standard|open` expands to a fixed bundle of those
+// options when the network is created. Options given explicitly next to the
+// policy win over the template, and the network remembers both the policy and
+// the settings it ended up with so the admin API can show them.
+//
+// No policy loosens the leak protection: non-I2P traffic is dropped on every
+// network and the plugin never routes through an outproxy.
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// PolicyOption is the network option selecting a policy template.
+const PolicyOption = "i2p.policy"
+
+// Policy template names.
+const (
+	PolicyLockedDown = "locked-down"
+	PolicyStandard   = "standard"
+	PolicyOpen       = "open"
+)
+
+// policyTemplates maps policy names to the network options they set.
+var policyTemplates = map[string]map[string]string{
+	// Only allowlisted destinations, no host exposure, no east-west traffic
+	PolicyLockedDown: {
+		FilterModeOption:        "allowlist",
+		"i2p.exposure.default":  "i2p",
+		"i2p.exposure.allow_ip": "false",
+		IsolatedOption:          "true",
+		AntiSpoofOption:         "true",
+	},
+	// Blocklist filtering and I2P-only exposure
+	PolicyStandard: {
+		FilterModeOption:        "blocklist",
+		"i2p.exposure.default":  "i2p",
+		"i2p.exposure.allow_ip": "false",
+		IsolatedOption:          "false",
+		AntiSpoofOption:         "true",
+	},
+	// No destination filtering, host exposure allowed, no ebtables rules
+	PolicyOpen: {
+		FilterModeOption:        "disabled",
+		"i2p.exposure.default":  "i2p",
+		"i2p.exposure.allow_ip": "true",
+		IsolatedOption:          "false",
+		AntiSpoofOption:         "false",
+	},
+}
+
+// Policies returns the names of the policy templates, sorted.
+func Policies() []string {
+	names := make([]string, 0, len(policyTemplates))
+	for name := range policyTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NetworkPolicy records the policy template a network was created with.
+type NetworkPolicy struct {
+	// Name is the policy template
+	Name string `json:"name"`
+	// Settings are the values of the template's options on the network,
+	// including explicit overrides
+	Settings map[string]string `json:"settings"`
+	// Overrides lists the template options that were set explicitly
+	Overrides []string `json:"overrides,omitempty"`
+}
+
+// applyPolicyTemplate expands the i2p.policy option. It returns a copy of
+// options with the template's settings added where they were not given
+// explicitly, and the policy the network records; options without a policy
+// are returned unchanged with a nil policy.
+func applyPolicyTemplate(options map[string]interface{}) (map[string]interface{}, *NetworkPolicy, error) {
+	value, ok := options[PolicyOption]
+	if !ok {
+		return options, nil, nil
+	}
+	name, _ := value.(string)
+	name = strings.ToLower(strings.TrimSpace(name))
+	template, ok := policyTemplates[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid %s value %v: expected one of %s", PolicyOption, value, strings.Join(Policies(), ", "))
+	}
+
+	expanded := make(map[string]interface{}, len(options)+len(template))
+	for key, value := range options {
+		expanded[key] = value
+	}
+
+	policy := &NetworkPolicy{Name: name, Settings: make(map[string]string, len(template))}
+	for key, setting := range template {
+		if explicit, set := options[key]; set {
+			policy.Settings[key] = fmt.Sprint(explicit)
+			policy.Overrides = append(policy.Overrides, key)
+			continue
+		}
+		expanded[key] = setting
+		policy.Settings[key] = setting
+	}
+	sort.Strings(policy.Overrides)
+
+	if len(policy.Overrides) > 0 {
+		log.Printf("Applied network policy %s with explicit overrides: %s", name, strings.Join(policy.Overrides, ", "))
+	} else {
+		log.Printf("Applied network policy %s", name)
+	}
+	return expanded, policy, nil
+}
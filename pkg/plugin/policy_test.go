@@ -0,0 +1,85 @@
+package plugin
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyPolicyTemplate(t *testing.T) {
+	options := map[string]interface{}{"name": "web"}
+	expanded, policy, err := applyPolicyTemplate(options)
+	if err != nil || policy != nil || !reflect.DeepEqual(expanded, options) {
+		t.Fatalf("Expected options without a policy to be unchanged, got %v, %+v, %v", expanded, policy, err)
+	}
+
+	options = map[string]interface{}{
+		PolicyOption:      " Locked-Down ",
+		"i2p.filter.mode": "blocklist",
+	}
+	expanded, policy, err = applyPolicyTemplate(options)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if policy.Name != PolicyLockedDown {
+		t.Errorf("Expected policy %s, got %s", PolicyLockedDown, policy.Name)
+	}
+	if expanded["i2p.filter.mode"] != "blocklist" || expanded[IsolatedOption] != "true" || expanded["i2p.exposure.allow_ip"] != "false" {
+		t.Errorf("Expected template settings with the explicit override, got %v", expanded)
+	}
+	if policy.Settings["i2p.filter.mode"] != "blocklist" || !reflect.DeepEqual(policy.Overrides, []string{"i2p.filter.mode"}) {
+		t.Errorf("Expected the override to be recorded, got %+v", policy)
+	}
+	if _, changed := options[IsolatedOption]; changed {
+		t.Error("Expected the caller's options not to be modified")
+	}
+
+	if _, _, err := applyPolicyTemplate(map[string]interface{}{PolicyOption: "paranoid"}); err == nil || !strings.Contains(err.Error(), "locked-down, open, standard") {
+		t.Errorf("Expected an unknown policy to be rejected with the valid names, got %v", err)
+	}
+}
+
+func TestCreateNetworkWithPolicy(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+	nm := p.networkMgr
+
+	nm.mutex.Lock()
+	setup, err := nm.prepareNetworkLocked("net2", map[string]interface{}{
+		PolicyOption:    PolicyOpen,
+		AntiSpoofOption: "true",
+	}, []IPAMData{{Pool: "172.30.0.0/24"}})
+	nm.mutex.Unlock()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	network := setup.network
+	if !network.AntiSpoof || network.Isolated || !network.ExposureConfig.AllowIPExposure {
+		t.Errorf("Expected the open policy with anti-spoofing kept on, got anti-spoof %v, isolated %v, allow IP %v",
+			network.AntiSpoof, network.Isolated, network.ExposureConfig.AllowIPExposure)
+	}
+	if setup.filterConfig.EnableAllowlist || setup.filterConfig.EnableBlocklist {
+		t.Errorf("Expected filtering disabled by the open policy, got %+v", setup.filterConfig)
+	}
+
+	nm.mutex.Lock()
+	nm.addNetworkLocked(network)
+	nm.mutex.Unlock()
+
+	var view AdminNetwork
+	if w := adminGet(t, mux, "/v1/networks/net2", &view); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if view.Policy == nil || view.Policy.Name != PolicyOpen || view.Policy.Settings[AntiSpoofOption] != "true" || view.Policy.Settings["i2p.filter.mode"] != "disabled" {
+		t.Errorf("Expected the policy in the network view, got %+v", view.Policy)
+	}
+
+	err = nm.CreateNetwork("net3", map[string]interface{}{
+		DryRunOption: "true",
+		PolicyOption: PolicyLockedDown,
+	}, []IPAMData{{Pool: "172.31.0.0/24"}})
+	if err == nil || !strings.Contains(err.Error(), `"filter_mode":"allowlist"`) || !strings.Contains(err.Error(), `"name":"locked-down"`) {
+		t.Errorf("Expected the dry run plan to show the locked-down policy, got %v", err)
+	}
+}
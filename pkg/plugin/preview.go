@@ -0,0 +1,72 @@
+// Package plugin provides the exposure address preview.
+//
+// Applications often need their own .b32.i2p address in their configuration
+// before they start, e.g. as a base URL. POST /v1/exposures/preview takes a
+// container's labels and the network it would join and returns the exposures
+// the join would create, like a dry run. Where the destination already
+// exists, because the container has a session or was given keys, or because
+// its exposure group is already served, the exact address is included.
+// Otherwise the join generates a new destination and the address is omitted.
+package plugin
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ExposurePreviewRequest describes a container join to preview exposures for.
+type ExposurePreviewRequest struct {
+	// Network is the ID or name of the network the container would join
+	Network string `json:"network"`
+	// ContainerID is the ID of the container (default "dry-run")
+	ContainerID string `json:"container_id,omitempty"`
+	// Labels are the labels of the container
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// ExposurePreview lists the exposures a container join would create.
+type ExposurePreview struct {
+	// ContainerID is the previewed container
+	ContainerID string `json:"container_id"`
+	// Exposures are the service exposures that would be created, with the
+	// addresses that are already known
+	Exposures []PlannedExposure `json:"exposures"`
+}
+
+// PreviewExposures returns the exposures joining a container with the given
+// labels to a network would create.
+func (nm *NetworkManager) PreviewExposures(req ExposurePreviewRequest) (*ExposurePreview, error) {
+	if req.Network == "" {
+		return nil, errors.New("network is required")
+	}
+
+	containerID := req.ContainerID
+	if containerID == "" {
+		containerID = dryRunID
+	}
+	plan, err := nm.DryRun(DryRunRequest{Network: req.Network, ContainerID: containerID, Labels: req.Labels})
+	if err != nil {
+		return nil, err
+	}
+	return &ExposurePreview{ContainerID: containerID, Exposures: plan.Join.Exposures}, nil
+}
+
+// handleAdminPreviewExposures previews the exposures of a container join.
+func (p *Plugin) handleAdminPreviewExposures(w http.ResponseWriter, r *http.Request) {
+	var req ExposurePreviewRequest
+	if err := p.readJSONRequest(r, &req); err != nil {
+		p.writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	preview, err := p.networkMgr.PreviewExposures(req)
+	if errors.Is(err, errDryRunNetworkNotFound) {
+		p.writeAdminError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		p.writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	p.writeJSONResponse(w, preview)
+}
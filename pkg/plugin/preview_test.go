@@ -0,0 +1,104 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// adminPreviewExposures posts an exposure preview request and decodes the
+// preview of a 200 response.
+func adminPreviewExposures(t *testing.T, mux *http.ServeMux, req ExposurePreviewRequest) (*httptest.ResponseRecorder, ExposurePreview) {
+	t.Helper()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to encode request: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/"+AdminAPIVersion+"/exposures/preview", strings.NewReader(string(data))))
+
+	var preview ExposurePreview
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &preview); err != nil {
+			t.Fatalf("Failed to decode preview: %v (body %q)", err, w.Body.String())
+		}
+	}
+	return w, preview
+}
+
+func TestAdminPreviewExposures(t *testing.T) {
+	_, mux := newAdminTestPlugin(t)
+
+	if w, _ := adminPreviewExposures(t, mux, ExposurePreviewRequest{}); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a network, got %d", w.Code)
+	}
+	if w, _ := adminPreviewExposures(t, mux, ExposurePreviewRequest{Network: "missing"}); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown network, got %d", w.Code)
+	}
+
+	w, preview := adminPreviewExposures(t, mux, ExposurePreviewRequest{
+		Network: "i2p-test",
+		Labels:  map[string]string{"i2p.expose.80": "i2p", "i2p.expose.8080": "ip:127.0.0.1"},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if preview.ContainerID != dryRunID || len(preview.Exposures) != 2 {
+		t.Fatalf("Unexpected preview %+v", preview)
+	}
+	for _, exposure := range preview.Exposures {
+		if exposure.Address != "" {
+			t.Errorf("Expected no address without a destination, got %+v", exposure)
+		}
+	}
+}
+
+func TestPreviewExposuresKnownAddress(t *testing.T) {
+	t.Parallel()
+
+	tunnelMgr := createMockTunnelManager(t)
+	if _, err := tunnelMgr.GetOrCreateContainerSession("app"); err != nil {
+		t.Fatalf("Failed to create container session: %v", err)
+	}
+
+	nm, err := NewNetworkManager(tunnelMgr)
+	if err != nil {
+		t.Fatalf("Failed to create network manager: %v", err)
+	}
+	_, subnet, _ := net.ParseCIDR("172.20.2.0/24")
+	gateway := net.ParseIP("172.20.2.1")
+	nm.addNetworkLocked(&I2PNetwork{
+		ID:            "net1",
+		Subnet:        subnet,
+		Gateway:       gateway,
+		Endpoints:     map[string]*I2PEndpoint{},
+		IPAllocator:   NewIPAllocator(subnet, gateway),
+		TunnelManager: tunnelMgr,
+	})
+
+	labels := map[string]string{"i2p.expose.80": "i2p", "i2p.expose.443": "i2p"}
+	preview, err := nm.PreviewExposures(ExposurePreviewRequest{Network: "net1", ContainerID: "app", Labels: labels})
+	if err != nil {
+		t.Fatalf("PreviewExposures() unexpected error: %v", err)
+	}
+	if len(preview.Exposures) != 2 {
+		t.Fatalf("Expected 2 exposures, got %+v", preview.Exposures)
+	}
+	address := preview.Exposures[0].Address
+	if !strings.HasSuffix(address, ".b32.i2p") || preview.Exposures[1].Address != address {
+		t.Errorf("Expected both ports at the session's address, got %+v", preview.Exposures)
+	}
+
+	preview, err = nm.PreviewExposures(ExposurePreviewRequest{Network: "net1", ContainerID: "new", Labels: labels})
+	if err != nil {
+		t.Fatalf("PreviewExposures() unexpected error: %v", err)
+	}
+	if preview.Exposures[0].Address != "" {
+		t.Errorf("Expected no address for a container without a destination, got %q", preview.Exposures[0].Address)
+	}
+}
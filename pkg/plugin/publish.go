@@ -0,0 +1,270 @@
+// Package plugin provides the ports containers publish with docker run -p.
+//
+// Docker hands a container's published ports to the driver with
+// ProgramExternalConnectivity once the container has joined, and takes them
+// back with RevokeExternalConnectivity before it leaves. Every port binding
+// becomes an exposure of the container, following the network's exposure
+// policy like ports requested by labels: on networks that allow IP exposure
+// the host address and port are forwarded to the container port, and on
+// networks that forbid it (i2p.exposure.allow_ip=false) the container port is
+// served by an I2P server tunnel instead. Revoking removes exactly the
+// exposures the bindings created.
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+// PortMapOption carries the port bindings of ProgramExternalConnectivity.
+const PortMapOption = "com.docker.network.portmap"
+
+// IP protocol numbers of port bindings.
+const (
+	protoTCP = 6
+	protoUDP = 17
+)
+
+// PortBinding is a port published with docker run -p, as Docker's
+// types.PortBinding encodes it.
+type PortBinding struct {
+	// Proto is the IP protocol number (6 for TCP, 17 for UDP)
+	Proto int `json:"Proto"`
+	// IP is the container address
+	IP bindingIP `json:"IP"`
+	// Port is the container port
+	Port int `json:"Port"`
+	// HostIP is the host address to publish on; unspecified means any
+	HostIP bindingIP `json:"HostIP"`
+	// HostPort is the host port to publish on; 0 uses the container port
+	HostPort int `json:"HostPort"`
+	// HostPortEnd is the last host port of a range. The driver publishes
+	// single ports only and rejects bindings whose range spans more than one port
+	HostPortEnd int `json:"HostPortEnd"`
+}
+
+// bindingIP is an address of a port binding. Docker encodes addresses as
+// strings, but older versions sent them as byte arrays.
+type bindingIP net.IP
+
+// UnmarshalJSON accepts an address string or an array of address bytes.
+func (b *bindingIP) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		if text == "" {
+			*b = nil
+			return nil
+		}
+		ip := net.ParseIP(text)
+		if ip == nil {
+			return fmt.Errorf("invalid address %q", text)
+		}
+		*b = bindingIP(ip)
+		return nil
+	}
+
+	var raw []byte
+	var numbers []int
+	if err := json.Unmarshal(data, &numbers); err != nil {
+		return fmt.Errorf("invalid address %s", data)
+	}
+	for _, n := range numbers {
+		if n < 0 || n > 255 {
+			return fmt.Errorf("invalid address %s", data)
+		}
+		raw = append(raw, byte(n))
+	}
+	if len(raw) != 0 && len(raw) != net.IPv4len && len(raw) != net.IPv6len {
+		return fmt.Errorf("invalid address %s", data)
+	}
+	*b = bindingIP(raw)
+	return nil
+}
+
+// parsePortBindings returns the port bindings of ProgramExternalConnectivity
+// options, or nil if there are none.
+func parsePortBindings(options map[string]interface{}) ([]PortBinding, error) {
+	raw, set := options[PortMapOption]
+	if !set || raw == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port bindings: %w", err)
+	}
+	var bindings []PortBinding
+	if err := json.Unmarshal(data, &bindings); err != nil {
+		return nil, fmt.Errorf("invalid port bindings: %w", err)
+	}
+	return bindings, nil
+}
+
+// publishedPort returns the exposure of a port binding under a network's
+// exposure policy.
+//
+// Bindings are IP exposures published on the binding's host address, or on
+// 127.0.0.1 if it is unspecified, and are downgraded to I2P exposures on
+// networks that do not allow IP exposure.
+func publishedPort(config service.NetworkExposureConfig, binding PortBinding) (service.ExposedPort, error) {
+	var protocol string
+	switch binding.Proto {
+	case protoTCP:
+		protocol = "tcp"
+	case protoUDP:
+		protocol = "udp"
+	default:
+		return service.ExposedPort{}, fmt.Errorf("unsupported protocol %d", binding.Proto)
+	}
+	if binding.Port <= 0 || binding.Port > 65535 {
+		return service.ExposedPort{}, fmt.Errorf("container port %d is out of range", binding.Port)
+	}
+
+	hostPort := binding.HostPort
+	if hostPort == 0 {
+		hostPort = binding.Port
+	}
+	if binding.HostPortEnd != 0 && binding.HostPortEnd != hostPort {
+		return service.ExposedPort{}, fmt.Errorf("host port range %d-%d is not supported, publish a single host port", hostPort, binding.HostPortEnd)
+	}
+	port := service.ExposedPort{
+		ContainerPort: binding.Port,
+		Protocol:      protocol,
+		ServiceName:   fmt.Sprintf("portmap-%d", hostPort),
+		Published:     true,
+	}
+
+	if !config.AllowIPExposure {
+		port.ExposureType = service.ExposureTypeI2P
+		return port, nil
+	}
+
+	targetIP := "127.0.0.1"
+	if hostIP := net.IP(binding.HostIP); hostIP != nil && !hostIP.IsUnspecified() {
+		targetIP = hostIP.String()
+	}
+	if err := config.ValidateTargetIP(targetIP); err != nil {
+		return service.ExposedPort{}, err
+	}
+	port.ExposureType = service.ExposureTypeIP
+	port.TargetIP = targetIP
+	port.HostPort = hostPort
+	return port, nil
+}
+
+// PublishPorts exposes the ports a joined endpoint's container publishes.
+//
+// Ports the endpoint already exposes, e.g. because Docker programs the
+// endpoint again or the exposure was restored from the state file, are left
+// as they are. If a port cannot be exposed, the ports exposed by the call are
+// removed again and the error is returned.
+func (nm *NetworkManager) PublishPorts(networkID, endpointID string, bindings []PortBinding) ([]*service.ServiceExposure, error) {
+	var published []*service.ServiceExposure
+	err := nm.updateEndpoints(networkID, func(network *I2PNetwork) error {
+		endpoint, exists := network.Endpoints[endpointID]
+		if !exists {
+			return fmt.Errorf("endpoint %s not found", endpointID)
+		}
+		if endpoint.State != EndpointJoined {
+			return fmt.Errorf("endpoint %s is not joined", endpointID)
+		}
+
+		undo := func() {
+			for _, exposure := range published {
+				nm.unpublishLocked(endpoint, exposure)
+			}
+			published = nil
+		}
+
+		for _, binding := range bindings {
+			port, err := publishedPort(network.ExposureConfig, binding)
+			if err != nil {
+				undo()
+				return fmt.Errorf("port binding %d/%d rejected: %w", binding.Port, binding.Proto, err)
+			}
+			if port.ExposureType == service.ExposureTypeI2P && endpoint.ClientOnly {
+				undo()
+				return fmt.Errorf("container %s is client-only and cannot publish port %d over I2P", endpoint.ContainerID, port.ContainerPort)
+			}
+			if hasExposure(endpoint, port) {
+				log.Printf("Port %d/%s of container %s is already exposed", port.ContainerPort, port.Protocol, endpoint.ContainerID)
+				continue
+			}
+
+			exposure, err := nm.serviceMgr.ExposeService(endpoint.ContainerID, network.ID, endpoint.IPAddress, port)
+			if err != nil {
+				undo()
+				return fmt.Errorf("failed to publish port %d/%s: %w", port.ContainerPort, port.Protocol, err)
+			}
+			endpoint.ServiceExposures = append(endpoint.ServiceExposures, exposure)
+			published = append(published, exposure)
+
+			log.Printf("Published port %d/%s of container %s as %s exposure %s",
+				port.ContainerPort, port.Protocol, endpoint.ContainerID, port.ExposureType, exposure.Destination)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return published, nil
+}
+
+// UnpublishPorts removes the exposures of the ports an endpoint's container
+// published. Exposures of other origins, such as labels or the admin API,
+// are kept. Unknown networks and endpoints have nothing to remove.
+func (nm *NetworkManager) UnpublishPorts(networkID, endpointID string) error {
+	var errs []string
+	err := nm.updateEndpoints(networkID, func(network *I2PNetwork) error {
+		endpoint, exists := network.Endpoints[endpointID]
+		if !exists {
+			return nil
+		}
+
+		var published []*service.ServiceExposure
+		for _, exposure := range endpoint.ServiceExposures {
+			if exposure.Port.Published {
+				published = append(published, exposure)
+			}
+		}
+		for _, exposure := range published {
+			if err := nm.unpublishLocked(endpoint, exposure); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		// The network is unknown or was deleted, taking its exposures along
+		return nil
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to unpublish ports of endpoint %s: %s", endpointID, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// unpublishLocked removes a published exposure from the exposure manager and
+// the endpoint. The caller must hold the network's mutex.
+func (nm *NetworkManager) unpublishLocked(endpoint *I2PEndpoint, exposure *service.ServiceExposure) error {
+	_, err := nm.serviceMgr.UnexposeService(endpoint.ContainerID, exposure.Port.ContainerPort, exposure.Port.Protocol)
+
+	for i, existing := range endpoint.ServiceExposures {
+		if existing == exposure {
+			endpoint.ServiceExposures = append(endpoint.ServiceExposures[:i:i], endpoint.ServiceExposures[i+1:]...)
+			break
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+	log.Printf("Unpublished port %d/%s of container %s", exposure.Port.ContainerPort, exposure.Port.Protocol, endpoint.ContainerID)
+	return nil
+}
@@ -0,0 +1,152 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+func TestParsePortBindings(t *testing.T) {
+	var options map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"com.docker.network.portmap": [
+		{"Proto": 6, "IP": "172.20.1.2", "Port": 80, "HostIP": "0.0.0.0", "HostPort": 8080, "HostPortEnd": 8080},
+		{"Proto": 17, "IP": "", "Port": 53, "HostIP": [127, 0, 0, 2], "HostPort": 5353}
+	]}`), &options); err != nil {
+		t.Fatal(err)
+	}
+
+	bindings, err := parsePortBindings(options)
+	if err != nil {
+		t.Fatalf("parsePortBindings() unexpected error: %v", err)
+	}
+	if len(bindings) != 2 || bindings[0].Port != 80 || bindings[0].HostPort != 8080 || !net.IP(bindings[0].IP).Equal(net.ParseIP("172.20.1.2")) {
+		t.Fatalf("Unexpected bindings %+v", bindings)
+	}
+	if !net.IP(bindings[1].HostIP).Equal(net.ParseIP("127.0.0.2")) {
+		t.Errorf("Expected a byte array host address to be decoded, got %v", net.IP(bindings[1].HostIP))
+	}
+
+	if bindings, err := parsePortBindings(map[string]interface{}{}); err != nil || bindings != nil {
+		t.Errorf("Expected no bindings without the option, got %v, %v", bindings, err)
+	}
+	if _, err := parsePortBindings(map[string]interface{}{PortMapOption: "80:80"}); err == nil {
+		t.Error("Expected malformed bindings to be rejected")
+	}
+}
+
+func TestPublishedPort(t *testing.T) {
+	allowIP := service.NetworkExposureConfig{AllowIPExposure: true}
+
+	port, err := publishedPort(allowIP, PortBinding{Proto: protoTCP, Port: 80, HostIP: bindingIP(net.IPv4zero), HostPort: 8080})
+	if err != nil {
+		t.Fatalf("publishedPort() unexpected error: %v", err)
+	}
+	if port.ExposureType != service.ExposureTypeIP || port.TargetIP != "127.0.0.1" || port.HostPort != 8080 || !port.Published {
+		t.Errorf("Expected an IP exposure on 127.0.0.1:8080, got %+v", port)
+	}
+
+	// Networks without IP exposure serve the port over I2P
+	port, err = publishedPort(service.NetworkExposureConfig{}, PortBinding{Proto: protoUDP, Port: 53})
+	if err != nil {
+		t.Fatalf("publishedPort() unexpected error: %v", err)
+	}
+	if port.ExposureType != service.ExposureTypeI2P || port.Protocol != "udp" || port.TargetIP != "" {
+		t.Errorf("Expected an I2P exposure, got %+v", port)
+	}
+
+	strict := service.NetworkExposureConfig{AllowIPExposure: true, AllowedTargets: []string{"127.0.0.1"}}
+	if _, err := publishedPort(strict, PortBinding{Proto: protoTCP, Port: 80, HostIP: bindingIP(net.ParseIP("10.0.0.1"))}); err == nil {
+		t.Error("Expected a host address outside the allowed targets to be rejected")
+	}
+	if _, err := publishedPort(allowIP, PortBinding{Proto: 132, Port: 80}); err == nil {
+		t.Error("Expected SCTP bindings to be rejected")
+	}
+
+	// Ranges like -p 8080-8090:80 are refused instead of publishing their first port
+	if _, err := publishedPort(allowIP, PortBinding{Proto: protoTCP, Port: 80, HostPort: 8080, HostPortEnd: 8090}); err == nil || !strings.Contains(err.Error(), "8080-8090") {
+		t.Errorf("Expected a host port range to be rejected, got %v", err)
+	}
+	if _, err := publishedPort(allowIP, PortBinding{Proto: protoTCP, Port: 80, HostPortEnd: 90}); err == nil {
+		t.Error("Expected a host port range starting at the container port to be rejected")
+	}
+	if _, err := publishedPort(allowIP, PortBinding{Proto: protoTCP, Port: 80, HostPortEnd: 80}); err != nil {
+		t.Errorf("Expected a range of one port to be accepted, got %v", err)
+	}
+}
+
+func TestPublishAndRevokePorts(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	network := p.networkMgr.GetNetwork("net1")
+	network.ExposureConfig = service.NetworkExposureConfig{DefaultExposureType: service.ExposureTypeI2P, AllowIPExposure: true}
+	endpoint := network.Endpoints["ep1"]
+	t.Cleanup(func() { p.networkMgr.serviceMgr.CleanupServices("container1") })
+
+	// An exposure requested by other means survives the revoke
+	apiPort := freeTCPPort(t)
+	labelled, err := p.networkMgr.serviceMgr.ExposeService("container1", "net1", endpoint.IPAddress, service.ExposedPort{
+		ContainerPort: 9090, Protocol: "tcp", ExposureType: service.ExposureTypeIP, TargetIP: "127.0.0.1", HostPort: apiPort,
+	})
+	if err != nil {
+		t.Fatalf("ExposeService() unexpected error: %v", err)
+	}
+	endpoint.ServiceExposures = append(endpoint.ServiceExposures, labelled)
+
+	webPort := freeTCPPort(t)
+	program := func() string {
+		body := fmt.Sprintf(`{"NetworkID": "net1", "EndpointID": "ep1", "Options": {"com.docker.network.portmap": [
+			{"Proto": 6, "IP": "172.20.1.2", "Port": 80, "HostIP": "", "HostPort": %d, "HostPortEnd": %d}
+		]}}`, webPort, webPort)
+		w := httptest.NewRecorder()
+		p.handleProgramExternalConnectivity(w, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		return w.Body.String()
+	}
+
+	if body := program(); !strings.Contains(body, `"Err":""`) {
+		t.Fatalf("ProgramExternalConnectivity failed: %s", body)
+	}
+	if len(endpoint.ServiceExposures) != 2 || !endpoint.ServiceExposures[1].Port.Published {
+		t.Fatalf("Expected the published port to be exposed, got %d exposures", len(endpoint.ServiceExposures))
+	}
+	if listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", webPort)); err == nil {
+		listener.Close()
+		t.Errorf("Expected host port %d to be forwarded", webPort)
+	}
+
+	// Programming the endpoint again changes nothing
+	program()
+	if len(endpoint.ServiceExposures) != 2 {
+		t.Errorf("Expected a repeated program to be ignored, got %d exposures", len(endpoint.ServiceExposures))
+	}
+
+	w := httptest.NewRecorder()
+	p.handleRevokeExternalConnectivity(w, httptest.NewRequest("POST", "/", strings.NewReader(`{"NetworkID": "net1", "EndpointID": "ep1"}`)))
+	if !strings.Contains(w.Body.String(), `"Err":""`) {
+		t.Fatalf("RevokeExternalConnectivity failed: %s", w.Body.String())
+	}
+	if len(endpoint.ServiceExposures) != 1 || endpoint.ServiceExposures[0] != labelled {
+		t.Errorf("Expected only the labelled exposure to remain, got %d exposures", len(endpoint.ServiceExposures))
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", webPort))
+	if err != nil {
+		t.Errorf("Expected host port %d to be released: %v", webPort, err)
+	} else {
+		listener.Close()
+	}
+
+	// Bindings the network refuses fail the program without side effects
+	network.ExposureConfig.AllowedTargets = []string{"127.0.0.1"}
+	if _, err := p.networkMgr.PublishPorts("net1", "ep1", []PortBinding{
+		{Proto: protoTCP, Port: 80, HostPort: webPort},
+		{Proto: protoTCP, Port: 443, HostIP: bindingIP(net.ParseIP("10.0.0.1")), HostPort: 443},
+	}); err == nil {
+		t.Error("Expected a binding outside the allowed targets to be rejected")
+	}
+	if len(endpoint.ServiceExposures) != 1 {
+		t.Errorf("Expected the rejected program to be rolled back, got %d exposures", len(endpoint.ServiceExposures))
+	}
+}
@@ -0,0 +1,220 @@
+// Package plugin provides request rate and size limits for the plugin HTTP servers.
+//
+// This file implements per-endpoint token bucket rate limiting and request body
+// size limits, so a misbehaving client hammering CreateNetwork or flooding the
+// admin API cannot starve other callers. On the local plugin socket every
+// endpoint has a bucket per peer UID (SO_PEERCRED), so another local user
+// flooding the socket does not use up Docker's budget and fail its container
+// starts. Rejected requests are counted in the
+// i2p_plugin_http_requests_rejected_total metric.
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/metrics"
+)
+
+// Servers distinguished in rejection metrics.
+const (
+	serverPlugin = "plugin"
+	serverAdmin  = "admin"
+	serverGRPC   = "grpc"
+)
+
+// Rejection reasons used as metric labels.
+const (
+	rejectRateLimited = "rate_limited"
+	rejectTooLarge    = "too_large"
+)
+
+// RateLimitConfig defines request limits for the plugin HTTP servers.
+type RateLimitConfig struct {
+	// RequestsPerSecond is the sustained request rate allowed per endpoint (0 disables rate limiting)
+	RequestsPerSecond float64
+	// Burst is the number of requests allowed above the sustained rate
+	Burst int
+	// MaxRequestBytes is the maximum request body size (0 disables the limit)
+	MaxRequestBytes int64
+}
+
+// DefaultRateLimitConfig returns the default request limits.
+//
+// On the plugin socket the rate limit applies to each local user separately.
+func DefaultRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		RequestsPerSecond: 50,
+		Burst:             100,
+		MaxRequestBytes:   1 << 20, // 1 MiB
+	}
+}
+
+// Validate checks the request limits for consistency.
+func (c RateLimitConfig) Validate() error {
+	if c.RequestsPerSecond < 0 {
+		return fmt.Errorf("requests per second cannot be negative")
+	}
+	if c.RequestsPerSecond > 0 && c.Burst < 1 {
+		return fmt.Errorf("burst must be at least 1 when rate limiting is enabled")
+	}
+	if c.MaxRequestBytes < 0 {
+		return fmt.Errorf("max request bytes cannot be negative")
+	}
+	return nil
+}
+
+// SetRateLimits configures request limits for the plugin and admin servers.
+//
+// It must be called before Start.
+func (p *Plugin) SetRateLimits(config RateLimitConfig) error {
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid rate limit configuration: %w", err)
+	}
+	p.rateLimits = config
+	return nil
+}
+
+// tokenBucket is a simple token bucket rate limiter.
+type tokenBucket struct {
+	mutex    sync.Mutex
+	rate     float64
+	burst    float64
+	tokens   float64
+	lastFill time.Time
+	now      func() time.Time
+}
+
+// newTokenBucket creates a full token bucket.
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:     rate,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		lastFill: time.Now(),
+		now:      time.Now,
+	}
+}
+
+// allow takes a token from the bucket, returning false if none are available.
+func (b *tokenBucket) allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.now()
+	b.tokens += now.Sub(b.lastFill).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.lastFill = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// peerUIDKey is the context key of the UID of the process a plugin socket
+// request came from.
+type peerUIDKey struct{}
+
+// peerConnContext records the UID of the process at the other end of a plugin
+// socket connection in the context of its requests. Connections without peer
+// credentials, such as those of the TLS listener, get no UID.
+func peerConnContext(ctx context.Context, conn net.Conn) context.Context {
+	creds, err := peerCredentialsOf(conn)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, peerUIDKey{}, creds.UID)
+}
+
+// peerBuckets holds the token buckets of one endpoint by peer UID.
+//
+// Only processes that can open the plugin socket have a UID here, which keeps
+// the number of buckets bounded by the socket's permissions.
+type peerBuckets struct {
+	mutex   sync.Mutex
+	limits  RateLimitConfig
+	buckets map[uint32]*tokenBucket
+}
+
+// get returns the bucket of a UID, creating a full one on first use.
+func (b *peerBuckets) get(uid uint32) *tokenBucket {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	bucket, exists := b.buckets[uid]
+	if !exists {
+		bucket = newTokenBucket(b.limits.RequestsPerSecond, b.limits.Burst)
+		b.buckets[uid] = bucket
+	}
+	return bucket
+}
+
+// limitRequests wraps a handler with the configured rate and size limits.
+//
+// Each registered endpoint gets its own bucket, so flooding one endpoint does
+// not block calls to the others. Requests on the plugin socket are limited
+// per peer UID instead, so Docker keeps its own budget whatever other local
+// users send; requests whose peer has no known UID share the endpoint's bucket.
+func (p *Plugin) limitRequests(server, endpoint string, handler http.HandlerFunc) http.HandlerFunc {
+	limits := p.rateLimits
+
+	var bucket *tokenBucket
+	var peers *peerBuckets
+	if limits.RequestsPerSecond > 0 {
+		bucket = newTokenBucket(limits.RequestsPerSecond, limits.Burst)
+		peers = &peerBuckets{limits: limits, buckets: make(map[uint32]*tokenBucket)}
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		limiter := bucket
+		if uid, ok := r.Context().Value(peerUIDKey{}).(uint32); ok && limiter != nil {
+			limiter = peers.get(uid)
+		}
+		if limiter != nil && !limiter.allow() {
+			p.rejectRequest(w, server, endpoint, rejectRateLimited, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+
+		if limits.MaxRequestBytes > 0 {
+			if r.ContentLength > limits.MaxRequestBytes {
+				p.rejectRequest(w, server, endpoint, rejectTooLarge, http.StatusRequestEntityTooLarge,
+					fmt.Sprintf("request body exceeds %d bytes", limits.MaxRequestBytes))
+				return
+			}
+			// Bodies without a declared length are cut off while reading
+			r.Body = http.MaxBytesReader(w, r.Body, limits.MaxRequestBytes)
+		}
+
+		handler(w, r)
+	}
+}
+
+// rejectRequest counts a rejected request and writes an error in the server's format.
+func (p *Plugin) rejectRequest(w http.ResponseWriter, server, endpoint, reason string, status int, message string) {
+	p.rejectedRequests().Inc(server, endpoint, reason)
+
+	if server == serverAdmin {
+		p.writeAdminError(w, status, message)
+		return
+	}
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(status)
+	p.writeJSONResponse(w, ErrorResponse{Err: message})
+}
+
+// rejectedRequests returns the counter of requests rejected by limits.
+func (p *Plugin) rejectedRequests() *metrics.CounterVec {
+	return p.metrics.NewCounterVec("i2p_plugin_http_requests_rejected_total",
+		"HTTP requests rejected by rate or size limits.", "server", "endpoint", "reason")
+}
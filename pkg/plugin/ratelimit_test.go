@@ -0,0 +1,274 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/metrics"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(1000, 0)
+	bucket := newTokenBucket(2, 3)
+	bucket.now = func() time.Time { return now }
+	bucket.lastFill = now
+
+	for i := 0; i < 3; i++ {
+		if !bucket.allow() {
+			t.Fatalf("Expected request %d within burst to be allowed", i+1)
+		}
+	}
+	if bucket.allow() {
+		t.Error("Expected request beyond burst to be rejected")
+	}
+
+	// Two tokens per second refill one token every 500ms
+	now = now.Add(500 * time.Millisecond)
+	if !bucket.allow() {
+		t.Error("Expected refilled token to be allowed")
+	}
+	if bucket.allow() {
+		t.Error("Expected bucket to be empty again")
+	}
+
+	// Refill never exceeds the burst size
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if !bucket.allow() {
+			t.Fatalf("Expected request %d after refill to be allowed", i+1)
+		}
+	}
+	if bucket.allow() {
+		t.Error("Expected refill to be capped at burst size")
+	}
+}
+
+func TestRateLimitConfigValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    RateLimitConfig
+		expectErr bool
+	}{
+		{"default", DefaultRateLimitConfig(), false},
+		{"disabled", RateLimitConfig{}, false},
+		{"negative rate", RateLimitConfig{RequestsPerSecond: -1, Burst: 1}, true},
+		{"zero burst", RateLimitConfig{RequestsPerSecond: 10}, true},
+		{"negative size", RateLimitConfig{MaxRequestBytes: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.expectErr && err == nil {
+				t.Error("Expected validation error")
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("Unexpected validation error: %v", err)
+			}
+		})
+	}
+}
+
+func newRateLimitTestPlugin(t *testing.T, config RateLimitConfig) *Plugin {
+	t.Helper()
+
+	p := &Plugin{metrics: metrics.NewRegistry()}
+	if err := p.SetRateLimits(config); err != nil {
+		t.Fatalf("Failed to set rate limits: %v", err)
+	}
+	return p
+}
+
+func TestLimitRequestsRateLimit(t *testing.T) {
+	p := newRateLimitTestPlugin(t, RateLimitConfig{RequestsPerSecond: 0.001, Burst: 2})
+
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, r *http.Request) { p.writeJSONResponse(w, ErrorResponse{}) }
+	mux.HandleFunc("/NetworkDriver.CreateNetwork", p.limitRequests(serverPlugin, "/NetworkDriver.CreateNetwork", ok))
+	mux.HandleFunc("/NetworkDriver.Join", p.limitRequests(serverPlugin, "/NetworkDriver.Join", ok))
+
+	// Requests on the TLS listener are rate limited
+	request := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader("{}"))
+		req.TLS = &tls.ConnectionState{}
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		if rec := request("/NetworkDriver.CreateNetwork"); rec.Code != http.StatusOK {
+			t.Fatalf("Expected request %d to succeed, got %d", i+1, rec.Code)
+		}
+	}
+
+	rec := request("/NetworkDriver.CreateNetwork")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", rec.Code)
+	}
+	var resp ErrorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.Err == "" {
+		t.Errorf("Expected plugin error response, got %q (%v)", rec.Body.String(), err)
+	}
+
+	// Other endpoints have their own bucket
+	if rec := request("/NetworkDriver.Join"); rec.Code != http.StatusOK {
+		t.Errorf("Expected Join to be unaffected, got %d", rec.Code)
+	}
+
+	if got := p.rejectedRequests().Value(serverPlugin, "/NetworkDriver.CreateNetwork", rejectRateLimited); got != 1 {
+		t.Errorf("Expected 1 rejected request, got %v", got)
+	}
+}
+
+func TestLimitRequestsPerPeerUID(t *testing.T) {
+	p := newRateLimitTestPlugin(t, RateLimitConfig{RequestsPerSecond: 0.001, Burst: 2, MaxRequestBytes: 16})
+	handler := p.limitRequests(serverPlugin, "/NetworkDriver.Join", func(w http.ResponseWriter, r *http.Request) {
+		p.writeJSONResponse(w, ErrorResponse{})
+	})
+
+	// request sends a socket request from the given UID, or without one
+	request := func(uid *uint32, body string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/NetworkDriver.Join", strings.NewReader(body))
+		if uid != nil {
+			req = req.WithContext(context.WithValue(req.Context(), peerUIDKey{}, *uid))
+		}
+		handler(rec, req)
+		return rec.Code
+	}
+	docker, other := uint32(0), uint32(1000)
+
+	// Another local user flooding the socket runs out of its own budget
+	for i := 0; i < 2; i++ {
+		if code := request(&other, "{}"); code != http.StatusOK {
+			t.Fatalf("Expected request %d of uid 1000 to succeed, got %d", i+1, code)
+		}
+	}
+	if code := request(&other, "{}"); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected uid 1000 to be rate limited, got %d", code)
+	}
+
+	// Docker's budget is untouched, and limited in turn
+	for i := 0; i < 2; i++ {
+		if code := request(&docker, "{}"); code != http.StatusOK {
+			t.Fatalf("Expected request %d of uid 0 to succeed, got %d", i+1, code)
+		}
+	}
+	if code := request(&docker, "{}"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected uid 0 to be rate limited, got %d", code)
+	}
+
+	// Peers without credentials share the endpoint's bucket
+	if code := request(nil, "{}"); code != http.StatusOK {
+		t.Errorf("Expected a request without peer UID to succeed, got %d", code)
+	}
+
+	if code := request(nil, strings.Repeat("x", 17)); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected the size limit to still apply on the socket, got %d", code)
+	}
+	if got := p.rejectedRequests().Value(serverPlugin, "/NetworkDriver.Join", rejectRateLimited); got != 2 {
+		t.Errorf("Expected 2 rate limited requests, got %v", got)
+	}
+}
+
+func TestPeerConnContext(t *testing.T) {
+	if !peerCredentialsSupported {
+		t.Skip("peer credentials are not supported on this platform")
+	}
+
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "plugin.sock"))
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	client, err := net.Dial("unix", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+	server, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Failed to accept: %v", err)
+	}
+	defer server.Close()
+
+	uid, ok := peerConnContext(context.Background(), server).Value(peerUIDKey{}).(uint32)
+	if !ok || uid != uint32(os.Getuid()) {
+		t.Errorf("Expected the peer uid %d in the context, got %v (%v)", os.Getuid(), uid, ok)
+	}
+
+	// Other connections, like those of the TLS listener, have no peer UID
+	pipe, peer := net.Pipe()
+	defer pipe.Close()
+	defer peer.Close()
+	if _, ok := peerConnContext(context.Background(), pipe).Value(peerUIDKey{}).(uint32); ok {
+		t.Error("Expected no peer uid for a connection without credentials")
+	}
+}
+
+func TestLimitRequestsBodySize(t *testing.T) {
+	p := newRateLimitTestPlugin(t, RateLimitConfig{MaxRequestBytes: 16})
+
+	var readErr error
+	handler := p.limitRequests(serverPlugin, "/NetworkDriver.CreateNetwork", func(w http.ResponseWriter, r *http.Request) {
+		var req CreateNetworkRequest
+		readErr = p.readJSONRequest(r, &req)
+		p.writeJSONResponse(w, ErrorResponse{})
+	})
+
+	body := bytes.Repeat([]byte("x"), 64)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/NetworkDriver.CreateNetwork", bytes.NewReader(body)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", rec.Code)
+	}
+	if got := p.rejectedRequests().Value(serverPlugin, "/NetworkDriver.CreateNetwork", rejectTooLarge); got != 1 {
+		t.Errorf("Expected 1 rejected request, got %v", got)
+	}
+
+	// Bodies without a declared length are cut off while reading
+	req := httptest.NewRequest(http.MethodPost, "/NetworkDriver.CreateNetwork", bytes.NewReader(body))
+	req.ContentLength = -1
+	handler(httptest.NewRecorder(), req)
+	if readErr == nil {
+		t.Error("Expected oversized body without content length to fail reading")
+	}
+}
+
+func TestAdminRateLimitAndMetrics(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	p.SetRateLimits(RateLimitConfig{RequestsPerSecond: 0.001, Burst: 1})
+	mux := http.NewServeMux()
+	p.setupAdminHandlers(mux)
+
+	for i, expected := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/networks", nil))
+		if rec.Code != expected {
+			t.Fatalf("Request %d: expected status %d, got %d", i+1, expected, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected metrics status 200, got %d", rec.Code)
+	}
+	expected := `i2p_plugin_http_requests_rejected_total{server="admin",endpoint="/v1/networks",reason="rate_limited"} 1`
+	if !strings.Contains(rec.Body.String(), expected) {
+		t.Errorf("Expected metrics to contain %q, got:\n%s", expected, rec.Body.String())
+	}
+}
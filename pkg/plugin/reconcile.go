@@ -0,0 +1,541 @@
+// Package plugin provides the reconciliation of plugin state with Docker.
+//
+// Restored state describes the world as it was when the plugin went down.
+// Networks removed with docker network rm or containers disconnected while
+// the plugin was not running are never reported to it, and their sessions
+// and addresses would stay allocated forever. After restoring, the plugin
+// therefore asks the Docker Engine API which restored networks still exist
+// and which endpoints are attached to them: restored networks Docker no
+// longer knows are deleted, and restored endpoints Docker does not list are
+// removed with their sessions and addresses. Parked endpoints are kept, as
+// Docker may still call DeleteEndpoint for them.
+//
+// The opposite happens when the state file is lost or was never written:
+// Docker does not repeat CreateNetwork or Join for networks and containers it
+// already set up, so they would stay without tunnels until recreated by hand.
+// Networks of the plugin's driver the plugin does not know are therefore
+// adopted from Docker's view, with the endpoints Docker lists and joins of
+// their running containers, recreating their sessions and exposures. Finally,
+// container sessions whose container is neither joined nor running in
+// Docker are closed.
+//
+// dockerd may still be starting when the plugin comes up, so the Engine API
+// is polled until it answers or reconcileTimeout has passed. Networks and
+// endpoints Docker created or joined again since the restore are left alone.
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+const (
+	// reconcileTimeout is how long the Docker Engine API is waited for
+	reconcileTimeout = 2 * time.Minute
+	// reconcileRetry is the delay between attempts to reach the Engine API
+	reconcileRetry = 5 * time.Second
+	// dockerRequestTimeout bounds a single Engine API request
+	dockerRequestTimeout = 10 * time.Second
+)
+
+// dockerClient queries the Docker Engine API over its Unix socket.
+type dockerClient struct {
+	http *http.Client
+}
+
+// newDockerClient creates a client for the Engine API socket at socketPath.
+func newDockerClient(socketPath string) *dockerClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return &dockerClient{http: &http.Client{Transport: transport, Timeout: dockerRequestTimeout}}
+}
+
+// dockerNetwork is the part of a network inspect response the plugin reads.
+type dockerNetwork struct {
+	ID       string            `json:"Id"`
+	Name     string            `json:"Name"`
+	Internal bool              `json:"Internal"`
+	Options  map[string]string `json:"Options"`
+	IPAM     struct {
+		Config []struct {
+			Subnet  string `json:"Subnet"`
+			Gateway string `json:"Gateway"`
+		} `json:"Config"`
+	} `json:"IPAM"`
+	Containers map[string]dockerNetworkContainer `json:"Containers"`
+}
+
+// dockerNetworkContainer is a container attached to a network, keyed by
+// container ID in a network inspect response.
+type dockerNetworkContainer struct {
+	EndpointID  string `json:"EndpointID"`
+	IPv4Address string `json:"IPv4Address"`
+	IPv6Address string `json:"IPv6Address"`
+}
+
+// dockerContainer is the part of a container inspect response the plugin
+// reads.
+type dockerContainer struct {
+	ID     string `json:"Id"`
+	Config struct {
+		Labels       map[string]interface{} `json:"Labels"`
+		ExposedPorts map[string]interface{} `json:"ExposedPorts"`
+		Env          []interface{}          `json:"Env"`
+	} `json:"Config"`
+	State struct {
+		Running bool `json:"Running"`
+	} `json:"State"`
+	NetworkSettings struct {
+		SandboxKey string `json:"SandboxKey"`
+		Networks   map[string]struct {
+			EndpointID string   `json:"EndpointID"`
+			Aliases    []string `json:"Aliases"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// getJSON decodes the response to an Engine API GET request into v. found is
+// false if Docker answers 404.
+func (c *dockerClient) getJSON(ctx context.Context, path, what string, v interface{}) (found bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return false, fmt.Errorf("inspecting %s: %s: %s", what, resp.Status, body)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, fmt.Errorf("decoding %s: %w", what, err)
+	}
+	return true, nil
+}
+
+// networkEndpoints returns the IDs of the endpoints Docker has attached to a
+// network. found is false if Docker does not know the network.
+func (c *dockerClient) networkEndpoints(ctx context.Context, networkID string) (endpoints map[string]bool, found bool, err error) {
+	network, found, err := c.network(ctx, networkID)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	endpoints = make(map[string]bool, len(network.Containers))
+	for _, container := range network.Containers {
+		endpoints[container.EndpointID] = true
+	}
+	return endpoints, true, nil
+}
+
+// network inspects a network. found is false if Docker does not know it.
+func (c *dockerClient) network(ctx context.Context, networkID string) (*dockerNetwork, bool, error) {
+	var network dockerNetwork
+	found, err := c.getJSON(ctx, "/networks/"+url.PathEscape(networkID), "network "+networkID, &network)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	return &network, true, nil
+}
+
+// container inspects a container. found is false if Docker does not know it.
+func (c *dockerClient) container(ctx context.Context, containerID string) (*dockerContainer, bool, error) {
+	var container dockerContainer
+	found, err := c.getJSON(ctx, "/containers/"+url.PathEscape(containerID)+"/json", "container "+containerID, &container)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	return &container, true, nil
+}
+
+// dockerState is Docker's view of the networks of the plugin's driver.
+type dockerState struct {
+	// networks are the inspected networks of the driver
+	networks []*dockerNetwork
+
+	// containers maps the IDs of the containers attached to those networks
+	// to their inspect responses
+	containers map[string]*dockerContainer
+}
+
+// driverState inspects the networks of a driver and the containers attached
+// to them. Containers removed between the two queries are left out.
+func (c *dockerClient) driverState(ctx context.Context, driver string) (*dockerState, error) {
+	filters, err := json.Marshal(map[string][]string{"driver": {driver}})
+	if err != nil {
+		return nil, err
+	}
+	var listed []dockerNetwork
+	if _, err := c.getJSON(ctx, "/networks?filters="+url.QueryEscape(string(filters)), "networks", &listed); err != nil {
+		return nil, err
+	}
+
+	state := &dockerState{containers: make(map[string]*dockerContainer)}
+	for _, entry := range listed {
+		// Listing does not report attached containers
+		network, found, err := c.network(ctx, entry.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+		state.networks = append(state.networks, network)
+
+		for containerID := range network.Containers {
+			if _, seen := state.containers[containerID]; seen {
+				continue
+			}
+			container, found, err := c.container(ctx, containerID)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				state.containers[containerID] = container
+			}
+		}
+	}
+	return state, nil
+}
+
+// sandboxID returns the ID a joined container is tracked under, derived from
+// its sandbox key the way handleJoin derives it.
+func (c *dockerContainer) sandboxID() string {
+	if id := extractContainerID(c.NetworkSettings.SandboxKey); id != "" {
+		return id
+	}
+	return c.NetworkSettings.SandboxKey
+}
+
+// joinOptions returns the Join options Docker passes for the container.
+func (c *dockerContainer) joinOptions() map[string]interface{} {
+	options := make(map[string]interface{})
+	if c.Config.Labels != nil {
+		options["Labels"] = c.Config.Labels
+	}
+	if c.Config.ExposedPorts != nil {
+		options["ExposedPorts"] = c.Config.ExposedPorts
+	}
+	if c.Config.Env != nil {
+		options["Env"] = c.Config.Env
+	}
+	return options
+}
+
+// createOptions returns the CreateNetwork options and IPAM data Docker
+// passed when it created the network.
+func (n *dockerNetwork) createOptions() (map[string]interface{}, []IPAMData) {
+	options := make(map[string]interface{}, len(n.Options)+2)
+	for key, value := range n.Options {
+		options[key] = value
+	}
+	if n.Internal {
+		options[InternalOption] = true
+	}
+	options["com.docker.network.generic"] = map[string]interface{}{"name": n.Name}
+
+	ipamData := make([]IPAMData, 0, len(n.IPAM.Config))
+	for _, config := range n.IPAM.Config {
+		if config.Subnet == "" {
+			continue
+		}
+		ipamData = append(ipamData, IPAMData{Pool: config.Subnet, Gateway: config.Gateway})
+	}
+	return options, ipamData
+}
+
+// dockerDriverName returns the driver name Docker registers a plugin
+// listening on sockPath under: the socket file name without its extension.
+func dockerDriverName(sockPath string) string {
+	return strings.TrimSuffix(filepath.Base(sockPath), filepath.Ext(sockPath))
+}
+
+// SetDockerSocket sets the Docker Engine API socket the plugin state is
+// reconciled against on start and on-demand containers are started and
+// stopped through. An empty path keeps the state as it is and leaves
+// on-demand containers to be started by hand.
+//
+// Must be called before Start.
+func (p *Plugin) SetDockerSocket(path string) {
+	p.dockerSocket = path
+	if path == "" {
+		p.networkMgr.serviceMgr.SetContainerRunner(nil)
+		return
+	}
+	p.networkMgr.serviceMgr.SetContainerRunner(newContainerRunner(path))
+}
+
+// reconcileNetworks reconciles the plugin state with Docker once the Engine
+// API answers, giving up after reconcileTimeout.
+func (p *Plugin) reconcileNetworks(ctx context.Context) {
+	if p.dockerSocket == "" {
+		return
+	}
+	if _, err := os.Stat(p.dockerSocket); errors.Is(err, os.ErrNotExist) {
+		log.Printf("Warning: Docker socket %s is not available, networks are not reconciled with Docker", p.dockerSocket)
+		return
+	}
+
+	client := newDockerClient(p.dockerSocket)
+	ctx, cancel := context.WithTimeout(ctx, reconcileTimeout)
+	defer cancel()
+
+	for {
+		driver, err := p.driverName(ctx, client)
+		if err == nil {
+			err = p.networkMgr.syncWithDocker(ctx, client, driver)
+		}
+		if err == nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Warning: Networks were not reconciled with Docker: %v", err)
+			return
+		case <-time.After(reconcileRetry):
+		}
+	}
+}
+
+// syncWithDocker reconciles restored networks with Docker, adopts the
+// networks of driver the plugin does not know and closes stale container
+// sessions, logging what changed.
+func (nm *NetworkManager) syncWithDocker(ctx context.Context, client *dockerClient, driver string) error {
+	if nm.hasRestored() {
+		networks, endpoints, err := nm.reconcileWithDocker(ctx, client)
+		if err != nil {
+			return err
+		}
+		log.Printf("Reconciled restored networks with Docker: removed %d networks and %d endpoints", networks, endpoints)
+	}
+
+	state, err := client.driverState(ctx, driver)
+	if err != nil {
+		return err
+	}
+	networks, joined := nm.adoptDockerNetworks(state)
+	sessions := nm.collectStaleSessions(state)
+	log.Printf("Reconciled %s networks with Docker: adopted %d networks and %d containers, closed %d stale sessions",
+		driver, networks, joined, sessions)
+	return nil
+}
+
+// adoptDockerNetworks creates the networks Docker reports that the plugin
+// does not know, with their endpoints, and joins their running containers,
+// returning how many networks and containers were adopted.
+//
+// Adopted networks and endpoints are marked restored, so Docker repeating
+// CreateNetwork, CreateEndpoint or Join for them is accepted.
+func (nm *NetworkManager) adoptDockerNetworks(state *dockerState) (networks, joined int) {
+	for _, dockerNet := range state.networks {
+		if nm.GetNetwork(dockerNet.ID) != nil {
+			continue
+		}
+
+		options, ipamData := dockerNet.createOptions()
+		log.Printf("Network %s (%s) is missing from the plugin state, adopting it from Docker", dockerNet.ID, dockerNet.Name)
+		if err := nm.CreateNetwork(dockerNet.ID, options, ipamData); err != nil {
+			log.Printf("Warning: Failed to adopt network %s: %v", dockerNet.ID, err)
+			continue
+		}
+		networks++
+
+		for containerID, attached := range dockerNet.Containers {
+			if nm.adoptDockerEndpoint(dockerNet.ID, containerID, attached, state.containers[containerID]) {
+				joined++
+			}
+		}
+		nm.markRestored(dockerNet.ID)
+	}
+	return networks, joined
+}
+
+// adoptDockerEndpoint creates the endpoint of a container attached to an
+// adopted network and joins the container if it is running, reporting
+// whether it was joined.
+func (nm *NetworkManager) adoptDockerEndpoint(networkID, containerID string, attached dockerNetworkContainer, container *dockerContainer) bool {
+	address, addressIPv6 := parseAddress(attached.IPv4Address), parseAddress(attached.IPv6Address)
+	if _, err := nm.CreateEndpointWithAddresses(networkID, attached.EndpointID, address, addressIPv6, nil); err != nil {
+		log.Printf("Warning: Failed to adopt endpoint %s of container %s: %v", attached.EndpointID, i2p.DisplayName(containerID), err)
+		return false
+	}
+	if container == nil || !container.State.Running {
+		return false
+	}
+
+	options := container.joinOptions()
+	for _, settings := range container.NetworkSettings.Networks {
+		if settings.EndpointID == attached.EndpointID && len(settings.Aliases) > 0 {
+			options[EndpointAliasesOption] = settings.Aliases
+		}
+	}
+	if _, err := nm.JoinEndpoint(networkID, attached.EndpointID, container.sandboxID(), container.NetworkSettings.SandboxKey, options); err != nil {
+		log.Printf("Warning: Failed to join container %s to adopted network %s: %v", i2p.DisplayName(containerID), networkID, err)
+		return false
+	}
+	return true
+}
+
+// markRestored marks a network and its endpoints as restored.
+func (nm *NetworkManager) markRestored(networkID string) {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	network, exists := nm.networks[networkID]
+	if !exists {
+		return
+	}
+	network.Restored = true
+	network.mutex.Lock()
+	for _, endpoint := range network.Endpoints {
+		endpoint.Restored = true
+	}
+	network.mutex.Unlock()
+}
+
+// collectStaleSessions closes the container sessions of containers that are
+// neither joined to a network nor running in Docker, returning how many were
+// closed. Group, smoke test and mesh control sessions are not tied to a
+// container and are kept.
+func (nm *NetworkManager) collectStaleSessions(state *dockerState) int {
+	running := make(map[string]bool, len(state.containers))
+	for _, container := range state.containers {
+		if container.State.Running {
+			running[container.sandboxID()] = true
+		}
+	}
+
+	closed := 0
+	for _, sessionID := range nm.tunnelMgr.ListContainerSessions() {
+		if service.IsGroupSessionID(sessionID) || sessionID == smokeTestServerOwner || sessionID == smokeTestClientOwner || sessionID == meshOwner {
+			continue
+		}
+		if nm.joined.contains(sessionID) || running[sessionID] {
+			continue
+		}
+		log.Printf("Container %s no longer exists, closing its stale session", i2p.DisplayName(sessionID))
+		if err := nm.tunnelMgr.DestroyContainerSession(sessionID); err != nil {
+			log.Printf("Warning: Failed to close session of container %s: %v", i2p.DisplayName(sessionID), err)
+			continue
+		}
+		closed++
+	}
+	return closed
+}
+
+// hasRestored reports whether any restored network or endpoint is waiting to
+// be reconciled.
+func (nm *NetworkManager) hasRestored() bool {
+	return len(nm.restoredNetworks()) > 0
+}
+
+// restoredNetworks returns the IDs of the networks that were restored or hold
+// restored endpoints.
+func (nm *NetworkManager) restoredNetworks() []string {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	var ids []string
+	for id, network := range nm.networks {
+		restored := network.Restored
+		network.mutex.RLock()
+		for _, endpoint := range network.Endpoints {
+			restored = restored || endpoint.Restored
+		}
+		network.mutex.RUnlock()
+		if restored {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// reconcileWithDocker deletes the restored networks Docker no longer knows
+// and the restored endpoints it does not list as attached, returning how many
+// of each were removed. Docker is queried for every network before anything
+// is deleted, so a failing query changes nothing.
+func (nm *NetworkManager) reconcileWithDocker(ctx context.Context, client *dockerClient) (networks, endpoints int, err error) {
+	type dockerView struct {
+		endpoints map[string]bool
+		found     bool
+	}
+
+	views := make(map[string]dockerView)
+	for _, id := range nm.restoredNetworks() {
+		attached, found, err := client.networkEndpoints(ctx, id)
+		if err != nil {
+			return 0, 0, err
+		}
+		views[id] = dockerView{endpoints: attached, found: found}
+	}
+
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	for id, view := range views {
+		network, exists := nm.networks[id]
+		if !exists {
+			continue
+		}
+
+		// Docker repeated CreateNetwork since the restore if Restored is clear
+		if !view.found {
+			if !network.Restored {
+				continue
+			}
+			log.Printf("Network %s was removed from Docker while the plugin was down, deleting it", id)
+			if err := nm.deleteNetworkLocked(id); err != nil {
+				log.Printf("Warning: Failed to delete network %s: %v", id, err)
+				continue
+			}
+			networks++
+			continue
+		}
+
+		network.mutex.Lock()
+		for endpointID, endpoint := range network.Endpoints {
+			// Parked endpoints hold nothing and wait for Docker's DeleteEndpoint
+			if !endpoint.Restored || endpoint.State == EndpointParked || view.endpoints[endpointID] {
+				continue
+			}
+			log.Printf("Endpoint %s of container %s is no longer attached to network %s in Docker, removing it",
+				endpointID, endpoint.ContainerID, id)
+			if err := nm.deleteEndpointInternal(network, endpointID); err != nil {
+				log.Printf("Warning: Failed to remove endpoint %s: %v", endpointID, err)
+				continue
+			}
+			endpoints++
+		}
+		network.mutex.Unlock()
+	}
+
+	nm.saveStateLocked()
+	return networks, endpoints, nil
+}
@@ -0,0 +1,205 @@
+package plugin
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// startDockerTestServer serves a fake Engine API on a Unix socket.
+func startDockerTestServer(t *testing.T, handler http.HandlerFunc) *dockerClient {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to create socket: %v", err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return newDockerClient(path)
+}
+
+func TestReconcileWithDocker(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	addTenantNetwork(p)
+	nm := p.networkMgr
+
+	network := nm.GetNetwork("net1")
+	network.Restored = true
+	network.Endpoints["ep1"].Restored = true
+	network.Endpoints["ep3"] = &I2PEndpoint{ID: "ep3", NetworkID: "net1", State: EndpointCreated, IPAddress: net.ParseIP("172.20.1.3"), Restored: true}
+	network.Endpoints["ep4"] = &I2PEndpoint{ID: "ep4", NetworkID: "net1", State: EndpointParked, Restored: true}
+	network.Endpoints["ep5"] = &I2PEndpoint{ID: "ep5", NetworkID: "net1", State: EndpointCreated, IPAddress: net.ParseIP("172.20.1.5")}
+	nm.GetNetwork("net2").Restored = true
+
+	client := startDockerTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/networks/net1":
+			w.Write([]byte(`{"Id":"net1","Containers":{"container1":{"EndpointID":"ep1"}}}`))
+		default:
+			http.Error(w, `{"message":"network not found"}`, http.StatusNotFound)
+		}
+	})
+
+	networks, endpoints, err := nm.reconcileWithDocker(context.Background(), client)
+	if err != nil {
+		t.Fatalf("reconcileWithDocker() unexpected error: %v", err)
+	}
+	if networks != 1 || endpoints != 1 {
+		t.Errorf("Expected 1 network and 1 endpoint to be removed, got %d and %d", networks, endpoints)
+	}
+	if nm.GetNetwork("net2") != nil {
+		t.Error("Expected the network unknown to Docker to be deleted")
+	}
+	for _, id := range []string{"ep1", "ep4", "ep5"} {
+		if network.Endpoints[id] == nil {
+			t.Errorf("Expected endpoint %s to be kept", id)
+		}
+	}
+	if network.Endpoints["ep3"] != nil {
+		t.Error("Expected the restored endpoint Docker does not list to be removed")
+	}
+}
+
+func TestReconcileWithDockerError(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	nm := p.networkMgr
+	nm.GetNetwork("net1").Restored = true
+
+	client := startDockerTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "starting", http.StatusServiceUnavailable)
+	})
+
+	if _, _, err := nm.reconcileWithDocker(context.Background(), client); err == nil {
+		t.Fatal("Expected an error while Docker is unavailable")
+	}
+	if nm.GetNetwork("net1") == nil {
+		t.Error("Expected networks to be kept while Docker is unavailable")
+	}
+}
+
+func TestReconcileNetworksWithoutSocket(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	p.networkMgr.GetNetwork("net1").Restored = true
+	p.SetDockerSocket(filepath.Join(t.TempDir(), "docker.sock"))
+
+	// Returns right away instead of waiting for a socket that is not mounted
+	p.reconcileNetworks(context.Background())
+	if p.networkMgr.GetNetwork("net1") == nil {
+		t.Error("Expected networks to be kept without a Docker socket")
+	}
+}
+
+// startDriverTestServer serves a fake Engine API with net1 of
+// newAdminTestPlugin and net9, which the plugin does not know.
+func startDriverTestServer(t *testing.T, filters *string) *dockerClient {
+	t.Helper()
+
+	return startDockerTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/networks":
+			*filters = r.URL.Query().Get("filters")
+			w.Write([]byte(`[{"Id":"net1"},{"Id":"net9"}]`))
+		case "/networks/net1":
+			w.Write([]byte(`{"Id":"net1","Name":"i2p-test","Containers":{"container1":{"EndpointID":"ep1"}}}`))
+		case "/networks/net9":
+			w.Write([]byte(`{"Id":"net9","Name":"lost-net","Options":{"i2p.exposure.default":"ip"},
+				"IPAM":{"Config":[{"Subnet":"10.60.0.0/24","Gateway":"10.60.0.1"}]},
+				"Containers":{
+					"c1":{"EndpointID":"ep91","IPv4Address":"10.60.0.5/24"},
+					"c2":{"EndpointID":"ep92","IPv4Address":"10.60.0.6/24"}}}`))
+		case "/containers/c1/json":
+			w.Write([]byte(`{"Id":"c1","State":{"Running":true},"NetworkSettings":{"SandboxKey":"/var/run/docker/netns/sb1"},
+				"Config":{"Labels":{"i2p.alias":"web"},"Env":["PORT=8080"]}}`))
+		default:
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+		}
+	})
+}
+
+func TestDockerDriverState(t *testing.T) {
+	var filters string
+	client := startDriverTestServer(t, &filters)
+
+	state, err := client.driverState(context.Background(), "i2p-network")
+	if err != nil {
+		t.Fatalf("driverState() unexpected error: %v", err)
+	}
+	if filters != `{"driver":["i2p-network"]}` {
+		t.Errorf("Expected networks to be filtered by driver, got %q", filters)
+	}
+	if len(state.networks) != 2 {
+		t.Fatalf("Expected 2 networks, got %d", len(state.networks))
+	}
+
+	// c2 was removed between the queries
+	if len(state.containers) != 1 || state.containers["c1"] == nil {
+		t.Fatalf("Expected only the container Docker still knows, got %v", state.containers)
+	}
+	container := state.containers["c1"]
+	if container.sandboxID() != "sb1" {
+		t.Errorf("Expected the sandbox ID handleJoin uses, got %q", container.sandboxID())
+	}
+	options := container.joinOptions()
+	if labels, ok := options["Labels"].(map[string]interface{}); !ok || labels["i2p.alias"] != "web" {
+		t.Errorf("Expected the labels in the Join options, got %v", options)
+	}
+	if _, ok := options["ExposedPorts"]; ok {
+		t.Errorf("Expected no exposed ports in the Join options, got %v", options)
+	}
+
+	createOptions, ipamData := state.networks[1].createOptions()
+	if getNetworkName(createOptions) != "lost-net" || createOptions["i2p.exposure.default"] != "ip" {
+		t.Errorf("Expected the network name and driver options, got %v", createOptions)
+	}
+	if len(ipamData) != 1 || ipamData[0].Pool != "10.60.0.0/24" || ipamData[0].Gateway != "10.60.0.1" {
+		t.Errorf("Expected the IPAM configuration as IPAM data, got %v", ipamData)
+	}
+}
+
+func TestSyncWithDockerAdoptsNetworks(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	nm := p.networkMgr
+	if err := nm.proxyMgr.CheckIptablesAvailability(); err != nil {
+		t.Skipf("iptables not available: %v", err)
+	}
+
+	var filters string
+	client := startDriverTestServer(t, &filters)
+	if err := nm.syncWithDocker(context.Background(), client, "i2p-network"); err != nil {
+		t.Fatalf("syncWithDocker() unexpected error: %v", err)
+	}
+
+	network := nm.GetNetwork("net9")
+	if network == nil {
+		t.Fatal("Expected the network missing from the plugin state to be adopted")
+	}
+	if network.Name != "lost-net" || network.Subnet.String() != "10.60.0.0/24" || !network.Restored {
+		t.Errorf("Expected the network to be adopted as Docker created it, got %+v", network)
+	}
+
+	joined := network.Endpoints["ep91"]
+	if joined == nil || joined.State != EndpointJoined || joined.ContainerID != "sb1" || !joined.IPAddress.Equal(net.ParseIP("10.60.0.5")) {
+		t.Errorf("Expected the running container to be joined under its sandbox ID, got %+v", joined)
+	}
+	if joined != nil && !joined.Restored {
+		t.Error("Expected adopted endpoints to accept Docker joining them again")
+	}
+	created := network.Endpoints["ep92"]
+	if created == nil || created.State != EndpointCreated {
+		t.Errorf("Expected the endpoint of the unknown container to be created only, got %+v", created)
+	}
+}
+
+func TestDockerDriverName(t *testing.T) {
+	if name := dockerDriverName("/run/docker/plugins/i2p-network.sock"); name != "i2p-network" {
+		t.Errorf("Expected the socket name without extension, got %q", name)
+	}
+}
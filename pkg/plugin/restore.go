@@ -0,0 +1,484 @@
+// Package plugin provides recovery of networks and endpoints after a plugin
+// restart.
+//
+// With dockerd's live-restore, containers keep running while the daemon or
+// the plugin restarts, and Docker does not create networks or join endpoints
+// again when the plugin comes back; it expects the driver to still know them.
+// The network manager therefore saves its networks, their endpoints, the
+// options of every Join, the session keys of joined containers and the ports
+// they expose to a state file whenever they change.
+//
+// On start, the saved networks are created again with their subnets, proxy
+// listeners and filter entries, and endpoints get their addresses back.
+// Joined endpoints whose sandbox still exists are joined again with the saved
+// options and keys, which re-establishes their sessions, exposures and port
+// maps under the same .b32.i2p addresses without restarting the container;
+// saved exposures the Join options do not recreate, such as ports exposed
+// through the admin API, are exposed again as well.
+// Endpoints of containers that stopped meanwhile are parked, so Docker's
+// Leave and DeleteEndpoint for them succeed.
+//
+// Restored networks and endpoints are reconciled with the next calls Docker
+// makes: a CreateNetwork, CreateEndpoint or Join repeating a restored one is
+// accepted instead of failing because it already exists, and what Docker
+// removed while the plugin was down is dropped once the Docker Engine API
+// answers (see reconcileNetworks). The state file holds private keys; like
+// every state file it is created with mode 0600.
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/state"
+)
+
+// networkStateSchema describes the layout versions of the network state file.
+var networkStateSchema = state.Schema{Name: "network state"}
+
+// networkRecord is what is saved about a network.
+type networkRecord struct {
+	// ID is the Docker network ID
+	ID string `json:"id"`
+	// Options are the network's options, including live filter updates
+	Options map[string]interface{} `json:"options,omitempty"`
+	// Policy is the policy template the network was created with, if any
+	Policy *NetworkPolicy `json:"policy,omitempty"`
+	// Subnet is the network's subnet in CIDR notation
+	Subnet string `json:"subnet"`
+	// Gateway is the network's gateway address
+	Gateway string `json:"gateway"`
+	// IPAMPool is the IPAM driver pool the network was created on, if any
+	IPAMPool string `json:"ipam_pool,omitempty"`
+	// IPAMStrategy is the allocation strategy of the network's IPAM pools
+	IPAMStrategy string `json:"ipam_strategy,omitempty"`
+	// SubnetIPv6 is the network's IPv6 subnet in CIDR notation, if any
+	SubnetIPv6 string `json:"subnet_ipv6,omitempty"`
+	// GatewayIPv6 is the network's IPv6 gateway address, if any
+	GatewayIPv6 string `json:"gateway_ipv6,omitempty"`
+	// IPv6Pool is the IPAM driver pool of the IPv6 subnet, if any
+	IPv6Pool string `json:"ipv6_pool,omitempty"`
+	// Endpoints are the network's endpoints
+	Endpoints []endpointRecord `json:"endpoints,omitempty"`
+}
+
+// endpointRecord is what is saved about an endpoint.
+type endpointRecord struct {
+	// ID is the Docker endpoint ID
+	ID string `json:"id"`
+	// State is the endpoint's lifecycle state
+	State EndpointState `json:"state"`
+	// AllocationKey is the key the endpoint's addresses are allocated for
+	AllocationKey string `json:"allocation_key,omitempty"`
+	// IPAddress is the endpoint's address, empty while parked unless it
+	// belongs to an IPAM driver pool
+	IPAddress string `json:"ip_address,omitempty"`
+	// RequestedAddress is the address Docker asked for the endpoint, kept
+	// while parked so the endpoint rejoins with it
+	RequestedAddress string `json:"requested_address,omitempty"`
+	// IPv6Address is the endpoint's IPv6 address, saved like IPAddress
+	IPv6Address string `json:"ipv6_address,omitempty"`
+	// RequestedIPv6Address is the IPv6 address Docker asked for the endpoint
+	RequestedIPv6Address string `json:"requested_ipv6_address,omitempty"`
+	// MacAddress is the endpoint's MAC address, empty while parked
+	MacAddress string `json:"mac_address,omitempty"`
+	// ContainerID is the joined container
+	ContainerID string `json:"container_id,omitempty"`
+	// SandboxKey is the network namespace of the joined container
+	SandboxKey string `json:"sandbox_key,omitempty"`
+	// JoinOptions are the options of the container's Join
+	JoinOptions map[string]interface{} `json:"join_options,omitempty"`
+	// Aliases are the endpoint's network aliases
+	Aliases []string `json:"aliases,omitempty"`
+	// PublicKey is the base64 destination of the container's session
+	PublicKey string `json:"public_key,omitempty"`
+	// PrivateKey is the base64 private key blob of the session
+	PrivateKey string `json:"private_key,omitempty"`
+	// Exposures are the ports the joined container exposes
+	Exposures []exposureRecord `json:"exposures,omitempty"`
+}
+
+// exposureRecord is what is saved about an exposed port.
+type exposureRecord struct {
+	// Port is the exposed port configuration
+	Port service.ExposedPort `json:"port"`
+	// ExpiresAt is when an exposure with a TTL is removed
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// SetStatePath sets the file networks and endpoints are persisted to, so
+// running containers keep their connectivity across plugin restarts. An
+// empty path keeps them in memory only.
+//
+// Must be called before Start so persisted networks are restored.
+func (p *Plugin) SetStatePath(path string) {
+	p.statePath = path
+}
+
+// restoreNetworks restores the persisted networks and endpoints.
+func (p *Plugin) restoreNetworks() error {
+	return p.networkMgr.restoreState(p.statePath)
+}
+
+// sandboxExists reports whether the network namespace of a container still
+// exists. When the directory holding the namespaces is not visible to the
+// plugin, the container cannot be inspected and is assumed to be running.
+func sandboxExists(sandboxKey string) bool {
+	if sandboxKey == "" {
+		return false
+	}
+	if _, err := os.Stat(sandboxKey); !errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+	_, err := os.Stat(filepath.Dir(sandboxKey))
+	return errors.Is(err, os.ErrNotExist)
+}
+
+// restoreState reads the state file at path and restores its networks and
+// endpoints, keeping path for later saves. A missing file is not an error.
+func (nm *NetworkManager) restoreState(path string) error {
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	nm.statePath = path
+	if path == "" {
+		return nil
+	}
+
+	var records []networkRecord
+	err := state.Load(path, networkStateSchema, &records)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var networks, rejoined, parked int
+	for _, record := range records {
+		network, err := nm.restoreNetworkLocked(record)
+		if err != nil {
+			log.Printf("Warning: Failed to restore network %s: %v", record.ID, err)
+			continue
+		}
+		networks++
+
+		for _, endpointRecord := range record.Endpoints {
+			network.mutex.Lock()
+			endpoint, err := nm.restoreEndpointLocked(network, endpointRecord)
+			network.mutex.Unlock()
+			if err != nil {
+				log.Printf("Warning: Failed to restore endpoint %s on network %s: %v", endpointRecord.ID, network.ID, err)
+				continue
+			}
+			switch {
+			case endpoint.State == EndpointJoined:
+				rejoined++
+			case endpointRecord.State == EndpointJoined:
+				parked++
+			}
+		}
+	}
+
+	// Save what was actually restored, dropping what could not be
+	nm.saveStateLocked()
+
+	log.Printf("Restored %d networks from %s: %d containers rejoined, %d stopped containers parked", networks, path, rejoined, parked)
+	return nil
+}
+
+// restoreNetworkLocked creates a saved network again. The caller must hold
+// nm.mutex.
+func (nm *NetworkManager) restoreNetworkLocked(record networkRecord) (*I2PNetwork, error) {
+	ipamData := []IPAMData{{Pool: record.Subnet, Gateway: record.Gateway}}
+	if record.IPAMPool != "" {
+		if err := nm.restoreIPAMPoolLocked(record); err != nil {
+			return nil, err
+		}
+		ipamData[0].AddressSpace = IPAMAddressSpace
+	}
+	if record.SubnetIPv6 != "" {
+		ipv6Data := IPAMData{Pool: record.SubnetIPv6, Gateway: record.GatewayIPv6}
+		if record.IPv6Pool != "" {
+			if err := nm.restorePoolLocked(record.IPv6Pool, record.SubnetIPv6, record.GatewayIPv6, record.IPAMStrategy); err != nil {
+				delete(nm.ipamPools, record.IPAMPool)
+				return nil, err
+			}
+			ipv6Data.AddressSpace = IPAMAddressSpace
+		}
+		ipamData = append(ipamData, ipv6Data)
+	}
+
+	setup, err := nm.prepareNetworkLocked(record.ID, record.Options, ipamData)
+	if err == nil {
+		setup.network.Policy = record.Policy
+		err = nm.createNetworkLocked(setup)
+	}
+	if err != nil {
+		delete(nm.ipamPools, record.IPAMPool)
+		delete(nm.ipamPools, record.IPv6Pool)
+		return nil, err
+	}
+	setup.network.Restored = true
+	return setup.network, nil
+}
+
+// restoreEndpointLocked adds a saved endpoint to network. A joined endpoint
+// is joined again if its container is still running and parked otherwise.
+// The caller must hold nm.mutex and the network's mutex.
+func (nm *NetworkManager) restoreEndpointLocked(network *I2PNetwork, record endpointRecord) (*I2PEndpoint, error) {
+	endpoint := &I2PEndpoint{
+		ID:            record.ID,
+		NetworkID:     network.ID,
+		State:         EndpointParked,
+		AllocationKey: record.AllocationKey,
+		ClientTunnels: make(map[string]*i2p.Tunnel),
+		ServerTunnels: make(map[string]*i2p.Tunnel),
+		Aliases:       record.Aliases,
+		Restored:      true,
+	}
+	if record.RequestedAddress != "" {
+		endpoint.RequestedAddress = net.ParseIP(record.RequestedAddress)
+	}
+	if record.RequestedIPv6Address != "" {
+		endpoint.RequestedIPv6Address = net.ParseIP(record.RequestedIPv6Address)
+	}
+
+	// Addresses of IPAM driver pools are held until Docker releases them,
+	// even by parked endpoints
+	stopped := record.State == EndpointJoined && !nm.sandboxRunning(record.SandboxKey)
+	keepAddress := network.IPAMPool != "" && record.IPAddress != ""
+	if keepAddress || record.State != EndpointParked && !stopped {
+		ip := net.ParseIP(record.IPAddress)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", record.IPAddress)
+		}
+		if err := network.IPAllocator.Reserve(ip); err != nil {
+			return nil, err
+		}
+		endpoint.IPAddress = ip
+		endpoint.MacAddress = record.MacAddress
+	}
+	keepIPv6Address := network.IPv6Pool != "" && record.IPv6Address != ""
+	if network.IPv6Allocator != nil && record.IPv6Address != "" && (keepIPv6Address || record.State != EndpointParked && !stopped) {
+		ip := net.ParseIP(record.IPv6Address)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IPv6 address %q", record.IPv6Address)
+		}
+		if err := network.IPv6Allocator.Reserve(ip); err != nil {
+			if endpoint.IPAddress != nil && network.IPAMPool == "" {
+				network.IPAllocator.ReleaseIP(endpoint.IPAddress)
+			}
+			return nil, err
+		}
+		endpoint.IPv6Address = ip
+	}
+
+	if stopped {
+		log.Printf("Container %s stopped while the plugin was down, parking endpoint %s", record.ContainerID, record.ID)
+		network.Endpoints[endpoint.ID] = endpoint
+		return endpoint, nil
+	}
+
+	if record.State != EndpointParked {
+		endpoint.State = EndpointCreated
+	}
+	network.Endpoints[endpoint.ID] = endpoint
+
+	if record.State != EndpointJoined {
+		return endpoint, nil
+	}
+
+	// Hand the container its previous keys before the join builds its
+	// session, so its addresses stay the same
+	if record.PrivateKey != "" {
+		keys, err := i2p.ParseKeys(record.PublicKey, record.PrivateKey)
+		if err == nil {
+			err = nm.tunnelMgr.AdoptSessionKeys(record.ContainerID, keys)
+		}
+		if err != nil {
+			log.Printf("Warning: Container %s gets a new destination, its saved keys were not restored: %v", record.ContainerID, err)
+		}
+	}
+
+	if err := nm.joinEndpointLocked(network, endpoint, record.ContainerID, record.SandboxKey, record.JoinOptions); err != nil {
+		if network.IPAMPool == "" {
+			network.IPAllocator.ReleaseIP(endpoint.IPAddress)
+			endpoint.IPAddress = nil
+			endpoint.MacAddress = ""
+		}
+		nm.releaseIPv6AddressLocked(network, endpoint)
+		endpoint.State = EndpointParked
+		log.Printf("Warning: Failed to rejoin container %s, parking endpoint %s: %v", record.ContainerID, record.ID, err)
+		return endpoint, nil
+	}
+
+	nm.restoreExposuresLocked(network, endpoint, record.Exposures)
+	return endpoint, nil
+}
+
+// restoreExposuresLocked exposes the saved ports of a rejoined endpoint that
+// the join did not expose again from its options, such as ports exposed
+// through the admin API or mapped with -p. Exposures keep the rest of their
+// TTL, and those that expired while the plugin was down are dropped. The
+// caller must hold nm.mutex and the network's mutex.
+func (nm *NetworkManager) restoreExposuresLocked(network *I2PNetwork, endpoint *I2PEndpoint, records []exposureRecord) {
+	for _, record := range records {
+		port := record.Port
+		// Ports waiting for their dependencies come from the Join options
+		// and are exposed by the join once those are listening
+		if len(port.After) > 0 || hasExposure(endpoint, port) {
+			continue
+		}
+		if record.ExpiresAt != nil {
+			port.TTL = time.Until(*record.ExpiresAt)
+			if port.TTL <= 0 {
+				continue
+			}
+		}
+
+		exposure, err := nm.serviceMgr.ExposeService(endpoint.ContainerID, network.ID, endpoint.IPAddress, port)
+		if err != nil {
+			log.Printf("Warning: Failed to restore %s exposure of port %d for container %s: %v",
+				port.ExposureType, port.ContainerPort, endpoint.ContainerID, err)
+			continue
+		}
+		endpoint.ServiceExposures = append(endpoint.ServiceExposures, exposure)
+	}
+}
+
+// hasExposure reports whether an endpoint already exposes a port with the
+// same protocol. An empty protocol is TCP.
+func hasExposure(endpoint *I2PEndpoint, port service.ExposedPort) bool {
+	protocol := func(p string) string {
+		if p == "" {
+			return "tcp"
+		}
+		return strings.ToLower(p)
+	}
+	for _, exposure := range endpoint.ServiceExposures {
+		if exposure.Port.ContainerPort == port.ContainerPort && protocol(exposure.Port.Protocol) == protocol(port.Protocol) {
+			return true
+		}
+	}
+	return false
+}
+
+// saveState writes the networks and endpoints to the state file for changes
+// made without holding nm.mutex, such as exposures added or removed by the
+// service manager.
+func (nm *NetworkManager) saveState() {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+	nm.saveStateLocked()
+}
+
+// saveStateLocked writes the networks and endpoints to the state file, if
+// one is configured. The caller must hold nm.mutex, for reading at least, but
+// no network's mutex.
+//
+// Endpoint operations on different networks save concurrently; nm.stateMutex
+// makes each write a snapshot taken after the previous one was written. Saving
+// never waits for an endpoint operation in progress.
+func (nm *NetworkManager) saveStateLocked() {
+	if nm.statePath == "" {
+		return
+	}
+
+	nm.stateMutex.Lock()
+	defer nm.stateMutex.Unlock()
+
+	records := make([]networkRecord, 0, len(nm.networks))
+	for _, network := range nm.networks {
+		record := networkRecord{
+			ID:      network.ID,
+			Options: network.Options,
+			Policy:  network.Policy,
+			Subnet:  network.Subnet.String(),
+			Gateway: network.Gateway.String(),
+		}
+		if network.IPAMPool != "" {
+			record.IPAMPool = network.IPAMPool
+			record.IPAMStrategy = network.IPAllocator.Strategy()
+		}
+		if network.SubnetIPv6 != nil {
+			record.SubnetIPv6 = network.SubnetIPv6.String()
+			record.GatewayIPv6 = network.GatewayIPv6.String()
+			if network.IPv6Pool != "" {
+				record.IPv6Pool = network.IPv6Pool
+				record.IPAMStrategy = network.IPv6Allocator.Strategy()
+			}
+		}
+		// Endpoints change under the network's lock only. An endpoint
+		// operation holding it, such as a join building tunnels, saves the
+		// state again once it is done, so until then the endpoints saved last
+		// are written rather than waiting for it.
+		if network.mutex.TryRLock() {
+			network.savedEndpoints = nil
+			for _, endpoint := range network.Endpoints {
+				network.savedEndpoints = append(network.savedEndpoints, nm.endpointRecord(endpoint))
+			}
+			network.mutex.RUnlock()
+			sort.Slice(network.savedEndpoints, func(i, j int) bool {
+				return network.savedEndpoints[i].ID < network.savedEndpoints[j].ID
+			})
+		}
+		record.Endpoints = network.savedEndpoints
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+
+	if err := state.Save(nm.statePath, networkStateSchema, records); err != nil {
+		log.Printf("Warning: Failed to save network state: %v", err)
+	}
+}
+
+// endpointRecord returns the saved form of an endpoint.
+func (nm *NetworkManager) endpointRecord(endpoint *I2PEndpoint) endpointRecord {
+	record := endpointRecord{
+		ID:            endpoint.ID,
+		State:         endpoint.State,
+		AllocationKey: endpoint.AllocationKey,
+		MacAddress:    endpoint.MacAddress,
+		ContainerID:   endpoint.ContainerID,
+		SandboxKey:    endpoint.SandboxKey,
+		JoinOptions:   endpoint.JoinOptions,
+		Aliases:       endpoint.Aliases,
+	}
+	if endpoint.IPAddress != nil {
+		record.IPAddress = endpoint.IPAddress.String()
+	}
+	if endpoint.RequestedAddress != nil {
+		record.RequestedAddress = endpoint.RequestedAddress.String()
+	}
+	if endpoint.IPv6Address != nil {
+		record.IPv6Address = endpoint.IPv6Address.String()
+	}
+	if endpoint.RequestedIPv6Address != nil {
+		record.RequestedIPv6Address = endpoint.RequestedIPv6Address.String()
+	}
+	if endpoint.State == EndpointJoined {
+		if keys, exists := nm.tunnelMgr.SessionKeys(endpoint.ContainerID); exists {
+			record.PublicKey = keys.Addr().Base64()
+			record.PrivateKey = keys.String()
+		}
+		for _, exposure := range endpoint.ServiceExposures {
+			saved := exposureRecord{Port: exposure.Port}
+			if !exposure.ExpiresAt.IsZero() {
+				expiresAt := exposure.ExpiresAt
+				saved.ExpiresAt = &expiresAt
+			}
+			record.Exposures = append(record.Exposures, saved)
+		}
+	}
+	return record
+}
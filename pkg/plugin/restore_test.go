@@ -0,0 +1,210 @@
+package plugin
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/state"
+)
+
+func TestSaveNetworkState(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	nm := p.networkMgr
+	path := filepath.Join(t.TempDir(), "networks.json")
+	nm.statePath = path
+
+	endpoint := nm.GetNetwork("net1").Endpoints["ep1"]
+	endpoint.SandboxKey = "/var/run/docker/netns/abc"
+	endpoint.JoinOptions = map[string]interface{}{"Labels": map[string]interface{}{"app": "web"}}
+	expiresAt := time.Now().Add(time.Hour).Round(time.Second)
+	endpoint.ServiceExposures = []*service.ServiceExposure{{
+		ContainerID: "container1",
+		Port:        service.ExposedPort{ContainerPort: 8080, Protocol: "tcp", ExposureType: service.ExposureTypeIP, TTL: 2 * time.Hour},
+		ExpiresAt:   expiresAt,
+	}}
+
+	nm.mutex.Lock()
+	nm.saveStateLocked()
+	nm.mutex.Unlock()
+
+	var records []networkRecord
+	if err := state.Load(path, networkStateSchema, &records); err != nil {
+		t.Fatalf("Failed to load network state: %v", err)
+	}
+	if len(records) != 1 || records[0].ID != "net1" || records[0].Subnet != "172.20.1.0/24" || records[0].Gateway != "172.20.1.1" {
+		t.Fatalf("Expected net1 to be saved, got %+v", records)
+	}
+	if len(records[0].Endpoints) != 1 {
+		t.Fatalf("Expected 1 saved endpoint, got %d", len(records[0].Endpoints))
+	}
+	saved := records[0].Endpoints[0]
+	if saved.State != EndpointJoined || saved.ContainerID != "container1" || saved.IPAddress != "172.20.1.2" ||
+		saved.SandboxKey != "/var/run/docker/netns/abc" || saved.JoinOptions["Labels"] == nil {
+		t.Errorf("Unexpected saved endpoint %+v", saved)
+	}
+	if len(saved.Exposures) != 1 || saved.Exposures[0].Port.ContainerPort != 8080 ||
+		saved.Exposures[0].ExpiresAt == nil || !saved.Exposures[0].ExpiresAt.Equal(expiresAt) {
+		t.Errorf("Expected the exposure of port 8080 to be saved with its expiry, got %+v", saved.Exposures)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat network state: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the state file to have mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestRestoreEndpoints(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	nm := p.networkMgr
+	network := nm.GetNetwork("net1")
+	network.IPAllocator.Reserve(net.ParseIP("172.20.1.2"))
+	nm.sandboxRunning = func(sandboxKey string) bool { return sandboxKey == "/netns/running" }
+
+	records := []endpointRecord{
+		{ID: "created", State: EndpointCreated, IPAddress: "172.20.1.3", MacAddress: "02:42:ac:14:01:03"},
+		{ID: "parked", State: EndpointParked},
+		{ID: "stopped", State: EndpointJoined, IPAddress: "172.20.1.4", ContainerID: "container4", SandboxKey: "/netns/gone"},
+		{ID: "running", State: EndpointJoined, IPAddress: "172.20.1.5", ContainerID: "container5", SandboxKey: "/netns/running"},
+	}
+
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+	for _, record := range records {
+		if _, err := nm.restoreEndpointLocked(network, record); err != nil {
+			t.Fatalf("restoreEndpointLocked(%s) unexpected error: %v", record.ID, err)
+		}
+	}
+
+	tests := []struct {
+		id        string
+		state     EndpointState
+		ip        string
+		container string
+	}{
+		{id: "created", state: EndpointCreated, ip: "172.20.1.3"},
+		{id: "parked", state: EndpointParked},
+		{id: "stopped", state: EndpointParked},
+		{id: "running", state: EndpointJoined, ip: "172.20.1.5", container: "container5"},
+	}
+	for _, tt := range tests {
+		endpoint := network.Endpoints[tt.id]
+		if endpoint == nil || endpoint.State != tt.state || endpoint.ContainerID != tt.container || !endpoint.Restored {
+			t.Errorf("Expected restored endpoint %s to be %s, got %+v", tt.id, tt.state, endpoint)
+			continue
+		}
+		if tt.ip == "" && endpoint.IPAddress != nil {
+			t.Errorf("Expected endpoint %s to have no address, got %s", tt.id, endpoint.IPAddress)
+		}
+		if tt.ip != "" && (!endpoint.IPAddress.Equal(net.ParseIP(tt.ip)) || !network.IPAllocator.IsAllocated(endpoint.IPAddress)) {
+			t.Errorf("Expected endpoint %s to hold %s, got %s", tt.id, tt.ip, endpoint.IPAddress)
+		}
+	}
+	if network.IPAllocator.IsAllocated(net.ParseIP("172.20.1.4")) {
+		t.Error("Expected the address of the stopped container to stay free")
+	}
+
+	// An address taken in the meantime fails the endpoint
+	if _, err := nm.restoreEndpointLocked(network, endpointRecord{ID: "conflict", State: EndpointCreated, IPAddress: "172.20.1.3"}); err == nil {
+		t.Error("Expected an error for an address already in use")
+	}
+}
+
+func TestRestoreExposuresSkipped(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	nm := p.networkMgr
+	network := nm.GetNetwork("net1")
+	endpoint := network.Endpoints["ep1"]
+	endpoint.ServiceExposures = []*service.ServiceExposure{{
+		ContainerID: "container1",
+		Port:        service.ExposedPort{ContainerPort: 8080, ExposureType: service.ExposureTypeIP},
+	}}
+	expired := time.Now().Add(-time.Minute)
+
+	nm.mutex.Lock()
+	nm.restoreExposuresLocked(network, endpoint, []exposureRecord{
+		{Port: service.ExposedPort{ContainerPort: 8080, Protocol: "TCP", ExposureType: service.ExposureTypeI2P}},
+		{Port: service.ExposedPort{ContainerPort: 9090, ExposureType: service.ExposureTypeIP}, ExpiresAt: &expired},
+		{Port: service.ExposedPort{ContainerPort: 5432, ExposureType: service.ExposureTypeIP, After: []int{8080}}},
+	})
+	nm.mutex.Unlock()
+
+	if len(endpoint.ServiceExposures) != 1 {
+		t.Errorf("Expected exposed, expired and dependent ports to be skipped, got %d exposures", len(endpoint.ServiceExposures))
+	}
+}
+
+func TestReconcileRestored(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	nm := p.networkMgr
+	network := nm.GetNetwork("net1")
+	network.Restored = true
+	endpoint := network.Endpoints["ep1"]
+	endpoint.Restored = true
+
+	if err := nm.CreateNetwork("net1", nil, nil); err != nil {
+		t.Errorf("Expected CreateNetwork of a restored network to succeed, got %v", err)
+	}
+	if err := nm.CreateNetwork("net1", nil, nil); err == nil {
+		t.Error("Expected a second CreateNetwork to fail")
+	}
+
+	joined, err := nm.JoinEndpoint("net1", "ep1", "container1", "/netns/container1", nil)
+	if err != nil || joined != endpoint {
+		t.Errorf("Expected Join of a restored endpoint to return it, got %v", err)
+	}
+	if _, err := nm.JoinEndpoint("net1", "ep1", "container1", "/netns/container1", nil); err == nil {
+		t.Error("Expected a second Join to fail")
+	}
+
+	endpoint.Restored = true
+	if _, err := nm.JoinEndpoint("net1", "ep1", "other", "/netns/other", nil); err == nil {
+		t.Error("Expected Join of a restored endpoint by another container to fail")
+	}
+	created, err := nm.CreateEndpoint("net1", "ep1", nil)
+	if err != nil || created != endpoint || endpoint.Restored {
+		t.Errorf("Expected CreateEndpoint of a restored endpoint to return it, got %v", err)
+	}
+}
+
+func TestRestoreStateMissingFile(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	path := filepath.Join(t.TempDir(), "networks.json")
+
+	if err := p.networkMgr.restoreState(path); err != nil {
+		t.Fatalf("Expected a missing state file to be ignored, got %v", err)
+	}
+	if p.networkMgr.statePath != path {
+		t.Errorf("Expected the state path to be kept for saving, got %q", p.networkMgr.statePath)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected no state file to be written, got %v", err)
+	}
+}
+
+func TestSandboxExists(t *testing.T) {
+	dir := t.TempDir()
+	sandbox := filepath.Join(dir, "abc")
+	if err := os.WriteFile(sandbox, nil, 0600); err != nil {
+		t.Fatalf("Failed to create sandbox: %v", err)
+	}
+
+	if !sandboxExists(sandbox) {
+		t.Error("Expected an existing sandbox to be running")
+	}
+	if sandboxExists(filepath.Join(dir, "gone")) {
+		t.Error("Expected a missing sandbox to be stopped")
+	}
+	if !sandboxExists(filepath.Join(dir, "missing", "abc")) {
+		t.Error("Expected sandboxes in an invisible directory to be assumed running")
+	}
+	if sandboxExists("") {
+		t.Error("Expected an empty sandbox key to be stopped")
+	}
+}
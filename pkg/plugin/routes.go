@@ -0,0 +1,51 @@
+// Package plugin provides the static routes of joined containers.
+//
+// The DNS resolver answers I2P names with synthetic addresses from
+// 198.18.0.0/15, or fd69:3270:b32::/48 in IPv6 address mode, and the
+// transparent proxy intercepts connections to them as they reach the host
+// through the network gateway. A container whose default route leaves
+// through another network, e.g. one also attached to a bridge network, would
+// send those connections elsewhere, so Join returns static routes sending the
+// synthetic ranges through the gateway of the I2P network.
+//
+// The kernel refuses a second route to the same range, so a container on
+// several I2P networks gets the routes from the first network it joins only.
+package plugin
+
+import (
+	"log"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+// syntheticRoutes returns the static routes a container joining network
+// needs to reach synthetic addresses, or nil if the container already got
+// them from another network.
+//
+// The IPv6 range is only routed in IPv6 address mode and on networks with an
+// IPv6 gateway.
+func (nm *NetworkManager) syntheticRoutes(network *I2PNetwork, containerID string) []StaticRoute {
+	if nm.joined.endpoints(containerID) > 1 {
+		log.Printf("Container %s already routes I2P addresses through another network, skipping routes of network %s",
+			i2p.DisplayName(containerID), network.ID)
+		return nil
+	}
+
+	var routes []StaticRoute
+	if network.Gateway != nil {
+		routes = append(routes, StaticRoute{
+			Destination: proxy.SyntheticIPRange,
+			RouteType:   RouteTypeNextHop,
+			NextHop:     network.Gateway.String(),
+		})
+	}
+	if network.GatewayIPv6 != nil && nm.proxyMgr.AddressMode() == proxy.AddressModeIPv6 {
+		routes = append(routes, StaticRoute{
+			Destination: proxy.SyntheticIPv6Range,
+			RouteType:   RouteTypeNextHop,
+			NextHop:     network.GatewayIPv6.String(),
+		})
+	}
+	return routes
+}
@@ -0,0 +1,83 @@
+package plugin
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+func TestSyntheticRoutes(t *testing.T) {
+	t.Parallel()
+
+	nm := createMockNetworkManager(t)
+	if err := nm.proxyMgr.SetAddressMode(proxy.AddressModeIPv6); err != nil {
+		t.Fatalf("SetAddressMode() unexpected error: %v", err)
+	}
+
+	_, subnet, _ := net.ParseCIDR("10.50.0.0/24")
+	_, subnetIPv6, _ := net.ParseCIDR("fd00:50::/64")
+	dualStack := &I2PNetwork{
+		ID:            "net1",
+		Subnet:        subnet,
+		Gateway:       net.ParseIP("10.50.0.1"),
+		SubnetIPv6:    subnetIPv6,
+		GatewayIPv6:   net.ParseIP("fd00:50::1"),
+		Endpoints:     map[string]*I2PEndpoint{},
+		IPAllocator:   NewIPAllocator(subnet, net.ParseIP("10.50.0.1")),
+		IPv6Allocator: NewIPAllocator(subnetIPv6, net.ParseIP("fd00:50::1")),
+		TunnelManager: nm.tunnelMgr,
+	}
+	_, other, _ := net.ParseCIDR("10.51.0.0/24")
+	second := &I2PNetwork{
+		ID:            "net2",
+		Subnet:        other,
+		Gateway:       net.ParseIP("10.51.0.1"),
+		Endpoints:     map[string]*I2PEndpoint{},
+		IPAllocator:   NewIPAllocator(other, net.ParseIP("10.51.0.1")),
+		TunnelManager: nm.tunnelMgr,
+	}
+	nm.addNetworkLocked(dualStack)
+	nm.addNetworkLocked(second)
+
+	for _, network := range []string{"net1", "net2"} {
+		if _, err := nm.CreateEndpoint(network, "ep-"+network, nil); err != nil {
+			t.Fatalf("Failed to create endpoint: %v", err)
+		}
+	}
+
+	if _, err := nm.JoinEndpoint("net1", "ep-net1", "container1", "", nil); err != nil {
+		t.Fatalf("Failed to join endpoint: %v", err)
+	}
+	routes := nm.syntheticRoutes(dualStack, "container1")
+	expected := []StaticRoute{
+		{Destination: proxy.SyntheticIPRange, RouteType: RouteTypeNextHop, NextHop: "10.50.0.1"},
+		{Destination: proxy.SyntheticIPv6Range, RouteType: RouteTypeNextHop, NextHop: "fd00:50::1"},
+	}
+	if fmt.Sprint(routes) != fmt.Sprint(expected) {
+		t.Errorf("Expected routes through the gateways %v, got %v", expected, routes)
+	}
+
+	// The container already routes synthetic addresses through net1
+	if _, err := nm.JoinEndpoint("net2", "ep-net2", "container1", "", nil); err != nil {
+		t.Fatalf("Failed to join endpoint: %v", err)
+	}
+	if routes := nm.syntheticRoutes(second, "container1"); routes != nil {
+		t.Errorf("Expected no routes from a second network, got %v", routes)
+	}
+
+	// A container first joining an IPv4-only network gets the IPv4 route only
+	if err := nm.LeaveEndpoint("net1", "ep-net1"); err != nil {
+		t.Fatalf("Failed to leave endpoint: %v", err)
+	}
+	if err := nm.LeaveEndpoint("net2", "ep-net2"); err != nil {
+		t.Fatalf("Failed to leave endpoint: %v", err)
+	}
+	if _, err := nm.JoinEndpoint("net2", "ep-net2", "container2", "", nil); err != nil {
+		t.Fatalf("Failed to join endpoint: %v", err)
+	}
+	if routes := nm.syntheticRoutes(second, "container2"); len(routes) != 1 || routes[0].NextHop != "10.51.0.1" {
+		t.Errorf("Expected the IPv4 route only, got %v", routes)
+	}
+}
@@ -0,0 +1,185 @@
+// Package plugin provides per-endpoint sidecar sockets for I2P networks.
+//
+// This file implements the plugin side of the sidecar pattern. Containers that
+// carry the i2p.sidecar label get the SOCKS proxy and DNS resolution API on
+// Unix sockets whose paths are rendered from per-network templates. Operators
+// bind-mount the socket directory into the designated containers, so only those
+// containers can reach the proxy regardless of IP-level reachability.
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// SidecarLabel is the container label that enables sidecar sockets.
+	// Its value names the sidecar and is available as {name} in path templates.
+	SidecarLabel = "i2p.sidecar"
+
+	// defaultSidecarSOCKSPath is the default SOCKS socket path template
+	defaultSidecarSOCKSPath = "/run/i2p-sidecar/{name}/socks.sock"
+
+	// defaultSidecarDNSPath is the default DNS API socket path template
+	defaultSidecarDNSPath = "/run/i2p-sidecar/{name}/dns.sock"
+)
+
+// sidecarNamePattern restricts template values to safe path components.
+var sidecarNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// SidecarConfig defines sidecar socket path templates for a network.
+//
+// Templates may use {name}, {network}, {endpoint} and {container}
+// placeholders. An empty template disables that socket.
+type SidecarConfig struct {
+	// SOCKSPathTemplate is the path template for the SOCKS5 socket
+	SOCKSPathTemplate string
+	// DNSPathTemplate is the path template for the DNS resolution API socket
+	DNSPathTemplate string
+}
+
+// parseSidecarConfig extracts sidecar socket templates from network options.
+//
+// Configuration options:
+//   - i2p.sidecar.socks_path: SOCKS socket template (default: /run/i2p-sidecar/{name}/socks.sock)
+//   - i2p.sidecar.dns_path: DNS API socket template (default: /run/i2p-sidecar/{name}/dns.sock)
+//
+// Setting either option to an empty string disables that socket.
+func parseSidecarConfig(options map[string]interface{}) SidecarConfig {
+	config := SidecarConfig{
+		SOCKSPathTemplate: defaultSidecarSOCKSPath,
+		DNSPathTemplate:   defaultSidecarDNSPath,
+	}
+
+	if options == nil {
+		return config
+	}
+
+	if path, ok := options["i2p.sidecar.socks_path"].(string); ok {
+		config.SOCKSPathTemplate = strings.TrimSpace(path)
+		log.Printf("Network sidecar SOCKS socket template set to %q", config.SOCKSPathTemplate)
+	}
+
+	if path, ok := options["i2p.sidecar.dns_path"].(string); ok {
+		config.DNSPathTemplate = strings.TrimSpace(path)
+		log.Printf("Network sidecar DNS socket template set to %q", config.DNSPathTemplate)
+	}
+
+	return config
+}
+
+// getSidecarName returns the sidecar name from container labels.
+//
+// Returns false if the container did not request sidecar sockets.
+func getSidecarName(options map[string]interface{}) (string, bool) {
+	labels, ok := options["Labels"].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+
+	name, ok := labels[SidecarLabel].(string)
+	if !ok {
+		return "", false
+	}
+
+	name = strings.TrimSpace(name)
+	return name, name != ""
+}
+
+// renderSidecarPath expands placeholders in a socket path template.
+//
+// Every substituted value must be a safe path component and the result must be
+// an absolute path, so labels cannot be used to escape the template directory.
+func renderSidecarPath(template string, vars map[string]string) (string, error) {
+	if template == "" {
+		return "", nil
+	}
+
+	path := template
+	for key, value := range vars {
+		placeholder := "{" + key + "}"
+		if !strings.Contains(path, placeholder) {
+			continue
+		}
+		if !sidecarNamePattern.MatchString(value) {
+			return "", fmt.Errorf("invalid value %q for %s", value, placeholder)
+		}
+		path = strings.ReplaceAll(path, placeholder, value)
+	}
+
+	if strings.ContainsAny(path, "{}") {
+		return "", fmt.Errorf("unknown placeholder in sidecar path template %q", template)
+	}
+	if !filepath.IsAbs(path) || filepath.Clean(path) != path {
+		return "", fmt.Errorf("sidecar path %q must be a clean absolute path", path)
+	}
+
+	return path, nil
+}
+
+// startSidecarSockets starts sidecar sockets for a joining endpoint if requested.
+//
+// The caller must hold the network's mutex.
+func (nm *NetworkManager) startSidecarSockets(network *I2PNetwork, endpoint *I2PEndpoint, options map[string]interface{}) error {
+	name, ok := getSidecarName(options)
+	if !ok {
+		return nil
+	}
+
+	socksPath, dnsPath, err := renderSidecarPaths(network, endpoint.ID, endpoint.ContainerID, name)
+	if err != nil {
+		return err
+	}
+
+	if socksPath == "" && dnsPath == "" {
+		log.Printf("Warning: Sidecar requested by container %s but network %s has all sidecar sockets disabled",
+			endpoint.ContainerID, network.ID)
+		return nil
+	}
+
+	sockets, err := nm.proxyMgr.StartSidecarSockets(endpoint.ID, socksPath, dnsPath)
+	if err != nil {
+		return err
+	}
+
+	endpoint.SidecarSockets = sockets
+	return nil
+}
+
+// renderSidecarPaths renders a network's sidecar socket templates for an
+// endpoint. Disabled sockets have an empty path.
+func renderSidecarPaths(network *I2PNetwork, endpointID, containerID, name string) (string, string, error) {
+	vars := map[string]string{
+		"name":      name,
+		"network":   network.ID,
+		"endpoint":  endpointID,
+		"container": containerID,
+	}
+
+	socksPath, err := renderSidecarPath(network.SidecarConfig.SOCKSPathTemplate, vars)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid SOCKS socket path: %w", err)
+	}
+
+	dnsPath, err := renderSidecarPath(network.SidecarConfig.DNSPathTemplate, vars)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid DNS socket path: %w", err)
+	}
+
+	return socksPath, dnsPath, nil
+}
+
+// stopSidecarSockets stops sidecar sockets for an endpoint if any are running.
+func (nm *NetworkManager) stopSidecarSockets(endpoint *I2PEndpoint) {
+	if endpoint.SidecarSockets == nil {
+		return
+	}
+
+	if err := nm.proxyMgr.StopSidecarSockets(endpoint.ID); err != nil {
+		log.Printf("Warning: Failed to stop sidecar sockets for endpoint %s: %v", endpoint.ID, err)
+	}
+	endpoint.SidecarSockets = nil
+}
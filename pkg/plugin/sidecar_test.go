@@ -0,0 +1,80 @@
+package plugin
+
+import (
+	"testing"
+)
+
+func TestParseSidecarConfig(t *testing.T) {
+	config := parseSidecarConfig(nil)
+	if config.SOCKSPathTemplate != defaultSidecarSOCKSPath || config.DNSPathTemplate != defaultSidecarDNSPath {
+		t.Errorf("Expected default templates, got %+v", config)
+	}
+
+	config = parseSidecarConfig(map[string]interface{}{
+		"i2p.sidecar.socks_path": "/srv/i2p/{network}/{name}.sock",
+		"i2p.sidecar.dns_path":   "",
+	})
+	if config.SOCKSPathTemplate != "/srv/i2p/{network}/{name}.sock" {
+		t.Errorf("Expected custom SOCKS template, got %q", config.SOCKSPathTemplate)
+	}
+	if config.DNSPathTemplate != "" {
+		t.Errorf("Expected DNS socket to be disabled, got %q", config.DNSPathTemplate)
+	}
+}
+
+func TestGetSidecarName(t *testing.T) {
+	if _, ok := getSidecarName(map[string]interface{}{}); ok {
+		t.Error("Expected no sidecar without labels")
+	}
+
+	options := map[string]interface{}{
+		"Labels": map[string]interface{}{SidecarLabel: " web "},
+	}
+	name, ok := getSidecarName(options)
+	if !ok || name != "web" {
+		t.Errorf("Expected sidecar name web, got %q (%v)", name, ok)
+	}
+}
+
+func TestRenderSidecarPath(t *testing.T) {
+	vars := map[string]string{
+		"name":      "web",
+		"network":   "net1",
+		"endpoint":  "ep1",
+		"container": "abc123",
+	}
+
+	tests := []struct {
+		name      string
+		template  string
+		vars      map[string]string
+		expected  string
+		expectErr bool
+	}{
+		{"default", defaultSidecarSOCKSPath, vars, "/run/i2p-sidecar/web/socks.sock", false},
+		{"all placeholders", "/run/{network}/{endpoint}/{container}-{name}.sock", vars, "/run/net1/ep1/abc123-web.sock", false},
+		{"disabled", "", vars, "", false},
+		{"traversal name", defaultSidecarSOCKSPath, map[string]string{"name": ".."}, "", true},
+		{"slash in name", defaultSidecarSOCKSPath, map[string]string{"name": "a/b"}, "", true},
+		{"unknown placeholder", "/run/{bogus}/socks.sock", vars, "", true},
+		{"relative", "run/{name}.sock", vars, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := renderSidecarPath(tt.template, tt.vars)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("Expected error, got path %q", path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if path != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, path)
+			}
+		})
+	}
+}
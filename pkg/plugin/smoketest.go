@@ -0,0 +1,293 @@
+// Package plugin provides the startup smoke test.
+//
+// When enabled, the plugin checks the whole exposure path once after it
+// starts: a tiny echo service on loopback is exposed through a server tunnel
+// of a throwaway destination, a client tunnel of a second throwaway
+// destination dials it, and a nonce is sent around. The outcome is logged,
+// served at GET /v1/smoke-test and exported as metrics, so operators learn
+// right away whether SAM, tunnel creation and stream forwarding work on this
+// host instead of when the first container fails to be reached.
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/metrics"
+)
+
+// Smoke test statuses.
+const (
+	SmokeTestRunning = "running"
+	SmokeTestPassed  = "passed"
+	SmokeTestFailed  = "failed"
+)
+
+const (
+	// smokeTestServerOwner owns the destination the echo service is exposed on
+	smokeTestServerOwner = "smoke-test-server"
+	// smokeTestClientOwner owns the destination the echo service is dialed from
+	smokeTestClientOwner = "smoke-test-client"
+	// smokeTestPort is the I2P port the echo service is exposed on
+	smokeTestPort = 80
+	// smokeTestTimeout bounds the whole smoke test; new destinations can take
+	// minutes to become reachable on a freshly started router
+	smokeTestTimeout = 3 * time.Minute
+	// smokeTestRetryInterval is the pause between failed round trips
+	smokeTestRetryInterval = 5 * time.Second
+)
+
+// AdminSmokeTest describes the outcome of the startup smoke test.
+type AdminSmokeTest struct {
+	// Status is "running", "passed" or "failed"
+	Status string `json:"status"`
+	// StartedAt is when the smoke test started
+	StartedAt time.Time `json:"started_at"`
+	// FinishedAt is when the smoke test finished (unset while running)
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// RoundTrip is the time from dialing the echo service to receiving the
+	// echoed nonce, e.g. "2.5s" (set when passed)
+	RoundTrip string `json:"round_trip,omitempty"`
+	// Attempts is the number of round trips tried
+	Attempts int `json:"attempts"`
+	// Error is why the smoke test failed
+	Error string `json:"error,omitempty"`
+}
+
+// smokeTestState holds whether the smoke test runs and its latest outcome.
+type smokeTestState struct {
+	mutex     sync.Mutex
+	enabled   bool
+	result    *AdminSmokeTest
+	roundTrip time.Duration
+}
+
+// SetSmokeTest enables or disables the startup smoke test. It must be called
+// before Start.
+func (p *Plugin) SetSmokeTest(enabled bool) {
+	p.smokeTest.mutex.Lock()
+	defer p.smokeTest.mutex.Unlock()
+	p.smokeTest.enabled = enabled
+}
+
+// SmokeTestResult returns the outcome of the startup smoke test, or false if
+// it has not started.
+func (p *Plugin) SmokeTestResult() (AdminSmokeTest, bool) {
+	p.smokeTest.mutex.Lock()
+	defer p.smokeTest.mutex.Unlock()
+
+	if p.smokeTest.result == nil {
+		return AdminSmokeTest{}, false
+	}
+	return *p.smokeTest.result, true
+}
+
+// smokeTestEnabled reports whether the smoke test runs at startup.
+func (p *Plugin) smokeTestEnabled() bool {
+	p.smokeTest.mutex.Lock()
+	defer p.smokeTest.mutex.Unlock()
+	return p.smokeTest.enabled
+}
+
+// recordSmokeTest stores the latest smoke test outcome.
+func (p *Plugin) recordSmokeTest(result AdminSmokeTest, roundTrip time.Duration) {
+	p.smokeTest.mutex.Lock()
+	defer p.smokeTest.mutex.Unlock()
+	p.smokeTest.result = &result
+	p.smokeTest.roundTrip = roundTrip
+}
+
+// runSmokeTest runs the smoke test and records its outcome.
+func (p *Plugin) runSmokeTest(ctx context.Context) {
+	started := time.Now()
+	p.recordSmokeTest(AdminSmokeTest{Status: SmokeTestRunning, StartedAt: started}, 0)
+	log.Printf("Running startup smoke test")
+
+	roundTrip, attempts, err := p.smokeTestRoundTrip(ctx)
+
+	finished := time.Now()
+	result := AdminSmokeTest{StartedAt: started, FinishedAt: &finished, Attempts: attempts}
+	if err != nil {
+		result.Status = SmokeTestFailed
+		result.Error = err.Error()
+		log.Printf("Warning: Startup smoke test failed after %s: %v", finished.Sub(started).Round(time.Millisecond), err)
+	} else {
+		result.Status = SmokeTestPassed
+		result.RoundTrip = roundTrip.Round(time.Millisecond).String()
+		log.Printf("Startup smoke test passed on attempt %d: round trip %s", attempts, result.RoundTrip)
+	}
+	p.recordSmokeTest(result, roundTrip)
+}
+
+// smokeTestRoundTrip exposes an echo service over I2P and dials it back,
+// retrying until a nonce comes back or the smoke test times out. The tunnels
+// and sessions it creates are destroyed before it returns.
+func (p *Plugin) smokeTestRoundTrip(ctx context.Context) (roundTrip time.Duration, attempts int, err error) {
+	// A broken SAM library must fail the smoke test, not the plugin
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, smokeTestTimeout)
+	defer cancel()
+
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to start echo service: %w", err)
+	}
+	defer echo.Close()
+	go serveEcho(echo)
+
+	tm := p.networkMgr.tunnelMgr
+	defer tm.DestroyContainerSession(smokeTestServerOwner)
+	defer tm.DestroyContainerSession(smokeTestClientOwner)
+
+	purpose := fmt.Sprintf("server-%d", smokeTestPort)
+	server, err := tm.CreateTunnel(&i2p.TunnelConfig{
+		Name:        i2p.TunnelName(smokeTestServerOwner, purpose),
+		ContainerID: smokeTestServerOwner,
+		Purpose:     purpose,
+		Type:        i2p.TunnelTypeServer,
+		LocalPort:   smokeTestPort,
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create server tunnel: %w", err)
+	}
+	defer tm.DestroyTunnel(server.GetConfig().Name)
+
+	streams, err := server.Listen()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer streams.Close()
+	go forwardSmokeTestStreams(streams, echo.Addr().String())
+
+	client, err := tm.CreateClientTunnelTo(smokeTestClientOwner, server.GetDestination(), smokeTestPort)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create client tunnel: %w", err)
+	}
+	defer tm.DestroyTunnel(client.GetConfig().Name)
+
+	for {
+		attempts++
+		roundTrip, err = smokeTestExchange(ctx, client)
+		if err == nil {
+			return roundTrip, attempts, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return 0, attempts, fmt.Errorf("no round trip after %d attempts: %w", attempts, err)
+		case <-time.After(smokeTestRetryInterval):
+		}
+	}
+}
+
+// smokeTestExchange dials the echo service through the client tunnel, sends
+// a nonce and waits for it to come back.
+func smokeTestExchange(ctx context.Context, client *i2p.Tunnel) (time.Duration, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return 0, err
+	}
+	line := hex.EncodeToString(nonce) + "\n"
+
+	started := time.Now()
+	conn, err := client.Connect()
+	if err != nil {
+		return 0, fmt.Errorf("failed to dial echo service: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := io.WriteString(conn, line); err != nil {
+		return 0, fmt.Errorf("failed to send nonce: %w", err)
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return 0, fmt.Errorf("failed to read echo: %w", err)
+	}
+	if reply != line {
+		return 0, fmt.Errorf("echo service returned %q, want %q", reply, line)
+	}
+	return time.Since(started), nil
+}
+
+// forwardSmokeTestStreams forwards I2P streams accepted on the server tunnel
+// to the echo service until the listener is closed.
+func forwardSmokeTestStreams(streams net.Listener, target string) {
+	for {
+		stream, err := streams.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer stream.Close()
+			local, err := net.Dial("tcp", target)
+			if err != nil {
+				log.Printf("Warning: Smoke test failed to reach echo service: %v", err)
+				return
+			}
+			defer local.Close()
+			go io.Copy(local, stream)
+			io.Copy(stream, local)
+		}()
+	}
+}
+
+// serveEcho echoes every connection accepted on listener until it is closed.
+func serveEcho(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			io.Copy(conn, conn)
+		}()
+	}
+}
+
+// smokeTestSamples returns a collect function reporting the finished smoke
+// test's outcome; nothing is reported before it finishes.
+func (p *Plugin) smokeTestSamples(value func(result AdminSmokeTest, roundTrip time.Duration) (float64, bool)) func() []metrics.Sample {
+	return func() []metrics.Sample {
+		p.smokeTest.mutex.Lock()
+		defer p.smokeTest.mutex.Unlock()
+
+		result := p.smokeTest.result
+		if result == nil || result.Status == SmokeTestRunning {
+			return nil
+		}
+		v, ok := value(*result, p.smokeTest.roundTrip)
+		if !ok {
+			return nil
+		}
+		return []metrics.Sample{{Value: v}}
+	}
+}
+
+// handleAdminSmokeTest returns the outcome of the startup smoke test.
+func (p *Plugin) handleAdminSmokeTest(w http.ResponseWriter, r *http.Request) {
+	result, ok := p.SmokeTestResult()
+	if !ok {
+		p.writeAdminError(w, http.StatusNotFound, "startup smoke test is disabled")
+		return
+	}
+	p.writeJSONResponse(w, result)
+}
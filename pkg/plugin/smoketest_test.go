@@ -0,0 +1,94 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+func TestAdminSmokeTest(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+
+	if w := adminGet(t, mux, "/v1/smoke-test", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 before the smoke test ran, got %d", w.Code)
+	}
+
+	finished := time.Now()
+	p.recordSmokeTest(AdminSmokeTest{Status: SmokeTestPassed, StartedAt: finished.Add(-3 * time.Second), FinishedAt: &finished, RoundTrip: "2.5s", Attempts: 2}, 2500*time.Millisecond)
+
+	var result AdminSmokeTest
+	adminGet(t, mux, "/v1/smoke-test", &result)
+	if result.Status != SmokeTestPassed || result.RoundTrip != "2.5s" || result.Attempts != 2 || result.FinishedAt == nil {
+		t.Errorf("Unexpected smoke test result %+v", result)
+	}
+
+	var out bytes.Buffer
+	p.metrics.WritePrometheus(&out)
+	for _, want := range []string{"i2p_smoke_test_success 1\n", "i2p_smoke_test_round_trip_seconds 2.5\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, out.String())
+		}
+	}
+
+	p.recordSmokeTest(AdminSmokeTest{Status: SmokeTestFailed, StartedAt: finished, FinishedAt: &finished, Error: "boom"}, 0)
+	out.Reset()
+	p.metrics.WritePrometheus(&out)
+	if !strings.Contains(out.String(), "i2p_smoke_test_success 0\n") || strings.Contains(out.String(), "i2p_smoke_test_round_trip_seconds 0") {
+		t.Errorf("Expected a failed smoke test to report success 0 and no round trip, got:\n%s", out.String())
+	}
+}
+
+func TestSmokeTestOverFakeSAM(t *testing.T) {
+	t.Parallel()
+
+	p, _ := newAdminTestPlugin(t)
+	p.networkMgr.tunnelMgr = createMockTunnelManager(t)
+	p.runSmokeTest(context.Background())
+
+	result, ok := p.SmokeTestResult()
+	if !ok {
+		t.Fatal("Expected the smoke test result to be recorded")
+	}
+	// go-sam-go v0.33.0 stream listeners panic on toolchains newer than the
+	// pinned one (see GOTOOLCHAIN in the Makefile)
+	if strings.HasPrefix(result.Error, "panic:") {
+		t.Fatalf("Server tunnel panicked, run the tests with the pinned Go toolchain: %s", result.Error)
+	}
+	if result.Status != SmokeTestPassed || result.Attempts != 1 || result.RoundTrip == "" {
+		t.Errorf("Unexpected smoke test result %+v", result)
+	}
+	if tunnels := p.networkMgr.tunnelMgr.ListTunnels(); len(tunnels) != 0 {
+		t.Errorf("Expected the smoke test's tunnels to be destroyed, got %d", len(tunnels))
+	}
+}
+
+func TestSmokeTestWithoutSAM(t *testing.T) {
+	// Reserve a port nothing listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve a port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	p, _ := newAdminTestPlugin(t)
+	host, portText, _ := net.SplitHostPort(address)
+	port, _ := strconv.Atoi(portText)
+	p.networkMgr.tunnelMgr = i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: &i2p.SAMConfig{Host: host, Port: port, Timeout: 5 * time.Second}})
+	p.runSmokeTest(context.Background())
+
+	result, _ := p.SmokeTestResult()
+	if result.Status != SmokeTestFailed || !strings.Contains(result.Error, "server tunnel") || result.FinishedAt == nil {
+		t.Errorf("Unexpected smoke test result %+v", result)
+	}
+	if sessions := p.networkMgr.tunnelMgr.ListContainerSessions(); len(sessions) != 0 {
+		t.Errorf("Expected no sessions left behind, got %v", sessions)
+	}
+}
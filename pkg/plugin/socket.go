@@ -0,0 +1,102 @@
+// Package plugin provides Unix socket ownership and permission handling.
+//
+// This file implements the permission settings applied to the plugin and
+// admin sockets after they are created, so deployments where Docker runs
+// under a non-root group can connect without manual chmod after restarts.
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+)
+
+// SocketPermissions defines the mode and ownership of a Unix socket.
+type SocketPermissions struct {
+	// Mode is the permission mode applied to the socket file
+	Mode os.FileMode
+	// Owner is a user name or numeric UID (empty keeps the process user)
+	Owner string
+	// Group is a group name or numeric GID (empty keeps the process group)
+	Group string
+}
+
+// DefaultSocketPermissions returns owner-only permissions for plugin sockets.
+func DefaultSocketPermissions() SocketPermissions {
+	return SocketPermissions{Mode: 0600}
+}
+
+// SetSocketPermissions sets the mode and ownership of the plugin socket.
+//
+// Must be called before Start.
+func (p *Plugin) SetSocketPermissions(perms SocketPermissions) {
+	p.sockPerms = perms
+}
+
+// SetAdminSocketPermissions sets the mode and ownership of the admin socket.
+//
+// Must be called before Start.
+func (p *Plugin) SetAdminSocketPermissions(perms SocketPermissions) {
+	p.adminSockPerms = perms
+}
+
+// apply sets ownership and then the mode on the socket at path.
+//
+// Ownership is changed first so the mode never briefly grants access to
+// the previous group.
+func (perms SocketPermissions) apply(path string) error {
+	uid, gid, err := perms.resolveOwnership()
+	if err != nil {
+		return err
+	}
+
+	if uid != -1 || gid != -1 {
+		if err := os.Chown(path, uid, gid); err != nil {
+			return fmt.Errorf("failed to set socket ownership: %w", err)
+		}
+	}
+
+	if err := os.Chmod(path, perms.Mode); err != nil {
+		return fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+
+	return nil
+}
+
+// resolveOwnership converts Owner and Group to numeric IDs.
+//
+// Returns -1 for fields that are not set, which os.Chown leaves unchanged.
+func (perms SocketPermissions) resolveOwnership() (int, int, error) {
+	uid, gid := -1, -1
+
+	if perms.Owner != "" {
+		id, err := strconv.Atoi(perms.Owner)
+		if err != nil {
+			u, lookupErr := user.Lookup(perms.Owner)
+			if lookupErr != nil {
+				return 0, 0, fmt.Errorf("unknown socket owner %q: %w", perms.Owner, lookupErr)
+			}
+			if id, err = strconv.Atoi(u.Uid); err != nil {
+				return 0, 0, fmt.Errorf("non-numeric UID %q for user %s", u.Uid, perms.Owner)
+			}
+		}
+		uid = id
+	}
+
+	if perms.Group != "" {
+		id, err := strconv.Atoi(perms.Group)
+		if err != nil {
+			g, lookupErr := user.LookupGroup(perms.Group)
+			if lookupErr != nil {
+				return 0, 0, fmt.Errorf("unknown socket group %q: %w", perms.Group, lookupErr)
+			}
+			if id, err = strconv.Atoi(g.Gid); err != nil {
+				return 0, 0, fmt.Errorf("non-numeric GID %q for group %s", g.Gid, perms.Group)
+			}
+		}
+		gid = id
+	}
+
+	return uid, gid, nil
+}
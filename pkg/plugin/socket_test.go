@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestSocketPermissionsApply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to create socket: %v", err)
+	}
+	defer listener.Close()
+
+	// Use the current IDs so the test works without privileges
+	perms := SocketPermissions{
+		Mode:  0660,
+		Owner: strconv.Itoa(os.Getuid()),
+		Group: strconv.Itoa(os.Getgid()),
+	}
+	if err := perms.apply(path); err != nil {
+		t.Fatalf("Failed to apply socket permissions: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat socket: %v", err)
+	}
+	if info.Mode().Perm() != 0660 {
+		t.Errorf("Expected mode 0660, got %o", info.Mode().Perm())
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		if int(stat.Gid) != os.Getgid() {
+			t.Errorf("Expected GID %d, got %d", os.Getgid(), stat.Gid)
+		}
+	}
+}
+
+func TestSocketPermissionsResolveOwnership(t *testing.T) {
+	uid, gid, err := SocketPermissions{}.resolveOwnership()
+	if err != nil || uid != -1 || gid != -1 {
+		t.Errorf("Expected unset ownership, got %d:%d (%v)", uid, gid, err)
+	}
+
+	uid, gid, err = SocketPermissions{Owner: "1000", Group: "999"}.resolveOwnership()
+	if err != nil || uid != 1000 || gid != 999 {
+		t.Errorf("Expected 1000:999, got %d:%d (%v)", uid, gid, err)
+	}
+
+	uid, _, err = SocketPermissions{Owner: "root"}.resolveOwnership()
+	if err != nil || uid != 0 {
+		t.Errorf("Expected root to resolve to UID 0, got %d (%v)", uid, err)
+	}
+
+	if _, _, err := (SocketPermissions{Owner: "no-such-user-i2p"}).resolveOwnership(); err == nil {
+		t.Error("Expected error for unknown owner")
+	}
+	if _, _, err := (SocketPermissions{Group: "no-such-group-i2p"}).resolveOwnership(); err == nil {
+		t.Error("Expected error for unknown group")
+	}
+}
@@ -0,0 +1,81 @@
+// Package plugin provides persistence of traffic statistics.
+//
+// When a statistics path is configured, the proxy's traffic counters and
+// per-container daily usage are loaded from it on start, saved on an interval
+// while the plugin runs and saved once more during shutdown, after the proxy
+// has stopped and every connection has been accounted.
+package plugin
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+// statsPersistence configures where and how often traffic statistics are saved.
+type statsPersistence struct {
+	path     string
+	interval time.Duration
+}
+
+// SetStatsPersistence sets the file traffic statistics are persisted to and
+// how often they are saved. An empty path disables persistence.
+//
+// Must be called before Start so persisted statistics are loaded.
+func (p *Plugin) SetStatsPersistence(path string, interval time.Duration) {
+	p.stats = statsPersistence{path: path, interval: interval}
+}
+
+// loadTrafficStats restores persisted traffic statistics.
+//
+// A missing file is not an error; the file is created on the first save.
+func (p *Plugin) loadTrafficStats() error {
+	if p.stats.path == "" {
+		return nil
+	}
+
+	snapshot, err := proxy.LoadTrafficSnapshot(p.stats.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	p.networkMgr.proxyMgr.RestoreTrafficSnapshot(snapshot)
+	log.Printf("Restored traffic statistics saved at %s from %s", snapshot.SavedAt.Format(time.RFC3339), p.stats.path)
+	return nil
+}
+
+// saveTrafficStats writes the current traffic statistics to disk.
+func (p *Plugin) saveTrafficStats() error {
+	if p.stats.path == "" {
+		return nil
+	}
+	return proxy.SaveTrafficSnapshot(p.stats.path, p.networkMgr.proxyMgr.TrafficSnapshot())
+}
+
+// snapshotTrafficStats saves traffic statistics every interval until ctx is done.
+func (p *Plugin) snapshotTrafficStats(ctx context.Context) {
+	if p.stats.path == "" || p.stats.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(p.stats.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.saveTrafficStats(); err != nil {
+				log.Printf("Warning: Failed to save traffic statistics: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrafficStatsPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+
+	p, _ := newAdminTestPlugin(t)
+	p.SetStatsPersistence(path, time.Minute)
+	if err := p.loadTrafficStats(); err != nil {
+		t.Fatalf("Expected missing statistics file to be ignored, got %v", err)
+	}
+
+	p.networkMgr.proxyMgr.GetTrafficFilter().RecordUsage("container1", 1500)
+	if err := p.saveTrafficStats(); err != nil {
+		t.Fatalf("Failed to save traffic statistics: %v", err)
+	}
+
+	// A restarted plugin picks up the saved usage
+	restarted, mux := newAdminTestPlugin(t)
+	restarted.SetStatsPersistence(path, time.Minute)
+	if err := restarted.loadTrafficStats(); err != nil {
+		t.Fatalf("Failed to load traffic statistics: %v", err)
+	}
+
+	var usage []AdminUsage
+	w := adminGet(t, mux, "/v1/usage?container=container", &usage)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if len(usage) != 1 || usage[0].ContainerID != "container1" || usage[0].BytesTransferred != 1500 {
+		t.Errorf("Unexpected usage after restart: %+v", usage)
+	}
+
+	adminGet(t, mux, "/v1/usage?day=2000-01-01", &usage)
+	if len(usage) != 0 {
+		t.Errorf("Expected no usage for another day, got %+v", usage)
+	}
+
+	// Persistence is disabled without a path
+	disabled, _ := newAdminTestPlugin(t)
+	if err := disabled.saveTrafficStats(); err != nil {
+		t.Errorf("Expected saving without a path to be a no-op, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+// Package plugin provides the default subnet pool.
+//
+// Networks created without IPAM data, and pools the IPAM driver hands out
+// without a requested subnet, get a /24 of their own carved out of the
+// default subnet (IPAM_SUBNET, 172.20.0.0/16 unless configured). A /24 is
+// free while no network or IPAM pool overlaps it, so deleting a network or
+// releasing its pool returns its /24 to the pool without further
+// bookkeeping, including for networks restored from the state file.
+package plugin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+// DefaultSubnet is the default subnet networks are carved out of.
+const DefaultSubnet = "172.20.0.0/16"
+
+// defaultSubnetPrefix is the prefix length of the subnets carved out of the
+// default subnet.
+const defaultSubnetPrefix = 24
+
+// SetDefaultSubnet sets the IPv4 subnet whose /24s are handed to networks
+// created without IPAM data. The first /24 is left out, so the default pool
+// 172.20.0.0/16 hands out 172.20.1.0/24 first. Container traffic of the
+// subnet is intercepted, so it must not overlap the synthetic DNS range. It
+// must be called before any network is created.
+func (nm *NetworkManager) SetDefaultSubnet(cidr string) error {
+	_, subnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return fmt.Errorf("invalid default subnet: %w", err)
+	}
+	ones, bits := subnet.Mask.Size()
+	if bits != 32 || ones > defaultSubnetPrefix-1 {
+		return fmt.Errorf("default subnet %s must be an IPv4 subnet of at least two /%d subnets", subnet, defaultSubnetPrefix)
+	}
+	_, syntheticRange, _ := net.ParseCIDR(proxy.SyntheticIPRange)
+	if subnetsOverlap(subnet, syntheticRange) {
+		return fmt.Errorf("default subnet %s overlaps the I2P DNS synthetic address range %s", subnet, syntheticRange)
+	}
+
+	nm.mutex.Lock()
+	defer nm.mutex.Unlock()
+
+	if len(nm.networks) > 0 || len(nm.ipamPools) > 0 {
+		return fmt.Errorf("default subnet cannot be changed while networks exist")
+	}
+	nm.proxyMgr.SetContainerSubnet(subnet)
+	nm.defaultSubnet = subnet
+	return nil
+}
+
+// DefaultSubnet returns the subnet networks without IPAM data are carved out
+// of.
+func (nm *NetworkManager) DefaultSubnet() *net.IPNet {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	return nm.defaultSubnet
+}
+
+// nextDefaultSubnetLocked returns the first free /24 within the default
+// subnet, skipping the first. The caller must hold nm.mutex.
+func (nm *NetworkManager) nextDefaultSubnetLocked() (*net.IPNet, error) {
+	ones, _ := nm.defaultSubnet.Mask.Size()
+	for i := 1; i < 1<<(defaultSubnetPrefix-ones); i++ {
+		subnet := carveSubnet(nm.defaultSubnet, i)
+		if nm.checkSubnetConflicts(subnet) == nil {
+			return subnet, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no free /%d subnet left in default subnet %s", defaultSubnetPrefix, nm.defaultSubnet)
+}
+
+// carveSubnet returns the index-th /24 of an IPv4 subnet.
+func carveSubnet(base *net.IPNet, index int) *net.IPNet {
+	start := binary.BigEndian.Uint32(base.IP.To4())
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, start+uint32(index)<<(32-defaultSubnetPrefix))
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(defaultSubnetPrefix, 32)}
+}
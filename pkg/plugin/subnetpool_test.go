@@ -0,0 +1,37 @@
+package plugin
+
+import (
+	"net"
+	"testing"
+)
+
+func TestNetworkManager_SetDefaultSubnet(t *testing.T) {
+	nm := createMockNetworkManager(t)
+
+	for _, cidr := range []string{"bogus", "fd00::/48", "10.0.0.0/24", "198.18.0.0/16"} {
+		if err := nm.SetDefaultSubnet(cidr); err == nil {
+			t.Errorf("Expected default subnet %s to be rejected", cidr)
+		}
+	}
+
+	if err := nm.SetDefaultSubnet("10.64.0.0/12"); err != nil {
+		t.Fatalf("SetDefaultSubnet() unexpected error: %v", err)
+	}
+	if subnet := nm.proxyMgr.GetConfig().ContainerSubnet; subnet.String() != "10.64.0.0/12" {
+		t.Errorf("Expected the default subnet to be intercepted, got %s", subnet)
+	}
+
+	// /24s are carved across octet boundaries
+	nm.mutex.Lock()
+	_, taken, _ := net.ParseCIDR("10.64.0.0/16")
+	nm.addNetworkLocked(&I2PNetwork{ID: "net1", Subnet: taken, Endpoints: map[string]*I2PEndpoint{}, TunnelManager: nm.tunnelMgr})
+	subnet, err := nm.nextDefaultSubnetLocked()
+	nm.mutex.Unlock()
+	if err != nil || subnet.String() != "10.65.0.0/24" {
+		t.Errorf("Expected 10.65.0.0/24 next to a network on 10.64.0.0/16, got %v (%v)", subnet, err)
+	}
+
+	if err := nm.SetDefaultSubnet("10.0.0.0/16"); err == nil {
+		t.Error("Expected the default subnet to be fixed once networks exist")
+	}
+}
@@ -0,0 +1,341 @@
+// Package plugin provides partial shutdown of the plugin's subsystems.
+//
+// The SOCKS proxy, the DNS resolver, the service exposures and the proxy
+// listeners of a single network can be stopped and started through the admin
+// API while everything else keeps running, e.g. to recover a wedged resolver
+// without restarting the plugin and detaching every container. Networks that
+// rely on a stopped subsystem are reported as degraded instead of breaking
+// silently.
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Subsystems that can be stopped and started on their own.
+const (
+	// SubsystemProxy is the SOCKS proxy serving every network
+	SubsystemProxy = "proxy"
+	// SubsystemDNS is the DNS resolver serving every network
+	SubsystemDNS = "dns"
+	// SubsystemExposures are the service exposures of all containers
+	SubsystemExposures = "exposures"
+)
+
+// Subsystem states.
+const (
+	SubsystemRunning = "running"
+	SubsystemStopped = "stopped"
+)
+
+// Network statuses.
+const (
+	// NetworkActive is a network whose subsystems are all running
+	NetworkActive = "active"
+	// NetworkDegraded is a network relying on a stopped subsystem
+	NetworkDegraded = "degraded"
+	// NetworkStopped is a network whose proxy listeners are stopped
+	NetworkStopped = "stopped"
+)
+
+// Subsystems returns the names of the subsystems that can be stopped.
+func Subsystems() []string {
+	return []string{SubsystemProxy, SubsystemDNS, SubsystemExposures}
+}
+
+// AdminSubsystem describes a subsystem and the networks relying on it.
+type AdminSubsystem struct {
+	// Name is the subsystem name: proxy, dns or exposures
+	Name string `json:"name"`
+	// State is either "running" or "stopped"
+	State string `json:"state"`
+	// Dependents are the IDs of the networks relying on the subsystem
+	Dependents []string `json:"dependents"`
+}
+
+// isSubsystem reports whether name is a known subsystem.
+func isSubsystem(name string) bool {
+	for _, subsystem := range Subsystems() {
+		if subsystem == name {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownSubsystemError returns the error for an unknown subsystem name.
+func unknownSubsystemError(name string) error {
+	return fmt.Errorf("unknown subsystem %q (must be %s)", name, strings.Join(Subsystems(), ", "))
+}
+
+// SubsystemRunning reports whether a subsystem is running.
+func (nm *NetworkManager) SubsystemRunning(name string) bool {
+	switch name {
+	case SubsystemProxy:
+		return nm.proxyMgr.SOCKSRunning()
+	case SubsystemDNS:
+		return nm.proxyMgr.DNSRunning()
+	case SubsystemExposures:
+		return nm.serviceMgr.Running()
+	}
+	return false
+}
+
+// StopSubsystem stops a subsystem while the rest of the plugin keeps running.
+//
+// Networks relying on the subsystem are degraded until it is started again,
+// which is logged as a warning. Stopping a stopped subsystem does nothing.
+func (nm *NetworkManager) StopSubsystem(name string) error {
+	if !isSubsystem(name) {
+		return unknownSubsystemError(name)
+	}
+	if !nm.SubsystemRunning(name) {
+		return nil
+	}
+
+	var err error
+	switch name {
+	case SubsystemProxy:
+		err = nm.proxyMgr.StopSOCKS()
+	case SubsystemDNS:
+		err = nm.proxyMgr.StopDNS()
+	case SubsystemExposures:
+		err = nm.serviceMgr.Stop()
+		nm.clearEndpointExposures()
+	}
+
+	log.Printf("Stopped %s subsystem", name)
+	if dependents := nm.subsystemDependents(name); len(dependents) > 0 {
+		log.Printf("Warning: Networks %s are degraded while the %s subsystem is stopped",
+			strings.Join(dependents, ", "), name)
+	}
+	return err
+}
+
+// StartSubsystem starts a subsystem stopped with StopSubsystem.
+//
+// Starting a running subsystem does nothing.
+func (nm *NetworkManager) StartSubsystem(name string) error {
+	if !isSubsystem(name) {
+		return unknownSubsystemError(name)
+	}
+	if nm.SubsystemRunning(name) {
+		return nil
+	}
+
+	var err error
+	switch name {
+	case SubsystemProxy:
+		err = nm.proxyMgr.StartSOCKS()
+	case SubsystemDNS:
+		err = nm.proxyMgr.StartDNS()
+	case SubsystemExposures:
+		// Restored exposures reach their endpoints through the ready handler
+		err = nm.serviceMgr.Start()
+	}
+
+	log.Printf("Started %s subsystem", name)
+	return err
+}
+
+// clearEndpointExposures forgets the exposures of every endpoint after the
+// exposures subsystem has been stopped.
+func (nm *NetworkManager) clearEndpointExposures() {
+	for _, networkID := range nm.ListNetworks() {
+		network := nm.GetNetwork(networkID)
+		if network == nil {
+			continue
+		}
+
+		network.mutex.Lock()
+		for _, endpoint := range network.Endpoints {
+			endpoint.ServiceExposures = nil
+		}
+		network.mutex.Unlock()
+	}
+}
+
+// StopNetwork stops the SOCKS proxy and DNS resolver listeners of a network.
+//
+// Containers stay attached and their exposures keep serving, but they cannot
+// reach I2P through the proxy until StartNetwork is called. Stopping a
+// stopped network does nothing.
+func (nm *NetworkManager) StopNetwork(network *I2PNetwork) error {
+	network.mutex.Lock()
+	defer network.mutex.Unlock()
+
+	if network.Stopped {
+		return nil
+	}
+	network.Stopped = true
+
+	if err := nm.proxyMgr.RemoveNetworkListeners(network.ID); err != nil {
+		return err
+	}
+	log.Printf("Stopped proxy listeners of network %s", network.ID)
+	return nil
+}
+
+// StartNetwork starts the proxy listeners of a network stopped with
+// StopNetwork. The network stays stopped if they cannot be started.
+//
+// Starting a running network does nothing.
+func (nm *NetworkManager) StartNetwork(network *I2PNetwork) error {
+	network.mutex.Lock()
+	defer network.mutex.Unlock()
+
+	if !network.Stopped {
+		return nil
+	}
+
+	if err := nm.proxyMgr.AddNetworkListeners(network.ID, network.Subnet, network.ProxyBindIP); err != nil {
+		return fmt.Errorf("failed to start proxy listeners for network %s: %w", network.ID, err)
+	}
+	network.Stopped = false
+
+	log.Printf("Started proxy listeners of network %s", network.ID)
+	return nil
+}
+
+// networkDependsOn reports whether a network relies on a subsystem.
+//
+// Every network with running listeners relies on the proxy and the DNS
+// resolver; only networks with exposed containers rely on exposures.
+func (nm *NetworkManager) networkDependsOn(network *I2PNetwork, name string) bool {
+	network.mutex.RLock()
+	defer network.mutex.RUnlock()
+
+	switch name {
+	case SubsystemProxy, SubsystemDNS:
+		return !network.Stopped
+	case SubsystemExposures:
+		for _, endpoint := range network.Endpoints {
+			if endpoint.State != EndpointJoined {
+				continue
+			}
+			if len(endpoint.ServiceExposures) > 0 || nm.serviceMgr.HeldExposures(endpoint.ContainerID) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// subsystemDependents returns the sorted IDs of the networks relying on a
+// subsystem.
+func (nm *NetworkManager) subsystemDependents(name string) []string {
+	nm.mutex.RLock()
+	defer nm.mutex.RUnlock()
+
+	dependents := []string{}
+	for networkID, network := range nm.networks {
+		if nm.networkDependsOn(network, name) {
+			dependents = append(dependents, networkID)
+		}
+	}
+
+	sort.Strings(dependents)
+	return dependents
+}
+
+// networkStatus returns the status of a network and the stopped subsystems
+// degrading it.
+func (nm *NetworkManager) networkStatus(network *I2PNetwork) (string, []string) {
+	network.mutex.RLock()
+	stopped := network.Stopped
+	network.mutex.RUnlock()
+
+	if stopped {
+		return NetworkStopped, nil
+	}
+
+	var degradedBy []string
+	for _, name := range Subsystems() {
+		if !nm.SubsystemRunning(name) && nm.networkDependsOn(network, name) {
+			degradedBy = append(degradedBy, name)
+		}
+	}
+	if len(degradedBy) > 0 {
+		return NetworkDegraded, degradedBy
+	}
+	return NetworkActive, nil
+}
+
+// adminNetworkView returns the admin view of a network with its status.
+func (nm *NetworkManager) adminNetworkView(network *I2PNetwork) AdminNetwork {
+	view := network.adminView()
+	view.Status, view.DegradedBy = nm.networkStatus(network)
+	view.ExternalServices = nm.adminExternalServices(network)
+	nm.addEndpointStats(network, view.Endpoints)
+	nm.addQueuedExposures(view.Endpoints)
+	return view
+}
+
+// adminSubsystems returns admin views of all subsystems.
+func (nm *NetworkManager) adminSubsystems() []AdminSubsystem {
+	subsystems := make([]AdminSubsystem, 0, len(Subsystems()))
+	for _, name := range Subsystems() {
+		subsystems = append(subsystems, nm.adminSubsystem(name))
+	}
+	return subsystems
+}
+
+// adminSubsystem returns the admin view of a subsystem.
+func (nm *NetworkManager) adminSubsystem(name string) AdminSubsystem {
+	state := SubsystemStopped
+	if nm.SubsystemRunning(name) {
+		state = SubsystemRunning
+	}
+	return AdminSubsystem{Name: name, State: state, Dependents: nm.subsystemDependents(name)}
+}
+
+// handleAdminSubsystems lists all subsystems.
+func (p *Plugin) handleAdminSubsystems(w http.ResponseWriter, r *http.Request) {
+	p.writeJSONResponse(w, p.networkMgr.adminSubsystems())
+}
+
+// handleAdminSubsystemAction returns a handler applying actions to the
+// subsystem named in the path, in order.
+func (p *Plugin) handleAdminSubsystemAction(actions ...func(name string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if !isSubsystem(name) {
+			p.writeAdminError(w, http.StatusNotFound, unknownSubsystemError(name).Error())
+			return
+		}
+
+		for _, action := range actions {
+			if err := action(name); err != nil {
+				p.writeAdminError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		p.writeJSONResponse(w, p.networkMgr.adminSubsystem(name))
+	}
+}
+
+// handleAdminNetworkAction returns a handler applying actions to the network
+// referenced in the path by ID or name, in order.
+func (p *Plugin) handleAdminNetworkAction(actions ...func(network *I2PNetwork) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ref := r.PathValue("id")
+
+		network := p.networkMgr.LookupNetwork(ref)
+		if network == nil {
+			p.writeAdminError(w, http.StatusNotFound, fmt.Sprintf("network %s not found", ref))
+			return
+		}
+
+		for _, action := range actions {
+			if err := action(network); err != nil {
+				p.writeAdminError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		p.writeJSONResponse(w, p.networkMgr.adminNetworkView(network))
+	}
+}
@@ -0,0 +1,106 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// adminAction sends a POST without body to the admin API and decodes the response.
+func adminAction(t *testing.T, mux *http.ServeMux, path string, v interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+
+	if v != nil && w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("Failed to decode %s response: %v", path, err)
+		}
+	}
+	return w
+}
+
+func TestAdminSubsystems(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+	p.networkMgr.GetNetwork("net1").ProxyBindIP = net.ParseIP("127.0.0.1")
+
+	var subsystems []AdminSubsystem
+	adminGet(t, mux, "/v1/subsystems", &subsystems)
+	if len(subsystems) != 3 {
+		t.Fatalf("Expected 3 subsystems, got %d", len(subsystems))
+	}
+	for _, subsystem := range subsystems {
+		if subsystem.State != SubsystemRunning {
+			t.Errorf("Expected %s to be running, got %s", subsystem.Name, subsystem.State)
+		}
+	}
+	if proxy := subsystems[0]; len(proxy.Dependents) != 1 || proxy.Dependents[0] != "net1" {
+		t.Errorf("Expected net1 to rely on the proxy, got %v", proxy.Dependents)
+	}
+
+	var network AdminNetwork
+	adminGet(t, mux, "/v1/networks/net1", &network)
+	if network.Status != NetworkActive {
+		t.Errorf("Expected network to be active, got %s", network.Status)
+	}
+
+	// Stopping the proxy degrades the network instead of breaking it silently
+	var subsystem AdminSubsystem
+	if w := adminAction(t, mux, "/v1/subsystems/proxy/stop", &subsystem); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if subsystem.State != SubsystemStopped {
+		t.Errorf("Expected proxy to be stopped, got %s", subsystem.State)
+	}
+	if w := adminAction(t, mux, "/v1/subsystems/proxy/stop", nil); w.Code != http.StatusOK {
+		t.Errorf("Expected stopping a stopped subsystem to succeed, got %d", w.Code)
+	}
+	adminGet(t, mux, "/v1/networks/net1", &network)
+	if network.Status != NetworkDegraded || len(network.DegradedBy) != 1 || network.DegradedBy[0] != SubsystemProxy {
+		t.Errorf("Expected network to be degraded by the proxy, got %s %v", network.Status, network.DegradedBy)
+	}
+
+	if w := adminAction(t, mux, "/v1/subsystems/router/stop", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown subsystem, got %d", w.Code)
+	}
+
+	// Listeners of a network restarted while the proxy and resolver are
+	// stopped come back without binding
+	adminAction(t, mux, "/v1/subsystems/dns/stop", nil)
+	var stopped AdminNetwork
+	if w := adminAction(t, mux, "/v1/networks/net1/stop", &stopped); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if stopped.Status != NetworkStopped || stopped.DegradedBy != nil {
+		t.Errorf("Expected network to be stopped, got %s %v", stopped.Status, stopped.DegradedBy)
+	}
+	adminGet(t, mux, "/v1/subsystems", &subsystems)
+	if len(subsystems[0].Dependents) != 0 {
+		t.Errorf("Expected stopped networks not to rely on the proxy, got %v", subsystems[0].Dependents)
+	}
+
+	if w := adminAction(t, mux, "/v1/networks/i2p-test/restart", &network); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if network.Status != NetworkDegraded || len(network.DegradedBy) != 2 {
+		t.Errorf("Expected network to be degraded by the proxy and DNS, got %s %v", network.Status, network.DegradedBy)
+	}
+	if w := adminAction(t, mux, "/v1/networks/unknown/stop", nil); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown network, got %d", w.Code)
+	}
+
+	// Stop the network again so the proxy does not bind fixed ports
+	adminAction(t, mux, "/v1/networks/net1/stop", nil)
+	for _, name := range []string{SubsystemProxy, SubsystemDNS} {
+		if w := adminAction(t, mux, "/v1/subsystems/"+name+"/start", &subsystem); w.Code != http.StatusOK || subsystem.State != SubsystemRunning {
+			t.Errorf("Expected %s to start, got %d %s", name, w.Code, subsystem.State)
+		}
+	}
+
+	if w := adminAction(t, mux, "/v1/subsystems/exposures/restart", &subsystem); w.Code != http.StatusOK || subsystem.State != SubsystemRunning {
+		t.Errorf("Expected exposures to restart, got %d %s", w.Code, subsystem.State)
+	}
+}
@@ -0,0 +1,145 @@
+// Package plugin provides tenant separation of network telemetry.
+//
+// Hosting providers running several customers' I2P networks on one host tag
+// each network with the tenant it belongs to through the i2p.tenant option.
+// The per-network metric families carry the network's name and tenant as
+// labels, log events naming a network are tagged with its ID, name and
+// tenant, and anomaly notifications name the network of the container. The
+// admin API's network, exposure, anomaly and log listings accept a tenant
+// filter, so each customer's telemetry can be separated without parsing
+// messages.
+//
+// Log lines are written while nm.mutex is held, so the tags are kept in a
+// snapshot that is replaced whenever a network is added or removed and read
+// without locking.
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TenantOption is the network option naming the tenant a network belongs to.
+const TenantOption = "i2p.tenant"
+
+// maxTenantLength bounds tenant names so they stay usable as metric labels.
+const maxTenantLength = 63
+
+// tenantPattern matches valid tenant names.
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// parseTenant returns the tenant selected by network options, or an empty
+// string if the network has none.
+func parseTenant(options map[string]interface{}) (string, error) {
+	switch value := options[TenantOption].(type) {
+	case nil:
+		return "", nil
+	case string:
+		tenant := strings.TrimSpace(value)
+		if tenant == "" {
+			return "", nil
+		}
+		if len(tenant) > maxTenantLength || !tenantPattern.MatchString(tenant) {
+			return "", fmt.Errorf("invalid %s value %q: expected up to %d letters, digits, '.', '_' or '-', starting with a letter or digit",
+				TenantOption, value, maxTenantLength)
+		}
+		return tenant, nil
+	default:
+		return "", fmt.Errorf("invalid %s value %v: expected a string", TenantOption, value)
+	}
+}
+
+// networkTag identifies a network in telemetry.
+type networkTag struct {
+	// ID is the Docker network ID
+	ID string
+	// Name is the human-readable network name
+	Name string
+	// Tenant is the tenant the network belongs to, if any
+	Tenant string
+}
+
+// refreshNetworkTagsLocked replaces the tag snapshot with the current
+// networks. The caller must hold nm.mutex.
+func (nm *NetworkManager) refreshNetworkTagsLocked() {
+	tags := make([]networkTag, 0, len(nm.networks))
+	for _, network := range nm.networks {
+		tags = append(tags, networkTag{ID: network.ID, Name: network.Name, Tenant: network.Tenant})
+	}
+	// Longer IDs first so an ID that is a prefix of another never shadows it
+	sort.Slice(tags, func(i, j int) bool {
+		if len(tags[i].ID) != len(tags[j].ID) {
+			return len(tags[i].ID) > len(tags[j].ID)
+		}
+		return tags[i].ID < tags[j].ID
+	})
+	nm.tags.Store(&tags)
+}
+
+// networkTags returns the tag snapshot.
+func (nm *NetworkManager) networkTags() []networkTag {
+	if tags := nm.tags.Load(); tags != nil {
+		return *tags
+	}
+	return nil
+}
+
+// networkTag returns the tag of a network. Unknown networks are tagged with
+// their ID only.
+func (nm *NetworkManager) networkTag(networkID string) networkTag {
+	for _, tag := range nm.networkTags() {
+		if tag.ID == networkID {
+			return tag
+		}
+	}
+	return networkTag{ID: networkID}
+}
+
+// messageTag returns the tag of the network whose ID a log message names.
+//
+// It does not lock nm.mutex, so it is safe to call while logging.
+func (nm *NetworkManager) messageTag(message string) (networkTag, bool) {
+	for _, tag := range nm.networkTags() {
+		if strings.Contains(message, tag.ID) {
+			return tag, true
+		}
+	}
+	return networkTag{}, false
+}
+
+// containerTag returns the tag of the first network, in ID order, a container
+// has an endpoint on.
+func (nm *NetworkManager) containerTag(containerID string) (networkTag, bool) {
+	nm.mutex.RLock()
+	networks := make([]*I2PNetwork, 0, len(nm.networks))
+	for _, network := range nm.networks {
+		networks = append(networks, network)
+	}
+	nm.mutex.RUnlock()
+	sort.Slice(networks, func(i, j int) bool { return networks[i].ID < networks[j].ID })
+
+	for _, network := range networks {
+		network.mutex.RLock()
+		for _, endpoint := range network.Endpoints {
+			if endpoint.ContainerID == containerID {
+				network.mutex.RUnlock()
+				return nm.networkTag(network.ID), true
+			}
+		}
+		network.mutex.RUnlock()
+	}
+	return networkTag{}, false
+}
+
+// tenantNetworks returns the IDs of the networks of tenant.
+func (nm *NetworkManager) tenantNetworks(tenant string) map[string]bool {
+	networkIDs := make(map[string]bool)
+	for _, tag := range nm.networkTags() {
+		if tag.Tenant == tenant {
+			networkIDs[tag.ID] = true
+		}
+	}
+	return networkIDs
+}
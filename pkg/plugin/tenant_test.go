@@ -0,0 +1,127 @@
+package plugin
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTenant(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  map[string]interface{}
+		expected string
+		wantErr  bool
+	}{
+		{name: "unset", options: map[string]interface{}{}},
+		{name: "empty", options: map[string]interface{}{TenantOption: " "}},
+		{name: "name", options: map[string]interface{}{TenantOption: "acme"}, expected: "acme"},
+		{name: "trimmed", options: map[string]interface{}{TenantOption: " customer-42.eu "}, expected: "customer-42.eu"},
+		{name: "invalid character", options: map[string]interface{}{TenantOption: "acme corp"}, wantErr: true},
+		{name: "leading dash", options: map[string]interface{}{TenantOption: "-acme"}, wantErr: true},
+		{name: "too long", options: map[string]interface{}{TenantOption: strings.Repeat("a", maxTenantLength+1)}, wantErr: true},
+		{name: "not a string", options: map[string]interface{}{TenantOption: 42.0}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tenant, err := parseTenant(tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTenant() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tenant != tt.expected {
+				t.Errorf("Expected tenant %q, got %q", tt.expected, tenant)
+			}
+		})
+	}
+}
+
+// addTenantNetwork adds a network of tenant acme with one joined endpoint to
+// the admin test plugin.
+func addTenantNetwork(p *Plugin) {
+	_, subnet, _ := net.ParseCIDR("172.20.2.0/24")
+	gateway := net.ParseIP("172.20.2.1")
+	p.networkMgr.addNetworkLocked(&I2PNetwork{
+		ID:      "net2",
+		Name:    "acme-net",
+		Tenant:  "acme",
+		Subnet:  subnet,
+		Gateway: gateway,
+		Endpoints: map[string]*I2PEndpoint{
+			"ep2": {ID: "ep2", NetworkID: "net2", State: EndpointJoined, ContainerID: "container2", IPAddress: net.ParseIP("172.20.2.2")},
+		},
+		IPAllocator:   NewIPAllocator(subnet, gateway),
+		TunnelManager: p.networkMgr.tunnelMgr,
+	})
+}
+
+func TestAdminTenantFiltering(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+	addTenantNetwork(p)
+
+	var networks []AdminNetwork
+	adminGet(t, mux, "/v1/networks?tenant=acme", &networks)
+	if len(networks) != 1 || networks[0].ID != "net2" || networks[0].Tenant != "acme" {
+		t.Errorf("Expected only the acme network, got %+v", networks)
+	}
+
+	adminGet(t, mux, "/v1/networks", &networks)
+	if len(networks) != 2 {
+		t.Errorf("Expected both networks without a tenant filter, got %d", len(networks))
+	}
+
+	adminGet(t, mux, "/v1/networks?tenant=other", &networks)
+	if len(networks) != 0 {
+		t.Errorf("Expected no networks of an unknown tenant, got %d", len(networks))
+	}
+
+	tag, ok := p.networkMgr.containerTag("container2")
+	if !ok || tag.Tenant != "acme" || tag.Name != "acme-net" {
+		t.Errorf("Expected container2 to be tagged with the acme network, got %+v (found %v)", tag, ok)
+	}
+}
+
+func TestTenantMetricsLabels(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+	addTenantNetwork(p)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, expected := range []string{
+		`i2p_network_endpoints{network="net2",name="acme-net",tenant="acme"} 1`,
+		`i2p_ipam_addresses_allocated{network="net2",name="acme-net",tenant="acme",strategy="sequential"} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), expected) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", expected, rec.Body.String())
+		}
+	}
+}
+
+func TestLogHubTenantTagging(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	addTenantNetwork(p)
+	p.logHub.tag = p.networkMgr.messageTag
+
+	sub := p.logHub.subscribe("", "", "acme")
+	defer p.logHub.unsubscribe(sub)
+
+	p.logHub.Write([]byte("Joining endpoint ep1 to network net1 for container container1\n"))
+	p.logHub.Write([]byte("Joining endpoint ep2 to network net2 for container container2\n"))
+	p.logHub.Write([]byte("Plugin started\n"))
+
+	if len(sub.events) != 1 {
+		t.Fatalf("Expected 1 event of tenant acme, got %d", len(sub.events))
+	}
+	event := <-sub.events
+	if event.NetworkID != "net2" || event.Network != "acme-net" || event.Tenant != "acme" {
+		t.Errorf("Expected the event to be tagged with the acme network, got %+v", event)
+	}
+
+	// Removed networks are no longer tagged
+	p.networkMgr.removeNetworkLocked(p.networkMgr.GetNetwork("net2"))
+	if _, ok := p.networkMgr.messageTag("network net2 deleted"); ok {
+		t.Error("Expected no tag for a removed network")
+	}
+}
@@ -0,0 +1,108 @@
+// Package plugin provides the plugin API over TCP with mutual TLS.
+//
+// Docker reaches plugins on its own host through their Unix socket. To run
+// the plugin on a dedicated I2P gateway host serving remote Docker engines,
+// the plugin API can also be served on a TCP port. Anyone reaching that port
+// could create networks and expose containers, so the listener only speaks
+// TLS and requires every client to present a certificate signed by the
+// configured client CA. Remote engines discover the plugin through a .json
+// spec file in /etc/docker/plugins naming its https address and their client
+// certificate.
+package plugin
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+)
+
+// TLSListenerConfig configures the TCP listener of the plugin API.
+type TLSListenerConfig struct {
+	// Address is the host:port the plugin API listens on (empty disables it)
+	Address string
+	// CertFile is the PEM certificate chain the listener presents
+	CertFile string
+	// KeyFile is the PEM private key of CertFile
+	KeyFile string
+	// ClientCAFile holds the PEM CA certificates client certificates must be
+	// signed by
+	ClientCAFile string
+}
+
+// SetTLSListener sets the TCP address the plugin API is served on with TLS
+// in addition to the Unix socket. The certificates are loaded immediately,
+// so configuration errors surface before the plugin starts. An empty
+// address disables the listener.
+//
+// Must be called before Start.
+func (p *Plugin) SetTLSListener(config TLSListenerConfig) error {
+	if config.Address == "" {
+		p.tlsAddress, p.tlsConfig = "", nil
+		return nil
+	}
+	if _, port, err := net.SplitHostPort(config.Address); err != nil || port == "" {
+		return fmt.Errorf("invalid TLS listen address %q: expected host:port", config.Address)
+	}
+	tlsConfig, err := loadTLSListenerConfig(config)
+	if err != nil {
+		return err
+	}
+
+	p.tlsAddress, p.tlsConfig = config.Address, tlsConfig
+	return nil
+}
+
+// loadTLSListenerConfig loads the server certificate and client CAs of a TLS
+// listener.
+func loadTLSListenerConfig(config TLSListenerConfig) (*tls.Config, error) {
+	if config.CertFile == "" || config.KeyFile == "" || config.ClientCAFile == "" {
+		return nil, fmt.Errorf("TLS listener on %s requires a certificate, key and client CA file", config.Address)
+	}
+
+	certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	pem, err := os.ReadFile(config.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS client CA file: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("TLS client CA file %s contains no PEM certificates", config.ClientCAFile)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		ClientCAs:    clientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// startTLSListener serves the plugin API on the TLS listener, if one is
+// configured. Serving errors are reported on errCh.
+func (p *Plugin) startTLSListener(errCh chan<- error) error {
+	if p.tlsAddress == "" {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", p.tlsAddress)
+	if err != nil {
+		return fmt.Errorf("failed to create TLS listener: %w", err)
+	}
+	p.tlsListener = tls.NewListener(listener, p.tlsConfig)
+
+	log.Printf("Plugin listening on %s with TLS", listener.Addr())
+
+	go func() {
+		if err := p.server.Serve(p.tlsListener); err != nil && err != http.ErrServerClosed {
+			errCh <- fmt.Errorf("TLS server error: %w", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,153 @@
+package plugin
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// tlsTestCA is a certificate authority issuing test certificates.
+type tlsTestCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTLSTestCA(t *testing.T, name string) *tlsTestCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &tlsTestCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key signed by the CA.
+func (ca *tlsTestCA) issue(t *testing.T, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "i2p-gateway"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestTLSListener(t *testing.T) {
+	p, _ := newAdminTestPlugin(t)
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		return path
+	}
+
+	ca, other := newTLSTestCA(t, "docker-engines"), newTLSTestCA(t, "other")
+	serverCert, serverKey := ca.issue(t, x509.ExtKeyUsageServerAuth)
+	config := TLSListenerConfig{
+		Address:      "127.0.0.1:0",
+		CertFile:     write("cert.pem", serverCert),
+		KeyFile:      write("key.pem", serverKey),
+		ClientCAFile: write("ca.pem", ca.pem),
+	}
+
+	for _, invalid := range []TLSListenerConfig{
+		{Address: "gateway", CertFile: config.CertFile, KeyFile: config.KeyFile, ClientCAFile: config.ClientCAFile},
+		{Address: config.Address, CertFile: config.CertFile, KeyFile: config.KeyFile},
+		{Address: config.Address, CertFile: config.CertFile, KeyFile: config.KeyFile, ClientCAFile: config.KeyFile},
+	} {
+		if err := p.SetTLSListener(invalid); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+	if err := p.SetTLSListener(config); err != nil {
+		t.Fatalf("SetTLSListener() unexpected error: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	p.setupHandlers(mux)
+	p.server = &http.Server{Handler: mux}
+	defer p.server.Shutdown(context.Background())
+	if err := p.startTLSListener(make(chan error, 1)); err != nil {
+		t.Fatalf("startTLSListener() unexpected error: %v", err)
+	}
+	address := "https://" + p.tlsListener.Addr().String() + "/Plugin.Activate"
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	activate := func(issuer *tlsTestCA) error {
+		tlsConfig := &tls.Config{RootCAs: roots}
+		if issuer != nil {
+			certPEM, keyPEM := issuer.issue(t, x509.ExtKeyUsageClientAuth)
+			certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+			if err != nil {
+				t.Fatalf("Failed to load client certificate: %v", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{certificate}
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}, Timeout: 5 * time.Second}
+		resp, err := client.Post(address, "application/json", nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected the plugin API to answer, got %s", resp.Status)
+		}
+		return nil
+	}
+
+	if err := activate(ca); err != nil {
+		t.Errorf("Expected engines with a client certificate of the CA to be served, got %v", err)
+	}
+	if err := activate(nil); err == nil {
+		t.Error("Expected clients without a certificate to be refused")
+	}
+	if err := activate(other); err == nil {
+		t.Error("Expected clients with a certificate of another CA to be refused")
+	}
+}
@@ -0,0 +1,254 @@
+// Package plugin provides request tracing for the Docker plugin API.
+//
+// This file implements a debug mode that records the request and response
+// bodies of every NetworkDriver call in a fixed-size ring buffer. Secrets are
+// redacted before recording. The buffer is retrievable through the admin API,
+// so Docker's exact call sequence can be reproduced when users report
+// lifecycle bugs.
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultTraceBufferSize is the default number of traced calls kept
+	DefaultTraceBufferSize = 500
+
+	// traceMaxBodyBytes caps the recorded size of each request and response body
+	traceMaxBodyBytes = 64 * 1024
+
+	// traceRedacted replaces the value of secret fields
+	traceRedacted = "[REDACTED]"
+)
+
+// traceSecretMarkers are substrings of JSON field names whose values are redacted.
+var traceSecretMarkers = []string{"password", "passwd", "secret", "token", "private", "credential", "privkey"}
+
+// RequestTrace is a recorded Docker plugin API call.
+type RequestTrace struct {
+	// Sequence orders calls across buffer wrap-around
+	Sequence uint64 `json:"sequence"`
+	// Time is when the request was received
+	Time time.Time `json:"time"`
+	// Endpoint is the plugin API path, e.g. /NetworkDriver.Join
+	Endpoint string `json:"endpoint"`
+	// Status is the HTTP status code of the response
+	Status int `json:"status"`
+	// DurationMS is the handler duration in milliseconds
+	DurationMS float64 `json:"duration_ms"`
+	// Request is the redacted request body
+	Request json.RawMessage `json:"request,omitempty"`
+	// Response is the redacted response body
+	Response json.RawMessage `json:"response,omitempty"`
+	// Truncated reports whether either body exceeded the recording limit
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// RequestTracer keeps the most recent plugin API calls in a ring buffer.
+//
+// RequestTracer is safe for concurrent use.
+type RequestTracer struct {
+	mutex    sync.Mutex
+	entries  []RequestTrace
+	next     int
+	full     bool
+	sequence uint64
+}
+
+// NewRequestTracer creates a tracer that keeps up to size calls.
+func NewRequestTracer(size int) *RequestTracer {
+	if size <= 0 {
+		size = DefaultTraceBufferSize
+	}
+	return &RequestTracer{
+		entries: make([]RequestTrace, size),
+	}
+}
+
+// record adds a call to the buffer, evicting the oldest when full.
+func (t *RequestTracer) record(entry RequestTrace) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.sequence++
+	entry.Sequence = t.sequence
+
+	t.entries[t.next] = entry
+	t.next = (t.next + 1) % len(t.entries)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+// Snapshot returns the recorded calls, oldest first.
+func (t *RequestTracer) Snapshot() []RequestTrace {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !t.full {
+		return append([]RequestTrace{}, t.entries[:t.next]...)
+	}
+
+	snapshot := make([]RequestTrace, 0, len(t.entries))
+	snapshot = append(snapshot, t.entries[t.next:]...)
+	snapshot = append(snapshot, t.entries[:t.next]...)
+	return snapshot
+}
+
+// SetRequestTracing enables or disables request tracing.
+//
+// Enabling replaces any existing buffer, so calls recorded before a resize are
+// discarded. It is safe to call while the plugin is serving requests.
+func (p *Plugin) SetRequestTracing(enabled bool, size int) {
+	if !enabled {
+		if p.tracer.Swap(nil) != nil {
+			log.Printf("Request tracing disabled")
+		}
+		return
+	}
+
+	p.tracer.Store(NewRequestTracer(size))
+	log.Printf("Request tracing enabled (buffer size %d)", len(p.tracer.Load().entries))
+}
+
+// traceRecorder captures the status and body written by a handler.
+type traceRecorder struct {
+	http.ResponseWriter
+	status int
+	body   limitedBuffer
+}
+
+// WriteHeader records the status code before passing it on.
+func (r *traceRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the body before passing it on.
+func (r *traceRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+// limitedBuffer stores up to traceMaxBodyBytes and notes if more was written.
+type limitedBuffer struct {
+	bytes.Buffer
+	truncated bool
+}
+
+// Write appends p, dropping anything beyond the limit.
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := traceMaxBodyBytes - b.Len(); len(p) > room {
+		b.truncated = true
+		p = p[:max(room, 0)]
+	}
+	b.Buffer.Write(p)
+	return len(p), nil
+}
+
+// traceRequests wraps a plugin handler so calls are recorded while tracing is enabled.
+func (p *Plugin) traceRequests(endpoint string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tracer := p.tracer.Load()
+		if tracer == nil {
+			handler(w, r)
+			return
+		}
+
+		start := time.Now()
+
+		// The handler still needs the body, so read it fully and replay it
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var request limitedBuffer
+		request.Write(body)
+
+		recorder := &traceRecorder{ResponseWriter: w}
+		handler(recorder, r)
+
+		entry := RequestTrace{
+			Time:       start,
+			Endpoint:   endpoint,
+			Status:     recorder.status,
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			Request:    redactTraceBody(request.Bytes(), request.truncated),
+			Response:   redactTraceBody(recorder.body.Bytes(), recorder.body.truncated),
+			Truncated:  request.truncated || recorder.body.truncated,
+		}
+		if err != nil {
+			entry.Request = redactTraceBody([]byte("failed to read request body: "+err.Error()), false)
+		}
+		tracer.record(entry)
+	}
+}
+
+// redactTraceBody returns a body as JSON with secret fields redacted.
+//
+// Bodies that are not valid JSON (including truncated ones) are recorded as a
+// JSON string, since secrets cannot be located reliably in them.
+func redactTraceBody(body []byte, truncated bool) json.RawMessage {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil
+	}
+
+	var value interface{}
+	if truncated || json.Unmarshal(body, &value) != nil {
+		if truncated {
+			body = []byte("[truncated body omitted]")
+		}
+		encoded, _ := json.Marshal(string(body))
+		return encoded
+	}
+
+	encoded, err := json.Marshal(redactTraceValue(value))
+	if err != nil {
+		return nil
+	}
+	return encoded
+}
+
+// redactTraceValue replaces the values of secret fields in a decoded JSON value.
+func redactTraceValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isTraceSecret(key) {
+				v[key] = traceRedacted
+				continue
+			}
+			v[key] = redactTraceValue(field)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactTraceValue(item)
+		}
+	}
+	return value
+}
+
+// isTraceSecret reports whether a JSON field name refers to a secret.
+func isTraceSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, marker := range traceSecretMarkers {
+		if strings.Contains(key, marker) {
+			return true
+		}
+	}
+	return false
+}
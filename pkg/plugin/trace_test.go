@@ -0,0 +1,141 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestTracerRingBuffer(t *testing.T) {
+	tracer := NewRequestTracer(3)
+
+	if got := tracer.Snapshot(); len(got) != 0 {
+		t.Fatalf("Expected empty snapshot, got %d entries", len(got))
+	}
+
+	for i := 1; i <= 5; i++ {
+		tracer.record(RequestTrace{Endpoint: fmt.Sprintf("/call%d", i)})
+	}
+
+	snapshot := tracer.Snapshot()
+	if len(snapshot) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(snapshot))
+	}
+	for i, entry := range snapshot {
+		expected := fmt.Sprintf("/call%d", i+3)
+		if entry.Endpoint != expected || entry.Sequence != uint64(i+3) {
+			t.Errorf("Entry %d: expected %s (seq %d), got %s (seq %d)", i, expected, i+3, entry.Endpoint, entry.Sequence)
+		}
+	}
+}
+
+func TestRedactTraceBody(t *testing.T) {
+	body := []byte(`{"NetworkID":"net1","Options":{"com.docker.network.generic":{"i2p.sam.password":"hunter2","i2p.filter.mode":"allowlist"}},"Keys":[{"PrivateKey":"abc"}]}`)
+
+	var redacted map[string]interface{}
+	if err := json.Unmarshal(redactTraceBody(body, false), &redacted); err != nil {
+		t.Fatalf("Redacted body is not valid JSON: %v", err)
+	}
+
+	options := redacted["Options"].(map[string]interface{})["com.docker.network.generic"].(map[string]interface{})
+	if options["i2p.sam.password"] != traceRedacted {
+		t.Errorf("Expected password to be redacted, got %v", options["i2p.sam.password"])
+	}
+	if options["i2p.filter.mode"] != "allowlist" {
+		t.Errorf("Expected non-secret option to be kept, got %v", options["i2p.filter.mode"])
+	}
+	key := redacted["Keys"].([]interface{})[0].(map[string]interface{})
+	if key["PrivateKey"] != traceRedacted {
+		t.Errorf("Expected private key to be redacted, got %v", key["PrivateKey"])
+	}
+	if redacted["NetworkID"] != "net1" {
+		t.Errorf("Expected NetworkID to be kept, got %v", redacted["NetworkID"])
+	}
+
+	if got := redactTraceBody(nil, false); got != nil {
+		t.Errorf("Expected nil for empty body, got %s", got)
+	}
+	if got := string(redactTraceBody([]byte("not json"), false)); got != `"not json"` {
+		t.Errorf("Expected invalid JSON to be recorded as a string, got %s", got)
+	}
+	if got := string(redactTraceBody([]byte(`{"password":"x"`), true)); strings.Contains(got, "password") {
+		t.Errorf("Expected truncated body to be omitted, got %s", got)
+	}
+}
+
+func TestTraceRequests(t *testing.T) {
+	p := &Plugin{}
+	handler := p.traceRequests("/NetworkDriver.CreateNetwork", func(w http.ResponseWriter, r *http.Request) {
+		var req CreateNetworkRequest
+		if err := p.readJSONRequest(r, &req); err != nil {
+			t.Errorf("Handler could not read traced body: %v", err)
+		}
+		p.writeJSONResponse(w, ErrorResponse{Err: "network " + req.NetworkID + " failed"})
+	})
+
+	call := func() {
+		body := `{"NetworkID":"net1","Options":{"secret_token":"s3cret"}}`
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/NetworkDriver.CreateNetwork", strings.NewReader(body)))
+	}
+
+	// Disabled tracing records nothing
+	call()
+	if p.tracer.Load() != nil {
+		t.Fatal("Expected tracing to be disabled by default")
+	}
+
+	p.SetRequestTracing(true, 10)
+	call()
+
+	traces := p.tracer.Load().Snapshot()
+	if len(traces) != 1 {
+		t.Fatalf("Expected 1 trace, got %d", len(traces))
+	}
+	trace := traces[0]
+	if trace.Endpoint != "/NetworkDriver.CreateNetwork" || trace.Status != http.StatusOK {
+		t.Errorf("Unexpected trace metadata: %+v", trace)
+	}
+	if strings.Contains(string(trace.Request), "s3cret") {
+		t.Errorf("Expected secret to be redacted, got %s", trace.Request)
+	}
+	if !strings.Contains(string(trace.Response), "network net1 failed") {
+		t.Errorf("Expected response body to be recorded, got %s", trace.Response)
+	}
+
+	p.SetRequestTracing(false, 0)
+	if p.tracer.Load() != nil {
+		t.Error("Expected tracing to be disabled")
+	}
+}
+
+func TestAdminTraces(t *testing.T) {
+	p, mux := newAdminTestPlugin(t)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/traces", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 while tracing is disabled, got %d", rec.Code)
+	}
+
+	p.SetRequestTracing(true, 10)
+	tracer := p.tracer.Load()
+	tracer.record(RequestTrace{Endpoint: "/NetworkDriver.CreateNetwork"})
+	tracer.record(RequestTrace{Endpoint: "/NetworkDriver.Join"})
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/traces?endpoint=/NetworkDriver.Join", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	var traces []RequestTrace
+	if err := json.NewDecoder(rec.Body).Decode(&traces); err != nil {
+		t.Fatalf("Failed to decode traces: %v", err)
+	}
+	if len(traces) != 1 || traces[0].Endpoint != "/NetworkDriver.Join" {
+		t.Errorf("Expected only the Join trace, got %+v", traces)
+	}
+}
@@ -0,0 +1,69 @@
+// Package plugin provides per-network tunnel option overrides.
+//
+// Networks hosting many destinations often want different tunnel pools than
+// the plugin-wide I2P_* defaults: fewer tunnels for a fleet of idle test
+// services, idle pool reduction, or peer restrictions. Network options
+// prefixed with i2p.tunnel. override the defaults for the sessions of the
+// network's containers, e.g. -o i2p.tunnel.reduce_idle=true or
+// -o i2p.tunnel.inbound.IPRestriction=1; -o i2p.tunnel.length=2 and
+// -o i2p.tunnel.quantity=3 set both directions at once. They are validated
+// when the network is created and applied when a container joins.
+package plugin
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+// TunnelOptionPrefix prefixes network options overriding tunnel options.
+const TunnelOptionPrefix = "i2p.tunnel."
+
+// parseTunnelOverrides returns the tunnel option overrides selected by network
+// options, keyed without TunnelOptionPrefix, or nil if there are none.
+func parseTunnelOverrides(options map[string]interface{}) (map[string]string, error) {
+	var overrides map[string]string
+	for name, value := range options {
+		key, ok := strings.CutPrefix(name, TunnelOptionPrefix)
+		if !ok {
+			continue
+		}
+
+		var text string
+		switch value := value.(type) {
+		case string:
+			text = value
+		case bool:
+			text = strconv.FormatBool(value)
+		case float64:
+			text = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			return nil, fmt.Errorf("invalid %s value %v: expected a string, number or boolean", name, value)
+		}
+		if overrides == nil {
+			overrides = make(map[string]string)
+		}
+		overrides[key] = text
+	}
+
+	if _, err := i2p.DefaultTunnelOptions().WithOverrides(overrides); err != nil {
+		return nil, fmt.Errorf("invalid %s option: %w", TunnelOptionPrefix+"*", err)
+	}
+	return overrides, nil
+}
+
+// applyTunnelOverridesLocked applies the network's tunnel option overrides to
+// the sessions of a joining container. A container on several networks with
+// overrides gets those of the network it joined last. The caller must hold
+// the network's mutex.
+func (nm *NetworkManager) applyTunnelOverridesLocked(network *I2PNetwork, containerID string) {
+	if len(network.TunnelOverrides) == 0 {
+		return
+	}
+	if err := nm.tunnelMgr.SetContainerSessionOverrides(containerID, network.TunnelOverrides); err != nil {
+		log.Printf("Warning: Failed to apply tunnel options of network %s to container %s: %v", network.ID, containerID, err)
+	}
+}
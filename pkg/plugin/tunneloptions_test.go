@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestParseTunnelOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		options map[string]interface{}
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "no overrides",
+			options: map[string]interface{}{MaxEndpointsOption: "5"},
+		},
+		{
+			name: "typed and passthrough options",
+			options: map[string]interface{}{
+				"i2p.tunnel.inbound_tunnels":       float64(3),
+				"i2p.tunnel.reduce_idle":           true,
+				"i2p.tunnel.inbound.IPRestriction": "1",
+			},
+			want: map[string]string{"inbound_tunnels": "3", "reduce_idle": "true", "inbound.IPRestriction": "1"},
+		},
+		{
+			name:    "shorthands",
+			options: map[string]interface{}{"i2p.tunnel.length": "2", "i2p.tunnel.quantity": float64(3)},
+			want:    map[string]string{"length": "2", "quantity": "3"},
+		},
+		{
+			name:    "invalid value",
+			options: map[string]interface{}{"i2p.tunnel.inbound_tunnels": "none"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown option",
+			options: map[string]interface{}{"i2p.tunnel.ssu.enable": "false"},
+			wantErr: true,
+		},
+		{
+			name:    "typed option passed through",
+			options: map[string]interface{}{"i2p.tunnel.outbound.quantity": "4"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported type",
+			options: map[string]interface{}{"i2p.tunnel.close_idle": []string{"true"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTunnelOverrides(tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTunnelOverrides() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("parseTunnelOverrides() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
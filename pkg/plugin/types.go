@@ -1,7 +1,7 @@
 // Package plugin defines the types used in Docker Plugin API communication.
 //
 // These types represent the request and response structures defined in
-// Docker's Plugin API v2 specification for network drivers.
+// Docker's Plugin API v2 specification for network and IPAM drivers.
 package plugin
 
 // ActivateResponse represents the response to Plugin.Activate.
@@ -103,8 +103,6 @@ type JoinResponse struct {
 	StaticRoutes          []StaticRoute          `json:"StaticRoutes,omitempty"`
 	DisableGatewayService bool                   `json:"DisableGatewayService,omitempty"`
 	GWName                string                 `json:"GWName,omitempty"`
-	ResolvConf            string                 `json:"ResolvConf,omitempty"`
-	DNS                   []string               `json:"DNS,omitempty"`
 	Options               map[string]interface{} `json:"Options,omitempty"`
 	ErrorResponse
 }
@@ -116,6 +114,14 @@ type StaticRoute struct {
 	NextHop     string `json:"NextHop"`
 }
 
+// Route types of a StaticRoute, as defined by libnetwork.
+const (
+	// RouteTypeNextHop sends traffic through StaticRoute.NextHop
+	RouteTypeNextHop = 0
+	// RouteTypeConnected sends traffic directly out of the interface
+	RouteTypeConnected = 1
+)
+
 // LeaveRequest represents a request to leave a network.
 type LeaveRequest struct {
 	NetworkID  string `json:"NetworkID"`
@@ -134,3 +140,59 @@ type ExternalConnectivityRequest struct {
 	EndpointID string                 `json:"EndpointID"`
 	Options    map[string]interface{} `json:"Options"`
 }
+
+// IPAMCapabilitiesResponse represents the response to IpamDriver.GetCapabilities.
+type IPAMCapabilitiesResponse struct {
+	RequiresMACAddress    bool `json:"RequiresMACAddress"`
+	RequiresRequestReplay bool `json:"RequiresRequestReplay"`
+	ErrorResponse
+}
+
+// AddressSpacesResponse represents the response to IpamDriver.GetDefaultAddressSpaces.
+type AddressSpacesResponse struct {
+	LocalDefaultAddressSpace  string `json:"LocalDefaultAddressSpace"`
+	GlobalDefaultAddressSpace string `json:"GlobalDefaultAddressSpace"`
+	ErrorResponse
+}
+
+// RequestPoolRequest represents a request for an address pool.
+type RequestPoolRequest struct {
+	AddressSpace string            `json:"AddressSpace"`
+	Pool         string            `json:"Pool"`
+	SubPool      string            `json:"SubPool"`
+	Options      map[string]string `json:"Options"`
+	V6           bool              `json:"V6"`
+}
+
+// RequestPoolResponse represents the response to a pool request.
+type RequestPoolResponse struct {
+	PoolID string            `json:"PoolID"`
+	Pool   string            `json:"Pool"`
+	Data   map[string]string `json:"Data,omitempty"`
+	ErrorResponse
+}
+
+// ReleasePoolRequest represents a request to release an address pool.
+type ReleasePoolRequest struct {
+	PoolID string `json:"PoolID"`
+}
+
+// RequestAddressRequest represents a request for an address from a pool.
+type RequestAddressRequest struct {
+	PoolID  string            `json:"PoolID"`
+	Address string            `json:"Address"`
+	Options map[string]string `json:"Options"`
+}
+
+// RequestAddressResponse represents the response to an address request.
+type RequestAddressResponse struct {
+	Address string            `json:"Address"`
+	Data    map[string]string `json:"Data,omitempty"`
+	ErrorResponse
+}
+
+// ReleaseAddressRequest represents a request to release an address.
+type ReleaseAddressRequest struct {
+	PoolID  string `json:"PoolID"`
+	Address string `json:"Address"`
+}
@@ -0,0 +1,222 @@
+// Package proxy provides anomaly detection on traffic filter decisions.
+//
+// A container that suddenly starts hammering clearnet addresses or
+// destinations its network's filter refuses has usually been compromised or
+// misconfigured. The detector keeps, per container and kind of refusal, the
+// number of refusals in the current window and an exponentially weighted
+// baseline of earlier windows. A window whose count reaches both a minimum
+// and a multiple of the baseline is flagged once; flagged anomalies are
+// listed for a retention period and passed to a handler.
+package proxy
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Kinds of filter refusals tracked by the anomaly detector.
+const (
+	// AnomalyNonI2PBlocked counts blocked connections to non-I2P destinations
+	AnomalyNonI2PBlocked = "non_i2p_blocked"
+	// AnomalyDenied counts I2P destinations refused by the allowlist or blocklist
+	AnomalyDenied = "denied"
+)
+
+// maxAnomalies bounds the number of listed anomalies.
+const maxAnomalies = 1000
+
+// AnomalyConfig tunes the anomaly detector.
+type AnomalyConfig struct {
+	// Window is the interval refusals are counted over
+	Window time.Duration
+	// Factor is the multiple of the baseline a window's count must reach
+	Factor float64
+	// MinEvents is the smallest window count that is flagged, so quiet
+	// containers are not reported for a handful of refusals
+	MinEvents int64
+	// Smoothing is the weight of the latest window in the baseline (0-1)
+	Smoothing float64
+	// Retention is how long flagged anomalies are listed and idle
+	// containers are tracked
+	Retention time.Duration
+}
+
+// DefaultAnomalyConfig returns the detector settings used by the proxy.
+func DefaultAnomalyConfig() AnomalyConfig {
+	return AnomalyConfig{
+		Window:    time.Minute,
+		Factor:    5,
+		MinEvents: 10,
+		Smoothing: 0.2,
+		Retention: time.Hour,
+	}
+}
+
+// Anomaly is an abrupt rise in a container's filter refusals.
+type Anomaly struct {
+	// ContainerID is the container whose connections were refused
+	ContainerID string
+	// Kind is AnomalyNonI2PBlocked or AnomalyDenied
+	Kind string
+	// Count is the number of refusals in the flagged window when it was flagged
+	Count int64
+	// Baseline is the number of refusals per window expected from history
+	Baseline float64
+	// Window is the interval Count and Baseline refer to
+	Window time.Duration
+	// DetectedAt is when the anomaly was flagged
+	DetectedAt time.Time
+}
+
+// anomalyKey identifies a rate tracked by the detector.
+type anomalyKey struct {
+	containerID string
+	kind        string
+}
+
+// rateTracker holds the refusal counts of one container and kind.
+type rateTracker struct {
+	windowStart time.Time
+	count       int64
+	baseline    float64
+	flagged     bool
+	lastSeen    time.Time
+}
+
+// advance closes the windows that ended before now, folding their counts
+// into the baseline.
+func (t *rateTracker) advance(now time.Time, config AnomalyConfig) {
+	elapsed := now.Sub(t.windowStart)
+	if elapsed < config.Window {
+		return
+	}
+	windows := int64(elapsed / config.Window)
+
+	// The current window counts once; the empty windows after it decay
+	// the baseline
+	t.baseline = config.Smoothing*float64(t.count) + (1-config.Smoothing)*t.baseline
+	t.baseline *= math.Pow(1-config.Smoothing, float64(windows-1))
+
+	t.windowStart = t.windowStart.Add(time.Duration(windows) * config.Window)
+	t.count = 0
+	t.flagged = false
+}
+
+// AnomalyDetector flags containers whose filter refusals rise abruptly.
+type AnomalyDetector struct {
+	config    AnomalyConfig
+	trackers  map[anomalyKey]*rateTracker
+	anomalies []Anomaly
+	handler   func(Anomaly)
+	mutex     sync.Mutex
+}
+
+// NewAnomalyDetector creates a detector with the given settings.
+func NewAnomalyDetector(config AnomalyConfig) *AnomalyDetector {
+	return &AnomalyDetector{
+		config:   config,
+		trackers: make(map[anomalyKey]*rateTracker),
+	}
+}
+
+// SetHandler sets the function called with every flagged anomaly.
+//
+// The handler runs on the connection that triggered the anomaly and should
+// not block.
+func (d *AnomalyDetector) SetHandler(handler func(Anomaly)) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.handler = handler
+}
+
+// Record counts a refusal of kind for a container at now, flagging an
+// anomaly if the container's current window stands out from its baseline.
+func (d *AnomalyDetector) Record(containerID, kind string, now time.Time) {
+	if containerID == "" {
+		return
+	}
+
+	d.mutex.Lock()
+	key := anomalyKey{containerID: containerID, kind: kind}
+	tracker, exists := d.trackers[key]
+	if !exists {
+		d.pruneLocked(now)
+		tracker = &rateTracker{windowStart: now}
+		d.trackers[key] = tracker
+	}
+	tracker.advance(now, d.config)
+	tracker.count++
+	tracker.lastSeen = now
+
+	if tracker.flagged || tracker.count < d.config.MinEvents || float64(tracker.count) < d.config.Factor*tracker.baseline {
+		d.mutex.Unlock()
+		return
+	}
+	tracker.flagged = true
+
+	anomaly := Anomaly{
+		ContainerID: containerID,
+		Kind:        kind,
+		Count:       tracker.count,
+		Baseline:    tracker.baseline,
+		Window:      d.config.Window,
+		DetectedAt:  now,
+	}
+	d.anomalies = append(d.anomalies, anomaly)
+	if len(d.anomalies) > maxAnomalies {
+		d.anomalies = d.anomalies[len(d.anomalies)-maxAnomalies:]
+	}
+	handler := d.handler
+	d.mutex.Unlock()
+
+	if handler != nil {
+		handler(anomaly)
+	}
+}
+
+// Anomalies returns the anomalies flagged within the retention period,
+// newest first.
+func (d *AnomalyDetector) Anomalies() []Anomaly {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.pruneLocked(time.Now())
+	anomalies := append([]Anomaly(nil), d.anomalies...)
+	sort.SliceStable(anomalies, func(i, j int) bool { return anomalies[i].DetectedAt.After(anomalies[j].DetectedAt) })
+	return anomalies
+}
+
+// pruneLocked drops anomalies and idle trackers older than the retention
+// period. The caller must hold d.mutex.
+func (d *AnomalyDetector) pruneLocked(now time.Time) {
+	cutoff := now.Add(-d.config.Retention)
+
+	kept := d.anomalies[:0]
+	for _, anomaly := range d.anomalies {
+		if anomaly.DetectedAt.After(cutoff) {
+			kept = append(kept, anomaly)
+		}
+	}
+	d.anomalies = kept
+
+	for key, tracker := range d.trackers {
+		if tracker.lastSeen.Before(cutoff) {
+			delete(d.trackers, key)
+		}
+	}
+}
+
+// GetAnomalies returns the filter anomalies flagged within the retention
+// period, newest first.
+func (pm *ProxyManager) GetAnomalies() []Anomaly {
+	return pm.trafficFilter.anomalies.Anomalies()
+}
+
+// SetAnomalyHandler sets the function called with every flagged filter
+// anomaly.
+func (pm *ProxyManager) SetAnomalyHandler(handler func(Anomaly)) {
+	pm.trafficFilter.anomalies.SetHandler(handler)
+}
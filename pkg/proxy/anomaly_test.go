@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnomalyDetectorFlagsAbruptRise(t *testing.T) {
+	detector := NewAnomalyDetector(DefaultAnomalyConfig())
+
+	var flagged []Anomaly
+	detector.SetHandler(func(anomaly Anomaly) { flagged = append(flagged, anomaly) })
+
+	start := time.Now()
+
+	// A steady trickle of refusals builds a baseline without being flagged
+	for minute := 0; minute < 30; minute++ {
+		at := start.Add(time.Duration(minute) * time.Minute)
+		detector.Record("container1", AnomalyDenied, at)
+		detector.Record("container1", AnomalyDenied, at.Add(time.Second))
+	}
+	if len(flagged) != 0 {
+		t.Fatalf("Expected steady refusals not to be flagged, got %+v", flagged)
+	}
+
+	// A burst is flagged once per window
+	burst := start.Add(30 * time.Minute)
+	for i := 0; i < 50; i++ {
+		detector.Record("container1", AnomalyDenied, burst.Add(time.Duration(i)*time.Millisecond))
+	}
+	if len(flagged) != 1 {
+		t.Fatalf("Expected one anomaly, got %d", len(flagged))
+	}
+	if anomaly := flagged[0]; anomaly.ContainerID != "container1" || anomaly.Kind != AnomalyDenied || anomaly.Count < 10 || anomaly.Baseline < 1 {
+		t.Errorf("Unexpected anomaly %+v", anomaly)
+	}
+
+	// Other kinds are tracked separately
+	detector.Record("container1", AnomalyNonI2PBlocked, burst)
+	if len(flagged) != 1 {
+		t.Errorf("Expected a single non-I2P refusal not to be flagged")
+	}
+}
+
+func TestAnomalyDetectorSteadyHighRate(t *testing.T) {
+	config := DefaultAnomalyConfig()
+	detector := NewAnomalyDetector(config)
+
+	var flagged int
+	detector.SetHandler(func(Anomaly) { flagged++ })
+
+	// A container that always produces many refusals is flagged while its
+	// baseline builds up, then no longer
+	start := time.Now()
+	for minute := 0; minute < 60; minute++ {
+		for i := 0; i < 20; i++ {
+			detector.Record("noisy", AnomalyNonI2PBlocked, start.Add(time.Duration(minute)*time.Minute+time.Duration(i)*time.Second))
+		}
+	}
+	before := flagged
+	for i := 0; i < 20; i++ {
+		detector.Record("noisy", AnomalyNonI2PBlocked, start.Add(60*time.Minute+time.Duration(i)*time.Second))
+	}
+	if flagged != before {
+		t.Errorf("Expected a steady rate not to be flagged once the baseline settled")
+	}
+}
+
+func TestAnomalyDetectorIgnoresQuietContainers(t *testing.T) {
+	detector := NewAnomalyDetector(DefaultAnomalyConfig())
+
+	now := time.Now()
+	for i := int64(0); i < DefaultAnomalyConfig().MinEvents-1; i++ {
+		detector.Record("quiet", AnomalyNonI2PBlocked, now)
+	}
+	detector.Record("", AnomalyNonI2PBlocked, now)
+
+	if anomalies := detector.Anomalies(); len(anomalies) != 0 {
+		t.Errorf("Expected no anomalies below the minimum count, got %+v", anomalies)
+	}
+}
+
+func TestAnomalyDetectorRetention(t *testing.T) {
+	config := DefaultAnomalyConfig()
+	detector := NewAnomalyDetector(config)
+
+	old := time.Now().Add(-2 * config.Retention)
+	recent := time.Now()
+	for i := int64(0); i < config.MinEvents; i++ {
+		detector.Record("old", AnomalyDenied, old)
+		detector.Record("recent", AnomalyDenied, recent)
+	}
+
+	anomalies := detector.Anomalies()
+	if len(anomalies) != 1 || anomalies[0].ContainerID != "recent" {
+		t.Fatalf("Expected only the recent anomaly to be listed, got %+v", anomalies)
+	}
+	if _, exists := detector.trackers[anomalyKey{containerID: "old", kind: AnomalyDenied}]; exists {
+		t.Error("Expected the idle tracker to be pruned")
+	}
+}
+
+func TestShouldAllowConnectionFromRecordsRefusals(t *testing.T) {
+	filter := NewTrafficFilter(DefaultFilterConfig())
+	if err := filter.AddToBlocklist("bad.i2p"); err != nil {
+		t.Fatalf("Failed to add blocklist entry: %v", err)
+	}
+
+	for i := int64(0); i < DefaultAnomalyConfig().MinEvents; i++ {
+		if allowed, _ := filter.ShouldAllowConnectionFrom("container1", "example.com:443", "tcp"); allowed {
+			t.Fatal("Expected non-I2P destination to be blocked")
+		}
+		if allowed, _ := filter.ShouldAllowConnectionFrom("container2", "bad.i2p:80", "tcp"); allowed {
+			t.Fatal("Expected blocklisted destination to be denied")
+		}
+		if allowed, _ := filter.ShouldAllowConnectionFrom("container3", "good.i2p:80", "tcp"); !allowed {
+			t.Fatal("Expected I2P destination to be allowed")
+		}
+	}
+
+	kinds := map[string]string{}
+	for _, anomaly := range filter.anomalies.Anomalies() {
+		kinds[anomaly.ContainerID] = anomaly.Kind
+	}
+	if len(kinds) != 2 || kinds["container1"] != AnomalyNonI2PBlocked || kinds["container2"] != AnomalyDenied {
+		t.Errorf("Unexpected anomalies by container: %v", kinds)
+	}
+}
@@ -0,0 +1,263 @@
+// Package proxy provides per-endpoint address anti-spoofing.
+//
+// The SOCKS proxy identifies clients by source address, and traffic filter
+// identities and proxy policies follow from it, so a container that forges
+// another endpoint's address inherits that endpoint's access. Each joined
+// endpoint therefore gets firewall rules binding its IP and MAC addresses to
+// each other: ebtables drops frames from the endpoint's MAC carrying any other
+// IP (or ARP claims for any other address), and iptables drops packets from
+// the endpoint's IP arriving with any other MAC.
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// antiSpoofChain is the iptables and ebtables chain holding endpoint bindings.
+const antiSpoofChain = "I2P_ANTISPOOF"
+
+// endpointBinding is an endpoint address pair with anti-spoofing rules installed.
+type endpointBinding struct {
+	// ip is the endpoint's allocated IP address
+	ip net.IP
+	// mac is the endpoint's allocated MAC address
+	mac net.HardwareAddr
+}
+
+// iptablesRules returns the iptables rules enforcing the binding.
+func (b endpointBinding) iptablesRules() []string {
+	return []string{
+		fmt.Sprintf("-t filter -A %s -s %s -m mac ! --mac-source %s -j DROP", antiSpoofChain, b.ip, b.mac),
+	}
+}
+
+// ebtablesRules returns the ebtables rules enforcing the binding.
+func (b endpointBinding) ebtablesRules() []string {
+	return []string{
+		fmt.Sprintf("-t filter -A %s -p IPv4 -s %s ! --ip-src %s -j DROP", antiSpoofChain, b.mac, b.ip),
+		fmt.Sprintf("-t filter -A %s -p ARP -s %s ! --arp-ip-src %s -j DROP", antiSpoofChain, b.mac, b.ip),
+		fmt.Sprintf("-t filter -A %s -p ARP -s %s ! --arp-mac-src %s -j DROP", antiSpoofChain, b.mac, b.mac),
+	}
+}
+
+// antiSpoofIptablesChainRules returns the rules creating the iptables chain.
+//
+// The chain is consulted before any other rule for traffic to the proxy
+// listeners (INPUT) and traffic routed through the host (FORWARD).
+func antiSpoofIptablesChainRules() []string {
+	return []string{
+		"-t filter -N " + antiSpoofChain,
+		"-t filter -I INPUT 1 -j " + antiSpoofChain,
+		"-t filter -I FORWARD 1 -j " + antiSpoofChain,
+	}
+}
+
+// antiSpoofEbtablesChainRules returns the rules creating the ebtables chain.
+//
+// User-defined ebtables chains accept frames by default, which would skip the
+// remaining rules of the calling chain, so the policy is set to RETURN.
+func antiSpoofEbtablesChainRules() []string {
+	return []string{
+		"-t filter -N " + antiSpoofChain,
+		"-t filter -P " + antiSpoofChain + " RETURN",
+		"-t filter -I INPUT 1 -j " + antiSpoofChain,
+		"-t filter -I FORWARD 1 -j " + antiSpoofChain,
+	}
+}
+
+// BindEndpointAddress installs anti-spoofing rules for an endpoint.
+//
+// Binding an endpoint that is already bound replaces its rules. The chains
+// are created with the first binding. If a rule cannot be installed, the
+// endpoint's rules are rolled back and an error is returned.
+func (pm *ProxyManager) BindEndpointAddress(endpointID string, ip net.IP, mac string) error {
+	hwAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return fmt.Errorf("invalid MAC address %q for endpoint %s: %w", mac, endpointID, err)
+	}
+	if ip.To4() == nil {
+		return fmt.Errorf("endpoint %s has no IPv4 address to bind", endpointID)
+	}
+
+	pm.bindingMutex.Lock()
+	defer pm.bindingMutex.Unlock()
+
+	if _, exists := pm.endpointBindings[endpointID]; exists {
+		if err := pm.unbindEndpointLocked(endpointID); err != nil {
+			log.Printf("Warning: Failed to remove previous anti-spoofing rules of endpoint %s: %v", endpointID, err)
+		}
+	}
+
+	if len(pm.endpointBindings) == 0 {
+		if err := pm.createAntiSpoofChains(); err != nil {
+			return err
+		}
+	}
+
+	binding := endpointBinding{ip: ip.To4(), mac: hwAddr}
+	if err := pm.applyBinding(binding); err != nil {
+		if len(pm.endpointBindings) == 0 {
+			pm.removeAntiSpoofChains()
+		}
+		return fmt.Errorf("failed to bind endpoint %s to %s/%s: %w", endpointID, ip, hwAddr, err)
+	}
+
+	pm.endpointBindings[endpointID] = binding
+	log.Printf("Bound endpoint %s to IP %s and MAC %s", endpointID, binding.ip, binding.mac)
+	return nil
+}
+
+// UnbindEndpointAddress removes an endpoint's anti-spoofing rules.
+//
+// The chains are removed with the last binding. Unbinding an endpoint
+// without rules is not an error.
+func (pm *ProxyManager) UnbindEndpointAddress(endpointID string) error {
+	pm.bindingMutex.Lock()
+	defer pm.bindingMutex.Unlock()
+
+	return pm.unbindEndpointLocked(endpointID)
+}
+
+// CheckEbtablesAvailability verifies that the firewall backend can filter
+// bridged frames.
+func (pm *ProxyManager) CheckEbtablesAvailability() error {
+	return pm.interceptor.firewall.CheckRules(FamilyBridge)
+}
+
+// unbindEndpointLocked removes an endpoint's rules. The caller must hold
+// pm.bindingMutex.
+func (pm *ProxyManager) unbindEndpointLocked(endpointID string) error {
+	binding, exists := pm.endpointBindings[endpointID]
+	if !exists {
+		return nil
+	}
+	delete(pm.endpointBindings, endpointID)
+
+	errors := pm.removeBinding(binding)
+	if len(pm.endpointBindings) == 0 {
+		errors = append(errors, pm.removeAntiSpoofChains()...)
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to unbind endpoint %s: %s", endpointID, strings.Join(errors, "; "))
+	}
+	log.Printf("Removed anti-spoofing rules of endpoint %s", endpointID)
+	return nil
+}
+
+// unbindAllEndpoints removes every binding and the chains, returning errors.
+func (pm *ProxyManager) unbindAllEndpoints() []string {
+	pm.bindingMutex.Lock()
+	defer pm.bindingMutex.Unlock()
+
+	if len(pm.endpointBindings) == 0 {
+		return nil
+	}
+
+	var errors []string
+	for endpointID, binding := range pm.endpointBindings {
+		errors = append(errors, pm.removeBinding(binding)...)
+		delete(pm.endpointBindings, endpointID)
+	}
+	return append(errors, pm.removeAntiSpoofChains()...)
+}
+
+// applyBinding installs a binding's rules, removing them again on failure.
+func (pm *ProxyManager) applyBinding(binding endpointBinding) error {
+	iptablesRules := binding.iptablesRules()
+	for i, rule := range iptablesRules {
+		if err := pm.runIptables(rule); err != nil {
+			for _, installed := range iptablesRules[:i] {
+				pm.runIptables(strings.Replace(installed, "-A", "-D", 1))
+			}
+			return err
+		}
+	}
+
+	ebtablesRules := binding.ebtablesRules()
+	for i, rule := range ebtablesRules {
+		if err := pm.runEbtables(rule); err != nil {
+			for _, installed := range ebtablesRules[:i] {
+				pm.runEbtables(strings.Replace(installed, "-A", "-D", 1))
+			}
+			for _, installed := range iptablesRules {
+				pm.runIptables(strings.Replace(installed, "-A", "-D", 1))
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// removeBinding deletes a binding's rules, returning any errors.
+func (pm *ProxyManager) removeBinding(binding endpointBinding) []string {
+	var errors []string
+	for _, rule := range binding.iptablesRules() {
+		if err := pm.runIptables(strings.Replace(rule, "-A", "-D", 1)); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	for _, rule := range binding.ebtablesRules() {
+		if err := pm.runEbtables(strings.Replace(rule, "-A", "-D", 1)); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	return errors
+}
+
+// createAntiSpoofChains creates both chains, removing them again on failure.
+// Leftovers of a previous instance are removed first.
+func (pm *ProxyManager) createAntiSpoofChains() error {
+	for _, chain := range []firewallChain{antiSpoofIptablesChain, antiSpoofEbtablesChain} {
+		if err := pm.removeLeftoverChain(chain); err != nil {
+			return err
+		}
+	}
+	for _, rule := range antiSpoofIptablesChainRules() {
+		if err := pm.runIptables(rule); err != nil {
+			pm.removeAntiSpoofChains()
+			return fmt.Errorf("failed to create iptables chain %s: %w", antiSpoofChain, err)
+		}
+	}
+	for _, rule := range antiSpoofEbtablesChainRules() {
+		if err := pm.runEbtables(rule); err != nil {
+			pm.removeAntiSpoofChains()
+			return fmt.Errorf("failed to create ebtables chain %s: %w", antiSpoofChain, err)
+		}
+	}
+	return nil
+}
+
+// removeAntiSpoofChains unhooks, flushes and deletes both chains, returning
+// any errors.
+func (pm *ProxyManager) removeAntiSpoofChains() []string {
+	var errors []string
+	teardown := []string{
+		"-t filter -D INPUT -j " + antiSpoofChain,
+		"-t filter -D FORWARD -j " + antiSpoofChain,
+		"-t filter -F " + antiSpoofChain,
+		"-t filter -X " + antiSpoofChain,
+	}
+	for _, rule := range teardown {
+		if err := pm.runIptables(rule); err != nil {
+			errors = append(errors, err.Error())
+		}
+		if err := pm.runEbtables(rule); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	return errors
+}
+
+// executeEbtablesRule executes a single ebtables rule.
+func executeEbtablesRule(rule string) error {
+	output, err := exec.Command("ebtables", strings.Fields(rule)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ebtables command failed: %s (output: %s)", err, string(output))
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// recordEbtables makes pm record ebtables rules instead of running them.
+func recordEbtables(pm *ProxyManager) *[]string {
+	var rules []string
+	pm.runEbtables = func(rule string) error {
+		rules = append(rules, rule)
+		return nil
+	}
+	return &rules
+}
+
+func TestProxyManager_BindEndpointAddress(t *testing.T) {
+	t.Parallel()
+
+	pm, iptables := newBindTestManager(t)
+	ebtables := recordEbtables(pm)
+
+	if err := pm.BindEndpointAddress("ep1", net.ParseIP("172.20.0.2"), "02:42:ac:14:00:02"); err != nil {
+		t.Fatalf("Failed to bind endpoint: %v", err)
+	}
+
+	expectedIptables := []string{
+		"-t filter -N I2P_ANTISPOOF",
+		"-t filter -I INPUT 1 -j I2P_ANTISPOOF",
+		"-t filter -I FORWARD 1 -j I2P_ANTISPOOF",
+		"-t filter -A I2P_ANTISPOOF -s 172.20.0.2 -m mac ! --mac-source 02:42:ac:14:00:02 -j DROP",
+	}
+	if fmt.Sprint(*iptables) != fmt.Sprint(expectedIptables) {
+		t.Errorf("Unexpected iptables rules:\n got %v\nwant %v", *iptables, expectedIptables)
+	}
+	if len(*ebtables) != 7 || (*ebtables)[1] != "-t filter -P I2P_ANTISPOOF RETURN" {
+		t.Errorf("Unexpected ebtables rules: %v", *ebtables)
+	}
+	if rule := (*ebtables)[4]; rule != "-t filter -A I2P_ANTISPOOF -p IPv4 -s 02:42:ac:14:00:02 ! --ip-src 172.20.0.2 -j DROP" {
+		t.Errorf("Unexpected IPv4 binding rule: %s", rule)
+	}
+
+	// A second endpoint reuses the chains
+	*iptables, *ebtables = nil, nil
+	if err := pm.BindEndpointAddress("ep2", net.ParseIP("172.20.0.3"), "02:42:ac:14:00:03"); err != nil {
+		t.Fatalf("Failed to bind second endpoint: %v", err)
+	}
+	if len(*iptables) != 1 || len(*ebtables) != 3 {
+		t.Errorf("Expected only endpoint rules, got %v and %v", *iptables, *ebtables)
+	}
+
+	// Unbinding deletes the endpoint's rules; the last one removes the chains
+	*iptables, *ebtables = nil, nil
+	if err := pm.UnbindEndpointAddress("ep1"); err != nil {
+		t.Fatalf("Failed to unbind endpoint: %v", err)
+	}
+	if len(*iptables) != 1 || !strings.HasPrefix((*iptables)[0], "-t filter -D I2P_ANTISPOOF -s 172.20.0.2 ") {
+		t.Errorf("Expected the endpoint rule to be deleted, got %v", *iptables)
+	}
+	if err := pm.UnbindEndpointAddress("ep1"); err != nil {
+		t.Errorf("Unbinding an unknown endpoint should not fail: %v", err)
+	}
+
+	*iptables, *ebtables = nil, nil
+	if err := pm.UnbindEndpointAddress("ep2"); err != nil {
+		t.Fatalf("Failed to unbind last endpoint: %v", err)
+	}
+	if last := (*iptables)[len(*iptables)-1]; last != "-t filter -X I2P_ANTISPOOF" {
+		t.Errorf("Expected the iptables chain to be deleted, got %v", *iptables)
+	}
+	if last := (*ebtables)[len(*ebtables)-1]; last != "-t filter -X I2P_ANTISPOOF" {
+		t.Errorf("Expected the ebtables chain to be deleted, got %v", *ebtables)
+	}
+}
+
+func TestProxyManager_BindEndpointAddressRollback(t *testing.T) {
+	t.Parallel()
+
+	pm, iptables := newBindTestManager(t)
+	var ebtables []string
+	pm.runEbtables = func(rule string) error {
+		ebtables = append(ebtables, rule)
+		if strings.Contains(rule, "--arp-ip-src") && strings.Contains(rule, "-A ") {
+			return fmt.Errorf("ebtables failed")
+		}
+		return nil
+	}
+
+	if err := pm.BindEndpointAddress("ep1", net.ParseIP("172.20.0.2"), "02:42:ac:14:00:02"); err == nil {
+		t.Fatal("Expected bind to fail")
+	}
+
+	// The IPv4 binding and iptables rule are removed again, then the chains
+	if !containsRule(ebtables, "-t filter -D I2P_ANTISPOOF -p IPv4 -s 02:42:ac:14:00:02 ! --ip-src 172.20.0.2 -j DROP") {
+		t.Errorf("Expected installed ebtables rule to be rolled back, got %v", ebtables)
+	}
+	if !containsRule(*iptables, "-t filter -D I2P_ANTISPOOF -s 172.20.0.2 -m mac ! --mac-source 02:42:ac:14:00:02 -j DROP") {
+		t.Errorf("Expected installed iptables rule to be rolled back, got %v", *iptables)
+	}
+	if !containsRule(*iptables, "-t filter -X I2P_ANTISPOOF") {
+		t.Errorf("Expected the chain to be removed, got %v", *iptables)
+	}
+	if len(pm.endpointBindings) != 0 {
+		t.Errorf("Expected no bindings, got %v", pm.endpointBindings)
+	}
+}
+
+func TestProxyManager_BindEndpointAddressInvalid(t *testing.T) {
+	t.Parallel()
+
+	pm, _ := newBindTestManager(t)
+	recordEbtables(pm)
+
+	if err := pm.BindEndpointAddress("ep1", net.ParseIP("172.20.0.2"), "not-a-mac"); err == nil {
+		t.Error("Expected error for invalid MAC address")
+	}
+	if err := pm.BindEndpointAddress("ep1", net.ParseIP("fd00::2"), "02:42:ac:14:00:02"); err == nil {
+		t.Error("Expected error for IPv6 address")
+	}
+}
+
+// containsRule reports whether rules contains rule.
+func containsRule(rules []string, rule string) bool {
+	for _, r := range rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,364 @@
+// Package proxy provides per-network proxy listeners.
+//
+// Containers reach the SOCKS proxy and DNS resolver through their network's
+// bridge, so listeners bound to 127.0.0.1 are unreachable to them, and the
+// iptables REDIRECT rules deliver intercepted traffic to the primary address
+// of the bridge, i.e. the network gateway. Each network therefore gets
+// listeners on its gateway address by default. Networks may instead bind to
+// the wildcard address, in which case firewall rules restrict the proxy ports
+// to container subnets, or to an explicit address.
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Proxy bind modes selected with the i2p.proxy.bind network option.
+const (
+	// BindGateway binds the proxy to the network's gateway address (default)
+	BindGateway = "gateway"
+	// BindAny binds the proxy to all addresses, scoped to container subnets by iptables
+	BindAny = "any"
+)
+
+// proxyInputChain is the iptables chain restricting wildcard proxy listeners.
+const proxyInputChain = "I2P_PROXY_INPUT"
+
+// ResolveBindIP returns the address a network's proxy listeners bind to.
+//
+// mode is BindGateway (or empty), BindAny or an IP address. Loopback addresses
+// are unreachable from containers; they are left over from configurations
+// predating per-network binding and are migrated to the gateway with a warning.
+func ResolveBindIP(mode string, gateway net.IP) (net.IP, error) {
+	mode = strings.TrimSpace(mode)
+
+	switch strings.ToLower(mode) {
+	case "", BindGateway:
+		if gateway == nil {
+			return nil, fmt.Errorf("network has no gateway to bind the proxy to")
+		}
+		return gateway, nil
+	case BindAny:
+		return net.IPv4zero, nil
+	case "localhost":
+		return migrateLoopbackBind(mode, gateway)
+	}
+
+	ip := net.ParseIP(mode)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid proxy bind %q (expected %s, %s or an IP address)", mode, BindGateway, BindAny)
+	}
+	if ip.IsLoopback() {
+		return migrateLoopbackBind(mode, gateway)
+	}
+	return ip, nil
+}
+
+// migrateLoopbackBind replaces a loopback bind address with the gateway.
+func migrateLoopbackBind(mode string, gateway net.IP) (net.IP, error) {
+	if gateway == nil {
+		return nil, fmt.Errorf("proxy bind %q is unreachable from containers and the network has no gateway", mode)
+	}
+	log.Printf("Warning: Proxy bind address %s is unreachable from containers, binding to gateway %s instead", mode, gateway)
+	return gateway, nil
+}
+
+// isLoopbackAddr reports whether a host:port address has a loopback host.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// migrateBindAddrs drops loopback fixed listener addresses from the config.
+//
+// Earlier versions bound the proxy to 127.0.0.1, which containers cannot
+// reach; per-network listeners replace those listeners.
+func (c *ProxyConfig) migrateBindAddrs() {
+	if c.SOCKSBindAddr != "" && isLoopbackAddr(c.SOCKSBindAddr) {
+		log.Printf("Warning: Ignoring SOCKS bind address %s, which containers cannot reach; using per-network listeners", c.SOCKSBindAddr)
+		c.SOCKSBindAddr = ""
+	}
+	if c.DNSBindAddr != "" && isLoopbackAddr(c.DNSBindAddr) {
+		log.Printf("Warning: Ignoring DNS bind address %s, which containers cannot reach; using per-network listeners", c.DNSBindAddr)
+		c.DNSBindAddr = ""
+	}
+}
+
+// networkListener is a SOCKS proxy and DNS resolver bound to one address.
+//
+// Networks sharing a bind address, such as several BindAny networks, share
+// one listener.
+type networkListener struct {
+	bindIP      net.IP
+	socksProxy  *SOCKSProxy
+	dnsResolver *I2PDNSResolver
+	// networks holds the subnets of the networks using the listener by ID
+	networks map[string]*net.IPNet
+}
+
+// AddNetworkListeners starts proxy listeners for a network on bindIP.
+//
+// Listeners on the wildcard address are restricted to the network's subnet by
+// iptables rules. Adding a network that already has listeners is an error.
+func (pm *ProxyManager) AddNetworkListeners(networkID string, subnet *net.IPNet, bindIP net.IP) error {
+	pm.listenerMutex.Lock()
+	defer pm.listenerMutex.Unlock()
+
+	if _, exists := pm.networkBinds[networkID]; exists {
+		return fmt.Errorf("proxy listeners for network %s already exist", networkID)
+	}
+
+	key := bindIP.String()
+	listener, exists := pm.listeners[key]
+	if !exists {
+		listener = &networkListener{
+			bindIP:   bindIP,
+			networks: make(map[string]*net.IPNet),
+		}
+		if err := pm.startNetworkListener(listener); err != nil {
+			return err
+		}
+		pm.listeners[key] = listener
+	}
+
+	if bindIP.IsUnspecified() {
+		if err := pm.allowProxyInput(subnet, len(pm.wildcardNetworks()) == 0); err != nil {
+			if len(listener.networks) == 0 {
+				pm.stopNetworkListener(listener)
+				delete(pm.listeners, key)
+			}
+			return fmt.Errorf("failed to restrict proxy listeners to %s: %w", subnet, err)
+		}
+	}
+
+	listener.networks[networkID] = subnet
+	pm.networkBinds[networkID] = key
+
+	log.Printf("Proxy for network %s listening on %s (SOCKS) and %s (DNS)", networkID,
+		net.JoinHostPort(key, strconv.Itoa(pm.config.SOCKSPort)), net.JoinHostPort(key, strconv.Itoa(pm.config.DNSPort)))
+	return nil
+}
+
+// RemoveNetworkListeners releases a network's proxy listeners.
+//
+// Listeners are stopped once no network uses them. Removing a network
+// without listeners is not an error.
+func (pm *ProxyManager) RemoveNetworkListeners(networkID string) error {
+	pm.listenerMutex.Lock()
+	defer pm.listenerMutex.Unlock()
+
+	key, exists := pm.networkBinds[networkID]
+	if !exists {
+		return nil
+	}
+	delete(pm.networkBinds, networkID)
+
+	listener := pm.listeners[key]
+	subnet := listener.networks[networkID]
+	delete(listener.networks, networkID)
+
+	var errors []string
+	if listener.bindIP.IsUnspecified() {
+		if err := pm.revokeProxyInput(subnet, len(pm.wildcardNetworks()) == 0); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+
+	if len(listener.networks) == 0 {
+		delete(pm.listeners, key)
+		errors = append(errors, pm.stopNetworkListener(listener)...)
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to remove proxy listeners of network %s: %s", networkID, strings.Join(errors, "; "))
+	}
+	return nil
+}
+
+// NetworkBindIP returns the address a network's proxy listeners are bound to.
+func (pm *ProxyManager) NetworkBindIP(networkID string) net.IP {
+	pm.listenerMutex.Lock()
+	defer pm.listenerMutex.Unlock()
+
+	if key, exists := pm.networkBinds[networkID]; exists {
+		return pm.listeners[key].bindIP
+	}
+	return nil
+}
+
+// wildcardNetworks returns the IDs of networks using the wildcard listener.
+// The caller must hold pm.listenerMutex.
+func (pm *ProxyManager) wildcardNetworks() []string {
+	var ids []string
+	for _, listener := range pm.listeners {
+		if listener.bindIP.IsUnspecified() {
+			for id := range listener.networks {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// startNetworkListener starts the SOCKS proxy and DNS resolver of a listener.
+//
+// Services stopped with StopSOCKS or StopDNS are created but not started.
+// Sockets are bound synchronously so address errors are reported to the
+// caller; serving happens in the background. The caller must hold
+// pm.listenerMutex.
+func (pm *ProxyManager) startNetworkListener(listener *networkListener) error {
+	host := listener.bindIP.String()
+	listener.socksProxy = pm.newSOCKSProxy(net.JoinHostPort(host, strconv.Itoa(pm.config.SOCKSPort)))
+	listener.dnsResolver = pm.newDNSResolver(net.JoinHostPort(host, strconv.Itoa(pm.config.DNSPort)))
+
+	if !pm.socksStopped {
+		if err := serveSOCKSProxy(listener.socksProxy); err != nil {
+			return err
+		}
+	}
+	if !pm.dnsStopped {
+		if err := serveDNSResolver(listener.dnsResolver); err != nil {
+			listener.socksProxy.Stop()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stopNetworkListener stops the SOCKS proxy and DNS resolver of a listener.
+func (pm *ProxyManager) stopNetworkListener(listener *networkListener) []string {
+	var errors []string
+	if err := listener.socksProxy.Stop(); err != nil {
+		errors = append(errors, fmt.Sprintf("SOCKS proxy on %s: %v", listener.bindIP, err))
+	}
+	if err := listener.dnsResolver.Stop(); err != nil {
+		errors = append(errors, fmt.Sprintf("DNS resolver on %s: %v", listener.bindIP, err))
+	}
+	return errors
+}
+
+// stopAllNetworkListeners stops every network listener.
+func (pm *ProxyManager) stopAllNetworkListeners() []string {
+	pm.listenerMutex.Lock()
+	defer pm.listenerMutex.Unlock()
+
+	var errors []string
+	if len(pm.wildcardNetworks()) > 0 {
+		if err := pm.revokeProxyInput(nil, true); err != nil {
+			errors = append(errors, fmt.Sprintf("proxy input rules cleanup failed: %v", err))
+		}
+	}
+	for _, listener := range pm.listeners {
+		errors = append(errors, pm.stopNetworkListener(listener)...)
+	}
+
+	pm.listeners = make(map[string]*networkListener)
+	pm.networkBinds = make(map[string]string)
+	return errors
+}
+
+// proxyInputChainRules returns the rules sending proxy traffic to the input chain.
+func (pm *ProxyManager) proxyInputChainRules() []string {
+	return []string{
+		fmt.Sprintf("-t filter -A INPUT -p tcp --dport %d -j %s", pm.config.SOCKSPort, proxyInputChain),
+		fmt.Sprintf("-t filter -A INPUT -p udp --dport %d -j %s", pm.config.DNSPort, proxyInputChain),
+		fmt.Sprintf("-t filter -A INPUT -p tcp --dport %d -j %s", pm.config.DNSPort, proxyInputChain),
+	}
+}
+
+// allowProxyInput lets a subnet reach the wildcard proxy listeners.
+//
+// The first wildcard network creates the input chain, which drops proxy
+// traffic from everywhere else.
+func (pm *ProxyManager) allowProxyInput(subnet *net.IPNet, first bool) error {
+	if first {
+		if err := pm.removeLeftoverChain(proxyInputIptablesChain); err != nil {
+			return err
+		}
+	}
+	rules := pm.proxyInputRules(subnet, first)
+	for i, rule := range rules {
+		if err := pm.runIptables(rule); err != nil {
+			if first && i < len(rules)-1 {
+				pm.revokeProxyInput(nil, true)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// proxyInputRules returns the rules allowProxyInput installs for a subnet.
+func (pm *ProxyManager) proxyInputRules(subnet *net.IPNet, first bool) []string {
+	var rules []string
+	if first {
+		rules = append([]string{
+			"-t filter -N " + proxyInputChain,
+			"-t filter -A " + proxyInputChain + " -j DROP",
+		}, pm.proxyInputChainRules()...)
+	}
+	return append(rules, fmt.Sprintf("-t filter -I %s 1 -s %s -j ACCEPT", proxyInputChain, subnet))
+}
+
+// PlanNetworkRules returns the firewall rules adding a network would install,
+// without running them, as the commands or script lines that would run.
+//
+// The interception rules, rendered by the firewall backend, are included when
+// startProxy is set, as they are installed when the proxy starts with the
+// first network.
+func (pm *ProxyManager) PlanNetworkRules(subnet *net.IPNet, bindIP net.IP, startProxy bool) []string {
+	var rules []string
+	if startProxy {
+		rules = append(rules, pm.interceptor.RenderRules()...)
+	}
+
+	if bindIP.IsUnspecified() {
+		pm.listenerMutex.Lock()
+		first := len(pm.wildcardNetworks()) == 0
+		pm.listenerMutex.Unlock()
+		rules = append(rules, pm.renderRules(FamilyIPv4, pm.proxyInputRules(subnet, first))...)
+	}
+	return rules
+}
+
+// revokeProxyInput removes a subnet's access to the wildcard proxy listeners.
+//
+// The input chain is removed with the last wildcard network.
+func (pm *ProxyManager) revokeProxyInput(subnet *net.IPNet, last bool) error {
+	var errors []string
+	if subnet != nil {
+		if err := pm.runIptables(fmt.Sprintf("-t filter -D %s -s %s -j ACCEPT", proxyInputChain, subnet)); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+
+	if last {
+		for _, rule := range pm.proxyInputChainRules() {
+			if err := pm.runIptables(strings.Replace(rule, "-A", "-D", 1)); err != nil {
+				errors = append(errors, err.Error())
+			}
+		}
+		for _, rule := range []string{"-t filter -F " + proxyInputChain, "-t filter -X " + proxyInputChain} {
+			if err := pm.runIptables(rule); err != nil {
+				errors = append(errors, err.Error())
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("%s", strings.Join(errors, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+func TestResolveBindIP(t *testing.T) {
+	gateway := net.ParseIP("172.20.0.1")
+
+	tests := []struct {
+		name     string
+		mode     string
+		gateway  net.IP
+		expected string
+		wantErr  bool
+	}{
+		{name: "default", mode: "", gateway: gateway, expected: "172.20.0.1"},
+		{name: "gateway", mode: "gateway", gateway: gateway, expected: "172.20.0.1"},
+		{name: "any", mode: " ANY ", gateway: gateway, expected: "0.0.0.0"},
+		{name: "explicit address", mode: "10.0.0.5", gateway: gateway, expected: "10.0.0.5"},
+		{name: "loopback migrated", mode: "127.0.0.1", gateway: gateway, expected: "172.20.0.1"},
+		{name: "localhost migrated", mode: "localhost", gateway: gateway, expected: "172.20.0.1"},
+		{name: "no gateway", mode: "gateway", wantErr: true},
+		{name: "loopback without gateway", mode: "127.0.0.1", wantErr: true},
+		{name: "invalid", mode: "bridge0", gateway: gateway, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, err := ResolveBindIP(tt.mode, tt.gateway)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %s", ip)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if ip.String() != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, ip)
+			}
+		})
+	}
+}
+
+func TestProxyConfigMigratesLoopbackBinds(t *testing.T) {
+	config := &ProxyConfig{SOCKSBindAddr: "127.0.0.1:1080", DNSBindAddr: "10.0.0.1:53"}
+	config.migrateBindAddrs()
+
+	if config.SOCKSBindAddr != "" {
+		t.Errorf("Expected loopback SOCKS bind address to be dropped, got %s", config.SOCKSBindAddr)
+	}
+	if config.DNSBindAddr != "10.0.0.1:53" {
+		t.Errorf("Expected non-loopback DNS bind address to be kept, got %s", config.DNSBindAddr)
+	}
+}
+
+// newBindTestManager returns a proxy manager on ephemeral ports that records
+// iptables rules instead of running them.
+func newBindTestManager(t *testing.T) (*ProxyManager, *[]string) {
+	t.Helper()
+
+	_, subnet, _ := net.ParseCIDR("172.20.0.0/16")
+	config := DefaultProxyConfig(subnet)
+	config.SOCKSPort = 0
+	config.DNSPort = 0
+
+	pm := NewProxyManager(ProxyOptions{Config: config, TunnelManager: i2p.NewTunnelManager(i2p.TunnelManagerOptions{})})
+	var rules []string
+	pm.runIptables = func(rule string) error {
+		rules = append(rules, rule)
+		return nil
+	}
+	pm.listRules = func(command, table, chain string) ([]string, error) {
+		return nil, errors.New("no chain by that name")
+	}
+	t.Cleanup(func() { pm.stopAllNetworkListeners() })
+	return pm, &rules
+}
+
+func TestProxyManager_NetworkListeners(t *testing.T) {
+	t.Parallel()
+
+	pm, rules := newBindTestManager(t)
+	_, subnet, _ := net.ParseCIDR("172.30.0.0/16")
+	loopback := net.ParseIP("127.0.0.1")
+
+	if err := pm.AddNetworkListeners("net1", subnet, loopback); err != nil {
+		t.Fatalf("Failed to add listeners: %v", err)
+	}
+	if err := pm.AddNetworkListeners("net1", subnet, loopback); err == nil {
+		t.Error("Expected error when adding listeners twice")
+	}
+	if ip := pm.NetworkBindIP("net1"); !ip.Equal(loopback) {
+		t.Errorf("Expected bind IP %s, got %s", loopback, ip)
+	}
+	if len(*rules) != 0 {
+		t.Errorf("Expected no firewall rules for an address bind, got %v", *rules)
+	}
+
+	if err := pm.RemoveNetworkListeners("net1"); err != nil {
+		t.Fatalf("Failed to remove listeners: %v", err)
+	}
+	if ip := pm.NetworkBindIP("net1"); ip != nil {
+		t.Errorf("Expected no bind IP after removal, got %s", ip)
+	}
+	if err := pm.RemoveNetworkListeners("net1"); err != nil {
+		t.Errorf("Removing unknown listeners should not fail: %v", err)
+	}
+}
+
+func TestProxyManager_WildcardListenersAreScoped(t *testing.T) {
+	t.Parallel()
+
+	pm, rules := newBindTestManager(t)
+	_, subnet1, _ := net.ParseCIDR("172.30.0.0/16")
+	_, subnet2, _ := net.ParseCIDR("172.31.0.0/16")
+
+	if err := pm.AddNetworkListeners("net1", subnet1, net.IPv4zero); err != nil {
+		t.Fatalf("Failed to add listeners: %v", err)
+	}
+	if err := pm.AddNetworkListeners("net2", subnet2, net.IPv4zero); err != nil {
+		t.Fatalf("Failed to add listeners: %v", err)
+	}
+	if len(pm.listeners) != 1 {
+		t.Errorf("Expected wildcard networks to share one listener, got %d", len(pm.listeners))
+	}
+
+	joined := strings.Join(*rules, "\n")
+	for _, expected := range []string{
+		"-t filter -N " + proxyInputChain,
+		"-t filter -A " + proxyInputChain + " -j DROP",
+		"-t filter -I " + proxyInputChain + " 1 -s 172.30.0.0/16 -j ACCEPT",
+		"-t filter -I " + proxyInputChain + " 1 -s 172.31.0.0/16 -j ACCEPT",
+	} {
+		if !strings.Contains(joined, expected) {
+			t.Errorf("Expected rule %q, got:\n%s", expected, joined)
+		}
+	}
+	if strings.Count(joined, "-N "+proxyInputChain) != 1 {
+		t.Errorf("Expected the input chain to be created once, got:\n%s", joined)
+	}
+
+	*rules = nil
+	if err := pm.RemoveNetworkListeners("net1"); err != nil {
+		t.Fatalf("Failed to remove listeners: %v", err)
+	}
+	if len(pm.listeners) != 1 || strings.Contains(strings.Join(*rules, "\n"), "-X "+proxyInputChain) {
+		t.Errorf("Expected the shared listener and chain to stay for net2, rules: %v", *rules)
+	}
+
+	if err := pm.RemoveNetworkListeners("net2"); err != nil {
+		t.Fatalf("Failed to remove listeners: %v", err)
+	}
+	if len(pm.listeners) != 0 {
+		t.Error("Expected the wildcard listener to stop with its last network")
+	}
+	if !strings.Contains(strings.Join(*rules, "\n"), "-t filter -X "+proxyInputChain) {
+		t.Errorf("Expected the input chain to be removed, got: %v", *rules)
+	}
+}
+
+func TestProxyManager_PlanNetworkRules(t *testing.T) {
+	t.Parallel()
+
+	pm, rules := newBindTestManager(t)
+	_, subnet1, _ := net.ParseCIDR("172.30.0.0/16")
+	_, subnet2, _ := net.ParseCIDR("172.31.0.0/16")
+
+	if planned := pm.PlanNetworkRules(subnet1, net.ParseIP("172.30.0.1"), false); len(planned) != 0 {
+		t.Errorf("Expected no rules for an address bind, got %v", planned)
+	}
+	if planned := pm.PlanNetworkRules(subnet1, net.ParseIP("172.30.0.1"), true); len(planned) == 0 {
+		t.Error("Expected interception rules when the proxy would start")
+	}
+
+	// The plan matches what adding the listeners installs
+	planned := pm.PlanNetworkRules(subnet1, net.IPv4zero, false)
+	if err := pm.AddNetworkListeners("net1", subnet1, net.IPv4zero); err != nil {
+		t.Fatalf("Failed to add listeners: %v", err)
+	}
+	if strings.Join(planned, "\n") != "iptables "+strings.Join(*rules, "\niptables ") {
+		t.Errorf("Expected planned rules %v, installed %v", planned, *rules)
+	}
+	if len(pm.PlanNetworkRules(subnet2, net.IPv4zero, false)) != 1 {
+		t.Error("Expected only the ACCEPT rule once the input chain exists")
+	}
+}
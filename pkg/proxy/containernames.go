@@ -0,0 +1,177 @@
+// Package proxy provides container names of networks.
+//
+// On bridge networks, Docker's embedded DNS server lets containers find each
+// other by name. Containers on I2P networks use the network's DNS resolver
+// instead, so it answers the names of their endpoints itself: every joined
+// endpoint registers its network aliases with its container addresses, and
+// A and AAAA queries for one of them from a client on the same network are
+// answered with the addresses of every endpoint using the name, much like
+// Docker round-robins aliases shared by several containers. Names are scoped
+// to their network; clients on other networks, and clients the resolver
+// cannot attribute to a network, get NXDOMAIN.
+package proxy
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// containerNameTTL is the TTL of container name answers. Containers get new
+// addresses when they rejoin, so answers are cached briefly.
+const containerNameTTL = 30
+
+// ContainerNames holds the container names of every network.
+//
+// One table is shared by every DNS resolver of a proxy manager, like
+// ExternalServices.
+type ContainerNames struct {
+	// endpoints maps network IDs to endpoint IDs to their names
+	endpoints map[string]map[string]containerName
+	// sourceResolver attributes client addresses to networks
+	sourceResolver SourceResolver
+	// mutex protects endpoints and sourceResolver
+	mutex sync.RWMutex
+}
+
+// containerName is the names and addresses of a joined endpoint.
+type containerName struct {
+	names []string
+	ipv4  net.IP
+	ipv6  net.IP
+}
+
+// NewContainerNames creates an empty container name table.
+func NewContainerNames() *ContainerNames {
+	return &ContainerNames{endpoints: make(map[string]map[string]containerName)}
+}
+
+// set replaces the names of an endpoint; no names remove it.
+func (c *ContainerNames) set(networkID, endpointID string, names []string, ipv4, ipv6 net.IP) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(names) == 0 || ipv4 == nil && ipv6 == nil {
+		delete(c.endpoints[networkID], endpointID)
+		if len(c.endpoints[networkID]) == 0 {
+			delete(c.endpoints, networkID)
+		}
+		return
+	}
+
+	lowered := make([]string, len(names))
+	for i, name := range names {
+		lowered[i] = strings.ToLower(strings.TrimSuffix(name, "."))
+	}
+	if c.endpoints[networkID] == nil {
+		c.endpoints[networkID] = make(map[string]containerName)
+	}
+	c.endpoints[networkID][endpointID] = containerName{names: lowered, ipv4: ipv4, ipv6: ipv6}
+}
+
+// removeNetwork removes the names of every endpoint of a network.
+func (c *ContainerNames) removeNetwork(networkID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.endpoints, networkID)
+}
+
+// setSourceResolver sets the resolver attributing clients to networks.
+func (c *ContainerNames) setSourceResolver(resolver SourceResolver) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.sourceResolver = resolver
+}
+
+// lookup returns the addresses of the endpoints named name on the network of
+// the client at ip, sorted so answers are stable. found is false if no
+// endpoint there has the name.
+func (c *ContainerNames) lookup(ip net.IP, name string) (ipv4, ipv6 []net.IP, found bool) {
+	if c == nil || ip == nil {
+		return nil, nil, false
+	}
+
+	c.mutex.RLock()
+	resolver := c.sourceResolver
+	c.mutex.RUnlock()
+	if resolver == nil {
+		return nil, nil, false
+	}
+	source, ok := resolver(ip)
+	if !ok {
+		return nil, nil, false
+	}
+
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	for _, endpoint := range c.endpoints[source.NetworkID] {
+		for _, endpointName := range endpoint.names {
+			if endpointName != name {
+				continue
+			}
+			found = true
+			if endpoint.ipv4 != nil {
+				ipv4 = append(ipv4, endpoint.ipv4)
+			}
+			if endpoint.ipv6 != nil {
+				ipv6 = append(ipv6, endpoint.ipv6)
+			}
+			break
+		}
+	}
+	sortIPs(ipv4)
+	sortIPs(ipv6)
+	return ipv4, ipv6, found
+}
+
+// sortIPs sorts addresses of the same family in byte order.
+func sortIPs(ips []net.IP) {
+	sort.Slice(ips, func(i, j int) bool { return string(ips[i]) < string(ips[j]) })
+}
+
+// answerContainerName answers a query for a container name on the network of
+// the client at ip, or returns nil if the query is not for one. Names without
+// an address of the requested type get an empty answer.
+func (r *I2PDNSResolver) answerContainerName(req *dns.Msg, ip net.IP) *dns.Msg {
+	if r.names == nil || len(req.Question) != 1 {
+		return nil
+	}
+	question := req.Question[0]
+	ipv4, ipv6, found := r.names.lookup(ip, question.Name)
+	if !found {
+		return nil
+	}
+
+	msg := new(dns.Msg)
+	msg.SetReply(req)
+	msg.Authoritative = true
+
+	switch question.Qtype {
+	case dns.TypeA:
+		for _, address := range ipv4 {
+			header := dns.RR_Header{Name: question.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: containerNameTTL}
+			msg.Answer = append(msg.Answer, &dns.A{Hdr: header, A: address.To4()})
+		}
+	case dns.TypeAAAA:
+		for _, address := range ipv6 {
+			header := dns.RR_Header{Name: question.Name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: containerNameTTL}
+			msg.Answer = append(msg.Answer, &dns.AAAA{Hdr: header, AAAA: address})
+		}
+	}
+	return msg
+}
+
+// SetContainerNames replaces the names a joined endpoint is found under by
+// clients on its network. No names remove the endpoint's names.
+func (pm *ProxyManager) SetContainerNames(networkID, endpointID string, names []string, ipv4, ipv6 net.IP) {
+	pm.names.set(networkID, endpointID, names, ipv4, ipv6)
+}
+
+// RemoveContainerNames removes the names of every endpoint of a network.
+func (pm *ProxyManager) RemoveContainerNames(networkID string) {
+	pm.names.removeNetwork(networkID)
+}
@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestContainerNamesScopedToNetwork(t *testing.T) {
+	networks := map[string]string{"127.0.0.1": "net1", "172.20.2.5": "net2"}
+
+	pm := NewProxyManager(ProxyOptions{})
+	pm.SetSourceResolver(func(ip net.IP) (ClientSource, bool) {
+		networkID, ok := networks[ip.String()]
+		return ClientSource{NetworkID: networkID}, ok
+	})
+	pm.SetContainerNames("net1", "ep1", []string{"web", "frontend"}, net.ParseIP("172.20.1.3"), net.ParseIP("fd00::3"))
+	pm.SetContainerNames("net1", "ep2", []string{"Web."}, net.ParseIP("172.20.1.2"), nil)
+	pm.SetContainerNames("net2", "ep3", []string{"db"}, net.ParseIP("172.20.2.2"), nil)
+
+	pm.listenerMutex.Lock()
+	resolver := pm.newDNSResolver("127.0.0.1:0")
+	pm.listenerMutex.Unlock()
+	if err := resolver.Listen(); err != nil {
+		t.Fatalf("Listen() unexpected error: %v", err)
+	}
+	defer resolver.Stop()
+	go resolver.Serve()
+	server := resolver.server.PacketConn.LocalAddr().String()
+
+	query := func(name string, qtype uint16) *dns.Msg {
+		req := new(dns.Msg)
+		req.SetQuestion(name, qtype)
+		resp, _, err := (&dns.Client{Net: "udp"}).Exchange(req, server)
+		if err != nil {
+			t.Fatalf("Query for %s failed: %v", name, err)
+		}
+		return resp
+	}
+
+	resp := query("WEB.", dns.TypeA)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 2 {
+		t.Fatalf("Expected both endpoints named web, got %v", resp)
+	}
+	if first := resp.Answer[0].(*dns.A); !first.A.Equal(net.ParseIP("172.20.1.2")) || first.Hdr.Name != "WEB." {
+		t.Errorf("Expected sorted answers for the queried name, got %v", resp.Answer)
+	}
+
+	resp = query("web.", dns.TypeAAAA)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 || !resp.Answer[0].(*dns.AAAA).AAAA.Equal(net.ParseIP("fd00::3")) {
+		t.Errorf("Expected the IPv6 address of the endpoint that has one, got %v", resp)
+	}
+
+	resp = query("frontend.", dns.TypeMX)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Errorf("Expected an empty answer for other record types, got %v", resp)
+	}
+
+	// Names of other networks do not exist for the client
+	if resp := query("db.", dns.TypeA); resp.Rcode != dns.RcodeNameError {
+		t.Errorf("Expected NXDOMAIN for a name of another network, got %v", resp)
+	}
+	if _, _, found := pm.names.lookup(net.ParseIP("172.20.2.5"), "db"); !found {
+		t.Error("Expected clients on net2 to find db")
+	}
+	if _, _, found := pm.names.lookup(net.ParseIP("10.0.0.1"), "web"); found {
+		t.Error("Expected clients outside managed networks not to find names")
+	}
+
+	pm.SetContainerNames("net1", "ep2", nil, nil, nil)
+	if resp := query("web.", dns.TypeA); len(resp.Answer) != 1 {
+		t.Errorf("Expected the removed endpoint to be dropped, got %v", resp.Answer)
+	}
+	pm.RemoveContainerNames("net1")
+	if resp := query("web.", dns.TypeA); resp.Rcode != dns.RcodeNameError {
+		t.Errorf("Expected NXDOMAIN after the network was removed, got %v", resp)
+	}
+}
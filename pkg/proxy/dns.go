@@ -2,8 +2,14 @@ package proxy
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/miekg/dns"
@@ -16,8 +22,24 @@ import (
 type I2PDNSResolver struct {
 	// listenAddr is the address where the DNS resolver listens
 	listenAddr string
-	// server is the DNS server instance
+	// server is the UDP DNS server instance
 	server *dns.Server
+	// tcpServer is the TCP DNS server instance
+	tcpServer *dns.Server
+	// mutex protects server and tcpServer
+	mutex sync.Mutex
+	// namingResolver looks up I2P hostnames (nil answers every .i2p name)
+	namingResolver NamingResolver
+	// namingMutex protects namingResolver
+	namingMutex sync.RWMutex
+	// addresses hands out synthetic addresses and remembers their names
+	addresses *SyntheticAddresses
+	// shedder admits queries within the query limits (nil answers all)
+	shedder *dnsLoadShedder
+	// external holds the external services of networks (nil answers none)
+	external *ExternalServices
+	// names holds the container names of networks (nil answers none)
+	names *ContainerNames
 	// ctx is the context for resolver operation
 	ctx context.Context
 	// cancel cancels the resolver context
@@ -30,58 +52,126 @@ type I2PDNSResolver struct {
 // for I2P destinations while blocking all other queries.
 func NewI2PDNSResolver(listenAddr string) *I2PDNSResolver {
 	ctx, cancel := context.WithCancel(context.Background())
+	addresses, _ := NewSyntheticAddresses(AddressModeIPv4)
 
 	return &I2PDNSResolver{
 		listenAddr: listenAddr,
+		addresses:  addresses,
 		ctx:        ctx,
 		cancel:     cancel,
 	}
 }
 
+// SetSyntheticAddresses sets the table synthetic addresses are handed out from.
+//
+// Resolvers and SOCKS proxies sharing a table can translate each other's
+// addresses. It must be called before the resolver serves queries.
+func (r *I2PDNSResolver) SetSyntheticAddresses(addresses *SyntheticAddresses) {
+	if addresses != nil {
+		r.addresses = addresses
+	}
+}
+
 // Start begins the DNS resolver service.
 //
 // This method blocks until the resolver is stopped or an error occurs.
 // It should be run in a goroutine for non-blocking operation.
 func (r *I2PDNSResolver) Start() error {
+	if err := r.Listen(); err != nil {
+		return err
+	}
+	return r.Serve()
+}
+
+// Listen binds the resolver's UDP and TCP sockets without serving them.
+//
+// Binding separately from Serve lets callers report address errors
+// synchronously before serving in the background.
+func (r *I2PDNSResolver) Listen() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.ctx.Err() != nil {
+		return r.ctx.Err()
+	}
+
+	packetConn, err := net.ListenPacket("udp", r.listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s/udp: %w", r.listenAddr, err)
+	}
+
+	// Also handle TCP queries, on the port the UDP socket was bound to
+	listener, err := net.Listen("tcp", packetConn.LocalAddr().String())
+	if err != nil {
+		packetConn.Close()
+		return fmt.Errorf("failed to listen on %s/tcp: %w", r.listenAddr, err)
+	}
+
 	mux := dns.NewServeMux()
 	mux.HandleFunc(".", r.handleDNSQuery)
 
 	r.server = &dns.Server{
-		Addr:    r.listenAddr,
-		Net:     "udp",
-		Handler: mux,
+		PacketConn: packetConn,
+		Handler:    mux,
 		// Enable timeout to allow graceful shutdown
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 5 * time.Second,
 	}
-
-	// Also handle TCP queries
-	tcpServer := &dns.Server{
-		Addr:         r.listenAddr,
-		Net:          "tcp",
+	r.tcpServer = &dns.Server{
+		Listener:     listener,
 		Handler:      mux,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 5 * time.Second,
 	}
 
+	return nil
+}
+
+// Serve answers queries on the sockets bound by Listen.
+//
+// It blocks until the resolver is stopped, which is not treated as an error.
+func (r *I2PDNSResolver) Serve() error {
+	r.mutex.Lock()
+	server, tcpServer := r.server, r.tcpServer
+	r.mutex.Unlock()
+
+	if server == nil {
+		return fmt.Errorf("DNS resolver is not listening")
+	}
+
 	// Start TCP server in background
 	go func() {
-		if err := tcpServer.ListenAndServe(); err != nil {
+		if err := tcpServer.ActivateAndServe(); err != nil {
 			// Log error but don't fail UDP server
 		}
 	}()
 
-	return r.server.ListenAndServe()
+	if err := server.ActivateAndServe(); err != nil && r.ctx.Err() == nil {
+		return err
+	}
+	return nil
 }
 
 // Stop gracefully shuts down the DNS resolver.
+//
+// The sockets are closed even if serving has not started yet.
 func (r *I2PDNSResolver) Stop() error {
 	r.cancel()
 
-	if r.server != nil {
-		return r.server.Shutdown()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.server == nil {
+		return nil
 	}
 
+	// Shutdown fails for servers that have not started serving yet; closing
+	// the sockets stops them either way
+	r.server.Shutdown()
+	r.tcpServer.Shutdown()
+	r.server.PacketConn.Close()
+	r.tcpServer.Listener.Close()
+
 	return nil
 }
 
@@ -89,27 +179,83 @@ func (r *I2PDNSResolver) Stop() error {
 //
 // This method implements the core DNS resolution logic for I2P domains.
 func (r *I2PDNSResolver) handleDNSQuery(w dns.ResponseWriter, req *dns.Msg) {
+	// Queries over the rate limits are failed before any lookup is made
+	if r.shedder != nil {
+		if admitted, _ := r.shedder.admit(remoteIP(w.RemoteAddr())); !admitted {
+			msg := new(dns.Msg)
+			msg.SetRcode(req, dns.RcodeServerFailure)
+			w.WriteMsg(msg)
+			return
+		}
+	}
+
+	// External services and container names are only known to clients on
+	// their network
+	msg := r.answerExternalService(req, remoteIP(w.RemoteAddr()))
+	if msg == nil {
+		msg = r.answerContainerName(req, remoteIP(w.RemoteAddr()))
+	}
+	if msg == nil {
+		msg = r.buildResponse(req)
+	}
+
+	// TXT records carrying a full destination exceed the classic UDP message
+	// size; truncated answers make clients retry over TCP
+	if _, udp := w.RemoteAddr().(*net.UDPAddr); udp {
+		size := dns.MinMsgSize
+		if opt := req.IsEdns0(); opt != nil && int(opt.UDPSize()) > size {
+			size = int(opt.UDPSize())
+		}
+		msg.Truncate(size)
+	}
+
+	w.WriteMsg(msg)
+}
+
+// remoteIP returns the IP address of a DNS client, or nil if it has none.
+func remoteIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.UDPAddr:
+		return addr.IP
+	case *net.TCPAddr:
+		return addr.IP
+	}
+	return nil
+}
+
+// buildResponse builds the reply to a DNS query.
+//
+// Queries arrive from untrusted containers, so anything other than a single
+// question is answered with FORMERR rather than resolved piecemeal.
+func (r *I2PDNSResolver) buildResponse(req *dns.Msg) *dns.Msg {
 	msg := new(dns.Msg)
+
+	if len(req.Question) != 1 {
+		msg.SetRcode(req, dns.RcodeFormatError)
+		return msg
+	}
+
 	msg.SetReply(req)
 	msg.Authoritative = true
 
-	// Process each question in the query
-	for _, question := range req.Question {
-		if answer := r.resolveQuestion(question); answer != nil {
-			msg.Answer = append(msg.Answer, answer)
-		} else {
-			// Return NXDOMAIN for non-I2P queries
-			msg.Rcode = dns.RcodeNameError
-		}
+	answers, exists := r.resolveQuestion(req.Question[0])
+	if len(answers) > 0 {
+		msg.Answer = append(msg.Answer, answers...)
+	} else if !exists {
+		// Return NXDOMAIN for non-I2P queries
+		msg.Rcode = dns.RcodeNameError
 	}
+	// Existing names without a record of the requested type get an empty
+	// answer, so stub resolvers still try the other address family
 
-	w.WriteMsg(msg)
+	return msg
 }
 
 // resolveQuestion resolves a single DNS question.
 //
-// Returns a DNS resource record if the question can be answered, nil otherwise.
-func (r *I2PDNSResolver) resolveQuestion(question dns.Question) dns.RR {
+// Returns the DNS resource records answering the question, none if it cannot
+// be answered, and whether the name exists at all.
+func (r *I2PDNSResolver) resolveQuestion(question dns.Question) ([]dns.RR, bool) {
 	name := strings.ToLower(question.Name)
 
 	// Remove trailing dot if present
@@ -117,22 +263,49 @@ func (r *I2PDNSResolver) resolveQuestion(question dns.Question) dns.RR {
 		name = name[:len(name)-1]
 	}
 
+	// Reverse lookups only exist for synthetic addresses
+	if ip, reverse := parseReverseName(name); reverse {
+		return r.resolvePTR(ip, question)
+	}
+
 	// Only handle I2P domains
 	if !r.isI2PDomain(name) {
-		return nil
+		return nil, false
+	}
+
+	// Names no naming backend knows do not exist
+	destination, err := r.lookupName(name)
+	if err != nil {
+		if !errors.Is(err, ErrNameNotFound) {
+			log.Printf("Warning: %v", err)
+		}
+		return nil, false
 	}
 
+	// Names are answered in the configured address family only
+	ipv6 := r.addresses.Mode() == AddressModeIPv6
+
 	switch question.Qtype {
 	case dns.TypeA:
-		return r.resolveA(name, question.Name)
+		if ipv6 {
+			return nil, true
+		}
+		return []dns.RR{r.resolveA(name, question.Name)}, true
 	case dns.TypeAAAA:
-		// Return empty response for IPv6 (I2P doesn't use IPv6 addresses)
-		return nil
+		if !ipv6 {
+			return nil, true
+		}
+		return []dns.RR{r.resolveAAAA(name, question.Name)}, true
+	case dns.TypeTXT:
+		return resolveTXT(name, destination, question.Name), true
 	case dns.TypeCNAME:
-		return r.resolveCNAME(name, question.Name)
+		if answer := r.resolveCNAME(name, question.Name); answer != nil {
+			return []dns.RR{answer}, true
+		}
+		return nil, true
 	default:
 		// Unsupported query type
-		return nil
+		return nil, true
 	}
 }
 
@@ -153,6 +326,12 @@ func (r *I2PDNSResolver) isI2PDomain(domain string) bool {
 	return false
 }
 
+// SyntheticIPRange is the address range the DNS resolver answers I2P names from.
+//
+// Container networks must not overlap it, or intercepted I2P traffic and
+// local traffic become indistinguishable.
+const SyntheticIPRange = "198.18.0.0/15"
+
 // resolveA creates an A record response for I2P domains.
 //
 // I2P domains are resolved to a special IP address that will be intercepted
@@ -165,6 +344,7 @@ func (r *I2PDNSResolver) resolveA(domain, originalName string) dns.RR {
 	// Generate a consistent IP based on domain hash to ensure
 	// the same domain always gets the same IP
 	ip := r.generateI2PIP(domain)
+	r.addresses.record(ip, domain)
 
 	return &dns.A{
 		Hdr: dns.RR_Header{
@@ -177,6 +357,89 @@ func (r *I2PDNSResolver) resolveA(domain, originalName string) dns.RR {
 	}
 }
 
+// resolveAAAA creates an AAAA record response for I2P domains in IPv6 mode.
+func (r *I2PDNSResolver) resolveAAAA(domain, originalName string) dns.RR {
+	ip := generateI2PIPv6(domain)
+	r.addresses.record(ip, domain)
+
+	return &dns.AAAA{
+		Hdr: dns.RR_Header{
+			Name:   originalName,
+			Rrtype: dns.TypeAAAA,
+			Class:  dns.ClassINET,
+			Ttl:    300, // 5 minutes TTL
+		},
+		AAAA: ip,
+	}
+}
+
+// Resolve returns the synthetic address for an I2P domain.
+//
+// This is the same address the DNS server answers with, for consumers that
+// reach the resolver over the HTTP API instead of DNS.
+func (r *I2PDNSResolver) Resolve(name string) (net.IP, error) {
+	domain := strings.TrimSuffix(strings.ToLower(name), ".")
+
+	if !r.isI2PDomain(domain) {
+		return nil, fmt.Errorf("%s is not an I2P domain", name)
+	}
+
+	if _, err := r.lookupName(domain); err != nil {
+		return nil, err
+	}
+
+	ip := r.generateI2PIP(domain)
+	if r.addresses.Mode() == AddressModeIPv6 {
+		ip = generateI2PIPv6(domain)
+	}
+	r.addresses.record(ip, domain)
+	return ip, nil
+}
+
+// SetNamingResolver sets the resolver used to check that I2P hostnames exist.
+//
+// A nil resolver answers every .i2p name.
+func (r *I2PDNSResolver) SetNamingResolver(resolver NamingResolver) {
+	r.namingMutex.Lock()
+	defer r.namingMutex.Unlock()
+
+	r.namingResolver = resolver
+}
+
+// lookupName checks domain against the naming resolver, if one is set, and
+// returns the destination it names (domain itself without a resolver).
+func (r *I2PDNSResolver) lookupName(domain string) (string, error) {
+	r.namingMutex.RLock()
+	resolver := r.namingResolver
+	r.namingMutex.RUnlock()
+
+	return lookupDestination(resolver, domain)
+}
+
+// ServeHTTP implements a minimal HTTP resolution API.
+//
+// GET /resolve?name=example.i2p returns the synthetic address as JSON.
+// Non-I2P names are rejected with 404 to prevent DNS leaks.
+func (r *I2PDNSResolver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if req.Method != http.MethodGet || req.URL.Path != "/resolve" {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "not found"})
+		return
+	}
+
+	name := req.URL.Query().Get("name")
+	ip, err := r.Resolve(name)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"name": name, "address": ip.String()})
+}
+
 // resolveCNAME handles CNAME queries for I2P domains.
 //
 // This is mainly for handling subdomain redirects within I2P.
@@ -0,0 +1,226 @@
+// Package proxy provides load shedding for the DNS resolvers.
+//
+// A container running a misconfigured resolver loop can send thousands of
+// queries a second, and every query occupies one of the DNS server's
+// goroutines while the naming backends are consulted. Queries are therefore
+// admitted through two token buckets: one per source address, so a single
+// container cannot starve its neighbours, and one shared by all resolvers of
+// the manager, which caps the total query rate. Queries over either limit are
+// answered with SERVFAIL straight away, which stub resolvers treat as a
+// transient failure, and are counted by reason.
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Reasons a DNS query was shed, as reported in DNSLoadStats.
+const (
+	// DNSShedSource marks queries over the per-source rate limit
+	DNSShedSource = "source"
+	// DNSShedGlobal marks queries over the global rate limit
+	DNSShedGlobal = "global"
+)
+
+// dnsSourceIdle is how long a source's bucket is kept after its last query.
+// A bucket idle this long has refilled anyway, so dropping it loses nothing.
+const dnsSourceIdle = time.Minute
+
+// DNSQueryLimits defines the query rates the DNS resolvers answer.
+type DNSQueryLimits struct {
+	// SourceQPS is the sustained queries per second answered per source
+	// address (0 disables the per-source limit)
+	SourceQPS float64
+	// SourceBurst is the number of queries a source may send above SourceQPS
+	SourceBurst int
+	// GlobalQPS is the sustained queries per second answered by all
+	// resolvers together (0 disables the global limit)
+	GlobalQPS float64
+	// GlobalBurst is the number of queries answered above GlobalQPS
+	GlobalBurst int
+}
+
+// DefaultDNSQueryLimits returns the default DNS query limits.
+//
+// A container resolving names normally stays far below the per-source rate,
+// even when opening many connections at once.
+func DefaultDNSQueryLimits() DNSQueryLimits {
+	return DNSQueryLimits{
+		SourceQPS:   50,
+		SourceBurst: 100,
+		GlobalQPS:   1000,
+		GlobalBurst: 2000,
+	}
+}
+
+// Validate checks the query limits for consistency.
+func (l DNSQueryLimits) Validate() error {
+	if l.SourceQPS < 0 || l.GlobalQPS < 0 {
+		return fmt.Errorf("DNS query rates cannot be negative")
+	}
+	if l.SourceQPS > 0 && l.SourceBurst < 1 {
+		return fmt.Errorf("DNS source burst must be at least 1 when the source limit is enabled")
+	}
+	if l.GlobalQPS > 0 && l.GlobalBurst < 1 {
+		return fmt.Errorf("DNS global burst must be at least 1 when the global limit is enabled")
+	}
+	return nil
+}
+
+// DNSLoadStats counts the queries seen by the DNS resolvers.
+type DNSLoadStats struct {
+	// Queries counts the queries received
+	Queries uint64 `json:"queries"`
+	// Shed counts the queries answered with SERVFAIL by reason
+	// (DNSShedSource or DNSShedGlobal)
+	Shed map[string]uint64 `json:"shed"`
+	// Sources is the number of source addresses currently tracked
+	Sources int `json:"sources"`
+}
+
+// dnsBucket is a token bucket of DNS queries.
+type dnsBucket struct {
+	tokens float64
+	// last is when tokens were last refilled
+	last time.Time
+}
+
+// take refills the bucket for the time elapsed since its last use and takes
+// a token, returning false if none is available.
+func (b *dnsBucket) take(now time.Time, rate float64, burst int) bool {
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// dnsLoadShedder admits DNS queries within the configured limits.
+//
+// It is shared by every resolver of a proxy manager, so the global limit
+// covers all networks together.
+type dnsLoadShedder struct {
+	mutex  sync.Mutex
+	limits DNSQueryLimits
+	global dnsBucket
+	// sources holds the per-source buckets by address
+	sources map[string]*dnsBucket
+	// lastSweep is when idle source buckets were last removed
+	lastSweep time.Time
+	queries   uint64
+	shed      map[string]uint64
+	now       func() time.Time
+}
+
+// newDNSLoadShedder creates a shedder with the given limits.
+func newDNSLoadShedder(limits DNSQueryLimits) *dnsLoadShedder {
+	s := &dnsLoadShedder{
+		sources: make(map[string]*dnsBucket),
+		shed:    make(map[string]uint64),
+		now:     time.Now,
+	}
+	s.setLimits(limits)
+	return s
+}
+
+// setLimits replaces the query limits. All buckets start out full again.
+func (s *dnsLoadShedder) setLimits(limits DNSQueryLimits) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	s.limits = limits
+	s.global = dnsBucket{tokens: float64(limits.GlobalBurst), last: now}
+	s.sources = make(map[string]*dnsBucket)
+	s.lastSweep = now
+}
+
+// admit records a query from source and reports whether it may be answered,
+// and if not, the reason it is shed.
+//
+// The per-source limit is checked first, so a flooding source does not use
+// up the global budget of everyone else.
+func (s *dnsLoadShedder) admit(source net.IP) (bool, string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	s.queries++
+	s.sweep(now)
+
+	if s.limits.SourceQPS > 0 && source != nil {
+		key := source.String()
+		bucket, ok := s.sources[key]
+		if !ok {
+			bucket = &dnsBucket{tokens: float64(s.limits.SourceBurst), last: now}
+			s.sources[key] = bucket
+		}
+		if !bucket.take(now, s.limits.SourceQPS, s.limits.SourceBurst) {
+			s.shed[DNSShedSource]++
+			return false, DNSShedSource
+		}
+	}
+
+	if s.limits.GlobalQPS > 0 && !s.global.take(now, s.limits.GlobalQPS, s.limits.GlobalBurst) {
+		s.shed[DNSShedGlobal]++
+		return false, DNSShedGlobal
+	}
+	return true, ""
+}
+
+// sweep removes source buckets idle for dnsSourceIdle, at most once per
+// dnsSourceIdle. The caller must hold s.mutex.
+func (s *dnsLoadShedder) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < dnsSourceIdle {
+		return
+	}
+	s.lastSweep = now
+
+	for key, bucket := range s.sources {
+		if now.Sub(bucket.last) >= dnsSourceIdle {
+			delete(s.sources, key)
+		}
+	}
+}
+
+// stats returns the query counters.
+func (s *dnsLoadShedder) stats() DNSLoadStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	shed := make(map[string]uint64, 2)
+	shed[DNSShedSource] = s.shed[DNSShedSource]
+	shed[DNSShedGlobal] = s.shed[DNSShedGlobal]
+
+	return DNSLoadStats{
+		Queries: s.queries,
+		Shed:    shed,
+		Sources: len(s.sources),
+	}
+}
+
+// SetDNSQueryLimits configures the query rates the DNS resolvers answer.
+//
+// May be called while the proxy runs; the limits apply to all resolvers,
+// including those of networks added later.
+func (pm *ProxyManager) SetDNSQueryLimits(limits DNSQueryLimits) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+	pm.dnsShedder.setLimits(limits)
+	return nil
+}
+
+// GetDNSLoadStats returns how many DNS queries were received and shed.
+func (pm *ProxyManager) GetDNSLoadStats() DNSLoadStats {
+	return pm.dnsShedder.stats()
+}
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestDNSQueryLimits_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  DNSQueryLimits
+		wantErr bool
+	}{
+		{name: "defaults", limits: DefaultDNSQueryLimits()},
+		{name: "disabled", limits: DNSQueryLimits{}},
+		{name: "negative rate", limits: DNSQueryLimits{SourceQPS: -1}, wantErr: true},
+		{name: "missing source burst", limits: DNSQueryLimits{SourceQPS: 1}, wantErr: true},
+		{name: "missing global burst", limits: DNSQueryLimits{GlobalQPS: 1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.limits.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDNSLoadShedder_Admit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	shedder := newDNSLoadShedder(DNSQueryLimits{})
+	shedder.now = func() time.Time { return now }
+	shedder.setLimits(DNSQueryLimits{SourceQPS: 1, SourceBurst: 2, GlobalQPS: 1, GlobalBurst: 3})
+
+	flooder := net.ParseIP("172.20.0.2")
+	other := net.ParseIP("172.20.0.3")
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := shedder.admit(flooder); !ok {
+			t.Fatalf("Query %d within the burst was shed", i)
+		}
+	}
+	if ok, reason := shedder.admit(flooder); ok || reason != DNSShedSource {
+		t.Errorf("Expected the flooding source to be shed, got %v %q", ok, reason)
+	}
+
+	// The flooder's shed query did not use up the global budget
+	if ok, _ := shedder.admit(other); !ok {
+		t.Errorf("Expected another source to be answered")
+	}
+	if ok, reason := shedder.admit(other); ok || reason != DNSShedGlobal {
+		t.Errorf("Expected the global limit to shed, got %v %q", ok, reason)
+	}
+
+	// Buckets refill over time
+	now = now.Add(2 * time.Second)
+	if ok, _ := shedder.admit(flooder); !ok {
+		t.Errorf("Expected the source to be answered after refilling")
+	}
+
+	stats := shedder.stats()
+	if stats.Queries != 6 || stats.Shed[DNSShedSource] != 1 || stats.Shed[DNSShedGlobal] != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// Idle sources are forgotten
+	now = now.Add(2 * dnsSourceIdle)
+	shedder.admit(other)
+	if stats := shedder.stats(); stats.Sources != 1 {
+		t.Errorf("Expected idle sources to be removed, got %d", stats.Sources)
+	}
+}
+
+func TestI2PDNSResolver_ShedsFloodWithServfail(t *testing.T) {
+	pm := NewProxyManager(ProxyOptions{})
+	if err := pm.SetDNSQueryLimits(DNSQueryLimits{SourceQPS: 0.001, SourceBurst: 1}); err != nil {
+		t.Fatalf("SetDNSQueryLimits() unexpected error: %v", err)
+	}
+
+	pm.listenerMutex.Lock()
+	resolver := pm.newDNSResolver("127.0.0.1:0")
+	pm.listenerMutex.Unlock()
+	if err := resolver.Listen(); err != nil {
+		t.Fatalf("Listen() unexpected error: %v", err)
+	}
+	defer resolver.Stop()
+	go resolver.Serve()
+	address := resolver.server.PacketConn.LocalAddr().String()
+
+	req := new(dns.Msg)
+	req.SetQuestion("forum.i2p.", dns.TypeA)
+
+	client := &dns.Client{Net: "udp"}
+	resp, _, err := client.Exchange(req, address)
+	if err != nil {
+		t.Fatalf("First query failed: %v", err)
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		t.Errorf("Expected the first query to be answered, got %s", dns.RcodeToString[resp.Rcode])
+	}
+
+	resp, _, err = client.Exchange(req, address)
+	if err != nil {
+		t.Fatalf("Second query failed: %v", err)
+	}
+	if resp.Rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected SERVFAIL over the limit, got %s", dns.RcodeToString[resp.Rcode])
+	}
+
+	if stats := pm.GetDNSLoadStats(); stats.Shed[DNSShedSource] != 1 {
+		t.Errorf("Expected one shed query, got %+v", stats)
+	}
+
+	if err := pm.SetDNSQueryLimits(DNSQueryLimits{GlobalQPS: -1}); err == nil {
+		t.Errorf("Expected invalid limits to be rejected")
+	}
+}
@@ -0,0 +1,130 @@
+// Package proxy provides the reverse (PTR) and TXT records of the DNS resolver.
+//
+// Synthetic addresses hide which I2P name a connection goes to, which makes
+// debugging inside containers hard. The resolver therefore answers reverse
+// lookups of synthetic addresses with the name they were handed out for, so
+// `dig -x` and `getent hosts <address>` reveal the mapping, and answers TXT
+// queries for I2P names with the b32 address and full destination the name
+// resolves to. Reverse lookups of any other address get NXDOMAIN like every
+// other non-I2P name, so they never leak.
+package proxy
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/go-i2p/i2pkeys"
+	"github.com/miekg/dns"
+)
+
+// maxTXTString is the maximum length of a character string in a TXT record.
+const maxTXTString = 255
+
+// parseReverseName returns the address a lower-case in-addr.arpa or
+// ip6.arpa name without the trailing dot stands for.
+func parseReverseName(name string) (net.IP, bool) {
+	if labels, ok := strings.CutSuffix(name, ".in-addr.arpa"); ok {
+		octets := strings.Split(labels, ".")
+		if len(octets) != net.IPv4len {
+			return nil, false
+		}
+		ip := make(net.IP, net.IPv4len)
+		for i, octet := range octets {
+			value, err := strconv.ParseUint(octet, 10, 8)
+			if err != nil {
+				return nil, false
+			}
+			ip[net.IPv4len-1-i] = byte(value)
+		}
+		return ip, true
+	}
+
+	if labels, ok := strings.CutSuffix(name, ".ip6.arpa"); ok {
+		nibbles := strings.Split(labels, ".")
+		if len(nibbles) != 2*net.IPv6len {
+			return nil, false
+		}
+		ip := make(net.IP, net.IPv6len)
+		for i, nibble := range nibbles {
+			value, err := strconv.ParseUint(nibble, 16, 4)
+			if err != nil || len(nibble) != 1 {
+				return nil, false
+			}
+			// Nibbles are listed least significant first
+			position := 2*net.IPv6len - 1 - i
+			ip[position/2] |= byte(value) << (4 * (1 - position%2))
+		}
+		return ip, true
+	}
+
+	return nil, false
+}
+
+// resolvePTR answers a reverse lookup of ip with the I2P name the synthetic
+// address was handed out for. Addresses never handed out do not exist.
+func (r *I2PDNSResolver) resolvePTR(ip net.IP, question dns.Question) ([]dns.RR, bool) {
+	name, ok := r.addresses.Lookup(ip)
+	if !ok {
+		return nil, false
+	}
+	if question.Qtype != dns.TypePTR {
+		return nil, true
+	}
+
+	return []dns.RR{&dns.PTR{
+		Hdr: dns.RR_Header{
+			Name:   question.Name,
+			Rrtype: dns.TypePTR,
+			Class:  dns.ClassINET,
+			Ttl:    300, // 5 minutes TTL
+		},
+		Ptr: dns.Fqdn(name),
+	}}, true
+}
+
+// resolveTXT describes the destination an I2P domain resolves to.
+//
+// The b32 address is answered in a "b32=" record and a full base64
+// destination in a "destination=" record, split into character strings of at
+// most 255 bytes that clients concatenate. A name resolved without a naming
+// backend has no known destination and gets no records.
+func resolveTXT(domain, destination, originalName string) []dns.RR {
+	var b32 string
+	var chunks []string
+	switch {
+	case strings.HasSuffix(destination, ".b32.i2p"):
+		b32 = destination
+	case destination == domain:
+		return nil
+	default:
+		addr := i2pkeys.I2PAddr(destination)
+		if _, err := addr.ToBytes(); err != nil {
+			return nil
+		}
+		b32 = addr.Base32()
+		chunks = splitTXT("destination=" + destination)
+	}
+
+	header := dns.RR_Header{
+		Name:   originalName,
+		Rrtype: dns.TypeTXT,
+		Class:  dns.ClassINET,
+		Ttl:    300, // 5 minutes TTL
+	}
+	records := []dns.RR{&dns.TXT{Hdr: header, Txt: []string{"b32=" + b32}}}
+	if chunks != nil {
+		records = append(records, &dns.TXT{Hdr: header, Txt: chunks})
+	}
+	return records
+}
+
+// splitTXT splits text into TXT character strings.
+func splitTXT(text string) []string {
+	var parts []string
+	for len(text) > maxTXTString {
+		parts = append(parts, text[:maxTXTString])
+		text = text[maxTXTString:]
+	}
+	return append(parts, text)
+}
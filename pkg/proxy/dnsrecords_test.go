@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/go-i2p/i2pkeys"
+	"github.com/miekg/dns"
+)
+
+func TestParseReverseName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		reverse bool
+	}{
+		{name: "5.0.18.198.in-addr.arpa", want: "198.18.0.5", reverse: true},
+		{name: "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.2.3.b.0.0.7.2.3.9.6.d.f.ip6.arpa", want: "fd69:3270:b32::1", reverse: true},
+		{name: "0.18.198.in-addr.arpa"},
+		{name: "256.0.18.198.in-addr.arpa"},
+		{name: "x.0.18.198.in-addr.arpa"},
+		{name: "10.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.2.3.b.0.0.7.2.3.9.6.d.f.ip6.arpa"},
+		{name: "example.i2p"},
+	}
+
+	for _, tt := range tests {
+		ip, reverse := parseReverseName(tt.name)
+		if reverse != tt.reverse {
+			t.Errorf("parseReverseName(%q) reverse = %v, want %v", tt.name, reverse, tt.reverse)
+			continue
+		}
+		if reverse && !ip.Equal(net.ParseIP(tt.want)) {
+			t.Errorf("parseReverseName(%q) = %v, want %s", tt.name, ip, tt.want)
+		}
+	}
+}
+
+func TestI2PDNSResolver_PTR(t *testing.T) {
+	for _, mode := range []string{AddressModeIPv4, AddressModeIPv6} {
+		t.Run(mode, func(t *testing.T) {
+			addresses, _ := NewSyntheticAddresses(mode)
+			resolver := NewI2PDNSResolver("127.0.0.1:5353")
+			resolver.SetSyntheticAddresses(addresses)
+
+			ip, err := resolver.Resolve("forum.i2p")
+			if err != nil {
+				t.Fatalf("Resolve() unexpected error: %v", err)
+			}
+			reverse, err := dns.ReverseAddr(ip.String())
+			if err != nil {
+				t.Fatalf("ReverseAddr() unexpected error: %v", err)
+			}
+
+			req := new(dns.Msg)
+			req.SetQuestion(reverse, dns.TypePTR)
+			resp := resolver.buildResponse(req)
+			if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+				t.Fatalf("Expected one PTR answer, got %v", resp)
+			}
+			if ptr, ok := resp.Answer[0].(*dns.PTR); !ok || ptr.Ptr != "forum.i2p." {
+				t.Errorf("Expected PTR to forum.i2p., got %v", resp.Answer[0])
+			}
+		})
+	}
+
+	resolver := NewI2PDNSResolver("127.0.0.1:5353")
+	req := new(dns.Msg)
+	for _, name := range []string{"9.0.18.198.in-addr.arpa.", "1.0.0.10.in-addr.arpa."} {
+		req.SetQuestion(name, dns.TypePTR)
+		if resp := resolver.buildResponse(req); resp.Rcode != dns.RcodeNameError {
+			t.Errorf("Expected NXDOMAIN for %s, got %v", name, resp)
+		}
+	}
+}
+
+func TestI2PDNSResolver_TXT(t *testing.T) {
+	destination := strings.Repeat("A", 516)
+	b32 := i2pkeys.I2PAddr(destination).Base32()
+
+	resolver := NewI2PDNSResolver("127.0.0.1:5353")
+	resolver.SetNamingResolver(&staticNaming{name: "static", hosts: map[string]string{
+		"forum.i2p": destination,
+		"short.i2p": "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb.b32.i2p",
+	}})
+
+	req := new(dns.Msg)
+	req.SetQuestion("forum.i2p.", dns.TypeTXT)
+	resp := resolver.buildResponse(req)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 2 {
+		t.Fatalf("Expected two TXT answers, got %v", resp)
+	}
+	if txt := resp.Answer[0].(*dns.TXT); len(txt.Txt) != 1 || txt.Txt[0] != "b32="+b32 {
+		t.Errorf("Expected b32 record, got %v", txt)
+	}
+	txt := resp.Answer[1].(*dns.TXT)
+	for _, part := range txt.Txt {
+		if len(part) > maxTXTString {
+			t.Errorf("Expected character strings of at most %d bytes, got %d", maxTXTString, len(part))
+		}
+	}
+	if joined := strings.Join(txt.Txt, ""); joined != "destination="+destination {
+		t.Errorf("Expected the full destination, got %q", joined)
+	}
+	if _, err := resp.Pack(); err != nil {
+		t.Errorf("Failed to pack TXT answer: %v", err)
+	}
+
+	req.SetQuestion("short.i2p.", dns.TypeTXT)
+	resp = resolver.buildResponse(req)
+	if len(resp.Answer) != 1 || resp.Answer[0].(*dns.TXT).Txt[0] != "b32=bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb.b32.i2p" {
+		t.Errorf("Expected only a b32 record for a name resolving to a b32 address, got %v", resp.Answer)
+	}
+
+	// Without a naming backend the destination is unknown
+	resolver = NewI2PDNSResolver("127.0.0.1:5353")
+	req.SetQuestion("forum.i2p.", dns.TypeTXT)
+	if resp := resolver.buildResponse(req); resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Errorf("Expected an empty answer without a naming backend, got %v", resp)
+	}
+}
+
+func TestI2PDNSResolver_TXTTruncatedOverUDP(t *testing.T) {
+	resolver := NewI2PDNSResolver("127.0.0.1:0")
+	resolver.SetNamingResolver(&staticNaming{name: "static", hosts: map[string]string{"forum.i2p": strings.Repeat("A", 516)}})
+	if err := resolver.Listen(); err != nil {
+		t.Fatalf("Listen() unexpected error: %v", err)
+	}
+	defer resolver.Stop()
+	go resolver.Serve()
+	address := resolver.server.PacketConn.LocalAddr().String()
+
+	req := new(dns.Msg)
+	req.SetQuestion("forum.i2p.", dns.TypeTXT)
+
+	resp, _, err := (&dns.Client{Net: "udp"}).Exchange(req, address)
+	if err != nil {
+		t.Fatalf("UDP query failed: %v", err)
+	}
+	if !resp.Truncated {
+		t.Errorf("Expected a truncated UDP answer, got %d records", len(resp.Answer))
+	}
+
+	resp, _, err = (&dns.Client{Net: "tcp"}).Exchange(req, address)
+	if err != nil {
+		t.Fatalf("TCP query failed: %v", err)
+	}
+	if resp.Truncated || len(resp.Answer) != 2 {
+		t.Errorf("Expected the full answer over TCP, got %v", resp)
+	}
+}
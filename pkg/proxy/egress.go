@@ -0,0 +1,308 @@
+// Package proxy provides egress blocking for internal networks.
+//
+// Networks created with docker network create --internal promise that their
+// containers cannot reach anything but I2P. Interception already sends their
+// TCP and DNS traffic to the proxy, but it covers the configured container
+// subnet only, and a network on another pool or a rule removed by hand would
+// open a direct way out. Internal networks therefore get firewall rules of
+// their own, for their IPv4 and IPv6 subnets, dropping every routed packet
+// that leaves the network, except connections to the SAM bridges. The proxy, the DNS resolver and port maps
+// listen on the host, so that traffic is delivered locally and not affected.
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// egressChain is the chain dropping traffic leaving internal networks, in
+// the IPv4 and IPv6 filter tables.
+const egressChain = "I2P_EGRESS"
+
+// egressChainRules returns the rules creating the chain. It is hooked first
+// into FORWARD so rules installed by Docker or others cannot accept the
+// traffic before it is dropped.
+func egressChainRules() []string {
+	return []string{
+		"-t filter -N " + egressChain,
+		"-t filter -I FORWARD 1 -j " + egressChain,
+	}
+}
+
+// egressRules returns the rules confining subnet to itself and the SAM
+// bridges at sam of the subnet's address family.
+func egressRules(subnet *net.IPNet, sam []*net.TCPAddr) []string {
+	rules := []string{
+		fmt.Sprintf("-t filter -A %s -s %s -d %s -j RETURN", egressChain, subnet, subnet),
+	}
+	for _, addr := range sam {
+		if (addr.IP.To4() == nil) != (subnet.IP.To4() == nil) {
+			continue
+		}
+		rules = append(rules, fmt.Sprintf("-t filter -A %s -s %s -d %s -p tcp --dport %d -j RETURN", egressChain, subnet, addr.IP, addr.Port))
+	}
+	return append(rules, fmt.Sprintf("-t filter -A %s -s %s -j DROP", egressChain, subnet))
+}
+
+// subnetFamily returns the rule family of a subnet.
+func subnetFamily(subnet *net.IPNet) RuleFamily {
+	if subnet.IP.To4() == nil {
+		return FamilyIPv6
+	}
+	return FamilyIPv4
+}
+
+// egressFirewallChain returns the egress chain of a rule family.
+func egressFirewallChain(family RuleFamily) firewallChain {
+	if family == FamilyIPv6 {
+		return egressIp6tablesChain
+	}
+	return egressIptablesChain
+}
+
+// blockedNetwork is an internal network whose egress is blocked.
+type blockedNetwork struct {
+	// subnets are the network's IPv4 and IPv6 subnets
+	subnets []*net.IPNet
+	// sam are the SAM bridges the network may still reach
+	sam []*net.TCPAddr
+}
+
+// BlockNetworkEgress drops all traffic from an internal network's subnets
+// that leaves the network, except TCP connections to the SAM bridges at sam.
+// IPv4 and IPv6 subnets are blocked in the chain of their family, and SAM
+// bridges are only let through from subnets of their own family. SAM bridges
+// on the host itself need no exception.
+//
+// The chain of a family is created with the first blocked network having a
+// subnet of that family. Blocking a network twice is an error.
+func (pm *ProxyManager) BlockNetworkEgress(networkID string, subnets []*net.IPNet, sam []*net.TCPAddr) error {
+	if len(subnets) == 0 {
+		return fmt.Errorf("network %s has no subnet to block", networkID)
+	}
+
+	pm.egressMutex.Lock()
+	defer pm.egressMutex.Unlock()
+
+	if _, exists := pm.egressBlocks[networkID]; exists {
+		return fmt.Errorf("egress of network %s is already blocked", networkID)
+	}
+
+	for i, subnet := range subnets {
+		if err := pm.blockSubnetEgressLocked(subnet, sam); err != nil {
+			delete(pm.egressBlocks, networkID)
+			for _, blocked := range subnets[:i] {
+				pm.unblockSubnetEgressLocked(blocked, sam)
+			}
+			return fmt.Errorf("failed to block egress of network %s: %w", networkID, err)
+		}
+		// Later subnets of the same family find the chain created
+		pm.egressBlocks[networkID] = &blockedNetwork{subnets: subnets[:i+1], sam: sam}
+	}
+
+	log.Printf("Blocked traffic leaving internal network %s (%s) except to %d SAM bridges", networkID, subnetList(subnets), len(sam))
+	return nil
+}
+
+// blockSubnetEgressLocked installs the rules of one subnet, creating the
+// chain of its family if no blocked network has a subnet of that family. The
+// caller must hold pm.egressMutex.
+func (pm *ProxyManager) blockSubnetEgressLocked(subnet *net.IPNet, sam []*net.TCPAddr) error {
+	family := subnetFamily(subnet)
+	chain := egressFirewallChain(family)
+	run := pm.chainRunner(chain)
+
+	first := pm.familyBlocksLocked(family) == 0
+	if first {
+		if err := pm.removeLeftoverChain(chain); err != nil {
+			return err
+		}
+		for _, rule := range egressChainRules() {
+			if err := run(rule); err != nil {
+				pm.removeEgressChain(family)
+				return fmt.Errorf("failed to create %s: %w", chain, err)
+			}
+		}
+	}
+
+	rules := egressRules(subnet, sam)
+	for i, rule := range rules {
+		if err := run(rule); err != nil {
+			for _, installed := range rules[:i] {
+				run(strings.Replace(installed, "-A", "-D", 1))
+			}
+			if first {
+				pm.removeEgressChain(family)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// unblockSubnetEgressLocked deletes the rules of one subnet, returning
+// errors. The caller must hold pm.egressMutex and have removed the subnet's
+// network from pm.egressBlocks, so the chain of its family is removed if no
+// other network has a subnet of that family.
+func (pm *ProxyManager) unblockSubnetEgressLocked(subnet *net.IPNet, sam []*net.TCPAddr) []string {
+	family := subnetFamily(subnet)
+	run := pm.chainRunner(egressFirewallChain(family))
+
+	var errors []string
+	for _, rule := range egressRules(subnet, sam) {
+		if err := run(strings.Replace(rule, "-A", "-D", 1)); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	if pm.familyBlocksLocked(family) == 0 {
+		errors = append(errors, pm.removeEgressChain(family)...)
+	}
+	return errors
+}
+
+// familyBlocksLocked returns the number of blocked subnets of a family. The
+// caller must hold pm.egressMutex.
+func (pm *ProxyManager) familyBlocksLocked(family RuleFamily) int {
+	count := 0
+	for _, network := range pm.egressBlocks {
+		for _, subnet := range network.subnets {
+			if subnetFamily(subnet) == family {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// RemoveNetworkEgressBlock removes the egress rules of an internal network.
+//
+// The chain of a family is removed with the last blocked subnet of that
+// family. Removing the block of a network that is not blocked is not an
+// error.
+func (pm *ProxyManager) RemoveNetworkEgressBlock(networkID string) error {
+	pm.egressMutex.Lock()
+	defer pm.egressMutex.Unlock()
+
+	errors := pm.removeEgressBlockLocked(networkID)
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to remove egress block of network %s: %s", networkID, strings.Join(errors, "; "))
+	}
+	return nil
+}
+
+// PlanNetworkEgressRules returns the firewall commands BlockNetworkEgress
+// would run for a network, without running them.
+func (pm *ProxyManager) PlanNetworkEgressRules(subnets []*net.IPNet, sam []*net.TCPAddr) []string {
+	pm.egressMutex.Lock()
+	defer pm.egressMutex.Unlock()
+
+	var planned []string
+	created := make(map[RuleFamily]bool)
+	for _, subnet := range subnets {
+		family := subnetFamily(subnet)
+		var rules []string
+		if !created[family] && pm.familyBlocksLocked(family) == 0 {
+			rules = append(rules, egressChainRules()...)
+		}
+		created[family] = true
+		rules = append(rules, egressRules(subnet, sam)...)
+		planned = append(planned, pm.renderRules(family, rules)...)
+	}
+	return planned
+}
+
+// removeAllEgressBlocks removes the egress rules of every network, returning
+// errors.
+func (pm *ProxyManager) removeAllEgressBlocks() []string {
+	pm.egressMutex.Lock()
+	defer pm.egressMutex.Unlock()
+
+	var errors []string
+	for networkID := range pm.egressBlocks {
+		errors = append(errors, pm.removeEgressBlockLocked(networkID)...)
+	}
+	return errors
+}
+
+// removeEgressBlockLocked removes a network's rules, returning errors. The
+// caller must hold pm.egressMutex.
+func (pm *ProxyManager) removeEgressBlockLocked(networkID string) []string {
+	network, exists := pm.egressBlocks[networkID]
+	if !exists {
+		return nil
+	}
+	delete(pm.egressBlocks, networkID)
+
+	var errors []string
+	for _, subnet := range network.subnets {
+		errors = append(errors, pm.unblockSubnetEgressLocked(subnet, network.sam)...)
+	}
+
+	if len(errors) == 0 {
+		log.Printf("Removed egress block of internal network %s", networkID)
+	}
+	return errors
+}
+
+// removeEgressChain unhooks, flushes and deletes the chain of a family,
+// returning errors.
+func (pm *ProxyManager) removeEgressChain(family RuleFamily) []string {
+	run := pm.chainRunner(egressFirewallChain(family))
+
+	var errors []string
+	for _, rule := range []string{
+		"-t filter -D FORWARD -j " + egressChain,
+		"-t filter -F " + egressChain,
+		"-t filter -X " + egressChain,
+	} {
+		if err := run(rule); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	return errors
+}
+
+// subnetList joins subnets for log messages.
+func subnetList(subnets []*net.IPNet) string {
+	names := make([]string, len(subnets))
+	for i, subnet := range subnets {
+		names[i] = subnet.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// ResolveSAMBridges returns the addresses of SAM bridges given as host:port
+// that containers would reach through routing. Bridges on loopback addresses
+// are only reachable from the host and skipped, as are hosts that do not
+// resolve.
+func ResolveSAMBridges(bridges []string) []*net.TCPAddr {
+	var addrs []*net.TCPAddr
+	for _, bridge := range bridges {
+		host, port, err := net.SplitHostPort(bridge)
+		if err != nil {
+			continue
+		}
+		portNum, err := strconv.Atoi(port)
+		if err != nil {
+			continue
+		}
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			log.Printf("Warning: Failed to resolve SAM bridge %s for egress rules: %v", bridge, err)
+			continue
+		}
+		for _, ip := range ips {
+			if ip.IsLoopback() {
+				continue
+			}
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			addrs = append(addrs, &net.TCPAddr{IP: ip, Port: portNum})
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,176 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestProxyManager_BlockNetworkEgress(t *testing.T) {
+	t.Parallel()
+
+	pm, iptables := newBindTestManager(t)
+	_, subnet, _ := net.ParseCIDR("172.30.0.0/24")
+	sam := []*net.TCPAddr{{IP: net.ParseIP("10.0.0.5").To4(), Port: 7656}}
+
+	planned := pm.PlanNetworkEgressRules([]*net.IPNet{subnet}, sam)
+	if err := pm.BlockNetworkEgress("net1", []*net.IPNet{subnet}, sam); err != nil {
+		t.Fatalf("Failed to block egress: %v", err)
+	}
+
+	expected := []string{
+		"-t filter -N I2P_EGRESS",
+		"-t filter -I FORWARD 1 -j I2P_EGRESS",
+		"-t filter -A I2P_EGRESS -s 172.30.0.0/24 -d 172.30.0.0/24 -j RETURN",
+		"-t filter -A I2P_EGRESS -s 172.30.0.0/24 -d 10.0.0.5 -p tcp --dport 7656 -j RETURN",
+		"-t filter -A I2P_EGRESS -s 172.30.0.0/24 -j DROP",
+	}
+	if fmt.Sprint(*iptables) != fmt.Sprint(expected) {
+		t.Errorf("Unexpected iptables rules:\n got %v\nwant %v", *iptables, expected)
+	}
+	if len(planned) != len(expected) || planned[0] != "iptables "+expected[0] {
+		t.Errorf("Expected the plan to list the installed rules, got %v", planned)
+	}
+
+	if err := pm.BlockNetworkEgress("net1", []*net.IPNet{subnet}, nil); err == nil {
+		t.Error("Expected blocking a network twice to fail")
+	}
+
+	// A second network reuses the chain
+	*iptables = nil
+	_, other, _ := net.ParseCIDR("172.31.0.0/24")
+	if err := pm.BlockNetworkEgress("net2", []*net.IPNet{other}, nil); err != nil {
+		t.Fatalf("Failed to block second network: %v", err)
+	}
+	if len(*iptables) != 2 {
+		t.Errorf("Expected only the network rules, got %v", *iptables)
+	}
+
+	// Removing deletes the network's rules; the last one removes the chain
+	*iptables = nil
+	if err := pm.RemoveNetworkEgressBlock("net1"); err != nil {
+		t.Fatalf("Failed to remove egress block: %v", err)
+	}
+	if len(*iptables) != 3 || !strings.HasPrefix((*iptables)[0], "-t filter -D I2P_EGRESS -s 172.30.0.0/24 ") {
+		t.Errorf("Expected the network rules to be deleted, got %v", *iptables)
+	}
+	if err := pm.RemoveNetworkEgressBlock("net1"); err != nil {
+		t.Errorf("Removing an unknown block should not fail: %v", err)
+	}
+
+	*iptables = nil
+	if errs := pm.removeAllEgressBlocks(); len(errs) != 0 {
+		t.Fatalf("Failed to remove all blocks: %v", errs)
+	}
+	if last := (*iptables)[len(*iptables)-1]; last != "-t filter -X I2P_EGRESS" {
+		t.Errorf("Expected the chain to be removed with the last network, got %v", *iptables)
+	}
+}
+
+func TestProxyManager_BlockNetworkEgressFailure(t *testing.T) {
+	t.Parallel()
+
+	pm, _ := newBindTestManager(t)
+	var iptables []string
+	pm.runIptables = func(rule string) error {
+		iptables = append(iptables, rule)
+		if strings.HasSuffix(rule, "-j DROP") && strings.Contains(rule, "-A I2P_EGRESS") {
+			return errors.New("table is locked")
+		}
+		return nil
+	}
+
+	_, subnet, _ := net.ParseCIDR("172.30.0.0/24")
+	if err := pm.BlockNetworkEgress("net1", []*net.IPNet{subnet}, nil); err == nil {
+		t.Fatal("Expected blocking to fail")
+	}
+	if last := iptables[len(iptables)-1]; last != "-t filter -X I2P_EGRESS" {
+		t.Errorf("Expected the chain to be removed after the failure, got %v", iptables)
+	}
+	if len(pm.egressBlocks) != 0 {
+		t.Errorf("Expected no recorded blocks, got %v", pm.egressBlocks)
+	}
+
+	if err := pm.BlockNetworkEgress("net2", nil, nil); err == nil {
+		t.Error("Expected a network without subnets to be rejected")
+	}
+}
+
+func TestProxyManager_BlockNetworkEgressIPv6(t *testing.T) {
+	t.Parallel()
+
+	pm, iptables := newBindTestManager(t)
+	var ip6tables []string
+	pm.runIp6tables = func(rule string) error {
+		ip6tables = append(ip6tables, rule)
+		return nil
+	}
+
+	_, subnet, _ := net.ParseCIDR("172.30.0.0/24")
+	_, subnet6, _ := net.ParseCIDR("fd00:30::/64")
+	sam := []*net.TCPAddr{{IP: net.ParseIP("10.0.0.5").To4(), Port: 7656}, {IP: net.ParseIP("fd00::5"), Port: 7656}}
+
+	planned := pm.PlanNetworkEgressRules([]*net.IPNet{subnet, subnet6}, sam)
+	if err := pm.BlockNetworkEgress("net1", []*net.IPNet{subnet, subnet6}, sam); err != nil {
+		t.Fatalf("Failed to block egress: %v", err)
+	}
+
+	expected6 := []string{
+		"-t filter -N I2P_EGRESS",
+		"-t filter -I FORWARD 1 -j I2P_EGRESS",
+		"-t filter -A I2P_EGRESS -s fd00:30::/64 -d fd00:30::/64 -j RETURN",
+		"-t filter -A I2P_EGRESS -s fd00:30::/64 -d fd00::5 -p tcp --dport 7656 -j RETURN",
+		"-t filter -A I2P_EGRESS -s fd00:30::/64 -j DROP",
+	}
+	if fmt.Sprint(ip6tables) != fmt.Sprint(expected6) {
+		t.Errorf("Unexpected ip6tables rules:\n got %v\nwant %v", ip6tables, expected6)
+	}
+	for _, rule := range *iptables {
+		if strings.Contains(rule, "fd00") {
+			t.Errorf("Expected no IPv6 addresses in iptables rules, got %q", rule)
+		}
+	}
+	if len(*iptables) != 5 {
+		t.Errorf("Expected the IPv4 chain and rules, got %v", *iptables)
+	}
+	if len(planned) != 10 || planned[5] != "ip6tables "+expected6[0] {
+		t.Errorf("Expected the plan to list the rules of both families, got %v", planned)
+	}
+
+	// The IPv6 chain goes with the last IPv6 subnet
+	ip6tables = nil
+	if err := pm.RemoveNetworkEgressBlock("net1"); err != nil {
+		t.Fatalf("Failed to remove egress block: %v", err)
+	}
+	if last := ip6tables[len(ip6tables)-1]; last != "-t filter -X I2P_EGRESS" {
+		t.Errorf("Expected the IPv6 chain to be removed, got %v", ip6tables)
+	}
+
+	// A failing IPv6 rule rolls back the IPv4 rules
+	*iptables = nil
+	pm.runIp6tables = func(rule string) error {
+		if strings.HasSuffix(rule, "-j DROP") {
+			return errors.New("table is locked")
+		}
+		return nil
+	}
+	if err := pm.BlockNetworkEgress("net2", []*net.IPNet{subnet, subnet6}, nil); err == nil {
+		t.Fatal("Expected blocking to fail")
+	}
+	if last := (*iptables)[len(*iptables)-1]; last != "-t filter -X I2P_EGRESS" {
+		t.Errorf("Expected the IPv4 chain to be removed after the failure, got %v", *iptables)
+	}
+	if len(pm.egressBlocks) != 0 {
+		t.Errorf("Expected no recorded blocks, got %v", pm.egressBlocks)
+	}
+}
+
+func TestResolveSAMBridges(t *testing.T) {
+	addrs := ResolveSAMBridges([]string{"127.0.0.1:7656", "10.0.0.5:7656", "172.17.0.2:7657", "no-port", "[fd00::1]:7656"})
+
+	if fmt.Sprint(addrs) != "[10.0.0.5:7656 172.17.0.2:7657 [fd00::1]:7656]" {
+		t.Errorf("Expected only routed bridges, got %v", addrs)
+	}
+}
@@ -0,0 +1,84 @@
+// Package proxy provides per-endpoint traffic counters.
+//
+// Daily usage records attribute proxy traffic to containers for accounting;
+// the counters in this file follow a single Docker endpoint for as long as it
+// exists, including the connections it currently has open, so the plugin can
+// report them in EndpointOperInfo and the admin API.
+package proxy
+
+// EndpointTraffic is the proxy traffic of one endpoint.
+type EndpointTraffic struct {
+	// Connections counts completed proxy connections
+	Connections int64 `json:"connections"`
+	// ActiveConnections counts proxy connections currently relaying data
+	ActiveConnections int64 `json:"active_connections"`
+	// BytesTransferred is the data volume of completed connections in both directions
+	BytesTransferred int64 `json:"bytes_transferred"`
+}
+
+// openEndpointConnection counts a connection of an endpoint as active.
+func (tf *TrafficFilter) openEndpointConnection(endpointID string) {
+	if endpointID == "" {
+		return
+	}
+
+	tf.statsMutex.Lock()
+	defer tf.statsMutex.Unlock()
+
+	traffic, exists := tf.endpoints[endpointID]
+	if !exists {
+		traffic = &EndpointTraffic{}
+		tf.endpoints[endpointID] = traffic
+	}
+	traffic.ActiveConnections++
+}
+
+// closeEndpointConnection adds a connection counted by openEndpointConnection
+// to the endpoint's completed traffic.
+func (tf *TrafficFilter) closeEndpointConnection(endpointID string, bytesTransferred int64) {
+	if endpointID == "" {
+		return
+	}
+
+	tf.statsMutex.Lock()
+	defer tf.statsMutex.Unlock()
+
+	// The endpoint may have been forgotten while the connection was open
+	traffic, exists := tf.endpoints[endpointID]
+	if !exists {
+		return
+	}
+	traffic.ActiveConnections--
+	traffic.Connections++
+	traffic.BytesTransferred += bytesTransferred
+}
+
+// GetEndpointTraffic returns the proxy traffic of an endpoint.
+func (tf *TrafficFilter) GetEndpointTraffic(endpointID string) EndpointTraffic {
+	tf.statsMutex.RLock()
+	defer tf.statsMutex.RUnlock()
+
+	if traffic, exists := tf.endpoints[endpointID]; exists {
+		return *traffic
+	}
+	return EndpointTraffic{}
+}
+
+// ForgetEndpoint drops the traffic counters of a deleted endpoint.
+func (tf *TrafficFilter) ForgetEndpoint(endpointID string) {
+	tf.statsMutex.Lock()
+	defer tf.statsMutex.Unlock()
+
+	delete(tf.endpoints, endpointID)
+}
+
+// GetEndpointTraffic returns the proxy traffic of an endpoint (see
+// TrafficFilter.GetEndpointTraffic).
+func (pm *ProxyManager) GetEndpointTraffic(endpointID string) EndpointTraffic {
+	return pm.trafficFilter.GetEndpointTraffic(endpointID)
+}
+
+// ForgetEndpointTraffic drops the traffic counters of a deleted endpoint.
+func (pm *ProxyManager) ForgetEndpointTraffic(endpointID string) {
+	pm.trafficFilter.ForgetEndpoint(endpointID)
+}
@@ -0,0 +1,26 @@
+package proxy
+
+import "testing"
+
+func TestTrafficFilter_EndpointTraffic(t *testing.T) {
+	filter := NewTrafficFilter(DefaultFilterConfig())
+
+	filter.openEndpointConnection("ep1")
+	filter.openEndpointConnection("ep1")
+	filter.openEndpointConnection("") // unattributed connections are not counted
+	filter.closeEndpointConnection("ep1", 100)
+
+	if traffic := filter.GetEndpointTraffic("ep1"); traffic != (EndpointTraffic{Connections: 1, ActiveConnections: 1, BytesTransferred: 100}) {
+		t.Errorf("Unexpected ep1 traffic: %+v", traffic)
+	}
+	if traffic := filter.GetEndpointTraffic("ep2"); traffic != (EndpointTraffic{}) {
+		t.Errorf("Expected no traffic for an unknown endpoint, got %+v", traffic)
+	}
+
+	// A connection closing after its endpoint was deleted does not revive it
+	filter.ForgetEndpoint("ep1")
+	filter.closeEndpointConnection("ep1", 50)
+	if traffic := filter.GetEndpointTraffic("ep1"); traffic != (EndpointTraffic{}) {
+		t.Errorf("Expected a forgotten endpoint to have no traffic, got %+v", traffic)
+	}
+}
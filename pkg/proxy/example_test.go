@@ -0,0 +1,47 @@
+package proxy_test
+
+import (
+	"log"
+	"net"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+)
+
+func ExampleNewSOCKSProxy() {
+	filter := proxy.NewTrafficFilter(proxy.DefaultFilterConfig())
+	if err := filter.AddToBlocklist("*.tracker.i2p"); err != nil {
+		log.Fatal(err)
+	}
+
+	socks := proxy.NewSOCKSProxy(proxy.SOCKSOptions{
+		TunnelManager: i2p.NewTunnelManager(i2p.TunnelManagerOptions{}),
+		TrafficFilter: filter,
+	})
+	defer socks.Stop()
+
+	// Serve the proxy on a listener of your own
+	listener, err := net.Listen("tcp", "127.0.0.1:1080")
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Fatal(socks.Serve(listener))
+}
+
+func ExampleNewProxyManager() {
+	_, subnet, _ := net.ParseCIDR("172.20.0.0/16")
+
+	config := proxy.DefaultProxyConfig(subnet)
+	config.AddressMode = proxy.AddressModeIPv6
+
+	manager := proxy.NewProxyManager(proxy.ProxyOptions{
+		Config:        config,
+		TunnelManager: i2p.NewTunnelManager(i2p.TunnelManagerOptions{}),
+	})
+	defer manager.Stop()
+
+	// Start installs the iptables rules redirecting the subnet's traffic
+	if err := manager.Start(); err != nil {
+		log.Fatal(err)
+	}
+}
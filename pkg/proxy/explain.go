@@ -0,0 +1,208 @@
+// Package proxy provides explanations of traffic filter decisions.
+//
+// A connection through the SOCKS proxy passes several layers of policy: the
+// client's container may have outbound I2P disabled, its network may name
+// external services, aliases and synthetic addresses rewrite the target, and
+// finally the traffic filter allows or blocks the destination. When a
+// connection is refused it is not obvious which layer refused it.
+// ExplainConnection walks a destination through the same layers as the SOCKS
+// proxy, without connecting, logging or counting anything, and reports every
+// layer it passed and the rule that decided.
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Scopes of filter policy layers.
+const (
+	// FilterScopeContainer layers apply to a single container
+	FilterScopeContainer = "container"
+	// FilterScopeNetwork layers come from the options of a network
+	FilterScopeNetwork = "network"
+	// FilterScopeGlobal layers apply to every container
+	FilterScopeGlobal = "global"
+)
+
+// Rules reported by filter explanations.
+const (
+	// FilterRuleOutboundDisabled refuses containers labelled i2p.outbound=false
+	FilterRuleOutboundDisabled = "outbound-disabled"
+	// FilterRuleOutbound lets the container open connections
+	FilterRuleOutbound = "outbound"
+	// FilterRuleSyntheticAddress maps a synthetic DNS address back to its name
+	FilterRuleSyntheticAddress = "synthetic-address"
+	// FilterRuleExternalService maps an external service name of the
+	// client's network to its destination
+	FilterRuleExternalService = "external-service"
+	// FilterRuleAlias maps a configured alias to its destination
+	FilterRuleAlias = "alias"
+	// FilterRuleNonI2P blocks destinations outside I2P
+	FilterRuleNonI2P = "non-i2p"
+	// FilterRuleAllowlist allows a destination on the allowlist
+	FilterRuleAllowlist = "allowlist"
+	// FilterRuleNotAllowlisted blocks destinations missing from the
+	// allowlist in allowlist mode
+	FilterRuleNotAllowlisted = "not-in-allowlist"
+	// FilterRuleBlocklist blocks a destination on the blocklist
+	FilterRuleBlocklist = "blocklist"
+	// FilterRuleDefault allows I2P destinations no rule matched
+	FilterRuleDefault = "default"
+)
+
+// Decisions of filter explanation steps.
+const (
+	FilterDecisionAllow = "allow"
+	FilterDecisionBlock = "block"
+)
+
+// FilterStep is a policy layer a connection passed.
+type FilterStep struct {
+	// Scope is the scope of the layer: container, network or global
+	Scope string `json:"scope"`
+	// Rule is the rule that applied
+	Rule string `json:"rule"`
+	// Pattern is the list entry the destination matched, if any
+	Pattern string `json:"pattern,omitempty"`
+	// Origin names where the rule is configured, if known
+	Origin string `json:"origin,omitempty"`
+	// Detail describes what the layer did
+	Detail string `json:"detail"`
+	// Decision is allow or block for the layer that decided, and empty for
+	// layers the connection passed through
+	Decision string `json:"decision,omitempty"`
+}
+
+// FilterExplanation describes how the proxy decides a connection.
+type FilterExplanation struct {
+	// Destination is the destination as requested
+	Destination string `json:"destination"`
+	// Target is the destination the filter sees after rewriting
+	Target string `json:"target"`
+	// Allowed reports whether the connection would be allowed
+	Allowed bool `json:"allowed"`
+	// Scope is the scope of the deciding rule
+	Scope string `json:"scope"`
+	// Rule is the deciding rule
+	Rule string `json:"rule"`
+	// Pattern is the list entry the deciding rule matched, if any
+	Pattern string `json:"pattern,omitempty"`
+	// Reason describes the decision
+	Reason string `json:"reason"`
+	// Steps are the layers the connection passed, in order
+	Steps []FilterStep `json:"steps"`
+}
+
+// Decision returns the step that decided the connection, the last one.
+func (e *FilterExplanation) Decision() *FilterStep {
+	if len(e.Steps) == 0 {
+		return nil
+	}
+	return &e.Steps[len(e.Steps)-1]
+}
+
+// decide appends the deciding step and copies it into the summary fields.
+func (e *FilterExplanation) decide(step FilterStep) {
+	e.Steps = append(e.Steps, step)
+	e.Allowed = step.Decision == FilterDecisionAllow
+	e.Scope = step.Scope
+	e.Rule = step.Rule
+	e.Pattern = step.Pattern
+	e.Reason = step.Detail
+}
+
+// SetDecisionOrigin attributes the deciding step to a scope and origin, for
+// callers that know where its rule is configured.
+func (e *FilterExplanation) SetDecisionOrigin(scope, origin string) {
+	if step := e.Decision(); step != nil {
+		step.Scope = scope
+		step.Origin = origin
+		e.Scope = scope
+	}
+}
+
+// explain applies the filtering rules to a destination without logging or
+// counting the decision.
+func (tf *TrafficFilter) explain(destination string) filterDecision {
+	tf.mutex.RLock()
+	defer tf.mutex.RUnlock()
+
+	return tf.decideLocked(filterHost(strings.ToLower(destination)))
+}
+
+// ExplainConnection reports how the SOCKS proxy would decide a connection
+// from source to destination, a host with or without a port.
+func (pm *ProxyManager) ExplainConnection(source ClientSource, destination string) FilterExplanation {
+	explanation := FilterExplanation{Destination: destination}
+
+	if source.OutboundDisabled {
+		explanation.Target = destination
+		explanation.decide(FilterStep{
+			Scope:    FilterScopeContainer,
+			Rule:     FilterRuleOutboundDisabled,
+			Detail:   fmt.Sprintf("endpoint %s has outbound I2P disabled", source.EndpointID),
+			Decision: FilterDecisionBlock,
+		})
+		return explanation
+	}
+	explanation.Steps = append(explanation.Steps, FilterStep{
+		Scope:  FilterScopeContainer,
+		Rule:   FilterRuleOutbound,
+		Detail: "container may open outbound I2P connections",
+	})
+
+	// The rewriters work on host:port targets; a bare host borrows a port
+	// that is dropped again afterwards
+	target := destination
+	_, _, err := net.SplitHostPort(target)
+	hasPort := err == nil
+	if !hasPort {
+		target = net.JoinHostPort(target, "0")
+	}
+
+	pm.listenerMutex.Lock()
+	rewriter := pm.targetRewriter
+	pm.listenerMutex.Unlock()
+
+	rewrites := []struct {
+		scope, rule string
+		rewrite     func(string) string
+	}{
+		{FilterScopeGlobal, FilterRuleSyntheticAddress, func(t string) string { return translateSyntheticTarget(pm.addresses, t) }},
+		{FilterScopeNetwork, FilterRuleExternalService, func(t string) string { return pm.external.rewriteTarget(source.NetworkID, t) }},
+		{FilterScopeGlobal, FilterRuleAlias, func(t string) string { return rewriteTarget(rewriter, t) }},
+	}
+	for _, step := range rewrites {
+		rewritten := step.rewrite(target)
+		if rewritten == target {
+			continue
+		}
+		explanation.Steps = append(explanation.Steps, FilterStep{
+			Scope:  step.scope,
+			Rule:   step.rule,
+			Detail: fmt.Sprintf("%s is rewritten to %s", filterHost(target), filterHost(rewritten)),
+		})
+		target = rewritten
+	}
+
+	if !hasPort {
+		target = filterHost(target)
+	}
+	explanation.Target = target
+
+	decision := pm.trafficFilter.explain(target)
+	step := FilterStep{
+		Scope:    FilterScopeGlobal,
+		Rule:     decision.rule,
+		Pattern:  decision.pattern,
+		Detail:   decision.reason,
+		Decision: FilterDecisionBlock,
+	}
+	if decision.allowed {
+		step.Decision = FilterDecisionAllow
+	}
+	explanation.decide(step)
+	return explanation
+}
@@ -0,0 +1,78 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplainConnection(t *testing.T) {
+	address := strings.Repeat("d", 52) + ".b32.i2p"
+
+	pm := NewProxyManager(ProxyOptions{})
+	pm.SetExternalServices("net1", map[string]string{"db": address})
+	rewriter, _ := NewAliasRewriter([]string{"service.internal=forum.i2p"})
+	pm.SetTargetRewriter(rewriter)
+	pm.AddToBlocklist("*.bad.i2p")
+	pm.AddToBlocklist("evil.bad.i2p")
+
+	source := ClientSource{NetworkID: "net1", EndpointID: "ep1", ContainerID: "container1"}
+
+	tests := []struct {
+		name        string
+		source      ClientSource
+		destination string
+		target      string
+		allowed     bool
+		scope       string
+		rule        string
+		pattern     string
+		steps       int
+	}{
+		{name: "default", source: source, destination: "forum.i2p:80", target: "forum.i2p:80", allowed: true, scope: FilterScopeGlobal, rule: FilterRuleDefault, steps: 2},
+		{name: "exact entry", source: source, destination: "EVIL.bad.i2p", target: "EVIL.bad.i2p", scope: FilterScopeGlobal, rule: FilterRuleBlocklist, pattern: "evil.bad.i2p", steps: 2},
+		{name: "wildcard", source: source, destination: "other.bad.i2p:443", target: "other.bad.i2p:443", scope: FilterScopeGlobal, rule: FilterRuleBlocklist, pattern: "*.bad.i2p", steps: 2},
+		{name: "clearnet", source: source, destination: "example.com:80", target: "example.com:80", scope: FilterScopeGlobal, rule: FilterRuleNonI2P, steps: 2},
+		{name: "external service", source: source, destination: "db:5432", target: address + ":5432", allowed: true, scope: FilterScopeGlobal, rule: FilterRuleDefault, steps: 3},
+		{name: "alias", source: source, destination: "service.internal", target: "forum.i2p", allowed: true, scope: FilterScopeGlobal, rule: FilterRuleDefault, steps: 3},
+		{name: "outbound disabled", source: ClientSource{NetworkID: "net1", EndpointID: "ep2", OutboundDisabled: true}, destination: "forum.i2p", target: "forum.i2p", scope: FilterScopeContainer, rule: FilterRuleOutboundDisabled, steps: 1},
+	}
+
+	for _, tt := range tests {
+		explanation := pm.ExplainConnection(tt.source, tt.destination)
+		if explanation.Allowed != tt.allowed || explanation.Scope != tt.scope || explanation.Rule != tt.rule || explanation.Pattern != tt.pattern {
+			t.Errorf("%s: expected allowed=%v by %s rule %s (%q), got %+v", tt.name, tt.allowed, tt.scope, tt.rule, tt.pattern, explanation)
+		}
+		if explanation.Target != tt.target {
+			t.Errorf("%s: expected target %s, got %s", tt.name, tt.target, explanation.Target)
+		}
+		if len(explanation.Steps) != tt.steps {
+			t.Errorf("%s: expected %d steps, got %+v", tt.name, tt.steps, explanation.Steps)
+		}
+	}
+
+	// Explaining does not count as traffic
+	if stats := pm.GetTrafficStats(); stats.I2PConnectionsAllowed != 0 || stats.I2PConnectionsBlocked != 0 || stats.NonI2PConnectionsBlocked != 0 {
+		t.Errorf("Expected explanations to leave the statistics alone, got %+v", stats)
+	}
+}
+
+func TestExplainConnectionAllowlist(t *testing.T) {
+	pm := NewProxyManager(ProxyOptions{})
+	pm.UpdateFilterConfig(&FilterConfig{EnableAllowlist: true})
+	pm.AddToAllowlist("*.trusted.i2p")
+
+	explanation := pm.ExplainConnection(ClientSource{}, "forum.trusted.i2p:80")
+	if !explanation.Allowed || explanation.Rule != FilterRuleAllowlist || explanation.Pattern != "*.trusted.i2p" {
+		t.Errorf("Expected the allowlist wildcard to allow, got %+v", explanation)
+	}
+
+	explanation = pm.ExplainConnection(ClientSource{}, "forum.i2p:80")
+	if explanation.Allowed || explanation.Rule != FilterRuleNotAllowlisted {
+		t.Errorf("Expected destinations off the allowlist to be blocked, got %+v", explanation)
+	}
+
+	explanation.SetDecisionOrigin(FilterScopeNetwork, "options of network i2p-test")
+	if step := explanation.Decision(); explanation.Scope != FilterScopeNetwork || step.Scope != FilterScopeNetwork || step.Origin == "" {
+		t.Errorf("Expected the decision to be attributed to the network, got %+v", explanation)
+	}
+}
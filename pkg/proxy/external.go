@@ -0,0 +1,233 @@
+// Package proxy provides external I2P services of networks.
+//
+// Containers often depend on services that run elsewhere on I2P, such as a
+// database or an API another operator hosts. Much like Docker models external
+// services, a network can name such destinations: every external service is a
+// short name and the b32 address of an existing destination. For clients on
+// the network, the DNS resolver answers the name with the synthetic address of
+// the destination, and the SOCKS proxy connects names and synthetic addresses
+// to the destination, so containers reach the service by name just as they
+// reach each other. Names are scoped to their network; clients on other
+// networks, and clients the proxy cannot attribute to a network, get NXDOMAIN.
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// ExternalServices holds the external services of every network.
+//
+// One table is shared by every DNS resolver and SOCKS proxy of a proxy
+// manager, like SyntheticAddresses.
+type ExternalServices struct {
+	// services maps network IDs to service names to b32 addresses
+	services map[string]map[string]string
+	// sourceResolver attributes client addresses to networks
+	sourceResolver SourceResolver
+	// mutex protects services and sourceResolver
+	mutex sync.RWMutex
+}
+
+// NewExternalServices creates an empty external service table.
+func NewExternalServices() *ExternalServices {
+	return &ExternalServices{services: make(map[string]map[string]string)}
+}
+
+// ParseExternalServices parses comma-separated "name=address" pairs.
+//
+// Names must be hostnames that are not I2P names themselves, so they can
+// never shadow one, and addresses must be b32 addresses. Both are returned in
+// lower case.
+func ParseExternalServices(value string) (map[string]string, error) {
+	services := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, address, ok := strings.Cut(entry, "=")
+		name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+		address = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(address), "."))
+		if !ok || name == "" || address == "" {
+			return nil, fmt.Errorf("invalid external service %q: expected name=address", entry)
+		}
+		if !isValidSOCKSDomain([]byte(name)) || strings.Contains(name, "*") {
+			return nil, fmt.Errorf("invalid external service %q: %q is not a hostname", entry, name)
+		}
+		if name == "i2p" || strings.HasSuffix(name, ".i2p") {
+			return nil, fmt.Errorf("invalid external service %q: I2P names cannot be service names", entry)
+		}
+		if !isB32Address(address) {
+			return nil, fmt.Errorf("invalid external service %q: %q is not a b32 address", entry, address)
+		}
+		if _, exists := services[name]; exists {
+			return nil, fmt.Errorf("duplicate external service %q", name)
+		}
+		services[name] = address
+	}
+	return services, nil
+}
+
+// isB32Address reports whether name is a base32 I2P address: 52 base32
+// characters, or 56 and more for encrypted lease sets, followed by .b32.i2p.
+func isB32Address(name string) bool {
+	label, ok := strings.CutSuffix(name, ".b32.i2p")
+	if !ok || len(label) < 52 {
+		return false
+	}
+	for _, c := range label {
+		if (c < 'a' || c > 'z') && (c < '2' || c > '7') {
+			return false
+		}
+	}
+	return true
+}
+
+// set replaces the external services of a network; no services remove it.
+func (e *ExternalServices) set(networkID string, services map[string]string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if len(services) == 0 {
+		delete(e.services, networkID)
+		return
+	}
+	copied := make(map[string]string, len(services))
+	for name, address := range services {
+		copied[name] = address
+	}
+	e.services[networkID] = copied
+}
+
+// setSourceResolver sets the resolver attributing clients to networks.
+func (e *ExternalServices) setSourceResolver(resolver SourceResolver) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.sourceResolver = resolver
+}
+
+// lookup returns the b32 address of the external service name on the
+// network of the client at ip.
+func (e *ExternalServices) lookup(ip net.IP, name string) (string, bool) {
+	if e == nil || ip == nil {
+		return "", false
+	}
+
+	e.mutex.RLock()
+	resolver := e.sourceResolver
+	e.mutex.RUnlock()
+	if resolver == nil {
+		return "", false
+	}
+	source, ok := resolver(ip)
+	if !ok {
+		return "", false
+	}
+	return e.lookupNetwork(source.NetworkID, name)
+}
+
+// lookupNetwork returns the b32 address of the external service name of a
+// network.
+func (e *ExternalServices) lookupNetwork(networkID, name string) (string, bool) {
+	if e == nil || networkID == "" {
+		return "", false
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	address, ok := e.services[networkID][strings.ToLower(strings.TrimSuffix(name, "."))]
+	return address, ok
+}
+
+// rewriteTarget replaces an external service name of networkID in a
+// host:port target with its b32 address.
+//
+// Targets that are not external services are returned unchanged.
+func (e *ExternalServices) rewriteTarget(networkID, target string) string {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return target
+	}
+	if address, ok := e.lookupNetwork(networkID, host); ok {
+		return net.JoinHostPort(address, port)
+	}
+	return target
+}
+
+// externalServiceAddress returns the synthetic address of an external
+// service's b32 address, the one the address itself resolves to, and records
+// it so the SOCKS proxy translates it back.
+func (r *I2PDNSResolver) externalServiceAddress(address string) net.IP {
+	ip := r.generateI2PIP(address)
+	if r.addresses.Mode() == AddressModeIPv6 {
+		ip = generateI2PIPv6(address)
+	}
+	r.addresses.record(ip, address)
+	return ip
+}
+
+// answerExternalService answers a query for an external service of the
+// network of the client at ip, or returns nil if the query is not for one.
+func (r *I2PDNSResolver) answerExternalService(req *dns.Msg, ip net.IP) *dns.Msg {
+	if r.external == nil || len(req.Question) != 1 {
+		return nil
+	}
+	question := req.Question[0]
+	address, ok := r.external.lookup(ip, strings.ToLower(question.Name))
+	if !ok {
+		return nil
+	}
+
+	msg := new(dns.Msg)
+	msg.SetReply(req)
+	msg.Authoritative = true
+
+	header := dns.RR_Header{Name: question.Name, Class: dns.ClassINET, Ttl: 300}
+	synthetic := r.externalServiceAddress(address)
+	ipv6 := r.addresses.Mode() == AddressModeIPv6
+	switch {
+	case question.Qtype == dns.TypeA && !ipv6:
+		header.Rrtype = dns.TypeA
+		msg.Answer = append(msg.Answer, &dns.A{Hdr: header, A: synthetic})
+	case question.Qtype == dns.TypeAAAA && ipv6:
+		header.Rrtype = dns.TypeAAAA
+		msg.Answer = append(msg.Answer, &dns.AAAA{Hdr: header, AAAA: synthetic})
+	case question.Qtype == dns.TypeTXT:
+		msg.Answer = resolveTXT("", address, question.Name)
+	}
+	return msg
+}
+
+// SetExternalServices replaces the external services of a network, by name.
+// No services remove them.
+//
+// The synthetic addresses of the services are recorded right away, so
+// clients that connect to them without resolving the names first, e.g.
+// from /etc/hosts, are translated too.
+func (pm *ProxyManager) SetExternalServices(networkID string, services map[string]string) {
+	pm.external.set(networkID, services)
+
+	pm.listenerMutex.Lock()
+	defer pm.listenerMutex.Unlock()
+
+	for _, address := range services {
+		pm.dnsResolver.externalServiceAddress(address)
+	}
+}
+
+// ExternalServiceAddress returns the synthetic address the b32 address of an
+// external service resolves to.
+//
+// The address is derived from the b32 address, so it stays the same across
+// restarts as long as the address mode does.
+func (pm *ProxyManager) ExternalServiceAddress(address string) net.IP {
+	pm.listenerMutex.Lock()
+	defer pm.listenerMutex.Unlock()
+
+	return pm.dnsResolver.externalServiceAddress(address)
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestParseExternalServices(t *testing.T) {
+	address := strings.Repeat("a", 52) + ".b32.i2p"
+
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: map[string]string{}},
+		{name: "services", value: " DB=" + strings.ToUpper(address) + ", api.internal=" + address + ",", want: map[string]string{"db": address, "api.internal": address}},
+		{name: "missing address", value: "db", wantErr: true},
+		{name: "not b32", value: "db=forum.i2p", wantErr: true},
+		{name: "short b32", value: "db=abc.b32.i2p", wantErr: true},
+		{name: "I2P name", value: "forum.i2p=" + address, wantErr: true},
+		{name: "wildcard", value: "*.internal=" + address, wantErr: true},
+		{name: "duplicate", value: "db=" + address + ",DB=" + address, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		services, err := ParseExternalServices(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ParseExternalServices() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if len(services) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, services)
+		}
+		for name, address := range tt.want {
+			if services[name] != address {
+				t.Errorf("%s: expected %s=%s, got %v", tt.name, name, address, services)
+			}
+		}
+	}
+}
+
+func TestExternalServicesScopedToNetwork(t *testing.T) {
+	address := strings.Repeat("c", 52) + ".b32.i2p"
+	// The resolver looks up clients from its own goroutine
+	var networksMutex sync.Mutex
+	networks := map[string]string{"127.0.0.1": "net1"}
+
+	pm := NewProxyManager(ProxyOptions{})
+	pm.SetSourceResolver(func(ip net.IP) (ClientSource, bool) {
+		networksMutex.Lock()
+		defer networksMutex.Unlock()
+		networkID, ok := networks[ip.String()]
+		return ClientSource{NetworkID: networkID}, ok
+	})
+	pm.SetExternalServices("net1", map[string]string{"db": address})
+
+	pm.listenerMutex.Lock()
+	resolver := pm.newDNSResolver("127.0.0.1:0")
+	pm.listenerMutex.Unlock()
+	if err := resolver.Listen(); err != nil {
+		t.Fatalf("Listen() unexpected error: %v", err)
+	}
+	defer resolver.Stop()
+	go resolver.Serve()
+	server := resolver.server.PacketConn.LocalAddr().String()
+
+	req := new(dns.Msg)
+	req.SetQuestion("DB.", dns.TypeA)
+	client := &dns.Client{Net: "udp"}
+	resp, _, err := client.Exchange(req, server)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+		t.Fatalf("Expected the service to resolve, got %v", resp)
+	}
+	ip := resp.Answer[0].(*dns.A).A
+	if !ip.Equal(pm.ExternalServiceAddress(address)) {
+		t.Errorf("Expected the service's synthetic address, got %s", ip)
+	}
+	if name, ok := pm.addresses.Lookup(ip); !ok || name != address {
+		t.Errorf("Expected the address to translate to %s, got %q", address, name)
+	}
+	if target := pm.external.rewriteTarget("net1", "db:5432"); target != address+":5432" {
+		t.Errorf("Expected SOCKS targets to be rewritten, got %s", target)
+	}
+
+	// Clients on other networks do not see the service
+	networksMutex.Lock()
+	networks["127.0.0.1"] = "net2"
+	networksMutex.Unlock()
+	resp, _, err = client.Exchange(req, server)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if resp.Rcode != dns.RcodeNameError {
+		t.Errorf("Expected NXDOMAIN on another network, got %s", dns.RcodeToString[resp.Rcode])
+	}
+	if target := pm.external.rewriteTarget("net2", "db:5432"); target != "db:5432" {
+		t.Errorf("Expected targets on another network to stay unchanged, got %s", target)
+	}
+
+	pm.SetExternalServices("net1", nil)
+	if _, ok := pm.external.lookupNetwork("net1", "db"); ok {
+		t.Error("Expected the services to be removed")
+	}
+}
@@ -25,8 +25,19 @@ type TrafficFilter struct {
 	allowlistRegex map[string]*regexp.Regexp
 	// blocklistRegex contains pre-compiled regex patterns for blocklist wildcards
 	blocklistRegex map[string]*regexp.Regexp
-	// stats tracks traffic statistics
+	// stats tracks traffic statistics; its LogEntries are only filled in
+	// copies returned by GetStats
 	stats *TrafficStats
+	// logs holds the recent traffic log entries
+	logs *logRing
+	// usage holds per-container byte counters by day
+	usage map[usageKey]*UsageRecord
+	// endpoints holds the traffic counters of each endpoint by endpoint ID
+	endpoints map[string]*EndpointTraffic
+	// anomalies flags containers whose refusals rise abruptly
+	anomalies *AnomalyDetector
+	// statsMutex protects concurrent access to stats, usage and endpoints
+	statsMutex sync.RWMutex
 	// mutex protects concurrent access to filter state
 	mutex sync.RWMutex
 }
@@ -67,14 +78,15 @@ type TrafficStats struct {
 	I2PConnectionsBlocked int64
 	// NonI2PConnectionsBlocked counts blocked non-I2P connections
 	NonI2PConnectionsBlocked int64
+	// UnknownSourcesRejected counts proxy connections refused because the
+	// client is not an endpoint of a managed network
+	UnknownSourcesRejected int64
 	// TotalBytesTransferred tracks total data volume
 	TotalBytesTransferred int64
 	// LastActivity records the timestamp of the last network activity
 	LastActivity time.Time
-	// LogEntries contains recent traffic log entries
+	// LogEntries contains recent traffic log entries, oldest first
 	LogEntries []TrafficLogEntry
-	// mutex protects concurrent access to stats
-	mutex sync.RWMutex
 }
 
 // TrafficLogEntry represents a single traffic event.
@@ -107,9 +119,11 @@ func NewTrafficFilter(config *FilterConfig) *TrafficFilter {
 		blocklist:      make(map[string]bool),
 		allowlistRegex: make(map[string]*regexp.Regexp),
 		blocklistRegex: make(map[string]*regexp.Regexp),
-		stats: &TrafficStats{
-			LogEntries: make([]TrafficLogEntry, 0, config.MaxLogEntries),
-		},
+		stats:          &TrafficStats{},
+		logs:           newLogRing(config.MaxLogEntries),
+		usage:          make(map[usageKey]*UsageRecord),
+		endpoints:      make(map[string]*EndpointTraffic),
+		anomalies:      NewAnomalyDetector(DefaultAnomalyConfig()),
 	}
 }
 
@@ -126,6 +140,7 @@ func (tf *TrafficFilter) UpdateConfig(config *FilterConfig) {
 	defer tf.mutex.Unlock()
 
 	tf.config = config
+	tf.logs.resize(config.MaxLogEntries)
 
 	if tf.config.LogTraffic {
 		log.Printf("Updated traffic filter configuration: allowlist=%v, blocklist=%v",
@@ -246,58 +261,97 @@ func (tf *TrafficFilter) RemoveFromBlocklist(destination string) {
 // This method checks the destination against allowlist/blocklist rules and
 // returns the decision along with a reason for logging.
 func (tf *TrafficFilter) ShouldAllowConnection(destination string, protocol string) (bool, string) {
+	allowed, reason, _ := tf.evaluateConnection(destination, protocol)
+	return allowed, reason
+}
+
+// ShouldAllowConnectionFrom is ShouldAllowConnection for a connection made
+// by a container; refusals are passed to the anomaly detector.
+func (tf *TrafficFilter) ShouldAllowConnectionFrom(containerID, destination, protocol string) (bool, string) {
+	allowed, reason, kind := tf.evaluateConnection(destination, protocol)
+	if !allowed && tf.anomalies != nil {
+		tf.anomalies.Record(containerID, kind, time.Now())
+	}
+	return allowed, reason
+}
+
+// evaluateConnection applies the filtering rules to a destination. Refusals
+// are classified as AnomalyNonI2PBlocked or AnomalyDenied.
+func (tf *TrafficFilter) evaluateConnection(destination string, protocol string) (bool, string, string) {
 	tf.mutex.RLock()
 	defer tf.mutex.RUnlock()
 
 	// Normalize destination for consistent matching
 	dest := strings.ToLower(destination)
 
-	// Extract hostname from destination (remove port if present)
-	host, _, err := net.SplitHostPort(dest)
+	decision := tf.decideLocked(filterHost(dest))
+	switch {
+	case decision.allowed:
+		tf.logTrafficEvent("ALLOW", protocol, "", dest, decision.reason, 0)
+		tf.incrementStat(func() { tf.stats.I2PConnectionsAllowed++ })
+		return true, decision.reason, ""
+	case decision.rule == FilterRuleNonI2P:
+		tf.logTrafficEvent("BLOCK", protocol, "", dest, decision.reason, 0)
+		tf.incrementStat(func() { tf.stats.NonI2PConnectionsBlocked++ })
+		return false, decision.reason, AnomalyNonI2PBlocked
+	default:
+		tf.logTrafficEvent("BLOCK", protocol, "", dest, decision.reason, 0)
+		tf.incrementStat(func() { tf.stats.I2PConnectionsBlocked++ })
+		return false, decision.reason, AnomalyDenied
+	}
+}
+
+// filterHost returns the host of a lower-cased destination, which may
+// carry a port.
+func filterHost(destination string) string {
+	host, _, err := net.SplitHostPort(destination)
 	if err != nil {
 		// Destination might not have a port, use as-is
-		host = dest
+		return destination
 	}
+	return host
+}
 
-	// Check if this is an I2P destination
+// filterDecision is the outcome of the filtering rules for a host.
+type filterDecision struct {
+	// allowed reports whether the host may be connected to
+	allowed bool
+	// rule is the FilterRule constant that decided
+	rule string
+	// pattern is the list entry the host matched, if a list decided
+	pattern string
+	// reason describes the decision for logs
+	reason string
+}
+
+// decideLocked applies the filtering rules to a lower-cased host without
+// logging or counting the decision. The caller must hold tf.mutex.
+func (tf *TrafficFilter) decideLocked(host string) filterDecision {
+	// Non-I2P traffic is always blocked
 	if !tf.isI2PDestination(host) {
-		// Non-I2P traffic is always blocked
-		reason := fmt.Sprintf("Non-I2P destination blocked: %s", host)
-		tf.logTrafficEvent("BLOCK", protocol, "", dest, reason, 0)
-		tf.incrementStat(func() { tf.stats.NonI2PConnectionsBlocked++ })
-		return false, reason
+		return filterDecision{rule: FilterRuleNonI2P, reason: fmt.Sprintf("Non-I2P destination blocked: %s", host)}
 	}
 
 	// Check allowlist first (takes precedence)
 	if tf.config.EnableAllowlist {
-		if allowed := tf.matchesPattern(host, tf.allowlist, tf.allowlistRegex); allowed {
-			reason := fmt.Sprintf("I2P destination allowed by allowlist: %s", host)
-			tf.logTrafficEvent("ALLOW", protocol, "", dest, reason, 0)
-			tf.incrementStat(func() { tf.stats.I2PConnectionsAllowed++ })
-			return true, reason
+		if pattern, ok := tf.matchingPattern(host, tf.allowlist, tf.allowlistRegex); ok {
+			return filterDecision{allowed: true, rule: FilterRuleAllowlist, pattern: pattern,
+				reason: fmt.Sprintf("I2P destination allowed by allowlist: %s", host)}
 		}
 		// If allowlist is enabled but destination not found, block it
-		reason := fmt.Sprintf("I2P destination not in allowlist: %s", host)
-		tf.logTrafficEvent("BLOCK", protocol, "", dest, reason, 0)
-		tf.incrementStat(func() { tf.stats.I2PConnectionsBlocked++ })
-		return false, reason
+		return filterDecision{rule: FilterRuleNotAllowlisted, reason: fmt.Sprintf("I2P destination not in allowlist: %s", host)}
 	}
 
 	// Check blocklist
 	if tf.config.EnableBlocklist {
-		if blocked := tf.matchesPattern(host, tf.blocklist, tf.blocklistRegex); blocked {
-			reason := fmt.Sprintf("I2P destination blocked by blocklist: %s", host)
-			tf.logTrafficEvent("BLOCK", protocol, "", dest, reason, 0)
-			tf.incrementStat(func() { tf.stats.I2PConnectionsBlocked++ })
-			return false, reason
+		if pattern, ok := tf.matchingPattern(host, tf.blocklist, tf.blocklistRegex); ok {
+			return filterDecision{rule: FilterRuleBlocklist, pattern: pattern,
+				reason: fmt.Sprintf("I2P destination blocked by blocklist: %s", host)}
 		}
 	}
 
 	// Default: allow I2P traffic if not explicitly blocked
-	reason := fmt.Sprintf("I2P destination allowed: %s", host)
-	tf.logTrafficEvent("ALLOW", protocol, "", dest, reason, 0)
-	tf.incrementStat(func() { tf.stats.I2PConnectionsAllowed++ })
-	return true, reason
+	return filterDecision{allowed: true, rule: FilterRuleDefault, reason: fmt.Sprintf("I2P destination allowed: %s", host)}
 }
 
 // LogConnection records a completed connection for traffic analysis.
@@ -318,58 +372,53 @@ func (tf *TrafficFilter) LogConnection(source, destination string, protocol stri
 	tf.logTrafficEvent("LOG", protocol, source, destination, reason, bytesTransferred)
 }
 
+// RejectSource records a proxy connection refused because of its client address.
+func (tf *TrafficFilter) RejectSource(source, protocol, reason string) {
+	tf.mutex.Lock()
+	defer tf.mutex.Unlock()
+
+	tf.incrementStat(func() { tf.stats.UnknownSourcesRejected++ })
+	tf.logTrafficEvent("BLOCK", protocol, source, "", "Unknown client rejected: "+reason, 0)
+}
+
 // GetStats returns a copy of current traffic statistics.
 func (tf *TrafficFilter) GetStats() TrafficStats {
-	tf.stats.mutex.RLock()
-	defer tf.stats.mutex.RUnlock()
+	tf.statsMutex.RLock()
+	defer tf.statsMutex.RUnlock()
 
 	// Create a deep copy of stats
 	statsCopy := TrafficStats{
 		I2PConnectionsAllowed:    tf.stats.I2PConnectionsAllowed,
 		I2PConnectionsBlocked:    tf.stats.I2PConnectionsBlocked,
 		NonI2PConnectionsBlocked: tf.stats.NonI2PConnectionsBlocked,
+		UnknownSourcesRejected:   tf.stats.UnknownSourcesRejected,
 		TotalBytesTransferred:    tf.stats.TotalBytesTransferred,
 		LastActivity:             tf.stats.LastActivity,
-		LogEntries:               make([]TrafficLogEntry, len(tf.stats.LogEntries)),
+		LogEntries:               tf.logs.recent(0),
 	}
-
-	copy(statsCopy.LogEntries, tf.stats.LogEntries)
 	return statsCopy
 }
 
-// GetRecentLogs returns recent traffic log entries.
+// GetRecentLogs returns up to limit of the newest traffic log entries, oldest
+// first. A limit of zero or less returns all entries.
 func (tf *TrafficFilter) GetRecentLogs(limit int) []TrafficLogEntry {
-	tf.stats.mutex.RLock()
-	defer tf.stats.mutex.RUnlock()
-
-	if limit <= 0 || limit > len(tf.stats.LogEntries) {
-		limit = len(tf.stats.LogEntries)
-	}
-
-	// Return the most recent entries
-	start := len(tf.stats.LogEntries) - limit
-	if start < 0 {
-		start = 0
-	}
-
-	logs := make([]TrafficLogEntry, limit)
-	copy(logs, tf.stats.LogEntries[start:])
-	return logs
+	return tf.logs.recent(limit)
 }
 
 // ClearStats resets all traffic statistics and logs
 func (tf *TrafficFilter) ClearStats() {
-	tf.stats.mutex.Lock()
-	defer tf.stats.mutex.Unlock()
+	tf.statsMutex.Lock()
+	defer tf.statsMutex.Unlock()
 
 	tf.stats.I2PConnectionsAllowed = 0
 	tf.stats.I2PConnectionsBlocked = 0
 	tf.stats.NonI2PConnectionsBlocked = 0
+	tf.stats.UnknownSourcesRejected = 0
 	tf.stats.TotalBytesTransferred = 0
 	tf.stats.LastActivity = time.Time{}
-	tf.stats.LogEntries = make([]TrafficLogEntry, 0)
+	tf.logs.reset()
 
-	// Create log entry directly without using logTrafficEvent to avoid deadlock
+	// Create log entry directly so it is recorded even with logging disabled
 	logEntry := TrafficLogEntry{
 		Timestamp:        time.Now(),
 		Action:           "ADMIN",
@@ -379,7 +428,7 @@ func (tf *TrafficFilter) ClearStats() {
 		Reason:           "Statistics cleared",
 		BytesTransferred: 0,
 	}
-	tf.stats.LogEntries = append(tf.stats.LogEntries, logEntry)
+	tf.logs.add(logEntry)
 } // GetAllowlist returns a copy of the current allowlist
 func (tf *TrafficFilter) GetAllowlist() []string {
 	tf.mutex.RLock()
@@ -454,22 +503,24 @@ func (tf *TrafficFilter) compileWildcardPattern(pattern string) (*regexp.Regexp,
 	return regexp.Compile(regexPattern)
 }
 
-// matchesPattern checks if a destination matches any pattern in the map.
-// This optimized version uses pre-compiled regex patterns from the cache.
-func (tf *TrafficFilter) matchesPattern(destination string, patterns map[string]bool, regexCache map[string]*regexp.Regexp) bool {
+// matchingPattern returns the pattern in the given map a destination
+// matches. An exact entry is preferred; of several matching wildcards the
+// first in lexical order is returned, so the answer does not depend on map
+// iteration order.
+func (tf *TrafficFilter) matchingPattern(destination string, patterns map[string]bool, regexCache map[string]*regexp.Regexp) (string, bool) {
 	// Check for exact match first
 	if patterns[destination] {
-		return true
+		return destination, true
 	}
 
 	// Check wildcard patterns using cached compiled regexes
+	var match string
 	for pattern := range patterns {
-		if tf.matchesWildcardCached(destination, pattern, regexCache) {
-			return true
+		if (match == "" || pattern < match) && tf.matchesWildcardCached(destination, pattern, regexCache) {
+			match = pattern
 		}
 	}
-
-	return false
+	return match, match != ""
 }
 
 // matchesWildcardCached checks if a destination matches a wildcard pattern using cached regex.
@@ -509,17 +560,8 @@ func (tf *TrafficFilter) logTrafficEvent(action, protocol, source, destination,
 		BytesTransferred: bytes,
 	}
 
-	// Add to stats log entries
-	tf.stats.mutex.Lock()
-	tf.stats.LogEntries = append(tf.stats.LogEntries, entry)
-
-	// Limit log entries to prevent memory growth
-	if len(tf.stats.LogEntries) > tf.config.MaxLogEntries {
-		// Remove oldest entries
-		copy(tf.stats.LogEntries, tf.stats.LogEntries[1:])
-		tf.stats.LogEntries = tf.stats.LogEntries[:tf.config.MaxLogEntries]
-	}
-	tf.stats.mutex.Unlock()
+	// The ring overwrites the oldest entry once it is full
+	tf.logs.add(entry)
 
 	// Log to system logger
 	log.Printf("TRAFFIC %s: %s %s -> %s (%s)", action, protocol, source, destination, reason)
@@ -528,7 +570,7 @@ func (tf *TrafficFilter) logTrafficEvent(action, protocol, source, destination,
 // incrementStat safely increments a statistic counter with proper mutex protection.
 // This helper prevents data races when updating stats from methods that hold tf.mutex.
 func (tf *TrafficFilter) incrementStat(incrementFunc func()) {
-	tf.stats.mutex.Lock()
+	tf.statsMutex.Lock()
 	incrementFunc()
-	tf.stats.mutex.Unlock()
+	tf.statsMutex.Unlock()
 }
@@ -0,0 +1,160 @@
+// Package proxy provides the firewall backends transparent interception is
+// installed with.
+//
+// A Firewall renders the rules redirecting a container subnet's traffic to
+// the SOCKS proxy and DNS resolver and installs or removes them. Rendering is
+// separate from installing so the exact rules can be inspected (see
+// PlanNetworkRules) and tested without touching the host firewall. The
+// backend is selected with FIREWALL_BACKEND: iptables (default), nftables or
+// noop.
+//
+// The chains of anti-spoofing, isolation, outbound and egress blocking and
+// the proxy input rules go through the backend too. They are written in
+// iptables syntax, or ebtables syntax for bridged frames, which the iptables
+// backend runs as is, the nftables backend translates into tables of its own
+// and the noop backend ignores.
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Firewall backends selected with FIREWALL_BACKEND.
+const (
+	// FirewallIptables installs interception with iptables and ip6tables (default)
+	FirewallIptables = "iptables"
+	// FirewallNftables installs interception as nftables tables, replaced atomically
+	FirewallNftables = "nftables"
+	// FirewallNoop installs nothing, for hosts whose firewall is managed elsewhere
+	FirewallNoop = "noop"
+)
+
+// RuleFamily is the packet family a rule of the plugin's own chains applies
+// to, named after the command whose syntax the rule is written in.
+type RuleFamily string
+
+// Rule families of the plugin's own chains.
+const (
+	// FamilyIPv4 rules are written in iptables syntax
+	FamilyIPv4 RuleFamily = "iptables"
+	// FamilyIPv6 rules are written in ip6tables syntax
+	FamilyIPv6 RuleFamily = "ip6tables"
+	// FamilyBridge rules filter bridged frames and are written in ebtables syntax
+	FamilyBridge RuleFamily = "ebtables"
+)
+
+// InterceptionSpec describes the interception a Firewall installs.
+type InterceptionSpec struct {
+	// Subnet is the container subnet whose traffic is intercepted
+	Subnet *net.IPNet
+	// ProxyPort is the port of the SOCKS proxy
+	ProxyPort int
+	// DNSPort is the port of the DNS resolver
+	DNSPort int
+	// SyntheticIPv6 also redirects connections to SyntheticIPv6Range
+	SyntheticIPv6 bool
+}
+
+// Firewall installs transparent interception with one firewall backend.
+type Firewall interface {
+	// Name returns the backend name, e.g. FirewallIptables
+	Name() string
+	// Available reports whether the backend can be used on this host
+	Available(spec InterceptionSpec) error
+	// RenderRules returns the rules Install would apply, as the commands or
+	// script lines the backend runs, without applying them
+	RenderRules(spec InterceptionSpec) []string
+	// Install applies the rules, removing any it applied on failure
+	Install(spec InterceptionSpec) error
+	// Remove removes the rules applied by Install
+	Remove(spec InterceptionSpec) error
+
+	// CheckRules reports whether rules of family can be applied with RunRule
+	CheckRules(family RuleFamily) error
+	// RunRule applies one rule of the plugin's own chains, given as the
+	// arguments of the family's command, e.g. "-t filter -A CHAIN -j DROP"
+	RunRule(family RuleFamily, rule string) error
+	// ListRules lists a chain applied with RunRule as "-A CHAIN ..." rules,
+	// failing if the chain does not exist
+	ListRules(family RuleFamily, table, chain string) ([]string, error)
+	// RenderRule returns the command RunRule would run for rule, or "" if it
+	// would run none
+	RenderRule(family RuleFamily, rule string) string
+}
+
+// FirewallBackends returns the names of the firewall backends.
+func FirewallBackends() []string {
+	return []string{FirewallIptables, FirewallNftables, FirewallNoop}
+}
+
+// NewFirewall returns the firewall backend with the given name.
+func NewFirewall(name string) (Firewall, error) {
+	switch name {
+	case FirewallIptables:
+		return newIptablesFirewall(executeIptablesRule, executeIp6tablesRule), nil
+	case FirewallNftables:
+		return newNftablesFirewall(executeNftScript), nil
+	case FirewallNoop:
+		return noopFirewall{}, nil
+	case "ebpf":
+		// Requested but never implemented, so refused as unsupported rather than unknown
+		return nil, fmt.Errorf("firewall backend ebpf is not supported, use one of %s", strings.Join(FirewallBackends(), ", "))
+	}
+	return nil, fmt.Errorf("firewall backend must be one of %s, got %q", strings.Join(FirewallBackends(), ", "), name)
+}
+
+// SetFirewallBackend selects the firewall backend interception is installed
+// with. It must be called before the proxy starts.
+func (pm *ProxyManager) SetFirewallBackend(name string) error {
+	firewall, err := NewFirewall(name)
+	if err != nil {
+		return err
+	}
+	pm.interceptor.firewall = firewall
+	pm.config.Firewall = name
+	return nil
+}
+
+// FirewallBackend returns the name of the firewall backend in use.
+func (pm *ProxyManager) FirewallBackend() string {
+	return pm.interceptor.firewall.Name()
+}
+
+// noopFirewall installs nothing. Traffic is only intercepted if the host
+// firewall is set up to do so by other means.
+type noopFirewall struct{}
+
+func (noopFirewall) Name() string { return FirewallNoop }
+
+func (noopFirewall) Available(InterceptionSpec) error { return nil }
+
+func (noopFirewall) RenderRules(InterceptionSpec) []string { return nil }
+
+func (noopFirewall) Install(InterceptionSpec) error { return nil }
+
+func (noopFirewall) Remove(InterceptionSpec) error { return nil }
+
+func (noopFirewall) CheckRules(RuleFamily) error { return nil }
+
+func (noopFirewall) RunRule(RuleFamily, string) error { return nil }
+
+// ListRules reports every chain as missing, as none is ever created.
+func (noopFirewall) ListRules(family RuleFamily, table, chain string) ([]string, error) {
+	return nil, fmt.Errorf("%s chain %s (table %s) does not exist", family, chain, table)
+}
+
+func (noopFirewall) RenderRule(RuleFamily, string) string { return "" }
+
+// renderRules returns the commands running rules of family with the
+// firewall backend, leaving out rules it would not run.
+func (pm *ProxyManager) renderRules(family RuleFamily, rules []string) []string {
+	var rendered []string
+	for _, rule := range rules {
+		if command := pm.interceptor.firewall.RenderRule(family, rule); command != "" {
+			rendered = append(rendered, command)
+		}
+	}
+	return rendered
+}
@@ -0,0 +1,248 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func testInterceptionSpec(syntheticIPv6 bool) InterceptionSpec {
+	_, subnet, _ := net.ParseCIDR("172.20.0.0/16")
+	return InterceptionSpec{Subnet: subnet, ProxyPort: 1080, DNSPort: 5353, SyntheticIPv6: syntheticIPv6}
+}
+
+func TestNewFirewall(t *testing.T) {
+	for _, name := range FirewallBackends() {
+		firewall, err := NewFirewall(name)
+		if err != nil {
+			t.Fatalf("Failed to create %s backend: %v", name, err)
+		}
+		if firewall.Name() != name {
+			t.Errorf("Expected backend %s, got %s", name, firewall.Name())
+		}
+	}
+
+	if _, err := NewFirewall("pf"); err == nil || !strings.Contains(err.Error(), "nftables") {
+		t.Errorf("Expected an error listing the backends, got %v", err)
+	}
+}
+
+func TestIptablesFirewall_RenderRules(t *testing.T) {
+	firewall := newIptablesFirewall(nil, nil)
+
+	rules := firewall.RenderRules(testInterceptionSpec(false))
+	if !containsRule(rules, "iptables -t nat -A I2P_REDIRECT -s 172.20.0.0/16 -p udp --dport 53 -j REDIRECT --to-port 5353") ||
+		!containsRule(rules, "iptables -t filter -A I2P_FILTER -s 172.20.0.0/16 -j DROP") {
+		t.Errorf("Expected iptables commands, got %v", rules)
+	}
+	for _, rule := range rules {
+		if strings.HasPrefix(rule, "ip6tables ") {
+			t.Errorf("Expected no ip6tables commands in IPv4 mode, got %q", rule)
+		}
+	}
+
+	rules = firewall.RenderRules(testInterceptionSpec(true))
+	if !containsRule(rules, "ip6tables -t nat -A PREROUTING -d "+SyntheticIPv6Range+" -j I2P_REDIRECT6") {
+		t.Errorf("Expected ip6tables commands in IPv6 mode, got %v", rules)
+	}
+}
+
+func TestIptablesFirewall_InstallRollsBack(t *testing.T) {
+	var ran []string
+	run := func(rule string) error {
+		ran = append(ran, rule)
+		if strings.Contains(rule, "-N I2P_FILTER") {
+			return errors.New("chain exists")
+		}
+		return nil
+	}
+	firewall := newIptablesFirewall(run, run)
+
+	if err := firewall.Install(testInterceptionSpec(false)); err == nil {
+		t.Fatal("Expected the failing rule to fail the install")
+	}
+	if ran[len(ran)-1] != "-t nat -X I2P_REDIRECT" {
+		t.Errorf("Expected the installed rules to be removed, ran %v", ran)
+	}
+}
+
+func TestNftablesFirewall_RenderRules(t *testing.T) {
+	var scripts []string
+	firewall := newNftablesFirewall(func(script string) error {
+		scripts = append(scripts, script)
+		return nil
+	})
+
+	rules := firewall.RenderRules(testInterceptionSpec(false))
+	if rules[0] != "table ip i2p" || rules[1] != "delete table ip i2p" {
+		t.Errorf("Expected the table to be replaced, got %v", rules[:2])
+	}
+	if !containsRule(rules, "    ip saddr 172.20.0.0/16 udp dport 53 redirect to :5353") ||
+		!containsRule(rules, "    ip saddr 172.20.0.0/16 tcp dport != 1080 redirect to :1080") {
+		t.Errorf("Expected redirect rules, got %v", rules)
+	}
+	if strings.Contains(strings.Join(rules, "\n"), "ip6") {
+		t.Errorf("Expected no IPv6 table in IPv4 mode, got %v", rules)
+	}
+	if rules = firewall.RenderRules(testInterceptionSpec(true)); !containsRule(rules,
+		"    ip6 daddr "+SyntheticIPv6Range+" meta l4proto tcp redirect to :1080") {
+		t.Errorf("Expected an IPv6 redirect in IPv6 mode, got %v", rules)
+	}
+
+	// Install applies exactly the rendered script
+	spec := testInterceptionSpec(true)
+	if err := firewall.Install(spec); err != nil {
+		t.Fatalf("Failed to install: %v", err)
+	}
+	if scripts[0] != strings.Join(firewall.RenderRules(spec), "\n")+"\n" {
+		t.Errorf("Expected the rendered script to be applied, got %q", scripts[0])
+	}
+	if err := firewall.Remove(spec); err != nil {
+		t.Fatalf("Failed to remove: %v", err)
+	}
+	if !strings.Contains(scripts[1], "delete table ip i2p\n") || !strings.Contains(scripts[1], "delete table ip6 i2p6\n") {
+		t.Errorf("Expected both tables to be deleted, got %q", scripts[1])
+	}
+}
+
+func TestNoopFirewall(t *testing.T) {
+	spec := testInterceptionSpec(false)
+
+	if rules := (noopFirewall{}).RenderRules(spec); len(rules) != 0 {
+		t.Errorf("Expected no noop rules, got %v", rules)
+	}
+	if err := (noopFirewall{}).Install(spec); err != nil {
+		t.Errorf("Expected the noop install to succeed, got %v", err)
+	}
+	if _, err := NewFirewall("ebpf"); err == nil || !strings.Contains(err.Error(), "ebpf is not supported") {
+		t.Errorf("Expected the eBPF backend to be refused as unsupported, got %v", err)
+	}
+
+	// The plugin's own chains are not created either
+	_, subnet, _ := net.ParseCIDR("172.20.0.0/16")
+	pm := NewProxyManager(ProxyOptions{Config: DefaultProxyConfig(subnet)})
+	if err := pm.SetFirewallBackend(FirewallNoop); err != nil {
+		t.Fatalf("Failed to select noop: %v", err)
+	}
+	if err := pm.BindEndpointAddress("ep1", net.ParseIP("172.20.0.2"), "02:42:ac:14:00:02"); err != nil {
+		t.Fatalf("Expected binding to succeed without a firewall, got %v", err)
+	}
+	if pm.chainExists(antiSpoofIptablesChain) {
+		t.Error("Expected the noop backend to create no chains")
+	}
+	if err := pm.UnbindEndpointAddress("ep1"); err != nil {
+		t.Errorf("Expected unbinding to succeed without a firewall, got %v", err)
+	}
+	if rules := pm.PlanNetworkEgressRules([]*net.IPNet{subnet}, nil); len(rules) != 0 {
+		t.Errorf("Expected no planned rules, got %v", rules)
+	}
+}
+
+func TestNftablesFirewall_RunRule(t *testing.T) {
+	var scripts []string
+	fail := false
+	firewall := newNftablesFirewall(func(script string) error {
+		if fail {
+			return errors.New("nft failed")
+		}
+		scripts = append(scripts, script)
+		return nil
+	})
+	_, subnet, _ := net.ParseCIDR("172.20.0.0/16")
+	pm := NewProxyManager(ProxyOptions{Config: DefaultProxyConfig(subnet)})
+	pm.interceptor.firewall = firewall
+
+	if err := pm.BindEndpointAddress("ep1", net.ParseIP("172.20.0.2"), "02:42:ac:14:00:02"); err != nil {
+		t.Fatalf("Failed to bind endpoint: %v", err)
+	}
+	ip := firewall.rulesets[FamilyIPv4].script(FamilyIPv4)
+	for _, line := range []string{
+		"  chain I2P_ANTISPOOF {\n    ip saddr 172.20.0.2 ether saddr != 02:42:ac:14:00:02 drop\n  }",
+		"  chain INPUT {\n    type filter hook input priority filter - 1; policy accept;\n    jump I2P_ANTISPOOF\n  }",
+	} {
+		if !strings.Contains(ip, line) {
+			t.Errorf("Expected %q in the IPv4 table, got:\n%s", line, ip)
+		}
+	}
+	bridge := firewall.rulesets[FamilyBridge].script(FamilyBridge)
+	for _, line := range []string{
+		"    ether type ip ether saddr 02:42:ac:14:00:02 ip saddr != 172.20.0.2 drop",
+		"    ether type arp ether saddr 02:42:ac:14:00:02 arp saddr ip != 172.20.0.2 drop",
+		"    ether type arp ether saddr 02:42:ac:14:00:02 arp saddr ether != 02:42:ac:14:00:02 drop",
+	} {
+		if !strings.Contains(bridge, line) {
+			t.Errorf("Expected %q in the bridge table, got:\n%s", line, bridge)
+		}
+	}
+	if last := scripts[len(scripts)-1]; !strings.HasPrefix(last, "table bridge i2p_bridge\ndelete table bridge i2p_bridge\n") {
+		t.Errorf("Expected every change to replace the table, got %q", last)
+	}
+
+	// A rule nft refuses leaves the ruleset as it was
+	fail = true
+	if err := pm.BlockEndpointOutbound("ep1", net.ParseIP("172.20.0.2")); err == nil {
+		t.Fatal("Expected the failing nft script to fail the block")
+	}
+	if _, err := firewall.ListRules(FamilyIPv4, "filter", noOutboundChain); err == nil {
+		t.Error("Expected the failed chain not to be recorded")
+	}
+	fail = false
+
+	// Removing the last binding deletes the tables
+	if err := pm.UnbindEndpointAddress("ep1"); err != nil {
+		t.Fatalf("Failed to unbind endpoint: %v", err)
+	}
+	if last := scripts[len(scripts)-1]; last != "table bridge i2p_bridge\ndelete table bridge i2p_bridge\n" {
+		t.Errorf("Expected the bridge table to be deleted, got %q", last)
+	}
+	if rules := pm.renderRules(FamilyIPv4, antiSpoofIptablesChainRules()); len(rules) != 3 ||
+		rules[1] != "nft insert rule ip i2p_rules INPUT jump I2P_ANTISPOOF" {
+		t.Errorf("Expected nft commands, got %v", rules)
+	}
+}
+
+func TestNftStatement(t *testing.T) {
+	tests := []struct {
+		family   RuleFamily
+		rule     string
+		expected string
+	}{
+		{FamilyIPv4, "-s 10.0.0.2 -m conntrack --ctstate NEW -j DROP", "ip saddr 10.0.0.2 ct state new drop"},
+		{FamilyIPv4, "-s 10.0.0.0/24 -d 10.0.0.5 -p tcp --dport 7656 -j RETURN", "ip saddr 10.0.0.0/24 ip daddr 10.0.0.5 tcp dport 7656 return"},
+		{FamilyIPv4, "-p udp --dport 53 -j I2P_PROXY_INPUT", "udp dport 53 jump I2P_PROXY_INPUT"},
+		{FamilyIPv6, "-s fd00::/64 -d fd00::/64 -j ACCEPT", "ip6 saddr fd00::/64 ip6 daddr fd00::/64 accept"},
+		{FamilyBridge, "-p IPv4 --ip-src 10.0.0.0/24 --ip-dst 10.0.0.0/24 -j DROP", "ether type ip ip saddr 10.0.0.0/24 ip daddr 10.0.0.0/24 drop"},
+	}
+	for _, tt := range tests {
+		statement, err := nftStatement(tt.family, strings.Fields(tt.rule))
+		if err != nil || statement != tt.expected {
+			t.Errorf("nftStatement(%s, %q) = %q, %v; want %q", tt.family, tt.rule, statement, err, tt.expected)
+		}
+	}
+
+	if _, err := nftStatement(FamilyIPv4, strings.Fields("-s 10.0.0.2 --sport 80 -j DROP")); err == nil {
+		t.Error("Expected an unsupported option to be refused")
+	}
+	if _, err := nftStatement(FamilyIPv4, strings.Fields("-s 10.0.0.2")); err == nil {
+		t.Error("Expected a rule without target to be refused")
+	}
+}
+
+func TestProxyManager_SetFirewallBackend(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("172.20.0.0/16")
+	pm := NewProxyManager(ProxyOptions{Config: DefaultProxyConfig(subnet)})
+
+	if pm.FirewallBackend() != FirewallIptables {
+		t.Errorf("Expected the iptables backend by default, got %s", pm.FirewallBackend())
+	}
+	if err := pm.SetFirewallBackend("pf"); err == nil {
+		t.Error("Expected an unknown backend to be refused")
+	}
+	if err := pm.SetFirewallBackend(FirewallNftables); err != nil {
+		t.Fatalf("Failed to select nftables: %v", err)
+	}
+	if rules := pm.PlanNetworkRules(subnet, net.ParseIP("172.20.0.1"), true); len(rules) == 0 || rules[0] != "table ip i2p" {
+		t.Errorf("Expected nft script lines in the plan, got %v", rules)
+	}
+}
@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func FuzzParseSOCKS5Request(f *testing.F) {
+	f.Add([]byte{0x05, 0x01, 0x00, 0x01, 127, 0, 0, 1, 0x00, 0x50})
+	f.Add(append(append([]byte{0x05, 0x01, 0x00, 0x03, 11}, "example.i2p"...), 0x01, 0xbb))
+	f.Add([]byte{0x05, 0x01, 0x00, 0x03, 0x00, 0x00, 0x50})
+	f.Add([]byte{0x05, 0x02, 0x00, 0x01})
+	f.Add([]byte{0x05, 0x01, 0x00, 0x04})
+	f.Add(append(append([]byte{0x05, 0x01, 0x00, 0x04}, net.ParseIP("fd69:3270:b32::1")...), 0x00, 0x50))
+	f.Add([]byte{})
+
+	proxy := &SOCKSProxy{}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		target, err := proxy.parseSOCKS5Request(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		host, _, err := net.SplitHostPort(target)
+		if err != nil {
+			t.Fatalf("parseSOCKS5Request(%x) returned unsplittable target %q: %v", data, target, err)
+		}
+		if net.ParseIP(host) == nil && !isValidSOCKSDomain([]byte(host)) {
+			t.Errorf("parseSOCKS5Request(%x) returned invalid host %q", data, host)
+		}
+	})
+}
+
+func FuzzI2PDNSResolver_buildResponse(f *testing.F) {
+	for _, name := range []string{"example.i2p.", "example.com.", "abcdefghijklmnop.b32.i2p."} {
+		req := new(dns.Msg)
+		req.SetQuestion(name, dns.TypeA)
+		if packed, err := req.Pack(); err == nil {
+			f.Add(packed)
+		}
+	}
+	f.Add([]byte{})
+
+	resolver := NewI2PDNSResolver("127.0.0.1:5353")
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		req := new(dns.Msg)
+		if err := req.Unpack(data); err != nil {
+			return
+		}
+
+		resp := resolver.buildResponse(req)
+		if len(req.Question) != 1 && resp.Rcode != dns.RcodeFormatError {
+			t.Errorf("expected FORMERR for %d questions, got rcode %d", len(req.Question), resp.Rcode)
+		}
+		for _, rr := range resp.Answer {
+			a, ok := rr.(*dns.A)
+			if !ok {
+				continue
+			}
+			if !a.A.Mask(net.CIDRMask(15, 32)).Equal(net.IPv4(198, 18, 0, 0)) {
+				t.Errorf("answer %s outside the synthetic range", a.A)
+			}
+		}
+		if _, err := resp.Pack(); err != nil {
+			t.Errorf("failed to pack response: %v", err)
+		}
+	})
+}
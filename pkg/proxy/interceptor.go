@@ -11,10 +11,10 @@ import (
 	"strings"
 )
 
-// TrafficInterceptor manages iptables rules for transparent traffic interception.
+// TrafficInterceptor manages the firewall rules for transparent traffic interception.
 //
-// The interceptor sets up iptables rules to redirect container traffic to the
-// I2P proxy, ensuring all traffic flows through I2P tunnels.
+// The interceptor sets up rules with its Firewall backend to redirect container
+// traffic to the I2P proxy, ensuring all traffic flows through I2P tunnels.
 type TrafficInterceptor struct {
 	// containerSubnet is the subnet used by I2P containers
 	containerSubnet *net.IPNet
@@ -22,58 +22,166 @@ type TrafficInterceptor struct {
 	proxyPort int
 	// dnsPort is the port where the DNS resolver listens
 	dnsPort int
+	// syntheticIPv6 also redirects traffic to SyntheticIPv6Range
+	syntheticIPv6 bool
+	// firewall is the backend rules are installed with
+	firewall Firewall
 }
 
 // NewTrafficInterceptor creates a new traffic interceptor for the given subnet.
 //
 // The interceptor will set up iptables rules to redirect traffic from containers
-// in the specified subnet to the I2P proxy services.
+// in the specified subnet to the I2P proxy services, unless another backend is
+// selected with ProxyManager.SetFirewallBackend.
 func NewTrafficInterceptor(subnet *net.IPNet, proxyPort, dnsPort int) *TrafficInterceptor {
 	return &TrafficInterceptor{
 		containerSubnet: subnet,
 		proxyPort:       proxyPort,
 		dnsPort:         dnsPort,
+		firewall:        newIptablesFirewall(executeIptablesRule, executeIp6tablesRule),
 	}
 }
 
-// SetupInterception configures iptables rules for transparent traffic proxying.
+// spec returns the interception the firewall installs.
+func (t *TrafficInterceptor) spec() InterceptionSpec {
+	return InterceptionSpec{
+		Subnet:        t.containerSubnet,
+		ProxyPort:     t.proxyPort,
+		DNSPort:       t.dnsPort,
+		SyntheticIPv6: t.syntheticIPv6,
+	}
+}
+
+// SetupInterception configures the firewall rules for transparent traffic proxying.
 //
-// This method sets up the necessary iptables rules to:
+// This method sets up the necessary rules to:
 // 1. Redirect TCP traffic to the SOCKS proxy
 // 2. Redirect DNS traffic to the I2P DNS resolver
 // 3. Drop non-I2P traffic to prevent leaks
 func (t *TrafficInterceptor) SetupInterception() error {
-	rules := t.generateIptablesRules()
+	return t.firewall.Install(t.spec())
+}
 
-	for _, rule := range rules {
-		if err := t.executeIptablesRule(rule); err != nil {
+// CleanupInterception removes all firewall rules created for I2P traffic interception.
+//
+// This method should be called when the network is being torn down to ensure
+// no stale rules remain.
+func (t *TrafficInterceptor) CleanupInterception() error {
+	return t.firewall.Remove(t.spec())
+}
+
+// RenderRules returns the rules SetupInterception would install, without
+// installing them.
+func (t *TrafficInterceptor) RenderRules() []string {
+	return t.firewall.RenderRules(t.spec())
+}
+
+// IsAvailable checks if the firewall backend is available and the current
+// process has sufficient privileges to modify its rules.
+//
+// This method should be called before attempting to set up traffic interception
+// to ensure the system is properly configured.
+func (t *TrafficInterceptor) IsAvailable() error {
+	return t.firewall.Available(t.spec())
+}
+
+// iptablesFirewall installs interception with iptables and ip6tables.
+type iptablesFirewall struct {
+	// run executes an iptables rule (replaceable in tests)
+	run func(rule string) error
+	// run6 executes an ip6tables rule (replaceable in tests)
+	run6 func(rule string) error
+	// runBridge executes an ebtables rule
+	runBridge func(rule string) error
+}
+
+// newIptablesFirewall returns an iptables backend executing rules with run
+// and run6, and bridge rules with ebtables.
+func newIptablesFirewall(run, run6 func(rule string) error) *iptablesFirewall {
+	return &iptablesFirewall{run: run, run6: run6, runBridge: executeEbtablesRule}
+}
+
+func (f *iptablesFirewall) Name() string { return FirewallIptables }
+
+// Available checks that iptables exists and can be run, and ip6tables too
+// when IPv6 synthetic addresses are intercepted.
+func (f *iptablesFirewall) Available(spec InterceptionSpec) error {
+	// Check if iptables command exists
+	if _, err := exec.LookPath("iptables"); err != nil {
+		return fmt.Errorf("iptables command not found: %w", err)
+	}
+
+	// Test if we can run iptables (requires root privileges)
+	cmd := exec.Command("iptables", "-L", "-n")
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("cannot execute iptables (insufficient privileges?): %w", err)
+	}
+
+	// IPv6 synthetic addresses are intercepted with ip6tables
+	if spec.SyntheticIPv6 {
+		if err := exec.Command("ip6tables", "-t", "nat", "-L", "-n").Run(); err != nil {
+			return fmt.Errorf("cannot execute ip6tables (required for IPv6 DNS address mode): %w", err)
+		}
+	}
+
+	return nil
+}
+
+// RenderRules returns the iptables and ip6tables commands Install runs.
+func (f *iptablesFirewall) RenderRules(spec InterceptionSpec) []string {
+	var rules []string
+	for _, rule := range generateIptablesRules(spec) {
+		rules = append(rules, "iptables "+rule)
+	}
+	for _, rule := range generateIp6tablesRules(spec) {
+		rules = append(rules, "ip6tables "+rule)
+	}
+	return rules
+}
+
+// Install adds the rules, removing them again if one fails.
+func (f *iptablesFirewall) Install(spec InterceptionSpec) error {
+	for _, rule := range generateIptablesRules(spec) {
+		if err := f.run(rule); err != nil {
 			// If rule fails, clean up any previously added rules
-			t.CleanupInterception()
+			f.Remove(spec)
 			return fmt.Errorf("failed to add iptables rule '%s': %w", rule, err)
 		}
 	}
 
+	for _, rule := range generateIp6tablesRules(spec) {
+		if err := f.run6(rule); err != nil {
+			f.Remove(spec)
+			return fmt.Errorf("failed to add ip6tables rule '%s': %w", rule, err)
+		}
+	}
+
 	return nil
 }
 
-// CleanupInterception removes all iptables rules created for I2P traffic interception.
-//
-// This method should be called when the network is being torn down to ensure
-// no stale iptables rules remain.
-func (t *TrafficInterceptor) CleanupInterception() error {
-	rules := t.generateIptablesRules()
+// Remove deletes the rules in reverse order, continuing past failures.
+func (f *iptablesFirewall) Remove(spec InterceptionSpec) error {
+	rules := generateIptablesRules(spec)
 
 	var errors []string
 
 	// Remove rules in reverse order (LIFO)
 	for i := len(rules) - 1; i >= 0; i-- {
-		deleteRule := strings.Replace(rules[i], "-A", "-D", 1)
-		if err := t.executeIptablesRule(deleteRule); err != nil {
+		deleteRule := undoRule(rules[i])
+		if err := f.run(deleteRule); err != nil {
 			// Log error but continue with cleanup
 			errors = append(errors, fmt.Sprintf("failed to remove rule '%s': %v", deleteRule, err))
 		}
 	}
 
+	rules6 := generateIp6tablesRules(spec)
+	for i := len(rules6) - 1; i >= 0; i-- {
+		deleteRule := undoRule(rules6[i])
+		if err := f.run6(deleteRule); err != nil {
+			errors = append(errors, fmt.Sprintf("failed to remove ip6tables rule '%s': %v", deleteRule, err))
+		}
+	}
+
 	if len(errors) > 0 {
 		return fmt.Errorf("cleanup errors: %s", strings.Join(errors, "; "))
 	}
@@ -81,14 +189,58 @@ func (t *TrafficInterceptor) CleanupInterception() error {
 	return nil
 }
 
+// CheckRules checks that the family's command exists and can list its
+// filter table.
+func (f *iptablesFirewall) CheckRules(family RuleFamily) error {
+	command := string(family)
+	if _, err := exec.LookPath(command); err != nil {
+		return fmt.Errorf("%s command not found: %w", command, err)
+	}
+	if err := exec.Command(command, "-t", "filter", "-L").Run(); err != nil {
+		return fmt.Errorf("cannot execute %s (insufficient privileges?): %w", command, err)
+	}
+	return nil
+}
+
+// RunRule runs rule with the family's command.
+func (f *iptablesFirewall) RunRule(family RuleFamily, rule string) error {
+	switch family {
+	case FamilyIPv6:
+		return f.run6(rule)
+	case FamilyBridge:
+		return f.runBridge(rule)
+	default:
+		return f.run(rule)
+	}
+}
+
+// ListRules lists a chain with the family's command.
+func (f *iptablesFirewall) ListRules(family RuleFamily, table, chain string) ([]string, error) {
+	return listChainRules(string(family), table, chain)
+}
+
+// RenderRule returns rule as a command line of the family's command.
+func (f *iptablesFirewall) RenderRule(family RuleFamily, rule string) string {
+	return string(family) + " " + rule
+}
+
+// undoRule returns the rule reverting rule: appended rules are deleted and
+// created chains, which are empty and unreferenced by then, are removed.
+func undoRule(rule string) string {
+	if strings.Contains(rule, " -N ") {
+		return strings.Replace(rule, " -N ", " -X ", 1)
+	}
+	return strings.Replace(rule, "-A", "-D", 1)
+}
+
 // generateIptablesRules creates the iptables rules needed for I2P traffic interception.
 //
 // The rules implement:
 // 1. DNS redirection to I2P DNS resolver
 // 2. TCP traffic redirection to SOCKS proxy
 // 3. Traffic dropping for non-I2P destinations
-func (t *TrafficInterceptor) generateIptablesRules() []string {
-	subnet := t.containerSubnet.String()
+func generateIptablesRules(spec InterceptionSpec) []string {
+	subnet := spec.Subnet.String()
 
 	return []string{
 		// Create custom chain for I2P traffic processing
@@ -96,13 +248,13 @@ func (t *TrafficInterceptor) generateIptablesRules() []string {
 
 		// Redirect DNS traffic (port 53) to I2P DNS resolver
 		fmt.Sprintf("-t nat -A I2P_REDIRECT -s %s -p udp --dport 53 -j REDIRECT --to-port %d",
-			subnet, t.dnsPort),
+			subnet, spec.DNSPort),
 		fmt.Sprintf("-t nat -A I2P_REDIRECT -s %s -p tcp --dport 53 -j REDIRECT --to-port %d",
-			subnet, t.dnsPort),
+			subnet, spec.DNSPort),
 
 		// Redirect TCP traffic to SOCKS proxy (excluding proxy port itself)
 		fmt.Sprintf("-t nat -A I2P_REDIRECT -s %s -p tcp ! --dport %d -j REDIRECT --to-port %d",
-			subnet, t.proxyPort, t.proxyPort),
+			subnet, spec.ProxyPort, spec.ProxyPort),
 
 		// Apply I2P_REDIRECT chain to FORWARD traffic from containers
 		// Note: Container traffic goes through FORWARD chain, not OUTPUT (which is for host-generated traffic)
@@ -113,11 +265,11 @@ func (t *TrafficInterceptor) generateIptablesRules() []string {
 
 		// Allow traffic to I2P proxy and DNS resolver
 		fmt.Sprintf("-t filter -A I2P_FILTER -s %s -p tcp --dport %d -j ACCEPT",
-			subnet, t.proxyPort),
+			subnet, spec.ProxyPort),
 		fmt.Sprintf("-t filter -A I2P_FILTER -s %s -p udp --dport %d -j ACCEPT",
-			subnet, t.dnsPort),
+			subnet, spec.DNSPort),
 		fmt.Sprintf("-t filter -A I2P_FILTER -s %s -p tcp --dport %d -j ACCEPT",
-			subnet, t.dnsPort),
+			subnet, spec.DNSPort),
 
 		// Allow loopback traffic
 		fmt.Sprintf("-t filter -A I2P_FILTER -s %s -d 127.0.0.0/8 -j ACCEPT", subnet),
@@ -135,37 +287,43 @@ func (t *TrafficInterceptor) generateIptablesRules() []string {
 	}
 }
 
-// executeIptablesRule executes a single iptables rule using the iptables command.
+// generateIp6tablesRules creates the ip6tables rules for IPv6 synthetic addresses.
 //
-// This method handles the actual execution of iptables commands and provides
-// error handling for common iptables failures.
-func (t *TrafficInterceptor) executeIptablesRule(rule string) error {
-	args := strings.Fields(rule)
-	cmd := exec.Command("iptables", args...)
+// In IPv6 address mode, I2P names resolve to SyntheticIPv6Range, so TCP
+// connections to that range are redirected to the SOCKS proxy, which maps
+// the address back to the name. No rules are needed in IPv4 mode.
+func generateIp6tablesRules(spec InterceptionSpec) []string {
+	if !spec.SyntheticIPv6 {
+		return nil
+	}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("iptables command failed: %s (output: %s)", err, string(output))
+	return []string{
+		"-t nat -N I2P_REDIRECT6",
+		fmt.Sprintf("-t nat -A I2P_REDIRECT6 -p tcp -j REDIRECT --to-port %d", spec.ProxyPort),
+		fmt.Sprintf("-t nat -A PREROUTING -d %s -j I2P_REDIRECT6", SyntheticIPv6Range),
 	}
+}
 
+// executeIp6tablesRule executes a single rule using the ip6tables command.
+func executeIp6tablesRule(rule string) error {
+	output, err := exec.Command("ip6tables", strings.Fields(rule)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ip6tables command failed: %s (output: %s)", err, string(output))
+	}
 	return nil
 }
 
-// IsAvailable checks if iptables is available and the current process has
-// sufficient privileges to modify iptables rules.
+// executeIptablesRule executes a single iptables rule using the iptables command.
 //
-// This method should be called before attempting to set up traffic interception
-// to ensure the system is properly configured.
-func (t *TrafficInterceptor) IsAvailable() error {
-	// Check if iptables command exists
-	if _, err := exec.LookPath("iptables"); err != nil {
-		return fmt.Errorf("iptables command not found: %w", err)
-	}
+// This function handles the actual execution of iptables commands and provides
+// error handling for common iptables failures.
+func executeIptablesRule(rule string) error {
+	args := strings.Fields(rule)
+	cmd := exec.Command("iptables", args...)
 
-	// Test if we can run iptables (requires root privileges)
-	cmd := exec.Command("iptables", "-L", "-n")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("cannot execute iptables (insufficient privileges?): %w", err)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("iptables command failed: %s (output: %s)", err, string(output))
 	}
 
 	return nil
@@ -0,0 +1,242 @@
+// Package proxy provides inter-container isolation.
+//
+// Containers on the same network normally reach each other directly over the
+// bridge. Isolated networks drop that east-west traffic with ebtables, which
+// sees bridged frames whether or not bridge netfilter is enabled, so
+// containers can only talk to each other through I2P destinations or as
+// explicitly allowed peers. Traffic to the gateway, where the proxy listens,
+// is delivered to the host and not affected.
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+// isolationChain is the ebtables chain dropping traffic within isolated networks.
+const isolationChain = "I2P_ISOLATE"
+
+// isolatedNetwork is a network whose containers cannot reach each other.
+type isolatedNetwork struct {
+	// subnet is the network subnet
+	subnet *net.IPNet
+	// peers holds the allowed address pairs by pairKey
+	peers map[string][2]net.IP
+}
+
+// isolationRule returns the rule dropping traffic within subnet.
+func isolationRule(subnet *net.IPNet) string {
+	return fmt.Sprintf("-t filter -A %s -p IPv4 --ip-src %s --ip-dst %s -j DROP", isolationChain, subnet, subnet)
+}
+
+// peerRules returns the rules letting a and b reach each other.
+//
+// Allowed traffic returns to the calling chain rather than being accepted, so
+// later rules such as anti-spoofing still apply.
+func peerRules(a, b net.IP) []string {
+	return []string{
+		fmt.Sprintf("-t filter -I %s 1 -p IPv4 --ip-src %s --ip-dst %s -j RETURN", isolationChain, a, b),
+		fmt.Sprintf("-t filter -I %s 1 -p IPv4 --ip-src %s --ip-dst %s -j RETURN", isolationChain, b, a),
+	}
+}
+
+// deletePeerRule returns the rule deleting a peer rule.
+func deletePeerRule(rule string) string {
+	return strings.Replace(rule, fmt.Sprintf("-I %s 1", isolationChain), "-D "+isolationChain, 1)
+}
+
+// pairKey returns the key of an unordered address pair.
+func pairKey(a, b net.IP) string {
+	if bytes.Compare(a.To16(), b.To16()) > 0 {
+		a, b = b, a
+	}
+	return a.String() + "-" + b.String()
+}
+
+// isolationChainRules returns the rules creating the isolation chain.
+//
+// Only bridged frames pass FORWARD, so traffic to the host is not affected.
+// The policy is RETURN for the same reason as the anti-spoofing chain.
+func isolationChainRules() []string {
+	return []string{
+		"-t filter -N " + isolationChain,
+		"-t filter -P " + isolationChain + " RETURN",
+		"-t filter -A FORWARD -j " + isolationChain,
+	}
+}
+
+// IsolateNetwork drops direct traffic between containers of a network.
+//
+// The chain is created with the first isolated network. Isolating a network
+// twice is an error.
+func (pm *ProxyManager) IsolateNetwork(networkID string, subnet *net.IPNet) error {
+	pm.isolationMutex.Lock()
+	defer pm.isolationMutex.Unlock()
+
+	if _, exists := pm.isolatedNetworks[networkID]; exists {
+		return fmt.Errorf("network %s is already isolated", networkID)
+	}
+
+	if len(pm.isolatedNetworks) == 0 {
+		if err := pm.removeLeftoverChain(isolationEbtablesChain); err != nil {
+			return err
+		}
+		for _, rule := range isolationChainRules() {
+			if err := pm.runEbtables(rule); err != nil {
+				pm.removeIsolationChain()
+				return fmt.Errorf("failed to create ebtables chain %s: %w", isolationChain, err)
+			}
+		}
+	}
+
+	if err := pm.runEbtables(isolationRule(subnet)); err != nil {
+		if len(pm.isolatedNetworks) == 0 {
+			pm.removeIsolationChain()
+		}
+		return fmt.Errorf("failed to isolate network %s: %w", networkID, err)
+	}
+
+	pm.isolatedNetworks[networkID] = &isolatedNetwork{subnet: subnet, peers: make(map[string][2]net.IP)}
+	log.Printf("Isolated containers of network %s (%s) from each other", networkID, subnet)
+	return nil
+}
+
+// RemoveNetworkIsolation removes a network's isolation and peer rules.
+//
+// The chain is removed with the last isolated network. Removing isolation
+// from a network that is not isolated is not an error.
+func (pm *ProxyManager) RemoveNetworkIsolation(networkID string) error {
+	pm.isolationMutex.Lock()
+	defer pm.isolationMutex.Unlock()
+
+	errors := pm.removeNetworkIsolationLocked(networkID)
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to remove isolation of network %s: %s", networkID, strings.Join(errors, "; "))
+	}
+	return nil
+}
+
+// AllowPeers lets two containers of an isolated network reach each other.
+//
+// Allowing a pair that is already allowed does nothing. Peers of a network
+// that is not isolated need no rules, so that is not an error either.
+func (pm *ProxyManager) AllowPeers(networkID string, a, b net.IP) error {
+	pm.isolationMutex.Lock()
+	defer pm.isolationMutex.Unlock()
+
+	network, exists := pm.isolatedNetworks[networkID]
+	if !exists {
+		return nil
+	}
+
+	key := pairKey(a, b)
+	if _, exists := network.peers[key]; exists {
+		return nil
+	}
+
+	rules := peerRules(a, b)
+	for i, rule := range rules {
+		if err := pm.runEbtables(rule); err != nil {
+			for _, installed := range rules[:i] {
+				pm.runEbtables(deletePeerRule(installed))
+			}
+			return fmt.Errorf("failed to allow %s and %s to reach each other: %w", a, b, err)
+		}
+	}
+
+	network.peers[key] = [2]net.IP{a, b}
+	log.Printf("Allowed %s and %s on isolated network %s to reach each other", a, b, networkID)
+	return nil
+}
+
+// RevokePeers removes every allowed pair of an isolated network involving ip.
+func (pm *ProxyManager) RevokePeers(networkID string, ip net.IP) error {
+	pm.isolationMutex.Lock()
+	defer pm.isolationMutex.Unlock()
+
+	network, exists := pm.isolatedNetworks[networkID]
+	if !exists {
+		return nil
+	}
+
+	var errors []string
+	for key, pair := range network.peers {
+		if !pair[0].Equal(ip) && !pair[1].Equal(ip) {
+			continue
+		}
+		errors = append(errors, pm.removePeerRules(pair)...)
+		delete(network.peers, key)
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to revoke peers of %s: %s", ip, strings.Join(errors, "; "))
+	}
+	return nil
+}
+
+// removeAllIsolation removes the isolation of every network, returning errors.
+func (pm *ProxyManager) removeAllIsolation() []string {
+	pm.isolationMutex.Lock()
+	defer pm.isolationMutex.Unlock()
+
+	var errors []string
+	for networkID := range pm.isolatedNetworks {
+		errors = append(errors, pm.removeNetworkIsolationLocked(networkID)...)
+	}
+	return errors
+}
+
+// removeNetworkIsolationLocked removes a network's rules, returning errors.
+// The caller must hold pm.isolationMutex.
+func (pm *ProxyManager) removeNetworkIsolationLocked(networkID string) []string {
+	network, exists := pm.isolatedNetworks[networkID]
+	if !exists {
+		return nil
+	}
+	delete(pm.isolatedNetworks, networkID)
+
+	var errors []string
+	for _, pair := range network.peers {
+		errors = append(errors, pm.removePeerRules(pair)...)
+	}
+	if err := pm.runEbtables(strings.Replace(isolationRule(network.subnet), "-A", "-D", 1)); err != nil {
+		errors = append(errors, err.Error())
+	}
+	if len(pm.isolatedNetworks) == 0 {
+		errors = append(errors, pm.removeIsolationChain()...)
+	}
+
+	if len(errors) == 0 {
+		log.Printf("Removed container isolation of network %s", networkID)
+	}
+	return errors
+}
+
+// removePeerRules deletes the rules of an allowed pair, returning errors.
+func (pm *ProxyManager) removePeerRules(pair [2]net.IP) []string {
+	var errors []string
+	for _, rule := range peerRules(pair[0], pair[1]) {
+		if err := pm.runEbtables(deletePeerRule(rule)); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	return errors
+}
+
+// removeIsolationChain unhooks, flushes and deletes the chain, returning errors.
+func (pm *ProxyManager) removeIsolationChain() []string {
+	var errors []string
+	for _, rule := range []string{
+		"-t filter -D FORWARD -j " + isolationChain,
+		"-t filter -F " + isolationChain,
+		"-t filter -X " + isolationChain,
+	} {
+		if err := pm.runEbtables(rule); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	return errors
+}
@@ -0,0 +1,75 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+)
+
+func TestProxyManager_IsolateNetwork(t *testing.T) {
+	t.Parallel()
+
+	pm, _ := newBindTestManager(t)
+	ebtables := recordEbtables(pm)
+	_, subnet, _ := net.ParseCIDR("172.30.0.0/24")
+
+	if err := pm.IsolateNetwork("net1", subnet); err != nil {
+		t.Fatalf("Failed to isolate network: %v", err)
+	}
+	if err := pm.IsolateNetwork("net1", subnet); err == nil {
+		t.Error("Expected error when isolating a network twice")
+	}
+
+	expected := []string{
+		"-t filter -N I2P_ISOLATE",
+		"-t filter -P I2P_ISOLATE RETURN",
+		"-t filter -A FORWARD -j I2P_ISOLATE",
+		"-t filter -A I2P_ISOLATE -p IPv4 --ip-src 172.30.0.0/24 --ip-dst 172.30.0.0/24 -j DROP",
+	}
+	if len(*ebtables) != len(expected) {
+		t.Fatalf("Expected %d rules, got %v", len(expected), *ebtables)
+	}
+	for i, rule := range expected {
+		if (*ebtables)[i] != rule {
+			t.Errorf("Rule %d: expected %q, got %q", i, rule, (*ebtables)[i])
+		}
+	}
+
+	// Peers are allowed in both directions, once
+	a, b := net.ParseIP("172.30.0.2"), net.ParseIP("172.30.0.3")
+	*ebtables = nil
+	if err := pm.AllowPeers("net1", a, b); err != nil {
+		t.Fatalf("Failed to allow peers: %v", err)
+	}
+	if err := pm.AllowPeers("net1", b, a); err != nil {
+		t.Fatalf("Failed to allow peers again: %v", err)
+	}
+	if len(*ebtables) != 2 || !containsRule(*ebtables, "-t filter -I I2P_ISOLATE 1 -p IPv4 --ip-src 172.30.0.3 --ip-dst 172.30.0.2 -j RETURN") {
+		t.Errorf("Expected two peer rules, got %v", *ebtables)
+	}
+
+	// Peers on networks that are not isolated need no rules
+	*ebtables = nil
+	if err := pm.AllowPeers("net2", a, b); err != nil || len(*ebtables) != 0 {
+		t.Errorf("Expected no rules for a network that is not isolated, got %v (%v)", *ebtables, err)
+	}
+
+	*ebtables = nil
+	if err := pm.RevokePeers("net1", b); err != nil {
+		t.Fatalf("Failed to revoke peers: %v", err)
+	}
+	if len(*ebtables) != 2 || !containsRule(*ebtables, "-t filter -D I2P_ISOLATE -p IPv4 --ip-src 172.30.0.2 --ip-dst 172.30.0.3 -j RETURN") {
+		t.Errorf("Expected the peer rules to be deleted, got %v", *ebtables)
+	}
+
+	// The last isolated network removes the chain
+	*ebtables = nil
+	if err := pm.RemoveNetworkIsolation("net1"); err != nil {
+		t.Fatalf("Failed to remove isolation: %v", err)
+	}
+	if len(*ebtables) != 4 || (*ebtables)[3] != "-t filter -X I2P_ISOLATE" {
+		t.Errorf("Expected the drop rule and chain to be removed, got %v", *ebtables)
+	}
+	if err := pm.RemoveNetworkIsolation("net1"); err != nil {
+		t.Errorf("Removing isolation twice should not fail: %v", err)
+	}
+}
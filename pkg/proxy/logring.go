@@ -0,0 +1,148 @@
+// Package proxy provides the bounded buffer of recent traffic log entries.
+//
+// Every proxied connection and every refusal adds a traffic log entry, so
+// under heavy connection churn the buffer is written constantly. Entries are
+// kept in a fixed-size ring: adding one overwrites the oldest entry in place
+// instead of shifting the whole buffer, and the ring has its own lock so
+// readers taking snapshots never hold up the traffic counters. How full the
+// ring is and how many entries it has dropped are exported as metrics.
+package proxy
+
+import "sync"
+
+// LogBufferStats describes the traffic log buffer.
+type LogBufferStats struct {
+	// Capacity is the maximum number of entries kept
+	Capacity int `json:"capacity"`
+	// Entries is the number of entries currently kept
+	Entries int `json:"entries"`
+	// Written counts entries added since the filter was created
+	Written uint64 `json:"written"`
+	// Evicted counts entries overwritten by newer ones
+	Evicted uint64 `json:"evicted"`
+}
+
+// logRing is a fixed-capacity ring of traffic log entries, oldest first.
+type logRing struct {
+	mutex   sync.RWMutex
+	entries []TrafficLogEntry
+	// start is the index of the oldest entry
+	start int
+	// count is the number of entries kept
+	count   int
+	written uint64
+	evicted uint64
+}
+
+// newLogRing creates a ring keeping up to capacity entries. A capacity of
+// zero or less keeps none.
+func newLogRing(capacity int) *logRing {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &logRing{entries: make([]TrafficLogEntry, capacity)}
+}
+
+// add appends entry, overwriting the oldest entry when the ring is full.
+func (r *logRing) add(entry TrafficLogEntry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.written++
+	capacity := len(r.entries)
+	if capacity == 0 {
+		r.evicted++
+		return
+	}
+
+	if r.count < capacity {
+		r.entries[(r.start+r.count)%capacity] = entry
+		r.count++
+		return
+	}
+	r.entries[r.start] = entry
+	r.start = (r.start + 1) % capacity
+	r.evicted++
+}
+
+// recent returns up to limit of the newest entries, oldest first. A limit of
+// zero or less returns all entries.
+func (r *logRing) recent(limit int) []TrafficLogEntry {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if limit <= 0 || limit > r.count {
+		limit = r.count
+	}
+	return r.copyLocked(r.count-limit, limit)
+}
+
+// copyLocked copies n entries starting at the skip-th oldest entry. The
+// caller must hold the mutex.
+func (r *logRing) copyLocked(skip, n int) []TrafficLogEntry {
+	result := make([]TrafficLogEntry, n)
+	if n == 0 {
+		return result
+	}
+
+	capacity := len(r.entries)
+	first := (r.start + skip) % capacity
+	copied := copy(result, r.entries[first:min(first+n, capacity)])
+	copy(result[copied:], r.entries[:n-copied])
+	return result
+}
+
+// reset drops all entries. The written and evicted counters are kept.
+func (r *logRing) reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	clear(r.entries)
+	r.start = 0
+	r.count = 0
+}
+
+// resize changes the capacity, keeping the newest entries that fit.
+func (r *logRing) resize(capacity int) {
+	if capacity < 0 {
+		capacity = 0
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if capacity == len(r.entries) {
+		return
+	}
+	keep := min(r.count, capacity)
+	entries := make([]TrafficLogEntry, capacity)
+	copy(entries, r.copyLocked(r.count-keep, keep))
+	r.evicted += uint64(r.count - keep)
+	r.entries = entries
+	r.start = 0
+	r.count = keep
+}
+
+// stats returns the ring's utilization.
+func (r *logRing) stats() LogBufferStats {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return LogBufferStats{
+		Capacity: len(r.entries),
+		Entries:  r.count,
+		Written:  r.written,
+		Evicted:  r.evicted,
+	}
+}
+
+// GetLogBufferStats returns the utilization of the traffic log buffer.
+func (tf *TrafficFilter) GetLogBufferStats() LogBufferStats {
+	return tf.logs.stats()
+}
+
+// GetLogBufferStats returns the utilization of the traffic log buffer (see
+// TrafficFilter.GetLogBufferStats).
+func (pm *ProxyManager) GetLogBufferStats() LogBufferStats {
+	return pm.trafficFilter.GetLogBufferStats()
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// ringReasons returns the reasons of entries, which tests use as sequence numbers.
+func ringReasons(entries []TrafficLogEntry) string {
+	reasons := ""
+	for _, entry := range entries {
+		reasons += entry.Reason
+	}
+	return reasons
+}
+
+func TestLogRingWrapsAround(t *testing.T) {
+	ring := newLogRing(3)
+	for i := 0; i < 5; i++ {
+		ring.add(TrafficLogEntry{Reason: fmt.Sprint(i)})
+	}
+
+	if got := ringReasons(ring.recent(0)); got != "234" {
+		t.Errorf("Expected the newest entries oldest first, got %q", got)
+	}
+	if got := ringReasons(ring.recent(2)); got != "34" {
+		t.Errorf("Expected the two newest entries, got %q", got)
+	}
+	if stats := ring.stats(); stats != (LogBufferStats{Capacity: 3, Entries: 3, Written: 5, Evicted: 2}) {
+		t.Errorf("Unexpected buffer stats %+v", stats)
+	}
+
+	ring.reset()
+	ring.add(TrafficLogEntry{Reason: "x"})
+	if got := ringReasons(ring.recent(0)); got != "x" {
+		t.Errorf("Expected only the entry added after the reset, got %q", got)
+	}
+}
+
+func TestLogRingResize(t *testing.T) {
+	ring := newLogRing(4)
+	for i := 0; i < 6; i++ {
+		ring.add(TrafficLogEntry{Reason: fmt.Sprint(i)})
+	}
+
+	ring.resize(2)
+	if got := ringReasons(ring.recent(0)); got != "45" {
+		t.Errorf("Expected shrinking to keep the newest entries, got %q", got)
+	}
+
+	ring.resize(5)
+	ring.add(TrafficLogEntry{Reason: "6"})
+	if got := ringReasons(ring.recent(0)); got != "456" {
+		t.Errorf("Expected growing to keep all entries, got %q", got)
+	}
+	if stats := ring.stats(); stats.Capacity != 5 || stats.Evicted != 4 {
+		t.Errorf("Unexpected buffer stats %+v", stats)
+	}
+
+	ring.resize(0)
+	ring.add(TrafficLogEntry{Reason: "7"})
+	if entries := ring.recent(0); len(entries) != 0 {
+		t.Errorf("Expected a zero-capacity ring to keep nothing, got %d entries", len(entries))
+	}
+}
+
+func TestLogRingConcurrentReaders(t *testing.T) {
+	ring := newLogRing(16)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				ring.add(TrafficLogEntry{Reason: "e"})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				if entries := ring.recent(8); len(entries) > 8 {
+					t.Errorf("Expected at most 8 entries, got %d", len(entries))
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := ring.stats(); stats.Written != 2000 || stats.Entries != 16 || stats.Evicted != 1984 {
+		t.Errorf("Unexpected buffer stats %+v", stats)
+	}
+}
+
+func TestTrafficFilterLogBufferStats(t *testing.T) {
+	config := DefaultFilterConfig()
+	config.MaxLogEntries = 2
+	filter := NewTrafficFilter(config)
+
+	for i := 0; i < 3; i++ {
+		filter.ShouldAllowConnection("example.i2p:80", "tcp")
+	}
+	if stats := filter.GetLogBufferStats(); stats.Entries != 2 || stats.Written != 3 || stats.Evicted != 1 {
+		t.Errorf("Unexpected buffer stats %+v", stats)
+	}
+
+	// A new configuration resizes the buffer
+	config = DefaultFilterConfig()
+	config.MaxLogEntries = 10
+	filter.UpdateConfig(config)
+	if stats := filter.GetLogBufferStats(); stats.Capacity != 10 || stats.Entries != 2 {
+		t.Errorf("Expected the buffer to grow and keep its entries, got %+v", stats)
+	}
+}
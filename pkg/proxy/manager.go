@@ -3,6 +3,7 @@ package proxy
 import (
 	"context"
 	"fmt"
+	"log"
 	"net"
 	"sync"
 
@@ -14,7 +15,7 @@ import (
 // The ProxyManager integrates traffic interception, SOCKS proxying, and DNS
 // resolution to provide transparent I2P connectivity for Docker containers.
 type ProxyManager struct {
-	// interceptor manages iptables rules for traffic interception
+	// interceptor manages the firewall rules for traffic interception
 	interceptor *TrafficInterceptor
 	// socksProxy handles SOCKS5 connections and I2P routing
 	socksProxy *SOCKSProxy
@@ -32,6 +33,59 @@ type ProxyManager struct {
 	cancel context.CancelFunc
 	// wg tracks running services
 	wg sync.WaitGroup
+	// sidecars holds per-endpoint Unix socket listeners by ID
+	sidecars map[string]*SidecarSockets
+	// sidecarMutex protects sidecars
+	sidecarMutex sync.Mutex
+	// listeners holds per-network proxy listeners by bind address
+	listeners map[string]*networkListener
+	// networkBinds maps network IDs to the bind address of their listener
+	networkBinds map[string]string
+	// sourceResolver verifies the client addresses of proxy connections
+	sourceResolver SourceResolver
+	// namingResolver looks up I2P hostnames for new listeners
+	namingResolver NamingResolver
+	// targetRewriter maps aliased SOCKS targets for new listeners
+	targetRewriter TargetRewriter
+	// socksStopped is set while the SOCKS proxy is stopped with StopSOCKS
+	socksStopped bool
+	// dnsStopped is set while the DNS resolver is stopped with StopDNS
+	dnsStopped bool
+	// listenerMutex protects listeners, networkBinds, sourceResolver,
+	// namingResolver, targetRewriter, socksStopped and dnsStopped
+	listenerMutex sync.Mutex
+	// runIptables executes an iptables rule (replaceable in tests)
+	runIptables func(rule string) error
+	// runEbtables executes an ebtables rule (replaceable in tests)
+	runEbtables func(rule string) error
+	// runIp6tables executes an ip6tables rule (replaceable in tests)
+	runIp6tables func(rule string) error
+	// listRules lists the rules of a chain (replaceable in tests)
+	listRules func(command, table, chain string) ([]string, error)
+	// endpointBindings holds the anti-spoofing bindings by endpoint ID
+	endpointBindings map[string]endpointBinding
+	// bindingMutex protects endpointBindings
+	bindingMutex sync.Mutex
+	// isolatedNetworks holds the networks without east-west traffic by ID
+	isolatedNetworks map[string]*isolatedNetwork
+	// isolationMutex protects isolatedNetworks
+	isolationMutex sync.Mutex
+	// outboundBlocks holds the addresses of inbound-only endpoints by endpoint ID
+	outboundBlocks map[string]net.IP
+	// outboundMutex protects outboundBlocks
+	outboundMutex sync.Mutex
+	// egressBlocks holds the internal networks whose egress is blocked by ID
+	egressBlocks map[string]*blockedNetwork
+	// egressMutex protects egressBlocks
+	egressMutex sync.Mutex
+	// addresses is the synthetic address table shared by all resolvers and proxies
+	addresses *SyntheticAddresses
+	// external is the external service table shared by all resolvers and proxies
+	external *ExternalServices
+	// names is the container name table shared by all resolvers
+	names *ContainerNames
+	// dnsShedder enforces the DNS query limits of all resolvers
+	dnsShedder *dnsLoadShedder
 }
 
 // ProxyConfig holds configuration for the proxy manager.
@@ -42,48 +96,122 @@ type ProxyConfig struct {
 	SOCKSPort int
 	// DNSPort is the port for the DNS resolver
 	DNSPort int
-	// SOCKSBindAddr is the address to bind the SOCKS proxy to
+	// SOCKSBindAddr is an optional fixed address for an extra SOCKS listener.
+	// Loopback addresses are ignored since containers cannot reach them;
+	// networks get their own listeners (see AddNetworkListeners)
 	SOCKSBindAddr string
-	// DNSBindAddr is the address to bind the DNS resolver to
+	// DNSBindAddr is an optional fixed address for an extra DNS listener,
+	// with the same loopback handling as SOCKSBindAddr
 	DNSBindAddr string
+	// AddressMode selects the synthetic addresses I2P names resolve to:
+	// AddressModeIPv4 (default) or AddressModeIPv6
+	AddressMode string
+	// StaleChains selects how interception chains left behind by a previous
+	// instance are handled: StaleChainsReconcile (default) or StaleChainsFlush
+	StaleChains string
+	// Firewall is the backend interception is installed with, set with
+	// SetFirewallBackend: FirewallIptables (default), FirewallNftables or
+	// FirewallNoop
+	Firewall string
 }
 
 // DefaultProxyConfig returns a default proxy configuration.
+//
+// No fixed listeners are configured; each network binds the proxy to its
+// own gateway address when it is created.
 func DefaultProxyConfig(subnet *net.IPNet) *ProxyConfig {
 	return &ProxyConfig{
 		ContainerSubnet: subnet,
 		SOCKSPort:       1080,
 		DNSPort:         53,
-		SOCKSBindAddr:   "127.0.0.1:1080",
-		DNSBindAddr:     "127.0.0.1:53",
 	}
 }
 
-// NewProxyManager creates a new proxy manager with the given configuration.
+// ProxyOptions configures a ProxyManager.
+//
+// The zero value gives a manager with DefaultProxyConfig that serves the
+// listeners added with AddNetworkListeners. Start intercepts container
+// traffic and needs a Config with a ContainerSubnet.
+type ProxyOptions struct {
+	// Config is the proxy configuration (nil uses DefaultProxyConfig(nil))
+	Config *ProxyConfig
+	// TunnelManager provides the I2P tunnels proxied connections are made through
+	TunnelManager *i2p.TunnelManager
+}
+
+// NewProxyManager creates a new proxy manager with the given options.
 //
-// The proxy manager will use the provided tunnel manager for I2P connectivity
+// The proxy manager will use the options' tunnel manager for I2P connectivity
 // and configure all proxy components according to the configuration.
-func NewProxyManager(config *ProxyConfig, tunnelManager *i2p.TunnelManager) *ProxyManager {
+func NewProxyManager(options ProxyOptions) *ProxyManager {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	config := options.Config
+	if config == nil {
+		config = DefaultProxyConfig(nil)
+	}
+	tunnelManager := options.TunnelManager
+
+	config.migrateBindAddrs()
+
 	// Create shared traffic filter for all components
 	trafficFilter := NewTrafficFilter(DefaultFilterConfig())
 
+	addresses, err := NewSyntheticAddresses(config.AddressMode)
+	if err != nil {
+		log.Printf("Warning: %v, using %s", err, AddressModeIPv4)
+		addresses, _ = NewSyntheticAddresses(AddressModeIPv4)
+	}
+
+	external := NewExternalServices()
+	names := NewContainerNames()
+
 	interceptor := NewTrafficInterceptor(config.ContainerSubnet, config.SOCKSPort, config.DNSPort)
-	socksProxy := NewSOCKSProxy(config.SOCKSBindAddr, tunnelManager)
-	socksProxy.SetTrafficFilter(trafficFilter)
+	interceptor.syntheticIPv6 = addresses.Mode() == AddressModeIPv6
+	socksProxy := NewSOCKSProxy(SOCKSOptions{
+		ListenAddr:    config.SOCKSBindAddr,
+		TunnelManager: tunnelManager,
+		TrafficFilter: trafficFilter,
+		Addresses:     addresses,
+		External:      external,
+	})
+	dnsShedder := newDNSLoadShedder(DefaultDNSQueryLimits())
 	dnsResolver := NewI2PDNSResolver(config.DNSBindAddr)
-
-	return &ProxyManager{
-		interceptor:   interceptor,
-		socksProxy:    socksProxy,
-		dnsResolver:   dnsResolver,
-		trafficFilter: trafficFilter,
-		tunnelManager: tunnelManager,
-		config:        config,
-		ctx:           ctx,
-		cancel:        cancel,
+	dnsResolver.SetSyntheticAddresses(addresses)
+	dnsResolver.shedder = dnsShedder
+	dnsResolver.external = external
+	dnsResolver.names = names
+
+	pm := &ProxyManager{
+		interceptor:      interceptor,
+		socksProxy:       socksProxy,
+		dnsResolver:      dnsResolver,
+		trafficFilter:    trafficFilter,
+		tunnelManager:    tunnelManager,
+		config:           config,
+		ctx:              ctx,
+		cancel:           cancel,
+		sidecars:         make(map[string]*SidecarSockets),
+		listeners:        make(map[string]*networkListener),
+		networkBinds:     make(map[string]string),
+		endpointBindings: make(map[string]endpointBinding),
+		isolatedNetworks: make(map[string]*isolatedNetwork),
+		outboundBlocks:   make(map[string]net.IP),
+		egressBlocks:     make(map[string]*blockedNetwork),
+		addresses:        addresses,
+		dnsShedder:       dnsShedder,
+		external:         external,
+		names:            names,
 	}
+	// The plugin's own chains are applied with whichever firewall backend
+	// is selected when they are created
+	pm.runIptables = func(rule string) error { return pm.interceptor.firewall.RunRule(FamilyIPv4, rule) }
+	pm.runIp6tables = func(rule string) error { return pm.interceptor.firewall.RunRule(FamilyIPv6, rule) }
+	pm.runEbtables = func(rule string) error { return pm.interceptor.firewall.RunRule(FamilyBridge, rule) }
+	pm.listRules = func(command, table, chain string) ([]string, error) {
+		return pm.interceptor.firewall.ListRules(RuleFamily(command), table, chain)
+	}
+	return pm
 }
 
 // Start begins all proxy services and sets up traffic interception.
@@ -91,36 +219,73 @@ func NewProxyManager(config *ProxyConfig, tunnelManager *i2p.TunnelManager) *Pro
 // This method starts the SOCKS proxy, DNS resolver, and configures iptables
 // rules for transparent traffic interception.
 func (pm *ProxyManager) Start() error {
-	// Check if iptables is available
+	if pm.config.ContainerSubnet == nil {
+		return fmt.Errorf("no container subnet configured for traffic interception")
+	}
+
+	// Check if the firewall backend is available
 	if err := pm.interceptor.IsAvailable(); err != nil {
-		return fmt.Errorf("iptables not available: %w", err)
+		return fmt.Errorf("%s not available: %w", pm.interceptor.firewall.Name(), err)
+	}
+
+	pm.listenerMutex.Lock()
+	// Start fixed SOCKS listener, if configured
+	if pm.config.SOCKSBindAddr != "" && !pm.socksStopped {
+		pm.startFixedSOCKS()
 	}
 
-	// Start SOCKS proxy
+	// Start fixed DNS listener, if configured
+	if pm.config.DNSBindAddr != "" && !pm.dnsStopped {
+		pm.startFixedDNS()
+	}
+	pm.listenerMutex.Unlock()
+
+	// Replace or remove interception chains left behind by a previous
+	// instance; the nftables backend replaces its tables atomically instead
+	var retired []firewallChain
+	if pm.interceptor.firewall.Name() == FirewallIptables {
+		var err error
+		if retired, err = pm.prepareInterceptionChains(); err != nil {
+			pm.Stop()
+			return fmt.Errorf("failed to set up traffic interception: %w", err)
+		}
+	}
+
+	// Set up traffic interception
+	if err := pm.interceptor.SetupInterception(); err != nil {
+		pm.Stop()
+		pm.restoreRetiredChains(retired)
+		return fmt.Errorf("failed to set up traffic interception: %w", err)
+	}
+	pm.removeRetiredChains(retired)
+
+	return nil
+}
+
+// startFixedSOCKS serves the fixed SOCKS listener in the background.
+// The caller must hold pm.listenerMutex.
+func (pm *ProxyManager) startFixedSOCKS() {
+	socksProxy := pm.socksProxy
 	pm.wg.Add(1)
 	go func() {
 		defer pm.wg.Done()
-		if err := pm.socksProxy.Start(); err != nil && err != context.Canceled {
+		if err := socksProxy.Start(); err != nil && err != context.Canceled {
 			// Log error but don't fail startup
 		}
 	}()
+}
 
-	// Start DNS resolver
+// startFixedDNS serves the fixed DNS listener in the background.
+// The caller must hold pm.listenerMutex.
+func (pm *ProxyManager) startFixedDNS() {
+	dnsResolver := pm.dnsResolver
 	pm.wg.Add(1)
 	go func() {
 		defer pm.wg.Done()
-		if err := pm.dnsResolver.Start(); err != nil && err != context.Canceled {
+		if err := dnsResolver.Start(); err != nil && err != context.Canceled {
 			// Log error but don't fail startup
 		}
 	}()
-
-	// Set up traffic interception
-	if err := pm.interceptor.SetupInterception(); err != nil {
-		pm.Stop()
-		return fmt.Errorf("failed to set up traffic interception: %w", err)
-	}
-
-	return nil
 }
 
 // Stop gracefully shuts down all proxy services and cleans up iptables rules.
@@ -132,11 +297,20 @@ func (pm *ProxyManager) Stop() error {
 
 	var errors []string
 
+	// Stop per-endpoint sidecar sockets and per-network listeners
+	errors = append(errors, pm.stopAllSidecarSockets()...)
+	errors = append(errors, pm.stopAllNetworkListeners()...)
+	errors = append(errors, pm.unbindAllEndpoints()...)
+	errors = append(errors, pm.removeAllIsolation()...)
+	errors = append(errors, pm.unblockAllEndpoints()...)
+	errors = append(errors, pm.removeAllEgressBlocks()...)
+
 	// Clean up traffic interception
 	if err := pm.interceptor.CleanupInterception(); err != nil {
-		errors = append(errors, fmt.Sprintf("iptables cleanup failed: %v", err))
+		errors = append(errors, fmt.Sprintf("%s cleanup failed: %v", pm.interceptor.firewall.Name(), err))
 	}
 
+	pm.listenerMutex.Lock()
 	// Stop SOCKS proxy
 	if err := pm.socksProxy.Stop(); err != nil {
 		errors = append(errors, fmt.Sprintf("SOCKS proxy stop failed: %v", err))
@@ -146,6 +320,7 @@ func (pm *ProxyManager) Stop() error {
 	if err := pm.dnsResolver.Stop(); err != nil {
 		errors = append(errors, fmt.Sprintf("DNS resolver stop failed: %v", err))
 	}
+	pm.listenerMutex.Unlock()
 
 	// Wait for all services to stop
 	pm.wg.Wait()
@@ -176,6 +351,13 @@ func (pm *ProxyManager) CheckIptablesAvailability() error {
 	return pm.interceptor.IsAvailable()
 }
 
+// SetContainerSubnet sets the subnet whose container traffic is intercepted.
+// It must be called before Start.
+func (pm *ProxyManager) SetContainerSubnet(subnet *net.IPNet) {
+	pm.config.ContainerSubnet = subnet
+	pm.interceptor.containerSubnet = subnet
+}
+
 // GetConfig returns the current proxy configuration.
 func (pm *ProxyManager) GetConfig() *ProxyConfig {
 	return pm.config
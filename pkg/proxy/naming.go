@@ -0,0 +1,318 @@
+// Package proxy provides pluggable I2P name resolution.
+//
+// The DNS resolver and SOCKS proxy look up I2P hostnames through a
+// NamingResolver. Backends are the SAM bridge's NAMING LOOKUP, a local
+// hosts.txt file and a registrar HTTP API; they are tried in the configured
+// order and the first one that knows a name wins. Base32 addresses name their
+// destination directly and are never looked up. Without a resolver, names are
+// handed to the router unresolved.
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+// Naming backends selected with NamingConfig.Backends.
+const (
+	// NamingSAM looks names up through the SAM bridge (the router's address book)
+	NamingSAM = "sam"
+	// NamingHosts looks names up in a local hosts.txt file
+	NamingHosts = "hosts"
+	// NamingRegistrar looks names up through a registrar HTTP API
+	NamingRegistrar = "registrar"
+)
+
+// registrarTimeout bounds every registrar API request.
+const registrarTimeout = 10 * time.Second
+
+// ErrNameNotFound is returned by naming resolvers for names they do not know.
+var ErrNameNotFound = errors.New("I2P name not found")
+
+// NamingResolver resolves I2P hostnames to destinations.
+type NamingResolver interface {
+	// Name identifies the backend in logs and errors
+	Name() string
+	// Lookup returns the base64 destination or b32 address of an I2P
+	// hostname, or an error wrapping ErrNameNotFound if the name is unknown
+	Lookup(name string) (string, error)
+}
+
+// NamingConfig selects and orders the naming backends.
+type NamingConfig struct {
+	// Backends are the backends to try, in order (empty disables lookups)
+	Backends []string
+	// HostsFile is the hosts.txt file used by the hosts backend
+	HostsFile string
+	// RegistrarURL is the lookup endpoint used by the registrar backend
+	RegistrarURL string
+}
+
+// NewNamingResolver creates the resolver chain described by config.
+//
+// It returns nil when no backends are configured. The SAM backend performs
+// lookups through tunnelManager.
+func NewNamingResolver(config NamingConfig, tunnelManager *i2p.TunnelManager) (NamingResolver, error) {
+	if len(config.Backends) == 0 {
+		return nil, nil
+	}
+
+	chain := make(NamingChain, 0, len(config.Backends))
+	for _, backend := range config.Backends {
+		switch strings.ToLower(strings.TrimSpace(backend)) {
+		case NamingSAM:
+			if tunnelManager == nil {
+				return nil, fmt.Errorf("sam naming backend requires a tunnel manager")
+			}
+			chain = append(chain, &SAMNamingResolver{tunnelManager: tunnelManager})
+		case NamingHosts:
+			if config.HostsFile == "" {
+				return nil, fmt.Errorf("hosts naming backend requires a hosts file")
+			}
+			chain = append(chain, NewHostsFileResolver(config.HostsFile))
+		case NamingRegistrar:
+			resolver, err := NewRegistrarResolver(config.RegistrarURL)
+			if err != nil {
+				return nil, err
+			}
+			chain = append(chain, resolver)
+		default:
+			return nil, fmt.Errorf("unknown naming backend %q (expected %s, %s or %s)", backend, NamingSAM, NamingHosts, NamingRegistrar)
+		}
+	}
+	return chain, nil
+}
+
+// NamingChain tries naming resolvers in order.
+type NamingChain []NamingResolver
+
+// Name returns the backend names joined in lookup order.
+func (c NamingChain) Name() string {
+	names := make([]string, len(c))
+	for i, resolver := range c {
+		names[i] = resolver.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Lookup returns the answer of the first resolver that knows name.
+//
+// Backend failures do not stop the chain; they are reported only if no
+// later backend knows the name either.
+func (c NamingChain) Lookup(name string) (string, error) {
+	var failures []string
+	for _, resolver := range c {
+		destination, err := resolver.Lookup(name)
+		if err == nil {
+			return destination, nil
+		}
+		if !errors.Is(err, ErrNameNotFound) {
+			failures = append(failures, fmt.Sprintf("%s: %v", resolver.Name(), err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return "", fmt.Errorf("failed to look up %s: %s", name, strings.Join(failures, "; "))
+	}
+	return "", fmt.Errorf("%w: %s", ErrNameNotFound, name)
+}
+
+// lookupDestination resolves a host through resolver.
+//
+// Base32 addresses are returned unchanged, as is every host when resolver
+// is nil.
+func lookupDestination(resolver NamingResolver, host string) (string, error) {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if resolver == nil || strings.HasSuffix(host, ".b32.i2p") {
+		return host, nil
+	}
+	return resolver.Lookup(host)
+}
+
+// SAMNamingResolver looks names up through the SAM bridge.
+type SAMNamingResolver struct {
+	tunnelManager *i2p.TunnelManager
+}
+
+// Name returns the backend name.
+func (r *SAMNamingResolver) Name() string {
+	return NamingSAM
+}
+
+// Lookup resolves name with SAM NAMING LOOKUP.
+func (r *SAMNamingResolver) Lookup(name string) (string, error) {
+	destination, err := r.tunnelManager.LookupName(name)
+	if errors.Is(err, i2p.ErrNameNotFound) {
+		return "", fmt.Errorf("%w: %s", ErrNameNotFound, name)
+	}
+	return destination, err
+}
+
+// HostsFileResolver looks names up in a hosts.txt file.
+//
+// The file uses the router address book format, one name=destination pair
+// per line, with # comments. It is re-read whenever it changes.
+type HostsFileResolver struct {
+	path string
+
+	mutex   sync.Mutex
+	modTime time.Time
+	hosts   map[string]string
+}
+
+// NewHostsFileResolver creates a resolver reading the hosts file at path.
+func NewHostsFileResolver(path string) *HostsFileResolver {
+	return &HostsFileResolver{path: path}
+}
+
+// Name returns the backend name.
+func (r *HostsFileResolver) Name() string {
+	return NamingHosts
+}
+
+// Lookup returns the destination listed for name.
+func (r *HostsFileResolver) Lookup(name string) (string, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err := r.reloadLocked(); err != nil {
+		return "", err
+	}
+
+	destination, ok := r.hosts[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNameNotFound, name)
+	}
+	return destination, nil
+}
+
+// reloadLocked re-reads the hosts file if it changed since the last read.
+// The caller must hold r.mutex.
+func (r *HostsFileResolver) reloadLocked() error {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to read hosts file: %w", err)
+	}
+	if r.hosts != nil && info.ModTime().Equal(r.modTime) {
+		return nil
+	}
+
+	file, err := os.Open(r.path)
+	if err != nil {
+		return fmt.Errorf("failed to read hosts file: %w", err)
+	}
+	defer file.Close()
+
+	hosts := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, destination, ok := strings.Cut(line, "=")
+		if !ok || name == "" || destination == "" {
+			continue
+		}
+		// Address book entries may carry #!key=value metadata after the destination
+		destination, _, _ = strings.Cut(destination, "#!")
+		hosts[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(destination)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read hosts file: %w", err)
+	}
+
+	r.hosts = hosts
+	r.modTime = info.ModTime()
+	log.Printf("Loaded %d I2P names from %s", len(hosts), r.path)
+	return nil
+}
+
+// RegistrarResolver looks names up through a registrar HTTP API.
+//
+// Lookups are GET requests to the configured URL with the name in the name
+// query parameter. The registrar answers 200 with {"destination": "..."} for
+// registered names and 404 for unknown ones.
+type RegistrarResolver struct {
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewRegistrarResolver creates a resolver for the registrar lookup endpoint.
+func NewRegistrarResolver(endpoint string) (*RegistrarResolver, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("registrar naming backend requires an http(s) URL, got %q", endpoint)
+	}
+	return &RegistrarResolver{
+		endpoint: parsed,
+		client:   &http.Client{Timeout: registrarTimeout},
+	}, nil
+}
+
+// Name returns the backend name.
+func (r *RegistrarResolver) Name() string {
+	return NamingRegistrar
+}
+
+// Lookup asks the registrar for name's destination.
+func (r *RegistrarResolver) Lookup(name string) (string, error) {
+	target := *r.endpoint
+	query := target.Query()
+	query.Set("name", name)
+	target.RawQuery = query.Encode()
+
+	resp, err := r.client.Get(target.String())
+	if err != nil {
+		return "", fmt.Errorf("registrar request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", fmt.Errorf("%w: %s", ErrNameNotFound, name)
+	default:
+		return "", fmt.Errorf("registrar returned %s", resp.Status)
+	}
+
+	var result struct {
+		Destination string `json:"destination"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode registrar response: %w", err)
+	}
+	if result.Destination == "" {
+		return "", fmt.Errorf("%w: %s", ErrNameNotFound, name)
+	}
+	return result.Destination, nil
+}
+
+// SetNamingResolver sets the resolver used for I2P hostnames on all listeners.
+//
+// Listeners started later use the same resolver. A nil resolver hands names
+// to the router unresolved.
+func (pm *ProxyManager) SetNamingResolver(resolver NamingResolver) {
+	pm.listenerMutex.Lock()
+	defer pm.listenerMutex.Unlock()
+
+	pm.namingResolver = resolver
+	pm.socksProxy.SetNamingResolver(resolver)
+	pm.dnsResolver.SetNamingResolver(resolver)
+	for _, listener := range pm.listeners {
+		listener.socksProxy.SetNamingResolver(resolver)
+		listener.dnsResolver.SetNamingResolver(resolver)
+	}
+}
@@ -0,0 +1,208 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// staticNaming is a naming resolver backed by a map.
+type staticNaming struct {
+	name  string
+	hosts map[string]string
+	err   error
+}
+
+func (s *staticNaming) Name() string { return s.name }
+
+func (s *staticNaming) Lookup(name string) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	if destination, ok := s.hosts[name]; ok {
+		return destination, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrNameNotFound, name)
+}
+
+func TestHostsFileResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.txt")
+	content := "# address book\nexample.i2p=AAAA\n\nForum.i2p=BBBB#!sig=xyz\nbroken line\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write hosts file: %v", err)
+	}
+
+	resolver := NewHostsFileResolver(path)
+
+	if dest, err := resolver.Lookup("example.i2p"); err != nil || dest != "AAAA" {
+		t.Errorf("Expected AAAA, got %q (%v)", dest, err)
+	}
+	if dest, err := resolver.Lookup("forum.i2p"); err != nil || dest != "BBBB" {
+		t.Errorf("Expected BBBB without metadata, got %q (%v)", dest, err)
+	}
+	if _, err := resolver.Lookup("missing.i2p"); !errors.Is(err, ErrNameNotFound) {
+		t.Errorf("Expected ErrNameNotFound, got %v", err)
+	}
+
+	// Changes to the file are picked up on the next lookup
+	if err := os.WriteFile(path, []byte("missing.i2p=CCCC\n"), 0644); err != nil {
+		t.Fatalf("Failed to rewrite hosts file: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Failed to touch hosts file: %v", err)
+	}
+	if dest, err := resolver.Lookup("missing.i2p"); err != nil || dest != "CCCC" {
+		t.Errorf("Expected reloaded entry CCCC, got %q (%v)", dest, err)
+	}
+
+	if _, err := NewHostsFileResolver(filepath.Join(t.TempDir(), "absent")).Lookup("example.i2p"); err == nil || errors.Is(err, ErrNameNotFound) {
+		t.Errorf("Expected read error for a missing hosts file, got %v", err)
+	}
+}
+
+func TestRegistrarResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("name") {
+		case "example.i2p":
+			json.NewEncoder(w).Encode(map[string]string{"destination": "AAAA"})
+		case "broken.i2p":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	resolver, err := NewRegistrarResolver(server.URL + "/lookup")
+	if err != nil {
+		t.Fatalf("Failed to create registrar resolver: %v", err)
+	}
+
+	if dest, err := resolver.Lookup("example.i2p"); err != nil || dest != "AAAA" {
+		t.Errorf("Expected AAAA, got %q (%v)", dest, err)
+	}
+	if _, err := resolver.Lookup("missing.i2p"); !errors.Is(err, ErrNameNotFound) {
+		t.Errorf("Expected ErrNameNotFound, got %v", err)
+	}
+	if _, err := resolver.Lookup("broken.i2p"); err == nil || errors.Is(err, ErrNameNotFound) {
+		t.Errorf("Expected registrar error, got %v", err)
+	}
+
+	if _, err := NewRegistrarResolver("ftp://registrar"); err == nil {
+		t.Error("Expected non-HTTP registrar URL to be rejected")
+	}
+}
+
+func TestNamingChain(t *testing.T) {
+	first := &staticNaming{name: "first", hosts: map[string]string{"a.i2p": "first-a"}}
+	second := &staticNaming{name: "second", hosts: map[string]string{"a.i2p": "second-a", "b.i2p": "second-b"}}
+	failing := &staticNaming{name: "failing", err: errors.New("unreachable")}
+
+	chain := NamingChain{first, failing, second}
+
+	if dest, err := chain.Lookup("a.i2p"); err != nil || dest != "first-a" {
+		t.Errorf("Expected first backend to win, got %q (%v)", dest, err)
+	}
+	if dest, err := chain.Lookup("b.i2p"); err != nil || dest != "second-b" {
+		t.Errorf("Expected lookup to continue past failures, got %q (%v)", dest, err)
+	}
+	if _, err := chain.Lookup("c.i2p"); err == nil || errors.Is(err, ErrNameNotFound) {
+		t.Errorf("Expected backend failure to be reported, got %v", err)
+	}
+	if _, err := (NamingChain{first, second}).Lookup("c.i2p"); !errors.Is(err, ErrNameNotFound) {
+		t.Errorf("Expected ErrNameNotFound, got %v", err)
+	}
+	if name := chain.Name(); name != "first,failing,second" {
+		t.Errorf("Expected chain name first,failing,second, got %s", name)
+	}
+}
+
+func TestNewNamingResolver(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      NamingConfig
+		expectNil   bool
+		expectError bool
+	}{
+		{name: "no backends", config: NamingConfig{}, expectNil: true},
+		{name: "hosts", config: NamingConfig{Backends: []string{"hosts"}, HostsFile: "/etc/i2p/hosts.txt"}},
+		{name: "hosts without file", config: NamingConfig{Backends: []string{"hosts"}}, expectError: true},
+		{name: "registrar", config: NamingConfig{Backends: []string{"registrar"}, RegistrarURL: "https://reg.example/lookup"}},
+		{name: "registrar without URL", config: NamingConfig{Backends: []string{"registrar"}}, expectError: true},
+		{name: "sam without tunnel manager", config: NamingConfig{Backends: []string{"sam"}}, expectError: true},
+		{name: "unknown backend", config: NamingConfig{Backends: []string{"dht"}}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := NewNamingResolver(tt.config, nil)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if (resolver == nil) != tt.expectNil {
+				t.Errorf("Expected nil resolver %v, got %v", tt.expectNil, resolver)
+			}
+		})
+	}
+}
+
+func TestI2PDNSResolver_NamingResolver(t *testing.T) {
+	resolver := NewI2PDNSResolver("127.0.0.1:5353")
+	resolver.SetNamingResolver(&staticNaming{name: "static", hosts: map[string]string{"known.i2p": "AAAA"}})
+
+	query := func(name string) int {
+		req := new(dns.Msg)
+		req.SetQuestion(name, dns.TypeA)
+		return resolver.buildResponse(req).Rcode
+	}
+
+	if rcode := query("known.i2p."); rcode != dns.RcodeSuccess {
+		t.Errorf("Expected known name to resolve, got rcode %d", rcode)
+	}
+	if rcode := query("unknown.i2p."); rcode != dns.RcodeNameError {
+		t.Errorf("Expected NXDOMAIN for unknown name, got rcode %d", rcode)
+	}
+	if rcode := query("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa.b32.i2p."); rcode != dns.RcodeSuccess {
+		t.Errorf("Expected b32 address to bypass lookups, got rcode %d", rcode)
+	}
+	if _, err := resolver.Resolve("unknown.i2p"); !errors.Is(err, ErrNameNotFound) {
+		t.Errorf("Expected Resolve to report unknown name, got %v", err)
+	}
+
+	resolver.SetNamingResolver(nil)
+	if rcode := query("unknown.i2p."); rcode != dns.RcodeSuccess {
+		t.Errorf("Expected every .i2p name to resolve without a naming resolver, got rcode %d", rcode)
+	}
+}
+
+func TestProxyManager_SetNamingResolver(t *testing.T) {
+	t.Parallel()
+
+	pm, _ := newBindTestManager(t)
+	naming := &staticNaming{name: "static"}
+
+	pm.SetNamingResolver(naming)
+	if pm.socksProxy.getNamingResolver() != naming {
+		t.Error("Expected naming resolver to be applied to the fixed SOCKS proxy")
+	}
+
+	pm.SetNamingResolver(nil)
+	if pm.socksProxy.getNamingResolver() != nil {
+		t.Error("Expected naming resolver to be cleared")
+	}
+}
@@ -0,0 +1,121 @@
+// Package proxy provides the nftables firewall backend.
+//
+// Interception is installed as the tables nftablesTable and, in IPv6 address
+// mode, nftablesTable6, applied with one `nft -f -` script. Each script
+// declares and deletes its tables before defining them, so rules left behind
+// by a previous instance are replaced in the same transaction and containers
+// are never left unfiltered.
+package proxy
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Tables created by the nftables backend.
+const (
+	nftablesTable  = "i2p"
+	nftablesTable6 = "i2p6"
+)
+
+// nftablesFirewall installs interception with nft.
+type nftablesFirewall struct {
+	// run applies an nft script (replaceable in tests)
+	run func(script string) error
+	// rulesets holds the plugin's own chains by family (see nftrules.go)
+	rulesets map[RuleFamily]*nftRuleset
+	// mutex protects rulesets
+	mutex sync.Mutex
+}
+
+// newNftablesFirewall returns an nftables backend applying scripts with run.
+func newNftablesFirewall(run func(script string) error) *nftablesFirewall {
+	return &nftablesFirewall{run: run, rulesets: make(map[RuleFamily]*nftRuleset)}
+}
+
+func (f *nftablesFirewall) Name() string { return FirewallNftables }
+
+// Available checks that nft exists and can list the ruleset.
+func (f *nftablesFirewall) Available(InterceptionSpec) error {
+	if _, err := exec.LookPath("nft"); err != nil {
+		return fmt.Errorf("nft command not found: %w", err)
+	}
+	if err := exec.Command("nft", "list", "tables").Run(); err != nil {
+		return fmt.Errorf("cannot execute nft (insufficient privileges?): %w", err)
+	}
+	return nil
+}
+
+// RenderRules returns the lines of the nft script Install applies.
+func (f *nftablesFirewall) RenderRules(spec InterceptionSpec) []string {
+	subnet := spec.Subnet.String()
+
+	lines := []string{
+		"table ip " + nftablesTable,
+		"delete table ip " + nftablesTable,
+		"table ip " + nftablesTable + " {",
+		"  chain redirect {",
+		"    type nat hook prerouting priority dstnat; policy accept;",
+		fmt.Sprintf("    ip saddr %s udp dport 53 redirect to :%d", subnet, spec.DNSPort),
+		fmt.Sprintf("    ip saddr %s tcp dport 53 redirect to :%d", subnet, spec.DNSPort),
+		fmt.Sprintf("    ip saddr %s tcp dport != %d redirect to :%d", subnet, spec.ProxyPort, spec.ProxyPort),
+		"  }",
+		"  chain filter {",
+		"    type filter hook forward priority filter; policy accept;",
+		fmt.Sprintf("    ip saddr %s tcp dport %d accept", subnet, spec.ProxyPort),
+		fmt.Sprintf("    ip saddr %s udp dport %d accept", subnet, spec.DNSPort),
+		fmt.Sprintf("    ip saddr %s tcp dport %d accept", subnet, spec.DNSPort),
+		fmt.Sprintf("    ip saddr %s ip daddr 127.0.0.0/8 accept", subnet),
+		fmt.Sprintf("    ip saddr %s ip daddr %s accept", subnet, subnet),
+		fmt.Sprintf("    ip saddr %s log prefix \"I2P-DROP: \" drop", subnet),
+		"  }",
+		"}",
+	}
+	if spec.SyntheticIPv6 {
+		lines = append(lines,
+			"table ip6 "+nftablesTable6,
+			"delete table ip6 "+nftablesTable6,
+			"table ip6 "+nftablesTable6+" {",
+			"  chain redirect {",
+			"    type nat hook prerouting priority dstnat; policy accept;",
+			fmt.Sprintf("    ip6 daddr %s meta l4proto tcp redirect to :%d", SyntheticIPv6Range, spec.ProxyPort),
+			"  }",
+			"}",
+		)
+	}
+	return lines
+}
+
+// Install applies the rendered script in one transaction, so nothing is
+// left to remove on failure.
+func (f *nftablesFirewall) Install(spec InterceptionSpec) error {
+	if err := f.run(strings.Join(f.RenderRules(spec), "\n") + "\n"); err != nil {
+		return fmt.Errorf("failed to apply nftables rules: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the tables, succeeding if they do not exist.
+func (f *nftablesFirewall) Remove(spec InterceptionSpec) error {
+	script := []string{"table ip " + nftablesTable, "delete table ip " + nftablesTable}
+	if spec.SyntheticIPv6 {
+		script = append(script, "table ip6 "+nftablesTable6, "delete table ip6 "+nftablesTable6)
+	}
+	if err := f.run(strings.Join(script, "\n") + "\n"); err != nil {
+		return fmt.Errorf("failed to remove nftables rules: %w", err)
+	}
+	return nil
+}
+
+// executeNftScript applies a script with `nft -f -`.
+func executeNftScript(script string) error {
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("nft command failed: %s (output: %s)", err, string(output))
+	}
+	return nil
+}
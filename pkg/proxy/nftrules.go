@@ -0,0 +1,426 @@
+// Package proxy provides the plugin's own chains on the nftables backend.
+//
+// Anti-spoofing, isolation, outbound and egress blocking and the proxy input
+// rules add and delete single rules in iptables and ebtables syntax. The
+// nftables backend keeps those chains in memory, translates each rule into
+// an nft statement and, after every change, replaces one table per family
+// (nftablesRulesTable, nftablesRulesTable6 and nftablesBridgeTable) in a
+// single `nft -f -` transaction. Built-in chains such as INPUT and FORWARD
+// become base chains hooked just before the filter priority, so the rules see
+// traffic before those of Docker or iptables-nft, as with `-I INPUT 1`.
+package proxy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tables holding the plugin's own chains on the nftables backend.
+const (
+	nftablesRulesTable  = "i2p_rules"
+	nftablesRulesTable6 = "i2p_rules6"
+	nftablesBridgeTable = "i2p_bridge"
+)
+
+// nftablesHooks maps the built-in chains rules may be added to onto the
+// hooks of their base chains, in the order they are rendered.
+var nftablesHooks = []struct{ chain, hook string }{
+	{"PREROUTING", "prerouting"},
+	{"INPUT", "input"},
+	{"FORWARD", "forward"},
+	{"OUTPUT", "output"},
+}
+
+// nftHook returns the hook of a built-in chain, or "" for other chains.
+func nftHook(chain string) string {
+	for _, builtin := range nftablesHooks {
+		if builtin.chain == chain {
+			return builtin.hook
+		}
+	}
+	return ""
+}
+
+// nftFamily returns the nft address family and table of a rule family.
+func nftFamily(family RuleFamily) (nftFamily, table string) {
+	switch family {
+	case FamilyIPv6:
+		return "ip6", nftablesRulesTable6
+	case FamilyBridge:
+		return "bridge", nftablesBridgeTable
+	default:
+		return "ip", nftablesRulesTable
+	}
+}
+
+// nftRuleset holds the chains of one family applied with RunRule, as rules
+// in the family's command syntax without the -A and chain name.
+type nftRuleset struct {
+	chains map[string][]string
+}
+
+// clone returns a copy of the ruleset, so failed changes can be undone.
+func (r *nftRuleset) clone() *nftRuleset {
+	chains := make(map[string][]string, len(r.chains))
+	for chain, rules := range r.chains {
+		chains[chain] = append([]string(nil), rules...)
+	}
+	return &nftRuleset{chains: chains}
+}
+
+// parsedRule is a rule in iptables or ebtables syntax.
+type parsedRule struct {
+	// op is the command, e.g. "-A" or "-N"
+	op string
+	// chain is the chain the command applies to
+	chain string
+	// position is the 1-based position of -I (0 if not given)
+	position int
+	// args are the remaining arguments, e.g. the match and target of -A or
+	// the new name of -E
+	args []string
+}
+
+// parseRule parses a rule of the plugin's own chains. Only the filter table
+// is supported.
+func parseRule(rule string) (parsedRule, error) {
+	fields := strings.Fields(rule)
+	table := "filter"
+	if len(fields) >= 2 && fields[0] == "-t" {
+		table, fields = fields[1], fields[2:]
+	}
+	if table != "filter" {
+		return parsedRule{}, fmt.Errorf("table %s is not supported by the %s firewall backend", table, FirewallNftables)
+	}
+	if len(fields) < 2 {
+		return parsedRule{}, fmt.Errorf("invalid rule %q", rule)
+	}
+
+	parsed := parsedRule{op: fields[0], chain: fields[1], args: fields[2:]}
+	if parsed.op == "-I" && len(parsed.args) > 0 {
+		if position, err := strconv.Atoi(parsed.args[0]); err == nil {
+			parsed.position, parsed.args = position, parsed.args[1:]
+		}
+	}
+	return parsed, nil
+}
+
+// apply applies a parsed rule to the ruleset, checking that its match and
+// target translate.
+func (r *nftRuleset) apply(family RuleFamily, rule parsedRule) error {
+	rules, exists := r.chains[rule.chain]
+	builtin := nftHook(rule.chain) != ""
+	spec := strings.Join(rule.args, " ")
+
+	switch rule.op {
+	case "-N":
+		if exists || builtin {
+			return fmt.Errorf("chain %s already exists", rule.chain)
+		}
+		r.chains[rule.chain] = nil
+		return nil
+	case "-P":
+		// User-defined nft chains return to their caller by default
+		if builtin || len(rule.args) != 1 || rule.args[0] != "RETURN" {
+			return fmt.Errorf("policy %s of chain %s is not supported by the %s firewall backend", spec, rule.chain, FirewallNftables)
+		}
+		if !exists {
+			return fmt.Errorf("chain %s does not exist", rule.chain)
+		}
+		return nil
+	case "-E":
+		if !exists || len(rule.args) != 1 {
+			return fmt.Errorf("chain %s does not exist", rule.chain)
+		}
+		if _, taken := r.chains[rule.args[0]]; taken {
+			return fmt.Errorf("chain %s already exists", rule.args[0])
+		}
+		r.chains[rule.args[0]] = rules
+		delete(r.chains, rule.chain)
+		return nil
+	}
+
+	if !exists && !builtin {
+		return fmt.Errorf("chain %s does not exist", rule.chain)
+	}
+
+	switch rule.op {
+	case "-X":
+		if builtin {
+			return fmt.Errorf("built-in chain %s cannot be deleted", rule.chain)
+		}
+		if len(rules) > 0 {
+			return fmt.Errorf("chain %s is not empty", rule.chain)
+		}
+		delete(r.chains, rule.chain)
+	case "-F":
+		r.chains[rule.chain] = nil
+	case "-A", "-I":
+		if _, err := nftStatement(family, rule.args); err != nil {
+			return err
+		}
+		if rule.op == "-A" {
+			r.chains[rule.chain] = append(rules, spec)
+			break
+		}
+		position := rule.position - 1
+		if position < 0 {
+			position = 0
+		}
+		if position > len(rules) {
+			return fmt.Errorf("index %d is out of range for chain %s", rule.position, rule.chain)
+		}
+		r.chains[rule.chain] = append(rules[:position], append([]string{spec}, rules[position:]...)...)
+	case "-D":
+		for i, existing := range rules {
+			if existing == spec {
+				r.chains[rule.chain] = append(rules[:i], rules[i+1:]...)
+				return nil
+			}
+		}
+		return fmt.Errorf("rule %q does not exist in chain %s", spec, rule.chain)
+	default:
+		return fmt.Errorf("command %s is not supported by the %s firewall backend", rule.op, FirewallNftables)
+	}
+	return nil
+}
+
+// script returns the nft script replacing the family's table with the
+// ruleset. An empty ruleset only deletes the table.
+func (r *nftRuleset) script(family RuleFamily) string {
+	nftFamily, table := nftFamily(family)
+	name := nftFamily + " " + table
+	lines := []string{"table " + name, "delete table " + name}
+
+	var userChains []string
+	used := false
+	for chain, rules := range r.chains {
+		if nftHook(chain) == "" {
+			userChains = append(userChains, chain)
+			used = true
+		} else if len(rules) > 0 {
+			used = true
+		}
+	}
+	if !used {
+		return strings.Join(lines, "\n") + "\n"
+	}
+	sort.Strings(userChains)
+
+	lines = append(lines, "table "+name+" {")
+	// User-defined chains are declared before the base chains jumping to them
+	for _, chain := range userChains {
+		lines = append(lines, "  chain "+chain+" {")
+		lines = append(lines, r.statements(family, chain)...)
+		lines = append(lines, "  }")
+	}
+	for _, builtin := range nftablesHooks {
+		if len(r.chains[builtin.chain]) == 0 {
+			continue
+		}
+		lines = append(lines,
+			"  chain "+builtin.chain+" {",
+			"    type filter hook "+builtin.hook+" priority filter - 1; policy accept;",
+		)
+		lines = append(lines, r.statements(family, builtin.chain)...)
+		lines = append(lines, "  }")
+	}
+	lines = append(lines, "}")
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// statements returns the nft statements of a chain's rules.
+func (r *nftRuleset) statements(family RuleFamily, chain string) []string {
+	var lines []string
+	for _, rule := range r.chains[chain] {
+		// Rules were checked when they were added
+		statement, _ := nftStatement(family, strings.Fields(rule))
+		lines = append(lines, "    "+statement)
+	}
+	return lines
+}
+
+// list returns the rules of a chain as "-A CHAIN ..." rules.
+func (r *nftRuleset) list(chain string) ([]string, bool) {
+	rules, exists := r.chains[chain]
+	if !exists && nftHook(chain) == "" {
+		return nil, false
+	}
+	listed := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		listed = append(listed, "-A "+chain+" "+rule)
+	}
+	return listed, true
+}
+
+// nftStatement translates the match and target of a rule in the family's
+// command syntax into an nft statement.
+func nftStatement(family RuleFamily, args []string) (string, error) {
+	address := "ip"
+	if family == FamilyIPv6 {
+		address = "ip6"
+	}
+
+	var statement []string
+	var protocol, verdict string
+	negate := false
+	for i := 0; i < len(args); i++ {
+		option := args[i]
+		if option == "!" {
+			negate = true
+			continue
+		}
+		if i+1 >= len(args) {
+			return "", fmt.Errorf("option %s has no value", option)
+		}
+		value := args[i+1]
+		i++
+
+		operator := ""
+		if negate {
+			operator = "!= "
+			negate = false
+		}
+
+		var match string
+		switch {
+		case option == "-j":
+			switch value {
+			case "DROP":
+				verdict = "drop"
+			case "ACCEPT":
+				verdict = "accept"
+			case "RETURN":
+				verdict = "return"
+			default:
+				verdict = "jump " + value
+			}
+		case option == "-m":
+			// Match extensions are implied by their options
+		case option == "-p" && family == FamilyBridge:
+			switch strings.ToUpper(value) {
+			case "IPV4":
+				match = "ether type " + operator + "ip"
+			case "IPV6":
+				match = "ether type " + operator + "ip6"
+			case "ARP":
+				match = "ether type " + operator + "arp"
+			default:
+				return "", fmt.Errorf("protocol %s is not supported by the %s firewall backend", value, FirewallNftables)
+			}
+		case option == "-p":
+			if operator != "" {
+				return "", fmt.Errorf("negated protocols are not supported by the %s firewall backend", FirewallNftables)
+			}
+			protocol = value
+		case option == "-s" && family == FamilyBridge:
+			match = "ether saddr " + operator + value
+		case option == "-d" && family == FamilyBridge:
+			match = "ether daddr " + operator + value
+		case option == "-s":
+			match = address + " saddr " + operator + value
+		case option == "-d":
+			match = address + " daddr " + operator + value
+		case option == "--dport" && protocol != "":
+			match = protocol + " dport " + operator + value
+			protocol = ""
+		case option == "--mac-source":
+			match = "ether saddr " + operator + value
+		case option == "--ctstate":
+			match = "ct state " + operator + strings.ToLower(value)
+		case option == "--ip-src":
+			match = "ip saddr " + operator + value
+		case option == "--ip-dst":
+			match = "ip daddr " + operator + value
+		case option == "--arp-ip-src":
+			match = "arp saddr ip " + operator + value
+		case option == "--arp-mac-src":
+			match = "arp saddr ether " + operator + value
+		default:
+			return "", fmt.Errorf("option %s is not supported by the %s firewall backend", option, FirewallNftables)
+		}
+		if match != "" {
+			statement = append(statement, match)
+		}
+	}
+
+	if protocol != "" {
+		statement = append(statement, "meta l4proto "+protocol)
+	}
+	if verdict == "" {
+		return "", fmt.Errorf("rule %q has no target", strings.Join(args, " "))
+	}
+	return strings.Join(append(statement, verdict), " "), nil
+}
+
+// CheckRules checks that nft can be used; it handles every family.
+func (f *nftablesFirewall) CheckRules(RuleFamily) error {
+	return f.Available(InterceptionSpec{})
+}
+
+// RunRule applies a rule to the family's ruleset and replaces the family's
+// table with the result. The ruleset is left unchanged if nft fails.
+func (f *nftablesFirewall) RunRule(family RuleFamily, rule string) error {
+	parsed, err := parseRule(rule)
+	if err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	ruleset := f.rulesets[family]
+	if ruleset == nil {
+		ruleset = &nftRuleset{chains: make(map[string][]string)}
+	}
+	updated := ruleset.clone()
+	if err := updated.apply(family, parsed); err != nil {
+		return fmt.Errorf("%s rule %q: %w", family, rule, err)
+	}
+	if err := f.run(updated.script(family)); err != nil {
+		return fmt.Errorf("failed to apply %s rule %q: %w", family, rule, err)
+	}
+	f.rulesets[family] = updated
+	return nil
+}
+
+// ListRules lists a chain of the family's ruleset.
+func (f *nftablesFirewall) ListRules(family RuleFamily, table, chain string) ([]string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if ruleset := f.rulesets[family]; ruleset != nil && table == "filter" {
+		if rules, exists := ruleset.list(chain); exists {
+			return rules, nil
+		}
+	}
+	return nil, fmt.Errorf("%s chain %s (table %s) does not exist", family, chain, table)
+}
+
+// RenderRule returns the nft command applying rule, or "" for rules that
+// need none, such as chain policies.
+func (f *nftablesFirewall) RenderRule(family RuleFamily, rule string) string {
+	parsed, err := parseRule(rule)
+	if err != nil {
+		return ""
+	}
+	nftFamily, table := nftFamily(family)
+	target := nftFamily + " " + table + " " + parsed.chain
+
+	switch parsed.op {
+	case "-N":
+		return "nft add chain " + target
+	case "-A", "-I":
+		statement, err := nftStatement(family, parsed.args)
+		if err != nil {
+			return ""
+		}
+		if parsed.op == "-I" {
+			return "nft insert rule " + target + " " + statement
+		}
+		return "nft add rule " + target + " " + statement
+	}
+	return ""
+}
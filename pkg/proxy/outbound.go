@@ -0,0 +1,168 @@
+// Package proxy provides outbound blocking for inbound-only containers.
+//
+// Containers that only serve I2P, such as eepsites, never need to open
+// connections of their own, and a compromised service should not be able to
+// either. Their addresses get an iptables rule dropping new connections to the
+// SOCKS proxy and DNS resolver ports, so intercepted traffic never reaches
+// the proxy; the SOCKS proxy also refuses them if the resolver marks them as
+// outbound-disabled. Replies on streams the container accepted through its
+// server tunnels are not new connections and still flow.
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+// noOutboundChain is the iptables chain dropping proxy traffic from
+// inbound-only containers.
+const noOutboundChain = "I2P_NO_OUTBOUND"
+
+// outboundBlockRules returns the rules dropping new proxy connections from ip.
+func outboundBlockRules(ip net.IP) []string {
+	return []string{
+		fmt.Sprintf("-t filter -A %s -s %s -m conntrack --ctstate NEW -j DROP", noOutboundChain, ip),
+	}
+}
+
+// noOutboundChainRules returns the rules creating the chain and sending
+// traffic for the proxy ports to it.
+func (pm *ProxyManager) noOutboundChainRules() []string {
+	return []string{
+		"-t filter -N " + noOutboundChain,
+		fmt.Sprintf("-t filter -I INPUT 1 -p tcp --dport %d -j %s", pm.config.SOCKSPort, noOutboundChain),
+		fmt.Sprintf("-t filter -I INPUT 1 -p udp --dport %d -j %s", pm.config.DNSPort, noOutboundChain),
+		fmt.Sprintf("-t filter -I INPUT 1 -p tcp --dport %d -j %s", pm.config.DNSPort, noOutboundChain),
+	}
+}
+
+// BlockEndpointOutbound drops new connections from an endpoint's address to
+// the proxy listeners.
+//
+// Blocking an endpoint that is already blocked replaces its rules. The chain
+// is created with the first blocked endpoint.
+func (pm *ProxyManager) BlockEndpointOutbound(endpointID string, ip net.IP) error {
+	if ip.To4() == nil {
+		return fmt.Errorf("endpoint %s has no IPv4 address to block", endpointID)
+	}
+
+	pm.outboundMutex.Lock()
+	defer pm.outboundMutex.Unlock()
+
+	if _, exists := pm.outboundBlocks[endpointID]; exists {
+		if err := pm.unblockEndpointLocked(endpointID); err != nil {
+			log.Printf("Warning: Failed to remove previous outbound block of endpoint %s: %v", endpointID, err)
+		}
+	}
+
+	if len(pm.outboundBlocks) == 0 {
+		if err := pm.removeLeftoverChain(noOutboundIptablesChain); err != nil {
+			return err
+		}
+		for _, rule := range pm.noOutboundChainRules() {
+			if err := pm.runIptables(rule); err != nil {
+				pm.removeNoOutboundChain()
+				return fmt.Errorf("failed to create iptables chain %s: %w", noOutboundChain, err)
+			}
+		}
+	}
+
+	ip = ip.To4()
+	rules := outboundBlockRules(ip)
+	for i, rule := range rules {
+		if err := pm.runIptables(rule); err != nil {
+			for _, installed := range rules[:i] {
+				pm.runIptables(strings.Replace(installed, "-A", "-D", 1))
+			}
+			if len(pm.outboundBlocks) == 0 {
+				pm.removeNoOutboundChain()
+			}
+			return fmt.Errorf("failed to block outbound traffic of endpoint %s (%s): %w", endpointID, ip, err)
+		}
+	}
+
+	pm.outboundBlocks[endpointID] = ip
+	log.Printf("Blocked outbound I2P traffic of endpoint %s (%s)", endpointID, ip)
+	return nil
+}
+
+// UnblockEndpointOutbound removes an endpoint's outbound block.
+//
+// The chain is removed with the last blocked endpoint. Unblocking an endpoint
+// that is not blocked is not an error.
+func (pm *ProxyManager) UnblockEndpointOutbound(endpointID string) error {
+	pm.outboundMutex.Lock()
+	defer pm.outboundMutex.Unlock()
+
+	return pm.unblockEndpointLocked(endpointID)
+}
+
+// unblockEndpointLocked removes an endpoint's rules. The caller must hold
+// pm.outboundMutex.
+func (pm *ProxyManager) unblockEndpointLocked(endpointID string) error {
+	ip, exists := pm.outboundBlocks[endpointID]
+	if !exists {
+		return nil
+	}
+	delete(pm.outboundBlocks, endpointID)
+
+	errors := pm.removeOutboundBlock(ip)
+	if len(pm.outboundBlocks) == 0 {
+		errors = append(errors, pm.removeNoOutboundChain()...)
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to unblock endpoint %s: %s", endpointID, strings.Join(errors, "; "))
+	}
+	log.Printf("Removed outbound block of endpoint %s", endpointID)
+	return nil
+}
+
+// unblockAllEndpoints removes every outbound block and the chain, returning
+// errors.
+func (pm *ProxyManager) unblockAllEndpoints() []string {
+	pm.outboundMutex.Lock()
+	defer pm.outboundMutex.Unlock()
+
+	if len(pm.outboundBlocks) == 0 {
+		return nil
+	}
+
+	var errors []string
+	for endpointID, ip := range pm.outboundBlocks {
+		errors = append(errors, pm.removeOutboundBlock(ip)...)
+		delete(pm.outboundBlocks, endpointID)
+	}
+	return append(errors, pm.removeNoOutboundChain()...)
+}
+
+// removeOutboundBlock deletes the rules blocking ip, returning any errors.
+func (pm *ProxyManager) removeOutboundBlock(ip net.IP) []string {
+	var errors []string
+	for _, rule := range outboundBlockRules(ip) {
+		if err := pm.runIptables(strings.Replace(rule, "-A", "-D", 1)); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	return errors
+}
+
+// removeNoOutboundChain unhooks, flushes and deletes the chain, returning any
+// errors.
+func (pm *ProxyManager) removeNoOutboundChain() []string {
+	var teardown []string
+	for _, rule := range pm.noOutboundChainRules()[1:] {
+		teardown = append(teardown, strings.Replace(rule, "-I INPUT 1", "-D INPUT", 1))
+	}
+	teardown = append(teardown, "-t filter -F "+noOutboundChain, "-t filter -X "+noOutboundChain)
+
+	var errors []string
+	for _, rule := range teardown {
+		if err := pm.runIptables(rule); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	return errors
+}
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestProxyManager_BlockEndpointOutbound(t *testing.T) {
+	t.Parallel()
+
+	pm, iptables := newBindTestManager(t)
+	pm.config.SOCKSPort = 1080
+	pm.config.DNSPort = 53
+
+	if err := pm.BlockEndpointOutbound("ep1", net.ParseIP("172.20.0.2")); err != nil {
+		t.Fatalf("Failed to block endpoint: %v", err)
+	}
+
+	expected := []string{
+		"-t filter -N I2P_NO_OUTBOUND",
+		"-t filter -I INPUT 1 -p tcp --dport 1080 -j I2P_NO_OUTBOUND",
+		"-t filter -I INPUT 1 -p udp --dport 53 -j I2P_NO_OUTBOUND",
+		"-t filter -I INPUT 1 -p tcp --dport 53 -j I2P_NO_OUTBOUND",
+		"-t filter -A I2P_NO_OUTBOUND -s 172.20.0.2 -m conntrack --ctstate NEW -j DROP",
+	}
+	if fmt.Sprint(*iptables) != fmt.Sprint(expected) {
+		t.Errorf("Unexpected iptables rules:\n got %v\nwant %v", *iptables, expected)
+	}
+
+	// A second endpoint reuses the chain
+	*iptables = nil
+	if err := pm.BlockEndpointOutbound("ep2", net.ParseIP("172.20.0.3")); err != nil {
+		t.Fatalf("Failed to block second endpoint: %v", err)
+	}
+	if len(*iptables) != 1 {
+		t.Errorf("Expected only the endpoint rule, got %v", *iptables)
+	}
+
+	// Unblocking deletes the endpoint's rule; the last one removes the chain
+	*iptables = nil
+	if err := pm.UnblockEndpointOutbound("ep1"); err != nil {
+		t.Fatalf("Failed to unblock endpoint: %v", err)
+	}
+	if len(*iptables) != 1 || !strings.HasPrefix((*iptables)[0], "-t filter -D I2P_NO_OUTBOUND -s 172.20.0.2 ") {
+		t.Errorf("Expected the endpoint rule to be deleted, got %v", *iptables)
+	}
+	if err := pm.UnblockEndpointOutbound("ep1"); err != nil {
+		t.Errorf("Unblocking an unknown endpoint should not fail: %v", err)
+	}
+
+	*iptables = nil
+	if err := pm.UnblockEndpointOutbound("ep2"); err != nil {
+		t.Fatalf("Failed to unblock last endpoint: %v", err)
+	}
+	expectedTeardown := []string{
+		"-t filter -D I2P_NO_OUTBOUND -s 172.20.0.3 -m conntrack --ctstate NEW -j DROP",
+		"-t filter -D INPUT -p tcp --dport 1080 -j I2P_NO_OUTBOUND",
+		"-t filter -D INPUT -p udp --dport 53 -j I2P_NO_OUTBOUND",
+		"-t filter -D INPUT -p tcp --dport 53 -j I2P_NO_OUTBOUND",
+		"-t filter -F I2P_NO_OUTBOUND",
+		"-t filter -X I2P_NO_OUTBOUND",
+	}
+	if fmt.Sprint(*iptables) != fmt.Sprint(expectedTeardown) {
+		t.Errorf("Unexpected teardown rules:\n got %v\nwant %v", *iptables, expectedTeardown)
+	}
+}
+
+func TestProxyManager_BlockEndpointOutboundFailure(t *testing.T) {
+	t.Parallel()
+
+	pm, _ := newBindTestManager(t)
+	var iptables []string
+	pm.runIptables = func(rule string) error {
+		iptables = append(iptables, rule)
+		if strings.Contains(rule, "-A I2P_NO_OUTBOUND") {
+			return errors.New("conntrack match unavailable")
+		}
+		return nil
+	}
+
+	if err := pm.BlockEndpointOutbound("ep1", net.ParseIP("172.20.0.2")); err == nil {
+		t.Fatal("Expected blocking to fail")
+	}
+	if last := iptables[len(iptables)-1]; last != "-t filter -X I2P_NO_OUTBOUND" {
+		t.Errorf("Expected the chain to be removed after the failure, got %v", iptables)
+	}
+	if len(pm.outboundBlocks) != 0 {
+		t.Errorf("Expected no recorded blocks, got %v", pm.outboundBlocks)
+	}
+
+	if err := pm.BlockEndpointOutbound("ep2", net.ParseIP("fd00::2")); err == nil {
+		t.Error("Expected an IPv6-only address to be rejected")
+	}
+}
@@ -2,12 +2,32 @@ package proxy
 
 import (
 	"net"
+	"strings"
 	"testing"
 
+	"github.com/go-i2p/go-docker-network-i2p/pkg/fakesam"
 	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/testutil"
 	"github.com/miekg/dns"
 )
 
+// startFakeSAM starts an in-memory SAM bridge that is closed when the test
+// ends, and returns its configuration.
+func startFakeSAM(tb testing.TB) *i2p.SAMConfig {
+	host, port := testutil.SAMAddress(testutil.StartFakeSAM(tb, fakesam.ServerOptions{}))
+	return &i2p.SAMConfig{Host: host, Port: port, Timeout: testutil.StartTimeout}
+}
+
+// newFakeSAMTunnelManager returns a tunnel manager on a fake SAM bridge of
+// its own, so tests need no I2P router.
+func newFakeSAMTunnelManager(tb testing.TB) *i2p.TunnelManager {
+	tb.Helper()
+
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: startFakeSAM(tb)})
+	tb.Cleanup(func() { tunnelMgr.DestroyAllTunnels() })
+	return tunnelMgr
+}
+
 func TestNewTrafficInterceptor(t *testing.T) {
 	_, subnet, err := net.ParseCIDR("172.20.0.0/16")
 	if err != nil {
@@ -36,7 +56,7 @@ func TestTrafficInterceptor_generateIptablesRules(t *testing.T) {
 	}
 
 	interceptor := NewTrafficInterceptor(subnet, 1080, 53)
-	rules := interceptor.generateIptablesRules()
+	rules := generateIptablesRules(interceptor.spec())
 
 	// Check that we have the expected number of rules
 	if len(rules) == 0 {
@@ -66,13 +86,8 @@ func TestTrafficInterceptor_generateIptablesRules(t *testing.T) {
 
 func TestNewSOCKSProxy(t *testing.T) {
 	// Create a mock tunnel manager (simplified for testing)
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	proxy := NewSOCKSProxy("127.0.0.1:1080", tunnelMgr)
+	tunnelMgr := newFakeSAMTunnelManager(t)
+	proxy := NewSOCKSProxy(SOCKSOptions{ListenAddr: "127.0.0.1:1080", TunnelManager: tunnelMgr})
 
 	if proxy.listenAddr != "127.0.0.1:1080" {
 		t.Errorf("Expected listen address 127.0.0.1:1080, got %s", proxy.listenAddr)
@@ -81,16 +96,16 @@ func TestNewSOCKSProxy(t *testing.T) {
 	if proxy.tunnelManager != tunnelMgr {
 		t.Error("Expected tunnel manager to be set correctly")
 	}
-}
 
-func TestSOCKSProxy_isI2PDestination(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
+	// The zero value gets its own default filter
+	if NewSOCKSProxy(SOCKSOptions{}).GetTrafficFilter() == nil {
+		t.Error("Expected a default traffic filter for zero options")
 	}
+}
 
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	proxy := NewSOCKSProxy("127.0.0.1:1080", tunnelMgr)
+func TestSOCKSProxy_isI2PDestination(t *testing.T) {
+	tunnelMgr := newFakeSAMTunnelManager(t)
+	proxy := NewSOCKSProxy(SOCKSOptions{ListenAddr: "127.0.0.1:1080", TunnelManager: tunnelMgr})
 
 	tests := []struct {
 		name     string
@@ -227,12 +242,13 @@ func TestDefaultProxyConfig(t *testing.T) {
 		t.Errorf("Expected DNS port 53, got %d", config.DNSPort)
 	}
 
-	if config.SOCKSBindAddr != "127.0.0.1:1080" {
-		t.Errorf("Expected SOCKS bind address 127.0.0.1:1080, got %s", config.SOCKSBindAddr)
+	// Networks bind their own listeners; no fixed listener by default
+	if config.SOCKSBindAddr != "" {
+		t.Errorf("Expected no fixed SOCKS bind address, got %s", config.SOCKSBindAddr)
 	}
 
-	if config.DNSBindAddr != "127.0.0.1:53" {
-		t.Errorf("Expected DNS bind address 127.0.0.1:53, got %s", config.DNSBindAddr)
+	if config.DNSBindAddr != "" {
+		t.Errorf("Expected no fixed DNS bind address, got %s", config.DNSBindAddr)
 	}
 }
 
@@ -244,13 +260,8 @@ func TestNewProxyManager(t *testing.T) {
 
 	config := DefaultProxyConfig(subnet)
 
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager := NewProxyManager(config, tunnelMgr)
+	tunnelMgr := newFakeSAMTunnelManager(t)
+	manager := NewProxyManager(ProxyOptions{Config: config, TunnelManager: tunnelMgr})
 
 	if manager.config != config {
 		t.Error("Expected config to be set correctly")
@@ -263,6 +274,15 @@ func TestNewProxyManager(t *testing.T) {
 	if !manager.IsRunning() {
 		t.Error("Expected new proxy manager to be in running state")
 	}
+
+	// Zero options use the default ports, but cannot intercept without a subnet
+	zero := NewProxyManager(ProxyOptions{})
+	if zero.config.SOCKSPort != 1080 || zero.config.DNSPort != 53 {
+		t.Errorf("Expected default ports, got %d and %d", zero.config.SOCKSPort, zero.config.DNSPort)
+	}
+	if err := zero.Start(); err == nil || !strings.Contains(err.Error(), "subnet") {
+		t.Errorf("Expected Start to require a container subnet, got %v", err)
+	}
 }
 
 /*func TestProxyManager_Lifecycle(t *testing.T) {
@@ -278,13 +298,13 @@ func TestNewProxyManager(t *testing.T) {
 	config.SOCKSBindAddr = "127.0.0.1:10801" // Use non-privileged port
 	config.DNSBindAddr = "127.0.0.1:5353"    // Use non-privileged port
 
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
+	samClient, err := i2p.NewSAMClient(startFakeSAM(t))
 	if err != nil {
 		t.Fatalf("Failed to create SAM client: %v", err)
 	}
 
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager := NewProxyManager(config, tunnelMgr)
+	tunnelMgr := newFakeSAMTunnelManager(t)
+	manager := NewProxyManager(ProxyOptions{Config: config, TunnelManager: tunnelMgr})
 
 	// Test start (will fail due to iptables, but that's expected)
 	err = manager.Start()
@@ -316,12 +336,12 @@ func TestI2PDNSResolver_resolveQuestion(t *testing.T) {
 		Qclass: dns.ClassINET,
 	}
 
-	answer := resolver.resolveQuestion(question)
-	if answer == nil {
-		t.Error("Expected answer for I2P domain, got nil")
+	answers, _ := resolver.resolveQuestion(question)
+	if len(answers) != 1 {
+		t.Fatalf("Expected one answer for I2P domain, got %d", len(answers))
 	}
 
-	if aRecord, ok := answer.(*dns.A); ok {
+	if aRecord, ok := answers[0].(*dns.A); ok {
 		if aRecord.A == nil {
 			t.Error("Expected A record to have an IP address")
 		}
@@ -340,9 +360,9 @@ func TestI2PDNSResolver_resolveQuestion_NonI2P(t *testing.T) {
 		Qclass: dns.ClassINET,
 	}
 
-	answer := resolver.resolveQuestion(question)
-	if answer != nil {
-		t.Error("Expected nil answer for non-I2P domain")
+	answers, _ := resolver.resolveQuestion(question)
+	if len(answers) != 0 {
+		t.Error("Expected no answer for non-I2P domain")
 	}
 }
 
@@ -355,7 +375,7 @@ func TestTrafficInterceptor_iptablesIntegration(t *testing.T) {
 	interceptor := NewTrafficInterceptor(subnet, 1080, 53)
 
 	// Test that rules are generated correctly
-	rules := interceptor.generateIptablesRules()
+	rules := generateIptablesRules(interceptor.spec())
 	if len(rules) == 0 {
 		t.Error("Expected iptables rules to be generated")
 	}
@@ -378,13 +398,8 @@ func BenchmarkI2PDNSResolver_generateI2PIP(b *testing.B) {
 }
 
 func TestSOCKSProxy_TrafficFilterIntegration(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	proxy := NewSOCKSProxy("127.0.0.1:1080", tunnelMgr)
+	tunnelMgr := newFakeSAMTunnelManager(t)
+	proxy := NewSOCKSProxy(SOCKSOptions{ListenAddr: "127.0.0.1:1080", TunnelManager: tunnelMgr})
 
 	// Test traffic filter initialization
 	filter := proxy.GetTrafficFilter()
@@ -393,7 +408,7 @@ func TestSOCKSProxy_TrafficFilterIntegration(t *testing.T) {
 	}
 
 	// Test allowlist functionality
-	err = filter.AddToAllowlist("example.i2p")
+	err := filter.AddToAllowlist("example.i2p")
 	if err != nil {
 		t.Errorf("Failed to add to allowlist: %v", err)
 	}
@@ -429,13 +444,8 @@ func TestProxyManager_TrafficFilterIntegration(t *testing.T) {
 
 	config := DefaultProxyConfig(subnet)
 
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager := NewProxyManager(config, tunnelMgr)
+	tunnelMgr := newFakeSAMTunnelManager(t)
+	manager := NewProxyManager(ProxyOptions{Config: config, TunnelManager: tunnelMgr})
 
 	// Test traffic filter initialization
 	filter := manager.GetTrafficFilter()
@@ -499,17 +509,12 @@ func TestProxyManager_TrafficFilterIntegration(t *testing.T) {
 }
 
 func TestSOCKSProxy_TrafficFilterValidation(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	proxy := NewSOCKSProxy("127.0.0.1:1080", tunnelMgr)
+	tunnelMgr := newFakeSAMTunnelManager(t)
+	proxy := NewSOCKSProxy(SOCKSOptions{ListenAddr: "127.0.0.1:1080", TunnelManager: tunnelMgr})
 	filter := proxy.GetTrafficFilter()
 
 	// Test wildcard patterns
-	err = filter.AddToAllowlist("*.example.i2p")
+	err := filter.AddToAllowlist("*.example.i2p")
 	if err != nil {
 		t.Errorf("Failed to add wildcard to allowlist: %v", err)
 	}
@@ -551,13 +556,8 @@ func TestSOCKSProxy_TrafficFilterValidation(t *testing.T) {
 }
 
 func BenchmarkSOCKSProxy_isI2PDestination(b *testing.B) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		b.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	proxy := NewSOCKSProxy("127.0.0.1:1080", tunnelMgr)
+	tunnelMgr := newFakeSAMTunnelManager(b)
+	proxy := NewSOCKSProxy(SOCKSOptions{ListenAddr: "127.0.0.1:1080", TunnelManager: tunnelMgr})
 	target := "example.i2p:80"
 
 	b.ResetTimer()
@@ -567,13 +567,8 @@ func BenchmarkSOCKSProxy_isI2PDestination(b *testing.B) {
 }
 
 func BenchmarkTrafficFilter_ShouldAllowConnection(b *testing.B) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		b.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	proxy := NewSOCKSProxy("127.0.0.1:1080", tunnelMgr)
+	tunnelMgr := newFakeSAMTunnelManager(b)
+	proxy := NewSOCKSProxy(SOCKSOptions{ListenAddr: "127.0.0.1:1080", TunnelManager: tunnelMgr})
 	filter := proxy.GetTrafficFilter()
 
 	// Add some test patterns
@@ -0,0 +1,160 @@
+// Package proxy provides SOCKS target rewriting.
+//
+// Application configs often name their upstreams with clearnet-style hosts
+// such as service.internal. A TargetRewriter maps such names to I2P
+// destinations before the SOCKS proxy filters and dials a target, so those
+// configs work unmodified while the traffic still rides I2P, and filter rules
+// and traffic logs see the I2P destination. AliasRewriter is the configured
+// rewriter: it maps exact names, and wildcard aliases such as *.internal map
+// every subdomain of a suffix.
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// TargetRewriter maps requested SOCKS hosts to I2P destinations.
+type TargetRewriter interface {
+	// Rewrite returns the I2P destination for host and true, or false if
+	// host is not rewritten
+	Rewrite(host string) (string, bool)
+}
+
+// AliasRewriter rewrites configured alias names to I2P destinations.
+//
+// Aliases are "alias=destination" pairs. An alias of the form *.suffix
+// matches every name ending in .suffix; if its destination also starts with
+// "*.", the matched prefix is carried over, so *.internal=*.i2p maps
+// forum.internal to forum.i2p. Exact aliases take precedence over wildcards,
+// and longer wildcard suffixes over shorter ones.
+type AliasRewriter struct {
+	// exact maps lower-cased alias names to destinations
+	exact map[string]string
+	// wildcards are the wildcard aliases, longest suffix first
+	wildcards []wildcardAlias
+}
+
+// wildcardAlias maps the subdomains of a suffix to a destination.
+type wildcardAlias struct {
+	// suffix is the matched suffix including its leading dot, e.g. ".internal"
+	suffix string
+	// destination is the I2P destination, with a leading "*." if the matched
+	// prefix is carried over
+	destination string
+}
+
+// NewAliasRewriter creates a rewriter for "alias=destination" pairs.
+//
+// Destinations must be I2P names or base32 addresses, and aliases must not be
+// I2P names themselves, so a rewrite can never turn an I2P target into a
+// clearnet one or shadow an I2P name.
+func NewAliasRewriter(aliases []string) (*AliasRewriter, error) {
+	rewriter := &AliasRewriter{exact: make(map[string]string)}
+	seen := make(map[string]bool)
+
+	for _, entry := range aliases {
+		alias, destination, ok := strings.Cut(entry, "=")
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		destination = strings.ToLower(strings.TrimSpace(destination))
+		if !ok || alias == "" || destination == "" {
+			return nil, fmt.Errorf("invalid SOCKS alias %q: expected alias=destination", entry)
+		}
+		if seen[alias] {
+			return nil, fmt.Errorf("duplicate SOCKS alias %q", alias)
+		}
+		seen[alias] = true
+
+		suffix, wildcard := strings.CutPrefix(alias, "*")
+		if wildcard && !strings.HasPrefix(suffix, ".") {
+			return nil, fmt.Errorf("invalid SOCKS alias %q: wildcards must have the form *.suffix", alias)
+		}
+		name := strings.TrimPrefix(suffix, ".")
+		if !isValidSOCKSDomain([]byte(name)) || strings.Contains(name, "*") || name == "" {
+			return nil, fmt.Errorf("invalid SOCKS alias %q: not a hostname", alias)
+		}
+		if name == "i2p" || strings.HasSuffix(name, ".i2p") {
+			return nil, fmt.Errorf("invalid SOCKS alias %q: I2P names cannot be aliased", alias)
+		}
+
+		target, carried := strings.CutPrefix(destination, "*.")
+		if carried && !wildcard {
+			return nil, fmt.Errorf("invalid SOCKS alias %q: only wildcard aliases can have a wildcard destination", entry)
+		}
+		if !isValidSOCKSDomain([]byte(target)) || !strings.HasSuffix("."+target, ".i2p") {
+			return nil, fmt.Errorf("invalid SOCKS alias %q: destination %q is not an I2P name", entry, destination)
+		}
+
+		if wildcard {
+			rewriter.wildcards = append(rewriter.wildcards, wildcardAlias{suffix: suffix, destination: destination})
+		} else {
+			rewriter.exact[alias] = destination
+		}
+	}
+
+	sort.SliceStable(rewriter.wildcards, func(i, j int) bool {
+		return len(rewriter.wildcards[i].suffix) > len(rewriter.wildcards[j].suffix)
+	})
+	return rewriter, nil
+}
+
+// Rewrite returns the I2P destination of an aliased host.
+func (r *AliasRewriter) Rewrite(host string) (string, bool) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if destination, ok := r.exact[host]; ok {
+		return destination, true
+	}
+
+	for _, alias := range r.wildcards {
+		prefix, ok := strings.CutSuffix(host, alias.suffix)
+		if !ok || prefix == "" {
+			continue
+		}
+		if target, carried := strings.CutPrefix(alias.destination, "*."); carried {
+			return prefix + "." + target, true
+		}
+		return alias.destination, true
+	}
+	return "", false
+}
+
+// Len returns the number of configured aliases.
+func (r *AliasRewriter) Len() int {
+	return len(r.exact) + len(r.wildcards)
+}
+
+// rewriteTarget applies a rewriter to the host of a host:port target.
+//
+// Targets that are not rewritten are returned unchanged.
+func rewriteTarget(rewriter TargetRewriter, target string) string {
+	if rewriter == nil {
+		return target
+	}
+
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return target
+	}
+	if destination, ok := rewriter.Rewrite(host); ok {
+		return net.JoinHostPort(destination, port)
+	}
+	return target
+}
+
+// SetTargetRewriter sets the rewriter applied to SOCKS targets on all
+// listeners.
+//
+// Listeners started later use the same rewriter. A nil rewriter leaves
+// targets unchanged.
+func (pm *ProxyManager) SetTargetRewriter(rewriter TargetRewriter) {
+	pm.listenerMutex.Lock()
+	defer pm.listenerMutex.Unlock()
+
+	pm.targetRewriter = rewriter
+	pm.socksProxy.SetTargetRewriter(rewriter)
+	for _, listener := range pm.listeners {
+		listener.socksProxy.SetTargetRewriter(rewriter)
+	}
+}
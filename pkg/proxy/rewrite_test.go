@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAliasRewriter(t *testing.T) {
+	rewriter, err := NewAliasRewriter([]string{
+		"service.internal=forum.i2p",
+		"*.internal=*.i2p",
+		"*.db.internal=postgres.i2p",
+		"Wiki.Corp = ukqv5i2rxsrhqu7ilq7bgsbhyadzgyjqqjfjqpjcjffkivbcqjba.b32.i2p",
+	})
+	if err != nil {
+		t.Fatalf("NewAliasRewriter() unexpected error: %v", err)
+	}
+	if rewriter.Len() != 4 {
+		t.Errorf("Expected 4 aliases, got %d", rewriter.Len())
+	}
+
+	tests := []struct {
+		host     string
+		expected string
+		ok       bool
+	}{
+		{host: "service.internal", expected: "forum.i2p", ok: true},
+		{host: "SERVICE.internal.", expected: "forum.i2p", ok: true},
+		{host: "git.internal", expected: "git.i2p", ok: true},
+		{host: "a.b.internal", expected: "a.b.i2p", ok: true},
+		{host: "main.db.internal", expected: "postgres.i2p", ok: true},
+		{host: "wiki.corp", expected: "ukqv5i2rxsrhqu7ilq7bgsbhyadzgyjqqjfjqpjcjffkivbcqjba.b32.i2p", ok: true},
+		{host: "internal"},
+		{host: "example.com"},
+		{host: "forum.i2p"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			destination, ok := rewriter.Rewrite(tt.host)
+			if ok != tt.ok || destination != tt.expected {
+				t.Errorf("Rewrite(%q) = %q, %v; expected %q, %v", tt.host, destination, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestNewAliasRewriterErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		alias   string
+		errText string
+	}{
+		{name: "missing destination", alias: "service.internal", errText: "expected alias=destination"},
+		{name: "clearnet destination", alias: "service.internal=example.com", errText: "is not an I2P name"},
+		{name: "I2P alias", alias: "forum.i2p=other.i2p", errText: "I2P names cannot be aliased"},
+		{name: "wildcard I2P alias", alias: "*.i2p=*.i2p", errText: "I2P names cannot be aliased"},
+		{name: "bad wildcard", alias: "*internal=forum.i2p", errText: "*.suffix"},
+		{name: "wildcard destination", alias: "service.internal=*.i2p", errText: "only wildcard aliases"},
+		{name: "invalid characters", alias: "service internal=forum.i2p", errText: "not a hostname"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewAliasRewriter([]string{tt.alias})
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expected an error containing %q, got %v", tt.errText, err)
+			}
+		})
+	}
+
+	if _, err := NewAliasRewriter([]string{"a.internal=a.i2p", "A.internal=b.i2p"}); err == nil {
+		t.Error("Expected an error for a duplicate alias")
+	}
+}
+
+func TestRewriteTarget(t *testing.T) {
+	rewriter, _ := NewAliasRewriter([]string{"service.internal=forum.i2p"})
+
+	if got := rewriteTarget(rewriter, "service.internal:8080"); got != "forum.i2p:8080" {
+		t.Errorf("Expected forum.i2p:8080, got %s", got)
+	}
+	if got := rewriteTarget(rewriter, "example.com:80"); got != "example.com:80" {
+		t.Errorf("Expected unaliased targets to be unchanged, got %s", got)
+	}
+	if got := rewriteTarget(nil, "service.internal:80"); got != "service.internal:80" {
+		t.Errorf("Expected targets to be unchanged without a rewriter, got %s", got)
+	}
+}
+
+func TestSOCKSProxy_TargetRewriting(t *testing.T) {
+	proxy, addr := startSourceTestProxy(t, nil)
+	if err := proxy.GetTrafficFilter().AddToBlocklist("bad.i2p"); err != nil {
+		t.Fatalf("Failed to block destination: %v", err)
+	}
+	rewriter, _ := NewAliasRewriter([]string{"service.internal=bad.i2p"})
+	proxy.SetTargetRewriter(rewriter)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	host := "service.internal"
+	request := []byte{0x05, 0x01, 0x00, 0x05, 0x01, 0x00, 0x03, byte(len(host))}
+	request = append(append(request, host...), 0x00, 0x50)
+	if _, err := conn.Write(request); err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+
+	reply := make([]byte, 12)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatalf("Failed to read reply: %v", err)
+	}
+	if reply[3] != 0x02 {
+		t.Errorf("Expected the aliased destination to be refused by the ruleset, got reply %v", reply)
+	}
+
+	logs := proxy.GetTrafficFilter().GetRecentLogs(1)
+	if len(logs) != 1 || logs[0].Destination != "bad.i2p:80" {
+		t.Errorf("Expected the filter to see bad.i2p:80, got %+v", logs)
+	}
+}
+
+func TestProxyManager_SetTargetRewriter(t *testing.T) {
+	pm, _ := newBindTestManager(t)
+	rewriter, _ := NewAliasRewriter([]string{"service.internal=forum.i2p"})
+
+	pm.SetTargetRewriter(rewriter)
+	if pm.socksProxy.getTargetRewriter() != rewriter {
+		t.Error("Expected target rewriter to be applied to the fixed SOCKS proxy")
+	}
+	if pm.newSOCKSProxy("127.0.0.1:0").getTargetRewriter() != rewriter {
+		t.Error("Expected target rewriter to be applied to new SOCKS proxies")
+	}
+
+	pm.SetTargetRewriter(nil)
+	if pm.socksProxy.getTargetRewriter() != nil {
+		t.Error("Expected target rewriter to be cleared")
+	}
+}
@@ -0,0 +1,176 @@
+// Package proxy provides per-endpoint Unix socket access to the I2P proxy.
+//
+// This file implements the sidecar pattern: the SOCKS proxy and the DNS
+// resolution HTTP API are served on Unix sockets that are bind-mounted into
+// designated containers. Only containers with the socket mounted can reach
+// the proxy, regardless of IP-level reachability.
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// sidecarSocketMode is the permission mode for sidecar sockets.
+//
+// Access is controlled by which containers get the socket mounted, so the
+// socket itself is usable by any user inside those containers.
+const sidecarSocketMode = 0666
+
+// SidecarSockets is a set of Unix sockets serving the proxy to one endpoint.
+type SidecarSockets struct {
+	// SOCKSPath is the Unix socket path for the SOCKS5 proxy
+	SOCKSPath string
+	// DNSPath is the Unix socket path for the DNS resolution HTTP API
+	DNSPath string
+
+	// socksListener accepts SOCKS5 connections
+	socksListener net.Listener
+	// dnsServer serves the resolution API
+	dnsServer *http.Server
+}
+
+// StartSidecarSockets starts SOCKS and DNS Unix sockets for an endpoint.
+//
+// Either path may be empty to skip that socket. Parent directories are created
+// as needed and stale socket files are replaced.
+func (pm *ProxyManager) StartSidecarSockets(id, socksPath, dnsPath string) (*SidecarSockets, error) {
+	if id == "" {
+		return nil, fmt.Errorf("sidecar ID cannot be empty")
+	}
+	if socksPath == "" && dnsPath == "" {
+		return nil, fmt.Errorf("at least one sidecar socket path is required")
+	}
+
+	pm.sidecarMutex.Lock()
+	defer pm.sidecarMutex.Unlock()
+
+	if _, exists := pm.sidecars[id]; exists {
+		return nil, fmt.Errorf("sidecar sockets for %s already exist", id)
+	}
+
+	sockets := &SidecarSockets{SOCKSPath: socksPath, DNSPath: dnsPath}
+
+	if socksPath != "" {
+		listener, err := listenSidecarSocket(socksPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start SOCKS socket: %w", err)
+		}
+		sockets.socksListener = listener
+
+		go func() {
+			if err := pm.socksProxy.Serve(listener); err != nil {
+				log.Printf("Warning: SOCKS sidecar socket %s stopped: %v", socksPath, err)
+			}
+		}()
+	}
+
+	if dnsPath != "" {
+		listener, err := listenSidecarSocket(dnsPath)
+		if err != nil {
+			sockets.close()
+			return nil, fmt.Errorf("failed to start DNS socket: %w", err)
+		}
+		sockets.dnsServer = &http.Server{Handler: pm.dnsResolver}
+
+		go func() {
+			if err := sockets.dnsServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+				log.Printf("Warning: DNS sidecar socket %s stopped: %v", dnsPath, err)
+			}
+		}()
+	}
+
+	pm.sidecars[id] = sockets
+	log.Printf("Started sidecar sockets for %s (socks: %q, dns: %q)", id, socksPath, dnsPath)
+
+	return sockets, nil
+}
+
+// StopSidecarSockets stops and removes the Unix sockets for an endpoint.
+//
+// Stopping an unknown ID is not an error.
+func (pm *ProxyManager) StopSidecarSockets(id string) error {
+	pm.sidecarMutex.Lock()
+	sockets, exists := pm.sidecars[id]
+	delete(pm.sidecars, id)
+	pm.sidecarMutex.Unlock()
+
+	if !exists {
+		return nil
+	}
+
+	log.Printf("Stopping sidecar sockets for %s", id)
+	return sockets.close()
+}
+
+// stopAllSidecarSockets stops every sidecar socket set.
+func (pm *ProxyManager) stopAllSidecarSockets() []string {
+	pm.sidecarMutex.Lock()
+	sidecars := pm.sidecars
+	pm.sidecars = make(map[string]*SidecarSockets)
+	pm.sidecarMutex.Unlock()
+
+	var errors []string
+	for id, sockets := range sidecars {
+		if err := sockets.close(); err != nil {
+			errors = append(errors, fmt.Sprintf("sidecar %s: %v", id, err))
+		}
+	}
+	return errors
+}
+
+// close shuts down the listeners and removes the socket files.
+func (s *SidecarSockets) close() error {
+	var errors []string
+
+	if s.socksListener != nil {
+		if err := s.socksListener.Close(); err != nil {
+			errors = append(errors, fmt.Sprintf("SOCKS socket: %v", err))
+		}
+		os.Remove(s.SOCKSPath)
+	}
+
+	if s.dnsServer != nil {
+		if err := s.dnsServer.Shutdown(context.Background()); err != nil {
+			errors = append(errors, fmt.Sprintf("DNS socket: %v", err))
+		}
+		os.Remove(s.DNSPath)
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to close sidecar sockets: %v", errors)
+	}
+	return nil
+}
+
+// listenSidecarSocket creates a Unix socket listener at path.
+func listenSidecarSocket(path string) (net.Listener, error) {
+	if !filepath.IsAbs(path) {
+		return nil, fmt.Errorf("socket path must be absolute: %s", path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	if err := os.RemoveAll(path); err != nil {
+		return nil, fmt.Errorf("failed to remove existing socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+
+	if err := os.Chmod(path, sidecarSocketMode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+
+	return listener, nil
+}
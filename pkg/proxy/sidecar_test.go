@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProxyManager_SidecarSockets(t *testing.T) {
+	t.Parallel()
+
+	pm, _ := newBindTestManager(t)
+	dir := t.TempDir()
+	socksPath := filepath.Join(dir, "web", "socks.sock")
+	dnsPath := filepath.Join(dir, "web", "dns.sock")
+
+	sockets, err := pm.StartSidecarSockets("ep1", socksPath, dnsPath)
+	if err != nil {
+		t.Fatalf("Failed to start sidecar sockets: %v", err)
+	}
+	if sockets.SOCKSPath != socksPath || sockets.DNSPath != dnsPath {
+		t.Errorf("Unexpected socket paths: %+v", sockets)
+	}
+
+	if _, err := pm.StartSidecarSockets("ep1", socksPath, dnsPath); err == nil {
+		t.Error("Expected error starting duplicate sidecar sockets")
+	}
+
+	// The DNS API resolves I2P names over the Unix socket
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", dnsPath)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://sidecar/resolve?name=example.i2p")
+	if err != nil {
+		t.Fatalf("Failed to query DNS socket: %v", err)
+	}
+	var result map[string]string
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	_, i2pRange, _ := net.ParseCIDR("198.18.0.0/15")
+	if ip := net.ParseIP(result["address"]); ip == nil || !i2pRange.Contains(ip) {
+		t.Errorf("Expected address in I2P range, got %q", result["address"])
+	}
+
+	resp, err = client.Get("http://sidecar/resolve?name=example.com")
+	if err != nil {
+		t.Fatalf("Failed to query DNS socket: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404 for non-I2P name, got %d", resp.StatusCode)
+	}
+
+	// The SOCKS socket accepts connections
+	conn, err := net.Dial("unix", socksPath)
+	if err != nil {
+		t.Fatalf("Failed to connect to SOCKS socket: %v", err)
+	}
+	conn.Close()
+
+	if err := pm.StopSidecarSockets("ep1"); err != nil {
+		t.Errorf("Failed to stop sidecar sockets: %v", err)
+	}
+	for _, path := range []string{socksPath, dnsPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected socket %s to be removed", path)
+		}
+	}
+
+	if err := pm.StopSidecarSockets("ep1"); err != nil {
+		t.Errorf("Stopping unknown sidecar should not fail: %v", err)
+	}
+}
+
+func TestProxyManager_SidecarSocketsValidation(t *testing.T) {
+	t.Parallel()
+
+	pm, _ := newBindTestManager(t)
+
+	if _, err := pm.StartSidecarSockets("", "/tmp/a.sock", ""); err == nil {
+		t.Error("Expected error for empty ID")
+	}
+	if _, err := pm.StartSidecarSockets("ep1", "", ""); err == nil {
+		t.Error("Expected error when no socket paths are given")
+	}
+	if _, err := pm.StartSidecarSockets("ep1", "relative/socks.sock", ""); err == nil {
+		t.Error("Expected error for relative socket path")
+	}
+}
@@ -2,11 +2,13 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
@@ -23,6 +25,22 @@ type SOCKSProxy struct {
 	tunnelManager *i2p.TunnelManager
 	// trafficFilter provides traffic filtering and monitoring
 	trafficFilter *TrafficFilter
+	// sourceResolver maps client addresses to endpoints (nil accepts all clients)
+	sourceResolver SourceResolver
+	// sourceMutex protects sourceResolver
+	sourceMutex sync.RWMutex
+	// namingResolver looks up I2P hostnames (nil hands them to the router)
+	namingResolver NamingResolver
+	// namingMutex protects namingResolver
+	namingMutex sync.RWMutex
+	// targetRewriter maps aliased hosts to I2P destinations (nil leaves targets unchanged)
+	targetRewriter TargetRewriter
+	// external maps the external service names of networks to their destinations (nil maps none)
+	external *ExternalServices
+	// targetMutex protects targetRewriter
+	targetMutex sync.RWMutex
+	// addresses maps synthetic addresses back to I2P names (nil disables translation)
+	addresses *SyntheticAddresses
 	// listener is the TCP listener for SOCKS connections
 	listener net.Listener
 	// ctx is the context for proxy operation
@@ -31,19 +49,54 @@ type SOCKSProxy struct {
 	cancel context.CancelFunc
 }
 
+// SOCKSOptions configures a SOCKSProxy.
+//
+// Every field is optional. The zero value gives a proxy that only serves
+// listeners passed to Serve, filters with DefaultFilterConfig, accepts any
+// client and hands I2P hostnames to the router.
+type SOCKSOptions struct {
+	// ListenAddr is the TCP address Start listens on, e.g. "127.0.0.1:1080"
+	ListenAddr string
+	// TunnelManager provides the I2P tunnels connections are made through
+	TunnelManager *i2p.TunnelManager
+	// TrafficFilter decides which destinations may be reached (nil uses DefaultFilterConfig)
+	TrafficFilter *TrafficFilter
+	// SourceResolver verifies client addresses (nil accepts all clients)
+	SourceResolver SourceResolver
+	// NamingResolver looks up I2P hostnames (nil hands them to the router)
+	NamingResolver NamingResolver
+	// Addresses maps synthetic addresses back to I2P names (nil disables translation)
+	Addresses *SyntheticAddresses
+	// TargetRewriter maps aliased hosts to I2P destinations (nil leaves targets unchanged)
+	TargetRewriter TargetRewriter
+	// External maps the external service names of networks to their destinations (nil maps none)
+	External *ExternalServices
+}
+
 // NewSOCKSProxy creates a new SOCKS5 proxy that routes traffic through I2P.
 //
-// The proxy will listen on the specified address and use the tunnel manager
-// to create I2P client tunnels for outbound connections.
-func NewSOCKSProxy(listenAddr string, tunnelManager *i2p.TunnelManager) *SOCKSProxy {
+// The proxy uses the options' tunnel manager to create I2P client tunnels
+// for outbound connections. The resolvers, filter and synthetic addresses
+// can be replaced later with the proxy's setters.
+func NewSOCKSProxy(options SOCKSOptions) *SOCKSProxy {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	trafficFilter := options.TrafficFilter
+	if trafficFilter == nil {
+		trafficFilter = NewTrafficFilter(DefaultFilterConfig())
+	}
+
 	return &SOCKSProxy{
-		listenAddr:    listenAddr,
-		tunnelManager: tunnelManager,
-		trafficFilter: NewTrafficFilter(DefaultFilterConfig()),
-		ctx:           ctx,
-		cancel:        cancel,
+		listenAddr:     options.ListenAddr,
+		tunnelManager:  options.TunnelManager,
+		trafficFilter:  trafficFilter,
+		sourceResolver: options.SourceResolver,
+		namingResolver: options.NamingResolver,
+		addresses:      options.Addresses,
+		targetRewriter: options.TargetRewriter,
+		external:       options.External,
+		ctx:            ctx,
+		cancel:         cancel,
 	}
 }
 
@@ -83,6 +136,24 @@ func (s *SOCKSProxy) Start() error {
 	}
 }
 
+// Serve accepts SOCKS5 connections on an existing listener.
+//
+// This is used for additional listeners such as per-endpoint Unix sockets.
+// It blocks until the listener is closed, which is not treated as an error.
+func (s *SOCKSProxy) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		go s.handleConnection(conn)
+	}
+}
+
 // Stop gracefully shuts down the SOCKS proxy.
 //
 // This method closes the listener and cancels all active connections.
@@ -103,6 +174,14 @@ func (s *SOCKSProxy) Stop() error {
 func (s *SOCKSProxy) handleConnection(conn net.Conn) {
 	defer conn.Close()
 
+	// Refuse clients that are not endpoints of managed networks before
+	// speaking SOCKS to them
+	source, clientAddr, err := verifySource(s.getSourceResolver(), conn)
+	if err != nil {
+		s.trafficFilter.RejectSource(clientAddr, "tcp", err.Error())
+		return
+	}
+
 	// Set connection timeout
 	conn.SetDeadline(time.Now().Add(30 * time.Second))
 
@@ -118,8 +197,19 @@ func (s *SOCKSProxy) handleConnection(conn net.Conn) {
 		return
 	}
 
+	// Clients that resolved a name through the DNS resolver connect to its
+	// synthetic address; filter and connect by the name instead
+	target = translateSyntheticTarget(s.addresses, target)
+
+	// External services of the client's network are connected by name
+	target = s.external.rewriteTarget(source.NetworkID, target)
+
+	// Map aliases such as service.internal to their I2P destinations before
+	// filtering, so filter rules and logs see the destination
+	target = rewriteTarget(s.getTargetRewriter(), target)
+
 	// Check if connection should be allowed using traffic filter
-	allowed, _ := s.trafficFilter.ShouldAllowConnection(target, "tcp")
+	allowed, _ := s.trafficFilter.ShouldAllowConnectionFrom(source.ContainerID, target, "tcp")
 	if !allowed {
 		s.sendSOCKS5Error(conn, 0x02) // Connection not allowed by ruleset
 		return
@@ -138,41 +228,46 @@ func (s *SOCKSProxy) handleConnection(conn net.Conn) {
 		return
 	}
 
-	// Get client address for logging
-	clientAddr := conn.RemoteAddr().String()
-
 	// Relay traffic between SOCKS client and I2P connection
+	s.trafficFilter.openEndpointConnection(source.EndpointID)
 	bytesTransferred := s.relayTraffic(conn, i2pConn)
 
-	// Log the completed connection
+	// Log the completed connection and account it to the client's container
 	s.trafficFilter.LogConnection(clientAddr, target, "tcp", bytesTransferred)
+	s.trafficFilter.RecordUsage(source.ContainerID, bytesTransferred)
+	s.trafficFilter.closeEndpointConnection(source.EndpointID, bytesTransferred)
 }
 
 // performSOCKS5Handshake handles the SOCKS5 authentication handshake.
 //
-// This implementation only supports "no authentication" method.
-func (s *SOCKSProxy) performSOCKS5Handshake(conn net.Conn) error {
-	// Read client greeting
-	buf := make([]byte, 256)
-	n, err := conn.Read(buf)
-	if err != nil || n < 3 {
-		return fmt.Errorf("failed to read SOCKS5 greeting")
+// This implementation only supports "no authentication" method. The greeting
+// is read field by field so a request pipelined behind it is left unread.
+func (s *SOCKSProxy) performSOCKS5Handshake(conn io.ReadWriter) error {
+	// Read client greeting: VER NMETHODS
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("failed to read SOCKS5 greeting: %w", err)
 	}
 
 	// Check SOCKS version
-	if buf[0] != 0x05 {
-		return fmt.Errorf("unsupported SOCKS version: %d", buf[0])
+	if header[0] != 0x05 {
+		return fmt.Errorf("unsupported SOCKS version: %d", header[0])
 	}
 
 	// Check for "no authentication" method
-	nMethods := int(buf[1])
-	if nMethods == 0 || n < 2+nMethods {
+	nMethods := int(header[1])
+	if nMethods == 0 {
 		return fmt.Errorf("invalid SOCKS5 greeting")
 	}
 
+	methods := make([]byte, nMethods)
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return fmt.Errorf("invalid SOCKS5 greeting: %w", err)
+	}
+
 	supportsNoAuth := false
-	for i := 0; i < nMethods; i++ {
-		if buf[2+i] == 0x00 { // No authentication
+	for _, method := range methods {
+		if method == 0x00 { // No authentication
 			supportsNoAuth = true
 			break
 		}
@@ -185,60 +280,91 @@ func (s *SOCKSProxy) performSOCKS5Handshake(conn net.Conn) error {
 	}
 
 	// Send "no authentication" response
-	_, err = conn.Write([]byte{0x05, 0x00})
+	_, err := conn.Write([]byte{0x05, 0x00})
 	return err
 }
 
 // parseSOCKS5Request parses the SOCKS5 connection request.
 //
-// Returns the target address in "host:port" format.
-func (s *SOCKSProxy) parseSOCKS5Request(conn net.Conn) (string, error) {
-	buf := make([]byte, 256)
-	n, err := conn.Read(buf)
-	if err != nil || n < 7 {
-		return "", fmt.Errorf("failed to read SOCKS5 request")
+// Returns the target address in "host:port" format. The request is read
+// field by field, so fragmented requests and domain names of any valid
+// length are handled, and domain names are restricted to hostname characters
+// because they come straight from untrusted containers.
+func (s *SOCKSProxy) parseSOCKS5Request(r io.Reader) (string, error) {
+	// Read request header: VER CMD RSV ATYP
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", fmt.Errorf("failed to read SOCKS5 request: %w", err)
 	}
 
 	// Check SOCKS version and command
-	if buf[0] != 0x05 {
+	if header[0] != 0x05 {
 		return "", fmt.Errorf("invalid SOCKS version")
 	}
-	if buf[1] != 0x01 { // CONNECT command
-		return "", fmt.Errorf("unsupported SOCKS command: %d", buf[1])
+	if header[1] != 0x01 { // CONNECT command
+		return "", fmt.Errorf("unsupported SOCKS command: %d", header[1])
 	}
 
 	// Parse address
-	addrType := buf[3]
 	var host string
-	var port uint16
 
-	switch addrType {
+	switch addrType := header[3]; addrType {
 	case 0x01: // IPv4
-		if n < 10 {
+		addr := make([]byte, net.IPv4len)
+		if _, err := io.ReadFull(r, addr); err != nil {
 			return "", fmt.Errorf("invalid IPv4 address length")
 		}
-		host = fmt.Sprintf("%d.%d.%d.%d", buf[4], buf[5], buf[6], buf[7])
-		port = uint16(buf[8])<<8 | uint16(buf[9])
+		host = net.IP(addr).String()
 
 	case 0x03: // Domain name
-		if n < 5 {
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(r, length); err != nil {
 			return "", fmt.Errorf("invalid domain name length")
 		}
-		domainLen := int(buf[4])
-		if n < 7+domainLen {
+		if length[0] == 0 {
+			return "", fmt.Errorf("empty domain name")
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(r, domain); err != nil {
 			return "", fmt.Errorf("incomplete domain name")
 		}
-		host = string(buf[5 : 5+domainLen])
-		port = uint16(buf[5+domainLen])<<8 | uint16(buf[6+domainLen])
+		if !isValidSOCKSDomain(domain) {
+			return "", fmt.Errorf("invalid domain name %q", domain)
+		}
+		host = string(domain)
 
 	case 0x04: // IPv6
-		return "", fmt.Errorf("IPv6 not supported")
+		addr := make([]byte, net.IPv6len)
+		if _, err := io.ReadFull(r, addr); err != nil {
+			return "", fmt.Errorf("invalid IPv6 address length")
+		}
+		host = net.IP(addr).String()
 
 	default:
 		return "", fmt.Errorf("unsupported address type: %d", addrType)
 	}
 
-	return fmt.Sprintf("%s:%d", host, port), nil
+	portBytes := make([]byte, 2)
+	if _, err := io.ReadFull(r, portBytes); err != nil {
+		return "", fmt.Errorf("incomplete port")
+	}
+	port := int(portBytes[0])<<8 | int(portBytes[1])
+
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
+// isValidSOCKSDomain reports whether a requested domain contains only
+// hostname characters.
+func isValidSOCKSDomain(domain []byte) bool {
+	for _, c := range domain {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
 }
 
 // isI2PDestination checks if the target address is an I2P destination.
@@ -278,14 +404,21 @@ func (s *SOCKSProxy) connectToI2P(target string) (net.Conn, error) {
 		return nil, fmt.Errorf("invalid port: %w", err)
 	}
 
+	destination, err := lookupDestination(s.getNamingResolver(), host)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create I2P client tunnel configuration
+	purpose := fmt.Sprintf("client-%s-%d", host, port)
 	tunnelConfig := &i2p.TunnelConfig{
-		Name:        fmt.Sprintf("client-%s-%d", host, port),
+		Name:        i2p.TunnelName("proxy-session", purpose),
+		Purpose:     purpose,
 		ContainerID: "proxy-session",
 		Type:        i2p.TunnelTypeClient,
 		LocalHost:   "127.0.0.1",
 		LocalPort:   0, // Let system assign port
-		Destination: host,
+		Destination: destination,
 		Options:     i2p.DefaultTunnelOptions(),
 	}
 
@@ -353,3 +486,63 @@ func (s *SOCKSProxy) SetTrafficFilter(filter *TrafficFilter) {
 		s.trafficFilter = filter
 	}
 }
+
+// SetSourceResolver sets the resolver used to verify client addresses.
+//
+// A nil resolver accepts connections from any client.
+func (s *SOCKSProxy) SetSourceResolver(resolver SourceResolver) {
+	s.sourceMutex.Lock()
+	defer s.sourceMutex.Unlock()
+
+	s.sourceResolver = resolver
+}
+
+// getSourceResolver returns the resolver used to verify client addresses.
+func (s *SOCKSProxy) getSourceResolver() SourceResolver {
+	s.sourceMutex.RLock()
+	defer s.sourceMutex.RUnlock()
+
+	return s.sourceResolver
+}
+
+// SetSyntheticAddresses sets the table used to map synthetic addresses back
+// to I2P names. It must be called before the proxy serves connections.
+func (s *SOCKSProxy) SetSyntheticAddresses(addresses *SyntheticAddresses) {
+	s.addresses = addresses
+}
+
+// SetNamingResolver sets the resolver used to look up I2P hostnames.
+//
+// A nil resolver hands hostnames to the router unresolved.
+func (s *SOCKSProxy) SetNamingResolver(resolver NamingResolver) {
+	s.namingMutex.Lock()
+	defer s.namingMutex.Unlock()
+
+	s.namingResolver = resolver
+}
+
+// getNamingResolver returns the resolver used to look up I2P hostnames.
+func (s *SOCKSProxy) getNamingResolver() NamingResolver {
+	s.namingMutex.RLock()
+	defer s.namingMutex.RUnlock()
+
+	return s.namingResolver
+}
+
+// SetTargetRewriter sets the rewriter applied to requested targets.
+//
+// A nil rewriter leaves targets unchanged.
+func (s *SOCKSProxy) SetTargetRewriter(rewriter TargetRewriter) {
+	s.targetMutex.Lock()
+	defer s.targetMutex.Unlock()
+
+	s.targetRewriter = rewriter
+}
+
+// getTargetRewriter returns the rewriter applied to requested targets.
+func (s *SOCKSProxy) getTargetRewriter() TargetRewriter {
+	s.targetMutex.RLock()
+	defer s.targetMutex.RUnlock()
+
+	return s.targetRewriter
+}
@@ -0,0 +1,88 @@
+// Package proxy provides client source verification.
+//
+// Proxy listeners are reachable from anything that can route to their
+// address: the host itself, other Docker networks and, for wildcard binds,
+// every subnet the firewall lets through. A source resolver maps the client
+// address of each TCP connection to the endpoint it belongs to, and the SOCKS
+// proxy refuses connections that do not come from a managed endpoint.
+// Sidecar Unix sockets are exempt since access to them is granted by mounting
+// the socket.
+package proxy
+
+import (
+	"fmt"
+	"net"
+)
+
+// ClientSource identifies the endpoint a proxy connection originates from.
+type ClientSource struct {
+	// NetworkID is the network the endpoint belongs to
+	NetworkID string
+	// EndpointID is the endpoint owning the client address
+	EndpointID string
+	// ContainerID is the container using the endpoint (empty before join)
+	ContainerID string
+	// OutboundDisabled is set for inbound-only containers, which may not
+	// open connections through the proxy
+	OutboundDisabled bool
+}
+
+// SourceResolver maps a client IP address to its endpoint.
+//
+// It returns false for addresses that do not belong to a managed endpoint.
+type SourceResolver func(ip net.IP) (ClientSource, bool)
+
+// sourceIP returns the client IP of a connection.
+//
+// It returns nil for connections without an IP address, such as those
+// accepted on Unix sockets.
+func sourceIP(conn net.Conn) net.IP {
+	switch addr := conn.RemoteAddr().(type) {
+	case *net.TCPAddr:
+		return addr.IP
+	case *net.UDPAddr:
+		return addr.IP
+	}
+	return nil
+}
+
+// verifySource checks that a connection originates from a managed endpoint.
+//
+// It returns the client's endpoint (empty if unknown) and a description of
+// the client for traffic logs, or an error when the connection must be
+// refused. Without a resolver, and for connections without an IP address,
+// every client is accepted.
+func verifySource(resolver SourceResolver, conn net.Conn) (ClientSource, string, error) {
+	clientAddr := conn.RemoteAddr().String()
+
+	ip := sourceIP(conn)
+	if resolver == nil || ip == nil {
+		return ClientSource{}, clientAddr, nil
+	}
+
+	source, ok := resolver(ip)
+	if !ok {
+		return source, clientAddr, fmt.Errorf("%s is not an endpoint of a managed I2P network", ip)
+	}
+	if source.OutboundDisabled {
+		return source, clientAddr, fmt.Errorf("%s belongs to endpoint %s, which has outbound I2P disabled", ip, source.EndpointID)
+	}
+	return source, fmt.Sprintf("%s (endpoint %s)", clientAddr, source.EndpointID), nil
+}
+
+// SetSourceResolver sets the resolver verifying proxy clients on all listeners.
+//
+// Listeners started later use the same resolver. A nil resolver accepts
+// connections from any client.
+func (pm *ProxyManager) SetSourceResolver(resolver SourceResolver) {
+	pm.listenerMutex.Lock()
+	defer pm.listenerMutex.Unlock()
+
+	pm.sourceResolver = resolver
+	pm.external.setSourceResolver(resolver)
+	pm.names.setSourceResolver(resolver)
+	pm.socksProxy.SetSourceResolver(resolver)
+	for _, listener := range pm.listeners {
+		listener.socksProxy.SetSourceResolver(resolver)
+	}
+}
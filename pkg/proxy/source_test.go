@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+// startSourceTestProxy serves a SOCKS proxy on a loopback port.
+func startSourceTestProxy(t *testing.T, resolver SourceResolver) (*SOCKSProxy, string) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	proxy := NewSOCKSProxy(SOCKSOptions{
+		ListenAddr:     listener.Addr().String(),
+		TunnelManager:  i2p.NewTunnelManager(i2p.TunnelManagerOptions{}),
+		SourceResolver: resolver,
+	})
+	proxy.listener = listener
+	go proxy.Serve(listener)
+	t.Cleanup(func() { proxy.Stop() })
+
+	return proxy, listener.Addr().String()
+}
+
+// socksGreeting sends a SOCKS5 greeting and returns the server's reply.
+func socksGreeting(t *testing.T, addr string) ([]byte, error) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect to proxy: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if _, err := conn.Write([]byte{0x05, 0x01, 0x00}); err != nil {
+		return nil, err
+	}
+	reply := make([]byte, 2)
+	_, err = io.ReadFull(conn, reply)
+	return reply, err
+}
+
+func TestSOCKSProxy_SourceVerification(t *testing.T) {
+	t.Run("unknown client is rejected", func(t *testing.T) {
+		proxy, addr := startSourceTestProxy(t, func(ip net.IP) (ClientSource, bool) {
+			return ClientSource{}, false
+		})
+
+		if reply, err := socksGreeting(t, addr); err == nil {
+			t.Fatalf("Expected connection to be closed, got reply %v", reply)
+		}
+		if got := proxy.GetTrafficFilter().GetStats().UnknownSourcesRejected; got != 1 {
+			t.Errorf("Expected 1 rejected source, got %d", got)
+		}
+	})
+
+	t.Run("endpoint client is served", func(t *testing.T) {
+		resolved := make(chan net.IP, 1)
+		proxy, addr := startSourceTestProxy(t, func(ip net.IP) (ClientSource, bool) {
+			resolved <- ip
+			return ClientSource{NetworkID: "net1", EndpointID: "ep1"}, true
+		})
+
+		reply, err := socksGreeting(t, addr)
+		if err != nil {
+			t.Fatalf("Expected SOCKS greeting reply, got %v", err)
+		}
+		if reply[0] != 0x05 || reply[1] != 0x00 {
+			t.Errorf("Unexpected greeting reply %v", reply)
+		}
+		if ip := <-resolved; !ip.IsLoopback() {
+			t.Errorf("Expected resolver to see the client address, got %v", ip)
+		}
+		if got := proxy.GetTrafficFilter().GetStats().UnknownSourcesRejected; got != 0 {
+			t.Errorf("Expected no rejected sources, got %d", got)
+		}
+	})
+
+	t.Run("outbound-disabled client is rejected", func(t *testing.T) {
+		proxy, addr := startSourceTestProxy(t, func(ip net.IP) (ClientSource, bool) {
+			return ClientSource{NetworkID: "net1", EndpointID: "ep1", OutboundDisabled: true}, true
+		})
+
+		if reply, err := socksGreeting(t, addr); err == nil {
+			t.Fatalf("Expected connection to be closed, got reply %v", reply)
+		}
+		if got := proxy.GetTrafficFilter().GetStats().UnknownSourcesRejected; got != 1 {
+			t.Errorf("Expected 1 rejected source, got %d", got)
+		}
+	})
+
+	t.Run("no resolver accepts all clients", func(t *testing.T) {
+		_, addr := startSourceTestProxy(t, nil)
+
+		if _, err := socksGreeting(t, addr); err != nil {
+			t.Errorf("Expected SOCKS greeting reply, got %v", err)
+		}
+	})
+}
+
+func TestProxyManager_SetSourceResolver(t *testing.T) {
+	t.Parallel()
+
+	pm, _ := newBindTestManager(t)
+	resolver := func(ip net.IP) (ClientSource, bool) { return ClientSource{}, false }
+
+	pm.SetSourceResolver(resolver)
+	if pm.socksProxy.getSourceResolver() == nil {
+		t.Error("Expected resolver to be applied to the fixed SOCKS proxy")
+	}
+
+	pm.SetSourceResolver(nil)
+	if pm.socksProxy.getSourceResolver() != nil {
+		t.Error("Expected resolver to be cleared")
+	}
+}
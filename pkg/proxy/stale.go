@@ -0,0 +1,238 @@
+// Package proxy provides recovery from firewall chains left behind by a
+// previous instance.
+//
+// A plugin that crashes or is killed cannot remove its iptables and ebtables
+// chains, and creating them again on the next start failed. Chains holding
+// per-endpoint rules describe endpoints of the previous instance, so any
+// leftover is removed before the chain is created again. The interception
+// chains may still be protecting containers that kept running; by default
+// (StaleChainsReconcile) a leftover one is renamed, the current rules are
+// installed next to it and only then is it removed together with every jump
+// to it, so containers are never left unfiltered. StaleChainsFlush removes
+// leftovers before installing the current rules instead.
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// Leftover chain handling modes selected with STALE_CHAINS.
+const (
+	// StaleChainsReconcile replaces leftover interception chains without a
+	// gap in filtering (default)
+	StaleChainsReconcile = "reconcile"
+	// StaleChainsFlush removes leftover interception chains before creating
+	// them again
+	StaleChainsFlush = "flush"
+)
+
+// retiredChainSuffix is appended to the names of leftover interception
+// chains while they are being replaced.
+const retiredChainSuffix = "_STALE"
+
+// firewallChain is a chain created by the plugin.
+type firewallChain struct {
+	// command is iptables, ip6tables or ebtables
+	command string
+	// table is the table holding the chain
+	table string
+	// name is the chain name
+	name string
+	// hooks are the built-in chains jumping to the chain
+	hooks []string
+}
+
+// String describes the chain for log messages.
+func (c firewallChain) String() string {
+	return fmt.Sprintf("%s chain %s (table %s)", c.command, c.name, c.table)
+}
+
+// retired returns the chain under the name it has while being replaced.
+func (c firewallChain) retired() firewallChain {
+	c.name += retiredChainSuffix
+	return c
+}
+
+// interceptionChains are the chains installed by SetupInterception.
+var interceptionChains = []firewallChain{
+	{command: "iptables", table: "nat", name: "I2P_REDIRECT", hooks: []string{"FORWARD"}},
+	{command: "iptables", table: "filter", name: "I2P_FILTER", hooks: []string{"FORWARD"}},
+	{command: "ip6tables", table: "nat", name: "I2P_REDIRECT6", hooks: []string{"PREROUTING"}},
+}
+
+// Chains holding per-endpoint rules, created with their first rule.
+var (
+	antiSpoofIptablesChain  = firewallChain{command: "iptables", table: "filter", name: antiSpoofChain, hooks: []string{"INPUT", "FORWARD"}}
+	antiSpoofEbtablesChain  = firewallChain{command: "ebtables", table: "filter", name: antiSpoofChain, hooks: []string{"INPUT", "FORWARD"}}
+	isolationEbtablesChain  = firewallChain{command: "ebtables", table: "filter", name: isolationChain, hooks: []string{"FORWARD"}}
+	proxyInputIptablesChain = firewallChain{command: "iptables", table: "filter", name: proxyInputChain, hooks: []string{"INPUT"}}
+	noOutboundIptablesChain = firewallChain{command: "iptables", table: "filter", name: noOutboundChain, hooks: []string{"INPUT"}}
+	egressIptablesChain     = firewallChain{command: "iptables", table: "filter", name: egressChain, hooks: []string{"FORWARD"}}
+	egressIp6tablesChain    = firewallChain{command: "ip6tables", table: "filter", name: egressChain, hooks: []string{"FORWARD"}}
+)
+
+// SetStaleChainMode selects how interception chains left behind by a
+// previous instance are handled when the proxy starts: StaleChainsReconcile
+// (default) or StaleChainsFlush.
+func (pm *ProxyManager) SetStaleChainMode(mode string) error {
+	switch mode {
+	case StaleChainsReconcile, StaleChainsFlush:
+	default:
+		return fmt.Errorf("stale chain mode must be %s or %s, got %q", StaleChainsReconcile, StaleChainsFlush, mode)
+	}
+	pm.config.StaleChains = mode
+	return nil
+}
+
+// prepareInterceptionChains clears the way for SetupInterception.
+//
+// In reconcile mode leftover interception chains are renamed and returned,
+// to be removed with removeRetiredChains once the current rules are in
+// place. In flush mode they are removed right away.
+func (pm *ProxyManager) prepareInterceptionChains() ([]firewallChain, error) {
+	var retired []firewallChain
+	for _, chain := range interceptionChains {
+		// An instance may have crashed while replacing its chains
+		if err := pm.removeLeftoverChain(chain.retired()); err != nil {
+			pm.restoreRetiredChains(retired)
+			return nil, err
+		}
+		if !pm.chainExists(chain) {
+			continue
+		}
+
+		if pm.config.StaleChains == StaleChainsFlush {
+			if err := pm.removeLeftoverChain(chain); err != nil {
+				pm.restoreRetiredChains(retired)
+				return nil, err
+			}
+			continue
+		}
+
+		rename := fmt.Sprintf("-t %s -E %s %s", chain.table, chain.name, chain.retired().name)
+		if err := pm.chainRunner(chain)(rename); err != nil {
+			pm.restoreRetiredChains(retired)
+			return nil, fmt.Errorf("failed to rename leftover %s: %w", chain, err)
+		}
+		retired = append(retired, chain)
+	}
+	return retired, nil
+}
+
+// removeRetiredChains removes the renamed leftovers of chains that have been
+// replaced.
+func (pm *ProxyManager) removeRetiredChains(chains []firewallChain) {
+	for _, chain := range chains {
+		if errors := pm.removeChain(chain.retired()); len(errors) > 0 {
+			log.Printf("Warning: Failed to remove leftover %s: %s", chain.retired(), strings.Join(errors, "; "))
+			continue
+		}
+		log.Printf("Replaced leftover %s from a previous instance", chain)
+	}
+}
+
+// restoreRetiredChains renames leftover chains back when they could not be
+// replaced, so their rules keep protecting containers.
+func (pm *ProxyManager) restoreRetiredChains(chains []firewallChain) {
+	for _, chain := range chains {
+		rename := fmt.Sprintf("-t %s -E %s %s", chain.table, chain.retired().name, chain.name)
+		if err := pm.chainRunner(chain)(rename); err != nil {
+			log.Printf("Warning: Failed to restore leftover %s: %v", chain, err)
+		}
+	}
+}
+
+// removeLeftoverChain removes a chain left behind by a previous instance
+// before it is created again. Nothing is done if the chain does not exist.
+func (pm *ProxyManager) removeLeftoverChain(chain firewallChain) error {
+	if !pm.chainExists(chain) {
+		return nil
+	}
+	if errors := pm.removeChain(chain); len(errors) > 0 {
+		return fmt.Errorf("failed to remove leftover %s: %s", chain, strings.Join(errors, "; "))
+	}
+	log.Printf("Removed leftover %s from a previous instance", chain)
+	return nil
+}
+
+// removeChain deletes every jump to a chain from its hooks, then flushes and
+// deletes it, returning any errors.
+//
+// Jumps are found by listing the hooks, which also catches duplicates and
+// jumps installed for other subnets.
+func (pm *ProxyManager) removeChain(chain firewallChain) []string {
+	run := pm.chainRunner(chain)
+
+	var errors []string
+	for _, hook := range chain.hooks {
+		rules, err := pm.listRules(chain.command, chain.table, hook)
+		if err != nil {
+			continue
+		}
+		for _, rule := range rules {
+			rule = strings.TrimSpace(rule)
+			if !jumpsTo(rule, chain.name) {
+				continue
+			}
+			spec := strings.TrimPrefix(rule, "-A "+hook+" ")
+			if err := run(fmt.Sprintf("-t %s -D %s %s", chain.table, hook, spec)); err != nil {
+				errors = append(errors, err.Error())
+			}
+		}
+	}
+
+	for _, rule := range []string{"-t " + chain.table + " -F " + chain.name, "-t " + chain.table + " -X " + chain.name} {
+		if err := run(rule); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	return errors
+}
+
+// chainExists reports whether a chain exists.
+func (pm *ProxyManager) chainExists(chain firewallChain) bool {
+	_, err := pm.listRules(chain.command, chain.table, chain.name)
+	return err == nil
+}
+
+// chainRunner returns the function executing rules for a chain's command.
+func (pm *ProxyManager) chainRunner(chain firewallChain) func(rule string) error {
+	switch chain.command {
+	case "ebtables":
+		return pm.runEbtables
+	case "ip6tables":
+		return pm.runIp6tables
+	default:
+		return pm.runIptables
+	}
+}
+
+// jumpsTo reports whether a listed rule jumps to the named chain.
+func jumpsTo(rule, chain string) bool {
+	fields := strings.Fields(rule)
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] == "-j" && fields[i+1] == chain {
+			return true
+		}
+	}
+	return false
+}
+
+// listChainRules lists the rules of a chain, failing if it does not exist.
+//
+// iptables and ip6tables list rules in the syntax they were added with;
+// ebtables lists them without the -A and chain name.
+func listChainRules(command, table, chain string) ([]string, error) {
+	list := "-S"
+	if command == "ebtables" {
+		list = "-L"
+	}
+	output, err := exec.Command(command, "-t", table, list, chain).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s command failed: %s (output: %s)", command, err, string(output))
+	}
+	return strings.Split(strings.TrimSpace(string(output)), "\n"), nil
+}
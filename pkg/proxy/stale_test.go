@@ -0,0 +1,215 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+// newStaleTestManager returns a manager whose firewall holds the given
+// chains, keyed by "command table chain", and a log of the commands run.
+func newStaleTestManager(t *testing.T, chains map[string][]string) (*ProxyManager, *[]string) {
+	t.Helper()
+
+	pm, _ := newBindTestManager(t)
+	var commands []string
+	record := func(command string) func(string) error {
+		return func(rule string) error {
+			commands = append(commands, command+" "+rule)
+			return nil
+		}
+	}
+	pm.runIptables = record("iptables")
+	pm.runIp6tables = record("ip6tables")
+	pm.runEbtables = record("ebtables")
+	pm.listRules = func(command, table, chain string) ([]string, error) {
+		rules, exists := chains[command+" "+table+" "+chain]
+		if !exists {
+			return nil, errors.New("no chain by that name")
+		}
+		return rules, nil
+	}
+	return pm, &commands
+}
+
+func TestProxyManager_RemoveLeftoverChain(t *testing.T) {
+	pm, commands := newStaleTestManager(t, map[string][]string{
+		"iptables filter INPUT": {
+			"-P INPUT ACCEPT",
+			"-A INPUT -j I2P_ANTISPOOF",
+			"-A INPUT -j I2P_ANTISPOOF",
+			"-A INPUT -s 10.0.0.0/8 -j ACCEPT",
+		},
+		"iptables filter FORWARD": {
+			"-P FORWARD DROP",
+			"-A FORWARD -j I2P_ANTISPOOF",
+		},
+		"iptables filter I2P_ANTISPOOF": {"-N I2P_ANTISPOOF"},
+	})
+
+	if err := pm.removeLeftoverChain(antiSpoofIptablesChain); err != nil {
+		t.Fatalf("Failed to remove leftover chain: %v", err)
+	}
+	expected := []string{
+		"iptables -t filter -D INPUT -j I2P_ANTISPOOF",
+		"iptables -t filter -D INPUT -j I2P_ANTISPOOF",
+		"iptables -t filter -D FORWARD -j I2P_ANTISPOOF",
+		"iptables -t filter -F I2P_ANTISPOOF",
+		"iptables -t filter -X I2P_ANTISPOOF",
+	}
+	if fmt.Sprint(*commands) != fmt.Sprint(expected) {
+		t.Errorf("Unexpected commands:\n got %v\nwant %v", *commands, expected)
+	}
+
+	*commands = nil
+	if err := pm.removeLeftoverChain(noOutboundIptablesChain); err != nil {
+		t.Fatalf("Expected a missing chain to be ignored, got %v", err)
+	}
+	if len(*commands) != 0 {
+		t.Errorf("Expected no commands for a missing chain, got %v", *commands)
+	}
+}
+
+func TestProxyManager_RemoveLeftoverEbtablesChain(t *testing.T) {
+	pm, commands := newStaleTestManager(t, map[string][]string{
+		"ebtables filter FORWARD": {
+			"Bridge table: filter",
+			"",
+			"Bridge chain: FORWARD, entries: 1, policy: ACCEPT",
+			"-j I2P_ISOLATE ",
+		},
+		"ebtables filter I2P_ISOLATE": {"Bridge chain: I2P_ISOLATE, entries: 0, policy: RETURN"},
+	})
+
+	_, subnet, _ := net.ParseCIDR("172.20.0.0/16")
+	if err := pm.IsolateNetwork("net1", subnet); err != nil {
+		t.Fatalf("Failed to isolate network: %v", err)
+	}
+	expected := []string{
+		"ebtables -t filter -D FORWARD -j I2P_ISOLATE",
+		"ebtables -t filter -F I2P_ISOLATE",
+		"ebtables -t filter -X I2P_ISOLATE",
+		"ebtables -t filter -N I2P_ISOLATE",
+	}
+	if fmt.Sprint((*commands)[:4]) != fmt.Sprint(expected) {
+		t.Errorf("Expected the leftover chain to be removed before creating it:\n got %v\nwant %v", *commands, expected)
+	}
+}
+
+func TestProxyManager_PrepareInterceptionChainsReconcile(t *testing.T) {
+	pm, commands := newStaleTestManager(t, map[string][]string{
+		"iptables filter I2P_FILTER": {"-N I2P_FILTER", "-A I2P_FILTER -s 172.21.0.0/16 -j DROP"},
+		// Renamed chains keep their jumps
+		"iptables filter FORWARD": {
+			"-P FORWARD ACCEPT",
+			"-A FORWARD -s 172.21.0.0/16 -j I2P_FILTER_STALE",
+			"-A FORWARD -s 172.21.0.0/16 -j I2P_FILTER_STALE",
+			"-A FORWARD -s 172.20.0.0/16 -j I2P_FILTER",
+		},
+	})
+
+	retired, err := pm.prepareInterceptionChains()
+	if err != nil {
+		t.Fatalf("Failed to prepare chains: %v", err)
+	}
+	if len(retired) != 1 || retired[0].name != "I2P_FILTER" {
+		t.Fatalf("Expected I2P_FILTER to be retired, got %v", retired)
+	}
+	expected := []string{"iptables -t filter -E I2P_FILTER I2P_FILTER_STALE"}
+	if fmt.Sprint(*commands) != fmt.Sprint(expected) {
+		t.Errorf("Unexpected commands:\n got %v\nwant %v", *commands, expected)
+	}
+
+	*commands = nil
+	pm.removeRetiredChains(retired)
+	expected = []string{
+		"iptables -t filter -D FORWARD -s 172.21.0.0/16 -j I2P_FILTER_STALE",
+		"iptables -t filter -D FORWARD -s 172.21.0.0/16 -j I2P_FILTER_STALE",
+		"iptables -t filter -F I2P_FILTER_STALE",
+		"iptables -t filter -X I2P_FILTER_STALE",
+	}
+	if fmt.Sprint(*commands) != fmt.Sprint(expected) {
+		t.Errorf("Unexpected commands:\n got %v\nwant %v", *commands, expected)
+	}
+
+	*commands = nil
+	pm.restoreRetiredChains(retired)
+	expected = []string{"iptables -t filter -E I2P_FILTER_STALE I2P_FILTER"}
+	if fmt.Sprint(*commands) != fmt.Sprint(expected) {
+		t.Errorf("Unexpected commands:\n got %v\nwant %v", *commands, expected)
+	}
+}
+
+func TestProxyManager_PrepareInterceptionChainsFlush(t *testing.T) {
+	pm, commands := newStaleTestManager(t, map[string][]string{
+		"iptables nat I2P_REDIRECT":   {"-N I2P_REDIRECT"},
+		"iptables nat FORWARD":        {"-A FORWARD -s 172.20.0.0/16 -j I2P_REDIRECT"},
+		"ip6tables nat I2P_REDIRECT6": {"-N I2P_REDIRECT6"},
+		"ip6tables nat PREROUTING":    {"-A PREROUTING -d fd69:3270:b32::/48 -j I2P_REDIRECT6"},
+	})
+	if err := pm.SetStaleChainMode(StaleChainsFlush); err != nil {
+		t.Fatalf("Failed to set mode: %v", err)
+	}
+
+	retired, err := pm.prepareInterceptionChains()
+	if err != nil {
+		t.Fatalf("Failed to prepare chains: %v", err)
+	}
+	if len(retired) != 0 {
+		t.Errorf("Expected no retired chains in flush mode, got %v", retired)
+	}
+	expected := []string{
+		"iptables -t nat -D FORWARD -s 172.20.0.0/16 -j I2P_REDIRECT",
+		"iptables -t nat -F I2P_REDIRECT",
+		"iptables -t nat -X I2P_REDIRECT",
+		"ip6tables -t nat -D PREROUTING -d fd69:3270:b32::/48 -j I2P_REDIRECT6",
+		"ip6tables -t nat -F I2P_REDIRECT6",
+		"ip6tables -t nat -X I2P_REDIRECT6",
+	}
+	if fmt.Sprint(*commands) != fmt.Sprint(expected) {
+		t.Errorf("Unexpected commands:\n got %v\nwant %v", *commands, expected)
+	}
+
+	if err := pm.SetStaleChainMode("keep"); err == nil {
+		t.Error("Expected an unknown mode to be rejected")
+	}
+}
+
+func TestProxyManager_PrepareInterceptionChainsRenameFailure(t *testing.T) {
+	pm, commands := newStaleTestManager(t, map[string][]string{
+		"iptables nat I2P_REDIRECT":  {"-N I2P_REDIRECT"},
+		"iptables filter I2P_FILTER": {"-N I2P_FILTER"},
+	})
+	pm.runIptables = func(rule string) error {
+		*commands = append(*commands, "iptables "+rule)
+		if rule == "-t filter -E I2P_FILTER I2P_FILTER_STALE" {
+			return errors.New("permission denied")
+		}
+		return nil
+	}
+
+	if _, err := pm.prepareInterceptionChains(); err == nil {
+		t.Fatal("Expected preparing chains to fail")
+	}
+	expected := []string{
+		"iptables -t nat -E I2P_REDIRECT I2P_REDIRECT_STALE",
+		"iptables -t filter -E I2P_FILTER I2P_FILTER_STALE",
+		"iptables -t nat -E I2P_REDIRECT_STALE I2P_REDIRECT",
+	}
+	if fmt.Sprint(*commands) != fmt.Sprint(expected) {
+		t.Errorf("Expected the renamed chain to be restored:\n got %v\nwant %v", *commands, expected)
+	}
+}
+
+func TestUndoRule(t *testing.T) {
+	tests := map[string]string{
+		"-t nat -N I2P_REDIRECT":                              "-t nat -X I2P_REDIRECT",
+		"-t filter -A FORWARD -s 172.20.0.0/16 -j I2P_FILTER": "-t filter -D FORWARD -s 172.20.0.0/16 -j I2P_FILTER",
+	}
+	for rule, expected := range tests {
+		if undo := undoRule(rule); undo != expected {
+			t.Errorf("undoRule(%q) = %q, want %q", rule, undo, expected)
+		}
+	}
+}
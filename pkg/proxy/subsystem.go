@@ -0,0 +1,224 @@
+// Package proxy provides stopping and starting the proxy services on their own.
+//
+// The SOCKS proxy and the DNS resolver can each be stopped while the plugin
+// keeps running, e.g. to recover a wedged resolver, without removing the
+// interception and firewall rules or any network's state. A stopped service
+// is replaced by a fresh instance that is not listening, so configuration
+// changes still reach it and starting it again binds every listener anew.
+// Listeners of networks added while a service is stopped start without it.
+// Sidecar sockets are per-endpoint opt-ins and keep serving.
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+// newSOCKSProxy creates a SOCKS proxy for addr sharing the manager's filter,
+// resolvers, target rewriter, synthetic addresses and external services. The caller must hold
+// pm.listenerMutex.
+func (pm *ProxyManager) newSOCKSProxy(addr string) *SOCKSProxy {
+	return NewSOCKSProxy(SOCKSOptions{
+		ListenAddr:     addr,
+		TunnelManager:  pm.tunnelManager,
+		TrafficFilter:  pm.trafficFilter,
+		SourceResolver: pm.sourceResolver,
+		NamingResolver: pm.namingResolver,
+		Addresses:      pm.addresses,
+		TargetRewriter: pm.targetRewriter,
+		External:       pm.external,
+	})
+}
+
+// newDNSResolver creates a DNS resolver for addr sharing the manager's naming
+// resolver, synthetic addresses, query limits, external services and
+// container names. The caller must hold pm.listenerMutex.
+func (pm *ProxyManager) newDNSResolver(addr string) *I2PDNSResolver {
+	resolver := NewI2PDNSResolver(addr)
+	resolver.SetNamingResolver(pm.namingResolver)
+	resolver.SetSyntheticAddresses(pm.addresses)
+	resolver.shedder = pm.dnsShedder
+	resolver.external = pm.external
+	resolver.names = pm.names
+	return resolver
+}
+
+// serveSOCKSProxy binds a SOCKS proxy's listen address and serves it in the
+// background.
+func serveSOCKSProxy(socksProxy *SOCKSProxy) error {
+	listener, err := net.Listen("tcp", socksProxy.listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socksProxy.listenAddr, err)
+	}
+
+	socksProxy.listener = listener
+	go func() {
+		if err := socksProxy.Serve(listener); err != nil {
+			log.Printf("Warning: SOCKS proxy on %s stopped: %v", socksProxy.listenAddr, err)
+		}
+	}()
+	return nil
+}
+
+// serveDNSResolver binds a DNS resolver's listen address and serves it in the
+// background.
+func serveDNSResolver(resolver *I2PDNSResolver) error {
+	if err := resolver.Listen(); err != nil {
+		return err
+	}
+
+	go func() {
+		if err := resolver.Serve(); err != nil {
+			log.Printf("Warning: DNS resolver on %s stopped: %v", resolver.listenAddr, err)
+		}
+	}()
+	return nil
+}
+
+// SOCKSRunning reports whether the SOCKS proxy serves connections, i.e. it
+// has not been stopped with StopSOCKS.
+func (pm *ProxyManager) SOCKSRunning() bool {
+	pm.listenerMutex.Lock()
+	defer pm.listenerMutex.Unlock()
+
+	return !pm.socksStopped
+}
+
+// DNSRunning reports whether the DNS resolver answers queries, i.e. it has
+// not been stopped with StopDNS.
+func (pm *ProxyManager) DNSRunning() bool {
+	pm.listenerMutex.Lock()
+	defer pm.listenerMutex.Unlock()
+
+	return !pm.dnsStopped
+}
+
+// StopSOCKS stops the SOCKS proxy on every listener.
+//
+// Intercepted connections are refused until StartSOCKS is called. Stopping
+// a stopped proxy does nothing.
+func (pm *ProxyManager) StopSOCKS() error {
+	pm.listenerMutex.Lock()
+	defer pm.listenerMutex.Unlock()
+
+	if pm.socksStopped {
+		return nil
+	}
+	pm.socksStopped = true
+
+	var errors []string
+	for _, listener := range pm.listeners {
+		if err := listener.socksProxy.Stop(); err != nil {
+			errors = append(errors, fmt.Sprintf("SOCKS proxy on %s: %v", listener.bindIP, err))
+		}
+		listener.socksProxy = pm.newSOCKSProxy(listener.socksProxy.listenAddr)
+	}
+	if pm.config.SOCKSBindAddr != "" {
+		if err := pm.socksProxy.Stop(); err != nil {
+			errors = append(errors, fmt.Sprintf("SOCKS proxy on %s: %v", pm.config.SOCKSBindAddr, err))
+		}
+		pm.socksProxy = pm.newSOCKSProxy(pm.config.SOCKSBindAddr)
+	}
+
+	log.Printf("Stopped SOCKS proxy on %d listeners", len(pm.listeners))
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to stop SOCKS proxy: %s", strings.Join(errors, "; "))
+	}
+	return nil
+}
+
+// StartSOCKS starts the SOCKS proxy stopped with StopSOCKS on every listener.
+//
+// Listeners that fail to bind are reported but do not keep the others from
+// starting. Starting a running proxy does nothing.
+func (pm *ProxyManager) StartSOCKS() error {
+	pm.listenerMutex.Lock()
+	defer pm.listenerMutex.Unlock()
+
+	if !pm.socksStopped {
+		return nil
+	}
+	pm.socksStopped = false
+
+	var errors []string
+	for _, listener := range pm.listeners {
+		if err := serveSOCKSProxy(listener.socksProxy); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	if pm.config.SOCKSBindAddr != "" && pm.IsRunning() {
+		pm.startFixedSOCKS()
+	}
+
+	log.Printf("Started SOCKS proxy on %d listeners", len(pm.listeners))
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to start SOCKS proxy: %s", strings.Join(errors, "; "))
+	}
+	return nil
+}
+
+// StopDNS stops the DNS resolver on every listener.
+//
+// Containers cannot resolve I2P names until StartDNS is called. Stopping a
+// stopped resolver does nothing.
+func (pm *ProxyManager) StopDNS() error {
+	pm.listenerMutex.Lock()
+	defer pm.listenerMutex.Unlock()
+
+	if pm.dnsStopped {
+		return nil
+	}
+	pm.dnsStopped = true
+
+	var errors []string
+	for _, listener := range pm.listeners {
+		if err := listener.dnsResolver.Stop(); err != nil {
+			errors = append(errors, fmt.Sprintf("DNS resolver on %s: %v", listener.bindIP, err))
+		}
+		listener.dnsResolver = pm.newDNSResolver(listener.dnsResolver.listenAddr)
+	}
+	if pm.config.DNSBindAddr != "" {
+		if err := pm.dnsResolver.Stop(); err != nil {
+			errors = append(errors, fmt.Sprintf("DNS resolver on %s: %v", pm.config.DNSBindAddr, err))
+		}
+		pm.dnsResolver = pm.newDNSResolver(pm.config.DNSBindAddr)
+	}
+
+	log.Printf("Stopped DNS resolver on %d listeners", len(pm.listeners))
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to stop DNS resolver: %s", strings.Join(errors, "; "))
+	}
+	return nil
+}
+
+// StartDNS starts the DNS resolver stopped with StopDNS on every listener.
+//
+// Listeners that fail to bind are reported but do not keep the others from
+// starting. Starting a running resolver does nothing.
+func (pm *ProxyManager) StartDNS() error {
+	pm.listenerMutex.Lock()
+	defer pm.listenerMutex.Unlock()
+
+	if !pm.dnsStopped {
+		return nil
+	}
+	pm.dnsStopped = false
+
+	var errors []string
+	for _, listener := range pm.listeners {
+		if err := serveDNSResolver(listener.dnsResolver); err != nil {
+			errors = append(errors, err.Error())
+		}
+	}
+	if pm.config.DNSBindAddr != "" && pm.IsRunning() {
+		pm.startFixedDNS()
+	}
+
+	log.Printf("Started DNS resolver on %d listeners", len(pm.listeners))
+	if len(errors) > 0 {
+		return fmt.Errorf("failed to start DNS resolver: %s", strings.Join(errors, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+)
+
+func TestProxyManager_StopStartSOCKSAndDNS(t *testing.T) {
+	t.Parallel()
+
+	pm, _ := newBindTestManager(t)
+	_, subnet, _ := net.ParseCIDR("172.30.0.0/16")
+	loopback := net.ParseIP("127.0.0.1")
+
+	if err := pm.AddNetworkListeners("net1", subnet, loopback); err != nil {
+		t.Fatalf("Failed to add listeners: %v", err)
+	}
+	listener := pm.listeners[pm.networkBinds["net1"]]
+
+	if err := pm.StopSOCKS(); err != nil {
+		t.Fatalf("StopSOCKS() unexpected error: %v", err)
+	}
+	if err := pm.StopSOCKS(); err != nil {
+		t.Errorf("Stopping a stopped SOCKS proxy should not fail: %v", err)
+	}
+	if pm.SOCKSRunning() || listener.socksProxy.listener != nil {
+		t.Error("Expected the SOCKS proxy to be stopped")
+	}
+	if !pm.DNSRunning() || listener.dnsResolver.server == nil {
+		t.Error("Expected the DNS resolver to keep running")
+	}
+
+	// Networks added while the proxy is stopped start without it
+	_, subnet2, _ := net.ParseCIDR("172.31.0.0/16")
+	if err := pm.AddNetworkListeners("net2", subnet2, net.ParseIP("127.0.0.2")); err != nil {
+		t.Fatalf("Failed to add listeners: %v", err)
+	}
+	listener2 := pm.listeners[pm.networkBinds["net2"]]
+	if listener2.socksProxy.listener != nil {
+		t.Error("Expected no SOCKS proxy on a network added while it is stopped")
+	}
+
+	if err := pm.StartSOCKS(); err != nil {
+		t.Fatalf("StartSOCKS() unexpected error: %v", err)
+	}
+	if !pm.SOCKSRunning() || listener.socksProxy.listener == nil || listener2.socksProxy.listener == nil {
+		t.Error("Expected the SOCKS proxy to run on every listener")
+	}
+
+	if err := pm.StopDNS(); err != nil {
+		t.Fatalf("StopDNS() unexpected error: %v", err)
+	}
+	if pm.DNSRunning() || listener.dnsResolver.server != nil {
+		t.Error("Expected the DNS resolver to be stopped")
+	}
+	if err := pm.StartDNS(); err != nil {
+		t.Fatalf("StartDNS() unexpected error: %v", err)
+	}
+	if !pm.DNSRunning() || listener.dnsResolver.server == nil || listener2.dnsResolver.server == nil {
+		t.Error("Expected the DNS resolver to run on every listener")
+	}
+}
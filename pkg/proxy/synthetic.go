@@ -0,0 +1,181 @@
+// Package proxy provides synthetic addresses for I2P names.
+//
+// The DNS resolver answers I2P names with addresses derived from a hash of the
+// name, and the SOCKS proxy maps those addresses back to the name when a
+// client connects by address. The IPv4 range 198.18.0.0/15 only leaves room
+// for tens of thousands of names, so large address books collide; in IPv6
+// mode names are answered from a unique local (ULA) /48 instead, whose 80-bit
+// interface space makes collisions practically impossible.
+package proxy
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Synthetic address modes selected with DNS_ADDRESS_MODE.
+const (
+	// AddressModeIPv4 answers A queries from SyntheticIPRange (default)
+	AddressModeIPv4 = "ipv4"
+	// AddressModeIPv6 answers AAAA queries from SyntheticIPv6Range
+	AddressModeIPv6 = "ipv6"
+)
+
+// SyntheticIPv6Range is the ULA prefix the DNS resolver answers I2P names
+// from in IPv6 mode.
+const SyntheticIPv6Range = "fd69:3270:b32::/48"
+
+// maxSyntheticNames caps the names remembered for reverse lookups; the oldest
+// are forgotten first.
+const maxSyntheticNames = 65536
+
+// SyntheticAddresses remembers which I2P name each synthetic address was
+// handed out for.
+//
+// One table is shared by every DNS resolver and SOCKS proxy of a proxy
+// manager, so an address resolved on one network's listener can be used on
+// another's.
+type SyntheticAddresses struct {
+	// mode is AddressModeIPv4 or AddressModeIPv6
+	mode string
+	// names maps synthetic addresses to I2P names
+	names map[string]string
+	// order lists the addresses in names, oldest first
+	order []string
+	// mutex protects mode, names and order
+	mutex sync.RWMutex
+}
+
+// NewSyntheticAddresses creates an address table for mode.
+//
+// An empty mode selects AddressModeIPv4.
+func NewSyntheticAddresses(mode string) (*SyntheticAddresses, error) {
+	addresses := &SyntheticAddresses{names: make(map[string]string)}
+	if err := addresses.SetMode(mode); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// ValidateAddressMode checks that mode is a supported synthetic address mode.
+func ValidateAddressMode(mode string) error {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", AddressModeIPv4, AddressModeIPv6:
+		return nil
+	}
+	return fmt.Errorf("invalid DNS address mode %q (expected %s or %s)", mode, AddressModeIPv4, AddressModeIPv6)
+}
+
+// SetMode selects the address family names are answered with.
+//
+// Addresses handed out before keep mapping to their names.
+func (s *SyntheticAddresses) SetMode(mode string) error {
+	if err := ValidateAddressMode(mode); err != nil {
+		return err
+	}
+
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode == "" {
+		mode = AddressModeIPv4
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.mode = mode
+	return nil
+}
+
+// Mode returns the address family names are answered with.
+func (s *SyntheticAddresses) Mode() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.mode
+}
+
+// Lookup returns the I2P name a synthetic address was handed out for.
+func (s *SyntheticAddresses) Lookup(ip net.IP) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	name, ok := s.names[ip.String()]
+	return name, ok
+}
+
+// record remembers that ip was handed out for name.
+//
+// In IPv4 mode a colliding name takes over the address.
+func (s *SyntheticAddresses) record(ip net.IP, name string) {
+	key := ip.String()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.names[key]; !exists {
+		if len(s.order) >= maxSyntheticNames {
+			delete(s.names, s.order[0])
+			s.order = s.order[1:]
+		}
+		s.order = append(s.order, key)
+	}
+	s.names[key] = name
+}
+
+// generateI2PIPv6 derives the ULA address of an I2P domain.
+//
+// The 80 bits after the /48 prefix come from the SHA-256 of the domain.
+func generateI2PIPv6(domain string) net.IP {
+	_, prefix, _ := net.ParseCIDR(SyntheticIPv6Range)
+	sum := sha256.Sum256([]byte(domain))
+
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, prefix.IP)
+	copy(ip[6:], sum[:10])
+	return ip
+}
+
+// SetAddressMode selects the synthetic addresses I2P names resolve to.
+//
+// The ip6tables rules for IPv6 addresses are installed when the proxy starts
+// with the first network, so the mode should be set before any network is
+// created.
+func (pm *ProxyManager) SetAddressMode(mode string) error {
+	if err := pm.addresses.SetMode(mode); err != nil {
+		return err
+	}
+	pm.interceptor.syntheticIPv6 = pm.addresses.Mode() == AddressModeIPv6
+	return nil
+}
+
+// AddressMode returns the address family I2P names resolve to.
+func (pm *ProxyManager) AddressMode() string {
+	return pm.addresses.Mode()
+}
+
+// translateSyntheticTarget replaces a synthetic address in a host:port target
+// with the I2P name it was handed out for.
+//
+// Targets that are not synthetic addresses are returned unchanged.
+func translateSyntheticTarget(addresses *SyntheticAddresses, target string) string {
+	if addresses == nil {
+		return target
+	}
+
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return target
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return target
+	}
+
+	if name, ok := addresses.Lookup(ip); ok {
+		return net.JoinHostPort(name, port)
+	}
+	return target
+}
@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestGenerateI2PIPv6(t *testing.T) {
+	_, prefix, _ := net.ParseCIDR(SyntheticIPv6Range)
+
+	ip := generateI2PIPv6("example.i2p")
+	if !prefix.Contains(ip) {
+		t.Errorf("Expected %s to be within %s", ip, SyntheticIPv6Range)
+	}
+	if !ip.Equal(generateI2PIPv6("example.i2p")) {
+		t.Error("Expected the same domain to get the same address")
+	}
+	if ip.Equal(generateI2PIPv6("other.i2p")) {
+		t.Error("Expected different domains to get different addresses")
+	}
+}
+
+func TestSyntheticAddresses(t *testing.T) {
+	if _, err := NewSyntheticAddresses("dual"); err == nil {
+		t.Error("Expected error for an unknown address mode")
+	}
+
+	addresses, err := NewSyntheticAddresses("")
+	if err != nil {
+		t.Fatalf("Failed to create address table: %v", err)
+	}
+	if addresses.Mode() != AddressModeIPv4 {
+		t.Errorf("Expected default mode %s, got %s", AddressModeIPv4, addresses.Mode())
+	}
+
+	ip := generateI2PIPv6("example.i2p")
+	addresses.record(ip, "example.i2p")
+	if name, ok := addresses.Lookup(ip); !ok || name != "example.i2p" {
+		t.Errorf("Expected example.i2p, got %q (%v)", name, ok)
+	}
+
+	// The oldest addresses are forgotten first
+	for i := 0; i < maxSyntheticNames; i++ {
+		addresses.record(net.IPv4(198, 18, byte(i>>8), byte(i)), fmt.Sprintf("host%d.i2p", i))
+	}
+	if _, ok := addresses.Lookup(ip); ok {
+		t.Error("Expected the oldest address to be forgotten")
+	}
+	if len(addresses.names) != maxSyntheticNames || len(addresses.order) != maxSyntheticNames {
+		t.Errorf("Expected %d remembered addresses, got %d", maxSyntheticNames, len(addresses.names))
+	}
+}
+
+func TestTranslateSyntheticTarget(t *testing.T) {
+	addresses, _ := NewSyntheticAddresses(AddressModeIPv6)
+	ip := generateI2PIPv6("example.i2p")
+	addresses.record(ip, "example.i2p")
+
+	tests := []struct {
+		target   string
+		expected string
+	}{
+		{net.JoinHostPort(ip.String(), "80"), "example.i2p:80"},
+		{"[fd69:3270:b32::1]:80", "[fd69:3270:b32::1]:80"},
+		{"example.i2p:443", "example.i2p:443"},
+		{"invalid", "invalid"},
+	}
+
+	for _, tt := range tests {
+		if target := translateSyntheticTarget(addresses, tt.target); target != tt.expected {
+			t.Errorf("translateSyntheticTarget(%q): expected %q, got %q", tt.target, tt.expected, target)
+		}
+	}
+	if target := translateSyntheticTarget(nil, "1.2.3.4:80"); target != "1.2.3.4:80" {
+		t.Errorf("Expected target to be unchanged without a table, got %q", target)
+	}
+}
+
+func TestI2PDNSResolver_IPv6Mode(t *testing.T) {
+	addresses, _ := NewSyntheticAddresses(AddressModeIPv6)
+	resolver := NewI2PDNSResolver("127.0.0.1:5353")
+	resolver.SetSyntheticAddresses(addresses)
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.i2p.", dns.TypeAAAA)
+	resp := resolver.buildResponse(req)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 1 {
+		t.Fatalf("Expected one AAAA answer, got %v", resp)
+	}
+	aaaa, ok := resp.Answer[0].(*dns.AAAA)
+	if !ok || !aaaa.AAAA.Equal(generateI2PIPv6("example.i2p")) {
+		t.Errorf("Expected the synthetic IPv6 address, got %v", resp.Answer[0])
+	}
+	if name, ok := addresses.Lookup(aaaa.AAAA); !ok || name != "example.i2p" {
+		t.Errorf("Expected the address to map back to example.i2p, got %q", name)
+	}
+
+	// A queries for known names get NODATA, not NXDOMAIN
+	req.SetQuestion("example.i2p.", dns.TypeA)
+	resp = resolver.buildResponse(req)
+	if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) != 0 {
+		t.Errorf("Expected an empty NOERROR answer, got %v", resp)
+	}
+
+	ip, err := resolver.Resolve("example.i2p")
+	if err != nil || ip.To4() != nil {
+		t.Errorf("Expected Resolve to return an IPv6 address, got %v (%v)", ip, err)
+	}
+}
+
+func TestSOCKSProxy_parseSOCKS5Request_IPv6(t *testing.T) {
+	ip := generateI2PIPv6("example.i2p")
+	request := append(append([]byte{0x05, 0x01, 0x00, 0x04}, ip...), 0x00, 0x50)
+
+	proxy := &SOCKSProxy{}
+	target, err := proxy.parseSOCKS5Request(bytes.NewReader(request))
+	if err != nil {
+		t.Fatalf("Failed to parse IPv6 request: %v", err)
+	}
+	if expected := net.JoinHostPort(ip.String(), "80"); target != expected {
+		t.Errorf("Expected %q, got %q", expected, target)
+	}
+}
+
+func TestTrafficInterceptor_generateIp6tablesRules(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("172.20.0.0/16")
+	interceptor := NewTrafficInterceptor(subnet, 1080, 53)
+
+	if rules := generateIp6tablesRules(interceptor.spec()); len(rules) != 0 {
+		t.Errorf("Expected no ip6tables rules in IPv4 mode, got %v", rules)
+	}
+
+	interceptor.syntheticIPv6 = true
+	rules := generateIp6tablesRules(interceptor.spec())
+	if !containsRule(rules, "-t nat -A I2P_REDIRECT6 -p tcp -j REDIRECT --to-port 1080") ||
+		!containsRule(rules, "-t nat -A PREROUTING -d "+SyntheticIPv6Range+" -j I2P_REDIRECT6") {
+		t.Errorf("Expected redirect rules for %s, got %v", SyntheticIPv6Range, rules)
+	}
+}
@@ -0,0 +1,217 @@
+// Package proxy provides per-container usage accounting and persistence.
+//
+// The traffic filter counts the bytes each container moves through the
+// proxy per UTC day. Together with the aggregate counters, the usage records
+// form a TrafficSnapshot that is written to disk periodically and reloaded on
+// start, so bandwidth accounting survives plugin restarts.
+package proxy
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/state"
+)
+
+// UsageDayFormat is the layout of UsageRecord.Day.
+const UsageDayFormat = "2006-01-02"
+
+// usageRetention is how long daily usage records are kept.
+const usageRetention = 90 * 24 * time.Hour
+
+// UsageRecord is the proxy traffic of one container on one day.
+type UsageRecord struct {
+	// Day is the UTC day in UsageDayFormat
+	Day string `json:"day"`
+	// ContainerID is the container the traffic originated from
+	ContainerID string `json:"container_id"`
+	// Connections counts completed proxy connections
+	Connections int64 `json:"connections"`
+	// BytesTransferred is the data volume in both directions
+	BytesTransferred int64 `json:"bytes_transferred"`
+}
+
+// usageKey identifies a usage record.
+type usageKey struct {
+	day         string
+	containerID string
+}
+
+// trafficSnapshotSchema describes the layout versions of the traffic
+// statistics file. Version 0 is the file written without an envelope.
+var trafficSnapshotSchema = state.Schema{
+	Name: "traffic statistics",
+	Migrations: []state.Migration{
+		{
+			Description: "wrap the statistics in a versioned envelope",
+			Migrate:     func(data json.RawMessage) (json.RawMessage, error) { return data, nil },
+		},
+	},
+}
+
+// TrafficSnapshot is the persisted form of the traffic statistics.
+//
+// Log entries are not persisted.
+type TrafficSnapshot struct {
+	// SavedAt is when the snapshot was taken
+	SavedAt time.Time `json:"saved_at"`
+	// I2PConnectionsAllowed counts successful I2P connections
+	I2PConnectionsAllowed int64 `json:"i2p_connections_allowed"`
+	// I2PConnectionsBlocked counts blocked I2P connections
+	I2PConnectionsBlocked int64 `json:"i2p_connections_blocked"`
+	// NonI2PConnectionsBlocked counts blocked non-I2P connections
+	NonI2PConnectionsBlocked int64 `json:"non_i2p_connections_blocked"`
+	// UnknownSourcesRejected counts connections from unknown clients
+	UnknownSourcesRejected int64 `json:"unknown_sources_rejected"`
+	// TotalBytesTransferred tracks total data volume
+	TotalBytesTransferred int64 `json:"total_bytes_transferred"`
+	// LastActivity records the timestamp of the last network activity
+	LastActivity time.Time `json:"last_activity"`
+	// Usage holds the per-container daily usage records
+	Usage []UsageRecord `json:"usage"`
+}
+
+// RecordUsage adds a completed connection to a container's usage for today.
+func (tf *TrafficFilter) RecordUsage(containerID string, bytesTransferred int64) {
+	if containerID == "" {
+		return
+	}
+
+	now := time.Now().UTC()
+	key := usageKey{day: now.Format(UsageDayFormat), containerID: containerID}
+
+	tf.statsMutex.Lock()
+	defer tf.statsMutex.Unlock()
+
+	record, exists := tf.usage[key]
+	if !exists {
+		record = &UsageRecord{Day: key.day, ContainerID: containerID}
+		tf.usage[key] = record
+		tf.pruneUsageLocked(now)
+	}
+	record.Connections++
+	record.BytesTransferred += bytesTransferred
+}
+
+// GetUsage returns the usage records of a day, or of all days if day is
+// empty, sorted by day and container.
+func (tf *TrafficFilter) GetUsage(day string) []UsageRecord {
+	tf.statsMutex.RLock()
+	defer tf.statsMutex.RUnlock()
+
+	return tf.usageLocked(day)
+}
+
+// usageLocked returns matching usage records sorted by day and container.
+// The caller must hold tf.statsMutex.
+func (tf *TrafficFilter) usageLocked(day string) []UsageRecord {
+	records := make([]UsageRecord, 0, len(tf.usage))
+	for _, record := range tf.usage {
+		if day == "" || record.Day == day {
+			records = append(records, *record)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Day != records[j].Day {
+			return records[i].Day < records[j].Day
+		}
+		return records[i].ContainerID < records[j].ContainerID
+	})
+	return records
+}
+
+// pruneUsageLocked drops usage records older than usageRetention.
+// The caller must hold tf.statsMutex.
+func (tf *TrafficFilter) pruneUsageLocked(now time.Time) {
+	cutoff := now.Add(-usageRetention).Format(UsageDayFormat)
+	for key := range tf.usage {
+		if key.day < cutoff {
+			delete(tf.usage, key)
+		}
+	}
+}
+
+// Snapshot returns the traffic counters and usage records for persistence.
+func (tf *TrafficFilter) Snapshot() TrafficSnapshot {
+	tf.statsMutex.RLock()
+	defer tf.statsMutex.RUnlock()
+
+	return TrafficSnapshot{
+		SavedAt:                  time.Now(),
+		I2PConnectionsAllowed:    tf.stats.I2PConnectionsAllowed,
+		I2PConnectionsBlocked:    tf.stats.I2PConnectionsBlocked,
+		NonI2PConnectionsBlocked: tf.stats.NonI2PConnectionsBlocked,
+		UnknownSourcesRejected:   tf.stats.UnknownSourcesRejected,
+		TotalBytesTransferred:    tf.stats.TotalBytesTransferred,
+		LastActivity:             tf.stats.LastActivity,
+		Usage:                    tf.usageLocked(""),
+	}
+}
+
+// RestoreSnapshot adds persisted counters and usage records to the filter.
+//
+// Values are added rather than replaced so traffic counted before the
+// snapshot was loaded is kept.
+func (tf *TrafficFilter) RestoreSnapshot(snapshot TrafficSnapshot) {
+	tf.statsMutex.Lock()
+	defer tf.statsMutex.Unlock()
+
+	tf.stats.I2PConnectionsAllowed += snapshot.I2PConnectionsAllowed
+	tf.stats.I2PConnectionsBlocked += snapshot.I2PConnectionsBlocked
+	tf.stats.NonI2PConnectionsBlocked += snapshot.NonI2PConnectionsBlocked
+	tf.stats.UnknownSourcesRejected += snapshot.UnknownSourcesRejected
+	tf.stats.TotalBytesTransferred += snapshot.TotalBytesTransferred
+	if snapshot.LastActivity.After(tf.stats.LastActivity) {
+		tf.stats.LastActivity = snapshot.LastActivity
+	}
+
+	for _, persisted := range snapshot.Usage {
+		if persisted.Day == "" || persisted.ContainerID == "" {
+			continue
+		}
+		key := usageKey{day: persisted.Day, containerID: persisted.ContainerID}
+		record, exists := tf.usage[key]
+		if !exists {
+			record = &UsageRecord{Day: persisted.Day, ContainerID: persisted.ContainerID}
+			tf.usage[key] = record
+		}
+		record.Connections += persisted.Connections
+		record.BytesTransferred += persisted.BytesTransferred
+	}
+	tf.pruneUsageLocked(time.Now().UTC())
+}
+
+// SaveTrafficSnapshot writes a snapshot to path.
+//
+// The file is written to a temporary file and renamed into place so a crash
+// never leaves a truncated snapshot behind.
+func SaveTrafficSnapshot(path string, snapshot TrafficSnapshot) error {
+	return state.Save(path, trafficSnapshotSchema, snapshot)
+}
+
+// LoadTrafficSnapshot reads a snapshot written by SaveTrafficSnapshot,
+// upgrading files written by older plugin versions.
+//
+// A missing file yields an error matching os.ErrNotExist.
+func LoadTrafficSnapshot(path string) (TrafficSnapshot, error) {
+	var snapshot TrafficSnapshot
+	err := state.Load(path, trafficSnapshotSchema, &snapshot)
+	return snapshot, err
+}
+
+// GetUsage returns per-container daily usage records (see TrafficFilter.GetUsage).
+func (pm *ProxyManager) GetUsage(day string) []UsageRecord {
+	return pm.trafficFilter.GetUsage(day)
+}
+
+// TrafficSnapshot returns the traffic statistics for persistence.
+func (pm *ProxyManager) TrafficSnapshot() TrafficSnapshot {
+	return pm.trafficFilter.Snapshot()
+}
+
+// RestoreTrafficSnapshot adds persisted traffic statistics to the current ones.
+func (pm *ProxyManager) RestoreTrafficSnapshot(snapshot TrafficSnapshot) {
+	pm.trafficFilter.RestoreSnapshot(snapshot)
+}
@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrafficFilter_RecordUsage(t *testing.T) {
+	filter := NewTrafficFilter(DefaultFilterConfig())
+
+	filter.RecordUsage("web", 100)
+	filter.RecordUsage("web", 50)
+	filter.RecordUsage("db", 10)
+	filter.RecordUsage("", 1000) // unattributed connections are not accounted
+
+	today := time.Now().UTC().Format(UsageDayFormat)
+	usage := filter.GetUsage(today)
+	if len(usage) != 2 {
+		t.Fatalf("Expected 2 usage records, got %+v", usage)
+	}
+	if usage[0].ContainerID != "db" || usage[0].BytesTransferred != 10 || usage[0].Connections != 1 {
+		t.Errorf("Unexpected db usage: %+v", usage[0])
+	}
+	if usage[1].ContainerID != "web" || usage[1].BytesTransferred != 150 || usage[1].Connections != 2 {
+		t.Errorf("Unexpected web usage: %+v", usage[1])
+	}
+
+	if usage := filter.GetUsage("2000-01-01"); len(usage) != 0 {
+		t.Errorf("Expected no usage on another day, got %+v", usage)
+	}
+
+	// Usage is accounting, not statistics, and survives a stats reset
+	filter.ClearStats()
+	if usage := filter.GetUsage(""); len(usage) != 2 {
+		t.Errorf("Expected usage to survive ClearStats, got %+v", usage)
+	}
+}
+
+func TestTrafficSnapshot_SaveAndRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "stats.json")
+
+	if _, err := LoadTrafficSnapshot(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected missing snapshot error, got %v", err)
+	}
+
+	filter := NewTrafficFilter(DefaultFilterConfig())
+	filter.ShouldAllowConnection("example.i2p:80", "tcp")
+	filter.ShouldAllowConnection("example.com:80", "tcp")
+	filter.LogConnection("172.20.0.2:4000", "example.i2p:80", "tcp", 300)
+	filter.RecordUsage("web", 300)
+	expired := time.Now().UTC().Add(-2 * usageRetention).Format(UsageDayFormat)
+
+	snapshot := filter.Snapshot()
+	snapshot.Usage = append(snapshot.Usage, UsageRecord{Day: expired, ContainerID: "old", BytesTransferred: 1})
+	if err := SaveTrafficSnapshot(path, snapshot); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	loaded, err := LoadTrafficSnapshot(path)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+
+	restored := NewTrafficFilter(DefaultFilterConfig())
+	restored.RecordUsage("web", 20)
+	restored.RestoreSnapshot(loaded)
+
+	stats := restored.GetStats()
+	if stats.I2PConnectionsAllowed != 1 || stats.NonI2PConnectionsBlocked != 1 || stats.TotalBytesTransferred != 300 {
+		t.Errorf("Unexpected restored stats: %+v", stats)
+	}
+	if stats.LastActivity.IsZero() {
+		t.Error("Expected last activity to be restored")
+	}
+
+	usage := restored.GetUsage("")
+	if len(usage) != 1 {
+		t.Fatalf("Expected expired usage to be pruned, got %+v", usage)
+	}
+	if usage[0].BytesTransferred != 320 || usage[0].Connections != 2 {
+		t.Errorf("Expected restored usage to be added to current usage, got %+v", usage[0])
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadTrafficSnapshot(path); err == nil {
+		t.Error("Expected error loading a corrupt snapshot")
+	}
+}
+
+func TestTrafficSnapshot_UpgradesUnversionedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	legacy := `{"i2p_connections_allowed": 7, "usage": [{"day": "2026-01-02", "container_id": "web", "bytes_transferred": 42}]}`
+	if err := os.WriteFile(path, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := LoadTrafficSnapshot(path)
+	if err != nil {
+		t.Fatalf("Failed to load unversioned snapshot: %v", err)
+	}
+	if snapshot.I2PConnectionsAllowed != 7 || len(snapshot.Usage) != 1 || snapshot.Usage[0].BytesTransferred != 42 {
+		t.Errorf("Unexpected snapshot from an unversioned file: %+v", snapshot)
+	}
+	if backup, err := os.ReadFile(path + ".v0.bak"); err != nil || string(backup) != legacy {
+		t.Errorf("Expected the unversioned file to be backed up, got %q (%v)", backup, err)
+	}
+}
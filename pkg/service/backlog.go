@@ -0,0 +1,224 @@
+// Package service provides queueing of I2P exposures while the router is
+// overloaded.
+//
+// When I2P exposures keep failing, or the router reports that it is
+// overloaded, ExposeServices stops building tunnels for new containers.
+// Their I2P ports are queued instead and Join succeeds with the exposures
+// pending. A single background drain retries the queue with exponential
+// backoff: one queued port is tried first, and only once it succeeds is the
+// rest of the queue exposed. Every exposure created from the queue is
+// published and reported to the ready handler, the same way deferred
+// exposures are.
+package service
+
+import (
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backlog tuning (variables so tests can shorten them).
+var (
+	// overloadThreshold is the number of consecutive failed I2P exposures
+	// after which the router is considered overloaded
+	overloadThreshold = 3
+	// backlogRetryInitial is the delay before the queue is first retried
+	backlogRetryInitial = 5 * time.Second
+	// backlogRetryMax caps the delay between retries of the queue
+	backlogRetryMax = 2 * time.Minute
+)
+
+// routerOverloadError reports whether err says the router is overloaded,
+// which queues exposures without waiting for overloadThreshold failures.
+func routerOverloadError(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "overload") || strings.Contains(message, "too busy")
+}
+
+// recordI2PResultLocked updates the count of consecutive failed I2P
+// exposures. The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) recordI2PResultLocked(err error) {
+	switch {
+	case err == nil:
+		sem.i2pFailures = 0
+	case routerOverloadError(err):
+		sem.i2pFailures = max(sem.i2pFailures+1, overloadThreshold)
+	default:
+		sem.i2pFailures++
+	}
+}
+
+// overloadedLocked reports whether I2P exposures are queued instead of
+// created. The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) overloadedLocked() bool {
+	return sem.i2pFailures >= overloadThreshold
+}
+
+// Overloaded reports whether the router is considered overloaded, i.e. new
+// I2P exposures are queued until a queued one succeeds.
+func (sem *ServiceExposureManager) Overloaded() bool {
+	sem.mutex.RLock()
+	defer sem.mutex.RUnlock()
+
+	return sem.overloadedLocked()
+}
+
+// queueExposureLocked queues an I2P port until the router recovers and
+// starts the drain if it is not running. The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) queueExposureLocked(containerID string, networkID string, containerIP net.IP, port ExposedPort) {
+	if sem.backlog == nil {
+		sem.backlog = make(map[string]*heldExposures)
+	}
+	queued, exists := sem.backlog[containerID]
+	if !exists {
+		queued = &heldExposures{networkID: networkID, containerIP: containerIP}
+		sem.backlog[containerID] = queued
+	}
+	queued.ports = append(queued.ports, port)
+
+	if !sem.draining {
+		sem.draining = true
+		go sem.drainBacklog()
+	}
+}
+
+// QueuedExposures returns the number of a container's I2P ports waiting for
+// the router to recover.
+func (sem *ServiceExposureManager) QueuedExposures(containerID string) int {
+	sem.mutex.RLock()
+	defer sem.mutex.RUnlock()
+
+	if queued, exists := sem.backlog[containerID]; exists {
+		return len(queued.ports)
+	}
+	return 0
+}
+
+// BacklogDepth returns the number of I2P ports of all containers waiting for
+// the router to recover.
+func (sem *ServiceExposureManager) BacklogDepth() int {
+	sem.mutex.RLock()
+	defer sem.mutex.RUnlock()
+
+	return sem.backlogDepthLocked()
+}
+
+// drainBacklog retries the queued ports until the queue is empty or the
+// manager shuts down.
+func (sem *ServiceExposureManager) drainBacklog() {
+	delay := backlogRetryInitial
+	for {
+		select {
+		case <-sem.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		// Stop moves the queue to the held ports, cleanup empties it
+		sem.mutex.Lock()
+		if len(sem.backlog) == 0 {
+			sem.draining = false
+			sem.mutex.Unlock()
+			return
+		}
+
+		created, recovered := sem.retryBacklogLocked()
+		handler := sem.onReady
+		sem.mutex.Unlock()
+
+		if handler != nil {
+			for _, exposure := range created {
+				handler(exposure)
+			}
+		}
+
+		if recovered {
+			delay = backlogRetryInitial
+		} else {
+			delay = min(delay*2, backlogRetryMax)
+			log.Printf("Router is still overloaded, retrying %d queued exposures in %s", sem.BacklogDepth(), delay)
+		}
+	}
+}
+
+// retryBacklogLocked tries the first queued port and, if it succeeds,
+// exposes the rest of the queue. Ports that fail again stay queued.
+//
+// Returns the exposures created and whether the probe succeeded. The caller
+// must hold sem.mutex.
+func (sem *ServiceExposureManager) retryBacklogLocked() (created []*ServiceExposure, recovered bool) {
+	containerIDs := make([]string, 0, len(sem.backlog))
+	for containerID := range sem.backlog {
+		containerIDs = append(containerIDs, containerID)
+	}
+	sort.Strings(containerIDs)
+
+	// Probe with a single port so an overloaded router sees one request per retry
+	first := containerIDs[0]
+	probe := sem.backlog[first]
+	exposure, err := sem.createExposure(first, probe.networkID, probe.containerIP, probe.ports[0])
+	sem.recordI2PResultLocked(err)
+	if err != nil {
+		log.Printf("Warning: Queued %s service on port %d for container %s still cannot be exposed: %v",
+			probe.ports[0].ExposureType, probe.ports[0].ContainerPort, first, err)
+		return nil, false
+	}
+	sem.addQueuedExposureLocked(exposure)
+	created = append(created, exposure)
+	probe.ports = probe.ports[1:]
+
+	backlog := sem.backlog
+	sem.backlog = nil
+	for _, containerID := range containerIDs {
+		queued := backlog[containerID]
+		for i, result := range sem.createExposuresLocked(containerID, queued.networkID, queued.containerIP, queued.ports) {
+			port := queued.ports[i]
+			sem.recordI2PResultLocked(result.err)
+			if result.err != nil {
+				sem.queueBackLocked(containerID, queued, port)
+				continue
+			}
+			sem.addQueuedExposureLocked(result.exposure)
+			created = append(created, result.exposure)
+		}
+	}
+
+	log.Printf("Router recovered, exposed %d queued services, %d still queued", len(created), sem.backlogDepthLocked())
+	return created, true
+}
+
+// queueBackLocked returns a port that failed again to the queue without
+// starting another drain. The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) queueBackLocked(containerID string, queued *heldExposures, port ExposedPort) {
+	if sem.backlog == nil {
+		sem.backlog = make(map[string]*heldExposures)
+	}
+	requeued, exists := sem.backlog[containerID]
+	if !exists {
+		requeued = &heldExposures{networkID: queued.networkID, containerIP: queued.containerIP}
+		sem.backlog[containerID] = requeued
+	}
+	requeued.ports = append(requeued.ports, port)
+}
+
+// addQueuedExposureLocked tracks, publishes and schedules the expiry of an
+// exposure created from the queue. The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) addQueuedExposureLocked(exposure *ServiceExposure) {
+	sem.exposures[exposure.ContainerID] = append(sem.exposures[exposure.ContainerID], exposure)
+	sem.publishExposureLocked(exposure)
+	sem.scheduleExpiryLocked(exposure)
+	log.Printf("Exposed queued %s service %s for container %s on %s",
+		exposure.Port.ExposureType, exposure.TunnelName, exposure.ContainerID, exposure.Destination)
+}
+
+// backlogDepthLocked returns the number of queued ports. The caller must
+// hold sem.mutex.
+func (sem *ServiceExposureManager) backlogDepthLocked() int {
+	depth := 0
+	for _, queued := range sem.backlog {
+		depth += len(queued.ports)
+	}
+	return depth
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+// newUnreachableRouterManager returns a manager whose SAM bridge refuses
+// connections, so every I2P exposure fails. The queue is not retried during
+// the test.
+func newUnreachableRouterManager(t *testing.T) *ServiceExposureManager {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	retry := backlogRetryInitial
+	backlogRetryInitial = time.Hour
+	t.Cleanup(func() { backlogRetryInitial = retry })
+
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: &i2p.SAMConfig{Host: "127.0.0.1", Port: port, Timeout: time.Second}})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
+	if err != nil {
+		t.Fatalf("Failed to create service exposure manager: %v", err)
+	}
+	t.Cleanup(func() { manager.Shutdown() })
+	return manager
+}
+
+func TestExposeServicesQueuesWhileOverloaded(t *testing.T) {
+	manager := newUnreachableRouterManager(t)
+	containerIP := net.ParseIP("127.0.0.1")
+
+	// Fewer failures than the threshold are reported as usual
+	_, err := manager.ExposeServices("container1", "net1", containerIP, []ExposedPort{
+		{ContainerPort: 80, Protocol: "tcp", ServiceName: "web"},
+	})
+	var failures ExposureErrors
+	if !errors.As(err, &failures) || len(failures) != 1 {
+		t.Fatalf("Expected one failed port, got %v", err)
+	}
+	if manager.Overloaded() || manager.QueuedExposures("container1") != 0 {
+		t.Fatal("Expected nothing to be queued below the threshold")
+	}
+
+	// Reaching the threshold queues the failed ports and Join succeeds
+	exposures, err := manager.ExposeServices("container2", "net1", containerIP, []ExposedPort{
+		{ContainerPort: 80, Protocol: "tcp", ServiceName: "web"},
+		{ContainerPort: 443, Protocol: "tcp", ServiceName: "https"},
+	})
+	if err != nil || len(exposures) != 0 {
+		t.Fatalf("Expected the ports to be queued, got %d exposures (%v)", len(exposures), err)
+	}
+	if !manager.Overloaded() {
+		t.Error("Expected the router to be considered overloaded")
+	}
+	if queued := manager.QueuedExposures("container2"); queued != 2 {
+		t.Errorf("Expected 2 queued ports, got %d", queued)
+	}
+
+	// While overloaded, I2P ports are queued without trying and IP ports are exposed
+	backend := startEchoServer(t)
+	exposures, err = manager.ExposeServices("container3", "net1", containerIP, []ExposedPort{
+		{ContainerPort: 8080, Protocol: "tcp", ServiceName: "api"},
+		{ContainerPort: backend.Addr().(*net.TCPAddr).Port, HostPort: freePort(t, "tcp"), Protocol: "tcp", ServiceName: "web", ExposureType: ExposureTypeIP, TargetIP: "127.0.0.1"},
+	})
+	if err != nil || len(exposures) != 1 || exposures[0].Port.ExposureType != ExposureTypeIP {
+		t.Fatalf("Expected only the IP port to be exposed, got %d exposures (%v)", len(exposures), err)
+	}
+	if depth := manager.BacklogDepth(); depth != 3 {
+		t.Errorf("Expected a backlog of 3 ports, got %d", depth)
+	}
+
+	// Cleanup drops a container's queued ports
+	if err := manager.CleanupServices("container2"); err != nil {
+		t.Fatalf("CleanupServices() unexpected error: %v", err)
+	}
+	if depth := manager.BacklogDepth(); depth != 1 {
+		t.Errorf("Expected a backlog of 1 port after cleanup, got %d", depth)
+	}
+
+	// Stop holds the queued ports with the exposed ones
+	if err := manager.Stop(); err != nil {
+		t.Fatalf("Stop() unexpected error: %v", err)
+	}
+	if depth := manager.BacklogDepth(); depth != 0 {
+		t.Errorf("Expected an empty backlog while stopped, got %d", depth)
+	}
+	if held := manager.HeldExposures("container3"); held != 2 {
+		t.Errorf("Expected 2 held ports, got %d", held)
+	}
+}
+
+func TestRecordI2PResult(t *testing.T) {
+	manager := newForwarderTestManager(t)
+
+	manager.recordI2PResultLocked(errors.New("connection refused"))
+	if manager.overloadedLocked() {
+		t.Error("Expected a single failure not to mark the router overloaded")
+	}
+	manager.recordI2PResultLocked(errors.New("SESSION STATUS RESULT=I2P_ERROR MESSAGE=\"router overloaded\""))
+	if !manager.overloadedLocked() {
+		t.Error("Expected an overload error to mark the router overloaded")
+	}
+	manager.recordI2PResultLocked(nil)
+	if manager.overloadedLocked() {
+		t.Error("Expected a success to clear the overload")
+	}
+}
@@ -0,0 +1,257 @@
+// Package service provides dependency ordering for multi-port exposures.
+//
+// A label such as i2p.expose.443.after=80 holds back the exposure of port 443
+// until port 80 accepts TCP connections inside the container. The dependency
+// ports are probed in the background after Join, so an application that
+// opens its ports one by one is only advertised once it is ready to serve.
+package service
+
+import (
+	"context"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// afterLabelSuffix marks the dependency label of an exposed port.
+const afterLabelSuffix = ".after"
+
+// Dependency probing intervals (variables so tests can shorten them).
+var (
+	// dependencyProbeInterval is how often unready dependency ports are probed
+	dependencyProbeInterval = time.Second
+	// dependencyProbeTimeout bounds a single connection attempt
+	dependencyProbeTimeout = 2 * time.Second
+	// dependencyWaitWarning is how long a dependency may stay unready before a warning is logged
+	dependencyWaitWarning = 5 * time.Minute
+)
+
+// ReadyHandler is called after a deferred exposure has been created.
+type ReadyHandler func(exposure *ServiceExposure)
+
+// SetReadyHandler sets the function notified when an exposure held back by
+// its dependencies has been created.
+//
+// The handler runs without sem.mutex held and may call back into the manager.
+func (sem *ServiceExposureManager) SetReadyHandler(handler ReadyHandler) {
+	sem.mutex.Lock()
+	defer sem.mutex.Unlock()
+
+	sem.onReady = handler
+}
+
+// parseAfterLabel parses an i2p.expose.<port>.after label.
+//
+// The value is a comma-separated list of container ports that must be
+// listening before the port is exposed. Returns the exposed port and its
+// dependencies, or ok=false if the label is invalid.
+func parseAfterLabel(key string, value interface{}) (port int, after []int, ok bool) {
+	portStr := strings.TrimSuffix(strings.TrimPrefix(key, "i2p.expose."), afterLabelSuffix)
+	if !isDecimal(portStr) {
+		log.Printf("Warning: Invalid port in label %q", key)
+		return 0, nil, false
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		log.Printf("Warning: Invalid port in label %q: %v", key, err)
+		return 0, nil, false
+	}
+
+	valueStr, isString := value.(string)
+	if !isString {
+		log.Printf("Warning: Invalid value type for label %q", key)
+		return 0, nil, false
+	}
+
+	for _, dependency := range strings.Split(valueStr, ",") {
+		dependency = strings.TrimSpace(dependency)
+		if !isDecimal(dependency) {
+			log.Printf("Warning: Invalid dependency port in label %q: %q", key, dependency)
+			return 0, nil, false
+		}
+		dependencyPort, err := strconv.Atoi(dependency)
+		if err != nil || dependencyPort <= 0 || dependencyPort > 65535 || dependencyPort == port {
+			log.Printf("Warning: Invalid dependency port in label %q: %q", key, dependency)
+			return 0, nil, false
+		}
+		after = append(after, dependencyPort)
+	}
+	return port, after, true
+}
+
+// extractPortDependencies returns the dependency ports declared with
+// i2p.expose.<port>.after labels, keyed by exposed port.
+func extractPortDependencies(options map[string]interface{}) map[int][]int {
+	labels, ok := options["Labels"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	dependencies := make(map[int][]int)
+	for key, value := range labels {
+		if !strings.HasPrefix(key, "i2p.expose.") || !strings.HasSuffix(key, afterLabelSuffix) {
+			continue
+		}
+		if port, after, ok := parseAfterLabel(key, value); ok {
+			dependencies[port] = after
+		}
+	}
+	return dependencies
+}
+
+// deferExposureLocked creates an exposure once its dependency ports listen.
+//
+// The wait is cancelled when the container's services are cleaned up or the
+// manager shuts down. The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) deferExposureLocked(containerID string, networkID string, containerIP net.IP, port ExposedPort) {
+	if sem.pending == nil {
+		sem.pending = make(map[string]*pendingExposures)
+	}
+	pending, exists := sem.pending[containerID]
+	if !exists {
+		ctx, cancel := context.WithCancel(sem.ctx)
+		pending = &pendingExposures{ctx: ctx, cancel: cancel}
+		sem.pending[containerID] = pending
+	}
+	pending.count++
+
+	log.Printf("Holding back %s exposure of port %d for container %s until ports %v are listening",
+		port.ExposureType, port.ContainerPort, containerID, port.After)
+
+	go sem.awaitDependencies(pending, dependencyProbeInterval, containerID, networkID, containerIP, port)
+}
+
+// pendingExposures tracks the deferred exposures of a container.
+type pendingExposures struct {
+	// ctx is cancelled when the container's services are cleaned up
+	ctx context.Context
+	// cancel stops waiting for the container's dependencies
+	cancel context.CancelFunc
+	// count is the number of exposures still waiting
+	count int
+}
+
+// awaitDependencies probes the dependency ports of a deferred exposure and
+// creates the exposure once they all accept connections.
+func (sem *ServiceExposureManager) awaitDependencies(pending *pendingExposures, interval time.Duration, containerID string, networkID string, containerIP net.IP, port ExposedPort) {
+	if !waitForPorts(pending.ctx, interval, containerIP, port.After) {
+		return
+	}
+
+	sem.mutex.Lock()
+
+	// Cleanup may have run between the probe and taking the lock
+	if pending.ctx.Err() != nil {
+		sem.mutex.Unlock()
+		return
+	}
+	sem.releasePendingLocked(containerID, pending)
+
+	if sem.stopped {
+		sem.holdExposureLocked(containerID, networkID, containerIP, port)
+		sem.mutex.Unlock()
+		log.Printf("Ports %v of container %s are listening, holding %s service on port %d until exposures start",
+			port.After, containerID, port.ExposureType, port.ContainerPort)
+		return
+	}
+
+	exposure, err := sem.createExposure(containerID, networkID, containerIP, port)
+	if err != nil {
+		sem.mutex.Unlock()
+		log.Printf("Warning: Failed to expose %s service on port %d for container %s: %v",
+			port.ExposureType, port.ContainerPort, containerID, err)
+		return
+	}
+
+	sem.exposures[containerID] = append(sem.exposures[containerID], exposure)
+	sem.publishExposureLocked(exposure)
+	sem.scheduleExpiryLocked(exposure)
+	handler := sem.onReady
+	sem.mutex.Unlock()
+
+	log.Printf("Ports %v of container %s are listening, exposed %s service %s on %s",
+		port.After, containerID, port.ExposureType, exposure.TunnelName, exposure.Destination)
+
+	if handler != nil {
+		handler(exposure)
+	}
+}
+
+// releasePendingLocked drops a completed deferred exposure from tracking.
+// The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) releasePendingLocked(containerID string, pending *pendingExposures) {
+	pending.count--
+	if pending.count == 0 && sem.pending[containerID] == pending {
+		pending.cancel()
+		delete(sem.pending, containerID)
+	}
+}
+
+// cancelPendingLocked stops waiting for the dependencies of a container's
+// deferred exposures. The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) cancelPendingLocked(containerID string) {
+	if pending, exists := sem.pending[containerID]; exists {
+		pending.cancel()
+		delete(sem.pending, containerID)
+	}
+}
+
+// PendingExposures returns the number of a container's exposures that are
+// still waiting for their dependency ports.
+func (sem *ServiceExposureManager) PendingExposures(containerID string) int {
+	sem.mutex.RLock()
+	defer sem.mutex.RUnlock()
+
+	if pending, exists := sem.pending[containerID]; exists {
+		return pending.count
+	}
+	return 0
+}
+
+// waitForPorts blocks until every port accepts TCP connections on ip,
+// probing every interval.
+//
+// Returns false if ctx is cancelled first.
+func waitForPorts(ctx context.Context, interval time.Duration, ip net.IP, ports []int) bool {
+	start := time.Now()
+	warned := false
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for remaining := ports; ; {
+		remaining = unreadyPorts(ctx, ip, remaining)
+		if len(remaining) == 0 {
+			return true
+		}
+
+		if !warned && time.Since(start) >= dependencyWaitWarning {
+			log.Printf("Warning: Ports %v on %s are still not listening after %s", remaining, ip, dependencyWaitWarning)
+			warned = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// unreadyPorts returns the ports that do not accept TCP connections on ip.
+func unreadyPorts(ctx context.Context, ip net.IP, ports []int) []int {
+	dialer := net.Dialer{Timeout: dependencyProbeTimeout}
+
+	var unready []int
+	for _, port := range ports {
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), strconv.Itoa(port)))
+		if err != nil {
+			unready = append(unready, port)
+			continue
+		}
+		conn.Close()
+	}
+	return unready
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseAfterLabel(t *testing.T) {
+	tests := []struct {
+		name          string
+		key           string
+		value         interface{}
+		expectedPort  int
+		expectedAfter []int
+		shouldFail    bool
+	}{
+		{name: "single dependency", key: "i2p.expose.443.after", value: "80", expectedPort: 443, expectedAfter: []int{80}},
+		{name: "multiple dependencies", key: "i2p.expose.443.after", value: "80, 8080", expectedPort: 443, expectedAfter: []int{80, 8080}},
+		{name: "self dependency", key: "i2p.expose.443.after", value: "443", shouldFail: true},
+		{name: "invalid dependency", key: "i2p.expose.443.after", value: "http", shouldFail: true},
+		{name: "out of range dependency", key: "i2p.expose.443.after", value: "70000", shouldFail: true},
+		{name: "invalid port", key: "i2p.expose.+443.after", value: "80", shouldFail: true},
+		{name: "non-string value", key: "i2p.expose.443.after", value: 80, shouldFail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port, after, ok := parseAfterLabel(tt.key, tt.value)
+			if tt.shouldFail {
+				if ok {
+					t.Errorf("Expected label to be rejected, got port %d after %v", port, after)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("Expected label to be accepted")
+			}
+			if port != tt.expectedPort || !reflect.DeepEqual(after, tt.expectedAfter) {
+				t.Errorf("Expected port %d after %v, got port %d after %v", tt.expectedPort, tt.expectedAfter, port, after)
+			}
+		})
+	}
+}
+
+func TestDetectExposedPortsWithDependencies(t *testing.T) {
+	manager := &ServiceExposureManager{}
+	options := map[string]interface{}{
+		"Labels": map[string]interface{}{
+			"i2p.expose.80":        "i2p",
+			"i2p.expose.443":       "i2p",
+			"i2p.expose.443.after": "80",
+		},
+	}
+
+	ports, err := manager.DetectExposedPorts("container1", options)
+	if err != nil {
+		t.Fatalf("DetectExposedPorts() error = %v", err)
+	}
+	if len(ports) != 2 {
+		t.Fatalf("Expected 2 ports, got %d: %+v", len(ports), ports)
+	}
+	for _, port := range ports {
+		switch port.ContainerPort {
+		case 80:
+			if len(port.After) != 0 {
+				t.Errorf("Expected port 80 to have no dependencies, got %v", port.After)
+			}
+		case 443:
+			if !reflect.DeepEqual(port.After, []int{80}) {
+				t.Errorf("Expected port 443 to wait for port 80, got %v", port.After)
+			}
+		default:
+			t.Errorf("Unexpected port %d", port.ContainerPort)
+		}
+	}
+}
+
+// newDependencyTestManager returns a manager without a tunnel manager, for
+// IP exposures, and shortens the dependency probe interval.
+func newDependencyTestManager(t *testing.T) *ServiceExposureManager {
+	t.Helper()
+
+	interval := dependencyProbeInterval
+	dependencyProbeInterval = 10 * time.Millisecond
+	t.Cleanup(func() { dependencyProbeInterval = interval })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &ServiceExposureManager{
+		exposures: make(map[string][]*ServiceExposure),
+		portMaps:  make(map[string][]*PortMapping),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// freeTCPPort returns a loopback port nothing listens on.
+func freeTCPPort(t *testing.T) int {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func TestDeferredExposure(t *testing.T) {
+	manager := newDependencyTestManager(t)
+	exposedPort := freeTCPPort(t)
+	dependencyPort := freeTCPPort(t)
+
+	ready := make(chan *ServiceExposure, 1)
+	manager.SetReadyHandler(func(exposure *ServiceExposure) {
+		ready <- exposure
+	})
+
+	exposures, err := manager.ExposeServices("container1", "net1", net.ParseIP("127.0.0.1"), []ExposedPort{{
+		ContainerPort: exposedPort,
+		Protocol:      "tcp",
+		ExposureType:  ExposureTypeIP,
+		TargetIP:      "127.0.0.1",
+		After:         []int{dependencyPort},
+	}})
+	if err != nil {
+		t.Fatalf("ExposeServices() error = %v", err)
+	}
+	if len(exposures) != 0 {
+		t.Errorf("Expected exposure to be held back, got %d exposures", len(exposures))
+	}
+	if pending := manager.PendingExposures("container1"); pending != 1 {
+		t.Errorf("Expected 1 pending exposure, got %d", pending)
+	}
+
+	select {
+	case <-ready:
+		t.Fatal("Exposure created before its dependency was listening")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(dependencyPort)))
+	if err != nil {
+		t.Fatalf("Failed to listen on dependency port: %v", err)
+	}
+	defer listener.Close()
+
+	var exposure *ServiceExposure
+	select {
+	case exposure = <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Exposure was not created after its dependency started listening")
+	}
+
+	if exposure.Port.ContainerPort != exposedPort {
+		t.Errorf("Expected exposure of port %d, got %d", exposedPort, exposure.Port.ContainerPort)
+	}
+	if got := manager.GetServiceExposures("container1"); len(got) != 1 || got[0] != exposure {
+		t.Errorf("Expected the deferred exposure to be tracked, got %v", got)
+	}
+	if pending := manager.PendingExposures("container1"); pending != 0 {
+		t.Errorf("Expected no pending exposures, got %d", pending)
+	}
+
+	if err := manager.CleanupServices("container1"); err != nil {
+		t.Errorf("CleanupServices() error = %v", err)
+	}
+}
+
+func TestDeferredExposureCancelledByCleanup(t *testing.T) {
+	manager := newDependencyTestManager(t)
+	dependencyPort := freeTCPPort(t)
+
+	ready := make(chan *ServiceExposure, 1)
+	manager.SetReadyHandler(func(exposure *ServiceExposure) {
+		ready <- exposure
+	})
+
+	_, err := manager.ExposeServices("container1", "net1", net.ParseIP("127.0.0.1"), []ExposedPort{{
+		ContainerPort: freeTCPPort(t),
+		Protocol:      "tcp",
+		ExposureType:  ExposureTypeIP,
+		TargetIP:      "127.0.0.1",
+		After:         []int{dependencyPort},
+	}})
+	if err != nil {
+		t.Fatalf("ExposeServices() error = %v", err)
+	}
+
+	if err := manager.CleanupServices("container1"); err != nil {
+		t.Fatalf("CleanupServices() error = %v", err)
+	}
+	if pending := manager.PendingExposures("container1"); pending != 0 {
+		t.Errorf("Expected pending exposures to be cancelled, got %d", pending)
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(dependencyPort)))
+	if err != nil {
+		t.Fatalf("Failed to listen on dependency port: %v", err)
+	}
+	defer listener.Close()
+
+	select {
+	case <-ready:
+		t.Error("Deferred exposure created after its container was cleaned up")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
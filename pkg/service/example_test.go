@@ -0,0 +1,31 @@
+package service_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+)
+
+func ExampleNewServiceExposureManager() {
+	manager, err := service.NewServiceExposureManager(service.ExposureManagerOptions{
+		TunnelManager: i2p.NewTunnelManager(i2p.TunnelManagerOptions{}),
+		Concurrency:   2,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Ports are detected from container labels without contacting SAM
+	ports, err := manager.DetectExposedPorts("container1", map[string]interface{}{
+		"Labels": map[string]interface{}{"i2p.expose.80": "i2p"},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, port := range ports {
+		fmt.Printf("%d/%s over %s\n", port.ContainerPort, port.Protocol, port.ExposureType)
+	}
+	// Output: 80/tcp over i2p
+}
@@ -0,0 +1,100 @@
+// Package service provides the JSON form of service exposures.
+//
+// A ServiceExposure holds tunnels, port forwarders and timers that have no
+// meaningful JSON form. ExposureInfo is the documented schema every output
+// surface uses for an exposure: the admin and gRPC APIs, state dumps, the
+// events of the HTTP publisher and i2pnet. ServiceExposure marshals to it, so
+// encoding an exposure directly gives the same document.
+package service
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+// Exposure states reported in ExposureInfo.
+const (
+	// ExposureStateActive is an exposure whose tunnel or forwarder is serving
+	ExposureStateActive = "active"
+	// ExposureStateInactive is an exposure whose tunnel is not serving, e.g.
+	// while its session is rebuilt
+	ExposureStateInactive = "inactive"
+)
+
+// ExposureInfo is the external JSON schema of a service exposure.
+type ExposureInfo struct {
+	// ContainerID is the container providing the service
+	ContainerID string `json:"container_id"`
+	// NetworkID is the network the container is attached to
+	NetworkID string `json:"network_id"`
+	// Service is the exposure purpose, e.g. "web-80"
+	Service string `json:"service"`
+	// ContainerPort is the port inside the container
+	ContainerPort int `json:"container_port"`
+	// Protocol is tcp or udp
+	Protocol string `json:"protocol"`
+	// Type is either "i2p" or "ip"
+	Type string `json:"type"`
+	// Destination is the .b32.i2p address or host IP:port
+	Destination string `json:"destination"`
+	// Addresses are the base64, b32 and, for blinded leasesets, b33 forms of
+	// the I2P destination (omitted for IP exposures)
+	Addresses *i2p.Addresses `json:"addresses,omitempty"`
+	// TunnelName is the server tunnel serving the port, or the name an IP
+	// exposure is tracked under
+	TunnelName string `json:"tunnel_name"`
+	// Target is the container IP:port traffic is forwarded to (omitted if unknown)
+	Target string `json:"target,omitempty"`
+	// State is ExposureStateActive or ExposureStateInactive
+	State string `json:"state"`
+	// CreatedAt is when the exposure was created (omitted if unknown)
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	// ExpiresAt is when the exposure's TTL elapses (omitted if it has none)
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// SharedTunnel reports whether the service is served by the container's shared server sub-session
+	SharedTunnel bool `json:"shared_tunnel,omitempty"`
+	// Group is the exposure group whose destination serves the port, if any
+	Group string `json:"group,omitempty"`
+}
+
+// Info returns the external form of the exposure.
+func (e *ServiceExposure) Info() ExposureInfo {
+	info := ExposureInfo{
+		ContainerID:   e.ContainerID,
+		NetworkID:     e.networkID,
+		Service:       exposurePurpose(e.Port),
+		ContainerPort: e.Port.ContainerPort,
+		Protocol:      normalizeProtocol(e.Port.Protocol),
+		Type:          string(e.Port.ExposureType),
+		Destination:   e.Destination,
+		Addresses:     e.Addresses,
+		TunnelName:    e.TunnelName,
+		State:         ExposureStateActive,
+		SharedTunnel:  e.Port.SharedTunnel,
+		Group:         e.Port.Group,
+	}
+	if e.containerIP != nil {
+		info.Target = net.JoinHostPort(e.containerIP.String(), strconv.Itoa(e.Port.ContainerPort))
+	}
+	if e.Tunnel != nil && !e.Tunnel.IsActive() {
+		info.State = ExposureStateInactive
+	}
+	if !e.CreatedAt.IsZero() {
+		createdAt := e.CreatedAt
+		info.CreatedAt = &createdAt
+	}
+	if !e.ExpiresAt.IsZero() {
+		expiresAt := e.ExpiresAt
+		info.ExpiresAt = &expiresAt
+	}
+	return info
+}
+
+// MarshalJSON encodes the exposure as its ExposureInfo.
+func (e *ServiceExposure) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.Info())
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+func TestServiceExposure_MarshalJSON(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	exposure := &ServiceExposure{
+		ContainerID: "container1",
+		Port:        ExposedPort{ContainerPort: 80, ServiceName: "web", ExposureType: ExposureTypeI2P},
+		Tunnel:      &i2p.Tunnel{},
+		Destination: "abc.b32.i2p",
+		TunnelName:  "container1-web-80",
+		CreatedAt:   created,
+		networkID:   "net1",
+		containerIP: net.ParseIP("172.20.0.2"),
+	}
+
+	data, err := json.Marshal(exposure)
+	if err != nil {
+		t.Fatalf("Failed to marshal exposure: %v", err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Failed to decode %s: %v", data, err)
+	}
+
+	// Internal handles are left out and the schema's fields are present
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	expected := []string{"container_id", "container_port", "created_at", "destination", "network_id",
+		"protocol", "service", "state", "target", "tunnel_name", "type"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected fields %v, got %v", expected, keys)
+	}
+
+	info := exposure.Info()
+	if info.Service != "web-80" || info.Protocol != "tcp" || info.Target != "172.20.0.2:80" {
+		t.Errorf("Unexpected exposure info %+v", info)
+	}
+	// The zero tunnel is not active
+	if info.State != ExposureStateInactive {
+		t.Errorf("Expected state %s, got %s", ExposureStateInactive, info.State)
+	}
+	if info.CreatedAt == nil || !info.CreatedAt.Equal(created) || info.ExpiresAt != nil {
+		t.Errorf("Expected only the creation time, got %v and %v", info.CreatedAt, info.ExpiresAt)
+	}
+
+	ip := (&ServiceExposure{Port: ExposedPort{ContainerPort: 8080, ExposureType: ExposureTypeIP}}).Info()
+	if ip.State != ExposureStateActive || ip.Target != "" {
+		t.Errorf("Expected an active IP exposure without target, got %+v", ip)
+	}
+}
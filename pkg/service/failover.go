@@ -0,0 +1,144 @@
+// Package service provides destination failover for port maps.
+//
+// A port map may list several I2P destinations serving the same thing, e.g.
+// mirrors of an IRC network. Connections go to the first healthy destination
+// in the configured order. A destination that cannot be reached is marked
+// down and skipped for a backoff period that doubles with every consecutive
+// failure, so containers keep working while one destination is offline and
+// return to it once it answers again. When every destination is down they
+// are still tried, soonest retry first, rather than refusing the connection.
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// failoverBaseBackoff is how long a destination is skipped after its first failure
+	failoverBaseBackoff = 10 * time.Second
+	// failoverMaxBackoff bounds the backoff of a destination that keeps failing
+	failoverMaxBackoff = 5 * time.Minute
+)
+
+// PortMapTarget is an I2P destination a port map forwards to.
+type PortMapTarget struct {
+	// Destination is the I2P host (.i2p name, .b32.i2p address or base64 destination)
+	Destination string `json:"destination"`
+	// Port is the service port on the remote destination
+	Port int `json:"port"`
+}
+
+// TargetHealth reports the health of a port map destination.
+type TargetHealth struct {
+	PortMapTarget
+	// Healthy is false while the destination is skipped after failures
+	Healthy bool `json:"healthy"`
+	// Failures counts the consecutive failed connections
+	Failures int `json:"failures"`
+	// RetryAt is when an unhealthy destination is tried again (nil if healthy)
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+	// LastError is the error of the last failed connection
+	LastError string `json:"last_error,omitempty"`
+}
+
+// targetState tracks the health of one destination.
+type targetState struct {
+	target    PortMapTarget
+	failures  int
+	downUntil time.Time
+	lastError string
+}
+
+// failoverList orders the destinations of a port map by health.
+type failoverList struct {
+	// targets are the destinations in the configured order
+	targets []*targetState
+	// now returns the current time (replaced in tests)
+	now func() time.Time
+	// mutex protects the target states
+	mutex sync.Mutex
+}
+
+// newFailoverList tracks the health of targets, all initially healthy.
+func newFailoverList(targets []PortMapTarget) *failoverList {
+	f := &failoverList{now: time.Now}
+	for _, target := range targets {
+		f.targets = append(f.targets, &targetState{target: target})
+	}
+	return f
+}
+
+// order returns the destinations to try for a connection: healthy ones in
+// the configured order, followed by those that are down, soonest retry first.
+func (f *failoverList) order() []*targetState {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := f.now()
+	var healthy, down []*targetState
+	for _, state := range f.targets {
+		if now.Before(state.downUntil) {
+			down = append(down, state)
+		} else {
+			healthy = append(healthy, state)
+		}
+	}
+
+	sort.SliceStable(down, func(i, j int) bool { return down[i].downUntil.Before(down[j].downUntil) })
+	return append(healthy, down...)
+}
+
+// succeeded marks a destination healthy after a connection got through.
+func (f *failoverList) succeeded(state *targetState) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	state.failures = 0
+	state.downUntil = time.Time{}
+	state.lastError = ""
+}
+
+// failed marks a destination down after a failed connection and returns how
+// long it is skipped.
+func (f *failoverList) failed(state *targetState, err error) time.Duration {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	state.failures++
+	state.lastError = err.Error()
+
+	backoff := failoverBaseBackoff
+	for i := 1; i < state.failures && backoff < failoverMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > failoverMaxBackoff {
+		backoff = failoverMaxBackoff
+	}
+	state.downUntil = f.now().Add(backoff)
+	return backoff
+}
+
+// health returns the health of every destination in the configured order.
+func (f *failoverList) health() []TargetHealth {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := f.now()
+	health := make([]TargetHealth, 0, len(f.targets))
+	for _, state := range f.targets {
+		entry := TargetHealth{
+			PortMapTarget: state.target,
+			Healthy:       !now.Before(state.downUntil),
+			Failures:      state.failures,
+			LastError:     state.lastError,
+		}
+		if !entry.Healthy {
+			retryAt := state.downUntil
+			entry.RetryAt = &retryAt
+		}
+		health = append(health, entry)
+	}
+	return health
+}
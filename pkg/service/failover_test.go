@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFailoverList(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	f := newFailoverList([]PortMapTarget{{Destination: "a.i2p", Port: 80}, {Destination: "b.i2p", Port: 80}, {Destination: "c.i2p", Port: 80}})
+	f.now = func() time.Time { return now }
+
+	order := func() string {
+		var names string
+		for _, state := range f.order() {
+			names += state.target.Destination[:1]
+		}
+		return names
+	}
+
+	if got := order(); got != "abc" {
+		t.Errorf("Expected the configured order while healthy, got %s", got)
+	}
+
+	a, b := f.targets[0], f.targets[1]
+	if backoff := f.failed(a, errors.New("unreachable")); backoff != failoverBaseBackoff {
+		t.Errorf("Expected the base backoff after one failure, got %s", backoff)
+	}
+	if got := order(); got != "bca" {
+		t.Errorf("Expected the failed destination to be tried last, got %s", got)
+	}
+
+	// Down destinations are ordered by when they may be retried
+	f.failed(a, errors.New("unreachable"))
+	f.failed(b, errors.New("unreachable"))
+	if got := order(); got != "cba" {
+		t.Errorf("Expected the soonest retry first among down destinations, got %s", got)
+	}
+
+	health := f.health()
+	if health[0].Healthy || health[0].Failures != 2 || health[0].RetryAt == nil || health[0].LastError != "unreachable" {
+		t.Errorf("Unexpected health of a: %+v", health[0])
+	}
+	if !health[2].Healthy || health[2].RetryAt != nil {
+		t.Errorf("Unexpected health of c: %+v", health[2])
+	}
+
+	// Destinations come back after their backoff or a successful connection
+	now = now.Add(failoverBaseBackoff + failoverBaseBackoff/2)
+	if got := order(); got != "bca" {
+		t.Errorf("Expected b to be retried after its backoff, got %s", got)
+	}
+	f.succeeded(a)
+	if got := order(); got != "abc" || f.health()[0].Failures != 0 {
+		t.Errorf("Expected a to be healthy after a success, got %s", got)
+	}
+
+	for i := 0; i < 10; i++ {
+		f.failed(a, errors.New("unreachable"))
+	}
+	if retry := f.health()[0].RetryAt.Sub(now); retry != failoverMaxBackoff {
+		t.Errorf("Expected the backoff to be capped at %s, got %s", failoverMaxBackoff, retry)
+	}
+}
+
+func TestPortMappingFailsOver(t *testing.T) {
+	pm := PortMap{ListenPort: 6667, Destination: "down.i2p", DestinationPort: 6667, Fallbacks: []PortMapTarget{{Destination: "up.i2p", Port: 6667}}}
+
+	var dialed []string
+	mapping := &PortMapping{
+		Map:      pm,
+		failover: newFailoverList(pm.Targets()),
+		dial: func(destination string) (net.Conn, error) {
+			dialed = append(dialed, destination)
+			if destination == "down.i2p" {
+				return nil, errors.New("destination not found")
+			}
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		},
+	}
+
+	conn, target, err := mapping.dialTarget()
+	if err != nil || target.Destination != "up.i2p" {
+		t.Fatalf("Expected to fail over to up.i2p, got %q (%v)", target.Destination, err)
+	}
+	conn.Close()
+
+	// The failed destination is skipped until its backoff has elapsed
+	dialed = nil
+	if _, target, _ := mapping.dialTarget(); target.Destination != "up.i2p" || len(dialed) != 1 {
+		t.Errorf("Expected only up.i2p to be dialed, dialed %v", dialed)
+	}
+	if health := mapping.Health(); health[0].Healthy || !health[1].Healthy {
+		t.Errorf("Unexpected health %+v", health)
+	}
+
+	mapping.dial = func(string) (net.Conn, error) { return nil, errors.New("router down") }
+	if _, _, err := mapping.dialTarget(); err == nil {
+		t.Error("Expected an error when no destination is reachable")
+	}
+}
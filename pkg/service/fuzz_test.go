@@ -0,0 +1,64 @@
+package service
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func FuzzParsePortSpec(f *testing.F) {
+	for _, seed := range []string{"80/tcp", "443/udp", "0/tcp", "65536/tcp", "+80/tcp", "80", ""} {
+		f.Add(seed)
+	}
+
+	manager := &ServiceExposureManager{}
+
+	f.Fuzz(func(t *testing.T, portSpec string) {
+		port := manager.parsePortSpec(portSpec)
+		if port == nil {
+			return
+		}
+		if port.ContainerPort <= 0 || port.ContainerPort > 65535 {
+			t.Errorf("parsePortSpec(%q) returned out of range port %d", portSpec, port.ContainerPort)
+		}
+		if port.Protocol != "tcp" && port.Protocol != "udp" {
+			t.Errorf("parsePortSpec(%q) returned protocol %q", portSpec, port.Protocol)
+		}
+	})
+}
+
+func FuzzParseExposureLabel(f *testing.F) {
+	seeds := [][2]string{
+		{"i2p.expose.80", "i2p"},
+		{"i2p.expose.443", "ip:127.0.0.1"},
+		{"i2p.expose.8080", "ip"},
+		{"i2p.expose.+80", "i2p"},
+		{"i2p.expose.-1", "i2p"},
+		{"i2p.expose.80", "ip:not-an-ip"},
+		{"i2p.expose.", ""},
+	}
+	for _, seed := range seeds {
+		f.Add(seed[0], seed[1])
+	}
+
+	manager := &ServiceExposureManager{}
+
+	f.Fuzz(func(t *testing.T, key, value string) {
+		port := manager.parseExposureLabel(key, value)
+		if port == nil {
+			return
+		}
+		if port.ContainerPort <= 0 || port.ContainerPort > 65535 {
+			t.Errorf("parseExposureLabel(%q, %q) returned out of range port %d", key, value, port.ContainerPort)
+		}
+		if !isDecimal(strings.TrimPrefix(key, "i2p.expose.")) {
+			t.Errorf("parseExposureLabel(%q, %q) accepted a non-decimal port", key, value)
+		}
+		if port.ExposureType != ExposureTypeI2P && port.ExposureType != ExposureTypeIP {
+			t.Errorf("parseExposureLabel(%q, %q) returned exposure type %q", key, value, port.ExposureType)
+		}
+		if port.TargetIP != "" && net.ParseIP(port.TargetIP) == nil {
+			t.Errorf("parseExposureLabel(%q, %q) returned invalid target IP %q", key, value, port.TargetIP)
+		}
+	})
+}
@@ -0,0 +1,605 @@
+// Package service provides exposure groups for replicated services.
+//
+// Containers labeled with the same i2p.expose.group register their I2P ports
+// under one destination owned by the group instead of a destination of their
+// own. The plugin accepts the group's inbound streams and forwards each of
+// them to one of the members, so an eepsite run as several replicas keeps
+// its address while individual containers come and go. The members are
+// chosen round-robin or by the fewest active streams, and a member that
+// refuses a stream is skipped in favour of the next one.
+package service
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+const (
+	// ExposureGroupLabel is the container label naming the exposure group
+	ExposureGroupLabel = "i2p.expose.group"
+	// ExposureBalanceLabel is the container label selecting how a group
+	// spreads inbound streams across its members
+	ExposureBalanceLabel = "i2p.expose.group.balance"
+)
+
+const (
+	// BalanceRoundRobin hands inbound streams to the members in turn
+	BalanceRoundRobin = "round-robin"
+	// BalanceLeastConnections hands inbound streams to the member with the
+	// fewest active streams
+	BalanceLeastConnections = "least-connections"
+)
+
+// maxGroupNameLength bounds group names, which end up in SAM session IDs.
+const maxGroupNameLength = 32
+
+// memberDialTimeout bounds the connection to a member for one inbound stream.
+const memberDialTimeout = 10 * time.Second
+
+// BalanceModes returns the supported balance modes.
+func BalanceModes() []string {
+	return []string{BalanceRoundRobin, BalanceLeastConnections}
+}
+
+// GroupSessionID returns the ID the I2P session of an exposure group is
+// tracked under by the tunnel manager.
+func GroupSessionID(group string) string {
+	return "group-" + group
+}
+
+// IsGroupSessionID reports whether a session ID tracked by the tunnel manager
+// belongs to an exposure group rather than a container.
+func IsGroupSessionID(sessionID string) bool {
+	return strings.HasPrefix(sessionID, "group-")
+}
+
+// groupTunnelName returns the name of the server tunnel serving a port of a group.
+func groupTunnelName(group string, port int) string {
+	return i2p.TunnelName(GroupSessionID(group), strconv.Itoa(port))
+}
+
+// exposureGroupConfig returns the exposure group and balance mode selected by
+// container labels. The group is empty if the container does not join one.
+func exposureGroupConfig(options map[string]interface{}) (string, string, error) {
+	labels, ok := options["Labels"].(map[string]interface{})
+	if !ok {
+		return "", "", nil
+	}
+
+	group, _ := labels[ExposureGroupLabel].(string)
+	group = strings.TrimSpace(group)
+	if group == "" {
+		return "", "", nil
+	}
+	if err := validateGroupName(group); err != nil {
+		return "", "", fmt.Errorf("invalid %s label: %w", ExposureGroupLabel, err)
+	}
+
+	value, _ := labels[ExposureBalanceLabel].(string)
+	switch balance := strings.ToLower(strings.TrimSpace(value)); balance {
+	case "", BalanceRoundRobin:
+		return group, BalanceRoundRobin, nil
+	case BalanceLeastConnections:
+		return group, BalanceLeastConnections, nil
+	}
+	return "", "", fmt.Errorf("invalid %s label %q (expected %s or %s)",
+		ExposureBalanceLabel, value, BalanceRoundRobin, BalanceLeastConnections)
+}
+
+// validateGroupName checks that a group name is short and SAM-safe.
+func validateGroupName(name string) error {
+	if len(name) > maxGroupNameLength {
+		return fmt.Errorf("group name %q exceeds %d characters", name, maxGroupNameLength)
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') &&
+			r != '-' && r != '_' && r != '.' {
+			return fmt.Errorf("group name %q contains %q, only letters, digits, '.', '_' and '-' are allowed", name, r)
+		}
+	}
+	return nil
+}
+
+// groupMember is a container backing a port of an exposure group.
+type groupMember struct {
+	// containerID identifies the member container
+	containerID string
+	// addr is the container IP:port inbound streams are forwarded to
+	addr string
+	// active counts the streams currently forwarded to the member
+	active int
+	// streams counts the completed streams forwarded to the member
+	streams int64
+	// bytes counts the data forwarded to and from the member, including
+	// streams still open
+	bytes atomic.Int64
+}
+
+// StreamStats counts the inbound I2P streams forwarded to a container.
+type StreamStats struct {
+	// Streams counts completed streams
+	Streams int64 `json:"streams"`
+	// ActiveStreams counts streams currently forwarded
+	ActiveStreams int64 `json:"active_streams"`
+	// BytesTransferred is the data forwarded in both directions, including
+	// streams still open
+	BytesTransferred int64 `json:"bytes_transferred"`
+}
+
+// countingConn adds the bytes read and written on a connection to a counter.
+type countingConn struct {
+	net.Conn
+	counter *atomic.Int64
+}
+
+// Read reads from the connection and counts the bytes read.
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.counter.Add(int64(n))
+	return n, err
+}
+
+// Write writes to the connection and counts the bytes written.
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.counter.Add(int64(n))
+	return n, err
+}
+
+// balancer picks the member each inbound stream of a group is forwarded to.
+type balancer struct {
+	// mode is BalanceRoundRobin or BalanceLeastConnections
+	mode string
+	// members are the containers in the order they joined
+	members []*groupMember
+	// next is the index the next round starts at
+	next int
+	// demand starts and stops the member of an on-demand group (nil otherwise)
+	demand *onDemandContainer
+	// mutex protects members and next
+	mutex sync.Mutex
+}
+
+// add registers a member container.
+func (b *balancer) add(containerID, addr string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.members = append(b.members, &groupMember{containerID: containerID, addr: addr})
+}
+
+// remove unregisters a member container and returns how many are left.
+//
+// Streams already forwarded to the member are not interrupted.
+func (b *balancer) remove(containerID string) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for i, member := range b.members {
+		if member.containerID == containerID {
+			b.members = append(b.members[:i:i], b.members[i+1:]...)
+			if b.next > i {
+				b.next--
+			}
+			break
+		}
+	}
+	return len(b.members)
+}
+
+// count returns the number of members.
+func (b *balancer) count() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return len(b.members)
+}
+
+// pick returns the member the next stream goes to, skipping those in tried,
+// and counts the stream as active. Returns nil if every member was tried.
+// Callers release the stream with done.
+func (b *balancer) pick(tried map[*groupMember]bool) *groupMember {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var picked *groupMember
+	pickedIndex := 0
+	for offset := range b.members {
+		index := (b.next + offset) % len(b.members)
+		member := b.members[index]
+		if tried[member] {
+			continue
+		}
+		if picked == nil || (b.mode == BalanceLeastConnections && member.active < picked.active) {
+			picked, pickedIndex = member, index
+		}
+		if b.mode != BalanceLeastConnections {
+			break
+		}
+	}
+	if picked == nil {
+		return nil
+	}
+
+	// Ties under least-connections rotate like round-robin
+	b.next = (pickedIndex + 1) % len(b.members)
+	picked.active++
+	return picked
+}
+
+// done releases a stream counted by pick. completed is false for streams
+// the member never received.
+func (b *balancer) done(member *groupMember, completed bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	member.active--
+	if completed {
+		member.streams++
+	}
+}
+
+// stats returns the stream counters of a member container.
+func (b *balancer) stats(containerID string) StreamStats {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, member := range b.members {
+		if member.containerID == containerID {
+			return StreamStats{
+				Streams:          member.streams,
+				ActiveStreams:    int64(member.active),
+				BytesTransferred: member.bytes.Load(),
+			}
+		}
+	}
+	return StreamStats{}
+}
+
+// dial connects to a member for an inbound stream. The returned release
+// function must be called once the stream is closed.
+//
+// The member of an on-demand group is started first if it is stopped, and
+// tried until it accepts the stream or onDemandStartTimeout has passed.
+func (b *balancer) dial(protocol string) (net.Conn, string, func(), error) {
+	if b.demand == nil {
+		return b.dialMembers(protocol)
+	}
+
+	deadline := time.Now().Add(onDemandStartTimeout)
+	for {
+		if err := b.demand.wake(); err != nil {
+			return nil, "", nil, err
+		}
+		conn, addr, release, err := b.dialMembers(protocol)
+		if err == nil {
+			b.demand.begin()
+			return conn, addr, func() { release(); b.demand.end() }, nil
+		}
+		if !time.Now().Before(deadline) {
+			return nil, addr, nil, err
+		}
+		time.Sleep(onDemandDialRetry)
+	}
+}
+
+// dialMembers connects to a member for an inbound stream, falling back to the
+// other members if it cannot be reached.
+func (b *balancer) dialMembers(protocol string) (net.Conn, string, func(), error) {
+	tried := make(map[*groupMember]bool)
+	var lastErr error
+	for {
+		member := b.pick(tried)
+		if member == nil {
+			break
+		}
+
+		conn, err := net.DialTimeout(protocol, member.addr, memberDialTimeout)
+		if err == nil {
+			return &countingConn{Conn: conn, counter: &member.bytes}, member.addr, func() { b.done(member, true) }, nil
+		}
+
+		log.Printf("Warning: Group member %s at %s refused a stream, trying the next one: %v",
+			member.containerID, member.addr, err)
+		b.done(member, false)
+		tried[member] = true
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		return nil, "", nil, fmt.Errorf("exposure group has no members")
+	}
+	return nil, "", nil, fmt.Errorf("no exposure group member reachable: %w", lastErr)
+}
+
+// exposureGroup is a port of an exposure group served by one server tunnel.
+type exposureGroup struct {
+	// name is the group name from the i2p.expose.group label
+	name string
+	// port is the I2P port and the container port of the members
+	port int
+	// balancer picks the member of each inbound stream
+	balancer *balancer
+	// tunnel is the group's server tunnel (nil until built)
+	tunnel *i2p.Tunnel
+	// destination is the .b32.i2p address of the group
+	destination string
+	// addresses are the encodings of the group's destination (nil if unknown)
+	addresses *i2p.Addresses
+	// forwarder accepts the tunnel's inbound streams (nil until built)
+	forwarder *PortForwarder
+	// ready is closed once the tunnel is built or has failed
+	ready chan struct{}
+	// err is the error building the tunnel
+	err error
+}
+
+// groupKey returns the key a port of a group is tracked under.
+func groupKey(group string, port int) string {
+	return fmt.Sprintf("%s/%d", group, port)
+}
+
+// StreamStats returns the inbound streams forwarded to the exposure's
+// container. Only exposure groups forward streams through the plugin, so
+// other exposures report none.
+func (e *ServiceExposure) StreamStats() StreamStats {
+	if e.group == nil {
+		return StreamStats{}
+	}
+	return e.group.balancer.stats(e.ContainerID)
+}
+
+// ExposureGroupMember describes a container backing an exposure group.
+type ExposureGroupMember struct {
+	// ContainerID identifies the member container
+	ContainerID string `json:"container_id"`
+	// Address is the container IP:port streams are forwarded to
+	Address string `json:"address"`
+	// ActiveStreams counts the streams currently forwarded to the member
+	ActiveStreams int `json:"active_streams"`
+}
+
+// ExposureGroupInfo describes a port of an exposure group.
+type ExposureGroupInfo struct {
+	// Name is the group name
+	Name string `json:"name"`
+	// Port is the exposed port
+	Port int `json:"port"`
+	// Balance is the balance mode of the group
+	Balance string `json:"balance"`
+	// Destination is the .b32.i2p address shared by the members
+	Destination string `json:"destination"`
+	// Members are the containers backing the port in the order they joined
+	Members []ExposureGroupMember `json:"members"`
+	// OnDemand is the state of the container of an on-demand group
+	// (running, starting or stopped), empty for other groups
+	OnDemand string `json:"on_demand,omitempty"`
+}
+
+// ExposureGroups returns the ports of all exposure groups, sorted by group
+// name and port.
+func (sem *ServiceExposureManager) ExposureGroups() []ExposureGroupInfo {
+	sem.groupsMutex.Lock()
+	defer sem.groupsMutex.Unlock()
+
+	groups := make([]ExposureGroupInfo, 0, len(sem.groups))
+	for _, group := range sem.groups {
+		select {
+		case <-group.ready:
+		default:
+			continue // Still being built
+		}
+		if group.err != nil {
+			continue
+		}
+
+		info := ExposureGroupInfo{
+			Name:        group.name,
+			Port:        group.port,
+			Balance:     group.balancer.mode,
+			Destination: group.destination,
+			Members:     []ExposureGroupMember{},
+		}
+		if group.balancer.demand != nil {
+			info.OnDemand = group.balancer.demand.state()
+		}
+		group.balancer.mutex.Lock()
+		for _, member := range group.balancer.members {
+			info.Members = append(info.Members, ExposureGroupMember{
+				ContainerID:   member.containerID,
+				Address:       member.addr,
+				ActiveStreams: member.active,
+			})
+		}
+		group.balancer.mutex.Unlock()
+		groups = append(groups, info)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Name != groups[j].Name {
+			return groups[i].Name < groups[j].Name
+		}
+		return groups[i].Port < groups[j].Port
+	})
+	return groups
+}
+
+// joinGroup adds a container port to its exposure group, building the
+// group's server tunnel for the first member.
+//
+// Members joining concurrently wait for the first one to finish building
+// the tunnel. It does not take sem.mutex, so it is safe to call from the
+// exposure workers.
+func (sem *ServiceExposureManager) joinGroup(containerID string, containerIP net.IP, port ExposedPort) (*ServiceExposure, error) {
+	key := groupKey(port.Group, port.ContainerPort)
+	addr := net.JoinHostPort(containerIP.String(), strconv.Itoa(port.ContainerPort))
+
+	sem.groupsMutex.Lock()
+	if sem.groups == nil {
+		sem.groups = make(map[string]*exposureGroup)
+	}
+	group, exists := sem.groups[key]
+	if !exists {
+		group = &exposureGroup{
+			name:     port.Group,
+			port:     port.ContainerPort,
+			balancer: &balancer{mode: port.Balance, demand: sem.onDemandForLocked(containerID, port)},
+			ready:    make(chan struct{}),
+		}
+		sem.groups[key] = group
+	} else if group.balancer.mode != port.Balance {
+		log.Printf("Warning: Container %s asks for %s balancing of group %s, which uses %s",
+			containerID, port.Balance, port.Group, group.balancer.mode)
+	}
+	group.balancer.add(containerID, addr)
+	sem.groupsMutex.Unlock()
+
+	if !exists {
+		err := sem.buildGroup(group, port)
+
+		sem.groupsMutex.Lock()
+		if err != nil {
+			group.err = err
+			delete(sem.groups, key)
+		}
+		sem.groupsMutex.Unlock()
+		close(group.ready)
+	} else {
+		<-group.ready
+	}
+
+	if group.err != nil {
+		return nil, group.err
+	}
+	if group.balancer.demand != nil {
+		group.balancer.demand.joined(port.ContainerPort)
+	}
+
+	log.Printf("Container %s joined exposure group %s on port %d (%s)", containerID, port.Group, port.ContainerPort, addr)
+	return &ServiceExposure{
+		ContainerID: containerID,
+		Port:        port,
+		Tunnel:      group.tunnel,
+		Destination: group.destination,
+		Addresses:   group.addresses,
+		TunnelName:  group.tunnel.GetConfig().Name,
+		group:       group,
+	}, nil
+}
+
+// buildGroup creates the server tunnel of a group port and starts forwarding
+// its inbound streams to the members.
+func (sem *ServiceExposureManager) buildGroup(group *exposureGroup, port ExposedPort) error {
+	sessionID := GroupSessionID(group.name)
+	tunnelName := groupTunnelName(group.name, group.port)
+
+	tunnelConfig := &i2p.TunnelConfig{
+		Name:        tunnelName,
+		Purpose:     strconv.Itoa(group.port),
+		Type:        i2p.TunnelTypeServer,
+		LocalPort:   group.port,
+		ContainerID: sessionID,
+		Options:     i2p.DefaultTunnelOptions(),
+		HTTPHeaders: port.HTTPHeaders,
+		InboundOnly: port.InboundOnly,
+	}
+	if port.TunnelOptions != nil {
+		tunnelConfig.Options = *port.TunnelOptions
+	}
+
+	log.Printf("Creating server tunnel for exposure group %s on port %d", group.name, group.port)
+	tunnel, err := sem.tunnelMgr.CreateTunnel(tunnelConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create server tunnel for group %s port %d: %w", group.name, group.port, err)
+	}
+
+	destination, err := sem.generateB32Address(tunnel.GetConfig().Destination)
+	if err == nil {
+		var listener net.Listener
+		if listener, err = tunnel.Listen(); err == nil {
+			group.forwarder = newBalancedForwarder(listener, group.balancer)
+		}
+	}
+	if err != nil {
+		sem.destroyGroupTunnel(group.name, tunnelName)
+		return fmt.Errorf("failed to serve group %s port %d: %w", group.name, group.port, err)
+	}
+
+	group.tunnel = tunnel
+	group.destination = destination
+	group.addresses = sem.destinationAddresses(tunnel)
+	return nil
+}
+
+// leaveGroup removes the container of an exposure from its group, tearing
+// the group's tunnel down with its last member.
+func (sem *ServiceExposureManager) leaveGroup(exposure *ServiceExposure) error {
+	group := exposure.group
+	key := groupKey(group.name, group.port)
+
+	sem.groupsMutex.Lock()
+	remaining := group.balancer.remove(exposure.ContainerID)
+	if remaining > 0 {
+		sem.groupsMutex.Unlock()
+		log.Printf("Container %s left exposure group %s on port %d, %d members remain",
+			exposure.ContainerID, group.name, group.port, remaining)
+		return nil
+	}
+	if sem.groups[key] == group {
+		delete(sem.groups, key)
+	}
+	sem.groupsMutex.Unlock()
+
+	log.Printf("Last member %s left exposure group %s on port %d, closing it", exposure.ContainerID, group.name, group.port)
+
+	var errors []string
+	if err := group.forwarder.Stop(); err != nil {
+		errors = append(errors, fmt.Sprintf("failed to stop forwarder of group %s: %v", group.name, err))
+	}
+	if err := sem.destroyGroupTunnel(group.name, exposure.TunnelName); err != nil {
+		errors = append(errors, err.Error())
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("%s", strings.Join(errors, "; "))
+	}
+	return nil
+}
+
+// destroyGroupTunnel destroys a server tunnel of a group, closing the
+// group's I2P session once none of its ports is served any more.
+func (sem *ServiceExposureManager) destroyGroupTunnel(group, tunnelName string) error {
+	var err error
+	if destroyErr := sem.tunnelMgr.DestroyTunnel(tunnelName); destroyErr != nil {
+		err = fmt.Errorf("failed to destroy tunnel %s: %w", tunnelName, destroyErr)
+	}
+
+	sessionID := GroupSessionID(group)
+	if !sem.tunnelMgr.HasContainerTunnels(sessionID) {
+		if closeErr := sem.tunnelMgr.DestroyContainerSession(sessionID); closeErr != nil {
+			log.Printf("Warning: Failed to close I2P session of exposure group %s: %v", group, closeErr)
+		}
+	}
+	return err
+}
+
+// containerGroupLocked returns the exposure group and balance mode of a
+// container's existing I2P exposures, if they belong to a group.
+// The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) containerGroupLocked(containerID string) (string, string) {
+	for _, exposure := range sem.exposures[containerID] {
+		if exposure.Port.Group != "" {
+			return exposure.Port.Group, exposure.Port.Balance
+		}
+	}
+	return "", ""
+}
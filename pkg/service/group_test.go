@@ -0,0 +1,213 @@
+package service
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestExposureGroupConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  map[string]interface{}
+		group   string
+		balance string
+		wantErr bool
+	}{
+		{name: "no group", labels: map[string]interface{}{}},
+		{name: "default balance", labels: map[string]interface{}{ExposureGroupLabel: " blog "}, group: "blog", balance: BalanceRoundRobin},
+		{name: "least connections", labels: map[string]interface{}{ExposureGroupLabel: "blog", ExposureBalanceLabel: "Least-Connections"}, group: "blog", balance: BalanceLeastConnections},
+		{name: "balance without group", labels: map[string]interface{}{ExposureBalanceLabel: BalanceLeastConnections}},
+		{name: "unknown balance", labels: map[string]interface{}{ExposureGroupLabel: "blog", ExposureBalanceLabel: "random"}, wantErr: true},
+		{name: "unsafe name", labels: map[string]interface{}{ExposureGroupLabel: "my blog"}, wantErr: true},
+		{name: "long name", labels: map[string]interface{}{ExposureGroupLabel: "a-very-long-exposure-group-name-for-a-blog"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group, balance, err := exposureGroupConfig(map[string]interface{}{"Labels": tt.labels})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got group %q", group)
+				}
+				return
+			}
+			if err != nil || group != tt.group || balance != tt.balance {
+				t.Errorf("Expected %q/%q, got %q/%q (%v)", tt.group, tt.balance, group, balance, err)
+			}
+		})
+	}
+}
+
+func TestDetectExposedPortsWithGroup(t *testing.T) {
+	manager := &ServiceExposureManager{}
+
+	ports, err := manager.DetectExposedPorts("container1", map[string]interface{}{
+		"Labels": map[string]interface{}{
+			ExposureGroupLabel:   "blog",
+			ExposureBalanceLabel: BalanceLeastConnections,
+			TunnelStrategyLabel:  TunnelStrategyShared,
+			"i2p.expose.80":      "i2p",
+			"i2p.expose.8080":    "ip",
+		},
+	})
+	if err != nil {
+		t.Fatalf("DetectExposedPorts() unexpected error: %v", err)
+	}
+	if len(ports) != 2 {
+		t.Fatalf("Expected the group labels not to be read as ports, got %d ports", len(ports))
+	}
+	for _, port := range ports {
+		inGroup := port.ExposureType == ExposureTypeI2P
+		if (port.Group == "blog") != inGroup || (port.Balance == BalanceLeastConnections) != inGroup {
+			t.Errorf("Port %d (%s): unexpected group %q/%q", port.ContainerPort, port.ExposureType, port.Group, port.Balance)
+		}
+		if port.SharedTunnel {
+			t.Errorf("Port %d: group ports are served by the group's tunnel, not a shared one", port.ContainerPort)
+		}
+		if name := ExposureTunnelName("container2", port); inGroup && name != groupTunnelName("blog", 80) {
+			t.Errorf("Expected members to share the group's tunnel name, got %s", name)
+		}
+	}
+}
+
+func TestBalancerRoundRobin(t *testing.T) {
+	b := &balancer{mode: BalanceRoundRobin}
+	b.add("a", "10.0.0.1:80")
+	b.add("b", "10.0.0.2:80")
+	b.add("c", "10.0.0.3:80")
+
+	var order []string
+	for i := 0; i < 4; i++ {
+		member := b.pick(nil)
+		order = append(order, member.containerID)
+		b.done(member, true)
+	}
+	if got := order[0] + order[1] + order[2] + order[3]; got != "abca" {
+		t.Errorf("Expected members in turn, got %v", order)
+	}
+
+	// Removing a member keeps the rotation going
+	if remaining := b.remove("b"); remaining != 2 {
+		t.Errorf("Expected 2 remaining members, got %d", remaining)
+	}
+	if member := b.pick(nil); member.containerID != "c" {
+		t.Errorf("Expected c after removing b, got %s", member.containerID)
+	}
+
+	tried := map[*groupMember]bool{b.members[0]: true, b.members[1]: true}
+	if member := b.pick(tried); member != nil {
+		t.Errorf("Expected no member once all were tried, got %s", member.containerID)
+	}
+}
+
+func TestBalancerLeastConnections(t *testing.T) {
+	b := &balancer{mode: BalanceLeastConnections}
+	b.add("a", "10.0.0.1:80")
+	b.add("b", "10.0.0.2:80")
+
+	first := b.pick(nil)
+	second := b.pick(nil)
+	if first == second {
+		t.Fatalf("Expected the streams to be spread, both went to %s", first.containerID)
+	}
+
+	// The member whose stream closed has the fewest streams
+	b.done(first, true)
+	if member := b.pick(nil); member != first {
+		t.Errorf("Expected %s with no active streams, got %s", first.containerID, member.containerID)
+	}
+	if first.active != 1 || second.active != 1 {
+		t.Errorf("Unexpected active streams: %d and %d", first.active, second.active)
+	}
+}
+
+// startNamedServer starts a TCP server that writes name to every connection.
+func startNamedServer(t *testing.T, name string) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte(name + "\n"))
+			conn.Close()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// readMember connects to addr and returns the name the serving member wrote.
+func readMember(t *testing.T, addr string) string {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to connect to group: %v", err)
+	}
+	defer conn.Close()
+
+	line, _ := bufio.NewReader(conn).ReadString('\n')
+	return line
+}
+
+func TestBalancedForwarder(t *testing.T) {
+	b := &balancer{mode: BalanceRoundRobin}
+	b.add("a", startNamedServer(t, "a"))
+	b.add("down", "127.0.0.1:1")
+	b.add("c", startNamedServer(t, "c"))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forwarder := newBalancedForwarder(listener, b)
+	t.Cleanup(func() { forwarder.Stop() })
+
+	// The unreachable member is skipped in favour of the next one
+	var served []string
+	for i := 0; i < 3; i++ {
+		served = append(served, readMember(t, listener.Addr().String()))
+	}
+	if served[0] != "a\n" || served[1] != "c\n" || served[2] != "a\n" {
+		t.Errorf("Expected streams to go to a, c, a, got %q", served)
+	}
+
+	// Streams are counted once the forwarder has closed them
+	exposure := &ServiceExposure{ContainerID: "a", group: &exposureGroup{balancer: b}}
+	deadline := time.Now().Add(5 * time.Second)
+	for exposure.StreamStats().Streams < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := exposure.StreamStats(); stats != (StreamStats{Streams: 2, BytesTransferred: 4}) {
+		t.Errorf("Unexpected stream stats of a: %+v", stats)
+	}
+	if stats := (&ServiceExposure{ContainerID: "down", group: &exposureGroup{balancer: b}}).StreamStats(); stats.Streams != 0 {
+		t.Errorf("Expected refused streams not to be counted, got %+v", stats)
+	}
+
+	manager := &ServiceExposureManager{groups: map[string]*exposureGroup{}}
+	group := &exposureGroup{name: "blog", port: 80, balancer: b, destination: "blog.b32.i2p", forwarder: forwarder, ready: make(chan struct{})}
+	close(group.ready)
+	manager.groups[groupKey("blog", 80)] = group
+
+	if err := manager.leaveGroup(&ServiceExposure{ContainerID: "down", group: group}); err != nil {
+		t.Fatalf("leaveGroup() unexpected error: %v", err)
+	}
+	groups := manager.ExposureGroups()
+	if len(groups) != 1 || len(groups[0].Members) != 2 || groups[0].Destination != "blog.b32.i2p" {
+		t.Fatalf("Expected the group to keep its remaining members, got %+v", groups)
+	}
+	if groups[0].Members[0].ContainerID != "a" || groups[0].Members[1].ContainerID != "c" {
+		t.Errorf("Unexpected members %+v", groups[0].Members)
+	}
+}
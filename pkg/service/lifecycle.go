@@ -0,0 +1,163 @@
+// Package service provides stopping and starting exposures on their own.
+//
+// Stop tears down every exposure's tunnel or forwarder while the plugin and
+// the containers keep running, and remembers the exposed ports. Start exposes
+// them again and reports each recreated exposure to the ready handler, the
+// same way deferred exposures are reported. Containers' I2P sessions are left
+// open in between, so restarted I2P exposures keep their addresses. Client
+// port maps are not affected.
+package service
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// heldExposures are the ports of a container exposed again on Start.
+type heldExposures struct {
+	// networkID is the network the ports were exposed for
+	networkID string
+	// containerIP is the container address the ports forward to
+	containerIP net.IP
+	// ports are the held ports
+	ports []ExposedPort
+}
+
+// Running reports whether exposures are served, i.e. the manager has not been
+// stopped with Stop.
+func (sem *ServiceExposureManager) Running() bool {
+	sem.mutex.RLock()
+	defer sem.mutex.RUnlock()
+
+	return !sem.stopped
+}
+
+// HeldExposures returns the number of a container's ports waiting for the
+// manager to be started again.
+func (sem *ServiceExposureManager) HeldExposures(containerID string) int {
+	sem.mutex.RLock()
+	defer sem.mutex.RUnlock()
+
+	if held, exists := sem.held[containerID]; exists {
+		return len(held.ports)
+	}
+	return 0
+}
+
+// Stop removes every exposure and holds its port until Start is called.
+//
+// Published addresses are withdrawn and TTLs are paused. Ports exposed while
+// stopped are held too; exposing a single port with ExposeService fails.
+// Stopping a stopped manager does nothing.
+func (sem *ServiceExposureManager) Stop() error {
+	sem.mutex.Lock()
+	defer sem.mutex.Unlock()
+
+	if sem.stopped {
+		return nil
+	}
+	sem.stopped = true
+
+	var errors []string
+	count := 0
+	for containerID, exposures := range sem.exposures {
+		for _, exposure := range exposures {
+			port := exposure.Port
+			if !exposure.ExpiresAt.IsZero() {
+				port.TTL = time.Until(exposure.ExpiresAt)
+			}
+
+			if err := sem.destroyExposure(exposure); err != nil {
+				errors = append(errors, err.Error())
+			}
+			if !exposure.ExpiresAt.IsZero() && port.TTL <= 0 {
+				continue
+			}
+			sem.holdExposureLocked(containerID, exposure.networkID, exposure.containerIP, port)
+			count++
+		}
+	}
+	sem.exposures = make(map[string][]*ServiceExposure)
+
+	// Ports queued while the router is overloaded are held as well
+	for containerID, queued := range sem.backlog {
+		for _, port := range queued.ports {
+			sem.holdExposureLocked(containerID, queued.networkID, queued.containerIP, port)
+			count++
+		}
+	}
+	sem.backlog = nil
+
+	log.Printf("Stopped service exposures, holding %d ports", count)
+	if len(errors) > 0 {
+		return fmt.Errorf("stop errors: %s", strings.Join(errors, "; "))
+	}
+	return nil
+}
+
+// Start exposes the ports held since Stop again.
+//
+// Recreated exposures are published, resume their remaining TTL and are
+// reported to the ready handler. Ports that cannot be exposed are dropped and
+// returned as ExposureErrors. Starting a running manager does nothing.
+func (sem *ServiceExposureManager) Start() error {
+	sem.mutex.Lock()
+
+	if !sem.stopped {
+		sem.mutex.Unlock()
+		return nil
+	}
+	sem.stopped = false
+
+	var restored []*ServiceExposure
+	var failures ExposureErrors
+	for containerID, held := range sem.held {
+		for i, result := range sem.createExposuresLocked(containerID, held.networkID, held.containerIP, held.ports) {
+			port := held.ports[i]
+			if result.err != nil {
+				log.Printf("Warning: Failed to expose %s service on port %d for container %s: %v",
+					port.ExposureType, port.ContainerPort, containerID, result.err)
+				failures = append(failures, ExposureFailure{Port: port, Err: result.err})
+				continue
+			}
+
+			exposure := result.exposure
+			sem.exposures[containerID] = append(sem.exposures[containerID], exposure)
+			sem.publishExposureLocked(exposure)
+			sem.scheduleExpiryLocked(exposure)
+			restored = append(restored, exposure)
+		}
+	}
+	sem.held = nil
+	handler := sem.onReady
+	sem.mutex.Unlock()
+
+	log.Printf("Started service exposures, restored %d services", len(restored))
+	if handler != nil {
+		for _, exposure := range restored {
+			handler(exposure)
+		}
+	}
+
+	if len(failures) > 0 {
+		return failures
+	}
+	return nil
+}
+
+// holdExposureLocked remembers a port to expose on Start.
+// The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) holdExposureLocked(containerID string, networkID string, containerIP net.IP, port ExposedPort) {
+	if sem.held == nil {
+		sem.held = make(map[string]*heldExposures)
+	}
+	held, exists := sem.held[containerID]
+	if !exists {
+		held = &heldExposures{networkID: networkID, containerIP: containerIP}
+		sem.held[containerID] = held
+	}
+	held.ports = append(held.ports, port)
+}
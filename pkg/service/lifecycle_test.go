@@ -0,0 +1,81 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStopAndStartExposures(t *testing.T) {
+	manager := newForwarderTestManager(t)
+	backend := startEchoServer(t)
+	backendPort := backend.Addr().(*net.TCPAddr).Port
+	containerIP := net.ParseIP("127.0.0.1")
+	hostPort := freePort(t, "tcp")
+
+	var ready []*ServiceExposure
+	manager.SetReadyHandler(func(exposure *ServiceExposure) {
+		ready = append(ready, exposure)
+	})
+
+	port := ExposedPort{ContainerPort: backendPort, HostPort: hostPort, ServiceName: "web", ExposureType: ExposureTypeIP, TargetIP: "127.0.0.1", TTL: time.Hour}
+	if _, err := manager.ExposeService("container1", "net1", containerIP, port); err != nil {
+		t.Fatalf("Failed to expose service: %v", err)
+	}
+
+	if err := manager.Stop(); err != nil {
+		t.Fatalf("Stop() unexpected error: %v", err)
+	}
+	if manager.Running() {
+		t.Error("Expected exposures to be stopped")
+	}
+	if exposures := manager.GetServiceExposures("container1"); exposures != nil {
+		t.Errorf("Expected no exposures while stopped, got %d", len(exposures))
+	}
+	if held := manager.HeldExposures("container1"); held != 1 {
+		t.Errorf("Expected 1 held port, got %d", held)
+	}
+	if conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", hostPort)); err == nil {
+		conn.Close()
+		t.Error("Expected the forwarder to be stopped")
+	}
+
+	// Single ports cannot be exposed while stopped, joined containers are held
+	if _, err := manager.ExposeService("container1", "net1", containerIP, port); err == nil {
+		t.Error("Expected ExposeService to fail while stopped")
+	}
+	exposures, err := manager.ExposeServices("container2", "net1", containerIP, []ExposedPort{
+		{ContainerPort: backendPort, HostPort: freePort(t, "tcp"), ExposureType: ExposureTypeIP, TargetIP: "127.0.0.1"},
+	})
+	if err != nil || len(exposures) != 0 {
+		t.Fatalf("Expected ports to be held while stopped, got %d exposures (%v)", len(exposures), err)
+	}
+	if held := manager.HeldExposures("container2"); held != 1 {
+		t.Errorf("Expected 1 held port, got %d", held)
+	}
+
+	if err := manager.Start(); err != nil {
+		t.Fatalf("Start() unexpected error: %v", err)
+	}
+	t.Cleanup(func() { manager.Shutdown() })
+	if !manager.Running() || manager.HeldExposures("container1") != 0 {
+		t.Error("Expected exposures to be running with no held ports")
+	}
+	if len(ready) != 2 {
+		t.Errorf("Expected 2 restored exposures to be reported, got %d", len(ready))
+	}
+
+	restored := manager.GetServiceExposures("container1")
+	if len(restored) != 1 {
+		t.Fatalf("Expected 1 restored exposure, got %d", len(restored))
+	}
+	if remaining := time.Until(restored[0].ExpiresAt); remaining <= 0 || remaining > time.Hour {
+		t.Errorf("Expected the remaining TTL to be resumed, expires in %s", remaining)
+	}
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", hostPort))
+	if err != nil {
+		t.Fatalf("Expected the forwarder to be restored: %v", err)
+	}
+	conn.Close()
+}
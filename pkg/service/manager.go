@@ -17,6 +17,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/base32"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -57,6 +58,30 @@ type ExposedPort struct {
 	TargetIP string `json:"target_ip,omitempty"`
 	// HostPort is the port on the host to bind (only used for -p port mappings, defaults to ContainerPort)
 	HostPort int `json:"host_port,omitempty"`
+	// Preset is the name of the i2p.preset the port came from, if any
+	Preset string `json:"preset,omitempty"`
+	// TunnelOptions overrides the default server tunnel options (I2P exposure only)
+	TunnelOptions *i2p.TunnelOptions `json:"tunnel_options,omitempty"`
+	// HTTPHeaders are headers added to HTTP responses served over the tunnel
+	HTTPHeaders map[string]string `json:"http_headers,omitempty"`
+	// TTL removes the exposure after the duration has elapsed (0 keeps it until cleanup)
+	TTL time.Duration `json:"ttl,omitempty"`
+	// InboundOnly creates an inbound-only server tunnel (I2P exposure only)
+	InboundOnly bool `json:"inbound_only,omitempty"`
+	// After lists container ports that must be listening before the port is exposed
+	After []int `json:"after,omitempty"`
+	// SharedTunnel serves the port from the container's shared server sub-session (I2P exposure only)
+	SharedTunnel bool `json:"shared_tunnel,omitempty"`
+	// Group is the exposure group whose destination serves the port (I2P exposure only)
+	Group string `json:"group,omitempty"`
+	// Balance is how the group spreads inbound streams across its members
+	Balance string `json:"balance,omitempty"`
+	// OnDemand is the idle timeout of a port served on demand, which
+	// starts and stops the container with its streams (0 if always served)
+	OnDemand time.Duration `json:"on_demand,omitempty"`
+	// Published marks a port published with docker run -p, which Docker
+	// revokes with RevokeExternalConnectivity
+	Published bool `json:"published,omitempty"`
 }
 
 // NetworkExposureConfig defines network-level exposure defaults.
@@ -65,6 +90,59 @@ type NetworkExposureConfig struct {
 	DefaultExposureType ExposureType
 	// AllowIPExposure determines if IP-based exposure is permitted
 	AllowIPExposure bool
+	// AllowedTargets lists the IP addresses and interface names IP exposures may bind to.
+	// When non-empty, strict mode is enabled and any other target IP is refused.
+	AllowedTargets []string
+}
+
+// ValidateTargetIP checks an IP exposure target against the strict mode allowlist.
+//
+// Entries in AllowedTargets are either literal IP addresses or host interface
+// names, which permit every address currently assigned to that interface.
+// Interfaces are resolved on each call so address changes are picked up.
+// Unspecified addresses (0.0.0.0, ::) only pass if listed literally.
+func (c NetworkExposureConfig) ValidateTargetIP(targetIP string) error {
+	if len(c.AllowedTargets) == 0 {
+		return nil
+	}
+
+	ip := net.ParseIP(targetIP)
+	if ip == nil {
+		return fmt.Errorf("invalid target IP address: %s", targetIP)
+	}
+
+	for _, allowed := range c.AllowedTargets {
+		if allowedIP := net.ParseIP(allowed); allowedIP != nil {
+			if allowedIP.Equal(ip) {
+				return nil
+			}
+			continue
+		}
+
+		if ip.IsUnspecified() {
+			continue
+		}
+
+		iface, err := net.InterfaceByName(allowed)
+		if err != nil {
+			log.Printf("Warning: Allowed exposure interface %s not found: %v", allowed, err)
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil {
+			log.Printf("Warning: Failed to get addresses for interface %s: %v", allowed, err)
+			continue
+		}
+
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("target IP %s is not in the allowed exposure targets %v", targetIP, c.AllowedTargets)
 }
 
 // ServiceExposure represents an I2P service exposure configuration.
@@ -77,10 +155,25 @@ type ServiceExposure struct {
 	Tunnel *i2p.Tunnel
 	// Destination is the I2P destination address (.b32.i2p format) or IP:port for IP exposure
 	Destination string
+	// Addresses are the encodings of the I2P destination (nil for IP exposure)
+	Addresses *i2p.Addresses
 	// TunnelName is the internal name for the tunnel
 	TunnelName string
 	// Forwarder handles port forwarding for IP exposure (nil for I2P exposure)
 	Forwarder *PortForwarder
+	// ExpiresAt is when the exposure is removed (zero if it has no TTL)
+	ExpiresAt time.Time
+	// CreatedAt is when the exposure was created
+	CreatedAt time.Time
+
+	// expiry removes the exposure when its TTL elapses (nil if it has no TTL)
+	expiry *time.Timer
+	// networkID is the network the exposure was created for
+	networkID string
+	// containerIP is the container address the exposure forwards to
+	containerIP net.IP
+	// group is the exposure group serving the port (nil unless Port.Group is set)
+	group *exposureGroup
 }
 
 // PortForwarder manages TCP/UDP port forwarding from host to container.
@@ -91,16 +184,31 @@ type PortForwarder struct {
 	listener net.Listener
 	// packetConn handles UDP packets on the host interface (nil for TCP)
 	packetConn net.PacketConn
+	// listenAddr is the bound host address, used to verify release on stop
+	listenAddr string
 	// targetAddr is the container IP:port to forward to
 	targetAddr string
+	// balancer picks the target of each connection instead of targetAddr (exposure groups only)
+	balancer *balancer
 	// ctx provides cancellation context
 	ctx context.Context
 	// cancel cancels the context
 	cancel context.CancelFunc
 	// wg tracks active forwarding goroutines
 	wg sync.WaitGroup
+	// conns tracks open TCP connections so Stop can close them
+	conns map[net.Conn]struct{}
+	// connsMutex protects conns
+	connsMutex sync.Mutex
+	// stopOnce ensures the forwarder is only torn down once
+	stopOnce sync.Once
+	// stopErr is the result of the first Stop call
+	stopErr error
 }
 
+// portReleaseTimeout is how long Stop waits for the host port to become bindable again.
+const portReleaseTimeout = 2 * time.Second
+
 // ServiceExposureManager manages I2P service exposure for containers.
 //
 // The manager handles automatic detection of exposed ports, creation of
@@ -112,6 +220,52 @@ type ServiceExposureManager struct {
 	// exposures tracks all active service exposures by container ID
 	exposures map[string][]*ServiceExposure
 
+	// portMaps tracks active client port maps by container ID
+	portMaps map[string][]*PortMapping
+
+	// publisher is notified of I2P exposure addresses (nil disables publication)
+	publisher Publisher
+
+	// onExpire is notified when an exposure is removed because its TTL elapsed
+	onExpire ExpiryHandler
+
+	// pending tracks exposures waiting for their dependency ports by container ID
+	pending map[string]*pendingExposures
+
+	// onReady is notified when a deferred exposure has been created
+	onReady ReadyHandler
+
+	// concurrency is the number of exposures created in parallel (0 selects the default)
+	concurrency int
+
+	// stopped is set while exposures are stopped with Stop
+	stopped bool
+
+	// held tracks the ports to expose again on Start by container ID
+	held map[string]*heldExposures
+
+	// backlog tracks the I2P ports queued while the router is overloaded by container ID
+	backlog map[string]*heldExposures
+
+	// i2pFailures counts consecutive failed I2P exposures
+	i2pFailures int
+
+	// draining is set while the backlog is retried in the background
+	draining bool
+
+	// groups tracks the ports of exposure groups by group name and port
+	groups map[string]*exposureGroup
+
+	// onDemand tracks the containers of on-demand exposures by container ID
+	onDemand map[string]*onDemandContainer
+
+	// runner starts and stops on-demand containers (nil if none is configured)
+	runner ContainerRunner
+
+	// groupsMutex protects groups, onDemand and runner. Members join from
+	// the exposure workers, which do not hold mutex.
+	groupsMutex sync.Mutex
+
 	// mutex protects concurrent access to exposures
 	mutex sync.RWMutex
 
@@ -122,21 +276,44 @@ type ServiceExposureManager struct {
 	cancel context.CancelFunc
 }
 
+// ExposureManagerOptions configures a ServiceExposureManager.
+//
+// Only TunnelManager is required. The other fields default to creating
+// DefaultExposureConcurrency exposures in parallel, publishing no addresses
+// and notifying nobody; they can also be set later with the manager's setters.
+type ExposureManagerOptions struct {
+	// TunnelManager creates the I2P server tunnels of exposed services
+	TunnelManager *i2p.TunnelManager
+	// Concurrency is the number of exposures of a container created in parallel
+	Concurrency int
+	// Publisher is notified of I2P exposure addresses (nil disables publication)
+	Publisher Publisher
+	// OnReady is called after an exposure held back by its dependencies has been created
+	OnReady ReadyHandler
+	// OnExpire is called after an exposure has been removed because its TTL elapsed
+	OnExpire ExpiryHandler
+}
+
 // NewServiceExposureManager creates a new service exposure manager.
 //
 // The manager requires a TunnelManager to create I2P server tunnels for exposed services.
-func NewServiceExposureManager(tunnelMgr *i2p.TunnelManager) (*ServiceExposureManager, error) {
-	if tunnelMgr == nil {
+func NewServiceExposureManager(options ExposureManagerOptions) (*ServiceExposureManager, error) {
+	if options.TunnelManager == nil {
 		return nil, fmt.Errorf("tunnel manager cannot be nil")
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &ServiceExposureManager{
-		tunnelMgr: tunnelMgr,
-		exposures: make(map[string][]*ServiceExposure),
-		ctx:       ctx,
-		cancel:    cancel,
+		tunnelMgr:   options.TunnelManager,
+		exposures:   make(map[string][]*ServiceExposure),
+		portMaps:    make(map[string][]*PortMapping),
+		concurrency: options.Concurrency,
+		publisher:   options.Publisher,
+		onReady:     options.OnReady,
+		onExpire:    options.OnExpire,
+		ctx:         ctx,
+		cancel:      cancel,
 	}, nil
 }
 
@@ -146,11 +323,13 @@ func NewServiceExposureManager(tunnelMgr *i2p.TunnelManager) (*ServiceExposureMa
 // identify ports that should be exposed over I2P. It supports various formats
 // with priority-based configuration:
 // 1. Docker labels (i2p.expose.*) - highest priority
-// 2. Docker EXPOSE directives - medium priority
-// 3. Environment variables indicating exposed services - lowest priority
+// 2. Exposure presets (i2p.preset) - high priority
+// 3. Docker EXPOSE directives - medium priority
+// 4. Environment variables indicating exposed services - lowest priority
 //
 // Labels allow explicit control over exposure type (i2p or ip) and take
-// precedence over automatically detected ports. Ports detected from EXPOSE
+// precedence over automatically detected ports. A label for a preset port
+// keeps the preset's tunnel options and HTTP headers. Ports detected from EXPOSE
 // directives and environment variables default to I2P exposure for backward
 // compatibility.
 func (sem *ServiceExposureManager) DetectExposedPorts(containerID string, options map[string]interface{}) ([]ExposedPort, error) {
@@ -158,6 +337,26 @@ func (sem *ServiceExposureManager) DetectExposedPorts(containerID string, option
 		return nil, fmt.Errorf("container ID cannot be empty")
 	}
 
+	strategy, err := tunnelStrategy(options)
+	if err != nil {
+		return nil, err
+	}
+	group, balance, err := exposureGroupConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	idle, err := onDemandConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	if idle > 0 {
+		if group != "" {
+			return nil, fmt.Errorf("%s cannot be combined with %s", OnDemandLabel, ExposureGroupLabel)
+		}
+		// The ports are served from a group the container is the only member of
+		group, balance = OnDemandGroupName(containerID), BalanceRoundRobin
+	}
+
 	var ports []ExposedPort
 
 	// 1. Check for explicit label-based configuration (highest priority)
@@ -165,7 +364,19 @@ func (sem *ServiceExposureManager) DetectExposedPorts(containerID string, option
 		ports = append(ports, labelPorts...)
 	}
 
-	// 2. Check for exposed ports in container options (medium priority)
+	// 2. Expand exposure presets, letting labels override preset ports
+	for _, port := range sem.extractPortsFromPresets(options) {
+		if i := findConfiguredPort(port.ContainerPort, port.ExposureType, ports); i >= 0 {
+			if ports[i].Preset == "" {
+				preset, _ := LookupPreset(port.Preset)
+				applyPreset(&ports[i], preset)
+			}
+			continue
+		}
+		ports = append(ports, port)
+	}
+
+	// 3. Check for exposed ports in container options (medium priority)
 	if exposedPorts := sem.extractPortsFromOptions(options); len(exposedPorts) > 0 {
 		// Add ports not already configured via labels with the same exposure type
 		// This allows same port with different exposure types (e.g., both I2P and IP)
@@ -178,7 +389,7 @@ func (sem *ServiceExposureManager) DetectExposedPorts(containerID string, option
 		}
 	}
 
-	// 3. Check for environment variables indicating services (lowest priority)
+	// 4. Check for environment variables indicating services (lowest priority)
 	if envPorts := sem.extractPortsFromEnvironment(options); len(envPorts) > 0 {
 		for _, port := range envPorts {
 			// Default to I2P exposure for auto-detected ports (backward compatibility)
@@ -189,10 +400,21 @@ func (sem *ServiceExposureManager) DetectExposedPorts(containerID string, option
 		}
 	}
 
-	// Deduplicate ports with exposure type consideration
+	// Deduplicate ports with exposure type consideration and attach the
+	// dependencies declared with i2p.expose.<port>.after labels
+	dependencies := extractPortDependencies(options)
 	seen := make(map[string]bool)
 	var uniquePorts []ExposedPort
 	for _, port := range ports {
+		if after, ok := dependencies[port.ContainerPort]; ok {
+			port.After = after
+		}
+		if group != "" && port.ExposureType != ExposureTypeIP {
+			// The group's destination serves the port instead of the container's
+			port.Group, port.Balance, port.OnDemand = group, balance, idle
+		} else if strategy == TunnelStrategyShared && port.ExposureType != ExposureTypeIP {
+			port.SharedTunnel = true
+		}
 		// Include ExposureType in uniqueness key to allow same port with different exposure types
 		key := fmt.Sprintf("%d/%s/%s", port.ContainerPort, port.Protocol, port.ExposureType)
 		if !seen[key] {
@@ -262,11 +484,22 @@ func (sem *ServiceExposureManager) extractPortsFromEnvironment(options map[strin
 	return ports
 }
 
+// portSpecPattern matches Docker port specifications like "80/tcp" or "443/udp".
+var portSpecPattern = regexp.MustCompile(`^(\d+)/(tcp|udp)$`)
+
+// environmentPortPatterns match environment variables that advertise a port.
+var environmentPortPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^PORT=(\d+)$`),
+	regexp.MustCompile(`^HTTP_PORT=(\d+)$`),
+	regexp.MustCompile(`^HTTPS_PORT=(\d+)$`),
+	regexp.MustCompile(`^SERVICE_PORT=(\d+)$`),
+	regexp.MustCompile(`^APP_PORT=(\d+)$`),
+	regexp.MustCompile(`^SERVER_PORT=(\d+)$`),
+}
+
 // parsePortSpec parses a Docker port specification (e.g., "80/tcp", "443/tcp").
 func (sem *ServiceExposureManager) parsePortSpec(portSpec string) *ExposedPort {
-	// Match pattern like "80/tcp" or "443/udp"
-	re := regexp.MustCompile(`^(\d+)/(tcp|udp)$`)
-	matches := re.FindStringSubmatch(portSpec)
+	matches := portSpecPattern.FindStringSubmatch(portSpec)
 	if len(matches) != 3 {
 		return nil
 	}
@@ -331,17 +564,7 @@ func (sem *ServiceExposureManager) parsePortMapping(portData map[string]interfac
 // parseEnvironmentPort parses environment variables for port information.
 func (sem *ServiceExposureManager) parseEnvironmentPort(envVar string) *ExposedPort {
 	// Look for patterns like "PORT=8080", "HTTP_PORT=80", "SERVICE_PORT=3000"
-	portPatterns := []string{
-		`^PORT=(\d+)$`,
-		`^HTTP_PORT=(\d+)$`,
-		`^HTTPS_PORT=(\d+)$`,
-		`^SERVICE_PORT=(\d+)$`,
-		`^APP_PORT=(\d+)$`,
-		`^SERVER_PORT=(\d+)$`,
-	}
-
-	for _, pattern := range portPatterns {
-		re := regexp.MustCompile(pattern)
+	for _, re := range environmentPortPatterns {
 		matches := re.FindStringSubmatch(envVar)
 		if len(matches) == 2 {
 			port, err := strconv.Atoi(matches[1])
@@ -379,6 +602,8 @@ func (sem *ServiceExposureManager) parseEnvironmentPort(envVar string) *ExposedP
 // determine how ports should be exposed. Label format:
 //   - i2p.expose.80=i2p          (expose port 80 to I2P network)
 //   - i2p.expose.443=ip:127.0.0.1 (expose port 443 to localhost IP)
+//
+// Dependency labels (i2p.expose.443.after=80) are read by extractPortDependencies.
 func (sem *ServiceExposureManager) extractPortsFromLabels(options map[string]interface{}) []ExposedPort {
 	var ports []ExposedPort
 
@@ -386,7 +611,8 @@ func (sem *ServiceExposureManager) extractPortsFromLabels(options map[string]int
 	if labels, ok := options["Labels"]; ok {
 		if labelMap, ok := labels.(map[string]interface{}); ok {
 			for key, value := range labelMap {
-				if strings.HasPrefix(key, "i2p.expose.") {
+				if strings.HasPrefix(key, "i2p.expose.") && !strings.HasSuffix(key, afterLabelSuffix) &&
+					key != ExposureGroupLabel && key != ExposureBalanceLabel && key != OnDemandLabel {
 					if port := sem.parseExposureLabel(key, value); port != nil {
 						ports = append(ports, *port)
 					}
@@ -403,24 +629,37 @@ func (sem *ServiceExposureManager) extractPortsFromLabels(options map[string]int
 // Label formats supported:
 //   - i2p.expose.80=i2p          (expose port 80 to I2P)
 //   - i2p.expose.443=ip:127.0.0.1 (expose port 443 to localhost)
+//   - i2p.expose.8080=i2p;ttl=2h  (remove the exposure after two hours)
+//   - i2p.expose.80=i2p;inbound_only=true (inbound-only server tunnel)
 //
 // Returns nil if the label format is invalid.
 func (sem *ServiceExposureManager) parseExposureLabel(key string, value interface{}) *ExposedPort {
 	// Extract port number from label key (e.g., "i2p.expose.80" -> "80")
+	// Label keys come from untrusted containers, so only plain decimal ports
+	// are accepted (strconv.Atoi alone would allow signs like "+80") and keys
+	// are quoted when logged.
 	portStr := strings.TrimPrefix(key, "i2p.expose.")
+	if !isDecimal(portStr) {
+		log.Printf("Warning: Invalid port in label %q", key)
+		return nil
+	}
 	port, err := strconv.Atoi(portStr)
 	if err != nil || port <= 0 || port > 65535 {
-		log.Printf("Warning: Invalid port in label %s: %v", key, err)
+		log.Printf("Warning: Invalid port in label %q: %v", key, err)
 		return nil
 	}
 
 	// Parse value (exposure configuration)
 	valueStr, ok := value.(string)
 	if !ok {
-		log.Printf("Warning: Invalid value type for label %s", key)
+		log.Printf("Warning: Invalid value type for label %q", key)
 		return nil
 	}
 
+	// Split off exposure options, e.g. "i2p;ttl=2h"
+	options := strings.Split(valueStr, ";")
+	valueStr = options[0]
+
 	// Parse exposure configuration
 	// Format: "i2p" or "ip:127.0.0.1"
 	parts := strings.SplitN(valueStr, ":", 2)
@@ -428,7 +667,7 @@ func (sem *ServiceExposureManager) parseExposureLabel(key string, value interfac
 
 	// Validate exposure type
 	if exposureType != ExposureTypeI2P && exposureType != ExposureTypeIP {
-		log.Printf("Warning: Invalid exposure type in label %s: %s", key, exposureType)
+		log.Printf("Warning: Invalid exposure type in label %q: %q", key, exposureType)
 		return nil
 	}
 
@@ -444,17 +683,37 @@ func (sem *ServiceExposureManager) parseExposureLabel(key string, value interfac
 
 	// Validate IP address format when provided and not empty
 	if targetIP != "" && net.ParseIP(targetIP) == nil {
-		log.Printf("Warning: Invalid target IP in label %s: %s", key, targetIP)
+		log.Printf("Warning: Invalid target IP in label %q: %q", key, targetIP)
 		return nil
 	}
 
-	return &ExposedPort{
+	exposedPort := &ExposedPort{
 		ContainerPort: port,
 		Protocol:      "tcp",
 		ServiceName:   fmt.Sprintf("service-%d", port),
 		ExposureType:  exposureType,
 		TargetIP:      targetIP,
 	}
+
+	if err := applyExposureOptions(exposedPort, options[1:]); err != nil {
+		log.Printf("Warning: Invalid option in label %q: %v", key, err)
+		return nil
+	}
+
+	return exposedPort
+}
+
+// isDecimal reports whether s is a non-empty string of ASCII digits.
+func isDecimal(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
 }
 
 // isPortConfigured checks if a port with a specific exposure type is already configured.
@@ -468,12 +727,18 @@ func (sem *ServiceExposureManager) parseExposureLabel(key string, value interfac
 // This allows the same port number to be exposed with different exposure types
 // (e.g., port 80 can be exposed via both I2P and IP simultaneously).
 func (sem *ServiceExposureManager) isPortConfigured(port int, exposureType ExposureType, configuredPorts []ExposedPort) bool {
-	for _, p := range configuredPorts {
+	return findConfiguredPort(port, exposureType, configuredPorts) >= 0
+}
+
+// findConfiguredPort returns the index of the port configured with a specific
+// exposure type, or -1.
+func findConfiguredPort(port int, exposureType ExposureType, configuredPorts []ExposedPort) int {
+	for i, p := range configuredPorts {
 		if p.ContainerPort == port && p.ExposureType == exposureType {
-			return true
+			return i
 		}
 	}
-	return false
+	return -1
 }
 
 // isPortConfiguredAny checks if a port is configured with any exposure type.
@@ -518,7 +783,7 @@ func (sem *ServiceExposureManager) createIPServiceExposure(containerID string, c
 	}
 
 	// Generate unique exposure name
-	exposureName := fmt.Sprintf("ip-%s-%s-%d", containerID, port.ServiceName, port.ContainerPort)
+	exposureName := ExposureTunnelName(containerID, port)
 
 	// Format listen address (brackets needed for IPv6 in net.Listen)
 	listenAddr := fmt.Sprintf("%s:%d", targetIP, hostPort)
@@ -534,10 +799,7 @@ func (sem *ServiceExposureManager) createIPServiceExposure(containerID string, c
 	containerAddr := fmt.Sprintf("%s:%d", containerIP.String(), port.ContainerPort)
 
 	// Determine protocol (default to TCP if not specified)
-	protocol := strings.ToLower(port.Protocol)
-	if protocol == "" {
-		protocol = "tcp"
-	}
+	protocol := normalizeProtocol(port.Protocol)
 
 	// Create port forwarder with protocol support
 	forwarder, err := newPortForwarder(protocol, listenAddr, containerAddr)
@@ -566,6 +828,7 @@ func newPortForwarder(protocol, listenAddr, targetAddr string) (*PortForwarder,
 		targetAddr: targetAddr,
 		ctx:        ctx,
 		cancel:     cancel,
+		conns:      make(map[net.Conn]struct{}),
 	}
 
 	switch protocol {
@@ -576,6 +839,7 @@ func newPortForwarder(protocol, listenAddr, targetAddr string) (*PortForwarder,
 			return nil, fmt.Errorf("failed to listen on tcp %s: %w", listenAddr, err)
 		}
 		pf.listener = listener
+		pf.listenAddr = listener.Addr().String()
 
 		// Start accepting TCP connections
 		pf.wg.Add(1)
@@ -588,6 +852,7 @@ func newPortForwarder(protocol, listenAddr, targetAddr string) (*PortForwarder,
 			return nil, fmt.Errorf("failed to listen on udp %s: %w", listenAddr, err)
 		}
 		pf.packetConn = packetConn
+		pf.listenAddr = packetConn.LocalAddr().String()
 
 		// Start forwarding UDP packets
 		pf.wg.Add(1)
@@ -601,6 +866,25 @@ func newPortForwarder(protocol, listenAddr, targetAddr string) (*PortForwarder,
 	return pf, nil
 }
 
+// newBalancedForwarder starts forwarding the TCP connections accepted by
+// listener to the members of an exposure group.
+func newBalancedForwarder(listener net.Listener, b *balancer) *PortForwarder {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	pf := &PortForwarder{
+		protocol: "tcp",
+		listener: listener,
+		balancer: b,
+		ctx:      ctx,
+		cancel:   cancel,
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	pf.wg.Add(1)
+	go pf.acceptLoop()
+	return pf
+}
+
 // acceptLoop accepts incoming connections and forwards them to the target.
 func (pf *PortForwarder) acceptLoop() {
 	defer pf.wg.Done()
@@ -628,23 +912,49 @@ func (pf *PortForwarder) handleConnection(clientConn net.Conn) {
 	defer pf.wg.Done()
 	defer clientConn.Close()
 
+	if !pf.trackConn(clientConn) {
+		return
+	}
+	defer pf.untrackConn(clientConn)
+
 	// Connect to container
-	targetConn, err := net.DialTimeout("tcp", pf.targetAddr, 10*time.Second)
+	targetConn, targetAddr, release, err := pf.dialTarget()
 	if err != nil {
-		log.Printf("Failed to connect to target %s: %v", pf.targetAddr, err)
+		log.Printf("Failed to connect to target %s: %v", targetAddr, err)
 		return
 	}
+	defer release()
 	defer targetConn.Close()
 
+	if !pf.trackConn(targetConn) {
+		return
+	}
+	defer pf.untrackConn(targetConn)
+
 	// Use go-forward to handle bidirectional forwarding
 	cfg := config.DefaultConfig()
 	cfg.EnableMetrics = false // Disable metrics for simplicity
 
 	if err := stream.Forward(pf.ctx, clientConn, targetConn, cfg); err != nil {
 		if err != context.Canceled && err != io.EOF {
-			log.Printf("TCP forwarding error for %s: %v", pf.targetAddr, err)
+			log.Printf("TCP forwarding error for %s: %v", targetAddr, err)
+		}
+	}
+}
+
+// dialTarget connects to the target of a TCP connection and returns its
+// address and a function to call once the connection is closed.
+func (pf *PortForwarder) dialTarget() (net.Conn, string, func(), error) {
+	if pf.balancer != nil {
+		conn, addr, release, err := pf.balancer.dial("tcp")
+		if err != nil {
+			return nil, "group members", nil, err
 		}
+		return conn, addr, release, nil
 	}
+
+	conn, err := net.DialTimeout("tcp", pf.targetAddr, 10*time.Second)
+	return conn, pf.targetAddr, func() {}, err
 }
 
 // forwardPackets handles UDP packet forwarding between host and container.
@@ -696,34 +1006,125 @@ func (u *udpTargetConn) WriteTo(p []byte, _ net.Addr) (n int, err error) {
 	return u.PacketConn.WriteTo(p, u.targetAddr)
 }
 
+// trackConn registers an open connection so Stop can close it.
+//
+// Returns false if the forwarder is already stopping, in which case the
+// caller should drop the connection.
+func (pf *PortForwarder) trackConn(conn net.Conn) bool {
+	pf.connsMutex.Lock()
+	defer pf.connsMutex.Unlock()
+
+	if pf.conns == nil {
+		return false
+	}
+	pf.conns[conn] = struct{}{}
+	return true
+}
+
+// untrackConn removes a connection registered with trackConn.
+func (pf *PortForwarder) untrackConn(conn net.Conn) {
+	pf.connsMutex.Lock()
+	defer pf.connsMutex.Unlock()
+
+	if pf.conns != nil {
+		delete(pf.conns, conn)
+	}
+}
+
 // Stop stops the port forwarder and waits for all connections to close.
+//
+// Open connections are closed rather than drained so a stuck peer cannot
+// hold the listener. After all goroutines exit, Stop verifies that the host
+// port can be bound again. Calling Stop more than once is safe.
 func (pf *PortForwarder) Stop() error {
+	pf.stopOnce.Do(func() {
+		pf.stopErr = pf.stop()
+	})
+	return pf.stopErr
+}
+
+// stop performs the forwarder teardown for Stop.
+func (pf *PortForwarder) stop() error {
 	pf.cancel()
 
+	var errs []string
+
 	// Close listener/packet connection to stop accepting new connections
 	if pf.listener != nil {
-		if err := pf.listener.Close(); err != nil {
-			log.Printf("Error closing TCP listener: %v", err)
+		if err := pf.listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, fmt.Sprintf("failed to close TCP listener: %v", err))
 		}
 	}
 	if pf.packetConn != nil {
-		if err := pf.packetConn.Close(); err != nil {
-			log.Printf("Error closing UDP packet connection: %v", err)
+		if err := pf.packetConn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, fmt.Sprintf("failed to close UDP packet connection: %v", err))
 		}
 	}
 
+	// Close active connections so forwarding goroutines unblock
+	pf.connsMutex.Lock()
+	conns := pf.conns
+	pf.conns = nil
+	pf.connsMutex.Unlock()
+	for conn := range conns {
+		conn.Close()
+	}
+
 	// Wait for all forwarding goroutines to finish
 	pf.wg.Wait()
 
+	if err := pf.waitForPortRelease(); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
 	return nil
 }
 
+// waitForPortRelease verifies the host port is free by briefly binding it.
+func (pf *PortForwarder) waitForPortRelease() error {
+	if pf.listenAddr == "" {
+		return nil
+	}
+
+	deadline := time.Now().Add(portReleaseTimeout)
+	for {
+		var err error
+		switch pf.protocol {
+		case "udp":
+			var conn net.PacketConn
+			if conn, err = net.ListenPacket("udp", pf.listenAddr); err == nil {
+				conn.Close()
+			}
+		default:
+			var listener net.Listener
+			if listener, err = net.Listen("tcp", pf.listenAddr); err == nil {
+				listener.Close()
+			}
+		}
+
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("port %s/%s still in use after stop: %w", pf.listenAddr, pf.protocol, err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
 // createI2PServiceExposure creates an I2P-based service exposure.
 //
 // This method wraps the existing createServiceExposure logic and is named
 // explicitly to distinguish it from IP-based exposures. It creates an I2P
 // server tunnel that allows external I2P users to access the container service.
+// Ports of an exposure group join the group's tunnel instead.
 func (sem *ServiceExposureManager) createI2PServiceExposure(containerID string, networkID string, containerIP net.IP, port ExposedPort) (*ServiceExposure, error) {
+	if port.Group != "" {
+		return sem.joinGroup(containerID, containerIP, port)
+	}
 	return sem.createServiceExposure(containerID, networkID, containerIP, port)
 }
 
@@ -736,6 +1137,18 @@ func (sem *ServiceExposureManager) createI2PServiceExposure(containerID string,
 // The method routes each port to the appropriate exposure handler based on
 // its ExposureType field. If no ExposureType is specified, it defaults to
 // I2P exposure for backward compatibility.
+//
+// Ports with dependencies (ExposedPort.After) are not returned; they are
+// exposed in the background once the dependency ports are listening and
+// reported to the ready handler.
+//
+// Exposures are created in parallel (see SetConcurrency). A port that fails
+// does not stop the others: the exposures that were created are returned
+// together with an ExposureErrors listing the failed ports.
+//
+// While the router is overloaded, I2P ports are queued instead of created
+// and are not returned either; see QueuedExposures. They are exposed in the
+// background as the router recovers and reported to the ready handler.
 func (sem *ServiceExposureManager) ExposeServices(containerID string, networkID string, containerIP net.IP, ports []ExposedPort) ([]*ServiceExposure, error) {
 	if containerID == "" {
 		return nil, fmt.Errorf("container ID cannot be empty")
@@ -750,55 +1163,297 @@ func (sem *ServiceExposureManager) ExposeServices(containerID string, networkID
 	sem.mutex.Lock()
 	defer sem.mutex.Unlock()
 
-	var exposures []*ServiceExposure
-
+	var immediate []ExposedPort
 	for _, port := range ports {
-		var exposure *ServiceExposure
-		var err error
+		if len(port.After) > 0 {
+			sem.deferExposureLocked(containerID, networkID, containerIP, port)
+			continue
+		}
+		immediate = append(immediate, port)
+	}
 
-		// Route to appropriate exposure handler based on type
-		switch port.ExposureType {
-		case ExposureTypeI2P:
-			exposure, err = sem.createI2PServiceExposure(containerID, networkID, containerIP, port)
-		case ExposureTypeIP:
-			exposure, err = sem.createIPServiceExposure(containerID, containerIP, port)
-		default:
-			// Default to I2P for backward compatibility and unknown types
-			port.ExposureType = ExposureTypeI2P
-			exposure, err = sem.createI2PServiceExposure(containerID, networkID, containerIP, port)
+	if sem.stopped {
+		for _, port := range immediate {
+			sem.holdExposureLocked(containerID, networkID, containerIP, port)
 		}
+		log.Printf("Exposures are stopped, holding %d services of container %s until they start",
+			len(immediate), containerID)
+		return nil, nil
+	}
 
-		if err != nil {
+	// Queue I2P ports rather than building tunnels on an overloaded router
+	if sem.overloadedLocked() {
+		var direct []ExposedPort
+		for _, port := range immediate {
+			if port.ExposureType == ExposureTypeIP {
+				direct = append(direct, port)
+				continue
+			}
+			sem.queueExposureLocked(containerID, networkID, containerIP, port)
+		}
+		if queued := len(immediate) - len(direct); queued > 0 {
+			log.Printf("Router is overloaded, queued %d services of container %s until it recovers", queued, containerID)
+		}
+		immediate = direct
+	}
+
+	var exposures []*ServiceExposure
+	var failures ExposureErrors
+	var retry []ExposedPort
+
+	for i, result := range sem.createExposuresLocked(containerID, networkID, containerIP, immediate) {
+		port := immediate[i]
+		if port.ExposureType != ExposureTypeIP {
+			sem.recordI2PResultLocked(result.err)
+		}
+		if result.err != nil {
 			log.Printf("Warning: Failed to expose %s service on port %d for container %s: %v",
-				port.ExposureType, port.ContainerPort, containerID, err)
+				port.ExposureType, port.ContainerPort, containerID, result.err)
+			if port.ExposureType != ExposureTypeIP {
+				retry = append(retry, port)
+			}
+			failures = append(failures, ExposureFailure{Port: port, Err: result.err})
 			continue
 		}
 
+		exposure := result.exposure
 		exposures = append(exposures, exposure)
+		sem.publishExposureLocked(exposure)
+		sem.scheduleExpiryLocked(exposure)
 		log.Printf("Successfully exposed %s service %s for container %s on %s",
 			port.ExposureType, exposure.TunnelName, containerID, exposure.Destination)
 	}
 
-	// Store exposures for this container
-	sem.exposures[containerID] = exposures
+	// Failed I2P ports are queued instead of reported once the router is
+	// considered overloaded
+	if sem.overloadedLocked() && len(retry) > 0 {
+		remaining := failures[:0]
+		for _, failure := range failures {
+			if failure.Port.ExposureType == ExposureTypeIP {
+				remaining = append(remaining, failure)
+			}
+		}
+		failures = remaining
+		for _, port := range retry {
+			sem.queueExposureLocked(containerID, networkID, containerIP, port)
+		}
+		log.Printf("Router is overloaded, queued %d failed services of container %s until it recovers", len(retry), containerID)
+	}
+
+	// Store exposures for this container, keeping any created earlier (e.g. -p
+	// port mappings added after Join) so cleanup still reaches them
+	sem.exposures[containerID] = append(sem.exposures[containerID], exposures...)
 
 	log.Printf("Successfully exposed %d services for container %s", len(exposures), containerID)
+	if len(failures) > 0 {
+		return exposures, failures
+	}
 	return exposures, nil
 }
 
+// ExposeService creates a single service exposure for a container.
+//
+// Unlike ExposeServices, a failure is returned to the caller instead of being
+// logged and skipped, and exposing a port that is already exposed with the
+// same protocol is refused. Dependencies in port.After are not waited for.
+// I2P ports follow the tunnel strategy and exposure group of the container's
+// existing exposures.
+func (sem *ServiceExposureManager) ExposeService(containerID string, networkID string, containerIP net.IP, port ExposedPort) (*ServiceExposure, error) {
+	if containerID == "" {
+		return nil, fmt.Errorf("container ID cannot be empty")
+	}
+	if networkID == "" {
+		return nil, fmt.Errorf("network ID cannot be empty")
+	}
+	if containerIP == nil {
+		return nil, fmt.Errorf("container IP cannot be nil")
+	}
+
+	sem.mutex.Lock()
+	defer sem.mutex.Unlock()
+
+	if sem.stopped {
+		return nil, fmt.Errorf("exposures are stopped")
+	}
+	if sem.findExposureLocked(containerID, port.ContainerPort, port.Protocol) >= 0 {
+		return nil, fmt.Errorf("port %d/%s of container %s is already exposed",
+			port.ContainerPort, normalizeProtocol(port.Protocol), containerID)
+	}
+
+	if port.ExposureType != ExposureTypeIP {
+		if port.Group, port.Balance = sem.containerGroupLocked(containerID); port.Group == "" && sem.sharesTunnelLocked(containerID) {
+			port.SharedTunnel = true
+		}
+	}
+
+	exposure, err := sem.createExposure(containerID, networkID, containerIP, port)
+	if err != nil {
+		return nil, err
+	}
+
+	sem.exposures[containerID] = append(sem.exposures[containerID], exposure)
+	sem.publishExposureLocked(exposure)
+	sem.scheduleExpiryLocked(exposure)
+
+	log.Printf("Successfully exposed %s service %s for container %s on %s",
+		exposure.Port.ExposureType, exposure.TunnelName, containerID, exposure.Destination)
+	return exposure, nil
+}
+
+// UnexposeService removes the exposure of a single container port.
+//
+// The exposure's tunnel or forwarder is torn down and the removed exposure is
+// returned so callers can recreate it. An empty protocol matches TCP.
+func (sem *ServiceExposureManager) UnexposeService(containerID string, containerPort int, protocol string) (*ServiceExposure, error) {
+	sem.mutex.Lock()
+	defer sem.mutex.Unlock()
+
+	index := sem.findExposureLocked(containerID, containerPort, protocol)
+	if index < 0 {
+		return nil, fmt.Errorf("port %d/%s of container %s is not exposed",
+			containerPort, normalizeProtocol(protocol), containerID)
+	}
+
+	exposures := sem.exposures[containerID]
+	exposure := exposures[index]
+	sem.exposures[containerID] = append(exposures[:index:index], exposures[index+1:]...)
+	if len(sem.exposures[containerID]) == 0 {
+		delete(sem.exposures, containerID)
+	}
+
+	if err := sem.destroyExposure(exposure); err != nil {
+		return exposure, err
+	}
+
+	log.Printf("Removed %s service exposure %s for container %s", exposure.Port.ExposureType, exposure.TunnelName, containerID)
+	return exposure, nil
+}
+
+// createExposure routes a port to the exposure handler for its type.
+//
+// Ports without a known exposure type default to I2P exposure for backward
+// compatibility. The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) createExposure(containerID string, networkID string, containerIP net.IP, port ExposedPort) (*ServiceExposure, error) {
+	var exposure *ServiceExposure
+	var err error
+
+	switch port.ExposureType {
+	case ExposureTypeIP:
+		exposure, err = sem.createIPServiceExposure(containerID, containerIP, port)
+	default:
+		port.ExposureType = ExposureTypeI2P
+		exposure, err = sem.createI2PServiceExposure(containerID, networkID, containerIP, port)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	exposure.networkID = networkID
+	exposure.containerIP = containerIP
+	exposure.CreatedAt = time.Now()
+	return exposure, nil
+}
+
+// findExposureLocked returns the index of a container's exposure for the given
+// port and protocol, or -1. The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) findExposureLocked(containerID string, containerPort int, protocol string) int {
+	for i, exposure := range sem.exposures[containerID] {
+		if exposure.Port.ContainerPort == containerPort &&
+			normalizeProtocol(exposure.Port.Protocol) == normalizeProtocol(protocol) {
+			return i
+		}
+	}
+	return -1
+}
+
+// destroyExposure tears down the I2P tunnel or port forwarder of an exposure.
+//
+// The exposure's published address is withdrawn first so it stops being
+// advertised before the tunnel goes away. The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) destroyExposure(exposure *ServiceExposure) error {
+	var errors []string
+
+	exposure.stopExpiry()
+	sem.unpublishExposureLocked(exposure)
+
+	// Leave the exposure group, or clean up the I2P tunnel if present
+	if exposure.group != nil {
+		if err := sem.leaveGroup(exposure); err != nil {
+			errors = append(errors, err.Error())
+		}
+	} else if exposure.Tunnel != nil {
+		if err := sem.tunnelMgr.DestroyTunnel(exposure.TunnelName); err != nil {
+			errors = append(errors, fmt.Sprintf("failed to destroy tunnel %s: %v", exposure.TunnelName, err))
+		}
+	}
+
+	// Clean up port forwarder if present
+	if exposure.Forwarder != nil {
+		log.Printf("Stopping port forwarder for %s", exposure.TunnelName)
+		if err := exposure.Forwarder.Stop(); err != nil {
+			errors = append(errors, fmt.Sprintf("failed to stop forwarder %s: %v", exposure.TunnelName, err))
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("%s", strings.Join(errors, "; "))
+	}
+	return nil
+}
+
+// exposurePurpose describes an exposed port for tunnel naming, e.g. "web-80".
+func exposurePurpose(port ExposedPort) string {
+	if port.ServiceName == "" {
+		return strconv.Itoa(port.ContainerPort)
+	}
+	return fmt.Sprintf("%s-%d", port.ServiceName, port.ContainerPort)
+}
+
+// ExposureTunnelName returns the name an exposure of port is tracked under.
+//
+// I2P exposures use it as their server tunnel name; IP exposures carry an
+// "ip-" prefix since they have no tunnel. Ports of an exposure group are
+// tracked under the group's tunnel name.
+func ExposureTunnelName(containerID string, port ExposedPort) string {
+	if port.Group != "" && port.ExposureType != ExposureTypeIP {
+		return groupTunnelName(port.Group, port.ContainerPort)
+	}
+	name := i2p.TunnelName(containerID, exposurePurpose(port))
+	if port.ExposureType == ExposureTypeIP {
+		return "ip-" + name
+	}
+	return name
+}
+
+// normalizeProtocol returns the lower-case protocol name, defaulting to tcp.
+func normalizeProtocol(protocol string) string {
+	if protocol == "" {
+		return "tcp"
+	}
+	return strings.ToLower(protocol)
+}
+
 // createServiceExposure creates a single I2P service exposure.
 func (sem *ServiceExposureManager) createServiceExposure(containerID string, networkID string, containerIP net.IP, port ExposedPort) (*ServiceExposure, error) {
 	// Generate unique tunnel name
-	tunnelName := fmt.Sprintf("%s-%s-%d", containerID, port.ServiceName, port.ContainerPort)
+	purpose := exposurePurpose(port)
+	tunnelName := i2p.TunnelName(containerID, purpose)
 
 	// Create tunnel configuration
 	tunnelConfig := &i2p.TunnelConfig{
 		Name:        tunnelName,
+		Purpose:     purpose,
 		Type:        i2p.TunnelTypeServer,
 		LocalHost:   containerIP.String(),
 		LocalPort:   port.ContainerPort,
 		ContainerID: containerID,
 		Options:     i2p.DefaultTunnelOptions(),
+		HTTPHeaders: port.HTTPHeaders,
+		InboundOnly: port.InboundOnly,
+		Shared:      port.SharedTunnel,
+	}
+	if port.TunnelOptions != nil {
+		tunnelConfig.Options = *port.TunnelOptions
 	}
 
 	// Create the I2P server tunnel
@@ -820,22 +1475,24 @@ func (sem *ServiceExposureManager) createServiceExposure(containerID string, net
 		Port:        port,
 		Tunnel:      tunnel,
 		Destination: b32Address,
+		Addresses:   sem.destinationAddresses(tunnel),
 		TunnelName:  tunnelName,
 	}, nil
 }
 
 // generateB32Address generates a .b32.i2p address from an I2P destination.
 //
-// I2P destinations are base64-encoded, but .b32.i2p addresses use base32 encoding
-// with a specific format. This method converts the destination appropriately.
+// Base64 destinations get their real .b32.i2p address. Other strings, such as
+// the placeholder destinations of tests, are hashed to a deterministic
+// address of the same form.
 func (sem *ServiceExposureManager) generateB32Address(destination string) (string, error) {
 	if destination == "" {
 		return "", fmt.Errorf("destination cannot be empty")
 	}
+	if address, err := i2p.B32Address(destination); err == nil {
+		return address, nil
+	}
 
-	// Hash the destination to generate a consistent shorter address
-	// In a real I2P implementation, this would use the actual destination key
-	// For now, we'll create a deterministic hash-based address
 	hash := sha256.Sum256([]byte(destination))
 
 	// Take first 20 bytes for base32 encoding (similar to I2P's approach)
@@ -847,6 +1504,52 @@ func (sem *ServiceExposureManager) generateB32Address(destination string) (strin
 	return fmt.Sprintf("%s.b32.i2p", b32), nil
 }
 
+// destinationAddresses returns the encodings of a server tunnel's
+// destination, with its b33 address if the session's leaseset is blinded.
+// Returns nil if the destination is not a base64 destination.
+func (sem *ServiceExposureManager) destinationAddresses(tunnel *i2p.Tunnel) *i2p.Addresses {
+	config := tunnel.GetConfig()
+	var blinding *i2p.Blinding
+	if b, blinded := sem.tunnelMgr.SessionBlinding(config.ContainerID); blinded {
+		blinding = &b
+	}
+
+	addresses, err := i2p.AddressesOf(config.Destination, blinding)
+	if err != nil && blinding != nil {
+		log.Printf("Warning: No b33 address for blinded tunnel %s: %v", config.Name, err)
+		addresses, err = i2p.AddressesOf(config.Destination, nil)
+	}
+	if err != nil {
+		return nil
+	}
+	return &addresses
+}
+
+// PreviewAddress returns the .b32.i2p address an I2P exposure of port by
+// containerID would get, if its destination is known before the exposure is
+// created: the container already has a session or was given keys, or the
+// port's exposure group is already served. It returns false otherwise, and
+// for IP exposures.
+func (sem *ServiceExposureManager) PreviewAddress(containerID string, port ExposedPort) (string, bool) {
+	if port.ExposureType == ExposureTypeIP {
+		return "", false
+	}
+
+	owner := containerID
+	if port.Group != "" {
+		owner = GroupSessionID(port.Group)
+	}
+	destination, known := sem.tunnelMgr.KnownDestination(owner)
+	if !known {
+		return "", false
+	}
+	address, err := sem.generateB32Address(destination)
+	if err != nil {
+		return "", false
+	}
+	return address, true
+}
+
 // GetServiceExposures returns all service exposures for a container.
 func (sem *ServiceExposureManager) GetServiceExposures(containerID string) []*ServiceExposure {
 	sem.mutex.RLock()
@@ -875,28 +1578,39 @@ func (sem *ServiceExposureManager) CleanupServices(containerID string) error {
 	sem.mutex.Lock()
 	defer sem.mutex.Unlock()
 
+	return sem.cleanupServicesLocked(containerID)
+}
+
+// cleanupServicesLocked removes all service exposures for a container.
+//
+// I2P tunnels are destroyed and IP forwarders are stopped, closing their
+// listeners, packet connections and open streams. The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) cleanupServicesLocked(containerID string) error {
+	// Stop waiting for dependencies so no deferred exposure appears afterwards
+	sem.cancelPendingLocked(containerID)
+	delete(sem.held, containerID)
+	delete(sem.backlog, containerID)
+
+	// Stop client port maps so no new outbound streams are started
+	errors := sem.cleanupPortMaps(containerID)
+
 	exposures, exists := sem.exposures[containerID]
 	if !exists {
-		return nil // Nothing to clean up
+		if len(errors) > 0 {
+			return fmt.Errorf("cleanup errors: %s", strings.Join(errors, "; "))
+		}
+		return nil // Nothing else to clean up
 	}
 
-	var errors []string
-
-	// Clean up all tunnels and forwarders for this container
+	// Clean up all tunnels and forwarders for this container, keeping on-demand
+	// exposures reachable while the container is stopped
 	for _, exposure := range exposures {
-		// Clean up I2P tunnel if present
-		if exposure.Tunnel != nil {
-			if err := sem.tunnelMgr.DestroyTunnel(exposure.TunnelName); err != nil {
-				errors = append(errors, fmt.Sprintf("failed to destroy tunnel %s: %v", exposure.TunnelName, err))
-			}
+		if exposure.group != nil && exposure.group.balancer.demand != nil {
+			sem.parkOnDemandLocked(exposure)
+			continue
 		}
-
-		// Clean up port forwarder if present
-		if exposure.Forwarder != nil {
-			log.Printf("Stopping port forwarder for %s", exposure.TunnelName)
-			if err := exposure.Forwarder.Stop(); err != nil {
-				errors = append(errors, fmt.Sprintf("failed to stop forwarder %s: %v", exposure.TunnelName, err))
-			}
+		if err := sem.destroyExposure(exposure); err != nil {
+			errors = append(errors, err.Error())
 		}
 	}
 
@@ -920,12 +1634,21 @@ func (sem *ServiceExposureManager) Shutdown() error {
 
 	var errors []string
 
-	// Clean up all exposures
+	// Clean up all exposures and port maps, including containers that only have one kind
+	containerIDs := make(map[string]struct{})
 	for containerID := range sem.exposures {
-		if err := sem.CleanupServices(containerID); err != nil {
+		containerIDs[containerID] = struct{}{}
+	}
+	for containerID := range sem.portMaps {
+		containerIDs[containerID] = struct{}{}
+	}
+
+	for containerID := range containerIDs {
+		if err := sem.cleanupServicesLocked(containerID); err != nil {
 			errors = append(errors, fmt.Sprintf("failed to cleanup services for container %s: %v", containerID, err))
 		}
 	}
+	sem.closeOnDemandLocked("")
 
 	if len(errors) > 0 {
 		return fmt.Errorf("shutdown errors: %s", strings.Join(errors, "; "))
@@ -6,18 +6,14 @@ import (
 	"net"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
 )
 
 func TestNewServiceExposureManager(t *testing.T) {
 	// Create a mock tunnel manager
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
 
 	tests := []struct {
 		name          string
@@ -40,7 +36,7 @@ func TestNewServiceExposureManager(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			manager, err := NewServiceExposureManager(tt.tunnelMgr)
+			manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tt.tunnelMgr})
 
 			if tt.shouldError {
 				if err == nil {
@@ -61,13 +57,8 @@ func TestNewServiceExposureManager(t *testing.T) {
 }
 
 func TestDetectExposedPorts(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -160,13 +151,8 @@ func TestDetectExposedPorts(t *testing.T) {
 }
 
 func TestParsePortSpec(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -245,13 +231,8 @@ func TestParsePortSpec(t *testing.T) {
 }
 
 func TestParseEnvironmentPort(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -340,13 +321,8 @@ func TestParseEnvironmentPort(t *testing.T) {
 }
 
 func TestGenerateB32Address(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -415,8 +391,8 @@ func TestExposeServices(t *testing.T) {
 	}
 	defer samClient.Disconnect()
 
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -468,13 +444,8 @@ func TestExposeServices(t *testing.T) {
 }
 
 func TestGetServiceExposures(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -490,13 +461,8 @@ func TestGetServiceExposures(t *testing.T) {
 }
 
 func TestCleanupServices(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -540,13 +506,8 @@ func TestCleanupServices(t *testing.T) {
 }
 
 func TestShutdown(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -560,13 +521,8 @@ func TestShutdown(t *testing.T) {
 
 // Benchmark tests
 func BenchmarkGenerateB32Address(b *testing.B) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		b.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		b.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -583,13 +539,8 @@ func BenchmarkGenerateB32Address(b *testing.B) {
 }
 
 func BenchmarkDetectExposedPorts(b *testing.B) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		b.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		b.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -618,13 +569,8 @@ func BenchmarkDetectExposedPorts(b *testing.B) {
 
 // TestParseExposureLabel tests the label parsing functionality for port exposure configuration.
 func TestParseExposureLabel(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -786,13 +732,8 @@ func TestParseExposureLabel(t *testing.T) {
 
 // TestExtractPortsFromLabels tests extraction of port configurations from Docker labels.
 func TestExtractPortsFromLabels(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -949,15 +890,44 @@ func TestExposureTypeConstants(t *testing.T) {
 	}
 }
 
-// BenchmarkParseExposureLabel benchmarks label parsing performance.
-func BenchmarkParseExposureLabel(b *testing.B) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		b.Fatalf("Failed to create SAM client: %v", err)
+// TestValidateTargetIP tests the strict mode exposure target allowlist.
+func TestValidateTargetIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		allowed   []string
+		targetIP  string
+		expectErr bool
+	}{
+		{"no allowlist permits anything", nil, "0.0.0.0", false},
+		{"listed IP", []string{"127.0.0.1", "10.0.5.2"}, "10.0.5.2", false},
+		{"unlisted IP", []string{"127.0.0.1"}, "192.168.1.10", true},
+		{"wildcard refused", []string{"127.0.0.1"}, "0.0.0.0", true},
+		{"IPv6 wildcard refused", []string{"127.0.0.1"}, "::", true},
+		{"wildcard listed literally", []string{"0.0.0.0"}, "0.0.0.0", false},
+		{"loopback interface", []string{"lo"}, "127.0.0.1", false},
+		{"wildcard not matched by interface", []string{"lo"}, "0.0.0.0", true},
+		{"unknown interface", []string{"does-not-exist0"}, "127.0.0.1", true},
+		{"invalid target", []string{"127.0.0.1"}, "not-an-ip", true},
 	}
 
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NetworkExposureConfig{AllowIPExposure: true, AllowedTargets: tt.allowed}
+			err := config.ValidateTargetIP(tt.targetIP)
+			if tt.expectErr && err == nil {
+				t.Errorf("Expected %s to be refused", tt.targetIP)
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("Expected %s to be allowed: %v", tt.targetIP, err)
+			}
+		})
+	}
+}
+
+// BenchmarkParseExposureLabel benchmarks label parsing performance.
+func BenchmarkParseExposureLabel(b *testing.B) {
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		b.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -970,13 +940,8 @@ func BenchmarkParseExposureLabel(b *testing.B) {
 
 // BenchmarkExtractPortsFromLabels benchmarks label extraction performance.
 func BenchmarkExtractPortsFromLabels(b *testing.B) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		b.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		b.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -998,13 +963,8 @@ func BenchmarkExtractPortsFromLabels(b *testing.B) {
 
 // TestDetectExposedPortsWithLabels tests the enhanced DetectExposedPorts with label integration.
 func TestDetectExposedPortsWithLabels(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -1212,13 +1172,8 @@ func TestDetectExposedPortsWithLabels(t *testing.T) {
 
 // TestIsPortConfigured tests the port configuration check helper.
 func TestIsPortConfigured(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -1285,13 +1240,8 @@ func TestIsPortConfigured(t *testing.T) {
 
 // TestCreateIPServiceExposure tests IP-based service exposure creation.
 func TestCreateIPServiceExposure(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -1481,8 +1431,8 @@ func TestExposeServicesWithMixedTypes(t *testing.T) {
 	}
 	defer samClient.Disconnect()
 
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -1588,8 +1538,8 @@ func TestExposeServicesDefaultType(t *testing.T) {
 	}
 	defer samClient.Disconnect()
 
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -1634,13 +1584,8 @@ func TestExposeServicesDefaultType(t *testing.T) {
 
 // TestUDPPortForwarding tests UDP port forwarding functionality.
 func TestUDPPortForwarding(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -1683,13 +1628,8 @@ func TestUDPPortForwarding(t *testing.T) {
 
 // TestTCPAndUDPMixedForwarding tests both TCP and UDP exposures in same container.
 func TestTCPAndUDPMixedForwarding(t *testing.T) {
-	samClient, err := i2p.NewSAMClient(i2p.DefaultSAMConfig())
-	if err != nil {
-		t.Fatalf("Failed to create SAM client: %v", err)
-	}
-
-	tunnelMgr := i2p.NewTunnelManager(samClient)
-	manager, err := NewServiceExposureManager(tunnelMgr)
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: i2p.DefaultSAMConfig()})
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: tunnelMgr})
 	if err != nil {
 		t.Fatalf("Failed to create service exposure manager: %v", err)
 	}
@@ -1751,3 +1691,283 @@ func TestTCPAndUDPMixedForwarding(t *testing.T) {
 		}
 	}
 }
+
+// newForwarderTestManager creates a service manager that does not need a SAM bridge.
+func newForwarderTestManager(t *testing.T) *ServiceExposureManager {
+	t.Helper()
+
+	manager, err := NewServiceExposureManager(ExposureManagerOptions{TunnelManager: i2p.NewTunnelManager(i2p.TunnelManagerOptions{})})
+	if err != nil {
+		t.Fatalf("Failed to create service exposure manager: %v", err)
+	}
+	return manager
+}
+
+// startEchoServer starts a TCP server that echoes until the client disconnects.
+func startEchoServer(t *testing.T) net.Listener {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to start echo server: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 1024)
+				for {
+					n, err := conn.Read(buf)
+					if err != nil {
+						return
+					}
+					conn.Write(buf[:n])
+				}
+			}()
+		}
+	}()
+
+	return listener
+}
+
+// freePort returns a port that is currently free on 127.0.0.1.
+func freePort(t *testing.T, network string) int {
+	t.Helper()
+
+	if network == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to find free UDP port: %v", err)
+		}
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).Port
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find free TCP port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+// TestPortForwarderStopClosesConnections tests that Stop closes open streams and frees the port.
+func TestPortForwarderStopClosesConnections(t *testing.T) {
+	backend := startEchoServer(t)
+
+	forwarder, err := newPortForwarder("tcp", "127.0.0.1:0", backend.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", forwarder.listenAddr)
+	if err != nil {
+		t.Fatalf("Failed to connect to forwarder: %v", err)
+	}
+	defer conn.Close()
+
+	// Round-trip once so the forwarding goroutine is active
+	conn.Write([]byte("ping"))
+	buf := make([]byte, 4)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(buf); err != nil {
+		t.Fatalf("Failed to read through forwarder: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- forwarder.Stop() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Stop returned error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Stop blocked on an open connection")
+	}
+
+	// The client side observes the connection closing
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(buf); err == nil {
+		t.Error("Expected forwarded connection to be closed")
+	}
+
+	// The port is free again
+	listener, err := net.Listen("tcp", forwarder.listenAddr)
+	if err != nil {
+		t.Fatalf("Expected port %s to be released: %v", forwarder.listenAddr, err)
+	}
+	listener.Close()
+
+	// Stop is idempotent
+	if err := forwarder.Stop(); err != nil {
+		t.Errorf("Second Stop returned error: %v", err)
+	}
+}
+
+// TestPortForwarderStopReleasesUDPPort tests that Stop closes the UDP packet connection.
+func TestPortForwarderStopReleasesUDPPort(t *testing.T) {
+	forwarder, err := newPortForwarder("udp", "127.0.0.1:0", "127.0.0.1:9")
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %v", err)
+	}
+
+	if err := forwarder.Stop(); err != nil {
+		t.Errorf("Stop returned error: %v", err)
+	}
+
+	conn, err := net.ListenPacket("udp", forwarder.listenAddr)
+	if err != nil {
+		t.Fatalf("Expected UDP port %s to be released: %v", forwarder.listenAddr, err)
+	}
+	conn.Close()
+}
+
+// TestCleanupServicesMixedExposures tests cleanup for a container with I2P and IP exposures.
+func TestCleanupServicesMixedExposures(t *testing.T) {
+	manager := newForwarderTestManager(t)
+	backend := startEchoServer(t)
+	backendPort := backend.Addr().(*net.TCPAddr).Port
+	containerIP := net.ParseIP("127.0.0.1")
+
+	tcpPort := freePort(t, "tcp")
+	udpPort := freePort(t, "udp")
+
+	// Label/EXPOSE exposures created during Join
+	_, err := manager.ExposeServices("container1", "net1", containerIP, []ExposedPort{
+		{ContainerPort: backendPort, HostPort: tcpPort, Protocol: "tcp", ServiceName: "web", ExposureType: ExposureTypeIP, TargetIP: "127.0.0.1"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to expose services: %v", err)
+	}
+
+	// Port mappings created after Join must not replace earlier exposures
+	_, err = manager.ExposeServices("container1", "net1", containerIP, []ExposedPort{
+		{ContainerPort: backendPort, HostPort: udpPort, Protocol: "udp", ServiceName: "dns", ExposureType: ExposureTypeIP, TargetIP: "127.0.0.1"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to expose port mapping: %v", err)
+	}
+
+	// Simulate an I2P exposure whose tunnel is already gone
+	manager.mutex.Lock()
+	manager.exposures["container1"] = append(manager.exposures["container1"], &ServiceExposure{
+		ContainerID: "container1",
+		Port:        ExposedPort{ContainerPort: 80, ExposureType: ExposureTypeI2P},
+		Tunnel:      &i2p.Tunnel{},
+		TunnelName:  "container1-http-80",
+	})
+	manager.mutex.Unlock()
+
+	if exposures := manager.GetServiceExposures("container1"); len(exposures) != 3 {
+		t.Fatalf("Expected 3 tracked exposures, got %d", len(exposures))
+	}
+
+	// Tunnel teardown fails, but forwarders must still be stopped
+	err = manager.CleanupServices("container1")
+	if err == nil || !strings.Contains(err.Error(), "container1-http-80") {
+		t.Errorf("Expected tunnel cleanup error, got %v", err)
+	}
+
+	if exposures := manager.GetServiceExposures("container1"); exposures != nil {
+		t.Errorf("Expected exposures to be removed, got %d", len(exposures))
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", tcpPort))
+	if err != nil {
+		t.Errorf("Expected TCP port %d to be released: %v", tcpPort, err)
+	} else {
+		listener.Close()
+	}
+
+	conn, err := net.ListenPacket("udp", fmt.Sprintf("127.0.0.1:%d", udpPort))
+	if err != nil {
+		t.Errorf("Expected UDP port %d to be released: %v", udpPort, err)
+	} else {
+		conn.Close()
+	}
+}
+
+// TestExposeAndUnexposeService tests single-port exposure management.
+func TestExposeAndUnexposeService(t *testing.T) {
+	manager := newForwarderTestManager(t)
+	backend := startEchoServer(t)
+	backendPort := backend.Addr().(*net.TCPAddr).Port
+	containerIP := net.ParseIP("127.0.0.1")
+	hostPort := freePort(t, "tcp")
+
+	port := ExposedPort{ContainerPort: backendPort, HostPort: hostPort, ServiceName: "web", ExposureType: ExposureTypeIP, TargetIP: "127.0.0.1"}
+	exposure, err := manager.ExposeService("container1", "net1", containerIP, port)
+	if err != nil {
+		t.Fatalf("Failed to expose service: %v", err)
+	}
+	if exposure.Destination != fmt.Sprintf("127.0.0.1:%d", hostPort) {
+		t.Errorf("Unexpected destination %s", exposure.Destination)
+	}
+
+	// The same port and protocol cannot be exposed twice
+	if _, err := manager.ExposeService("container1", "net1", containerIP, port); err == nil {
+		t.Error("Expected duplicate exposure to fail")
+	}
+
+	if _, err := manager.UnexposeService("container1", backendPort, "udp"); err == nil {
+		t.Error("Expected unexposing an unexposed protocol to fail")
+	}
+
+	removed, err := manager.UnexposeService("container1", backendPort, "TCP")
+	if err != nil {
+		t.Fatalf("Failed to unexpose service: %v", err)
+	}
+	if removed != exposure {
+		t.Error("Expected the removed exposure to be returned")
+	}
+	if exposures := manager.GetServiceExposures("container1"); exposures != nil {
+		t.Errorf("Expected no exposures left, got %d", len(exposures))
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", hostPort))
+	if err != nil {
+		t.Errorf("Expected host port %d to be released: %v", hostPort, err)
+	} else {
+		listener.Close()
+	}
+}
+
+// TestShutdownStopsForwarders tests that Shutdown cleans up without deadlocking.
+func TestShutdownStopsForwarders(t *testing.T) {
+	manager := newForwarderTestManager(t)
+	backend := startEchoServer(t)
+	tcpPort := freePort(t, "tcp")
+
+	_, err := manager.ExposeServices("container1", "net1", net.ParseIP("127.0.0.1"), []ExposedPort{
+		{ContainerPort: backend.Addr().(*net.TCPAddr).Port, HostPort: tcpPort, Protocol: "tcp", ServiceName: "web", ExposureType: ExposureTypeIP, TargetIP: "127.0.0.1"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to expose services: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- manager.Shutdown() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Shutdown returned error: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Shutdown deadlocked")
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", tcpPort))
+	if err != nil {
+		t.Errorf("Expected TCP port %d to be released: %v", tcpPort, err)
+	} else {
+		listener.Close()
+	}
+}
@@ -0,0 +1,469 @@
+// Package service provides on-demand exposures for scale-to-zero services.
+//
+// A container labeled i2p.expose.ondemand has its I2P ports served from a
+// destination the plugin owns, like an exposure group with the container as
+// its only member. When the container stops, the destination stays up and
+// published. The first inbound stream that arrives while the container is
+// stopped starts it again through the ContainerRunner, usually the Docker
+// Engine API, and is forwarded once the container has joined and accepts the
+// connection. After the container has served no stream for its idle timeout,
+// it is stopped again.
+//
+// The destination is kept while the plugin runs. It is closed when the
+// container cannot be started because it no longer exists, or when the
+// plugin shuts down.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+// OnDemandLabel is the container label that serves the container's I2P ports
+// on demand; its value is the idle timeout, or true for DefaultOnDemandIdle.
+const OnDemandLabel = "i2p.expose.ondemand"
+
+// DefaultOnDemandIdle is how long an on-demand container serves no stream
+// before it is stopped, unless its label sets another timeout.
+const DefaultOnDemandIdle = 10 * time.Minute
+
+const (
+	// onDemandStartTimeout bounds starting a container for an inbound stream,
+	// including the wait until it accepts the stream
+	onDemandStartTimeout = 2 * time.Minute
+	// onDemandStopTimeout bounds stopping an idle container
+	onDemandStopTimeout = time.Minute
+	// onDemandDialRetry is the delay between attempts to reach a container
+	// that has started but does not accept streams yet
+	onDemandDialRetry = 500 * time.Millisecond
+)
+
+// On-demand exposure states reported by ExposureGroups.
+const (
+	// OnDemandRunning means the container is joined and serves streams
+	OnDemandRunning = "running"
+	// OnDemandStarting means the container is being started for a stream
+	OnDemandStarting = "starting"
+	// OnDemandStopped means the container is stopped until the next stream
+	OnDemandStopped = "stopped"
+)
+
+// ErrContainerGone is returned by a ContainerRunner for a container that no
+// longer exists.
+var ErrContainerGone = errors.New("container no longer exists")
+
+// ContainerRunner starts and stops the containers of on-demand exposures.
+type ContainerRunner interface {
+	// StartContainer starts a stopped container
+	StartContainer(ctx context.Context, containerID string) error
+	// StopContainer stops a running container
+	StopContainer(ctx context.Context, containerID string) error
+}
+
+// SetContainerRunner sets what starts and stops on-demand containers. Without
+// a runner, on-demand exposures are served while their container runs but
+// cannot start it.
+func (sem *ServiceExposureManager) SetContainerRunner(runner ContainerRunner) {
+	sem.groupsMutex.Lock()
+	defer sem.groupsMutex.Unlock()
+
+	sem.runner = runner
+	for _, demand := range sem.onDemand {
+		demand.setRunner(runner)
+	}
+}
+
+// OnDemandGroupName returns the name of the exposure group serving the ports
+// of an on-demand container.
+func OnDemandGroupName(containerID string) string {
+	return "ondemand-" + i2p.ShortID(containerID)
+}
+
+// onDemandConfig returns the idle timeout selected by the i2p.expose.ondemand
+// label, or 0 if the container is not served on demand.
+func onDemandConfig(options map[string]interface{}) (time.Duration, error) {
+	labels, ok := options["Labels"].(map[string]interface{})
+	if !ok {
+		return 0, nil
+	}
+	value, _ := labels[OnDemandLabel].(string)
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "", "false":
+		return 0, nil
+	case "true":
+		return DefaultOnDemandIdle, nil
+	}
+
+	idle, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s label %q: expected true, false or an idle timeout such as 15m", OnDemandLabel, value)
+	}
+	if idle <= 0 {
+		return 0, fmt.Errorf("invalid %s label %q: the idle timeout must be positive", OnDemandLabel, value)
+	}
+	return idle, nil
+}
+
+// onDemandContainer tracks the container behind on-demand exposures: whether
+// it runs, the streams it serves and when it was last used.
+type onDemandContainer struct {
+	// containerID is the container started and stopped on demand
+	containerID string
+	// idle is how long the container may serve no stream before it is stopped
+	idle time.Duration
+	// runner starts and stops the container (nil if none is configured)
+	runner ContainerRunner
+	// onGone is called when the container turns out not to exist any more
+	onGone func()
+
+	// running is set while the container is joined
+	running bool
+	// ready is closed when the pending start has finished (nil if none)
+	ready chan struct{}
+	// startErr is the error of the last failed start
+	startErr error
+	// active counts the streams currently forwarded to the container
+	active int
+	// lastActive is when the container last began or finished a stream
+	lastActive time.Time
+	// timer checks whether the container has become idle
+	timer *time.Timer
+	// records are the published addresses of the container's parked ports
+	records map[int]AddressRecord
+	// closed is set once the container's on-demand exposures are closed
+	closed bool
+
+	// mutex protects the fields above
+	mutex sync.Mutex
+}
+
+// newOnDemandContainer returns the on-demand state of a stopped container.
+func newOnDemandContainer(containerID string, idle time.Duration, runner ContainerRunner) *onDemandContainer {
+	return &onDemandContainer{
+		containerID: containerID,
+		idle:        idle,
+		runner:      runner,
+		records:     make(map[int]AddressRecord),
+	}
+}
+
+// setRunner replaces the runner starting and stopping the container.
+func (d *onDemandContainer) setRunner(runner ContainerRunner) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.runner = runner
+}
+
+// state returns OnDemandRunning, OnDemandStarting or OnDemandStopped.
+func (d *onDemandContainer) state() string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	switch {
+	case d.running:
+		return OnDemandRunning
+	case d.ready != nil:
+		return OnDemandStarting
+	}
+	return OnDemandStopped
+}
+
+// joined records that the container has joined, ending a pending start.
+func (d *onDemandContainer) joined(port int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	delete(d.records, port)
+	d.running = true
+	d.startErr = nil
+	if d.ready != nil {
+		close(d.ready)
+		d.ready = nil
+	}
+	d.lastActive = time.Now()
+	d.scheduleLocked(d.idle)
+}
+
+// left records that the container has left, keeping the published address
+// of its port to withdraw when the exposure is closed.
+func (d *onDemandContainer) left(port int, record AddressRecord) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.records[port] = record
+	d.running = false
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
+
+// wake starts the container if it is stopped and waits until it has joined.
+func (d *onDemandContainer) wake() error {
+	d.mutex.Lock()
+	if d.closed {
+		d.mutex.Unlock()
+		return fmt.Errorf("on-demand exposure of container %s is closed", i2p.DisplayName(d.containerID))
+	}
+	if d.running {
+		d.mutex.Unlock()
+		return nil
+	}
+	if d.ready == nil {
+		d.ready = make(chan struct{})
+		go d.start(d.runner)
+	}
+	ready := d.ready
+	d.mutex.Unlock()
+
+	timeout := time.NewTimer(onDemandStartTimeout)
+	defer timeout.Stop()
+	select {
+	case <-ready:
+	case <-timeout.C:
+		return fmt.Errorf("container %s did not join within %s", i2p.DisplayName(d.containerID), onDemandStartTimeout)
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.running {
+		return nil
+	}
+	if d.startErr != nil {
+		return d.startErr
+	}
+	return fmt.Errorf("container %s stopped again before serving the stream", i2p.DisplayName(d.containerID))
+}
+
+// start starts the container for a waiting stream. The container's Join ends
+// the pending start; a failure ends it with an error.
+func (d *onDemandContainer) start(runner ContainerRunner) {
+	log.Printf("Starting container %s for an inbound stream to its on-demand exposure", i2p.DisplayName(d.containerID))
+
+	err := fmt.Errorf("no Docker API is configured to start containers")
+	if runner != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), onDemandStartTimeout)
+		err = runner.StartContainer(ctx, d.containerID)
+		cancel()
+	}
+	if err == nil {
+		return
+	}
+
+	log.Printf("Warning: Failed to start on-demand container %s: %v", i2p.DisplayName(d.containerID), err)
+	d.mutex.Lock()
+	d.startErr = fmt.Errorf("failed to start container %s: %w", i2p.DisplayName(d.containerID), err)
+	if d.ready != nil {
+		close(d.ready)
+		d.ready = nil
+	}
+	onGone := d.onGone
+	d.mutex.Unlock()
+
+	if errors.Is(err, ErrContainerGone) && onGone != nil {
+		onGone()
+	}
+}
+
+// begin counts a stream forwarded to the container.
+func (d *onDemandContainer) begin() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.active++
+	d.lastActive = time.Now()
+}
+
+// end releases a stream counted by begin.
+func (d *onDemandContainer) end() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.active--
+	d.lastActive = time.Now()
+}
+
+// scheduleLocked checks for idleness after the given delay. The caller must
+// hold d.mutex.
+func (d *onDemandContainer) scheduleLocked(after time.Duration) {
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.AfterFunc(after, d.checkIdle)
+}
+
+// checkIdle stops the container once it has served no stream for its idle
+// timeout. The container's Leave then parks its exposures.
+func (d *onDemandContainer) checkIdle() {
+	d.mutex.Lock()
+	if !d.running || d.closed {
+		d.mutex.Unlock()
+		return
+	}
+	if d.active > 0 {
+		d.scheduleLocked(d.idle)
+		d.mutex.Unlock()
+		return
+	}
+	if remaining := d.idle - time.Since(d.lastActive); remaining > 0 {
+		d.scheduleLocked(remaining)
+		d.mutex.Unlock()
+		return
+	}
+	runner := d.runner
+	d.mutex.Unlock()
+
+	if runner == nil {
+		log.Printf("Warning: On-demand container %s is idle, but no Docker API is configured to stop it", i2p.DisplayName(d.containerID))
+		return
+	}
+
+	log.Printf("Stopping on-demand container %s after %s without streams", i2p.DisplayName(d.containerID), d.idle)
+	ctx, cancel := context.WithTimeout(context.Background(), onDemandStopTimeout)
+	err := runner.StopContainer(ctx, d.containerID)
+	cancel()
+	if err == nil {
+		return
+	}
+
+	log.Printf("Warning: Failed to stop idle on-demand container %s: %v", i2p.DisplayName(d.containerID), err)
+	d.mutex.Lock()
+	if d.running && !d.closed {
+		d.scheduleLocked(d.idle)
+	}
+	d.mutex.Unlock()
+}
+
+// close ends the container's on-demand exposures and returns the published
+// addresses of its parked ports.
+func (d *onDemandContainer) close() []AddressRecord {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.closed = true
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	if d.ready != nil {
+		d.startErr = fmt.Errorf("on-demand exposure of container %s was closed", i2p.DisplayName(d.containerID))
+		close(d.ready)
+		d.ready = nil
+	}
+
+	records := make([]AddressRecord, 0, len(d.records))
+	for _, record := range d.records {
+		records = append(records, record)
+	}
+	return records
+}
+
+// onDemandForLocked returns the on-demand state of a container whose port
+// joins an exposure group, creating it for the container's first on-demand
+// port. Ports of other groups get nil. The caller must hold sem.groupsMutex.
+func (sem *ServiceExposureManager) onDemandForLocked(containerID string, port ExposedPort) *onDemandContainer {
+	if demand, exists := sem.onDemand[containerID]; exists && port.Group == OnDemandGroupName(containerID) {
+		return demand
+	}
+	if port.OnDemand <= 0 {
+		return nil
+	}
+
+	if sem.onDemand == nil {
+		sem.onDemand = make(map[string]*onDemandContainer)
+	}
+	demand := newOnDemandContainer(containerID, port.OnDemand, sem.runner)
+	demand.onGone = func() {
+		// The forwarder of the waiting stream is stopped by closing
+		go sem.CloseOnDemand(containerID)
+	}
+	sem.onDemand[containerID] = demand
+	return demand
+}
+
+// parkOnDemandLocked takes the container of an on-demand exposure out of its
+// group, keeping the group's tunnel and published address for the
+// container's next start. The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) parkOnDemandLocked(exposure *ServiceExposure) {
+	exposure.stopExpiry()
+
+	group := exposure.group
+	group.balancer.remove(exposure.ContainerID)
+	group.balancer.demand.left(group.port, exposureRecord(exposure))
+
+	log.Printf("Container %s left, port %d stays reachable at %s until it is started on demand",
+		i2p.DisplayName(exposure.ContainerID), group.port, group.destination)
+}
+
+// CloseOnDemand closes the on-demand exposures of a stopped container,
+// withdrawing their published addresses and tearing down their destination.
+// Exposures of a running container are closed when it leaves.
+func (sem *ServiceExposureManager) CloseOnDemand(containerID string) {
+	sem.mutex.Lock()
+	defer sem.mutex.Unlock()
+
+	sem.closeOnDemandLocked(containerID)
+}
+
+// closeOnDemandLocked closes the on-demand exposures of a stopped container,
+// or of every stopped container if containerID is empty. The caller must
+// hold sem.mutex.
+func (sem *ServiceExposureManager) closeOnDemandLocked(containerID string) {
+	var closing []*exposureGroup
+	var records []AddressRecord
+
+	sem.groupsMutex.Lock()
+	for id, demand := range sem.onDemand {
+		if containerID != "" && id != containerID {
+			continue
+		}
+		stopped := true
+		for _, group := range sem.groups {
+			if group.balancer.demand == demand && group.balancer.count() > 0 {
+				stopped = false
+			}
+		}
+		if !stopped {
+			continue
+		}
+
+		for key, group := range sem.groups {
+			if group.balancer.demand == demand {
+				delete(sem.groups, key)
+				closing = append(closing, group)
+			}
+		}
+		records = append(records, demand.close()...)
+		delete(sem.onDemand, id)
+	}
+	sem.groupsMutex.Unlock()
+
+	for _, record := range records {
+		if sem.publisher == nil {
+			break
+		}
+		if err := sem.publisher.Unpublish(record); err != nil {
+			log.Printf("Warning: Failed to unpublish address of %s: %v", record.Address, err)
+		}
+	}
+	for _, group := range closing {
+		log.Printf("Closing on-demand exposure group %s on port %d", group.name, group.port)
+		if group.forwarder != nil {
+			if err := group.forwarder.Stop(); err != nil {
+				log.Printf("Warning: Failed to stop forwarder of group %s: %v", group.name, err)
+			}
+		}
+		if group.tunnel != nil {
+			if err := sem.destroyGroupTunnel(group.name, group.tunnel.GetConfig().Name); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRunner records the containers an on-demand exposure starts and stops.
+type fakeRunner struct {
+	start   func(containerID string) error
+	starts  int
+	stopped chan string
+	mutex   sync.Mutex
+}
+
+func (r *fakeRunner) StartContainer(_ context.Context, containerID string) error {
+	r.mutex.Lock()
+	r.starts++
+	start := r.start
+	r.mutex.Unlock()
+	return start(containerID)
+}
+
+func (r *fakeRunner) setStart(start func(containerID string) error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.start = start
+}
+
+func (r *fakeRunner) startCount() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.starts
+}
+
+func (r *fakeRunner) StopContainer(_ context.Context, containerID string) error {
+	r.stopped <- containerID
+	return nil
+}
+
+func TestOnDemandConfig(t *testing.T) {
+	tests := []struct {
+		value   string
+		idle    time.Duration
+		wantErr bool
+	}{
+		{value: ""},
+		{value: "false"},
+		{value: "true", idle: DefaultOnDemandIdle},
+		{value: " 15m ", idle: 15 * time.Minute},
+		{value: "0s", wantErr: true},
+		{value: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		idle, err := onDemandConfig(map[string]interface{}{"Labels": map[string]interface{}{OnDemandLabel: tt.value}})
+		if (err != nil) != tt.wantErr || idle != tt.idle {
+			t.Errorf("onDemandConfig(%q) = %s, %v; want %s (error %v)", tt.value, idle, err, tt.idle, tt.wantErr)
+		}
+	}
+}
+
+func TestDetectExposedPortsOnDemand(t *testing.T) {
+	manager := &ServiceExposureManager{}
+
+	ports, err := manager.DetectExposedPorts("container1", map[string]interface{}{
+		"Labels": map[string]interface{}{OnDemandLabel: "5m", "i2p.expose.80": "i2p", "i2p.expose.8080": "ip"},
+	})
+	if err != nil {
+		t.Fatalf("DetectExposedPorts() unexpected error: %v", err)
+	}
+	if len(ports) != 2 {
+		t.Fatalf("Expected the on-demand label not to be read as a port, got %d ports", len(ports))
+	}
+	for _, port := range ports {
+		onDemand := port.ExposureType != ExposureTypeIP
+		if onDemand != (port.Group == OnDemandGroupName("container1") && port.OnDemand == 5*time.Minute) {
+			t.Errorf("Expected only the I2P port to be served on demand, got %+v", port)
+		}
+	}
+
+	_, err = manager.DetectExposedPorts("container1", map[string]interface{}{
+		"Labels": map[string]interface{}{OnDemandLabel: "true", ExposureGroupLabel: "blog", "i2p.expose.80": "i2p"},
+	})
+	if err == nil || !strings.Contains(err.Error(), ExposureGroupLabel) {
+		t.Errorf("Expected on-demand exposure not to combine with groups, got %v", err)
+	}
+}
+
+func TestOnDemandStartAndIdleStop(t *testing.T) {
+	b := &balancer{mode: BalanceRoundRobin}
+	runner := &fakeRunner{stopped: make(chan string, 1)}
+	demand := newOnDemandContainer("container1", 50*time.Millisecond, runner)
+	b.demand = demand
+
+	// Starting the container makes it join its group
+	addr := startNamedServer(t, "app")
+	runner.setStart(func(containerID string) error {
+		go func() {
+			b.add(containerID, addr)
+			demand.joined(80)
+		}()
+		return nil
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forwarder := newBalancedForwarder(listener, b)
+	t.Cleanup(func() { forwarder.Stop() })
+
+	manager := &ServiceExposureManager{groups: map[string]*exposureGroup{}}
+	group := &exposureGroup{name: OnDemandGroupName("container1"), port: 80, balancer: b, destination: "app.b32.i2p", forwarder: forwarder, ready: make(chan struct{})}
+	close(group.ready)
+	manager.groups[groupKey(group.name, 80)] = group
+
+	if served := readMember(t, listener.Addr().String()); served != "app\n" {
+		t.Fatalf("Expected the stream to reach the started container, got %q", served)
+	}
+	if served := readMember(t, listener.Addr().String()); served != "app\n" || runner.startCount() != 1 {
+		t.Fatalf("Expected the running container to serve without another start, got %q after %d starts", served, runner.startCount())
+	}
+	if groups := manager.ExposureGroups(); len(groups) != 1 || groups[0].OnDemand != OnDemandRunning {
+		t.Errorf("Expected the group to report a running container, got %+v", groups)
+	}
+
+	select {
+	case stopped := <-runner.stopped:
+		if stopped != "container1" {
+			t.Errorf("Expected container1 to be stopped, got %s", stopped)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the idle container to be stopped")
+	}
+
+	// The container's Leave parks the exposure instead of closing it
+	manager.parkOnDemandLocked(&ServiceExposure{ContainerID: "container1", Port: ExposedPort{ContainerPort: 80}, group: group})
+	if groups := manager.ExposureGroups(); len(groups) != 1 || len(groups[0].Members) != 0 || groups[0].OnDemand != OnDemandStopped {
+		t.Errorf("Expected the group to stay with a stopped container, got %+v", groups)
+	}
+
+	// A container that was removed cannot be started
+	gone := make(chan struct{}, 1)
+	demand.mutex.Lock()
+	demand.onGone = func() { gone <- struct{}{} }
+	demand.mutex.Unlock()
+	runner.setStart(func(string) error { return ErrContainerGone })
+	if served := readMember(t, listener.Addr().String()); served != "" {
+		t.Errorf("Expected the stream to be dropped, got %q", served)
+	}
+	select {
+	case <-gone:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the removed container to be reported")
+	}
+}
@@ -0,0 +1,89 @@
+// Package service provides concurrent exposure creation.
+//
+// Building an I2P server tunnel takes several seconds, so the exposures of a
+// container are created by a bounded pool of workers instead of one after the
+// other. Results keep the order of the requested ports, and every port that
+// could not be exposed is reported in a single aggregated error.
+package service
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+)
+
+// DefaultExposureConcurrency is the default number of exposures created in
+// parallel for one container.
+const DefaultExposureConcurrency = 4
+
+// ExposureFailure describes a port that could not be exposed.
+type ExposureFailure struct {
+	// Port is the port that was requested
+	Port ExposedPort
+	// Err is why the exposure failed
+	Err error
+}
+
+// ExposureErrors reports the ports ExposeServices could not expose.
+type ExposureErrors []ExposureFailure
+
+// Error lists every failed port with its cause.
+func (e ExposureErrors) Error() string {
+	failures := make([]string, len(e))
+	for i, failure := range e {
+		failures[i] = fmt.Sprintf("port %d/%s (%s): %v", failure.Port.ContainerPort,
+			normalizeProtocol(failure.Port.Protocol), failure.Port.ExposureType, failure.Err)
+	}
+	return fmt.Sprintf("failed to expose %d ports: %s", len(e), strings.Join(failures, "; "))
+}
+
+// SetConcurrency sets how many exposures of a container are created in
+// parallel. Values below 1 select DefaultExposureConcurrency.
+func (sem *ServiceExposureManager) SetConcurrency(workers int) {
+	sem.mutex.Lock()
+	defer sem.mutex.Unlock()
+
+	sem.concurrency = workers
+}
+
+// exposureResult is the outcome of creating one exposure.
+type exposureResult struct {
+	exposure *ServiceExposure
+	err      error
+}
+
+// createExposuresLocked creates exposures for ports with at most
+// sem.concurrency of them in flight.
+//
+// Results are returned in the order of ports. The caller must hold sem.mutex;
+// the workers do not take it.
+func (sem *ServiceExposureManager) createExposuresLocked(containerID string, networkID string, containerIP net.IP, ports []ExposedPort) []exposureResult {
+	workers := sem.concurrency
+	if workers < 1 {
+		workers = DefaultExposureConcurrency
+	}
+
+	results := make([]exposureResult, len(ports))
+	if len(ports) > 1 && workers > 1 {
+		log.Printf("Creating %d exposures for container %s with up to %d in parallel",
+			len(ports), containerID, min(workers, len(ports)))
+	}
+
+	slots := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, port := range ports {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, port ExposedPort) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			results[i].exposure, results[i].err = sem.createExposure(containerID, networkID, containerIP, port)
+		}(i, port)
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+// TestExposeServicesParallel tests that exposures created in parallel keep the
+// port order and that every failed port is reported.
+func TestExposeServicesParallel(t *testing.T) {
+	for _, workers := range []int{1, DefaultExposureConcurrency} {
+		manager := newForwarderTestManager(t)
+		manager.SetConcurrency(workers)
+		backend := startEchoServer(t)
+		backendPort := backend.Addr().(*net.TCPAddr).Port
+
+		// Hold a host port so its exposure cannot bind
+		busy, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to reserve busy port: %v", err)
+		}
+		busyPort := busy.Addr().(*net.TCPAddr).Port
+
+		var ports []ExposedPort
+		for i := 0; i < 3; i++ {
+			ports = append(ports, ExposedPort{ContainerPort: backendPort, HostPort: freePort(t, "tcp"), Protocol: "tcp", ServiceName: "web", ExposureType: ExposureTypeIP, TargetIP: "127.0.0.1"})
+		}
+		ports = append(ports[:1], append([]ExposedPort{
+			{ContainerPort: backendPort, HostPort: busyPort, Protocol: "tcp", ServiceName: "busy", ExposureType: ExposureTypeIP, TargetIP: "127.0.0.1"},
+		}, ports[1:]...)...)
+
+		exposures, err := manager.ExposeServices("container1", "net1", net.ParseIP("127.0.0.1"), ports)
+		busy.Close()
+
+		var failures ExposureErrors
+		if !errors.As(err, &failures) {
+			t.Fatalf("workers=%d: expected ExposureErrors, got %v", workers, err)
+		}
+		if len(failures) != 1 || failures[0].Port.HostPort != busyPort {
+			t.Errorf("workers=%d: expected busy port %d to fail, got %+v", workers, busyPort, failures)
+		}
+		if !strings.Contains(err.Error(), "failed to expose 1 ports") {
+			t.Errorf("workers=%d: unexpected error message: %v", workers, err)
+		}
+
+		if len(exposures) != 3 {
+			t.Fatalf("workers=%d: expected 3 exposures, got %d", workers, len(exposures))
+		}
+		for i, want := range []int{ports[0].HostPort, ports[2].HostPort, ports[3].HostPort} {
+			if exposures[i].Port.HostPort != want {
+				t.Errorf("workers=%d: expected exposure %d on host port %d, got %d", workers, i, want, exposures[i].Port.HostPort)
+			}
+		}
+		if tracked := manager.GetServiceExposures("container1"); len(tracked) != 3 {
+			t.Errorf("workers=%d: expected 3 tracked exposures, got %d", workers, len(tracked))
+		}
+
+		if err := manager.CleanupServices("container1"); err != nil {
+			t.Errorf("workers=%d: cleanup failed: %v", workers, err)
+		}
+	}
+}
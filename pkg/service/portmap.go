@@ -0,0 +1,375 @@
+// Package service provides client "port map" tunnels for legacy applications.
+//
+// This file implements fixed client tunnels that bind a plain TCP listener on
+// the network side and forward every accepted connection to a configured I2P
+// destination. Applications that cannot use SOCKS or DNS interception can
+// connect to the listener as if the remote I2P service were a local one. A
+// port map may list fallback destinations it fails over to (see failover.go).
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-forward/config"
+	"github.com/go-i2p/go-forward/stream"
+)
+
+// PortMapLabelPrefix is the container label prefix used to request port maps.
+const PortMapLabelPrefix = "i2p.portmap."
+
+// PortMap describes a fixed mapping from a local TCP port to an I2P destination.
+type PortMap struct {
+	// ListenPort is the TCP port the container connects to
+	ListenPort int `json:"listen_port"`
+	// Destination is the I2P host (.i2p name, .b32.i2p address or base64 destination)
+	Destination string `json:"destination"`
+	// DestinationPort is the service port on the remote destination
+	DestinationPort int `json:"destination_port"`
+	// Fallbacks are tried in order when the destination cannot be reached
+	Fallbacks []PortMapTarget `json:"fallbacks,omitempty"`
+}
+
+// Targets returns the destination followed by the fallbacks.
+func (pm PortMap) Targets() []PortMapTarget {
+	targets := []PortMapTarget{{Destination: pm.Destination, Port: pm.DestinationPort}}
+	return append(targets, pm.Fallbacks...)
+}
+
+// PortMapping is an active port map backed by an I2P client tunnel.
+type PortMapping struct {
+	// ContainerID identifies the container that requested the mapping
+	ContainerID string
+	// Map is the requested port map configuration
+	Map PortMap
+	// ListenAddr is the host:port the TCP listener is bound to
+	ListenAddr string
+	// Tunnel is the I2P client tunnel used to reach the destination
+	Tunnel *i2p.Tunnel
+	// TunnelName is the internal name for the tunnel
+	TunnelName string
+
+	// listener accepts plain TCP connections from the container
+	listener net.Listener
+	// failover orders the destinations by health
+	failover *failoverList
+	// dial opens an I2P stream to a destination (Tunnel.Dial outside tests)
+	dial func(destination string) (net.Conn, error)
+	// ctx provides cancellation context
+	ctx context.Context
+	// cancel cancels the context
+	cancel context.CancelFunc
+	// wg tracks active forwarding goroutines
+	wg sync.WaitGroup
+}
+
+// DetectPortMaps extracts client port map requests from container options.
+//
+// Port maps are configured with labels of the form:
+//   - i2p.portmap.6667=irc.postman.i2p:6667
+//   - i2p.portmap.8080=example.b32.i2p (remote port defaults to the listen port)
+//   - i2p.portmap.6667=irc.postman.i2p:6667,irc.echelon.i2p (fallback destinations)
+func (sem *ServiceExposureManager) DetectPortMaps(options map[string]interface{}) []PortMap {
+	var maps []PortMap
+
+	labels, ok := options["Labels"].(map[string]interface{})
+	if !ok {
+		return maps
+	}
+
+	for key, value := range labels {
+		if !strings.HasPrefix(key, PortMapLabelPrefix) {
+			continue
+		}
+		if pm := sem.parsePortMapLabel(key, value); pm != nil {
+			maps = append(maps, *pm)
+		}
+	}
+
+	return maps
+}
+
+// parsePortMapLabel parses an individual i2p.portmap.<port> label.
+//
+// Returns nil if the label format is invalid.
+func (sem *ServiceExposureManager) parsePortMapLabel(key string, value interface{}) *PortMap {
+	portStr := strings.TrimPrefix(key, PortMapLabelPrefix)
+	listenPort, err := strconv.Atoi(portStr)
+	if err != nil || listenPort <= 0 || listenPort > 65535 {
+		log.Printf("Warning: Invalid port in label %s", key)
+		return nil
+	}
+
+	valueStr, ok := value.(string)
+	if !ok {
+		log.Printf("Warning: Invalid value type for label %s", key)
+		return nil
+	}
+
+	valueStr = strings.TrimSpace(valueStr)
+	if valueStr == "" {
+		log.Printf("Warning: Missing destination in label %s", key)
+		return nil
+	}
+
+	var targets []PortMapTarget
+	for _, entry := range strings.Split(valueStr, ",") {
+		target, err := parsePortMapTarget(strings.TrimSpace(entry), listenPort)
+		if err != nil {
+			log.Printf("Warning: Invalid entry %q in label %s: %v", entry, key, err)
+			return nil
+		}
+		targets = append(targets, target)
+	}
+
+	pm := &PortMap{
+		ListenPort:      listenPort,
+		Destination:     targets[0].Destination,
+		DestinationPort: targets[0].Port,
+	}
+	if len(targets) > 1 {
+		pm.Fallbacks = targets[1:]
+	}
+	return pm
+}
+
+// parsePortMapTarget parses a <destination>[:<port>] entry of a port map
+// label. The port defaults to the listen port.
+func parsePortMapTarget(entry string, listenPort int) (PortMapTarget, error) {
+	host := entry
+	port := listenPort
+	if idx := strings.LastIndex(entry, ":"); idx >= 0 {
+		host = entry[:idx]
+		var err error
+		port, err = strconv.Atoi(entry[idx+1:])
+		if err != nil || port <= 0 || port > 65535 {
+			return PortMapTarget{}, fmt.Errorf("invalid destination port")
+		}
+	}
+
+	if host == "" || strings.ContainsAny(host, " /") {
+		return PortMapTarget{}, fmt.Errorf("invalid destination")
+	}
+	return PortMapTarget{Destination: host, Port: port}, nil
+}
+
+// CreatePortMaps starts client port maps for a container.
+//
+// Each map gets its own I2P client tunnel and a TCP listener on listenIP. Maps that
+// fail to start are logged and skipped, mirroring ExposeServices behavior.
+func (sem *ServiceExposureManager) CreatePortMaps(containerID string, listenIP net.IP, maps []PortMap) ([]*PortMapping, error) {
+	if containerID == "" {
+		return nil, fmt.Errorf("container ID cannot be empty")
+	}
+	if listenIP == nil {
+		return nil, fmt.Errorf("listen IP cannot be nil")
+	}
+
+	sem.mutex.Lock()
+	defer sem.mutex.Unlock()
+
+	var mappings []*PortMapping
+
+	for _, pm := range maps {
+		mapping, err := sem.createPortMapping(containerID, listenIP, pm)
+		if err != nil {
+			log.Printf("Warning: Failed to create port map %d -> %s:%d for container %s: %v",
+				pm.ListenPort, pm.Destination, pm.DestinationPort, containerID, err)
+			continue
+		}
+
+		mappings = append(mappings, mapping)
+		log.Printf("Port map %s -> %s:%d active for container %s",
+			mapping.ListenAddr, pm.Destination, pm.DestinationPort, containerID)
+	}
+
+	sem.portMaps[containerID] = append(sem.portMaps[containerID], mappings...)
+
+	return mappings, nil
+}
+
+// GetPortMappings returns all active port maps for a container.
+func (sem *ServiceExposureManager) GetPortMappings(containerID string) []*PortMapping {
+	sem.mutex.RLock()
+	defer sem.mutex.RUnlock()
+
+	mappings, exists := sem.portMaps[containerID]
+	if !exists {
+		return nil
+	}
+
+	result := make([]*PortMapping, len(mappings))
+	copy(result, mappings)
+	return result
+}
+
+// portMapPurpose describes a port map for tunnel naming, e.g. "portmap-6667".
+func portMapPurpose(pm PortMap) string {
+	return fmt.Sprintf("portmap-%d", pm.ListenPort)
+}
+
+// PortMapTunnelName returns the client tunnel name of a container's port map.
+func PortMapTunnelName(containerID string, pm PortMap) string {
+	return i2p.TunnelName(containerID, portMapPurpose(pm))
+}
+
+// createPortMapping creates the client tunnel and listener for a single port map.
+func (sem *ServiceExposureManager) createPortMapping(containerID string, listenIP net.IP, pm PortMap) (*PortMapping, error) {
+	purpose := portMapPurpose(pm)
+	tunnelName := PortMapTunnelName(containerID, pm)
+
+	tunnelConfig := &i2p.TunnelConfig{
+		Name:        tunnelName,
+		Purpose:     purpose,
+		ContainerID: containerID,
+		Type:        i2p.TunnelTypeClient,
+		LocalHost:   listenIP.String(),
+		LocalPort:   pm.ListenPort,
+		Destination: pm.Destination,
+		Options:     i2p.DefaultTunnelOptions(),
+	}
+
+	tunnel, err := sem.tunnelMgr.CreateTunnel(tunnelConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client tunnel: %w", err)
+	}
+
+	listenAddr := net.JoinHostPort(listenIP.String(), strconv.Itoa(pm.ListenPort))
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		sem.tunnelMgr.DestroyTunnel(tunnelName)
+		return nil, fmt.Errorf("failed to listen on %s: %w", listenAddr, err)
+	}
+
+	ctx, cancel := context.WithCancel(sem.ctx)
+	mapping := &PortMapping{
+		ContainerID: containerID,
+		Map:         pm,
+		ListenAddr:  listenAddr,
+		Tunnel:      tunnel,
+		TunnelName:  tunnelName,
+		listener:    listener,
+		failover:    newFailoverList(pm.Targets()),
+		dial:        tunnel.Dial,
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	mapping.wg.Add(1)
+	go mapping.acceptLoop()
+
+	return mapping, nil
+}
+
+// acceptLoop accepts container connections and forwards them to the destination.
+func (m *PortMapping) acceptLoop() {
+	defer m.wg.Done()
+
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			select {
+			case <-m.ctx.Done():
+				return
+			default:
+				log.Printf("Error accepting port map connection on %s: %v", m.ListenAddr, err)
+				return
+			}
+		}
+
+		m.wg.Add(1)
+		go m.handleConnection(conn)
+	}
+}
+
+// handleConnection dials the I2P destination and relays a single connection.
+func (m *PortMapping) handleConnection(clientConn net.Conn) {
+	defer m.wg.Done()
+	defer clientConn.Close()
+
+	i2pConn, target, err := m.dialTarget()
+	if err != nil {
+		log.Printf("Port map %s failed to reach %s: %v", m.ListenAddr, m.Map.Destination, err)
+		return
+	}
+	defer i2pConn.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.EnableMetrics = false
+
+	if err := stream.Forward(m.ctx, clientConn, i2pConn, cfg); err != nil {
+		if err != context.Canceled && err != io.EOF {
+			log.Printf("Port map forwarding error for %s: %v", target.Destination, err)
+		}
+	}
+}
+
+// dialTarget opens a stream to the first destination that can be reached,
+// healthy destinations first, and records the outcome of every attempt.
+func (m *PortMapping) dialTarget() (net.Conn, PortMapTarget, error) {
+	var lastErr error
+	for _, state := range m.failover.order() {
+		conn, err := m.dial(state.target.Destination)
+		if err == nil {
+			m.failover.succeeded(state)
+			return conn, state.target, nil
+		}
+
+		lastErr = err
+		backoff := m.failover.failed(state, err)
+		if len(m.failover.targets) > 1 {
+			log.Printf("Warning: Port map %s marked %s down for %s, failing over: %v",
+				m.ListenAddr, state.target.Destination, backoff, err)
+		}
+	}
+	if len(m.failover.targets) > 1 {
+		lastErr = fmt.Errorf("none of %d destinations reachable, last error: %w", len(m.failover.targets), lastErr)
+	}
+	return nil, PortMapTarget{}, lastErr
+}
+
+// Health returns the health of the port map's destinations, the destination
+// first and the fallbacks in order.
+func (m *PortMapping) Health() []TargetHealth {
+	return m.failover.health()
+}
+
+// Stop closes the listener and waits for active connections to finish.
+//
+// The client tunnel itself is destroyed by the owning ServiceExposureManager.
+func (m *PortMapping) Stop() error {
+	m.cancel()
+
+	if m.listener != nil {
+		if err := m.listener.Close(); err != nil {
+			log.Printf("Error closing port map listener %s: %v", m.ListenAddr, err)
+		}
+	}
+
+	m.wg.Wait()
+	return nil
+}
+
+// cleanupPortMaps stops all port maps for a container (caller must hold sem.mutex).
+func (sem *ServiceExposureManager) cleanupPortMaps(containerID string) []string {
+	var errors []string
+
+	for _, mapping := range sem.portMaps[containerID] {
+		if err := mapping.Stop(); err != nil {
+			errors = append(errors, fmt.Sprintf("failed to stop port map %s: %v", mapping.TunnelName, err))
+		}
+		if err := sem.tunnelMgr.DestroyTunnel(mapping.TunnelName); err != nil {
+			errors = append(errors, fmt.Sprintf("failed to destroy tunnel %s: %v", mapping.TunnelName, err))
+		}
+	}
+
+	delete(sem.portMaps, containerID)
+	return errors
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParsePortMapLabel(t *testing.T) {
+	t.Parallel()
+
+	manager := newForwarderTestManager(t)
+
+	tests := []struct {
+		name     string
+		key      string
+		value    interface{}
+		expected *PortMap
+	}{
+		{
+			name:     "destination with port",
+			key:      "i2p.portmap.6667",
+			value:    "irc.postman.i2p:6667",
+			expected: &PortMap{ListenPort: 6667, Destination: "irc.postman.i2p", DestinationPort: 6667},
+		},
+		{
+			name:     "different remote port",
+			key:      "i2p.portmap.8080",
+			value:    "example.b32.i2p:80",
+			expected: &PortMap{ListenPort: 8080, Destination: "example.b32.i2p", DestinationPort: 80},
+		},
+		{
+			name:     "remote port defaults to listen port",
+			key:      "i2p.portmap.119",
+			value:    " news.i2p ",
+			expected: &PortMap{ListenPort: 119, Destination: "news.i2p", DestinationPort: 119},
+		},
+		{
+			name:  "fallback destinations",
+			key:   "i2p.portmap.6667",
+			value: "irc.postman.i2p:6667, irc.echelon.i2p ,irc.ilita.i2p:6668",
+			expected: &PortMap{ListenPort: 6667, Destination: "irc.postman.i2p", DestinationPort: 6667, Fallbacks: []PortMapTarget{
+				{Destination: "irc.echelon.i2p", Port: 6667},
+				{Destination: "irc.ilita.i2p", Port: 6668},
+			}},
+		},
+		{name: "invalid listen port", key: "i2p.portmap.abc", value: "irc.postman.i2p"},
+		{name: "listen port out of range", key: "i2p.portmap.70000", value: "irc.postman.i2p"},
+		{name: "invalid remote port", key: "i2p.portmap.6667", value: "irc.postman.i2p:xyz"},
+		{name: "empty destination", key: "i2p.portmap.6667", value: ""},
+		{name: "missing host", key: "i2p.portmap.6667", value: ":6667"},
+		{name: "destination with path", key: "i2p.portmap.80", value: "example.i2p/index.html"},
+		{name: "non-string value", key: "i2p.portmap.80", value: 80},
+		{name: "empty fallback", key: "i2p.portmap.6667", value: "irc.postman.i2p,"},
+		{name: "invalid fallback port", key: "i2p.portmap.6667", value: "irc.postman.i2p,irc.echelon.i2p:0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := manager.parsePortMapLabel(tt.key, tt.value)
+
+			if tt.expected == nil {
+				if result != nil {
+					t.Errorf("Expected nil, got %+v", result)
+				}
+				return
+			}
+
+			if result == nil {
+				t.Fatalf("Expected %+v, got nil", tt.expected)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestDetectPortMaps(t *testing.T) {
+	t.Parallel()
+
+	manager := newForwarderTestManager(t)
+
+	options := map[string]interface{}{
+		"Labels": map[string]interface{}{
+			"i2p.portmap.6667": "irc.postman.i2p:6667",
+			"i2p.portmap.bad":  "irc.postman.i2p",
+			"i2p.expose.80":    "i2p",
+			"other.label":      "value",
+		},
+	}
+
+	maps := manager.DetectPortMaps(options)
+	if len(maps) != 1 {
+		t.Fatalf("Expected 1 port map, got %d", len(maps))
+	}
+	if maps[0].ListenPort != 6667 || maps[0].Destination != "irc.postman.i2p" {
+		t.Errorf("Unexpected port map: %+v", maps[0])
+	}
+
+	if maps := manager.DetectPortMaps(map[string]interface{}{}); len(maps) != 0 {
+		t.Errorf("Expected no port maps without labels, got %d", len(maps))
+	}
+}
+
+func TestCreatePortMapsValidation(t *testing.T) {
+	t.Parallel()
+
+	manager := newForwarderTestManager(t)
+
+	if _, err := manager.CreatePortMaps("", net.ParseIP("172.20.1.1"), nil); err == nil {
+		t.Error("Expected error for empty container ID")
+	}
+	if _, err := manager.CreatePortMaps("container1", nil, nil); err == nil {
+		t.Error("Expected error for nil listen IP")
+	}
+	if mappings := manager.GetPortMappings("container1"); mappings != nil {
+		t.Errorf("Expected no port mappings, got %d", len(mappings))
+	}
+}
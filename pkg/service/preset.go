@@ -0,0 +1,175 @@
+// Package service provides built-in exposure presets for common stacks.
+//
+// This file implements exposure presets selected with the i2p.preset label.
+// A preset expands into the ports, tunnel options and HTTP headers that the
+// most common eepsite and container types need, so users don't have to spell
+// out an i2p.expose.* label per port.
+package service
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+// PresetLabel is the container label used to select exposure presets.
+const PresetLabel = "i2p.preset"
+
+// ExposurePreset is a named exposure configuration for a common service type.
+type ExposurePreset struct {
+	// Name is the value used in the i2p.preset label
+	Name string `json:"name"`
+	// Description is a short human-readable summary of the preset
+	Description string `json:"description"`
+	// Ports are the ports the preset exposes over I2P
+	Ports []ExposedPort `json:"ports"`
+	// TunnelOptions are the server tunnel options used for the preset's ports
+	TunnelOptions i2p.TunnelOptions `json:"tunnel_options"`
+	// HTTPHeaders are headers added to HTTP responses served over the tunnels
+	HTTPHeaders map[string]string `json:"http_headers,omitempty"`
+}
+
+// serverTunnelOptions returns tunnel options for long-running server tunnels.
+//
+// Idle closing is disabled because a server destination that goes away
+// while idle is unreachable until the next outbound connection.
+func serverTunnelOptions() i2p.TunnelOptions {
+	options := i2p.DefaultTunnelOptions()
+	options.CloseIdle = false
+	options.CloseIdleTime = 0
+	return options
+}
+
+// exposurePresets holds the built-in presets by name.
+var exposurePresets = map[string]ExposurePreset{
+	"web": {
+		Name:        "web",
+		Description: "HTTP eepsite on port 80",
+		Ports: []ExposedPort{
+			{ContainerPort: 80, Protocol: "tcp", ServiceName: "web"},
+		},
+		TunnelOptions: func() i2p.TunnelOptions {
+			// Extra tunnels absorb bursts of page and asset requests
+			options := serverTunnelOptions()
+			options.InboundTunnels = 3
+			options.OutboundTunnels = 3
+			return options
+		}(),
+		HTTPHeaders: map[string]string{
+			"Referrer-Policy":        "no-referrer",
+			"X-Content-Type-Options": "nosniff",
+		},
+	},
+	"xmpp": {
+		Name:        "xmpp",
+		Description: "XMPP server with client (5222) and federation (5269) ports",
+		Ports: []ExposedPort{
+			{ContainerPort: 5222, Protocol: "tcp", ServiceName: "xmpp-client"},
+			{ContainerPort: 5269, Protocol: "tcp", ServiceName: "xmpp-server"},
+		},
+		TunnelOptions: serverTunnelOptions(),
+	},
+	"git": {
+		Name:        "git",
+		Description: "Git hosting over SSH (22) and the git daemon (9418)",
+		Ports: []ExposedPort{
+			{ContainerPort: 22, Protocol: "tcp", ServiceName: "git-ssh"},
+			{ContainerPort: 9418, Protocol: "tcp", ServiceName: "git"},
+		},
+		TunnelOptions: serverTunnelOptions(),
+	},
+}
+
+// LookupPreset returns the built-in preset with the given name.
+func LookupPreset(name string) (ExposurePreset, bool) {
+	preset, ok := exposurePresets[strings.ToLower(strings.TrimSpace(name))]
+	return preset, ok
+}
+
+// PresetNames returns the names of all built-in presets in sorted order.
+func PresetNames() []string {
+	names := make([]string, 0, len(exposurePresets))
+	for name := range exposurePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// extractPortsFromPresets expands the i2p.preset label into exposed ports.
+//
+// The label value is a preset name or a comma-separated list of names, e.g.
+// i2p.preset=web or i2p.preset=web,git. Unknown names are logged and skipped.
+func (sem *ServiceExposureManager) extractPortsFromPresets(options map[string]interface{}) []ExposedPort {
+	var ports []ExposedPort
+
+	labels, ok := options["Labels"].(map[string]interface{})
+	if !ok {
+		return ports
+	}
+
+	value, ok := labels[PresetLabel]
+	if !ok {
+		return ports
+	}
+
+	valueStr, ok := value.(string)
+	if !ok {
+		log.Printf("Warning: Invalid value type for label %s", PresetLabel)
+		return ports
+	}
+
+	for _, name := range strings.Split(valueStr, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+
+		preset, ok := LookupPreset(name)
+		if !ok {
+			log.Printf("Warning: Unknown exposure preset %q in label %s (available: %s)",
+				name, PresetLabel, strings.Join(PresetNames(), ", "))
+			continue
+		}
+
+		for _, port := range preset.Ports {
+			port.ExposureType = ExposureTypeI2P
+			applyPreset(&port, preset)
+			ports = append(ports, port)
+		}
+	}
+
+	return ports
+}
+
+// applyPreset copies a preset's tunnel options and HTTP headers onto a port.
+func applyPreset(port *ExposedPort, preset ExposurePreset) {
+	options := preset.TunnelOptions
+	port.Preset = preset.Name
+	port.TunnelOptions = &options
+
+	if len(preset.HTTPHeaders) > 0 {
+		port.HTTPHeaders = make(map[string]string, len(preset.HTTPHeaders))
+		for name, value := range preset.HTTPHeaders {
+			port.HTTPHeaders[name] = value
+		}
+	}
+}
+
+// ModeLabel is the container label selecting the container's I2P mode.
+const ModeLabel = "i2p.mode"
+
+// ModeClient is the ModeLabel value for client-only containers, which can
+// reach I2P but never get server tunnels or destinations of their own.
+const ModeClient = "client"
+
+// IsClientOnly reports whether container options select client-only mode.
+func IsClientOnly(options map[string]interface{}) bool {
+	labels, ok := options["Labels"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	mode, ok := labels[ModeLabel].(string)
+	return ok && strings.EqualFold(strings.TrimSpace(mode), ModeClient)
+}
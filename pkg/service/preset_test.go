@@ -0,0 +1,138 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestLookupPreset(t *testing.T) {
+	for _, name := range []string{"web", "xmpp", "git", " Web "} {
+		preset, ok := LookupPreset(name)
+		if !ok {
+			t.Errorf("LookupPreset(%q) not found", name)
+			continue
+		}
+		if len(preset.Ports) == 0 {
+			t.Errorf("preset %s has no ports", preset.Name)
+		}
+		if preset.TunnelOptions.CloseIdle {
+			t.Errorf("preset %s should keep idle server tunnels open", preset.Name)
+		}
+	}
+
+	if _, ok := LookupPreset("unknown"); ok {
+		t.Error("expected unknown preset to be rejected")
+	}
+}
+
+func TestPresetNames(t *testing.T) {
+	names := PresetNames()
+	expected := []string{"git", "web", "xmpp"}
+	if len(names) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, names)
+		}
+	}
+}
+
+func TestDetectExposedPortsWithPresets(t *testing.T) {
+	manager := &ServiceExposureManager{}
+
+	tests := []struct {
+		name     string
+		labels   map[string]interface{}
+		expected map[int]string
+	}{
+		{
+			name:     "web preset",
+			labels:   map[string]interface{}{"i2p.preset": "web"},
+			expected: map[int]string{80: "web"},
+		},
+		{
+			name:     "multiple presets",
+			labels:   map[string]interface{}{"i2p.preset": "xmpp, git"},
+			expected: map[int]string{5222: "xmpp", 5269: "xmpp", 22: "git", 9418: "git"},
+		},
+		{
+			name:     "unknown preset is skipped",
+			labels:   map[string]interface{}{"i2p.preset": "web,bogus"},
+			expected: map[int]string{80: "web"},
+		},
+		{
+			name: "label overrides preset port",
+			labels: map[string]interface{}{
+				"i2p.preset":    "web",
+				"i2p.expose.80": "i2p",
+			},
+			expected: map[int]string{80: "web"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ports, err := manager.DetectExposedPorts("container1", map[string]interface{}{"Labels": tt.labels})
+			if err != nil {
+				t.Fatalf("DetectExposedPorts() error = %v", err)
+			}
+			if len(ports) != len(tt.expected) {
+				t.Fatalf("expected %d ports, got %d: %+v", len(tt.expected), len(ports), ports)
+			}
+			for _, port := range ports {
+				preset, ok := tt.expected[port.ContainerPort]
+				if !ok {
+					t.Errorf("unexpected port %d", port.ContainerPort)
+					continue
+				}
+				if port.Preset != preset {
+					t.Errorf("port %d: expected preset %q, got %q", port.ContainerPort, preset, port.Preset)
+				}
+				if port.TunnelOptions == nil {
+					t.Errorf("port %d: expected preset tunnel options", port.ContainerPort)
+				}
+				if port.ExposureType != ExposureTypeI2P {
+					t.Errorf("port %d: expected I2P exposure, got %s", port.ContainerPort, port.ExposureType)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyPresetCopiesHeaders(t *testing.T) {
+	preset, _ := LookupPreset("web")
+
+	var port ExposedPort
+	applyPreset(&port, preset)
+	port.HTTPHeaders["Referrer-Policy"] = "changed"
+	port.TunnelOptions.InboundTunnels = 1
+
+	if preset.HTTPHeaders["Referrer-Policy"] != "no-referrer" {
+		t.Error("applyPreset should copy the preset's HTTP headers")
+	}
+	if fresh, _ := LookupPreset("web"); fresh.TunnelOptions.InboundTunnels != 3 {
+		t.Error("applyPreset should copy the preset's tunnel options")
+	}
+}
+
+func TestIsClientOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  map[string]interface{}
+		expected bool
+	}{
+		{name: "no labels", options: map[string]interface{}{}},
+		{name: "client mode", options: map[string]interface{}{"Labels": map[string]interface{}{ModeLabel: "client"}}, expected: true},
+		{name: "mixed case", options: map[string]interface{}{"Labels": map[string]interface{}{ModeLabel: " Client "}}, expected: true},
+		{name: "other mode", options: map[string]interface{}{"Labels": map[string]interface{}{ModeLabel: "server"}}},
+		{name: "wrong type", options: map[string]interface{}{"Labels": map[string]interface{}{ModeLabel: true}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsClientOnly(tt.options); got != tt.expected {
+				t.Errorf("IsClientOnly() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,496 @@
+// Package service provides external publication of exposure addresses.
+//
+// This file implements optional publishers that write the .b32.i2p address of
+// every I2P exposure to an external system, so clearnet-side infrastructure
+// can discover which destination belongs to which service. Supported backends
+// are an unbound zone file, a Consul KV path and a generic HTTP endpoint.
+// Consumers that need another encoding, such as the b33 address of a blinded
+// leaseset or the full base64 destination, select it with
+// PublisherConfig.Encoding.
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+// Publisher backend names accepted by NewPublisher.
+const (
+	PublisherZoneFile = "zonefile"
+	PublisherConsul   = "consul"
+	PublisherHTTP     = "http"
+)
+
+// DefaultPublishZone is the DNS zone used by the zone file publisher.
+const DefaultPublishZone = "i2p.internal"
+
+// publishTimeout bounds each request made by the network publishers.
+const publishTimeout = 5 * time.Second
+
+// maxTXTString is the longest string a TXT record can hold.
+const maxTXTString = 255
+
+// AddressRecord is the published address of a single I2P exposure.
+type AddressRecord struct {
+	// ContainerID identifies the container providing the service
+	ContainerID string `json:"container_id"`
+	// Service is the exposure purpose, e.g. "web-80"
+	Service string `json:"service"`
+	// Port is the exposed container port
+	Port int `json:"port"`
+	// Address is the address of the service in the publisher's encoding,
+	// the .b32.i2p address by default
+	Address string `json:"address"`
+	// Addresses are all encodings of the service's destination (nil if unknown)
+	Addresses *i2p.Addresses `json:"addresses,omitempty"`
+	// Exposure is the exposure the address belongs to, sent along by the
+	// HTTP publisher (nil if unknown)
+	Exposure *ExposureInfo `json:"-"`
+}
+
+// Publisher writes exposure addresses to an external system.
+//
+// Publish is called after an I2P exposure is created and Unpublish after it
+// is removed. Implementations must be safe for concurrent use.
+type Publisher interface {
+	Publish(record AddressRecord) error
+	Unpublish(record AddressRecord) error
+}
+
+// PublisherConfig selects and configures a publisher backend.
+type PublisherConfig struct {
+	// Backend is one of "zonefile", "consul" or "http" (empty disables publication)
+	Backend string `json:"backend"`
+	// Target is the zone file path, Consul KV URL prefix or HTTP endpoint URL
+	Target string `json:"target"`
+	// Zone is the DNS zone for zone file records (defaults to DefaultPublishZone)
+	Zone string `json:"zone,omitempty"`
+	// Encoding is the address encoding published: b32 (default), b33 or base64
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// NewPublisher creates the publisher selected by config.
+//
+// Returns nil without error when no backend is configured.
+func NewPublisher(config PublisherConfig) (Publisher, error) {
+	if config.Backend == "" {
+		return nil, nil
+	}
+	if config.Target == "" {
+		return nil, fmt.Errorf("publisher %s requires a target", config.Backend)
+	}
+	if config.Encoding != "" {
+		if err := i2p.ValidateEncoding(config.Encoding); err != nil {
+			return nil, err
+		}
+	}
+
+	var publisher Publisher
+	switch config.Backend {
+	case PublisherZoneFile:
+		publisher = NewZoneFilePublisher(config.Target, config.Zone)
+	case PublisherConsul:
+		consul, err := NewConsulPublisher(config.Target)
+		if err != nil {
+			return nil, err
+		}
+		publisher = consul
+	case PublisherHTTP:
+		hook, err := NewHTTPPublisher(config.Target)
+		if err != nil {
+			return nil, err
+		}
+		publisher = hook
+	default:
+		return nil, fmt.Errorf("unknown publisher backend %q (must be %s, %s or %s)",
+			config.Backend, PublisherZoneFile, PublisherConsul, PublisherHTTP)
+	}
+
+	if config.Encoding == "" || config.Encoding == i2p.EncodingB32 {
+		return publisher, nil
+	}
+	return &encodingPublisher{Publisher: publisher, encoding: config.Encoding}, nil
+}
+
+// Publishers combines publishers into one that notifies each in turn. nil
+// publishers are skipped; without any, Publishers returns nil.
+func Publishers(publishers ...Publisher) Publisher {
+	var combined multiPublisher
+	for _, publisher := range publishers {
+		if publisher != nil {
+			combined = append(combined, publisher)
+		}
+	}
+	switch len(combined) {
+	case 0:
+		return nil
+	case 1:
+		return combined[0]
+	}
+	return combined
+}
+
+// multiPublisher notifies several publishers of every address.
+type multiPublisher []Publisher
+
+func (m multiPublisher) Publish(record AddressRecord) error {
+	var errs []error
+	for _, publisher := range m {
+		if err := publisher.Publish(record); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiPublisher) Unpublish(record AddressRecord) error {
+	var errs []error
+	for _, publisher := range m {
+		if err := publisher.Unpublish(record); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// encodingPublisher publishes addresses in an encoding other than b32.
+type encodingPublisher struct {
+	Publisher
+	encoding string
+}
+
+// Publish publishes the record with its address in the configured encoding.
+func (e *encodingPublisher) Publish(record AddressRecord) error {
+	return e.Publisher.Publish(record.Encoded(e.encoding))
+}
+
+// Unpublish removes the record with its address in the configured encoding.
+func (e *encodingPublisher) Unpublish(record AddressRecord) error {
+	return e.Publisher.Unpublish(record.Encoded(e.encoding))
+}
+
+// Encoded returns the record with its address in the named encoding. Records
+// without known addresses are returned unchanged.
+func (r AddressRecord) Encoded(encoding string) AddressRecord {
+	if r.Addresses != nil {
+		r.Address = r.Addresses.Encoded(encoding)
+	}
+	return r
+}
+
+// recordKey identifies a record by container and service.
+func (r AddressRecord) recordKey() string {
+	return r.ContainerID + "/" + r.Service
+}
+
+// ZoneFilePublisher maintains an unbound include file with one TXT record per exposure.
+//
+// The whole file is rewritten atomically on every change, so unbound can
+// include it directly and pick up changes on reload.
+type ZoneFilePublisher struct {
+	path    string
+	zone    string
+	records map[string]AddressRecord
+	mutex   sync.Mutex
+}
+
+// NewZoneFilePublisher creates a zone file publisher writing to path.
+func NewZoneFilePublisher(path, zone string) *ZoneFilePublisher {
+	if zone == "" {
+		zone = DefaultPublishZone
+	}
+	return &ZoneFilePublisher{
+		path:    path,
+		zone:    strings.Trim(zone, "."),
+		records: make(map[string]AddressRecord),
+	}
+}
+
+// Publish adds a record and rewrites the zone file.
+func (z *ZoneFilePublisher) Publish(record AddressRecord) error {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	z.records[record.recordKey()] = record
+	return z.writeLocked()
+}
+
+// Unpublish removes a record and rewrites the zone file.
+func (z *ZoneFilePublisher) Unpublish(record AddressRecord) error {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	delete(z.records, record.recordKey())
+	return z.writeLocked()
+}
+
+// RecordName returns the DNS name a record is published under,
+// e.g. "web-80.3f2a1b9c8d7e.i2p.internal".
+func (z *ZoneFilePublisher) RecordName(record AddressRecord) string {
+	containerID := record.ContainerID
+	if len(containerID) > 12 {
+		containerID = containerID[:12]
+	}
+	return fmt.Sprintf("%s.%s.%s", dnsLabel(record.Service), dnsLabel(containerID), z.zone)
+}
+
+// writeLocked renders all records to a temporary file and renames it into
+// place. The caller must hold z.mutex.
+func (z *ZoneFilePublisher) writeLocked() error {
+	keys := make([]string, 0, len(z.records))
+	for key := range z.records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by i2p-network-plugin, do not edit.\n")
+	for _, key := range keys {
+		record := z.records[key]
+		fmt.Fprintf(&buf, "local-data: \"%s. IN TXT %s\"\n", z.RecordName(record), txtData(record.Address))
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(z.path), filepath.Base(z.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary zone file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write zone file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write zone file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to set zone file permissions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), z.path); err != nil {
+		return fmt.Errorf("failed to replace zone file %s: %w", z.path, err)
+	}
+	return nil
+}
+
+// txtData returns the quoted TXT strings of an address. Base64 destinations
+// are longer than a TXT string may be and are split across several strings.
+func txtData(address string) string {
+	if strings.HasSuffix(address, ".i2p") {
+		address = dnsLabel(address)
+	} else {
+		address = strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '~':
+				return r
+			default:
+				return '-'
+			}
+		}, address)
+	}
+
+	var parts []string
+	for len(address) > maxTXTString {
+		parts = append(parts, `\"`+address[:maxTXTString]+`\"`)
+		address = address[maxTXTString:]
+	}
+	parts = append(parts, `\"`+address+`\"`)
+	return strings.Join(parts, " ")
+}
+
+// dnsLabel replaces characters that are not valid in DNS names with '-'.
+func dnsLabel(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '-'
+		}
+	}, s)
+}
+
+// ConsulPublisher stores addresses as Consul KV entries.
+//
+// Each record is written to <prefix>/<container>/<service>, where prefix is a
+// KV URL such as http://127.0.0.1:8500/v1/kv/i2p/services.
+type ConsulPublisher struct {
+	prefix string
+	client *http.Client
+}
+
+// NewConsulPublisher creates a Consul KV publisher for the given URL prefix.
+func NewConsulPublisher(prefix string) (*ConsulPublisher, error) {
+	if err := validatePublishURL(prefix); err != nil {
+		return nil, err
+	}
+	return &ConsulPublisher{
+		prefix: strings.TrimSuffix(prefix, "/"),
+		client: &http.Client{Timeout: publishTimeout},
+	}, nil
+}
+
+// Publish writes the record's address to its KV key.
+func (c *ConsulPublisher) Publish(record AddressRecord) error {
+	return c.do(http.MethodPut, record, strings.NewReader(record.Address))
+}
+
+// Unpublish deletes the record's KV key.
+func (c *ConsulPublisher) Unpublish(record AddressRecord) error {
+	return c.do(http.MethodDelete, record, nil)
+}
+
+// do sends a KV request for a record.
+func (c *ConsulPublisher) do(method string, record AddressRecord, body io.Reader) error {
+	key := c.prefix + "/" + url.PathEscape(record.ContainerID) + "/" + url.PathEscape(record.Service)
+
+	req, err := http.NewRequest(method, key, body)
+	if err != nil {
+		return fmt.Errorf("failed to create Consul request: %w", err)
+	}
+
+	return sendPublishRequest(c.client, req)
+}
+
+// HTTPPublisher posts publish and unpublish events as JSON to an HTTP endpoint.
+//
+// The request body is {"action": "publish"|"unpublish", "record": {...},
+// "exposure": {...}}, where exposure is the ExposureInfo of the record's
+// exposure.
+type HTTPPublisher struct {
+	endpoint string
+	client   *http.Client
+}
+
+// httpPublishEvent is the JSON body sent by HTTPPublisher.
+type httpPublishEvent struct {
+	Action   string        `json:"action"`
+	Record   AddressRecord `json:"record"`
+	Exposure *ExposureInfo `json:"exposure,omitempty"`
+}
+
+// NewHTTPPublisher creates a publisher posting to endpoint.
+func NewHTTPPublisher(endpoint string) (*HTTPPublisher, error) {
+	if err := validatePublishURL(endpoint); err != nil {
+		return nil, err
+	}
+	return &HTTPPublisher{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: publishTimeout},
+	}, nil
+}
+
+// Publish posts a publish event for the record.
+func (h *HTTPPublisher) Publish(record AddressRecord) error {
+	return h.post("publish", record)
+}
+
+// Unpublish posts an unpublish event for the record.
+func (h *HTTPPublisher) Unpublish(record AddressRecord) error {
+	return h.post("unpublish", record)
+}
+
+// post sends a single event to the endpoint.
+func (h *HTTPPublisher) post(action string, record AddressRecord) error {
+	body, err := json.Marshal(httpPublishEvent{Action: action, Record: record, Exposure: record.Exposure})
+	if err != nil {
+		return fmt.Errorf("failed to encode publish event: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, h.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create publish request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return sendPublishRequest(h.client, req)
+}
+
+// validatePublishURL checks that a publisher target is an absolute HTTP(S) URL.
+func validatePublishURL(target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("invalid publisher URL %q: %w", target, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid publisher URL %q: must be an http or https URL", target)
+	}
+	return nil
+}
+
+// sendPublishRequest sends req and fails on non-2xx responses.
+func sendPublishRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w", req.Method, req.URL.Redacted(), err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s returned %s", req.Method, req.URL.Redacted(), resp.Status)
+	}
+	return nil
+}
+
+// SetPublisher sets the publisher notified of I2P exposure addresses.
+//
+// A nil publisher disables publication. Exposures that already exist are
+// not published retroactively.
+func (sem *ServiceExposureManager) SetPublisher(publisher Publisher) {
+	sem.mutex.Lock()
+	defer sem.mutex.Unlock()
+
+	sem.publisher = publisher
+}
+
+// exposureRecord returns the address record for an I2P exposure.
+func exposureRecord(exposure *ServiceExposure) AddressRecord {
+	info := exposure.Info()
+	return AddressRecord{
+		ContainerID: exposure.ContainerID,
+		Service:     exposurePurpose(exposure.Port),
+		Port:        exposure.Port.ContainerPort,
+		Address:     exposure.Destination,
+		Addresses:   exposure.Addresses,
+		Exposure:    &info,
+	}
+}
+
+// publishExposureLocked publishes the address of an I2P exposure.
+//
+// Publication is best effort: failures are logged and never fail the
+// exposure. The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) publishExposureLocked(exposure *ServiceExposure) {
+	if sem.publisher == nil || exposure.Tunnel == nil {
+		return
+	}
+	if err := sem.publisher.Publish(exposureRecord(exposure)); err != nil {
+		log.Printf("Warning: Failed to publish address of %s: %v", exposure.TunnelName, err)
+	}
+}
+
+// unpublishExposureLocked removes the published address of an I2P exposure.
+// The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) unpublishExposureLocked(exposure *ServiceExposure) {
+	if sem.publisher == nil || exposure.Tunnel == nil {
+		return
+	}
+	if err := sem.publisher.Unpublish(exposureRecord(exposure)); err != nil {
+		log.Printf("Warning: Failed to unpublish address of %s: %v", exposure.TunnelName, err)
+	}
+}
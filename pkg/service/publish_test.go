@@ -0,0 +1,309 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+)
+
+var testRecord = AddressRecord{
+	ContainerID: "3f2a1b9c8d7e6f5a4b3c",
+	Service:     "web-80",
+	Port:        80,
+	Address:     "abcdefghijklmnopqrstuvwxyz234567abcdefghijklmnopqrst.b32.i2p",
+}
+
+func TestNewPublisher(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      PublisherConfig
+		expectNil   bool
+		expectError bool
+	}{
+		{name: "disabled", config: PublisherConfig{}, expectNil: true},
+		{name: "zone file", config: PublisherConfig{Backend: PublisherZoneFile, Target: "/tmp/i2p.conf"}},
+		{name: "consul", config: PublisherConfig{Backend: PublisherConsul, Target: "http://127.0.0.1:8500/v1/kv/i2p"}},
+		{name: "http", config: PublisherConfig{Backend: PublisherHTTP, Target: "https://example.com/hook"}},
+		{name: "missing target", config: PublisherConfig{Backend: PublisherHTTP}, expectError: true},
+		{name: "invalid URL", config: PublisherConfig{Backend: PublisherConsul, Target: "127.0.0.1:8500"}, expectError: true},
+		{name: "unknown backend", config: PublisherConfig{Backend: "etcd", Target: "http://etcd"}, expectError: true},
+		{name: "b33 encoding", config: PublisherConfig{Backend: PublisherZoneFile, Target: "/tmp/i2p.conf", Encoding: i2p.EncodingB33}},
+		{name: "unknown encoding", config: PublisherConfig{Backend: PublisherZoneFile, Target: "/tmp/i2p.conf", Encoding: "b64"}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher, err := NewPublisher(tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if (publisher == nil) != tt.expectNil {
+				t.Errorf("Expected nil publisher %v, got %v", tt.expectNil, publisher)
+			}
+		})
+	}
+}
+
+func TestZoneFilePublisher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "i2p.conf")
+	publisher := NewZoneFilePublisher(path, "i2p.example.")
+
+	if err := publisher.Publish(testRecord); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read zone file: %v", err)
+	}
+	expected := `local-data: "web-80.3f2a1b9c8d7e.i2p.example. IN TXT \"` + testRecord.Address + `\""`
+	if !strings.Contains(string(data), expected) {
+		t.Errorf("Zone file missing record %s:\n%s", expected, data)
+	}
+
+	if err := publisher.Unpublish(testRecord); err != nil {
+		t.Fatalf("Unpublish() error = %v", err)
+	}
+
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read zone file: %v", err)
+	}
+	if strings.Contains(string(data), "local-data") {
+		t.Errorf("Expected record to be removed:\n%s", data)
+	}
+}
+
+func TestZoneFilePublisher_Encoding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "i2p.conf")
+	publisher, err := NewPublisher(PublisherConfig{Backend: PublisherZoneFile, Target: path, Encoding: i2p.EncodingBase64})
+	if err != nil {
+		t.Fatalf("NewPublisher() unexpected error: %v", err)
+	}
+
+	destination := strings.Repeat("AbC~", 130) + "AAAA"
+	record := testRecord
+	record.Addresses = &i2p.Addresses{Base64: destination, B32: testRecord.Address}
+	if err := publisher.Publish(record); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read zone file: %v", err)
+	}
+	expected := `IN TXT \"` + destination[:255] + `\" \"` + destination[255:510] + `\" \"` + destination[510:] + `\""`
+	if !strings.Contains(string(data), expected) {
+		t.Errorf("Expected the destination split across TXT strings:\n%s", data)
+	}
+}
+
+func TestAddressRecord_Encoded(t *testing.T) {
+	record := testRecord
+	if encoded := record.Encoded(i2p.EncodingB33); encoded.Address != testRecord.Address {
+		t.Errorf("Expected records without addresses to be unchanged, got %s", encoded.Address)
+	}
+
+	record.Addresses = &i2p.Addresses{Base64: "base64dest", B32: testRecord.Address, B33: "blinded.b32.i2p"}
+	if encoded := record.Encoded(i2p.EncodingB33); encoded.Address != "blinded.b32.i2p" {
+		t.Errorf("Expected the b33 address, got %s", encoded.Address)
+	}
+	if encoded := record.Encoded(i2p.EncodingBase64); encoded.Address != "base64dest" {
+		t.Errorf("Expected the base64 destination, got %s", encoded.Address)
+	}
+	if record.Address != testRecord.Address {
+		t.Error("Expected Encoded not to modify the record")
+	}
+}
+
+func TestZoneFilePublisher_RecordName(t *testing.T) {
+	publisher := NewZoneFilePublisher("/tmp/unused", "")
+
+	record := AddressRecord{ContainerID: "abc", Service: "Web_80\" IN A"}
+	if name := publisher.RecordName(record); name != "web-80--in-a.abc.i2p.internal" {
+		t.Errorf("Unexpected record name %q", name)
+	}
+}
+
+func TestConsulPublisher(t *testing.T) {
+	var mutex sync.Mutex
+	requests := make(map[string]string)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		requests[r.Method+" "+r.URL.Path] = string(body)
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	publisher, err := NewConsulPublisher(server.URL + "/v1/kv/i2p/")
+	if err != nil {
+		t.Fatalf("NewConsulPublisher() error = %v", err)
+	}
+
+	if err := publisher.Publish(testRecord); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if err := publisher.Unpublish(testRecord); err != nil {
+		t.Fatalf("Unpublish() error = %v", err)
+	}
+
+	key := "/v1/kv/i2p/" + testRecord.ContainerID + "/web-80"
+	if body, ok := requests["PUT "+key]; !ok || body != testRecord.Address {
+		t.Errorf("Expected PUT %s with address, got %v", key, requests)
+	}
+	if _, ok := requests["DELETE "+key]; !ok {
+		t.Errorf("Expected DELETE %s, got %v", key, requests)
+	}
+}
+
+func TestHTTPPublisher(t *testing.T) {
+	var events []httpPublishEvent
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event httpPublishEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		events = append(events, event)
+	}))
+	defer server.Close()
+
+	publisher, err := NewHTTPPublisher(server.URL)
+	if err != nil {
+		t.Fatalf("NewHTTPPublisher() error = %v", err)
+	}
+
+	record := testRecord
+	record.Exposure = &ExposureInfo{ContainerID: testRecord.ContainerID, Service: testRecord.Service, State: ExposureStateActive}
+	if err := publisher.Publish(record); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if err := publisher.Unpublish(testRecord); err != nil {
+		t.Fatalf("Unpublish() error = %v", err)
+	}
+
+	if len(events) != 2 || events[0].Action != "publish" || events[1].Action != "unpublish" {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+	if events[0].Record != testRecord {
+		t.Errorf("Expected record %+v, got %+v", testRecord, events[0].Record)
+	}
+	if events[0].Exposure == nil || *events[0].Exposure != *record.Exposure {
+		t.Errorf("Expected the exposure %+v to be sent, got %+v", record.Exposure, events[0].Exposure)
+	}
+	if events[1].Exposure != nil {
+		t.Errorf("Expected no exposure for a record without one, got %+v", events[1].Exposure)
+	}
+}
+
+func TestHTTPPublisher_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	publisher, err := NewHTTPPublisher(server.URL)
+	if err != nil {
+		t.Fatalf("NewHTTPPublisher() error = %v", err)
+	}
+
+	if err := publisher.Publish(testRecord); err == nil {
+		t.Error("Expected error for 503 response")
+	}
+}
+
+// recordingPublisher records published and unpublished records.
+type recordingPublisher struct {
+	published   []AddressRecord
+	unpublished []AddressRecord
+}
+
+func (r *recordingPublisher) Publish(record AddressRecord) error {
+	r.published = append(r.published, record)
+	return nil
+}
+
+func (r *recordingPublisher) Unpublish(record AddressRecord) error {
+	r.unpublished = append(r.unpublished, record)
+	return nil
+}
+
+func TestPublishers(t *testing.T) {
+	if Publishers(nil, nil) != nil {
+		t.Error("Expected no publisher without any to combine")
+	}
+	single := &recordingPublisher{}
+	if Publishers(nil, single) != single {
+		t.Error("Expected a single publisher to be returned as is")
+	}
+
+	other := &recordingPublisher{}
+	combined := Publishers(single, other)
+	if err := combined.Publish(testRecord); err != nil {
+		t.Fatalf("Publish() unexpected error: %v", err)
+	}
+	if err := combined.Unpublish(testRecord); err != nil {
+		t.Fatalf("Unpublish() unexpected error: %v", err)
+	}
+	for _, publisher := range []*recordingPublisher{single, other} {
+		if len(publisher.published) != 1 || len(publisher.unpublished) != 1 {
+			t.Errorf("Expected every publisher to be notified, got %+v", publisher)
+		}
+	}
+}
+
+func TestServiceExposureManager_PublishesI2PExposures(t *testing.T) {
+	publisher := &recordingPublisher{}
+	manager := &ServiceExposureManager{}
+	manager.SetPublisher(publisher)
+
+	i2pExposure := &ServiceExposure{
+		ContainerID: testRecord.ContainerID,
+		Port:        ExposedPort{ContainerPort: 80, ServiceName: "web", ExposureType: ExposureTypeI2P},
+		Tunnel:      &i2p.Tunnel{},
+		Destination: testRecord.Address,
+	}
+	ipExposure := &ServiceExposure{
+		ContainerID: testRecord.ContainerID,
+		Port:        ExposedPort{ContainerPort: 8080, ExposureType: ExposureTypeIP},
+		Destination: "127.0.0.1:8080",
+	}
+
+	manager.publishExposureLocked(i2pExposure)
+	manager.publishExposureLocked(ipExposure)
+	manager.unpublishExposureLocked(i2pExposure)
+	manager.unpublishExposureLocked(ipExposure)
+
+	// Records carry the exposure, which the HTTP publisher sends along
+	for _, records := range [][]AddressRecord{publisher.published, publisher.unpublished} {
+		for i := range records {
+			if records[i].Exposure == nil || records[i].Exposure.Service != "web-80" {
+				t.Errorf("Expected the record to carry its exposure, got %+v", records[i].Exposure)
+			}
+			records[i].Exposure = nil
+		}
+	}
+	if len(publisher.published) != 1 || publisher.published[0] != testRecord {
+		t.Errorf("Expected only the I2P exposure to be published as %+v, got %+v", testRecord, publisher.published)
+	}
+	if len(publisher.unpublished) != 1 || publisher.unpublished[0] != testRecord {
+		t.Errorf("Expected only the I2P exposure to be unpublished, got %+v", publisher.unpublished)
+	}
+}
@@ -0,0 +1,61 @@
+// Package service provides the tunnel strategy of a container's exposures.
+//
+// The i2p.tunnel.strategy label selects how a container's I2P exposures are
+// served. With per-port (the default) every exposed port gets a server
+// sub-session of its own. With shared all of them go through one server
+// sub-session that routes incoming streams by port, which keeps router load
+// flat for containers exposing many ports.
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TunnelStrategyLabel is the container label selecting the tunnel strategy.
+const TunnelStrategyLabel = "i2p.tunnel.strategy"
+
+const (
+	// TunnelStrategyPerPort gives every exposed port its own server sub-session
+	TunnelStrategyPerPort = "per-port"
+	// TunnelStrategyShared serves every exposed port from one server sub-session
+	TunnelStrategyShared = "shared"
+)
+
+// TunnelStrategies returns the supported tunnel strategies.
+func TunnelStrategies() []string {
+	return []string{TunnelStrategyPerPort, TunnelStrategyShared}
+}
+
+// tunnelStrategy returns the tunnel strategy selected by container labels.
+func tunnelStrategy(options map[string]interface{}) (string, error) {
+	labels, ok := options["Labels"].(map[string]interface{})
+	if !ok {
+		return TunnelStrategyPerPort, nil
+	}
+
+	value, ok := labels[TunnelStrategyLabel].(string)
+	if !ok {
+		return TunnelStrategyPerPort, nil
+	}
+
+	switch strategy := strings.ToLower(strings.TrimSpace(value)); strategy {
+	case "", TunnelStrategyPerPort:
+		return TunnelStrategyPerPort, nil
+	case TunnelStrategyShared:
+		return TunnelStrategyShared, nil
+	}
+	return "", fmt.Errorf("invalid %s label %q (expected %s or %s)",
+		TunnelStrategyLabel, value, TunnelStrategyPerPort, TunnelStrategyShared)
+}
+
+// sharesTunnelLocked reports whether a container's I2P exposures use the
+// shared strategy. The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) sharesTunnelLocked(containerID string) bool {
+	for _, exposure := range sem.exposures[containerID] {
+		if exposure.Port.SharedTunnel {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestTunnelStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  map[string]interface{}
+		expected string
+		wantErr  bool
+	}{
+		{name: "no labels", options: map[string]interface{}{}, expected: TunnelStrategyPerPort},
+		{name: "no strategy", options: map[string]interface{}{"Labels": map[string]interface{}{}}, expected: TunnelStrategyPerPort},
+		{name: "per-port", options: map[string]interface{}{"Labels": map[string]interface{}{TunnelStrategyLabel: "per-port"}}, expected: TunnelStrategyPerPort},
+		{name: "shared", options: map[string]interface{}{"Labels": map[string]interface{}{TunnelStrategyLabel: " Shared "}}, expected: TunnelStrategyShared},
+		{name: "unknown", options: map[string]interface{}{"Labels": map[string]interface{}{TunnelStrategyLabel: "pooled"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := tunnelStrategy(tt.options)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got strategy %q", strategy)
+				}
+				return
+			}
+			if err != nil || strategy != tt.expected {
+				t.Errorf("Expected %q, got %q (%v)", tt.expected, strategy, err)
+			}
+		})
+	}
+}
+
+func TestDetectExposedPortsSharedStrategy(t *testing.T) {
+	manager := &ServiceExposureManager{}
+
+	ports, err := manager.DetectExposedPorts("container1", map[string]interface{}{
+		"Labels": map[string]interface{}{
+			TunnelStrategyLabel: TunnelStrategyShared,
+			"i2p.expose.80":     "i2p",
+			"i2p.expose.443":    "i2p",
+			"i2p.expose.8080":   "ip",
+		},
+	})
+	if err != nil {
+		t.Fatalf("DetectExposedPorts() unexpected error: %v", err)
+	}
+	if len(ports) != 3 {
+		t.Fatalf("Expected 3 ports, got %d", len(ports))
+	}
+	for _, port := range ports {
+		if shared := port.ExposureType == ExposureTypeI2P; port.SharedTunnel != shared {
+			t.Errorf("Port %d (%s): expected shared tunnel %v", port.ContainerPort, port.ExposureType, shared)
+		}
+	}
+
+	if _, err := manager.DetectExposedPorts("container1", map[string]interface{}{
+		"Labels": map[string]interface{}{TunnelStrategyLabel: "pooled", "i2p.expose.80": "i2p"},
+	}); err == nil {
+		t.Error("Expected error for an unknown tunnel strategy")
+	}
+}
+
+func TestSharesTunnelLocked(t *testing.T) {
+	manager := &ServiceExposureManager{exposures: map[string][]*ServiceExposure{
+		"shared":   {{Port: ExposedPort{ContainerPort: 80, SharedTunnel: true}}},
+		"per-port": {{Port: ExposedPort{ContainerPort: 80}}},
+	}}
+
+	if !manager.sharesTunnelLocked("shared") {
+		t.Error("Expected container with shared exposures to use the shared strategy")
+	}
+	if manager.sharesTunnelLocked("per-port") || manager.sharesTunnelLocked("unknown") {
+		t.Error("Expected containers without shared exposures to use the per-port strategy")
+	}
+}
@@ -0,0 +1,142 @@
+// Package service provides exposure expiry for temporary services.
+//
+// This file implements exposure TTLs, requested with label options such as
+// i2p.expose.8080=i2p;ttl=2h. When the TTL elapses the exposure's tunnel or
+// forwarder is torn down, and if it was the container's last tunnel its I2P
+// session is closed too, so the address of an ephemeral service doesn't
+// linger after it is no longer wanted.
+package service
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpiryHandler is called after an exposure has been removed because its TTL elapsed.
+type ExpiryHandler func(exposure *ServiceExposure)
+
+// SetExpiryHandler sets the function notified when an exposure expires.
+//
+// The handler runs without sem.mutex held and may call back into the manager.
+func (sem *ServiceExposureManager) SetExpiryHandler(handler ExpiryHandler) {
+	sem.mutex.Lock()
+	defer sem.mutex.Unlock()
+
+	sem.onExpire = handler
+}
+
+// applyExposureOptions applies the ";"-separated options of an exposure label.
+//
+// Options have the form key=value. Supported options:
+//   - ttl=<duration>         remove the exposure after the duration (e.g. 30m, 2h)
+//   - inbound_only=<bool>    create an inbound-only server tunnel (I2P exposure only)
+func applyExposureOptions(port *ExposedPort, options []string) error {
+	for _, option := range options {
+		name, value, ok := strings.Cut(strings.TrimSpace(option), "=")
+		if !ok {
+			return fmt.Errorf("option %q must have the form key=value", option)
+		}
+
+		switch strings.TrimSpace(name) {
+		case "ttl":
+			ttl, err := time.ParseDuration(strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("invalid ttl %q: %w", value, err)
+			}
+			if ttl <= 0 {
+				return fmt.Errorf("ttl must be positive, got %s", ttl)
+			}
+			port.TTL = ttl
+		case "inbound_only":
+			inboundOnly, err := strconv.ParseBool(strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("invalid inbound_only %q: %w", value, err)
+			}
+			if inboundOnly && port.ExposureType != ExposureTypeI2P {
+				return fmt.Errorf("inbound_only only applies to i2p exposures")
+			}
+			port.InboundOnly = inboundOnly
+		default:
+			return fmt.Errorf("unknown option %q", name)
+		}
+	}
+	return nil
+}
+
+// scheduleExpiryLocked arms the expiry timer of an exposure with a TTL.
+// The caller must hold sem.mutex.
+func (sem *ServiceExposureManager) scheduleExpiryLocked(exposure *ServiceExposure) {
+	if exposure.Port.TTL <= 0 {
+		return
+	}
+
+	exposure.ExpiresAt = time.Now().Add(exposure.Port.TTL)
+	exposure.expiry = time.AfterFunc(exposure.Port.TTL, func() {
+		sem.expireExposure(exposure)
+	})
+
+	log.Printf("Exposure %s for container %s expires at %s",
+		exposure.TunnelName, exposure.ContainerID, exposure.ExpiresAt.Format(time.RFC3339))
+}
+
+// stopExpiry disarms the expiry timer of an exposure, if any.
+func (exposure *ServiceExposure) stopExpiry() {
+	if exposure.expiry != nil {
+		exposure.expiry.Stop()
+	}
+}
+
+// expireExposure removes an exposure whose TTL has elapsed.
+//
+// Nothing happens if the exposure was already removed or the manager is
+// shutting down.
+func (sem *ServiceExposureManager) expireExposure(exposure *ServiceExposure) {
+	if sem.ctx.Err() != nil {
+		return
+	}
+
+	sem.mutex.Lock()
+
+	exposures := sem.exposures[exposure.ContainerID]
+	index := -1
+	for i, existing := range exposures {
+		if existing == exposure {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		sem.mutex.Unlock()
+		return
+	}
+
+	sem.exposures[exposure.ContainerID] = append(exposures[:index:index], exposures[index+1:]...)
+	if len(sem.exposures[exposure.ContainerID]) == 0 {
+		delete(sem.exposures, exposure.ContainerID)
+	}
+
+	log.Printf("TTL of %s exposure %s for container %s elapsed, removing it",
+		exposure.Port.ExposureType, exposure.TunnelName, exposure.ContainerID)
+
+	if err := sem.destroyExposure(exposure); err != nil {
+		log.Printf("Warning: Failed to remove expired exposure %s: %v", exposure.TunnelName, err)
+	}
+
+	// Drop the container's I2P identity once nothing uses it any more, so the
+	// destination of an ephemeral service cannot be reached again
+	if exposure.Tunnel != nil && !sem.tunnelMgr.HasContainerTunnels(exposure.ContainerID) {
+		if err := sem.tunnelMgr.DestroyContainerSession(exposure.ContainerID); err != nil {
+			log.Printf("Warning: Failed to close I2P session of container %s: %v", exposure.ContainerID, err)
+		}
+	}
+
+	handler := sem.onExpire
+	sem.mutex.Unlock()
+
+	if handler != nil {
+		handler(exposure)
+	}
+}
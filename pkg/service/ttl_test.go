@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestApplyExposureOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		options     []string
+		expectedTTL time.Duration
+		shouldFail  bool
+	}{
+		{name: "no options", options: nil},
+		{name: "ttl", options: []string{"ttl=2h"}, expectedTTL: 2 * time.Hour},
+		{name: "ttl with spaces", options: []string{" ttl = 30m "}, expectedTTL: 30 * time.Minute},
+		{name: "zero ttl", options: []string{"ttl=0s"}, shouldFail: true},
+		{name: "negative ttl", options: []string{"ttl=-1h"}, shouldFail: true},
+		{name: "invalid ttl", options: []string{"ttl=tomorrow"}, shouldFail: true},
+		{name: "missing value", options: []string{"ttl"}, shouldFail: true},
+		{name: "unknown option", options: []string{"color=blue"}, shouldFail: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var port ExposedPort
+			err := applyExposureOptions(&port, tt.options)
+			if tt.shouldFail {
+				if err == nil {
+					t.Error("Expected error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if port.TTL != tt.expectedTTL {
+				t.Errorf("Expected TTL %s, got %s", tt.expectedTTL, port.TTL)
+			}
+		})
+	}
+}
+
+func TestApplyExposureOptionsInboundOnly(t *testing.T) {
+	port := ExposedPort{ExposureType: ExposureTypeI2P}
+	if err := applyExposureOptions(&port, []string{"inbound_only=true"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !port.InboundOnly {
+		t.Error("Expected inbound_only to be set")
+	}
+
+	if err := applyExposureOptions(&port, []string{"inbound_only=maybe"}); err == nil {
+		t.Error("Expected error for invalid inbound_only value")
+	}
+
+	ipPort := ExposedPort{ExposureType: ExposureTypeIP}
+	if err := applyExposureOptions(&ipPort, []string{"inbound_only=true"}); err == nil {
+		t.Error("Expected error for inbound_only on an IP exposure")
+	}
+	if err := applyExposureOptions(&ipPort, []string{"inbound_only=false"}); err != nil {
+		t.Errorf("Unexpected error for disabled inbound_only: %v", err)
+	}
+}
+
+func TestParseExposureLabelWithTTL(t *testing.T) {
+	manager := &ServiceExposureManager{}
+
+	port := manager.parseExposureLabel("i2p.expose.8080", "i2p;ttl=2h")
+	if port == nil {
+		t.Fatal("Expected port, got nil")
+	}
+	if port.ExposureType != ExposureTypeI2P || port.TTL != 2*time.Hour {
+		t.Errorf("Expected I2P exposure with 2h TTL, got %s with %s", port.ExposureType, port.TTL)
+	}
+
+	port = manager.parseExposureLabel("i2p.expose.9090", "ip:::1;ttl=15m")
+	if port == nil {
+		t.Fatal("Expected port, got nil")
+	}
+	if port.TargetIP != "::1" || port.TTL != 15*time.Minute {
+		t.Errorf("Expected IP exposure on ::1 with 15m TTL, got %s with %s", port.TargetIP, port.TTL)
+	}
+
+	if port := manager.parseExposureLabel("i2p.expose.8080", "i2p;ttl=forever"); port != nil {
+		t.Errorf("Expected invalid TTL to be rejected, got %+v", port)
+	}
+}
+
+func TestExposureExpiry(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find free port: %v", err)
+	}
+	freePort := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	manager := &ServiceExposureManager{
+		exposures: make(map[string][]*ServiceExposure),
+		portMaps:  make(map[string][]*PortMapping),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+
+	expired := make(chan *ServiceExposure, 1)
+	manager.SetExpiryHandler(func(exposure *ServiceExposure) {
+		expired <- exposure
+	})
+
+	exposure, err := manager.ExposeService("container1", "net1", net.ParseIP("127.0.0.1"), ExposedPort{
+		ContainerPort: freePort,
+		Protocol:      "tcp",
+		ExposureType:  ExposureTypeIP,
+		TargetIP:      "127.0.0.1",
+		TTL:           50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("ExposeService() error = %v", err)
+	}
+	if exposure.ExpiresAt.IsZero() {
+		t.Error("Expected ExpiresAt to be set")
+	}
+
+	select {
+	case got := <-expired:
+		if got != exposure {
+			t.Error("Expiry handler received a different exposure")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Exposure did not expire")
+	}
+
+	if exposures := manager.GetServiceExposures("container1"); len(exposures) != 0 {
+		t.Errorf("Expected expired exposure to be removed, got %d exposures", len(exposures))
+	}
+
+	// The forwarder must have released the host port
+	listener, err = net.Listen("tcp", exposure.Destination)
+	if err != nil {
+		t.Fatalf("Expected host port to be released: %v", err)
+	}
+	listener.Close()
+}
+
+func TestExposureExpiryCancelledByUnexpose(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find free port: %v", err)
+	}
+	freePort := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	manager := &ServiceExposureManager{
+		exposures: make(map[string][]*ServiceExposure),
+		portMaps:  make(map[string][]*PortMapping),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+
+	expired := make(chan *ServiceExposure, 1)
+	manager.SetExpiryHandler(func(exposure *ServiceExposure) {
+		expired <- exposure
+	})
+
+	_, err = manager.ExposeService("container1", "net1", net.ParseIP("127.0.0.1"), ExposedPort{
+		ContainerPort: freePort,
+		Protocol:      "tcp",
+		ExposureType:  ExposureTypeIP,
+		TargetIP:      "127.0.0.1",
+		TTL:           100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("ExposeService() error = %v", err)
+	}
+	if _, err := manager.UnexposeService("container1", freePort, "tcp"); err != nil {
+		t.Fatalf("UnexposeService() error = %v", err)
+	}
+
+	select {
+	case <-expired:
+		t.Error("Expiry handler should not run for an exposure that was already removed")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
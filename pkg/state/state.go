@@ -0,0 +1,197 @@
+// Package state provides versioned state files and their startup migrations.
+//
+// State the plugin keeps across restarts is stored in JSON files wrapped in
+// an envelope recording the layout version of the data:
+//
+//	{"schema_version": 1, "data": {...}}
+//
+// Every kind of state file has a Schema listing the migrations that upgrade
+// its data one version at a time. Loading a file written by an older plugin
+// runs the pending migrations, keeps a copy of the original file next to it
+// and rewrites it in the current layout, so layout changes upgrade existing
+// installs cleanly. Files written by a newer plugin are refused on load and
+// never overwritten, so a downgrade cannot discard state it does not
+// understand.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// ErrNewerVersion is returned for state files written by a newer plugin.
+var ErrNewerVersion = errors.New("state file was written by a newer plugin version")
+
+// Migration upgrades the data of a state file by one version.
+type Migration struct {
+	// Description says what the migration changes, e.g. "rename usage to daily_usage"
+	Description string
+	// Migrate converts data of the previous version to the next one
+	Migrate func(data json.RawMessage) (json.RawMessage, error)
+}
+
+// Schema describes the layout versions of one kind of state file.
+//
+// Version 0 is the unversioned layout of files written before they carried
+// an envelope; their whole content is the data. Migrations[i] upgrades
+// version i to version i+1, so the current version is len(Migrations).
+type Schema struct {
+	// Name identifies the state in errors and log messages, e.g. "traffic statistics"
+	Name string
+	// Migrations upgrade the data from version 0 to the current version
+	Migrations []Migration
+}
+
+// envelope is the on-disk form of a versioned state file.
+type envelope struct {
+	Version int             `json:"schema_version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Version returns the current layout version of the schema.
+func (s Schema) Version() int {
+	return len(s.Migrations)
+}
+
+// Load reads a state file into v, migrating it to the current version first.
+//
+// A missing file yields an error matching os.ErrNotExist, and a file written
+// by a newer plugin one matching ErrNewerVersion.
+func Load(path string, schema Schema, v interface{}) error {
+	data, err := schema.Migrate(path)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s file %s: %w", schema.Name, path, err)
+	}
+	return nil
+}
+
+// Save writes v to a state file in the current version.
+//
+// The file is written to a temporary file and renamed into place so a crash
+// never leaves a truncated file behind. A file written by a newer plugin is
+// not overwritten; an error matching ErrNewerVersion is returned instead.
+func Save(path string, schema Schema, v interface{}) error {
+	if raw, err := os.ReadFile(path); err == nil {
+		if version, _, err := decode(raw); err == nil && version > schema.Version() {
+			return newerVersionError(path, schema, version)
+		}
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", schema.Name, err)
+	}
+	return writeEnvelope(path, schema, data)
+}
+
+// Migrate upgrades a state file to the current version and returns its data.
+//
+// Before an older file is rewritten, its original content is kept in
+// <path>.v<version>.bak. The file is left untouched if a migration fails.
+func (s Schema) Migrate(path string) (json.RawMessage, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	version, data, err := decode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s file %s: %w", s.Name, path, err)
+	}
+	if version > s.Version() {
+		return nil, newerVersionError(path, s, version)
+	}
+	if version == s.Version() {
+		return data, nil
+	}
+
+	for from := version; from < s.Version(); from++ {
+		migration := s.Migrations[from]
+		if data, err = migration.Migrate(data); err != nil {
+			return nil, fmt.Errorf("failed to migrate %s file %s from version %d to %d: %w",
+				s.Name, path, from, from+1, err)
+		}
+		log.Printf("Migrated %s file %s from version %d to %d: %s", s.Name, path, from, from+1, migration.Description)
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", path, version)
+	if err := writeFile(backup, raw); err != nil {
+		return nil, fmt.Errorf("failed to back up %s file %s: %w", s.Name, path, err)
+	}
+	if err := writeEnvelope(path, s, data); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Upgraded %s file %s to version %d, previous version kept at %s", s.Name, path, s.Version(), backup)
+	return data, nil
+}
+
+// decode returns the version and data of a state file's content.
+//
+// Content without an envelope is the data of version 0.
+func decode(raw []byte) (int, json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return 0, nil, err
+	}
+	if _, versioned := fields["schema_version"]; !versioned {
+		return 0, raw, nil
+	}
+
+	var file envelope
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return 0, nil, err
+	}
+	if file.Version < 0 {
+		return 0, nil, fmt.Errorf("invalid schema version %d", file.Version)
+	}
+	return file.Version, file.Data, nil
+}
+
+// newerVersionError reports a state file written by a newer plugin.
+func newerVersionError(path string, schema Schema, version int) error {
+	return fmt.Errorf("%s file %s has version %d but this plugin supports up to version %d: %w",
+		schema.Name, path, version, schema.Version(), ErrNewerVersion)
+}
+
+// writeEnvelope writes data in the schema's current version to path.
+func writeEnvelope(path string, schema Schema, data json.RawMessage) error {
+	content, err := json.MarshalIndent(envelope{Version: schema.Version(), Data: data}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", schema.Name, err)
+	}
+	if err := writeFile(path, content); err != nil {
+		return fmt.Errorf("failed to write %s file %s: %w", schema.Name, path, err)
+	}
+	return nil
+}
+
+// writeFile atomically replaces path with content, creating its directory.
+func writeFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
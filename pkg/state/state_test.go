@@ -0,0 +1,159 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// record is the current layout of the test state.
+type record struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// testSchema upgrades {"title": ...} (version 0) to {"name": ...} (version 1)
+// and adds a count (version 2).
+var testSchema = Schema{
+	Name: "test state",
+	Migrations: []Migration{
+		{
+			Description: "rename title to name",
+			Migrate: func(data json.RawMessage) (json.RawMessage, error) {
+				var old struct {
+					Title string `json:"title"`
+				}
+				if err := json.Unmarshal(data, &old); err != nil {
+					return nil, err
+				}
+				return json.Marshal(map[string]string{"name": old.Title})
+			},
+		},
+		{
+			Description: "add count",
+			Migrate: func(data json.RawMessage) (json.RawMessage, error) {
+				var fields map[string]interface{}
+				if err := json.Unmarshal(data, &fields); err != nil {
+					return nil, err
+				}
+				fields["count"] = 1
+				return json.Marshal(fields)
+			},
+		},
+	},
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "test.json")
+
+	var loaded record
+	if err := Load(path, testSchema, &loaded); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected missing file error, got %v", err)
+	}
+
+	if err := Save(path, testSchema, record{Name: "web", Count: 3}); err != nil {
+		t.Fatalf("Save() unexpected error: %v", err)
+	}
+	if err := Load(path, testSchema, &loaded); err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if loaded != (record{Name: "web", Count: 3}) {
+		t.Errorf("Unexpected loaded state: %+v", loaded)
+	}
+
+	content, _ := os.ReadFile(path)
+	if !strings.Contains(string(content), `"schema_version": 2`) {
+		t.Errorf("Expected the file to carry the current version, got %s", content)
+	}
+}
+
+func TestLoadMigratesOlderVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		version int
+	}{
+		{name: "unversioned", content: `{"title": "web"}`, version: 0},
+		{name: "version 1", content: `{"schema_version": 1, "data": {"name": "web"}}`, version: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.json")
+			writeTestFile(t, path, tt.content)
+
+			var loaded record
+			if err := Load(path, testSchema, &loaded); err != nil {
+				t.Fatalf("Load() unexpected error: %v", err)
+			}
+			if loaded != (record{Name: "web", Count: 1}) {
+				t.Errorf("Unexpected migrated state: %+v", loaded)
+			}
+
+			backup, err := os.ReadFile(fmt.Sprintf("%s.v%d.bak", path, tt.version))
+			if err != nil || string(backup) != tt.content {
+				t.Errorf("Expected the original file to be backed up, got %q (%v)", backup, err)
+			}
+
+			// The file was rewritten in the current version
+			content, _ := os.ReadFile(path)
+			if version, _, err := decode(content); err != nil || version != testSchema.Version() {
+				t.Errorf("Expected the file to be upgraded to version %d, got %d (%v)", testSchema.Version(), version, err)
+			}
+		})
+	}
+}
+
+func TestNewerVersionIsRefused(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.json")
+	newer := `{"schema_version": 3, "data": {"name": "web", "count": 1, "tags": []}}`
+	writeTestFile(t, path, newer)
+
+	var loaded record
+	if err := Load(path, testSchema, &loaded); !errors.Is(err, ErrNewerVersion) {
+		t.Errorf("Expected ErrNewerVersion on load, got %v", err)
+	}
+	if err := Save(path, testSchema, record{Name: "web"}); !errors.Is(err, ErrNewerVersion) {
+		t.Errorf("Expected ErrNewerVersion on save, got %v", err)
+	}
+
+	content, _ := os.ReadFile(path)
+	if string(content) != newer {
+		t.Errorf("Expected the newer file to be left untouched, got %s", content)
+	}
+}
+
+func TestFailedMigrationLeavesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.json")
+	original := `{"title": 42}`
+	writeTestFile(t, path, original)
+
+	var loaded record
+	if err := Load(path, testSchema, &loaded); err == nil {
+		t.Fatal("Expected the migration to fail")
+	}
+
+	content, _ := os.ReadFile(path)
+	if string(content) != original {
+		t.Errorf("Expected the file to be left untouched, got %s", content)
+	}
+	if _, err := os.Stat(path + ".v0.bak"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected no backup for a failed migration, got %v", err)
+	}
+
+	writeTestFile(t, path, "not json")
+	if err := Load(path, testSchema, &loaded); err == nil {
+		t.Error("Expected error loading a corrupt file")
+	}
+}
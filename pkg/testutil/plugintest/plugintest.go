@@ -0,0 +1,290 @@
+// Package plugintest provides isolated plugin fixtures for tests.
+//
+// Every fixture is wired to its own in-memory SAM bridge (see pkg/fakesam)
+// and the noop firewall backend, listens on sockets in its own temporary
+// directory and is handed a subnet no other fixture in the process uses (see
+// pkg/testutil). Tests built on the fixtures share no state, need neither an
+// I2P router nor root, and can call t.Parallel. Fixtures are torn down with
+// t.Cleanup.
+//
+// The fixtures only construct the plugin's exported components, so they work
+// from any package outside pkg/plugin, including tests of code built on top
+// of the plugin.
+package plugintest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/fakesam"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/i2p"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/plugin"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/proxy"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/service"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/testutil"
+)
+
+// IPAMData returns IPAM data for a network on subnet with the first address
+// as gateway, as Docker's built-in IPAM driver would pass it.
+func IPAMData(subnet *net.IPNet) []plugin.IPAMData {
+	gateway := make(net.IP, len(subnet.IP))
+	copy(gateway, subnet.IP)
+	gateway[len(gateway)-1]++
+
+	ones, _ := subnet.Mask.Size()
+	return []plugin.IPAMData{{
+		Pool:    subnet.String(),
+		Gateway: gateway.String() + "/" + strconv.Itoa(ones),
+	}}
+}
+
+// NetworkOptions returns the driver options fixture networks are created
+// with. The noop firewall backend accepts the rules of every option,
+// anti-spoofing and isolation included, so the plugin's defaults apply.
+func NetworkOptions() map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+// SAMConfig returns a SAM configuration for server without pacing, so tests
+// creating many sessions are not slowed down.
+func SAMConfig(server *fakesam.Server) *i2p.SAMConfig {
+	host, port := testutil.SAMAddress(server)
+
+	config := i2p.DefaultSAMConfig()
+	config.Host = host
+	config.Port = port
+	config.Timeout = testutil.StartTimeout
+	config.OperationRate = 0
+	return config
+}
+
+// NewTunnelManager returns a tunnel manager connected to a fake SAM bridge of
+// its own, and the bridge.
+func NewTunnelManager(t testing.TB) (*i2p.TunnelManager, *fakesam.Server) {
+	t.Helper()
+
+	server := testutil.StartFakeSAM(t, fakesam.ServerOptions{})
+	tunnelMgr := i2p.NewTunnelManager(i2p.TunnelManagerOptions{SAM: SAMConfig(server)})
+	t.Cleanup(func() { tunnelMgr.DestroyAllTunnels() })
+	return tunnelMgr, server
+}
+
+// NewNetworkManager returns a network manager using the noop firewall backend
+// and a fake SAM bridge of its own, and the bridge. The manager is shut down
+// with t.Cleanup.
+func NewNetworkManager(t testing.TB) (*plugin.NetworkManager, *fakesam.Server) {
+	t.Helper()
+
+	tunnelMgr, server := NewTunnelManager(t)
+	networkMgr, err := plugin.NewNetworkManager(tunnelMgr)
+	if err != nil {
+		t.Fatalf("plugintest: failed to create network manager: %v", err)
+	}
+	if err := networkMgr.SetFirewallBackend(proxy.FirewallNoop); err != nil {
+		t.Fatalf("plugintest: failed to select the noop firewall: %v", err)
+	}
+	t.Cleanup(func() { networkMgr.Shutdown() })
+	return networkMgr, server
+}
+
+// NewServiceManager returns a service exposure manager using a fake SAM
+// bridge of its own, and the bridge. The manager is shut down with t.Cleanup.
+func NewServiceManager(t testing.TB) (*service.ServiceExposureManager, *fakesam.Server) {
+	t.Helper()
+
+	tunnelMgr, server := NewTunnelManager(t)
+	serviceMgr, err := service.NewServiceExposureManager(service.ExposureManagerOptions{TunnelManager: tunnelMgr})
+	if err != nil {
+		t.Fatalf("plugintest: failed to create service exposure manager: %v", err)
+	}
+	t.Cleanup(func() { serviceMgr.Shutdown() })
+	return serviceMgr, server
+}
+
+// PluginFixture is a running plugin with sockets and a SAM bridge of its own.
+type PluginFixture struct {
+	// Plugin is the running plugin
+	Plugin *plugin.Plugin
+	// SAM is the fake SAM bridge the plugin's sessions are created on
+	SAM *fakesam.Server
+	// SocketPath is the Docker plugin API socket
+	SocketPath string
+	// AdminSocketPath is the admin API socket
+	AdminSocketPath string
+	// Subnet is a subnet reserved for the fixture's first network
+	Subnet *net.IPNet
+	// client talks HTTP over the plugin socket
+	client *http.Client
+	// adminClient talks HTTP over the admin socket
+	adminClient *http.Client
+}
+
+// PluginOptions customizes a plugin fixture.
+type PluginOptions struct {
+	// SAM configures the fake SAM bridge
+	SAM fakesam.ServerOptions
+	// Configure is called with the plugin before it starts, e.g. to call its
+	// setters (nil leaves the defaults)
+	Configure func(p *plugin.Plugin) error
+}
+
+// NewPlugin starts a plugin with the noop firewall backend, a fake SAM bridge
+// and plugin and admin sockets in a temporary directory. It is stopped with
+// t.Cleanup.
+func NewPlugin(t testing.TB, options PluginOptions) *PluginFixture {
+	t.Helper()
+
+	server := testutil.StartFakeSAM(t, options.SAM)
+	dir := testutil.TempDir(t)
+
+	f := &PluginFixture{
+		SAM:             server,
+		SocketPath:      filepath.Join(dir, "plugin.sock"),
+		AdminSocketPath: filepath.Join(dir, "admin.sock"),
+		Subnet:          testutil.Subnet(t),
+	}
+
+	p, err := plugin.NewWithSAMConfig(f.SocketPath, SAMConfig(server))
+	if err != nil {
+		t.Fatalf("plugintest: failed to create plugin: %v", err)
+	}
+	if err := p.SetFirewallBackend(proxy.FirewallNoop); err != nil {
+		t.Fatalf("plugintest: failed to select the noop firewall: %v", err)
+	}
+	p.SetAdminSocketPath(f.AdminSocketPath)
+	if options.Configure != nil {
+		if err := options.Configure(p); err != nil {
+			t.Fatalf("plugintest: failed to configure plugin: %v", err)
+		}
+	}
+	f.Plugin = p
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	var startErr error
+	go func() {
+		startErr = p.Start(ctx)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+
+	f.client = unixClient(f.SocketPath)
+	f.adminClient = unixClient(f.AdminSocketPath)
+	if !waitForSocket(f.AdminSocketPath, stopped) {
+		select {
+		case <-stopped:
+			t.Fatalf("plugintest: plugin stopped while starting: %v", startErr)
+		default:
+			t.Fatalf("plugintest: %s is not accepting connections after %s", f.AdminSocketPath, testutil.StartTimeout)
+		}
+	}
+	return f
+}
+
+// Call posts req as JSON to a Docker plugin API endpoint such as
+// "/NetworkDriver.CreateNetwork" and decodes the reply into resp (which may
+// be nil). It fails the test if the request cannot be made; errors reported
+// in the reply's Err field are left to the caller.
+func (f *PluginFixture) Call(t testing.TB, endpoint string, req, resp interface{}) {
+	t.Helper()
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("plugintest: failed to encode %s request: %v", endpoint, err)
+	}
+
+	reply, err := f.client.Post("http://plugin"+endpoint, "application/vnd.docker.plugins.v1+json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("plugintest: %s failed: %v", endpoint, err)
+	}
+	defer reply.Body.Close()
+
+	if resp != nil {
+		if err := json.NewDecoder(reply.Body).Decode(resp); err != nil {
+			t.Fatalf("plugintest: failed to decode %s reply: %v", endpoint, err)
+		}
+	}
+}
+
+// Admin sends a GET request for path to the admin API and decodes the JSON
+// reply into resp (which may be nil). It returns the status code.
+func (f *PluginFixture) Admin(t testing.TB, path string, resp interface{}) int {
+	t.Helper()
+
+	reply, err := f.adminClient.Get("http://admin" + path)
+	if err != nil {
+		t.Fatalf("plugintest: admin request %s failed: %v", path, err)
+	}
+	defer reply.Body.Close()
+
+	if resp != nil {
+		if err := json.NewDecoder(reply.Body).Decode(resp); err != nil {
+			t.Fatalf("plugintest: failed to decode admin reply for %s: %v", path, err)
+		}
+	}
+	return reply.StatusCode
+}
+
+// CreateNetwork creates a network on the fixture's subnet with
+// NetworkOptions merged with options, failing the test on error.
+func (f *PluginFixture) CreateNetwork(t testing.TB, networkID string, options map[string]interface{}) {
+	t.Helper()
+
+	merged := NetworkOptions()
+	for key, value := range options {
+		merged[key] = value
+	}
+
+	var resp plugin.ErrorResponse
+	f.Call(t, "/NetworkDriver.CreateNetwork", plugin.CreateNetworkRequest{
+		NetworkID: networkID,
+		Options:   merged,
+		IPv4Data:  IPAMData(f.Subnet),
+	}, &resp)
+	if resp.Err != "" {
+		t.Fatalf("plugintest: failed to create network %s: %s", networkID, resp.Err)
+	}
+}
+
+// unixClient returns an HTTP client connecting to the Unix socket at path.
+func unixClient(path string) *http.Client {
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+}
+
+// waitForSocket waits until the Unix socket at path accepts connections. It
+// gives up when stopped is closed or after testutil.StartTimeout.
+func waitForSocket(path string, stopped <-chan struct{}) bool {
+	deadline := time.Now().Add(testutil.StartTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-stopped:
+			return false
+		default:
+		}
+
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return false
+}
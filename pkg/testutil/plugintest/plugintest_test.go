@@ -0,0 +1,58 @@
+package plugintest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/plugin"
+	"github.com/go-i2p/go-docker-network-i2p/pkg/testutil"
+)
+
+func TestNewNetworkManager_Parallel(t *testing.T) {
+	for _, name := range []string{"first", "second", "third"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			networkMgr, _ := NewNetworkManager(t)
+			if err := networkMgr.CreateNetwork("net-"+name, NetworkOptions(), IPAMData(testutil.Subnet(t))); err != nil {
+				t.Fatalf("CreateNetwork() unexpected error: %v", err)
+			}
+			if networks := networkMgr.ListNetworks(); len(networks) != 1 {
+				t.Errorf("Expected one network per manager, got %v", networks)
+			}
+		})
+	}
+}
+
+func TestNewServiceManager(t *testing.T) {
+	t.Parallel()
+
+	serviceMgr, _ := NewServiceManager(t)
+	if serviceMgr.Overloaded() {
+		t.Errorf("Expected a new service manager not to be overloaded")
+	}
+}
+
+func TestNewPlugin_Parallel(t *testing.T) {
+	for _, name := range []string{"first", "second"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			f := NewPlugin(t, PluginOptions{})
+			f.CreateNetwork(t, "net-"+name, nil)
+
+			var networks []plugin.AdminNetwork
+			if status := f.Admin(t, "/"+plugin.AdminAPIVersion+"/networks", &networks); status != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d", status)
+			}
+			if len(networks) != 1 || networks[0].ID != "net-"+name {
+				t.Fatalf("Expected only this fixture's network, got %+v", networks)
+			}
+			if networks[0].Subnet != f.Subnet.String() {
+				t.Errorf("Expected subnet %s, got %s", f.Subnet, networks[0].Subnet)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Package testutil provides isolated resources for the plugin's tests.
+//
+// Every fake SAM bridge StartFakeSAM starts listens on a port of its own,
+// every directory TempDir creates is private to the test, and no two calls of
+// Subnet in a process return the same subnet. Tests taking all of their
+// bridges, sockets and subnets from here share no state and can call
+// t.Parallel. Resources are released with t.Cleanup.
+//
+// The package depends on nothing of the plugin but pkg/fakesam, so the tests
+// of every package, pkg/i2p included, can use it. Fully wired plugins and
+// managers are in pkg/testutil/plugintest.
+package testutil
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/fakesam"
+)
+
+// StartTimeout bounds how long helpers wait for something they started to
+// listen.
+const StartTimeout = 5 * time.Second
+
+// subnets counts the subnets handed out by Subnet.
+var subnets atomic.Uint32
+
+// Subnet returns a /24 no other call in the process returns.
+//
+// Subnets are taken from 10.64.0.0/10, which overlaps neither the plugin's
+// default subnet 172.20.0.0/16 nor the DNS synthetic range, so networks
+// created on them never conflict with each other or with defaults.
+func Subnet(t testing.TB) *net.IPNet {
+	t.Helper()
+
+	n := subnets.Add(1) - 1
+	if n >= 1<<14 {
+		t.Fatalf("testutil: all %d test subnets are in use", 1<<14)
+	}
+	return &net.IPNet{
+		IP:   net.IPv4(10, byte(64+n>>8), byte(n), 0).To4(),
+		Mask: net.CIDRMask(24, 32),
+	}
+}
+
+// TempDir returns a new temporary directory removed with t.Cleanup.
+//
+// Unlike t.TempDir its path is short, so Unix sockets created in it stay
+// within the 108-byte path limit whatever the test's name.
+func TempDir(t testing.TB) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "i2pnet-")
+	if err != nil {
+		t.Fatalf("testutil: failed to create temporary directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// StartFakeSAM starts a fake SAM bridge on a free loopback port and closes
+// it with t.Cleanup.
+func StartFakeSAM(t testing.TB, options fakesam.ServerOptions) *fakesam.Server {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("testutil: failed to listen for the fake SAM bridge: %v", err)
+	}
+
+	server := fakesam.NewServer(options)
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	// Serve records the listener asynchronously
+	deadline := time.Now().Add(StartTimeout)
+	for server.Addr() == "" {
+		if time.Now().After(deadline) {
+			t.Fatalf("testutil: fake SAM bridge did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return server
+}
+
+// SAMAddress returns the host and port a fake SAM bridge listens on, the
+// fields of an i2p.SAMConfig.
+func SAMAddress(server *fakesam.Server) (string, int) {
+	host, portText, _ := net.SplitHostPort(server.Addr())
+	port, _ := strconv.Atoi(portText)
+	return host, port
+}
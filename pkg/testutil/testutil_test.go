@@ -0,0 +1,55 @@
+package testutil
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/go-i2p/go-docker-network-i2p/pkg/fakesam"
+)
+
+func TestSubnet_Unique(t *testing.T) {
+	t.Parallel()
+
+	seen := make(map[string]bool)
+	for i := 0; i < 300; i++ {
+		subnet := Subnet(t).String()
+		if seen[subnet] {
+			t.Fatalf("Subnet %s handed out twice", subnet)
+		}
+		seen[subnet] = true
+	}
+}
+
+func TestTempDir(t *testing.T) {
+	t.Parallel()
+
+	var dir string
+	t.Run("create", func(t *testing.T) {
+		dir = TempDir(t)
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			t.Fatalf("Expected %s to be a directory: %v", dir, err)
+		}
+	})
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be removed after the test, got %v", dir, err)
+	}
+}
+
+func TestStartFakeSAM(t *testing.T) {
+	t.Parallel()
+
+	first := StartFakeSAM(t, fakesam.ServerOptions{})
+	second := StartFakeSAM(t, fakesam.ServerOptions{})
+	if first.Addr() == second.Addr() {
+		t.Fatalf("Expected bridges on different ports, both listen on %s", first.Addr())
+	}
+
+	host, port := SAMAddress(first)
+	conn, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		t.Fatalf("Failed to connect to the fake SAM bridge: %v", err)
+	}
+	conn.Close()
+}
@@ -63,6 +63,11 @@ check_prerequisites() {
         exit 1
     fi
     
+    # Check for ebtables (anti-spoofing, can be disabled per network)
+    if ! command -v ebtables &> /dev/null; then
+        print_warn "ebtables is not installed. Networks will need -o i2p.antispoof=false."
+    fi
+    
     print_info "Prerequisites check passed"
 }
 